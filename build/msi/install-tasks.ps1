@@ -152,6 +152,53 @@ try {
         Write-Warning "Cimian Watchdog task registration failed (non-fatal): $($_.Exception.Message)"
     }
 
+    # User notifications task: cimistatus --notify in each logged-in user's
+    # session at logon and every 30 minutes, raising toasts for pending
+    # updates, deferred items about to be forced, and an owed restart.
+    Write-Host "Creating Cimian User Notifications scheduled task..."
+    try {
+        $cimistatusExe = Join-Path $InstallPath "cimistatus.exe"
+        $notifyAction = New-ScheduledTaskAction -Execute $cimistatusExe -Argument "--notify" -WorkingDirectory $InstallPath
+        $notifyTrigger = New-ScheduledTaskTrigger -AtLogOn
+        $notifyTrigger.Repetition = (New-ScheduledTaskTrigger -Once -At (Get-Date) -RepetitionInterval (New-TimeSpan -Minutes 30)).Repetition
+        $notifySettings = New-ScheduledTaskSettingsSet `
+            -ExecutionTimeLimit (New-TimeSpan -Minutes 5) `
+            -AllowStartIfOnBatteries `
+            -DontStopIfGoingOnBatteries `
+            -MultipleInstances IgnoreNew
+        $notifyPrincipal = New-ScheduledTaskPrincipal -GroupId "S-1-5-32-545" -RunLevel Limited
+        Register-ScheduledTask `
+            -TaskName "Cimian User Notifications" `
+            -Action $notifyAction `
+            -Trigger $notifyTrigger `
+            -Settings $notifySettings `
+            -Principal $notifyPrincipal `
+            -Description "Shows Cimian toast notifications to the logged-in user: pending updates, forced installs and required restarts." `
+            -Force `
+            -ErrorAction Stop | Out-Null
+        Write-Host "OK Cimian User Notifications scheduled task created"
+        Write-Host "   Task Name: Cimian User Notifications"
+        Write-Host "   Schedule: At logon, then every 30 minutes"
+        Write-Host "   Command: $cimistatusExe --notify"
+    } catch {
+        # Toasts are best-effort — never block the install on this.
+        Write-Warning "Cimian User Notifications task registration failed (non-fatal): $($_.Exception.Message)"
+    }
+
+    # Tray icon: cimistatus --tray in every user's session at logon. HKLM Run
+    # starts it per user without a task; ShowTrayIcon: false makes it exit at once.
+    Write-Host "Registering Cimian tray icon at logon..."
+    try {
+        $cimistatusExe = Join-Path $InstallPath "cimistatus.exe"
+        Set-ItemProperty -Path "HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Run" -Name "CimianStatus" -Value "`"$cimistatusExe`" --tray" -ErrorAction Stop
+        Write-Host "OK Cimian tray icon registered"
+        Write-Host "   Run key: HKLM\SOFTWARE\Microsoft\Windows\CurrentVersion\Run\CimianStatus"
+        Write-Host "   Command: $cimistatusExe --tray"
+    } catch {
+        # The tray icon is best-effort — never block the install on this.
+        Write-Warning "Cimian tray icon registration failed (non-fatal): $($_.Exception.Message)"
+    }
+
 } catch {
     Write-Error "Failed to create Cimian scheduled task: $_"
     exit 1
@@ -6,7 +6,8 @@ Write-Host "Removing Cimian scheduled tasks..."
 try {
     $taskNames = @(
         "Cimian Managed Software Update Hourly",
-        "Cimian Watchdog"
+        "Cimian Watchdog",
+        "Cimian User Notifications"
     )
 
     foreach ($taskName in $taskNames) {
@@ -25,6 +26,10 @@ try {
 
     Write-Host "✅ Cimian scheduled tasks cleanup completed"
 
+    # The tray icon starts from the Run key, not a task
+    Remove-ItemProperty -Path "HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Run" -Name "CimianStatus" -ErrorAction SilentlyContinue
+    Write-Host "✅ Removed tray icon Run key"
+
 } catch {
     Write-Error "Failed to remove scheduled tasks: $_"
     # Don't exit with error during uninstall to avoid blocking removal
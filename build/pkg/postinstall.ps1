@@ -117,6 +117,35 @@ try {
         }
     }
 
+    # Per-user toast pass: cimistatus --notify at logon and every 30 minutes (best-effort)
+    try {
+        $notifyAction = New-ScheduledTaskAction -Execute (Join-Path $InstallDir "cimistatus.exe") -Argument "--notify" -WorkingDirectory $InstallDir
+        $notifyTrigger = New-ScheduledTaskTrigger -AtLogOn
+        $notifyTrigger.Repetition = (New-ScheduledTaskTrigger -Once -At (Get-Date) -RepetitionInterval (New-TimeSpan -Minutes 30)).Repetition
+        $notifySettings = New-ScheduledTaskSettingsSet -ExecutionTimeLimit (New-TimeSpan -Minutes 5) -AllowStartIfOnBatteries -DontStopIfGoingOnBatteries -MultipleInstances IgnoreNew
+        Register-ScheduledTask `
+            -TaskName "Cimian User Notifications" `
+            -Action $notifyAction `
+            -Trigger $notifyTrigger `
+            -Settings $notifySettings `
+            -Principal (New-ScheduledTaskPrincipal -GroupId "S-1-5-32-545" -RunLevel Limited) `
+            -Description "Shows Cimian toast notifications to the logged-in user" `
+            -Force `
+            -ErrorAction Stop | Out-Null
+        Write-Host "User notifications task registered"
+    } catch {
+        Write-Warning "Failed to register user notifications task: $_"
+    }
+
+    # Tray icon in each user's session at logon (best-effort)
+    try {
+        $trayCommand = "`"$(Join-Path $InstallDir "cimistatus.exe")`" --tray"
+        Set-ItemProperty -Path "HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Run" -Name "CimianStatus" -Value $trayCommand -ErrorAction Stop
+        Write-Host "Tray icon registered at logon"
+    } catch {
+        Write-Warning "Failed to register tray icon: $_"
+    }
+
     # Write version to registry
     try {
         $packageVersion = $env:CIMIAN_VERSION
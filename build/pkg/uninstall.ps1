@@ -10,16 +10,19 @@ $ErrorActionPreference = 'Continue'
 $InstallDir = "C:\Program Files\Cimian"
 Write-Host "CimianTools uninstall: phase=$($env:CIMIAN_PHASE) version=$($env:CIMIAN_VERSION)" -ForegroundColor Yellow
 
-# 1. Remove scheduled task. Harmless if it's already gone.
-try {
-    $task = Get-ScheduledTask -TaskName "Cimian Managed Software Update Hourly" -ErrorAction SilentlyContinue
-    if ($task) {
-        Unregister-ScheduledTask -TaskName "Cimian Managed Software Update Hourly" -Confirm:$false -ErrorAction Stop
-        Write-Host "Removed scheduled task"
+# 1. Remove scheduled tasks. Harmless if they're already gone.
+foreach ($taskName in @("Cimian Managed Software Update Hourly", "Cimian User Notifications")) {
+    try {
+        $task = Get-ScheduledTask -TaskName $taskName -ErrorAction SilentlyContinue
+        if ($task) {
+            Unregister-ScheduledTask -TaskName $taskName -Confirm:$false -ErrorAction Stop
+            Write-Host "Removed scheduled task: $taskName"
+        }
+    } catch {
+        Write-Warning "Failed to remove scheduled task ${taskName}: $_"
     }
-} catch {
-    Write-Warning "Failed to remove scheduled task: $_"
 }
+Remove-ItemProperty -Path "HKLM:\SOFTWARE\Microsoft\Windows\CurrentVersion\Run" -Name "CimianStatus" -ErrorAction SilentlyContinue
 
 # 2. Stop and remove CimianWatcher service. The service binary is about to be
 # deleted by RemoveFiles; uninstalling the service first prevents SCM holding
@@ -5,6 +5,7 @@ using System.IO;
 using System.Linq;
 using System.Security.Principal;
 using System.ServiceProcess;
+using Cimian.Core.Services;
 
 namespace Cimian.CLI.Cimistatus;
 
@@ -131,6 +132,29 @@ class Program
             Console.WriteLine("Never");
         }
 
+        // Last result (status.json)
+        var lastStatus = LastRunStatusStore.Read();
+        if (lastStatus != null)
+        {
+            Console.WriteLine("  Last Result:      " + lastStatus.Outcome + " - " + lastStatus.Message);
+            foreach (var deferral in lastStatus.Deferrals)
+            {
+                Console.WriteLine("  Postponed:        " + deferral.Describe());
+            }
+        }
+
+        // Restart owed by Cimian, then Windows' own pending-reboot sources
+        var restart = RestartStateStore.Read();
+        if (restart != null)
+        {
+            Console.WriteLine("  Restart:          " + restart.Describe(DateTime.Now));
+        }
+        var pendingReboot = PendingReboot.GetReasons();
+        if (pendingReboot.Count > 0)
+        {
+            Console.WriteLine("  Pending Reboot:   " + string.Join("; ", pendingReboot.Select(PendingReboot.Describe)));
+        }
+
         // Logs directory
         Console.Write("  Logs Directory:   ");
         if (Directory.Exists(logsDir))
@@ -25,6 +25,11 @@ public class DiagnosticResult
     /// </summary>
     public bool ExecutablesOK { get; set; }
     
+    /// <summary>
+    /// Whether the CimianWatcher run broker answered.
+    /// </summary>
+    public bool BrokerOK { get; set; }
+    
     /// <summary>
     /// List of issues found during diagnostics.
     /// </summary>
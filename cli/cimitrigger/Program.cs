@@ -44,7 +44,7 @@ public class Program
         rootCommand.AddCommand(guiCommand);
 
         // Headless command
-        var headlessCommand = new Command("headless", "Smart headless update (tries service, falls back to direct)");
+        var headlessCommand = new Command("headless", "Smart headless update (asks CimianWatcher, falls back to direct when elevated)");
         headlessCommand.SetHandler(async () =>
         {
             var elevationService = new ElevationService();
@@ -57,6 +57,81 @@ public class Program
         });
         rootCommand.AddCommand(headlessCommand);
 
+        // Self-service: list optional installs
+        var availableCommand = new Command("available", "List optional software you can install yourself");
+        availableCommand.SetHandler(async () =>
+        {
+            var selfService = new SelfServiceClient();
+            if (!await selfService.ListAvailableAsync())
+            {
+                Environment.Exit(1);
+            }
+        });
+        rootCommand.AddCommand(availableCommand);
+
+        // Self-service: request an optional install
+        var installCommand = new Command("install", "Install optional software (recorded in your self-service manifest and run by CimianWatcher)");
+        var itemArgument = new Argument<string>("item", "Name of the optional item (see 'available')");
+        installCommand.AddArgument(itemArgument);
+        installCommand.SetHandler(async (string item) =>
+        {
+            var selfService = new SelfServiceClient();
+            if (!await selfService.RequestInstallAsync(item))
+            {
+                Environment.Exit(1);
+            }
+        }, itemArgument);
+        rootCommand.AddCommand(installCommand);
+
+        // Self-service: request removal of an installed optional item
+        var removeCommand = new Command("remove", "Remove optional software you installed (subject to the AllowSelfServiceUninstall policy)");
+        var removeItemArgument = new Argument<string>("item", "Name of the installed optional item (see 'available')");
+        removeCommand.AddArgument(removeItemArgument);
+        removeCommand.SetHandler(async (string item) =>
+        {
+            var selfService = new SelfServiceClient();
+            if (!await selfService.RequestRemovalAsync(item))
+            {
+                Environment.Exit(1);
+            }
+        }, removeItemArgument);
+        rootCommand.AddCommand(removeCommand);
+
+        // Auto-run schedule: status, pause, resume
+        var statusCommand = new Command("status", "Show CimianWatcher's auto-run schedule and the last run");
+        statusCommand.SetHandler(async () =>
+        {
+            if (!await new ScheduleClient().ShowStatusAsync())
+            {
+                Environment.Exit(1);
+            }
+        });
+        rootCommand.AddCommand(statusCommand);
+
+        var pauseCommand = new Command("pause", "Hold automatic runs for maintenance (elevated administrators only)");
+        var untilArgument = new Argument<string>("until", "How long (90m, 4h, 2d) or until when (2026-10-20 08:00), at most 30 days");
+        var reasonArgument = new Argument<string?>("reason", () => null, "Why, shown in reports and CimianStatus");
+        pauseCommand.AddArgument(untilArgument);
+        pauseCommand.AddArgument(reasonArgument);
+        pauseCommand.SetHandler(async (string until, string? reason) =>
+        {
+            if (!await new ScheduleClient().PauseAsync(until, reason))
+            {
+                Environment.Exit(1);
+            }
+        }, untilArgument, reasonArgument);
+        rootCommand.AddCommand(pauseCommand);
+
+        var resumeCommand = new Command("resume", "End a maintenance hold early (elevated administrators only)");
+        resumeCommand.SetHandler(async () =>
+        {
+            if (!await new ScheduleClient().ResumeAsync())
+            {
+                Environment.Exit(1);
+            }
+        });
+        rootCommand.AddCommand(resumeCommand);
+
         // Debug command
         var debugCommand = new Command("debug", "Run diagnostics to troubleshoot issues");
         debugCommand.SetHandler(() =>
@@ -68,7 +143,7 @@ public class Program
         rootCommand.AddCommand(debugCommand);
 
         // Force option with subcommand
-        var forceCommand = new Command("--force", "Force direct elevation (skip service attempt)");
+        var forceCommand = new Command("--force", "Run directly from an elevated prompt (skip the service)");
         var forceModeArgument = new Argument<string>("mode", "The mode to use (gui or headless)");
         forceCommand.AddArgument(forceModeArgument);
         forceCommand.SetHandler(async (string mode) =>
@@ -0,0 +1,89 @@
+using System.IO.Pipes;
+using System.Text;
+using Cimian.Core.Services;
+using CimianTools.CimiTrigger.Models;
+
+namespace CimianTools.CimiTrigger.Services;
+
+/// <summary>
+/// Asks the CimianWatcher run broker to start an update, or to report, pause or
+/// resume its auto-run schedule. The service decides whether the current user
+/// may, and runs managedsoftwareupdate as SYSTEM. <see cref="ForControl"/> talks
+/// to the administrators-only control pipe instead of the user pipe.
+/// </summary>
+public class BrokerClient
+{
+    private readonly string _pipeName;
+
+    public BrokerClient(string? pipeName = null)
+    {
+        _pipeName = pipeName ?? RunBrokerProtocol.PipeName;
+    }
+
+    /// <summary>A client for the control pipe; it only opens from an elevated administrator prompt.</summary>
+    public static BrokerClient ForControl() => new(RunBrokerProtocol.ControlPipeName);
+
+    /// <summary>A standard user's "check for updates now".</summary>
+    public static RunBrokerRequest CreateCheckRequest() => new() { Mode = RunBrokerProtocol.CheckMode };
+
+    /// <summary>
+    /// Builds the request sent for a trigger mode, optionally limited to
+    /// <paramref name="items"/> (a self-service install).
+    /// </summary>
+    public static RunBrokerRequest CreateRequest(TriggerMode mode, IReadOnlyList<string>? items = null) => new()
+    {
+        Mode = mode switch
+        {
+            TriggerMode.Gui => "gui",
+            TriggerMode.Headless => "headless",
+            _ => throw new ArgumentException($"Invalid mode: {mode}")
+        },
+        Items = items is { Count: > 0 } ? items.ToList() : null
+    };
+
+    /// <summary>
+    /// Sends a run request. Returns null when the broker isn't reachable (service
+    /// stopped or an older CimianWatcher without the broker).
+    /// </summary>
+    public Task<RunBrokerResponse?> RequestRunAsync(TriggerMode mode, int connectTimeoutMs = 3000, IReadOnlyList<string>? items = null) =>
+        SendAsync(CreateRequest(mode, items), connectTimeoutMs);
+
+    /// <summary>
+    /// Sends any broker request (a run, or status / pause / resume). Returns null
+    /// when the broker isn't reachable, and a refusal when its pipe ACL turns the
+    /// caller away.
+    /// </summary>
+    public async Task<RunBrokerResponse?> SendAsync(RunBrokerRequest request, int connectTimeoutMs = 3000)
+    {
+        using var pipe = new NamedPipeClientStream(".", _pipeName, PipeDirection.InOut, PipeOptions.Asynchronous);
+        try
+        {
+            await pipe.ConnectAsync(connectTimeoutMs);
+        }
+        catch (UnauthorizedAccessException)
+        {
+            return new RunBrokerResponse { Message = "Access denied - this needs an elevated administrator prompt" };
+        }
+        catch (Exception ex) when (ex is TimeoutException or IOException)
+        {
+            return null;
+        }
+
+        try
+        {
+            using var writer = new StreamWriter(pipe, new UTF8Encoding(false), leaveOpen: true) { AutoFlush = true };
+            using var reader = new StreamReader(pipe, Encoding.UTF8, leaveOpen: true);
+
+            await writer.WriteLineAsync(RunBrokerProtocol.Serialize(request));
+
+            using var cts = new CancellationTokenSource(TimeSpan.FromSeconds(30));
+            var line = await reader.ReadLineAsync(cts.Token);
+            return RunBrokerProtocol.Deserialize<RunBrokerResponse>(line)
+                ?? new RunBrokerResponse { Message = "No response from run broker" };
+        }
+        catch (Exception ex) when (ex is IOException or OperationCanceledException)
+        {
+            return new RunBrokerResponse { Message = $"Run broker connection failed: {ex.Message}" };
+        }
+    }
+}
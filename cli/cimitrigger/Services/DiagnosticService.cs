@@ -1,6 +1,7 @@
 using System.Diagnostics;
 using System.ServiceProcess;
 using Cimian.Core;
+using Cimian.Core.Services;
 using CimianTools.CimiTrigger.Models;
 
 namespace CimianTools.CimiTrigger.Services;
@@ -11,12 +12,15 @@ namespace CimianTools.CimiTrigger.Services;
 public class DiagnosticService
 {
     private readonly ElevationService _elevationService;
-    private readonly TriggerService _triggerService;
+    private readonly BrokerClient _brokerClient;
+    private readonly BrokerClient _controlClient;
 
-    public DiagnosticService(ElevationService? elevationService = null, TriggerService? triggerService = null)
+    public DiagnosticService(ElevationService? elevationService = null, BrokerClient? brokerClient = null,
+        BrokerClient? controlClient = null)
     {
         _elevationService = elevationService ?? new ElevationService();
-        _triggerService = triggerService ?? new TriggerService(_elevationService);
+        _brokerClient = brokerClient ?? new BrokerClient();
+        _controlClient = controlClient ?? BrokerClient.ForControl();
     }
 
     /// <summary>
@@ -66,27 +70,26 @@ public class DiagnosticService
             result.Issues.Add("Missing required executables");
         }
 
-        // 5. Test trigger file creation
-        Console.WriteLine("\n5. Testing trigger file creation...");
-        if (result.DirectoryOK)
+        // 5. Ask the user pipe for status (starts nothing)
+        Console.WriteLine($"\n5. Checking the run broker ({RunBrokerProtocol.PipeName})...");
+        result.BrokerOK = CheckBroker(_brokerClient);
+        if (!result.BrokerOK)
         {
-            TestTriggerFile();
-        }
-        else
-        {
-            Console.WriteLine("   ⏭️  Skipped due to directory access issues");
+            result.Issues.Add("CimianWatcher run broker not reachable");
         }
 
-        // 6. Monitor for service response
-        if (result.ServiceRunning && result.DirectoryOK)
+        // 6. The control pipe only opens for elevated administrators
+        if (result.IsAdmin)
         {
-            Console.WriteLine("\n6. Testing service response...");
-            Console.WriteLine("   Testing trigger file monitoring for 30 seconds...");
-            MonitorTriggerResponse();
+            Console.WriteLine($"\n6. Checking the control pipe ({RunBrokerProtocol.ControlPipeName})...");
+            if (!CheckBroker(_controlClient))
+            {
+                result.Issues.Add("CimianWatcher control pipe not reachable");
+            }
         }
         else
         {
-            Console.WriteLine("\n6. Service response test skipped (prerequisites not met)");
+            Console.WriteLine("\n6. Control pipe check skipped (needs an elevated administrator prompt)");
         }
 
         // 7. Environment information
@@ -234,63 +237,25 @@ public class DiagnosticService
     }
 
     /// <summary>
-    /// Creates a test trigger file.
+    /// Sends a status request, which every broker answers without starting anything.
     /// </summary>
-    private void TestTriggerFile()
+    private static bool CheckBroker(BrokerClient client)
     {
-        var timestamp = DateTime.Now.ToString("yyyy-MM-dd HH:mm:ss");
-        var content = $"""
-            Bootstrap triggered at: {timestamp}
-            Mode: GUI
-            Triggered by: cimitrigger debug
-            """;
-
-        try
+        var response = client.SendAsync(new RunBrokerRequest { Mode = RunBrokerProtocol.StatusMode })
+            .GetAwaiter().GetResult();
+        if (response == null)
         {
-            File.WriteAllText(TriggerService.GuiBootstrapFile, content);
-            Console.WriteLine($"   ✅ Created test trigger file: {TriggerService.GuiBootstrapFile}");
-
-            // Verify it's readable
-            var data = File.ReadAllBytes(TriggerService.GuiBootstrapFile);
-            Console.WriteLine($"   ✅ Trigger file contents verified ({data.Length} bytes)");
+            Console.WriteLine("   ❌ No answer - CimianWatcher isn't running or predates the run broker");
+            return false;
         }
-        catch (Exception ex)
+        if (!response.Accepted)
         {
-            Console.WriteLine($"   ❌ Failed to create trigger file: {ex.Message}");
-        }
-    }
-
-    /// <summary>
-    /// Monitors for service response to trigger file.
-    /// </summary>
-    private void MonitorTriggerResponse()
-    {
-        Console.WriteLine("   Monitoring for file deletion (indicates service processed it)...");
-
-        var start = DateTime.Now;
-        while (DateTime.Now - start < TimeSpan.FromSeconds(30))
-        {
-            if (!File.Exists(TriggerService.GuiBootstrapFile))
-            {
-                var elapsed = DateTime.Now - start;
-                Console.WriteLine($"   ✅ File was deleted after {elapsed.TotalSeconds:F1}s - service is responding!");
-                return;
-            }
-
-            Console.Write(".");
-            Thread.Sleep(2000);
+            Console.WriteLine($"   ❌ Refused: {response.Message}");
+            return false;
         }
 
-        Console.WriteLine();
-        Console.WriteLine("   ❌ File was not processed within 30 seconds");
-        Console.WriteLine("   💡 Service may not be running or monitoring correctly");
-
-        // Clean up
-        if (File.Exists(TriggerService.GuiBootstrapFile))
-        {
-            Console.WriteLine("   🧹 Cleaning up test trigger file...");
-            try { File.Delete(TriggerService.GuiBootstrapFile); } catch { }
-        }
+        Console.WriteLine("   ✅ CimianWatcher answered");
+        return true;
     }
 
     /// <summary>
@@ -320,8 +285,8 @@ public class DiagnosticService
         }
 
         Console.WriteLine("\n💡 Alternative methods to try:");
-        Console.WriteLine("   1. cimitrigger --force gui        # Direct elevation (bypasses service)");
-        Console.WriteLine("   2. cimitrigger --force headless   # Direct headless elevation");
+        Console.WriteLine("   1. cimitrigger --force gui        # Direct run from an elevated prompt (bypasses service)");
+        Console.WriteLine("   2. cimitrigger --force headless   # Direct headless run from an elevated prompt");
         Console.WriteLine("   3. Manual PowerShell elevation:");
         Console.WriteLine($"      PowerShell -Command \"Start-Process -FilePath '{CimianPaths.ManagedSoftwareUpdateExe}' -ArgumentList '--auto','--show-status','-vv' -Verb RunAs\"");
 
@@ -369,6 +334,17 @@ public class DiagnosticService
                 Console.WriteLine("   4. Use direct method: cimitrigger --force gui");
                 Console.WriteLine();
             }
+            else if (issue.Contains("run broker", StringComparison.OrdinalIgnoreCase)
+                || issue.Contains("control pipe", StringComparison.OrdinalIgnoreCase))
+            {
+                Console.WriteLine("🔴 RUN BROKER UNREACHABLE:");
+                Console.WriteLine("   Solutions:");
+                Console.WriteLine("   1. Restart the service: net stop CimianWatcher && net start CimianWatcher");
+                Console.WriteLine($"   2. Check {CimianPaths.CimiwatcherLog} for pipe errors");
+                Console.WriteLine("   3. Update Cimian - older CimianWatcher builds have no run broker");
+                Console.WriteLine("   4. Use direct method: cimitrigger --force gui");
+                Console.WriteLine();
+            }
             else if (issue.Contains("directory", StringComparison.OrdinalIgnoreCase))
             {
                 Console.WriteLine("🔴 DIRECTORY ACCESS:");
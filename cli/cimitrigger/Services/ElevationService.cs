@@ -1,11 +1,13 @@
 using System.Diagnostics;
+using System.Security.Principal;
 using Cimian.Core;
 using CimianTools.CimiTrigger.Models;
 
 namespace CimianTools.CimiTrigger.Services;
 
 /// <summary>
-/// Handles elevation of processes using various methods.
+/// Runs managedsoftwareupdate directly when already elevated, and manages the
+/// CimianStatus GUI in the user session.
 /// </summary>
 public class ElevationService
 {
@@ -36,12 +38,24 @@ public class ElevationService
     ];
 
     /// <summary>
-    /// Runs an update with direct elevation, trying multiple methods.
+    /// Runs managedsoftwareupdate directly from this process. Only works when
+    /// cimitrigger is already elevated; standard users go through the
+    /// CimianWatcher run broker instead of being prompted or handed a scheduled task.
     /// </summary>
     /// <param name="mode">The trigger mode (gui or headless).</param>
     /// <returns>Elevation result.</returns>
     public async Task<ElevationResult> RunDirectUpdateAsync(TriggerMode mode)
     {
+        if (!IsAdministrator())
+        {
+            return new ElevationResult
+            {
+                Success = false,
+                Error = "Not running elevated and the CimianWatcher run broker is unavailable. " +
+                        "Start the CimianWatcher service, or run cimitrigger from an elevated prompt."
+            };
+        }
+
         var execPath = FindExecutable();
         if (execPath == null)
         {
@@ -67,147 +81,69 @@ public class ElevationService
         };
         Console.WriteLine(message);
 
-        // Try elevation methods in order
-        var methods = new (string Name, Func<string, string, Task<bool>> Method)[]
-        {
-            ("PowerShell RunAs", RunWithPowerShellAsync),
-            ("Scheduled Task", RunWithScheduledTaskAsync)
-        };
-
-        string? lastError = null;
-        for (int i = 0; i < methods.Length; i++)
+        try
         {
-            Console.WriteLine($"⚡ Using elevation method {i + 1} ({methods[i].Name})...");
-            try
+            var psi = new ProcessStartInfo
             {
-                if (await methods[i].Method(execPath, args))
-                {
-                    Console.WriteLine("✅ Update process started successfully!");
-
-                    // Give time for the process to fully start
-                    Console.WriteLine("⏳ Giving process time to initialize logging...");
-                    await Task.Delay(5000);
-
-                    if (IsProcessRunning("managedsoftwareupdate"))
-                    {
-                        Console.WriteLine("✅ Update process confirmed running - CimianStatus should now show live progress");
-                    }
-                    else
-                    {
-                        Console.WriteLine("📋 Update process completed quickly");
-                        Console.WriteLine($"💡 Check CimianStatus GUI for results, or view logs in {CimianPaths.LogsDir}");
-                    }
-
-                    return new ElevationResult
-                    {
-                        Success = true,
-                        Method = methods[i].Name
-                    };
-                }
-            }
-            catch (Exception ex)
+                FileName = execPath,
+                Arguments = args,
+                UseShellExecute = false,
+                CreateNoWindow = mode == TriggerMode.Headless
+            };
+
+            using var process = Process.Start(psi);
+            if (process == null)
             {
-                Console.WriteLine($"📋 Method {i + 1} unavailable, trying next: {ex.Message}");
-                lastError = ex.Message;
+                return new ElevationResult { Success = false, Error = "Failed to start managedsoftwareupdate.exe" };
             }
-        }
-
-        return new ElevationResult
-        {
-            Success = false,
-            Error = $"All elevation methods failed, last error: {lastError}"
-        };
-    }
-
-    /// <summary>
-    /// Elevates using PowerShell Start-Process with -Verb RunAs.
-    /// </summary>
-    private async Task<bool> RunWithPowerShellAsync(string execPath, string args)
-    {
-        var psCommand = $"Start-Process -FilePath '{execPath}' -ArgumentList '{args}' -Verb RunAs";
-        
-        var psi = new ProcessStartInfo
-        {
-            FileName = "powershell.exe",
-            Arguments = $"-ExecutionPolicy Bypass -Command \"{psCommand}\"",
-            UseShellExecute = false,
-            CreateNoWindow = true
-        };
 
-        using var process = Process.Start(psi);
-        if (process == null) return false;
-        
-        await process.WaitForExitAsync();
-        return process.ExitCode == 0;
-    }
+            Console.WriteLine($"✅ Update process started successfully (PID: {process.Id})");
 
-    /// <summary>
-    /// Elevates using a scheduled task with SYSTEM account.
-    /// </summary>
-    private async Task<bool> RunWithScheduledTaskAsync(string execPath, string args)
-    {
-        var taskName = $"CimianDirect_{DateTimeOffset.UtcNow.ToUnixTimeSeconds()}";
+            // Give time for the process to fully start
+            Console.WriteLine("⏳ Giving process time to initialize logging...");
+            await Task.Delay(5000);
 
-        // Create the task
-        var createArgs = $"/Create /TN \"{taskName}\" /TR \"\\\"{execPath}\\\" {args}\" /SC ONCE /ST 23:59 /RU SYSTEM /F";
-        
-        var createPsi = new ProcessStartInfo
-        {
-            FileName = "schtasks.exe",
-            Arguments = createArgs,
-            UseShellExecute = false,
-            CreateNoWindow = true
-        };
-
-        using (var createProcess = Process.Start(createPsi))
-        {
-            if (createProcess == null) return false;
-            await createProcess.WaitForExitAsync();
-            if (createProcess.ExitCode != 0)
+            if (IsProcessRunning("managedsoftwareupdate"))
             {
-                throw new InvalidOperationException("Failed to create scheduled task");
+                Console.WriteLine("✅ Update process confirmed running - CimianStatus should now show live progress");
             }
-        }
-
-        // Run the task
-        var runPsi = new ProcessStartInfo
-        {
-            FileName = "schtasks.exe",
-            Arguments = $"/Run /TN \"{taskName}\"",
-            UseShellExecute = false,
-            CreateNoWindow = true
-        };
-
-        bool success = false;
-        using (var runProcess = Process.Start(runPsi))
-        {
-            if (runProcess != null)
+            else
             {
-                await runProcess.WaitForExitAsync();
-                success = runProcess.ExitCode == 0;
+                Console.WriteLine("📋 Update process completed quickly");
+                Console.WriteLine($"💡 Check CimianStatus GUI for results, or view logs in {CimianPaths.LogsDir}");
             }
-        }
 
-        // Clean up the task in background
-        _ = Task.Run(async () =>
+            return new ElevationResult
+            {
+                Success = true,
+                Method = "Direct"
+            };
+        }
+        catch (Exception ex)
         {
-            await Task.Delay(10000);
-            var deletePsi = new ProcessStartInfo
+            return new ElevationResult
             {
-                FileName = "schtasks.exe",
-                Arguments = $"/Delete /TN \"{taskName}\" /F",
-                UseShellExecute = false,
-                CreateNoWindow = true
+                Success = false,
+                Error = $"Failed to start managedsoftwareupdate.exe: {ex.Message}"
             };
-            Process.Start(deletePsi)?.WaitForExit();
-        });
+        }
+    }
 
-        if (!success)
+    /// <summary>
+    /// Checks if the current process has admin privileges.
+    /// </summary>
+    public static bool IsAdministrator()
+    {
+        try
         {
-            throw new InvalidOperationException("Failed to run scheduled task");
+            using var identity = WindowsIdentity.GetCurrent();
+            var principal = new WindowsPrincipal(identity);
+            return principal.IsInRole(WindowsBuiltInRole.Administrator);
+        }
+        catch
+        {
+            return false;
         }
-
-        return true;
     }
 
     /// <summary>
@@ -0,0 +1,106 @@
+using Cimian.Core.Services;
+
+namespace CimianTools.CimiTrigger.Services;
+
+/// <summary>
+/// cimitrigger status / pause / resume: reads CimianWatcher's auto-run schedule
+/// (AutoRunIntervalMinutes) and starts or ends a maintenance hold through the
+/// run broker. Pausing and resuming go over the control pipe, so they need an
+/// elevated administrator prompt; status uses the user pipe.
+/// </summary>
+public class ScheduleClient
+{
+    private readonly BrokerClient _brokerClient;
+    private readonly BrokerClient _controlClient;
+
+    public ScheduleClient(BrokerClient? brokerClient = null, BrokerClient? controlClient = null)
+    {
+        _brokerClient = brokerClient ?? new BrokerClient();
+        _controlClient = controlClient ?? BrokerClient.ForControl();
+    }
+
+    /// <summary>Console lines describing the schedule.</summary>
+    public static List<string> FormatStatus(ScheduleStatus status)
+    {
+        var lines = new List<string>
+        {
+            status.Enabled
+                ? $"Auto runs: every {status.IntervalMinutes} minutes, plus up to {status.SplayMinutes} minutes splay"
+                : "Auto runs: scheduled task (AutoRunIntervalMinutes is not set)"
+        };
+        if (status.PausedUntil is { } until)
+        {
+            var reason = string.IsNullOrWhiteSpace(status.PauseReason) ? "" : $" - {status.PauseReason}";
+            lines.Add($"Paused until: {until:yyyy-MM-dd HH:mm}{reason}{(string.IsNullOrEmpty(status.PausedBy) ? "" : $" (by {status.PausedBy})")}");
+        }
+        if (status.Enabled)
+        {
+            if (status.WaitingForNetwork)
+            {
+                lines.Add("Next run: as soon as the network is available");
+            }
+            else if (status.NextRun is { } next)
+            {
+                lines.Add($"Next run: {next:yyyy-MM-dd HH:mm}");
+            }
+            if (status.LastScheduledRun is { } scheduled)
+            {
+                lines.Add($"Last scheduled run: {scheduled:yyyy-MM-dd HH:mm}");
+            }
+        }
+
+        if (status.UpdateRunning)
+        {
+            lines.Add("An update is running now");
+        }
+        if (status.LastRunEnd is { } end)
+        {
+            lines.Add($"Last run finished: {end:yyyy-MM-dd HH:mm}{(string.IsNullOrEmpty(status.LastRunOutcome) ? "" : $" ({status.LastRunOutcome})")}");
+        }
+        return lines;
+    }
+
+    public async Task<bool> ShowStatusAsync() =>
+        await SendAsync(_brokerClient, new RunBrokerRequest { Mode = RunBrokerProtocol.StatusMode }, printMessage: false);
+
+    public async Task<bool> PauseAsync(string until, string? reason = null)
+    {
+        if (MaintenanceHoldStore.ParseUntil(until, DateTime.Now) is not { } end)
+        {
+            Console.Error.WriteLine($"❌ '{until}' is not a duration (90m, 4h, 2d) or a date and time.");
+            return false;
+        }
+        return await SendAsync(_controlClient, new RunBrokerRequest { Mode = RunBrokerProtocol.PauseMode, Until = end, Reason = reason }, printMessage: true);
+    }
+
+    public async Task<bool> ResumeAsync() =>
+        await SendAsync(_controlClient, new RunBrokerRequest { Mode = RunBrokerProtocol.ResumeMode }, printMessage: true);
+
+    private static async Task<bool> SendAsync(BrokerClient client, RunBrokerRequest request, bool printMessage)
+    {
+        var response = await client.SendAsync(request);
+        if (response == null)
+        {
+            Console.Error.WriteLine("❌ CimianWatcher isn't reachable. Is the service running? (sc query CimianWatcher)");
+            return false;
+        }
+        if (!response.Accepted)
+        {
+            Console.Error.WriteLine($"❌ {response.Message}");
+            return false;
+        }
+
+        if (printMessage)
+        {
+            Console.WriteLine($"✅ {response.Message}");
+        }
+        if (response.Schedule != null)
+        {
+            foreach (var line in FormatStatus(response.Schedule))
+            {
+                Console.WriteLine(line);
+            }
+        }
+        return true;
+    }
+}
@@ -0,0 +1,189 @@
+using Cimian.Core;
+using Cimian.Core.Models;
+using Cimian.Core.Services;
+using CimianTools.CimiTrigger.Models;
+
+namespace CimianTools.CimiTrigger.Services;
+
+/// <summary>
+/// End-user side of self-service installs and removals: lists the optional
+/// installs from the last run's InstallInfo.yaml, records a request in the
+/// SelfServeManifest, and asks the CimianWatcher run broker to act on it now as
+/// SYSTEM. The same path Managed Software Center uses, for standard users at a prompt.
+/// </summary>
+public class SelfServiceClient
+{
+    private readonly string _installInfoPath;
+    private readonly ISelfServiceManifestService _selfServiceManifest;
+    private readonly BrokerClient _brokerClient;
+
+    public SelfServiceClient(
+        string? installInfoPath = null,
+        ISelfServiceManifestService? selfServiceManifest = null,
+        BrokerClient? brokerClient = null)
+    {
+        _installInfoPath = installInfoPath ?? CimianPaths.InstallInfoYaml;
+        _selfServiceManifest = selfServiceManifest ?? new SelfServiceManifestService();
+        _brokerClient = brokerClient ?? new BrokerClient();
+    }
+
+    /// <summary>
+    /// Optional installs offered to this machine, sorted by display name. Empty
+    /// until managedsoftwareupdate has written InstallInfo.yaml once.
+    /// </summary>
+    public List<InstallInfoItem> LoadOptionalInstalls()
+    {
+        if (!File.Exists(_installInfoPath))
+        {
+            return [];
+        }
+
+        try
+        {
+            var installInfo = YamlUtils.DeserializeInstallInfo(File.ReadAllText(_installInfoPath));
+            return (installInfo?.OptionalInstalls ?? [])
+                .OrderBy(i => DisplayName(i), StringComparer.OrdinalIgnoreCase)
+                .ToList();
+        }
+        catch (Exception ex)
+        {
+            Console.Error.WriteLine($"⚠️  Could not read {_installInfoPath}: {ex.Message}");
+            return [];
+        }
+    }
+
+    /// <summary>
+    /// Console listing: one header line per item with its state, then the first
+    /// line of its description and its icon name.
+    /// </summary>
+    public static List<string> FormatAvailable(IEnumerable<InstallInfoItem> items, ICollection<string> requested, ICollection<string>? removalRequested = null)
+    {
+        var lines = new List<string>();
+        foreach (var item in items)
+        {
+            var state = item.Installed
+                ? removalRequested?.Contains(item.Name, StringComparer.OrdinalIgnoreCase) == true ? "removal requested"
+                    : item.NeedsUpdate ? "update available" : "installed"
+                : requested.Contains(item.Name, StringComparer.OrdinalIgnoreCase) ? "install requested" : "available";
+            lines.Add($"{DisplayName(item)} ({item.Name} {item.Version}) - {state}");
+
+            var description = item.Description?.Split('\n')[0].Trim();
+            if (!string.IsNullOrEmpty(description))
+            {
+                lines.Add($"    {description}");
+            }
+            if (!string.IsNullOrEmpty(item.Icon))
+            {
+                lines.Add($"    icon: {item.Icon}");
+            }
+        }
+        return lines;
+    }
+
+    /// <summary>
+    /// Prints the optional installs and whether each is installed or requested.
+    /// </summary>
+    public async Task<bool> ListAvailableAsync()
+    {
+        var items = LoadOptionalInstalls();
+        if (items.Count == 0)
+        {
+            Console.WriteLine("No optional software is offered to this machine (or Cimian hasn't run yet).");
+            return true;
+        }
+
+        var manifest = await _selfServiceManifest.LoadAsync();
+        foreach (var line in FormatAvailable(items, manifest.ManagedInstalls, manifest.ManagedUninstalls))
+        {
+            Console.WriteLine(line);
+        }
+        return true;
+    }
+
+    /// <summary>
+    /// Records an install request for an optional item and asks the run broker to
+    /// install it now. Returns false when the item isn't offered or the broker
+    /// declines; an unreachable broker still leaves the request for the next run.
+    /// </summary>
+    public async Task<bool> RequestInstallAsync(string itemName)
+    {
+        var item = FindOptionalInstall(itemName);
+        if (item == null)
+        {
+            Console.Error.WriteLine($"❌ '{itemName}' is not optional software offered to this machine. Run 'cimitrigger available' to list it.");
+            return false;
+        }
+        if (item.Installed && !item.NeedsUpdate)
+        {
+            Console.WriteLine($"✅ {DisplayName(item)} is already installed.");
+            return true;
+        }
+
+        await _selfServiceManifest.AddInstallRequestAsync(item.Name);
+        Console.WriteLine($"📝 Install of {DisplayName(item)} requested.");
+
+        return await RunNowAsync(item, "installed", "Installing");
+    }
+
+    /// <summary>
+    /// Records a removal request for an installed optional item and asks the run
+    /// broker to remove it now. Items the AllowSelfServiceUninstall policy (or the
+    /// pkginfo) doesn't let users remove are refused; managedsoftwareupdate checks
+    /// the policy again before acting on the request.
+    /// </summary>
+    public async Task<bool> RequestRemovalAsync(string itemName)
+    {
+        var item = FindOptionalInstall(itemName);
+        if (item == null)
+        {
+            Console.Error.WriteLine($"❌ '{itemName}' is not optional software offered to this machine. Run 'cimitrigger available' to list it.");
+            return false;
+        }
+        if (!item.Installed)
+        {
+            Console.WriteLine($"✅ {DisplayName(item)} is not installed.");
+            return true;
+        }
+        if (!item.Uninstallable)
+        {
+            Console.Error.WriteLine($"❌ {DisplayName(item)} can't be removed through self-service on this machine. Ask your administrator.");
+            return false;
+        }
+
+        await _selfServiceManifest.AddRemovalRequestAsync(item.Name);
+        Console.WriteLine($"📝 Removal of {DisplayName(item)} requested.");
+
+        return await RunNowAsync(item, "removed", "Removing");
+    }
+
+    private InstallInfoItem? FindOptionalInstall(string itemName) =>
+        LoadOptionalInstalls()
+            .FirstOrDefault(i => i.Name.Equals(itemName, StringComparison.OrdinalIgnoreCase)
+                || string.Equals(i.DisplayName, itemName, StringComparison.OrdinalIgnoreCase));
+
+    /// <summary>
+    /// Asks the run broker for a headless run of just this item. An unreachable
+    /// broker is fine: the recorded request is picked up by the next scheduled run.
+    /// </summary>
+    private async Task<bool> RunNowAsync(InstallInfoItem item, string pastTense, string progressive)
+    {
+        var response = await _brokerClient.RequestRunAsync(TriggerMode.Headless, items: [item.Name]);
+        if (response == null)
+        {
+            Console.WriteLine($"📋 CimianWatcher isn't reachable - it will be {pastTense} at the next scheduled run.");
+            return true;
+        }
+        if (!response.Accepted)
+        {
+            Console.WriteLine($"⚠️  Run broker declined the request: {response.Message}");
+            Console.WriteLine($"📋 The request stays recorded and will be {pastTense} at the next scheduled run.");
+            return false;
+        }
+
+        Console.WriteLine($"✅ {progressive} {DisplayName(item)} (PID: {response.ProcessId})");
+        return true;
+    }
+
+    private static string DisplayName(InstallInfoItem item) =>
+        string.IsNullOrEmpty(item.DisplayName) ? item.Name : item.DisplayName;
+}
@@ -1,83 +1,65 @@
 using Cimian.Core;
+using Cimian.Core.Services;
 using CimianTools.CimiTrigger.Models;
 
 namespace CimianTools.CimiTrigger.Services;
 
 /// <summary>
-/// Handles trigger file creation and monitoring for service-based updates.
+/// Starts updates through CimianWatcher's local IPC. Elevated administrators ask
+/// for the mode they chose on the control pipe; standard users ask for a
+/// user-requested check on the user pipe. Nothing is written to the flag files.
 /// </summary>
 public class TriggerService
 {
-    /// <summary>
-    /// Bootstrap flag file paths.
-    /// </summary>
-    public static readonly string GuiBootstrapFile = CimianPaths.BootstrapFlagFile;
-    public static readonly string HeadlessBootstrapFile = CimianPaths.HeadlessFlagFile;
-
     private readonly ElevationService _elevationService;
+    private readonly BrokerClient _brokerClient;
+    private readonly BrokerClient _controlClient;
 
-    public TriggerService(ElevationService? elevationService = null)
+    public TriggerService(ElevationService? elevationService = null, BrokerClient? brokerClient = null,
+        BrokerClient? controlClient = null)
     {
         _elevationService = elevationService ?? new ElevationService();
+        _brokerClient = brokerClient ?? new BrokerClient();
+        _controlClient = controlClient ?? BrokerClient.ForControl();
     }
 
     /// <summary>
-    /// Creates a trigger file to signal the service to start an update.
+    /// Asks CimianWatcher to start the update. Returns null when the service
+    /// isn't reachable, so the caller can run directly when elevated.
     /// </summary>
-    /// <param name="flagPath">Path to the trigger file.</param>
-    /// <param name="mode">The update mode.</param>
-    /// <returns>True if created successfully.</returns>
-    public bool CreateTriggerFile(string flagPath, string mode)
+    private async Task<bool?> TryRunBrokerAsync(TriggerMode mode)
     {
-        try
+        RunBrokerResponse? response;
+        if (ElevationService.IsAdministrator())
         {
-            // Ensure the directory exists
-            var dir = Path.GetDirectoryName(flagPath);
-            if (!string.IsNullOrEmpty(dir) && !Directory.Exists(dir))
-            {
-                Directory.CreateDirectory(dir);
-            }
-
-            // Create the trigger file with metadata
-            var timestamp = DateTime.Now.ToString("yyyy-MM-dd HH:mm:ss");
-            var content = $"""
-                Bootstrap triggered at: {timestamp}
-                Mode: {mode}
-                Triggered by: cimitrigger CLI
-                """;
-
-            File.WriteAllText(flagPath, content);
-            return true;
+            Console.WriteLine("📡 Requesting update from CimianWatcher...");
+            response = await _controlClient.RequestRunAsync(mode);
         }
-        catch (Exception ex)
+        else
         {
-            Console.WriteLine($"❌ Failed to create trigger file: {ex.Message}");
-            return false;
+            Console.WriteLine("📡 Requesting an update check from CimianWatcher...");
+            response = await _brokerClient.SendAsync(BrokerClient.CreateCheckRequest());
         }
-    }
 
-    /// <summary>
-    /// Waits for the service to process (delete) the trigger file.
-    /// </summary>
-    /// <param name="filePath">Path to the trigger file.</param>
-    /// <param name="timeout">Maximum time to wait.</param>
-    /// <returns>True if the file was processed (deleted).</returns>
-    public async Task<bool> WaitForFileProcessingAsync(string filePath, TimeSpan timeout)
-    {
-        var start = DateTime.Now;
-        while (DateTime.Now - start < timeout)
+        if (response == null)
         {
-            if (!File.Exists(filePath))
-            {
-                return true; // File was deleted - service processed it
-            }
-            await Task.Delay(500);
+            Console.WriteLine("📋 CimianWatcher isn't reachable (sc query CimianWatcher)");
+            return null;
         }
+
+        if (response.Accepted)
+        {
+            Console.WriteLine($"✅ Update started by CimianWatcher (PID: {response.ProcessId})");
+            return true;
+        }
+
+        Console.WriteLine($"⚠️  CimianWatcher declined the request: {response.Message}");
         return false;
     }
 
     /// <summary>
-    /// Runs a smart GUI update - tries service method first, falls back to direct elevation.
+    /// Runs a smart GUI update - asks CimianWatcher, then runs directly when
+    /// already elevated.
     /// </summary>
     public async Task<bool> RunSmartGUIUpdateAsync()
     {
@@ -100,98 +82,46 @@ public class TriggerService
             Console.WriteLine("💡 CimianStatus GUI will show the latest results");
         }
 
-        // Step 1: Try service method first
-        Console.WriteLine("📡 Trying service-based update method...");
-        if (!CreateTriggerFile(GuiBootstrapFile, "GUI"))
+        // Step 1: Ask CimianWatcher
+        var brokered = await TryRunBrokerAsync(TriggerMode.Gui);
+        if (brokered.HasValue)
         {
-            Console.WriteLine("📋 Service method unavailable (trigger file creation failed)");
-            Console.WriteLine("🔄 Using direct elevation method...");
-            var result = await _elevationService.RunDirectUpdateAsync(TriggerMode.Gui);
-            return result.Success;
+            return brokered.Value;
         }
 
-        Console.WriteLine("✅ Service trigger created successfully");
-        Console.WriteLine($"📁 Trigger file: {GuiBootstrapFile}");
-        Console.WriteLine("⏳ Waiting for CimianWatcher service response...");
-
-        // Wait and check if file gets processed
-        if (await WaitForFileProcessingAsync(GuiBootstrapFile, TimeSpan.FromSeconds(15)))
-        {
-            Console.WriteLine("✅ Service-based update initiated successfully!");
-
-            // Give the service a moment to start the process
-            await Task.Delay(2000);
-
-            // Check for Session 0 isolation issue
-            if (_elevationService.IsGUIRunningInSession0())
-            {
-                Console.WriteLine("⚠️  Detected GUI running in Session 0 (service session)");
-                Console.WriteLine("🔄 Switching to direct elevation for proper user session...");
-                _elevationService.KillSession0GUI();
-                var result = await _elevationService.RunDirectUpdateAsync(TriggerMode.Gui);
-                return result.Success;
-            }
-
-            // Ensure GUI is visible in user session
-            if (!_elevationService.IsGUIRunningInUserSession())
-            {
-                Console.WriteLine("🔄 Service completed - ensuring GUI remains visible in user session...");
-                _elevationService.LaunchGUIInUserSession();
-            }
-
-            return true;
-        }
-        else
+        // Step 2: Service unavailable - run directly (elevated prompts only)
+        Console.WriteLine("🔄 Using direct elevation method...");
+        var result = await _elevationService.RunDirectUpdateAsync(TriggerMode.Gui);
+        if (!result.Success)
         {
-            Console.WriteLine("📋 Service method timed out - using direct elevation method...");
-            Console.WriteLine("🔄 This is normal and ensures the update completes successfully");
-
-            // Clean up the trigger file
-            try { File.Delete(GuiBootstrapFile); } catch { }
-
-            var result = await _elevationService.RunDirectUpdateAsync(TriggerMode.Gui);
-            return result.Success;
+            Console.WriteLine($"❌ {result.Error}");
         }
+        return result.Success;
     }
 
     /// <summary>
-    /// Runs a smart headless update - tries service method first, falls back to direct elevation.
+    /// Runs a smart headless update - asks CimianWatcher, then runs directly when
+    /// already elevated.
     /// </summary>
     public async Task<bool> RunSmartHeadlessUpdateAsync()
     {
         Console.WriteLine("🚀 Starting smart headless update...");
 
-        // Step 1: Try service method first
-        Console.WriteLine("📡 Attempting service method first...");
-        if (!CreateTriggerFile(HeadlessBootstrapFile, "headless"))
+        // Step 1: Ask CimianWatcher
+        var brokered = await TryRunBrokerAsync(TriggerMode.Headless);
+        if (brokered.HasValue)
         {
-            Console.WriteLine($"⚠️  Service method failed (trigger file creation)");
-            Console.WriteLine("🔄 Falling back to direct elevation...");
-            var result = await _elevationService.RunDirectUpdateAsync(TriggerMode.Headless);
-            return result.Success;
+            return brokered.Value;
         }
 
-        Console.WriteLine("✅ Headless update trigger file created successfully");
-        Console.WriteLine($"📁 Trigger file location: {HeadlessBootstrapFile}");
-        Console.WriteLine("⏳ Waiting for CimianWatcher service to process the request...");
-
-        // Wait and check if file gets processed
-        if (await WaitForFileProcessingAsync(HeadlessBootstrapFile, TimeSpan.FromSeconds(15)))
+        // Step 2: Service unavailable - run directly (elevated prompts only)
+        Console.WriteLine("🔄 Falling back to direct elevation...");
+        var result = await _elevationService.RunDirectUpdateAsync(TriggerMode.Headless);
+        if (!result.Success)
         {
-            Console.WriteLine("✅ Service method successful - update should be starting!");
-            return true;
-        }
-        else
-        {
-            Console.WriteLine("⚠️  Service method failed (not processed within 15 seconds)");
-            Console.WriteLine("🔄 Automatically falling back to direct elevation...");
-
-            // Clean up the trigger file
-            try { File.Delete(HeadlessBootstrapFile); } catch { }
-
-            var result = await _elevationService.RunDirectUpdateAsync(TriggerMode.Headless);
-            return result.Success;
+            Console.WriteLine($"❌ {result.Error}");
         }
+        return result.Success;
     }
 
     /// <summary>
@@ -31,6 +31,7 @@ class Program
     private static async Task<int> RunAsServiceAsync(string[] args)
     {
         ConfigureLogging(isService: true);
+        ServiceCrashHandler.Install();
 
         try
         {
@@ -43,11 +44,23 @@ class Program
                 {
                     services.AddSingleton<FileWatcherService>();
                     services.AddHostedService(sp => sp.GetRequiredService<FileWatcherService>());
+                    services.AddHostedService<RepoChangeMonitorService>();
+                    services.AddSingleton<AutoRunScheduler>();
+                    services.AddHostedService(sp => sp.GetRequiredService<AutoRunScheduler>());
+                    services.AddHostedService<RunBrokerService>();
+                    services.AddHostedService<MetricsService>();
+                    services.AddHostedService<RunWatchdogService>();
+                    services.AddHostedService<BootstrapScreenService>();
+                    // A faulted background service restarts the whole service (ServiceCrashHandler.Watch)
+                    services.Configure<HostOptions>(options =>
+                        options.BackgroundServiceExceptionBehavior = BackgroundServiceExceptionBehavior.Ignore);
                 })
                 .UseSerilog()
                 .Build();
 
-            await host.RunAsync();
+            await host.StartAsync();
+            ServiceCrashHandler.Watch(host);
+            await host.WaitForShutdownAsync();
             return 0;
         }
         catch (Exception ex)
@@ -160,6 +173,13 @@ class Program
                     {
                         services.AddSingleton<FileWatcherService>();
                         services.AddHostedService(sp => sp.GetRequiredService<FileWatcherService>());
+                        services.AddHostedService<RepoChangeMonitorService>();
+                        services.AddSingleton<AutoRunScheduler>();
+                        services.AddHostedService(sp => sp.GetRequiredService<AutoRunScheduler>());
+                        services.AddHostedService<RunBrokerService>();
+                        services.AddHostedService<MetricsService>();
+                        services.AddHostedService<RunWatchdogService>();
+                        services.AddHostedService<BootstrapScreenService>();
                     })
                     .UseSerilog()
                     .Build();
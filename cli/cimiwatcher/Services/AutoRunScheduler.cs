@@ -0,0 +1,447 @@
+using System.Diagnostics;
+using System.Net.NetworkInformation;
+using System.Text.Json.Serialization;
+using Cimian.Core;
+using Cimian.Core.Services;
+using Microsoft.Extensions.Hosting;
+using Microsoft.Extensions.Logging;
+using YamlDotNet.Serialization;
+
+namespace Cimian.CLI.Cimiwatcher.Services;
+
+/// <summary>
+/// The subset of Config.yaml the auto-run scheduler needs.
+/// </summary>
+public class AutoRunConfig
+{
+    public const int MinimumIntervalMinutes = 15;
+    public const int MaximumIntervalMinutes = 1440;
+
+    [YamlMember(Alias = "AutoRunIntervalMinutes")]
+    public int AutoRunIntervalMinutes { get; set; }
+
+    [YamlMember(Alias = "AutoRunSplayMinutes")]
+    public int AutoRunSplayMinutes { get; set; } = 10;
+
+    [YamlMember(Alias = "AutoRunAtStartup")]
+    public bool AutoRunAtStartup { get; set; } = true;
+
+    [YamlMember(Alias = "AutoRunOnNetworkAvailable")]
+    public bool AutoRunOnNetworkAvailable { get; set; } = true;
+
+    /// <summary>An interval of 0 leaves auto runs to the hourly scheduled task.</summary>
+    public bool Enabled => AutoRunIntervalMinutes > 0;
+
+    public TimeSpan Interval => TimeSpan.FromMinutes(
+        Math.Clamp(AutoRunIntervalMinutes, MinimumIntervalMinutes, MaximumIntervalMinutes));
+
+    /// <summary>Never more than the interval, so runs can't bunch up.</summary>
+    public TimeSpan Splay => TimeSpan.FromMinutes(Math.Clamp(AutoRunSplayMinutes, 0, (int)Interval.TotalMinutes));
+}
+
+/// <summary>
+/// What the scheduler keeps across service restarts, in <see cref="CimianPaths.AutoRunScheduleJson"/>.
+/// </summary>
+public class AutoRunScheduleState
+{
+    [JsonPropertyName("last_scheduled_run")]
+    public DateTime? LastScheduledRun { get; set; }
+
+    /// <summary>The scheduler disabled the hourly task, and re-enables it when turned off.</summary>
+    [JsonPropertyName("task_disabled")]
+    public bool TaskDisabled { get; set; }
+}
+
+/// <summary>
+/// When the scheduler's runs come due. Every run is followed by the interval
+/// plus a random share of the splay, so a fleet installed at the same moment
+/// doesn't hit the repo at the same moment.
+/// </summary>
+public static class AutoRunSchedule
+{
+    /// <summary>How long after the service starts its first run may begin, so boot isn't slowed.</summary>
+    public static readonly TimeSpan StartupDelay = TimeSpan.FromMinutes(2);
+
+    /// <summary>
+    /// The first run after the service starts: right after <see cref="StartupDelay"/>
+    /// with AutoRunAtStartup, otherwise an interval after the last scheduled run.
+    /// </summary>
+    /// <param name="jitter">Share of the splay to add, 0 to 1.</param>
+    public static DateTime FirstRun(AutoRunConfig config, DateTime now, DateTime? lastRun, double jitter)
+    {
+        var earliest = now + StartupDelay;
+        var first = config.AutoRunAtStartup
+            ? earliest
+            : Max(earliest, (lastRun ?? now) + config.Interval);
+        return first + config.Splay * jitter;
+    }
+
+    /// <summary>The run after one that started at <paramref name="started"/>.</summary>
+    public static DateTime NextRun(AutoRunConfig config, DateTime started, double jitter) =>
+        started + config.Interval + config.Splay * jitter;
+
+    /// <summary><paramref name="next"/>, held back to the end of a pause.</summary>
+    public static DateTime Due(DateTime next, DateTime? pausedUntil) =>
+        pausedUntil is { } until && until > next ? until : next;
+
+    private static DateTime Max(DateTime a, DateTime b) => a > b ? a : b;
+}
+
+/// <summary>
+/// Runs managedsoftwareupdate --auto every AutoRunIntervalMinutes from inside the
+/// service, replacing the hourly scheduled task (which it disables while on).
+/// Runs go through <see cref="FileWatcherService"/>, so they share the single-run
+/// slot with flag files and brokered runs. A run that comes due while the
+/// machine is offline waits for the network with AutoRunOnNetworkAvailable, and
+/// a pending bootstrap flag file stands in for the scheduled run. A maintenance
+/// hold (<see cref="MaintenanceHoldStore"/>: cimitrigger pause through the run
+/// broker, or managedsoftwareupdate --pause) holds scheduled runs until it ends.
+/// </summary>
+public class AutoRunScheduler : BackgroundService
+{
+    private const string HourlyTaskName = "Cimian Managed Software Update Hourly";
+    private static readonly string StatePath = CimianPaths.AutoRunScheduleJson;
+    private static readonly TimeSpan Recheck = TimeSpan.FromMinutes(5);
+    private static readonly TimeSpan BusyRetry = TimeSpan.FromMinutes(5);
+
+    private readonly ILogger<AutoRunScheduler> _logger;
+    private readonly FileWatcherService _watcher;
+    private readonly SemaphoreSlim _wake = new(0);
+    private readonly object _lock = new();
+
+    private AutoRunScheduleState _state;
+    private AutoRunConfig _config = new();
+    private DateTime? _nextRun;
+    private TimeSpan _scheduledInterval;
+    private bool _startupScheduled;
+    private bool _taskChecked;
+    private bool _waitingForNetwork;
+
+    public AutoRunScheduler(ILogger<AutoRunScheduler> logger, FileWatcherService watcher)
+    {
+        _logger = logger;
+        _watcher = watcher;
+        _state = LoadState();
+    }
+
+    protected override async Task ExecuteAsync(CancellationToken stoppingToken)
+    {
+        NetworkChange.NetworkAvailabilityChanged += OnNetworkAvailabilityChanged;
+        try
+        {
+            while (!stoppingToken.IsCancellationRequested)
+            {
+                var delay = Recheck;
+                try
+                {
+                    delay = await TickAsync(stoppingToken);
+                }
+                catch (OperationCanceledException) when (stoppingToken.IsCancellationRequested)
+                {
+                    break;
+                }
+                catch (Exception ex)
+                {
+                    _logger.LogError(ex, "Error in auto-run scheduler");
+                }
+
+                try
+                {
+                    // Sleep until the next run, a config recheck, or a wake (network back, pause, resume)
+                    await _wake.WaitAsync(delay, stoppingToken);
+                }
+                catch (OperationCanceledException)
+                {
+                    break;
+                }
+            }
+        }
+        finally
+        {
+            NetworkChange.NetworkAvailabilityChanged -= OnNetworkAvailabilityChanged;
+        }
+    }
+
+    /// <summary>One pass: picks up config, starts a due run, and returns how long to sleep.</summary>
+    private async Task<TimeSpan> TickAsync(CancellationToken cancellationToken)
+    {
+        var config = LoadConfig();
+        var now = DateTime.Now;
+        DateTime due;
+        if (MaintenanceHoldStore.Expire(now) is { } ended)
+        {
+            _logger.LogInformation("Maintenance hold by {User} ended", string.IsNullOrEmpty(ended.SetBy) ? "unknown" : ended.SetBy);
+        }
+        lock (_lock)
+        {
+            _config = config;
+            if (!config.Enabled)
+            {
+                _nextRun = null;
+                _waitingForNetwork = false;
+            }
+            else
+            {
+                if (_nextRun == null || config.Interval != _scheduledInterval)
+                {
+                    // A changed interval counts from the last run; only the service's first pass runs at startup
+                    var first = _startupScheduled ? WithoutStartupRun(config) : config;
+                    _startupScheduled = true;
+                    _nextRun = AutoRunSchedule.FirstRun(first, now, _state.LastScheduledRun, Random.Shared.NextDouble());
+                    _scheduledInterval = config.Interval;
+                    _logger.LogInformation("Auto runs every {Interval} minutes (+ up to {Splay} minutes splay); next at {Next:yyyy-MM-dd HH:mm}",
+                        config.Interval.TotalMinutes, config.Splay.TotalMinutes, _nextRun);
+                }
+            }
+        }
+
+        if (!config.Enabled)
+        {
+            SetHourlyTaskDisabled(false);
+            return Recheck;
+        }
+        SetHourlyTaskDisabled(true);
+
+        lock (_lock)
+        {
+            due = AutoRunSchedule.Due(_nextRun!.Value, MaintenanceHoldStore.Read(now)?.Until);
+        }
+        if (now < due)
+        {
+            return Min(due - now, Recheck);
+        }
+
+        await RunDueAsync(config, now, cancellationToken);
+        lock (_lock)
+        {
+            return _nextRun is { } next && next > now ? Min(next - now, Recheck) : Recheck;
+        }
+    }
+
+    private async Task RunDueAsync(AutoRunConfig config, DateTime now, CancellationToken cancellationToken)
+    {
+        if (File.Exists(CimianPaths.BootstrapFlagFile) || File.Exists(CimianPaths.HeadlessFlagFile))
+        {
+            // FileWatcherService is about to run for the flag file (or bootstrap); that run stands in for this one
+            _logger.LogInformation("Scheduled run skipped: a flag file run is pending");
+            Reschedule(config, now);
+            return;
+        }
+
+        if (config.AutoRunOnNetworkAvailable && !NetworkInterface.GetIsNetworkAvailable())
+        {
+            lock (_lock)
+            {
+                if (!_waitingForNetwork)
+                {
+                    _logger.LogInformation("Scheduled run due but the machine is offline; waiting for the network");
+                }
+                _waitingForNetwork = true;
+            }
+            return;
+        }
+
+        var pid = await _watcher.TryStartRunAsync("--auto", withGUI: false, "scheduler", cancellationToken, "Scheduled");
+        if (pid == null)
+        {
+            lock (_lock)
+            {
+                _nextRun = now + BusyRetry;
+            }
+            return;
+        }
+
+        Reschedule(config, now);
+        lock (_lock)
+        {
+            _state.LastScheduledRun = now;
+            SaveState();
+        }
+    }
+
+    private void Reschedule(AutoRunConfig config, DateTime now)
+    {
+        lock (_lock)
+        {
+            _waitingForNetwork = false;
+            _nextRun = AutoRunSchedule.NextRun(config, now, Random.Shared.NextDouble());
+        }
+    }
+
+    private void OnNetworkAvailabilityChanged(object? sender, NetworkAvailabilityEventArgs e)
+    {
+        bool waiting;
+        lock (_lock)
+        {
+            waiting = _waitingForNetwork;
+        }
+        if (e.IsAvailable && waiting)
+        {
+            _logger.LogInformation("Network available - starting the scheduled run that was waiting for it");
+            _wake.Release();
+        }
+    }
+
+    /// <summary>The schedule as the run broker reports it.</summary>
+    public ScheduleStatus Status()
+    {
+        var lastRun = LastRunStatusStore.Read();
+        var hold = MaintenanceHoldStore.Read(DateTime.Now);
+        lock (_lock)
+        {
+            return new ScheduleStatus
+            {
+                Enabled = _config.Enabled,
+                IntervalMinutes = _config.Enabled ? (int)_config.Interval.TotalMinutes : 0,
+                SplayMinutes = _config.Enabled ? (int)_config.Splay.TotalMinutes : 0,
+                NextRun = _config.Enabled && _nextRun is { } next ? AutoRunSchedule.Due(next, hold?.Until) : null,
+                PausedUntil = hold?.Until,
+                PausedBy = hold?.SetBy,
+                PauseReason = hold?.Reason,
+                WaitingForNetwork = _waitingForNetwork,
+                UpdateRunning = _watcher.IsUpdateRunning,
+                LastScheduledRun = _state.LastScheduledRun,
+                LastRunEnd = lastRun?.EndTime,
+                LastRunOutcome = lastRun?.Outcome
+            };
+        }
+    }
+
+    /// <summary>
+    /// Starts a maintenance hold until <paramref name="until"/>: scheduled runs and
+    /// the hourly task's runs wait. Flag files and run-now requests still run.
+    /// Throws <see cref="ArgumentException"/> for an end time the hold can't have.
+    /// </summary>
+    public ScheduleStatus Pause(DateTime until, string user, string? reason)
+    {
+        MaintenanceHoldStore.Set(until, reason, user, DateTime.Now);
+        _logger.LogInformation("{User} paused automatic runs until {Until:yyyy-MM-dd HH:mm}{Reason}", user, until,
+            string.IsNullOrWhiteSpace(reason) ? "" : $": {reason.Trim()}");
+        _wake.Release();
+        return Status();
+    }
+
+    public ScheduleStatus Resume(string user)
+    {
+        if (MaintenanceHoldStore.Clear(DateTime.Now) is { } hold)
+        {
+            _logger.LogInformation("{User} ended the maintenance hold set by {SetBy}", user, hold.SetBy);
+        }
+        _wake.Release();
+        return Status();
+    }
+
+    /// <summary>
+    /// Keeps the hourly scheduled task from doubling up on the scheduler's runs:
+    /// disabled once per service start while the scheduler is on (an MSI repair
+    /// re-creates it enabled), enabled again only if the scheduler disabled it.
+    /// </summary>
+    private void SetHourlyTaskDisabled(bool disable)
+    {
+        if (disable ? _taskChecked : !_state.TaskDisabled)
+        {
+            return;
+        }
+        _taskChecked = disable;
+
+        var (exitCode, output) = RunSchtasks($"/Change /TN \"{HourlyTaskName}\" {(disable ? "/DISABLE" : "/ENABLE")}");
+        if (exitCode != 0)
+        {
+            _logger.LogDebug("Could not {Action} '{Task}': {Output}", disable ? "disable" : "enable", HourlyTaskName, output.Trim());
+            if (!disable)
+            {
+                lock (_lock)
+                {
+                    _state.TaskDisabled = false;
+                    SaveState();
+                }
+            }
+            return;
+        }
+
+        if (disable)
+        {
+            _logger.LogInformation("Disabled '{Task}': CimianWatcher schedules auto runs", HourlyTaskName);
+        }
+        else
+        {
+            _logger.LogInformation("Re-enabled '{Task}': AutoRunIntervalMinutes is off", HourlyTaskName);
+        }
+        lock (_lock)
+        {
+            _state.TaskDisabled = disable;
+            SaveState();
+        }
+    }
+
+    private static (int ExitCode, string Output) RunSchtasks(string arguments)
+    {
+        try
+        {
+            using var process = Process.Start(new ProcessStartInfo
+            {
+                FileName = "schtasks.exe",
+                Arguments = arguments,
+                UseShellExecute = false,
+                RedirectStandardOutput = true,
+                RedirectStandardError = true,
+                CreateNoWindow = true
+            });
+            if (process == null)
+            {
+                return (-1, "schtasks.exe did not start");
+            }
+            var output = process.StandardOutput.ReadToEnd() + process.StandardError.ReadToEnd();
+            process.WaitForExit(30_000);
+            return (process.HasExited ? process.ExitCode : -1, output);
+        }
+        catch (Exception ex) when (ex is System.ComponentModel.Win32Exception or InvalidOperationException)
+        {
+            return (-1, ex.Message);
+        }
+    }
+
+    private static AutoRunConfig WithoutStartupRun(AutoRunConfig config) => new()
+    {
+        AutoRunIntervalMinutes = config.AutoRunIntervalMinutes,
+        AutoRunSplayMinutes = config.AutoRunSplayMinutes,
+        AutoRunAtStartup = false,
+        AutoRunOnNetworkAvailable = config.AutoRunOnNetworkAvailable
+    };
+
+    private static TimeSpan Min(TimeSpan a, TimeSpan b) => a < b ? a : b;
+
+    private AutoRunConfig LoadConfig()
+    {
+        try
+        {
+            if (File.Exists(CimianPaths.ConfigYaml))
+            {
+                return YamlUtils.Deserializer.Deserialize<AutoRunConfig>(File.ReadAllText(CimianPaths.ConfigYaml)) ?? new AutoRunConfig();
+            }
+        }
+        catch (Exception ex)
+        {
+            _logger.LogWarning("Could not read {Path}: {Message}", CimianPaths.ConfigYaml, ex.Message);
+        }
+        return new AutoRunConfig();
+    }
+
+    private AutoRunScheduleState LoadState()
+    {
+        // Corrupt state just means no pause and a fresh schedule
+        return JsonStateFile.TryRead<AutoRunScheduleState>(StatePath) ?? new AutoRunScheduleState();
+    }
+
+    private void SaveState()
+    {
+        try
+        {
+            JsonStateFile.Write(StatePath, _state);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            _logger.LogDebug("Could not persist auto-run schedule state: {Message}", ex.Message);
+        }
+    }
+}
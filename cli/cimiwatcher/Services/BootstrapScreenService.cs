@@ -0,0 +1,130 @@
+using System.Diagnostics;
+using Cimian.Core;
+using Cimian.Core.Services;
+using Microsoft.Extensions.Hosting;
+using Microsoft.Extensions.Logging;
+
+namespace Cimian.CLI.Cimiwatcher.Services;
+
+/// <summary>
+/// Keeps the "Setting up your device" screen (cimistatus --bootstrap) up
+/// while a bootstrap runs, when BootstrapScreen is on. It opens on the logon
+/// screen before anyone logs on and in the user's session once they do; the
+/// logon-screen copy closes itself at logon. The screen closes itself when the
+/// bootstrap ends or times out. A user who skips it isn't shown it again until
+/// the next logon.
+/// </summary>
+public class BootstrapScreenService : BackgroundService
+{
+    private static readonly TimeSpan CheckInterval = TimeSpan.FromSeconds(10);
+    private const string LogonScreen = "(logon screen)";
+
+    private readonly ILogger<BootstrapScreenService> _logger;
+    private Process? _screen;
+    private string? _screenKey;
+
+    // Session and user who skipped the screen; cleared when they log off
+    private string? _skippedKey;
+
+    public BootstrapScreenService(ILogger<BootstrapScreenService> logger)
+    {
+        _logger = logger;
+    }
+
+    protected override async Task ExecuteAsync(CancellationToken stoppingToken)
+    {
+        while (!stoppingToken.IsCancellationRequested)
+        {
+            try
+            {
+                Check();
+            }
+            catch (Exception ex)
+            {
+                _logger.LogError(ex, "Error checking the bootstrap screen");
+            }
+
+            try
+            {
+                await Task.Delay(CheckInterval, stoppingToken);
+            }
+            catch (OperationCanceledException)
+            {
+                break;
+            }
+        }
+    }
+
+    private void Check()
+    {
+        ReapScreen();
+
+        var config = BootstrapScreenConfig.Load();
+        if (!config.Enabled
+            || BootstrapScreen.Evaluate(BootstrapProgressStore.Read(), config, DateTime.Now) != BootstrapScreenState.Show
+            || ConsoleSessionLauncher.ConsoleSessionId() is not { } session)
+        {
+            return;
+        }
+
+        var user = ConsoleSessionLauncher.ConsoleUserName(session) ?? LogonScreen;
+        var key = $"{session}:{user}";
+        if (_skippedKey != null && _skippedKey != key)
+        {
+            _skippedKey = null;
+        }
+        // A logon-screen copy still closing after a logon is given a poll to go
+        if (_screen != null || _skippedKey == key)
+        {
+            return;
+        }
+
+        var cimistatus = CimianPaths.CimiStatusExe;
+        if (!File.Exists(cimistatus))
+        {
+            _logger.LogWarning("Bootstrap screen not shown: CimianStatus not found at {Path}", cimistatus);
+            return;
+        }
+
+        try
+        {
+            _screen = ConsoleSessionLauncher.Start(session, cimistatus, BootstrapScreen.Argument);
+            _screenKey = key;
+            _logger.LogInformation("Showing the bootstrap screen for {User} in session {Session} (PID {Pid})",
+                user, session, _screen.Id);
+        }
+        catch (Exception ex) when (ex is System.ComponentModel.Win32Exception or InvalidOperationException or ArgumentException)
+        {
+            _logger.LogWarning("Could not show the bootstrap screen for {User}: {Message}", user, ex.Message);
+        }
+    }
+
+    private void ReapScreen()
+    {
+        if (_screen is not { HasExited: true } screen)
+        {
+            return;
+        }
+
+        var exitCode = screen.ExitCode;
+        switch (exitCode)
+        {
+            case BootstrapScreenExitCode.Skipped:
+                _skippedKey = _screenKey;
+                _logger.LogInformation("Bootstrap screen skipped by the user ({Key})", _screenKey);
+                break;
+            case BootstrapScreenExitCode.AlreadyShown:
+                _logger.LogDebug("Bootstrap screen already up in that session");
+                break;
+            case BootstrapScreenExitCode.TimedOut:
+                _logger.LogInformation("Bootstrap screen timed out with the bootstrap still running");
+                break;
+            default:
+                _logger.LogInformation("Bootstrap screen closed (exit code {ExitCode})", exitCode);
+                break;
+        }
+        screen.Dispose();
+        _screen = null;
+        _screenKey = null;
+    }
+}
@@ -0,0 +1,198 @@
+using System.ComponentModel;
+using System.Diagnostics;
+using System.Runtime.InteropServices;
+using System.Security.Principal;
+using System.Text;
+
+namespace Cimian.CLI.Cimiwatcher.Services;
+
+/// <summary>
+/// Starts a GUI process on the console session from the service. With a user
+/// logged on it runs as that user on their desktop (WTSQueryUserToken); before
+/// anyone logs on it runs as SYSTEM on the logon screen's desktop, using the
+/// service's own token moved into the console session.
+/// </summary>
+public static class ConsoleSessionLauncher
+{
+    private const uint InvalidSessionId = 0xFFFFFFFF;
+    private const uint CreateUnicodeEnvironment = 0x00000400;
+    private const uint TokenAllAccess = 0xF01FF;
+    private const int TokenSessionId = 12;
+    private const int SecurityImpersonation = 2;
+    private const int TokenPrimary = 1;
+
+    /// <summary>The console session's id, or null when there is no console session (e.g. while it's being switched).</summary>
+    public static uint? ConsoleSessionId()
+    {
+        var session = WTSGetActiveConsoleSessionId();
+        return session == InvalidSessionId ? null : session;
+    }
+
+    /// <summary>DOMAIN\user logged on at the console session, or null at the logon screen.</summary>
+    public static string? ConsoleUserName(uint session)
+    {
+        if (!WTSQueryUserToken(session, out var token))
+        {
+            return null;
+        }
+        try
+        {
+            using var identity = new WindowsIdentity(token);
+            return identity.Name;
+        }
+        finally
+        {
+            CloseHandle(token);
+        }
+    }
+
+    /// <summary>
+    /// Starts <paramref name="fileName"/> in the console session: as the
+    /// logged-on user on winsta0\default, or as SYSTEM on winsta0\Winlogon when
+    /// nobody is. Throws <see cref="Win32Exception"/> if it can't.
+    /// </summary>
+    public static Process Start(uint session, string fileName, string arguments)
+    {
+        var token = IntPtr.Zero;
+        var environment = IntPtr.Zero;
+        try
+        {
+            string desktop;
+            if (WTSQueryUserToken(session, out token))
+            {
+                desktop = @"winsta0\default";
+            }
+            else
+            {
+                token = SystemTokenFor(session);
+                desktop = @"winsta0\Winlogon";
+            }
+
+            if (!CreateEnvironmentBlock(out environment, token, false))
+            {
+                environment = IntPtr.Zero;
+            }
+
+            var startupInfo = new StartupInfo { cb = Marshal.SizeOf<StartupInfo>(), lpDesktop = desktop };
+            var commandLine = new StringBuilder($"\"{fileName}\" {arguments}");
+            if (!CreateProcessAsUser(token, null, commandLine, IntPtr.Zero, IntPtr.Zero, false,
+                    environment != IntPtr.Zero ? CreateUnicodeEnvironment : 0, environment,
+                    Path.GetDirectoryName(fileName), ref startupInfo, out var processInfo))
+            {
+                throw new Win32Exception(Marshal.GetLastWin32Error(), $"CreateProcessAsUser failed for {fileName}");
+            }
+
+            CloseHandle(processInfo.hThread);
+            try
+            {
+                var process = Process.GetProcessById(processInfo.dwProcessId);
+                // Open Process's own handle while ours still keeps the process
+                // around, so ExitCode can be read after it exits
+                _ = process.Handle;
+                return process;
+            }
+            finally
+            {
+                CloseHandle(processInfo.hProcess);
+            }
+        }
+        finally
+        {
+            if (environment != IntPtr.Zero)
+            {
+                DestroyEnvironmentBlock(environment);
+            }
+            if (token != IntPtr.Zero)
+            {
+                CloseHandle(token);
+            }
+        }
+    }
+
+    private static IntPtr SystemTokenFor(uint session)
+    {
+        using var current = WindowsIdentity.GetCurrent(TokenAccessLevels.Duplicate | TokenAccessLevels.Query);
+        if (!DuplicateTokenEx(current.Token, TokenAllAccess, IntPtr.Zero, SecurityImpersonation, TokenPrimary, out var token))
+        {
+            throw new Win32Exception(Marshal.GetLastWin32Error(), "DuplicateTokenEx failed");
+        }
+
+        var sessionId = (int)session;
+        if (!SetTokenInformation(token, TokenSessionId, ref sessionId, sizeof(int)))
+        {
+            var error = Marshal.GetLastWin32Error();
+            CloseHandle(token);
+            throw new Win32Exception(error, "SetTokenInformation(TokenSessionId) failed");
+        }
+        return token;
+    }
+
+    [StructLayout(LayoutKind.Sequential, CharSet = CharSet.Unicode)]
+    private struct StartupInfo
+    {
+        public int cb;
+        public string? lpReserved;
+        public string? lpDesktop;
+        public string? lpTitle;
+        public int dwX;
+        public int dwY;
+        public int dwXSize;
+        public int dwYSize;
+        public int dwXCountChars;
+        public int dwYCountChars;
+        public int dwFillAttribute;
+        public int dwFlags;
+        public short wShowWindow;
+        public short cbReserved2;
+        public IntPtr lpReserved2;
+        public IntPtr hStdInput;
+        public IntPtr hStdOutput;
+        public IntPtr hStdError;
+    }
+
+    [StructLayout(LayoutKind.Sequential)]
+    private struct ProcessInformation
+    {
+        public IntPtr hProcess;
+        public IntPtr hThread;
+        public int dwProcessId;
+        public int dwThreadId;
+    }
+
+    [DllImport("kernel32.dll")]
+    private static extern uint WTSGetActiveConsoleSessionId();
+
+    [DllImport("wtsapi32.dll", SetLastError = true)]
+    private static extern bool WTSQueryUserToken(uint sessionId, out IntPtr token);
+
+    [DllImport("advapi32.dll", SetLastError = true)]
+    private static extern bool DuplicateTokenEx(IntPtr existingToken, uint desiredAccess, IntPtr tokenAttributes,
+        int impersonationLevel, int tokenType, out IntPtr newToken);
+
+    [DllImport("advapi32.dll", SetLastError = true)]
+    private static extern bool SetTokenInformation(IntPtr token, int tokenInformationClass, ref int tokenInformation,
+        int tokenInformationLength);
+
+    [DllImport("userenv.dll", SetLastError = true)]
+    private static extern bool CreateEnvironmentBlock(out IntPtr environment, IntPtr token, bool inherit);
+
+    [DllImport("userenv.dll", SetLastError = true)]
+    private static extern bool DestroyEnvironmentBlock(IntPtr environment);
+
+    [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+    private static extern bool CreateProcessAsUser(
+        IntPtr token,
+        string? applicationName,
+        StringBuilder commandLine,
+        IntPtr processAttributes,
+        IntPtr threadAttributes,
+        bool inheritHandles,
+        uint creationFlags,
+        IntPtr environment,
+        string? currentDirectory,
+        ref StartupInfo startupInfo,
+        out ProcessInformation processInformation);
+
+    [DllImport("kernel32.dll", SetLastError = true)]
+    private static extern bool CloseHandle(IntPtr handle);
+}
@@ -1,11 +1,34 @@
 using System.Diagnostics;
+using System.Security.Principal;
 using Cimian.Core;
 using Cimian.Core.Services;
 using Microsoft.Extensions.Hosting;
 using Microsoft.Extensions.Logging;
+using YamlDotNet.Serialization;
 
 namespace Cimian.CLI.Cimiwatcher.Services;
 
+/// <summary>
+/// The subset of Config.yaml that decides whether the status window waits out Focus Assist.
+/// </summary>
+public class FocusConfig
+{
+    [YamlMember(Alias = "MachineRole")]
+    public string? MachineRole { get; set; }
+
+    [YamlMember(Alias = "RespectFocusAssist")]
+    public bool? RespectFocusAssist { get; set; }
+
+    [YamlMember(Alias = "ShowStatusWindow")]
+    public bool? ShowStatusWindow { get; set; }
+
+    public bool ShouldRespectFocusAssist =>
+        Cimian.Core.Models.MachineRole.ResolveBool(MachineRole, "RespectFocusAssist", RespectFocusAssist, true);
+
+    public bool ShouldShowStatusWindow =>
+        Cimian.Core.Models.MachineRole.ResolveBool(MachineRole, "ShowStatusWindow", ShowStatusWindow, true);
+}
+
 /// <summary>
 /// Background service that monitors bootstrap flag files and triggers updates when detected.
 /// Also checks for pending self-updates on service start.
@@ -16,6 +39,7 @@ public class FileWatcherService : BackgroundService
     private static readonly string HeadlessFlagFile = CimianPaths.HeadlessFlagFile;
     private static readonly string CimianExePath = CimianPaths.ManagedSoftwareUpdateExe;
     private static readonly TimeSpan PollInterval = TimeSpan.FromSeconds(10);
+    private static readonly TimeSpan FocusPollInterval = TimeSpan.FromSeconds(15);
 
     private readonly ILogger<FileWatcherService> _logger;
     private readonly object _lock = new();
@@ -36,12 +60,17 @@ public class FileWatcherService : BackgroundService
         _logger = logger;
     }
 
+    /// <summary>Whether a managedsoftwareupdate run started by the service is in progress.</summary>
+    public bool IsUpdateRunning => Volatile.Read(ref _updateRunning) != 0;
+
     protected override async Task ExecuteAsync(CancellationToken stoppingToken)
     {
         _logger.LogInformation("CimianWatcher file monitoring service started");
         
         // Check for pending self-updates on service start
         CheckAndPerformSelfUpdate();
+
+        ResumeBootstrap();
         
         _logger.LogInformation("Monitoring bootstrap files:");
         _logger.LogInformation("  GUI: {BootstrapFile}", BootstrapFlagFile);
@@ -74,6 +103,36 @@ public class FileWatcherService : BackgroundService
         _logger.LogInformation("CimianWatcher file monitoring service stopped");
     }
 
+    /// <summary>
+    /// A restart cut a bootstrap short: put its flag file back so the first
+    /// poll carries it on. managedsoftwareupdate takes the attempts it has
+    /// left from bootstrap_progress.json.
+    /// </summary>
+    private void ResumeBootstrap()
+    {
+        var progress = BootstrapProgressStore.Read();
+        if (progress is not { IsActive: true } || File.Exists(BootstrapFlagFile))
+        {
+            return;
+        }
+        if (Process.GetProcessesByName("managedsoftwareupdate").Length > 0)
+        {
+            _logger.LogInformation("Bootstrap in progress and managedsoftwareupdate is running - not resuming");
+            return;
+        }
+
+        try
+        {
+            File.WriteAllText(BootstrapFlagFile, $"Bootstrap resumed at: {DateTime.Now:O}\n");
+            _logger.LogInformation("Resuming bootstrap after {Attempts} attempt(s) (started {Started})",
+                progress.Attempts, progress.Started);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            _logger.LogWarning(ex, "Could not resume the bootstrap");
+        }
+    }
+
     private void CheckBootstrapFiles(CancellationToken cancellationToken)
     {
         // Check GUI bootstrap file
@@ -154,11 +213,18 @@ public class FileWatcherService : BackgroundService
 
         _logger.LogInformation("Starting {UpdateType} bootstrap update process", updateType);
 
-        // Read flag file content for optional custom arguments
-        // If the file contains an "Args:" line, use those arguments instead of defaults.
-        // This allows the MSC GUI to request specific modes (--checkonly, --installonly)
-        // without the service guessing.
+        // Flag files are a legacy path: the ProgramData folder they live in is
+        // writable by standard users, and the run happens as SYSTEM. Record who
+        // wrote each one alongside the broker's requests.
+        var (owner, trustedOwner) = FlagFileOwner(flagFile);
+        _logger.LogInformation("{UpdateType} flag file owned by {Owner}", updateType, owner);
+
+        // An "Args:" line replaces the default arguments. Only honored when
+        // SYSTEM or Administrators own the file. Anyone else's Args are refused
+        // outright: running the default instead would turn a narrow request
+        // (a check, one --item) into a full unattended install.
         string? customArgs = null;
+        string? refusedArgs = null;
         bool suppressCimistatus = false;
         try
         {
@@ -166,12 +232,23 @@ public class FileWatcherService : BackgroundService
             foreach (var line in content.Split('\n'))
             {
                 var trimmed = line.Trim();
-                if (trimmed.StartsWith("Args:", StringComparison.OrdinalIgnoreCase))
+                if (!trimmed.StartsWith("Args:", StringComparison.OrdinalIgnoreCase))
+                {
+                    continue;
+                }
+                var args = trimmed.Substring("Args:".Length).Trim();
+                if (trustedOwner)
                 {
-                    customArgs = trimmed.Substring("Args:".Length).Trim();
+                    customArgs = args;
                     suppressCimistatus = true; // caller manages its own UI
                     _logger.LogInformation("Custom args from flag file: {Args}", customArgs);
                 }
+                else
+                {
+                    refusedArgs = args;
+                    _logger.LogWarning("Refusing {UpdateType} flag file owned by {Owner}: only SYSTEM or Administrators may set Args; use the run broker",
+                        updateType, owner);
+                }
             }
         }
         catch (Exception ex)
@@ -179,9 +256,12 @@ public class FileWatcherService : BackgroundService
             _logger.LogWarning(ex, "Could not read flag file content, using defaults");
         }
 
-        // Delete the flag file immediately after reading it, BEFORE launching MSU.
-        // MSC's TriggerService polls for this deletion as the "acknowledged" signal.
-        // If we wait until after MSU finishes, MSC's 30s timeout expires and throws.
+        RunAuditLog.Append(Path.GetFileName(flagFile), owner, refusedArgs ?? customArgs ?? "default",
+            refusedArgs == null ? "consumed" : "refused: Args need a file owned by SYSTEM or Administrators");
+
+        // Delete the flag file immediately after reading it, BEFORE launching MSU,
+        // so a writer polling for the deletion sees it acknowledged. A refused one
+        // is deleted too, or every poll would refuse it again.
         try
         {
             if (File.Exists(flagFile))
@@ -195,9 +275,85 @@ public class FileWatcherService : BackgroundService
             _logger.LogWarning(ex, "Could not delete {UpdateType} flag file early", updateType);
         }
 
+        if (refusedArgs != null)
+        {
+            return;
+        }
+
+        var updateArgs = customArgs ?? (withGUI ? "--auto --show-status -vv" : "--auto --show-status");
+        await RunUpdateProcessAsync(updateArgs, updateType, withGUI, launchStatus: withGUI && !suppressCimistatus,
+            started: null, cancellationToken);
+    }
+
+    private static (string Name, bool Trusted) FlagFileOwner(string flagFile)
+    {
+        try
+        {
+            var sid = new FileInfo(flagFile).GetAccessControl().GetOwner(typeof(SecurityIdentifier)) as SecurityIdentifier;
+            string name;
+            try
+            {
+                name = sid?.Translate(typeof(NTAccount)).Value ?? "unknown";
+            }
+            catch (IdentityNotMappedException)
+            {
+                name = sid?.Value ?? "unknown";
+            }
+            return (name, IsTrustedFlagFileOwner(sid));
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            return ("unknown", false);
+        }
+    }
+
+    /// <summary>
+    /// Whether a flag file's owner may choose the arguments of the SYSTEM run it
+    /// starts: only SYSTEM and BUILTIN\Administrators. Files an elevated
+    /// administrator creates are owned by the Administrators group.
+    /// </summary>
+    public static bool IsTrustedFlagFileOwner(SecurityIdentifier? owner) =>
+        owner != null
+        && (owner.IsWellKnown(WellKnownSidType.LocalSystemSid) || owner.IsWellKnown(WellKnownSidType.BuiltinAdministratorsSid));
+
+    /// <summary>
+    /// Starts a run on behalf of the run broker or the auto-run scheduler. Shares
+    /// the single-run slot with the flag files, so a request while a run is active
+    /// is refused rather than queued. Returns the PID, or null if a run is already
+    /// active or the process failed to start.
+    /// </summary>
+    public async Task<int?> TryStartRunAsync(string updateArgs, bool withGUI, string requestedBy,
+        CancellationToken cancellationToken, string updateType = "Brokered")
+    {
+        if (Interlocked.CompareExchange(ref _updateRunning, 1, 0) != 0)
+        {
+            _logger.LogInformation("Run requested by {User} refused - an update is already running", requestedBy);
+            return null;
+        }
+
+        _logger.LogInformation("Run requested by {User} ({UpdateType}): {Args}", requestedBy, updateType, updateArgs);
+        var started = new TaskCompletionSource<int?>(TaskCreationOptions.RunContinuationsAsynchronously);
+        _ = Task.Run(async () =>
+        {
+            try
+            {
+                await RunUpdateProcessAsync(updateArgs, updateType, withGUI, launchStatus: false, started, cancellationToken);
+            }
+            finally
+            {
+                started.TrySetResult(null);
+                Interlocked.Exchange(ref _updateRunning, 0);
+            }
+        }, CancellationToken.None);
+
+        return await started.Task;
+    }
+
+    private async Task RunUpdateProcessAsync(string updateArgs, string updateType, bool withGUI, bool launchStatus,
+        TaskCompletionSource<int?>? started, CancellationToken cancellationToken)
+    {
         try
         {
-            var updateArgs = customArgs ?? (withGUI ? "--auto --show-status -vv" : "--auto --show-status");
             var updateProcess = new Process
             {
                 StartInfo = new ProcessStartInfo
@@ -216,11 +372,12 @@ public class FileWatcherService : BackgroundService
             }
 
             _logger.LogInformation("Started managedsoftwareupdate process (PID: {Pid})", updateProcess.Id);
+            started?.TrySetResult(updateProcess.Id);
 
             // If GUI mode and caller didn't suppress cimistatus, launch the status UI
-            if (withGUI && !suppressCimistatus)
+            if (launchStatus)
             {
-                LaunchCimianStatus();
+                _ = LaunchCimianStatusWhenAvailableAsync(updateProcess, cancellationToken);
             }
 
             // Wait for the update process to complete
@@ -244,6 +401,60 @@ public class FileWatcherService : BackgroundService
         }
     }
 
+    /// <summary>
+    /// Opens the status window now, or once the user leaves Focus Assist /
+    /// presentation / full-screen. If the run finishes first the window is skipped,
+    /// and it never opens when ShowStatusWindow (or the machine role) turns it off.
+    /// </summary>
+    private async Task LaunchCimianStatusWhenAvailableAsync(Process updateProcess, CancellationToken cancellationToken)
+    {
+        try
+        {
+            var config = LoadFocusConfig();
+            if (!config.ShouldShowStatusWindow)
+            {
+                _logger.LogInformation("CimianStatus UI disabled by ShowStatusWindow / MachineRole {Role}", config.MachineRole ?? "workstation");
+                return;
+            }
+
+            var state = config.ShouldRespectFocusAssist ? FocusAssist.GetState() : FocusState.Available;
+            if (state != FocusState.Available)
+            {
+                _logger.LogInformation("Holding CimianStatus UI: {Reason}", FocusAssist.Describe(state));
+                while (!updateProcess.HasExited && FocusAssist.GetState() != FocusState.Available)
+                {
+                    await Task.Delay(FocusPollInterval, cancellationToken);
+                }
+                if (updateProcess.HasExited)
+                {
+                    _logger.LogInformation("Update finished while the user was busy; CimianStatus UI not shown");
+                    return;
+                }
+            }
+            LaunchCimianStatus();
+        }
+        catch (OperationCanceledException)
+        {
+            // Service stopping
+        }
+    }
+
+    private FocusConfig LoadFocusConfig()
+    {
+        try
+        {
+            if (File.Exists(CimianPaths.ConfigYaml))
+            {
+                return YamlUtils.Deserializer.Deserialize<FocusConfig>(File.ReadAllText(CimianPaths.ConfigYaml)) ?? new FocusConfig();
+            }
+        }
+        catch (Exception ex)
+        {
+            _logger.LogWarning("Could not read {Path}: {Message}", CimianPaths.ConfigYaml, ex.Message);
+        }
+        return new FocusConfig();
+    }
+
     private void LaunchCimianStatus()
     {
         var cimianDir = Path.GetDirectoryName(CimianExePath);
@@ -301,7 +512,8 @@ public class FileWatcherService : BackgroundService
     /// Checks for pending self-updates and, if one is found, launches the installer as a
     /// detached process then exits immediately so the installer can replace CimianWatcher's
     /// own binary without contending with the running service.  Windows SCM will restart the
-    /// service after the installer (and postinstall.ps1) complete.
+    /// service after the installer (and postinstall.ps1) complete. The first start after
+    /// that runs the new version's health check, rolling it back if it fails.
     /// </summary>
     private void CheckAndPerformSelfUpdate()
     {
@@ -310,6 +522,13 @@ public class FileWatcherService : BackgroundService
             if (!SelfUpdateService.IsSelfUpdatePending())
             {
                 _logger.LogInformation("No self-update pending");
+                var health = SelfUpdateService.CheckUpdatedVersion(msg => _logger.LogInformation("{Msg}", msg));
+                if (health?.Status == SelfUpdateHealth.RolledBack)
+                {
+                    // Exit with an error so the SCM restarts the service on the restored binaries
+                    _logger.LogError("Self-update {Version} rolled back: {Detail}; restarting CimianWatcher", health.Version, health.Detail);
+                    Environment.Exit(1);
+                }
                 SelfUpdateService.CleanupStaleBackup();
                 return;
             }
@@ -0,0 +1,385 @@
+using System.Globalization;
+using System.Net;
+using System.Text;
+using System.Text.Json;
+using Cimian.Core;
+using Cimian.Core.Models;
+using Cimian.Core.Services;
+using Microsoft.Extensions.Hosting;
+using Microsoft.Extensions.Logging;
+using YamlDotNet.Serialization;
+
+namespace Cimian.CLI.Cimiwatcher.Services;
+
+/// <summary>
+/// The subset of Config.yaml the metrics endpoint needs.
+/// </summary>
+public class MetricsConfig
+{
+    [YamlMember(Alias = "MetricsPort")]
+    public int MetricsPort { get; set; }
+
+    [YamlMember(Alias = "CachePath")]
+    public string? CachePath { get; set; }
+}
+
+/// <summary>
+/// Cimian health as Prometheus / OpenMetrics samples. Gauges describe the last
+/// run and the machine now; counters add up the runs finished since the service
+/// started, each counted once by its session ID.
+/// </summary>
+public sealed class CimianMetrics
+{
+    /// <summary>Upper bounds (seconds) of the download_duration_seconds buckets.</summary>
+    internal static readonly double[] DownloadBuckets = [1, 5, 15, 30, 60, 120, 300, 600, 1800];
+
+    private readonly object _lock = new();
+    private readonly Dictionary<string, long> _runsByOutcome = new(StringComparer.Ordinal);
+    private readonly long[] _downloadBucketCounts = new long[DownloadBuckets.Length];
+    private string? _lastSessionId;
+    private LastRunStatus? _lastRun;
+    private long _failedInstalls;
+    private long _downloads;
+    private double _downloadSeconds;
+    private long _downloadBytes;
+    private IReadOnlyDictionary<string, int> _itemsByStatus = new Dictionary<string, int>();
+    private long? _cacheBytes;
+    private bool _selfUpdatePending;
+
+    /// <summary>
+    /// Folds a finished run into the counters. Returns false for a session
+    /// already counted. Cache hits aren't downloads and stay out of the histogram.
+    /// </summary>
+    public bool RecordRun(LastRunStatus run, UsageReport? usage)
+    {
+        lock (_lock)
+        {
+            if (run.SessionId == _lastSessionId)
+            {
+                return false;
+            }
+            _lastSessionId = run.SessionId;
+            _lastRun = run;
+            _runsByOutcome[run.Outcome] = _runsByOutcome.GetValueOrDefault(run.Outcome) + 1;
+            _failedInstalls += run.Failures;
+
+            if (usage != null && usage.SessionId == run.SessionId)
+            {
+                foreach (var download in usage.Downloads.Where(d => !d.CacheHit && d.Success))
+                {
+                    var seconds = download.DurationMs / 1000.0;
+                    _downloads++;
+                    _downloadSeconds += seconds;
+                    _downloadBytes += download.Bytes;
+                    for (var i = 0; i < DownloadBuckets.Length; i++)
+                    {
+                        if (seconds <= DownloadBuckets[i])
+                        {
+                            _downloadBucketCounts[i]++;
+                        }
+                    }
+                }
+            }
+            return true;
+        }
+    }
+
+    /// <summary>Replaces the machine-state gauges.</summary>
+    public void SetState(IReadOnlyDictionary<string, int> itemsByStatus, long? cacheBytes, bool selfUpdatePending)
+    {
+        lock (_lock)
+        {
+            _itemsByStatus = itemsByStatus;
+            _cacheBytes = cacheBytes;
+            _selfUpdatePending = selfUpdatePending;
+        }
+    }
+
+    /// <summary>
+    /// The exposition text: Prometheus text format 0.0.4, or OpenMetrics 1.0
+    /// when <paramref name="openMetrics"/> (counter families drop _total from
+    /// their TYPE line and the body ends with # EOF).
+    /// </summary>
+    public string Render(bool openMetrics = false)
+    {
+        var sb = new StringBuilder();
+        lock (_lock)
+        {
+            if (_lastRun is { } run)
+            {
+                Gauge(sb, "cimian_last_run_timestamp_seconds", "Unix time the last managedsoftwareupdate run ended.",
+                    new DateTimeOffset(run.EndTime.ToUniversalTime()).ToUnixTimeSeconds());
+                Gauge(sb, "cimian_last_run_duration_seconds", "Duration of the last run.",
+                    Math.Max(0, (run.EndTime - run.StartTime).TotalSeconds));
+                Gauge(sb, "cimian_last_run_success", "1 when the last run had no failures.", run.Outcome == "success" ? 1 : 0);
+                Gauge(sb, "cimian_last_run_failures", "Items that failed in the last run.", run.Failures);
+            }
+
+            Family(sb, "cimian_items", "gauge", "Managed items by status, from reports/items.json.");
+            foreach (var (status, count) in _itemsByStatus.OrderBy(kv => kv.Key, StringComparer.Ordinal))
+            {
+                Sample(sb, "cimian_items", $"{{status=\"{Escape(status)}\"}}", count);
+            }
+            Gauge(sb, "cimian_pending_updates", "Managed items waiting to be installed or updated.",
+                _itemsByStatus.GetValueOrDefault("Pending"));
+            if (_cacheBytes is long cacheBytes)
+            {
+                Gauge(sb, "cimian_cache_bytes", "Size of the installer cache.", cacheBytes);
+            }
+            Gauge(sb, "cimian_self_update_pending", "1 when a Cimian self-update is scheduled.", _selfUpdatePending ? 1 : 0);
+
+            Counter(sb, openMetrics, "cimian_runs", "Runs finished since CimianWatcher started, by outcome.");
+            foreach (var (outcome, count) in _runsByOutcome.OrderBy(kv => kv.Key, StringComparer.Ordinal))
+            {
+                Sample(sb, "cimian_runs_total", $"{{outcome=\"{Escape(outcome)}\"}}", count);
+            }
+            Counter(sb, openMetrics, "cimian_failed_installs", "Failed item installs since CimianWatcher started.");
+            Sample(sb, "cimian_failed_installs_total", "", _failedInstalls);
+            Counter(sb, openMetrics, "cimian_download_bytes", "Installer bytes downloaded since CimianWatcher started.");
+            Sample(sb, "cimian_download_bytes_total", "", _downloadBytes);
+
+            Family(sb, "cimian_download_duration_seconds", "histogram", "Installer download durations, cache hits excluded.");
+            for (var i = 0; i < DownloadBuckets.Length; i++)
+            {
+                Sample(sb, "cimian_download_duration_seconds_bucket", $"{{le=\"{Format(DownloadBuckets[i])}\"}}", _downloadBucketCounts[i]);
+            }
+            Sample(sb, "cimian_download_duration_seconds_bucket", "{le=\"+Inf\"}", _downloads);
+            Sample(sb, "cimian_download_duration_seconds_sum", "", _downloadSeconds);
+            Sample(sb, "cimian_download_duration_seconds_count", "", _downloads);
+        }
+        if (openMetrics)
+        {
+            sb.Append("# EOF\n");
+        }
+        return sb.ToString();
+    }
+
+    private static void Gauge(StringBuilder sb, string name, string help, double value)
+    {
+        Family(sb, name, "gauge", help);
+        Sample(sb, name, "", value);
+    }
+
+    private static void Counter(StringBuilder sb, bool openMetrics, string family, string help) =>
+        Family(sb, openMetrics ? family : family + "_total", "counter", help);
+
+    private static void Family(StringBuilder sb, string name, string type, string help) =>
+        sb.Append("# HELP ").Append(name).Append(' ').Append(help).Append('\n')
+          .Append("# TYPE ").Append(name).Append(' ').Append(type).Append('\n');
+
+    private static void Sample(StringBuilder sb, string name, string labels, double value) =>
+        sb.Append(name).Append(labels).Append(' ').Append(Format(value)).Append('\n');
+
+    private static string Format(double value) => value.ToString("R", CultureInfo.InvariantCulture);
+
+    private static string Escape(string label) =>
+        label.Replace("\\", "\\\\").Replace("\"", "\\\"").Replace("\n", "\\n");
+}
+
+/// <summary>
+/// Localhost metrics endpoint (MetricsPort in Config.yaml; 0, the default, is
+/// off). Serves <see cref="CimianMetrics"/> at http://localhost:&lt;port&gt;/metrics
+/// for a Prometheus agent or windows_exporter-style collector on the same host.
+/// State comes from the files managedsoftwareupdate already writes: status.json,
+/// reports/items.json, reports/usage.json and the installer cache.
+/// </summary>
+public class MetricsService : BackgroundService
+{
+    private static readonly TimeSpan RefreshInterval = TimeSpan.FromSeconds(30);
+    private static readonly TimeSpan CacheSizeInterval = TimeSpan.FromMinutes(5);
+    private static readonly JsonSerializerOptions JsonOptions = new() { PropertyNameCaseInsensitive = true };
+
+    private readonly ILogger<MetricsService> _logger;
+    private readonly CimianMetrics _metrics = new();
+    private HttpListener? _listener;
+    private int _port;
+    private long? _cacheBytes;
+    private DateTime _cacheSizedAt = DateTime.MinValue;
+
+    public MetricsService(ILogger<MetricsService> logger)
+    {
+        _logger = logger;
+    }
+
+    protected override async Task ExecuteAsync(CancellationToken stoppingToken)
+    {
+        while (!stoppingToken.IsCancellationRequested)
+        {
+            // An unreadable Config.yaml keeps the endpoint as it was
+            var config = LoadConfig();
+            var port = config?.MetricsPort ?? _port;
+            if (port != _port)
+            {
+                StopListener();
+                if (port is > 0 and <= 65535)
+                {
+                    StartListener(port, stoppingToken);
+                }
+                _port = port;
+            }
+
+            if (_listener != null)
+            {
+                try
+                {
+                    Refresh(config?.CachePath);
+                }
+                catch (Exception ex)
+                {
+                    _logger.LogWarning("Metrics refresh failed: {Message}", ex.Message);
+                }
+            }
+
+            try
+            {
+                await Task.Delay(RefreshInterval, stoppingToken);
+            }
+            catch (OperationCanceledException)
+            {
+                break;
+            }
+        }
+        StopListener();
+    }
+
+    private void StartListener(int port, CancellationToken stoppingToken)
+    {
+        var listener = new HttpListener();
+        // Both spellings: http.sys matches the Host header against the prefix
+        listener.Prefixes.Add($"http://localhost:{port}/");
+        listener.Prefixes.Add($"http://127.0.0.1:{port}/");
+        try
+        {
+            listener.Start();
+        }
+        catch (HttpListenerException ex)
+        {
+            _logger.LogError("Metrics endpoint could not listen on port {Port}: {Message}", port, ex.Message);
+            listener.Close();
+            return;
+        }
+
+        _listener = listener;
+        _logger.LogInformation("Metrics endpoint listening on http://localhost:{Port}/metrics", port);
+        _ = Task.Run(() => ServeAsync(listener, stoppingToken), CancellationToken.None);
+    }
+
+    private void StopListener()
+    {
+        if (_listener == null)
+        {
+            return;
+        }
+        _listener.Close();
+        _listener = null;
+        _logger.LogInformation("Metrics endpoint stopped");
+    }
+
+    private async Task ServeAsync(HttpListener listener, CancellationToken stoppingToken)
+    {
+        while (listener.IsListening && !stoppingToken.IsCancellationRequested)
+        {
+            HttpListenerContext context;
+            try
+            {
+                context = await listener.GetContextAsync();
+            }
+            catch (Exception ex) when (ex is HttpListenerException or ObjectDisposedException or InvalidOperationException)
+            {
+                // Closed by StopListener
+                break;
+            }
+
+            try
+            {
+                Respond(context);
+            }
+            catch (Exception ex) when (ex is HttpListenerException or IOException)
+            {
+                _logger.LogDebug("Metrics scrape aborted: {Message}", ex.Message);
+            }
+        }
+    }
+
+    private void Respond(HttpListenerContext context)
+    {
+        using var response = context.Response;
+        if (context.Request.Url?.AbsolutePath.TrimEnd('/') != "/metrics" || context.Request.HttpMethod is not ("GET" or "HEAD"))
+        {
+            response.StatusCode = (int)HttpStatusCode.NotFound;
+            return;
+        }
+
+        var openMetrics = context.Request.AcceptTypes?.Any(t => t.StartsWith("application/openmetrics-text", StringComparison.OrdinalIgnoreCase)) == true;
+        var body = Encoding.UTF8.GetBytes(_metrics.Render(openMetrics));
+        response.ContentType = openMetrics
+            ? "application/openmetrics-text; version=1.0.0; charset=utf-8"
+            : "text/plain; version=0.0.4; charset=utf-8";
+        response.ContentLength64 = body.Length;
+        if (context.Request.HttpMethod == "GET")
+        {
+            response.OutputStream.Write(body);
+        }
+    }
+
+    private void Refresh(string? cachePath)
+    {
+        if (LastRunStatusStore.Read() is { } run)
+        {
+            _metrics.RecordRun(run, ReadJson<UsageReport>(Path.Combine(CimianPaths.ReportsDir, UsageReports.FileName)));
+        }
+
+        var items = ReadJson<List<ItemRecord>>(Path.Combine(CimianPaths.ReportsDir, "items.json")) ?? [];
+        var itemsByStatus = items
+            .GroupBy(i => string.IsNullOrEmpty(i.CurrentStatus) ? "Unknown" : i.CurrentStatus)
+            .ToDictionary(g => g.Key, g => g.Count());
+
+        if (DateTime.UtcNow - _cacheSizedAt >= CacheSizeInterval)
+        {
+            _cacheBytes = DirectorySize(string.IsNullOrWhiteSpace(cachePath) ? CimianPaths.CacheDir : cachePath);
+            _cacheSizedAt = DateTime.UtcNow;
+        }
+
+        _metrics.SetState(itemsByStatus, _cacheBytes, SelfUpdateService.IsSelfUpdatePending());
+    }
+
+    private static long? DirectorySize(string path)
+    {
+        if (!Directory.Exists(path))
+        {
+            return null;
+        }
+        var options = new EnumerationOptions { RecurseSubdirectories = true, IgnoreInaccessible = true };
+        return new DirectoryInfo(path).EnumerateFiles("*", options).Sum(f => f.Length);
+    }
+
+    private T? ReadJson<T>(string path) where T : class
+    {
+        try
+        {
+            return File.Exists(path) ? JsonSerializer.Deserialize<T>(File.ReadAllText(path), JsonOptions) : null;
+        }
+        catch (Exception ex) when (ex is IOException or JsonException or UnauthorizedAccessException)
+        {
+            _logger.LogDebug("Could not read {Path}: {Message}", path, ex.Message);
+            return null;
+        }
+    }
+
+    private MetricsConfig? LoadConfig()
+    {
+        try
+        {
+            if (!File.Exists(CimianPaths.ConfigYaml))
+            {
+                return null;
+            }
+            return YamlUtils.Deserializer.Deserialize<MetricsConfig>(File.ReadAllText(CimianPaths.ConfigYaml));
+        }
+        catch (Exception ex)
+        {
+            _logger.LogWarning("Could not read {Path}: {Message}", CimianPaths.ConfigYaml, ex.Message);
+            return null;
+        }
+    }
+}
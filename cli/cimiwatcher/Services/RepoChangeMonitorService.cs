@@ -0,0 +1,466 @@
+using System.Net;
+using System.Net.Http.Headers;
+using System.Text;
+using System.Text.Json;
+using Cimian.Core;
+using Cimian.Core.Models;
+using Cimian.Core.Services;
+using Microsoft.Extensions.Hosting;
+using Microsoft.Extensions.Logging;
+using YamlDotNet.Serialization;
+
+namespace Cimian.CLI.Cimiwatcher.Services;
+
+/// <summary>
+/// The subset of Config.yaml the repo change monitor needs. cimiwatcher doesn't
+/// reference managedsoftwareupdate, so this mirrors the relevant CimianConfig keys.
+/// </summary>
+public class RepoWatchConfig
+{
+    [YamlMember(Alias = "SoftwareRepoURL")]
+    public string SoftwareRepoURL { get; set; } = string.Empty;
+
+    [YamlMember(Alias = "RepoBackend")]
+    public string? RepoBackend { get; set; }
+
+    [YamlMember(Alias = "ClientIdentifier")]
+    public string ClientIdentifier { get; set; } = string.Empty;
+
+    [YamlMember(Alias = "Catalogs")]
+    public List<string> Catalogs { get; set; } = new();
+
+    [YamlMember(Alias = "AuthToken")]
+    public string? AuthToken { get; set; }
+
+    [YamlMember(Alias = "AuthUser")]
+    public string? AuthUser { get; set; }
+
+    [YamlMember(Alias = "AuthPassword")]
+    public string? AuthPassword { get; set; }
+
+    [YamlMember(Alias = "UseClientCertificate")]
+    public bool UseClientCertificate { get; set; }
+
+    [YamlMember(Alias = "ClientCertificateThumbprint")]
+    public string? ClientCertificateThumbprint { get; set; }
+
+    [YamlMember(Alias = "ClientCertificateTemplate")]
+    public string? ClientCertificateTemplate { get; set; }
+
+    [YamlMember(Alias = "ProxyURL")]
+    public string? ProxyURL { get; set; }
+
+    [YamlMember(Alias = "ProxyBypassList")]
+    public List<string> ProxyBypassList { get; set; } = new();
+
+    [YamlMember(Alias = "ProxyPACURL")]
+    public string? ProxyPACURL { get; set; }
+
+    [YamlMember(Alias = "UseSystemProxy")]
+    public bool UseSystemProxy { get; set; } = true;
+
+    [YamlMember(Alias = "ProxyUser")]
+    public string? ProxyUser { get; set; }
+
+    [YamlMember(Alias = "ProxyPassword")]
+    public string? ProxyPassword { get; set; }
+
+    [YamlMember(Alias = "ProxyUseDefaultCredentials")]
+    public bool ProxyUseDefaultCredentials { get; set; }
+
+    [YamlMember(Alias = "RepoChangeWatch")]
+    public bool RepoChangeWatch { get; set; }
+
+    [YamlMember(Alias = "RepoChangeWatchInterval")]
+    public int RepoChangeWatchInterval { get; set; } = 300;
+
+    [YamlMember(Alias = "RepoChangeEventsURL")]
+    public string? RepoChangeEventsURL { get; set; }
+}
+
+/// <summary>
+/// Optional prefetch mode (RepoChangeWatch: true in Config.yaml). Periodically
+/// HEADs the client's manifest and catalogs and triggers a managedsoftwareupdate
+/// run only when one of them actually changed, so static repos stop paying for
+/// full runs and real changes land within one poll interval instead of waiting
+/// for the hourly task.
+///
+/// If RepoChangeEventsURL points at a server-sent-events endpoint, every event
+/// wakes the monitor early; the HEAD comparison still decides whether to run, so
+/// a noisy channel can't cause spurious runs. Runs are requested by writing the
+/// headless flag file, which FileWatcherService already consumes and serializes.
+///
+/// Included manifests aren't watched; changes to them are picked up by the
+/// regular scheduled run. Neither are object storage repos (RepoBackend s3 or
+/// azblob): their signing lives in managedsoftwareupdate, and unsigned HEADs
+/// would only ever get 403s, so the monitor says so once and stays idle.
+/// </summary>
+public class RepoChangeMonitorService : BackgroundService
+{
+    private static readonly string StatePath = Path.Combine(CimianPaths.ManagedInstallsRoot, "repo_watch_state.json");
+    private static readonly TimeSpan DisabledRecheck = TimeSpan.FromMinutes(5);
+    private const int MinimumIntervalSeconds = 60;
+
+    private readonly ILogger<RepoChangeMonitorService> _logger;
+    private readonly SemaphoreSlim _wake = new(0);
+    private Dictionary<string, string> _fingerprints;
+
+    private CancellationTokenSource? _eventsCts;
+    private string? _eventsUrl;
+    private string? _unsupportedBackendWarned;
+
+    public RepoChangeMonitorService(ILogger<RepoChangeMonitorService> logger)
+    {
+        _logger = logger;
+        _fingerprints = LoadState();
+    }
+
+    protected override async Task ExecuteAsync(CancellationToken stoppingToken)
+    {
+        while (!stoppingToken.IsCancellationRequested)
+        {
+            var delay = DisabledRecheck;
+            try
+            {
+                var config = LoadConfig();
+                if (config != null && config.RepoChangeWatch && !string.IsNullOrWhiteSpace(config.SoftwareRepoURL)
+                    && !IsWatchableBackend(config))
+                {
+                    StopEventSubscription();
+                    if (_unsupportedBackendWarned != config.RepoBackend)
+                    {
+                        _unsupportedBackendWarned = config.RepoBackend;
+                        _logger.LogWarning("RepoChangeWatch is on but doesn't support RepoBackend {Backend}; repo changes are picked up by scheduled runs only",
+                            config.RepoBackend);
+                    }
+                }
+                else if (config != null && config.RepoChangeWatch && !string.IsNullOrWhiteSpace(config.SoftwareRepoURL))
+                {
+                    _unsupportedBackendWarned = null;
+                    EnsureEventSubscription(config, stoppingToken);
+                    await CheckForChangesAsync(config, stoppingToken);
+                    delay = TimeSpan.FromSeconds(Math.Max(MinimumIntervalSeconds, config.RepoChangeWatchInterval));
+                }
+                else
+                {
+                    StopEventSubscription();
+                }
+            }
+            catch (OperationCanceledException) when (stoppingToken.IsCancellationRequested)
+            {
+                break;
+            }
+            catch (Exception ex)
+            {
+                _logger.LogError(ex, "Error during repo change check");
+            }
+
+            try
+            {
+                // Sleep until the interval elapses or an SSE event wakes us
+                await _wake.WaitAsync(delay, stoppingToken);
+            }
+            catch (OperationCanceledException)
+            {
+                break;
+            }
+        }
+
+        StopEventSubscription();
+    }
+
+    private async Task CheckForChangesAsync(RepoWatchConfig config, CancellationToken cancellationToken)
+    {
+        using var client = CreateHttpClient(config);
+        var current = new Dictionary<string, string>(StringComparer.OrdinalIgnoreCase);
+
+        foreach (var url in BuildWatchUrls(config, LastRunStatusStore.Read()?.Manifest))
+        {
+            try
+            {
+                using var request = new HttpRequestMessage(HttpMethod.Head, url);
+                using var response = await client.SendAsync(request, cancellationToken);
+                if (!response.IsSuccessStatusCode)
+                {
+                    // Auth failures mean the watch never fires, so they're worth seeing
+                    var level = response.StatusCode is HttpStatusCode.Unauthorized or HttpStatusCode.Forbidden
+                        ? LogLevel.Warning
+                        : LogLevel.Debug;
+                    _logger.Log(level, "HEAD {Url} returned {Status}", url, (int)response.StatusCode);
+                    continue;
+                }
+
+                var fingerprint = Fingerprint(response);
+                if (fingerprint != null)
+                {
+                    current[url] = fingerprint;
+                }
+            }
+            catch (HttpRequestException ex)
+            {
+                // Repo unreachable: keep the previous fingerprints so coming back
+                // online doesn't look like a change
+                _logger.LogDebug("HEAD {Url} failed: {Message}", url, ex.Message);
+                return;
+            }
+        }
+
+        if (current.Count == 0)
+        {
+            return;
+        }
+
+        var changed = ChangedResources(_fingerprints, current);
+        var baseline = _fingerprints.Count == 0;
+        _fingerprints = current;
+        SaveState(current);
+
+        if (baseline || changed.Count == 0)
+        {
+            return;
+        }
+
+        foreach (var url in changed)
+        {
+            _logger.LogInformation("Repo resource changed: {Url}", url);
+        }
+        RequestRun();
+    }
+
+    /// <summary>
+    /// Whether the monitor can HEAD the repo itself: plain http(s) only. The s3
+    /// and azblob backends sign each request in managedsoftwareupdate's pipeline.
+    /// </summary>
+    public static bool IsWatchableBackend(RepoWatchConfig config) =>
+        RepoBackend.Normalize(config.RepoBackend) == RepoBackend.Http;
+
+    /// <summary>
+    /// Manifest plus every configured catalog, in the same layout managedsoftwareupdate fetches.
+    /// A templated or empty ClientIdentifier is only known after a run resolves it, so
+    /// <paramref name="lastManifest"/> (from status.json) is watched instead.
+    /// </summary>
+    public static List<string> BuildWatchUrls(RepoWatchConfig config, string? lastManifest = null)
+    {
+        var baseUrl = config.SoftwareRepoURL.TrimEnd('/');
+        var manifest = !string.IsNullOrWhiteSpace(config.ClientIdentifier) && !ClientIdentifierTemplate.IsTemplate(config.ClientIdentifier)
+            ? config.ClientIdentifier
+            : string.IsNullOrWhiteSpace(lastManifest) ? "site_default" : lastManifest;
+
+        var urls = new List<string> { $"{baseUrl}/manifests/{manifest}.yaml" };
+        urls.AddRange(config.Catalogs.Select(c => $"{baseUrl}/catalogs/{c}.yaml"));
+        return urls;
+    }
+
+    /// <summary>
+    /// Change fingerprint for a HEAD response: ETag when the server sends one,
+    /// otherwise Last-Modified plus length. Null when neither is available.
+    /// </summary>
+    public static string? Fingerprint(HttpResponseMessage response)
+    {
+        if (response.Headers.ETag != null)
+        {
+            return response.Headers.ETag.Tag;
+        }
+
+        var lastModified = response.Content.Headers.LastModified;
+        var length = response.Content.Headers.ContentLength;
+        if (lastModified == null && length == null)
+        {
+            return null;
+        }
+        return $"{lastModified?.ToUnixTimeSeconds()}:{length}";
+    }
+
+    /// <summary>
+    /// Resources whose fingerprint differs from the previous poll. Resources that
+    /// disappear aren't changes (the server may just be omitting headers); new
+    /// ones are, since a newly added catalog should trigger a run.
+    /// </summary>
+    public static List<string> ChangedResources(IReadOnlyDictionary<string, string> previous,
+        IReadOnlyDictionary<string, string> current)
+    {
+        return current
+            .Where(kv => !previous.TryGetValue(kv.Key, out var old) || old != kv.Value)
+            .Select(kv => kv.Key)
+            .ToList();
+    }
+
+    private void RequestRun()
+    {
+        try
+        {
+            if (File.Exists(CimianPaths.HeadlessFlagFile))
+            {
+                return;
+            }
+            File.WriteAllText(CimianPaths.HeadlessFlagFile,
+                $"Triggered by repo change monitor at {DateTime.Now:yyyy-MM-dd HH:mm:ss}\n");
+            _logger.LogInformation("Repo content changed - requested a managedsoftwareupdate run");
+        }
+        catch (Exception ex)
+        {
+            _logger.LogError(ex, "Failed to write headless flag file");
+        }
+    }
+
+    private void EnsureEventSubscription(RepoWatchConfig config, CancellationToken stoppingToken)
+    {
+        var url = string.IsNullOrWhiteSpace(config.RepoChangeEventsURL) ? null : config.RepoChangeEventsURL;
+        if (url == _eventsUrl)
+        {
+            return;
+        }
+
+        StopEventSubscription();
+        if (url == null)
+        {
+            return;
+        }
+
+        _eventsUrl = url;
+        _eventsCts = CancellationTokenSource.CreateLinkedTokenSource(stoppingToken);
+        var token = _eventsCts.Token;
+        _ = Task.Run(() => ListenForEventsAsync(config, url, token), token);
+    }
+
+    private void StopEventSubscription()
+    {
+        _eventsCts?.Cancel();
+        _eventsCts?.Dispose();
+        _eventsCts = null;
+        _eventsUrl = null;
+    }
+
+    private async Task ListenForEventsAsync(RepoWatchConfig config, string url, CancellationToken cancellationToken)
+    {
+        _logger.LogInformation("Subscribing to repo change events: {Url}", url);
+
+        while (!cancellationToken.IsCancellationRequested)
+        {
+            try
+            {
+                using var client = CreateHttpClient(config, Timeout.InfiniteTimeSpan);
+                using var request = new HttpRequestMessage(HttpMethod.Get, url);
+                request.Headers.Accept.Add(new MediaTypeWithQualityHeaderValue("text/event-stream"));
+                using var response = await client.SendAsync(request, HttpCompletionOption.ResponseHeadersRead, cancellationToken);
+                response.EnsureSuccessStatusCode();
+
+                using var reader = new StreamReader(await response.Content.ReadAsStreamAsync(cancellationToken));
+                while (!cancellationToken.IsCancellationRequested)
+                {
+                    var line = await reader.ReadLineAsync(cancellationToken);
+                    if (line == null)
+                    {
+                        break;
+                    }
+                    // Any data line is a hint; the HEAD comparison decides what changed
+                    if (line.StartsWith("data:", StringComparison.Ordinal))
+                    {
+                        _logger.LogDebug("Repo change event received");
+                        _wake.Release();
+                    }
+                }
+            }
+            catch (OperationCanceledException) when (cancellationToken.IsCancellationRequested)
+            {
+                break;
+            }
+            catch (Exception ex)
+            {
+                _logger.LogWarning("Repo change event stream dropped: {Message}", ex.Message);
+            }
+
+            try
+            {
+                await Task.Delay(TimeSpan.FromSeconds(30), cancellationToken);
+            }
+            catch (OperationCanceledException)
+            {
+                break;
+            }
+        }
+    }
+
+    private static HttpClient CreateHttpClient(RepoWatchConfig config, TimeSpan? timeout = null)
+    {
+        var handler = new HttpClientHandler();
+        ProxySelector.Apply(handler, new ProxySettings(config.ProxyURL, config.ProxyBypassList, config.UseSystemProxy,
+            config.ProxyPACURL, config.ProxyUser, config.ProxyPassword, config.ProxyUseDefaultCredentials));
+        if (config.UseClientCertificate)
+        {
+            var cert = !string.IsNullOrEmpty(config.ClientCertificateThumbprint)
+                ? ClientCertificateStore.FindByThumbprint(config.ClientCertificateThumbprint)
+                : !string.IsNullOrEmpty(config.ClientCertificateTemplate)
+                    ? ClientCertificateStore.FindByTemplate(config.ClientCertificateTemplate)
+                    : null;
+            if (cert != null)
+            {
+                handler.ClientCertificates.Add(cert);
+            }
+        }
+
+        var client = new HttpClient(handler) { Timeout = timeout ?? TimeSpan.FromSeconds(30) };
+        client.DefaultRequestHeaders.UserAgent.ParseAdd("CimianWatcher/1.0");
+
+        if (!string.IsNullOrEmpty(config.AuthToken))
+        {
+            client.DefaultRequestHeaders.Authorization = new AuthenticationHeaderValue("Bearer", config.AuthToken);
+        }
+        else if (!string.IsNullOrEmpty(config.AuthUser))
+        {
+            var credentials = Convert.ToBase64String(Encoding.UTF8.GetBytes($"{config.AuthUser}:{config.AuthPassword}"));
+            client.DefaultRequestHeaders.Authorization = new AuthenticationHeaderValue("Basic", credentials);
+        }
+
+        return client;
+    }
+
+    private RepoWatchConfig? LoadConfig()
+    {
+        try
+        {
+            if (!File.Exists(CimianPaths.ConfigYaml))
+            {
+                return null;
+            }
+            return YamlUtils.Deserializer.Deserialize<RepoWatchConfig>(File.ReadAllText(CimianPaths.ConfigYaml));
+        }
+        catch (Exception ex)
+        {
+            _logger.LogWarning("Could not read {Path}: {Message}", CimianPaths.ConfigYaml, ex.Message);
+            return null;
+        }
+    }
+
+    private static Dictionary<string, string> LoadState()
+    {
+        try
+        {
+            if (File.Exists(StatePath))
+            {
+                var loaded = JsonSerializer.Deserialize<Dictionary<string, string>>(File.ReadAllText(StatePath));
+                if (loaded != null)
+                {
+                    return new Dictionary<string, string>(loaded, StringComparer.OrdinalIgnoreCase);
+                }
+            }
+        }
+        catch
+        {
+            // Corrupt state just means the next poll re-baselines
+        }
+        return new Dictionary<string, string>(StringComparer.OrdinalIgnoreCase);
+    }
+
+    private void SaveState(Dictionary<string, string> state)
+    {
+        try
+        {
+            File.WriteAllText(StatePath, JsonSerializer.Serialize(state, new JsonSerializerOptions { WriteIndented = true }));
+        }
+        catch (Exception ex)
+        {
+            _logger.LogDebug("Could not persist repo watch state: {Message}", ex.Message);
+        }
+    }
+}
@@ -0,0 +1,469 @@
+using System.IO.Pipes;
+using System.Security.AccessControl;
+using System.Security.Principal;
+using System.Text;
+using Cimian.Core;
+using Cimian.Core.Services;
+using Microsoft.Extensions.Hosting;
+using Microsoft.Extensions.Logging;
+using YamlDotNet.Serialization;
+
+namespace Cimian.CLI.Cimiwatcher.Services;
+
+/// <summary>
+/// The subset of Config.yaml the run broker needs.
+/// </summary>
+public class RunBrokerConfig
+{
+    [YamlMember(Alias = "RunBrokerAllowedGroups")]
+    public List<string>? RunBrokerAllowedGroups { get; set; }
+}
+
+/// <summary>
+/// Authenticated local IPC for CimianWatcher. Listens on two pipes:
+/// <see cref="RunBrokerProtocol.ControlPipeName"/>, whose ACL admits only
+/// Administrators and SYSTEM, and <see cref="RunBrokerProtocol.PipeName"/> for
+/// signed-in users in RunBrokerAllowedGroups, which takes only the requests
+/// <see cref="RunBrokerProtocol.IsUserRequest"/> allows. Each caller is
+/// impersonated just long enough to read their token, and every request and its
+/// outcome goes to <see cref="RunAuditLog"/>.
+///
+/// Runs launch through <see cref="FileWatcherService"/> so they share the
+/// single-run slot with the flag files. Callers choose a mode, never a command
+/// line, and the run happens under the service's SYSTEM account - no UAC prompt
+/// and no scheduled task. The same pipes report <see cref="AutoRunScheduler"/>'s
+/// schedule, and the control pipe pauses and resumes it.
+/// </summary>
+public class RunBrokerService : BackgroundService
+{
+    public const int MaxRequestBytes = 8 * 1024;
+
+    /// <summary>How long a caller has to send its request line.</summary>
+    private static readonly TimeSpan RequestReadTimeout = TimeSpan.FromSeconds(10);
+
+    private readonly ILogger<RunBrokerService> _logger;
+    private readonly FileWatcherService _watcher;
+    private readonly AutoRunScheduler _scheduler;
+
+    public RunBrokerService(ILogger<RunBrokerService> logger, FileWatcherService watcher, AutoRunScheduler scheduler)
+    {
+        _logger = logger;
+        _watcher = watcher;
+        _scheduler = scheduler;
+    }
+
+    protected override async Task ExecuteAsync(CancellationToken stoppingToken)
+    {
+        _logger.LogInformation("Run broker listening on pipes {Pipe} and {ControlPipe}",
+            RunBrokerProtocol.PipeName, RunBrokerProtocol.ControlPipeName);
+
+        await Task.WhenAll(
+            ListenAsync(RunBrokerProtocol.PipeName, control: false, stoppingToken),
+            ListenAsync(RunBrokerProtocol.ControlPipeName, control: true, stoppingToken));
+    }
+
+    private async Task ListenAsync(string pipeName, bool control, CancellationToken stoppingToken)
+    {
+        while (!stoppingToken.IsCancellationRequested)
+        {
+            NamedPipeServerStream? server = null;
+            try
+            {
+                server = CreateServer(pipeName, control);
+                await server.WaitForConnectionAsync(stoppingToken);
+
+                var connection = server;
+                server = null;
+                _ = Task.Run(() => HandleConnectionAsync(connection, control, stoppingToken), CancellationToken.None);
+            }
+            catch (OperationCanceledException)
+            {
+                break;
+            }
+            catch (Exception ex)
+            {
+                _logger.LogError(ex, "Run broker pipe error on {Pipe}", pipeName);
+                await Task.Delay(1000, CancellationToken.None);
+            }
+            finally
+            {
+                server?.Dispose();
+            }
+        }
+    }
+
+    /// <summary>
+    /// Reads one request line of at most <paramref name="maxBytes"/> UTF-8 bytes.
+    /// Stops reading as soon as the limit is passed, so a caller that never
+    /// sends a newline can't make the service buffer without bound, and gives up
+    /// with an <see cref="OperationCanceledException"/> after
+    /// <paramref name="timeout"/>. Null when the caller closed without sending.
+    /// </summary>
+    public static async Task<(string? Line, bool TooLarge)> ReadRequestLineAsync(Stream stream, int maxBytes, TimeSpan timeout,
+        CancellationToken cancellationToken)
+    {
+        using var deadline = CancellationTokenSource.CreateLinkedTokenSource(cancellationToken);
+        deadline.CancelAfter(timeout);
+
+        var buffer = new byte[maxBytes + 1];
+        var length = 0;
+        while (length < buffer.Length)
+        {
+            var read = await stream.ReadAsync(buffer.AsMemory(length), deadline.Token);
+            if (read == 0)
+            {
+                break;
+            }
+
+            var newline = Array.IndexOf(buffer, (byte)'\n', length, read);
+            length += read;
+            if (newline >= 0)
+            {
+                return newline > maxBytes ? (null, true) : (Decode(buffer, newline), false);
+            }
+        }
+
+        if (length > maxBytes)
+        {
+            return (null, true);
+        }
+        return (length == 0 ? null : Decode(buffer, length), false);
+
+        static string Decode(byte[] bytes, int count) =>
+            Encoding.UTF8.GetString(bytes, 0, count).TrimStart('\uFEFF').TrimEnd('\r');
+    }
+
+    private static NamedPipeServerStream CreateServer(string pipeName, bool control)
+    {
+        // The control pipe opens only for Administrators (an elevated token - a
+        // filtered admin token has the group deny-only) and SYSTEM. On the user
+        // pipe any signed-in user may connect; authorization happens per request
+        // against the caller's token.
+        var security = new PipeSecurity();
+        security.AddAccessRule(new PipeAccessRule(
+            new SecurityIdentifier(control ? WellKnownSidType.BuiltinAdministratorsSid : WellKnownSidType.AuthenticatedUserSid, null),
+            PipeAccessRights.ReadWrite, AccessControlType.Allow));
+        security.AddAccessRule(new PipeAccessRule(
+            new SecurityIdentifier(WellKnownSidType.LocalSystemSid, null),
+            PipeAccessRights.FullControl, AccessControlType.Allow));
+
+        return NamedPipeServerStreamAcl.Create(
+            pipeName,
+            PipeDirection.InOut,
+            NamedPipeServerStream.MaxAllowedServerInstances,
+            PipeTransmissionMode.Byte,
+            PipeOptions.Asynchronous,
+            inBufferSize: 0,
+            outBufferSize: 0,
+            security);
+    }
+
+    private async Task HandleConnectionAsync(NamedPipeServerStream connection, bool control, CancellationToken cancellationToken)
+    {
+        using (connection)
+        {
+            RunBrokerResponse response;
+            try
+            {
+                var (line, tooLarge) = await ReadRequestLineAsync(connection, MaxRequestBytes, RequestReadTimeout, cancellationToken);
+                response = tooLarge
+                    ? new RunBrokerResponse { Message = "Request too large" }
+                    : await HandleRequestAsync(connection, control, line, cancellationToken);
+            }
+            catch (OperationCanceledException) when (!cancellationToken.IsCancellationRequested)
+            {
+                _logger.LogWarning("Run broker caller sent no request within {Seconds}s; disconnected", RequestReadTimeout.TotalSeconds);
+                return;
+            }
+            catch (OperationCanceledException)
+            {
+                return;
+            }
+            catch (Exception ex)
+            {
+                _logger.LogError(ex, "Run broker request failed");
+                response = new RunBrokerResponse { Message = "Internal error" };
+            }
+
+            try
+            {
+                // A caller that never reads its answer mustn't hold the connection open either
+                using var timeout = CancellationTokenSource.CreateLinkedTokenSource(cancellationToken);
+                timeout.CancelAfter(RequestReadTimeout);
+                using var writer = new StreamWriter(connection, new UTF8Encoding(false), leaveOpen: true);
+                await writer.WriteLineAsync(RunBrokerProtocol.Serialize(response).AsMemory(), timeout.Token);
+                await writer.FlushAsync(timeout.Token);
+            }
+            catch (Exception ex) when (ex is IOException or OperationCanceledException)
+            {
+                // Caller disconnected or stopped reading without waiting for the answer
+            }
+        }
+    }
+
+    private async Task<RunBrokerResponse> HandleRequestAsync(NamedPipeServerStream connection, bool control, string? line,
+        CancellationToken cancellationToken)
+    {
+        var request = RunBrokerProtocol.Deserialize<RunBrokerRequest>(line);
+        if (request == null)
+        {
+            return new RunBrokerResponse { Message = "Malformed request" };
+        }
+
+        string user = "unknown";
+        bool allowed = false;
+        IReadOnlyList<string> allowedGroups = control ? [] : LoadAllowedGroups();
+        connection.RunAsClient(() =>
+        {
+            using var identity = WindowsIdentity.GetCurrent(TokenAccessLevels.Query);
+            user = identity.Name;
+            // The control pipe's ACL already admitted only Administrators and SYSTEM
+            allowed = control || allowedGroups.Any(group => IsInGroup(new WindowsPrincipal(identity), group));
+        });
+
+        var response = Authorize(request, user, allowed, control, allowedGroups)
+            ?? await DispatchAsync(request, user, cancellationToken);
+        RunAuditLog.Append(control ? RunBrokerProtocol.ControlPipeName : RunBrokerProtocol.PipeName, user,
+            RunAuditLog.Describe(request), RunAuditLog.Outcome(response));
+        return response;
+    }
+
+    /// <summary>
+    /// The refusal for a caller who may not send the request on this pipe, or null.
+    /// </summary>
+    private RunBrokerResponse? Authorize(RunBrokerRequest request, string user, bool allowed, bool control,
+        IReadOnlyList<string> allowedGroups)
+    {
+        if (!allowed)
+        {
+            _logger.LogWarning("Run broker refused {User}: not in any of {Groups}", user, string.Join(", ", allowedGroups));
+            return new RunBrokerResponse { Message = "Not authorized to request a run" };
+        }
+        if (!control && !RunBrokerProtocol.IsUserRequest(request))
+        {
+            _logger.LogWarning("Run broker refused {Mode} from {User} on the user pipe", request.Mode, user);
+            return new RunBrokerResponse
+            {
+                Message = $"'{request.Mode}' needs an elevated administrator; standard users can request a check"
+            };
+        }
+        return null;
+    }
+
+    private async Task<RunBrokerResponse> DispatchAsync(RunBrokerRequest request, string user, CancellationToken cancellationToken)
+    {
+        if (RunBrokerProtocol.IsDeferRequest(request))
+        {
+            return RecordDeferral(request, user);
+        }
+
+        if (RunBrokerProtocol.IsScheduleRequest(request))
+        {
+            return HandleScheduleRequest(request, user);
+        }
+
+        var args = RunBrokerProtocol.BuildArguments(request, out var error);
+        if (args == null)
+        {
+            _logger.LogWarning("Run broker rejected request from {User}: {Error}", user, error);
+            return new RunBrokerResponse { Message = error };
+        }
+
+        var mode = request.Mode.Trim().ToLowerInvariant();
+        var pid = await _watcher.TryStartRunAsync(args, mode == "gui", user, cancellationToken,
+            mode == RunBrokerProtocol.CheckMode ? "UserRequested" : "Brokered");
+        return pid.HasValue
+            ? new RunBrokerResponse { Accepted = true, Message = "Run started", ProcessId = pid }
+            : new RunBrokerResponse { Message = "An update is already running" };
+    }
+
+    /// <summary>
+    /// status, pause and resume: read or change the auto-run schedule; nothing runs now.
+    /// </summary>
+    private RunBrokerResponse HandleScheduleRequest(RunBrokerRequest request, string user)
+    {
+        switch (request.Mode.Trim().ToLowerInvariant())
+        {
+            case RunBrokerProtocol.PauseMode:
+                if (request.Until is not { } until)
+                {
+                    return new RunBrokerResponse { Message = "A pause needs an end time" };
+                }
+                if (MaintenanceHoldStore.Validate(until, DateTime.Now) is { } error)
+                {
+                    return new RunBrokerResponse { Message = $"Can't pause: {error}" };
+                }
+                return new RunBrokerResponse { Accepted = true, Message = $"Automatic runs paused until {until:yyyy-MM-dd HH:mm}", Schedule = _scheduler.Pause(until, user, request.Reason) };
+            case RunBrokerProtocol.ResumeMode:
+                return new RunBrokerResponse { Accepted = true, Message = "Automatic runs resumed", Schedule = _scheduler.Resume(user) };
+            default:
+                return new RunBrokerResponse { Accepted = true, Message = "Schedule status", Schedule = _scheduler.Status() };
+        }
+    }
+
+    /// <summary>
+    /// A toast's "Defer": nothing runs now, the next auto run postpones the items.
+    /// </summary>
+    private RunBrokerResponse RecordDeferral(RunBrokerRequest request, string user)
+    {
+        if (request.Items is not { Count: > 0 } items)
+        {
+            return new RunBrokerResponse { Message = "A deferral needs at least one item" };
+        }
+        if (!RunBrokerProtocol.ValidateItems(request, out var error))
+        {
+            _logger.LogWarning("Run broker rejected deferral from {User}: {Error}", user, error);
+            return new RunBrokerResponse { Message = error };
+        }
+
+        try
+        {
+            DeferralRequestStore.Add(items, user, DateTime.Now);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            _logger.LogError(ex, "Could not record deferral for {User}", user);
+            return new RunBrokerResponse { Message = "Could not record the deferral" };
+        }
+
+        _logger.LogInformation("{User} deferred {Items} until the next update run", user, string.Join(", ", items));
+        return new RunBrokerResponse { Accepted = true, Message = "Deferral recorded" };
+    }
+
+    private static bool IsInGroup(WindowsPrincipal principal, string group)
+    {
+        try
+        {
+            // Accept SIDs (S-1-5-32-545) as well as names so configs survive localized group names
+            return group.StartsWith("S-1-", StringComparison.OrdinalIgnoreCase)
+                ? principal.IsInRole(new SecurityIdentifier(group))
+                : principal.IsInRole(group);
+        }
+        catch (Exception ex) when (ex is ArgumentException or SystemException)
+        {
+            return false;
+        }
+    }
+
+    private IReadOnlyList<string> LoadAllowedGroups()
+    {
+        try
+        {
+            if (File.Exists(CimianPaths.ConfigYaml))
+            {
+                var config = YamlUtils.Deserializer.Deserialize<RunBrokerConfig>(File.ReadAllText(CimianPaths.ConfigYaml));
+                if (config?.RunBrokerAllowedGroups is { Count: > 0 } groups)
+                {
+                    return groups;
+                }
+            }
+        }
+        catch (Exception ex)
+        {
+            _logger.LogWarning("Could not read {Path}: {Message}", CimianPaths.ConfigYaml, ex.Message);
+        }
+        return RunBrokerProtocol.DefaultAllowedGroups;
+    }
+}
+
+/// <summary>
+/// Append-only record of who asked CimianWatcher for what: every request on
+/// either broker pipe, and every flag file consumed with the account that owns
+/// it. One line per entry in <see cref="CimianPaths.RunBrokerAuditLog"/>.
+///
+/// Much of each line comes from the caller, refused requests included, so
+/// nothing caller-supplied reaches the file raw: control characters are escaped
+/// and free text is quoted, and a standard user can't end a line early to forge
+/// one that reads like an administrator's.
+/// </summary>
+public static class RunAuditLog
+{
+    private static readonly object Lock = new();
+
+    public static string FormatLine(DateTime now, string source, string user, string request, string outcome) =>
+        $"{now:yyyy-MM-dd HH:mm:ss} {Escape(source)} user={Escape(user)} request={Escape(request)} {Escape(outcome)}";
+
+    /// <summary>
+    /// The request's mode plus its items, pause end and reason, if any. A mode
+    /// or item list that isn't valid is logged as &lt;invalid&gt; rather than as sent.
+    /// </summary>
+    public static string Describe(RunBrokerRequest request)
+    {
+        var mode = request.Mode?.Trim().ToLowerInvariant() ?? "";
+        var text = mode.Length is > 0 and <= 16 && mode.All(char.IsAsciiLetterLower) ? mode : "<invalid>";
+        if (request.Items is { Count: > 0 } items)
+        {
+            text += RunBrokerProtocol.ValidateItems(request, out _) ? $" items={string.Join(",", items)}" : " items=<invalid>";
+        }
+        if (request.Until is { } until)
+        {
+            text += $" until={until:yyyy-MM-dd HH:mm}";
+        }
+        if (request.StatusPort is { } port)
+        {
+            text += $" status_port={port}";
+        }
+        if (!string.IsNullOrWhiteSpace(request.Reason))
+        {
+            text += $" reason={Quote(request.Reason.Trim())}";
+        }
+        return text;
+    }
+
+    /// <summary>
+    /// Escapes characters that would break the one-entry-per-line layout: control
+    /// characters (CR and LF above all) and the Unicode line and paragraph separators.
+    /// </summary>
+    public static string Escape(string text)
+    {
+        if (!text.Any(NeedsEscape))
+        {
+            return text;
+        }
+        var escaped = new StringBuilder(text.Length + 16);
+        foreach (var c in text)
+        {
+            escaped.Append(c switch
+            {
+                '\r' => "\\r",
+                '\n' => "\\n",
+                '\t' => "\\t",
+                _ when NeedsEscape(c) => $"\\u{(int)c:x4}",
+                _ => c.ToString()
+            });
+        }
+        return escaped.ToString();
+    }
+
+    /// <summary>Free text in double quotes, with backslashes and quotes inside escaped.</summary>
+    private static string Quote(string text) =>
+        $"\"{Escape(text.Replace("\\", "\\\\").Replace("\"", "\\\""))}\"";
+
+    private static bool NeedsEscape(char c) => char.IsControl(c) || c is '\u2028' or '\u2029';
+
+    public static string Outcome(RunBrokerResponse response) =>
+        response.Accepted
+            ? response.ProcessId is { } pid ? $"accepted pid={pid}" : "accepted"
+            : $"refused: {response.Message}";
+
+    public static void Append(string source, string user, string request, string outcome, string? path = null)
+    {
+        path ??= CimianPaths.RunBrokerAuditLog;
+        try
+        {
+            var dir = Path.GetDirectoryName(path);
+            if (!string.IsNullOrEmpty(dir))
+            {
+                Directory.CreateDirectory(dir);
+            }
+            lock (Lock)
+            {
+                File.AppendAllText(path, FormatLine(DateTime.Now, source, user, request, outcome) + Environment.NewLine);
+            }
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            // The service log still has the request; an unwritable audit log mustn't refuse it
+        }
+    }
+}
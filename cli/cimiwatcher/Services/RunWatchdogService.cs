@@ -0,0 +1,392 @@
+using System.Diagnostics;
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using Cimian.Core;
+using Cimian.Core.Services;
+using Microsoft.Extensions.Hosting;
+using Microsoft.Extensions.Logging;
+using YamlDotNet.Serialization;
+
+namespace Cimian.CLI.Cimiwatcher.Services;
+
+/// <summary>
+/// The subset of Config.yaml the run watchdog needs.
+/// </summary>
+public class WatchdogConfig
+{
+    public const int MinimumHungRunMinutes = 10;
+    public const int MaximumHungRunMinutes = 1440;
+
+    [YamlMember(Alias = "HungRunMinutes")]
+    public int HungRunMinutes { get; set; } = 60;
+
+    [YamlMember(Alias = "RestartHungRuns")]
+    public bool RestartHungRuns { get; set; } = true;
+
+    /// <summary>HungRunMinutes of 0 turns the watchdog off.</summary>
+    public bool Enabled => HungRunMinutes > 0;
+
+    public TimeSpan Limit => TimeSpan.FromMinutes(
+        Math.Clamp(HungRunMinutes, MinimumHungRunMinutes, MaximumHungRunMinutes));
+}
+
+/// <summary>
+/// reports/watchdog.json: how often runs hung and the service crashed, for
+/// reporting tools that read the reports directory.
+/// </summary>
+public class WatchdogReport
+{
+    [JsonPropertyName("hung_runs")]
+    public int HungRuns { get; set; }
+
+    [JsonPropertyName("last_hung_run")]
+    public HungRunRecord? LastHungRun { get; set; }
+
+    [JsonPropertyName("service_crashes")]
+    public int ServiceCrashes { get; set; }
+
+    [JsonPropertyName("last_crash")]
+    public ServiceCrashRecord? LastCrash { get; set; }
+
+    /// <summary>Reads the report, or an empty one when it's missing or unreadable.</summary>
+    public static WatchdogReport Read(string? path = null)
+    {
+        path ??= CimianPaths.WatchdogJson;
+        try
+        {
+            if (File.Exists(path))
+            {
+                return JsonSerializer.Deserialize<WatchdogReport>(File.ReadAllText(path)) ?? new WatchdogReport();
+            }
+        }
+        catch (Exception ex) when (ex is IOException or JsonException or UnauthorizedAccessException)
+        {
+            // A corrupt report starts the counters again
+        }
+        return new WatchdogReport();
+    }
+
+    /// <summary>Reads, changes and writes back the report. Returns the updated report.</summary>
+    public static WatchdogReport Update(Action<WatchdogReport> change, string? path = null)
+    {
+        path ??= CimianPaths.WatchdogJson;
+        var report = Read(path);
+        change(report);
+        Directory.CreateDirectory(Path.GetDirectoryName(path)!);
+        File.WriteAllText(path, JsonSerializer.Serialize(report, new JsonSerializerOptions { WriteIndented = true }));
+        return report;
+    }
+}
+
+public class HungRunRecord
+{
+    [JsonPropertyName("session_id")]
+    public string SessionId { get; set; } = "";
+
+    [JsonPropertyName("run_type")]
+    public string RunType { get; set; } = "";
+
+    [JsonPropertyName("killed_at")]
+    public DateTime KilledAt { get; set; }
+
+    [JsonPropertyName("silent_minutes")]
+    public int SilentMinutes { get; set; }
+
+    /// <summary>The last thing the run logged before it went quiet.</summary>
+    [JsonPropertyName("last_event")]
+    public string? LastEvent { get; set; }
+
+    [JsonPropertyName("restarted")]
+    public bool Restarted { get; set; }
+}
+
+public class ServiceCrashRecord
+{
+    [JsonPropertyName("time")]
+    public DateTime Time { get; set; }
+
+    [JsonPropertyName("error")]
+    public string Error { get; set; } = "";
+
+    [JsonPropertyName("dump")]
+    public string? Dump { get; set; }
+}
+
+/// <summary>
+/// Tracks how long a session's events.jsonl has gone without growing.
+/// managedsoftwareupdate heartbeats into it during long downloads and waits
+/// (<see cref="SessionLogger.HeartbeatInterval"/>), so a log that stops growing
+/// means the run is stuck, not busy.
+/// </summary>
+public class EventLogSilence
+{
+    private string? _sessionDir;
+    private long _length = -1;
+    private DateTime _changedAt;
+
+    /// <summary>Records the log's length now and returns how long it has been unchanged.</summary>
+    public TimeSpan Observe(string sessionDir, long length, DateTime now)
+    {
+        if (!string.Equals(sessionDir, _sessionDir, StringComparison.OrdinalIgnoreCase) || length != _length)
+        {
+            _sessionDir = sessionDir;
+            _length = length;
+            _changedAt = now;
+        }
+        return now - _changedAt;
+    }
+
+    public void Reset()
+    {
+        _sessionDir = null;
+        _length = -1;
+    }
+}
+
+/// <summary>
+/// Watches managedsoftwareupdate runs for hangs. A run whose events.jsonl
+/// hasn't grown for HungRunMinutes (default 60, 0 turns it off) has its process
+/// tree killed, is counted in <see cref="CimianPaths.WatchdogJson"/>, and with
+/// RestartHungRuns is started again through <see cref="FileWatcherService"/>.
+/// A run the watchdog itself restarted is never restarted a second time.
+/// </summary>
+public class RunWatchdogService : BackgroundService
+{
+    private const string ProcessName = "managedsoftwareupdate";
+    private static readonly TimeSpan CheckInterval = TimeSpan.FromMinutes(1);
+    private static readonly TimeSpan ExitWait = TimeSpan.FromMinutes(2);
+
+    private readonly ILogger<RunWatchdogService> _logger;
+    private readonly FileWatcherService _watcher;
+    private readonly EventLogSilence _silence = new();
+    private int? _restartedPid;
+
+    public RunWatchdogService(ILogger<RunWatchdogService> logger, FileWatcherService watcher)
+    {
+        _logger = logger;
+        _watcher = watcher;
+    }
+
+    /// <summary>
+    /// The arguments that start a killed run of <paramref name="runType"/> again,
+    /// or null for runs that aren't restarted (manual, on-demand, dry runs).
+    /// </summary>
+    public static string? RestartArguments(string? runType) => runType switch
+    {
+        "auto" or "bootstrap" => "--auto --show-status",
+        "checkonly" => "--checkonly",
+        "installonly" => "--installonly",
+        _ => null
+    };
+
+    protected override async Task ExecuteAsync(CancellationToken stoppingToken)
+    {
+        while (!stoppingToken.IsCancellationRequested)
+        {
+            try
+            {
+                await CheckAsync(stoppingToken);
+            }
+            catch (OperationCanceledException) when (stoppingToken.IsCancellationRequested)
+            {
+                break;
+            }
+            catch (Exception ex)
+            {
+                _logger.LogError(ex, "Error in run watchdog");
+            }
+
+            try
+            {
+                await Task.Delay(CheckInterval, stoppingToken);
+            }
+            catch (OperationCanceledException)
+            {
+                break;
+            }
+        }
+    }
+
+    private async Task CheckAsync(CancellationToken cancellationToken)
+    {
+        var config = LoadConfig();
+        var run = config.Enabled ? FindRunningSession() : null;
+        if (run == null)
+        {
+            _silence.Reset();
+            return;
+        }
+
+        var silent = _silence.Observe(run.SessionDir, EventLogLength(run.SessionDir), DateTime.Now);
+        if (silent < config.Limit)
+        {
+            return;
+        }
+        _silence.Reset();
+        await HandleHungRunAsync(run, config, silent, cancellationToken);
+    }
+
+    private async Task HandleHungRunAsync(RunningSession run, WatchdogConfig config, TimeSpan silent, CancellationToken cancellationToken)
+    {
+        var lastEvent = LastEvent(run.SessionDir);
+        _logger.LogWarning("managedsoftwareupdate (PID {Pid}, session {Session}, {RunType}) has logged nothing for {Minutes} minutes; last event: {LastEvent}. Killing it",
+            run.Process.Id, run.SessionId, run.RunType, (int)silent.TotalMinutes, lastEvent ?? "none");
+
+        try
+        {
+            run.Process.Kill(entireProcessTree: true);
+            run.Process.WaitForExit((int)ExitWait.TotalMilliseconds);
+        }
+        catch (Exception ex) when (ex is InvalidOperationException or System.ComponentModel.Win32Exception or AggregateException)
+        {
+            _logger.LogWarning("Could not kill managedsoftwareupdate (PID {Pid}): {Message}", run.Process.Id, ex.Message);
+        }
+
+        // Restart once: a run the watchdog started that hangs again is left for an administrator
+        var args = RestartArguments(run.RunType);
+        var restart = config.RestartHungRuns && args != null && run.Process.Id != _restartedPid;
+        var record = new HungRunRecord
+        {
+            SessionId = run.SessionId,
+            RunType = run.RunType,
+            KilledAt = DateTime.Now,
+            SilentMinutes = (int)silent.TotalMinutes,
+            LastEvent = lastEvent
+        };
+
+        if (restart)
+        {
+            // The killed run still holds FileWatcherService's run slot until its wait notices the exit
+            var deadline = DateTime.UtcNow + ExitWait;
+            while (_watcher.IsUpdateRunning && DateTime.UtcNow < deadline)
+            {
+                await Task.Delay(TimeSpan.FromSeconds(2), cancellationToken);
+            }
+
+            _restartedPid = await _watcher.TryStartRunAsync(args!, withGUI: false, "watchdog", cancellationToken, "WatchdogRestart");
+            record.Restarted = _restartedPid != null;
+        }
+        else
+        {
+            _logger.LogInformation("Hung {RunType} run not restarted{Reason}", run.RunType,
+                !config.RestartHungRuns ? " (RestartHungRuns is off)"
+                : args == null ? "" : " - the watchdog already restarted it once");
+        }
+
+        try
+        {
+            var report = WatchdogReport.Update(r =>
+            {
+                r.HungRuns++;
+                r.LastHungRun = record;
+            });
+            _logger.LogWarning("Hung runs so far: {Count}", report.HungRuns);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            _logger.LogWarning("Could not update {Path}: {Message}", CimianPaths.WatchdogJson, ex.Message);
+        }
+    }
+
+    private sealed record RunningSession(Process Process, string SessionDir, string SessionId, string RunType);
+
+    /// <summary>
+    /// The running managedsoftwareupdate process and the session it's logging
+    /// to: the newest session still marked running, written by that process.
+    /// </summary>
+    private static RunningSession? FindRunningSession()
+    {
+        var processes = Process.GetProcessesByName(ProcessName);
+        if (processes.Length == 0)
+        {
+            return null;
+        }
+
+        var sessionDir = SessionLogger.GetLatestSessionDir();
+        var sessionPath = sessionDir == null ? null : Path.Combine(sessionDir, "session.json");
+        if (sessionPath == null || !File.Exists(sessionPath))
+        {
+            return null;
+        }
+
+        try
+        {
+            using var doc = JsonDocument.Parse(File.ReadAllText(sessionPath));
+            var root = doc.RootElement;
+            if (!root.TryGetProperty("status", out var status) || status.GetString() != "running"
+                || !root.TryGetProperty("environment", out var env)
+                || !env.TryGetProperty("process_id", out var pidElement) || !pidElement.TryGetInt32(out var pid))
+            {
+                return null;
+            }
+
+            var process = processes.FirstOrDefault(p => p.Id == pid);
+            return process == null ? null : new RunningSession(process, sessionDir!,
+                root.TryGetProperty("session_id", out var id) ? id.GetString() ?? "" : "",
+                root.TryGetProperty("run_type", out var runType) ? runType.GetString() ?? "" : "");
+        }
+        catch (Exception ex) when (ex is IOException or JsonException or UnauthorizedAccessException or InvalidOperationException)
+        {
+            // Mid-write; look again next pass
+            return null;
+        }
+    }
+
+    /// <summary>
+    /// The length of events.jsonl through an open handle: the directory entry's
+    /// size lags behind while managedsoftwareupdate holds the file open.
+    /// </summary>
+    private static long EventLogLength(string sessionDir)
+    {
+        try
+        {
+            using var stream = new FileStream(Path.Combine(sessionDir, "events.jsonl"), FileMode.Open, FileAccess.Read, FileShare.ReadWrite | FileShare.Delete);
+            return stream.Length;
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            return -1;
+        }
+    }
+
+    /// <summary>The last event's type and message, for the hung-run record.</summary>
+    private static string? LastEvent(string sessionDir)
+    {
+        try
+        {
+            using var stream = new FileStream(Path.Combine(sessionDir, "events.jsonl"), FileMode.Open, FileAccess.Read, FileShare.ReadWrite | FileShare.Delete);
+            stream.Seek(Math.Max(0, stream.Length - 8192), SeekOrigin.Begin);
+            using var reader = new StreamReader(stream);
+            var line = reader.ReadToEnd().Split('\n', StringSplitOptions.RemoveEmptyEntries).LastOrDefault()?.Trim();
+            if (string.IsNullOrEmpty(line))
+            {
+                return null;
+            }
+
+            using var doc = JsonDocument.Parse(line);
+            var type = doc.RootElement.TryGetProperty("event_type", out var t) ? t.GetString() : null;
+            var message = doc.RootElement.TryGetProperty("message", out var m) ? m.GetString() : null;
+            return $"{type}: {message}";
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException or JsonException)
+        {
+            return null;
+        }
+    }
+
+    private WatchdogConfig LoadConfig()
+    {
+        try
+        {
+            if (File.Exists(CimianPaths.ConfigYaml))
+            {
+                return YamlUtils.Deserializer.Deserialize<WatchdogConfig>(File.ReadAllText(CimianPaths.ConfigYaml)) ?? new WatchdogConfig();
+            }
+        }
+        catch (Exception ex)
+        {
+            _logger.LogWarning("Could not read {Path}: {Message}", CimianPaths.ConfigYaml, ex.Message);
+        }
+        return new WatchdogConfig();
+    }
+}
@@ -0,0 +1,131 @@
+using System.Diagnostics;
+using System.Runtime.InteropServices;
+using Cimian.Core;
+using Microsoft.Extensions.DependencyInjection;
+using Microsoft.Extensions.Hosting;
+using Serilog;
+
+namespace Cimian.CLI.Cimiwatcher.Services;
+
+/// <summary>
+/// Turns an unhandled exception in CimianWatcher into a clean restart: writes
+/// a minidump and the exception to logs\crashdumps, counts the crash in
+/// <see cref="CimianPaths.WatchdogJson"/>, flushes the log and exits non-zero
+/// so the service recovery actions (restart after 60 seconds) bring it back.
+/// A background service that faults is handled the same way instead of
+/// leaving the service running without it.
+/// </summary>
+public static class ServiceCrashHandler
+{
+    private const int KeepDumps = 5;
+    private static int _crashing;
+
+    /// <summary>Hooks unhandled exceptions on any thread.</summary>
+    public static void Install()
+    {
+        AppDomain.CurrentDomain.UnhandledException += (_, e) =>
+            Crash(e.ExceptionObject as Exception ?? new Exception(e.ExceptionObject?.ToString()), "unhandled exception");
+    }
+
+    /// <summary>
+    /// Restarts the service when one of the host's background services faults.
+    /// Needs <see cref="BackgroundServiceExceptionBehavior.Ignore"/> so the host
+    /// doesn't stop itself first; call after the host has started.
+    /// </summary>
+    public static void Watch(IHost host)
+    {
+        foreach (var service in host.Services.GetServices<IHostedService>().OfType<BackgroundService>())
+        {
+            service.ExecuteTask?.ContinueWith(task =>
+                Crash(task.Exception!.GetBaseException(), service.GetType().Name),
+                CancellationToken.None, TaskContinuationOptions.OnlyOnFaulted, TaskScheduler.Default);
+        }
+    }
+
+    private static void Crash(Exception exception, string source)
+    {
+        if (Interlocked.Exchange(ref _crashing, 1) != 0)
+        {
+            return;
+        }
+
+        Log.Fatal(exception, "CimianWatcher crashed in {Source}; restarting", source);
+        try
+        {
+            var dump = WriteDump(exception, source, DateTime.Now);
+            WatchdogReport.Update(r =>
+            {
+                r.ServiceCrashes++;
+                r.LastCrash = new ServiceCrashRecord
+                {
+                    Time = DateTime.Now,
+                    Error = $"{source}: {exception.GetType().Name}: {exception.Message}",
+                    Dump = dump
+                };
+            });
+        }
+        catch (Exception ex)
+        {
+            Log.Error("Could not record the crash: {Message}", ex.Message);
+        }
+        finally
+        {
+            Log.CloseAndFlush();
+            Environment.Exit(1);
+        }
+    }
+
+    /// <summary>
+    /// Writes cimiwatcher-&lt;time&gt;.dmp and a .txt with the exception, keeping
+    /// the newest <see cref="KeepDumps"/>. Returns the dump's path, or null if
+    /// only the text could be written.
+    /// </summary>
+    private static string? WriteDump(Exception exception, string source, DateTime now)
+    {
+        Directory.CreateDirectory(CimianPaths.CrashDumpsDir);
+        var basePath = Path.Combine(CimianPaths.CrashDumpsDir, $"cimiwatcher-{now:yyyyMMdd-HHmmss}");
+        File.WriteAllText(basePath + ".txt", $"{now:yyyy-MM-dd HH:mm:ss} {source}{Environment.NewLine}{exception}");
+
+        string? dumpPath = basePath + ".dmp";
+        try
+        {
+            using var process = Process.GetCurrentProcess();
+            using var file = new FileStream(dumpPath, FileMode.Create, FileAccess.ReadWrite, FileShare.None);
+            if (!MiniDumpWriteDump(process.Handle, (uint)process.Id, file.SafeFileHandle, MiniDumpWithDataSegs | MiniDumpWithThreadInfo,
+                    IntPtr.Zero, IntPtr.Zero, IntPtr.Zero))
+            {
+                Log.Error("MiniDumpWriteDump failed: error {Error}", Marshal.GetLastWin32Error());
+                dumpPath = null;
+            }
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException or DllNotFoundException)
+        {
+            Log.Error("Could not write a crash dump: {Message}", ex.Message);
+            dumpPath = null;
+        }
+
+        foreach (var old in Directory.GetFiles(CimianPaths.CrashDumpsDir, "cimiwatcher-*.*")
+                     .GroupBy(Path.GetFileNameWithoutExtension)
+                     .OrderByDescending(g => g.Key, StringComparer.Ordinal)
+                     .Skip(KeepDumps)
+                     .SelectMany(g => g))
+        {
+            try
+            {
+                File.Delete(old);
+            }
+            catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+            {
+                // Tried again after the next crash
+            }
+        }
+        return dumpPath;
+    }
+
+    private const uint MiniDumpWithDataSegs = 0x00000001;
+    private const uint MiniDumpWithThreadInfo = 0x00001000;
+
+    [DllImport("dbghelp.dll", SetLastError = true)]
+    private static extern bool MiniDumpWriteDump(IntPtr hProcess, uint processId, Microsoft.Win32.SafeHandles.SafeFileHandle hFile,
+        uint dumpType, IntPtr exceptionParam, IntPtr userStreamParam, IntPtr callbackParam);
+}
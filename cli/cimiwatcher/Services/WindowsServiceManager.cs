@@ -45,6 +45,8 @@ public class WindowsServiceManager
 
             // Configure recovery options (restart on failure)
             RunScCommand($"failure {ServiceName} reset= 86400 actions= restart/60000/restart/60000/restart/60000");
+            // Also recover when the service stops itself with an error (ServiceCrashHandler)
+            RunScCommand($"failureflag {ServiceName} 1");
 
             Console.WriteLine($"Service {ServiceName} installed successfully");
             
@@ -47,6 +47,53 @@ public class Installer
     /// <summary>MSIX/APPX package identity name (from AppxManifest Identity/@Name).</summary>
     [YamlMember(Alias = "identity_name")]
     public string? IdentityName { get; set; }
+
+    /// <summary>zip/iso installers: path of the real setup inside the archive.</summary>
+    [YamlMember(Alias = "inner_path")]
+    public string? InnerPath { get; set; }
+
+    /// <summary>zip/iso installers: type of the inner installer.</summary>
+    [YamlMember(Alias = "inner_type")]
+    public string? InnerType { get; set; }
+
+    /// <summary>delta installers: previous full installer the patch applies to.</summary>
+    [YamlMember(Alias = "base_location")]
+    public string? BaseLocation { get; set; }
+
+    [YamlMember(Alias = "base_hash")]
+    public string? BaseHash { get; set; }
+
+    /// <summary>delta installers: full new installer used when the patch can't be applied.</summary>
+    [YamlMember(Alias = "full_location")]
+    public string? FullLocation { get; set; }
+
+    [YamlMember(Alias = "full_hash")]
+    public string? FullHash { get; set; }
+
+    [YamlMember(Alias = "target_type")]
+    public string? TargetType { get; set; }
+
+    /// <summary>chocolatey installers: feed package id, ChocolateySources names and version pin.</summary>
+    [YamlMember(Alias = "package_id")]
+    public string? PackageId { get; set; }
+
+    [YamlMember(Alias = "sources")]
+    public List<string>? Sources { get; set; }
+
+    [YamlMember(Alias = "pin")]
+    public bool? Pin { get; set; }
+
+    /// <summary>registry uninstallers: regex matched against Uninstall-hive DisplayNames.</summary>
+    [YamlMember(Alias = "display_name_regex")]
+    public string? DisplayNameRegex { get; set; }
+
+    /// <summary>Extra exit codes that mean success (0 always does).</summary>
+    [YamlMember(Alias = "success_exit_codes")]
+    public List<int>? SuccessExitCodes { get; set; }
+
+    /// <summary>Exit codes that mean success with a restart needed (default 3010, 1641).</summary>
+    [YamlMember(Alias = "reboot_exit_codes")]
+    public List<int>? RebootExitCodes { get; set; }
 }
 
 /// <summary>
@@ -108,6 +155,9 @@ public class PkgsInfo
     [YamlMember(Alias = "description")]
     public string? Description { get; set; }
 
+    [YamlMember(Alias = "release_notes")]
+    public string? ReleaseNotes { get; set; }
+
     [YamlMember(Alias = "catalogs")]
     public List<string> Catalogs { get; set; } = new();
 
@@ -117,18 +167,27 @@ public class PkgsInfo
     [YamlMember(Alias = "developer")]
     public string? Developer { get; set; }
 
+    [YamlMember(Alias = "icon_name")]
+    public string? IconName { get; set; }
+
     [YamlMember(Alias = "requires")]
     public List<string>? Requires { get; set; }
 
     [YamlMember(Alias = "update_for")]
     public List<string>? UpdateFor { get; set; }
 
+    [YamlMember(Alias = "aliases")]
+    public List<string>? Aliases { get; set; }
+
     [YamlMember(Alias = "installs")]
     public List<InstallItem>? Installs { get; set; }
 
     [YamlMember(Alias = "blocking_applications")]
     public List<string>? BlockingApplications { get; set; }
 
+    [YamlMember(Alias = "force_close_blocking_apps")]
+    public bool? ForceCloseBlockingApps { get; set; }
+
     [YamlMember(Alias = "supported_architectures")]
     public List<string>? SupportedArchitectures { get; set; }
 
@@ -167,6 +226,12 @@ public class PkgsInfo
     [YamlMember(Alias = "postinstall_script")]
     public string? PostinstallScript { get; set; }
 
+    [YamlMember(Alias = "preinstall_scripts")]
+    public List<RepoScriptRef>? PreinstallScripts { get; set; } // scripts/ in the repo, pinned by hash
+
+    [YamlMember(Alias = "postinstall_scripts")]
+    public List<RepoScriptRef>? PostinstallScripts { get; set; }
+
     [YamlMember(Alias = "preuninstall_script")]
     public string? PreuninstallScript { get; set; }
 
@@ -182,9 +247,23 @@ public class PkgsInfo
     [YamlMember(Alias = "uninstallable")]
     public bool? Uninstallable { get; set; }
 
+    [YamlMember(Alias = "self_service_uninstall")]
+    public bool SelfServiceUninstall { get; set; }
+
+    [YamlMember(Alias = "self_update_channel")]
+    public string? SelfUpdateChannel { get; set; }
+
+    [YamlMember(Alias = "self_update_rollout_percent")]
+    public int? SelfUpdateRolloutPercent { get; set; }
+
     [YamlMember(Alias = "install_window")]
     public InstallWindow? InstallWindow { get; set; }
 
+    // Minutes this item's installer may run before it's stopped; must survive
+    // into the catalog, the client never reads pkgsinfo directly.
+    [YamlMember(Alias = "installer_timeout")]
+    public int? InstallerTimeout { get; set; }
+
     [YamlMember(Alias = "OnDemand")]
     public bool OnDemand { get; set; }
 
@@ -196,6 +275,52 @@ public class PkgsInfo
     [YamlMember(Alias = "recurring")]
     public bool Recurring { get; set; }
 
+    // MSIX/APPX machine-wide provisioning (default) vs per-account install.
+    [YamlMember(Alias = "provision")]
+    public bool? Provision { get; set; }
+
+    // Authenticode signer (thumbprint or subject text) required of EXE/MSI payloads.
+    [YamlMember(Alias = "expected_signer")]
+    public string? ExpectedSigner { get; set; }
+
+    // in-place (default), uninstall-first or side-by-side.
+    [YamlMember(Alias = "upgrade_strategy")]
+    public string? UpgradeStrategy { get; set; }
+
+    // system (default) or user: run the installer as the logged-in user.
+    [YamlMember(Alias = "install_context")]
+    public string? InstallContext { get; set; }
+
+    // installer type configuration: test/set script pair or DSC resource.
+    [YamlMember(Alias = "configuration")]
+    public ConfigurationPayload? Configuration { get; set; }
+
+    // Deferral budget and deadline for disruptive installs.
+    [YamlMember(Alias = "max_deferrals")]
+    public int? MaxDeferrals { get; set; }
+
+    [YamlMember(Alias = "force_install_after_date")]
+    public DateTime? ForceInstallAfterDate { get; set; }
+
+    // Downloaded even on a metered connection under RespectMeteredConnections.
+    [YamlMember(Alias = "critical")]
+    public bool? Critical { get; set; }
+
+    // Disk space preconditions in MB, checked on the client before install.
+    [YamlMember(Alias = "installer_size_required")]
+    public long? InstallerSizeRequired { get; set; }
+
+    [YamlMember(Alias = "minimum_free_disk_mb")]
+    public long? MinimumFreeDiskMb { get; set; }
+
+    // RequireRestart / RecommendRestart / RequireLogout; requires_restart is the
+    // boolean shorthand for RequireRestart.
+    [YamlMember(Alias = "restart_action")]
+    public string? RestartAction { get; set; }
+
+    [YamlMember(Alias = "requires_restart")]
+    public bool? RequiresRestart { get; set; }
+
     /// <summary>
     /// Source file path (not serialized)
     /// </summary>
@@ -203,6 +328,36 @@ public class PkgsInfo
     public string FilePath { get; set; } = string.Empty;
 }
 
+/// <summary>
+/// A configuration item's payload: test_script/set_script, or a DSC resource.
+/// </summary>
+public class ConfigurationPayload
+{
+    [YamlMember(Alias = "test_script")]
+    public string? TestScript { get; set; }
+
+    [YamlMember(Alias = "set_script")]
+    public string? SetScript { get; set; }
+
+    [YamlMember(Alias = "dsc_resource")]
+    public DscResourceInfo? DscResource { get; set; }
+}
+
+public class DscResourceInfo
+{
+    [YamlMember(Alias = "name")]
+    public string? Name { get; set; }
+
+    [YamlMember(Alias = "module")]
+    public string? Module { get; set; }
+
+    [YamlMember(Alias = "module_version")]
+    public string? ModuleVersion { get; set; }
+
+    [YamlMember(Alias = "properties")]
+    public Dictionary<string, object>? Properties { get; set; }
+}
+
 /// <summary>
 /// Unused-software removal opt-in (paths gate removal by recorded usage;
 /// minimum_history_days is a Cimian extension).
@@ -219,6 +374,21 @@ public class UnusedSoftwareRemovalInfo
     public int? MinimumHistoryDays { get; set; }
 }
 
+/// <summary>
+/// A script in the repo's scripts/ directory (name), pinned by SHA256 (hash).
+/// </summary>
+public class RepoScriptRef
+{
+    [YamlMember(Alias = "name")]
+    public string Name { get; set; } = string.Empty;
+
+    [YamlMember(Alias = "hash")]
+    public string? Hash { get; set; }
+
+    [YamlMember(Alias = "timeout")]
+    public int? Timeout { get; set; }
+}
+
 /// <summary>
 /// Time window during which installation is allowed
 /// </summary>
@@ -239,6 +409,14 @@ public class InstallWindow
 /// </summary>
 public class CatalogFile
 {
+    /// <summary>
+    /// Monotonically increasing catalog generation (Unix milliseconds, bumped past
+    /// the previous value if the clock went backwards). Clients persist the last
+    /// generation they acted on and refuse older catalogs (replay protection).
+    /// </summary>
+    [YamlMember(Alias = "generation")]
+    public long? Generation { get; set; }
+
     [YamlMember(Alias = "items")]
     public List<PkgsInfo> Items { get; set; } = new();
 }
@@ -1,6 +1,8 @@
 using System.CommandLine;
+using System.Security.Cryptography;
 using Cimian.CLI.Makecatalogs.Services;
 using Cimian.Core;
+using Cimian.Core.Services;
 using YamlDotNet.Serialization;
 using YamlDotNet.Serialization.NamingConventions;
 
@@ -34,6 +36,14 @@ class Program
             aliases: ["--silent", "-q"],
             description: "Minimize output");
 
+        var compressOption = new Option<bool>(
+            aliases: ["--compress"],
+            description: "Also write .yaml.gz and .yaml.zst copies of each catalog for servers that serve pre-compressed files");
+
+        var signingKeyOption = new Option<string?>(
+            aliases: ["--signing-key"],
+            description: "PEM private key (ECDSA or RSA) to write a detached .sig for each catalog and manifest");
+
         var versionOption = new Option<bool>(
             aliases: ["-V"],
             description: "Print version and exit");
@@ -42,6 +52,8 @@ class Program
         rootCommand.AddOption(skipPayloadCheckOption);
         rootCommand.AddOption(hashCheckOption);
         rootCommand.AddOption(silentOption);
+        rootCommand.AddOption(compressOption);
+        rootCommand.AddOption(signingKeyOption);
         rootCommand.AddOption(versionOption);
 
         rootCommand.SetHandler((context) =>
@@ -50,11 +62,13 @@ class Program
             var skipPayloadCheck = context.ParseResult.GetValueForOption(skipPayloadCheckOption);
             var hashCheck = context.ParseResult.GetValueForOption(hashCheckOption);
             var silent = context.ParseResult.GetValueForOption(silentOption);
+            var compress = context.ParseResult.GetValueForOption(compressOption);
+            var signingKey = context.ParseResult.GetValueForOption(signingKeyOption);
             var showVersion = context.ParseResult.GetValueForOption(versionOption);
 
             try
             {
-                context.ExitCode = Run(repoPath, skipPayloadCheck, hashCheck, silent, showVersion);
+                context.ExitCode = Run(repoPath, skipPayloadCheck, hashCheck, silent, compress, signingKey, showVersion);
             }
             catch (Exception ex)
             {
@@ -66,7 +80,7 @@ class Program
         return await rootCommand.InvokeAsync(args);
     }
 
-    private static int Run(string? repoPath, bool skipPayloadCheck, bool hashCheck, bool silent, bool showVersion)
+    private static int Run(string? repoPath, bool skipPayloadCheck, bool hashCheck, bool silent, bool compress, string? signingKeyPath, bool showVersion)
     {
         if (showVersion)
         {
@@ -85,6 +99,20 @@ class Program
             }
         }
 
+        AsymmetricAlgorithm? signingKey = null;
+        if (!string.IsNullOrEmpty(signingKeyPath))
+        {
+            try
+            {
+                signingKey = MetadataSignature.LoadKey(signingKeyPath);
+            }
+            catch (Exception ex) when (ex is CryptographicException or IOException)
+            {
+                Console.Error.WriteLine($"Error: Could not load --signing-key {signingKeyPath}: {ex.Message}");
+                return 1;
+            }
+        }
+
         // Run catalog builder
         var builder = new CatalogBuilder(
             log: silent ? null : Console.WriteLine,
@@ -92,7 +120,7 @@ class Program
             success: msg => Console.WriteLine(msg)
         );
 
-        return builder.Run(repoPath, skipPayloadCheck, hashCheck, silent);
+        return builder.Run(repoPath, skipPayloadCheck, hashCheck, silent, compress, signingKey);
     }
 
     private static string? LoadRepoPathFromConfig()
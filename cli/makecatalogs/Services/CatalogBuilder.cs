@@ -1,5 +1,9 @@
+using System.IO.Compression;
+using System.Security.Cryptography;
+using System.Text;
 using Cimian.CLI.Makecatalogs.Models;
 using Cimian.Core.Services;
+using ZstdSharp;
 
 namespace Cimian.CLI.Makecatalogs.Services;
 
@@ -111,6 +115,62 @@ public class CatalogBuilder
                 }
             }
 
+            // Delta installers need their full installer in the repo as the fallback
+            // for clients that don't have the base version cached.
+            if (string.Equals(pkg.Installer?.Type, "delta", StringComparison.OrdinalIgnoreCase))
+            {
+                if (string.IsNullOrEmpty(pkg.Installer!.FullLocation))
+                {
+                    warnings.Add($"{pkg.FilePath} is a delta installer without full_location");
+                }
+                else
+                {
+                    var fullPath = "pkgs/" + pkg.Installer.FullLocation.TrimStart('/', '\\').Replace('\\', '/');
+                    if (!existingFiles.Contains(fullPath))
+                    {
+                        warnings.Add($"{pkg.FilePath} has missing full installer => {fullPath}");
+                    }
+                }
+            }
+
+            if (Cimian.Core.Models.UpgradeStrategy.Normalize(pkg.UpgradeStrategy) == null)
+            {
+                warnings.Add($"{pkg.FilePath} has unknown upgrade_strategy '{pkg.UpgradeStrategy}' " +
+                             $"(expected {string.Join(", ", Cimian.Core.Models.UpgradeStrategy.All)})");
+            }
+
+            if (Cimian.Core.Models.InstallContext.Normalize(pkg.InstallContext) == null)
+            {
+                warnings.Add($"{pkg.FilePath} has unknown install_context '{pkg.InstallContext}' " +
+                             $"(expected {string.Join(", ", Cimian.Core.Models.InstallContext.All)})");
+            }
+
+            if (string.Equals(pkg.Installer?.Type, "configuration", StringComparison.OrdinalIgnoreCase))
+            {
+                var configuration = pkg.Configuration;
+                if (configuration?.DscResource is { } dsc)
+                {
+                    if (string.IsNullOrWhiteSpace(dsc.Name) || string.IsNullOrWhiteSpace(dsc.Module))
+                    {
+                        warnings.Add($"{pkg.FilePath} has a dsc_resource without name or module");
+                    }
+                }
+                else if (string.IsNullOrWhiteSpace(configuration?.TestScript) || string.IsNullOrWhiteSpace(configuration?.SetScript))
+                {
+                    warnings.Add($"{pkg.FilePath} is a configuration item without test_script and set_script or dsc_resource");
+                }
+            }
+
+            foreach (var entry in new[] { pkg.Installer }.Concat(pkg.Uninstaller ?? []))
+            {
+                var overlap = (entry?.SuccessExitCodes ?? []).Intersect(entry?.RebootExitCodes ?? []).ToList();
+                if (overlap.Count > 0)
+                {
+                    warnings.Add($"{pkg.FilePath} lists exit code(s) {string.Join(", ", overlap)} in both " +
+                                 "success_exit_codes and reboot_exit_codes (success_exit_codes wins, no restart)");
+                }
+            }
+
             // Validate every uninstaller entry that references a file on disk.
             // MSIX/APPX uninstallers have only identity_name (no Location) so they're
             // skipped here and handled at runtime by managedsoftwareupdate.
@@ -217,6 +277,13 @@ public class CatalogBuilder
             while (pkg.Description.Contains("\n\n\n"))
                 pkg.Description = pkg.Description.Replace("\n\n\n", "\n\n");
         }
+
+        if (pkg.ReleaseNotes != null)
+        {
+            pkg.ReleaseNotes = pkg.ReleaseNotes.Replace("\r\n", "\n").Replace("\r", "\n");
+            while (pkg.ReleaseNotes.Contains("\n\n\n"))
+                pkg.ReleaseNotes = pkg.ReleaseNotes.Replace("\n\n\n", "\n\n");
+        }
         
         if (pkg.PreinstallScript != null)
         {
@@ -262,21 +329,37 @@ public class CatalogBuilder
     }
 
     /// <summary>
-    /// Writes catalog files to the repository
+    /// Extensions of the pre-compressed copies written next to each catalog when
+    /// compressing, for servers that serve them as Content-Encoding gzip / zstd
+    /// (nginx gzip_static, Caddy precompressed, and the like).
+    /// </summary>
+    public static readonly string[] PrecompressedExtensions = [".gz", ".zst"];
+
+    /// <summary>
+    /// Writes catalog files to the repository. With <paramref name="compress"/>
+    /// each catalog also gets .yaml.gz and .yaml.zst copies; without it any
+    /// copies left by an earlier run are removed so they can't go stale. The same
+    /// goes for the detached .sig written when <paramref name="signingKey"/> is set.
     /// </summary>
-    public void WriteCatalogs(string repoPath, Dictionary<string, List<PkgsInfo>> catalogs, bool silent = false)
+    public void WriteCatalogs(string repoPath, Dictionary<string, List<PkgsInfo>> catalogs, bool silent = false, bool compress = false, AsymmetricAlgorithm? signingKey = null)
     {
         var catalogDir = Path.Combine(repoPath, "catalogs");
         Directory.CreateDirectory(catalogDir);
 
         // Remove stale catalog files
         var existingCatalogs = Directory.GetFiles(catalogDir, "*.yaml");
+
+        // One generation for the whole run, strictly above anything already
+        // published, so clients can reject a replayed older catalog.
+        var generation = NextGeneration(existingCatalogs, DateTimeOffset.UtcNow.ToUnixTimeMilliseconds());
         foreach (var existingFile in existingCatalogs)
         {
             var baseName = Path.GetFileNameWithoutExtension(existingFile);
             if (!catalogs.ContainsKey(baseName))
             {
                 File.Delete(existingFile);
+                DeletePrecompressed(existingFile);
+                File.Delete(existingFile + MetadataSignature.DetachedExtension);
                 if (!silent)
                 {
                     _warn($"Removed stale catalog {existingFile}");
@@ -295,22 +378,158 @@ public class CatalogBuilder
                 NormalizeLineEndings(item);
             }
 
-            var catalogWrapper = new CatalogFile { Items = items };
+            var catalogWrapper = new CatalogFile { Generation = generation, Items = items };
             var yaml = YamlUtils.SerializeCatalog(catalogWrapper);
 
             File.WriteAllText(outPath, yaml);
+            if (compress)
+            {
+                WritePrecompressed(outPath, yaml);
+            }
+            else
+            {
+                DeletePrecompressed(outPath);
+            }
+            WriteSignature(outPath, yaml, signingKey);
 
             if (!silent)
             {
-                _success($"Wrote catalog {catName} ({items.Count} items)");
+                _success($"Wrote catalog {catName} ({items.Count} items{(compress ? ", precompressed" : "")}{(signingKey != null ? ", signed" : "")})");
             }
         }
     }
 
+    private static void WritePrecompressed(string catalogPath, string yaml)
+    {
+        var bytes = Encoding.UTF8.GetBytes(yaml);
+
+        using (var gzip = new GZipStream(File.Create(catalogPath + ".gz"), CompressionLevel.SmallestSize))
+        {
+            gzip.Write(bytes);
+        }
+
+        using var compressor = new Compressor(19);
+        File.WriteAllBytes(catalogPath + ".zst", compressor.Wrap(bytes).ToArray());
+    }
+
+    /// <summary>
+    /// Writes a detached .sig for every manifest under manifests\, so clients with
+    /// MetadataSigningKeys can verify them. Manifests that already end in an
+    /// embedded signature are left alone.
+    /// </summary>
+    public void SignManifests(string repoPath, AsymmetricAlgorithm signingKey, bool silent = false)
+    {
+        var manifestsDir = Path.Combine(repoPath, "manifests");
+        if (!Directory.Exists(manifestsDir))
+        {
+            return;
+        }
+
+        var signed = 0;
+        foreach (var manifestPath in Directory.EnumerateFiles(manifestsDir, "*.yaml", SearchOption.AllDirectories))
+        {
+            var content = File.ReadAllText(manifestPath);
+            if (MetadataSignature.ExtractEmbedded(content, out _) != null)
+            {
+                continue;
+            }
+            WriteSignature(manifestPath, content, signingKey);
+            signed++;
+        }
+
+        if (!silent)
+        {
+            _success($"Signed {signed} manifests");
+        }
+    }
+
+    // Without a key any old signature is removed: it no longer matches
+    private static void WriteSignature(string path, string content, AsymmetricAlgorithm? signingKey)
+    {
+        var sigPath = path + MetadataSignature.DetachedExtension;
+        if (signingKey == null)
+        {
+            File.Delete(sigPath);
+            return;
+        }
+        File.WriteAllText(sigPath, MetadataSignature.Sign(content, signingKey));
+    }
+
+    private static void DeletePrecompressed(string catalogPath)
+    {
+        foreach (var extension in PrecompressedExtensions)
+        {
+            File.Delete(catalogPath + extension);
+        }
+    }
+
+    /// <summary>
+    /// Writes icons/_icon_hashes.yaml (icon file name to SHA-256) for the repo's
+    /// icons folder. Does nothing when the repo has no icons folder.
+    /// </summary>
+    public void WriteIconHashes(string repoPath, bool silent = false)
+    {
+        var iconsDir = Path.Combine(repoPath, "icons");
+        if (!Directory.Exists(iconsDir))
+        {
+            return;
+        }
+
+        var hashes = new SortedDictionary<string, string>(StringComparer.OrdinalIgnoreCase);
+        foreach (var file in Directory.EnumerateFiles(iconsDir))
+        {
+            var name = Path.GetFileName(file);
+            if (!IconCache.SupportedExtensions.Contains(Path.GetExtension(name), StringComparer.OrdinalIgnoreCase))
+            {
+                continue;
+            }
+            hashes[name] = IconCache.ComputeHash(file);
+        }
+
+        File.WriteAllText(Path.Combine(iconsDir, IconCache.HashesFileName), YamlUtils.Serializer.Serialize(hashes));
+        if (!silent)
+        {
+            _success($"Wrote icon hashes ({hashes.Count} icons)");
+        }
+    }
+
+    /// <summary>
+    /// Returns the generation for this makecatalogs run: the current time in Unix
+    /// milliseconds, or one past the highest generation found in the existing
+    /// catalogs if the clock is behind (clock skew between build hosts).
+    /// </summary>
+    public static long NextGeneration(IEnumerable<string> existingCatalogPaths, long nowUnixMs)
+    {
+        long highest = 0;
+        foreach (var path in existingCatalogPaths)
+        {
+            try
+            {
+                // generation is the first key serialized, so only scan the head
+                // of the file instead of deserializing a multi-MB catalog.
+                foreach (var line in File.ReadLines(path).Take(20))
+                {
+                    if (line.StartsWith("generation:", StringComparison.Ordinal) &&
+                        long.TryParse(line["generation:".Length..].Trim(), out var value))
+                    {
+                        highest = Math.Max(highest, value);
+                        break;
+                    }
+                }
+            }
+            catch (IOException)
+            {
+                // Unreadable catalog: it is about to be rewritten anyway
+            }
+        }
+
+        return Math.Max(nowUnixMs, highest + 1);
+    }
+
     /// <summary>
     /// Runs the complete catalog building process
     /// </summary>
-    public int Run(string repoPath, bool skipPayloadCheck = false, bool hashCheck = false, bool silent = false)
+    public int Run(string repoPath, bool skipPayloadCheck = false, bool hashCheck = false, bool silent = false, bool compress = false, AsymmetricAlgorithm? signingKey = null)
     {
         if (!silent)
         {
@@ -337,7 +556,14 @@ public class CatalogBuilder
             var catalogs = BuildCatalogs(items, silent);
 
             // Write catalogs
-            WriteCatalogs(repoPath, catalogs, silent);
+            WriteCatalogs(repoPath, catalogs, silent, compress, signingKey);
+            if (signingKey != null)
+            {
+                SignManifests(repoPath, signingKey, silent);
+            }
+
+            // Index icons so clients only download the ones that changed
+            WriteIconHashes(repoPath, silent);
 
             // Print warnings
             foreach (var warning in warnings)
@@ -84,9 +84,15 @@ public class PkgsInfo
     [YamlMember(Alias = "description", Order = 7, DefaultValuesHandling = DefaultValuesHandling.OmitNull)]
     public string? Description { get; set; }
 
+    [YamlMember(Alias = "release_notes", Order = 7, DefaultValuesHandling = DefaultValuesHandling.OmitNull)]
+    public string? ReleaseNotes { get; set; }
+
     [YamlMember(Alias = "developer", Order = 8, DefaultValuesHandling = DefaultValuesHandling.OmitNull)]
     public string? Developer { get; set; }
 
+    [YamlMember(Alias = "icon_name", Order = 8, DefaultValuesHandling = DefaultValuesHandling.OmitNull)]
+    public string? IconName { get; set; }
+
     [YamlMember(Alias = "installer_type", Order = 9, DefaultValuesHandling = DefaultValuesHandling.OmitNull)]
     public string? InstallerType { get; set; }
 
@@ -146,6 +152,18 @@ public class PkgsInfo
     [YamlMember(Alias = "unattended_uninstall", Order = 26, DefaultValuesHandling = DefaultValuesHandling.OmitDefaults)]
     public bool UnattendedUninstall { get; set; }
 
+    // Approves self-service removal under AllowSelfServiceUninstall: approved.
+    [YamlMember(Alias = "self_service_uninstall", Order = 26, DefaultValuesHandling = DefaultValuesHandling.OmitDefaults)]
+    public bool SelfServiceUninstall { get; set; }
+
+    // Self-update channel of a Cimian package build; unset means stable.
+    [YamlMember(Alias = "self_update_channel", Order = 26, DefaultValuesHandling = DefaultValuesHandling.OmitNull)]
+    public string? SelfUpdateChannel { get; set; }
+
+    // Staged rollout of a Cimian package build: percent of machines offered it.
+    [YamlMember(Alias = "self_update_rollout_percent", Order = 26, DefaultValuesHandling = DefaultValuesHandling.OmitNull)]
+    public int? SelfUpdateRolloutPercent { get; set; }
+
     /// <summary>
     /// Opt-in unused-software removal (unused_software_removal_info).
     /// </summary>
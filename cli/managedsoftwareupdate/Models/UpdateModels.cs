@@ -11,6 +11,14 @@ public class CimianConfig
     [YamlMember(Alias = "SoftwareRepoURL")]
     public string SoftwareRepoURL { get; set; } = string.Empty;
 
+    /// <summary>
+    /// Mirrors of the repo, tried in order after SoftwareRepoURL when it fails or
+    /// serves content that doesn't verify. When SoftwareRepoURL is unset the first
+    /// entry is the primary.
+    /// </summary>
+    [YamlMember(Alias = "SoftwareRepoURLs")]
+    public List<string> SoftwareRepoURLs { get; set; } = new();
+
     [YamlMember(Alias = "ClientIdentifier")]
     public string ClientIdentifier { get; set; } = string.Empty;
 
@@ -56,6 +64,48 @@ public class CimianConfig
     [YamlMember(Alias = "SkipSelfService")]
     public bool SkipSelfService { get; set; }
 
+    /// <summary>
+    /// always, approved or never: which self-service removal requests a run carries
+    /// out. approved limits them to items whose pkginfo sets self_service_uninstall;
+    /// held requests stay in the SelfServeManifest until the policy allows them.
+    /// </summary>
+    [YamlMember(Alias = "AllowSelfServiceUninstall")]
+    public string AllowSelfServiceUninstall { get; set; } = "always";
+
+    /// <summary>
+    /// Channel this machine takes Cimian self-updates from: stable, beta or dev.
+    /// Cimian packages whose pkginfo self_update_channel is less stable than this
+    /// are dropped from the catalog, so the newest build in this channel or a more
+    /// stable one is the one offered.
+    /// </summary>
+    [YamlMember(Alias = "SelfUpdateChannel")]
+    public string SelfUpdateChannel { get; set; } = "stable";
+
+    /// <summary>
+    /// Refuse builds that would run under emulation: on ARM64 machines, items
+    /// whose supported_architectures only name x64 or x86 are dropped instead of
+    /// being installed as the fallback when the repo has no arm64 build.
+    /// </summary>
+    [YamlMember(Alias = "ForbidEmulatedInstalls")]
+    public bool ForbidEmulatedInstalls { get; set; }
+
+    /// <summary>
+    /// Refuse to schedule a self-update whose package signature doesn't verify
+    /// (Authenticode for MSI, the embedded signature for .pkg). .nupkg packages
+    /// can't carry one, so they're refused while this is on. Default true.
+    /// </summary>
+    [YamlMember(Alias = "SelfUpdateRequireSignature")]
+    public bool SelfUpdateRequireSignature { get; set; } = true;
+
+    /// <summary>
+    /// Publisher a Cimian self-update package must be signed by: a certificate
+    /// thumbprint (40 hex digits) or text the signer's subject contains. Takes
+    /// precedence over the pkginfo's expected_signer, so a tampered repo can't
+    /// name its own signer. Unset falls back to expected_signer.
+    /// </summary>
+    [YamlMember(Alias = "SelfUpdateExpectedSigner")]
+    public string? SelfUpdateExpectedSigner { get; set; }
+
     [YamlMember(Alias = "AuthToken")]
     public string? AuthToken { get; set; }
 
@@ -66,7 +116,62 @@ public class CimianConfig
     public string? AuthPassword { get; set; }
 
     [YamlMember(Alias = "InstallerTimeout")]
-    public int InstallerTimeout { get; set; } = 900; // 15 minutes default
+    public int InstallerTimeout { get; set; } = 900; // seconds; DefaultInstallerTimeoutMinutes wins when set
+
+    /// <summary>
+    /// Minutes an installer or uninstaller may run before it is stopped along
+    /// with every process it started, unless the item sets installer_timeout.
+    /// 0 uses InstallerTimeout (seconds) instead.
+    /// </summary>
+    [YamlMember(Alias = "DefaultInstallerTimeoutMinutes")]
+    public int DefaultInstallerTimeoutMinutes { get; set; }
+
+    [YamlIgnore]
+    public TimeSpan EffectiveInstallerTimeout => DefaultInstallerTimeoutMinutes > 0
+        ? TimeSpan.FromMinutes(DefaultInstallerTimeoutMinutes)
+        : TimeSpan.FromSeconds(InstallerTimeout);
+
+    [YamlMember(Alias = "ScriptTimeout")]
+    public int ScriptTimeout { get; set; } = 300; // seconds a script may run unless ScriptPolicies or the script sets its own; 0 = no limit
+
+    /// <summary>
+    /// Runs preflight, pkginfo and repo scripts in PowerShell ConstrainedLanguage
+    /// mode unless their ScriptPolicies entry says otherwise.
+    /// </summary>
+    [YamlMember(Alias = "ScriptConstrainedLanguage")]
+    public bool ScriptConstrainedLanguage { get; set; }
+
+    /// <summary>
+    /// Per-kind script settings keyed by preflight, postflight, preinstall,
+    /// postinstall, preuninstall, postuninstall, install, uninstall or installcheck.
+    /// </summary>
+    [YamlMember(Alias = "ScriptPolicies")]
+    public Dictionary<string, ScriptPolicy> ScriptPolicies { get; set; } = new();
+
+    [YamlMember(Alias = "MaxConcurrentDownloads")]
+    public int MaxConcurrentDownloads { get; set; } = 4; // 1 = sequential
+
+    /// <summary>
+    /// Cap on the total download rate, in KB/s, shared by all concurrent
+    /// downloads. 0 = unlimited. DownloadRateSchedule windows override it.
+    /// </summary>
+    [YamlMember(Alias = "MaxDownloadRateKBps")]
+    public int MaxDownloadRateKBps { get; set; }
+
+    // Older name for MaxDownloadRateKBps, used when that isn't set
+    [YamlMember(Alias = "DownloadBandwidthLimitKBps")]
+    public int DownloadBandwidthLimitKBps { get; set; }
+
+    /// <summary>
+    /// Download rate caps for times of day, e.g. a low cap during business hours
+    /// so branch links stay usable. The first window containing the current time
+    /// wins; outside every window MaxDownloadRateKBps applies.
+    /// </summary>
+    [YamlMember(Alias = "DownloadRateSchedule")]
+    public List<DownloadRateWindow> DownloadRateSchedule { get; set; } = new();
+
+    [YamlIgnore]
+    public int EffectiveMaxDownloadRateKBps => MaxDownloadRateKBps > 0 ? MaxDownloadRateKBps : DownloadBandwidthLimitKBps;
 
     [YamlMember(Alias = "UseCache")]
     public bool UseCache { get; set; } = true;
@@ -74,6 +179,38 @@ public class CimianConfig
     [YamlMember(Alias = "CacheRetentionDays")]
     public int CacheRetentionDays { get; set; } = 30;
 
+    /// <summary>
+    /// Most the download cache may hold, in MB; the least recently used payloads
+    /// are evicted past it, keeping the newest of each item. 0 = unlimited.
+    /// </summary>
+    [YamlMember(Alias = "CacheSizeLimitMB")]
+    public int CacheSizeLimitMB { get; set; }
+
+    /// <summary>
+    /// Refuse to install payloads (and run repo scripts) with no hash in the
+    /// catalog. Off by default so items published without installer.hash keep
+    /// installing after an upgrade; each one logs a warning instead. A hash that
+    /// is present is always checked.
+    /// </summary>
+    [YamlMember(Alias = "RequireHashValidation")]
+    public bool RequireHashValidation { get; set; }
+
+    [YamlMember(Alias = "PurgeCacheOnUninstall")]
+    public bool PurgeCacheOnUninstall { get; set; } // delete an item's cached installers once it's removed
+
+    [YamlMember(Alias = "RequireSignedInstallers")]
+    public bool RequireSignedInstallers { get; set; } // refuse unsigned / untrusted / wrong-signer EXE and MSI payloads
+
+    /// <summary>
+    /// Public keys (PEM text or paths to PEM files) manifests and catalogs must be
+    /// signed with. A signature that none of them verifies is always refused.
+    /// </summary>
+    [YamlMember(Alias = "MetadataSigningKeys")]
+    public List<string> MetadataSigningKeys { get; set; } = new();
+
+    [YamlMember(Alias = "RequireSignedMetadata")]
+    public bool RequireSignedMetadata { get; set; } // refuse unsigned manifests and catalogs too
+
     // sbin-installer configuration (matches Go: config.Configuration)
     [YamlMember(Alias = "SbinInstallerPath")]
     public string? SbinInstallerPath { get; set; }
@@ -84,15 +221,57 @@ public class CimianConfig
     [YamlMember(Alias = "ForceChocolatey")]
     public bool ForceChocolatey { get; set; }
 
+    /// <summary>
+    /// Chocolatey feeds for chocolatey-type installers (and the .nupkg fallback).
+    /// Each is registered with choco as cimian-&lt;Name&gt; with its credentials,
+    /// and installs pass them with --source, so whatever other sources are
+    /// configured machine-wide are never consulted.
+    /// </summary>
+    [YamlMember(Alias = "ChocolateySources")]
+    public List<ChocolateySource> ChocolateySources { get; set; } = new();
+
     [YamlMember(Alias = "PreferSbinInstaller")]
     public bool PreferSbinInstaller { get; set; } = true;
 
     [YamlMember(Alias = "PkgRequireSignature")]
     public bool PkgRequireSignature { get; set; }
 
+    /// <summary>
+    /// Remove items Cimian installed once no manifest mentions them any more,
+    /// after UnmanagedItemGraceDays. Off by default; AutoRemove is the old name.
+    /// </summary>
+    [YamlMember(Alias = "RemoveUnmanagedItems")]
+    public bool RemoveUnmanagedItems { get; set; }
+
     [YamlMember(Alias = "AutoRemove")]
     public bool AutoRemove { get; set; }
 
+    [YamlIgnore]
+    public bool RemovesUnmanagedItems => RemoveUnmanagedItems || AutoRemove;
+
+    /// <summary>
+    /// Days an item must stay out of every manifest before RemoveUnmanagedItems
+    /// removes it, so a manifest edit that drops an item by mistake can be fixed
+    /// first. 0 removes it on the first run that notices.
+    /// </summary>
+    [YamlMember(Alias = "UnmanagedItemGraceDays")]
+    public int UnmanagedItemGraceDays { get; set; } = 7;
+
+    /// <summary>
+    /// What removing an item does to installed items that require it: cascade
+    /// (default) removes them first; block refuses the removal while any of them
+    /// isn't being removed too.
+    /// </summary>
+    [YamlMember(Alias = "DependentRemovalPolicy")]
+    public string DependentRemovalPolicy { get; set; } = "cascade";
+
+    /// <summary>
+    /// Remove items Cimian installed only because something required them once
+    /// nothing installed requires them and no manifest asks for them.
+    /// </summary>
+    [YamlMember(Alias = "RemoveOrphanedDependencies")]
+    public bool RemoveOrphanedDependencies { get; set; }
+
     /// <summary>
     /// Master switch for install-loop prevention (LoopGuard). On by default.
     /// Set to false in config.yaml to disable loop suppression fleet-wide — admins
@@ -113,6 +292,15 @@ public class CimianConfig
     [YamlMember(Alias = "LoopMaxTime")]
     public int LoopMaxTime { get; set; } = 7;
 
+    /// <summary>
+    /// Failed installs of the same version in a row before LoopGuard quarantines it.
+    /// A quarantined item is skipped and reported as quarantined_after_N_failures until
+    /// the catalog has a new version (or changed pkgsinfo) or --clear-loop releases it;
+    /// there is no timed retry. Default 5; 0 disables quarantine.
+    /// </summary>
+    [YamlMember(Alias = "QuarantineFailureThreshold")]
+    public int QuarantineFailureThreshold { get; set; } = 5;
+
     /// <summary>
     /// Master switch for unused-software removal (unused_software_removal_info).
     /// On by default — harmless fleet-wide because every package must still
@@ -149,6 +337,14 @@ public class CimianConfig
     [YamlMember(Alias = "ClientCertificateThumbprint")]
     public string? ClientCertificateThumbprint { get; set; }
 
+    /// <summary>
+    /// Certificate template (name or OID) of the machine certificate to present
+    /// when neither ClientCertificatePath nor ClientCertificateThumbprint is set.
+    /// The newest valid match in LocalMachine\My with a private key is used.
+    /// </summary>
+    [YamlMember(Alias = "ClientCertificateTemplate")]
+    public string? ClientCertificateTemplate { get; set; }
+
     [YamlMember(Alias = "ClientKeyPath")]
     public string? ClientKeyPath { get; set; }
 
@@ -159,6 +355,437 @@ public class CimianConfig
     [YamlMember(Alias = "UseClientCertificateCNAsClientIdentifier")]
     public bool UseClientCertificateCNAsClientIdentifier { get; set; }
 
+    /// <summary>
+    /// Deep diagnostics: time manifest fetch, catalog load, each download and each
+    /// install, record them to an ETW session (.etl for PerfView/WPA) and a
+    /// Chrome trace-event file under logs\traces. Same as --trace. Default false.
+    /// </summary>
+    [YamlMember(Alias = "TraceDiagnostics")]
+    public bool TraceDiagnostics { get; set; }
+
+    /// <summary>
+    /// Write session start/end, install results, blocked installs and self-update
+    /// scheduling to the "Cimian" Windows Event Log channel with stable event IDs
+    /// (see CimianEventId). Default true.
+    /// </summary>
+    [YamlMember(Alias = "EventLogEnabled")]
+    public bool EventLogEnabled { get; set; } = true;
+
+    /// <summary>
+    /// Days of session logs kept under logs\. Sessions past the newest
+    /// LogRetentionSessions are zipped, then deleted once they are this old. 0 = no age limit.
+    /// </summary>
+    [YamlMember(Alias = "LogRetentionDays")]
+    public int LogRetentionDays { get; set; } = Cimian.Core.Services.LogRetentionPolicy.DefaultRetentionDays;
+
+    /// <summary>Newest session logs always kept, uncompressed, whatever their age.</summary>
+    [YamlMember(Alias = "LogRetentionSessions")]
+    public int LogRetentionSessions { get; set; } = Cimian.Core.Services.LogRetentionPolicy.DefaultKeepSessions;
+
+    /// <summary>
+    /// Most the session logs may take up, in MB; the oldest go first past it. 0 = unlimited.
+    /// </summary>
+    [YamlMember(Alias = "MaxLogSizeMB")]
+    public int MaxLogSizeMB { get; set; }
+
+    [YamlIgnore]
+    public Cimian.Core.Services.LogRetentionPolicy LogRetentionPolicy =>
+        new(LogRetentionDays, LogRetentionSessions, MaxLogSizeMB);
+
+    /// <summary>
+    /// Accept catalogs whose generation is older than the last one acted on. Off by
+    /// default: downgrades are refused to stop replay of old catalogs that would
+    /// reinstall vulnerable versions. Turn on briefly to roll the repo back on purpose.
+    /// </summary>
+    [YamlMember(Alias = "AllowCatalogDowngrade")]
+    public bool AllowCatalogDowngrade { get; set; }
+
+    /// <summary>
+    /// When the repo can't be reached, run from the manifests and catalogs kept
+    /// from earlier runs if they are at most this many hours old. The session is
+    /// reported as degraded and only installs whose payload is already cached
+    /// proceed. 0 (default) keeps the old behavior of aborting without a manifest.
+    /// </summary>
+    [YamlMember(Alias = "OfflineCacheMaxAgeHours")]
+    public int OfflineCacheMaxAgeHours { get; set; }
+
+    /// <summary>
+    /// Have CimianWatcher HEAD the manifest and catalogs every RepoChangeWatchInterval
+    /// seconds and trigger a run only when one changed. Default false.
+    /// </summary>
+    [YamlMember(Alias = "RepoChangeWatch")]
+    public bool RepoChangeWatch { get; set; }
+
+    /// <summary>
+    /// Seconds between RepoChangeWatch polls (minimum 60). Default 300.
+    /// </summary>
+    [YamlMember(Alias = "RepoChangeWatchInterval")]
+    public int RepoChangeWatchInterval { get; set; } = 300;
+
+    /// <summary>
+    /// Optional server-sent-events endpoint; each event makes RepoChangeWatch poll immediately.
+    /// </summary>
+    [YamlMember(Alias = "RepoChangeEventsURL")]
+    public string? RepoChangeEventsURL { get; set; }
+
+    /// <summary>
+    /// Minutes between auto runs started by CimianWatcher itself, which then
+    /// disables the hourly scheduled task. 0 (default) leaves auto runs to the task.
+    /// </summary>
+    [YamlMember(Alias = "AutoRunIntervalMinutes")]
+    public int AutoRunIntervalMinutes { get; set; }
+
+    /// <summary>
+    /// Up to this many random minutes added to each CimianWatcher auto run, so
+    /// machines don't all hit the repo together. Default 10.
+    /// </summary>
+    [YamlMember(Alias = "AutoRunSplayMinutes")]
+    public int AutoRunSplayMinutes { get; set; } = 10;
+
+    /// <summary>
+    /// Run shortly after CimianWatcher starts instead of waiting an interval
+    /// from the last run. Default true.
+    /// </summary>
+    [YamlMember(Alias = "AutoRunAtStartup")]
+    public bool AutoRunAtStartup { get; set; } = true;
+
+    /// <summary>
+    /// Hold an auto run that comes due while the machine is offline until the
+    /// network is back. Default true.
+    /// </summary>
+    [YamlMember(Alias = "AutoRunOnNetworkAvailable")]
+    public bool AutoRunOnNetworkAvailable { get; set; } = true;
+
+    /// <summary>
+    /// Minutes a run may go without writing to its events.jsonl before
+    /// CimianWatcher treats it as hung and kills it. Default 60; 0 turns the
+    /// watchdog off.
+    /// </summary>
+    [YamlMember(Alias = "HungRunMinutes")]
+    public int HungRunMinutes { get; set; } = 60;
+
+    /// <summary>
+    /// Start an auto, check-only or install-only run again after the watchdog
+    /// kills it as hung (once). Default true.
+    /// </summary>
+    [YamlMember(Alias = "RestartHungRuns")]
+    public bool RestartHungRuns { get; set; } = true;
+
+    /// <summary>
+    /// Runs a bootstrap may take to install everything before it gives up,
+    /// counted across restarts. Default 5.
+    /// </summary>
+    [YamlMember(Alias = "BootstrapMaxAttempts")]
+    public int BootstrapMaxAttempts { get; set; } = 5;
+
+    /// <summary>
+    /// Minutes to wait after a bootstrap run that left items outstanding,
+    /// doubled after each further one (up to an hour). Default 2.
+    /// </summary>
+    [YamlMember(Alias = "BootstrapRetryDelayMinutes")]
+    public int BootstrapRetryDelayMinutes { get; set; } = 2;
+
+    /// <summary>
+    /// Cover the screen with CimianStatus's "Setting up your device" screen,
+    /// on the logon screen and after logon, until the bootstrap ends. Default false.
+    /// </summary>
+    [YamlMember(Alias = "BootstrapScreen")]
+    public bool BootstrapScreen { get; set; }
+
+    /// <summary>
+    /// Minutes after the bootstrap started that the setup screen gives up and
+    /// lets the user in. Default 240; 0 keeps it up until the bootstrap ends.
+    /// </summary>
+    [YamlMember(Alias = "BootstrapScreenTimeoutMinutes")]
+    public int BootstrapScreenTimeoutMinutes { get; set; } = 240;
+
+    /// <summary>Offer a "Continue to desktop" button on the setup screen. Default false.</summary>
+    [YamlMember(Alias = "BootstrapScreenAllowSkip")]
+    public bool BootstrapScreenAllowSkip { get; set; }
+
+    /// <summary>
+    /// Report bootstrap progress to Intune's Enrollment Status Page, so Autopilot
+    /// provisioning counts Cimian's core apps. Default false.
+    /// </summary>
+    [YamlMember(Alias = "EspIntegration")]
+    public bool EspIntegration { get; set; }
+
+    /// <summary>
+    /// Items reported to the Enrollment Status Page as core apps. Empty means
+    /// every item the bootstrap installs.
+    /// </summary>
+    [YamlMember(Alias = "EspTrackedItems")]
+    public List<string> EspTrackedItems { get; set; } = new();
+
+    /// <summary>
+    /// Groups (names or SIDs) whose members may use CimianWatcher's user pipe for a
+    /// check or a self-service run. Empty means BUILTIN\Administrators and BUILTIN\Users.
+    /// The control pipe ignores this; it only opens for elevated administrators.
+    /// </summary>
+    [YamlMember(Alias = "RunBrokerAllowedGroups")]
+    public List<string> RunBrokerAllowedGroups { get; set; } = new();
+
+    /// <summary>
+    /// Port for CimianWatcher's Prometheus/OpenMetrics endpoint at
+    /// http://localhost:&lt;port&gt;/metrics. 0 (default) leaves it off.
+    /// </summary>
+    [YamlMember(Alias = "MetricsPort")]
+    public int MetricsPort { get; set; }
+
+    /// <summary>
+    /// Hold countdown reboots and the status window while the user is in Focus Assist,
+    /// presenting or running something full-screen. A passed force_install_after_date
+    /// still restarts. Default true.
+    /// </summary>
+    [YamlMember(Alias = "RespectFocusAssist")]
+    public bool RespectFocusAssist { get; set; } = true;
+
+    /// <summary>
+    /// When set, --auto installs only inside one of these windows and just checks and
+    /// precaches outside them. Inside a window the active-user restriction is lifted.
+    /// --ignore-maintenance-window bypasses them.
+    /// </summary>
+    [YamlMember(Alias = "MaintenanceWindows")]
+    public List<MaintenanceWindow> MaintenanceWindows { get; set; } = new();
+
+    /// <summary>
+    /// auto (detect VDI clones and write filters), always, or never. Non-persistent
+    /// machines skip the cache between runs, keep logs briefly and never self-update.
+    /// </summary>
+    [YamlMember(Alias = "NonPersistentMode")]
+    public string NonPersistentMode { get; set; } = "auto";
+
+    /// <summary>
+    /// workstation, kiosk, server or lab. Fills in the role's bundle of defaults
+    /// (see <see cref="Cimian.Core.Models.MachineRole"/>) for every key this file
+    /// doesn't set itself.
+    /// </summary>
+    [YamlMember(Alias = "MachineRole")]
+    public string MachineRole { get; set; } = "workstation";
+
+    /// <summary>
+    /// Let Managed Software Center raise toasts for pending and finished updates.
+    /// </summary>
+    [YamlMember(Alias = "ShowNotifications")]
+    public bool ShowNotifications { get; set; } = true;
+
+    /// <summary>
+    /// Hours before a deferred item's force_install_after_date that CimianStatus
+    /// warns the user with a toast.
+    /// </summary>
+    [YamlMember(Alias = "ForcedInstallWarningHours")]
+    public int ForcedInstallWarningHours { get; set; } = Cimian.Core.Services.UserNotices.DefaultForcedInstallWarningHours;
+
+    /// <summary>
+    /// Seconds an install waits for the user to close its blocking_applications,
+    /// retried once they close. Items with force_close_blocking_apps have them
+    /// closed when the time runs out. 0 (default) defers blocked installs to the
+    /// next run.
+    /// </summary>
+    [YamlMember(Alias = "BlockingAppTimeout")]
+    public int BlockingAppTimeout { get; set; }
+
+    /// <summary>
+    /// Skip installs while the machine runs on battery below this charge
+    /// (percent); they go in on a later run once it is plugged in or charged.
+    /// 0 (default) installs on any charge.
+    /// </summary>
+    [YamlMember(Alias = "MinimumBatteryPercent")]
+    public int MinimumBatteryPercent { get; set; }
+
+    /// <summary>
+    /// Defer downloads while Windows reports the network as metered or traffic
+    /// goes over a cellular adapter. Installers already in the cache, downloads
+    /// within MeteredDownloadThresholdMB and critical items still go in.
+    /// </summary>
+    [YamlMember(Alias = "RespectMeteredConnections")]
+    public bool RespectMeteredConnections { get; set; }
+
+    /// <summary>
+    /// Under RespectMeteredConnections, downloads up to this many MB still run
+    /// on a metered connection; larger ones, and ones of unknown size, wait.
+    /// 0 (default) defers every download.
+    /// </summary>
+    [YamlMember(Alias = "MeteredDownloadThresholdMB")]
+    public int MeteredDownloadThresholdMB { get; set; }
+
+    /// <summary>
+    /// Open the CimianStatus window when CimianWatcher starts a bootstrap or GUI run.
+    /// </summary>
+    [YamlMember(Alias = "ShowStatusWindow")]
+    public bool ShowStatusWindow { get; set; } = true;
+
+    /// <summary>
+    /// Show the CimianStatus notification-area icon (cimistatus --tray) with
+    /// the pending-update badge.
+    /// </summary>
+    [YamlMember(Alias = "ShowTrayIcon")]
+    public bool ShowTrayIcon { get; set; } = true;
+
+    /// <summary>
+    /// Stream run.log lines over the status connection (--show-status) so the
+    /// CimianStatus live log pane doesn't have to tail files. Off by default.
+    /// </summary>
+    [YamlMember(Alias = "StatusLogStreaming")]
+    public bool StatusLogStreaming { get; set; }
+
+    /// <summary>
+    /// Least severe level streamed with StatusLogStreaming: TRACE, DEBUG, INFO, WARN or ERROR.
+    /// </summary>
+    [YamlMember(Alias = "StatusLogStreamLevel")]
+    public string StatusLogStreamLevel { get; set; } = "INFO";
+
+    /// <summary>
+    /// countdown (five-minute warning), immediate (one minute), prompt (CimianStatus
+    /// asks the user) or never, for auto and bootstrap runs that need a restart.
+    /// </summary>
+    [YamlMember(Alias = "RestartPolicy")]
+    public string RestartPolicy { get; set; } = "countdown";
+
+    /// <summary>
+    /// Minutes of warning before a scheduled restart; 0 uses the RestartPolicy default.
+    /// </summary>
+    [YamlMember(Alias = "RestartGracePeriodMinutes")]
+    public int RestartGracePeriodMinutes { get; set; }
+
+    /// <summary>
+    /// Origins (scheme://host[:port]) installers may be downloaded from. When set, a
+    /// catalog location pointing anywhere else is refused and logged as an
+    /// origin_not_allowed security event. The SoftwareRepoURL origin is always allowed.
+    /// </summary>
+    [YamlMember(Alias = "AllowedDownloadOrigins")]
+    public List<string> AllowedDownloadOrigins { get; set; } = new();
+
+    /// <summary>
+    /// Write reports/usage.json under a one-way hash of the ClientIdentifier instead
+    /// of the identifier itself, so usage can be aggregated without naming machines.
+    /// Also leaves hostname and serial number out of reports/facts.json.
+    /// </summary>
+    [YamlMember(Alias = "AnonymousUsageReports")]
+    public bool AnonymousUsageReports { get; set; }
+
+    /// <summary>
+    /// Where to publish whether every mandatory item is installed after each run:
+    /// none (default), registry (HKLM\SOFTWARE\Cimian\Compliance), file
+    /// (compliance.json) or both. Lets conditional access require a compliant
+    /// Cimian client through an Intune detection rule or custom compliance script.
+    /// </summary>
+    [YamlMember(Alias = "ComplianceExport")]
+    public string ComplianceExport { get; set; } = "none";
+
+    /// <summary>
+    /// Extra report formats written to the reports directory after each run:
+    /// munkireport (ManagedInstallReport.plist) and/or osquery (cimian_osquery.json).
+    /// </summary>
+    [YamlMember(Alias = "ReportFormats")]
+    public List<string> ReportFormats { get; set; } = new();
+
+    /// <summary>
+    /// Endpoint that receives each run's session summary, inventory, managed items
+    /// and error events as a JSON POST. Runs that can't reach it queue the report
+    /// under report_queue and send it with the next run. Unset disables uploads.
+    /// </summary>
+    [YamlMember(Alias = "ReportURL")]
+    public string? ReportURL { get; set; }
+
+    /// <summary>
+    /// Bearer token for ReportURL. Unset sends the repo's credentials and client
+    /// certificate, as the repo client does.
+    /// </summary>
+    [YamlMember(Alias = "ReportAuthToken")]
+    public string? ReportAuthToken { get; set; }
+
+    /// <summary>
+    /// Webhooks (Slack, Teams or generic JSON POST) fired when a run completes,
+    /// partially fails or fails, or schedules a Cimian self-update.
+    /// </summary>
+    [YamlMember(Alias = "Webhooks")]
+    public List<WebhookSettings> Webhooks { get; set; } = new();
+
+    /// <summary>
+    /// Fetch catalogs and manifests over HTTP/1.1 only, for proxies or servers that
+    /// mishandle HTTP/2. By default HTTP/2 is requested and HTTP/1.1 used if refused.
+    /// </summary>
+    [YamlMember(Alias = "DisableHttp2")]
+    public bool DisableHttp2 { get; set; }
+
+    /// <summary>
+    /// What SoftwareRepoURL points at: http (any web server, default), s3 (a
+    /// bucket, signed with AWS SDK credentials) or azblob (a Blob Storage
+    /// container, managed identity or AuthToken as a SAS token).
+    /// </summary>
+    [YamlMember(Alias = "RepoBackend")]
+    public string RepoBackend { get; set; } = "http";
+
+    /// <summary>
+    /// AWS region for RepoBackend s3 when SoftwareRepoURL isn't an
+    /// amazonaws.com host that names one (S3-compatible storage, the global endpoint).
+    /// </summary>
+    [YamlMember(Alias = "RepoRegion")]
+    public string? RepoRegion { get; set; }
+
+    /// <summary>
+    /// Client ID of the user-assigned managed identity for RepoBackend azblob;
+    /// unset uses the system-assigned identity.
+    /// </summary>
+    [YamlMember(Alias = "RepoManagedIdentityClientId")]
+    public string? RepoManagedIdentityClientId { get; set; }
+
+    /// <summary>
+    /// Explicit proxy for every request (repo, downloads, icons), e.g.
+    /// http://proxy.example.com:8080. Takes precedence over ProxyPACURL and
+    /// the system proxy.
+    /// </summary>
+    [YamlMember(Alias = "ProxyURL")]
+    public string? ProxyURL { get; set; }
+
+    /// <summary>
+    /// Hosts that skip ProxyURL: wildcards such as *.corp.example.com or 10.*,
+    /// and &lt;local&gt; for single-label names.
+    /// </summary>
+    [YamlMember(Alias = "ProxyBypassList")]
+    public List<string> ProxyBypassList { get; set; } = new();
+
+    /// <summary>
+    /// PAC file evaluated through WinHTTP to pick a proxy per host, when
+    /// ProxyURL is not set.
+    /// </summary>
+    [YamlMember(Alias = "ProxyPACURL")]
+    public string? ProxyPACURL { get; set; }
+
+    /// <summary>
+    /// With neither ProxyURL nor ProxyPACURL set, use the Windows proxy settings
+    /// (default true); false connects directly.
+    /// </summary>
+    [YamlMember(Alias = "UseSystemProxy")]
+    public bool UseSystemProxy { get; set; } = true;
+
+    /// <summary>Basic/NTLM credentials for an authenticated proxy.</summary>
+    [YamlMember(Alias = "ProxyUser")]
+    public string? ProxyUser { get; set; }
+
+    [YamlMember(Alias = "ProxyPassword")]
+    public string? ProxyPassword { get; set; }
+
+    /// <summary>
+    /// Authenticate to the proxy with the account running the agent (the machine
+    /// account as SYSTEM, via Kerberos/NTLM) when ProxyUser is not set.
+    /// </summary>
+    [YamlMember(Alias = "ProxyUseDefaultCredentials")]
+    public bool ProxyUseDefaultCredentials { get; set; }
+
+    /// <summary>The proxy keys as the shared <see cref="Cimian.Core.Services.ProxySettings"/>.</summary>
+    [YamlIgnore]
+    public Cimian.Core.Services.ProxySettings ProxySettings => new(
+        ProxyURL, ProxyBypassList, UseSystemProxy, ProxyPACURL, ProxyUser, ProxyPassword, ProxyUseDefaultCredentials);
+
+    /// <summary>
+    /// Fetch installer payloads from LAN peers (Delivery Optimization or peer
+    /// cache servers) before the repo. Off by default.
+    /// </summary>
+    [YamlMember(Alias = "PeerCache")]
+    public PeerCacheSettings PeerCache { get; set; } = new();
+
     // TODO: Localization / i18n — extract all hardcoded UI strings to resource files for multi-language support
     // TODO: License seat tracking — track available license seats per package (requires server-side component)
 
@@ -309,6 +936,63 @@ public class ManifestFile
 
     [YamlMember(Alias = "default_installs")]
     public List<string> DefaultInstalls { get; set; } = new();
+
+    // Repo scripts run once the manifests are loaded, and after installs
+    [YamlMember(Alias = "preflight_scripts")]
+    public List<RepoScript> PreflightScripts { get; set; } = new();
+
+    [YamlMember(Alias = "postflight_scripts")]
+    public List<RepoScript> PostflightScripts { get; set; } = new();
+}
+
+/// <summary>
+/// A PowerShell script from the repo's scripts/ directory, named by a manifest
+/// (preflight_scripts, postflight_scripts) or a pkginfo (preinstall_scripts,
+/// postinstall_scripts). The hash pins the exact script that may run.
+/// </summary>
+public class RepoScript
+{
+    /// <summary>Path under scripts/, e.g. "network/check-vpn.ps1".</summary>
+    [YamlMember(Alias = "name")]
+    public string Name { get; set; } = string.Empty;
+
+    /// <summary>SHA256 of the script; required while RequireHashValidation is on.</summary>
+    [YamlMember(Alias = "hash")]
+    public string? Hash { get; set; }
+
+    /// <summary>Seconds before the script is stopped; 0 uses ScriptTimeout.</summary>
+    [YamlMember(Alias = "timeout")]
+    public int Timeout { get; set; }
+
+    public override string ToString() => Name;
+}
+
+/// <summary>
+/// A ScriptPolicies entry. Unset values keep the defaults: ScriptTimeout (or
+/// InstallerTimeout for install/uninstall scripts), ScriptConstrainedLanguage,
+/// and fail for pre*/install/uninstall scripts, warn for the rest.
+/// </summary>
+public class ScriptPolicy
+{
+    /// <summary>Seconds before the script is stopped; 0 = no limit.</summary>
+    [YamlMember(Alias = "Timeout")]
+    public int? Timeout { get; set; }
+
+    /// <summary>fail, warn or ignore.</summary>
+    [YamlMember(Alias = "OnFailure")]
+    public string? OnFailure { get; set; }
+
+    [YamlMember(Alias = "ConstrainedLanguage")]
+    public bool? ConstrainedLanguage { get; set; }
+
+    public override string ToString()
+    {
+        var parts = new List<string>();
+        if (Timeout != null) parts.Add(Timeout > 0 ? $"{Timeout}s" : "no timeout");
+        if (OnFailure != null) parts.Add($"on failure {OnFailure}");
+        if (ConstrainedLanguage != null) parts.Add(ConstrainedLanguage.Value ? "constrained" : "full language");
+        return string.Join(", ", parts);
+    }
 }
 
 /// <summary>
@@ -330,6 +1014,13 @@ public class ConditionalItem
 
     [YamlMember(Alias = "optional_installs")]
     public List<string> OptionalInstalls { get; set; } = new();
+
+    /// <summary>
+    /// Evaluated only when this item's condition matched, so segments can be
+    /// narrowed step by step (hardware, then OS build) in one manifest.
+    /// </summary>
+    [YamlMember(Alias = "conditional_items")]
+    public List<ConditionalItem> ConditionalItems { get; set; } = new();
 }
 
 /// <summary>
@@ -352,7 +1043,7 @@ public class ManifestItem
 
     /// <summary>
     /// True when this item's action was set by the user-writable
-    /// SelfServeManifest (install request or promoted optional). SourceManifest
+    /// SelfServeManifest (install or removal request, or promoted optional). SourceManifest
     /// keeps the server manifest that listed the item, so this flag is the only
     /// way to tell user intent from admin intent after the merge.
     /// </summary>
@@ -369,6 +1060,174 @@ public class ManifestItem
     public List<string> Includes { get; set; } = new();
 }
 
+/// <summary>
+/// A configuration profile from the repo's profiles/ directory, named in a
+/// manifest's managed_profiles. Each payload is one setting the profile keeps
+/// in place.
+/// </summary>
+public class ManagedProfile
+{
+    [YamlMember(Alias = "name")]
+    public string Name { get; set; } = string.Empty;
+
+    [YamlMember(Alias = "version")]
+    public string Version { get; set; } = string.Empty;
+
+    [YamlMember(Alias = "description")]
+    public string? Description { get; set; }
+
+    [YamlMember(Alias = "payloads")]
+    public List<ProfilePayload> Payloads { get; set; } = new();
+}
+
+/// <summary>
+/// One setting in a <see cref="ManagedProfile"/>. Which fields apply depends on
+/// the type: registry (key, name, value_type), policy (scope, key, name,
+/// value_type), csp (uri), defender (setting) or firewall (profile).
+/// </summary>
+public class ProfilePayload
+{
+    [YamlMember(Alias = "type")]
+    public string Type { get; set; } = string.Empty;
+
+    /// <summary>Local Group Policy: machine (default) or user.</summary>
+    [YamlMember(Alias = "scope")]
+    public string? Scope { get; set; }
+
+    [YamlMember(Alias = "key")]
+    public string? Key { get; set; }
+
+    [YamlMember(Alias = "name")]
+    public string? Name { get; set; }
+
+    /// <summary>string (default), expand_string, dword, qword or multi_string.</summary>
+    [YamlMember(Alias = "value_type")]
+    public string? ValueType { get; set; }
+
+    [YamlMember(Alias = "value")]
+    public string? Value { get; set; }
+
+    /// <summary>The strings of a multi_string value.</summary>
+    [YamlMember(Alias = "values")]
+    public List<string>? Values { get; set; }
+
+    /// <summary>Policy CSP node, ./Device/Vendor/MSFT/Policy/Config/&lt;Area&gt;/&lt;Policy&gt;.</summary>
+    [YamlMember(Alias = "uri")]
+    public string? Uri { get; set; }
+
+    /// <summary>Set-MpPreference parameter, e.g. DisableRealtimeMonitoring.</summary>
+    [YamlMember(Alias = "setting")]
+    public string? Setting { get; set; }
+
+    /// <summary>Firewall profile: domain, private or public.</summary>
+    [YamlMember(Alias = "profile")]
+    public string? Profile { get; set; }
+
+    public string EffectiveType() => Type.Trim().ToLowerInvariant();
+
+    public string EffectiveValueType() =>
+        string.IsNullOrWhiteSpace(ValueType) ? "string" : ValueType.Trim().ToLowerInvariant();
+
+    /// <summary>
+    /// What this payload sets, so two profiles claiming the same setting, or a
+    /// setting dropped from a profile's new version, can be told apart.
+    /// </summary>
+    public string Id() => EffectiveType() switch
+    {
+        "registry" => $"registry:{Key}\\{Name}",
+        "policy" => $"policy:{Scope ?? "machine"}:{Key}\\{Name}",
+        "csp" => $"csp:{Uri}",
+        "defender" => $"defender:{Setting}",
+        "firewall" => $"firewall:{Profile}",
+        _ => $"{Type}:{Key}{Uri}{Setting}{Profile}"
+    };
+
+    /// <summary>The value this payload wants, in the form <see cref="NormalizeValue"/> gives.</summary>
+    public string? DesiredValue() =>
+        NormalizeValue(EffectiveValueType() == "multi_string" && Values != null ? string.Join("\n", Values) : Value);
+
+    /// <summary>
+    /// A value as compared for compliance: booleans as true/false, numbers in
+    /// decimal (0x hex accepted), everything else as written.
+    /// </summary>
+    public string? NormalizeValue(string? value)
+    {
+        if (value == null)
+        {
+            return null;
+        }
+        var trimmed = value.Trim();
+        if (bool.TryParse(trimmed, out var flag))
+        {
+            return flag ? "true" : "false";
+        }
+        if (EffectiveValueType() is "dword" or "qword" || EffectiveType() is "defender" or "csp")
+        {
+            if (trimmed.StartsWith("0x", StringComparison.OrdinalIgnoreCase)
+                && ulong.TryParse(trimmed[2..], System.Globalization.NumberStyles.HexNumber, null, out var hex))
+            {
+                return hex.ToString(System.Globalization.CultureInfo.InvariantCulture);
+            }
+            if (long.TryParse(trimmed, out var number))
+            {
+                return number.ToString(System.Globalization.CultureInfo.InvariantCulture);
+            }
+        }
+        return value;
+    }
+
+    public string Describe() => EffectiveType() switch
+    {
+        "registry" or "policy" => $"{Type} {Key}\\{Name}",
+        "csp" => $"csp {Uri}",
+        "defender" => $"defender {Setting}",
+        "firewall" => $"firewall {Profile}",
+        _ => Type
+    };
+}
+
+/// <summary>
+/// What a configuration item (installer type: configuration) keeps in place:
+/// either a test_script/set_script pair, or a DSC resource that
+/// Invoke-DscResource tests and sets.
+/// </summary>
+public class ConfigurationPayload
+{
+    /// <summary>Exits 0 when the machine is compliant, anything else when it isn't.</summary>
+    [YamlMember(Alias = "test_script")]
+    public string? TestScript { get; set; }
+
+    /// <summary>Brings the machine back into compliance; run only after a failed test.</summary>
+    [YamlMember(Alias = "set_script")]
+    public string? SetScript { get; set; }
+
+    [YamlMember(Alias = "dsc_resource")]
+    public DscResourceInfo? DscResource { get; set; }
+
+    [YamlIgnore]
+    public string Method => DscResource != null ? "dsc" : "script";
+}
+
+/// <summary>
+/// A DSC resource invocation: Invoke-DscResource -Name -ModuleName -Property,
+/// with Test deciding compliance and Set remediating.
+/// </summary>
+public class DscResourceInfo
+{
+    [YamlMember(Alias = "name")]
+    public string Name { get; set; } = string.Empty;
+
+    [YamlMember(Alias = "module")]
+    public string Module { get; set; } = string.Empty;
+
+    [YamlMember(Alias = "module_version")]
+    public string? ModuleVersion { get; set; }
+
+    /// <summary>Resource properties: scalars, or lists of scalars.</summary>
+    [YamlMember(Alias = "properties")]
+    public Dictionary<string, object> Properties { get; set; } = new();
+}
+
 /// <summary>
 /// Represents a catalog item with installer information
 /// </summary>
@@ -386,15 +1245,27 @@ public class CatalogItem
     [YamlMember(Alias = "description")]
     public string? Description { get; set; }
 
+    /// <summary>"What's new" text for this version, shown to the user once it installs.</summary>
+    [YamlMember(Alias = "release_notes")]
+    public string? ReleaseNotes { get; set; }
+
     [YamlMember(Alias = "category")]
     public string? Category { get; set; }
 
     [YamlMember(Alias = "developer")]
     public string? Developer { get; set; }
 
+    /// <summary>Icon file in the repo's icons/ folder; defaults to &lt;name&gt;.png.</summary>
+    [YamlMember(Alias = "icon_name")]
+    public string? IconName { get; set; }
+
     [YamlMember(Alias = "installer")]
     public InstallerInfo Installer { get; set; } = new();
 
+    /// <summary>The catalog this item was taken from; set by CatalogService when it loads.</summary>
+    [YamlIgnore]
+    public string? SourceCatalog { get; set; }
+
     [YamlMember(Alias = "uninstaller")]
     public List<UninstallerInfo> Uninstaller { get; set; } = new();
 
@@ -407,9 +1278,21 @@ public class CatalogItem
     [YamlMember(Alias = "update_for")]
     public List<string> UpdateFor { get; set; } = new();
 
+    /// <summary>
+    /// Former names of this item. Manifests and requires entries using an alias
+    /// resolve to this item, so a rename doesn't break clients mid-migration.
+    /// </summary>
+    [YamlMember(Alias = "aliases")]
+    public List<string> Aliases { get; set; } = new();
+
     [YamlMember(Alias = "blocking_applications")]
     public List<string> BlockingApps { get; set; } = new();
 
+    // With BlockingAppTimeout, end the blocking applications still open when the
+    // wait runs out instead of deferring the install.
+    [YamlMember(Alias = "force_close_blocking_apps")]
+    public bool ForceCloseBlockingApps { get; set; }
+
     [YamlMember(Alias = "minimum_os_version")]
     public string? MinimumOsVersion { get; set; }
 
@@ -437,6 +1320,12 @@ public class CatalogItem
     [YamlMember(Alias = "postinstall_script")]
     public string? PostinstallScript { get; set; }
 
+    [YamlMember(Alias = "preinstall_scripts")]
+    public List<RepoScript> PreinstallScripts { get; set; } = new(); // repo scripts/ run before preinstall_script
+
+    [YamlMember(Alias = "postinstall_scripts")]
+    public List<RepoScript> PostinstallScripts { get; set; } = new(); // repo scripts/ run after postinstall_script
+
     [YamlMember(Alias = "preuninstall_script")]
     public string? PreuninstallScript { get; set; }
 
@@ -452,6 +1341,21 @@ public class CatalogItem
     [YamlMember(Alias = "uninstallable")]
     public bool Uninstallable { get; set; } = true;
 
+    // Admin approval for users to remove this item themselves when the client's
+    // AllowSelfServiceUninstall policy is "approved".
+    [YamlMember(Alias = "self_service_uninstall")]
+    public bool SelfServiceUninstall { get; set; }
+
+    // Cimian packages only: the self-update channel this build belongs to (e.g.
+    // beta). Unset means stable.
+    [YamlMember(Alias = "self_update_channel")]
+    public string? SelfUpdateChannel { get; set; }
+
+    // Cimian packages only: the share of machines (0-100) offered this build so
+    // far, by each machine's rollout bucket. Unset means every machine.
+    [YamlMember(Alias = "self_update_rollout_percent")]
+    public int? SelfUpdateRolloutPercent { get; set; }
+
     [YamlMember(Alias = "install_window")]
     public InstallWindow? InstallWindow { get; set; }
 
@@ -469,6 +1373,19 @@ public class CatalogItem
     [YamlMember(Alias = "restart_action")]
     public string? RestartAction { get; set; }
 
+    /// <summary>
+    /// Shorthand for restart_action: RequireRestart. An explicit restart_action wins.
+    /// </summary>
+    [YamlMember(Alias = "requires_restart")]
+    public bool RequiresRestart { get; set; }
+
+    /// <summary>
+    /// restart_action, or RequireRestart when only requires_restart is set.
+    /// </summary>
+    [YamlIgnore]
+    public string? EffectiveRestartAction =>
+        !string.IsNullOrWhiteSpace(RestartAction) ? RestartAction : RequiresRestart ? "RequireRestart" : null;
+
     [YamlMember(Alias = "version_script")]
     public string? VersionScript { get; set; }
 
@@ -491,9 +1408,76 @@ public class CatalogItem
     [YamlMember(Alias = "recurring")]
     public bool Recurring { get; set; }
 
+    // MSIX/APPX only. true (the default when unset) provisions the package for every
+    // user on the machine, existing and future, via Add-AppxProvisionedPackage.
+    // false installs it for the account running Cimian only (Add-AppxPackage), for
+    // the rare user-context deployment; from SYSTEM that would land in the service
+    // profile, so the engine provisions anyway and warns.
+    [YamlMember(Alias = "provision")]
+    public bool? Provision { get; set; }
+
+    // EXE/MSI only. Authenticode signer the payload must carry: a certificate
+    // thumbprint, or text that appears in the signing certificate's subject.
+    // Enforced when RequireSignedInstallers is on; a warning otherwise.
+    [YamlMember(Alias = "expected_signer")]
+    public string? ExpectedSigner { get; set; }
+
+    // How a version change is applied: in-place (default), uninstall-first for
+    // vendors whose new MSI refuses to install over the old one, or side-by-side
+    // when both versions are meant to coexist. See Cimian.Core.Models.UpgradeStrategy.
+    [YamlMember(Alias = "upgrade_strategy")]
+    public string? UpgradeStrategy { get; set; }
+
+    // system (default) or user. user runs the installer as whoever is logged on
+    // at the console, for per-user MSIX and AppData installers; the item waits
+    // for a later run while nobody is. See UserContextRunner.
+    [YamlMember(Alias = "install_context")]
+    public string? InstallContext { get; set; }
+
+    [YamlIgnore]
+    public bool InstallsAsUser =>
+        Cimian.Core.Models.InstallContext.Normalize(InstallContext) == Cimian.Core.Models.InstallContext.User;
+
+    // How many times the user can put this item off (blocking app open, user
+    // active) before the next run installs it anyway. Pairs with
+    // force_install_after_date; whichever runs out first wins.
+    [YamlMember(Alias = "max_deferrals")]
+    public int? MaxDeferrals { get; set; }
+
+    // Minutes this item's installer, uninstaller or install/uninstall script
+    // may run before it's stopped with its child processes, for installers
+    // known to take longer (or hang sooner) than DefaultInstallerTimeoutMinutes.
+    [YamlMember(Alias = "installer_timeout")]
+    public int? InstallerTimeout { get; set; }
+
+    // Disk space preconditions, in MB on the system drive: room the install
+    // takes up (plus the download while it isn't cached), and a floor of free
+    // space before installing at all. The item is skipped this run when the
+    // drive has less than either.
+    // Security fixes and the like: downloaded even on a metered connection
+    // under RespectMeteredConnections.
+    [YamlMember(Alias = "critical")]
+    public bool Critical { get; set; }
+
+    [YamlMember(Alias = "installer_size_required")]
+    public long? InstallerSizeRequired { get; set; }
+
+    [YamlMember(Alias = "minimum_free_disk_mb")]
+    public long? MinimumFreeDiskMb { get; set; }
+
     [YamlMember(Alias = "installs")]
     public List<InstallCheckItem> Installs { get; set; } = new();
 
+    // installer type configuration only: the test/set pair or DSC resource
+    // evaluated every run. Compliance is tracked apart from install state; see
+    // ConfigurationItemService.
+    [YamlMember(Alias = "configuration")]
+    public ConfigurationPayload? Configuration { get; set; }
+
+    [YamlIgnore]
+    public bool IsConfigurationItem =>
+        string.Equals(Installer?.Type, "configuration", StringComparison.OrdinalIgnoreCase);
+
     public bool IsUninstallable() => Uninstallable && (
         Uninstaller.Count > 0
         || Check.Registry.Name != null
@@ -617,6 +1601,167 @@ public class InstallWindow
     public override string ToString() => $"{Start}-{End}";
 }
 
+/// <summary>
+/// A fleet-wide maintenance window from Config.yaml. Days/Start/End follow
+/// install_window (abbreviated weekdays, HH:mm, overnight wrap); TimeZone is a
+/// Windows or IANA zone id and defaults to the machine's local time.
+/// </summary>
+public class MaintenanceWindow
+{
+    [YamlMember(Alias = "Days")]
+    public List<string>? Days { get; set; }
+
+    [YamlMember(Alias = "Start")]
+    public string Start { get; set; } = string.Empty;
+
+    [YamlMember(Alias = "End")]
+    public string End { get; set; } = string.Empty;
+
+    [YamlMember(Alias = "TimeZone")]
+    public string? TimeZone { get; set; }
+
+    public bool IsWithinWindow(DateTime utcNow)
+    {
+        var window = new InstallWindow { Start = Start, End = End, Weekdays = Days };
+        return window.IsWithinWindow(TimeZoneInfo.ConvertTimeFromUtc(utcNow, ResolveTimeZone()));
+    }
+
+    /// <summary>
+    /// Unknown zone ids fall back to local time rather than disabling the window.
+    /// </summary>
+    public TimeZoneInfo ResolveTimeZone()
+    {
+        if (string.IsNullOrWhiteSpace(TimeZone))
+        {
+            return TimeZoneInfo.Local;
+        }
+        try
+        {
+            return TimeZoneInfo.FindSystemTimeZoneById(TimeZone.Trim());
+        }
+        catch (Exception ex) when (ex is TimeZoneNotFoundException or InvalidTimeZoneException)
+        {
+            return TimeZoneInfo.Local;
+        }
+    }
+
+    public override string ToString()
+    {
+        var days = Days is { Count: > 0 } ? string.Join(",", Days) + " " : "";
+        var zone = string.IsNullOrWhiteSpace(TimeZone) ? "" : $" {TimeZone}";
+        return $"{days}{Start}-{End}{zone}";
+    }
+}
+
+/// <summary>
+/// A DownloadRateSchedule entry: a window like MaintenanceWindows with the
+/// download rate cap (KB/s, 0 = unlimited) that applies inside it.
+/// </summary>
+public class DownloadRateWindow : MaintenanceWindow
+{
+    [YamlMember(Alias = "MaxDownloadRateKBps")]
+    public int MaxDownloadRateKBps { get; set; }
+
+    public override string ToString() =>
+        $"{base.ToString()} {(MaxDownloadRateKBps > 0 ? $"{MaxDownloadRateKBps} KB/s" : "unlimited")}";
+}
+
+/// <summary>
+/// The Config.yaml PeerCache section. Mode is off, deliveryoptimization or
+/// peers (see <see cref="Cimian.Core.Models.PeerCacheMode"/>); payloads smaller
+/// than MinimumSizeMB always come from the repo.
+/// </summary>
+public class PeerCacheSettings
+{
+    [YamlMember(Alias = "Mode")]
+    public string Mode { get; set; } = "off";
+
+    /// <summary>Peer cache servers for Mode peers, e.g. http://cache01.branch.example.com:8080.</summary>
+    [YamlMember(Alias = "Peers")]
+    public List<string> Peers { get; set; } = new();
+
+    [YamlMember(Alias = "MinimumSizeMB")]
+    public int MinimumSizeMB { get; set; } = 10;
+
+    /// <summary>Give up on peers after this long without progress and use the repo.</summary>
+    [YamlMember(Alias = "TimeoutSeconds")]
+    public int TimeoutSeconds { get; set; } = 60;
+
+    public override string ToString()
+    {
+        var mode = Cimian.Core.Models.PeerCacheMode.Normalize(Mode) ?? Mode;
+        return mode == Cimian.Core.Models.PeerCacheMode.Peers
+            ? $"{mode} [{string.Join(", ", Peers)}], >= {MinimumSizeMB} MB"
+            : mode == Cimian.Core.Models.PeerCacheMode.Off ? mode : $"{mode}, >= {MinimumSizeMB} MB";
+    }
+}
+
+/// <summary>
+/// One Config.yaml Webhooks entry. Format is generic, slack or teams; Events
+/// picks from completed, partial_failure, failed, self_update_scheduled and
+/// self_update_refused (default: all but completed). Template overrides the
+/// message text and may use {hostname}, {run_type}, {status}, {session_id},
+/// {failed_items}, {failed_count}, {installs}, {updates}, {removals},
+/// {self_update} and {self_update_refused}.
+/// </summary>
+public class WebhookSettings
+{
+    [YamlMember(Alias = "URL")]
+    public string URL { get; set; } = string.Empty;
+
+    [YamlMember(Alias = "Format")]
+    public string Format { get; set; } = "generic";
+
+    [YamlMember(Alias = "Events")]
+    public List<string> Events { get; set; } = new();
+
+    [YamlMember(Alias = "Template")]
+    public string? Template { get; set; }
+
+    /// <summary>The events this webhook is sent for.</summary>
+    [YamlIgnore]
+    public IReadOnlyList<string> EffectiveEvents => Events.Count == 0
+        ? Cimian.Core.Models.WebhookEvent.Default
+        : Events.Select(Cimian.Core.Models.WebhookEvent.Normalize).OfType<string>().ToList();
+
+    /// <summary>Format and host only: webhook URLs carry their secret in the path.</summary>
+    public override string ToString()
+    {
+        var host = Uri.TryCreate(URL, UriKind.Absolute, out var uri) ? uri.Host : "(invalid URL)";
+        return $"{Cimian.Core.Models.WebhookFormat.Normalize(Format) ?? Format} {host} [{string.Join(", ", EffectiveEvents)}]";
+    }
+}
+
+/// <summary>
+/// A Chocolatey feed from Config.yaml's ChocolateySources. URL is a feed URL,
+/// UNC share or local folder; User/Password authenticate to it. Lower Priority
+/// values are tried first (0 leaves choco's default order).
+/// </summary>
+public class ChocolateySource
+{
+    [YamlMember(Alias = "Name")]
+    public string Name { get; set; } = string.Empty;
+
+    [YamlMember(Alias = "URL")]
+    public string URL { get; set; } = string.Empty;
+
+    [YamlMember(Alias = "User")]
+    public string? User { get; set; }
+
+    [YamlMember(Alias = "Password")]
+    public string? Password { get; set; }
+
+    [YamlMember(Alias = "Priority")]
+    public int Priority { get; set; }
+
+    /// <summary>The name the source is registered with in choco.</summary>
+    [YamlIgnore]
+    public string RegisteredName => "cimian-" + Name;
+
+    /// <summary>Name and URL only; never the password.</summary>
+    public override string ToString() => $"{Name} {URL}";
+}
+
 /// <summary>
 /// Install check item - used to verify installation by checking files, MSI product codes, or directories
 /// </summary>
@@ -685,6 +1830,14 @@ public class InstallCheckItem
             return "file";
         return string.Empty;
     }
+
+    /// <summary>A copy of this check pointed at <paramref name="path"/>.</summary>
+    public InstallCheckItem WithPath(string? path)
+    {
+        var copy = (InstallCheckItem)MemberwiseClone();
+        copy.Path = path;
+        return copy;
+    }
 }
 
 /// <summary>
@@ -745,6 +1898,97 @@ public class InstallerInfo
     [YamlMember(Alias = "temp_dir")]
     public string? TempDir { get; set; }
 
+    /// <summary>
+    /// For chocolatey installers: package id on the feed, when it differs from
+    /// the item name.
+    /// </summary>
+    [YamlMember(Alias = "package_id")]
+    public string? PackageId { get; set; }
+
+    /// <summary>
+    /// For chocolatey installers: ChocolateySources names to install from.
+    /// Empty uses every configured source.
+    /// </summary>
+    [YamlMember(Alias = "sources")]
+    public List<string> Sources { get; set; } = new();
+
+    /// <summary>
+    /// For chocolatey installers: pin the installed version so a machine-wide
+    /// choco upgrade leaves it alone. Cimian lifts the pin for its own upgrades.
+    /// </summary>
+    [YamlMember(Alias = "pin")]
+    public bool Pin { get; set; }
+
+    /// <summary>
+    /// For zip/iso installers: relative path of the real setup inside the archive
+    /// (e.g. "setup\Setup.exe"). The archive is extracted (zip) or mounted (iso),
+    /// this file is run with the installer args, and the staging area is cleaned up.
+    /// </summary>
+    [YamlMember(Alias = "inner_path")]
+    public string? InnerPath { get; set; }
+
+    /// <summary>
+    /// For zip/iso installers: type of the inner installer (msi, exe, msix, powershell).
+    /// Inferred from the inner_path extension when omitted.
+    /// </summary>
+    [YamlMember(Alias = "inner_type")]
+    public string? InnerType { get; set; }
+
+    /// <summary>
+    /// For delta installers (type: delta, location = MSDelta patch): repo location of
+    /// the previous full installer the patch applies to. Must already be in the cache.
+    /// </summary>
+    [YamlMember(Alias = "base_location")]
+    public string? BaseLocation { get; set; }
+
+    /// <summary>
+    /// For delta installers: SHA-256 of the base installer. A cached base that doesn't
+    /// match skips straight to the full download.
+    /// </summary>
+    [YamlMember(Alias = "base_hash")]
+    public string? BaseHash { get; set; }
+
+    /// <summary>
+    /// For delta installers: repo location of the full new installer, downloaded
+    /// when the patch can't be applied.
+    /// </summary>
+    [YamlMember(Alias = "full_location")]
+    public string? FullLocation { get; set; }
+
+    /// <summary>
+    /// For delta installers: SHA-256 of the full new installer; the patched result
+    /// must match it before it is used.
+    /// </summary>
+    [YamlMember(Alias = "full_hash")]
+    public string? FullHash { get; set; }
+
+    /// <summary>
+    /// For delta installers: type of the reconstructed installer (msi, exe, msix, ...).
+    /// Inferred from the full_location extension when omitted.
+    /// </summary>
+    [YamlMember(Alias = "target_type")]
+    public string? TargetType { get; set; }
+
+    /// <summary>
+    /// Extra exit codes that mean the installer succeeded. 0 always does.
+    /// A code listed here succeeds without a restart, even 3010 or 1641.
+    /// </summary>
+    [YamlMember(Alias = "success_exit_codes")]
+    public List<int> SuccessExitCodes { get; set; } = new();
+
+    /// <summary>
+    /// Exit codes that mean success with a restart needed. Defaults to 3010 and
+    /// 1641 when empty; a list replaces the defaults rather than adding to them.
+    /// </summary>
+    [YamlMember(Alias = "reboot_exit_codes")]
+    public List<int> RebootExitCodes { get; set; } = new();
+
+    /// <summary>
+    /// True for type: delta installers.
+    /// </summary>
+    [YamlIgnore]
+    public bool IsDelta => string.Equals(Type, "delta", StringComparison.OrdinalIgnoreCase);
+
     /// <summary>
     /// Gets all command-line arguments combined (subcommand + switches + flags + args)
     /// Normalizes switches and flags to ensure proper prefixes:
@@ -841,6 +2085,15 @@ public class UninstallerInfo
     [YamlMember(Alias = "identity_name")]
     public string? IdentityName { get; set; }
 
+    /// <summary>
+    /// type: registry uninstallers - a regular expression (case-insensitive)
+    /// matched against the DisplayName of Uninstall-hive entries, for apps whose
+    /// ARP name carries a version or architecture. product_code, when set, is
+    /// tried first.
+    /// </summary>
+    [YamlMember(Alias = "display_name_regex")]
+    public string? DisplayNameRegex { get; set; }
+
     /// <summary>
     /// Command-line switches (Windows-style with / prefix)
     /// </summary>
@@ -865,6 +2118,14 @@ public class UninstallerInfo
     [YamlMember(Alias = "args")]
     public List<string> Args { get; set; } = new();
 
+    /// <summary>Same meaning as <see cref="InstallerInfo.SuccessExitCodes"/>.</summary>
+    [YamlMember(Alias = "success_exit_codes")]
+    public List<int> SuccessExitCodes { get; set; } = new();
+
+    /// <summary>Same meaning as <see cref="InstallerInfo.RebootExitCodes"/>.</summary>
+    [YamlMember(Alias = "reboot_exit_codes")]
+    public List<int> RebootExitCodes { get; set; } = new();
+
     /// <summary>
     /// Gets all command-line arguments combined (subcommand + switches + flags + args)
     /// Normalizes switches and flags to ensure proper prefixes:
@@ -982,6 +2243,10 @@ public class FileCheck
 /// </summary>
 public class CatalogWrapper
 {
+    /// <summary>Monotonic catalog generation stamped by makecatalogs (replay protection).</summary>
+    [YamlMember(Alias = "generation")]
+    public long? Generation { get; set; }
+
     [YamlMember(Alias = "items")]
     public List<CatalogItem> Items { get; set; } = new();
 }
@@ -1041,3 +2306,12 @@ public class StatusCheckResult
     /// <summary>Error if status check failed</summary>
     public Exception? Error { get; set; }
 }
+
+/// <summary>
+/// How StatusService decides whether an item is installed, described without
+/// running the check (see StatusService.DescribeCheckStrategy).
+/// </summary>
+/// <param name="Method">The DetectionMethod the check reports, e.g. installs_array.</param>
+/// <param name="Description">What the check looks at, in one sentence.</param>
+/// <param name="Details">The individual checks, such as each installs entry.</param>
+public sealed record InstallCheckStrategy(string Method, string Description, IReadOnlyList<string> Details);
@@ -92,6 +92,9 @@ public class Program
         if (options.SetBootstrapMode)
         {
             StatusService.EnableBootstrapMode();
+            var progress = BootstrapProgressStore.Start(DateTime.Now);
+            // ESP sees the core apps as pending from the start of provisioning
+            new EnrollmentStatusReporter(new ConfigurationService().LoadConfig()).Report(progress);
             Console.WriteLine("[SUCCESS] Bootstrap mode enabled. System will enter bootstrap mode on next boot.");
             return 0;
         }
@@ -99,6 +102,7 @@ public class Program
         if (options.ClearBootstrapMode)
         {
             StatusService.DisableBootstrapMode();
+            BootstrapProgressStore.Clear();
             Console.WriteLine("[SUCCESS] Bootstrap mode disabled.");
             return 0;
         }
@@ -113,6 +117,16 @@ public class Program
             return ValidateCache();
         }
 
+        if (options.CacheEvict)
+        {
+            return EvictCache();
+        }
+
+        if (options.PruneLogs)
+        {
+            return PruneLogs();
+        }
+
         if (options.CleanCache)
         {
             return CleanCache();
@@ -129,6 +143,32 @@ public class Program
             return ShowLoopStatus();
         }
 
+        if (options.ClearCatalogOverride)
+        {
+            return ClearCatalogOverride();
+        }
+
+        if (!string.IsNullOrWhiteSpace(options.Pause))
+        {
+            return SetMaintenanceHold(options.Pause, options.Reason);
+        }
+
+        if (options.Resume)
+        {
+            return ClearMaintenanceHold();
+        }
+
+        // Read-only catalog queries; safe alongside a running session
+        if (options.ListItems)
+        {
+            return await ListItemsAsync(options);
+        }
+
+        if (!string.IsNullOrWhiteSpace(options.ItemInfo))
+        {
+            return await ShowItemInfoAsync(options);
+        }
+
         if (options.SelfUpdateStatus)
         {
             return ShowSelfUpdateStatus();
@@ -159,6 +199,16 @@ public class Program
             return SelfCheck();
         }
 
+        if (options.VerifyAgent)
+        {
+            return VerifyAgent(repair: !options.CheckOnly);
+        }
+
+        if (options.Setup)
+        {
+            return await RunSetupAsync(options);
+        }
+
         // Handle preflight-only: run preflight and exit
         if (options.PreflightOnly)
         {
@@ -171,6 +221,25 @@ public class Program
             return await RunPostflightOnlyAsync(options);
         }
 
+        // An auto run while a bootstrap is unfinished (a restart cut it short)
+        // carries the bootstrap on
+        var bootstrap = options.Bootstrap || StatusService.IsBootstrapMode()
+            || (options.Auto && BootstrapProgressStore.IsActive());
+
+        // Maintenance hold: unattended auto runs skip until it ends
+        if (!options.DryRun && MaintenanceHoldStore.AppliesTo(options.Auto, bootstrap, options.ShowStatus))
+        {
+            if (MaintenanceHoldStore.Expire(DateTime.Now) is { } ended)
+            {
+                Console.WriteLine($"[INFO] Maintenance hold set by {ended.SetBy} ended at {ended.Until:yyyy-MM-dd HH:mm}");
+            }
+            else if (MaintenanceHoldStore.Read(DateTime.Now) is { } hold)
+            {
+                Console.WriteLine($"[INFO] Automatic run skipped. {hold.Describe()}");
+                return 0;
+            }
+        }
+
         // Check for single instance
         if (!TryAcquireSingleInstance())
         {
@@ -217,23 +286,58 @@ public class Program
                 config.LogLevel = "DEBUG";
             }
 
+            if (options.Trace)
+            {
+                config.TraceDiagnostics = true;
+            }
+
+            if (options.OverrideCatalogs?.Any() == true)
+            {
+                var overrideResult = SetCatalogOverride(options.OverrideCatalogs, options.OverrideRuns ?? CatalogOverrideService.DefaultRuns);
+                if (overrideResult != 0)
+                {
+                    return overrideResult;
+                }
+            }
+
             // Create and run update engine
             var engine = new UpdateEngine(config);
 
-            var result = await engine.RunAsync(
-                checkOnly: options.CheckOnly,
-                installOnly: options.InstallOnly,
-                auto: options.Auto,
-                bootstrap: options.Bootstrap,
-                verbosity: effectiveVerbosity,
-                manifestTarget: options.ManifestTarget,
-                localManifest: options.LocalOnlyManifest,
-                skipPreflight: options.NoPreflight,
-                skipPostflight: options.NoPostflight,
-                showStatus: options.ShowStatus,
-                statusPort: options.StatusPort,
-                itemFilter: options.Items);
+            var reportUploader = new ReportUploader(config);
+            var webhookNotifier = new WebhookNotifier(config);
+            if (!string.IsNullOrWhiteSpace(options.Rollback))
+            {
+                var rollbackResult = await engine.RollbackAsync(options.Rollback.Trim(), effectiveVerbosity);
+                await reportUploader.UploadAsync(engine.SessionDir);
+                await webhookNotifier.NotifyAsync(engine.SessionDir);
+                return rollbackResult;
+            }
 
+            if (!string.IsNullOrWhiteSpace(options.InstallItem) || !string.IsNullOrWhiteSpace(options.UninstallItem))
+            {
+                if (!string.IsNullOrWhiteSpace(options.InstallItem) && !string.IsNullOrWhiteSpace(options.UninstallItem))
+                {
+                    Console.Error.WriteLine("[ERROR] Use either --install or --uninstall, not both.");
+                    return 1;
+                }
+                var uninstall = !string.IsNullOrWhiteSpace(options.UninstallItem);
+                var onDemandResult = await engine.RunOnDemandAsync(
+                    (uninstall ? options.UninstallItem! : options.InstallItem!).Trim(), uninstall, effectiveVerbosity);
+                await reportUploader.UploadAsync(engine.SessionDir);
+                await webhookNotifier.NotifyAsync(engine.SessionDir);
+                return onDemandResult;
+            }
+
+            if (bootstrap && !options.CheckOnly && !options.DryRun && options.Items?.Any() != true)
+            {
+                return await RunBootstrapAsync(options, config, engine, effectiveVerbosity);
+            }
+
+            var result = await RunEngineAsync(engine, options, options.Bootstrap, effectiveVerbosity);
+
+            // Central reporting (ReportURL); queued and retried by later runs when offline
+            await reportUploader.UploadAsync(engine.SessionDir);
+            await webhookNotifier.NotifyAsync(engine.SessionDir);
             return result;
         }
         finally
@@ -242,6 +346,26 @@ public class Program
         }
     }
 
+    private static Task<int> RunEngineAsync(UpdateEngine engine, Options options, bool bootstrap, int effectiveVerbosity) =>
+        engine.RunAsync(
+            checkOnly: options.CheckOnly && !options.DryRun,
+            installOnly: options.InstallOnly && !options.DryRun,
+            auto: options.Auto,
+            bootstrap: bootstrap,
+            verbosity: effectiveVerbosity,
+            manifestTarget: options.ManifestTarget,
+            localManifest: options.LocalOnlyManifest,
+            skipPreflight: options.NoPreflight,
+            skipPostflight: options.NoPostflight,
+            showStatus: options.ShowStatus,
+            statusPort: options.StatusPort,
+            itemFilter: options.Items,
+            dryRun: options.DryRun,
+            planOutputPath: options.PlanOutput,
+            precache: options.Precache && !options.DryRun,
+            ignoreMaintenanceWindow: options.IgnoreMaintenanceWindow,
+            progress: options.Progress);
+
     private static int ShowConfig()
     {
         var configService = new ConfigurationService();
@@ -251,29 +375,227 @@ public class Program
         Console.WriteLine();
         Console.WriteLine("Current configuration:");
         Console.WriteLine($"  SoftwareRepoURL: {config.SoftwareRepoURL}");
+        if (config.SoftwareRepoURLs.Count > 0)
+        {
+            Console.WriteLine($"  SoftwareRepoURLs: [{string.Join(", ", config.SoftwareRepoURLs)}]");
+        }
+        Console.WriteLine($"  RepoBackend: {config.RepoBackend}{(string.IsNullOrEmpty(config.RepoRegion) ? "" : $" ({config.RepoRegion})")}");
         Console.WriteLine($"  ClientIdentifier: {config.ClientIdentifier}");
         Console.WriteLine($"  CachePath: {config.CachePath}");
         Console.WriteLine($"  CatalogsPath: {config.CatalogsPath}");
         Console.WriteLine($"  ManifestsPath: {config.ManifestsPath}");
         Console.WriteLine($"  Catalogs: [{string.Join(", ", config.Catalogs)}]");
+        if (new CatalogOverrideService().Get() is { } catalogOverride)
+        {
+            Console.WriteLine($"  CatalogOverride: {catalogOverride.Describe()}");
+        }
+        if (MaintenanceHoldStore.Read(DateTime.Now) is { } hold)
+        {
+            Console.WriteLine($"  MaintenanceHold: {hold.Describe()}");
+        }
         Console.WriteLine($"  LogLevel: {config.LogLevel}");
         Console.WriteLine($"  Verbose: {config.Verbose}");
         Console.WriteLine($"  Debug: {config.Debug}");
         Console.WriteLine($"  CheckOnly: {config.CheckOnly}");
-        Console.WriteLine($"  InstallerTimeout: {config.InstallerTimeout}s");
+        Console.WriteLine($"  InstallerTimeout: {config.EffectiveInstallerTimeout.TotalMinutes:0.#} minutes{(config.DefaultInstallerTimeoutMinutes > 0 ? " (DefaultInstallerTimeoutMinutes)" : "")}");
+        Console.WriteLine($"  ScriptTimeout: {(config.ScriptTimeout > 0 ? $"{config.ScriptTimeout}s" : "none")}");
+        Console.WriteLine($"  ScriptConstrainedLanguage: {config.ScriptConstrainedLanguage}");
+        Console.WriteLine($"  ScriptPolicies: {(config.ScriptPolicies.Count > 0 ? $"[{string.Join("; ", config.ScriptPolicies.Select(p => $"{p.Key}: {p.Value}"))}]" : "(none)")}");
+        Console.WriteLine($"  MaxConcurrentDownloads: {config.MaxConcurrentDownloads}");
+        Console.WriteLine($"  MaxDownloadRateKBps: {(config.EffectiveMaxDownloadRateKBps > 0 ? config.EffectiveMaxDownloadRateKBps.ToString() : "unlimited")}");
+        Console.WriteLine($"  DownloadRateSchedule: {(config.DownloadRateSchedule.Count > 0 ? $"[{string.Join("; ", config.DownloadRateSchedule)}]" : "(none)")}");
         Console.WriteLine($"  NoPreflight: {config.NoPreflight}");
         Console.WriteLine($"  NoPostflight: {config.NoPostflight}");
         Console.WriteLine($"  PreflightFailureAction: {config.PreflightFailureAction}");
         Console.WriteLine($"  PostflightFailureAction: {config.PostflightFailureAction}");
         Console.WriteLine($"  LocalOnlyManifest: {config.LocalOnlyManifest ?? "(not set)"}");
         Console.WriteLine($"  SkipSelfService: {config.SkipSelfService}");
+        Console.WriteLine($"  AllowSelfServiceUninstall: {config.AllowSelfServiceUninstall}");
+        Console.WriteLine($"  SelfUpdateChannel: {config.SelfUpdateChannel} (rollout bucket {StatusService.RolloutBucket})");
+        Console.WriteLine($"  SelfUpdateRequireSignature: {config.SelfUpdateRequireSignature}");
+        Console.WriteLine($"  SelfUpdateExpectedSigner: {config.SelfUpdateExpectedSigner ?? "(pkginfo expected_signer)"}");
+        Console.WriteLine($"  ForbidEmulatedInstalls: {config.ForbidEmulatedInstalls}");
+        Console.WriteLine($"  RemoveUnmanagedItems: {config.RemovesUnmanagedItems}{(config.RemovesUnmanagedItems ? $" (after {config.UnmanagedItemGraceDays} day(s))" : "")}");
+        Console.WriteLine($"  DependentRemovalPolicy: {config.DependentRemovalPolicy}");
+        Console.WriteLine($"  RemoveOrphanedDependencies: {config.RemoveOrphanedDependencies}");
         Console.WriteLine($"  LoopGuardEnabled: {config.LoopGuardEnabled}");
+        Console.WriteLine($"  QuarantineFailureThreshold: {(config.QuarantineFailureThreshold > 0 ? config.QuarantineFailureThreshold.ToString() : "off")}");
+        Console.WriteLine($"  TraceDiagnostics: {config.TraceDiagnostics}");
+        Console.WriteLine($"  EventLogEnabled: {config.EventLogEnabled}");
+        Console.WriteLine($"  StatusLogStreaming: {config.StatusLogStreaming}{(config.StatusLogStreaming ? $" ({config.StatusLogStreamLevel} and above)" : "")}");
+        Console.WriteLine($"  LogRetention: {(config.LogRetentionDays > 0 ? $"{config.LogRetentionDays} days" : "no age limit")}, newest {config.LogRetentionSessions} sessions, {(config.MaxLogSizeMB > 0 ? $"{config.MaxLogSizeMB} MB" : "no size limit")}");
+        Console.WriteLine($"  AllowCatalogDowngrade: {config.AllowCatalogDowngrade}");
+        Console.WriteLine($"  OfflineCacheMaxAgeHours: {(config.OfflineCacheMaxAgeHours > 0 ? config.OfflineCacheMaxAgeHours.ToString() : "off")}");
+        Console.WriteLine($"  RepoChangeWatch: {config.RepoChangeWatch} (every {config.RepoChangeWatchInterval}s)");
+        Console.WriteLine($"  AutoRunIntervalMinutes: {(config.AutoRunIntervalMinutes > 0 ? $"{config.AutoRunIntervalMinutes} (+ up to {config.AutoRunSplayMinutes} splay, at startup: {config.AutoRunAtStartup}, wait for network: {config.AutoRunOnNetworkAvailable})" : "0 (scheduled task)")}");
+        Console.WriteLine($"  BootstrapMaxAttempts: {config.BootstrapMaxAttempts} (retry after {config.BootstrapRetryDelayMinutes} min, doubling){(BootstrapProgressStore.Read() is { } bootstrapProgress ? $" - last bootstrap {bootstrapProgress.Status}, {bootstrapProgress.Attempts} attempt(s)" : "")}");
+        Console.WriteLine($"  BootstrapScreen: {config.BootstrapScreen}{(config.BootstrapScreen ? $" ({(config.BootstrapScreenTimeoutMinutes > 0 ? $"{config.BootstrapScreenTimeoutMinutes} min timeout" : "no timeout")}, skip: {config.BootstrapScreenAllowSkip})" : "")}");
+        Console.WriteLine($"  EspIntegration: {config.EspIntegration}{(config.EspIntegration ? $" (tracking {(config.EspTrackedItems.Count > 0 ? $"[{string.Join(", ", config.EspTrackedItems)}]" : "bootstrap items")})" : "")}");
+        Console.WriteLine($"  HungRunMinutes: {(config.HungRunMinutes > 0 ? $"{config.HungRunMinutes} (restart: {config.RestartHungRuns})" : "0 (watchdog off)")}");
+        Console.WriteLine($"  RunBrokerAllowedGroups: {(config.RunBrokerAllowedGroups.Count > 0 ? $"[{string.Join(", ", config.RunBrokerAllowedGroups)}]" : "(default)")}");
+        Console.WriteLine($"  MetricsPort: {(config.MetricsPort > 0 ? config.MetricsPort.ToString() : "(off)")}");
+        Console.WriteLine($"  RespectFocusAssist: {config.RespectFocusAssist}");
+        Console.WriteLine($"  NonPersistentMode: {config.NonPersistentMode}");
+        Console.WriteLine($"  MachineRole: {config.MachineRole}");
+        Console.WriteLine($"  RestartPolicy: {config.RestartPolicy}{(config.RestartGracePeriodMinutes > 0 ? $" ({config.RestartGracePeriodMinutes} min grace)" : "")}");
+        Console.WriteLine($"  ShowNotifications: {config.ShowNotifications} (forced-install warning {config.ForcedInstallWarningHours}h)");
+        Console.WriteLine($"  BlockingAppTimeout: {(config.BlockingAppTimeout > 0 ? $"{config.BlockingAppTimeout}s" : "(defer)")}");
+        Console.WriteLine($"  MinimumBatteryPercent: {(config.MinimumBatteryPercent > 0 ? $"{config.MinimumBatteryPercent}%" : "off")}");
+        Console.WriteLine($"  RespectMeteredConnections: {config.RespectMeteredConnections}{(config.RespectMeteredConnections && config.MeteredDownloadThresholdMB > 0 ? $" (downloads up to {config.MeteredDownloadThresholdMB} MB allowed)" : "")}");
+        Console.WriteLine($"  ShowStatusWindow: {config.ShowStatusWindow}");
+        Console.WriteLine($"  ShowTrayIcon: {config.ShowTrayIcon}");
+        Console.WriteLine($"  MaintenanceWindows: {(config.MaintenanceWindows.Count > 0 ? $"[{string.Join("; ", config.MaintenanceWindows)}]" : "(none)")}");
+        Console.WriteLine($"  PeerCache: {config.PeerCache}");
+        Console.WriteLine($"  Webhooks: {(config.Webhooks.Count > 0 ? string.Join("; ", config.Webhooks) : "(none)")}");
+        Console.WriteLine($"  ChocolateySources: {(config.ChocolateySources.Count > 0 ? string.Join("; ", config.ChocolateySources) : "(machine sources)")}");
+        Console.WriteLine($"  AllowedDownloadOrigins: {(config.AllowedDownloadOrigins.Count > 0 ? $"[{string.Join(", ", config.AllowedDownloadOrigins)}]" : "(any)")}");
+        Console.WriteLine($"  AnonymousUsageReports: {config.AnonymousUsageReports}");
+        Console.WriteLine($"  ComplianceExport: {config.ComplianceExport}");
+        Console.WriteLine($"  ReportFormats: {(config.ReportFormats.Count > 0 ? $"[{string.Join(", ", config.ReportFormats)}]" : "(none)")}");
+        Console.WriteLine($"  ReportURL: {(string.IsNullOrEmpty(config.ReportURL) ? "(not set)" : config.ReportURL)}");
+        Console.WriteLine($"  DisableHttp2: {config.DisableHttp2}");
+        Console.WriteLine($"  Proxy: {config.ProxySettings.Describe()}");
         Console.WriteLine($"  AuthUser: {(string.IsNullOrEmpty(config.AuthUser) ? "(not set)" : "***")}");
         Console.WriteLine($"  AuthToken: {(string.IsNullOrEmpty(config.AuthToken) ? "(not set)" : "***")}");
+        Console.WriteLine($"  ReportAuthToken: {(string.IsNullOrEmpty(config.ReportAuthToken) ? "(not set)" : "***")}");
 
         return 0;
     }
 
+    /// <summary>
+    /// First-run setup (--setup). Prompts for (or takes via --setup-* flags) the
+    /// repo URL, auth, client identifier strategy, catalogs and schedule, tests the
+    /// repo before saving anything, then does a check-only validation run. The auth
+    /// password/token is never taken as an argument: it comes from the prompt,
+    /// CIMIAN_SETUP_AUTH_PASSWORD / CIMIAN_SETUP_AUTH_TOKEN, or stdin.
+    ///
+    /// Exit codes:
+    ///   0 = configured and validated
+    ///   1 = invalid answers, repo test failed, or validation run failed
+    /// </summary>
+    private static async Task<int> RunSetupAsync(Options options)
+    {
+        if (options.SetupAuthPassword != null || options.SetupAuthToken != null)
+        {
+            ConsoleLogger.Error($"{(options.SetupAuthPassword != null ? "--setup-auth-password" : "--setup-auth-token")} is not accepted on the command line " +
+                $"(arguments show up in process listings and logs); set {SetupWizard.PasswordEnvVar} / {SetupWizard.TokenEnvVar} or pipe it with --setup-auth-secret-stdin");
+            return 1;
+        }
+
+        var configPath = options.ConfigPath ?? CimianConfig.ConfigPath;
+        var configService = new ConfigurationService();
+        var wizard = new SetupWizard(configService, interactive: !options.NonInteractive && !Console.IsInputRedirected);
+
+        Console.WriteLine("Cimian setup");
+        Console.WriteLine($"Configuration file: {configPath}");
+        Console.WriteLine();
+
+        var answers = new SetupAnswers
+        {
+            RepoUrl = options.SetupRepoUrl,
+            AuthMethod = options.SetupAuth,
+            AuthUser = options.SetupAuthUser,
+            ClientCertificate = options.SetupClientCertificate,
+            ClientIdStrategy = options.SetupClientId,
+            ClientIdentifier = options.SetupClientIdValue,
+            Catalogs = options.SetupCatalogs?.ToList() is { Count: > 0 } catalogs ? catalogs : null,
+            ScheduleHours = options.SetupScheduleHours
+        };
+
+        var secretError = SetupWizard.ApplyAuthSecret(answers, Environment.GetEnvironmentVariable,
+            options.SetupAuthSecretStdin ? Console.In.ReadLine : null);
+        if (secretError != null)
+        {
+            ConsoleLogger.Error(secretError);
+            return 1;
+        }
+
+        CimianConfig? config;
+        List<string> errors;
+        try
+        {
+            config = wizard.BuildConfig(configService.LoadConfig(configPath), answers, out errors);
+        }
+        catch (ArgumentException ex)
+        {
+            ConsoleLogger.Error(ex.Message);
+            return 1;
+        }
+
+        if (config == null)
+        {
+            foreach (var error in errors)
+            {
+                ConsoleLogger.Error(error);
+            }
+            return 1;
+        }
+
+        Console.WriteLine();
+        var (reachable, message) = await wizard.TestRepositoryAsync(config);
+        if (reachable)
+        {
+            ConsoleLogger.Success(message);
+        }
+        else
+        {
+            ConsoleLogger.Error(message);
+            if (!wizard.Confirm("Save the configuration anyway?", defaultYes: false))
+            {
+                return 1;
+            }
+        }
+
+        if (File.Exists(configPath))
+        {
+            File.Copy(configPath, configPath + ".bak", overwrite: true);
+            ConsoleLogger.Info($"Previous configuration backed up to {configPath}.bak");
+        }
+        configService.SaveConfig(config, configPath);
+        ConsoleLogger.Success($"Configuration saved to {configPath}");
+
+        var scheduleHours = wizard.AskScheduleHours(answers.ScheduleHours);
+        if (wizard.ApplySchedule(scheduleHours, out var scheduleMessage))
+        {
+            ConsoleLogger.Info(scheduleMessage);
+        }
+        else
+        {
+            ConsoleLogger.Warn(scheduleMessage);
+        }
+
+        if (options.SkipValidation)
+        {
+            return 0;
+        }
+
+        Console.WriteLine();
+        ConsoleLogger.Info("Running a check-only pass to validate the configuration...");
+        if (!TryAcquireSingleInstance())
+        {
+            ConsoleLogger.Warn("Another instance of managedsoftwareupdate is running; skipping the validation run");
+            return 0;
+        }
+
+        try
+        {
+            var result = await new UpdateEngine(config).RunAsync(checkOnly: true, statusPort: options.StatusPort);
+            if (result == 0)
+            {
+                ConsoleLogger.Success("Setup complete");
+            }
+            else
+            {
+                ConsoleLogger.Error($"Validation run failed (exit {result}); see {CimianPaths.LogsDir}");
+            }
+            return result == 0 ? 0 : 1;
+        }
+        finally
+        {
+            ReleaseSingleInstance();
+        }
+    }
+
     private static async Task<int> RunPreflightOnlyAsync(Options options)
     {
         var configService = new ConfigurationService();
@@ -375,6 +697,25 @@ public class Program
         Console.WriteLine("Cache Configuration:");
         Console.WriteLine($"  Use Cache: {config.UseCache}");
         Console.WriteLine($"  Retention: {config.CacheRetentionDays} days");
+        Console.WriteLine($"  Size Limit: {(config.CacheSizeLimitMB > 0 ? $"{config.CacheSizeLimitMB} MB" : "unlimited")}");
+        Console.WriteLine($"  Purge On Uninstall: {config.PurgeCacheOnUninstall}");
+        Console.WriteLine($"  Require Hash Validation: {config.RequireHashValidation}");
+        Console.WriteLine($"  Require Signed Installers: {config.RequireSignedInstallers}");
+        Console.WriteLine($"  Require Signed Metadata: {config.RequireSignedMetadata}");
+        Console.WriteLine($"  Metadata Signing Keys: {config.MetadataSigningKeys.Count}");
+
+        var plan = new CacheManager(config).Plan();
+        Console.WriteLine();
+        Console.WriteLine($"Eviction Candidates: {plan.Candidates.Count} ({plan.EvictableBytes / (1024.0 * 1024.0):F1} MB)");
+        foreach (var payload in plan.Candidates)
+        {
+            Console.WriteLine($"  {Path.GetRelativePath(config.CachePath, payload.Path)} ({payload.Size / (1024.0 * 1024.0):F1} MB, last used {FormatTimeAgo(DateTime.UtcNow - payload.LastUsedUtc)})");
+        }
+        Console.WriteLine($"Retained For Rollback: {plan.Retained.Count} payloads");
+        if (plan.Candidates.Count > 0)
+        {
+            ConsoleLogger.Info("Run with --cache-evict to remove them");
+        }
 
         return 0;
     }
@@ -430,6 +771,32 @@ public class Program
         return 0;
     }
 
+    private static int EvictCache()
+    {
+        Console.WriteLine("Evicting cached payloads...");
+
+        var configService = new ConfigurationService();
+        var config = configService.LoadConfig();
+
+        var (fileCount, bytesFreed) = new CacheManager(config).Evict();
+
+        Console.WriteLine($"Cache eviction completed: removed {fileCount} files, freed {bytesFreed / (1024.0 * 1024.0):F1} MB");
+        return 0;
+    }
+
+    private static int PruneLogs()
+    {
+        Console.WriteLine("Pruning session logs...");
+
+        var configService = new ConfigurationService();
+        var config = configService.LoadConfig();
+
+        var result = LogRetention.Prune(CimianPaths.LogsDir, config.LogRetentionPolicy, DateTime.Now);
+
+        Console.WriteLine($"Log pruning completed: compressed {result.Compressed} sessions, deleted {result.Deleted}, freed {result.BytesFreed / (1024.0 * 1024.0):F1} MB");
+        return 0;
+    }
+
     private static int ShowSelfUpdateStatus()
     {
         Console.WriteLine("Cimian Self-Update Status");
@@ -450,6 +817,8 @@ public class Program
             Console.WriteLine($"   Item: {metadata.Item}");
             Console.WriteLine($"   Version: {metadata.Version}");
             Console.WriteLine($"   Installer: {metadata.InstallerType}");
+            if (!string.IsNullOrEmpty(metadata.Channel))
+                Console.WriteLine($"   Channel: {metadata.Channel}");
             Console.WriteLine($"   Scheduled: {metadata.ScheduledAt}");
             Console.WriteLine();
             ConsoleLogger.Info("To trigger the update:");
@@ -461,6 +830,27 @@ public class Program
             Console.WriteLine("Cimian is up to date");
         }
 
+        if (SelfUpdateHealthStore.Read() is { } health)
+        {
+            Console.WriteLine();
+            Console.WriteLine($"Last self-update: {health.Item} v{health.Version} ({health.Channel}), installed {health.Installed:yyyy-MM-dd HH:mm}");
+            switch (health.Status)
+            {
+                case SelfUpdateHealth.Healthy:
+                    Console.WriteLine("   Health check: passed");
+                    break;
+                case SelfUpdateHealth.RolledBack:
+                    Console.WriteLine($"   Health check: failed, rolled back to {health.PreviousVersion ?? "the previous version"}");
+                    Console.WriteLine($"   Reason: {health.Detail}");
+                    Console.WriteLine($"   v{health.Version} won't be offered again; a newer build will be");
+                    break;
+                default:
+                    Console.WriteLine("   Health check: waiting for CimianWatcher to start on the new version");
+                    break;
+            }
+        }
+        Console.WriteLine($"Rollout bucket: {StatusService.RolloutBucket} (builds at a self_update_rollout_percent above this reach this machine)");
+
         return 0;
     }
 
@@ -720,6 +1110,69 @@ public class Program
         }
     }
 
+    /// <summary>
+    /// Deeper sibling of --self-check: verifies binaries, service, scheduled
+    /// tasks, ACLs and registry keys, repairs what it can (report-only with
+    /// --checkonly) and writes reports\agent_verification.json.
+    ///
+    /// Exit codes:
+    ///   0 = healthy, possibly after repairs
+    ///   2 = drift that could not be repaired
+    ///   3 = unexpected error during verification
+    /// </summary>
+    private static int VerifyAgent(bool repair)
+    {
+        try
+        {
+            Console.WriteLine(repair ? "Verifying Cimian agent (repairing drift)" : "Verifying Cimian agent (report only)");
+            Console.WriteLine("═══════════════════════════════════════");
+
+            var report = new AgentVerifier(repair).Verify();
+            foreach (var group in report.Checks.GroupBy(c => c.Area))
+            {
+                Console.WriteLine();
+                Console.WriteLine($"{group.Key}:");
+                foreach (var check in group)
+                {
+                    var mark = check.Status switch
+                    {
+                        AgentCheckStatus.Ok => "OK      ",
+                        AgentCheckStatus.Repaired => "REPAIRED",
+                        _ => "FAILED  "
+                    };
+                    Console.WriteLine($"  [{mark}] {check.Name}: {check.Detail}");
+                }
+            }
+
+            var reportPath = Path.Combine(CimianPaths.ReportsDir, "agent_verification.json");
+            try
+            {
+                AgentVerifier.WriteReport(report, reportPath);
+            }
+            catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+            {
+                Console.Error.WriteLine($"[verify-agent] failed to write {reportPath}: {ex.Message}");
+                return 3;
+            }
+
+            var repaired = report.Checks.Count(c => c.Status == AgentCheckStatus.Repaired);
+            var failed = report.Checks.Count(c => c.Status == AgentCheckStatus.Failed);
+            Console.WriteLine();
+            if (failed == 0)
+            {
+                ConsoleLogger.Success(repaired == 0 ? "Agent is healthy" : $"Agent is healthy; repaired {repaired} item(s)");
+                return 0;
+            }
+            ConsoleLogger.Error($"{failed} problem(s) remain{(repaired > 0 ? $" after repairing {repaired} item(s)" : "")}; see {reportPath}");
+            return 2;
+        }
+        catch (Exception ex)
+        {
+            Console.Error.WriteLine($"[verify-agent] unexpected error: {ex.Message}");
+            return 3;
+        }
+    }
+
     /// <summary>
     /// Handles conflict when --checkonly is used but another instance is running.
     /// Matches Go: handleCheckOnlyConflict()
@@ -1011,6 +1464,260 @@ public class Program
 
     #endregion
 
+    #region Catalog Override CLI
+
+    private static string CurrentUser => $"{Environment.UserDomainName}\\{Environment.UserName}";
+
+    private static int SetCatalogOverride(IEnumerable<string> catalogs, int runs)
+    {
+        try
+        {
+            var entry = new CatalogOverrideService().Set(catalogs, runs, CurrentUser, DateTime.Now);
+            Console.WriteLine($"[SUCCESS] Catalog override set: {entry.Describe()}");
+            return 0;
+        }
+        catch (ArgumentException ex)
+        {
+            Console.Error.WriteLine($"[ERROR] --override-catalogs: {ex.Message}");
+            return 1;
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            Console.Error.WriteLine($"[ERROR] Could not save catalog override: {ex.Message}");
+            return 1;
+        }
+    }
+
+    private static int ClearCatalogOverride()
+    {
+        if (new CatalogOverrideService().Clear(CurrentUser, DateTime.Now))
+        {
+            Console.WriteLine("[SUCCESS] Catalog override cleared; the next run uses Config.yaml catalogs.");
+            return 0;
+        }
+
+        Console.WriteLine("[INFO] No catalog override is active.");
+        return 0;
+    }
+
+    #endregion
+
+    #region Maintenance Hold CLI
+
+    private static int SetMaintenanceHold(string duration, string? reason)
+    {
+        var now = DateTime.Now;
+        if (MaintenanceHoldStore.ParseUntil(duration, now) is not { } until)
+        {
+            Console.Error.WriteLine($"[ERROR] --pause: '{duration}' is not a duration (90m, 4h, 2d) or a date and time.");
+            return 1;
+        }
+
+        try
+        {
+            var hold = MaintenanceHoldStore.Set(until, reason, CurrentUser, now);
+            Console.WriteLine($"[SUCCESS] {hold.Describe()}");
+            Console.WriteLine("          Runs you start yourself still run; end the hold early with --resume.");
+            return 0;
+        }
+        catch (ArgumentException ex)
+        {
+            Console.Error.WriteLine($"[ERROR] --pause: {ex.Message}");
+            return 1;
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            Console.Error.WriteLine($"[ERROR] Could not save maintenance hold: {ex.Message}");
+            return 1;
+        }
+    }
+
+    private static int ClearMaintenanceHold()
+    {
+        if (MaintenanceHoldStore.Clear(DateTime.Now) is { } hold)
+        {
+            Console.WriteLine($"[SUCCESS] Maintenance hold set by {hold.SetBy} ended; automatic runs resume.");
+            return 0;
+        }
+
+        Console.WriteLine("[INFO] No maintenance hold is active.");
+        return 0;
+    }
+
+    #endregion
+
+    #region Bootstrap
+
+    /// <summary>
+    /// Runs a bootstrap to the end: after each run, another (after
+    /// BootstrapRetryDelayMinutes, doubling each time) until a run leaves
+    /// nothing outstanding or BootstrapMaxAttempts runs have been made. Progress
+    /// is kept in bootstrap_progress.json, so a restart partway through carries
+    /// on with the attempts left: a scheduled restart ends this loop and
+    /// CimianWatcher resumes the bootstrap when it starts again. With
+    /// EspIntegration, each attempt is reported to the Enrollment Status Page.
+    /// </summary>
+    private static async Task<int> RunBootstrapAsync(Options options, CimianConfig config, UpdateEngine engine, int effectiveVerbosity)
+    {
+        var progress = BootstrapProgressStore.Resume(DateTime.Now);
+        var enrollmentStatus = new EnrollmentStatusReporter(config);
+        while (true)
+        {
+            BootstrapProgressStore.BeginAttempt(progress, DateTime.Now);
+            enrollmentStatus.Report(progress);
+            Console.WriteLine($"[INFO] Bootstrap attempt {progress.Attempts} of {config.BootstrapMaxAttempts}");
+
+            var result = await RunEngineAsync(engine, options, bootstrap: true, effectiveVerbosity);
+            await new ReportUploader(config).UploadAsync(engine.SessionDir);
+            await new WebhookNotifier(config).NotifyAsync(engine.SessionDir);
+
+            BootstrapProgressStore.RecordAttempt(progress, result,
+                engine.Outcomes.Where(o => !o.Success).Select(o => o.Name),
+                engine.Outcomes.Where(o => o.Success).Select(o => o.Name),
+                config.BootstrapMaxAttempts, DateTime.Now);
+            enrollmentStatus.Report(progress);
+
+            if (progress.Status == BootstrapProgress.Completed)
+            {
+                StatusService.DisableBootstrapMode();
+                Console.WriteLine($"[SUCCESS] Bootstrap complete after {progress.Attempts} attempt(s)");
+                return result;
+            }
+            if (progress.Status == BootstrapProgress.Exhausted)
+            {
+                StatusService.DisableBootstrapMode();
+                Console.Error.WriteLine($"[ERROR] Bootstrap gave up after {progress.Attempts} attempt(s); still outstanding: " +
+                    (progress.Remaining.Count > 0 ? string.Join(", ", progress.Remaining) : "(run failed before installing)"));
+                return result;
+            }
+            if (engine.RestartScheduled)
+            {
+                Console.WriteLine("[INFO] Restart scheduled; the bootstrap resumes after it");
+                return result;
+            }
+
+            var delay = BootstrapProgressStore.RetryDelay(progress.Attempts, TimeSpan.FromMinutes(config.BootstrapRetryDelayMinutes));
+            progress.NextAttempt = DateTime.Now + delay;
+            BootstrapProgressStore.Save(progress);
+            Console.WriteLine($"[WARN] Bootstrap incomplete ({progress.Remaining.Count} item(s) outstanding); " +
+                $"retrying in {delay.TotalMinutes:0.#} minute(s)");
+            await Task.Delay(delay);
+
+            // Each attempt re-evaluates the manifests from scratch
+            engine = new UpdateEngine(config);
+        }
+    }
+
+    #endregion
+
+    #region Item Query CLI
+
+    private static ItemQueryService CreateItemQuery(Options options)
+    {
+        var config = new ConfigurationService().LoadConfig(options.ConfigPath ?? CimianConfig.ConfigPath);
+        if (options.Json)
+        {
+            // Keep stdout to the JSON document; warnings and errors go to stderr
+            ConsoleLogger.ProgressDisplay = line => Console.Error.WriteLine(line);
+        }
+        return new ItemQueryService(config);
+    }
+
+    private static async Task<int> ListItemsAsync(Options options)
+    {
+        var query = CreateItemQuery(options);
+        var rows = ItemQueryService.ListItems(await query.LoadVisibleItemsAsync());
+
+        if (options.Json)
+        {
+            Console.WriteLine(ItemQueryService.ToJson(rows));
+            return 0;
+        }
+
+        if (rows.Count == 0)
+        {
+            Console.WriteLine("No catalog items are visible to this machine.");
+            return 0;
+        }
+
+        var nameWidth = Math.Max(4, rows.Max(r => r.Name.Length));
+        var versionWidth = Math.Max(7, rows.Max(r => r.Version.Length));
+        var catalogWidth = Math.Max(7, rows.Max(r => r.Catalog?.Length ?? 0));
+        Console.WriteLine($"{"Name".PadRight(nameWidth)}  {"Version".PadRight(versionWidth)}  {"Catalog".PadRight(catalogWidth)}  Type");
+        foreach (var row in rows)
+        {
+            Console.WriteLine($"{row.Name.PadRight(nameWidth)}  {row.Version.PadRight(versionWidth)}  {(row.Catalog ?? "").PadRight(catalogWidth)}  {row.Type}");
+        }
+        Console.WriteLine();
+        Console.WriteLine($"{rows.Count} item(s)");
+        return 0;
+    }
+
+    private static async Task<int> ShowItemInfoAsync(Options options)
+    {
+        var query = CreateItemQuery(options);
+        var name = options.ItemInfo!.Trim();
+        var info = query.Describe(await query.LoadVisibleItemsAsync(), name);
+        if (info == null)
+        {
+            Console.Error.WriteLine($"[ERROR] No catalog visible to this machine has an item named '{name}'.");
+            return 1;
+        }
+
+        if (options.Json)
+        {
+            Console.WriteLine(ItemQueryService.ToJson(info));
+            return 0;
+        }
+
+        Console.WriteLine($"Name:          {info.Name}{(info.RequestedName != null ? $" (alias {info.RequestedName})" : "")}");
+        Console.WriteLine($"Version:       {info.Version}");
+        Console.WriteLine($"Catalog:       {info.Catalog}");
+        Console.WriteLine($"Install check: {info.InstallCheck.Description} [{info.InstallCheck.Method}]");
+        foreach (var detail in info.InstallCheck.Details)
+        {
+            Console.WriteLine($"               - {detail}");
+        }
+        if (info.UpdateFor.Count > 0)
+        {
+            Console.WriteLine($"Update for:    {string.Join(", ", info.UpdateFor)}");
+        }
+        if (info.Updates.Count > 0)
+        {
+            Console.WriteLine($"Updates:       {string.Join(", ", info.Updates)}");
+        }
+
+        Console.WriteLine();
+        Console.WriteLine("Requires:");
+        if (info.Requires.Count == 0)
+        {
+            Console.WriteLine("  (none)");
+        }
+        PrintDependencies(info.Requires, "  ");
+
+        Console.WriteLine();
+        Console.WriteLine("Pkginfo:");
+        Console.Write(YamlUtils.Serializer.Serialize(info.Pkginfo));
+        return 0;
+    }
+
+    private static void PrintDependencies(List<ItemDependency> nodes, string prefix)
+    {
+        for (var i = 0; i < nodes.Count; i++)
+        {
+            var node = nodes[i];
+            var last = i == nodes.Count - 1;
+            var state = node.Missing ? " [not in any visible catalog]"
+                : node.Cycle ? " [cycle]"
+                : $" {node.Version} ({node.Catalog})";
+            var pinned = node.RequiredVersion != null ? $" requires {node.RequiredVersion}," : "";
+            Console.WriteLine($"{prefix}{(last ? "└── " : "├── ")}{node.Name}{pinned}{state}");
+            PrintDependencies(node.Requires, prefix + (last ? "    " : "│   "));
+        }
+    }
+
+    #endregion
+
     [DllImport("kernel32.dll", SetLastError = true)]
     private static extern IntPtr GetStdHandle(int nStdHandle);
 
@@ -1062,6 +1769,57 @@ public class Options
     [Option('i', "installonly", Required = false, HelpText = "Install pending updates without checking for new ones")]
     public bool InstallOnly { get; set; }
 
+    [Option("dry-run", Required = false, HelpText = "Walk the full install/uninstall pipeline without executing anything and emit a JSON plan (exit 2 if the plan has warnings)")]
+    public bool DryRun { get; set; }
+
+    [Option("plan-output", Required = false, HelpText = "Path for the --dry-run JSON plan (default: reports\\dry_run_plan.json)")]
+    public string? PlanOutput { get; set; }
+
+    [Option("precache", Required = false, HelpText = "Download all pending updates into the cache without installing them")]
+    public bool Precache { get; set; }
+
+    [Option("rollback", Required = false, HelpText = "Reinstall the version of an item that its last update replaced")]
+    public string? Rollback { get; set; }
+
+    [Option("install", Required = false, HelpText = "Install one item (and what it requires) from the catalogs, bypassing the manifest")]
+    public string? InstallItem { get; set; }
+
+    [Option("uninstall", Required = false, HelpText = "Remove one item (and installed items that require it), bypassing the manifest")]
+    public string? UninstallItem { get; set; }
+
+    [Option("ignore-maintenance-window", Required = false, HelpText = "Install during --auto even outside the configured MaintenanceWindows")]
+    public bool IgnoreMaintenanceWindow { get; set; }
+
+    // Maintenance hold flags
+    [Option("pause", Required = false, HelpText = "Suspend automatic runs for a duration (90m, 4h, 2d) or until a date and time, at most 30 days, and exit")]
+    public string? Pause { get; set; }
+
+    [Option("reason", Required = false, HelpText = "With --pause, why automatic runs are held (shown in reports and CimianStatus)")]
+    public string? Reason { get; set; }
+
+    [Option("resume", Required = false, HelpText = "End a --pause maintenance hold early and exit")]
+    public bool Resume { get; set; }
+
+    // Catalog override flags (helpdesk troubleshooting)
+    [Option("override-catalogs", Required = false, Separator = ',', HelpText = "Use these comma-separated catalogs instead of Config.yaml's for this and the next runs (e.g. Testing,Production)")]
+    public IEnumerable<string>? OverrideCatalogs { get; set; }
+
+    [Option("override-runs", Required = false, HelpText = "With --override-catalogs, number of runs before the override expires (default 3)")]
+    public int? OverrideRuns { get; set; }
+
+    [Option("clear-catalog-override", Required = false, HelpText = "Remove an active --override-catalogs and exit")]
+    public bool ClearCatalogOverride { get; set; }
+
+    // Catalog query flags (read-only)
+    [Option("list-items", Required = false, HelpText = "List every catalog item visible to this machine (name, version, catalog, type) and exit")]
+    public bool ListItems { get; set; }
+
+    [Option("item-info", Required = false, HelpText = "Show an item's resolved pkginfo, dependency tree and install-check strategy and exit")]
+    public string? ItemInfo { get; set; }
+
+    [Option("json", Required = false, HelpText = "With --list-items or --item-info, print JSON instead of text")]
+    public bool Json { get; set; }
+
     // Bootstrap mode flags
     [Option("set-bootstrap-mode", Required = false, HelpText = "Enable bootstrap mode for next boot")]
     public bool SetBootstrapMode { get; set; }
@@ -1091,6 +1849,55 @@ public class Options
     [Option("self-check", Required = false, HelpText = "Verify Cimian installation health and exit (used by the Watchdog scheduled task)")]
     public bool SelfCheck { get; set; }
 
+    [Option("verify-agent", Required = false, HelpText = "Verify the agent's binaries, service, scheduled tasks, ACLs and registry keys, repair drift and exit (report only with --checkonly)")]
+    public bool VerifyAgent { get; set; }
+
+    // First-run setup flags (every prompt but the auth secret can be answered on the
+    // command line; secrets come from the environment or stdin so they stay out of
+    // process listings and audit logs)
+    [Option("setup", Required = false, HelpText = "Guided first-run configuration: repo URL, auth test, client identifier, schedule and a validation run")]
+    public bool Setup { get; set; }
+
+    [Option("non-interactive", Required = false, HelpText = "With --setup, never prompt; unanswered settings keep their current values")]
+    public bool NonInteractive { get; set; }
+
+    [Option("setup-repo-url", Required = false, HelpText = "With --setup, the software repository URL")]
+    public string? SetupRepoUrl { get; set; }
+
+    [Option("setup-auth", Required = false, HelpText = "With --setup, authentication method: none, basic, token or certificate")]
+    public string? SetupAuth { get; set; }
+
+    [Option("setup-auth-user", Required = false, HelpText = "With --setup, basic auth user name")]
+    public string? SetupAuthUser { get; set; }
+
+    // Parsed only so they can be refused with a pointer to the supported inputs.
+    [Option("setup-auth-password", Required = false, Hidden = true)]
+    public string? SetupAuthPassword { get; set; }
+
+    [Option("setup-auth-token", Required = false, Hidden = true)]
+    public string? SetupAuthToken { get; set; }
+
+    [Option("setup-auth-secret-stdin", Required = false, HelpText = "With --setup, read the basic auth password or bearer token from the first line of stdin (or set CIMIAN_SETUP_AUTH_PASSWORD / CIMIAN_SETUP_AUTH_TOKEN)")]
+    public bool SetupAuthSecretStdin { get; set; }
+
+    [Option("setup-client-certificate", Required = false, HelpText = "With --setup, client certificate PFX/PEM path or store thumbprint")]
+    public string? SetupClientCertificate { get; set; }
+
+    [Option("setup-client-id", Required = false, HelpText = "With --setup, client identifier strategy: hostname, serial, certificate or fixed")]
+    public string? SetupClientId { get; set; }
+
+    [Option("setup-client-id-value", Required = false, HelpText = "With --setup-client-id fixed, the manifest name to use")]
+    public string? SetupClientIdValue { get; set; }
+
+    [Option("setup-catalogs", Required = false, Separator = ',', HelpText = "With --setup, comma-separated catalogs")]
+    public IEnumerable<string>? SetupCatalogs { get; set; }
+
+    [Option("setup-schedule-hours", Required = false, HelpText = "With --setup, hours between automatic runs (0 = leave the scheduled task alone)")]
+    public int? SetupScheduleHours { get; set; }
+
+    [Option("skip-validation", Required = false, HelpText = "With --setup, skip the check-only validation run")]
+    public bool SkipValidation { get; set; }
+
     // Cache management flags
     [Option("validate-cache", Required = false, HelpText = "Validate cache integrity and remove corrupt files")]
     public bool ValidateCache { get; set; }
@@ -1098,9 +1905,15 @@ public class Options
     [Option("cache-status", Required = false, HelpText = "Show cache status and statistics")]
     public bool CacheStatus { get; set; }
 
+    [Option("cache-evict", Required = false, HelpText = "Evict old cached payloads over CacheSizeLimitMB or CacheRetentionDays and exit")]
+    public bool CacheEvict { get; set; }
+
     [Option("clean-cache", Required = false, HelpText = "Perform comprehensive cache cleanup and exit")]
     public bool CleanCache { get; set; }
 
+    [Option("prune-logs", Required = false, HelpText = "Compress and delete old session logs per LogRetentionDays / MaxLogSizeMB and exit")]
+    public bool PruneLogs { get; set; }
+
     // Loop guard flags
     [Option("clear-loop", Required = false, HelpText = "Clear install loop suppression for a package (use 'all' to clear all)")]
     public string? ClearLoop { get; set; }
@@ -1139,6 +1952,12 @@ public class Options
     [Option("show-status", Required = false, HelpText = "Show status window during operations")]
     public bool ShowStatus { get; set; }
 
+    [Option("progress", Required = false, HelpText = "Show live progress bars per download and install instead of log lines (interactive consoles only; run.log is unchanged)")]
+    public bool Progress { get; set; }
+
+    [Option("trace", Required = false, HelpText = "Record ETW and timing traces of this run to the logs\\traces directory (PerfView/WPA)")]
+    public bool Trace { get; set; }
+
     [Option("status-port", Required = false, Default = 19847,
         HelpText = "TCP port of the GUI status listener (default 19847 = login window). Managed Software Center passes its own port so the two listeners never collide.")]
     public int StatusPort { get; set; } = 19847;
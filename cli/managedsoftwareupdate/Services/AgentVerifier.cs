@@ -0,0 +1,552 @@
+using System.Diagnostics;
+using System.Security.AccessControl;
+using System.Security.Cryptography;
+using System.Security.Principal;
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using Cimian.Core;
+using Cimian.Core.Services;
+using Microsoft.Win32;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+public enum AgentCheckStatus
+{
+    Ok,
+    /// <summary>Drift was found and fixed.</summary>
+    Repaired,
+    /// <summary>Drift that could not (or, in report-only mode, was not) fixed.</summary>
+    Failed
+}
+
+public sealed record AgentCheck(
+    [property: JsonPropertyName("area")] string Area,
+    [property: JsonPropertyName("name")] string Name,
+    [property: JsonPropertyName("status"), JsonConverter(typeof(JsonStringEnumConverter))] AgentCheckStatus Status,
+    [property: JsonPropertyName("detail")] string Detail);
+
+/// <summary>
+/// What agent_baseline.json remembers about one Cimian binary.
+/// </summary>
+public class AgentBaselineEntry
+{
+    [JsonPropertyName("version")]
+    public string Version { get; set; } = string.Empty;
+
+    [JsonPropertyName("sha256")]
+    public string Sha256 { get; set; } = string.Empty;
+}
+
+public class AgentVerificationReport
+{
+    [JsonPropertyName("timestamp")]
+    public DateTimeOffset Timestamp { get; set; }
+
+    [JsonPropertyName("machine")]
+    public string Machine { get; set; } = string.Empty;
+
+    [JsonPropertyName("repair")]
+    public bool Repair { get; set; }
+
+    [JsonPropertyName("healthy")]
+    public bool Healthy => Checks.All(c => c.Status != AgentCheckStatus.Failed);
+
+    [JsonPropertyName("checks")]
+    public List<AgentCheck> Checks { get; set; } = [];
+}
+
+/// <summary>
+/// managedsoftwareupdate --verify-agent: checks that the agent itself is intact
+/// — binaries, the CimianWatcher service, the scheduled tasks, ACLs on the
+/// folders Cimian runs code from, and the HKLM\SOFTWARE\Cimian keys — and puts
+/// back what it can. Meant for tampered or partially uninstalled agents, where
+/// --self-check only notices missing binaries.
+///
+/// Binaries are held to the signer of managedsoftwareupdate.exe when it is
+/// signed, and to the SHA-256 recorded in agent_baseline.json the first time a
+/// given file version was seen: a hash change without a version change is
+/// tampering. Missing or altered binaries can't be repaired here; everything
+/// else can, unless running report-only.
+/// </summary>
+public class AgentVerifier
+{
+    internal static readonly string[] Binaries =
+    [
+        "managedsoftwareupdate.exe",
+        "cimitrigger.exe",
+        "cimiwatcher.exe",
+        "cimistatus.exe",
+        "cimiimport.exe",
+        "cimipkg.exe",
+        "makecatalogs.exe",
+        "makepkginfo.exe",
+        "manifestutil.exe",
+    ];
+
+    internal const string ServiceName = "CimianWatcher";
+    internal const string WatchdogTaskName = "Cimian Watchdog";
+    private const string RegistryPath = @"SOFTWARE\Cimian";
+
+    /// <summary>
+    /// Rights that let a principal change what SYSTEM later executes or reads as config.
+    /// </summary>
+    internal const FileSystemRights WriteRights =
+        FileSystemRights.WriteData | FileSystemRights.AppendData |
+        FileSystemRights.WriteExtendedAttributes | FileSystemRights.WriteAttributes |
+        FileSystemRights.Delete | FileSystemRights.DeleteSubdirectoriesAndFiles |
+        FileSystemRights.ChangePermissions | FileSystemRights.TakeOwnership;
+
+    private static readonly SecurityIdentifier[] BroadPrincipals =
+    [
+        new(WellKnownSidType.BuiltinUsersSid, null),
+        new(WellKnownSidType.AuthenticatedUserSid, null),
+        new(WellKnownSidType.WorldSid, null),
+    ];
+
+    private readonly string _installDir;
+    private readonly string _baselinePath;
+    private readonly bool _repair;
+    private readonly List<AgentCheck> _checks = [];
+
+    public AgentVerifier(bool repair, string? installDir = null, string? baselinePath = null)
+    {
+        _repair = repair;
+        _installDir = installDir ?? CimianPaths.CimianInstallDir;
+        _baselinePath = baselinePath ?? CimianPaths.AgentBaselineJson;
+    }
+
+    public AgentVerificationReport Verify()
+    {
+        _checks.Clear();
+        CheckBinaries();
+        CheckService();
+        CheckScheduledTasks();
+        CheckAcls();
+        CheckRegistry();
+
+        return new AgentVerificationReport
+        {
+            Timestamp = DateTimeOffset.Now,
+            Machine = Environment.MachineName,
+            Repair = _repair,
+            Checks = [.. _checks]
+        };
+    }
+
+    /// <summary>Writes the report to <paramref name="path"/> (reports\agent_verification.json).</summary>
+    public static void WriteReport(AgentVerificationReport report, string path)
+    {
+        JsonStateFile.Write(path, report);
+    }
+
+    // ── Binaries ─────────────────────────────────────────────────────────────
+
+    private void CheckBinaries()
+    {
+        var baseline = LoadBaseline();
+        var baselineChanged = false;
+
+        var anchor = Path.Combine(_installDir, "managedsoftwareupdate.exe");
+        var anchorSignature = File.Exists(anchor) ? AuthenticodeVerifier.Verify(anchor, null) : null;
+        var trustedThumbprint = anchorSignature is { IsValid: true } ? anchorSignature.Thumbprint : null;
+
+        foreach (var file in Binaries)
+        {
+            var path = Path.Combine(_installDir, file);
+            if (!File.Exists(path))
+            {
+                Add("binaries", file, AgentCheckStatus.Failed, $"Missing from {_installDir}; repair or reinstall the Cimian MSI");
+                continue;
+            }
+
+            if (trustedThumbprint != null)
+            {
+                var signature = AuthenticodeVerifier.Verify(path, null);
+                if (!signature.IsValid || !string.Equals(signature.Thumbprint, trustedThumbprint, StringComparison.OrdinalIgnoreCase))
+                {
+                    Add("binaries", file, AgentCheckStatus.Failed,
+                        $"Not signed by the same certificate as managedsoftwareupdate.exe ({signature.Status}: {signature.Detail})");
+                    continue;
+                }
+            }
+
+            var version = FileVersionInfo.GetVersionInfo(path).FileVersion ?? string.Empty;
+            var hash = ComputeHash(path);
+            baseline.TryGetValue(file, out var known);
+            var (status, detail, record) = CompareToBaseline(version, hash, known);
+            if (record)
+            {
+                baseline[file] = new AgentBaselineEntry { Version = version, Sha256 = hash };
+                baselineChanged = true;
+            }
+            Add("binaries", file, status, detail);
+        }
+
+        if (baselineChanged)
+        {
+            SaveBaseline(baseline);
+        }
+    }
+
+    /// <summary>
+    /// Judges a binary against its recorded baseline. Returns whether the
+    /// baseline should be (re)recorded: on first sight and after a version change.
+    /// </summary>
+    internal static (AgentCheckStatus Status, string Detail, bool Record) CompareToBaseline(string version, string sha256, AgentBaselineEntry? known)
+    {
+        if (known == null)
+        {
+            return (AgentCheckStatus.Ok, $"Version {version}; hash recorded as baseline", true);
+        }
+        if (!string.Equals(known.Version, version, StringComparison.OrdinalIgnoreCase))
+        {
+            return (AgentCheckStatus.Ok, $"Upgraded from {known.Version} to {version}; baseline updated", true);
+        }
+        if (!string.Equals(known.Sha256, sha256, StringComparison.OrdinalIgnoreCase))
+        {
+            return (AgentCheckStatus.Failed, $"Contents changed without a version change (version {version}); repair or reinstall the Cimian MSI", false);
+        }
+        return (AgentCheckStatus.Ok, $"Version {version}; hash matches baseline", false);
+    }
+
+    private Dictionary<string, AgentBaselineEntry> LoadBaseline()
+    {
+        try
+        {
+            if (File.Exists(_baselinePath))
+            {
+                var entries = JsonSerializer.Deserialize<Dictionary<string, AgentBaselineEntry>>(File.ReadAllText(_baselinePath));
+                if (entries != null)
+                {
+                    return new Dictionary<string, AgentBaselineEntry>(entries, StringComparer.OrdinalIgnoreCase);
+                }
+            }
+        }
+        catch (Exception ex) when (ex is IOException or JsonException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not read agent baseline: {ex.Message}");
+        }
+        return new Dictionary<string, AgentBaselineEntry>(StringComparer.OrdinalIgnoreCase);
+    }
+
+    private void SaveBaseline(Dictionary<string, AgentBaselineEntry> baseline)
+    {
+        try
+        {
+            JsonStateFile.Write(_baselinePath, baseline);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not save agent baseline: {ex.Message}");
+        }
+    }
+
+    private static string ComputeHash(string path)
+    {
+        using var stream = File.OpenRead(path);
+        return Convert.ToHexString(SHA256.HashData(stream)).ToLowerInvariant();
+    }
+
+    // ── CimianWatcher service ────────────────────────────────────────────────
+
+    private void CheckService()
+    {
+        var watcherExe = Path.Combine(_installDir, "cimiwatcher.exe");
+        var (queryExit, queryOutput) = RunTool("sc.exe", $"query {ServiceName}");
+        if (queryExit == 1060)
+        {
+            if (!_repair || !File.Exists(watcherExe))
+            {
+                Add("service", ServiceName, AgentCheckStatus.Failed, "Service is not registered");
+                return;
+            }
+            var (installExit, installOutput) = RunTool(watcherExe, "install");
+            var (startExit, _) = installExit == 0 ? RunTool("sc.exe", $"start {ServiceName}") : (installExit, "");
+            Add("service", ServiceName, installExit == 0 && startExit == 0 ? AgentCheckStatus.Repaired : AgentCheckStatus.Failed,
+                installExit == 0 ? "Service was not registered; reinstalled and started" : $"Service is not registered and reinstalling failed: {installOutput.Trim()}");
+            return;
+        }
+        if (queryExit != 0)
+        {
+            Add("service", ServiceName, AgentCheckStatus.Failed, $"sc query failed ({queryExit}): {queryOutput.Trim()}");
+            return;
+        }
+
+        var (_, config) = RunTool("sc.exe", $"qc {ServiceName}");
+        var startType = ParseScValue(config, "START_TYPE");
+        var binaryPath = ParseScValue(config, "BINARY_PATH_NAME", lastTokenOnly: false);
+        var state = ParseScValue(queryOutput, "STATE");
+        var problems = new List<string>();
+
+        if (binaryPath != null && !binaryPath.Contains(watcherExe, StringComparison.OrdinalIgnoreCase))
+        {
+            problems.Add($"points at {binaryPath}");
+            if (_repair)
+            {
+                RunTool("sc.exe", $"config {ServiceName} binPath= \"\\\"{watcherExe}\\\"\"");
+            }
+        }
+        if (!string.Equals(startType, "AUTO_START", StringComparison.OrdinalIgnoreCase))
+        {
+            problems.Add($"start type {startType ?? "unknown"}");
+            if (_repair)
+            {
+                RunTool("sc.exe", $"config {ServiceName} start= auto");
+            }
+        }
+        if (!string.Equals(state, "RUNNING", StringComparison.OrdinalIgnoreCase))
+        {
+            problems.Add($"state {state ?? "unknown"}");
+            if (_repair)
+            {
+                RunTool("sc.exe", $"start {ServiceName}");
+            }
+        }
+
+        if (problems.Count == 0)
+        {
+            Add("service", ServiceName, AgentCheckStatus.Ok, "Registered, automatic and running");
+            return;
+        }
+        if (!_repair)
+        {
+            Add("service", ServiceName, AgentCheckStatus.Failed, string.Join("; ", problems));
+            return;
+        }
+
+        var (_, after) = RunTool("sc.exe", $"query {ServiceName}");
+        var running = string.Equals(ParseScValue(after, "STATE"), "RUNNING", StringComparison.OrdinalIgnoreCase);
+        Add("service", ServiceName, running ? AgentCheckStatus.Repaired : AgentCheckStatus.Failed,
+            $"{string.Join("; ", problems)} — {(running ? "fixed" : "reconfigured but the service did not start")}");
+    }
+
+    /// <summary>
+    /// The value of <paramref name="field"/> in sc.exe query/qc output, e.g.
+    /// "RUNNING" from "STATE : 4  RUNNING". With <paramref name="lastTokenOnly"/>
+    /// false the whole value is returned (for paths with spaces).
+    /// </summary>
+    internal static string? ParseScValue(string output, string field, bool lastTokenOnly = true)
+    {
+        foreach (var line in output.Split('\n'))
+        {
+            var colon = line.IndexOf(':');
+            if (colon < 0 || !line[..colon].Trim().Equals(field, StringComparison.OrdinalIgnoreCase))
+            {
+                continue;
+            }
+            var value = line[(colon + 1)..].Trim();
+            if (!lastTokenOnly)
+            {
+                return value;
+            }
+            var tokens = value.Split(' ', StringSplitOptions.RemoveEmptyEntries);
+            return tokens.Length == 0 ? null : tokens[^1];
+        }
+        return null;
+    }
+
+    // ── Scheduled tasks ──────────────────────────────────────────────────────
+
+    private void CheckScheduledTasks()
+    {
+        var exe = Path.Combine(_installDir, "managedsoftwareupdate.exe");
+        CheckScheduledTask(SetupWizard.AutoRunTaskName, exe, "--auto", hours: 1);
+        CheckScheduledTask(WatchdogTaskName, exe, "--self-check", hours: 4);
+    }
+
+    private void CheckScheduledTask(string taskName, string exe, string arguments, int hours)
+    {
+        var (exit, _) = RunTool("schtasks.exe", $"/Query /TN \"{taskName}\"");
+        if (exit == 0)
+        {
+            Add("tasks", taskName, AgentCheckStatus.Ok, "Registered");
+            return;
+        }
+        if (!_repair)
+        {
+            Add("tasks", taskName, AgentCheckStatus.Failed, "Task is missing");
+            return;
+        }
+
+        var (createExit, createOutput) = RunTool("schtasks.exe",
+            $"/Create /TN \"{taskName}\" /TR \"\\\"{exe}\\\" {arguments}\" /SC HOURLY /MO {hours} /RU SYSTEM /RL HIGHEST /F");
+        Add("tasks", taskName, createExit == 0 ? AgentCheckStatus.Repaired : AgentCheckStatus.Failed,
+            createExit == 0 ? $"Task was missing; recreated to run {arguments} every {hours} hour(s)" : $"Task is missing and could not be recreated: {createOutput.Trim()}");
+    }
+
+    // ── ACLs ─────────────────────────────────────────────────────────────────
+
+    /// <summary>
+    /// Folders and files SYSTEM executes or trusts as config. Non-admins must
+    /// not be able to write to them; ManagedInstallsRoot itself is left alone
+    /// because Managed Software Center writes SelfServeManifest.yaml as the user.
+    /// </summary>
+    private IEnumerable<string> ProtectedPaths() => [_installDir, CimianPaths.SbinDir, CimianPaths.ConfigYaml];
+
+    private void CheckAcls()
+    {
+        foreach (var path in ProtectedPaths())
+        {
+            if (!File.Exists(path) && !Directory.Exists(path))
+            {
+                continue;
+            }
+
+            try
+            {
+                var offending = BroadWriteRules(ReadAcl(path)).ToList();
+                if (offending.Count == 0)
+                {
+                    Add("acls", path, AgentCheckStatus.Ok, "Only administrators and SYSTEM can write");
+                    continue;
+                }
+
+                var who = string.Join(", ", offending.Select(r => Describe(r.IdentityReference)).Distinct());
+                if (!_repair)
+                {
+                    Add("acls", path, AgentCheckStatus.Failed, $"Writable by {who}");
+                    continue;
+                }
+
+                var security = ReadAcl(path);
+                if (offending.Any(r => r.IsInherited))
+                {
+                    // Inherited grants (ProgramData hands Users create rights) can
+                    // only go once this path stops inheriting; keep everything else.
+                    security.SetAccessRuleProtection(isProtected: true, preserveInheritance: true);
+                    WriteAcl(path, security);
+                    security = ReadAcl(path);
+                }
+                foreach (var rule in BroadWriteRules(security).ToList())
+                {
+                    security.RemoveAccessRule(new FileSystemAccessRule(rule.IdentityReference, WriteRights,
+                        rule.InheritanceFlags, rule.PropagationFlags, AccessControlType.Allow));
+                }
+                WriteAcl(path, security);
+                Add("acls", path, AgentCheckStatus.Repaired, $"Removed write access for {who}");
+            }
+            catch (Exception ex) when (ex is UnauthorizedAccessException or IOException or InvalidOperationException)
+            {
+                Add("acls", path, AgentCheckStatus.Failed, $"Could not check or fix permissions: {ex.Message}");
+            }
+        }
+    }
+
+    internal static bool GrantsWrite(FileSystemRights rights) => (rights & WriteRights) != 0;
+
+    private static IEnumerable<FileSystemAccessRule> BroadWriteRules(FileSystemSecurity security) =>
+        security.GetAccessRules(includeExplicit: true, includeInherited: true, typeof(SecurityIdentifier))
+            .Cast<FileSystemAccessRule>()
+            .Where(r => r.AccessControlType == AccessControlType.Allow
+                && r.IdentityReference is SecurityIdentifier sid
+                && BroadPrincipals.Contains(sid)
+                && GrantsWrite(r.FileSystemRights));
+
+    private static FileSystemSecurity ReadAcl(string path) =>
+        Directory.Exists(path) ? new DirectoryInfo(path).GetAccessControl() : new FileInfo(path).GetAccessControl();
+
+    private static void WriteAcl(string path, FileSystemSecurity security)
+    {
+        if (security is DirectorySecurity directorySecurity)
+        {
+            new DirectoryInfo(path).SetAccessControl(directorySecurity);
+        }
+        else
+        {
+            new FileInfo(path).SetAccessControl((FileSecurity)security);
+        }
+    }
+
+    private static string Describe(IdentityReference identity)
+    {
+        try
+        {
+            return identity.Translate(typeof(NTAccount)).Value;
+        }
+        catch (IdentityNotMappedException)
+        {
+            return identity.Value;
+        }
+    }
+
+    // ── Registry ─────────────────────────────────────────────────────────────
+
+    private void CheckRegistry()
+    {
+        try
+        {
+            using var key = _repair
+                ? Registry.LocalMachine.CreateSubKey(RegistryPath, writable: true)
+                : Registry.LocalMachine.OpenSubKey(RegistryPath);
+            if (key == null)
+            {
+                Add("registry", $@"HKLM\{RegistryPath}", AgentCheckStatus.Failed, "Key is missing");
+                return;
+            }
+
+            var installPath = _installDir.TrimEnd('\\') + "\\";
+            CheckRegistryValue(key, "InstallPath", installPath,
+                current => current.TrimEnd('\\').Equals(_installDir.TrimEnd('\\'), StringComparison.OrdinalIgnoreCase));
+
+            var msuPath = Path.Combine(_installDir, "managedsoftwareupdate.exe");
+            var version = File.Exists(msuPath) ? FileVersionInfo.GetVersionInfo(msuPath).ProductVersion : null;
+            if (version != null)
+            {
+                CheckRegistryValue(key, "Version", version, current => current.Length > 0);
+            }
+        }
+        catch (Exception ex) when (ex is UnauthorizedAccessException or System.Security.SecurityException or IOException)
+        {
+            Add("registry", $@"HKLM\{RegistryPath}", AgentCheckStatus.Failed, $"Could not check or fix the registry: {ex.Message}");
+        }
+    }
+
+    private void CheckRegistryValue(RegistryKey key, string name, string expected, Func<string, bool> isValid)
+    {
+        var current = key.GetValue(name) as string;
+        if (current != null && isValid(current))
+        {
+            Add("registry", name, AgentCheckStatus.Ok, current);
+            return;
+        }
+        if (!_repair)
+        {
+            Add("registry", name, AgentCheckStatus.Failed, current == null ? "Value is missing" : $"Unexpected value {current}");
+            return;
+        }
+        key.SetValue(name, expected, RegistryValueKind.String);
+        Add("registry", name, AgentCheckStatus.Repaired, current == null ? $"Value was missing; set to {expected}" : $"Was {current}; set to {expected}");
+    }
+
+    // ── Helpers ──────────────────────────────────────────────────────────────
+
+    private void Add(string area, string name, AgentCheckStatus status, string detail) =>
+        _checks.Add(new AgentCheck(area, name, status, detail));
+
+    private static (int ExitCode, string Output) RunTool(string fileName, string arguments)
+    {
+        try
+        {
+            using var process = Process.Start(new ProcessStartInfo
+            {
+                FileName = fileName,
+                Arguments = arguments,
+                UseShellExecute = false,
+                RedirectStandardOutput = true,
+                RedirectStandardError = true,
+                CreateNoWindow = true
+            });
+            if (process == null)
+            {
+                return (-1, $"Failed to start {fileName}");
+            }
+            var output = process.StandardOutput.ReadToEnd() + process.StandardError.ReadToEnd();
+            process.WaitForExit(30000);
+            return (process.ExitCode, output);
+        }
+        catch (Exception ex) when (ex is System.ComponentModel.Win32Exception or InvalidOperationException)
+        {
+            return (-1, ex.Message);
+        }
+    }
+}
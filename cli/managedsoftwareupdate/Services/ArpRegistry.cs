@@ -0,0 +1,144 @@
+using System.Text.RegularExpressions;
+using Cimian.Core.Services;
+using Microsoft.Win32;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// One Add/Remove Programs entry under an Uninstall key. UserSid is set for
+/// entries from a user's hive (per-user installs), null for HKLM ones.
+/// </summary>
+public sealed record ArpEntry(
+    string KeyName,
+    string DisplayName,
+    string? DisplayVersion,
+    string? UninstallString,
+    string? QuietUninstallString,
+    string? UserSid = null)
+{
+    /// <summary>QuietUninstallString when the app registered one, otherwise UninstallString.</summary>
+    public string? Command => !string.IsNullOrWhiteSpace(QuietUninstallString) ? QuietUninstallString : UninstallString;
+
+    public bool IsQuiet => !string.IsNullOrWhiteSpace(QuietUninstallString);
+}
+
+/// <summary>What to look for: any of a product code, a DisplayName regex or a plain DisplayName.</summary>
+public sealed record ArpQuery(string? ProductCode = null, string? DisplayNameRegex = null, string? DisplayName = null);
+
+/// <summary>
+/// Reads the Windows Uninstall hives - HKLM in both registry views, and the
+/// console user's own hive, which is HKCU to them - and picks the entry a
+/// catalog item refers to, so apps can be removed with the uninstaller they
+/// registered instead of one shipped in the repo.
+/// </summary>
+public static class ArpRegistry
+{
+    private const string UninstallPath = @"SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall";
+
+    private static readonly TimeSpan RegexTimeout = TimeSpan.FromSeconds(1);
+
+    /// <summary>
+    /// Every entry with a DisplayName, HKLM 64-bit first, then 32-bit, then
+    /// the hive of <paramref name="userSid"/> when given.
+    /// </summary>
+    public static List<ArpEntry> Scan(string? userSid)
+    {
+        var entries = new List<ArpEntry>();
+        foreach (var view in new[] { RegistryView.Registry64, RegistryView.Registry32 })
+        {
+            try
+            {
+                using var baseKey = RegistryKey.OpenBaseKey(RegistryHive.LocalMachine, view);
+                using var root = baseKey.OpenSubKey(UninstallPath);
+                Read(root, null, entries);
+            }
+            catch (Exception ex)
+            {
+                ConsoleLogger.Debug($"Could not read {view} Uninstall key: {ex.Message}");
+            }
+        }
+
+        if (!string.IsNullOrEmpty(userSid))
+        {
+            try
+            {
+                using var root = Registry.Users.OpenSubKey($@"{userSid}\{UninstallPath}");
+                Read(root, userSid, entries);
+            }
+            catch (Exception ex)
+            {
+                ConsoleLogger.Debug($"Could not read Uninstall key for {userSid}: {ex.Message}");
+            }
+        }
+        return entries;
+    }
+
+    /// <summary>
+    /// The entry <paramref name="query"/> names, among those with an uninstall
+    /// command: the product code's key (or Inno Setup's {AppId}_is1) first,
+    /// then the first DisplayName the regex matches, then an exact DisplayName
+    /// and finally one that contains it (never the reverse, which would let a
+    /// short name adopt an unrelated app). Throws <see cref="ArgumentException"/>
+    /// for an invalid regex.
+    /// </summary>
+    public static ArpEntry? Find(IEnumerable<ArpEntry> entries, ArpQuery query)
+    {
+        var candidates = entries.Where(e => !string.IsNullOrWhiteSpace(e.Command)).ToList();
+
+        if (NormalizeProductCode(query.ProductCode) is { } productCode
+            && candidates.FirstOrDefault(e => NormalizeProductCode(TrimInnoSuffix(e.KeyName)) == productCode) is { } byCode)
+        {
+            return byCode;
+        }
+
+        if (!string.IsNullOrWhiteSpace(query.DisplayNameRegex))
+        {
+            var regex = new Regex(query.DisplayNameRegex, RegexOptions.IgnoreCase | RegexOptions.CultureInvariant, RegexTimeout);
+            if (candidates.FirstOrDefault(e => regex.IsMatch(e.DisplayName)) is { } byRegex)
+            {
+                return byRegex;
+            }
+        }
+
+        if (!string.IsNullOrWhiteSpace(query.DisplayName))
+        {
+            return candidates.FirstOrDefault(e => string.Equals(e.DisplayName, query.DisplayName, StringComparison.OrdinalIgnoreCase))
+                ?? candidates.FirstOrDefault(e => e.DisplayName.Contains(query.DisplayName, StringComparison.OrdinalIgnoreCase));
+        }
+        return null;
+    }
+
+    /// <summary>A product code as {UPPERCASE-GUID}, or null when it isn't one.</summary>
+    internal static string? NormalizeProductCode(string? value) =>
+        Guid.TryParse(value?.Trim().Trim('{', '}'), out var guid) ? guid.ToString("B").ToUpperInvariant() : null;
+
+    // Inno Setup registers under "{AppId}_is1"
+    private static string TrimInnoSuffix(string keyName) =>
+        keyName.EndsWith("_is1", StringComparison.OrdinalIgnoreCase) ? keyName[..^4] : keyName;
+
+    private static void Read(RegistryKey? root, string? userSid, List<ArpEntry> entries)
+    {
+        if (root == null)
+        {
+            return;
+        }
+
+        foreach (var keyName in root.GetSubKeyNames())
+        {
+            using var key = root.OpenSubKey(keyName);
+            var displayName = key?.GetValue("DisplayName")?.ToString();
+            if (string.IsNullOrEmpty(displayName))
+            {
+                continue;
+            }
+
+            entries.Add(new ArpEntry(
+                keyName,
+                displayName,
+                key!.GetValue("DisplayVersion")?.ToString(),
+                key.GetValue("UninstallString")?.ToString(),
+                key.GetValue("QuietUninstallString")?.ToString(),
+                userSid));
+        }
+    }
+}
@@ -0,0 +1,177 @@
+using System.Runtime.InteropServices;
+using System.Security.Cryptography;
+using System.Security.Cryptography.X509Certificates;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+public enum SignatureStatus
+{
+    /// <summary>Signature chains to a trusted root and matches expected_signer (if any).</summary>
+    Valid,
+    /// <summary>No Authenticode signature.</summary>
+    Unsigned,
+    /// <summary>Signed, but WinVerifyTrust rejected it (tampered, revoked, untrusted root).</summary>
+    Untrusted,
+    /// <summary>Trusted signature from someone other than expected_signer.</summary>
+    SignerMismatch
+}
+
+public sealed record SignatureVerification(SignatureStatus Status, string? Subject, string? Thumbprint, string Detail)
+{
+    public bool IsValid => Status == SignatureStatus.Valid;
+}
+
+/// <summary>
+/// Authenticode checks for EXE/MSI payloads: WinVerifyTrust for chain and
+/// integrity, then the leaf certificate's subject/thumbprint against the item's
+/// expected_signer.
+/// </summary>
+public static class AuthenticodeVerifier
+{
+    private static readonly Guid WintrustActionGenericVerifyV2 = new("00AAC56B-CD44-11d0-8CC2-00C04FC295EE");
+
+    private const uint WtdUiNone = 2;
+    private const uint WtdRevokeNone = 0;
+    private const uint WtdChoiceFile = 1;
+    private const uint WtdStateActionVerify = 1;
+    private const uint WtdStateActionClose = 2;
+    private const uint WtdCacheOnlyUrlRetrieval = 0x1000;
+
+    private const int TrustENoSignature = unchecked((int)0x800B0100);
+    private const int TrustESubjectFormUnknown = unchecked((int)0x800B0003);
+
+    [StructLayout(LayoutKind.Sequential, CharSet = CharSet.Unicode)]
+    private struct WinTrustFileInfo
+    {
+        public uint cbStruct;
+        public string pcwszFilePath;
+        public IntPtr hFile;
+        public IntPtr pgKnownSubject;
+    }
+
+    [StructLayout(LayoutKind.Sequential, CharSet = CharSet.Unicode)]
+    private struct WinTrustData
+    {
+        public uint cbStruct;
+        public IntPtr pPolicyCallbackData;
+        public IntPtr pSIPClientData;
+        public uint dwUIChoice;
+        public uint fdwRevocationChecks;
+        public uint dwUnionChoice;
+        public IntPtr pFile;
+        public uint dwStateAction;
+        public IntPtr hWVTStateData;
+        public IntPtr pwszURLReference;
+        public uint dwProvFlags;
+        public uint dwUIContext;
+        public IntPtr pSignatureSettings;
+    }
+
+    [DllImport("wintrust.dll", CharSet = CharSet.Unicode)]
+    private static extern int WinVerifyTrust(IntPtr hwnd, [MarshalAs(UnmanagedType.LPStruct)] Guid pgActionID, ref WinTrustData pWVTData);
+
+    /// <summary>
+    /// True for payloads Authenticode applies to. Scripts, archives and packages
+    /// carry their own integrity story (hash, .pkg signature).
+    /// </summary>
+    public static bool AppliesTo(string installerType, string path)
+    {
+        var type = installerType.ToLowerInvariant();
+        if (type is "exe" or "msi")
+        {
+            return true;
+        }
+        var ext = Path.GetExtension(path).ToLowerInvariant();
+        return ext is ".exe" or ".msi" or ".msp";
+    }
+
+    public static SignatureVerification Verify(string path, string? expectedSigner)
+    {
+        var trust = VerifyTrust(path);
+        if (trust == TrustENoSignature || trust == TrustESubjectFormUnknown)
+        {
+            return new SignatureVerification(SignatureStatus.Unsigned, null, null, "File is not signed");
+        }
+
+        string? subject = null;
+        string? thumbprint = null;
+        try
+        {
+#pragma warning disable SYSLIB0057 // the signer certificate isn't exposed any other way
+            using var cert = new X509Certificate2(X509Certificate.CreateFromSignedFile(path));
+#pragma warning restore SYSLIB0057
+            subject = cert.Subject;
+            thumbprint = cert.Thumbprint;
+        }
+        catch (CryptographicException)
+        {
+            // WinVerifyTrust already had its say; leave subject blank
+        }
+
+        if (trust != 0)
+        {
+            return new SignatureVerification(SignatureStatus.Untrusted, subject, thumbprint,
+                $"WinVerifyTrust failed (0x{trust:X8})");
+        }
+
+        if (!string.IsNullOrWhiteSpace(expectedSigner) && !MatchesSigner(expectedSigner, subject, thumbprint))
+        {
+            return new SignatureVerification(SignatureStatus.SignerMismatch, subject, thumbprint,
+                $"Signed by '{subject}', expected '{expectedSigner}'");
+        }
+
+        return new SignatureVerification(SignatureStatus.Valid, subject, thumbprint, $"Signed by '{subject}'");
+    }
+
+    /// <summary>
+    /// An expected_signer of 40 hex digits is a certificate thumbprint and must
+    /// match exactly; anything else must appear in the certificate subject
+    /// ("Mozilla Corporation" or "CN=Mozilla Corporation, O=...").
+    /// </summary>
+    public static bool MatchesSigner(string expectedSigner, string? subject, string? thumbprint)
+    {
+        var expected = expectedSigner.Trim();
+        var compact = expected.Replace(" ", "").Replace(":", "");
+        if (compact.Length == 40 && compact.All(Uri.IsHexDigit))
+        {
+            return string.Equals(compact, thumbprint, StringComparison.OrdinalIgnoreCase);
+        }
+        return !string.IsNullOrEmpty(subject) && subject.Contains(expected, StringComparison.OrdinalIgnoreCase);
+    }
+
+    private static int VerifyTrust(string path)
+    {
+        var fileInfo = new WinTrustFileInfo
+        {
+            cbStruct = (uint)Marshal.SizeOf<WinTrustFileInfo>(),
+            pcwszFilePath = path
+        };
+        var pFile = Marshal.AllocHGlobal(Marshal.SizeOf<WinTrustFileInfo>());
+        try
+        {
+            Marshal.StructureToPtr(fileInfo, pFile, false);
+            var data = new WinTrustData
+            {
+                cbStruct = (uint)Marshal.SizeOf<WinTrustData>(),
+                dwUIChoice = WtdUiNone,
+                fdwRevocationChecks = WtdRevokeNone,
+                dwUnionChoice = WtdChoiceFile,
+                pFile = pFile,
+                dwStateAction = WtdStateActionVerify,
+                // Offline-friendly: use cached CRLs only so air-gapped sites don't stall
+                dwProvFlags = WtdCacheOnlyUrlRetrieval
+            };
+
+            var result = WinVerifyTrust(IntPtr.Zero, WintrustActionGenericVerifyV2, ref data);
+
+            data.dwStateAction = WtdStateActionClose;
+            WinVerifyTrust(IntPtr.Zero, WintrustActionGenericVerifyV2, ref data);
+            return result;
+        }
+        finally
+        {
+            Marshal.DestroyStructure<WinTrustFileInfo>(pFile);
+            Marshal.FreeHGlobal(pFile);
+        }
+    }
+}
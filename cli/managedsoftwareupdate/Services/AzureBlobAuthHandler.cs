@@ -0,0 +1,98 @@
+using System.Net.Http.Headers;
+using Azure.Core;
+using Azure.Identity;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Authenticates repo requests to Azure Blob Storage (RepoBackend: azblob), so
+/// SoftwareRepoURL can point straight at a container such as
+/// https://account.blob.core.windows.net/cimian. With AuthToken set it is used
+/// as a SAS token appended to every request; otherwise a bearer token comes from
+/// the Azure SDK credential chain, which on Azure and Arc-enabled machines is the
+/// managed identity (RepoManagedIdentityClientId picks a user-assigned one).
+/// </summary>
+internal sealed class AzureBlobAuthHandler : DelegatingHandler
+{
+    /// <summary>Blob service version sent with every request; OAuth needs 2017-11-09 or later.</summary>
+    internal const string StorageApiVersion = "2023-11-03";
+
+    private static readonly string[] StorageScope = ["https://storage.azure.com/.default"];
+    private static readonly TimeSpan RefreshMargin = TimeSpan.FromMinutes(5);
+
+    private readonly HashSet<string> _repoHosts;
+    private readonly string? _sasToken;
+    private readonly TokenCredential? _credential;
+    private readonly SemaphoreSlim _tokenLock = new(1, 1);
+    private AccessToken? _token;
+
+    /// <param name="repoHosts">Only requests to these hosts (the repo and its mirrors) are authenticated, so tokens never reach other origins.</param>
+    public AzureBlobAuthHandler(IEnumerable<string> repoHosts, string? sasToken, string? managedIdentityClientId, TokenCredential? credential = null)
+    {
+        _repoHosts = new HashSet<string>(repoHosts, StringComparer.OrdinalIgnoreCase);
+        _sasToken = string.IsNullOrWhiteSpace(sasToken) ? null : sasToken.Trim().TrimStart('?');
+        if (_sasToken == null)
+        {
+            _credential = credential ?? new DefaultAzureCredential(new DefaultAzureCredentialOptions
+            {
+                ManagedIdentityClientId = string.IsNullOrWhiteSpace(managedIdentityClientId) ? null : managedIdentityClientId
+            });
+        }
+    }
+
+    protected override async Task<HttpResponseMessage> SendAsync(HttpRequestMessage request, CancellationToken cancellationToken)
+    {
+        if (request.RequestUri == null || !_repoHosts.Contains(request.RequestUri.Host))
+        {
+            return await base.SendAsync(request, cancellationToken).ConfigureAwait(false);
+        }
+
+        request.Headers.Remove("x-ms-version");
+        request.Headers.TryAddWithoutValidation("x-ms-version", StorageApiVersion);
+
+        if (_sasToken != null)
+        {
+            request.RequestUri = AppendSas(request.RequestUri!, _sasToken);
+        }
+        else
+        {
+            var token = await GetTokenAsync(cancellationToken).ConfigureAwait(false);
+            request.Headers.Authorization = new AuthenticationHeaderValue("Bearer", token);
+        }
+        return await base.SendAsync(request, cancellationToken).ConfigureAwait(false);
+    }
+
+    internal static Uri AppendSas(Uri uri, string sasToken)
+    {
+        var builder = new UriBuilder(uri);
+        var query = builder.Query.TrimStart('?');
+        builder.Query = string.IsNullOrEmpty(query) ? sasToken : $"{query}&{sasToken}";
+        return builder.Uri;
+    }
+
+    private async Task<string> GetTokenAsync(CancellationToken cancellationToken)
+    {
+        await _tokenLock.WaitAsync(cancellationToken).ConfigureAwait(false);
+        try
+        {
+            if (_token is not { } token || token.ExpiresOn - RefreshMargin <= DateTimeOffset.UtcNow)
+            {
+                try
+                {
+                    token = await _credential!.GetTokenAsync(new TokenRequestContext(StorageScope), cancellationToken).ConfigureAwait(false);
+                }
+                catch (AuthenticationFailedException ex)
+                {
+                    // Surface like any other transport failure so callers' repo error handling applies
+                    throw new HttpRequestException($"No Azure credentials for the blob repo: {ex.Message}", ex);
+                }
+                _token = token;
+            }
+            return token.Token;
+        }
+        finally
+        {
+            _tokenLock.Release();
+        }
+    }
+}
@@ -0,0 +1,128 @@
+using System.Diagnostics;
+using Cimian.CLI.managedsoftwareupdate.Models;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Token-bucket limiter shared by every concurrent download so
+/// MaxDownloadRateKBps caps the total, not each worker. Writers borrow
+/// against the bucket and sleep off any debt, which keeps the workers fair
+/// without a central scheduler. Bursts are capped at one second's worth.
+/// The rate is re-read every <see cref="RateRefreshInterval"/>, so a download
+/// that runs into a DownloadRateSchedule window slows down (or speeds up) mid-file.
+/// </summary>
+public sealed class BandwidthThrottle
+{
+    internal static readonly TimeSpan RateRefreshInterval = TimeSpan.FromSeconds(30);
+
+    private readonly Func<long> _rate;
+    private readonly object _lock = new();
+    private double _bytesPerSecond;
+    private double _available;
+    private long _lastTimestamp;
+    private long _rateTimestamp;
+
+    public BandwidthThrottle(long bytesPerSecond)
+        : this(() => bytesPerSecond)
+    {
+    }
+
+    /// <summary>
+    /// Throttle whose rate in bytes per second comes from <paramref name="bytesPerSecond"/>;
+    /// 0 or less means unlimited for as long as it says so.
+    /// </summary>
+    public BandwidthThrottle(Func<long> bytesPerSecond)
+    {
+        _rate = bytesPerSecond;
+        _lastTimestamp = _rateTimestamp = Stopwatch.GetTimestamp();
+        _bytesPerSecond = _rate();
+        _available = Math.Max(0, _bytesPerSecond);
+    }
+
+    /// <summary>
+    /// Throttle for a fixed rate in KB/s, or null when unlimited.
+    /// </summary>
+    public static BandwidthThrottle? FromKilobytesPerSecond(int kbps) =>
+        kbps > 0 ? new BandwidthThrottle(kbps * 1024L) : null;
+
+    /// <summary>
+    /// Throttle for MaxDownloadRateKBps and DownloadRateSchedule, or null when
+    /// neither limits anything.
+    /// </summary>
+    public static BandwidthThrottle? FromConfig(CimianConfig config, Func<DateTime>? utcNow = null)
+    {
+        if (config.DownloadRateSchedule.Count == 0)
+        {
+            return FromKilobytesPerSecond(config.EffectiveMaxDownloadRateKBps);
+        }
+
+        var clock = utcNow ?? (() => DateTime.UtcNow);
+        return new BandwidthThrottle(() => KilobytesPerSecondAt(config, clock()) * 1024L);
+    }
+
+    /// <summary>
+    /// The cap in KB/s at <paramref name="utcNow"/>: the first DownloadRateSchedule
+    /// window containing it, otherwise MaxDownloadRateKBps. 0 = unlimited.
+    /// </summary>
+    internal static int KilobytesPerSecondAt(CimianConfig config, DateTime utcNow)
+    {
+        var window = config.DownloadRateSchedule.FirstOrDefault(w => w.IsWithinWindow(utcNow));
+        return Math.Max(0, window?.MaxDownloadRateKBps ?? config.EffectiveMaxDownloadRateKBps);
+    }
+
+    /// <summary>
+    /// The lowest cap the configuration ever applies, in bytes per second, or 0
+    /// when nothing is capped; low enough rates mustn't be mistaken for stalls.
+    /// </summary>
+    internal static long LowestBytesPerSecond(CimianConfig config) =>
+        config.DownloadRateSchedule.Select(w => w.MaxDownloadRateKBps)
+            .Append(config.EffectiveMaxDownloadRateKBps)
+            .Where(kbps => kbps > 0)
+            .Select(kbps => kbps * 1024L)
+            .DefaultIfEmpty(0)
+            .Min();
+
+    /// <summary>The current rate in bytes per second; 0 when unlimited right now.</summary>
+    public long BytesPerSecond => (long)Math.Max(0, _bytesPerSecond);
+
+    /// <summary>
+    /// Accounts for <paramref name="bytes"/> just transferred and waits until the
+    /// running total is back under the limit.
+    /// </summary>
+    public Task WaitAsync(int bytes, CancellationToken cancellationToken = default)
+    {
+        var delay = Reserve(bytes);
+        return delay > TimeSpan.Zero ? Task.Delay(delay, cancellationToken) : Task.CompletedTask;
+    }
+
+    /// <summary>
+    /// Deducts from the bucket and returns how long the caller must wait.
+    /// </summary>
+    internal TimeSpan Reserve(int bytes)
+    {
+        lock (_lock)
+        {
+            var now = Stopwatch.GetTimestamp();
+            if (Stopwatch.GetElapsedTime(_rateTimestamp, now) >= RateRefreshInterval)
+            {
+                _bytesPerSecond = _rate();
+                _rateTimestamp = now;
+            }
+
+            var elapsed = Stopwatch.GetElapsedTime(_lastTimestamp, now).TotalSeconds;
+            _lastTimestamp = now;
+
+            if (_bytesPerSecond <= 0)
+            {
+                // Unlimited for now; the bucket refills once a cap applies again
+                _available = 0;
+                return TimeSpan.Zero;
+            }
+
+            _available = Math.Min(_bytesPerSecond, _available + elapsed * _bytesPerSecond);
+            _available -= bytes;
+
+            return _available >= 0 ? TimeSpan.Zero : TimeSpan.FromSeconds(-_available / _bytesPerSecond);
+        }
+    }
+}
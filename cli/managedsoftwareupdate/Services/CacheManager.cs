@@ -0,0 +1,209 @@
+using System.Text.RegularExpressions;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Keeps the download cache inside CacheSizeLimitMB and CacheRetentionDays.
+/// Payloads are evicted least recently used first, but the newest payload of
+/// every item stays so a broken update can be rolled back or repaired without
+/// going back to the repo. Partial downloads are left to
+/// <see cref="DownloadService.ValidateAndCleanCache"/>.
+/// </summary>
+public class CacheManager
+{
+    private static readonly string[] PartialSuffixes = [".downloading", ".patching"];
+
+    // "Chrome-120.0.1-x64.msi" and "Chrome_121.0.msi" both belong to "chrome"
+    private static readonly Regex VersionSuffix = new(@"^(?<stem>.+?)[-_ .]v?\d", RegexOptions.IgnoreCase | RegexOptions.CultureInvariant);
+
+    private readonly CimianConfig _config;
+    private readonly Dictionary<string, string> _owners = new(StringComparer.OrdinalIgnoreCase);
+
+    /// <summary>
+    /// Cache manager for <paramref name="config"/>'s CachePath. With a catalog,
+    /// payloads are grouped by the item they belong to; without one, by file name
+    /// with the version stripped.
+    /// </summary>
+    public CacheManager(CimianConfig config, IEnumerable<CatalogItem>? catalog = null)
+    {
+        _config = config;
+
+        foreach (var item in catalog ?? [])
+        {
+            var installer = item.Installer;
+            foreach (var location in new[] { installer.Location, installer.FullLocation, installer.BaseLocation })
+            {
+                if (!string.IsNullOrEmpty(location))
+                {
+                    _owners.TryAdd(DownloadService.CachePathFor(_config.CachePath, item, location), ItemKey.Canonical(item.Name));
+                }
+            }
+        }
+    }
+
+    /// <summary>CacheSizeLimitMB in bytes; 0 when the cache is unlimited.</summary>
+    public long LimitBytes => Math.Max(0, _config.CacheSizeLimitMB) * 1024L * 1024L;
+
+    /// <summary>
+    /// Every complete payload in the cache.
+    /// </summary>
+    public List<CachedPayload> Scan()
+    {
+        var payloads = new List<CachedPayload>();
+        if (!Directory.Exists(_config.CachePath))
+        {
+            return payloads;
+        }
+
+        foreach (var file in Directory.EnumerateFiles(_config.CachePath, "*", SearchOption.AllDirectories))
+        {
+            if (PartialSuffixes.Any(s => file.EndsWith(s, StringComparison.OrdinalIgnoreCase)))
+            {
+                continue;
+            }
+            try
+            {
+                var info = new FileInfo(file);
+                var lastUsed = info.LastAccessTimeUtc > info.LastWriteTimeUtc ? info.LastAccessTimeUtc : info.LastWriteTimeUtc;
+                payloads.Add(new CachedPayload(file, OwnerOf(file), info.Length, info.LastWriteTimeUtc, lastUsed));
+            }
+            catch (IOException ex)
+            {
+                ConsoleLogger.Debug($"Could not read cached file {file}: {ex.Message}");
+            }
+        }
+
+        return payloads;
+    }
+
+    /// <summary>
+    /// What eviction would remove right now, without touching anything.
+    /// </summary>
+    public CacheEvictionPlan Plan(DateTime? utcNow = null) =>
+        PlanEviction(Scan(), LimitBytes, _config.CacheRetentionDays, utcNow ?? DateTime.UtcNow);
+
+    /// <summary>
+    /// Deletes the payloads <see cref="Plan"/> picks. Returns the number of files
+    /// and bytes freed.
+    /// </summary>
+    public (int FileCount, long BytesFreed) Evict(DateTime? utcNow = null)
+    {
+        var plan = Plan(utcNow);
+        var fileCount = 0;
+        var bytesFreed = 0L;
+
+        foreach (var payload in plan.Candidates)
+        {
+            try
+            {
+                File.Delete(payload.Path);
+                fileCount++;
+                bytesFreed += payload.Size;
+                ConsoleLogger.Debug($"Evicted cached file: {payload.Path}");
+            }
+            catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+            {
+                ConsoleLogger.Warn($"Failed to evict cached file {payload.Path}: {ex.Message}");
+            }
+        }
+
+        if (fileCount > 0)
+        {
+            ConsoleLogger.Info($"Cache eviction: removed {fileCount} files, freed {bytesFreed / (1024.0 * 1024.0):F1} MB");
+        }
+        return (fileCount, bytesFreed);
+    }
+
+    /// <summary>
+    /// Records that a cached payload was just used, so LRU eviction sees it even
+    /// where NTFS last-access updates are turned off.
+    /// </summary>
+    public static void MarkUsed(string path)
+    {
+        try
+        {
+            File.SetLastAccessTimeUtc(path, DateTime.UtcNow);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Debug($"Could not update last access time of {path}: {ex.Message}");
+        }
+    }
+
+    /// <summary>
+    /// Picks payloads to evict: everything unused for longer than
+    /// <paramref name="retentionDays"/>, then least recently used first until the
+    /// cache fits in <paramref name="limitBytes"/>. The most recently downloaded
+    /// payload of each item is never picked. 0 disables either limit.
+    /// </summary>
+    internal static CacheEvictionPlan PlanEviction(IReadOnlyList<CachedPayload> payloads, long limitBytes, int retentionDays, DateTime utcNow)
+    {
+        var retained = payloads
+            .GroupBy(p => p.ItemKey, StringComparer.OrdinalIgnoreCase)
+            .Select(g => g.OrderByDescending(p => p.DownloadedUtc).First())
+            .ToList();
+        var retainedPaths = retained.Select(p => p.Path).ToHashSet(StringComparer.OrdinalIgnoreCase);
+
+        var evictable = payloads
+            .Where(p => !retainedPaths.Contains(p.Path))
+            .OrderBy(p => p.LastUsedUtc)
+            .ToList();
+
+        var candidates = new List<CachedPayload>();
+        var remaining = payloads.Sum(p => p.Size);
+        foreach (var payload in evictable)
+        {
+            var expired = retentionDays > 0 && payload.LastUsedUtc < utcNow.AddDays(-retentionDays);
+            var overQuota = limitBytes > 0 && remaining > limitBytes;
+            if (!expired && !overQuota)
+            {
+                continue;
+            }
+            candidates.Add(payload);
+            remaining -= payload.Size;
+        }
+
+        return new CacheEvictionPlan(payloads, candidates, retained, limitBytes);
+    }
+
+    /// <summary>
+    /// The item a cache file belongs to: the catalog's owner if known, otherwise
+    /// its folder plus the file name up to the version.
+    /// </summary>
+    internal string OwnerOf(string path)
+    {
+        if (_owners.TryGetValue(path, out var owner))
+        {
+            return owner;
+        }
+
+        var stem = Path.GetFileNameWithoutExtension(path);
+        var match = VersionSuffix.Match(stem);
+        if (match.Success)
+        {
+            stem = match.Groups["stem"].Value;
+        }
+        var folder = Path.GetRelativePath(_config.CachePath, Path.GetDirectoryName(path) ?? _config.CachePath);
+        return folder == "." ? stem.ToLowerInvariant() : $"{folder}/{stem}".ToLowerInvariant();
+    }
+}
+
+/// <summary>A complete payload in the download cache.</summary>
+public sealed record CachedPayload(string Path, string ItemKey, long Size, DateTime DownloadedUtc, DateTime LastUsedUtc);
+
+/// <summary>
+/// Outcome of <see cref="CacheManager.PlanEviction"/>: the candidates in
+/// eviction order and the payloads kept back for rollback.
+/// </summary>
+public sealed record CacheEvictionPlan(
+    IReadOnlyList<CachedPayload> Payloads,
+    IReadOnlyList<CachedPayload> Candidates,
+    IReadOnlyList<CachedPayload> Retained,
+    long LimitBytes)
+{
+    public long TotalBytes => Payloads.Sum(p => p.Size);
+    public long EvictableBytes => Candidates.Sum(p => p.Size);
+    public long BytesAfterEviction => TotalBytes - EvictableBytes;
+}
@@ -0,0 +1,126 @@
+using System.Text.Json;
+using Cimian.Core;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Replay protection for catalogs. makecatalogs stamps every catalog with a
+/// monotonically increasing <c>generation</c>; the client remembers the highest
+/// generation it has acted on per catalog and refuses anything older, so an
+/// attacker (or a stale mirror) serving last month's catalog can't roll the
+/// fleet back onto a vulnerable version.
+///
+/// Legacy catalogs without a generation are accepted until a generation has been
+/// seen for that catalog; after that, a catalog that drops the field is treated
+/// as a downgrade. AllowCatalogDowngrade: true in Config.yaml disables the check
+/// (e.g. deliberately restoring an older repo snapshot) and re-baselines the state.
+/// </summary>
+public class CatalogGenerationGuard
+{
+    private static readonly string DefaultStatePath =
+        Path.Combine(CimianPaths.ManagedInstallsRoot, "catalog_generations.json");
+
+    private readonly string _statePath;
+    private readonly bool _allowDowngrade;
+    private readonly Dictionary<string, long> _generations;
+
+    public CatalogGenerationGuard(bool allowDowngrade)
+        : this(DefaultStatePath, allowDowngrade)
+    {
+    }
+
+    internal CatalogGenerationGuard(string statePath, bool allowDowngrade)
+    {
+        _statePath = statePath;
+        _allowDowngrade = allowDowngrade;
+        _generations = Load(statePath);
+    }
+
+    /// <summary>
+    /// Last generation accepted for the catalog, or null if none recorded.
+    /// </summary>
+    public long? LastSeen(string catalogName) =>
+        _generations.TryGetValue(catalogName.ToLowerInvariant(), out var g) ? g : null;
+
+    /// <summary>
+    /// Decides whether a freshly downloaded catalog may be used. Returns false with
+    /// a reason when it is older than (or drops the generation of) the last one
+    /// accepted. Equal generations are fine — that's just an unchanged catalog.
+    /// </summary>
+    public bool IsAcceptable(string catalogName, long? generation, out string reason)
+    {
+        reason = string.Empty;
+        var lastSeen = LastSeen(catalogName);
+
+        if (lastSeen == null || _allowDowngrade)
+        {
+            return true;
+        }
+
+        if (generation == null)
+        {
+            reason = $"catalog {catalogName} has no generation but generation {lastSeen} was previously accepted";
+            return false;
+        }
+
+        if (generation < lastSeen)
+        {
+            reason = $"catalog {catalogName} generation {generation} is older than last accepted {lastSeen}";
+            return false;
+        }
+
+        return true;
+    }
+
+    /// <summary>
+    /// Records an accepted catalog generation. With AllowCatalogDowngrade the stored
+    /// value follows the catalog down so normal protection resumes from there.
+    /// </summary>
+    public void Record(string catalogName, long? generation)
+    {
+        if (generation == null) return;
+
+        var key = catalogName.ToLowerInvariant();
+        if (_generations.TryGetValue(key, out var existing) && existing == generation) return;
+        if (!_allowDowngrade && existing > generation) return;
+
+        _generations[key] = generation.Value;
+        Save();
+    }
+
+    private static Dictionary<string, long> Load(string path)
+    {
+        try
+        {
+            if (File.Exists(path))
+            {
+                var loaded = JsonSerializer.Deserialize<Dictionary<string, long>>(File.ReadAllText(path));
+                if (loaded != null)
+                {
+                    return new Dictionary<string, long>(loaded, StringComparer.OrdinalIgnoreCase);
+                }
+            }
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Warn($"Could not read catalog generation state {path}: {ex.Message}");
+        }
+
+        return new Dictionary<string, long>(StringComparer.OrdinalIgnoreCase);
+    }
+
+    private void Save()
+    {
+        try
+        {
+            // Write-then-move so a crash mid-write can't zero the state and
+            // silently disarm the guard on the next run.
+            JsonStateFile.Write(_statePath, _generations);
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Warn($"Could not persist catalog generation state: {ex.Message}");
+        }
+    }
+}
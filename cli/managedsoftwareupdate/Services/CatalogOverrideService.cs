@@ -0,0 +1,170 @@
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using Cimian.Core;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+public class CatalogOverride
+{
+    [JsonPropertyName("catalogs")]
+    public List<string> Catalogs { get; set; } = new();
+
+    [JsonPropertyName("runs_remaining")]
+    public int RunsRemaining { get; set; }
+
+    [JsonPropertyName("runs_total")]
+    public int RunsTotal { get; set; }
+
+    [JsonPropertyName("set_by")]
+    public string SetBy { get; set; } = "";
+
+    [JsonPropertyName("set_at")]
+    public DateTime SetAt { get; set; }
+
+    /// <summary>"Testing, Production (2 of 3 runs left, set by CONTOSO\helpdesk)".</summary>
+    public string Describe() =>
+        $"{string.Join(", ", Catalogs)} ({RunsRemaining} of {RunsTotal} runs left, set by {SetBy})";
+}
+
+/// <summary>
+/// Temporary catalog list set with --override-catalogs so helpdesk can point one
+/// machine at a testing catalog without touching its manifest or Config.yaml.
+/// Kept in <see cref="CimianPaths.CatalogOverrideJson"/>; each run that uses it
+/// spends one run and the last one removes it. Every change is appended to
+/// <see cref="CimianPaths.CatalogOverrideAuditLog"/>.
+/// </summary>
+public class CatalogOverrideService
+{
+    public const int DefaultRuns = 3;
+
+    private readonly string _path;
+    private readonly string _auditPath;
+
+    public CatalogOverrideService(string? path = null, string? auditPath = null)
+    {
+        _path = path ?? CimianPaths.CatalogOverrideJson;
+        _auditPath = auditPath ?? CimianPaths.CatalogOverrideAuditLog;
+    }
+
+    public CatalogOverride Set(IEnumerable<string> catalogs, int runs, string setBy, DateTime now)
+    {
+        var entry = new CatalogOverride
+        {
+            Catalogs = catalogs.Select(c => c.Trim()).Where(c => c.Length > 0).Distinct(StringComparer.OrdinalIgnoreCase).ToList(),
+            RunsRemaining = runs,
+            RunsTotal = runs,
+            SetBy = setBy,
+            SetAt = now
+        };
+        if (entry.Catalogs.Count == 0)
+        {
+            throw new ArgumentException("at least one catalog is required", nameof(catalogs));
+        }
+        if (runs < 1)
+        {
+            throw new ArgumentOutOfRangeException(nameof(runs), "the override must last at least one run");
+        }
+
+        Save(entry);
+        Audit(now, "set", $"catalogs={string.Join(",", entry.Catalogs)} runs={runs} by={setBy}");
+        return entry;
+    }
+
+    public CatalogOverride? Get()
+    {
+        try
+        {
+            if (!File.Exists(_path))
+            {
+                return null;
+            }
+            var entry = JsonSerializer.Deserialize<CatalogOverride>(File.ReadAllText(_path));
+            return entry is { Catalogs.Count: > 0, RunsRemaining: > 0 } ? entry : null;
+        }
+        catch (Exception ex) when (ex is IOException or JsonException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not read catalog override: {ex.Message}");
+            return null;
+        }
+    }
+
+    /// <summary>
+    /// Spends one run of the active override and returns it as it stood for this
+    /// run, or null when there is none. The run that spends the last one removes it.
+    /// </summary>
+    public CatalogOverride? Consume(string runType, DateTime now)
+    {
+        var entry = Get();
+        if (entry == null)
+        {
+            return null;
+        }
+
+        var remaining = entry.RunsRemaining - 1;
+        Audit(now, "used", $"catalogs={string.Join(",", entry.Catalogs)} run_type={runType} runs_left={remaining}");
+        if (remaining > 0)
+        {
+            Save(new CatalogOverride
+            {
+                Catalogs = entry.Catalogs,
+                RunsRemaining = remaining,
+                RunsTotal = entry.RunsTotal,
+                SetBy = entry.SetBy,
+                SetAt = entry.SetAt
+            });
+        }
+        else
+        {
+            Delete();
+            Audit(now, "expired", $"catalogs={string.Join(",", entry.Catalogs)} after {entry.RunsTotal} run(s)");
+        }
+        return entry;
+    }
+
+    public bool Clear(string clearedBy, DateTime now)
+    {
+        var entry = Get();
+        Delete();
+        if (entry == null)
+        {
+            return false;
+        }
+        Audit(now, "cleared", $"catalogs={string.Join(",", entry.Catalogs)} runs_left={entry.RunsRemaining} by={clearedBy}");
+        return true;
+    }
+
+    private void Save(CatalogOverride entry)
+    {
+        JsonStateFile.Write(_path, entry);
+    }
+
+    private void Delete()
+    {
+        try
+        {
+            File.Delete(_path);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not remove catalog override: {ex.Message}");
+        }
+    }
+
+    private void Audit(DateTime now, string action, string detail)
+    {
+        try
+        {
+            var dir = Path.GetDirectoryName(_auditPath);
+            if (!string.IsNullOrEmpty(dir))
+            {
+                Directory.CreateDirectory(dir);
+            }
+            File.AppendAllText(_auditPath, $"{now:yyyy-MM-dd HH:mm:ss} {action} {detail}{Environment.NewLine}");
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not write catalog override audit log: {ex.Message}");
+        }
+    }
+}
@@ -1,12 +1,23 @@
+using System.Net;
 using System.Net.Http.Headers;
+using System.Runtime.InteropServices;
 using System.Text;
 using YamlDotNet.Serialization;
 using YamlDotNet.Serialization.NamingConventions;
 using Cimian.CLI.managedsoftwareupdate.Models;
 using Cimian.Core.Services;
+using Cimian.Core.Version;
 
 namespace Cimian.CLI.managedsoftwareupdate.Services;
 
+/// <summary>How a catalog item runs on this machine's architecture, worst to best.</summary>
+public enum ArchitectureMatch
+{
+    Unsupported,
+    Emulated,
+    Native
+}
+
 /// <summary>
 /// Service for loading and managing catalogs
 /// Migrated from Go pkg/catalog
@@ -15,41 +26,81 @@ public class CatalogService
 {
     private readonly HttpClient _httpClient;
     private readonly CimianConfig _config;
+    private readonly CatalogGenerationGuard _generationGuard;
+    private readonly HttpValidatorStore _validators;
+    private readonly MetadataVerifier _metadataVerifier;
+    private readonly Dictionary<string, string> _aliases = new(ItemKey.Comparer);
+    private readonly List<string> _offlineCatalogs = new();
 
-    public CatalogService(CimianConfig config, HttpClient? httpClient = null)
+    public CatalogService(CimianConfig config, HttpClient? httpClient = null, CatalogGenerationGuard? generationGuard = null)
     {
         _config = config;
-        _httpClient = httpClient ?? CimianHttpClientFactory.CreateHttpClient(config);
+        _httpClient = httpClient ?? CimianHttpClientFactory.CreateHttpClient(config, acceptCompressed: true);
+        _generationGuard = generationGuard ?? new CatalogGenerationGuard(config.AllowCatalogDowngrade);
+        _validators = new HttpValidatorStore(config.CatalogsPath);
+        _metadataVerifier = new MetadataVerifier(config, _httpClient);
     }
 
+    /// <summary>
+    /// Whether accepted catalog generations are remembered for replay protection.
+    /// Off for --checkonly and --dry-run: a run that doesn't act on the catalog,
+    /// possibly against a test repo, mustn't make the real repo look like a
+    /// downgrade. Older catalogs are still refused either way.
+    /// </summary>
+    public bool RecordGenerations { get; set; } = true;
+
+    /// <summary>
+    /// Catalogs read from the local copy because the repo couldn't serve them.
+    /// Non-empty means this run is degraded.
+    /// </summary>
+    public IReadOnlyList<string> OfflineCatalogs => _offlineCatalogs;
+
     /// <summary>
     /// Downloads and loads all configured catalogs
     /// </summary>
     public async Task<Dictionary<string, CatalogItem>> LoadCatalogsAsync()
     {
-        var items = new Dictionary<string, CatalogItem>(StringComparer.OrdinalIgnoreCase);
+        var items = new Dictionary<string, CatalogItem>(ItemKey.Comparer);
         var catalogs = _config.Catalogs.Count > 0 ? _config.Catalogs : new List<string> { "Production" };
         var sysArch = GetSystemArchitecture();
+        var allowEmulation = !_config.ForbidEmulatedInstalls;
         ConsoleLogger.Info($"    Loading catalogs catalogCount: {catalogs.Count} systemArch: {sysArch}");
 
         foreach (var catalogName in catalogs)
         {
             ConsoleLogger.Info($"    Downloading catalog: {catalogName}");
-            var catalogItems = await DownloadCatalogAsync(catalogName);
+            List<CatalogItem> catalogItems;
+            using (DiagnosticTrace.Begin("catalog", catalogName))
+            {
+                catalogItems = await DownloadCatalogAsync(catalogName);
+            }
             ConsoleLogger.Info($"    Downloaded catalog: {catalogName} itemCount: {catalogItems.Count}");
             foreach (var item in catalogItems)
             {
                 // Filter by architecture first (Go parity)
-                if (!SupportsArchitecture(item, sysArch))
+                if (!SupportsArchitecture(item, sysArch, allowEmulation))
                 {
                     ConsoleLogger.Debug($"Skipping item (arch mismatch) item: {item.Name} arch: {string.Join(",", item.SupportedArch ?? new List<string>())} sysArch: {sysArch}");
                     continue;
                 }
+
+                // Cimian builds from another self-update channel must not win the
+                // highest-version pick below
+                if (StatusService.IsOutsideSelfUpdateChannel(item, _config.SelfUpdateChannel))
+                {
+                    ConsoleLogger.Debug($"Skipping item (self-update channel) item: {item.Name} version: {item.Version} channel: {item.SelfUpdateChannel} configured: {_config.SelfUpdateChannel}");
+                    continue;
+                }
+                if (StatusService.IsHeldBackSelfUpdate(item))
+                {
+                    ConsoleLogger.Debug($"Skipping item (self-update rollout) item: {item.Name} version: {item.Version} rollout: {item.SelfUpdateRolloutPercent?.ToString() ?? "100"}% bucket: {StatusService.RolloutBucket}");
+                    continue;
+                }
                 
-                var key = item.Name.ToLowerInvariant();
-                // Keep highest version if duplicate
-                if (!items.ContainsKey(key) || 
-                    CompareVersions(item.Version, items[key].Version) > 0)
+                var key = ItemKey.Canonical(item.Name);
+                // Keep the native build over an emulated one, then the highest version
+                if (!items.ContainsKey(key) ||
+                    IsPreferredBuild(item, items[key], sysArch, allowEmulation))
                 {
                     if (items.ContainsKey(key))
                     {
@@ -59,6 +110,7 @@ public class CatalogService
                     {
                         ConsoleLogger.Debug($"Added catalog item name: {item.Name} version: {item.Version} arch: {string.Join(" ", item.SupportedArch ?? new List<string>())}");
                     }
+                    item.SourceCatalog = catalogName;
                     items[key] = item;
                 }
                 else
@@ -68,13 +120,53 @@ public class CatalogService
             }
         }
 
+        foreach (var item in items.Values.Where(i => MatchArchitecture(i, sysArch, allowEmulation) == ArchitectureMatch.Emulated))
+        {
+            ConsoleLogger.Detail($"    No {sysArch} build of {item.Name}; using {string.Join("/", item.SupportedArch)} {item.Version} under emulation");
+        }
+
+        PruneUnassignedCatalogs(catalogs);
+        ApplyAliases(items);
         return items;
     }
 
+    /// <summary>
+    /// Local copies are kept between runs for conditional GETs and offline
+    /// fallback; a catalog no longer assigned to this machine is removed so it
+    /// can't resurface through <see cref="LoadLocalCatalogItems"/>.
+    /// </summary>
+    private void PruneUnassignedCatalogs(List<string> catalogs)
+    {
+        if (!Directory.Exists(_config.CatalogsPath))
+        {
+            return;
+        }
+
+        var assigned = new HashSet<string>(catalogs, StringComparer.OrdinalIgnoreCase);
+        foreach (var file in Directory.GetFiles(_config.CatalogsPath, "*.yaml"))
+        {
+            if (assigned.Contains(Path.GetFileNameWithoutExtension(file)))
+            {
+                continue;
+            }
+            try
+            {
+                File.Delete(file);
+                ConsoleLogger.Debug($"Removed unassigned local catalog: {file}");
+            }
+            catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+            {
+                ConsoleLogger.Debug($"Could not remove unassigned local catalog {file}: {ex.Message}");
+            }
+        }
+    }
+
     /// <summary>
     /// Downloads a specific catalog from the server
     /// </summary>
-    public async Task<List<CatalogItem>> DownloadCatalogAsync(string catalogName)
+    public Task<List<CatalogItem>> DownloadCatalogAsync(string catalogName) => DownloadCatalogAsync(catalogName, attempt: 1);
+
+    private async Task<List<CatalogItem>> DownloadCatalogAsync(string catalogName, int attempt)
     {
         var items = new List<CatalogItem>();
         var catalogUrl = $"{_config.SoftwareRepoURL.TrimEnd('/')}/catalogs/{catalogName}.yaml";
@@ -83,44 +175,121 @@ public class CatalogService
 
         try
         {
-            var response = await _httpClient.GetAsync(catalogUrl);
-            if (response.IsSuccessStatusCode)
+            using var request = new HttpRequestMessage(HttpMethod.Get, catalogUrl);
+            _validators.ApplyTo(request, localPath);
+            using var response = await _httpClient.SendAsync(request);
+            var notModified = response.StatusCode == HttpStatusCode.NotModified && File.Exists(localPath);
+            if (response.IsSuccessStatusCode || notModified)
             {
-                var content = await response.Content.ReadAsStringAsync();
-                ConsoleLogger.Debug($"Download completed to temp file tempFile: {localPath}.downloading size: {content.Length}");
+                string content;
+                if (notModified)
+                {
+                    content = await File.ReadAllTextAsync(localPath);
+                    OfflineCache.Touch(localPath);
+                    ConsoleLogger.Debug($"Catalog unchanged (304), using local copy: {localPath}");
+                }
+                else
+                {
+                    content = await response.Content.ReadAsStringAsync();
+                    ConsoleLogger.Debug($"Download completed to temp file tempFile: {localPath}.downloading size: {content.Length}");
+
+                    // A mirror serving a truncated or garbled catalog is demoted and
+                    // the next one asked, before the bad copy overwrites the cache
+                    if (!IsParseableCatalog(content)
+                        && attempt < RepoMirrorSet.For(_config).Mirrors.Count
+                        && RepoMirrorSet.For(_config).ReportBadContent(catalogUrl, "unparseable catalog"))
+                    {
+                        return await DownloadCatalogAsync(catalogName, attempt + 1);
+                    }
+                }
+
+                // Signed metadata: like a downgrade, refused content is neither
+                // saved nor returned
+                var trusted = notModified
+                    ? await _metadataVerifier.VerifyUnchangedAsync("catalog", catalogName, catalogUrl, content, localPath)
+                    : await _metadataVerifier.VerifyDownloadedAsync("catalog", catalogName, catalogUrl, content, localPath);
+                if (!trusted)
+                {
+                    return items;
+                }
+
+                // Replay protection: refuse a catalog older than the last one we
+                // acted on. Nothing is saved or returned, so the run can't act on it.
+                var generation = ReadCatalogGeneration(content);
+                if (!_generationGuard.IsAcceptable(catalogName, generation, out var downgradeReason))
+                {
+                    ConsoleLogger.Error($"Refusing catalog downgrade: {downgradeReason} (set AllowCatalogDowngrade: true to override)");
+                    return items;
+                }
                 
                 // Save locally
-                var dir = Path.GetDirectoryName(localPath);
-                if (!string.IsNullOrEmpty(dir))
+                if (!notModified)
                 {
-                    Directory.CreateDirectory(dir);
+                    var dir = Path.GetDirectoryName(localPath);
+                    if (!string.IsNullOrEmpty(dir))
+                    {
+                        Directory.CreateDirectory(dir);
+                    }
+                    await File.WriteAllTextAsync(localPath, content);
+                    _validators.Record(catalogUrl, response);
+                    ConsoleLogger.Debug($"File saved successfully file: {localPath} size: {content.Length}");
+                    ConsoleLogger.Debug($"Download completed successfully file: {localPath}");
+                    ConsoleLogger.Debug($"Downloaded catalog: {catalogName}");
                 }
-                await File.WriteAllTextAsync(localPath, content);
-                ConsoleLogger.Debug($"File saved successfully file: {localPath} size: {content.Length}");
-                ConsoleLogger.Debug($"Download completed successfully file: {localPath}");
-                ConsoleLogger.Debug($"Downloaded catalog: {catalogName}");
 
-                items = ParseCatalog(content);
+                using (DiagnosticTrace.Begin("parse", catalogName))
+                {
+                    items = ParseCatalog(content);
+                }
+                if (RecordGenerations)
+                {
+                    _generationGuard.Record(catalogName, generation);
+                }
             }
             else
             {
                 ConsoleLogger.Warn($"Failed to download catalog {catalogName}: {response.StatusCode}");
-                // Try to load from local cache
-                ConsoleLogger.Info($"    Falling back to local cache: {localPath}");
-                items = LoadLocalCatalog(localPath);
+                items = LoadFallbackCatalog(catalogName, localPath);
             }
         }
         catch (Exception ex)
         {
             ConsoleLogger.Warn($"Error downloading catalog {catalogName}: {ex.Message}");
-            // Try to load from local cache
-            ConsoleLogger.Info($"    Falling back to local cache: {localPath}");
-            items = LoadLocalCatalog(localPath);
+            items = LoadFallbackCatalog(catalogName, localPath);
         }
 
         return items;
     }
 
+    /// <summary>
+    /// The local copy of a catalog the repo failed to serve. With
+    /// OfflineCacheMaxAgeHours set, a copy older than that is not used.
+    /// </summary>
+    private List<CatalogItem> LoadFallbackCatalog(string catalogName, string localPath)
+    {
+        if (_config.OfflineCacheMaxAgeHours > 0 &&
+            !OfflineCache.IsUsable(localPath, _config.OfflineCacheMaxAgeHours, DateTime.UtcNow, out var age) &&
+            age is { } stale)
+        {
+            ConsoleLogger.Warn($"    Cached catalog {catalogName} is {OfflineCache.DescribeAge(stale)} old, past OfflineCacheMaxAgeHours ({_config.OfflineCacheMaxAgeHours}h); not using it");
+            return new List<CatalogItem>();
+        }
+
+        if (_metadataVerifier.Enabled && File.Exists(localPath)
+            && !_metadataVerifier.VerifyLocal("catalog", catalogName, File.ReadAllText(localPath), localPath))
+        {
+            return new List<CatalogItem>();
+        }
+
+        ConsoleLogger.Info($"    Falling back to local cache: {localPath}");
+        var items = LoadLocalCatalog(localPath);
+        if (items.Count > 0)
+        {
+            _offlineCatalogs.Add(catalogName);
+        }
+        return items;
+    }
+
     /// <summary>
     /// Loads catalog from local file
     /// </summary>
@@ -152,7 +321,7 @@ public class CatalogService
     /// </summary>
     public Dictionary<string, CatalogItem> LoadLocalCatalogItems()
     {
-        var items = new Dictionary<string, CatalogItem>(StringComparer.OrdinalIgnoreCase);
+        var items = new Dictionary<string, CatalogItem>(ItemKey.Comparer);
         var catalogsPath = _config.CatalogsPath;
 
         if (!Directory.Exists(catalogsPath))
@@ -161,6 +330,7 @@ public class CatalogService
         }
 
         var sysArch = GetSystemArchitecture();
+        var allowEmulation = !_config.ForbidEmulatedInstalls;
 
         foreach (var file in Directory.GetFiles(catalogsPath, "*.yaml"))
         {
@@ -168,24 +338,70 @@ public class CatalogService
             foreach (var item in catalogItems)
             {
                 // Filter by architecture
-                if (!SupportsArchitecture(item, sysArch))
+                if (!SupportsArchitecture(item, sysArch, allowEmulation) ||
+                    StatusService.IsOutsideSelfUpdateChannel(item, _config.SelfUpdateChannel) ||
+                    StatusService.IsHeldBackSelfUpdate(item))
                 {
                     continue;
                 }
 
-                var key = item.Name.ToLowerInvariant();
-                // Go parity: Keep highest version (Go uses DeduplicateCatalogItems which picks highest version)
-                if (!items.ContainsKey(key) || 
-                    CompareVersions(item.Version, items[key].Version) > 0)
+                var key = ItemKey.Canonical(item.Name);
+                // Go parity: Keep highest version (Go uses DeduplicateCatalogItems which picks highest version),
+                // after preferring a native build over an emulated one
+                if (!items.ContainsKey(key) ||
+                    IsPreferredBuild(item, items[key], sysArch, allowEmulation))
                 {
+                    item.SourceCatalog = Path.GetFileNameWithoutExtension(file);
                     items[key] = item;
                 }
             }
         }
 
+        ApplyAliases(items);
         return items;
     }
 
+    /// <summary>
+    /// Reads the top-level <c>generation</c> stamped by makecatalogs, or null for
+    /// legacy catalogs (and plain item lists) that predate it.
+    /// </summary>
+    internal static long? ReadCatalogGeneration(string yaml)
+    {
+        foreach (var line in yaml.Split('\n').Take(20))
+        {
+            var trimmed = line.TrimEnd('\r');
+            if (trimmed.StartsWith("generation:", StringComparison.Ordinal) &&
+                long.TryParse(trimmed["generation:".Length..].Trim().Trim('"', '\''), out var value))
+            {
+                return value;
+            }
+        }
+        return null;
+    }
+
+    /// <summary>
+    /// True when <paramref name="yaml"/> deserializes as a catalog, either the
+    /// items wrapper or a plain item list.
+    /// </summary>
+    internal static bool IsParseableCatalog(string yaml)
+    {
+        try
+        {
+            return YamlUtils.Deserializer.Deserialize<CatalogWrapper>(yaml)?.Items != null;
+        }
+        catch
+        {
+            try
+            {
+                return YamlUtils.Deserializer.Deserialize<List<CatalogItem>>(yaml) != null;
+            }
+            catch
+            {
+                return false;
+            }
+        }
+    }
+
     private List<CatalogItem> ParseCatalog(string yaml)
     {
         try
@@ -211,163 +427,177 @@ public class CatalogService
     }
 
     /// <summary>
-    /// Gets the system architecture
+    /// The architecture Windows itself runs on, not this process's: an x64
+    /// build of Cimian under emulation on an ARM64 machine still reports arm64.
     /// </summary>
     public static string GetSystemArchitecture()
     {
-        var arch = Environment.GetEnvironmentVariable("PROCESSOR_ARCHITECTURE");
-        return arch?.ToLowerInvariant() switch
+        return RuntimeInformation.OSArchitecture switch
         {
-            "amd64" => "x64",
-            "x86" => "x86",
-            "arm64" => "arm64",
-            "arm" => "arm",
+            Architecture.X64 => "x64",
+            Architecture.X86 => "x86",
+            Architecture.Arm64 => "arm64",
+            Architecture.Arm => "arm",
             _ => "x64"
         };
     }
 
     /// <summary>
-    /// Checks if an item supports the given architecture
+    /// How an item runs on a machine of <paramref name="architecture"/>: natively
+    /// when its supported_architectures name it (or don't restrict it at all),
+    /// emulated when they only name an architecture Windows emulates there (x64
+    /// or x86 code on ARM64), otherwise not at all. With
+    /// <paramref name="allowEmulation"/> off, emulated items are unsupported.
     /// </summary>
-    public static bool SupportsArchitecture(CatalogItem item, string architecture)
+    public static ArchitectureMatch MatchArchitecture(CatalogItem item, string architecture, bool allowEmulation = true)
     {
         if (item.SupportedArch.Count == 0)
         {
-            return true; // No restriction means all architectures
+            return ArchitectureMatch.Native; // No restriction means all architectures
         }
 
-        var normalizedArch = architecture.ToLowerInvariant() switch
+        var systemArch = NormalizeArchitecture(architecture);
+        var supported = item.SupportedArch.Select(NormalizeArchitecture).ToList();
+        if (supported.Contains(systemArch))
         {
-            "amd64" => "x64",
-            "x86_64" => "x64",
-            _ => architecture.ToLowerInvariant()
-        };
-
-        return item.SupportedArch.Any(a => 
-            a.Equals(normalizedArch, StringComparison.OrdinalIgnoreCase) ||
-            a.Equals("amd64", StringComparison.OrdinalIgnoreCase) && normalizedArch == "x64" ||
-            a.Equals("x86_64", StringComparison.OrdinalIgnoreCase) && normalizedArch == "x64");
+            return ArchitectureMatch.Native;
+        }
+        if (allowEmulation && EmulatedArchitectures(systemArch).Any(supported.Contains))
+        {
+            return ArchitectureMatch.Emulated;
+        }
+        return ArchitectureMatch.Unsupported;
     }
 
     /// <summary>
-    /// Compares two version strings
-    /// Returns: -1 if v1 < v2, 0 if v1 == v2, 1 if v1 > v2
-    /// Normalizes version strings before comparison to handle formats like:
-    ///   "5.2.3 (git 68d178c)" → "5.2.3"
-    ///   "2025, 0, 408, 54890" → "2025.0.408.54890"
-    ///   "InternalName" → unparseable, returns 0 (equal) to avoid false positives
+    /// Checks if an item can be installed on the given architecture, natively or
+    /// (unless <paramref name="allowEmulation"/> is off) under emulation.
     /// </summary>
-    public static int CompareVersions(string v1, string v2)
-    {
-        if (v1 == v2) return 0;
-        if (string.IsNullOrEmpty(v1)) return -1;
-        if (string.IsNullOrEmpty(v2)) return 1;
-
-        // Normalize both versions before comparison
-        var norm1 = NormalizeVersionString(v1);
-        var norm2 = NormalizeVersionString(v2);
+    public static bool SupportsArchitecture(CatalogItem item, string architecture, bool allowEmulation = true) =>
+        MatchArchitecture(item, architecture, allowEmulation) != ArchitectureMatch.Unsupported;
 
-        // If either version normalized to empty/null, it was unparseable — treat as equal
-        if (string.IsNullOrEmpty(norm1) || string.IsNullOrEmpty(norm2))
-            return 0;
-
-        // Quick equality check after normalization
-        if (norm1 == norm2) return 0;
-
-        var parts1 = norm1.Split('.');
-        var parts2 = norm2.Split('.');
-
-        var maxLen = Math.Max(parts1.Length, parts2.Length);
-
-        for (int i = 0; i < maxLen; i++)
+    /// <summary>
+    /// True when <paramref name="candidate"/> should replace <paramref name="current"/>
+    /// as the catalog entry for their name: a native build beats an emulated one
+    /// whatever their versions, so ARM64 machines only fall back to x64 when the
+    /// repo has no arm64 build; between equals the higher version wins.
+    /// </summary>
+    public static bool IsPreferredBuild(CatalogItem candidate, CatalogItem current, string architecture, bool allowEmulation = true)
+    {
+        var candidateMatch = MatchArchitecture(candidate, architecture, allowEmulation);
+        var currentMatch = MatchArchitecture(current, architecture, allowEmulation);
+        if (candidateMatch != currentMatch)
         {
-            var p1 = i < parts1.Length ? ParseVersionPart(parts1[i]) : 0;
-            var p2 = i < parts2.Length ? ParseVersionPart(parts2[i]) : 0;
-
-            if (p1 < p2) return -1;
-            if (p1 > p2) return 1;
+            return candidateMatch > currentMatch;
         }
-
-        return 0;
+        return CompareVersions(candidate.Version, current.Version) > 0;
     }
 
     /// <summary>
-    /// Normalizes a version string to a canonical dot-separated numeric format.
-    /// Handles: parenthetical metadata, comma-separated versions, non-version strings.
-    /// Returns null if the string is not a recognizable version.
+    /// Lower-case architecture name with the usual aliases folded
+    /// (amd64/x86_64 to x64, aarch64 to arm64).
     /// </summary>
-    private static string? NormalizeVersionString(string version)
+    public static string NormalizeArchitecture(string architecture) => architecture.Trim().ToLowerInvariant() switch
     {
-        if (string.IsNullOrWhiteSpace(version))
-            return null;
+        "amd64" or "x86_64" => "x64",
+        "aarch64" => "arm64",
+        var arch => arch
+    };
 
-        var v = version.Trim();
+    // Windows 11 on ARM emulates x64 and x86 code; nothing else emulates another architecture
+    private static string[] EmulatedArchitectures(string systemArch) =>
+        systemArch == "arm64" ? ["x64", "x86"] : [];
 
-        // Strip everything from first parenthesis onward: "5.2.3 (git 68d178c)" → "5.2.3"
-        var parenIndex = v.IndexOf('(');
-        if (parenIndex > 0)
-            v = v[..parenIndex].Trim();
+    /// <summary>
+    /// Compares two version strings: -1 if v1 &lt; v2, 0 if equal, 1 if v1 &gt; v2.
+    /// Delegates to <see cref="VersionService.CompareVersions"/>, whose parsing
+    /// rules every Cimian tool shares, so catalog deduplication and install
+    /// status agree with makecatalogs and cimiimport on which version is newer.
+    /// </summary>
+    public static int CompareVersions(string v1, string v2) => VersionService.CompareVersions(v1, v2);
 
-        // Replace comma+optional space with dot: "2025, 0, 408, 54890" → "2025.0.408.54890"
-        v = System.Text.RegularExpressions.Regex.Replace(v, @",\s*", ".");
+    /// <summary>
+    /// Finds a catalog item by name
+    /// </summary>
+    public CatalogItem? FindItem(Dictionary<string, CatalogItem> catalog, string name)
+    {
+        var key = ItemKey.Canonical(ResolveAlias(name));
+        return catalog.TryGetValue(key, out var item) ? item : null;
+    }
+
+    /// <summary>
+    /// Current name for an item referenced by one of its pkginfo aliases, or the
+    /// name unchanged. Lets manifests that still list a renamed item keep working
+    /// while they're migrated.
+    /// </summary>
+    public string ResolveAlias(string name) =>
+        _aliases.TryGetValue(name, out var target) ? target : name;
 
-        // Now split and validate — each part should be numeric or a known prerelease tag
-        var parts = v.Split('.', '-', '_');
-        var validParts = new System.Collections.Generic.List<string>();
-        foreach (var part in parts)
+    /// <summary>
+    /// Every alias currently mapped, keyed on the old name.
+    /// </summary>
+    public IReadOnlyDictionary<string, string> Aliases => _aliases;
+
+    /// <summary>
+    /// Indexes the <c>aliases</c> of the loaded items and points requires/update_for
+    /// references at the current names. Aliases stay out of the catalog map itself
+    /// so anything enumerating it sees each item once; an alias that collides with
+    /// a real item name is ignored because the real item always wins.
+    /// </summary>
+    private void ApplyAliases(Dictionary<string, CatalogItem> items)
+    {
+        _aliases.Clear();
+        foreach (var item in items.Values)
         {
-            var trimmed = part.Trim();
-            if (string.IsNullOrEmpty(trimmed))
-                continue;
-            // Accept numeric parts
-            if (int.TryParse(trimmed, out _))
+            foreach (var alias in item.Aliases ?? new List<string>())
             {
-                validParts.Add(trimmed);
-                continue;
-            }
-            // Accept known prerelease tags
-            var lower = trimmed.ToLowerInvariant();
-            if (lower.StartsWith("alpha") || lower.StartsWith("beta") || lower.StartsWith("rc") || lower.StartsWith("release"))
-            {
-                validParts.Add(trimmed);
-                continue;
+                if (string.IsNullOrWhiteSpace(alias) || ItemKey.Comparer.Equals(alias, item.Name))
+                {
+                    continue;
+                }
+                if (items.ContainsKey(alias))
+                {
+                    ConsoleLogger.Warn($"Ignoring alias {alias} on {item.Name}: an item with that name is in the catalog");
+                    continue;
+                }
+                if (_aliases.TryGetValue(alias, out var existing) && !ItemKey.Comparer.Equals(existing, item.Name))
+                {
+                    ConsoleLogger.Warn($"Alias {alias} is claimed by both {existing} and {item.Name}; keeping {existing}");
+                    continue;
+                }
+                _aliases[alias] = item.Name;
             }
-            // Non-numeric, non-tag part — stop here (trailing metadata)
-            break;
         }
 
-        // If no valid parts found, this isn't a recognizable version
-        if (validParts.Count == 0)
-            return null;
-
-        return string.Join(".", validParts);
-    }
-
-    private static int ParseVersionPart(string part)
-    {
-        // Try to parse as integer
-        if (int.TryParse(part, out var value))
+        if (_aliases.Count == 0)
         {
-            return value;
+            return;
         }
 
-        // Handle prerelease tags
-        var lowerPart = part.ToLowerInvariant();
-        if (lowerPart.StartsWith("alpha")) return -3;
-        if (lowerPart.StartsWith("beta")) return -2;
-        if (lowerPart.StartsWith("rc")) return -1;
-        if (lowerPart.StartsWith("release")) return 0;
-
-        return 0;
+        foreach (var item in items.Values)
+        {
+            item.Requires = item.Requires?.Select(ResolveReference).ToList() ?? new List<string>();
+            item.UpdateFor = item.UpdateFor?.Select(ResolveReference).ToList() ?? new List<string>();
+        }
+        ConsoleLogger.Debug($"Loaded item aliases count: {_aliases.Count}");
     }
 
     /// <summary>
-    /// Finds a catalog item by name
+    /// Resolves the name part of a requires/update_for entry, keeping any
+    /// "-version" / "--version" suffix as written.
     /// </summary>
-    public CatalogItem? FindItem(Dictionary<string, CatalogItem> catalog, string name)
+    private string ResolveReference(string reference)
     {
-        var key = name.ToLowerInvariant();
-        return catalog.TryGetValue(key, out var item) ? item : null;
+        if (_aliases.ContainsKey(reference))
+        {
+            return ResolveAlias(reference);
+        }
+        var (name, version) = SplitNameAndVersion(reference);
+        if (!string.IsNullOrEmpty(version) && _aliases.TryGetValue(name, out var target))
+        {
+            return target + reference[name.Length..];
+        }
+        return reference;
     }
 
     /// <summary>
@@ -519,14 +749,20 @@ public class CatalogService
     /// </remarks>
     /// <param name="seedNames">Items whose dependency graph should be expanded.</param>
     /// <param name="catalog">Loaded catalog keyed by lowercase name.</param>
+    /// <param name="updateOnly">
+    /// When given, receives the deps reached only through <c>update_for</c> (nothing
+    /// in the closure requires them). Those are updates: offered only when something
+    /// they update is installed or being installed, see <see cref="HasUpdateTarget"/>.
+    /// </param>
     /// <returns>Dependency names not present in the seed set.</returns>
     public static List<string> BuildDependencyClosure(
         IEnumerable<string> seedNames,
-        Dictionary<string, CatalogItem> catalog)
+        Dictionary<string, CatalogItem> catalog,
+        ISet<string>? updateOnly = null)
     {
         var seeds = seedNames?.ToList() ?? new List<string>();
         var visited = new HashSet<string>(
-            seeds.Select(n => n.ToLowerInvariant()),
+            seeds.Select(ItemKey.Canonical),
             StringComparer.OrdinalIgnoreCase);
         var deps = new List<string>();
         var queue = new Queue<string>(seeds);
@@ -540,28 +776,30 @@ public class CatalogService
             var name = queue.Dequeue();
 
             // update_for direction: catalog items whose UpdateFor lists this name
-            if (updateForIndex.TryGetValue(name.ToLowerInvariant(), out var updaters))
+            if (updateForIndex.TryGetValue(ItemKey.Canonical(name), out var updaters))
             {
                 foreach (var updateName in updaters)
                 {
-                    if (visited.Add(updateName.ToLowerInvariant()))
+                    if (visited.Add(ItemKey.Canonical(updateName)))
                     {
                         deps.Add(updateName);
+                        updateOnly?.Add(updateName);
                         queue.Enqueue(updateName);
                     }
                 }
             }
 
             // requires direction: deps declared by this catalog item
-            if (catalog.TryGetValue(name.ToLowerInvariant(), out var item)
+            if (catalog.TryGetValue(ItemKey.Canonical(name), out var item)
                 && item.Requires != null)
             {
                 foreach (var reqEntry in item.Requires)
                 {
                     var (reqName, _) = SplitNameAndVersion(reqEntry);
                     if (string.IsNullOrEmpty(reqName)) continue;
-                    if (!catalog.TryGetValue(reqName.ToLowerInvariant(), out var depItem)) continue;
-                    if (visited.Add(depItem.Name.ToLowerInvariant()))
+                    if (!catalog.TryGetValue(ItemKey.Canonical(reqName), out var depItem)) continue;
+                    updateOnly?.Remove(depItem.Name);
+                    if (visited.Add(ItemKey.Canonical(depItem.Name)))
                     {
                         deps.Add(depItem.Name);
                         queue.Enqueue(depItem.Name);
@@ -573,6 +811,22 @@ public class CatalogService
         return deps;
     }
 
+    /// <summary>
+    /// Whether an <c>update_for</c> item has something to update: any of its targets
+    /// (version suffixes ignored) for which <paramref name="isPresent"/> is true.
+    /// Items without <c>update_for</c> always do.
+    /// </summary>
+    public static bool HasUpdateTarget(CatalogItem item, Func<string, bool> isPresent)
+    {
+        if (item.UpdateFor == null || item.UpdateFor.Count == 0)
+        {
+            return true;
+        }
+        return item.UpdateFor
+            .Select(target => SplitNameAndVersion(target).name)
+            .Any(name => !string.IsNullOrEmpty(name) && isPresent(name));
+    }
+
     private static Dictionary<string, List<string>> BuildUpdateForIndex(
         Dictionary<string, CatalogItem> catalog)
     {
@@ -584,7 +838,7 @@ public class CatalogService
             foreach (var target in item.UpdateFor)
             {
                 if (string.IsNullOrEmpty(target)) continue;
-                var key = target.ToLowerInvariant();
+                var key = ItemKey.Canonical(target);
                 if (!index.TryGetValue(key, out var list))
                 {
                     list = new List<string>();
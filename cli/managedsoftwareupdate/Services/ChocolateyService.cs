@@ -0,0 +1,188 @@
+using System.Diagnostics;
+using System.Globalization;
+using System.Text;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Chocolatey command lines for chocolatey-type installers: the feeds from
+/// ChocolateySources are registered with choco (credentials included) once per
+/// run, and every install names its sources explicitly with --source, so the
+/// machine's own source list plays no part. Pinned items are unpinned around
+/// Cimian's own upgrades and pinned again afterwards.
+/// </summary>
+public class ChocolateyService
+{
+    // Registration is per process: one run registers each source at most once
+    private static readonly HashSet<string> Registered = new(StringComparer.OrdinalIgnoreCase);
+
+    private readonly CimianConfig _config;
+
+    public ChocolateyService(CimianConfig config)
+    {
+        _config = config;
+    }
+
+    public static string ChocoExe => Path.Combine(
+        Environment.GetFolderPath(Environment.SpecialFolder.CommonApplicationData),
+        "chocolatey", "bin", "choco.exe");
+
+    /// <summary>The package id on the feed: installer.package_id, or the item name.</summary>
+    public static string PackageId(CatalogItem item) =>
+        string.IsNullOrWhiteSpace(item.Installer.PackageId) ? item.Name : item.Installer.PackageId;
+
+    /// <summary>
+    /// The sources an item installs from: those its installer.sources names, or
+    /// every configured source. Error names any source that isn't configured.
+    /// </summary>
+    public (List<ChocolateySource> Sources, string? Error) ResolveSources(CatalogItem item)
+    {
+        if (item.Installer.Sources.Count == 0)
+        {
+            return (_config.ChocolateySources.ToList(), null);
+        }
+
+        var sources = new List<ChocolateySource>();
+        foreach (var name in item.Installer.Sources)
+        {
+            var source = _config.ChocolateySources.FirstOrDefault(s => string.Equals(s.Name, name, StringComparison.OrdinalIgnoreCase));
+            if (source == null)
+            {
+                return (sources, $"Chocolatey source '{name}' is not in ChocolateySources");
+            }
+            sources.Add(source);
+        }
+        return (sources, null);
+    }
+
+    /// <summary>
+    /// Registers each source with choco unless this run already has. choco
+    /// source add replaces a source of the same name, so a changed URL or
+    /// password takes effect on the next run.
+    /// </summary>
+    public async Task<string?> EnsureSourcesAsync(IEnumerable<ChocolateySource> sources, CancellationToken cancellationToken)
+    {
+        foreach (var source in sources)
+        {
+            lock (Registered)
+            {
+                if (Registered.Contains(source.Name))
+                    continue;
+            }
+
+            var (exitCode, output) = await RunAsync(BuildSourceAddArgs(source), cancellationToken);
+            if (exitCode != 0)
+            {
+                return $"Could not register Chocolatey source {source.Name} (exit code {exitCode}): {output.Trim()}";
+            }
+            ConsoleLogger.Detail($"Chocolatey source registered: {source}");
+            lock (Registered)
+            {
+                Registered.Add(source.Name);
+            }
+        }
+        return null;
+    }
+
+    /// <summary>
+    /// choco install for the item. The downloaded .nupkg's folder, when there is
+    /// one, comes first in --source, ahead of the feeds its dependencies
+    /// resolve from. No credentials appear here; they live in the registered sources.
+    /// </summary>
+    public static List<string> BuildInstallArgs(CatalogItem item, string? localFile, IReadOnlyList<ChocolateySource> sources)
+    {
+        var args = new List<string>
+        {
+            "install",
+            PackageId(item),
+            "--yes",
+            "--no-progress",
+            "--force"
+        };
+
+        if (!string.IsNullOrEmpty(item.Version))
+        {
+            args.Add($"--version={item.Version}");
+        }
+
+        var sourceList = new List<string>();
+        if (!string.IsNullOrEmpty(localFile))
+        {
+            sourceList.Add(Path.GetDirectoryName(localFile)!);
+        }
+        sourceList.AddRange(sources.Select(s => s.RegisteredName));
+        if (sourceList.Count > 0)
+        {
+            args.Add($"--source=\"{string.Join(";", sourceList)}\"");
+        }
+
+        return args;
+    }
+
+    public static List<string> BuildSourceAddArgs(ChocolateySource source)
+    {
+        var args = new List<string> { "source", "add", $"--name={source.RegisteredName}", $"--source={source.URL}" };
+        if (!string.IsNullOrEmpty(source.User))
+        {
+            args.Add($"--user={source.User}");
+            args.Add($"--password={source.Password}");
+        }
+        if (source.Priority > 0)
+        {
+            args.Add($"--priority={source.Priority.ToString(CultureInfo.InvariantCulture)}");
+        }
+        args.Add("--limit-output");
+        return args;
+    }
+
+    /// <summary>choco pin add (with the version) or choco pin remove for the item.</summary>
+    public static List<string> BuildPinArgs(CatalogItem item, bool add)
+    {
+        var args = new List<string> { "pin", add ? "add" : "remove", $"--name={PackageId(item)}" };
+        if (add && !string.IsNullOrEmpty(item.Version))
+        {
+            args.Add($"--version={item.Version}");
+        }
+        args.Add("--limit-output");
+        return args;
+    }
+
+    /// <summary>
+    /// Runs choco with <paramref name="args"/> passed one by one, so a password
+    /// is never joined into a logged command line.
+    /// </summary>
+    public static async Task<(int ExitCode, string Output)> RunAsync(IEnumerable<string> args, CancellationToken cancellationToken)
+    {
+        var startInfo = new ProcessStartInfo
+        {
+            FileName = ChocoExe,
+            UseShellExecute = false,
+            RedirectStandardOutput = true,
+            RedirectStandardError = true,
+            CreateNoWindow = true
+        };
+        foreach (var arg in args)
+        {
+            startInfo.ArgumentList.Add(arg);
+        }
+
+        try
+        {
+            using var process = new Process { StartInfo = startInfo };
+            var output = new StringBuilder();
+            process.OutputDataReceived += (_, e) => { if (e.Data != null) output.AppendLine(e.Data); };
+            process.ErrorDataReceived += (_, e) => { if (e.Data != null) output.AppendLine(e.Data); };
+            process.Start();
+            process.BeginOutputReadLine();
+            process.BeginErrorReadLine();
+            await process.WaitForExitAsync(cancellationToken);
+            return (process.ExitCode, output.ToString());
+        }
+        catch (Exception ex) when (ex is System.ComponentModel.Win32Exception or InvalidOperationException)
+        {
+            return (-1, ex.Message);
+        }
+    }
+}
@@ -0,0 +1,56 @@
+using System.Security.Cryptography;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Fingerprint of Config.yaml as it was when a session committed to its
+/// configuration. Config pushes (Intune, GPO file copy, preflight on another
+/// run) can rewrite the file mid-session; rather than re-reading it and ending up
+/// with half the run on old settings and half on new, the session keeps its
+/// snapshot and uses this to warn that the change will apply next run.
+/// </summary>
+public sealed class ConfigSnapshot
+{
+    public string Path { get; }
+
+    /// <summary>SHA-256 of the file contents, or null if it didn't exist.</summary>
+    public string? Hash { get; }
+
+    public DateTime TakenAt { get; }
+
+    private ConfigSnapshot(string path, string? hash)
+    {
+        Path = path;
+        Hash = hash;
+        TakenAt = DateTime.Now;
+    }
+
+    public static ConfigSnapshot Take(string path) => new(path, HashFile(path));
+
+    /// <summary>
+    /// True when the file on disk no longer matches the snapshot.
+    /// </summary>
+    public bool HasChanged() => !string.Equals(HashFile(Path), Hash, StringComparison.Ordinal);
+
+    internal static string? HashFile(string path)
+    {
+        try
+        {
+            if (!File.Exists(path))
+            {
+                return null;
+            }
+            using var stream = new FileStream(path, FileMode.Open, FileAccess.Read, FileShare.ReadWrite | FileShare.Delete);
+            return Convert.ToHexString(SHA256.HashData(stream));
+        }
+        catch (IOException)
+        {
+            // Locked mid-write by the pusher: that's a change in progress
+            return "unreadable";
+        }
+        catch (UnauthorizedAccessException)
+        {
+            return "unreadable";
+        }
+    }
+}
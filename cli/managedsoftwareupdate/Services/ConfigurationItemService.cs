@@ -0,0 +1,190 @@
+using System.Collections;
+using System.Globalization;
+using System.Text;
+using System.Text.RegularExpressions;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>One compliance test of a configuration item.</summary>
+public sealed record ConfigurationTestResult(bool Compliant, string Output, string? Error);
+
+/// <summary>
+/// Evaluates and remediates configuration items (installer type: configuration).
+/// The test (test_script, or the DSC resource's Test method) runs every time the
+/// item's status is checked; the set (set_script, or DSC Set) runs only when the
+/// test fails, and counts as done only once a second test passes. Each result
+/// goes to <see cref="ConfigurationItemsStore"/> so compliance is reported apart
+/// from install state.
+/// </summary>
+public class ConfigurationItemService
+{
+    private static readonly Regex Identifier = new("^[A-Za-z_][A-Za-z0-9_]*$", RegexOptions.Compiled);
+
+    private readonly ScriptService _scriptService;
+    private readonly ConfigurationItemsStore _store;
+    private readonly Func<CatalogItem, string, CancellationToken, Task<(bool Success, string Output)>> _runScript;
+
+    public ConfigurationItemService(
+        ScriptService? scriptService = null,
+        ConfigurationItemsStore? store = null,
+        Func<CatalogItem, string, CancellationToken, Task<(bool Success, string Output)>>? runScript = null)
+    {
+        _scriptService = scriptService ?? new ScriptService();
+        _store = store ?? new ConfigurationItemsStore();
+        _runScript = runScript ?? RunScriptAsync;
+    }
+
+    /// <summary>Why the item's configuration block can't be evaluated; null when it can.</summary>
+    public static string? Validate(CatalogItem item)
+    {
+        var configuration = item.Configuration;
+        if (configuration == null)
+        {
+            return "configuration item has no configuration block";
+        }
+
+        if (configuration.DscResource is { } dsc)
+        {
+            if (!string.IsNullOrWhiteSpace(configuration.TestScript) || !string.IsNullOrWhiteSpace(configuration.SetScript))
+                return "configuration has both dsc_resource and test_script/set_script";
+            if (!Identifier.IsMatch(dsc.Name))
+                return $"dsc_resource name '{dsc.Name}' is not a resource name";
+            if (string.IsNullOrWhiteSpace(dsc.Module))
+                return $"dsc_resource {dsc.Name} has no module";
+            if (dsc.Properties.Keys.FirstOrDefault(k => !Identifier.IsMatch(k)) is { } badKey)
+                return $"dsc_resource property '{badKey}' is not a property name";
+            if (dsc.Properties.FirstOrDefault(p => p.Value is IDictionary) is { Key: not null } nested)
+                return $"dsc_resource property {nested.Key} is a nested object; only scalars and lists are supported";
+            return null;
+        }
+
+        if (string.IsNullOrWhiteSpace(configuration.TestScript))
+            return "configuration has neither test_script nor dsc_resource";
+        if (string.IsNullOrWhiteSpace(configuration.SetScript))
+            return "configuration has a test_script but no set_script";
+        return null;
+    }
+
+    /// <summary>Runs the item's test and records the result.</summary>
+    public async Task<ConfigurationTestResult> TestAsync(CatalogItem item, CancellationToken cancellationToken = default)
+    {
+        var result = await RunTestAsync(item, cancellationToken);
+        Record(item, result.Compliant, result.Error, remediated: false);
+        return result;
+    }
+
+    /// <summary>
+    /// Runs the item's set, then tests again. Succeeds only when the machine is
+    /// compliant afterwards, so a set script that exits 0 without fixing
+    /// anything is reported as a failure rather than papering over the drift.
+    /// </summary>
+    public async Task<(bool Success, string Output)> RemediateAsync(CatalogItem item, CancellationToken cancellationToken = default)
+    {
+        var invalid = Validate(item);
+        if (invalid != null)
+        {
+            Record(item, compliant: false, invalid, remediated: false);
+            return (false, invalid);
+        }
+
+        var configuration = item.Configuration!;
+        var setScript = configuration.DscResource is { } dsc ? BuildDscScript(dsc, "Set") : configuration.SetScript!;
+        ConsoleLogger.Info($"Remediating configuration item {item.Name} ({configuration.Method})...");
+        var (setSuccess, setOutput) = await _runScript(item, setScript, cancellationToken);
+        if (!setSuccess)
+        {
+            var error = $"set failed: {FirstLine(setOutput)}";
+            Record(item, compliant: false, error, remediated: false);
+            return (false, setOutput);
+        }
+
+        var test = await RunTestAsync(item, cancellationToken);
+        if (!test.Compliant)
+        {
+            var error = test.Error ?? "still not compliant after remediation";
+            Record(item, compliant: false, error, remediated: false);
+            return (false, $"{item.Name}: {error}");
+        }
+
+        Record(item, compliant: true, error: null, remediated: true);
+        return (true, setOutput);
+    }
+
+    /// <summary>
+    /// A script invoking the DSC resource's <paramref name="method"/> (Test or
+    /// Set). Test exits 0 only when the resource reports InDesiredState; any
+    /// error from Invoke-DscResource exits non-zero.
+    /// </summary>
+    internal static string BuildDscScript(DscResourceInfo dsc, string method)
+    {
+        var module = string.IsNullOrWhiteSpace(dsc.ModuleVersion)
+            ? PowerShellPayloadHandler.Literal(dsc.Module)
+            : $"@{{ ModuleName = {PowerShellPayloadHandler.Literal(dsc.Module)}; ModuleVersion = {PowerShellPayloadHandler.Literal(dsc.ModuleVersion)} }}";
+
+        var properties = new StringBuilder();
+        foreach (var (name, value) in dsc.Properties.OrderBy(p => p.Key, StringComparer.Ordinal))
+        {
+            properties.Append($"    {name} = {PropertyLiteral(value)}\n");
+        }
+
+        var script = new StringBuilder();
+        script.Append("$ErrorActionPreference = 'Stop'\n");
+        script.Append("$property = @{\n").Append(properties).Append("}\n");
+        script.Append($"$result = Invoke-DscResource -Name {PowerShellPayloadHandler.Literal(dsc.Name)} -ModuleName {module} -Method {method} -Property $property\n");
+        script.Append(method == "Test"
+            ? "if ($result.InDesiredState) { exit 0 } else { 'Not in desired state'; exit 1 }\n"
+            : "if ($result.RebootRequired) { 'DSC resource reported that a reboot is required' }\nexit 0\n");
+        return script.ToString();
+    }
+
+    private async Task<ConfigurationTestResult> RunTestAsync(CatalogItem item, CancellationToken cancellationToken)
+    {
+        var invalid = Validate(item);
+        if (invalid != null)
+        {
+            return new ConfigurationTestResult(false, "", invalid);
+        }
+
+        var configuration = item.Configuration!;
+        var testScript = configuration.DscResource is { } dsc ? BuildDscScript(dsc, "Test") : configuration.TestScript!;
+        var (compliant, output) = await _runScript(item, testScript, cancellationToken);
+        ConsoleLogger.Debug($"Configuration test for {item.Name}: {(compliant ? "compliant" : "not compliant")}");
+        return new ConfigurationTestResult(compliant, output.Trim(), null);
+    }
+
+    private void Record(CatalogItem item, bool compliant, string? error, bool remediated)
+    {
+        var record = _store.Get(item.Name) ?? new ConfigurationItemRecord { Name = item.Name };
+        record.Version = item.Version;
+        record.Method = item.Configuration?.Method ?? "script";
+        record.Compliant = compliant;
+        record.Error = error;
+        record.EvaluatedAt = DateTime.Now;
+        if (remediated)
+        {
+            record.RemediatedAt = record.EvaluatedAt;
+        }
+        _store.Set(record);
+        _store.Save();
+    }
+
+    private Task<(bool Success, string Output)> RunScriptAsync(CatalogItem item, string script, CancellationToken cancellationToken)
+    {
+        var runner = item.InstallsAsUser ? _scriptService.ForItem(item).AsActiveUser() : _scriptService.ForItem(item);
+        return runner.ExecuteScriptWithExitCodeAsync(script, cancellationToken);
+    }
+
+    private static string PropertyLiteral(object? value) => value switch
+    {
+        null => "$null",
+        string text => PowerShellPayloadHandler.Literal(text),
+        IEnumerable list => "@(" + string.Join(", ", list.Cast<object?>().Select(PropertyLiteral)) + ")",
+        IFormattable formattable => PowerShellPayloadHandler.Literal(formattable.ToString(null, CultureInfo.InvariantCulture)),
+        _ => PowerShellPayloadHandler.Literal(value.ToString())
+    };
+
+    private static string FirstLine(string output) =>
+        output.Split('\n', StringSplitOptions.RemoveEmptyEntries | StringSplitOptions.TrimEntries).FirstOrDefault() ?? "no output";
+}
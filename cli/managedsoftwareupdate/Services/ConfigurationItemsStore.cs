@@ -0,0 +1,99 @@
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using Cimian.Core;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>The last compliance evaluation of one configuration item.</summary>
+public class ConfigurationItemRecord
+{
+    [JsonPropertyName("name")]
+    public string Name { get; set; } = "";
+
+    [JsonPropertyName("version")]
+    public string Version { get; set; } = "";
+
+    /// <summary>script or dsc.</summary>
+    [JsonPropertyName("method")]
+    public string Method { get; set; } = "script";
+
+    [JsonPropertyName("compliant")]
+    public bool Compliant { get; set; }
+
+    [JsonPropertyName("evaluated_at")]
+    public DateTime? EvaluatedAt { get; set; }
+
+    /// <summary>When the set script (or DSC Set) last brought the item back into compliance.</summary>
+    [JsonPropertyName("remediated_at")]
+    public DateTime? RemediatedAt { get; set; }
+
+    /// <summary>Why the item couldn't be tested or remediated; null when it could.</summary>
+    [JsonPropertyName("error")]
+    public string? Error { get; set; }
+}
+
+/// <summary>
+/// Compliance of every configuration item Cimian has evaluated, kept in
+/// <see cref="CimianPaths.ConfigurationItemsJson"/> apart from install state:
+/// a configuration item is never "installed", only compliant or not as of its
+/// last test.
+/// </summary>
+public class ConfigurationItemsStore
+{
+    private static readonly JsonSerializerOptions JsonOptions = new()
+    {
+        WriteIndented = true,
+        DefaultIgnoreCondition = JsonIgnoreCondition.WhenWritingNull
+    };
+
+    private readonly string _path;
+    private Dictionary<string, ConfigurationItemRecord>? _records;
+
+    public ConfigurationItemsStore(string? path = null)
+    {
+        _path = path ?? CimianPaths.ConfigurationItemsJson;
+    }
+
+    public ConfigurationItemRecord? Get(string name) =>
+        Records.TryGetValue(ItemKey.Canonical(name), out var record) ? record : null;
+
+    public List<ConfigurationItemRecord> All() =>
+        Records.Values.OrderBy(r => r.Name, StringComparer.OrdinalIgnoreCase).ToList();
+
+    public void Set(ConfigurationItemRecord record) => Records[ItemKey.Canonical(record.Name)] = record;
+
+    public void Save()
+    {
+        try
+        {
+            JsonStateFile.Write(_path, All(), JsonOptions);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not save configuration item state: {ex.Message}");
+        }
+    }
+
+    private Dictionary<string, ConfigurationItemRecord> Records => _records ??= Load();
+
+    private Dictionary<string, ConfigurationItemRecord> Load()
+    {
+        var records = new Dictionary<string, ConfigurationItemRecord>();
+        try
+        {
+            if (File.Exists(_path))
+            {
+                foreach (var record in JsonSerializer.Deserialize<List<ConfigurationItemRecord>>(File.ReadAllText(_path), JsonOptions) ?? new())
+                {
+                    records[ItemKey.Canonical(record.Name)] = record;
+                }
+            }
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException or JsonException)
+        {
+            ConsoleLogger.Warn($"Could not read configuration item state: {ex.Message}");
+        }
+        return records;
+    }
+}
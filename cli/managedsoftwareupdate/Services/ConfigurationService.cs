@@ -1,7 +1,9 @@
+using System.Security.Cryptography;
 using YamlDotNet.Serialization;
 using YamlDotNet.Serialization.NamingConventions;
 using Cimian.CLI.managedsoftwareupdate.Models;
 using Cimian.Core;
+using Cimian.Core.Models;
 using Cimian.Core.Services;
 
 namespace Cimian.CLI.managedsoftwareupdate.Services;
@@ -76,6 +78,8 @@ public class ConfigurationService
             if (timeout >= 60)
             {
                 config.InstallerTimeout = timeout;
+                // Policy beats Config.yaml, including its minutes setting
+                config.DefaultInstallerTimeoutMinutes = 0;
             }
         }
         catch (Exception ex)
@@ -100,7 +104,7 @@ public class ConfigurationService
         {
             var yaml = File.ReadAllText(path);
             var config = _deserializer.Deserialize<CimianConfig>(yaml);
-            return ApplyPolicyOverrides(config ?? GetDefaultConfig());
+            return ApplyPolicyOverrides(config != null ? ApplyPrimaryMirror(ApplyRoleDefaults(config, yaml)) : GetDefaultConfig());
         }
         catch (Exception ex)
         {
@@ -109,6 +113,128 @@ public class ConfigurationService
         }
     }
 
+    /// <summary>
+    /// With only SoftwareRepoURLs set, its first mirror becomes SoftwareRepoURL,
+    /// the URL every service builds requests from.
+    /// </summary>
+    private static CimianConfig ApplyPrimaryMirror(CimianConfig config)
+    {
+        if (string.IsNullOrWhiteSpace(config.SoftwareRepoURL) && config.SoftwareRepoURLs.Count > 0)
+        {
+            config.SoftwareRepoURL = config.SoftwareRepoURLs[0].Trim();
+        }
+        return config;
+    }
+
+    /// <summary>
+    /// Applies the MachineRole bundle to every key the YAML doesn't set itself,
+    /// so an explicit value always beats the role. An unknown role changes
+    /// nothing here; ValidateConfig reports it.
+    /// </summary>
+    public CimianConfig ApplyRoleDefaults(CimianConfig config, string yaml)
+    {
+        var defaults = MachineRole.Defaults(config.MachineRole);
+        if (defaults.Count == 0)
+        {
+            return config;
+        }
+
+        var explicitKeys = new HashSet<string>(StringComparer.Ordinal);
+        try
+        {
+            var mapping = _deserializer.Deserialize<Dictionary<string, object?>>(yaml);
+            if (mapping != null)
+            {
+                explicitKeys.UnionWith(mapping.Keys);
+            }
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Debug($"Could not list Config.yaml keys for role defaults: {ex.Message}");
+        }
+
+        foreach (var (key, value) in defaults)
+        {
+            if (explicitKeys.Contains(key))
+            {
+                continue;
+            }
+            var property = typeof(CimianConfig).GetProperty(key);
+            if (property != null && property.PropertyType == value.GetType())
+            {
+                property.SetValue(config, value);
+            }
+        }
+        return config;
+    }
+
+    /// <summary>
+    /// Loads configuration for a mid-run reload (after preflight). Unlike LoadConfig
+    /// this never falls back to defaults: the file must read identically twice in a
+    /// row (so a config push that is still writing it isn't picked up half-written)
+    /// and must parse and validate. Returns false with a reason otherwise, and the
+    /// caller keeps the configuration it already has.
+    /// </summary>
+    public bool TryLoadStableConfig(string path, out CimianConfig? config, out string? error)
+    {
+        config = null;
+        error = null;
+
+        if (!File.Exists(path))
+        {
+            error = $"{path} does not exist";
+            return false;
+        }
+
+        try
+        {
+            string? yaml = null;
+            for (var attempt = 0; attempt < StableReadAttempts; attempt++)
+            {
+                var first = File.ReadAllText(path);
+                Thread.Sleep(StableReadInterval);
+                var second = File.ReadAllText(path);
+                if (first == second)
+                {
+                    yaml = second;
+                    break;
+                }
+            }
+
+            if (yaml == null)
+            {
+                error = $"{path} kept changing while being read";
+                return false;
+            }
+
+            var loaded = _deserializer.Deserialize<CimianConfig>(yaml);
+            if (loaded == null)
+            {
+                error = $"{path} is empty";
+                return false;
+            }
+
+            loaded = ApplyPolicyOverrides(ApplyPrimaryMirror(ApplyRoleDefaults(loaded, yaml)));
+            var errors = ValidateConfig(loaded);
+            if (errors.Count > 0)
+            {
+                error = string.Join("; ", errors);
+                return false;
+            }
+
+            config = loaded;
+            return true;
+        }
+        catch (Exception ex)
+        {
+            error = ex.Message;
+            return false;
+        }
+    }
+
+    private const int StableReadAttempts = 5;
+    private static readonly TimeSpan StableReadInterval = TimeSpan.FromMilliseconds(250);
+
     /// <summary>
     /// Saves configuration to the default path
     /// </summary>
@@ -167,6 +293,14 @@ public class ConfigurationService
             errors.Add("SoftwareRepoURL must be a valid HTTP/HTTPS URL");
         }
 
+        foreach (var mirror in config.SoftwareRepoURLs)
+        {
+            if (!Uri.TryCreate(mirror, UriKind.Absolute, out var mirrorUri) || (mirrorUri.Scheme != "http" && mirrorUri.Scheme != "https"))
+            {
+                errors.Add($"SoftwareRepoURLs entry '{mirror}' must be a valid HTTP/HTTPS URL");
+            }
+        }
+
         if (string.IsNullOrWhiteSpace(config.CachePath))
         {
             errors.Add("CachePath is required");
@@ -177,6 +311,277 @@ public class ConfigurationService
             errors.Add("InstallerTimeout must be at least 60 seconds");
         }
 
+        if (config.DefaultInstallerTimeoutMinutes is < 0 or > 1440)
+        {
+            errors.Add("DefaultInstallerTimeoutMinutes must be between 0 (use InstallerTimeout) and 1440");
+        }
+
+        if (config.ScriptTimeout < 0)
+        {
+            errors.Add("ScriptTimeout must be 0 (no limit) or a positive number of seconds");
+        }
+
+        foreach (var (kind, policy) in config.ScriptPolicies)
+        {
+            if (!ScriptKind.All.Contains(kind, StringComparer.OrdinalIgnoreCase))
+            {
+                errors.Add($"ScriptPolicies key '{kind}' must be one of: {string.Join(", ", ScriptKind.All)}");
+            }
+            if (policy.Timeout < 0)
+            {
+                errors.Add($"ScriptPolicies {kind} Timeout must be 0 (no limit) or a positive number of seconds");
+            }
+            if (policy.OnFailure != null && !ScriptFailureAction.All.Contains(policy.OnFailure, StringComparer.OrdinalIgnoreCase))
+            {
+                errors.Add($"ScriptPolicies {kind} OnFailure must be one of: {string.Join(", ", ScriptFailureAction.All)}");
+            }
+        }
+
+        var role = MachineRole.Normalize(config.MachineRole);
+        if (role == null)
+        {
+            errors.Add($"MachineRole must be one of: {string.Join(", ", MachineRole.All)}");
+        }
+
+        var restartPolicy = RestartPolicy.Normalize(config.RestartPolicy);
+        if (restartPolicy == null)
+        {
+            errors.Add($"RestartPolicy must be one of: {string.Join(", ", RestartPolicy.All)}");
+        }
+
+        if (RepoBackend.Normalize(config.RepoBackend) == null)
+        {
+            errors.Add($"RepoBackend must be one of: {string.Join(", ", RepoBackend.All)}");
+        }
+
+        if (!string.IsNullOrWhiteSpace(config.ProxyURL) &&
+            (!Uri.TryCreate(config.ProxyURL, UriKind.Absolute, out var proxyUri) || (proxyUri.Scheme != "http" && proxyUri.Scheme != "https")))
+        {
+            errors.Add("ProxyURL must be an http or https URL such as http://proxy.example.com:8080");
+        }
+
+        if (!string.IsNullOrWhiteSpace(config.ProxyPACURL) && !Uri.TryCreate(config.ProxyPACURL, UriKind.Absolute, out _))
+        {
+            errors.Add("ProxyPACURL must be an absolute URL");
+        }
+
+        var peerCacheMode = PeerCacheMode.Normalize(config.PeerCache.Mode);
+        if (peerCacheMode == null)
+        {
+            errors.Add($"PeerCache Mode must be one of: {string.Join(", ", PeerCacheMode.All)}");
+        }
+        else if (peerCacheMode == PeerCacheMode.Peers && config.PeerCache.Peers.Count == 0)
+        {
+            errors.Add("PeerCache Mode peers needs at least one entry in Peers");
+        }
+        foreach (var peer in config.PeerCache.Peers)
+        {
+            if (DownloadService.NormalizeOrigin(peer) == null)
+            {
+                errors.Add($"PeerCache Peers entry '{peer}' must be an http or https URL");
+            }
+        }
+        if (config.PeerCache.MinimumSizeMB < 0 || config.PeerCache.TimeoutSeconds < 5)
+        {
+            errors.Add("PeerCache MinimumSizeMB can't be negative and TimeoutSeconds must be at least 5");
+        }
+
+        foreach (var format in config.ReportFormats)
+        {
+            if (ReportFormat.Normalize(format) == null)
+            {
+                errors.Add($"ReportFormats entry '{format}' must be one of: {string.Join(", ", ReportFormat.All)}");
+            }
+        }
+
+        if (!string.IsNullOrWhiteSpace(config.ReportURL) && DownloadService.NormalizeOrigin(config.ReportURL) == null)
+        {
+            errors.Add("ReportURL must be an http or https URL");
+        }
+
+        foreach (var webhook in config.Webhooks)
+        {
+            if (DownloadService.NormalizeOrigin(webhook.URL) == null)
+            {
+                errors.Add("Webhooks URL must be an http or https URL");
+            }
+            if (WebhookFormat.Normalize(webhook.Format) == null)
+            {
+                errors.Add($"Webhooks Format must be one of: {string.Join(", ", WebhookFormat.All)}");
+            }
+            foreach (var evt in webhook.Events.Where(e => WebhookEvent.Normalize(e) == null))
+            {
+                errors.Add($"Webhooks event '{evt}' must be one of: {string.Join(", ", WebhookEvent.All)}");
+            }
+        }
+
+        var chocolateySourceNames = new HashSet<string>(StringComparer.OrdinalIgnoreCase);
+        foreach (var source in config.ChocolateySources)
+        {
+            if (string.IsNullOrWhiteSpace(source.Name) || source.Name.Any(c => !char.IsAsciiLetterOrDigit(c) && c is not ('-' or '_' or '.')))
+            {
+                errors.Add($"ChocolateySources Name '{source.Name}' must be letters, digits, '-', '_' or '.'");
+            }
+            else if (!chocolateySourceNames.Add(source.Name))
+            {
+                errors.Add($"ChocolateySources Name '{source.Name}' is used more than once");
+            }
+            if (string.IsNullOrWhiteSpace(source.URL))
+            {
+                errors.Add($"ChocolateySources {source.Name} needs a URL");
+            }
+            if (!string.IsNullOrEmpty(source.Password) && string.IsNullOrEmpty(source.User))
+            {
+                errors.Add($"ChocolateySources {source.Name} has a Password but no User");
+            }
+        }
+
+        if (config.RestartGracePeriodMinutes is < 0 or > 1440)
+        {
+            errors.Add("RestartGracePeriodMinutes must be between 0 and 1440");
+        }
+
+        if (config.ForcedInstallWarningHours is < 0 or > 720)
+        {
+            errors.Add("ForcedInstallWarningHours must be between 0 and 720");
+        }
+
+        if (config.AutoRunIntervalMinutes is < 0 or (> 0 and < 15) or > 1440)
+        {
+            errors.Add("AutoRunIntervalMinutes must be 0 (use the scheduled task) or between 15 and 1440");
+        }
+
+        if (config.AutoRunSplayMinutes is < 0 or > 1440)
+        {
+            errors.Add("AutoRunSplayMinutes must be between 0 and 1440");
+        }
+
+        if (config.HungRunMinutes is < 0 or (> 0 and < 10) or > 1440)
+        {
+            errors.Add("HungRunMinutes must be 0 (watchdog off) or between 10 and 1440");
+        }
+
+        if (config.BootstrapMaxAttempts is < 1 or > 50)
+        {
+            errors.Add("BootstrapMaxAttempts must be between 1 and 50");
+        }
+
+        if (config.BootstrapRetryDelayMinutes is < 0 or > 60)
+        {
+            errors.Add("BootstrapRetryDelayMinutes must be between 0 and 60");
+        }
+
+        if (config.BootstrapScreenTimeoutMinutes is < 0 or > 1440)
+        {
+            errors.Add("BootstrapScreenTimeoutMinutes must be between 0 and 1440");
+        }
+
+        if (!SelfUpdateRollout.Channels.Contains(SelfUpdateRollout.NormalizeChannel(config.SelfUpdateChannel)))
+        {
+            errors.Add("SelfUpdateChannel must be stable, beta or dev");
+        }
+
+        if (config.EspTrackedItems.Any(string.IsNullOrWhiteSpace))
+        {
+            errors.Add("EspTrackedItems entries must be item names");
+        }
+
+        if (config.UnmanagedItemGraceDays is < 0 or > 365)
+        {
+            errors.Add("UnmanagedItemGraceDays must be between 0 and 365");
+        }
+
+        if (config.DependentRemovalPolicy?.Trim().ToLowerInvariant() is not ("cascade" or "block"))
+        {
+            errors.Add("DependentRemovalPolicy must be cascade or block");
+        }
+
+        if (config.BlockingAppTimeout is < 0 or > 14400)
+        {
+            errors.Add("BlockingAppTimeout must be between 0 and 14400 seconds");
+        }
+
+        if (config.MinimumBatteryPercent is < 0 or > 100)
+        {
+            errors.Add("MinimumBatteryPercent must be between 0 (off) and 100");
+        }
+
+        if (config.MeteredDownloadThresholdMB < 0)
+        {
+            errors.Add("MeteredDownloadThresholdMB must be 0 (defer every download) or more");
+        }
+
+        if (config.StatusLogStreaming
+            && config.StatusLogStreamLevel?.Trim().ToUpperInvariant() is not ("TRACE" or "DEBUG" or "INFO" or "WARN" or "ERROR"))
+        {
+            errors.Add("StatusLogStreamLevel must be one of: TRACE, DEBUG, INFO, WARN, ERROR");
+        }
+
+        if (config.LogRetentionDays < 0 || config.LogRetentionSessions < 0 || config.MaxLogSizeMB < 0)
+        {
+            errors.Add("LogRetentionDays, LogRetentionSessions and MaxLogSizeMB must be 0 (no limit) or more");
+        }
+
+        if (config.CacheSizeLimitMB < 0)
+        {
+            errors.Add("CacheSizeLimitMB must be 0 (unlimited) or more");
+        }
+
+        if (config.MaxDownloadRateKBps < 0 || config.DownloadBandwidthLimitKBps < 0)
+        {
+            errors.Add("MaxDownloadRateKBps must be 0 (unlimited) or more");
+        }
+
+        foreach (var window in config.DownloadRateSchedule)
+        {
+            if (!TimeSpan.TryParse(window.Start, out _) || !TimeSpan.TryParse(window.End, out _))
+            {
+                errors.Add($"DownloadRateSchedule window {window} needs Start and End as HH:mm");
+            }
+            if (window.MaxDownloadRateKBps < 0)
+            {
+                errors.Add($"DownloadRateSchedule window {window} needs MaxDownloadRateKBps of 0 (unlimited) or more");
+            }
+        }
+
+        if (config.MetricsPort is < 0 or > 65535)
+        {
+            errors.Add("MetricsPort must be between 0 (off) and 65535");
+        }
+
+        foreach (var origin in config.AllowedDownloadOrigins)
+        {
+            if (DownloadService.NormalizeOrigin(origin) == null)
+            {
+                errors.Add($"AllowedDownloadOrigins entry '{origin}' must be an http or https origin such as https://cdn.example.com");
+            }
+        }
+
+        if (config.RequireSignedMetadata && config.MetadataSigningKeys.Count == 0)
+        {
+            errors.Add("RequireSignedMetadata needs at least one public key in MetadataSigningKeys");
+        }
+        foreach (var key in config.MetadataSigningKeys)
+        {
+            try
+            {
+                MetadataSignature.LoadKey(key.Trim()).Dispose();
+            }
+            catch (Exception ex) when (ex is CryptographicException or IOException or UnauthorizedAccessException)
+            {
+                errors.Add($"MetadataSigningKeys entry '{(key.Length > 40 ? key[..40] + "..." : key)}' is not a readable ECDSA or RSA PEM key: {ex.Message}");
+            }
+        }
+
+        // Role-specific combinations that can't work on that kind of machine
+        if (role == MachineRole.Server && restartPolicy == RestartPolicy.Immediate && config.MaintenanceWindows.Count == 0)
+        {
+            errors.Add("MachineRole server with RestartPolicy immediate needs MaintenanceWindows, or servers restart whenever a run finishes");
+        }
+        if (role == MachineRole.Kiosk && config.RespectFocusAssist)
+        {
+            errors.Add("MachineRole kiosk can't use RespectFocusAssist: a full-screen kiosk app would hold restarts forever");
+        }
         return errors;
     }
 
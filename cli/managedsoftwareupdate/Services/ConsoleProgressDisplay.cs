@@ -0,0 +1,309 @@
+using System.Diagnostics;
+using System.Text;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// managedsoftwareupdate --progress: live ANSI progress bars for a manual run
+/// in a console, in place of the log lines. Fed the same per-item events as
+/// the GUI status reporter. Items being downloaded, installed or removed each
+/// have a bar; a finished item prints its result line once and drops out of
+/// the live area, so the redrawn block stays as small as the work in flight.
+/// Warnings and errors from <see cref="ConsoleLogger"/> print above the bars.
+/// </summary>
+public sealed class ConsoleProgressDisplay : IDisposable
+{
+    private const int BarWidth = 30;
+    private const int RedrawIntervalMs = 100;
+
+    private const string ColorReset = "\u001b[0m";
+    private const string ColorGreen = "\u001b[32m";
+    private const string ColorRed = "\u001b[31m";
+    private const string ColorDim = "\u001b[2m";
+    private const string ClearLine = "\u001b[2K";
+
+    private static readonly string[] Spinner = ["|", "/", "-", "\\"];
+
+    private readonly TextWriter _out;
+    private readonly object _lock = new();
+    private readonly Stopwatch _sinceRedraw = Stopwatch.StartNew();
+    private readonly Dictionary<string, Row> _rows = new(StringComparer.OrdinalIgnoreCase);
+    private readonly List<string> _order = new();
+    private string _status = "";
+    private int _percent;
+    private int _drawnLines;
+    private int _frame;
+    private bool _disposed;
+
+    private sealed class Row
+    {
+        public string Stage = "pending";
+        public int Percent;
+        public string Detail = "";
+    }
+
+    public ConsoleProgressDisplay(TextWriter? output = null)
+    {
+        _out = output ?? Console.Out;
+    }
+
+    /// <summary>
+    /// Only an interactive console can take cursor movement; redirected output
+    /// (a pipe, a log file, a scheduled task) keeps the plain log lines.
+    /// </summary>
+    public static bool IsSupported => !Console.IsOutputRedirected && !Console.IsErrorRedirected;
+
+    public void Status(string text)
+    {
+        lock (_lock)
+        {
+            _status = text;
+            Redraw(force: false);
+        }
+    }
+
+    public void Percent(int percent)
+    {
+        lock (_lock)
+        {
+            _percent = Math.Clamp(percent, 0, 100);
+            Redraw(force: false);
+        }
+    }
+
+    /// <summary>A lifecycle stage from the engine (pending, downloading, installed, failed, ...).</summary>
+    public void ItemStatus(string item, string stage, string? detail)
+    {
+        lock (_lock)
+        {
+            var row = GetRow(item);
+            if (row.Stage == stage && stage is "downloading" or "installing" or "removing")
+            {
+                return;
+            }
+            row.Stage = stage;
+            row.Percent = stage == "downloaded" ? 100 : 0;
+            row.Detail = detail ?? "";
+
+            if (stage is "installed" or "removed" or "failed")
+            {
+                Finish(item, row);
+                return;
+            }
+            Redraw(force: true);
+        }
+    }
+
+    /// <summary>Download percent, bytes and ETA, or the install phase.</summary>
+    public void ItemProgress(string item, string stage, int percent, long? bytes, long? totalBytes, int? etaSeconds, string? detail)
+    {
+        lock (_lock)
+        {
+            var row = GetRow(item);
+            if (row.Stage is "installed" or "removed" or "failed")
+            {
+                return;
+            }
+            row.Stage = stage;
+            row.Percent = Math.Clamp(percent, 0, 100);
+            row.Detail = stage == "downloading" ? DescribeDownload(bytes, totalBytes, etaSeconds) : detail ?? "";
+            Redraw(force: false);
+        }
+    }
+
+    /// <summary>A warning or error line, printed above the bars.</summary>
+    public void WriteLine(string line)
+    {
+        lock (_lock)
+        {
+            Erase();
+            _out.WriteLine(line);
+            Redraw(force: true);
+        }
+    }
+
+    private Row GetRow(string item)
+    {
+        if (!_rows.TryGetValue(item, out var row))
+        {
+            row = new Row();
+            _rows[item] = row;
+            _order.Add(item);
+        }
+        return row;
+    }
+
+    private void Finish(string item, Row row)
+    {
+        Erase();
+        var line = row.Stage == "failed"
+            ? $"{ColorRed}  x {item}  failed{(row.Detail.Length > 0 ? $": {row.Detail}" : "")}{ColorReset}"
+            : $"{ColorGreen}  + {item}  {row.Stage}{ColorReset}";
+        _out.WriteLine(line);
+        _rows.Remove(item);
+        _order.Remove(item);
+        Redraw(force: true);
+    }
+
+    private void Redraw(bool force)
+    {
+        if (_disposed || (!force && _sinceRedraw.ElapsedMilliseconds < RedrawIntervalMs))
+        {
+            return;
+        }
+        _sinceRedraw.Restart();
+        _frame++;
+
+        var lines = new List<string>();
+        if (_status.Length > 0)
+        {
+            lines.Add($"{_status} {Bar(_percent, indeterminate: false)} {_percent,3}%");
+        }
+
+        var waiting = 0;
+        var downloaded = 0;
+        foreach (var item in _order)
+        {
+            var row = _rows[item];
+            switch (row.Stage)
+            {
+                case "pending":
+                    waiting++;
+                    continue;
+                case "downloaded":
+                    downloaded++;
+                    continue;
+            }
+
+            var indeterminate = row.Stage is "installing" or "removing";
+            var label = indeterminate
+                ? $"{Spinner[_frame % Spinner.Length]} {Title(row.Stage)}"
+                : $"{Title(row.Stage)} {row.Percent,3}%";
+            var detail = row.Detail.Length > 0 ? $" {ColorDim}{row.Detail}{ColorReset}" : "";
+            lines.Add($"  {Fit(item, 28),-28} {Bar(row.Percent, indeterminate)} {label}{detail}");
+        }
+
+        if (waiting > 0 || downloaded > 0)
+        {
+            var parts = new List<string>();
+            if (downloaded > 0) parts.Add($"{downloaded} downloaded");
+            if (waiting > 0) parts.Add($"{waiting} waiting");
+            lines.Add($"{ColorDim}  {string.Join(", ", parts)}{ColorReset}");
+        }
+
+        var buffer = new StringBuilder();
+        AppendErase(buffer);
+        foreach (var line in lines)
+        {
+            buffer.Append(ClearLine).Append(Truncate(line)).Append('\n');
+        }
+        _out.Write(buffer.ToString());
+        _out.Flush();
+        _drawnLines = lines.Count;
+    }
+
+    private void Erase()
+    {
+        var buffer = new StringBuilder();
+        AppendErase(buffer);
+        _out.Write(buffer.ToString());
+        _drawnLines = 0;
+    }
+
+    private void AppendErase(StringBuilder buffer)
+    {
+        if (_drawnLines == 0)
+        {
+            return;
+        }
+        // Back to the first drawn line, then clear everything below it
+        buffer.Append($"\u001b[{_drawnLines}F").Append("\u001b[0J");
+    }
+
+    private string Bar(int percent, bool indeterminate)
+    {
+        if (indeterminate)
+        {
+            // A short block sweeping across the bar
+            var position = _frame % (BarWidth - 4);
+            return "[" + new string(' ', position) + "####" + new string(' ', BarWidth - 4 - position) + "]";
+        }
+        var filled = BarWidth * Math.Clamp(percent, 0, 100) / 100;
+        return "[" + new string('#', filled) + new string('.', BarWidth - filled) + "]";
+    }
+
+    private static string Title(string stage) =>
+        stage.Length == 0 ? stage : char.ToUpperInvariant(stage[0]) + stage[1..];
+
+    private static string Fit(string text, int width) =>
+        text.Length <= width ? text : text[..(width - 3)] + "...";
+
+    /// <summary>Keeps a line from wrapping, which would throw off the cursor-up count.</summary>
+    private static string Truncate(string line)
+    {
+        int width;
+        try
+        {
+            width = Console.WindowWidth;
+        }
+        catch (IOException)
+        {
+            return line;
+        }
+        if (width <= 0)
+        {
+            return line;
+        }
+
+        // Count only visible characters; ANSI sequences take no columns
+        var visible = 0;
+        for (var i = 0; i < line.Length; i++)
+        {
+            if (line[i] == '\u001b')
+            {
+                while (i < line.Length && line[i] != 'm') i++;
+                continue;
+            }
+            if (++visible >= width)
+            {
+                return line[..i] + ColorReset;
+            }
+        }
+        return line;
+    }
+
+    internal static string DescribeDownload(long? bytes, long? totalBytes, int? etaSeconds)
+    {
+        var parts = new List<string>();
+        if (bytes != null && totalBytes != null)
+        {
+            parts.Add($"{bytes.Value / (1024.0 * 1024):F1}/{totalBytes.Value / (1024.0 * 1024):F1} MB");
+        }
+        if (etaSeconds != null)
+        {
+            parts.Add(etaSeconds.Value < 60
+                ? $"{etaSeconds.Value}s left"
+                : $"{etaSeconds.Value / 60}m {etaSeconds.Value % 60}s left");
+        }
+        return string.Join(", ", parts);
+    }
+
+    public void Dispose()
+    {
+        lock (_lock)
+        {
+            if (_disposed)
+            {
+                return;
+            }
+            Erase();
+            _disposed = true;
+            if (_status.Length > 0)
+            {
+                _out.WriteLine(_status);
+            }
+            _out.Flush();
+        }
+    }
+}
@@ -0,0 +1,134 @@
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+public class DeferralEntry
+{
+    [JsonPropertyName("item_name")]
+    public string ItemName { get; set; } = "";
+
+    /// <summary>The count belongs to this version; a new version starts over.</summary>
+    [JsonPropertyName("version")]
+    public string Version { get; set; } = "";
+
+    [JsonPropertyName("count")]
+    public int Count { get; set; }
+
+    [JsonPropertyName("first_deferred")]
+    public DateTime FirstDeferred { get; set; }
+
+    [JsonPropertyName("last_deferred")]
+    public DateTime LastDeferred { get; set; }
+
+    [JsonPropertyName("last_reason")]
+    public string? LastReason { get; set; }
+}
+
+/// <summary>
+/// Per-item count of user-caused deferrals (blocking app open, user active),
+/// kept in <see cref="CimianPaths.DeferralsJson"/>. Once an item's max_deferrals
+/// are used up or its force_install_after_date passes, the next run installs it
+/// instead of deferring again.
+/// </summary>
+public class DeferralService
+{
+    private readonly string _path;
+    private Dictionary<string, DeferralEntry>? _entries;
+
+    public DeferralService(string? path = null)
+    {
+        _path = path ?? CimianPaths.DeferralsJson;
+    }
+
+    /// <summary>Only items with a deferral budget or deadline are counted.</summary>
+    public static bool TracksDeferrals(CatalogItem item) =>
+        item.MaxDeferrals is > 0 || item.ForceInstallAfterDate != null;
+
+    public DeferralEntry? Get(CatalogItem item)
+    {
+        return Entries.TryGetValue(ItemKey.Canonical(item.Name), out var entry)
+            && CatalogService.CompareVersions(entry.Version, item.Version) == 0
+            ? entry
+            : null;
+    }
+
+    /// <summary>
+    /// True when the item must install now rather than be deferred again.
+    /// </summary>
+    public bool ShouldForce(CatalogItem item, DateTime now, out string reason)
+    {
+        if (item.ForceInstallAfterDate is { } deadline && now >= deadline)
+        {
+            reason = $"force_install_after_date {deadline:yyyy-MM-dd} has passed";
+            return true;
+        }
+
+        var used = Get(item)?.Count ?? 0;
+        if (item.MaxDeferrals is int max && max > 0 && used >= max)
+        {
+            reason = $"all {max} deferrals used";
+            return true;
+        }
+
+        reason = "";
+        return false;
+    }
+
+    public DeferralEntry RecordDeferral(CatalogItem item, string reason, DateTime now)
+    {
+        var entry = Get(item);
+        if (entry == null)
+        {
+            entry = new DeferralEntry { ItemName = item.Name, Version = item.Version, FirstDeferred = now };
+            Entries[ItemKey.Canonical(item.Name)] = entry;
+        }
+        entry.Count++;
+        entry.LastDeferred = now;
+        entry.LastReason = reason;
+        return entry;
+    }
+
+    public void Clear(string itemName) => Entries.Remove(ItemKey.Canonical(itemName));
+
+    public void Save()
+    {
+        if (_entries == null)
+        {
+            return;
+        }
+        try
+        {
+            JsonStateFile.Write(_path, _entries.Values.OrderBy(e => e.ItemName));
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not save deferral state: {ex.Message}");
+        }
+    }
+
+    private Dictionary<string, DeferralEntry> Entries => _entries ??= Load();
+
+    private Dictionary<string, DeferralEntry> Load()
+    {
+        var entries = new Dictionary<string, DeferralEntry>();
+        try
+        {
+            if (File.Exists(_path))
+            {
+                foreach (var entry in JsonSerializer.Deserialize<List<DeferralEntry>>(File.ReadAllText(_path)) ?? [])
+                {
+                    entries[ItemKey.Canonical(entry.ItemName)] = entry;
+                }
+            }
+        }
+        catch (Exception ex) when (ex is IOException or JsonException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not read deferral state: {ex.Message}");
+        }
+        return entries;
+    }
+}
@@ -0,0 +1,153 @@
+using System.Runtime.InteropServices;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Downloads one URL through the Windows Delivery Optimization service
+/// (deliveryoptimization.h), which pulls pieces from LAN peers that already
+/// have the content and the rest from the URL itself. Callers verify the file.
+/// </summary>
+internal sealed class DeliveryOptimizationClient
+{
+    private static readonly Guid DeliveryOptimizationClsid = new("5b99fa76-721c-423c-adac-56d03c8a8007");
+    private static readonly TimeSpan PollInterval = TimeSpan.FromSeconds(1);
+
+    private const int RpcCAuthnDefault = -1;
+    private const int RpcCAuthzNone = 0;
+    private const int RpcCAuthnLevelDefault = 0;
+    private const int RpcCImpLevelImpersonate = 3;
+    private const int EoacNone = 0;
+
+    /// <summary>
+    /// Fetches <paramref name="url"/> to <paramref name="destination"/>, sending
+    /// <paramref name="httpHeaders"/> (CRLF-separated) to the origin. Throws when
+    /// Delivery Optimization is unavailable, fails, or makes no progress for
+    /// <paramref name="noProgressTimeout"/>.
+    /// </summary>
+    public async Task DownloadAsync(string url, string destination, string? httpHeaders, TimeSpan noProgressTimeout, CancellationToken cancellationToken)
+    {
+        var type = Type.GetTypeFromCLSID(DeliveryOptimizationClsid, throwOnError: true)!;
+        var manager = (IDOManager)Activator.CreateInstance(type)!;
+        IDODownload? download = null;
+        try
+        {
+            // The DO service impersonates the caller to write LocalPath
+            SetImpersonation(manager);
+            manager.CreateDownload(out download);
+            SetImpersonation(download);
+
+            SetProperty(download, DODownloadProperty.Uri, url);
+            SetProperty(download, DODownloadProperty.LocalPath, destination);
+            SetProperty(download, DODownloadProperty.DisplayName, $"Cimian {Path.GetFileName(destination)}");
+            SetProperty(download, DODownloadProperty.NoProgressTimeoutSeconds, (uint)noProgressTimeout.TotalSeconds);
+            if (!string.IsNullOrEmpty(httpHeaders))
+            {
+                SetProperty(download, DODownloadProperty.HttpCustomHeaders, httpHeaders);
+            }
+
+            download.Start(IntPtr.Zero);
+            while (true)
+            {
+                await Task.Delay(PollInterval, cancellationToken).ConfigureAwait(false);
+                download.GetStatus(out var status);
+                switch (status.State)
+                {
+                    case DODownloadState.Transferred:
+                        download.FinalizeDownload();
+                        return;
+                    case DODownloadState.Aborted:
+                        throw new IOException($"Delivery Optimization aborted the download (0x{status.Error:X8})");
+                    case DODownloadState.Paused when status.Error != 0:
+                        throw new IOException($"Delivery Optimization failed (0x{status.Error:X8}, extended 0x{status.ExtendedError:X8})");
+                }
+            }
+        }
+        catch
+        {
+            try { download?.Abort(); } catch (COMException) { /* already gone */ }
+            throw;
+        }
+        finally
+        {
+            if (download != null) Marshal.ReleaseComObject(download);
+            Marshal.ReleaseComObject(manager);
+        }
+    }
+
+    private static void SetProperty(IDODownload download, DODownloadProperty property, object value) =>
+        download.SetProperty(property, ref value);
+
+    private static void SetImpersonation(object comObject)
+    {
+        var unknown = Marshal.GetIUnknownForObject(comObject);
+        try
+        {
+            var hr = CoSetProxyBlanket(unknown, RpcCAuthnDefault, RpcCAuthzNone, IntPtr.Zero,
+                RpcCAuthnLevelDefault, RpcCImpLevelImpersonate, IntPtr.Zero, EoacNone);
+            Marshal.ThrowExceptionForHR(hr);
+        }
+        finally
+        {
+            Marshal.Release(unknown);
+        }
+    }
+
+    [DllImport("ole32.dll")]
+    private static extern int CoSetProxyBlanket(IntPtr proxy, int authnSvc, int authzSvc, IntPtr serverPrincName,
+        int authnLevel, int impLevel, IntPtr authInfo, int capabilities);
+
+    private enum DODownloadProperty
+    {
+        Id = 0,
+        Uri = 1,
+        ContentId = 2,
+        DisplayName = 3,
+        LocalPath = 4,
+        HttpCustomHeaders = 5,
+        CostPolicy = 6,
+        SecurityFlags = 7,
+        CallbackFreqPercent = 8,
+        CallbackFreqSeconds = 9,
+        NoProgressTimeoutSeconds = 10
+    }
+
+    private enum DODownloadState
+    {
+        Created = 0,
+        Transferring = 1,
+        Transferred = 2,
+        Finalizing = 3,
+        Finalized = 4,
+        Aborted = 5,
+        Paused = 6
+    }
+
+    [StructLayout(LayoutKind.Sequential)]
+    private struct DODownloadStatus
+    {
+        public ulong BytesTotal;
+        public ulong BytesTransferred;
+        public DODownloadState State;
+        public int Error;
+        public int ExtendedError;
+    }
+
+    [ComImport, Guid("400E2D4A-1431-4C1A-A748-39CA472CFDB1"), InterfaceType(ComInterfaceType.InterfaceIsIUnknown)]
+    private interface IDOManager
+    {
+        void CreateDownload(out IDODownload download);
+        void EnumDownloads(IntPtr category, out IntPtr downloads);
+    }
+
+    [ComImport, Guid("FBBD7FC0-C147-4727-A38D-827EF071EE77"), InterfaceType(ComInterfaceType.InterfaceIsIUnknown)]
+    private interface IDODownload
+    {
+        void Start(IntPtr ranges);
+        void Pause();
+        void Abort();
+        void FinalizeDownload(); // IDODownload::Finalize
+        void GetStatus(out DODownloadStatus status);
+        void GetProperty(DODownloadProperty property, [MarshalAs(UnmanagedType.Struct)] out object value);
+        void SetProperty(DODownloadProperty property, [In, MarshalAs(UnmanagedType.Struct)] ref object value);
+    }
+}
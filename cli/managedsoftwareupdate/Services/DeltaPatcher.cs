@@ -0,0 +1,50 @@
+using System.ComponentModel;
+using System.Runtime.InteropServices;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Applies binary patches for type: delta installers using Windows MSDelta
+/// (msdelta.dll, present on every supported Windows). Patches are produced with
+/// CreateDelta / the Windows SDK tooling from the previous full installer.
+/// </summary>
+public static class DeltaPatcher
+{
+    private const long DeltaFlagNone = 0;
+
+    [DllImport("msdelta.dll", CharSet = CharSet.Unicode, SetLastError = true)]
+    [return: MarshalAs(UnmanagedType.Bool)]
+    private static extern bool ApplyDeltaW(long applyFlags, string sourceName, string deltaName, string targetName);
+
+    /// <summary>
+    /// Reconstructs <paramref name="targetPath"/> from the base file and patch.
+    /// Returns false with the Win32 error when MSDelta rejects the patch (most
+    /// often because the base isn't the exact file the patch was built from).
+    /// </summary>
+    public static bool TryApply(string basePath, string patchPath, string targetPath, out string error)
+    {
+        error = string.Empty;
+        try
+        {
+            var dir = Path.GetDirectoryName(targetPath);
+            if (!string.IsNullOrEmpty(dir))
+            {
+                Directory.CreateDirectory(dir);
+            }
+
+            if (ApplyDeltaW(DeltaFlagNone, basePath, patchPath, targetPath))
+            {
+                return true;
+            }
+
+            error = new Win32Exception(Marshal.GetLastWin32Error()).Message;
+        }
+        catch (Exception ex) when (ex is DllNotFoundException or EntryPointNotFoundException)
+        {
+            error = "MSDelta is not available on this system";
+        }
+
+        try { File.Delete(targetPath); } catch { /* partial output */ }
+        return false;
+    }
+}
@@ -0,0 +1,227 @@
+using System.Collections.Concurrent;
+using System.Diagnostics;
+using System.Diagnostics.Tracing;
+using System.Text.Json;
+using Cimian.Core;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// ETW provider for managedsoftwareupdate run phases. Each span is emitted as a
+/// Start/Stop pair so PerfView / WPA group them into activities with durations.
+/// Capture ad-hoc with: PerfView /OnlyProviders=*Cimian-ManagedSoftwareUpdate collect
+/// </summary>
+[EventSource(Name = "Cimian-ManagedSoftwareUpdate")]
+internal sealed class CimianEventSource : EventSource
+{
+    public static readonly CimianEventSource Log = new();
+
+    [Event(1, Opcode = EventOpcode.Start, Level = EventLevel.Informational)]
+    public void SpanStart(string Phase, string Name) => WriteEvent(1, Phase, Name);
+
+    [Event(2, Opcode = EventOpcode.Stop, Level = EventLevel.Informational)]
+    public void SpanStop(string Phase, string Name, double DurationMs, bool Success) =>
+        WriteEvent(2, Phase, Name, DurationMs, Success);
+}
+
+/// <summary>
+/// Opt-in deep diagnostics for slow runs (TraceDiagnostics: true or --trace).
+///
+/// When enabled, the run is wrapped in a file-mode ETW session (logman) that
+/// records the Cimian-ManagedSoftwareUpdate provider into an .etl file PerfView
+/// and WPA open directly. Independently of ETW, every span is timed with a
+/// Stopwatch and written to a Chrome trace-event JSON file (chrome://tracing,
+/// Perfetto, or PerfView's "Open trace.json") so the data survives even when
+/// logman is unavailable or the session couldn't be started.
+///
+/// Spans cover manifest fetch, catalog load/parse, each download and each
+/// install/uninstall — the phases that separate a 5-minute run from a 40-minute one.
+/// When disabled every call is a no-op, so call sites don't need to guard.
+/// </summary>
+public static class DiagnosticTrace
+{
+    private const string EtwSessionName = "CimianManagedSoftwareUpdate";
+
+    private static readonly ConcurrentQueue<SpanRecord> _spans = new();
+    private static Stopwatch? _clock;
+    private static string? _traceBasePath;
+    private static bool _etwSessionStarted;
+
+    /// <summary>True while a trace is being collected for this run.</summary>
+    public static bool Enabled { get; private set; }
+
+    /// <summary>Directory traces are written to ({logs}\traces).</summary>
+    public static string TraceDir => Path.Combine(CimianPaths.LogsDir, "traces");
+
+    private sealed record SpanRecord(string Phase, string Name, double StartMs, double DurationMs, bool Success, int ThreadId);
+
+    /// <summary>
+    /// Starts collecting spans and, when possible, an ETW session writing to
+    /// {logs}\traces\{sessionId}.etl. Safe to call more than once.
+    /// </summary>
+    public static void Start(string sessionId)
+    {
+        if (Enabled) return;
+
+        Enabled = true;
+        _spans.Clear();
+        _clock = Stopwatch.StartNew();
+
+        try
+        {
+            Directory.CreateDirectory(TraceDir);
+            _traceBasePath = Path.Combine(TraceDir, sessionId);
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Warn($"Trace directory unavailable, writing trace to %TEMP% instead: {ex.Message}");
+            _traceBasePath = Path.Combine(Path.GetTempPath(), $"cimian_trace_{sessionId}");
+        }
+
+        _etwSessionStarted = StartEtwSession($"{_traceBasePath}.etl");
+        ConsoleLogger.Info(_etwSessionStarted
+            ? $"Diagnostic tracing enabled (ETW + spans): {_traceBasePath}.etl"
+            : $"Diagnostic tracing enabled (spans only): {_traceBasePath}.trace.json");
+    }
+
+    /// <summary>
+    /// Stops the ETW session, writes the span file and prints the slowest spans.
+    /// </summary>
+    public static void Stop()
+    {
+        if (!Enabled) return;
+        Enabled = false;
+
+        if (_etwSessionStarted)
+        {
+            RunLogman($"stop {EtwSessionName} -ets");
+            _etwSessionStarted = false;
+        }
+
+        try
+        {
+            var spans = _spans.ToList();
+            var tracePath = $"{_traceBasePath}.trace.json";
+            File.WriteAllText(tracePath, BuildChromeTrace(spans, Environment.ProcessId));
+
+            ConsoleLogger.Info($"Diagnostic trace written: {tracePath}");
+            foreach (var span in spans.OrderByDescending(s => s.DurationMs).Take(10))
+            {
+                ConsoleLogger.Info($"    {span.DurationMs / 1000.0,8:F1}s  {span.Phase,-10} {span.Name}{(span.Success ? "" : " (failed)")}");
+            }
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Warn($"Failed to write diagnostic trace: {ex.Message}");
+        }
+    }
+
+    /// <summary>
+    /// Opens a timed span. Dispose it when the phase ends; call
+    /// <see cref="Span.Fail"/> first to mark the phase as failed.
+    /// </summary>
+    public static Span Begin(string phase, string name = "")
+    {
+        if (!Enabled || _clock == null) return Span.None;
+        CimianEventSource.Log.SpanStart(phase, name);
+        return new Span(phase, name, _clock.Elapsed.TotalMilliseconds);
+    }
+
+    /// <summary>
+    /// A timed phase. <see cref="None"/> (returned while tracing is off) does nothing.
+    /// </summary>
+    public sealed class Span : IDisposable
+    {
+        internal static readonly Span None = new(null, "", 0);
+
+        private readonly string? _phase;
+        private readonly string _name;
+        private readonly double _startMs;
+        private bool _failed;
+        private bool _disposed;
+
+        internal Span(string? phase, string name, double startMs)
+        {
+            _phase = phase;
+            _name = name;
+            _startMs = startMs;
+        }
+
+        public void Fail() => _failed = true;
+
+        public void Dispose()
+        {
+            if (_phase == null || _disposed || _clock == null) return;
+            _disposed = true;
+
+            var duration = _clock.Elapsed.TotalMilliseconds - _startMs;
+            CimianEventSource.Log.SpanStop(_phase, _name, duration, !_failed);
+            _spans.Enqueue(new SpanRecord(_phase, _name, _startMs, duration, !_failed, Environment.CurrentManagedThreadId));
+        }
+    }
+
+    /// <summary>
+    /// Serializes spans as Chrome trace-event "complete" events (ph=X, times in µs).
+    /// </summary>
+    internal static string BuildChromeTrace(IEnumerable<(string Phase, string Name, double StartMs, double DurationMs, bool Success, int ThreadId)> spans, int pid)
+    {
+        var events = spans.Select(s => new Dictionary<string, object>
+        {
+            ["name"] = string.IsNullOrEmpty(s.Name) ? s.Phase : $"{s.Phase}: {s.Name}",
+            ["cat"] = s.Phase,
+            ["ph"] = "X",
+            ["ts"] = Math.Round(s.StartMs * 1000),
+            ["dur"] = Math.Round(s.DurationMs * 1000),
+            ["pid"] = pid,
+            ["tid"] = s.ThreadId,
+            ["args"] = new Dictionary<string, object> { ["success"] = s.Success }
+        });
+
+        return JsonSerializer.Serialize(new Dictionary<string, object>
+        {
+            ["traceEvents"] = events.ToList(),
+            ["displayTimeUnit"] = "ms"
+        }, new JsonSerializerOptions { WriteIndented = true });
+    }
+
+    private static string BuildChromeTrace(List<SpanRecord> spans, int pid) =>
+        BuildChromeTrace(spans.Select(s => (s.Phase, s.Name, s.StartMs, s.DurationMs, s.Success, s.ThreadId)), pid);
+
+    /// <summary>
+    /// Starts a file-mode ETW session for our EventSource. Any session left behind
+    /// by a crashed run is stopped first, since logman refuses duplicate names.
+    /// </summary>
+    private static bool StartEtwSession(string etlPath)
+    {
+        if (!OperatingSystem.IsWindows()) return false;
+
+        var providerGuid = EventSource.GetGuid(typeof(CimianEventSource));
+        RunLogman($"stop {EtwSessionName} -ets");
+        return RunLogman($"start {EtwSessionName} -p \"{{{providerGuid}}}\" 0xFFFFFFFF 5 -o \"{etlPath}\" -ets -ow");
+    }
+
+    private static bool RunLogman(string arguments)
+    {
+        try
+        {
+            using var process = Process.Start(new ProcessStartInfo
+            {
+                FileName = "logman.exe",
+                Arguments = arguments,
+                UseShellExecute = false,
+                RedirectStandardOutput = true,
+                RedirectStandardError = true,
+                CreateNoWindow = true
+            });
+            if (process == null) return false;
+            process.WaitForExit(15000);
+            return process.HasExited && process.ExitCode == 0;
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Debug($"logman {arguments} failed: {ex.Message}");
+            return false;
+        }
+    }
+}
@@ -1,15 +1,18 @@
+using System.Collections.Concurrent;
 using System.Net.Http.Headers;
 using System.Security.Cryptography;
 using System.Text;
 using System.Diagnostics;
 using Cimian.CLI.managedsoftwareupdate.Models;
 using Cimian.Core.Services;
+using UsageDownload = Cimian.Core.Models.UsageDownload;
 
 namespace Cimian.CLI.managedsoftwareupdate.Services;
 
 /// <summary>
 /// Service for downloading packages with hash verification
-/// Features: HEAD request for size, resumable downloads, bandwidth monitoring
+/// Features: HEAD request for size, resumable downloads, bandwidth monitoring,
+/// parallel batch downloads (MaxConcurrentDownloads) under a shared bandwidth cap
 /// Migrated from Go pkg/download
 /// </summary>
 public class DownloadService
@@ -27,11 +30,90 @@ public class DownloadService
     private const int BandwidthLogIntervalSeconds = 10;
     private const int MaxRetries = 5;
     private const int BufferSize = 64 * 1024; // 64KB buffer
+    private const int MaxConcurrencyCap = 16;
 
-    public DownloadService(CimianConfig config, HttpClient? httpClient = null)
+    private readonly BandwidthThrottle? _throttle;
+    private readonly int _maxConcurrency;
+    private readonly double _stallThresholdBytesPerSec;
+    private readonly HashSet<string>? _allowedOrigins;
+    private readonly ConcurrentQueue<UsageDownload> _downloads = new();
+    private readonly PeerCacheService _peerCache;
+    private readonly ConcurrentDictionary<string, string> _peerSources = new(StringComparer.OrdinalIgnoreCase);
+
+    /// <summary>
+    /// Raised with the item and URL when an installer download is refused because
+    /// its origin isn't in AllowedDownloadOrigins.
+    /// </summary>
+    public event Action<CatalogItem, string>? OriginRejected;
+
+    /// <summary>
+    /// Every installer fetched through DownloadItemAsync so far, for reports/usage.json.
+    /// </summary>
+    public IReadOnlyList<UsageDownload> Downloads => _downloads.ToArray();
+
+    public DownloadService(CimianConfig config, HttpClient? httpClient = null, PeerCacheService? peerCache = null)
     {
         _config = config;
         _httpClient = httpClient ?? CimianHttpClientFactory.CreateHttpClient(config, Timeout.InfiniteTimeSpan);
+        _peerCache = peerCache ?? new PeerCacheService(config);
+        _maxConcurrency = Math.Clamp(config.MaxConcurrentDownloads, 1, MaxConcurrencyCap);
+        _throttle = BandwidthThrottle.FromConfig(config);
+
+        // A deliberately low bandwidth cap split across workers mustn't look like a stall
+        var lowestCap = BandwidthThrottle.LowestBytesPerSecond(config);
+        _stallThresholdBytesPerSec = lowestCap <= 0
+            ? MinBandwidthBytesPerSec
+            : Math.Min(MinBandwidthBytesPerSec, lowestCap / (double)_maxConcurrency / 2);
+
+        if (config.AllowedDownloadOrigins.Count > 0)
+        {
+            _allowedOrigins = config.AllowedDownloadOrigins
+                .Append(config.SoftwareRepoURL)
+                .Select(NormalizeOrigin)
+                .OfType<string>()
+                .ToHashSet(StringComparer.Ordinal);
+        }
+    }
+
+    /// <summary>
+    /// "https://cdn.example.com" for any http(s) URL on that host (default port
+    /// omitted, lowercased), or null for anything that isn't an http(s) URL.
+    /// </summary>
+    public static string? NormalizeOrigin(string? url)
+    {
+        if (!Uri.TryCreate(url?.Trim(), UriKind.Absolute, out var uri)
+            || (uri.Scheme != Uri.UriSchemeHttp && uri.Scheme != Uri.UriSchemeHttps))
+        {
+            return null;
+        }
+        return uri.GetLeftPart(UriPartial.Authority).ToLowerInvariant();
+    }
+
+    /// <summary>
+    /// True when AllowedDownloadOrigins is empty or the URL's origin is in it
+    /// (or is the SoftwareRepoURL origin).
+    /// </summary>
+    public bool IsOriginAllowed(string url)
+    {
+        return _allowedOrigins == null
+            || (NormalizeOrigin(url) is { } origin && _allowedOrigins.Contains(origin));
+    }
+
+    /// <summary>
+    /// Full URL for one of the item's repo locations, or null (after raising
+    /// <see cref="OriginRejected"/>) when its origin isn't allowed.
+    /// </summary>
+    private string? ResolveItemUrl(CatalogItem item, string location)
+    {
+        var url = BuildFullUrl(location);
+        if (IsOriginAllowed(url))
+        {
+            return url;
+        }
+
+        ConsoleLogger.Error($"Refusing to download {item.Name} from {NormalizeOrigin(url) ?? url}: origin is not in AllowedDownloadOrigins");
+        OriginRejected?.Invoke(item, url);
+        return null;
     }
 
     /// <summary>
@@ -43,6 +125,19 @@ public class DownloadService
         string? expectedHash = null,
         IProgress<double>? progress = null,
         CancellationToken cancellationToken = default)
+    {
+        return (await FetchFileAsync(url, localPath, expectedHash, progress, cancellationToken)).Success;
+    }
+
+    /// <summary>
+    /// DownloadFileAsync, also saying whether the cached copy was used
+    /// </summary>
+    private async Task<(bool Success, bool FromCache)> FetchFileAsync(
+        string url,
+        string localPath,
+        string? expectedHash,
+        IProgress<double>? progress,
+        CancellationToken cancellationToken)
     {
         var dir = Path.GetDirectoryName(localPath);
         if (!string.IsNullOrEmpty(dir))
@@ -59,8 +154,9 @@ public class DownloadService
             if (existingHash.Equals(expectedHash, StringComparison.OrdinalIgnoreCase))
             {
                 ConsoleLogger.Info($"Using cached file: {Path.GetFileName(localPath)}");
+                CacheManager.MarkUsed(localPath);
                 ConsoleLogger.Detail($"    Hash verification passed for cached file: {localPath}");
-                return true;
+                return (true, true);
             }
             ConsoleLogger.Detail($"    Cached file hash mismatch, re-downloading expected: {expectedHash.Substring(0, 12)}... got: {existingHash.Substring(0, 12)}...");
         }
@@ -103,8 +199,19 @@ public class DownloadService
             ConsoleLogger.Detail($"    HEAD request failed, proceeding with default timeout: {ex.Message}");
         }
 
+        // Large payloads are looked for on LAN peers first (PeerCache); anything
+        // they can't serve, or serve wrong, comes from the repo below
+        if (_peerCache.AppliesTo(url, totalBytes, expectedHash)
+            && await _peerCache.TryFetchAsync(url, localPath, expectedHash!, cancellationToken) is { } peerSource)
+        {
+            _peerSources[localPath] = peerSource;
+            progress?.Report(100);
+            return (true, false);
+        }
+
         // Retry loop with resume support
         Exception? lastException = null;
+        var hashMismatches = 0;
         for (int attempt = 1; attempt <= MaxRetries; attempt++)
         {
             try
@@ -181,7 +288,7 @@ public class DownloadService
                     {
                         ConsoleLogger.Warn($"Hash mismatch after download expected: {expectedHash.Substring(0, 12)}... got: {downloadedHash.Substring(0, 12)}...");
                         try { File.Delete(tempPath); } catch { /* ignore */ }
-                        throw new InvalidOperationException($"Hash mismatch: expected {expectedHash}, got {downloadedHash}");
+                        throw new HashMismatchException(expectedHash, downloadedHash);
                     }
                 }
 
@@ -189,7 +296,7 @@ public class DownloadService
                 File.Move(tempPath, localPath, overwrite: true);
 
                 ConsoleLogger.Detail($"    File saved successfully file: {localPath}");
-                return true;
+                return (true, false);
             }
             catch (DownloadStalledException ex)
             {
@@ -207,6 +314,22 @@ public class DownloadService
             {
                 throw; // Don't retry if user cancelled
             }
+            catch (HashMismatchException ex)
+            {
+                // The repo is serving something other than what the catalog describes.
+                // One fresh download rules out transit corruption; past that, retrying
+                // just fetches the same wrong bytes. With mirrors configured, each
+                // mismatch demotes the mirror that served it and the retry goes to the next.
+                lastException = ex;
+                var mirrors = RepoMirrorSet.For(_config);
+                var switchedMirror = mirrors.ReportBadContent(url, "hash mismatch");
+                if (++hashMismatches > Math.Max(1, mirrors.Mirrors.Count - 1))
+                {
+                    ConsoleLogger.Error($"Hash mismatch persisted after re-download: {url}");
+                    break;
+                }
+                ConsoleLogger.Info(switchedMirror ? "Re-downloading from the next mirror after hash mismatch..." : "Re-downloading once after hash mismatch...");
+            }
             catch (Exception ex)
             {
                 lastException = ex;
@@ -221,7 +344,7 @@ public class DownloadService
         }
 
         // All retries exhausted
-        ConsoleLogger.Error($"Failed to download {url} after {MaxRetries} attempts: {lastException?.Message}");
+        ConsoleLogger.Error($"Failed to download {url}: {lastException?.Message}");
         
         // Clean up temp file on final failure (unless it's a stall - keep for next run)
         if (lastException is not DownloadStalledException && File.Exists(tempPath))
@@ -229,7 +352,7 @@ public class DownloadService
             try { File.Delete(tempPath); } catch { /* ignore */ }
         }
         
-        return false;
+        return (false, false);
     }
 
     /// <summary>
@@ -263,6 +386,11 @@ public class DownloadService
             await destination.WriteAsync(buffer.AsMemory(0, bytesRead), cancellationToken);
             written += bytesRead;
 
+            if (_throttle != null)
+            {
+                await _throttle.WaitAsync(bytesRead, cancellationToken);
+            }
+
             var now = DateTime.UtcNow;
 
             // Bandwidth logging every 10 seconds
@@ -287,6 +415,7 @@ public class DownloadService
 
                 lastBandwidthLog = now;
                 lastBandwidthBytes = written;
+                ConsoleLogger.Heartbeat($"Downloading {fileName}");
             }
 
             // Stall detection every 30 seconds
@@ -296,7 +425,7 @@ public class DownloadService
                 var periodSeconds = (now - lastStallCheckTime).TotalSeconds;
                 var currentSpeed = bytesInPeriod / periodSeconds;
 
-                if (currentSpeed < MinBandwidthBytesPerSec)
+                if (currentSpeed < _stallThresholdBytesPerSec)
                 {
                     if (stallWarningIssued)
                     {
@@ -307,7 +436,7 @@ public class DownloadService
                     else
                     {
                         // First stall warning
-                        ConsoleLogger.Warn($"Download speed critically low, monitoring for stall file: {fileName} speed_bytes_sec: {currentSpeed:F0} threshold_bytes_sec: {MinBandwidthBytesPerSec}");
+                        ConsoleLogger.Warn($"Download speed critically low, monitoring for stall file: {fileName} speed_bytes_sec: {currentSpeed:F0} threshold_bytes_sec: {_stallThresholdBytesPerSec:F0}");
                         stallWarningIssued = true;
                     }
                 }
@@ -369,49 +498,181 @@ public class DownloadService
             return null;
         }
 
-        var url = BuildFullUrl(item.Installer.Location);
+        using var span = DiagnosticTrace.Begin("download", item.Name);
+        if (item.Installer.IsDelta)
+        {
+            var deltaPath = await DownloadDeltaItemAsync(item, progress, cancellationToken);
+            if (deltaPath == null) span.Fail();
+            return deltaPath;
+        }
+
+        var url = ResolveItemUrl(item, item.Installer.Location);
+        if (url == null)
+        {
+            span.Fail();
+            return null;
+        }
         var localPath = GetCachePath(item);
 
-        var success = await DownloadFileAsync(
+        var stopwatch = Stopwatch.StartNew();
+        var (success, fromCache) = await FetchFileAsync(
             url,
             localPath,
             item.Installer.Hash,
             progress,
             cancellationToken);
+        RecordDownload(item, url, localPath, success, fromCache, stopwatch);
+        if (!success) span.Fail();
 
         return success ? localPath : null;
     }
 
     /// <summary>
-    /// Downloads multiple items
+    /// Produces the full installer for a type: delta item. When the base version is
+    /// in the cache with the expected hash, only the (small) patch is downloaded and
+    /// applied; the result must match full_hash. Anything else — no cached base,
+    /// base hash mismatch, patch download or apply failure — falls back to
+    /// downloading full_location, so a delta item never fails where a full one
+    /// would have succeeded.
+    /// </summary>
+    private async Task<string?> DownloadDeltaItemAsync(
+        CatalogItem item,
+        IProgress<double>? progress,
+        CancellationToken cancellationToken)
+    {
+        var installer = item.Installer;
+        var targetPath = GetCachePath(item);
+        var stopwatch = Stopwatch.StartNew();
+
+        if (File.Exists(targetPath) && HashMatches(targetPath, installer.FullHash))
+        {
+            ConsoleLogger.Info($"Using cached file: {Path.GetFileName(targetPath)}");
+            CacheManager.MarkUsed(targetPath);
+            RecordDownload(item, null, targetPath, true, true, stopwatch);
+            return targetPath;
+        }
+
+        var basePath = string.IsNullOrEmpty(installer.BaseLocation) ? null : GetCachePath(item, installer.BaseLocation);
+        if (basePath != null && File.Exists(basePath) && HashMatches(basePath, installer.BaseHash))
+        {
+            var patchPath = GetCachePath(item, installer.Location);
+            var patchUrl = ResolveItemUrl(item, installer.Location);
+            var patched = false;
+            if (patchUrl != null)
+            {
+                (patched, var patchFromCache) = await FetchFileAsync(patchUrl, patchPath, installer.Hash, progress, cancellationToken);
+                RecordDownload(item, patchUrl, patchPath, patched, patchFromCache, stopwatch);
+            }
+            if (patched)
+            {
+                var patchedPath = targetPath + ".patching";
+                if (DeltaPatcher.TryApply(basePath, patchPath, patchedPath, out var error))
+                {
+                    if (HashMatches(patchedPath, installer.FullHash))
+                    {
+                        File.Move(patchedPath, targetPath, overwrite: true);
+                        TryDelete(patchPath);
+                        ConsoleLogger.Info($"Applied delta update for {item.Name}: {new FileInfo(targetPath).Length / (1024 * 1024)} MB installer rebuilt from {Path.GetFileName(basePath)}");
+                        return targetPath;
+                    }
+                    ConsoleLogger.Warn($"Delta result for {item.Name} does not match full_hash; downloading full installer");
+                    TryDelete(patchedPath);
+                }
+                else
+                {
+                    ConsoleLogger.Warn($"Could not apply delta for {item.Name}: {error}; downloading full installer");
+                }
+            }
+            TryDelete(patchPath);
+        }
+        else
+        {
+            ConsoleLogger.Detail($"    Base installer for {item.Name} delta not cached or hash differs; downloading full installer");
+        }
+
+        if (string.IsNullOrEmpty(installer.FullLocation))
+        {
+            ConsoleLogger.Error($"Delta item {item.Name} has no full_location to fall back to");
+            return null;
+        }
+
+        var fullUrl = ResolveItemUrl(item, installer.FullLocation);
+        if (fullUrl == null)
+        {
+            return null;
+        }
+        stopwatch.Restart();
+        var (success, fromCache) = await FetchFileAsync(fullUrl, targetPath, installer.FullHash, progress, cancellationToken);
+        RecordDownload(item, fullUrl, targetPath, success, fromCache, stopwatch);
+        return success ? targetPath : null;
+    }
+
+    private void RecordDownload(CatalogItem item, string? url, string path, bool success, bool fromCache, Stopwatch stopwatch)
+    {
+        _downloads.Enqueue(new UsageDownload
+        {
+            Item = item.Name,
+            Version = item.Version,
+            Source = fromCache ? "cache" : _peerSources.TryRemove(path, out var peer) ? peer : NormalizeOrigin(url) ?? "",
+            CacheHit = fromCache,
+            Success = success,
+            DurationMs = stopwatch.ElapsedMilliseconds,
+            Bytes = success && File.Exists(path) ? new FileInfo(path).Length : 0
+        });
+    }
+
+    private static bool HashMatches(string path, string? expectedHash) =>
+        string.IsNullOrEmpty(expectedHash) || CalculateSHA256(path).Equals(expectedHash, StringComparison.OrdinalIgnoreCase);
+
+    private static void TryDelete(string path)
+    {
+        try { if (File.Exists(path)) File.Delete(path); } catch { /* best effort */ }
+    }
+
+    /// <summary>
+    /// Downloads multiple items on a pool of MaxConcurrentDownloads workers.
+    /// Each item reports 0 when its download starts, then percent progress, so the
+    /// status reporter can show every in-flight row. Results are keyed by item name;
+    /// failed and script-only items are absent.
     /// </summary>
     public async Task<Dictionary<string, string>> DownloadItemsAsync(
         IEnumerable<CatalogItem> items,
         IProgress<(string ItemName, double Percent)>? progress = null,
         CancellationToken cancellationToken = default)
     {
-        var result = new Dictionary<string, string>();
+        var result = new ConcurrentDictionary<string, string>();
         var itemList = items.ToList();
         var count = 0;
 
-        foreach (var item in itemList)
+        if (itemList.Count > 1 && _maxConcurrency > 1)
+        {
+            ConsoleLogger.Detail($"    Downloading {itemList.Count} items with {Math.Min(_maxConcurrency, itemList.Count)} workers");
+        }
+
+        var options = new ParallelOptions
+        {
+            MaxDegreeOfParallelism = _maxConcurrency,
+            CancellationToken = cancellationToken
+        };
+
+        await Parallel.ForEachAsync(itemList, options, async (item, ct) =>
         {
-            count++;
+            progress?.Report((item.Name, 0));
             var itemProgress = new Progress<double>(p =>
             {
                 progress?.Report((item.Name, p));
             });
 
-            var path = await DownloadItemAsync(item, itemProgress, cancellationToken);
+            var path = await DownloadItemAsync(item, itemProgress, ct);
             if (!string.IsNullOrEmpty(path))
             {
                 result[item.Name] = path;
             }
 
-            ConsoleLogger.Info($"Downloaded {count}/{itemList.Count}: {item.Name}");
-        }
+            ConsoleLogger.Info($"Downloaded {Interlocked.Increment(ref count)}/{itemList.Count}: {item.Name}");
+        });
 
-        return result;
+        return new Dictionary<string, string>(result);
     }
 
     /// <summary>
@@ -438,16 +699,59 @@ public class DownloadService
     /// </summary>
     public string GetCachePath(CatalogItem item)
     {
-        var fileName = Path.GetFileName(item.Installer.Location);
+        // Delta items resolve to the reconstructed full installer, which is what
+        // gets installed and what precache/status checks care about.
+        var location = item.Installer.IsDelta && !string.IsNullOrEmpty(item.Installer.FullLocation)
+            ? item.Installer.FullLocation
+            : item.Installer.Location;
+        return GetCachePath(item, location);
+    }
+
+    /// <summary>
+    /// Gets the local cache path for an arbitrary repo location belonging to the item
+    /// </summary>
+    public string GetCachePath(CatalogItem item, string location) =>
+        CachePathFor(_config.CachePath, item, location);
+
+    internal static string CachePathFor(string cacheRoot, CatalogItem item, string location)
+    {
+        var fileName = Path.GetFileName(location);
         
         // Organize by category if available
         if (!string.IsNullOrEmpty(item.Category))
         {
             var categoryPath = item.Category.Replace(" ", "_").ToLowerInvariant();
-            return Path.Combine(_config.CachePath, categoryPath, fileName);
+            return Path.Combine(cacheRoot, categoryPath, fileName);
         }
 
-        return Path.Combine(_config.CachePath, fileName);
+        return Path.Combine(cacheRoot, fileName);
+    }
+
+    /// <summary>
+    /// Checks a downloaded payload against the catalog hash immediately before it
+    /// is installed, catching cache files that were altered or corrupted after
+    /// download. Delta items are checked against full_hash, the hash of the
+    /// rebuilt installer that actually runs.
+    /// </summary>
+    public static PayloadVerification VerifyPayload(CatalogItem item, string localPath, bool requireHash)
+    {
+        var expected = item.Installer.IsDelta ? item.Installer.FullHash : item.Installer.Hash;
+        if (string.IsNullOrEmpty(expected))
+        {
+            return new PayloadVerification(
+                requireHash ? PayloadVerificationStatus.MissingHash : PayloadVerificationStatus.NotChecked,
+                null, null);
+        }
+        if (!File.Exists(localPath))
+        {
+            return new PayloadVerification(PayloadVerificationStatus.Missing, expected, null);
+        }
+
+        var actual = CalculateSHA256(localPath);
+        var status = actual.Equals(expected, StringComparison.OrdinalIgnoreCase)
+            ? PayloadVerificationStatus.Valid
+            : PayloadVerificationStatus.Mismatch;
+        return new PayloadVerification(status, expected, actual);
     }
 
     /// <summary>
@@ -550,6 +854,47 @@ public class DownloadService
         return (files.Length, totalSize, corruptCount);
     }
 
+    /// <summary>
+    /// Deletes everything the cache holds for an item that is no longer managed:
+    /// the installer, delta base/patch/rebuilt files, and any partial downloads.
+    /// Returns the number of files and bytes freed.
+    /// </summary>
+    public (int FileCount, long BytesFreed) PurgeItemCache(CatalogItem item)
+    {
+        var installer = item.Installer;
+        var locations = new[] { installer.Location, installer.FullLocation, installer.BaseLocation }
+            .Where(l => !string.IsNullOrEmpty(l))
+            .Select(l => GetCachePath(item, l!))
+            .Distinct(StringComparer.OrdinalIgnoreCase);
+
+        var fileCount = 0;
+        var bytesFreed = 0L;
+        foreach (var path in locations)
+        {
+            foreach (var candidate in new[] { path, path + ".downloading", path + ".patching" })
+            {
+                if (!File.Exists(candidate))
+                {
+                    continue;
+                }
+                try
+                {
+                    var length = new FileInfo(candidate).Length;
+                    File.Delete(candidate);
+                    fileCount++;
+                    bytesFreed += length;
+                    ConsoleLogger.Debug($"Purged cached file: {candidate}");
+                }
+                catch (Exception ex)
+                {
+                    ConsoleLogger.Warn($"Failed to purge cached file {candidate}: {ex.Message}");
+                }
+            }
+        }
+
+        return (fileCount, bytesFreed);
+    }
+
     /// <summary>
     /// Clears the cache selectively based on successful installations
     /// </summary>
@@ -586,6 +931,41 @@ public class DownloadService
     }
 }
 
+/// <summary>
+/// Outcome of <see cref="DownloadService.VerifyPayload"/>.
+/// </summary>
+public enum PayloadVerificationStatus
+{
+    Valid,
+    Mismatch,
+    Missing,
+    /// <summary>No catalog hash and RequireHashValidation is on.</summary>
+    MissingHash,
+    /// <summary>No catalog hash and RequireHashValidation is off.</summary>
+    NotChecked
+}
+
+public record PayloadVerification(PayloadVerificationStatus Status, string? ExpectedHash, string? ActualHash)
+{
+    public bool CanInstall => Status is PayloadVerificationStatus.Valid or PayloadVerificationStatus.NotChecked;
+}
+
+/// <summary>
+/// Thrown when a finished download doesn't match the catalog hash
+/// </summary>
+public class HashMismatchException : InvalidOperationException
+{
+    public string ExpectedHash { get; }
+    public string ActualHash { get; }
+
+    public HashMismatchException(string expectedHash, string actualHash)
+        : base($"Hash mismatch: expected {expectedHash}, got {actualHash}")
+    {
+        ExpectedHash = expectedHash;
+        ActualHash = actualHash;
+    }
+}
+
 /// <summary>
 /// Exception thrown when a download stalls due to low bandwidth
 /// The partial file is preserved to allow resume on retry
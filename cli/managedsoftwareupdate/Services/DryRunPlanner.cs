@@ -0,0 +1,233 @@
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using Cimian.CLI.managedsoftwareupdate.Models;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// One action managedsoftwareupdate would take in a --dry-run.
+/// </summary>
+public class DryRunAction
+{
+    public int Order { get; set; }
+    public string Name { get; set; } = string.Empty;
+    public string Version { get; set; } = string.Empty;
+    /// <summary>install, update, uninstall or self_update.</summary>
+    public string Action { get; set; } = string.Empty;
+    public string? InstallerType { get; set; }
+    public string? DownloadUrl { get; set; }
+    public string? CachePath { get; set; }
+    public string? Hash { get; set; }
+    /// <summary>How the uninstall would be performed (uninstall actions only).</summary>
+    public string? UninstallMethod { get; set; }
+    public List<string> Requires { get; set; } = new();
+    public List<string> BlockingApplications { get; set; } = new();
+    /// <summary>Scripts that would run, in execution order.</summary>
+    public List<string> Scripts { get; set; } = new();
+    public string? RestartAction { get; set; }
+    public bool Unattended { get; set; }
+    public List<string> Warnings { get; set; } = new();
+}
+
+/// <summary>
+/// An item that needs action but was held back this run, and why.
+/// </summary>
+public class DryRunDeferral
+{
+    public string Name { get; set; } = string.Empty;
+    public string Version { get; set; } = string.Empty;
+    public string Reason { get; set; } = string.Empty;
+}
+
+/// <summary>
+/// Structured --dry-run output: every action in the order it would execute,
+/// plus everything deferred. Stable snake_case JSON so CI can diff or assert on it.
+/// </summary>
+public class DryRunPlan
+{
+    public DateTime GeneratedAt { get; set; } = DateTime.UtcNow;
+    public string ClientIdentifier { get; set; } = string.Empty;
+    public string SoftwareRepoUrl { get; set; } = string.Empty;
+    public List<string> Catalogs { get; set; } = new();
+    public int ManifestItemCount { get; set; }
+    public List<DryRunAction> Actions { get; set; } = new();
+    public List<DryRunDeferral> Deferred { get; set; } = new();
+}
+
+/// <summary>
+/// Builds the --dry-run plan. Walks the same decisions as a real run — dependency
+/// order, download URL / cache path, installer type, blocking apps, script
+/// selection, uninstall method — but never downloads or executes anything.
+/// </summary>
+public class DryRunPlanner
+{
+    private static readonly JsonSerializerOptions JsonOptions = new()
+    {
+        WriteIndented = true,
+        PropertyNamingPolicy = JsonNamingPolicy.SnakeCaseLower,
+        DefaultIgnoreCondition = JsonIgnoreCondition.WhenWritingNull
+    };
+
+    private readonly CimianConfig _config;
+    private readonly DownloadService _downloadService;
+
+    public DryRunPlanner(CimianConfig config, DownloadService downloadService)
+    {
+        _config = config;
+        _downloadService = downloadService;
+    }
+
+    /// <summary>
+    /// Builds the plan. Installs/updates run first (dependencies before
+    /// dependents, matching ProcessInstallWithDependenciesAsync), then uninstalls.
+    /// </summary>
+    public DryRunPlan Build(
+        int manifestItemCount,
+        List<CatalogItem> toInstall,
+        List<CatalogItem> toUpdate,
+        List<CatalogItem> toUninstall,
+        Dictionary<string, CatalogItem> catalogMap,
+        IEnumerable<(CatalogItem Item, string Reason)> deferred)
+    {
+        var plan = new DryRunPlan
+        {
+            ClientIdentifier = _config.ClientIdentifier,
+            SoftwareRepoUrl = _config.SoftwareRepoURL,
+            Catalogs = _config.Catalogs.ToList(),
+            ManifestItemCount = manifestItemCount
+        };
+
+        var updateNames = new HashSet<string>(toUpdate.Select(i => i.Name), StringComparer.OrdinalIgnoreCase);
+        foreach (var item in OrderByDependencies(toInstall.Concat(toUpdate).ToList()))
+        {
+            var action = StatusService.IsCimianPackage(item) ? "self_update"
+                : updateNames.Contains(item.Name) ? "update" : "install";
+            plan.Actions.Add(BuildInstallAction(item, action, catalogMap));
+        }
+
+        foreach (var item in toUninstall)
+        {
+            plan.Actions.Add(BuildUninstallAction(item));
+        }
+
+        for (var i = 0; i < plan.Actions.Count; i++)
+        {
+            plan.Actions[i].Order = i + 1;
+        }
+
+        plan.Deferred = deferred
+            .Select(d => new DryRunDeferral { Name = d.Item.Name, Version = d.Item.Version, Reason = d.Reason })
+            .ToList();
+
+        return plan;
+    }
+
+    /// <summary>
+    /// Serializes the plan to snake_case JSON.
+    /// </summary>
+    public static string ToJson(DryRunPlan plan) => JsonSerializer.Serialize(plan, JsonOptions);
+
+    /// <summary>
+    /// Orders items so every scheduled `requires` entry precedes its dependent.
+    /// Requirements that aren't scheduled are already satisfied and ignored; cycles
+    /// are broken at the first revisit rather than looping.
+    /// </summary>
+    internal static List<CatalogItem> OrderByDependencies(List<CatalogItem> items)
+    {
+        var scheduled = items.ToDictionary(i => i.Name, StringComparer.OrdinalIgnoreCase);
+        var ordered = new List<CatalogItem>();
+        var visited = new HashSet<string>(StringComparer.OrdinalIgnoreCase);
+
+        void Visit(CatalogItem item)
+        {
+            if (!visited.Add(item.Name)) return;
+            foreach (var req in item.Requires)
+            {
+                var (reqName, _) = CatalogService.SplitNameAndVersion(req);
+                if (scheduled.TryGetValue(reqName, out var dep))
+                {
+                    Visit(dep);
+                }
+            }
+            ordered.Add(item);
+        }
+
+        foreach (var item in items)
+        {
+            Visit(item);
+        }
+        return ordered;
+    }
+
+    private DryRunAction BuildInstallAction(CatalogItem item, string action, Dictionary<string, CatalogItem> catalogMap)
+    {
+        var entry = new DryRunAction
+        {
+            Name = item.Name,
+            Version = item.Version,
+            Action = action,
+            Requires = item.Requires.ToList(),
+            BlockingApplications = item.BlockingApps.ToList(),
+            RestartAction = item.EffectiveRestartAction,
+            Unattended = item.UnattendedInstall
+        };
+
+        if (!string.IsNullOrEmpty(item.Installer.Location))
+        {
+            entry.DownloadUrl = _downloadService.BuildFullUrl(item.Installer.Location);
+            entry.CachePath = _downloadService.GetCachePath(item);
+            entry.Hash = item.Installer.Hash;
+            entry.InstallerType = InstallerService.GetInstallerType(item, entry.CachePath);
+            if (string.IsNullOrEmpty(item.Installer.Hash))
+            {
+                entry.Warnings.Add("installer has no hash; download would not be verified");
+            }
+        }
+        else
+        {
+            entry.InstallerType = InstallerService.GetInstallerType(item, string.Empty);
+        }
+
+        foreach (var req in item.Requires)
+        {
+            var (reqName, _) = CatalogService.SplitNameAndVersion(req);
+            if (!catalogMap.ContainsKey(reqName))
+            {
+                entry.Warnings.Add($"requires '{req}' which is not in any catalog");
+            }
+        }
+
+        entry.Scripts.AddRange(item.PreinstallScripts.Select(s => $"preinstall_scripts: {s.Name}"));
+        if (!string.IsNullOrEmpty(item.PreinstallScript)) entry.Scripts.Add("preinstall_script");
+        if (entry.InstallerType is "nopkg" or "script" && !string.IsNullOrEmpty(item.InstallScript)) entry.Scripts.Add("install_script");
+        if (entry.InstallerType == "configuration") entry.Scripts.Add(item.Configuration?.DscResource != null ? "dsc_resource (Set)" : "set_script");
+        if (!string.IsNullOrEmpty(item.PostinstallScript)) entry.Scripts.Add("postinstall_script");
+        entry.Scripts.AddRange(item.PostinstallScripts.Select(s => $"postinstall_scripts: {s.Name}"));
+
+        return entry;
+    }
+
+    private static DryRunAction BuildUninstallAction(CatalogItem item)
+    {
+        var entry = new DryRunAction
+        {
+            Name = item.Name,
+            Version = item.Version,
+            Action = "uninstall",
+            UninstallMethod = InstallerService.DescribeUninstallMethod(item),
+            BlockingApplications = item.BlockingApps.ToList(),
+            RestartAction = item.EffectiveRestartAction,
+            Unattended = item.UnattendedUninstall
+        };
+
+        if (!string.IsNullOrEmpty(item.PreuninstallScript)) entry.Scripts.Add("preuninstall_script");
+        if (entry.UninstallMethod == "uninstall_script") entry.Scripts.Add("uninstall_script");
+        if (!string.IsNullOrEmpty(item.PostuninstallScript)) entry.Scripts.Add("postuninstall_script");
+        if (entry.UninstallMethod == "none")
+        {
+            entry.Warnings.Add("no uninstall method available; removal would fail");
+        }
+
+        return entry;
+    }
+}
@@ -0,0 +1,121 @@
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core.Services;
+using Microsoft.Win32;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>InstallationState values the Enrollment Status Page reads for each tracked app.</summary>
+public static class EspInstallationState
+{
+    public const int NotInstalled = 1;
+    public const int InProgress = 2;
+    public const int Completed = 3;
+    public const int Error = 4;
+}
+
+/// <summary>
+/// Reports bootstrap progress to Intune's Enrollment Status Page (EspIntegration)
+/// so Autopilot provisioning waits for Cimian's core apps. Two things are written,
+/// after every bootstrap attempt:
+///
+/// - HKLM\SOFTWARE\Cimian\EnrollmentStatus: the bootstrap's state, for the
+///   registry detection rule of an Intune Win32 app that ESP blocks on.
+/// - While ESP is tracking the device, an InstallationState entry per core app
+///   (EspTrackedItems) under ESP's Sidecar app tracking key, so each app shows
+///   as in progress, installed or failed with Intune's own.
+/// </summary>
+public class EnrollmentStatusReporter
+{
+    internal const string EnrollmentTrackingPath = @"SOFTWARE\Microsoft\Windows\Autopilot\EnrollmentStatusTracking\Device\Setup";
+    internal const string SidecarTrackingPath = EnrollmentTrackingPath + @"\Apps\Tracking\Sidecar";
+    internal const string MarkerPath = @"SOFTWARE\Cimian\EnrollmentStatus";
+
+    private readonly CimianConfig _config;
+
+    public EnrollmentStatusReporter(CimianConfig config)
+    {
+        _config = config;
+    }
+
+    /// <summary>
+    /// The apps ESP is told about: EspTrackedItems, or with none listed, every
+    /// item the bootstrap has installed or is still retrying.
+    /// </summary>
+    public static List<string> TrackedItems(CimianConfig config, BootstrapProgress progress)
+    {
+        var items = config.EspTrackedItems.Count > 0
+            ? config.EspTrackedItems
+            : progress.CompletedItems.Concat(progress.Remaining);
+        return items
+            .Where(n => !string.IsNullOrWhiteSpace(n))
+            .Select(n => n.Trim())
+            .Distinct(StringComparer.OrdinalIgnoreCase)
+            .ToList();
+    }
+
+    /// <summary>
+    /// An app's InstallationState: installed once any attempt installed it (or
+    /// the bootstrap completed, which means nothing was left to do), failed
+    /// once the bootstrap gives up, and otherwise in progress after the first
+    /// attempt starts.
+    /// </summary>
+    public static int StateOf(string item, BootstrapProgress progress)
+    {
+        if (progress.Status == BootstrapProgress.Completed
+            || progress.CompletedItems.Contains(item, StringComparer.OrdinalIgnoreCase))
+        {
+            return EspInstallationState.Completed;
+        }
+        if (progress.Status == BootstrapProgress.Exhausted)
+        {
+            return EspInstallationState.Error;
+        }
+        return progress.Attempts > 0 ? EspInstallationState.InProgress : EspInstallationState.NotInstalled;
+    }
+
+    /// <summary>ESP tracking key name for an item, registry-safe and apart from Intune's own Win32App_&lt;guid&gt; entries.</summary>
+    public static string TrackingKeyName(string item)
+    {
+        var safe = new string(item.Select(c => char.IsLetterOrDigit(c) || c is '-' or '.' ? c : '_').ToArray());
+        return $"Win32App_Cimian_{safe}_1";
+    }
+
+    public void Report(BootstrapProgress progress)
+    {
+        if (!_config.EspIntegration || !OperatingSystem.IsWindows())
+        {
+            return;
+        }
+
+        var tracked = TrackedItems(_config, progress);
+        try
+        {
+            using (var marker = Registry.LocalMachine.CreateSubKey(MarkerPath, writable: true))
+            {
+                marker.SetValue("State", progress.Status, RegistryValueKind.String);
+                marker.SetValue("Attempts", progress.Attempts, RegistryValueKind.DWord);
+                marker.SetValue("TrackedInstalled", tracked.Count(i => StateOf(i, progress) == EspInstallationState.Completed), RegistryValueKind.DWord);
+                marker.SetValue("TrackedTotal", tracked.Count, RegistryValueKind.DWord);
+                marker.SetValue("Remaining", progress.Remaining.ToArray(), RegistryValueKind.MultiString);
+                marker.SetValue("Updated", DateTime.Now.ToString("O"), RegistryValueKind.String);
+            }
+
+            // Only while Autopilot is provisioning; afterwards nothing reads the tracking keys
+            using var enrollment = Registry.LocalMachine.OpenSubKey(EnrollmentTrackingPath);
+            if (enrollment == null)
+            {
+                return;
+            }
+            foreach (var item in tracked)
+            {
+                using var app = Registry.LocalMachine.CreateSubKey($@"{SidecarTrackingPath}\{TrackingKeyName(item)}", writable: true);
+                app.SetValue("InstallationState", StateOf(item, progress), RegistryValueKind.DWord);
+            }
+            ConsoleLogger.Info($"Enrollment Status Page: {tracked.Count(i => StateOf(i, progress) == EspInstallationState.Completed)} of {tracked.Count} core apps installed ({progress.Status})");
+        }
+        catch (Exception ex) when (ex is UnauthorizedAccessException or IOException or System.Security.SecurityException)
+        {
+            ConsoleLogger.Warn($"Could not report to the Enrollment Status Page: {ex.Message}");
+        }
+    }
+}
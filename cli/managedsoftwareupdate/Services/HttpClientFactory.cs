@@ -1,8 +1,10 @@
+using System.Net;
 using System.Net.Http.Headers;
 using System.Net.Security;
 using System.Security.Cryptography.X509Certificates;
 using System.Text;
 using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core.Models;
 using Cimian.Core.Services;
 
 namespace Cimian.CLI.managedsoftwareupdate.Services;
@@ -16,11 +18,21 @@ public static class CimianHttpClientFactory
 {
     /// <summary>
     /// Creates an HttpClient configured with authentication and optional client certificates.
-    /// Auth priority: DPAPI registry → Bearer token → Basic auth.
+    /// Auth priority: DPAPI registry → Bearer token → Basic auth, unless RepoBackend is
+    /// s3 or azblob, whose handlers sign each request instead.
+    /// Requests made through GetAsync ask for HTTP/2 (falling back to HTTP/1.1) unless
+    /// DisableHttp2 is set. <paramref name="acceptCompressed"/> advertises gzip, deflate,
+    /// br and zstd and decodes the response transparently; it is meant for catalogs and
+    /// manifests, not installers, whose ranged resumes need the bytes as stored.
     /// </summary>
-    public static HttpClient CreateHttpClient(CimianConfig config, TimeSpan? timeout = null)
+    public static HttpClient CreateHttpClient(CimianConfig config, TimeSpan? timeout = null, bool acceptCompressed = false)
     {
         var handler = new HttpClientHandler();
+        ProxySelector.Apply(handler, config.ProxySettings);
+        if (acceptCompressed)
+        {
+            handler.AutomaticDecompression = DecompressionMethods.GZip | DecompressionMethods.Deflate | DecompressionMethods.Brotli;
+        }
 
         // SSL client certificate support
         if (config.UseClientCertificate)
@@ -44,38 +56,77 @@ public static class CimianHttpClientFactory
             }
         }
 
-        var client = new HttpClient(handler)
+        HttpMessageHandler pipeline = acceptCompressed
+            ? new ZstdDecompressionHandler { InnerHandler = handler }
+            : handler;
+
+        // Object storage backends authenticate every request themselves
+        var backend = RepoBackend.Normalize(config.RepoBackend) ?? RepoBackend.Http;
+        var mirrors = RepoMirrorSet.For(config);
+        var repoHosts = mirrors.Mirrors
+            .Select(m => Uri.TryCreate(m, UriKind.Absolute, out var uri) ? uri.Host : null)
+            .OfType<string>()
+            .ToList();
+        if (backend == RepoBackend.S3)
+        {
+            pipeline = new S3SigningHandler(repoHosts, config.RepoRegion) { InnerHandler = pipeline };
+        }
+        else if (backend == RepoBackend.AzureBlob)
+        {
+            pipeline = new AzureBlobAuthHandler(repoHosts, config.AuthToken, config.RepoManagedIdentityClientId) { InnerHandler = pipeline };
+        }
+
+        // Outermost, so each mirror attempt is signed for its own host
+        pipeline = new MirrorFailoverHandler(mirrors) { InnerHandler = pipeline };
+
+        var client = new HttpClient(pipeline)
         {
             Timeout = timeout ?? TimeSpan.FromSeconds(60)
         };
+        if (!config.DisableHttp2)
+        {
+            client.DefaultRequestVersion = HttpVersion.Version20;
+            client.DefaultVersionPolicy = HttpVersionPolicy.RequestVersionOrLower;
+        }
+
+        // Object storage backends sign each request in their handler instead
+        if (backend == RepoBackend.Http)
+        {
+            client.DefaultRequestHeaders.Authorization = GetRepoAuthorization(config);
+        }
+
+        client.DefaultRequestHeaders.Add("User-Agent", "Cimian-ManagedSoftwareUpdate/1.0");
 
-        // Auth priority: DPAPI registry → Bearer token → Basic auth
+        return client;
+    }
+
+    /// <summary>
+    /// The Authorization header for an http repo, or null when none is configured.
+    /// Auth priority: DPAPI registry → Bearer token → Basic auth.
+    /// </summary>
+    public static AuthenticationHeaderValue? GetRepoAuthorization(CimianConfig config)
+    {
         var authHeader = AuthService.GetAuthHeader();
         if (!string.IsNullOrEmpty(authHeader))
         {
-            client.DefaultRequestHeaders.Authorization =
-                new AuthenticationHeaderValue("Basic", authHeader);
+            return new AuthenticationHeaderValue("Basic", authHeader);
         }
-        else if (!string.IsNullOrEmpty(config.AuthToken))
+        if (!string.IsNullOrEmpty(config.AuthToken))
         {
-            client.DefaultRequestHeaders.Authorization =
-                new AuthenticationHeaderValue("Bearer", config.AuthToken);
+            return new AuthenticationHeaderValue("Bearer", config.AuthToken);
         }
-        else if (!string.IsNullOrEmpty(config.AuthUser) && !string.IsNullOrEmpty(config.AuthPassword))
+        if (!string.IsNullOrEmpty(config.AuthUser) && !string.IsNullOrEmpty(config.AuthPassword))
         {
             var credentials = Convert.ToBase64String(
                 Encoding.UTF8.GetBytes($"{config.AuthUser}:{config.AuthPassword}"));
-            client.DefaultRequestHeaders.Authorization =
-                new AuthenticationHeaderValue("Basic", credentials);
+            return new AuthenticationHeaderValue("Basic", credentials);
         }
-
-        client.DefaultRequestHeaders.Add("User-Agent", "Cimian-ManagedSoftwareUpdate/1.0");
-
-        return client;
+        return null;
     }
 
     /// <summary>
-    /// Loads a client certificate from file (PEM or PFX) or Windows Certificate Store.
+    /// Loads a client certificate from file (PEM or PFX) or the Windows Certificate
+    /// Store, by thumbprint or by certificate template.
     /// PEM format uses separate cert + key files (Munki-compatible).
     /// PFX format uses a single file with optional password.
     /// </summary>
@@ -116,31 +167,13 @@ public static class CimianHttpClientFactory
         // Option 2: Windows Certificate Store by thumbprint
         if (!string.IsNullOrEmpty(config.ClientCertificateThumbprint))
         {
-            var thumbprint = config.ClientCertificateThumbprint.Replace(" ", "").ToUpperInvariant();
-
-            // Search LocalMachine\My first, then CurrentUser\My
-            foreach (var location in new[] { StoreLocation.LocalMachine, StoreLocation.CurrentUser })
-            {
-                using var store = new X509Store(StoreName.My, location);
-                try
-                {
-                    store.Open(OpenFlags.ReadOnly);
-                    var certs = store.Certificates.Find(
-                        X509FindType.FindByThumbprint, thumbprint, validOnly: false);
-
-                    if (certs.Count > 0)
-                    {
-                        ConsoleLogger.Detail($"    Found client certificate in {location}\\My store");
-                        return certs[0];
-                    }
-                }
-                catch (Exception ex)
-                {
-                    ConsoleLogger.Detail($"    Could not search {location}\\My store: {ex.Message}");
-                }
-            }
+            return ClientCertificateStore.FindByThumbprint(config.ClientCertificateThumbprint);
+        }
 
-            ConsoleLogger.Warn($"Client certificate with thumbprint {thumbprint} not found in any store");
+        // Option 3: machine certificate issued from a template (survives auto-enrollment renewals)
+        if (!string.IsNullOrEmpty(config.ClientCertificateTemplate))
+        {
+            return ClientCertificateStore.FindByTemplate(config.ClientCertificateTemplate);
         }
 
         return null;
@@ -248,7 +281,7 @@ public static class CimianHttpClientFactory
             {
                 cert = X509CertificateLoader.LoadCertificateFromFile(config.ClientCertificatePath);
             }
-            else if (!string.IsNullOrEmpty(config.ClientCertificateThumbprint))
+            else if (!string.IsNullOrEmpty(config.ClientCertificateThumbprint) || !string.IsNullOrEmpty(config.ClientCertificateTemplate))
             {
                 cert = LoadClientCertificate(config);
             }
@@ -0,0 +1,108 @@
+using System.Net.Http.Headers;
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+public class HttpValidators
+{
+    [JsonPropertyName("etag")]
+    public string? ETag { get; set; }
+
+    [JsonPropertyName("last_modified")]
+    public DateTimeOffset? LastModified { get; set; }
+}
+
+/// <summary>
+/// ETag and Last-Modified from the last successful download of each URL, kept
+/// next to the downloaded files, so the next run can send a conditional GET and
+/// reuse its local copy on 304 Not Modified. Validators are only sent while the
+/// local copy still exists.
+/// </summary>
+public class HttpValidatorStore
+{
+    public const string FileName = "_validators.json";
+
+    private readonly string _path;
+    private Dictionary<string, HttpValidators>? _entries;
+
+    /// <param name="directory">Directory holding the downloaded files; the store is <see cref="FileName"/> in it.</param>
+    public HttpValidatorStore(string directory)
+    {
+        _path = Path.Combine(directory, FileName);
+    }
+
+    public HttpValidators? Get(string url) => Entries.TryGetValue(url, out var validators) ? validators : null;
+
+    /// <summary>
+    /// Adds If-None-Match / If-Modified-Since for <paramref name="request"/>'s URL
+    /// when <paramref name="localPath"/> exists and validators were recorded for it.
+    /// </summary>
+    public void ApplyTo(HttpRequestMessage request, string localPath)
+    {
+        if (!File.Exists(localPath) || Get(request.RequestUri!.ToString()) is not { } validators)
+        {
+            return;
+        }
+        if (validators.ETag != null && EntityTagHeaderValue.TryParse(validators.ETag, out var etag))
+        {
+            request.Headers.IfNoneMatch.Add(etag);
+        }
+        if (validators.LastModified is { } lastModified)
+        {
+            request.Headers.IfModifiedSince = lastModified;
+        }
+    }
+
+    /// <summary>
+    /// Records the validators of a 200 response whose body was saved locally, or
+    /// forgets the URL's old ones if the server sent none.
+    /// </summary>
+    public void Record(string url, HttpResponseMessage response)
+    {
+        var etag = response.Headers.ETag?.ToString();
+        var lastModified = response.Content.Headers.LastModified;
+        if (etag == null && lastModified == null)
+        {
+            if (Entries.Remove(url))
+            {
+                Save();
+            }
+            return;
+        }
+
+        Entries[url] = new HttpValidators { ETag = etag, LastModified = lastModified };
+        Save();
+    }
+
+    private Dictionary<string, HttpValidators> Entries => _entries ??= Load();
+
+    private void Save()
+    {
+        try
+        {
+            JsonStateFile.Write(_path, Entries);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not save HTTP validators: {ex.Message}");
+        }
+    }
+
+    private Dictionary<string, HttpValidators> Load()
+    {
+        try
+        {
+            if (File.Exists(_path))
+            {
+                return JsonSerializer.Deserialize<Dictionary<string, HttpValidators>>(File.ReadAllText(_path)) ?? new();
+            }
+        }
+        catch (Exception ex) when (ex is IOException or JsonException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not read HTTP validators: {ex.Message}");
+        }
+        return new();
+    }
+}
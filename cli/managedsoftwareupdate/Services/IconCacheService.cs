@@ -0,0 +1,175 @@
+using System.Net;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Keeps <see cref="CimianPaths.IconsDir"/> in step with the repo's icons/
+/// folder for the items this machine can show, so Managed Software Center and
+/// CimianStatus render icons without fetching anything themselves.
+///
+/// When the repo publishes the makecatalogs hash index (icons/_icon_hashes.yaml)
+/// an icon is downloaded only if the cached copy's SHA-256 differs. Without it,
+/// each icon is a conditional GET against the validators recorded last time
+/// (<see cref="HttpValidatorStore"/>), so an unchanged icon costs a 304.
+/// </summary>
+public class IconCacheService
+{
+    private readonly CimianConfig _config;
+    private readonly HttpClient _httpClient;
+    private readonly string _iconsDir;
+    private readonly HttpValidatorStore _validators;
+
+    public IconCacheService(CimianConfig config, HttpClient? httpClient = null, string? iconsDir = null)
+    {
+        _config = config;
+        _httpClient = httpClient ?? CimianHttpClientFactory.CreateHttpClient(config, TimeSpan.FromSeconds(30));
+        _iconsDir = iconsDir ?? CimianPaths.IconsDir;
+        _validators = new HttpValidatorStore(_iconsDir);
+    }
+
+    /// <summary>
+    /// Downloads new or changed icons for <paramref name="items"/>. Returns how
+    /// many were written. Icons the repo doesn't have are skipped quietly; any
+    /// other failure is a warning and leaves the cached copy in place.
+    /// </summary>
+    public async Task<int> SyncAsync(IEnumerable<CatalogItem> items, CancellationToken cancellationToken = default)
+    {
+        var baseUrl = $"{_config.SoftwareRepoURL.TrimEnd('/')}/icons/";
+        Directory.CreateDirectory(_iconsDir);
+
+        var hashes = await FetchHashIndexAsync(baseUrl, cancellationToken);
+        var written = 0;
+
+        foreach (var item in items.DistinctBy(i => ItemKey.Canonical(i.Name)))
+        {
+            cancellationToken.ThrowIfCancellationRequested();
+            try
+            {
+                var changed = hashes != null
+                    ? await SyncHashedAsync(baseUrl, item, hashes, cancellationToken)
+                    : await SyncConditionalAsync(baseUrl, item, cancellationToken);
+                if (changed)
+                {
+                    written++;
+                }
+            }
+            catch (HttpRequestException ex)
+            {
+                ConsoleLogger.Warn($"Could not download icon for {item.Name}: {ex.Message}");
+            }
+            catch (IOException ex)
+            {
+                ConsoleLogger.Warn($"Could not cache icon for {item.Name}: {ex.Message}");
+            }
+        }
+
+        if (written > 0)
+        {
+            ConsoleLogger.Info($"    Updated {written} icon(s) in {_iconsDir}");
+        }
+        return written;
+    }
+
+    private async Task<bool> SyncHashedAsync(string baseUrl, CatalogItem item, Dictionary<string, string> hashes, CancellationToken cancellationToken)
+    {
+        var fileName = IconCache.Candidates(item.Name, item.IconName).FirstOrDefault(hashes.ContainsKey);
+        var localPath = fileName == null ? null : IconCache.ResolveInside(_iconsDir, fileName);
+        if (localPath == null)
+        {
+            return false;
+        }
+
+        var expected = hashes[fileName!];
+        if (File.Exists(localPath) && string.Equals(IconCache.ComputeHash(localPath), expected, StringComparison.OrdinalIgnoreCase))
+        {
+            return false;
+        }
+
+        using var response = await _httpClient.GetAsync(baseUrl + Uri.EscapeDataString(fileName!), cancellationToken);
+        if (!response.IsSuccessStatusCode)
+        {
+            ConsoleLogger.Warn($"Icon {fileName} is listed in {IconCache.HashesFileName} but could not be downloaded: {response.StatusCode}");
+            return false;
+        }
+
+        var tempPath = await WriteTempAsync(localPath, response, cancellationToken);
+        if (!string.Equals(IconCache.ComputeHash(tempPath), expected, StringComparison.OrdinalIgnoreCase))
+        {
+            File.Delete(tempPath);
+            ConsoleLogger.Warn($"Icon {fileName} does not match its hash in {IconCache.HashesFileName}; keeping the cached copy");
+            return false;
+        }
+        File.Move(tempPath, localPath, overwrite: true);
+        ConsoleLogger.Debug($"Cached icon {fileName} for {item.Name}");
+        return true;
+    }
+
+    private async Task<bool> SyncConditionalAsync(string baseUrl, CatalogItem item, CancellationToken cancellationToken)
+    {
+        var fileName = IconCache.IconFileName(item.Name, item.IconName);
+        var localPath = IconCache.ResolveInside(_iconsDir, fileName);
+        if (localPath == null)
+        {
+            return false;
+        }
+
+        var url = baseUrl + Uri.EscapeDataString(fileName);
+        using var request = new HttpRequestMessage(HttpMethod.Get, url);
+        _validators.ApplyTo(request, localPath);
+
+        using var response = await _httpClient.SendAsync(request, cancellationToken);
+        if (response.StatusCode == HttpStatusCode.NotModified || response.StatusCode == HttpStatusCode.NotFound)
+        {
+            return false;
+        }
+        if (!response.IsSuccessStatusCode)
+        {
+            ConsoleLogger.Debug($"Icon {fileName} not downloaded: {response.StatusCode}");
+            return false;
+        }
+
+        var tempPath = await WriteTempAsync(localPath, response, cancellationToken);
+        File.Move(tempPath, localPath, overwrite: true);
+        _validators.Record(url, response);
+        ConsoleLogger.Debug($"Cached icon {fileName} for {item.Name}");
+        return true;
+    }
+
+    /// <summary>
+    /// The repo's icon hash index, or null when it isn't published (older
+    /// makecatalogs, or no icons/ folder at all).
+    /// </summary>
+    private async Task<Dictionary<string, string>?> FetchHashIndexAsync(string baseUrl, CancellationToken cancellationToken)
+    {
+        try
+        {
+            using var response = await _httpClient.GetAsync(baseUrl + IconCache.HashesFileName, cancellationToken);
+            if (!response.IsSuccessStatusCode)
+            {
+                ConsoleLogger.Debug($"No icon hash index ({response.StatusCode}); using conditional requests");
+                return null;
+            }
+            var yaml = await response.Content.ReadAsStringAsync(cancellationToken);
+            var index = YamlUtils.Deserializer.Deserialize<Dictionary<string, string>>(yaml);
+            return index == null ? null : new Dictionary<string, string>(index, StringComparer.OrdinalIgnoreCase);
+        }
+        catch (Exception ex) when (ex is HttpRequestException or YamlDotNet.Core.YamlException)
+        {
+            ConsoleLogger.Debug($"Icon hash index unavailable: {ex.Message}");
+            return null;
+        }
+    }
+
+    private static async Task<string> WriteTempAsync(string localPath, HttpResponseMessage response, CancellationToken cancellationToken)
+    {
+        var tempPath = localPath + ".tmp";
+        await using (var file = File.Create(tempPath))
+        {
+            await response.Content.CopyToAsync(file, cancellationToken);
+        }
+        return tempPath;
+    }
+}
@@ -0,0 +1,223 @@
+using System.Globalization;
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core;
+using Cimian.Core.Services;
+using Microsoft.Win32;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+public class InstalledItemRecord
+{
+    [JsonPropertyName("name")]
+    public string Name { get; set; } = "";
+
+    [JsonPropertyName("version")]
+    public string Version { get; set; } = "";
+
+    /// <summary>installed or removed.</summary>
+    [JsonPropertyName("status")]
+    public string Status { get; set; } = InstalledItemsStore.StatusInstalled;
+
+    [JsonPropertyName("installed_at")]
+    public DateTime? InstalledAt { get; set; }
+
+    [JsonPropertyName("removed_at")]
+    public DateTime? RemovedAt { get; set; }
+
+    /// <summary>Catalog hash of the installer that ran (full_hash for delta items).</summary>
+    [JsonPropertyName("installer_hash")]
+    public string? InstallerHash { get; set; }
+
+    [JsonPropertyName("installer_type")]
+    public string? InstallerType { get; set; }
+
+    /// <summary>cimian for items recorded at install time, receipt for ones imported from the registry.</summary>
+    [JsonPropertyName("source")]
+    public string Source { get; set; } = "cimian";
+
+    /// <summary>
+    /// Installed only because another item required or updated it. Cleared once
+    /// a manifest or --install asks for the item itself.
+    /// </summary>
+    [JsonPropertyName("installed_as_dependency")]
+    public bool InstalledAsDependency { get; set; }
+
+    [JsonIgnore]
+    public bool IsInstalled => Status == InstalledItemsStore.StatusInstalled;
+}
+
+/// <summary>
+/// Local record of every item Cimian has installed or removed, kept in
+/// <see cref="CimianPaths.InstalledItemsJson"/>. Dependency resolution starts from
+/// it (so a requires already satisfied by an earlier run isn't re-resolved) and
+/// removals use it to find installed items that require what is being removed.
+/// </summary>
+public class InstalledItemsStore
+{
+    public const string StatusInstalled = "installed";
+    public const string StatusRemoved = "removed";
+
+    private const string ReceiptsRegistryPath = @"SOFTWARE\ManagedInstalls";
+
+    private readonly string _path;
+    private Dictionary<string, InstalledItemRecord>? _records;
+
+    public InstalledItemsStore(string? path = null)
+    {
+        _path = path ?? CimianPaths.InstalledItemsJson;
+    }
+
+    /// <summary>False until the store has been saved once on this machine.</summary>
+    public bool Exists => File.Exists(_path);
+
+    public InstalledItemRecord? Get(string name) =>
+        Records.TryGetValue(ItemKey.Canonical(name), out var record) ? record : null;
+
+    public List<InstalledItemRecord> Installed() =>
+        Records.Values.Where(r => r.IsInstalled).OrderBy(r => r.Name, StringComparer.OrdinalIgnoreCase).ToList();
+
+    /// <summary>
+    /// Installed items as "name--version" entries, the form
+    /// <see cref="CatalogService.CheckDependencies"/> and
+    /// <see cref="CatalogService.IsItemInstalled"/> take.
+    /// </summary>
+    public List<string> InstalledItemNames() =>
+        Installed().Select(r => string.IsNullOrEmpty(r.Version) ? r.Name : $"{r.Name}--{r.Version}").ToList();
+
+    /// <summary>
+    /// OnDemand items are never recorded, matching their ManagedInstalls receipt.
+    /// An item already installed in its own right stays that way when it's
+    /// reinstalled as a dependency.
+    /// </summary>
+    public void RecordInstall(CatalogItem item, DateTime now, bool asDependency = false)
+    {
+        if (item.OnDemand)
+        {
+            return;
+        }
+        var existing = Get(item.Name);
+        if (existing is { IsInstalled: true, InstalledAsDependency: false })
+        {
+            asDependency = false;
+        }
+        Records[ItemKey.Canonical(item.Name)] = new InstalledItemRecord
+        {
+            Name = item.Name,
+            Version = item.Version,
+            Status = StatusInstalled,
+            InstalledAt = now,
+            InstallerHash = item.Installer.IsDelta ? item.Installer.FullHash : item.Installer.Hash,
+            InstallerType = string.IsNullOrEmpty(item.Installer.Type) ? null : item.Installer.Type.ToLowerInvariant(),
+            InstalledAsDependency = asDependency
+        };
+        Save();
+    }
+
+    public void RecordRemoval(CatalogItem item, DateTime now)
+    {
+        var record = Get(item.Name) ?? new InstalledItemRecord { Name = item.Name, Version = item.Version };
+        record.Status = StatusRemoved;
+        record.RemovedAt = now;
+        Records[ItemKey.Canonical(item.Name)] = record;
+        Save();
+    }
+
+    /// <summary>
+    /// Seeds a store that has never been saved with receipts from earlier clients,
+    /// so machines upgrading to it don't start with an empty install history.
+    /// Returns the number imported.
+    /// </summary>
+    public int ImportReceipts(IEnumerable<InstalledItemRecord> receipts)
+    {
+        if (Exists)
+        {
+            return 0;
+        }
+
+        var imported = 0;
+        foreach (var receipt in receipts)
+        {
+            if (Records.TryAdd(ItemKey.Canonical(receipt.Name), receipt))
+            {
+                imported++;
+            }
+        }
+        Save();
+        return imported;
+    }
+
+    /// <summary>
+    /// Every HKLM\SOFTWARE\ManagedInstalls\&lt;name&gt; receipt with a Version.
+    /// </summary>
+    public static List<InstalledItemRecord> ReadRegistryReceipts()
+    {
+        var receipts = new List<InstalledItemRecord>();
+        try
+        {
+            using var root = Registry.LocalMachine.OpenSubKey(ReceiptsRegistryPath);
+            if (root == null)
+            {
+                return receipts;
+            }
+
+            foreach (var name in root.GetSubKeyNames())
+            {
+                using var key = root.OpenSubKey(name);
+                if (key?.GetValue("Version") is not string version || string.IsNullOrEmpty(version))
+                {
+                    continue;
+                }
+                receipts.Add(new InstalledItemRecord
+                {
+                    Name = name,
+                    Version = version,
+                    InstalledAt = DateTime.TryParseExact(key.GetValue("InstallDate") as string, "yyyy-MM-dd",
+                        CultureInfo.InvariantCulture, DateTimeStyles.None, out var date) ? date : null,
+                    InstallerType = key.GetValue("InstallerType") as string,
+                    Source = "receipt"
+                });
+            }
+        }
+        catch (Exception ex) when (ex is System.Security.SecurityException or UnauthorizedAccessException or IOException)
+        {
+            ConsoleLogger.Debug($"Could not read ManagedInstalls receipts: {ex.Message}");
+        }
+        return receipts;
+    }
+
+    private Dictionary<string, InstalledItemRecord> Records => _records ??= Load();
+
+    private void Save()
+    {
+        try
+        {
+            JsonStateFile.Write(_path, Records.Values.OrderBy(r => r.Name, StringComparer.OrdinalIgnoreCase));
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not save installed items state: {ex.Message}");
+        }
+    }
+
+    private Dictionary<string, InstalledItemRecord> Load()
+    {
+        var records = new Dictionary<string, InstalledItemRecord>();
+        try
+        {
+            if (File.Exists(_path))
+            {
+                foreach (var record in JsonSerializer.Deserialize<List<InstalledItemRecord>>(File.ReadAllText(_path)) ?? [])
+                {
+                    records[ItemKey.Canonical(record.Name)] = record;
+                }
+            }
+        }
+        catch (Exception ex) when (ex is IOException or JsonException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not read installed items state: {ex.Message}");
+        }
+        return records;
+    }
+}
@@ -0,0 +1,326 @@
+using System.ComponentModel;
+using System.Diagnostics;
+using System.Management;
+using System.Runtime.InteropServices;
+using System.Text;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>A process in a timed-out installer's tree.</summary>
+public sealed record ProcessTreeNode(int Id, int ParentId, string Name, string? CommandLine = null, DateTime? Started = null);
+
+/// <summary>
+/// The processes <see cref="InstallerJob.StopTree"/> stopped, and any still
+/// running afterwards (usually ones another account owns).
+/// </summary>
+public sealed record ProcessTreeStop(List<ProcessTreeNode> Stopped, List<ProcessTreeNode> Survivors)
+{
+    /// <summary>The stopped tree, then the survivors, for logs and failure output.</summary>
+    public string Describe() => Survivors.Count == 0
+        ? InstallerJob.FormatTree(Stopped)
+        : $"{InstallerJob.FormatTree(Stopped)}{Environment.NewLine}Still running afterwards:{Environment.NewLine}{InstallerJob.FormatTree(Survivors)}";
+}
+
+/// <summary>
+/// A Windows job object holding an installer and every process it starts, so a
+/// hung installer can be stopped together with its children, including ones
+/// whose parent already exited, which Process.Kill(entireProcessTree) can't
+/// find. Disposing the job leaves its processes running: an installer that
+/// launches the app when it finishes isn't affected.
+/// </summary>
+public sealed class InstallerJob : IDisposable
+{
+    private const int JobObjectBasicProcessIdList = 3;
+    private const int MaxListedProcesses = 1024;
+    private const int MaxCommandLineLength = 300;
+    private const int KillWaitMilliseconds = 2_000;
+
+    private IntPtr _handle;
+
+    private InstallerJob(IntPtr handle)
+    {
+        _handle = handle;
+    }
+
+    /// <summary>
+    /// Puts <paramref name="process"/> in a new job. Null when Windows refuses,
+    /// in which case <see cref="Capture"/> falls back to parent process IDs.
+    /// </summary>
+    public static InstallerJob? TryAttach(Process process)
+    {
+        try
+        {
+            return TryAttach(process.Handle);
+        }
+        catch (Exception ex) when (ex is InvalidOperationException or Win32Exception)
+        {
+            ConsoleLogger.Debug($"Could not put PID {process.Id} in a job object: {ex.Message}");
+            return null;
+        }
+    }
+
+    /// <inheritdoc cref="TryAttach(Process)"/>
+    public static InstallerJob? TryAttach(IntPtr processHandle)
+    {
+        try
+        {
+            var job = CreateJobObject(IntPtr.Zero, null);
+            if (job == IntPtr.Zero)
+            {
+                ConsoleLogger.Debug($"CreateJobObject failed: {new Win32Exception(Marshal.GetLastWin32Error()).Message}");
+                return null;
+            }
+            if (!AssignProcessToJobObject(job, processHandle))
+            {
+                ConsoleLogger.Debug($"AssignProcessToJobObject failed: {new Win32Exception(Marshal.GetLastWin32Error()).Message}");
+                CloseHandle(job);
+                return null;
+            }
+            return new InstallerJob(job);
+        }
+        catch (Exception ex) when (ex is DllNotFoundException or EntryPointNotFoundException)
+        {
+            return null;
+        }
+    }
+
+    /// <summary>IDs of the processes still running in the job.</summary>
+    public List<int> ProcessIds()
+    {
+        var ids = new List<int>();
+        // JOBOBJECT_BASIC_PROCESS_ID_LIST: two DWORD counts, then ULONG_PTR ids
+        const int headerSize = 8;
+        var size = headerSize + IntPtr.Size * MaxListedProcesses;
+        var buffer = Marshal.AllocHGlobal(size);
+        try
+        {
+            if (!QueryInformationJobObject(_handle, JobObjectBasicProcessIdList, buffer, (uint)size, IntPtr.Zero))
+            {
+                ConsoleLogger.Debug($"QueryInformationJobObject failed: {new Win32Exception(Marshal.GetLastWin32Error()).Message}");
+                return ids;
+            }
+            var listed = Marshal.ReadInt32(buffer, 4);
+            for (var i = 0; i < listed; i++)
+            {
+                ids.Add((int)Marshal.ReadIntPtr(buffer, headerSize + i * IntPtr.Size).ToInt64());
+            }
+        }
+        finally
+        {
+            Marshal.FreeHGlobal(buffer);
+        }
+        return ids;
+    }
+
+    /// <summary>Stops every process in the job.</summary>
+    public void Terminate()
+    {
+        if (!TerminateJobObject(_handle, 1))
+        {
+            ConsoleLogger.Debug($"TerminateJobObject failed: {new Win32Exception(Marshal.GetLastWin32Error()).Message}");
+        }
+    }
+
+    public void Dispose()
+    {
+        if (_handle != IntPtr.Zero)
+        {
+            CloseHandle(_handle);
+            _handle = IntPtr.Zero;
+        }
+    }
+
+    /// <summary>
+    /// The running processes of an installer's tree: <paramref name="rootId"/>,
+    /// everything in <paramref name="job"/>, and their descendants by parent
+    /// process ID (for children a job doesn't hold).
+    /// </summary>
+    public static List<ProcessTreeNode> Capture(int rootId, InstallerJob? job)
+    {
+        var seeds = new HashSet<int>(job?.ProcessIds() ?? []) { rootId };
+        return SelectTree(ListProcesses(), seeds);
+    }
+
+    /// <summary>
+    /// The processes in <paramref name="seeds"/> plus their descendants. A child
+    /// started before its supposed parent is a reused PID, not a descendant.
+    /// </summary>
+    internal static List<ProcessTreeNode> SelectTree(IReadOnlyList<ProcessTreeNode> all, ISet<int> seeds)
+    {
+        var selected = all.Where(p => seeds.Contains(p.Id)).ToList();
+        var included = selected.Select(p => p.Id).ToHashSet();
+        for (var i = 0; i < selected.Count; i++)
+        {
+            var parent = selected[i];
+            foreach (var child in all.Where(p => p.ParentId == parent.Id && p.Id != parent.Id && !included.Contains(p.Id)))
+            {
+                if (child.Started < parent.Started)
+                {
+                    continue;
+                }
+                included.Add(child.Id);
+                selected.Add(child);
+            }
+        }
+        return selected;
+    }
+
+    /// <summary>
+    /// <paramref name="nodes"/> as an indented tree, one process per line.
+    /// A process whose parent isn't among them (it exited) starts a new branch.
+    /// </summary>
+    public static string FormatTree(IReadOnlyList<ProcessTreeNode> nodes)
+    {
+        if (nodes.Count == 0)
+        {
+            return "(no processes left running)";
+        }
+
+        var ids = nodes.Select(n => n.Id).ToHashSet();
+        var children = nodes.ToLookup(n => ids.Contains(n.ParentId) && n.ParentId != n.Id ? n.ParentId : -1);
+        var text = new StringBuilder();
+        var written = new HashSet<int>();
+
+        void Write(ProcessTreeNode node, int depth)
+        {
+            if (!written.Add(node.Id))
+            {
+                return;
+            }
+            text.Append(' ', depth * 2).Append($"{node.Name} (PID {node.Id})");
+            if (!string.IsNullOrWhiteSpace(node.CommandLine))
+            {
+                var commandLine = node.CommandLine.Trim();
+                text.Append(": ").Append(commandLine.Length > MaxCommandLineLength
+                    ? commandLine[..MaxCommandLineLength] + "..."
+                    : commandLine);
+            }
+            text.AppendLine();
+            foreach (var child in children[node.Id])
+            {
+                Write(child, depth + 1);
+            }
+        }
+
+        foreach (var root in children[-1])
+        {
+            Write(root, 0);
+        }
+        // Anything left is in a parent cycle (PID reuse); list it flat
+        foreach (var node in nodes)
+        {
+            Write(node, 0);
+        }
+        return text.ToString().TrimEnd();
+    }
+
+    /// <summary>
+    /// Stops an installer and everything it started: lists the tree (see
+    /// <see cref="Capture"/>), ends <paramref name="job"/>, calls
+    /// <paramref name="stopRoot"/>, kills what's left outside the job, then
+    /// checks which of the processes outlived it all.
+    /// </summary>
+    public static ProcessTreeStop StopTree(int rootId, InstallerJob? job, Action stopRoot)
+    {
+        var nodes = Capture(rootId, job);
+        job?.Terminate();
+        try
+        {
+            stopRoot();
+        }
+        catch (Exception ex) when (ex is InvalidOperationException or Win32Exception)
+        {
+            // Already gone with the job
+        }
+        Kill(nodes);
+        return new ProcessTreeStop(nodes, StillRunning(nodes, ListProcesses()));
+    }
+
+    /// <summary>
+    /// The processes of <paramref name="stopped"/> found in <paramref name="running"/>.
+    /// A process is matched by ID and start time, so a reused PID doesn't count.
+    /// </summary>
+    internal static List<ProcessTreeNode> StillRunning(IReadOnlyList<ProcessTreeNode> stopped, IReadOnlyList<ProcessTreeNode> running)
+    {
+        var live = running.Select(p => (p.Id, p.Started)).ToHashSet();
+        return stopped.Where(p => live.Contains((p.Id, p.Started))).ToList();
+    }
+
+    /// <summary>
+    /// Kills each of <paramref name="nodes"/> still running, for processes outside a job.
+    /// Like <see cref="StillRunning"/>, a PID only counts with its start time: one
+    /// reused by an unrelated process since the tree was captured is left alone.
+    /// </summary>
+    public static void Kill(IEnumerable<ProcessTreeNode> nodes)
+    {
+        foreach (var node in nodes)
+        {
+            try
+            {
+                using var process = Process.GetProcessById(node.Id);
+                if (!IsSameProcess(node, process.StartTime))
+                {
+                    ConsoleLogger.Debug($"PID {node.Id} ({node.Name}) now belongs to another process; not killing it");
+                    continue;
+                }
+                process.Kill();
+                process.WaitForExit(KillWaitMilliseconds);
+            }
+            catch (Exception ex) when (ex is ArgumentException or InvalidOperationException or Win32Exception)
+            {
+                // Already gone, or not ours to stop
+            }
+        }
+    }
+
+    /// <summary>
+    /// Whether a running process that started at <paramref name="started"/> is the
+    /// one captured as <paramref name="node"/>. Without a captured start time there's
+    /// no telling, so it isn't. WMI reports start times to the microsecond.
+    /// </summary>
+    internal static bool IsSameProcess(ProcessTreeNode node, DateTime started) =>
+        node.Started is { } captured && (captured - started).Duration() < TimeSpan.FromMilliseconds(1);
+
+    private static List<ProcessTreeNode> ListProcesses()
+    {
+        var processes = new List<ProcessTreeNode>();
+        try
+        {
+            using var searcher = new ManagementObjectSearcher("SELECT ProcessId, ParentProcessId, Name, CommandLine, CreationDate FROM Win32_Process");
+            foreach (ManagementObject mo in searcher.Get())
+            {
+                using (mo)
+                {
+                    var created = mo["CreationDate"]?.ToString();
+                    processes.Add(new ProcessTreeNode(
+                        Convert.ToInt32(mo["ProcessId"]),
+                        Convert.ToInt32(mo["ParentProcessId"]),
+                        mo["Name"]?.ToString() ?? "?",
+                        mo["CommandLine"]?.ToString(),
+                        string.IsNullOrEmpty(created) ? null : ManagementDateTimeConverter.ToDateTime(created)));
+                }
+            }
+        }
+        catch (Exception ex) when (ex is ManagementException or COMException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Debug($"Could not list processes: {ex.Message}");
+        }
+        return processes;
+    }
+
+    [DllImport("kernel32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+    private static extern IntPtr CreateJobObject(IntPtr jobAttributes, string? name);
+
+    [DllImport("kernel32.dll", SetLastError = true)]
+    private static extern bool AssignProcessToJobObject(IntPtr job, IntPtr process);
+
+    [DllImport("kernel32.dll", SetLastError = true)]
+    private static extern bool QueryInformationJobObject(IntPtr job, int infoClass, IntPtr info, uint length, IntPtr returnLength);
+
+    [DllImport("kernel32.dll", SetLastError = true)]
+    private static extern bool TerminateJobObject(IntPtr job, uint exitCode);
+
+    [DllImport("kernel32.dll", SetLastError = true)]
+    private static extern bool CloseHandle(IntPtr handle);
+}
@@ -4,6 +4,7 @@ using System.Runtime.InteropServices;
 using System.Security.Cryptography;
 using System.Text;
 using System.Text.Json;
+using System.Text.RegularExpressions;
 using Cimian.CLI.managedsoftwareupdate.Models;
 using Cimian.Core.Services;
 using Microsoft.Win32;
@@ -21,9 +22,10 @@ namespace Cimian.CLI.managedsoftwareupdate.Services;
 /// - sbin-installer for .pkg and .nupkg (PRIMARY - matches Go)
 /// - MSI via msiexec.exe
 /// - EXE with silent switches
-/// - Chocolatey fallback for .nupkg
+/// - Chocolatey fallback for .nupkg, then native extraction when choco is absent
 /// - MSIX/AppX via PowerShell
 /// - PowerShell scripts
+/// - zip/iso archives wrapping any of the above
 /// </summary>
 public class InstallerService
 {
@@ -33,6 +35,7 @@ public class InstallerService
     
     private readonly CimianConfig _config;
     private readonly ScriptService _scriptService;
+    private readonly RepoScriptService _repoScripts;
     private SessionLogger? _sessionLogger;
     
     // Cached sbin-installer path (null = not checked, empty = not available)
@@ -49,10 +52,17 @@ public class InstallerService
     private static readonly int[] MsiexecBackoffSeconds = { 30, 60 };
     private const int MsiInstallLogRetention = 3;
 
+    /// <summary>
+    /// Tag line added to the output when an installer exits 3010 or 1641, so the
+    /// caller can schedule the restart the same way as for restart_action.
+    /// </summary>
+    public const string RestartRequiredTag = "RESTART_REQUIRED";
+
     public InstallerService(CimianConfig config)
     {
         _config = config;
         _scriptService = new ScriptService();
+        _repoScripts = new RepoScriptService(config, _scriptService);
     }
 
     /// <summary>
@@ -61,6 +71,7 @@ public class InstallerService
     public void SetSessionLogger(SessionLogger? logger)
     {
         _sessionLogger = logger;
+        _repoScripts.SetSessionLogger(logger);
     }
 
     #region sbin-installer Support (Ported from Go pkg/installer)
@@ -259,10 +270,7 @@ public class InstallerService
 
         var args = argsBuilder.ToString();
 
-        // Use configured timeout or default to 30 minutes
-        var timeoutMinutes = _config.InstallerTimeout > 0 
-            ? _config.InstallerTimeout / 60 
-            : 30;
+        var timeout = InstallerTimeoutFor(item, _config);
 
         ConsoleLogger.Debug($"sbin-installer command: {sbinPath} {args}");
 
@@ -299,11 +307,12 @@ public class InstallerService
             };
 
             process.Start();
+            using var job = InstallerJob.TryAttach(process);
             process.BeginOutputReadLine();
             process.BeginErrorReadLine();
 
             using var cts = CancellationTokenSource.CreateLinkedTokenSource(cancellationToken);
-            cts.CancelAfter(TimeSpan.FromMinutes(timeoutMinutes));
+            cts.CancelAfter(timeout);
 
             try
             {
@@ -311,8 +320,11 @@ public class InstallerService
             }
             catch (OperationCanceledException)
             {
-                try { process.Kill(true); } catch { }
-                var errorMsg = $"sbin-installer timed out after {timeoutMinutes} minutes";
+                var cancelled = cancellationToken.IsCancellationRequested;
+                StopProcessTree(item, process, job, timeout, cancelled);
+                var errorMsg = cancelled
+                    ? "sbin-installer was cancelled"
+                    : $"sbin-installer timed out after {timeout.TotalMinutes} minutes";
                 ConsoleLogger.Error(errorMsg);
                 _sessionLogger?.LogInstall(item.Name, item.Version, "install", "failed", errorMsg);
                 return (false, errorMsg);
@@ -385,8 +397,9 @@ public class InstallerService
                 var (signatureValid, signatureDetails) = VerifyPkgSignature(packagePath, buildInfo);
                 if (signatureValid)
                 {
-                    ConsoleLogger.Success($"✓ Signature verified: {signatureDetails}");
-                    _sessionLogger?.Log("INFO", $"Signature verified for {item.Name}: {signatureDetails}");
+                    // Integrity against the package's own build-info only; the signer isn't verified
+                    ConsoleLogger.Detail($"Package matches its build-info: {signatureDetails}");
+                    _sessionLogger?.Log("INFO", $"Package {item.Name} matches its build-info: {signatureDetails}");
                 }
                 else
                 {
@@ -423,7 +436,8 @@ public class InstallerService
     }
 
     /// <summary>
-    /// Installs a .nupkg package using sbin-installer with Chocolatey fallback.
+    /// Installs a .nupkg package using sbin-installer with Chocolatey fallback,
+    /// and extracts it natively (<see cref="NupkgService"/>) when neither is present.
     /// Matches Go: installOrUpgradeNupkgWithSbin() and installOrUpgradePackage()
     /// </summary>
     private async Task<(bool Success, string Output)> InstallNupkgWithSbinAsync(
@@ -450,10 +464,27 @@ public class InstallerService
         }
 
         // Fallback to Chocolatey
-        ConsoleLogger.Info($"[INSTALLER METHOD: choco] Using Chocolatey for .nupkg installation: {item.Name}");
-        return await InstallChocolateyAsync(item, packagePath, cancellationToken);
+        if (File.Exists(ChocolateyService.ChocoExe))
+        {
+            ConsoleLogger.Info($"[INSTALLER METHOD: choco] Using Chocolatey for .nupkg installation: {item.Name}");
+            return await InstallChocolateyAsync(item, packagePath, cancellationToken);
+        }
+
+        // No choco.exe either: extract the package and run its scripts ourselves
+        ConsoleLogger.Info($"[INSTALLER METHOD: nupkg] Chocolatey not installed, extracting .nupkg natively: {item.Name}");
+        _sessionLogger?.Log("INFO", $"Installing {item.Name} by extracting the .nupkg (no sbin-installer or Chocolatey)");
+        var (success, output, packageFolder) = await new NupkgService(_scriptService.ForItem(item)).InstallAsync(packagePath, cancellationToken);
+        _lastNupkgPackageFolder = success ? packageFolder : null;
+        return (success, output);
     }
 
+    /// <summary>
+    /// Folder a native .nupkg install extracted to, written to ManagedInstalls as
+    /// PackageFolder so the uninstall can find chocolateyUninstall.ps1. Reset on
+    /// each install attempt.
+    /// </summary>
+    private string? _lastNupkgPackageFolder;
+
     /// <summary>
     /// Extracts build-info.yaml from a .pkg file.
     /// Matches Go: extract.ExtractPkgBuildInfo()
@@ -585,25 +616,64 @@ public class InstallerService
         ConsoleLogger.Info($"Installing {item.Name} v{item.Version}...");
         _sessionLogger?.Log("INFO", $"Starting installation: {item.Name} v{item.Version}");
         _sessionLogger?.LogInstall(item.Name, item.Version, "install", "started", $"Installing {item.Name}");
+        _lastNupkgPackageFolder = null;
+
+        // Authenticode check before anything from the payload (or its preinstall) runs
+        var signatureError = VerifyInstallerSignature(item, localFile);
+        if (signatureError != null)
+        {
+            _sessionLogger?.LogInstall(item.Name, item.Version, "install", "failed", signatureError);
+            return (false, signatureError, null);
+        }
+
+        // install_context: user runs the installer itself as the console user;
+        // pre/postinstall scripts and the checks around it stay SYSTEM
+        if (item.InstallsAsUser)
+        {
+            var user = UserContextRunner.GetActiveUserName();
+            var type = GetInstallerType(item, localFile);
+            var contextError = !UserContextRunner.SupportsInstallerType(type)
+                ? $"install_context: user is not supported for {type} installers"
+                : user == null
+                    ? "install_context: user needs someone logged on at the console"
+                    : null;
+            if (contextError != null)
+            {
+                _sessionLogger?.LogInstall(item.Name, item.Version, "install", "failed", contextError);
+                return (false, contextError, null);
+            }
+            ConsoleLogger.Info($"Installing {item.Name} as {user}");
+        }
 
         // Run preinstall script if present
         if (!string.IsNullOrEmpty(item.PreinstallScript))
         {
             ConsoleLogger.Info($"Running preinstall script for {item.Name}...");
             _sessionLogger?.Log("INFO", $"Executing preinstall script for {item.Name}");
-            var preResult = await _scriptService.ExecuteScriptAsync(item.PreinstallScript, cancellationToken);
-            if (!preResult.Success)
+            var preResult = await _scriptService.ForItem(item).RunAsync(ScriptKind.Preinstall, item.PreinstallScript, cancellationToken);
+            if (!preResult.Success && ScriptFailureError(item, ScriptKind.Preinstall, "Preinstall script", preResult.Output) is { } errorMsg)
             {
-                var errorMsg = $"Preinstall script failed: {preResult.Output}";
                 _sessionLogger?.LogInstall(item.Name, item.Version, "install", "failed", errorMsg);
                 return (false, errorMsg, null);
             }
         }
 
+        // Shared preinstall_scripts from the repo; by default the first failure stops the install
+        var stopOnPreinstallFailure = ScriptService.FailureActionFor(ScriptKind.Preinstall) == ScriptFailureAction.Fail;
+        var preScripts = await _repoScripts.RunAllAsync(
+            item.PreinstallScripts.Select(s => (s, (string?)null)), ScriptKind.Preinstall, item, stopOnPreinstallFailure, cancellationToken);
+        if (stopOnPreinstallFailure && preScripts.FirstOrDefault(o => !o.Success) is { } failedPre)
+        {
+            var errorMsg = $"Preinstall script {failedPre.Name} failed: {failedPre.Error}";
+            _sessionLogger?.LogInstall(item.Name, item.Version, "install", "failed", errorMsg);
+            return (false, errorMsg, null);
+        }
+
         // Determine installer type
         var installerType = GetInstallerType(item, localFile);
         ConsoleLogger.Detail($"Installer type: {installerType}");
         _sessionLogger?.Log("DEBUG", $"Using installer type: {installerType} for {item.Name}");
+
         
         var result = installerType.ToLowerInvariant() switch
         {
@@ -619,12 +689,18 @@ public class InstallerService
             
             // nopkg / script-only: no installer binary, run install_script directly
             "nopkg" or "script" => await InstallScriptOnlyAsync(item, cancellationToken),
+
+            // Configuration items: run the set script / DSC Set, then re-test
+            "configuration" => await new ConfigurationItemService(_scriptService).RemediateAsync(item, cancellationToken),
             
             // Standard installers
             "msi" => await InstallMsiAsync(item, localFile, cancellationToken),
             "exe" => await InstallExeAsync(item, localFile, cancellationToken),
             "msix" or "appx" => await InstallMsixAsync(item, localFile, cancellationToken),
             "powershell" or "ps1" => await InstallPowerShellAsync(item, localFile, cancellationToken),
+
+            // Archives: extract (zip) or mount (iso), then run installer.inner_path
+            "zip" or "iso" => await InstallFromArchiveAsync(item, localFile, installerType.ToLowerInvariant(), cancellationToken),
             _ => await InstallExeAsync(item, localFile, cancellationToken) // Default to EXE
         };
 
@@ -634,15 +710,15 @@ public class InstallerService
             return (result.Success, result.Output, null);
         }
 
-        // Run postinstall script if present. Uses ExecuteScriptWithDetailsAsync so
-        // scripts can signal a Warning outcome via a "CIMIAN-WARNING: <message>"
-        // marker line in their output (e.g. "CIMIAN-WARNING: needs-followup").
+        // Run postinstall script if present. ScriptResult carries the Warning
+        // outcome scripts signal via a "CIMIAN-WARNING: <message>" marker line
+        // in their output (e.g. "CIMIAN-WARNING: needs-followup").
         string? postinstallWarning = null;
         if (!string.IsNullOrEmpty(item.PostinstallScript))
         {
             ConsoleLogger.Info($"Running postinstall script for {item.Name}...");
             _sessionLogger?.Log("INFO", $"Executing postinstall script for {item.Name}");
-            var postResult = await _scriptService.ExecuteScriptWithDetailsAsync(item.PostinstallScript, cancellationToken);
+            var postResult = await _scriptService.ForItem(item).RunAsync(ScriptKind.Postinstall, item.PostinstallScript, cancellationToken);
 
             if (postResult.WarningMessage != null)
             {
@@ -650,11 +726,26 @@ public class InstallerService
                 ConsoleLogger.Warn($"Postinstall WARNING for {item.Name}: {postinstallWarning}");
                 _sessionLogger?.Log("WARN", $"Postinstall WARNING for {item.Name}: {postinstallWarning}");
             }
-            else if (!postResult.Success)
+            else if (!postResult.Success
+                && ScriptFailureError(item, ScriptKind.Postinstall, "Postinstall script", postResult.Output) is { } errorMsg)
             {
-                ConsoleLogger.Warn($"Postinstall script failed: {postResult.Output}");
-                _sessionLogger?.Log("WARN", $"Postinstall script failed for {item.Name}: {postResult.Output}");
-                // Don't fail the installation for postinstall script failures (legacy behavior)
+                // Only with ScriptPolicies postinstall OnFailure: fail; by default a
+                // postinstall failure doesn't fail the installation (legacy behavior)
+                _sessionLogger?.LogInstall(item.Name, item.Version, "install", "failed", errorMsg);
+                return (false, errorMsg, null);
+            }
+        }
+
+        // Shared postinstall_scripts follow the same postinstall policy
+        foreach (var outcome in await _repoScripts.RunAllAsync(
+            item.PostinstallScripts.Select(s => (s, (string?)null)), ScriptKind.Postinstall, item, cancellationToken: cancellationToken))
+        {
+            postinstallWarning ??= outcome.WarningMessage;
+            if (!outcome.Success && ScriptService.FailureActionFor(ScriptKind.Postinstall) == ScriptFailureAction.Fail)
+            {
+                var errorMsg = $"Postinstall script {outcome.Name} failed: {outcome.Error}";
+                _sessionLogger?.LogInstall(item.Name, item.Version, "install", "failed", errorMsg);
+                return (false, errorMsg, null);
             }
         }
 
@@ -681,6 +772,155 @@ public class InstallerService
         return (result.Success, result.Output, postinstallWarning);
     }
 
+    /// <summary>
+    /// Checks the Authenticode signature of EXE/MSI payloads. Returns an error to
+    /// refuse the install, or null to go ahead. Unsigned, untrusted and
+    /// expected_signer mismatches only block when RequireSignedInstallers is on;
+    /// otherwise they're logged as warnings.
+    /// </summary>
+    private string? VerifyInstallerSignature(CatalogItem item, string localFile)
+    {
+        if (string.IsNullOrEmpty(localFile) || !File.Exists(localFile)
+            || !AuthenticodeVerifier.AppliesTo(GetInstallerType(item, localFile), localFile))
+        {
+            return null;
+        }
+
+        var required = _config?.RequireSignedInstallers == true;
+        if (!required && string.IsNullOrWhiteSpace(item.ExpectedSigner))
+        {
+            return null;
+        }
+
+        SignatureVerification verification;
+        try
+        {
+            verification = AuthenticodeVerifier.Verify(localFile, item.ExpectedSigner);
+        }
+        catch (Exception ex) when (ex is DllNotFoundException or EntryPointNotFoundException)
+        {
+            verification = new SignatureVerification(SignatureStatus.Untrusted, null, null, "WinVerifyTrust unavailable");
+        }
+
+        _sessionLogger?.LogEvent(new LogEvent
+        {
+            Level = verification.IsValid ? "INFO" : required ? "ERROR" : "WARN",
+            EventType = "signature_validation",
+            PackageName = item.Name,
+            PackageVersion = item.Version,
+            Action = "install",
+            Status = verification.Status.ToString().ToLowerInvariant(),
+            Message = verification.Detail,
+            InstallerType = item.Installer.Type,
+            Context = new Dictionary<string, object>
+            {
+                ["path"] = localFile,
+                ["expected_signer"] = item.ExpectedSigner ?? "",
+                ["subject"] = verification.Subject ?? "",
+                ["thumbprint"] = verification.Thumbprint ?? ""
+            }
+        });
+
+        if (verification.IsValid)
+        {
+            ConsoleLogger.Detail($"Signature verified: {verification.Detail}");
+            return null;
+        }
+
+        if (!required)
+        {
+            ConsoleLogger.Warn($"Signature check for {item.Name}: {verification.Detail} (RequireSignedInstallers is off)");
+            _sessionLogger?.Log("WARN", $"Signature check for {item.Name}: {verification.Detail}");
+            return null;
+        }
+
+        var error = $"Refusing to run installer for {item.Name}: {verification.Detail}";
+        ConsoleLogger.Error(error);
+        _sessionLogger?.Log("ERROR", error);
+        return error;
+    }
+
+    /// <summary>
+    /// The publisher a Cimian self-update package must be signed by: the
+    /// SelfUpdateExpectedSigner pin, else the pkginfo's expected_signer. Null
+    /// when neither is set and any trusted signature is accepted.
+    /// </summary>
+    internal static string? ExpectedSelfUpdateSigner(CimianConfig config, CatalogItem item) =>
+        !string.IsNullOrWhiteSpace(config.SelfUpdateExpectedSigner) ? config.SelfUpdateExpectedSigner.Trim()
+        : !string.IsNullOrWhiteSpace(item.ExpectedSigner) ? item.ExpectedSigner.Trim()
+        : null;
+
+    /// <summary>
+    /// Signature check for a downloaded Cimian self-update package before it is
+    /// scheduled: Authenticode for MSI/EXE, with the signer against
+    /// <see cref="ExpectedSelfUpdateSigner"/>. A .pkg never passes: its signer
+    /// is named by build-info.yaml inside the package itself and no signature
+    /// over the payload is checked against a real certificate, so a tampered
+    /// package could claim the pinned signer. Other formats have no signature
+    /// to check.
+    /// </summary>
+    internal (bool Valid, string Details) VerifySelfUpdateSignature(CatalogItem item, string localFile)
+    {
+        var expectedSigner = ExpectedSelfUpdateSigner(_config, item);
+        var installerType = GetInstallerType(item, localFile);
+        if (AuthenticodeVerifier.AppliesTo(installerType, localFile))
+        {
+            try
+            {
+                var verification = AuthenticodeVerifier.Verify(localFile, expectedSigner);
+                return (verification.IsValid, verification.Detail);
+            }
+            catch (Exception ex) when (ex is DllNotFoundException or EntryPointNotFoundException)
+            {
+                return (false, "WinVerifyTrust unavailable");
+            }
+        }
+
+        if (localFile.EndsWith(".pkg", StringComparison.OrdinalIgnoreCase))
+        {
+            return (false, ".pkg signatures can't be verified (build-info.yaml names its own signer); publish the self-update as a signed MSI or EXE");
+        }
+
+        return (false, $"{Path.GetExtension(localFile)} packages can't carry a signature");
+    }
+
+    /// <summary>
+    /// Names the removal path <see cref="UninstallAsync"/> would take for an item,
+    /// without running it (used by --dry-run). Mirrors UninstallAsync's precedence:
+    /// explicit uninstaller → uninstall_script → MSI product code → MSIX identity →
+    /// registry UninstallString (exe only) → natively extracted .nupkg → none.
+    /// </summary>
+    internal static string DescribeUninstallMethod(CatalogItem item)
+    {
+        if (item.Uninstaller.Count > 0)
+        {
+            return $"uninstaller:{item.Uninstaller[0].Type.ToLowerInvariant()}";
+        }
+        if (!string.IsNullOrWhiteSpace(item.UninstallScript))
+        {
+            return "uninstall_script";
+        }
+        if (item.Installs.Any(i => i.EffectiveType() == "msi" && !string.IsNullOrEmpty(i.ProductCode))
+            || (string.Equals(item.Installer?.Type, "msi", StringComparison.OrdinalIgnoreCase)
+                && !string.IsNullOrEmpty(item.Installer?.ProductCode)))
+        {
+            return "msi_product_code";
+        }
+        if (item.Installs.Any(i => i.EffectiveType() is "msix" or "appx" && !string.IsNullOrEmpty(i.IdentityName)))
+        {
+            return "msix_identity";
+        }
+        if (string.Equals(item.Installer?.Type, "exe", StringComparison.OrdinalIgnoreCase))
+        {
+            return "registry_uninstall_string";
+        }
+        if (string.Equals(item.Installer?.Type, "nupkg", StringComparison.OrdinalIgnoreCase))
+        {
+            return "nupkg_package_folder";
+        }
+        return "none";
+    }
+
     /// <summary>
     /// Uninstalls a catalog item
     /// </summary>
@@ -694,10 +934,10 @@ public class InstallerService
         if (!string.IsNullOrEmpty(item.PreuninstallScript))
         {
             ConsoleLogger.Info($"Running preuninstall script for {item.Name}...");
-            var preResult = await _scriptService.ExecuteScriptAsync(item.PreuninstallScript, cancellationToken);
-            if (!preResult.Success)
+            var preResult = await _scriptService.ForItem(item).RunAsync(ScriptKind.Preuninstall, item.PreuninstallScript, cancellationToken);
+            if (!preResult.Success && ScriptFailureError(item, ScriptKind.Preuninstall, "Preuninstall script", preResult.Output) is { } errorMsg)
             {
-                return (false, $"Preuninstall script failed: {preResult.Output}");
+                return (false, errorMsg);
             }
         }
 
@@ -708,17 +948,19 @@ public class InstallerService
             var uninstaller = item.Uninstaller[0];
             result = uninstaller.Type.ToLowerInvariant() switch
             {
-                "msi" => await UninstallMsiAsync(uninstaller, cancellationToken),
-                "exe" => await UninstallExeAsync(uninstaller, cancellationToken),
-                "powershell" or "ps1" => await UninstallPowerShellAsync(uninstaller, cancellationToken),
+                "msi" => await UninstallMsiAsync(item, uninstaller, cancellationToken),
+                "exe" => await UninstallExeAsync(item, uninstaller, cancellationToken),
+                "powershell" or "ps1" => await UninstallPowerShellAsync(item, uninstaller, cancellationToken),
                 "msix" or "appx" => await UninstallMsixAsync(item, uninstaller, cancellationToken),
-                _ => await UninstallMsiAsync(uninstaller, cancellationToken)
+                "registry" => await UninstallViaRegistryAsync(item, uninstaller, cancellationToken),
+                _ => await UninstallMsiAsync(item, uninstaller, cancellationToken)
             };
         }
         else if (!string.IsNullOrWhiteSpace(item.UninstallScript))
         {
             ConsoleLogger.Info($"Running uninstall_script for {item.Name}...");
-            result = await _scriptService.ExecuteScriptAsync(item.UninstallScript, cancellationToken);
+            var scriptResult = await _scriptService.ForItem(item).RunAsync(ScriptKind.Uninstall, item.UninstallScript, cancellationToken);
+            result = (scriptResult.Success, scriptResult.Output);
         }
         else
         {
@@ -744,7 +986,7 @@ public class InstallerService
                     Type = "msi",
                     ProductCode = msiProductCode
                 };
-                result = await UninstallMsiAsync(synthetic, cancellationToken);
+                result = await UninstallMsiAsync(item, synthetic, cancellationToken);
             }
             else
             {
@@ -773,7 +1015,13 @@ public class InstallerService
                 // silent background removal.
                 else if (string.Equals(item.Installer?.Type, "exe", StringComparison.OrdinalIgnoreCase))
                 {
-                    result = await UninstallViaRegistryAsync(item, cancellationToken);
+                    result = await UninstallViaRegistryAsync(item, null, cancellationToken);
+                }
+                // A .nupkg Cimian extracted itself: its chocolateyUninstall.ps1, then the folder
+                else if (ReadManagedInstallsValue(item.Name, "PackageFolder") is { } packageFolder)
+                {
+                    ConsoleLogger.Info($"Removing natively installed package {item.Name} from {packageFolder}");
+                    result = await new NupkgService(_scriptService.ForItem(item)).UninstallAsync(packageFolder, cancellationToken);
                 }
             }
         }
@@ -787,10 +1035,10 @@ public class InstallerService
         if (!string.IsNullOrEmpty(item.PostuninstallScript))
         {
             ConsoleLogger.Info($"Running postuninstall script for {item.Name}...");
-            var postResult = await _scriptService.ExecuteScriptAsync(item.PostuninstallScript, cancellationToken);
-            if (!postResult.Success)
+            var postResult = await _scriptService.ForItem(item).RunAsync(ScriptKind.Postuninstall, item.PostuninstallScript, cancellationToken);
+            if (!postResult.Success && ScriptFailureError(item, ScriptKind.Postuninstall, "Postuninstall script", postResult.Output) is { } errorMsg)
             {
-                ConsoleLogger.Warn($"Postuninstall script failed: {postResult.Output}");
+                return (false, errorMsg);
             }
         }
 
@@ -800,9 +1048,39 @@ public class InstallerService
         return result;
     }
 
-    private string GetInstallerType(CatalogItem item, string localFile)
+    /// <summary>
+    /// Applies the ScriptPolicies OnFailure of <paramref name="kind"/> to a failed
+    /// script: the error to fail the install/uninstall with, or null to carry on
+    /// (after a warning, unless the policy is ignore).
+    /// </summary>
+    private string? ScriptFailureError(CatalogItem item, string kind, string label, string output)
+    {
+        switch (ScriptService.FailureActionFor(kind))
+        {
+            case ScriptFailureAction.Fail:
+                return $"{label} failed: {output}";
+            case ScriptFailureAction.Warn:
+                ConsoleLogger.Warn($"{label} failed: {output}");
+                _sessionLogger?.Log("WARN", $"{label} failed for {item.Name}: {output}");
+                return null;
+            default:
+                ConsoleLogger.Debug($"{label} failed for {item.Name} (ignored by ScriptPolicies): {output}");
+                return null;
+        }
+    }
+
+    internal static string GetInstallerType(CatalogItem item, string localFile)
     {
-        if (!string.IsNullOrEmpty(item.Installer.Type))
+        if (item.Installer.IsDelta)
+        {
+            // The patch has already been turned into the full installer by
+            // DownloadService; install it as whatever it really is.
+            if (!string.IsNullOrEmpty(item.Installer.TargetType))
+            {
+                return item.Installer.TargetType;
+            }
+        }
+        else if (!string.IsNullOrEmpty(item.Installer.Type))
         {
             return item.Installer.Type;
         }
@@ -821,10 +1099,211 @@ public class InstallerService
             ".nupkg" => "nupkg",  // sbin-installer with choco fallback
             ".msix" or ".appx" or ".msixbundle" or ".appxbundle" => "msix",
             ".ps1" => "powershell",
+            ".zip" => "zip",
+            ".iso" => "iso",
             _ => "exe"
         };
     }
 
+    #region Archive Installers (zip/iso)
+
+    /// <summary>
+    /// Installs from a zip or iso whose real setup lives inside the archive.
+    /// Zips are extracted to a staging directory (installer temp_dir when set,
+    /// otherwise {cache}\extract); isos are mounted read-only with Mount-DiskImage.
+    /// The inner installer at installer.inner_path is then dispatched exactly as
+    /// if it had been downloaded on its own, and the staging directory / mount is
+    /// always torn down afterwards so a failed install doesn't strand a mounted
+    /// volume or a few GB of extracted media in the cache.
+    /// </summary>
+    private async Task<(bool Success, string Output)> InstallFromArchiveAsync(
+        CatalogItem item,
+        string localFile,
+        string archiveType,
+        CancellationToken cancellationToken)
+    {
+        if (string.IsNullOrWhiteSpace(item.Installer.InnerPath))
+        {
+            return (false, $"{archiveType} installer for {item.Name} has no inner_path defined");
+        }
+
+        if (!File.Exists(localFile))
+        {
+            return (false, $"Archive not found: {localFile}");
+        }
+
+        string? stagingDir = null;
+        var mounted = false;
+        try
+        {
+            string root;
+            if (archiveType == "iso")
+            {
+                var (mountOk, driveRoot, mountOutput) = await MountIsoAsync(localFile, cancellationToken);
+                if (!mountOk)
+                {
+                    return (false, $"Failed to mount ISO: {mountOutput}");
+                }
+                mounted = true;
+                root = driveRoot;
+                ConsoleLogger.Detail($"Mounted {Path.GetFileName(localFile)} at {root}");
+            }
+            else
+            {
+                var extractBase = !string.IsNullOrEmpty(item.Installer.TempDir)
+                    ? item.Installer.TempDir
+                    : Path.Combine(_config.CachePath, "extract");
+                stagingDir = Path.Combine(extractBase, $"{item.Name}_{Guid.NewGuid():N}");
+                Directory.CreateDirectory(stagingDir);
+                ConsoleLogger.Detail($"Extracting {Path.GetFileName(localFile)} to {stagingDir}");
+                // ExtractToDirectory rejects entries that resolve outside the
+                // destination (zip-slip), so a hostile archive can't write elsewhere.
+                await Task.Run(() => ZipFile.ExtractToDirectory(localFile, stagingDir, overwriteFiles: true), cancellationToken);
+                root = stagingDir;
+            }
+
+            var innerFile = ResolveArchiveInnerPath(root, item.Installer.InnerPath);
+            if (innerFile == null)
+            {
+                return (false, $"inner_path '{item.Installer.InnerPath}' escapes the archive root");
+            }
+            if (!File.Exists(innerFile))
+            {
+                return (false, $"inner_path '{item.Installer.InnerPath}' not found in {archiveType} archive");
+            }
+
+            var innerType = InferArchiveInnerType(item.Installer.InnerType, innerFile);
+            ConsoleLogger.Info($"[INSTALLER METHOD: {archiveType}] Running inner {innerType} installer: {item.Installer.InnerPath}");
+            _sessionLogger?.Log("INFO", $"Running {innerType} installer {item.Installer.InnerPath} from {archiveType} archive for {item.Name}");
+
+            return innerType switch
+            {
+                "msi" => await InstallMsiAsync(item, innerFile, cancellationToken),
+                "msix" or "appx" => await InstallMsixAsync(item, innerFile, cancellationToken),
+                "powershell" or "ps1" => await InstallPowerShellAsync(item, innerFile, cancellationToken),
+                "nupkg" => await InstallNupkgWithSbinAsync(item, innerFile, cancellationToken),
+                _ => await InstallExeAsync(item, innerFile, cancellationToken)
+            };
+        }
+        catch (OperationCanceledException)
+        {
+            throw;
+        }
+        catch (Exception ex)
+        {
+            return (false, $"Archive install failed: {ex.Message}");
+        }
+        finally
+        {
+            if (mounted)
+            {
+                await DismountIsoAsync(localFile);
+            }
+            if (stagingDir != null)
+            {
+                try
+                {
+                    Directory.Delete(stagingDir, recursive: true);
+                }
+                catch (Exception ex)
+                {
+                    ConsoleLogger.Debug($"Failed to remove staging directory {stagingDir} (non-fatal): {ex.Message}");
+                }
+            }
+        }
+    }
+
+    /// <summary>
+    /// Resolves inner_path against the extraction/mount root. Returns null when the
+    /// path is rooted or walks out of the root via "..", so pkginfo can't point the
+    /// agent at an arbitrary file on disk.
+    /// </summary>
+    internal static string? ResolveArchiveInnerPath(string root, string innerPath)
+    {
+        var relative = innerPath.Trim().Replace('/', Path.DirectorySeparatorChar).Replace('\\', Path.DirectorySeparatorChar);
+        if (Path.IsPathRooted(relative))
+        {
+            return null;
+        }
+
+        var fullRoot = Path.GetFullPath(root);
+        if (!fullRoot.EndsWith(Path.DirectorySeparatorChar))
+        {
+            fullRoot += Path.DirectorySeparatorChar;
+        }
+
+        var full = Path.GetFullPath(Path.Combine(fullRoot, relative));
+        return full.StartsWith(fullRoot, StringComparison.OrdinalIgnoreCase) ? full : null;
+    }
+
+    /// <summary>
+    /// Picks the inner installer type: explicit inner_type wins, otherwise the
+    /// inner file's extension. Unknown extensions fall back to exe, matching
+    /// GetInstallerType.
+    /// </summary>
+    internal static string InferArchiveInnerType(string? innerType, string innerFile)
+    {
+        if (!string.IsNullOrWhiteSpace(innerType))
+        {
+            return innerType.Trim().ToLowerInvariant();
+        }
+
+        return Path.GetExtension(innerFile).ToLowerInvariant() switch
+        {
+            ".msi" => "msi",
+            ".msix" or ".appx" or ".msixbundle" or ".appxbundle" => "msix",
+            ".ps1" => "powershell",
+            ".nupkg" => "nupkg",
+            _ => "exe"
+        };
+    }
+
+    /// <summary>
+    /// Mounts an ISO with Mount-DiskImage and returns the drive root (e.g. "E:\").
+    /// </summary>
+    private async Task<(bool Success, string DriveRoot, string Output)> MountIsoAsync(
+        string isoPath,
+        CancellationToken cancellationToken)
+    {
+        var escapedPath = isoPath.Replace("'", "''");
+        var script = $@"
+$ErrorActionPreference = 'Stop'
+$img = Mount-DiskImage -ImagePath '{escapedPath}' -Access ReadOnly -StorageType ISO -PassThru
+$vol = $img | Get-Volume
+if (-not $vol.DriveLetter) {{ Write-Output 'ERROR|no drive letter assigned'; exit 1 }}
+Write-Output ""DRIVE|$($vol.DriveLetter)""
+";
+        var (ok, output) = await _scriptService.ExecuteScriptAsync(script, cancellationToken);
+        foreach (var line in output.Split('\n', '\r'))
+        {
+            var trimmed = line.Trim();
+            if (trimmed.StartsWith("DRIVE|", StringComparison.Ordinal) && trimmed.Length > 6)
+            {
+                return (true, $"{trimmed[6]}:\\", output);
+            }
+        }
+
+        // Mount may have succeeded without a drive letter; don't leave it attached.
+        if (ok)
+        {
+            await DismountIsoAsync(isoPath);
+        }
+        return (false, string.Empty, output.Trim());
+    }
+
+    private async Task DismountIsoAsync(string isoPath)
+    {
+        var escapedPath = isoPath.Replace("'", "''");
+        var (ok, output) = await _scriptService.ExecuteScriptAsync(
+            $"Dismount-DiskImage -ImagePath '{escapedPath}' -ErrorAction Stop | Out-Null", CancellationToken.None);
+        if (!ok)
+        {
+            ConsoleLogger.Warn($"Failed to dismount {isoPath}: {output.Trim()}");
+        }
+    }
+
+    #endregion
+
     private async Task<(bool Success, string Output)> InstallMsiAsync(
         CatalogItem item,
         string localFile,
@@ -841,7 +1320,7 @@ public class InstallerService
         // pins at the old build and the item reinstall-loops every session until
         // LoopGuard suppresses it (73 devices, AB#3709).
         var replacesSbinInstaller = string.Equals(item.Name, "SbinInstaller", StringComparison.OrdinalIgnoreCase);
-        if (!replacesSbinInstaller && IsCimianBuiltMsi(localFile) && IsSbinInstallerAvailable())
+        if (!replacesSbinInstaller && !item.InstallsAsUser && IsCimianBuiltMsi(localFile) && IsSbinInstallerAvailable())
         {
             ConsoleLogger.Info($"[INSTALLER METHOD: sbin-installer] cimipkg-built MSI detected: {item.Name}");
             return await RunSbinInstallerAsync(localFile, item, cancellationToken);
@@ -867,14 +1346,22 @@ public class InstallerService
             RotateMsiInstallLogs(item.Name);
             var logPath = Path.Combine(_config.CachePath, $"{item.Name}_install.1.log");
 
-            List<string> BuildArgs() => new()
+            List<string> BuildArgs()
             {
-                "/i",
-                $"\"{localFile}\"",
-                "/qn",  // Quiet, no UI
-                "/norestart",
-                $"/l*v \"{logPath}\""
-            };
+                var args = new List<string>
+                {
+                    "/i",
+                    $"\"{localFile}\"",
+                    "/qn",  // Quiet, no UI
+                    "/norestart"
+                };
+                // The user can't write to the cache; a per-user install goes unlogged
+                if (!item.InstallsAsUser)
+                {
+                    args.Add($"/l*v \"{logPath}\"");
+                }
+                return args;
+            }
 
             for (int attempt = 1; attempt <= MsiexecMaxRetries; attempt++)
             {
@@ -891,7 +1378,8 @@ public class InstallerService
                     CreateNoWindow = true
                 };
 
-                var (ok, output) = await RunProcessWithTimeoutAsync(startInfo, item.Name, cancellationToken);
+                var (ok, output) = await RunProcessWithTimeoutAsync(startInfo, item, cancellationToken,
+                    item.Installer.SuccessExitCodes, item.Installer.RebootExitCodes, runAsUser: item.InstallsAsUser);
                 if (ok) return (true, output);
 
                 // 1618 = ERROR_INSTALL_ALREADY_RUNNING. Retry with backoff.
@@ -1090,7 +1578,8 @@ public class InstallerService
             CreateNoWindow = true
         };
 
-        return await RunProcessWithTimeoutAsync(startInfo, item.Name, cancellationToken);
+        return await RunProcessWithTimeoutAsync(startInfo, item, cancellationToken,
+            item.Installer.SuccessExitCodes, item.Installer.RebootExitCodes, runAsUser: item.InstallsAsUser);
     }
 
     private async Task<(bool Success, string Output)> InstallChocolateyAsync(
@@ -1098,45 +1587,55 @@ public class InstallerService
         string localFile,
         CancellationToken cancellationToken)
     {
-        var chocoExe = Path.Combine(
-            Environment.GetFolderPath(Environment.SpecialFolder.CommonApplicationData),
-            "chocolatey", "bin", "choco.exe");
-
-        if (!File.Exists(chocoExe))
+        if (!File.Exists(ChocolateyService.ChocoExe))
         {
             return (false, "Chocolatey is not installed");
         }
 
-        var args = new List<string>
+        var chocolatey = new ChocolateyService(_config);
+        var (sources, sourceError) = chocolatey.ResolveSources(item);
+        if (sourceError != null)
         {
-            "install",
-            item.Name,
-            "--yes",
-            "--no-progress",
-            "--force"
-        };
-
-        if (!string.IsNullOrEmpty(item.Version))
+            return (false, sourceError);
+        }
+        if (string.IsNullOrEmpty(localFile) && sources.Count == 0)
+        {
+            return (false, $"{item.Name} has no installer location and no ChocolateySources to install it from");
+        }
+        var registerError = await chocolatey.EnsureSourcesAsync(sources, cancellationToken);
+        if (registerError != null)
         {
-            args.Add($"--version={item.Version}");
+            return (false, registerError);
         }
 
-        if (!string.IsNullOrEmpty(localFile))
+        // A pin would stop this upgrade as well as the machine-wide ones
+        if (item.Installer.Pin)
         {
-            args.Add($"--source=\"{Path.GetDirectoryName(localFile)}\"");
+            await ChocolateyService.RunAsync(ChocolateyService.BuildPinArgs(item, add: false), cancellationToken);
         }
 
         var startInfo = new ProcessStartInfo
         {
-            FileName = chocoExe,
-            Arguments = string.Join(" ", args),
+            FileName = ChocolateyService.ChocoExe,
+            Arguments = string.Join(" ", ChocolateyService.BuildInstallArgs(item, localFile, sources)),
             UseShellExecute = false,
             RedirectStandardOutput = true,
             RedirectStandardError = true,
             CreateNoWindow = true
         };
 
-        return await RunProcessWithTimeoutAsync(startInfo, item.Name, cancellationToken);
+        var result = await RunProcessWithTimeoutAsync(startInfo, item, cancellationToken,
+            item.Installer.SuccessExitCodes, item.Installer.RebootExitCodes);
+
+        if (result.Success && item.Installer.Pin)
+        {
+            var (pinExit, pinOutput) = await ChocolateyService.RunAsync(ChocolateyService.BuildPinArgs(item, add: true), cancellationToken);
+            if (pinExit != 0)
+            {
+                ConsoleLogger.Warn($"Could not pin {ChocolateyService.PackageId(item)} at {item.Version}: {pinOutput.Trim()}");
+            }
+        }
+        return result;
     }
 
     /// <summary>
@@ -1172,6 +1671,9 @@ public class InstallerService
     /// Cimian's daemon-style deployment model. Per-user-install removal preserves
     /// the app's data directory under %LOCALAPPDATA%\Packages\&lt;PackageFamilyName&gt;
     /// so user settings survive the remediation.
+    ///
+    /// Items with <c>provision: false</c> install with Add-AppxPackage for the
+    /// running account instead (see <see cref="ShouldProvisionMsix"/>).
     /// </summary>
     private async Task<(bool Success, string Output)> InstallMsixAsync(
         CatalogItem item,
@@ -1179,8 +1681,22 @@ public class InstallerService
         CancellationToken cancellationToken)
     {
         _lastResolvedMsixPackageFullName = null;
+        var runningAsSystem = IsRunningAsSystem();
+        var provision = ShouldProvisionMsix(item, runningAsSystem);
+        if (item.InstallsAsUser)
+        {
+            ConsoleLogger.Info($"MSIX {item.Name}: install_context: user - installing for the logged-in user only");
+        }
+        else if (!provision)
+        {
+            ConsoleLogger.Info($"MSIX {item.Name}: provision: false - installing for the current account only");
+        }
+        else if (item.Provision == false)
+        {
+            ConsoleLogger.Warn($"MSIX {item.Name}: provision: false ignored when running as SYSTEM - provisioning for all users");
+        }
         _sessionLogger?.LogInstall(item.Name, item.Version, "install", "started",
-            $"Installing MSIX {item.Name} via Add-AppxProvisionedPackage");
+            $"Installing MSIX {item.Name} via {(provision ? "Add-AppxProvisionedPackage" : "Add-AppxPackage")}");
 
         // Look up the package Identity.Name from the installs-array entry that
         // cimiimport emits. This is used for the preflight query; without it we
@@ -1208,6 +1724,7 @@ $ErrorActionPreference = 'Stop'
 $logFile = '{escapedLog}'
 $identity = '{escapedIdentity}'
 $catalogVerStr = '{escapedCatalogVer}'
+$provision = ${(provision ? "true" : "false")}
 function Write-Log($msg) {{ try {{ Add-Content -Path $logFile -Value $msg -Encoding utf8 }} catch {{}} }}
 
 # --- Preflight: discover any existing installation across both stores ---
@@ -1258,6 +1775,25 @@ if ($highest -and $highest.PerUser -and $identity) {{
     }}
 }}
 
+# --- Install for the running account only (provision: false) ---
+if (-not $provision) {{
+    try {{
+        Add-AppxPackage -Path '{escapedPath}' -ForceApplicationShutdown -ErrorAction Stop
+        $installed = $null
+        if ($identity) {{
+            $installed = Get-AppxPackage -Name $identity -ErrorAction SilentlyContinue | Sort-Object {{ [System.Version]$_.Version }} -Descending | Select-Object -First 1
+        }}
+        Write-Log ""Add-AppxPackage complete: $($installed.PackageFullName)""
+        Write-Output ""OK|$($installed.PackageFullName)""
+        exit 0
+    }} catch {{
+        $msg = $_.Exception.Message
+        Write-Log $msg
+        Write-Output ""ERROR|$msg""
+        exit 1
+    }}
+}}
+
 # --- Install: provision the new package ---
 try {{
     $result = Add-AppxProvisionedPackage -Online -PackagePath '{escapedPath}' -SkipLicense
@@ -1279,7 +1815,8 @@ try {{
 }}
 ";
 
-        var (success, output) = await _scriptService.ExecuteScriptAsync(installScript, cancellationToken);
+        var runner = item.InstallsAsUser ? _scriptService.AsActiveUser() : _scriptService;
+        var (success, output) = await runner.ExecuteScriptAsync(installScript, cancellationToken);
 
         // Parse the outcome marker from stdout. ScriptService returns combined
         // stdout+stderr; the script emits exactly one of OK/SKIP/ERROR on its
@@ -1344,12 +1881,36 @@ try {{
         return (true, output);
     }
 
+    /// <summary>
+    /// Whether an MSIX item is provisioned machine-wide. Unset means yes; an
+    /// explicit <c>provision: false</c> is honoured only outside the SYSTEM
+    /// account, where a per-account install would never reach a real user.
+    /// install_context: user always installs for the user it runs as.
+    /// </summary>
+    internal static bool ShouldProvisionMsix(CatalogItem item, bool runningAsSystem) =>
+        !item.InstallsAsUser && (item.Provision != false || runningAsSystem);
+
+    private static bool IsRunningAsSystem()
+    {
+        try
+        {
+            using var identity = System.Security.Principal.WindowsIdentity.GetCurrent();
+            return identity.IsSystem;
+        }
+        catch
+        {
+            return true;
+        }
+    }
+
     private async Task<(bool Success, string Output)> InstallPowerShellAsync(
         CatalogItem item,
         string localFile,
         CancellationToken cancellationToken)
     {
-        return await _scriptService.ExecuteScriptFileAsync(localFile, cancellationToken);
+        var runner = item.InstallsAsUser ? _scriptService.ForItem(item).AsActiveUser() : _scriptService.ForItem(item);
+        var result = await runner.RunFileAsync(ScriptKind.Install, localFile, cancellationToken: cancellationToken);
+        return (result.Success, result.Output);
     }
 
     private async Task<(bool Success, string Output)> InstallScriptOnlyAsync(
@@ -1364,10 +1925,13 @@ try {{
 
         ConsoleLogger.Info($"Running install_script for {item.Name}...");
         _sessionLogger?.Log("INFO", $"Executing install_script for {item.Name}");
-        return await _scriptService.ExecuteScriptAsync(item.InstallScript, cancellationToken);
+        var runner = item.InstallsAsUser ? _scriptService.ForItem(item).AsActiveUser() : _scriptService.ForItem(item);
+        var result = await runner.RunAsync(ScriptKind.Install, item.InstallScript, cancellationToken);
+        return (result.Success, result.Output);
     }
 
     private async Task<(bool Success, string Output)> UninstallMsiAsync(
+        CatalogItem item,
         UninstallerInfo uninstaller,
         CancellationToken cancellationToken)
     {
@@ -1396,7 +1960,8 @@ try {{
             CreateNoWindow = true
         };
 
-        var result = await RunProcessWithTimeoutAsync(startInfo, "uninstall", cancellationToken);
+        var result = await RunProcessWithTimeoutAsync(startInfo, item, cancellationToken,
+            uninstaller.SuccessExitCodes, uninstaller.RebootExitCodes);
 
         // An uninstall whose product is already gone is a success, not a failure.
         // msiexec returns 1605 (ERROR_UNKNOWN_PRODUCT) or 1614 (ERROR_PRODUCT_UNINSTALLED)
@@ -1415,6 +1980,7 @@ try {{
     }
 
     private async Task<(bool Success, string Output)> UninstallExeAsync(
+        CatalogItem item,
         UninstallerInfo uninstaller,
         CancellationToken cancellationToken)
     {
@@ -1433,10 +1999,12 @@ try {{
             CreateNoWindow = true
         };
 
-        return await RunProcessWithTimeoutAsync(startInfo, "uninstall", cancellationToken);
+        return await RunProcessWithTimeoutAsync(startInfo, item, cancellationToken,
+            uninstaller.SuccessExitCodes, uninstaller.RebootExitCodes);
     }
 
     private async Task<(bool Success, string Output)> UninstallPowerShellAsync(
+        CatalogItem item,
         UninstallerInfo uninstaller,
         CancellationToken cancellationToken)
     {
@@ -1445,40 +2013,112 @@ try {{
             return (false, "No uninstall script specified");
         }
 
-        return await _scriptService.ExecuteScriptAsync(uninstaller.Command, cancellationToken);
+        var result = await _scriptService.ForItem(item).RunAsync(ScriptKind.Uninstall, uninstaller.Command, cancellationToken);
+        return (result.Success, result.Output);
     }
 
     /// <summary>
-    /// Removes an app that has no explicit uninstaller metadata by driving the
-    /// uninstaller the app itself registered in the Windows uninstall registry.
-    /// Prefers QuietUninstallString (already silent); otherwise runs UninstallString
-    /// and appends silent switches inferred from the uninstaller engine (NSIS "/S",
-    /// Inno "/VERYSILENT /SUPPRESSMSGBOXES /NORESTART") or the pkginfo's uninstaller
-    /// switches when present. Reached for exe-type installers (see UninstallAsync) --
-    /// keyed on the install mechanism, not unattended_uninstall: a user-initiated
-    /// Remove must work even for packages that opt out of silent background removal.
+    /// Removes an app by driving the uninstaller it registered in the Windows
+    /// Uninstall hives (<see cref="ArpRegistry"/>): HKLM in both views and the
+    /// console user's hive. Reached for a type: registry uninstaller block, and
+    /// for exe installers with no other uninstall metadata -- keyed on the
+    /// install mechanism, not unattended_uninstall: a user-initiated Remove must
+    /// work even for packages that opt out of silent background removal.
+    /// Per-user entries are uninstalled as that user.
     /// </summary>
     private async Task<(bool Success, string Output)> UninstallViaRegistryAsync(
         CatalogItem item,
+        UninstallerInfo? uninstaller,
         CancellationToken cancellationToken)
     {
-        var resolved = ResolveRegistryUninstall(item);
-        if (resolved == null)
+        var query = BuildArpQuery(item, uninstaller);
+        ArpEntry? entry;
+        try
+        {
+            entry = ArpRegistry.Find(ArpRegistry.Scan(UserContextRunner.GetActiveUserSid()), query);
+        }
+        catch (ArgumentException ex)
+        {
+            return (false, $"display_name_regex for {item.Name} is not a valid regular expression: {ex.Message}");
+        }
+        catch (RegexMatchTimeoutException)
         {
+            return (false, $"display_name_regex for {item.Name} took too long to match");
+        }
+
+        if (entry == null)
+        {
+            ConsoleLogger.Debug($"No registry uninstall entry found for {item.Name} (product code '{query.ProductCode}', regex '{query.DisplayNameRegex}', display name '{query.DisplayName}')");
             return (false,
                 $"No uninstall information found in the Windows registry for {item.Name}. " +
                 "Add an uninstaller block or uninstall_script to the package.");
         }
+        ConsoleLogger.Debug($"Resolved {(entry.IsQuiet ? "QuietUninstallString" : "UninstallString")} for {item.Name} via '{entry.DisplayName}' ({entry.KeyName})");
+
+        var declared = uninstaller ?? item.Uninstaller.FirstOrDefault();
+        var command = BuildRegistryUninstallCommand(entry, declared);
+        if (command == null)
+        {
+            return (false, $"Unparseable uninstall command for {item.Name}: {entry.Command}");
+        }
+
+        var startInfo = new ProcessStartInfo
+        {
+            FileName = command.Value.Exe,
+            Arguments = command.Value.Args,
+            UseShellExecute = false,
+            RedirectStandardOutput = true,
+            RedirectStandardError = true,
+            CreateNoWindow = true
+        };
+
+        var asUser = entry.UserSid != null;
+        ConsoleLogger.Info($"Removing {item.Name} via registry uninstaller{(asUser ? " as the logged-in user" : "")}: {startInfo.FileName} {startInfo.Arguments}".TrimEnd());
+        _sessionLogger?.Log("INFO", $"Uninstalling {item.Name} via registry UninstallString ({entry.KeyName})");
+        return await RunProcessWithTimeoutAsync(startInfo, item, cancellationToken,
+            declared?.SuccessExitCodes, declared?.RebootExitCodes, runAsUser: asUser);
+    }
+
+    /// <summary>
+    /// What identifies the item's Uninstall entry. A type: registry block's
+    /// product_code and display_name_regex win; otherwise the MSI product code
+    /// from installs[] or installer. The display name (falling back to the
+    /// item name) is only searched for when no regex was given, so a regex
+    /// that matches nothing never falls through to a looser name match.
+    /// </summary>
+    internal static ArpQuery BuildArpQuery(CatalogItem item, UninstallerInfo? uninstaller)
+    {
+        var productCode = uninstaller?.ProductCode;
+        if (string.IsNullOrEmpty(productCode))
+        {
+            productCode = item.Installs.FirstOrDefault(i => i.EffectiveType() == "msi" && !string.IsNullOrEmpty(i.ProductCode))?.ProductCode
+                ?? (string.Equals(item.Installer?.Type, "msi", StringComparison.OrdinalIgnoreCase) ? item.Installer?.ProductCode : null);
+        }
+
+        var regex = uninstaller?.DisplayNameRegex;
+        var displayName = !string.IsNullOrWhiteSpace(regex) ? null
+            : !string.IsNullOrEmpty(item.DisplayName) ? item.DisplayName : item.Name;
+        return new ArpQuery(productCode, regex, displayName);
+    }
 
-        var (command, isQuiet) = resolved.Value;
-        var (exe, embeddedArgs) = SplitCommandLine(command);
+    /// <summary>
+    /// The executable and arguments for an Uninstall entry. QuietUninstallString
+    /// runs as registered; UninstallString gets the declared uninstaller's
+    /// switches, or ones inferred from the uninstaller engine (NSIS "/S", Inno
+    /// "/VERYSILENT /SUPPRESSMSGBOXES /NORESTART"). msiexec entries are turned
+    /// into a silent /X removal. Null when the command has no executable.
+    /// </summary>
+    internal static (string Exe, string Args)? BuildRegistryUninstallCommand(ArpEntry entry, UninstallerInfo? declared)
+    {
+        var (exe, embeddedArgs) = SplitCommandLine(entry.Command ?? "");
         if (string.IsNullOrWhiteSpace(exe))
         {
-            return (false, $"Unparseable uninstall command for {item.Name}: {command}");
+            return null;
         }
 
         // msiexec entries can slip through here when the product wasn't matched by
         // product_code earlier; normalise to a silent removal.
+        var isQuiet = entry.IsQuiet;
         if (Path.GetFileNameWithoutExtension(exe).Equals("msiexec", StringComparison.OrdinalIgnoreCase))
         {
             embeddedArgs = embeddedArgs.Replace("/I", "/X", StringComparison.OrdinalIgnoreCase);
@@ -1492,29 +2132,12 @@ try {{
 
         if (!isQuiet)
         {
-            if (item.Uninstaller.Count > 0 && item.Uninstaller[0].Switches.Count > 0)
-            {
-                args.Add(string.Join(" ", item.Uninstaller[0].GetAllArgs()));
-            }
-            else
-            {
-                args.Add(InferRegistrySilentSwitch(exe));
-            }
+            args.Add(declared is { Switches.Count: > 0 }
+                ? string.Join(" ", declared.GetAllArgs())
+                : InferRegistrySilentSwitch(exe));
         }
 
-        var startInfo = new ProcessStartInfo
-        {
-            FileName = exe,
-            Arguments = string.Join(" ", args).Trim(),
-            UseShellExecute = false,
-            RedirectStandardOutput = true,
-            RedirectStandardError = true,
-            CreateNoWindow = true
-        };
-
-        ConsoleLogger.Info($"Removing {item.Name} via registry uninstaller: {exe} {startInfo.Arguments}".TrimEnd());
-        _sessionLogger?.Log("INFO", $"Uninstalling {item.Name} via registry UninstallString");
-        return await RunProcessWithTimeoutAsync(startInfo, "uninstall", cancellationToken);
+        return (exe, string.Join(" ", args).Trim());
     }
 
     /// <summary>
@@ -1535,71 +2158,6 @@ try {{
         return isInno ? "/VERYSILENT /SUPPRESSMSGBOXES /NORESTART" : "/S";
     }
 
-    /// <summary>
-    /// Finds the app's Windows uninstall entry by display name (exact, then a
-    /// registry-name-contains match — never the reverse, which would let a short
-    /// name adopt an unrelated app) and returns its uninstall command. Searches
-    /// both 64- and 32-bit views. Returns (command, isQuiet) or null if not found.
-    /// </summary>
-    private (string Command, bool IsQuiet)? ResolveRegistryUninstall(CatalogItem item)
-    {
-        var target = !string.IsNullOrEmpty(item.DisplayName) ? item.DisplayName : item.Name;
-        if (string.IsNullOrEmpty(target)) return null;
-
-        // `using` so the base-key handles are released on every exit path; left
-        // open these leak unmanaged registry handles across repeated removals.
-        using var base64 = RegistryKey.OpenBaseKey(RegistryHive.LocalMachine, RegistryView.Registry64);
-        using var base32 = RegistryKey.OpenBaseKey(RegistryHive.LocalMachine, RegistryView.Registry32);
-        var bases = new[] { base64, base32 };
-        const string uninstallPath = @"SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall";
-
-        // Two passes: exact display-name match first, then contains.
-        foreach (var exact in new[] { true, false })
-        {
-            foreach (var baseKey in bases)
-            {
-                try
-                {
-                    using var root = baseKey.OpenSubKey(uninstallPath);
-                    if (root == null) continue;
-
-                    foreach (var subName in root.GetSubKeyNames())
-                    {
-                        using var sub = root.OpenSubKey(subName);
-                        var name = sub?.GetValue("DisplayName")?.ToString();
-                        if (string.IsNullOrEmpty(name)) continue;
-
-                        var match = exact
-                            ? string.Equals(name, target, StringComparison.OrdinalIgnoreCase)
-                            : name.Contains(target, StringComparison.OrdinalIgnoreCase);
-                        if (!match) continue;
-
-                        var quiet = sub!.GetValue("QuietUninstallString")?.ToString();
-                        if (!string.IsNullOrWhiteSpace(quiet))
-                        {
-                            ConsoleLogger.Debug($"Resolved QuietUninstallString for {item.Name} via '{name}'");
-                            return (quiet!, true);
-                        }
-
-                        var uninstallString = sub.GetValue("UninstallString")?.ToString();
-                        if (!string.IsNullOrWhiteSpace(uninstallString))
-                        {
-                            ConsoleLogger.Debug($"Resolved UninstallString for {item.Name} via '{name}'");
-                            return (uninstallString!, false);
-                        }
-                    }
-                }
-                catch (Exception ex)
-                {
-                    ConsoleLogger.Debug($"Registry uninstall search error for {item.Name}: {ex.Message}");
-                }
-            }
-        }
-
-        ConsoleLogger.Debug($"No registry uninstall entry found for {item.Name} (searched display name '{target}')");
-        return null;
-    }
-
     /// <summary>
     /// Splits a registry uninstall command into its executable path and the
     /// remaining argument string. Handles a quoted exe ("C:\..\unins000.exe" /X)
@@ -1791,19 +2349,84 @@ exit 0
         }
     }
 
+    /// <summary>
+    /// True when an install or uninstall output carries <see cref="RestartRequiredTag"/>.
+    /// </summary>
+    public static bool InstallerRequestedRestart(string? output) =>
+        output != null && output.Contains(RestartRequiredTag + "=", StringComparison.Ordinal);
+
+    /// <summary>Exit codes that mean "succeeded, restart needed" when an item lists none.</summary>
+    private static readonly int[] DefaultRebootExitCodes = [3010, 1641]; // 3010 = reboot required, 1641 = reboot initiated
+
+    /// <summary>
+    /// Classifies an installer exit code against the item's success_exit_codes and
+    /// reboot_exit_codes. A code in success_exit_codes wins, so an item can list
+    /// 3010 there to succeed without asking for a restart.
+    /// </summary>
+    public static (bool Success, bool RestartRequired) ClassifyExitCode(
+        int exitCode,
+        IReadOnlyCollection<int>? successExitCodes = null,
+        IReadOnlyCollection<int>? rebootExitCodes = null)
+    {
+        if (successExitCodes != null && successExitCodes.Contains(exitCode))
+        {
+            return (true, false);
+        }
+        var rebootCodes = rebootExitCodes is { Count: > 0 } ? rebootExitCodes : DefaultRebootExitCodes;
+        if (rebootCodes.Contains(exitCode))
+        {
+            return (true, true);
+        }
+        return (exitCode == 0, false);
+    }
+
+    /// <summary>
+    /// How long <paramref name="item"/>'s installer or uninstaller may run: its
+    /// installer_timeout (minutes) when set, otherwise the Config.yaml default.
+    /// </summary>
+    internal static TimeSpan InstallerTimeoutFor(CatalogItem item, CimianConfig config) =>
+        item.InstallerTimeout is int minutes and > 0
+            ? TimeSpan.FromMinutes(minutes)
+            : config.EffectiveInstallerTimeout;
+
     private async Task<(bool Success, string Output)> RunProcessWithTimeoutAsync(
         ProcessStartInfo startInfo,
-        string itemName,
-        CancellationToken cancellationToken)
+        CatalogItem item,
+        CancellationToken cancellationToken,
+        IReadOnlyCollection<int>? successExitCodes = null,
+        IReadOnlyCollection<int>? rebootExitCodes = null,
+        bool runAsUser = false)
     {
+        var itemName = item.Name;
         var output = new StringBuilder();
-        var timeout = TimeSpan.FromSeconds(_config.InstallerTimeout);
+        var timeout = InstallerTimeoutFor(item, _config);
+        using var heartbeat = ConsoleLogger.HeartbeatWhile($"Waiting for {itemName}'s installer", timeout);
 
         ConsoleLogger.Detail($"Launching process: {startInfo.FileName}");
         if (!string.IsNullOrEmpty(startInfo.Arguments))
             ConsoleLogger.Detail($"Arguments: {startInfo.Arguments}");
         ConsoleLogger.Detail($"Timeout: {timeout.TotalMinutes} minutes");
 
+        if (runAsUser)
+        {
+            try
+            {
+                var (userExitCode, userOutput) = await UserContextRunner.RunAsync(startInfo, timeout, cancellationToken);
+                ConsoleLogger.Detail($"Process exited with code {userExitCode}");
+                output.Append(userOutput);
+                return ClassifyProcessResult(userExitCode, output, successExitCodes, rebootExitCodes);
+            }
+            catch (TimeoutException ex)
+            {
+                ConsoleLogger.Warn(ex.Message);
+                return (false, $"Installation timed out after {timeout.TotalMinutes} minutes: {ex.Message}");
+            }
+            catch (Exception ex)
+            {
+                return (false, $"Process execution as the logged-in user failed: {ex.Message}");
+            }
+        }
+
         try
         {
             using var process = new Process { StartInfo = startInfo };
@@ -1828,6 +2451,8 @@ exit 0
 
             process.Start();
             ConsoleLogger.Detail($"Process started with PID {process.Id}");
+            // Everything the installer starts joins the job, so a timeout can stop it all
+            using var job = InstallerJob.TryAttach(process);
             process.BeginOutputReadLine();
             process.BeginErrorReadLine();
 
@@ -1838,32 +2463,20 @@ exit 0
             {
                 await process.WaitForExitAsync(cts.Token);
             }
+            catch (OperationCanceledException) when (cancellationToken.IsCancellationRequested)
+            {
+                var tree = StopProcessTree(item, process, job, timeout, cancelled: true);
+                return (false, $"Installation cancelled. Processes stopped:\n{tree}");
+            }
             catch (OperationCanceledException)
             {
-                ConsoleLogger.Warn($"Process timed out after {timeout.TotalMinutes} minutes, killing PID {process.Id}");
-                try
-                {
-                    process.Kill(true);
-                }
-                catch { }
-                
-                return (false, $"Installation timed out after {timeout.TotalMinutes} minutes");
+                var tree = StopProcessTree(item, process, job, timeout);
+                return (false, $"Installation timed out after {timeout.TotalMinutes} minutes. Processes stopped:\n{tree}");
             }
 
             var exitCode = process.ExitCode;
             ConsoleLogger.Detail($"Process exited with code {exitCode}");
-            
-            // Common success exit codes
-            if (exitCode == 0 || exitCode == 3010) // 3010 = reboot required
-            {
-                if (exitCode == 3010)
-                {
-                    output.AppendLine("Note: A reboot is required to complete the installation");
-                }
-                return (true, output.ToString());
-            }
-
-            return (false, $"Exit code: {exitCode}\n{output}");
+            return ClassifyProcessResult(exitCode, output, successExitCodes, rebootExitCodes);
         }
         catch (Exception ex)
         {
@@ -1871,6 +2484,66 @@ exit 0
         }
     }
 
+    /// <summary>
+    /// Stops an installer that timed out (or whose run was cancelled) and every
+    /// process it started, so a hung GUI installer doesn't leave children (often
+    /// a hidden dialog or an msiexec holding locks) running. The tree is logged
+    /// and returned: the process the installer was waiting on is usually in it.
+    /// </summary>
+    private string StopProcessTree(CatalogItem item, Process process, InstallerJob? job, TimeSpan timeout, bool cancelled = false)
+    {
+        var what = cancelled ? "run was cancelled" : $"installer timed out after {timeout.TotalMinutes} minutes";
+        var stop = InstallerJob.StopTree(process.Id, job, () => process.Kill(entireProcessTree: true));
+        var tree = stop.Describe();
+        ConsoleLogger.Warn($"{item.Name}: {what}, stopped {stop.Stopped.Count} process(es):\n{tree}");
+        if (stop.Survivors.Count > 0)
+        {
+            ConsoleLogger.Warn($"{item.Name}: {stop.Survivors.Count} installer process(es) could not be stopped and may hold files the next attempt needs");
+        }
+        _sessionLogger?.LogEvent(new LogEvent
+        {
+            Level = "WARN",
+            EventType = cancelled ? "installer_cancelled" : "installer_timeout",
+            PackageName = item.Name,
+            PackageVersion = item.Version,
+            Status = cancelled ? "cancelled" : "timeout",
+            Message = $"The {what}; stopped {stop.Stopped.Count} process(es), {stop.Survivors.Count} still running",
+            InstallerType = item.Installer.Type,
+            Context = new Dictionary<string, object>
+            {
+                ["timeout_minutes"] = timeout.TotalMinutes,
+                ["process_tree"] = InstallerJob.FormatTree(stop.Stopped),
+                ["processes_stopped"] = stop.Stopped.Count,
+                ["processes_surviving"] = stop.Survivors.Select(p => $"{p.Name} (PID {p.Id})").ToList()
+            }
+        });
+        return tree;
+    }
+
+    private static (bool Success, string Output) ClassifyProcessResult(
+        int exitCode,
+        StringBuilder output,
+        IReadOnlyCollection<int>? successExitCodes,
+        IReadOnlyCollection<int>? rebootExitCodes)
+    {
+        var (succeeded, restartRequired) = ClassifyExitCode(exitCode, successExitCodes, rebootExitCodes);
+        if (succeeded)
+        {
+            if (exitCode != 0 && !restartRequired)
+            {
+                output.AppendLine($"Note: exit code {exitCode} is listed in success_exit_codes");
+            }
+            if (restartRequired)
+            {
+                output.AppendLine("Note: A reboot is required to complete the installation");
+                output.AppendLine($"{RestartRequiredTag}={exitCode}");
+            }
+            return (true, output.ToString());
+        }
+
+        return (false, $"Exit code: {exitCode}\n{output}");
+    }
+
     /// <summary>
     /// Verifies that an installation actually succeeded by checking the installs array.
     /// For MSI items, verifies the product is registered in Windows Installer via ProductCode/UpgradeCode.
@@ -1885,7 +2558,7 @@ exit 0
         {
             // No installs array - skip verification for backward compatibility
             var installerType = item.Installer.Type?.ToLowerInvariant() ?? "";
-            if (installerType is "nopkg" or "script" or "")
+            if (installerType is "nopkg" or "script" or "configuration" or "")
             {
                 ConsoleLogger.Debug($"No installs array for script-only/nopkg item {item.Name} - expected");
                 return (true, "");
@@ -1894,7 +2567,7 @@ exit 0
             return (true, "");
         }
 
-        foreach (var install in item.Installs)
+        foreach (var install in UserContextRunner.ResolveInstalls(item))
         {
             switch (install.EffectiveType())
             {
@@ -2001,6 +2674,9 @@ exit 0
     /// </summary>
     private static string? FindMsiVersionByProductCode(string productCode)
     {
+        if (MsiProducts.FindByProductCode(productCode) is { Version: { Length: > 0 } } product)
+            return product.Version;
+
         foreach (var view in new[] { RegistryView.Registry64, RegistryView.Registry32 })
         {
             try
@@ -2064,41 +2740,17 @@ exit 0
     }
 
     /// <summary>
-    /// Finds installed product via UpgradeCode using DTF's native Windows Installer API.
-    /// Replaces the previous packed GUID + registry walking approach.
+    /// Finds the newest installed product via UpgradeCode using Windows Installer
+    /// (MsiEnumRelatedProducts), falling back to the registry DisplayVersion when
+    /// the product registered no ProductVersion.
     /// </summary>
     private static (bool installed, string? version) FindMsiByUpgradeCodeStatic(string upgradeCode)
     {
-        if (string.IsNullOrEmpty(upgradeCode))
+        var product = MsiProducts.FindByUpgradeCode(upgradeCode);
+        if (product == null)
             return (false, null);
 
-        try
-        {
-            bool anyProductFound = false;
-            foreach (var installation in ProductInstallation.GetRelatedProducts(upgradeCode))
-            {
-                anyProductFound = true;
-                try
-                {
-                    var version = installation.ProductVersion?.ToString();
-                    if (!string.IsNullOrEmpty(version))
-                        return (true, version);
-
-                    // Fallback to registry DisplayVersion
-                    var regVersion = FindMsiVersionByProductCode(installation.ProductCode);
-                    if (!string.IsNullOrEmpty(regVersion))
-                        return (true, regVersion);
-                }
-                catch { /* continue to next product */ }
-            }
-
-            // Products exist but none had a readable version
-            if (anyProductFound)
-                return (true, null);
-        }
-        catch { /* failed to enumerate related products */ }
-
-        return (false, null);
+        return (true, !string.IsNullOrEmpty(product.Version) ? product.Version : FindMsiVersionByProductCode(product.ProductCode));
     }
 
     private void RegisterInstallation(CatalogItem item)
@@ -2136,6 +2788,13 @@ exit 0
                 if (msixInstall != null && !string.IsNullOrEmpty(msixInstall.IdentityName))
                     key?.SetValue("IdentityName", msixInstall.IdentityName);
             }
+
+            // Native .nupkg installs: where the package was extracted, for its uninstall script
+            if (!string.IsNullOrEmpty(_lastNupkgPackageFolder))
+            {
+                key?.SetValue("InstallerType", "nupkg");
+                key?.SetValue("PackageFolder", _lastNupkgPackageFolder);
+            }
         }
         catch (Exception ex)
         {
@@ -2213,7 +2872,7 @@ exit 0
     /// </summary>
     public bool NeedsUpdate(ManifestItem manifestItem, Dictionary<string, CatalogItem> catalogMap)
     {
-        var key = manifestItem.Name.ToLowerInvariant();
+        var key = ItemKey.Canonical(manifestItem.Name);
         
         if (!catalogMap.TryGetValue(key, out var catalogItem))
         {
@@ -2247,7 +2906,10 @@ exit 0
     }
 
     /// <summary>
-    /// Verifies the signature of a .pkg package.
+    /// Checks a .pkg against the signature block in its own build-info.yaml:
+    /// certificate dates, the payload hash, and that a signed hash is present.
+    /// Nothing is checked cryptographically against a certificate, so this shows
+    /// the payload matches its build-info, not who signed it; the details say so.
     /// Matches Go: extract.VerifyPkgSignature()
     /// </summary>
     private (bool Valid, string Details) VerifyPkgSignature(string packagePath, PkgBuildInfo buildInfo)
@@ -2282,7 +2944,7 @@ exit 0
             issues.Add("hash mismatch - package may have been tampered with");
         }
 
-        // 4. Verify signature (simplified - checks hash, not full crypto verification)
+        // 4. A signed hash must be present; it isn't verified against the certificate
         var signatureValid = !string.IsNullOrEmpty(signature.SignedHash);
         if (!signatureValid)
         {
@@ -2293,7 +2955,7 @@ exit 0
 
         if (valid)
         {
-            return (true, $"Signature valid, signed by {signature.Certificate?.Subject ?? "unknown"}");
+            return (true, $"payload hash matches; signer '{signature.Certificate?.Subject ?? "unknown"}' is self-declared, not verified");
         }
         else
         {
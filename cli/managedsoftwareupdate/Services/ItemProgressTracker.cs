@@ -0,0 +1,55 @@
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// One itemProgress update: how far a download is, in percent and (when the
+/// catalog knows the installer size) bytes, and how long it has left.
+/// </summary>
+public sealed record ItemProgressUpdate(int Percent, long? Bytes, long? TotalBytes, int? EtaSeconds);
+
+/// <summary>
+/// Turns the download callbacks (percent only, from several worker threads)
+/// into itemProgress updates for the GUI: one per whole percent per item, with
+/// bytes derived from installer.size and an ETA from the rate since the item's
+/// first sample - so a resumed download isn't credited with bytes it already had.
+/// </summary>
+public class ItemProgressTracker
+{
+    private readonly object _lock = new();
+    private readonly Dictionary<string, (DateTime Started, double StartPercent, int Reported)> _items =
+        new(StringComparer.OrdinalIgnoreCase);
+
+    /// <summary>
+    /// Records a sample and returns the update to send, or null when the item
+    /// hasn't moved a whole percent since the last one.
+    /// </summary>
+    public ItemProgressUpdate? Update(string itemName, double percent, long? totalBytes, DateTime now)
+    {
+        percent = Math.Clamp(percent, 0, 100);
+        var whole = (int)percent;
+
+        lock (_lock)
+        {
+            if (!_items.TryGetValue(itemName, out var state))
+            {
+                _items[itemName] = (now, percent, whole);
+                return null;
+            }
+            if (whole <= state.Reported)
+            {
+                return null;
+            }
+            _items[itemName] = state with { Reported = whole };
+
+            int? eta = null;
+            var progressed = percent - state.StartPercent;
+            var elapsed = (now - state.Started).TotalSeconds;
+            if (progressed > 0 && elapsed > 0)
+            {
+                eta = (int)Math.Ceiling(elapsed * (100 - percent) / progressed);
+            }
+
+            long? bytes = totalBytes is > 0 ? (long)(totalBytes.Value * percent / 100) : null;
+            return new ItemProgressUpdate(whole, bytes, totalBytes is > 0 ? totalBytes : null, eta);
+        }
+    }
+}
@@ -0,0 +1,192 @@
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// One row of --list-items.
+/// </summary>
+public class ItemSummary
+{
+    public string Name { get; set; } = string.Empty;
+    public string Version { get; set; } = string.Empty;
+    public string? DisplayName { get; set; }
+    public string? Catalog { get; set; }
+    public string Type { get; set; } = string.Empty;
+}
+
+/// <summary>
+/// A node of an item's requires tree. Missing means no visible catalog has it;
+/// Cycle means it already appears further up this branch.
+/// </summary>
+public class ItemDependency
+{
+    public string Name { get; set; } = string.Empty;
+    public string? Version { get; set; }
+    /// <summary>The requires entry as written, when it pins a version (name-1.2 or name--1.2).</summary>
+    public string? RequiredVersion { get; set; }
+    public string? Catalog { get; set; }
+    public bool Missing { get; set; }
+    public bool Cycle { get; set; }
+    public List<ItemDependency> Requires { get; set; } = new();
+}
+
+/// <summary>
+/// --item-info output: the resolved pkginfo, where it came from, what it
+/// depends on and how its install state is checked.
+/// </summary>
+public class ItemInfo
+{
+    public string Name { get; set; } = string.Empty;
+    /// <summary>The name asked for, when it was an alias of <see cref="Name"/>.</summary>
+    public string? RequestedName { get; set; }
+    public string Version { get; set; } = string.Empty;
+    public string? Catalog { get; set; }
+    public List<ItemDependency> Requires { get; set; } = new();
+    /// <summary>Items this one is an update for (its update_for).</summary>
+    public List<string> UpdateFor { get; set; } = new();
+    /// <summary>Visible items whose update_for names this one; they install along with it.</summary>
+    public List<string> Updates { get; set; } = new();
+    public InstallCheckStrategy InstallCheck { get; set; } = new(string.Empty, string.Empty, []);
+    public CatalogItem Pkginfo { get; set; } = new();
+}
+
+/// <summary>
+/// Read-only queries behind --list-items and --item-info. Loads manifests and
+/// catalogs the way a run does (manifests can add catalogs, an active
+/// --override-catalogs applies) so the answer matches what this machine would
+/// see, but never installs, runs scripts or spends an override run.
+/// </summary>
+public class ItemQueryService
+{
+    private static readonly JsonSerializerOptions JsonOptions = new()
+    {
+        WriteIndented = true,
+        PropertyNamingPolicy = JsonNamingPolicy.SnakeCaseLower,
+        DefaultIgnoreCondition = JsonIgnoreCondition.WhenWritingNull
+    };
+
+    private readonly CimianConfig _config;
+    private readonly ManifestService _manifestService;
+    private readonly CatalogService _catalogService;
+
+    public ItemQueryService(CimianConfig config, ManifestService? manifestService = null, CatalogService? catalogService = null)
+    {
+        _config = config;
+        _manifestService = manifestService ?? new ManifestService(config);
+        // Queries never act on the catalog, so they don't move its replay baseline
+        _catalogService = catalogService ?? new CatalogService(config) { RecordGenerations = false };
+    }
+
+    /// <summary>
+    /// Every catalog item visible to this machine, keyed like the run's catalog map.
+    /// </summary>
+    public async Task<Dictionary<string, CatalogItem>> LoadVisibleItemsAsync()
+    {
+        var catalogOverride = new CatalogOverrideService().Get();
+        if (catalogOverride != null)
+        {
+            _config.Catalogs = new List<string>(catalogOverride.Catalogs);
+        }
+
+        // Manifests first: their catalogs: lists add to the ones searched
+        await _manifestService.GetManifestItemsAsync();
+        return await _catalogService.LoadCatalogsAsync();
+    }
+
+    /// <summary>
+    /// The --list-items rows for <paramref name="items"/>, sorted by name.
+    /// </summary>
+    public static List<ItemSummary> ListItems(IReadOnlyDictionary<string, CatalogItem> items) =>
+        items.Values
+            .OrderBy(i => i.Name, StringComparer.OrdinalIgnoreCase)
+            .Select(i => new ItemSummary
+            {
+                Name = i.Name,
+                Version = i.Version,
+                DisplayName = i.DisplayName,
+                Catalog = i.SourceCatalog,
+                Type = (i.Installer?.Type ?? string.Empty).Trim().ToLowerInvariant() is { Length: > 0 } type ? type : "nopkg"
+            })
+            .ToList();
+
+    /// <summary>
+    /// Describes <paramref name="name"/> (or the item it is an alias of), or null
+    /// when no visible catalog has it.
+    /// </summary>
+    public ItemInfo? Describe(IReadOnlyDictionary<string, CatalogItem> items, string name)
+    {
+        var resolved = _catalogService.ResolveAlias(name);
+        if (!items.TryGetValue(ItemKey.Canonical(resolved), out var item))
+        {
+            return null;
+        }
+
+        return new ItemInfo
+        {
+            Name = item.Name,
+            RequestedName = ItemKey.Comparer.Equals(name, item.Name) ? null : name,
+            Version = item.Version,
+            Catalog = item.SourceCatalog,
+            Requires = BuildDependencyTree(items, item, new HashSet<string>(ItemKey.Comparer) { item.Name }),
+            UpdateFor = item.UpdateFor.ToList(),
+            Updates = items.Values
+                .Where(i => i.UpdateFor.Any(u => ItemKey.Comparer.Equals(u, item.Name)
+                                                 || ItemKey.Comparer.Equals(CatalogService.SplitNameAndVersion(u).name, item.Name)))
+                .Select(i => i.Name)
+                .OrderBy(n => n, StringComparer.OrdinalIgnoreCase)
+                .ToList(),
+            InstallCheck = StatusService.DescribeCheckStrategy(item),
+            Pkginfo = item
+        };
+    }
+
+    /// <summary>
+    /// <paramref name="item"/>'s requires, recursively. <paramref name="branch"/>
+    /// holds the names above this point so a cycle is reported instead of followed.
+    /// </summary>
+    internal static List<ItemDependency> BuildDependencyTree(
+        IReadOnlyDictionary<string, CatalogItem> items, CatalogItem item, HashSet<string> branch)
+    {
+        var nodes = new List<ItemDependency>();
+        foreach (var entry in item.Requires.Where(r => !string.IsNullOrWhiteSpace(r)))
+        {
+            // A plain name that ends in a digit (7zip-x64) is still a name when it is in the catalog
+            var (depName, depVersion) = items.ContainsKey(ItemKey.Canonical(entry))
+                ? (entry, string.Empty)
+                : CatalogService.SplitNameAndVersion(entry);
+            var node = new ItemDependency
+            {
+                Name = depName,
+                RequiredVersion = string.IsNullOrEmpty(depVersion) ? null : depVersion
+            };
+
+            if (!items.TryGetValue(ItemKey.Canonical(depName), out var dep))
+            {
+                node.Missing = true;
+            }
+            else
+            {
+                node.Name = dep.Name;
+                node.Version = dep.Version;
+                node.Catalog = dep.SourceCatalog;
+                if (!branch.Add(dep.Name))
+                {
+                    node.Cycle = true;
+                }
+                else
+                {
+                    node.Requires = BuildDependencyTree(items, dep, branch);
+                    branch.Remove(dep.Name);
+                }
+            }
+            nodes.Add(node);
+        }
+        return nodes;
+    }
+
+    /// <summary>Serializes --list-items or --item-info output as snake_case JSON.</summary>
+    public static string ToJson<T>(T value) => JsonSerializer.Serialize(value, JsonOptions);
+}
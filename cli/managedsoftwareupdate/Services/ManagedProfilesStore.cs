@@ -0,0 +1,114 @@
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>A setting a profile has taken over, with what was there before it.</summary>
+public class AppliedProfilePayload
+{
+    [JsonPropertyName("id")]
+    public string Id { get; set; } = "";
+
+    [JsonPropertyName("payload")]
+    public ProfilePayload Payload { get; set; } = new();
+
+    /// <summary>The value before the profile first set it; null when it was unset.</summary>
+    [JsonPropertyName("original")]
+    public string? Original { get; set; }
+}
+
+public class ManagedProfileRecord
+{
+    [JsonPropertyName("name")]
+    public string Name { get; set; } = "";
+
+    [JsonPropertyName("version")]
+    public string Version { get; set; } = "";
+
+    [JsonPropertyName("applied_at")]
+    public DateTime? AppliedAt { get; set; }
+
+    [JsonPropertyName("evaluated_at")]
+    public DateTime? EvaluatedAt { get; set; }
+
+    [JsonPropertyName("compliant")]
+    public bool Compliant { get; set; }
+
+    /// <summary>Why the last pass couldn't make the profile compliant.</summary>
+    [JsonPropertyName("errors")]
+    public List<string> Errors { get; set; } = new();
+
+    [JsonPropertyName("payloads")]
+    public List<AppliedProfilePayload> Payloads { get; set; } = new();
+}
+
+/// <summary>
+/// Every profile Cimian has applied, kept in <see cref="CimianPaths.ManagedProfilesJson"/>
+/// with each setting's previous value, so a profile dropped from the manifests
+/// (or a setting dropped from a profile) can be put back even after its file
+/// has left the repo. Doubles as the per-profile compliance report.
+/// </summary>
+public class ManagedProfilesStore
+{
+    private static readonly JsonSerializerOptions JsonOptions = new()
+    {
+        WriteIndented = true,
+        PropertyNamingPolicy = JsonNamingPolicy.SnakeCaseLower,
+        DefaultIgnoreCondition = JsonIgnoreCondition.WhenWritingNull
+    };
+
+    private readonly string _path;
+    private Dictionary<string, ManagedProfileRecord>? _records;
+
+    public ManagedProfilesStore(string? path = null)
+    {
+        _path = path ?? CimianPaths.ManagedProfilesJson;
+    }
+
+    public ManagedProfileRecord? Get(string name) =>
+        Records.TryGetValue(ItemKey.Canonical(name), out var record) ? record : null;
+
+    public List<ManagedProfileRecord> All() =>
+        Records.Values.OrderBy(r => r.Name, StringComparer.OrdinalIgnoreCase).ToList();
+
+    public void Set(ManagedProfileRecord record) => Records[ItemKey.Canonical(record.Name)] = record;
+
+    public void Remove(string name) => Records.Remove(ItemKey.Canonical(name));
+
+    public void Save()
+    {
+        try
+        {
+            JsonStateFile.Write(_path, All(), JsonOptions);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not save managed profiles state: {ex.Message}");
+        }
+    }
+
+    private Dictionary<string, ManagedProfileRecord> Records => _records ??= Load();
+
+    private Dictionary<string, ManagedProfileRecord> Load()
+    {
+        var records = new Dictionary<string, ManagedProfileRecord>();
+        try
+        {
+            if (File.Exists(_path))
+            {
+                foreach (var record in JsonSerializer.Deserialize<List<ManagedProfileRecord>>(File.ReadAllText(_path), JsonOptions) ?? new())
+                {
+                    records[ItemKey.Canonical(record.Name)] = record;
+                }
+            }
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException or JsonException)
+        {
+            ConsoleLogger.Warn($"Could not read managed profiles state: {ex.Message}");
+        }
+        return records;
+    }
+}
@@ -6,6 +6,7 @@ using YamlDotNet.Serialization.NamingConventions;
 using Cimian.CLI.managedsoftwareupdate.Models;
 using Cimian.Core;
 using Cimian.Core.Services;
+using Cimian.Core.Version;
 using Cimian.Engine.Predicates;
 using Cimian.Infrastructure.System;
 using SystemFacts = Cimian.Core.Models.SystemFacts;
@@ -26,22 +27,85 @@ namespace Cimian.CLI.managedsoftwareupdate.Services;
 public class ManifestService
 {
     private readonly HttpClient _httpClient;
+    private readonly MetadataVerifier _metadataVerifier;
     private readonly IDeserializer _deserializer;
     private readonly CimianConfig _config;
+    private readonly HttpValidatorStore _validators;
     private readonly Dictionary<string, string> _itemSources = new();
     private readonly PredicateEngine _predicateEngine;
     private readonly List<string> _featuredItems = new();
+    private readonly List<(RepoScript Script, string Manifest)> _preflightScripts = new();
+    private readonly List<(RepoScript Script, string Manifest)> _postflightScripts = new();
+    private readonly List<string> _offlineManifests = new();
+    private readonly Dictionary<string, List<string>> _includes = new(StringComparer.OrdinalIgnoreCase);
+    private SessionLogger? _sessionLogger;
 
     /// <summary>
     /// Featured items collected across all processed manifests
     /// </summary>
     public IReadOnlyList<string> FeaturedItems => _featuredItems;
+
+    /// <summary>
+    /// preflight_scripts collected across all processed manifests, with the
+    /// manifest that named each. A script named twice runs once.
+    /// </summary>
+    public IReadOnlyList<(RepoScript Script, string Manifest)> PreflightScripts => _preflightScripts;
+
+    /// <summary>
+    /// postflight_scripts collected across all processed manifests.
+    /// </summary>
+    public IReadOnlyList<(RepoScript Script, string Manifest)> PostflightScripts => _postflightScripts;
+
+    /// <summary>
+    /// Manifests read from the local copy because the repo couldn't be reached
+    /// (OfflineCacheMaxAgeHours). Non-empty means this run is degraded.
+    /// </summary>
+    public IReadOnlyList<string> OfflineManifests => _offlineManifests;
+
+    /// <summary>
+    /// The primary manifest the last <see cref="GetManifestItemsAsync"/> resolved
+    /// to, after ClientIdentifier templating and fallbacks, or the manifest
+    /// <see cref="LoadSpecificManifestAsync"/> loaded. Null when none did.
+    /// </summary>
+    public string? ResolvedManifest { get; private set; }
+
+    /// <summary>
+    /// The include tree under <see cref="ResolvedManifest"/> as this run loaded it:
+    /// each node's children are the included_manifests it named that loaded, in
+    /// order. A manifest included from several places appears once, where it was
+    /// first processed. Null when no primary manifest loaded.
+    /// </summary>
+    public ManifestHierarchyNode? GetManifestHierarchy()
+    {
+        return ResolvedManifest == null
+            ? null
+            : BuildHierarchyNode(ResolvedManifest, new HashSet<string>(StringComparer.OrdinalIgnoreCase));
+    }
+
+    private ManifestHierarchyNode BuildHierarchyNode(string name, HashSet<string> visited)
+    {
+        visited.Add(name);
+        var children = new List<ManifestHierarchyNode>();
+        if (_includes.TryGetValue(name, out var includes))
+        {
+            foreach (var include in includes)
+            {
+                if (!visited.Contains(include))
+                {
+                    children.Add(BuildHierarchyNode(include, visited));
+                }
+            }
+        }
+        return new ManifestHierarchyNode(name, children);
+    }
     private SystemFacts? _systemFacts;
 
     public ManifestService(CimianConfig config, HttpClient? httpClient = null)
     {
         _config = config;
-        _httpClient = httpClient ?? CimianHttpClientFactory.CreateHttpClient(config);
+        _httpClient = httpClient ?? CimianHttpClientFactory.CreateHttpClient(config, acceptCompressed: true);
+        _validators = new HttpValidatorStore(config.ManifestsPath);
+        _metadataVerifier = new MetadataVerifier(config, _httpClient);
         _deserializer = new DeserializerBuilder()
             .WithNamingConvention(UnderscoredNamingConvention.Instance)
             .IgnoreUnmatchedProperties()
@@ -49,6 +113,14 @@ public class ManifestService
         _predicateEngine = new PredicateEngine(new Microsoft.Extensions.Logging.Abstractions.NullLogger<PredicateEngine>());
     }
 
+    /// <summary>
+    /// Sets the session logger for structured event logging
+    /// </summary>
+    public void SetSessionLogger(SessionLogger? logger)
+    {
+        _sessionLogger = logger;
+    }
+
     /// <summary>
     /// Retrieves all manifest items from server
     /// Uses two-pass approach: first collect catalogs, then process conditional items
@@ -58,9 +130,10 @@ public class ManifestService
         var items = new List<ManifestItem>();
         var manifestResults = new Dictionary<string, ManifestFetchResult>(StringComparer.OrdinalIgnoreCase);
         var pendingConditionals = new List<(List<ConditionalItem> Items, string SourceManifest)>();
+        _includes.Clear();
 
         // PASS 1: Resolve and process the primary manifest, walking a 404 fallback
-        // chain (configured identifier -> hostname -> serial -> Orphaned ->
+        // chain (configured identifier -> serial -> hostname -> Orphaned ->
         // site_default), collecting catalogs and deferring conditional items.
         await ResolvePrimaryManifestAsync(items, manifestResults, pendingConditionals);
 
@@ -93,9 +166,12 @@ public class ManifestService
         var manifestResults = new Dictionary<string, ManifestFetchResult>(StringComparer.OrdinalIgnoreCase);
         var pendingConditionals = new List<(List<ConditionalItem> Items, string SourceManifest)>();
 
+        _includes.Clear();
+
         // Explicitly-requested manifest: a 404 should stay visible (quiet404: false),
         // unchanged from the pre-fallback-chain behavior.
-        await ProcessManifestAsync(manifestName, items, manifestResults, pendingConditionals, quiet404: false);
+        var result = await ProcessManifestAsync(manifestName, items, manifestResults, pendingConditionals, quiet404: false);
+        ResolvedManifest = result == ManifestFetchResult.Ok ? manifestName : null;
         
         // Process deferred conditional items
         foreach (var (conditionalItems, sourceManifest) in pendingConditionals)
@@ -134,11 +210,12 @@ public class ManifestService
     /// <summary>
     /// Resolves the primary manifest by walking an ordered candidate chain and
     /// processing the first one the server returns:
-    ///   configured identifier (cert CN &gt; ClientIdentifier &gt; hostname)
-    ///   -&gt; serial number -&gt; Orphaned -&gt; site_default
+    ///   configured identifier (cert CN &gt; ClientIdentifier, templates expanded)
+    ///   -&gt; serial number -&gt; hostname -&gt; Orphaned -&gt; site_default
     /// Only an HTTP 404 advances to the next candidate. A non-404 failure
     /// (auth, 5xx, network) aborts resolution immediately rather than degrading
-    /// to a catch-all, so genuine server problems stay visible.
+    /// to a catch-all, so genuine server problems stay visible. Every candidate
+    /// and its outcome goes into a manifest_resolved session event.
     /// </summary>
     private async Task ResolvePrimaryManifestAsync(
         List<ManifestItem> items,
@@ -156,25 +233,27 @@ public class ManifestService
         // number, which queries WMI) only runs once the chain actually reaches it.
         // When the configured identifier resolves on the first try, or the chain
         // aborts on a non-404, the WMI query is never issued.
-        var candidates = new List<(Func<string?> Resolve, string Kind)>
+        var candidates = new List<(Func<string?> Resolve, string Kind, string Source)>
         {
             // Configured identity: certificate CN takes precedence over the explicit
             // ClientIdentifier, preserving the prior single-identifier selection.
-            (() => CimianHttpClientFactory.GetClientCertificateCN(_config), Configured),
-            (() => _config.ClientIdentifier, Configured),
-            // Opportunistic probes.
-            (() => Environment.MachineName, Probe),
-            (GetSerialNumber, Probe),
+            (() => CimianHttpClientFactory.GetClientCertificateCN(_config), Configured, "certificate"),
+            (ExpandClientIdentifier, Configured, "ClientIdentifier"),
+            // Opportunistic probes, like Munki's implicit manifest names.
+            (GetSerialNumber, Probe, "serial"),
+            (() => Environment.MachineName, Probe, "hostname"),
             // Catch-all manifests of last resort.
-            (() => "Orphaned", CatchAll),
-            (() => "site_default", CatchAll),
+            (() => "Orphaned", CatchAll, "catch-all"),
+            (() => "site_default", CatchAll, "catch-all"),
         };
 
         var tried = new HashSet<string>(StringComparer.OrdinalIgnoreCase);
         var triedNames = new List<string>();
+        var attempts = new List<string>();
         var resolvedAny = false;
+        ResolvedManifest = null;
 
-        foreach (var (resolve, kind) in candidates)
+        foreach (var (resolve, kind, source) in candidates)
         {
             var name = resolve()?.Trim();
             // Skip blanks and de-duplicate candidates that resolve to the same name.
@@ -183,14 +262,21 @@ public class ManifestService
             triedNames.Add(name);
 
             var result = await ProcessManifestAsync(name, items, manifestResults, pendingConditionals, quiet404: true);
+            attempts.Add($"{name} ({source}): {result}");
 
             if (result == ManifestFetchResult.Ok)
             {
+                ResolvedManifest = name;
                 if (resolvedAny)
                 {
                     ConsoleLogger.Warn($"    Primary manifest resolved via fallback '{name}' ({kind}); " +
                         "device is running on a fallback/catch-all configuration.");
                 }
+                else
+                {
+                    ConsoleLogger.Info($"    Primary manifest: {name} ({source})");
+                }
+                LogManifestResolution(name, source, resolvedAny, attempts);
                 return;
             }
 
@@ -199,6 +285,7 @@ public class ManifestService
                 // Non-404 failure already logged in ProcessManifestAsync. Abort the
                 // chain so a transient server error is not mistaken for "no manifest".
                 ConsoleLogger.Error($"    Aborting primary manifest resolution at '{name}' due to a non-404 error; not falling through to catch-all.");
+                LogManifestResolution(null, null, resolvedAny, attempts);
                 return;
             }
 
@@ -211,13 +298,87 @@ public class ManifestService
         }
 
         ConsoleLogger.Warn($"    No primary manifest could be resolved from candidates: [{string.Join(", ", triedNames)}]. Device will have no managed items this run.");
+        LogManifestResolution(null, null, resolvedAny, attempts);
+    }
+
+    /// <summary>
+    /// ClientIdentifier with any %token% expanded (see <see cref="ClientIdentifierTemplate"/>).
+    /// Null when a token has no value on this machine, so the chain moves on.
+    /// </summary>
+    private string? ExpandClientIdentifier()
+    {
+        var template = _config.ClientIdentifier;
+        if (!ClientIdentifierTemplate.IsTemplate(template))
+        {
+            return template;
+        }
+
+        var expanded = ClientIdentifierTemplate.Expand(template, ResolveIdentifierToken, out var missingToken);
+        if (expanded == null)
+        {
+            ConsoleLogger.Warn($"    ClientIdentifier '{template}': no value for %{missingToken}% on this machine; skipping it");
+            return null;
+        }
+        ConsoleLogger.Info($"    ClientIdentifier '{template}' expanded to '{expanded}'");
+        return expanded;
+    }
+
+    /// <summary>
+    /// Value of a ClientIdentifier %token%. The built-in tokens avoid a full facts
+    /// collection; anything else is a conditional-items fact.
+    /// </summary>
+    private string? ResolveIdentifierToken(string token)
+    {
+        switch (token)
+        {
+            case "serial":
+            case "serial_number":
+                return GetSerialNumber();
+            case "hostname":
+                return Environment.MachineName;
+            case "ou":
+                return ClientIdentifierTemplate.GetComputerOu();
+            case "site":
+                return ClientIdentifierTemplate.GetComputerSite();
+        }
+
+        return GetSystemFacts().GetFactValue(token) switch
+        {
+            null => null,
+            string s => s,
+            bool b => b ? "true" : "false",
+            System.Collections.IEnumerable => null, // lists (catalogs, gpu_names) don't name one manifest
+            var value => Convert.ToString(value, System.Globalization.CultureInfo.InvariantCulture)
+        };
+    }
+
+    private void LogManifestResolution(string? manifest, string? source, bool fallback, List<string> attempts)
+    {
+        _sessionLogger?.LogEvent(new LogEvent
+        {
+            Level = manifest == null ? "ERROR" : fallback ? "WARN" : "INFO",
+            EventType = "manifest_resolved",
+            Action = "resolve_manifest",
+            Status = manifest == null ? "failed" : fallback ? "fallback" : "resolved",
+            Message = manifest == null
+                ? "No primary manifest could be resolved"
+                : $"Primary manifest {manifest} ({source}){(fallback ? " after fallback" : "")}",
+            Context = new Dictionary<string, object>
+            {
+                ["client_identifier"] = _config.ClientIdentifier,
+                ["manifest"] = manifest ?? "",
+                ["source"] = source ?? "",
+                ["fallback"] = fallback,
+                ["candidates"] = attempts
+            }
+        });
     }
 
     /// <summary>
     /// Reads the hardware serial number from the system BIOS for use as a
     /// manifest fallback identifier. Returns null if it cannot be determined.
     /// </summary>
-    private static string? GetSerialNumber()
+    internal static string? GetSerialNumber()
     {
         try
         {
@@ -266,88 +427,52 @@ public class ManifestService
         try
         {
             ConsoleLogger.Debug($"Starting download url: {manifestUrl} destination: {localPath}");
-            var response = await _httpClient.GetAsync(manifestUrl);
-            if (response.IsSuccessStatusCode)
+            using var request = new HttpRequestMessage(HttpMethod.Get, manifestUrl);
+            _validators.ApplyTo(request, localPath);
+            using var response = await _httpClient.SendAsync(request);
+            var notModified = response.StatusCode == HttpStatusCode.NotModified && File.Exists(localPath);
+            if (response.IsSuccessStatusCode || notModified)
             {
-                var content = await response.Content.ReadAsStringAsync();
-                ConsoleLogger.Debug($"Download completed to temp file tempFile: {localPath}.downloading size: {content.Length}");
-                
-                // Save locally
-                var dir = Path.GetDirectoryName(localPath);
-                if (!string.IsNullOrEmpty(dir))
+                string content;
+                if (notModified)
                 {
-                    Directory.CreateDirectory(dir);
-                }
-                await File.WriteAllTextAsync(localPath, content);
-                ConsoleLogger.Debug($"File saved successfully file: {localPath} size: {content.Length}");
-                ConsoleLogger.Debug($"Download completed successfully file: {localPath}");
-                ConsoleLogger.Debug($"Successfully downloaded manifest url: {manifestUrl}");
-                ConsoleLogger.Debug($"Processed manifest: {Path.GetFileNameWithoutExtension(manifestName)}");
-
-                var manifest = _deserializer.Deserialize<ManifestFile>(content);
-                if (manifest != null)
-                {
-                    // Add catalogs to config FIRST (before processing anything else)
-                    if (manifest.Catalogs != null && manifest.Catalogs.Count > 0)
+                    // Unchanged since the last run: the local copy is current
+                    content = await File.ReadAllTextAsync(localPath);
+                    if (!await _metadataVerifier.VerifyUnchangedAsync("manifest", manifestName, manifestUrl, content, localPath))
                     {
-                        ConsoleLogger.Debug($"Processing catalogs for manifest manifest: {Path.GetFileNameWithoutExtension(manifestName)} catalogs: [{string.Join(", ", manifest.Catalogs)}]");
-                        foreach (var catalog in manifest.Catalogs)
-                        {
-                            if (!_config.Catalogs.Contains(catalog))
-                            {
-                                ConsoleLogger.Debug($"Added catalog to collection catalog: {catalog}");
-                                _config.Catalogs.Add(catalog);
-                            }
-                        }
-                    }
-                    else
-                    {
-                        ConsoleLogger.Debug($"Processing catalogs for manifest manifest: {Path.GetFileNameWithoutExtension(manifestName)} catalogs: []");
-                    }
-
-                    // Process included manifests
-                    if (manifest.IncludedManifests != null)
-                    {
-                        ConsoleLogger.Debug($"Processing included manifests from {manifestName} count: {manifest.IncludedManifests.Count}");
-                        foreach (var include in manifest.IncludedManifests)
-                        {
-                            // Clean up the include path - normalize slashes and remove .yaml extension
-                            var includeName = include.Replace(".yaml", "").Replace("\\", "/");
-                            ConsoleLogger.Debug($"Processing included manifest: {includeName}");
-                            
-                            // Include paths are relative or absolute manifest references
-                            // They should be passed as-is to ProcessManifestAsync. A 404 on
-                            // an include stays visible (quiet404: false) — only the primary
-                            // fallback chain probes quietly.
-                            await ProcessManifestAsync(includeName, items, manifestResults, pendingConditionals);
-                        }
+                        manifestResults[manifestName] = ManifestFetchResult.Error;
+                        return ManifestFetchResult.Error;
                     }
+                    OfflineCache.Touch(localPath);
+                    ConsoleLogger.Debug($"Manifest unchanged (304), using local copy: {localPath}");
+                }
+                else
+                {
+                    content = await response.Content.ReadAsStringAsync();
+                    ConsoleLogger.Debug($"Download completed to temp file tempFile: {localPath}.downloading size: {content.Length}");
 
-                    // Collect featured_items from this manifest
-                    if (manifest.FeaturedItems != null && manifest.FeaturedItems.Count > 0)
+                    // Refused content never replaces the local copy
+                    if (!await _metadataVerifier.VerifyDownloadedAsync("manifest", manifestName, manifestUrl, content, localPath))
                     {
-                        foreach (var fi in manifest.FeaturedItems)
-                        {
-                            if (!_featuredItems.Contains(fi, StringComparer.OrdinalIgnoreCase))
-                                _featuredItems.Add(fi);
-                        }
-                        ConsoleLogger.Debug($"Collected {manifest.FeaturedItems.Count} featured items from {manifestName}");
+                        manifestResults[manifestName] = ManifestFetchResult.Error;
+                        return ManifestFetchResult.Error;
                     }
 
-                    // Convert to manifest items (excluding conditional items - they're deferred)
-                    var manifestItems = ConvertToManifestItems(manifest, manifestName);
-                    ConsoleLogger.Debug($"Processed manifest: {manifestName} itemCount: {manifestItems.Count}");
-                    items.AddRange(manifestItems);
-                    
-                    // DEFER conditional items processing until all manifests are loaded
-                    // This ensures catalogs are fully populated before conditional evaluation
-                    if (manifest.ConditionalItems != null && manifest.ConditionalItems.Count > 0)
+                    // Save locally
+                    var dir = Path.GetDirectoryName(localPath);
+                    if (!string.IsNullOrEmpty(dir))
                     {
-                        ConsoleLogger.Info($"    Deferring {manifest.ConditionalItems.Count} conditional items from {manifestName}");
-                        pendingConditionals.Add((manifest.ConditionalItems, manifestName));
+                        Directory.CreateDirectory(dir);
                     }
+                    await File.WriteAllTextAsync(localPath, content);
+                    _validators.Record(manifestUrl, response);
+                    ConsoleLogger.Debug($"File saved successfully file: {localPath} size: {content.Length}");
+                    ConsoleLogger.Debug($"Download completed successfully file: {localPath}");
+                    ConsoleLogger.Debug($"Successfully downloaded manifest url: {manifestUrl}");
                 }
+                ConsoleLogger.Debug($"Processed manifest: {Path.GetFileNameWithoutExtension(manifestName)}");
 
+                await ApplyManifestAsync(manifestName, content, items, manifestResults, pendingConditionals);
                 manifestResults[manifestName] = ManifestFetchResult.Ok;
                 return ManifestFetchResult.Ok;
             }
@@ -367,15 +492,153 @@ public class ManifestService
 
             // Non-404 (auth, 5xx, etc.): surface rather than treating it as missing.
             ConsoleLogger.Warn($"Failed to download manifest {manifestName}: {response.StatusCode}");
-            manifestResults[manifestName] = ManifestFetchResult.Error;
-            return ManifestFetchResult.Error;
         }
         catch (Exception ex)
         {
-            // Network/transport failure: surface, do not mask by falling back.
+            // Network/transport failure: surface, do not mask by falling back to a
+            // catch-all manifest.
             ConsoleLogger.Warn($"Error processing manifest {manifestName}: {ex.Message}");
-            manifestResults[manifestName] = ManifestFetchResult.Error;
-            return ManifestFetchResult.Error;
+        }
+
+        // Offline mode: this machine's own last copy is not a catch-all, so it may
+        // stand in while it is recent enough.
+        if (await TryApplyOfflineCopyAsync(manifestName, localPath, items, manifestResults, pendingConditionals))
+        {
+            manifestResults[manifestName] = ManifestFetchResult.Ok;
+            return ManifestFetchResult.Ok;
+        }
+        manifestResults[manifestName] = ManifestFetchResult.Error;
+        return ManifestFetchResult.Error;
+    }
+
+    /// <summary>
+    /// Processes the local copy of a manifest the repo failed to serve, when
+    /// OfflineCacheMaxAgeHours allows it. Includes resolve the same way, so each
+    /// falls back to its own local copy.
+    /// </summary>
+    private async Task<bool> TryApplyOfflineCopyAsync(
+        string manifestName,
+        string localPath,
+        List<ManifestItem> items,
+        Dictionary<string, ManifestFetchResult> manifestResults,
+        List<(List<ConditionalItem> Items, string SourceManifest)> pendingConditionals)
+    {
+        if (_config.OfflineCacheMaxAgeHours <= 0)
+        {
+            return false;
+        }
+        if (!OfflineCache.IsUsable(localPath, _config.OfflineCacheMaxAgeHours, DateTime.UtcNow, out var age))
+        {
+            ConsoleLogger.Warn(age is { } stale
+                ? $"    Cached manifest {manifestName} is {OfflineCache.DescribeAge(stale)} old, past OfflineCacheMaxAgeHours ({_config.OfflineCacheMaxAgeHours}h); not using it"
+                : $"    No cached copy of manifest {manifestName} to fall back to");
+            return false;
+        }
+
+        try
+        {
+            var content = await File.ReadAllTextAsync(localPath);
+            if (!_metadataVerifier.VerifyLocal("manifest", manifestName, content, localPath))
+            {
+                return false;
+            }
+            ConsoleLogger.Warn($"    Using cached manifest {manifestName} ({OfflineCache.DescribeAge(age!.Value)} old): repo unreachable");
+            _offlineManifests.Add(manifestName);
+            await ApplyManifestAsync(manifestName, content, items, manifestResults, pendingConditionals);
+            return true;
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Warn($"    Could not read cached manifest {manifestName}: {ex.Message}");
+            return false;
+        }
+    }
+
+    /// <summary>
+    /// Adds a fetched manifest's catalogs, includes, featured items and items to
+    /// this run, deferring its conditional items until every manifest is loaded.
+    /// </summary>
+    private async Task ApplyManifestAsync(
+        string manifestName,
+        string content,
+        List<ManifestItem> items,
+        Dictionary<string, ManifestFetchResult> manifestResults,
+        List<(List<ConditionalItem> Items, string SourceManifest)> pendingConditionals)
+    {
+        var manifest = _deserializer.Deserialize<ManifestFile>(content);
+        if (manifest != null)
+        {
+            // Add catalogs to config FIRST (before processing anything else)
+            if (manifest.Catalogs != null && manifest.Catalogs.Count > 0)
+            {
+                ConsoleLogger.Debug($"Processing catalogs for manifest manifest: {Path.GetFileNameWithoutExtension(manifestName)} catalogs: [{string.Join(", ", manifest.Catalogs)}]");
+                foreach (var catalog in manifest.Catalogs)
+                {
+                    if (!_config.Catalogs.Contains(catalog))
+                    {
+                        ConsoleLogger.Debug($"Added catalog to collection catalog: {catalog}");
+                        _config.Catalogs.Add(catalog);
+                    }
+                }
+            }
+            else
+            {
+                ConsoleLogger.Debug($"Processing catalogs for manifest manifest: {Path.GetFileNameWithoutExtension(manifestName)} catalogs: []");
+            }
+
+            // Process included manifests
+            if (manifest.IncludedManifests != null)
+            {
+                ConsoleLogger.Debug($"Processing included manifests from {manifestName} count: {manifest.IncludedManifests.Count}");
+                foreach (var include in manifest.IncludedManifests)
+                {
+                    // Clean up the include path - normalize slashes and remove .yaml extension
+                    var includeName = include.Replace(".yaml", "").Replace("\\", "/");
+                    ConsoleLogger.Debug($"Processing included manifest: {includeName}");
+                    
+                    // Include paths are relative or absolute manifest references
+                    // They should be passed as-is to ProcessManifestAsync. A 404 on
+                    // an include stays visible (quiet404: false) — only the primary
+                    // fallback chain probes quietly.
+                    var includeResult = await ProcessManifestAsync(includeName, items, manifestResults, pendingConditionals);
+                    if (includeResult == ManifestFetchResult.Ok)
+                    {
+                        if (!_includes.TryGetValue(manifestName, out var includes))
+                        {
+                            _includes[manifestName] = includes = new List<string>();
+                        }
+                        includes.Add(includeName);
+                    }
+                }
+            }
+
+            // Collect featured_items from this manifest
+            if (manifest.FeaturedItems != null && manifest.FeaturedItems.Count > 0)
+            {
+                foreach (var fi in manifest.FeaturedItems)
+                {
+                    if (!_featuredItems.Contains(fi, StringComparer.OrdinalIgnoreCase))
+                        _featuredItems.Add(fi);
+                }
+                ConsoleLogger.Debug($"Collected {manifest.FeaturedItems.Count} featured items from {manifestName}");
+            }
+
+            // Collect preflight_scripts / postflight_scripts from this manifest
+            CollectScripts(manifest.PreflightScripts, _preflightScripts, manifestName);
+            CollectScripts(manifest.PostflightScripts, _postflightScripts, manifestName);
+
+            // Convert to manifest items (excluding conditional items - they're deferred)
+            var manifestItems = ConvertToManifestItems(manifest, manifestName);
+            ConsoleLogger.Debug($"Processed manifest: {manifestName} itemCount: {manifestItems.Count}");
+            items.AddRange(manifestItems);
+            
+            // DEFER conditional items processing until all manifests are loaded
+            // This ensures catalogs are fully populated before conditional evaluation
+            if (manifest.ConditionalItems != null && manifest.ConditionalItems.Count > 0)
+            {
+                ConsoleLogger.Info($"    Deferring {manifest.ConditionalItems.Count} conditional items from {manifestName}");
+                pendingConditionals.Add((manifest.ConditionalItems, manifestName));
+            }
         }
     }
 
@@ -457,6 +720,12 @@ public class ManifestService
                         SetItemSource(name, sourceManifest, "conditional_optional_installs");
                     }
                 }
+
+                // Nested conditional_items only count when their parent matched (Munki parity)
+                if (conditional.ConditionalItems != null && conditional.ConditionalItems.Count > 0)
+                {
+                    items.AddRange(ProcessConditionalItems(conditional.ConditionalItems, sourceManifest));
+                }
             }
             else
             {
@@ -472,7 +741,30 @@ public class ManifestService
     /// </summary>
     private static readonly string ConditionsDir = CimianPaths.ConditionsDir;
 
-    private void EnsureSystemFacts()
+    /// <summary>
+    /// Evaluates conditions against facts the caller already has instead of
+    /// collecting them (and running condition scripts) on first use.
+    /// </summary>
+    internal void UseSystemFacts(SystemFacts facts)
+    {
+        _systemFacts = facts;
+    }
+
+    /// <summary>
+    /// The facts conditions are evaluated against, collected on first use.
+    /// <paramref name="refresh"/> collects them again instead of using the session's.
+    /// </summary>
+    public SystemFacts GetSystemFacts(bool refresh = false)
+    {
+        if (refresh)
+        {
+            _systemFacts = null;
+        }
+        EnsureSystemFacts(refresh);
+        return _systemFacts!;
+    }
+
+    private void EnsureSystemFacts(bool refresh = false)
     {
         if (_systemFacts != null) return;
 
@@ -484,7 +776,7 @@ public class ManifestService
         {
             var collector = new SystemFactsCollector(new ConsoleForwardingLogger<SystemFactsCollector>());
             collector.SetCatalogs(_config.Catalogs);
-            _systemFacts = collector.CollectAsync().GetAwaiter().GetResult();
+            _systemFacts = collector.GetSessionFactsAsync(refresh).GetAwaiter().GetResult();
             ConsoleLogger.Info($"    SystemFacts: machine_model='{_systemFacts.MachineModel}' machine_type='{_systemFacts.MachineType}' gpu_names=[{string.Join(", ", _systemFacts.GpuNames)}] arch='{_systemFacts.Architecture}'");
         }
         catch (Exception ex)
@@ -762,7 +1054,8 @@ public class ManifestService
                 {
                     Name = name,
                     Action = "uninstall",
-                    SourceManifest = selfServeSource
+                    SourceManifest = selfServeSource,
+                    IsSelfServe = true
                 });
                 SetItemSource(name, selfServeSource, "managed_uninstalls");
                 ConsoleLogger.Debug($"SelfServe: added uninstall request item: {name}");
@@ -778,6 +1071,7 @@ public class ManifestService
                 if (optional != null)
                 {
                     optional.Action = "uninstall";
+                    optional.IsSelfServe = true;
                     optional.PromotedFromOptional = true;
                     SetItemSource(name, selfServeSource, "managed_uninstalls");
                     ConsoleLogger.Debug($"SelfServe: flipped optional to uninstall item: {name} originalSource: {optional.SourceManifest}");
@@ -905,14 +1199,14 @@ public class ManifestService
 
     public void SetItemSource(string itemName, string sourceManifest, string sourceType)
     {
-        var key = itemName.ToLowerInvariant();
+        var key = ItemKey.Canonical(itemName);
         _itemSources[key] = $"{sourceManifest}:{sourceType}";
         ConsoleLogger.Debug($"Setting item source item: {itemName} sourceManifest: {sourceManifest} sourceType: {sourceType}");
     }
 
     public (string SourceManifest, string SourceType) GetItemSource(string itemName)
     {
-        var key = itemName.ToLowerInvariant();
+        var key = ItemKey.Canonical(itemName);
         if (_itemSources.TryGetValue(key, out var source))
         {
             var parts = source.Split(':');
@@ -926,6 +1220,18 @@ public class ManifestService
         _itemSources.Clear();
     }
 
+    private static void CollectScripts(List<RepoScript>? scripts, List<(RepoScript Script, string Manifest)> collected, string manifestName)
+    {
+        foreach (var script in scripts ?? [])
+        {
+            if (!string.IsNullOrWhiteSpace(script.Name)
+                && !collected.Any(c => c.Script.Name.Equals(script.Name, StringComparison.OrdinalIgnoreCase)))
+            {
+                collected.Add((script, manifestName));
+            }
+        }
+    }
+
     /// <summary>
     /// Deduplicates manifest items by name. When the same name appears with
     /// different actions across the manifest tree, the strongest action wins
@@ -949,7 +1255,7 @@ public class ManifestService
             if (string.IsNullOrEmpty(item.Name))
                 continue;
 
-            var key = item.Name.ToLowerInvariant();
+            var key = ItemKey.Canonical(item.Name);
 
             if (dedup.TryGetValue(key, out var existing))
             {
@@ -1004,27 +1310,16 @@ public class ManifestService
     /// Compare versions to determine if v1 is older than v2.
     /// Go parity: pkg/status.IsOlderVersion
     /// </summary>
-    private static bool IsOlderVersion(string? v1, string? v2)
-    {
-        if (string.IsNullOrEmpty(v1)) return true;
-        if (string.IsNullOrEmpty(v2)) return false;
-        
-        // Try to parse as Version objects first
-        if (Version.TryParse(v1.Replace("-", "."), out var ver1) && 
-            Version.TryParse(v2.Replace("-", "."), out var ver2))
-        {
-            return ver1 < ver2;
-        }
-
-        // Fall back to string comparison
-        return string.Compare(v1, v2, StringComparison.OrdinalIgnoreCase) < 0;
-    }
+    private static bool IsOlderVersion(string? v1, string? v2) => VersionService.IsOlderVersion(v1, v2);
 }
 
 /// <summary>
 /// Forwards Microsoft.Extensions.Logging calls to ConsoleLogger so warnings and errors
 /// from Cimian.Infrastructure services are visible in managedsoftwareupdate output.
 /// </summary>
+/// <summary>A manifest and the manifests it included, for the hierarchy display.</summary>
+public sealed record ManifestHierarchyNode(string Name, IReadOnlyList<ManifestHierarchyNode> Children);
+
 internal sealed class ConsoleForwardingLogger<T> : Microsoft.Extensions.Logging.ILogger<T>
 {
     public IDisposable BeginScope<TState>(TState state) where TState : notnull => NullScope.Instance;
@@ -0,0 +1,155 @@
+using System.Net;
+using System.Security.Cryptography;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Checks manifests and catalogs against the keys pinned in MetadataSigningKeys
+/// before they are used (see <see cref="MetadataSignature"/>). A bad signature is
+/// always refused; unsigned metadata only with RequireSignedMetadata. Detached
+/// signatures are kept next to the local copy so 304s and offline runs are
+/// checked the same way.
+/// </summary>
+public class MetadataVerifier
+{
+    private readonly CimianConfig _config;
+    private readonly HttpClient _httpClient;
+    private readonly List<AsymmetricAlgorithm> _keys = new();
+
+    public MetadataVerifier(CimianConfig config, HttpClient httpClient)
+    {
+        _config = config;
+        _httpClient = httpClient;
+
+        foreach (var key in config.MetadataSigningKeys.Where(k => !string.IsNullOrWhiteSpace(k)))
+        {
+            try
+            {
+                _keys.Add(MetadataSignature.LoadKey(key.Trim()));
+            }
+            catch (Exception ex) when (ex is CryptographicException or IOException or UnauthorizedAccessException)
+            {
+                ConsoleLogger.Error($"Ignoring MetadataSigningKeys entry: {ex.Message}");
+            }
+        }
+    }
+
+    /// <summary>True when there is anything to check.</summary>
+    public bool Enabled => _keys.Count > 0 || _config.RequireSignedMetadata;
+
+    /// <summary>
+    /// Checks content just downloaded from <paramref name="url"/>, fetching
+    /// &lt;url&gt;.sig when it has no embedded signature. Call before the content
+    /// replaces the local copy at <paramref name="localPath"/>.
+    /// </summary>
+    public async Task<bool> VerifyDownloadedAsync(string kind, string name, string url, string content, string localPath)
+    {
+        if (!Enabled)
+        {
+            return true;
+        }
+
+        var signature = MetadataSignature.ExtractEmbedded(content, out _) == null
+            ? await FetchDetachedAsync(url)
+            : null;
+        var status = MetadataSignature.Verify(content, signature, _keys);
+
+        var sigPath = localPath + MetadataSignature.DetachedExtension;
+        try
+        {
+            if (status == MetadataSignatureStatus.Valid && signature != null)
+            {
+                Directory.CreateDirectory(Path.GetDirectoryName(sigPath)!);
+                await File.WriteAllTextAsync(sigPath, signature);
+            }
+            else if (status == MetadataSignatureStatus.Unsigned && File.Exists(sigPath))
+            {
+                // The old signature belongs to the old copy
+                File.Delete(sigPath);
+            }
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Debug($"Could not update {sigPath}: {ex.Message}");
+        }
+
+        return Accept(kind, name, status);
+    }
+
+    /// <summary>
+    /// Checks a local copy the server said is unchanged (304), against the
+    /// signature kept with it or, if there is none yet, the repo's.
+    /// </summary>
+    public Task<bool> VerifyUnchangedAsync(string kind, string name, string url, string content, string localPath) =>
+        File.Exists(localPath + MetadataSignature.DetachedExtension)
+            ? Task.FromResult(VerifyLocal(kind, name, content, localPath))
+            : VerifyDownloadedAsync(kind, name, url, content, localPath);
+
+    /// <summary>
+    /// Checks a local copy used without the repo (offline fallback) against the
+    /// signature kept with it.
+    /// </summary>
+    public bool VerifyLocal(string kind, string name, string content, string localPath)
+    {
+        if (!Enabled)
+        {
+            return true;
+        }
+
+        var sigPath = localPath + MetadataSignature.DetachedExtension;
+        string? signature = null;
+        try
+        {
+            signature = File.Exists(sigPath) ? File.ReadAllText(sigPath) : null;
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Debug($"Could not read {sigPath}: {ex.Message}");
+        }
+        return Accept(kind, name, MetadataSignature.Verify(content, signature, _keys));
+    }
+
+    private async Task<string?> FetchDetachedAsync(string url)
+    {
+        try
+        {
+            using var response = await _httpClient.GetAsync(url + MetadataSignature.DetachedExtension);
+            if (response.IsSuccessStatusCode)
+            {
+                return await response.Content.ReadAsStringAsync();
+            }
+            if (response.StatusCode != HttpStatusCode.NotFound)
+            {
+                ConsoleLogger.Warn($"Failed to download signature {url}{MetadataSignature.DetachedExtension}: {response.StatusCode}");
+            }
+        }
+        catch (Exception ex) when (ex is HttpRequestException or TaskCanceledException)
+        {
+            ConsoleLogger.Warn($"Error downloading signature {url}{MetadataSignature.DetachedExtension}: {ex.Message}");
+        }
+        return null;
+    }
+
+    private bool Accept(string kind, string name, MetadataSignatureStatus status)
+    {
+        switch (status)
+        {
+            case MetadataSignatureStatus.Valid:
+                ConsoleLogger.Debug($"Signature verified for {kind} {name}");
+                return true;
+            case MetadataSignatureStatus.Invalid:
+                ConsoleLogger.Error($"Refusing {kind} {name}: its signature does not match any key in MetadataSigningKeys");
+                return false;
+            default:
+                if (_config.RequireSignedMetadata)
+                {
+                    ConsoleLogger.Error($"Refusing unsigned {kind} {name} (RequireSignedMetadata is on)");
+                    return false;
+                }
+                ConsoleLogger.Warn($"{kind} {name} is not signed; using it because RequireSignedMetadata is off");
+                return true;
+        }
+    }
+}
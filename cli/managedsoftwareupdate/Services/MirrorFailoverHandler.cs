@@ -0,0 +1,107 @@
+using System.Net;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Sends each repo request to the mirrors of a <see cref="RepoMirrorSet"/> in
+/// turn until one answers without a server error. Network failures, timeouts,
+/// 5xx, 408 and 429 move on to the next mirror; any other answer, including
+/// 404 and 304, is returned as is. Requests outside the repo pass straight through.
+/// </summary>
+internal sealed class MirrorFailoverHandler : DelegatingHandler
+{
+    private static readonly TimeSpan HealthCheckTimeout = TimeSpan.FromSeconds(10);
+
+    private readonly RepoMirrorSet _mirrors;
+
+    public MirrorFailoverHandler(RepoMirrorSet mirrors)
+    {
+        _mirrors = mirrors;
+    }
+
+    protected override async Task<HttpResponseMessage> SendAsync(HttpRequestMessage request, CancellationToken cancellationToken)
+    {
+        var relative = request.RequestUri == null ? null : _mirrors.RelativePath(request.RequestUri);
+        if (relative == null || request.Content != null)
+        {
+            return await base.SendAsync(request, cancellationToken).ConfigureAwait(false);
+        }
+        if (!_mirrors.HasFailover)
+        {
+            var single = await base.SendAsync(request, cancellationToken).ConfigureAwait(false);
+            _mirrors.RecordServed(relative, _mirrors.Mirrors[0]);
+            return single;
+        }
+
+        await _mirrors.EnsureHealthCheckedAsync(ProbeAsync).ConfigureAwait(false);
+
+        HttpResponseMessage? lastResponse = null;
+        Exception? lastError = null;
+        foreach (var mirror in _mirrors.Order)
+        {
+            try
+            {
+                var response = await base.SendAsync(Clone(request, RepoMirrorSet.Rebase(relative, mirror)), cancellationToken).ConfigureAwait(false);
+                if (!IsMirrorFailure(response.StatusCode))
+                {
+                    lastResponse?.Dispose();
+                    _mirrors.RecordServed(relative, mirror);
+                    return response;
+                }
+                lastResponse?.Dispose();
+                lastResponse = response;
+                _mirrors.Demote(mirror, $"{(int)response.StatusCode} {response.StatusCode} for {relative}");
+            }
+            catch (HttpRequestException ex)
+            {
+                lastError = ex;
+                _mirrors.Demote(mirror, $"{ex.Message} for {relative}");
+            }
+            catch (TaskCanceledException ex) when (!cancellationToken.IsCancellationRequested)
+            {
+                lastError = ex;
+                _mirrors.Demote(mirror, $"timed out for {relative}");
+            }
+        }
+
+        // Every mirror failed: hand back the last server answer so callers see a
+        // status code, or rethrow the last network error
+        if (lastResponse != null)
+        {
+            return lastResponse;
+        }
+        throw new HttpRequestException($"All {_mirrors.Mirrors.Count} repo mirrors failed for {relative}: {lastError?.Message}", lastError);
+    }
+
+    internal static bool IsMirrorFailure(HttpStatusCode status) =>
+        (int)status >= 500 || status is HttpStatusCode.RequestTimeout or HttpStatusCode.TooManyRequests;
+
+    /// <summary>A mirror is healthy when it answers at all without a server error.</summary>
+    private async Task<bool> ProbeAsync(string mirror)
+    {
+        using var cts = new CancellationTokenSource(HealthCheckTimeout);
+        try
+        {
+            using var response = await base.SendAsync(new HttpRequestMessage(HttpMethod.Head, mirror + "/"), cts.Token).ConfigureAwait(false);
+            return !IsMirrorFailure(response.StatusCode);
+        }
+        catch (Exception ex) when (ex is HttpRequestException or TaskCanceledException)
+        {
+            return false;
+        }
+    }
+
+    private static HttpRequestMessage Clone(HttpRequestMessage request, Uri uri)
+    {
+        var clone = new HttpRequestMessage(request.Method, uri)
+        {
+            Version = request.Version,
+            VersionPolicy = request.VersionPolicy
+        };
+        foreach (var header in request.Headers)
+        {
+            clone.Headers.TryAddWithoutValidation(header.Key, header.Value);
+        }
+        return clone;
+    }
+}
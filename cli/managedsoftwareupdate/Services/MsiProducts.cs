@@ -0,0 +1,106 @@
+using Cimian.Core.Services;
+using WixToolset.Dtf.WindowsInstaller;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>An installed Windows Installer product and the ProductVersion it registered.</summary>
+public sealed record MsiProduct(string ProductCode, string? Version, string? Name);
+
+/// <summary>
+/// Asks Windows Installer itself which products are installed -
+/// MsiGetProductInfo for a ProductCode, MsiEnumRelatedProducts for an
+/// UpgradeCode - instead of walking the Uninstall keys, which products can hide
+/// (ARPSYSTEMCOMPONENT), misreport or lose while still being registered.
+/// Works for per-machine products in either architecture; a null result means
+/// Windows Installer has no such product (or couldn't be asked).
+/// </summary>
+public static class MsiProducts
+{
+    /// <summary>The installed product with this ProductCode, or null.</summary>
+    public static MsiProduct? FindByProductCode(string? productCode)
+    {
+        var code = ArpRegistry.NormalizeProductCode(productCode);
+        if (code == null)
+        {
+            return null;
+        }
+
+        try
+        {
+            var installation = new ProductInstallation(code);
+            return installation.IsInstalled ? Describe(installation) : null;
+        }
+        catch (Exception ex) when (ex is InstallerException or ArgumentException or DllNotFoundException or EntryPointNotFoundException)
+        {
+            ConsoleLogger.Debug($"Windows Installer lookup failed productCode: {code} error: {ex.Message}");
+            return null;
+        }
+    }
+
+    /// <summary>
+    /// The newest installed product sharing this UpgradeCode, or null. Several
+    /// can be installed at once (side-by-side versions, a failed major upgrade);
+    /// the newest is the one an update decision should be made against.
+    /// </summary>
+    public static MsiProduct? FindByUpgradeCode(string? upgradeCode)
+    {
+        var code = ArpRegistry.NormalizeProductCode(upgradeCode);
+        if (code == null)
+        {
+            return null;
+        }
+
+        try
+        {
+            var products = new List<MsiProduct>();
+            foreach (var installation in ProductInstallation.GetRelatedProducts(code))
+            {
+                try
+                {
+                    if (installation.IsInstalled)
+                    {
+                        products.Add(Describe(installation));
+                    }
+                }
+                catch (InstallerException ex)
+                {
+                    ConsoleLogger.Debug($"Skipping related product productCode: {installation.ProductCode} error: {ex.Message}");
+                }
+            }
+            return Newest(products);
+        }
+        catch (Exception ex) when (ex is InstallerException or ArgumentException or DllNotFoundException or EntryPointNotFoundException)
+        {
+            ConsoleLogger.Debug($"Windows Installer lookup failed upgradeCode: {code} error: {ex.Message}");
+            return null;
+        }
+    }
+
+    /// <summary>The product with the highest version; products without one only win when nothing has a version.</summary>
+    internal static MsiProduct? Newest(IEnumerable<MsiProduct> products)
+    {
+        MsiProduct? newest = null;
+        foreach (var product in products)
+        {
+            if (newest == null
+                || (string.IsNullOrEmpty(newest.Version) && !string.IsNullOrEmpty(product.Version))
+                || (!string.IsNullOrEmpty(product.Version) && !string.IsNullOrEmpty(newest.Version)
+                    && CatalogService.CompareVersions(product.Version, newest.Version) > 0))
+            {
+                newest = product;
+            }
+        }
+        return newest;
+    }
+
+    // VersionString is ProductVersion exactly as the package declared it;
+    // DTF's ProductVersion would re-parse it into a System.Version
+    private static MsiProduct Describe(ProductInstallation installation)
+    {
+        var version = installation["VersionString"];
+        return new MsiProduct(
+            installation.ProductCode,
+            string.IsNullOrEmpty(version) ? installation.ProductVersion?.ToString() : version,
+            installation.ProductName);
+    }
+}
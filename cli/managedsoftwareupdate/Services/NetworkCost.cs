@@ -0,0 +1,114 @@
+using System.Net.NetworkInformation;
+using System.Runtime.InteropServices;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>What the machine's connection costs, as far as downloads are concerned.</summary>
+public enum NetworkCostKind
+{
+    Unmetered,
+    /// <summary>Windows flags the connection as metered (data plan, roaming, over its limit).</summary>
+    Metered,
+    /// <summary>Traffic leaves over a mobile broadband adapter, whatever Windows' cost says.</summary>
+    Cellular
+}
+
+/// <summary>
+/// Asks the Network List Manager (netlistmgr.h) what the machine's current
+/// connection costs: the same flag Windows Update uses to hold downloads on
+/// a metered Wi-Fi, tethered phone or cellular connection. Mobile broadband
+/// adapters carrying the default route count too, since an admin may not have
+/// marked the SIM's plan as metered.
+/// </summary>
+public static class NetworkCost
+{
+    private static readonly Guid NetworkListManagerClsid = new("DCB00C01-570F-4A9B-8D69-199FDBA5723B");
+
+    // NLM_CONNECTION_COST
+    internal const uint Unrestricted = 0x1;
+    internal const uint Fixed = 0x2;
+    internal const uint Variable = 0x4;
+    internal const uint OverDataLimit = 0x10000;
+    internal const uint Roaming = 0x40000;
+
+    /// <summary>
+    /// The connection's cost. Unknown (no Network List Manager, COM failure)
+    /// counts as unmetered so downloads aren't held on machines that can't say.
+    /// </summary>
+    public static NetworkCostKind Detect()
+    {
+        if (IsCellularRoute(GetRoutedInterfaceTypes()))
+        {
+            return NetworkCostKind.Cellular;
+        }
+        return GetCost() is { } cost && IsMeteredCost(cost) ? NetworkCostKind.Metered : NetworkCostKind.Unmetered;
+    }
+
+    /// <summary>True when Windows reports the connection as metered or it runs over cellular.</summary>
+    public static bool IsMetered() => Detect() != NetworkCostKind.Unmetered;
+
+    /// <summary>Fixed and variable data plans, over-limit and roaming connections are metered.</summary>
+    internal static bool IsMeteredCost(uint cost) =>
+        (cost & (Fixed | Variable | OverDataLimit | Roaming)) != 0;
+
+    /// <summary>
+    /// Only mobile broadband adapters have a default gateway: with Ethernet or
+    /// Wi-Fi also up, Windows routes over those and the modem sits idle.
+    /// </summary>
+    internal static bool IsCellularRoute(IReadOnlyCollection<NetworkInterfaceType> routedTypes) =>
+        routedTypes.Count > 0 && routedTypes.All(t => t is NetworkInterfaceType.Wwanpp or NetworkInterfaceType.Wwanpp2);
+
+    /// <summary>The NLM_CONNECTION_COST flags of the machine's connectivity, or null.</summary>
+    public static uint? GetCost()
+    {
+        if (!OperatingSystem.IsWindows())
+        {
+            return null;
+        }
+
+        object? manager = null;
+        try
+        {
+            var type = Type.GetTypeFromCLSID(NetworkListManagerClsid, throwOnError: true)!;
+            manager = Activator.CreateInstance(type)!;
+            ((INetworkCostManager)manager).GetCost(out var cost, IntPtr.Zero);
+            return cost;
+        }
+        catch (Exception ex) when (ex is COMException or InvalidCastException or TypeLoadException)
+        {
+            ConsoleLogger.Debug($"Could not read the network cost: {ex.Message}");
+            return null;
+        }
+        finally
+        {
+            if (manager != null) Marshal.ReleaseComObject(manager);
+        }
+    }
+
+    // Adapters that are up and have a default gateway, i.e. could carry a download
+    private static List<NetworkInterfaceType> GetRoutedInterfaceTypes()
+    {
+        try
+        {
+            return NetworkInterface.GetAllNetworkInterfaces()
+                .Where(n => n.OperationalStatus == OperationalStatus.Up
+                    && n.NetworkInterfaceType is not (NetworkInterfaceType.Loopback or NetworkInterfaceType.Tunnel)
+                    && n.GetIPProperties().GatewayAddresses.Count > 0)
+                .Select(n => n.NetworkInterfaceType)
+                .ToList();
+        }
+        catch (NetworkInformationException ex)
+        {
+            ConsoleLogger.Debug($"Could not list network adapters: {ex.Message}");
+            return new List<NetworkInterfaceType>();
+        }
+    }
+
+    [ComImport, Guid("DCB00008-570F-4A9B-8D69-199FDBA5723B"), InterfaceType(ComInterfaceType.InterfaceIsIUnknown)]
+    private interface INetworkCostManager
+    {
+        // A null destination asks for the machine-wide cost
+        void GetCost(out uint cost, IntPtr destinationAddress);
+    }
+}
@@ -0,0 +1,280 @@
+using System.IO.Compression;
+using System.Text.RegularExpressions;
+using System.Xml.Linq;
+using Cimian.Core;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>The id and version a .nupkg's .nuspec declares.</summary>
+public sealed record NuspecInfo(string Id, string Version);
+
+/// <summary>
+/// Installs .nupkg packages without Chocolatey, for clients that have neither
+/// sbin-installer nor choco.exe. The package is extracted to its own folder
+/// under <see cref="CimianPaths.NupkgLibDir"/> (lib\&lt;id&gt;, as Chocolatey
+/// does) and the scripts in tools\ run the way choco would run them:
+/// chocolateyBeforeModify.ps1 from the old version before an upgrade replaces
+/// it, then chocolateyBeforeInstall.ps1 and chocolateyInstall.ps1 from the new
+/// one, and chocolateyUninstall.ps1 on removal. Scripts that call Chocolatey's
+/// helper functions (Install-ChocolateyPackage and friends) can't run here and
+/// are refused before anything is replaced.
+/// </summary>
+public class NupkgService
+{
+    public const string BeforeModifyScript = "chocolateyBeforeModify.ps1";
+    public const string BeforeInstallScript = "chocolateyBeforeInstall.ps1";
+    public const string InstallScript = "chocolateyInstall.ps1";
+    public const string UninstallScript = "chocolateyUninstall.ps1";
+
+    // Functions only Chocolatey's own PowerShell host provides
+    private static readonly Regex ChocolateyHelper = new(
+        @"\b((Install|Uninstall|Get|Update)-Chocolatey\w+|Install-BinFile|Uninstall-BinFile|Get-PackageParameters|Get-ToolsLocation|Get-UninstallRegistryKey)\b",
+        RegexOptions.Compiled | RegexOptions.IgnoreCase);
+
+    // NuGet package ids; anything else could walk out of the lib folder
+    private static readonly Regex PackageId = new(@"^[A-Za-z0-9_][A-Za-z0-9._-]*$", RegexOptions.Compiled);
+
+    private readonly string _libRoot;
+    private readonly Func<string, IReadOnlyDictionary<string, string>, CancellationToken, Task<(bool Success, string Output)>> _runScript;
+    private readonly ScriptService _scriptService;
+
+    public NupkgService(
+        ScriptService? scriptService = null,
+        string? libRoot = null,
+        Func<string, IReadOnlyDictionary<string, string>, CancellationToken, Task<(bool Success, string Output)>>? runScript = null)
+    {
+        _scriptService = scriptService ?? new ScriptService();
+        _libRoot = libRoot ?? CimianPaths.NupkgLibDir;
+        _runScript = runScript ?? RunScriptAsync;
+    }
+
+    /// <summary>Where the package with this id is (or would be) extracted.</summary>
+    public string PackageFolder(string id) => Path.Combine(_libRoot, id);
+
+    /// <summary>The .nuspec's id and version; null when the file has no readable .nuspec.</summary>
+    public static NuspecInfo? ReadNuspec(string packagePath)
+    {
+        try
+        {
+            using var archive = ZipFile.OpenRead(packagePath);
+            var entry = archive.Entries.FirstOrDefault(e =>
+                !e.FullName.Contains('/') && e.Name.EndsWith(".nuspec", StringComparison.OrdinalIgnoreCase));
+            if (entry == null)
+            {
+                return null;
+            }
+
+            using var stream = entry.Open();
+            var metadata = XDocument.Load(stream).Root?.Elements().FirstOrDefault(e => e.Name.LocalName == "metadata");
+            var id = metadata?.Elements().FirstOrDefault(e => e.Name.LocalName == "id")?.Value.Trim();
+            var version = metadata?.Elements().FirstOrDefault(e => e.Name.LocalName == "version")?.Value.Trim();
+            return string.IsNullOrEmpty(id) ? null : new NuspecInfo(id, version ?? "");
+        }
+        catch (Exception ex) when (ex is IOException or InvalidDataException or System.Xml.XmlException)
+        {
+            ConsoleLogger.Debug($"Could not read .nuspec from {packagePath}: {ex.Message}");
+            return null;
+        }
+    }
+
+    /// <summary>
+    /// Extracts the package to lib\&lt;id&gt; and runs its install scripts. The
+    /// new version is staged next to the old one and only swapped in once it
+    /// has been checked, so a refused package leaves the installed one alone.
+    /// </summary>
+    public async Task<(bool Success, string Output, string? PackageFolder)> InstallAsync(
+        string packagePath,
+        CancellationToken cancellationToken = default)
+    {
+        var nuspec = ReadNuspec(packagePath);
+        if (nuspec == null)
+        {
+            return (false, $"{Path.GetFileName(packagePath)} has no .nuspec; it is not a NuGet package", null);
+        }
+        if (!PackageId.IsMatch(nuspec.Id))
+        {
+            return (false, $"'{nuspec.Id}' is not a valid package id", null);
+        }
+
+        var target = PackageFolder(nuspec.Id);
+        var staging = target + ".new";
+        var environment = BuildEnvironment(nuspec, target);
+        try
+        {
+            if (Directory.Exists(staging))
+            {
+                Directory.Delete(staging, recursive: true);
+            }
+            await Task.Run(() => Extract(packagePath, staging), cancellationToken);
+
+            var helper = FindChocolateyHelper(Path.Combine(staging, "tools"));
+            if (helper != null)
+            {
+                Directory.Delete(staging, recursive: true);
+                return (false, $"{nuspec.Id} calls the Chocolatey helper {helper}; install it with Chocolatey instead", null);
+            }
+
+            if (Directory.Exists(target))
+            {
+                var beforeModify = Path.Combine(target, "tools", BeforeModifyScript);
+                if (File.Exists(beforeModify))
+                {
+                    var (modifyOk, modifyOutput) = await _runScript(beforeModify, environment, cancellationToken);
+                    if (!modifyOk)
+                    {
+                        ConsoleLogger.Warn($"{BeforeModifyScript} for {nuspec.Id} failed: {modifyOutput.Trim()}");
+                    }
+                }
+                Directory.Delete(target, recursive: true);
+            }
+            Directory.Move(staging, target);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException or InvalidDataException)
+        {
+            return (false, $"Could not extract {Path.GetFileName(packagePath)}: {ex.Message}", null);
+        }
+
+        ConsoleLogger.Detail($"Extracted {nuspec.Id} {nuspec.Version} to {target}");
+
+        var output = "";
+        foreach (var script in new[] { BeforeInstallScript, InstallScript })
+        {
+            var scriptPath = Path.Combine(target, "tools", script);
+            if (!File.Exists(scriptPath))
+            {
+                continue;
+            }
+
+            ConsoleLogger.Info($"Running {script} for {nuspec.Id}...");
+            var (success, scriptOutput) = await _runScript(scriptPath, environment, cancellationToken);
+            output += scriptOutput;
+            if (!success)
+            {
+                return (false, $"{script} failed: {scriptOutput.Trim()}", target);
+            }
+        }
+
+        return (true, output, target);
+    }
+
+    /// <summary>
+    /// Runs chocolateyUninstall.ps1 from an extracted package, when it has one,
+    /// and removes the package folder. A folder that's already gone counts as removed.
+    /// </summary>
+    public async Task<(bool Success, string Output)> UninstallAsync(string packageFolder, CancellationToken cancellationToken = default)
+    {
+        if (!Directory.Exists(packageFolder))
+        {
+            return (true, $"{packageFolder} is already gone");
+        }
+
+        var output = "";
+        var uninstall = Path.Combine(packageFolder, "tools", UninstallScript);
+        if (File.Exists(uninstall))
+        {
+            var nuspec = new NuspecInfo(Path.GetFileName(packageFolder), "");
+            var (success, scriptOutput) = await _runScript(uninstall, BuildEnvironment(nuspec, packageFolder), cancellationToken);
+            if (!success)
+            {
+                return (false, $"{UninstallScript} failed: {scriptOutput.Trim()}");
+            }
+            output = scriptOutput;
+        }
+
+        try
+        {
+            Directory.Delete(packageFolder, recursive: true);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            return (false, $"Could not remove {packageFolder}: {ex.Message}");
+        }
+        return (true, output);
+    }
+
+    /// <summary>
+    /// Extracts the package's content, leaving out the OPC packaging parts
+    /// ([Content_Types].xml, _rels\, package\). Entry names are URL-decoded
+    /// the way NuGet writes them, and none may resolve outside the destination.
+    /// </summary>
+    internal static void Extract(string packagePath, string destination)
+    {
+        var root = Path.GetFullPath(destination);
+        if (!root.EndsWith(Path.DirectorySeparatorChar))
+        {
+            root += Path.DirectorySeparatorChar;
+        }
+        Directory.CreateDirectory(root);
+
+        using var archive = ZipFile.OpenRead(packagePath);
+        foreach (var entry in archive.Entries)
+        {
+            var name = Uri.UnescapeDataString(entry.FullName);
+            if (IsPackagingPart(name))
+            {
+                continue;
+            }
+
+            var path = Path.GetFullPath(Path.Combine(root, name.Replace('/', Path.DirectorySeparatorChar)));
+            if (!path.StartsWith(root, StringComparison.OrdinalIgnoreCase))
+            {
+                throw new InvalidDataException($"entry '{entry.FullName}' is outside the package");
+            }
+
+            if (name.EndsWith('/'))
+            {
+                Directory.CreateDirectory(path);
+                continue;
+            }
+            Directory.CreateDirectory(Path.GetDirectoryName(path)!);
+            entry.ExtractToFile(path, overwrite: true);
+        }
+    }
+
+    /// <summary>The first Chocolatey helper function a tools\*.ps1 calls, outside comments.</summary>
+    internal static string? FindChocolateyHelper(string toolsDir)
+    {
+        if (!Directory.Exists(toolsDir))
+        {
+            return null;
+        }
+
+        foreach (var script in Directory.EnumerateFiles(toolsDir, "*.ps1", SearchOption.AllDirectories))
+        {
+            foreach (var line in File.ReadLines(script))
+            {
+                var code = line.TrimStart();
+                if (code.StartsWith('#'))
+                {
+                    continue;
+                }
+                var match = ChocolateyHelper.Match(code);
+                if (match.Success)
+                {
+                    return match.Value;
+                }
+            }
+        }
+        return null;
+    }
+
+    /// <summary>The variables choco sets for package scripts that this installer can honour.</summary>
+    internal static Dictionary<string, string> BuildEnvironment(NuspecInfo nuspec, string packageFolder) => new()
+    {
+        ["ChocolateyPackageName"] = nuspec.Id,
+        ["ChocolateyPackageVersion"] = nuspec.Version,
+        ["ChocolateyPackageFolder"] = packageFolder
+    };
+
+    private static bool IsPackagingPart(string name) =>
+        name.Equals("[Content_Types].xml", StringComparison.OrdinalIgnoreCase)
+        || name.StartsWith("_rels/", StringComparison.OrdinalIgnoreCase)
+        || name.StartsWith("package/", StringComparison.OrdinalIgnoreCase);
+
+    private Task<(bool Success, string Output)> RunScriptAsync(
+        string scriptPath,
+        IReadOnlyDictionary<string, string> environment,
+        CancellationToken cancellationToken) =>
+        _scriptService.WithEnvironment(environment).ExecuteScriptFileAsync(scriptPath, cancellationToken);
+}
@@ -0,0 +1,48 @@
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Decides whether a manifest or catalog kept from an earlier run may stand in
+/// for the repo's copy when the repo can't be reached (OfflineCacheMaxAgeHours).
+/// A local copy's age is the time since the repo last served or confirmed it;
+/// ManifestService and CatalogService touch the file on 304 Not Modified.
+/// </summary>
+public static class OfflineCache
+{
+    /// <summary>
+    /// True when offline fallback is enabled and <paramref name="path"/> exists
+    /// and is no older than <paramref name="maxAgeHours"/>. <paramref name="age"/>
+    /// is the copy's age, or null when there is no copy.
+    /// </summary>
+    public static bool IsUsable(string path, int maxAgeHours, DateTime nowUtc, out TimeSpan? age)
+    {
+        age = null;
+        if (!File.Exists(path))
+        {
+            return false;
+        }
+
+        age = nowUtc - File.GetLastWriteTimeUtc(path);
+        return maxAgeHours > 0 && age <= TimeSpan.FromHours(maxAgeHours);
+    }
+
+    /// <summary>
+    /// Marks a local copy the repo just confirmed with 304 as current.
+    /// </summary>
+    public static void Touch(string path)
+    {
+        try
+        {
+            File.SetLastWriteTimeUtc(path, DateTime.UtcNow);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Debug($"Could not refresh timestamp of {path}: {ex.Message}");
+        }
+    }
+
+    /// <summary>"3h", "2d 4h" — for log lines about a cached copy's age.</summary>
+    public static string DescribeAge(TimeSpan age) =>
+        age.TotalDays >= 1 ? $"{(int)age.TotalDays}d {age.Hours}h" : $"{Math.Max(0, (int)age.TotalHours)}h";
+}
@@ -0,0 +1,59 @@
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Finds the items RemoveOrphanedDependencies removes: installed only as a
+/// dependency (see <see cref="InstalledItemRecord.InstalledAsDependency"/>),
+/// wanted by no manifest, and required by nothing that stays installed.
+/// </summary>
+public static class OrphanedDependencyFinder
+{
+    /// <param name="installed">Installed items, from <see cref="InstalledItemsStore.Installed"/>.</param>
+    /// <param name="wanted">Names the manifests still install, with their dependencies.</param>
+    /// <param name="removing">Names already queued for removal this run.</param>
+    /// <param name="catalog">Loaded catalog keyed by canonical name.</param>
+    /// <returns>Orphans in the order found; an orphan only its fellow orphans required comes after them.</returns>
+    public static List<CatalogItem> Find(
+        IEnumerable<InstalledItemRecord> installed,
+        ISet<string> wanted,
+        IEnumerable<string> removing,
+        Dictionary<string, CatalogItem> catalog)
+    {
+        var installedRecords = installed.Where(r => r.IsInstalled).ToList();
+        var gone = new HashSet<string>(removing, ItemKey.Comparer);
+        var remaining = installedRecords.Select(r => r.Name).Where(n => !gone.Contains(n)).ToList();
+
+        var candidates = installedRecords
+            .Where(r => r.InstalledAsDependency && !wanted.Contains(r.Name) && !gone.Contains(r.Name))
+            .Select(r => catalog.GetValueOrDefault(ItemKey.Canonical(r.Name)))
+            .Where(item => item != null && item.IsUninstallable())
+            .Select(item => item!)
+            .ToList();
+
+        // Removing one orphan can orphan what it required, so repeat until nothing changes
+        var orphans = new List<CatalogItem>();
+        bool found;
+        do
+        {
+            found = false;
+            foreach (var candidate in candidates.ToList())
+            {
+                var requiredBy = CatalogService.FindItemsRequiring(candidate.Name, catalog)
+                    .Where(dependent => !ItemKey.Comparer.Equals(dependent.Name, candidate.Name)
+                                        && CatalogService.IsItemInstalled(dependent.Name, remaining));
+                if (requiredBy.Any())
+                {
+                    continue;
+                }
+                orphans.Add(candidate);
+                candidates.Remove(candidate);
+                remaining.RemoveAll(n => ItemKey.Comparer.Equals(n, candidate.Name));
+                found = true;
+            }
+        } while (found);
+
+        return orphans;
+    }
+}
@@ -0,0 +1,175 @@
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core.Models;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Fetches installer payloads from nearby peers before the repo (PeerCache in
+/// Config.yaml), for branch offices on thin WAN links.
+///
+/// Mode deliveryoptimization hands the repo URL to Windows Delivery Optimization,
+/// which shares pieces with LAN peers. Mode peers asks each PeerCache.Peers
+/// server for the payload's repo path (pkgs/...) in turn. Only payloads with a
+/// catalog hash, at least MinimumSizeMB, are tried, and a payload that doesn't
+/// match the hash is discarded; the caller then downloads from the repo as usual.
+/// </summary>
+public sealed class PeerCacheService
+{
+    private const int BufferSize = 64 * 1024;
+
+    private readonly PeerCacheSettings _settings;
+    private readonly string _mode;
+    private readonly RepoMirrorSet _mirrors;
+    private readonly HttpClient? _peerClient;
+    private readonly DeliveryOptimizationClient? _deliveryOptimization;
+    private readonly string? _repoHeaders;
+
+    public PeerCacheService(CimianConfig config, HttpClient? peerClient = null)
+    {
+        _settings = config.PeerCache;
+        _mode = PeerCacheMode.Normalize(_settings.Mode) ?? PeerCacheMode.Off;
+        _mirrors = RepoMirrorSet.For(config);
+
+        if (_mode == PeerCacheMode.DeliveryOptimization)
+        {
+            // DO fetches the origin itself: it can send headers, not sign
+            // requests or present a client certificate
+            if ((RepoBackend.Normalize(config.RepoBackend) ?? RepoBackend.Http) != RepoBackend.Http || config.UseClientCertificate)
+            {
+                ConsoleLogger.Warn("PeerCache Mode deliveryoptimization needs an http repo without client certificates; downloading from the repo directly");
+                _mode = PeerCacheMode.Off;
+            }
+            else
+            {
+                _deliveryOptimization = new DeliveryOptimizationClient();
+                _repoHeaders = CimianHttpClientFactory.GetRepoAuthorization(config) is { } auth ? $"Authorization: {auth}\r\n" : null;
+            }
+        }
+        else if (_mode == PeerCacheMode.Peers)
+        {
+            _peerClient = peerClient ?? CreatePeerClient(config);
+        }
+    }
+
+    public bool IsEnabled => _mode != PeerCacheMode.Off;
+
+    /// <summary>
+    /// True when a payload of <paramref name="totalBytes"/> (-1 when unknown)
+    /// from <paramref name="url"/> should be looked for on peers first.
+    /// </summary>
+    public bool AppliesTo(string url, long totalBytes, string? expectedHash)
+    {
+        if (!IsEnabled || string.IsNullOrEmpty(expectedHash))
+        {
+            return false;
+        }
+        if (_settings.MinimumSizeMB > 0 && (totalBytes < 0 || totalBytes < _settings.MinimumSizeMB * 1024L * 1024L))
+        {
+            return false;
+        }
+        // Peer servers mirror the repo layout, so only repo URLs map onto them
+        return _mode != PeerCacheMode.Peers || RepoPath(url) != null;
+    }
+
+    /// <summary>
+    /// Fetches <paramref name="url"/> from peers to <paramref name="localPath"/>
+    /// and checks it against <paramref name="expectedHash"/>. Returns where it
+    /// came from ("deliveryoptimization", "peer http://cache01:8080"), or null
+    /// with nothing written when no peer could serve it.
+    /// </summary>
+    public async Task<string?> TryFetchAsync(string url, string localPath, string expectedHash, CancellationToken cancellationToken)
+    {
+        var peerPath = localPath + ".peer";
+        var fileName = Path.GetFileName(localPath);
+        foreach (var (source, fetch) in Sources(url))
+        {
+            try
+            {
+                await fetch(peerPath, cancellationToken);
+                var hash = DownloadService.CalculateSHA256(peerPath);
+                if (!hash.Equals(expectedHash, StringComparison.OrdinalIgnoreCase))
+                {
+                    ConsoleLogger.Warn($"{fileName} from {source} failed hash verification; discarding it");
+                    continue;
+                }
+
+                File.Move(peerPath, localPath, overwrite: true);
+                ConsoleLogger.Info($"Downloaded {fileName} via {source}");
+                return source;
+            }
+            catch (OperationCanceledException) when (cancellationToken.IsCancellationRequested)
+            {
+                throw;
+            }
+            catch (Exception ex)
+            {
+                ConsoleLogger.Detail($"    {source} could not serve {fileName}: {ex.Message}");
+            }
+            finally
+            {
+                try { if (File.Exists(peerPath)) File.Delete(peerPath); } catch { /* best effort */ }
+            }
+        }
+
+        ConsoleLogger.Detail($"    No peer had {fileName}; downloading from the repo");
+        return null;
+    }
+
+    private IEnumerable<(string Source, Func<string, CancellationToken, Task> Fetch)> Sources(string url)
+    {
+        var timeout = TimeSpan.FromSeconds(_settings.TimeoutSeconds);
+        if (_deliveryOptimization != null)
+        {
+            // Repo credentials go to the repo only, not to other download origins
+            var headers = RepoPath(url) != null ? _repoHeaders : null;
+            yield return (PeerCacheMode.DeliveryOptimization,
+                (path, ct) => _deliveryOptimization.DownloadAsync(url, path, headers, timeout, ct));
+        }
+        else if (_peerClient != null && RepoPath(url) is { } relative)
+        {
+            foreach (var peer in _settings.Peers.Select(p => p.Trim().TrimEnd('/')).Where(p => p.Length > 0))
+            {
+                yield return ($"peer {peer}", (path, ct) => FetchFromPeerAsync($"{peer}/{relative}", path, timeout, ct));
+            }
+        }
+    }
+
+    private string? RepoPath(string url) =>
+        Uri.TryCreate(url, UriKind.Absolute, out var uri) ? _mirrors.RelativePath(uri) : null;
+
+    /// <summary>
+    /// GETs <paramref name="url"/> into <paramref name="path"/>, giving up when
+    /// no bytes arrive for <paramref name="noProgressTimeout"/>.
+    /// </summary>
+    private async Task FetchFromPeerAsync(string url, string path, TimeSpan noProgressTimeout, CancellationToken cancellationToken)
+    {
+        using var stallCts = CancellationTokenSource.CreateLinkedTokenSource(cancellationToken);
+        stallCts.CancelAfter(noProgressTimeout);
+
+        using var response = await _peerClient!.GetAsync(url, HttpCompletionOption.ResponseHeadersRead, stallCts.Token);
+        response.EnsureSuccessStatusCode();
+
+        await using var source = await response.Content.ReadAsStreamAsync(stallCts.Token);
+        await using var destination = new FileStream(path, FileMode.Create, FileAccess.Write, FileShare.None, BufferSize, true);
+        var buffer = new byte[BufferSize];
+        int read;
+        while ((read = await source.ReadAsync(buffer, stallCts.Token)) > 0)
+        {
+            await destination.WriteAsync(buffer.AsMemory(0, read), stallCts.Token);
+            stallCts.CancelAfter(noProgressTimeout);
+        }
+    }
+
+    /// <summary>
+    /// Peer servers get the proxy settings but never the repo credentials.
+    /// </summary>
+    private static HttpClient CreatePeerClient(CimianConfig config)
+    {
+        var handler = new HttpClientHandler();
+        ProxySelector.Apply(handler, config.ProxySettings);
+        var client = new HttpClient(handler) { Timeout = Timeout.InfiniteTimeSpan };
+        client.DefaultRequestHeaders.Add("User-Agent", "Cimian-ManagedSoftwareUpdate/1.0");
+        return client;
+    }
+}
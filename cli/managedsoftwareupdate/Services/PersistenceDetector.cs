@@ -0,0 +1,128 @@
+using System.Management;
+using Cimian.Core;
+using Cimian.Core.Services;
+using Microsoft.Win32;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Whether this machine keeps its disk between boots. Non-persistent machines
+/// (pooled VDI clones, write-filtered kiosks) run in a tailored mode: no cache
+/// kept between runs, short log retention, no self-update (the image team
+/// updates Cimian in the golden image), and "non_persistent" in reports.
+/// </summary>
+public sealed record MachinePersistence(bool IsNonPersistent, string? Platform, string Reason)
+{
+    public static readonly MachinePersistence Persistent = new(false, null, "no non-persistent markers");
+
+    /// <summary>Value reported in session.json / sessions.json.</summary>
+    public string ReportValue => IsNonPersistent ? "non_persistent" : "persistent";
+}
+
+public static class PersistenceDetector
+{
+    /// <summary>HKLM keys that only exist on non-persistent VDI clones.</summary>
+    private static readonly (string Platform, string KeyPath)[] RegistryMarkers =
+    [
+        ("citrix-pvs", @"SYSTEM\CurrentControlSet\Services\bnistack\PvsAgent"),
+        ("citrix-mcs", @"SYSTEM\CurrentControlSet\Services\CVhdMp"),
+        ("vmware-horizon", @"SYSTEM\CurrentControlSet\Services\vmware-viewcomposer-ga")
+    ];
+
+    /// <summary>
+    /// NonPersistentMode from Config.yaml: auto (detect), always, never.
+    /// </summary>
+    public static MachinePersistence Detect(string? mode)
+    {
+        switch ((mode ?? "auto").Trim().ToLowerInvariant())
+        {
+            case "always" or "true":
+                return new MachinePersistence(true, null, "NonPersistentMode: always");
+            case "never" or "false":
+                return MachinePersistence.Persistent;
+        }
+        return Evaluate(CollectSignals());
+    }
+
+    /// <summary>
+    /// Any signal makes the machine non-persistent; the first one names the platform.
+    /// </summary>
+    public static MachinePersistence Evaluate(IReadOnlyList<(string Platform, string Evidence)> signals)
+    {
+        if (signals.Count == 0)
+        {
+            return MachinePersistence.Persistent;
+        }
+        return new MachinePersistence(true, signals[0].Platform, string.Join("; ", signals.Select(s => s.Evidence)));
+    }
+
+    private static List<(string Platform, string Evidence)> CollectSignals()
+    {
+        var signals = new List<(string Platform, string Evidence)>();
+
+        // Image builds can drop this file to opt in explicitly
+        if (File.Exists(CimianPaths.NonPersistentFlagFile))
+        {
+            signals.Add(("image", $"golden-image marker {CimianPaths.NonPersistentFlagFile}"));
+        }
+
+        foreach (var (platform, keyPath) in RegistryMarkers)
+        {
+            if (KeyExists(keyPath))
+            {
+                signals.Add((platform, $@"HKLM\{keyPath}"));
+            }
+        }
+
+        // AVD pooled hosts: the session host agent plus FSLogix profile containers
+        // (personal desktops rarely roam profiles)
+        if (KeyExists(@"SOFTWARE\Microsoft\RDInfraAgent")
+            && Registry.GetValue(@"HKEY_LOCAL_MACHINE\SOFTWARE\FSLogix\Profiles", "Enabled", null) is int enabled
+            && enabled == 1)
+        {
+            signals.Add(("avd-pooled", "AVD agent with FSLogix profile containers"));
+        }
+
+        if (IsUnifiedWriteFilterEnabled())
+        {
+            signals.Add(("uwf", "Unified Write Filter enabled"));
+        }
+
+        return signals;
+    }
+
+    private static bool KeyExists(string keyPath)
+    {
+        try
+        {
+            using var key = Registry.LocalMachine.OpenSubKey(keyPath);
+            return key != null;
+        }
+        catch (Exception ex) when (ex is System.Security.SecurityException or UnauthorizedAccessException)
+        {
+            return false;
+        }
+    }
+
+    private static bool IsUnifiedWriteFilterEnabled()
+    {
+        try
+        {
+            using var searcher = new ManagementObjectSearcher(@"root\standardcimv2\embedded",
+                "SELECT CurrentEnabled FROM UWF_Filter");
+            foreach (ManagementObject mo in searcher.Get())
+            {
+                if (mo["CurrentEnabled"] is true)
+                {
+                    return true;
+                }
+            }
+        }
+        catch (ManagementException ex)
+        {
+            // Namespace only exists when the UWF feature is installed
+            ConsoleLogger.Debug($"UWF query unavailable: {ex.Message}");
+        }
+        return false;
+    }
+}
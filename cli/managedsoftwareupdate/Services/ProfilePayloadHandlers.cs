@@ -0,0 +1,338 @@
+using System.Diagnostics;
+using System.Globalization;
+using System.Text.RegularExpressions;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core.Services;
+using Microsoft.Win32;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Reads and writes one type of profile payload. <see cref="ProfileService"/>
+/// compares what <see cref="ReadAsync"/> returns with the payload's value and
+/// calls <see cref="WriteAsync"/> when they differ. Failures throw.
+/// </summary>
+public interface IProfilePayloadHandler
+{
+    /// <summary>Null when the payload can be applied, else what is wrong with it.</summary>
+    string? Validate(ProfilePayload payload);
+
+    /// <summary>The current value in <see cref="ProfilePayload.NormalizeValue"/> form, or null when unset.</summary>
+    Task<string?> ReadAsync(ProfilePayload payload, CancellationToken cancellationToken);
+
+    /// <summary>Sets the value; null clears it, for removing a setting that wasn't there before.</summary>
+    Task WriteAsync(ProfilePayload payload, string? value, CancellationToken cancellationToken);
+
+    /// <summary>Called once after a pass that wrote anything through this handler.</summary>
+    Task CommitAsync(CancellationToken cancellationToken) => Task.CompletedTask;
+}
+
+/// <summary>A value under HKLM, written directly.</summary>
+public sealed class RegistryPayloadHandler : IProfilePayloadHandler
+{
+    private static readonly string[] LocalMachinePrefixes = [@"HKLM\", @"HKEY_LOCAL_MACHINE\", @"HKLM:\"];
+
+    public string? Validate(ProfilePayload payload)
+    {
+        if (string.IsNullOrWhiteSpace(payload.Key) || payload.Name == null)
+            return "registry payload needs key and name";
+        if (LocalMachinePath(payload.Key) == null)
+            return $"registry key {payload.Key} is not under HKLM";
+        return RegistryPolFile.KindFor(payload.EffectiveValueType()) == null
+            ? $"unsupported value_type {payload.ValueType}"
+            : null;
+    }
+
+    public Task<string?> ReadAsync(ProfilePayload payload, CancellationToken cancellationToken)
+    {
+        using var root = RegistryKey.OpenBaseKey(RegistryHive.LocalMachine, RegistryView.Registry64);
+        using var key = root.OpenSubKey(LocalMachinePath(payload.Key!)!);
+        var value = key?.GetValue(payload.Name, null, RegistryValueOptions.DoNotExpandEnvironmentNames);
+        return Task.FromResult(payload.NormalizeValue(Format(value)));
+    }
+
+    public Task WriteAsync(ProfilePayload payload, string? value, CancellationToken cancellationToken)
+    {
+        using var root = RegistryKey.OpenBaseKey(RegistryHive.LocalMachine, RegistryView.Registry64);
+        var path = LocalMachinePath(payload.Key!)!;
+        if (value == null)
+        {
+            using var existing = root.OpenSubKey(path, writable: true);
+            existing?.DeleteValue(payload.Name!, throwOnMissingValue: false);
+            return Task.CompletedTask;
+        }
+
+        var kind = RegistryPolFile.KindFor(payload.EffectiveValueType())!.Value;
+        using var key = root.CreateSubKey(path, writable: true);
+        key.SetValue(payload.Name!, kind switch
+        {
+            RegistryValueKind.DWord => unchecked((int)(uint)RegistryPolFile.ParseNumber(value)),
+            RegistryValueKind.QWord => RegistryPolFile.ParseNumber(value),
+            RegistryValueKind.MultiString => value.Split('\n'),
+            _ => value
+        }, kind);
+        return Task.CompletedTask;
+    }
+
+    /// <summary>The path under HKLM, or null for a key in another hive.</summary>
+    internal static string? LocalMachinePath(string key)
+    {
+        var prefix = LocalMachinePrefixes.FirstOrDefault(p => key.StartsWith(p, StringComparison.OrdinalIgnoreCase));
+        return prefix == null ? null : key[prefix.Length..].Trim('\\');
+    }
+
+    private static string? Format(object? value) => value switch
+    {
+        null => null,
+        string[] strings => string.Join('\n', strings),
+        int dword => unchecked((uint)dword).ToString(CultureInfo.InvariantCulture),
+        long qword => unchecked((ulong)qword).ToString(CultureInfo.InvariantCulture),
+        byte[] bytes => Convert.ToHexString(bytes),
+        _ => value.ToString()
+    };
+}
+
+/// <summary>
+/// A local Group Policy setting: written to the machine or user Registry.pol,
+/// then applied by a policy refresh, as if set in gpedit.msc.
+/// </summary>
+public sealed class PolicyPayloadHandler : IProfilePayloadHandler
+{
+    private readonly HashSet<string> _changedScopes = new();
+
+    public string? Validate(ProfilePayload payload)
+    {
+        if (string.IsNullOrWhiteSpace(payload.Key) || payload.Name == null)
+            return "policy payload needs key and name";
+        if (Scope(payload) is not ("machine" or "user"))
+            return $"policy scope must be machine or user, not {payload.Scope}";
+        return RegistryPolFile.KindFor(payload.EffectiveValueType()) == null
+            ? $"unsupported value_type {payload.ValueType}"
+            : null;
+    }
+
+    public Task<string?> ReadAsync(ProfilePayload payload, CancellationToken cancellationToken)
+    {
+        var entry = RegistryPolFile.Load(RegistryPolFile.PathFor(Scope(payload))).Find(PolicyKey(payload), payload.Name!);
+        return Task.FromResult(entry == null ? null : payload.NormalizeValue(RegistryPolFile.Decode(entry.Kind, entry.Data)));
+    }
+
+    public Task WriteAsync(ProfilePayload payload, string? value, CancellationToken cancellationToken)
+    {
+        var scope = Scope(payload);
+        var path = RegistryPolFile.PathFor(scope);
+        var file = RegistryPolFile.Load(path);
+        if (value == null)
+        {
+            file.Remove(PolicyKey(payload), payload.Name!);
+        }
+        else
+        {
+            var kind = RegistryPolFile.KindFor(payload.EffectiveValueType())!.Value;
+            file.Set(PolicyKey(payload), payload.Name!, kind, RegistryPolFile.Encode(kind, value));
+        }
+        file.Save(path);
+        _changedScopes.Add(scope);
+        return Task.CompletedTask;
+    }
+
+    /// <summary>Bumps gpt.ini for each changed side and refreshes policy so the values land in the registry.</summary>
+    public async Task CommitAsync(CancellationToken cancellationToken)
+    {
+        foreach (var scope in _changedScopes)
+        {
+            var gptIni = RegistryPolFile.GptIniPath;
+            var content = File.Exists(gptIni) ? await File.ReadAllTextAsync(gptIni, cancellationToken) : null;
+            await File.WriteAllTextAsync(gptIni, RegistryPolFile.UpdateGptIni(content, scope), cancellationToken);
+
+            var target = scope == "user" ? "user" : "computer";
+            ConsoleLogger.Info($"Refreshing local {target} policy");
+            using var process = Process.Start(new ProcessStartInfo
+            {
+                FileName = Path.Combine(Environment.SystemDirectory, "gpupdate.exe"),
+                Arguments = $"/target:{target} /force",
+                UseShellExecute = false,
+                CreateNoWindow = true,
+                RedirectStandardOutput = true,
+                RedirectStandardError = true
+            }) ?? throw new InvalidOperationException("gpupdate.exe did not start");
+            var output = process.StandardOutput.ReadToEndAsync(cancellationToken);
+            var errors = process.StandardError.ReadToEndAsync(cancellationToken);
+            await process.WaitForExitAsync(cancellationToken);
+            ConsoleLogger.Debug((await output + await errors).Trim());
+            if (process.ExitCode != 0)
+            {
+                ConsoleLogger.Warn($"gpupdate /target:{target} exited with {process.ExitCode}; policy applies at the next refresh");
+            }
+        }
+        _changedScopes.Clear();
+    }
+
+    private static string Scope(ProfilePayload payload) =>
+        string.IsNullOrWhiteSpace(payload.Scope) ? "machine" : payload.Scope.Trim().ToLowerInvariant();
+
+    // Registry.pol keys are relative to the hive the scope implies
+    private static string PolicyKey(ProfilePayload payload) =>
+        RegistryPayloadHandler.LocalMachinePath(payload.Key!) ?? payload.Key!.Trim('\\');
+}
+
+/// <summary>
+/// PowerShell-backed payloads. The read script prints VALUE|&lt;value&gt; or
+/// UNSET| as its last marker line.
+/// </summary>
+public abstract class PowerShellPayloadHandler : IProfilePayloadHandler
+{
+    private readonly ScriptService _scriptService;
+
+    protected PowerShellPayloadHandler(ScriptService scriptService)
+    {
+        _scriptService = scriptService;
+    }
+
+    public abstract string? Validate(ProfilePayload payload);
+
+    protected abstract string ReadScript(ProfilePayload payload);
+
+    /// <summary>The script that sets <paramref name="value"/>, or null when it can't be cleared.</summary>
+    protected abstract string? WriteScript(ProfilePayload payload, string? value);
+
+    public async Task<string?> ReadAsync(ProfilePayload payload, CancellationToken cancellationToken)
+    {
+        var output = await RunAsync(ReadScript(payload), cancellationToken);
+        var lines = output.Split('\n', '\r');
+        for (var i = lines.Length - 1; i >= 0; i--)
+        {
+            var line = lines[i];
+            if (line.StartsWith("VALUE|", StringComparison.Ordinal))
+                return payload.NormalizeValue(line[6..].Trim());
+            if (line.StartsWith("UNSET|", StringComparison.Ordinal))
+                return null;
+        }
+        throw new InvalidOperationException($"no value reported: {output.Trim()}");
+    }
+
+    public async Task WriteAsync(ProfilePayload payload, string? value, CancellationToken cancellationToken)
+    {
+        var script = WriteScript(payload, value);
+        if (script == null)
+        {
+            ConsoleLogger.Detail($"    {payload.Describe()} has no unset state; left as it is");
+            return;
+        }
+        await RunAsync(script, cancellationToken);
+    }
+
+    private async Task<string> RunAsync(string script, CancellationToken cancellationToken)
+    {
+        var (success, output) = await _scriptService.ExecuteScriptWithExitCodeAsync(
+            "$ErrorActionPreference = 'Stop'\n" + script, cancellationToken);
+        if (!success)
+        {
+            throw new InvalidOperationException(output.Trim());
+        }
+        return output;
+    }
+
+    /// <summary>A PowerShell literal: $true/$false, a number, or a single-quoted string.</summary>
+    internal static string Literal(string? value)
+    {
+        if (value == null)
+            return "$null";
+        if (bool.TryParse(value, out var flag))
+            return flag ? "$true" : "$false";
+        if (long.TryParse(value, NumberStyles.AllowLeadingSign, CultureInfo.InvariantCulture, out _))
+            return value;
+        return "'" + value.Replace("'", "''") + "'";
+    }
+
+    protected static bool IsIdentifier(string? value) =>
+        !string.IsNullOrEmpty(value) && Regex.IsMatch(value, "^[A-Za-z0-9_]+$");
+}
+
+/// <summary>
+/// A Policy CSP setting, ./Device/Vendor/MSFT/Policy/Config/&lt;Area&gt;/&lt;Policy&gt;,
+/// set through the MDM WMI bridge as an MDM server would.
+/// </summary>
+public sealed class CspPayloadHandler : PowerShellPayloadHandler
+{
+    private const string Namespace = @"root\cimv2\mdm\dmmap";
+    private const string ParentId = "./Vendor/MSFT/Policy/Config";
+
+    private static readonly Regex PolicyUri = new(
+        @"^\./(Device/)?Vendor/MSFT/Policy/Config/(?<area>[A-Za-z0-9_]+)/(?<policy>[A-Za-z0-9_]+)$",
+        RegexOptions.IgnoreCase | RegexOptions.Compiled);
+
+    public CspPayloadHandler(ScriptService scriptService) : base(scriptService) { }
+
+    public override string? Validate(ProfilePayload payload) =>
+        payload.Uri != null && PolicyUri.IsMatch(payload.Uri)
+            ? null
+            : $"csp uri {payload.Uri} is not a ./Device/Vendor/MSFT/Policy/Config/<Area>/<Policy> node";
+
+    protected override string ReadScript(ProfilePayload payload)
+    {
+        var (area, policy) = Parse(payload);
+        return $$"""
+            $i = Get-CimInstance -Namespace '{{Namespace}}' -ClassName 'MDM_Policy_Config01_{{area}}02' -Filter "ParentID='{{ParentId}}' and InstanceID='{{area}}'"
+            if ($i -and $null -ne $i.{{policy}}) { "VALUE|$($i.{{policy}})" } else { 'UNSET|' }
+            """;
+    }
+
+    protected override string WriteScript(ProfilePayload payload, string? value)
+    {
+        var (area, policy) = Parse(payload);
+        return $$"""
+            $class = 'MDM_Policy_Config01_{{area}}02'
+            $i = Get-CimInstance -Namespace '{{Namespace}}' -ClassName $class -Filter "ParentID='{{ParentId}}' and InstanceID='{{area}}'"
+            if ($i) {
+                $i.{{policy}} = {{Literal(value)}}
+                Set-CimInstance -CimInstance $i
+            } elseif ($null -ne {{Literal(value)}}) {
+                New-CimInstance -Namespace '{{Namespace}}' -ClassName $class -Property @{ ParentID = '{{ParentId}}'; InstanceID = '{{area}}'; {{policy}} = {{Literal(value)}} } | Out-Null
+            }
+            """;
+    }
+
+    private static (string Area, string Policy) Parse(ProfilePayload payload)
+    {
+        var match = PolicyUri.Match(payload.Uri!);
+        return (match.Groups["area"].Value, match.Groups["policy"].Value);
+    }
+}
+
+/// <summary>A Microsoft Defender preference, read with Get-MpPreference and set with Set-MpPreference.</summary>
+public sealed class DefenderPayloadHandler : PowerShellPayloadHandler
+{
+    public DefenderPayloadHandler(ScriptService scriptService) : base(scriptService) { }
+
+    public override string? Validate(ProfilePayload payload) =>
+        IsIdentifier(payload.Setting) ? null : $"defender setting {payload.Setting} is not a Set-MpPreference parameter name";
+
+    protected override string ReadScript(ProfilePayload payload) => $$"""
+        $v = (Get-MpPreference).{{payload.Setting}}
+        if ($null -eq $v) { 'UNSET|' } else { "VALUE|$($v -join ',')" }
+        """;
+
+    protected override string? WriteScript(ProfilePayload payload, string? value) =>
+        value == null ? null : $"Set-MpPreference -{payload.Setting} {Literal(value)}";
+}
+
+/// <summary>Whether Windows Firewall is on for the domain, private or public profile.</summary>
+public sealed class FirewallPayloadHandler : PowerShellPayloadHandler
+{
+    public FirewallPayloadHandler(ScriptService scriptService) : base(scriptService) { }
+
+    public override string? Validate(ProfilePayload payload)
+    {
+        if (payload.Profile?.Trim().ToLowerInvariant() is not ("domain" or "private" or "public"))
+            return $"firewall profile must be domain, private or public, not {payload.Profile}";
+        return bool.TryParse(payload.Value, out _) ? null : "firewall value must be true or false";
+    }
+
+    protected override string ReadScript(ProfilePayload payload) => $$"""
+        "VALUE|$((Get-NetFirewallProfile -Profile {{payload.Profile!.Trim()}}).Enabled)"
+        """;
+
+    protected override string? WriteScript(ProfilePayload payload, string? value) =>
+        value == null ? null : $"Set-NetFirewallProfile -Profile {payload.Profile!.Trim()} -Enabled {(value == "true" ? "True" : "False")}";
+}
@@ -0,0 +1,352 @@
+using System.Net;
+using YamlDotNet.Serialization;
+using YamlDotNet.Serialization.NamingConventions;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>What a profile pass did with one profile.</summary>
+public sealed record ProfileOutcome(string Name, string Version, string Action, IReadOnlyList<string> Changes, IReadOnlyList<string> Errors)
+{
+    public const string Compliant = "compliant";
+    public const string Applied = "applied";
+    public const string WouldApply = "would_apply";
+    public const string Removed = "removed";
+    public const string WouldRemove = "would_remove";
+
+    public bool Success => Errors.Count == 0;
+}
+
+/// <summary>
+/// Applies managed_profiles from the repo's profiles/ directory: each payload is
+/// read, compared with the profile and written only when it differs, so a
+/// compliant machine is left alone. What a setting held before is kept in
+/// <see cref="ManagedProfilesStore"/> and put back when the profile (or that
+/// setting) is removed. A name with no profiles/&lt;name&gt;.yaml in the repo is
+/// left to external MDM, as before.
+/// </summary>
+public class ProfileService
+{
+    private readonly CimianConfig _config;
+    private readonly HttpClient _httpClient;
+    private readonly HttpValidatorStore _validators;
+    private readonly ManagedProfilesStore _store;
+    private readonly string _profilesPath;
+    private readonly IReadOnlyDictionary<string, IProfilePayloadHandler> _handlers;
+
+    private static readonly IDeserializer Deserializer = new DeserializerBuilder()
+        .WithNamingConvention(UnderscoredNamingConvention.Instance)
+        .IgnoreUnmatchedProperties()
+        .Build();
+
+    public ProfileService(
+        CimianConfig config,
+        ScriptService scriptService,
+        HttpClient? httpClient = null,
+        ManagedProfilesStore? store = null,
+        IReadOnlyDictionary<string, IProfilePayloadHandler>? handlers = null,
+        string? profilesPath = null)
+    {
+        _config = config;
+        _httpClient = httpClient ?? CimianHttpClientFactory.CreateHttpClient(config, acceptCompressed: true);
+        _profilesPath = profilesPath ?? CimianPaths.ProfilesDir;
+        _validators = new HttpValidatorStore(_profilesPath);
+        _store = store ?? new ManagedProfilesStore();
+        _handlers = handlers ?? new Dictionary<string, IProfilePayloadHandler>
+        {
+            ["registry"] = new RegistryPayloadHandler(),
+            ["policy"] = new PolicyPayloadHandler(),
+            ["csp"] = new CspPayloadHandler(scriptService),
+            ["defender"] = new DefenderPayloadHandler(scriptService),
+            ["firewall"] = new FirewallPayloadHandler(scriptService)
+        };
+    }
+
+    /// <summary>
+    /// Fetches profiles/&lt;name&gt;.yaml for each name. A repo that can't be
+    /// reached falls back to the last copy fetched; a 404 means the name
+    /// belongs to external MDM and is skipped.
+    /// </summary>
+    public async Task<List<ManagedProfile>> FetchAsync(IEnumerable<string> names, CancellationToken cancellationToken)
+    {
+        var profiles = new List<ManagedProfile>();
+        foreach (var name in names.Distinct(StringComparer.OrdinalIgnoreCase))
+        {
+            var content = await FetchContentAsync(name, cancellationToken);
+            if (content == null)
+            {
+                continue;
+            }
+            try
+            {
+                var profile = Deserializer.Deserialize<ManagedProfile>(content) ?? new ManagedProfile();
+                if (string.IsNullOrWhiteSpace(profile.Name))
+                {
+                    profile.Name = name;
+                }
+                profiles.Add(profile);
+            }
+            catch (YamlDotNet.Core.YamlException ex)
+            {
+                ConsoleLogger.Warn($"Profile {name} is not valid YAML: {ex.Message}");
+            }
+        }
+        return profiles;
+    }
+
+    private async Task<string?> FetchContentAsync(string name, CancellationToken cancellationToken)
+    {
+        var url = $"{_config.SoftwareRepoURL.TrimEnd('/')}/profiles/{name}.yaml";
+        var localPath = Path.Combine(_profilesPath, $"{name}.yaml");
+        try
+        {
+            using var request = new HttpRequestMessage(HttpMethod.Get, url);
+            _validators.ApplyTo(request, localPath);
+            using var response = await _httpClient.SendAsync(request, cancellationToken);
+            if (response.StatusCode == HttpStatusCode.NotModified && File.Exists(localPath))
+            {
+                OfflineCache.Touch(localPath);
+                return await File.ReadAllTextAsync(localPath, cancellationToken);
+            }
+            if (response.IsSuccessStatusCode)
+            {
+                var content = await response.Content.ReadAsStringAsync(cancellationToken);
+                Directory.CreateDirectory(_profilesPath);
+                await File.WriteAllTextAsync(localPath, content, cancellationToken);
+                _validators.Record(url, response);
+                return content;
+            }
+            if (response.StatusCode == HttpStatusCode.NotFound)
+            {
+                ConsoleLogger.Detail($"    No profiles/{name}.yaml in the repo; {name} is left to external MDM");
+                return null;
+            }
+            ConsoleLogger.Warn($"Failed to download profile {name}: {response.StatusCode}");
+        }
+        catch (Exception ex) when (ex is HttpRequestException or TaskCanceledException && !cancellationToken.IsCancellationRequested)
+        {
+            ConsoleLogger.Warn($"Error downloading profile {name}: {ex.Message}");
+        }
+
+        // Re-applying the last copy only keeps settings the machine already has
+        if (File.Exists(localPath))
+        {
+            ConsoleLogger.Warn($"    Using cached profile {name}: repo unreachable");
+            return await File.ReadAllTextAsync(localPath, cancellationToken);
+        }
+        return null;
+    }
+
+    /// <summary>
+    /// Brings each profile's settings into line. A setting another profile in
+    /// this pass already holds is skipped with a warning, so two profiles can't
+    /// undo each other every run. With <paramref name="checkOnly"/> nothing is
+    /// written and the outcome says what would be.
+    /// </summary>
+    public async Task<List<ProfileOutcome>> ApplyAsync(
+        IReadOnlyList<ManagedProfile> profiles,
+        bool checkOnly,
+        DateTime now,
+        CancellationToken cancellationToken)
+    {
+        var outcomes = new List<ProfileOutcome>();
+        var claimed = new Dictionary<string, string>(StringComparer.OrdinalIgnoreCase);
+        var written = new HashSet<IProfilePayloadHandler>();
+
+        foreach (var profile in profiles)
+        {
+            var record = _store.Get(profile.Name) ?? new ManagedProfileRecord { Name = profile.Name };
+            var changes = new List<string>();
+            var errors = new List<string>();
+            var ids = new HashSet<string>(StringComparer.OrdinalIgnoreCase);
+
+            foreach (var payload in profile.Payloads)
+            {
+                var id = payload.Id();
+                ids.Add(id);
+                if (!claimed.TryAdd(id, profile.Name))
+                {
+                    ConsoleLogger.Warn($"Profile {profile.Name}: {payload.Describe()} is already set by {claimed[id]}; skipped");
+                    continue;
+                }
+
+                if (!_handlers.TryGetValue(payload.EffectiveType(), out var handler))
+                {
+                    errors.Add($"unknown payload type {payload.Type}");
+                    continue;
+                }
+                if (handler.Validate(payload) is { } invalid)
+                {
+                    errors.Add(invalid);
+                    continue;
+                }
+
+                try
+                {
+                    var current = await handler.ReadAsync(payload, cancellationToken);
+                    var desired = payload.DesiredValue();
+                    if (string.Equals(current, desired, StringComparison.Ordinal))
+                    {
+                        if (!checkOnly && record.Payloads.All(p => !string.Equals(p.Id, id, StringComparison.OrdinalIgnoreCase)))
+                        {
+                            record.Payloads.Add(new AppliedProfilePayload { Id = id, Payload = payload, Original = current });
+                        }
+                        continue;
+                    }
+
+                    changes.Add($"{payload.Describe()}: {current ?? "(unset)"} -> {desired ?? "(unset)"}");
+                    if (checkOnly)
+                    {
+                        continue;
+                    }
+
+                    var applied = record.Payloads.FirstOrDefault(p => string.Equals(p.Id, id, StringComparison.OrdinalIgnoreCase));
+                    if (applied == null)
+                    {
+                        record.Payloads.Add(new AppliedProfilePayload { Id = id, Payload = payload, Original = current });
+                    }
+                    else
+                    {
+                        applied.Payload = payload;
+                    }
+                    await handler.WriteAsync(payload, desired, cancellationToken);
+                    written.Add(handler);
+                }
+                catch (Exception ex) when (ex is not OperationCanceledException)
+                {
+                    errors.Add($"{payload.Describe()}: {ex.Message}");
+                }
+            }
+
+            // Settings the profile's new version no longer has go back to what they were
+            foreach (var dropped in record.Payloads.Where(p => !ids.Contains(p.Id)).ToList())
+            {
+                changes.Add($"{dropped.Payload.Describe()}: restored (no longer in the profile)");
+                if (checkOnly)
+                {
+                    continue;
+                }
+                if (await RestoreAsync(dropped, errors, written, cancellationToken))
+                {
+                    record.Payloads.Remove(dropped);
+                }
+            }
+
+            var action = changes.Count == 0 && errors.Count == 0 ? ProfileOutcome.Compliant
+                : checkOnly ? ProfileOutcome.WouldApply
+                : ProfileOutcome.Applied;
+            outcomes.Add(new ProfileOutcome(profile.Name, profile.Version, action, changes, errors));
+
+            if (!checkOnly)
+            {
+                record.Version = profile.Version;
+                record.EvaluatedAt = now;
+                record.Compliant = errors.Count == 0;
+                record.Errors = errors;
+                if (changes.Count > 0)
+                {
+                    record.AppliedAt = now;
+                }
+                _store.Set(record);
+            }
+        }
+
+        await CommitAsync(written, cancellationToken);
+        if (!checkOnly)
+        {
+            _store.Save();
+        }
+        return outcomes;
+    }
+
+    /// <summary>
+    /// Removes every applied profile not in <paramref name="keep"/>: each setting
+    /// gets its previous value back, or is cleared when it had none. A setting a
+    /// kept profile also holds is left to that profile.
+    /// </summary>
+    public async Task<List<ProfileOutcome>> RemoveAsync(
+        IEnumerable<string> keep,
+        bool checkOnly,
+        CancellationToken cancellationToken)
+    {
+        var kept = keep.ToHashSet(StringComparer.OrdinalIgnoreCase);
+        var all = _store.All();
+        var heldByKept = all
+            .Where(r => kept.Contains(r.Name))
+            .SelectMany(r => r.Payloads.Select(p => p.Id))
+            .ToHashSet(StringComparer.OrdinalIgnoreCase);
+
+        var outcomes = new List<ProfileOutcome>();
+        var written = new HashSet<IProfilePayloadHandler>();
+        foreach (var record in all.Where(r => !kept.Contains(r.Name)))
+        {
+            var changes = new List<string>();
+            var errors = new List<string>();
+            foreach (var applied in record.Payloads.Where(p => !heldByKept.Contains(p.Id)).ToList())
+            {
+                changes.Add($"{applied.Payload.Describe()}: restored");
+                if (!checkOnly)
+                {
+                    await RestoreAsync(applied, errors, written, cancellationToken);
+                }
+            }
+
+            outcomes.Add(new ProfileOutcome(record.Name, record.Version,
+                checkOnly ? ProfileOutcome.WouldRemove : ProfileOutcome.Removed, changes, errors));
+            if (!checkOnly && errors.Count == 0)
+            {
+                _store.Remove(record.Name);
+            }
+        }
+
+        await CommitAsync(written, cancellationToken);
+        if (!checkOnly && outcomes.Count > 0)
+        {
+            _store.Save();
+        }
+        return outcomes;
+    }
+
+    private async Task<bool> RestoreAsync(
+        AppliedProfilePayload applied,
+        List<string> errors,
+        HashSet<IProfilePayloadHandler> written,
+        CancellationToken cancellationToken)
+    {
+        if (!_handlers.TryGetValue(applied.Payload.EffectiveType(), out var handler))
+        {
+            return true;
+        }
+        try
+        {
+            if (!string.Equals(await handler.ReadAsync(applied.Payload, cancellationToken), applied.Original, StringComparison.Ordinal))
+            {
+                await handler.WriteAsync(applied.Payload, applied.Original, cancellationToken);
+                written.Add(handler);
+            }
+            return true;
+        }
+        catch (Exception ex) when (ex is not OperationCanceledException)
+        {
+            errors.Add($"{applied.Payload.Describe()}: {ex.Message}");
+            return false;
+        }
+    }
+
+    private static async Task CommitAsync(IEnumerable<IProfilePayloadHandler> handlers, CancellationToken cancellationToken)
+    {
+        foreach (var handler in handlers)
+        {
+            try
+            {
+                await handler.CommitAsync(cancellationToken);
+            }
+            catch (Exception ex) when (ex is not OperationCanceledException)
+            {
+                ConsoleLogger.Warn($"Could not finish applying profile settings: {ex.Message}");
+            }
+        }
+    }
+}
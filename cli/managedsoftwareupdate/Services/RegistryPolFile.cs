@@ -0,0 +1,240 @@
+using System.Globalization;
+using System.Text;
+using Microsoft.Win32;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>One value in a Registry.pol file.</summary>
+public sealed record RegistryPolEntry(string Key, string ValueName, RegistryValueKind Kind, byte[] Data);
+
+/// <summary>
+/// Reads and writes the local Group Policy Registry.pol files
+/// (%SystemRoot%\System32\GroupPolicy\Machine and \User), in the PReg format
+/// the Group Policy registry extension applies: a "PReg" version 1 header, then
+/// [key;value;type;size;data] entries with UTF-16 delimiters.
+/// </summary>
+public sealed class RegistryPolFile
+{
+    private const uint Signature = 0x67655250; // "PReg"
+    private const uint FormatVersion = 1;
+
+    private static readonly string GroupPolicyDir = Path.Combine(Environment.SystemDirectory, "GroupPolicy");
+
+    public List<RegistryPolEntry> Entries { get; } = new();
+
+    /// <summary>Registry.pol for machine or user policy.</summary>
+    public static string PathFor(string scope) =>
+        Path.Combine(GroupPolicyDir, scope == "user" ? "User" : "Machine", "Registry.pol");
+
+    public static string GptIniPath => Path.Combine(GroupPolicyDir, "gpt.ini");
+
+    /// <summary>The file at <paramref name="path"/>, or an empty one when there is none yet.</summary>
+    public static RegistryPolFile Load(string path) =>
+        File.Exists(path) ? Parse(File.ReadAllBytes(path)) : new RegistryPolFile();
+
+    public static RegistryPolFile Parse(byte[] bytes)
+    {
+        var file = new RegistryPolFile();
+        if (bytes.Length == 0)
+        {
+            return file;
+        }
+        using var reader = new BinaryReader(new MemoryStream(bytes), Encoding.Unicode);
+        if (bytes.Length < 8 || reader.ReadUInt32() != Signature || reader.ReadUInt32() != FormatVersion)
+        {
+            throw new InvalidDataException("Not a PReg version 1 file");
+        }
+
+        while (reader.BaseStream.Position < reader.BaseStream.Length)
+        {
+            Expect(reader, '[');
+            var key = ReadString(reader);
+            Expect(reader, ';');
+            var valueName = ReadString(reader);
+            Expect(reader, ';');
+            var kind = (RegistryValueKind)reader.ReadInt32();
+            Expect(reader, ';');
+            var size = reader.ReadInt32();
+            Expect(reader, ';');
+            var data = reader.ReadBytes(size);
+            if (data.Length != size)
+            {
+                throw new InvalidDataException($"Truncated data for {key}\\{valueName}");
+            }
+            Expect(reader, ']');
+            file.Entries.Add(new RegistryPolEntry(key, valueName, kind, data));
+        }
+        return file;
+    }
+
+    public byte[] ToBytes()
+    {
+        using var stream = new MemoryStream();
+        using var writer = new BinaryWriter(stream, Encoding.Unicode);
+        writer.Write(Signature);
+        writer.Write(FormatVersion);
+        foreach (var entry in Entries)
+        {
+            writer.Write('[');
+            WriteString(writer, entry.Key);
+            writer.Write(';');
+            WriteString(writer, entry.ValueName);
+            writer.Write(';');
+            writer.Write((int)entry.Kind);
+            writer.Write(';');
+            writer.Write(entry.Data.Length);
+            writer.Write(';');
+            writer.Write(entry.Data);
+            writer.Write(']');
+        }
+        writer.Flush();
+        return stream.ToArray();
+    }
+
+    public void Save(string path)
+    {
+        var dir = Path.GetDirectoryName(path);
+        if (!string.IsNullOrEmpty(dir))
+        {
+            Directory.CreateDirectory(dir);
+        }
+        var tempPath = path + ".tmp";
+        File.WriteAllBytes(tempPath, ToBytes());
+        File.Move(tempPath, path, overwrite: true);
+    }
+
+    public RegistryPolEntry? Find(string key, string valueName) =>
+        Entries.FirstOrDefault(e => Matches(e, key, valueName));
+
+    /// <summary>Replaces the value in place, or adds it at the end.</summary>
+    public void Set(string key, string valueName, RegistryValueKind kind, byte[] data)
+    {
+        var entry = new RegistryPolEntry(key, valueName, kind, data);
+        var index = Entries.FindIndex(e => Matches(e, key, valueName));
+        if (index >= 0)
+        {
+            Entries[index] = entry;
+        }
+        else
+        {
+            Entries.Add(entry);
+        }
+    }
+
+    public bool Remove(string key, string valueName) =>
+        Entries.RemoveAll(e => Matches(e, key, valueName)) > 0;
+
+    /// <summary>A profile value_type as a registry kind; null for one that isn't supported.</summary>
+    public static RegistryValueKind? KindFor(string valueType) => valueType switch
+    {
+        "string" => RegistryValueKind.String,
+        "expand_string" => RegistryValueKind.ExpandString,
+        "dword" => RegistryValueKind.DWord,
+        "qword" => RegistryValueKind.QWord,
+        "multi_string" => RegistryValueKind.MultiString,
+        _ => null
+    };
+
+    /// <summary>A value as stored data. Multi-string values are newline-separated.</summary>
+    public static byte[] Encode(RegistryValueKind kind, string value) => kind switch
+    {
+        RegistryValueKind.DWord => BitConverter.GetBytes(unchecked((uint)ParseNumber(value))),
+        RegistryValueKind.QWord => BitConverter.GetBytes(ParseNumber(value)),
+        RegistryValueKind.MultiString => Encoding.Unicode.GetBytes(string.Join('\0', value.Split('\n')) + "\0\0"),
+        _ => Encoding.Unicode.GetBytes(value + "\0")
+    };
+
+    /// <summary>Stored data as a value, the inverse of <see cref="Encode"/>.</summary>
+    public static string Decode(RegistryValueKind kind, byte[] data) => kind switch
+    {
+        RegistryValueKind.DWord when data.Length >= 4 => BitConverter.ToUInt32(data).ToString(CultureInfo.InvariantCulture),
+        RegistryValueKind.QWord when data.Length >= 8 => BitConverter.ToUInt64(data).ToString(CultureInfo.InvariantCulture),
+        RegistryValueKind.MultiString => string.Join('\n', Encoding.Unicode.GetString(data).TrimEnd('\0').Split('\0')),
+        _ => Encoding.Unicode.GetString(data).TrimEnd('\0')
+    };
+
+    /// <summary>
+    /// gpt.ini after a change to machine or user policy: the registry extension
+    /// listed for that side, and its half of Version bumped (machine in the low
+    /// word, user in the high word) so the next refresh re-reads Registry.pol.
+    /// </summary>
+    public static string UpdateGptIni(string? content, string scope)
+    {
+        var user = scope == "user";
+        var lines = (content ?? "").Split(["\r\n", "\n"], StringSplitOptions.None)
+            .Where(l => l.Length > 0)
+            .ToList();
+        if (!lines.Any(l => l.Trim().Equals("[General]", StringComparison.OrdinalIgnoreCase)))
+        {
+            lines.Insert(0, "[General]");
+        }
+
+        var versionIndex = lines.FindIndex(l => l.StartsWith("Version=", StringComparison.OrdinalIgnoreCase));
+        uint.TryParse(versionIndex >= 0 ? lines[versionIndex]["Version=".Length..] : "0", out var version);
+        var machineVersion = (version & 0xFFFF) + (user ? 0u : 1u);
+        var userVersion = (version >> 16) + (user ? 1u : 0u);
+        var versionLine = $"Version={((userVersion & 0xFFFF) << 16) | (machineVersion & 0xFFFF)}";
+        if (versionIndex >= 0)
+        {
+            lines[versionIndex] = versionLine;
+        }
+        else
+        {
+            lines.Add(versionLine);
+        }
+
+        // Registry extension plus the Administrative Templates snap-in for that side
+        var namesKey = user ? "gPCUserExtensionNames=" : "gPCMachineExtensionNames=";
+        var extension = user
+            ? "[{35378EAC-683F-11D2-A89A-00C04FBBCFA2}{D02B1F73-3407-48AE-BA88-E8213C6761F1}]"
+            : "[{35378EAC-683F-11D2-A89A-00C04FBBCFA2}{D02B1F72-3407-48AE-BA88-E8213C6761F1}]";
+        var namesIndex = lines.FindIndex(l => l.StartsWith(namesKey, StringComparison.OrdinalIgnoreCase));
+        if (namesIndex < 0)
+        {
+            lines.Insert(1, namesKey + extension);
+        }
+        else if (!lines[namesIndex].Contains("{35378EAC-683F-11D2-A89A-00C04FBBCFA2}", StringComparison.OrdinalIgnoreCase))
+        {
+            lines[namesIndex] += extension;
+        }
+
+        return string.Join("\r\n", lines) + "\r\n";
+    }
+
+    internal static long ParseNumber(string value)
+    {
+        var trimmed = value.Trim();
+        return trimmed.StartsWith("0x", StringComparison.OrdinalIgnoreCase)
+            ? (long)ulong.Parse(trimmed[2..], NumberStyles.HexNumber, CultureInfo.InvariantCulture)
+            : long.Parse(trimmed, CultureInfo.InvariantCulture);
+    }
+
+    private static bool Matches(RegistryPolEntry entry, string key, string valueName) =>
+        string.Equals(entry.Key, key, StringComparison.OrdinalIgnoreCase)
+        && string.Equals(entry.ValueName, valueName, StringComparison.OrdinalIgnoreCase);
+
+    private static void Expect(BinaryReader reader, char expected)
+    {
+        var actual = reader.ReadChar();
+        if (actual != expected)
+        {
+            throw new InvalidDataException($"Expected '{expected}' at offset {reader.BaseStream.Position - 2}, found '{actual}'");
+        }
+    }
+
+    private static string ReadString(BinaryReader reader)
+    {
+        var value = new StringBuilder();
+        char c;
+        while ((c = reader.ReadChar()) != '\0')
+        {
+            value.Append(c);
+        }
+        return value.ToString();
+    }
+
+    private static void WriteString(BinaryWriter writer, string value)
+    {
+        writer.Write(Encoding.Unicode.GetBytes(value + "\0"));
+    }
+}
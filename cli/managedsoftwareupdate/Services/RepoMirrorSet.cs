@@ -0,0 +1,162 @@
+using System.Collections.Concurrent;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// The repo mirrors from SoftwareRepoURL and SoftwareRepoURLs, in the order
+/// requests should try them, and which mirror served each artifact.
+///
+/// Services keep building URLs from SoftwareRepoURL (the primary);
+/// <see cref="MirrorFailoverHandler"/> rebases each repo request onto the
+/// mirrors in turn. The order starts as configured, with mirrors that fail the
+/// first health check moved to the back; a mirror that errors, times out or
+/// serves corrupt content (<see cref="ReportBadContent"/>) is moved to the back
+/// for the rest of the run. One set is shared by every HTTP client in the process.
+/// </summary>
+public sealed class RepoMirrorSet
+{
+    private static readonly ConcurrentDictionary<string, RepoMirrorSet> Shared = new(StringComparer.OrdinalIgnoreCase);
+
+    private readonly List<string> _mirrors;
+    private readonly List<string> _order;
+    private readonly object _lock = new();
+    private readonly ConcurrentDictionary<string, string> _servedBy = new(StringComparer.OrdinalIgnoreCase);
+    private Task? _healthCheck;
+
+    public RepoMirrorSet(IEnumerable<string> mirrors)
+    {
+        _mirrors = mirrors.Select(m => m.Trim().TrimEnd('/')).Where(m => m.Length > 0)
+            .Distinct(StringComparer.OrdinalIgnoreCase).ToList();
+        _order = [.. _mirrors];
+    }
+
+    /// <summary>The process-wide set for <paramref name="config"/>'s mirrors.</summary>
+    public static RepoMirrorSet For(CimianConfig config)
+    {
+        var mirrors = Configured(config);
+        return Shared.GetOrAdd(string.Join("|", mirrors), _ => new RepoMirrorSet(mirrors));
+    }
+
+    /// <summary>SoftwareRepoURL followed by SoftwareRepoURLs, without duplicates.</summary>
+    public static List<string> Configured(CimianConfig config) =>
+        new[] { config.SoftwareRepoURL }.Concat(config.SoftwareRepoURLs)
+            .Where(u => !string.IsNullOrWhiteSpace(u))
+            .Select(u => u.Trim().TrimEnd('/'))
+            .Distinct(StringComparer.OrdinalIgnoreCase)
+            .ToList();
+
+    public IReadOnlyList<string> Mirrors => _mirrors;
+
+    /// <summary>True when there is more than one mirror to fail over to.</summary>
+    public bool HasFailover => _mirrors.Count > 1;
+
+    /// <summary>Mirrors in the order the next request will try them.</summary>
+    public IReadOnlyList<string> Order
+    {
+        get
+        {
+            lock (_lock)
+            {
+                return [.. _order];
+            }
+        }
+    }
+
+    /// <summary>Repo-relative path to the mirror that last served it.</summary>
+    public IReadOnlyDictionary<string, string> ServedBy => new SortedDictionary<string, string>(_servedBy, StringComparer.OrdinalIgnoreCase);
+
+    /// <summary>
+    /// The path of <paramref name="uri"/> below whichever mirror it points into
+    /// ("catalogs/Production.yaml"), or null for URLs outside the repo.
+    /// </summary>
+    public string? RelativePath(Uri uri)
+    {
+        var url = uri.ToString();
+        foreach (var mirror in _mirrors)
+        {
+            if (url.StartsWith(mirror + "/", StringComparison.OrdinalIgnoreCase))
+            {
+                return url[(mirror.Length + 1)..];
+            }
+        }
+        return null;
+    }
+
+    public static Uri Rebase(string relativePath, string mirror) => new($"{mirror}/{relativePath}");
+
+    public void RecordServed(string relativePath, string mirror)
+    {
+        _servedBy[relativePath] = mirror;
+        if (HasFailover && !mirror.Equals(_mirrors[0], StringComparison.OrdinalIgnoreCase))
+        {
+            ConsoleLogger.Detail($"    {relativePath} served by mirror {mirror}");
+        }
+    }
+
+    /// <summary>Moves <paramref name="mirror"/> behind every other mirror.</summary>
+    public void Demote(string mirror, string reason)
+    {
+        lock (_lock)
+        {
+            if (!_order.Remove(mirror))
+            {
+                return;
+            }
+            _order.Add(mirror);
+        }
+        if (HasFailover)
+        {
+            ConsoleLogger.Warn($"Repo mirror {mirror} failed ({reason}); trying the next mirror");
+        }
+    }
+
+    /// <summary>
+    /// Content fetched from <paramref name="url"/> failed verification (hash
+    /// mismatch, unparseable catalog): demote the mirror that served it so the
+    /// retry goes elsewhere. Returns false when there is no other mirror.
+    /// </summary>
+    public bool ReportBadContent(string url, string reason)
+    {
+        if (!HasFailover || !Uri.TryCreate(url, UriKind.Absolute, out var uri) || RelativePath(uri) is not { } relative)
+        {
+            return false;
+        }
+        var mirror = _servedBy.TryGetValue(relative, out var served) ? served : _mirrors[0];
+        Demote(mirror, $"{reason} for {relative}");
+        return true;
+    }
+
+    /// <summary>
+    /// Runs <paramref name="probe"/> against every mirror once per process and
+    /// moves unreachable ones to the back, keeping the configured order otherwise.
+    /// </summary>
+    public Task EnsureHealthCheckedAsync(Func<string, Task<bool>> probe)
+    {
+        lock (_lock)
+        {
+            return _healthCheck ??= HealthCheckAsync(probe);
+        }
+    }
+
+    private async Task HealthCheckAsync(Func<string, Task<bool>> probe)
+    {
+        var results = await Task.WhenAll(_mirrors.Select(async m => (Mirror: m, Healthy: await probe(m).ConfigureAwait(false)))).ConfigureAwait(false);
+        var unhealthy = results.Where(r => !r.Healthy).Select(r => r.Mirror).ToHashSet(StringComparer.OrdinalIgnoreCase);
+        lock (_lock)
+        {
+            var ordered = OrderByHealth(_mirrors, unhealthy);
+            _order.Clear();
+            _order.AddRange(ordered);
+        }
+        foreach (var mirror in unhealthy)
+        {
+            ConsoleLogger.Warn($"Repo mirror {mirror} failed its health check; it will be tried last");
+        }
+        ConsoleLogger.Debug($"Repo mirror order: {string.Join(", ", Order)}");
+    }
+
+    internal static List<string> OrderByHealth(IReadOnlyList<string> mirrors, IReadOnlySet<string> unhealthy) =>
+        [.. mirrors.Where(m => !unhealthy.Contains(m)), .. mirrors.Where(unhealthy.Contains)];
+}
@@ -0,0 +1,232 @@
+using System.Diagnostics;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Runs the shared scripts manifests (preflight_scripts / postflight_scripts)
+/// and items (preinstall_scripts / postinstall_scripts) point at. Scripts live
+/// in the repo's scripts\ directory, are pinned by SHA256, and are cached under
+/// CimianPaths.ScriptsDir so a pinned copy keeps working offline.
+/// </summary>
+public class RepoScriptService
+{
+    private readonly CimianConfig _config;
+    private readonly ScriptService _scriptService;
+    private readonly HttpClient _httpClient;
+    private readonly string _scriptsPath;
+    private SessionLogger? _sessionLogger;
+
+    public RepoScriptService(
+        CimianConfig config,
+        ScriptService scriptService,
+        HttpClient? httpClient = null,
+        string? scriptsPath = null)
+    {
+        _config = config;
+        _scriptService = scriptService;
+        _httpClient = httpClient ?? CimianHttpClientFactory.CreateHttpClient(config);
+        _scriptsPath = scriptsPath ?? CimianPaths.ScriptsDir;
+    }
+
+    /// <summary>
+    /// Sets the session logger for structured event logging
+    /// </summary>
+    public void SetSessionLogger(SessionLogger? logger)
+    {
+        _sessionLogger = logger;
+    }
+
+    /// <summary>
+    /// Fetches, verifies and runs <paramref name="script"/>. <paramref name="phase"/>
+    /// is the <see cref="ScriptKind"/> (preflight, postflight, preinstall or
+    /// postinstall) whose ScriptPolicies apply; <paramref name="item"/> and
+    /// <paramref name="manifest"/> say who asked for it. Never throws for a
+    /// failing script - the outcome says what happened.
+    /// </summary>
+    public async Task<RepoScriptOutcome> RunAsync(
+        RepoScript script,
+        string phase,
+        CatalogItem? item = null,
+        string? manifest = null,
+        CancellationToken cancellationToken = default)
+    {
+        var stopwatch = Stopwatch.StartNew();
+        RepoScriptOutcome outcome;
+
+        var (localPath, error) = await FetchAsync(script, cancellationToken);
+        if (localPath == null)
+        {
+            outcome = new RepoScriptOutcome(script.Name, phase, false, -1, false, "", error, stopwatch.Elapsed);
+            LogFetchFailure(script, outcome, item, manifest);
+        }
+        else
+        {
+            var runner = item != null ? _scriptService.ForItem(item) : _scriptService;
+            var context = new Dictionary<string, object>
+            {
+                ["script"] = script.Name,
+                ["manifest"] = manifest ?? "",
+                ["hash"] = script.Hash ?? ""
+            };
+
+            ConsoleLogger.Info($"Running {phase} script {script.Name}...");
+            var result = await runner.RunFileAsync(phase, localPath,
+                script.Timeout > 0 ? TimeSpan.FromSeconds(script.Timeout) : null, context, cancellationToken);
+            outcome = new RepoScriptOutcome(script.Name, phase, result.Success, result.ExitCode, result.TimedOut,
+                result.Output, result.Success ? null : result.TimedOut ? "timed out" : $"exit code {result.ExitCode}",
+                stopwatch.Elapsed, result.WarningMessage);
+        }
+
+        if (!outcome.Success && ScriptService.FailureActionFor(phase) != ScriptFailureAction.Ignore)
+        {
+            ConsoleLogger.Warn($"{phase} script {script.Name} failed: {outcome.Error}");
+        }
+        return outcome;
+    }
+
+    /// <summary>
+    /// Runs <paramref name="scripts"/> in order, stopping at the first failure when
+    /// <paramref name="stopOnFailure"/> is set.
+    /// </summary>
+    public async Task<List<RepoScriptOutcome>> RunAllAsync(
+        IEnumerable<(RepoScript Script, string? Manifest)> scripts,
+        string phase,
+        CatalogItem? item = null,
+        bool stopOnFailure = false,
+        CancellationToken cancellationToken = default)
+    {
+        var outcomes = new List<RepoScriptOutcome>();
+        foreach (var (script, manifest) in scripts)
+        {
+            var outcome = await RunAsync(script, phase, item, manifest, cancellationToken);
+            outcomes.Add(outcome);
+            if (!outcome.Success && stopOnFailure)
+            {
+                break;
+            }
+        }
+        return outcomes;
+    }
+
+    /// <summary>
+    /// The local copy of <paramref name="script"/>, downloading it when the cached
+    /// copy is missing or doesn't match the pinned hash. Returns the reason when
+    /// the script can't be used.
+    /// </summary>
+    internal async Task<(string? Path, string? Error)> FetchAsync(RepoScript script, CancellationToken cancellationToken)
+    {
+        if (!IsSafeName(script.Name))
+        {
+            return (null, $"'{script.Name}' is not a .ps1 path inside scripts/");
+        }
+        if (string.IsNullOrWhiteSpace(script.Hash))
+        {
+            if (_config.RequireHashValidation)
+            {
+                return (null, "no hash pinned and RequireHashValidation is on");
+            }
+            ConsoleLogger.Warn($"Script {script.Name} has no hash pinned; running it unverified");
+        }
+
+        var localPath = Path.Combine(_scriptsPath, script.Name.Replace('/', Path.DirectorySeparatorChar));
+        if (File.Exists(localPath) && !string.IsNullOrWhiteSpace(script.Hash) && HashMatches(localPath, script.Hash))
+        {
+            return (localPath, null);
+        }
+
+        var url = $"{_config.SoftwareRepoURL.TrimEnd('/')}/scripts/{script.Name.TrimStart('/')}";
+        var tempPath = localPath + ".downloading";
+        try
+        {
+            using var response = await _httpClient.GetAsync(url, cancellationToken);
+            if (!response.IsSuccessStatusCode)
+            {
+                return Fallback(localPath, script, $"download failed: {response.StatusCode}");
+            }
+            Directory.CreateDirectory(Path.GetDirectoryName(localPath)!);
+            await File.WriteAllBytesAsync(tempPath, await response.Content.ReadAsByteArrayAsync(cancellationToken), cancellationToken);
+        }
+        catch (Exception ex) when (ex is HttpRequestException or TaskCanceledException && !cancellationToken.IsCancellationRequested)
+        {
+            return Fallback(localPath, script, $"download failed: {ex.Message}");
+        }
+
+        if (!string.IsNullOrWhiteSpace(script.Hash) && !HashMatches(tempPath, script.Hash))
+        {
+            var actual = DownloadService.CalculateSHA256(tempPath);
+            File.Delete(tempPath);
+            return (null, $"hash mismatch (expected {script.Hash}, got {actual})");
+        }
+        File.Move(tempPath, localPath, overwrite: true);
+        return (localPath, null);
+    }
+
+    // Unpinned scripts only run from cache when the repo can't be reached;
+    // pinned ones were already checked before downloading
+    private static (string? Path, string? Error) Fallback(string localPath, RepoScript script, string error)
+    {
+        if (string.IsNullOrWhiteSpace(script.Hash) && File.Exists(localPath))
+        {
+            ConsoleLogger.Warn($"    Using cached script {script.Name}: {error}");
+            return (localPath, null);
+        }
+        return (null, error);
+    }
+
+    /// <summary>
+    /// True for a relative .ps1 path that stays inside scripts/.
+    /// </summary>
+    internal static bool IsSafeName(string? name)
+    {
+        if (string.IsNullOrWhiteSpace(name) || Path.IsPathRooted(name) || name.Contains(':'))
+        {
+            return false;
+        }
+        var segments = name.Split('/', '\\');
+        return segments.All(s => s.Length > 0 && s != "." && s != "..")
+            && name.EndsWith(".ps1", StringComparison.OrdinalIgnoreCase);
+    }
+
+    internal static bool HashMatches(string path, string expectedHash) =>
+        DownloadService.CalculateSHA256(path).Equals(expectedHash.Trim(), StringComparison.OrdinalIgnoreCase);
+
+    // Scripts that ran are recorded by ScriptService; this covers the ones that never could
+    private void LogFetchFailure(RepoScript script, RepoScriptOutcome outcome, CatalogItem? item, string? manifest)
+    {
+        _sessionLogger?.LogEvent(new LogEvent
+        {
+            Level = "WARN",
+            EventType = "script_run",
+            PackageName = item?.Name,
+            PackageVersion = item?.Version,
+            Action = outcome.Phase,
+            Status = "failed",
+            Message = $"{outcome.Phase} script {script.Name} failed: {outcome.Error}",
+            Context = new Dictionary<string, object>
+            {
+                ["script"] = script.Name,
+                ["kind"] = outcome.Phase,
+                ["manifest"] = manifest ?? "",
+                ["exit_code"] = outcome.ExitCode,
+                ["duration_ms"] = (long)outcome.Duration.TotalMilliseconds,
+                ["timed_out"] = false,
+                ["hash"] = script.Hash ?? ""
+            }
+        });
+    }
+}
+
+/// <summary>What happened when a repo script ran (or couldn't).</summary>
+public sealed record RepoScriptOutcome(
+    string Name,
+    string Phase,
+    bool Success,
+    int ExitCode,
+    bool TimedOut,
+    string Output,
+    string? Error,
+    TimeSpan Duration,
+    string? WarningMessage = null);
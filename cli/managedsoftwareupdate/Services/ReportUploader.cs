@@ -0,0 +1,249 @@
+using System.Net;
+using System.Net.Http.Headers;
+using System.Text;
+using System.Text.Json;
+using System.Text.Json.Nodes;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Sends each run's reports to ReportURL as one JSON document: session.json,
+/// reports/facts.json (inventory), reports/items.json, reports/usage.json and
+/// the session's error events.
+///
+/// The report is queued under report_queue before it is sent, and the queue is
+/// sent oldest first, so runs made offline are delivered by the next run that
+/// reaches the server. Requests go through the repo's HTTP client (proxy, client
+/// certificate, credentials); ReportAuthToken replaces the credentials.
+/// </summary>
+public sealed class ReportUploader
+{
+    /// <summary>Bumped when the payload changes incompatibly.</summary>
+    public const int SchemaVersion = 1;
+
+    /// <summary>Oldest queued reports are dropped beyond this.</summary>
+    internal const int MaxQueuedReports = 50;
+
+    private const int MaxAttempts = 3;
+
+    private readonly CimianConfig _config;
+    private readonly HttpClient _httpClient;
+    private readonly string _queueDir;
+    private readonly string _reportsDir;
+    private readonly TimeSpan _retryDelay;
+
+    public ReportUploader(CimianConfig config, HttpClient? httpClient = null, string? queueDir = null, string? reportsDir = null, TimeSpan? retryDelay = null)
+    {
+        _config = config;
+        _httpClient = httpClient ?? CimianHttpClientFactory.CreateHttpClient(config, TimeSpan.FromSeconds(60));
+        _queueDir = queueDir ?? CimianPaths.ReportQueueDir;
+        _reportsDir = reportsDir ?? CimianPaths.ReportsDir;
+        _retryDelay = retryDelay ?? TimeSpan.FromSeconds(2);
+    }
+
+    public bool IsEnabled => !string.IsNullOrWhiteSpace(_config.ReportURL);
+
+    /// <summary>
+    /// Queues the report for <paramref name="sessionDir"/> (when given) and sends
+    /// everything queued. Never throws: failures stay queued for the next run.
+    /// </summary>
+    public async Task UploadAsync(string? sessionDir, CancellationToken cancellationToken = default)
+    {
+        if (!IsEnabled)
+        {
+            return;
+        }
+
+        try
+        {
+            if (sessionDir != null && BuildPayload(sessionDir) is { } payload)
+            {
+                Enqueue(payload);
+            }
+            await FlushQueueAsync(cancellationToken);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException or JsonException)
+        {
+            ConsoleLogger.Warn($"Report upload skipped: {ex.Message}");
+        }
+    }
+
+    /// <summary>
+    /// The report for one session, or null when its session.json is missing.
+    /// </summary>
+    internal JsonObject? BuildPayload(string sessionDir)
+    {
+        var session = ReadJson(Path.Combine(sessionDir, "session.json"));
+        if (session == null)
+        {
+            return null;
+        }
+
+        var identifier = string.IsNullOrEmpty(_config.ClientIdentifier) ? Environment.MachineName : _config.ClientIdentifier;
+        return new JsonObject
+        {
+            ["schema_version"] = SchemaVersion,
+            ["machine"] = UsageReports.MachineLabel(identifier, _config.AnonymousUsageReports),
+            ["session_id"] = session["session_id"]?.GetValue<string>() ?? Path.GetFileName(sessionDir),
+            ["generated_at"] = DateTime.UtcNow.ToString("o"),
+            ["session"] = session,
+            ["inventory"] = ReadJson(Path.Combine(_reportsDir, "facts.json")),
+            ["items"] = ReadJson(Path.Combine(_reportsDir, "items.json")),
+            ["usage"] = ReadJson(Path.Combine(_reportsDir, UsageReports.FileName)),
+            ["errors"] = ReadErrorEvents(Path.Combine(sessionDir, "events.jsonl"))
+        };
+    }
+
+    private void Enqueue(JsonObject payload)
+    {
+        var name = $"{DateTime.UtcNow:yyyyMMddHHmmssfff}_{payload["session_id"]}.json";
+        var path = Path.Combine(_queueDir, string.Concat(name.Split(Path.GetInvalidFileNameChars())));
+        JsonStateFile.WriteText(path, payload.ToJsonString());
+
+        foreach (var stale in QueuedReports().SkipLast(MaxQueuedReports))
+        {
+            ConsoleLogger.Warn($"Report queue is full; dropping {Path.GetFileName(stale)}");
+            File.Delete(stale);
+        }
+    }
+
+    private IEnumerable<string> QueuedReports() =>
+        Directory.Exists(_queueDir)
+            ? Directory.GetFiles(_queueDir, "*.json").OrderBy(Path.GetFileName, StringComparer.Ordinal)
+            : [];
+
+    private async Task FlushQueueAsync(CancellationToken cancellationToken)
+    {
+        var queued = QueuedReports().ToList();
+        var sent = 0;
+        foreach (var path in queued)
+        {
+            var result = await SendAsync(File.ReadAllText(path), cancellationToken);
+            if (result == SendResult.Retry)
+            {
+                ConsoleLogger.Warn($"Report server unreachable; {queued.Count - sent} report(s) stay queued for the next run");
+                return;
+            }
+            if (result == SendResult.Rejected)
+            {
+                ConsoleLogger.Warn($"Report server rejected {Path.GetFileName(path)}; dropping it");
+            }
+            File.Delete(path);
+            sent++;
+        }
+        if (sent > 0)
+        {
+            ConsoleLogger.Detail($"    Uploaded {sent} report(s) to {_config.ReportURL}");
+        }
+    }
+
+    private enum SendResult { Sent, Rejected, Retry }
+
+    /// <summary>
+    /// POSTs one report, retrying network errors, timeouts and 5xx/408/429
+    /// answers. 401/403 keep the report queued; other 4xx answers won't improve
+    /// with retries, so the report is dropped.
+    /// </summary>
+    private async Task<SendResult> SendAsync(string json, CancellationToken cancellationToken)
+    {
+        for (var attempt = 1; attempt <= MaxAttempts; attempt++)
+        {
+            try
+            {
+                using var request = new HttpRequestMessage(HttpMethod.Post, _config.ReportURL)
+                {
+                    Content = new StringContent(json, Encoding.UTF8, "application/json")
+                };
+                if (!string.IsNullOrEmpty(_config.ReportAuthToken))
+                {
+                    request.Headers.Authorization = new AuthenticationHeaderValue("Bearer", _config.ReportAuthToken);
+                }
+
+                using var response = await _httpClient.SendAsync(request, cancellationToken);
+                if (response.IsSuccessStatusCode)
+                {
+                    return SendResult.Sent;
+                }
+                if (response.StatusCode is HttpStatusCode.Unauthorized or HttpStatusCode.Forbidden)
+                {
+                    // Credentials problems get fixed in config; keep the reports until then
+                    ConsoleLogger.Warn($"Report server refused our credentials ({(int)response.StatusCode})");
+                    return SendResult.Retry;
+                }
+                if (!MirrorFailoverHandler.IsMirrorFailure(response.StatusCode))
+                {
+                    ConsoleLogger.Detail($"    Report upload answered {(int)response.StatusCode} {response.StatusCode}");
+                    return SendResult.Rejected;
+                }
+                ConsoleLogger.Detail($"    Report upload attempt {attempt}/{MaxAttempts}: {(int)response.StatusCode} {response.StatusCode}");
+            }
+            catch (HttpRequestException ex)
+            {
+                ConsoleLogger.Detail($"    Report upload attempt {attempt}/{MaxAttempts}: {ex.Message}");
+            }
+            catch (TaskCanceledException) when (!cancellationToken.IsCancellationRequested)
+            {
+                ConsoleLogger.Detail($"    Report upload attempt {attempt}/{MaxAttempts} timed out");
+            }
+
+            if (attempt < MaxAttempts)
+            {
+                await Task.Delay(_retryDelay * attempt, cancellationToken);
+            }
+        }
+        return SendResult.Retry;
+    }
+
+    private static JsonNode? ReadJson(string path)
+    {
+        if (!File.Exists(path))
+        {
+            return null;
+        }
+        try
+        {
+            return JsonNode.Parse(File.ReadAllText(path));
+        }
+        catch (JsonException ex)
+        {
+            ConsoleLogger.Debug($"Leaving unreadable {Path.GetFileName(path)} out of the report: {ex.Message}");
+            return null;
+        }
+    }
+
+    /// <summary>ERROR events and failed actions from the session's events.jsonl.</summary>
+    private static JsonArray ReadErrorEvents(string path)
+    {
+        var errors = new JsonArray();
+        if (!File.Exists(path))
+        {
+            return errors;
+        }
+        foreach (var line in File.ReadLines(path))
+        {
+            if (string.IsNullOrWhiteSpace(line))
+            {
+                continue;
+            }
+            try
+            {
+                if (JsonNode.Parse(line) is JsonObject evt && IsError(evt))
+                {
+                    errors.Add(evt);
+                }
+            }
+            catch (JsonException)
+            {
+                // A torn last line from a crashed run; skip it
+            }
+        }
+        return errors;
+    }
+
+    private static bool IsError(JsonObject evt) =>
+        string.Equals(evt["level"]?.GetValue<string>(), "ERROR", StringComparison.OrdinalIgnoreCase)
+        || string.Equals(evt["status"]?.GetValue<string>(), "failed", StringComparison.OrdinalIgnoreCase);
+}
@@ -0,0 +1,268 @@
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core;
+using Cimian.Core.Services;
+using Microsoft.Win32;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Per-item rollback state kept under <see cref="CimianPaths.RollbackDir"/>.
+/// <c>Installed</c> is the pkginfo of whatever Cimian last installed; when an
+/// update is about to replace it, that becomes a snapshot together with the
+/// ManagedInstalls receipt and the cached installer path, so
+/// <c>--rollback</c> can put the previous version back later.
+/// </summary>
+public class RollbackState
+{
+    [JsonPropertyName("item_name")]
+    public string ItemName { get; set; } = "";
+
+    /// <summary>YAML of the catalog item currently installed.</summary>
+    [JsonPropertyName("installed_item")]
+    public string? InstalledItem { get; set; }
+
+    [JsonPropertyName("installed_installer")]
+    public string? InstalledInstaller { get; set; }
+
+    /// <summary>Newest last.</summary>
+    [JsonPropertyName("snapshots")]
+    public List<RollbackSnapshot> Snapshots { get; set; } = new();
+
+    [JsonPropertyName("history")]
+    public List<RollbackHistoryEntry> History { get; set; } = new();
+}
+
+public class RollbackSnapshot
+{
+    [JsonPropertyName("version")]
+    public string Version { get; set; } = "";
+
+    [JsonPropertyName("replaced_by")]
+    public string ReplacedBy { get; set; } = "";
+
+    [JsonPropertyName("taken_at")]
+    public DateTime TakenAt { get; set; }
+
+    [JsonPropertyName("product_code")]
+    public string? ProductCode { get; set; }
+
+    /// <summary>Values of HKLM\SOFTWARE\ManagedInstalls\&lt;item&gt; at snapshot time.</summary>
+    [JsonPropertyName("receipt")]
+    public Dictionary<string, string> Receipt { get; set; } = new();
+
+    [JsonPropertyName("cached_installer")]
+    public string? CachedInstaller { get; set; }
+
+    /// <summary>YAML of the catalog item for this version; null when Cimian never recorded it.</summary>
+    [JsonPropertyName("catalog_item")]
+    public string? CatalogItem { get; set; }
+}
+
+public class RollbackHistoryEntry
+{
+    [JsonPropertyName("timestamp")]
+    public DateTime Timestamp { get; set; }
+
+    [JsonPropertyName("from_version")]
+    public string FromVersion { get; set; } = "";
+
+    [JsonPropertyName("to_version")]
+    public string ToVersion { get; set; } = "";
+
+    [JsonPropertyName("status")]
+    public string Status { get; set; } = "";
+
+    [JsonPropertyName("source")]
+    public string? Source { get; set; }
+
+    [JsonPropertyName("message")]
+    public string? Message { get; set; }
+
+    [JsonPropertyName("session_id")]
+    public string? SessionId { get; set; }
+}
+
+/// <summary>
+/// Snapshots the installed version of an item before an update replaces it and
+/// hands the snapshot back for <c>managedsoftwareupdate --rollback</c>.
+/// </summary>
+public class RollbackService
+{
+    /// <summary>Versions kept per item; older snapshots are dropped.</summary>
+    public const int MaxSnapshotsPerItem = 3;
+    private const int MaxHistoryEntries = 20;
+
+    private readonly string _rootDir;
+
+    public RollbackService(string? rootDir = null)
+    {
+        _rootDir = rootDir ?? CimianPaths.RollbackDir;
+    }
+
+    public string GetStatePath(string itemName) =>
+        Path.Combine(_rootDir, $"{ItemKey.Canonical(itemName)}.json");
+
+    public RollbackState Load(string itemName)
+    {
+        var path = GetStatePath(itemName);
+        try
+        {
+            if (File.Exists(path))
+            {
+                var state = JsonSerializer.Deserialize<RollbackState>(File.ReadAllText(path));
+                if (state != null)
+                {
+                    return state;
+                }
+            }
+        }
+        catch (Exception ex) when (ex is IOException or JsonException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not read rollback state for {itemName}: {ex.Message}");
+        }
+        return new RollbackState { ItemName = itemName };
+    }
+
+    public void Save(RollbackState state)
+    {
+        JsonStateFile.Write(GetStatePath(state.ItemName), state);
+    }
+
+    /// <summary>
+    /// Records the pkginfo just installed so the next update can snapshot it.
+    /// </summary>
+    public void RecordInstalled(CatalogItem item, string? installerPath)
+    {
+        var state = Load(item.Name);
+        state.ItemName = item.Name;
+        state.InstalledItem = YamlUtils.Serializer.Serialize(item);
+        state.InstalledInstaller = string.IsNullOrEmpty(installerPath) ? null : installerPath;
+        Save(state);
+    }
+
+    /// <summary>
+    /// Snapshots <paramref name="installedVersion"/> before <paramref name="incoming"/>
+    /// replaces it. The previous pkginfo and installer come from the last
+    /// <see cref="RecordInstalled"/> when its version matches; otherwise only the
+    /// receipt is kept and rollback will need that version in the catalog cache.
+    /// </summary>
+    public RollbackSnapshot? TakeSnapshot(CatalogItem incoming, string installedVersion,
+        IReadOnlyDictionary<string, string>? receipt = null)
+    {
+        if (string.IsNullOrEmpty(installedVersion)
+            || CatalogService.CompareVersions(installedVersion, incoming.Version) == 0)
+        {
+            return null;
+        }
+
+        var state = Load(incoming.Name);
+        state.ItemName = incoming.Name;
+
+        var previous = DeserializeItem(state.InstalledItem);
+        var matches = previous != null && CatalogService.CompareVersions(previous.Version, installedVersion) == 0;
+
+        var snapshot = new RollbackSnapshot
+        {
+            Version = installedVersion,
+            ReplacedBy = incoming.Version,
+            TakenAt = DateTime.Now,
+            Receipt = receipt != null ? new Dictionary<string, string>(receipt) : new(),
+            CatalogItem = matches ? state.InstalledItem : null,
+            CachedInstaller = matches ? state.InstalledInstaller : null,
+            ProductCode = matches ? GetProductCode(previous!) : null
+        };
+
+        // Re-snapshotting the same version (an update retried after a failure) replaces it
+        state.Snapshots.RemoveAll(s => CatalogService.CompareVersions(s.Version, installedVersion) == 0);
+        state.Snapshots.Add(snapshot);
+        if (state.Snapshots.Count > MaxSnapshotsPerItem)
+        {
+            state.Snapshots.RemoveRange(0, state.Snapshots.Count - MaxSnapshotsPerItem);
+        }
+
+        Save(state);
+        return snapshot;
+    }
+
+    public RollbackSnapshot? GetLatestSnapshot(string itemName) => Load(itemName).Snapshots.LastOrDefault();
+
+    /// <summary>
+    /// Logs a rollback attempt; on success the snapshot is consumed and the
+    /// restored version becomes the installed one.
+    /// </summary>
+    public void RecordRollback(string itemName, RollbackSnapshot snapshot, string fromVersion, bool success,
+        string? source, string? message, string? sessionId)
+    {
+        var state = Load(itemName);
+        state.ItemName = itemName;
+        state.History.Add(new RollbackHistoryEntry
+        {
+            Timestamp = DateTime.Now,
+            FromVersion = fromVersion,
+            ToVersion = snapshot.Version,
+            Status = success ? "completed" : "failed",
+            Source = source,
+            Message = message,
+            SessionId = sessionId
+        });
+        if (state.History.Count > MaxHistoryEntries)
+        {
+            state.History.RemoveRange(0, state.History.Count - MaxHistoryEntries);
+        }
+
+        if (success)
+        {
+            state.Snapshots.RemoveAll(s => CatalogService.CompareVersions(s.Version, snapshot.Version) == 0);
+            state.InstalledItem = snapshot.CatalogItem;
+            state.InstalledInstaller = snapshot.CachedInstaller;
+        }
+        Save(state);
+    }
+
+    public static CatalogItem? DeserializeItem(string? yaml)
+    {
+        if (string.IsNullOrWhiteSpace(yaml))
+        {
+            return null;
+        }
+        try
+        {
+            return YamlUtils.Deserializer.Deserialize<CatalogItem>(yaml);
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Debug($"Rollback snapshot item unreadable: {ex.Message}");
+            return null;
+        }
+    }
+
+    private static string? GetProductCode(CatalogItem item) =>
+        item.Installs.FirstOrDefault(i => i.EffectiveType() == "msi" && !string.IsNullOrEmpty(i.ProductCode))?.ProductCode
+        ?? item.Installer?.ProductCode;
+
+    /// <summary>
+    /// Current ManagedInstalls receipt values for an item.
+    /// </summary>
+    public static Dictionary<string, string> ReadReceipt(string itemName)
+    {
+        var values = new Dictionary<string, string>(StringComparer.OrdinalIgnoreCase);
+        try
+        {
+            using var key = Registry.LocalMachine.OpenSubKey($@"SOFTWARE\ManagedInstalls\{itemName}");
+            if (key != null)
+            {
+                foreach (var name in key.GetValueNames())
+                {
+                    values[name] = key.GetValue(name)?.ToString() ?? "";
+                }
+            }
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Debug($"Could not read receipt for {itemName}: {ex.Message}");
+        }
+        return values;
+    }
+}
@@ -0,0 +1,182 @@
+using System.Globalization;
+using System.Security.Cryptography;
+using System.Text;
+using System.Text.RegularExpressions;
+using Amazon.Runtime;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+internal sealed record S3Credentials(string AccessKey, string SecretKey, string? SessionToken);
+
+/// <summary>
+/// Signs repo requests with AWS Signature Version 4 (RepoBackend: s3), so
+/// SoftwareRepoURL can point straight at a bucket such as
+/// https://bucket.s3.eu-west-1.amazonaws.com/cimian. Credentials come from the
+/// AWS SDK's default chain: environment variables, the shared credentials file,
+/// or the EC2 instance profile. Conditional GETs and ranged resumes keep working
+/// because S3 honors the same headers as any web server.
+/// </summary>
+internal sealed class S3SigningHandler : DelegatingHandler
+{
+    private const string Algorithm = "AWS4-HMAC-SHA256";
+    private const string Service = "s3";
+    private static readonly string EmptyPayloadHash = Convert.ToHexString(SHA256.HashData([])).ToLowerInvariant();
+    private static readonly Regex RegionInHost = new(@"(?:^|\.)s3[.-](?:dualstack\.)?([a-z0-9-]+)\.amazonaws\.com$", RegexOptions.IgnoreCase);
+
+    private readonly HashSet<string> _repoHosts;
+    private readonly string? _configuredRegion;
+    private readonly Func<CancellationToken, Task<S3Credentials>> _credentials;
+
+    /// <param name="repoHosts">Only requests to these hosts (the repo and its mirrors) are signed; installers on other origins go out untouched.</param>
+    public S3SigningHandler(IEnumerable<string> repoHosts, string? region, Func<CancellationToken, Task<S3Credentials>>? credentials = null)
+    {
+        _repoHosts = new HashSet<string>(repoHosts, StringComparer.OrdinalIgnoreCase);
+        _configuredRegion = region;
+        _credentials = credentials ?? ResolveSdkCredentialsAsync;
+    }
+
+    protected override async Task<HttpResponseMessage> SendAsync(HttpRequestMessage request, CancellationToken cancellationToken)
+    {
+        if (request.RequestUri == null || !_repoHosts.Contains(request.RequestUri.Host))
+        {
+            return await base.SendAsync(request, cancellationToken).ConfigureAwait(false);
+        }
+
+        var credentials = await _credentials(cancellationToken).ConfigureAwait(false);
+        Sign(request, credentials, ResolveRegion(request.RequestUri!, _configuredRegion), DateTime.UtcNow);
+        return await base.SendAsync(request, cancellationToken).ConfigureAwait(false);
+    }
+
+    /// <summary>
+    /// RepoRegion when set, else the region in an amazonaws.com host name,
+    /// else us-east-1 (the global s3.amazonaws.com endpoint).
+    /// </summary>
+    internal static string ResolveRegion(Uri uri, string? configured)
+    {
+        if (!string.IsNullOrWhiteSpace(configured))
+        {
+            return configured.Trim();
+        }
+        var match = RegionInHost.Match(uri.Host);
+        return match.Success && !match.Groups[1].Value.Equals("external-1", StringComparison.OrdinalIgnoreCase)
+            ? match.Groups[1].Value.ToLowerInvariant()
+            : "us-east-1";
+    }
+
+    /// <summary>
+    /// Adds x-amz-date, x-amz-content-sha256, x-amz-security-token (for
+    /// temporary credentials) and the Authorization header. Only those and Host
+    /// are signed, so headers added later (Range, If-None-Match) don't break it.
+    /// Repo requests carry no body.
+    /// </summary>
+    internal static void Sign(HttpRequestMessage request, S3Credentials credentials, string region, DateTime utcNow)
+    {
+        var uri = request.RequestUri!;
+        var amzDate = utcNow.ToString("yyyyMMdd'T'HHmmss'Z'", CultureInfo.InvariantCulture);
+        var date = amzDate[..8];
+        var host = uri.IsDefaultPort ? uri.Host : $"{uri.Host}:{uri.Port}";
+
+        request.Headers.Remove("x-amz-date");
+        request.Headers.Remove("x-amz-content-sha256");
+        request.Headers.Remove("x-amz-security-token");
+        request.Headers.TryAddWithoutValidation("x-amz-date", amzDate);
+        request.Headers.TryAddWithoutValidation("x-amz-content-sha256", EmptyPayloadHash);
+
+        var headers = new SortedDictionary<string, string>(StringComparer.Ordinal)
+        {
+            ["host"] = host,
+            ["x-amz-content-sha256"] = EmptyPayloadHash,
+            ["x-amz-date"] = amzDate,
+        };
+        if (!string.IsNullOrEmpty(credentials.SessionToken))
+        {
+            request.Headers.TryAddWithoutValidation("x-amz-security-token", credentials.SessionToken);
+            headers["x-amz-security-token"] = credentials.SessionToken;
+        }
+
+        var signedHeaders = string.Join(";", headers.Keys);
+        var canonicalRequest = string.Join("\n",
+            request.Method.Method,
+            CanonicalPath(uri),
+            CanonicalQuery(uri),
+            string.Concat(headers.Select(h => $"{h.Key}:{h.Value}\n")),
+            signedHeaders,
+            EmptyPayloadHash);
+
+        var scope = $"{date}/{region}/{Service}/aws4_request";
+        var stringToSign = string.Join("\n", Algorithm, amzDate, scope, Hex(SHA256.HashData(Encoding.UTF8.GetBytes(canonicalRequest))));
+
+        var key = Hmac(Encoding.UTF8.GetBytes("AWS4" + credentials.SecretKey), date);
+        key = Hmac(key, region);
+        key = Hmac(key, Service);
+        key = Hmac(key, "aws4_request");
+        var signature = Hex(Hmac(key, stringToSign));
+
+        request.Headers.Remove("Authorization");
+        request.Headers.TryAddWithoutValidation("Authorization",
+            $"{Algorithm} Credential={credentials.AccessKey}/{scope}, SignedHeaders={signedHeaders}, Signature={signature}");
+    }
+
+    /// <summary>Each path segment decoded and re-encoded per RFC 3986, as S3 expects.</summary>
+    internal static string CanonicalPath(Uri uri)
+    {
+        var segments = uri.AbsolutePath.Split('/').Select(s => Encode(Uri.UnescapeDataString(s)));
+        var path = string.Join("/", segments);
+        return path.Length == 0 ? "/" : path;
+    }
+
+    private static string CanonicalQuery(Uri uri)
+    {
+        if (string.IsNullOrEmpty(uri.Query) || uri.Query == "?")
+        {
+            return string.Empty;
+        }
+        return string.Join("&", uri.Query.TrimStart('?').Split('&', StringSplitOptions.RemoveEmptyEntries)
+            .Select(pair =>
+            {
+                var eq = pair.IndexOf('=');
+                var name = Uri.UnescapeDataString(eq < 0 ? pair : pair[..eq]);
+                var value = eq < 0 ? string.Empty : Uri.UnescapeDataString(pair[(eq + 1)..]);
+                return (Name: Encode(name), Value: Encode(value));
+            })
+            .OrderBy(p => p.Name, StringComparer.Ordinal)
+            .ThenBy(p => p.Value, StringComparer.Ordinal)
+            .Select(p => $"{p.Name}={p.Value}"));
+    }
+
+    private static string Encode(string value)
+    {
+        var builder = new StringBuilder();
+        foreach (var b in Encoding.UTF8.GetBytes(value))
+        {
+            var c = (char)b;
+            if (c is (>= 'A' and <= 'Z') or (>= 'a' and <= 'z') or (>= '0' and <= '9') or '-' or '_' or '.' or '~')
+            {
+                builder.Append(c);
+            }
+            else
+            {
+                builder.Append('%').Append(b.ToString("X2", CultureInfo.InvariantCulture));
+            }
+        }
+        return builder.ToString();
+    }
+
+    private static byte[] Hmac(byte[] key, string data) => HMACSHA256.HashData(key, Encoding.UTF8.GetBytes(data));
+
+    private static string Hex(byte[] bytes) => Convert.ToHexString(bytes).ToLowerInvariant();
+
+    private static async Task<S3Credentials> ResolveSdkCredentialsAsync(CancellationToken cancellationToken)
+    {
+        try
+        {
+            var credentials = await FallbackCredentialsFactory.GetCredentials().GetCredentialsAsync().ConfigureAwait(false);
+            return new S3Credentials(credentials.AccessKey, credentials.SecretKey, credentials.UseToken ? credentials.Token : null);
+        }
+        catch (AmazonClientException ex)
+        {
+            // Surface like any other transport failure so callers' repo error handling applies
+            throw new HttpRequestException($"No AWS credentials for the S3 repo: {ex.Message}", ex);
+        }
+    }
+}
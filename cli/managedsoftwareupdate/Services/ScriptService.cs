@@ -2,16 +2,132 @@ using System.Diagnostics;
 using System.Management.Automation;
 using System.Text;
 using System.Text.RegularExpressions;
+using Cimian.CLI.managedsoftwareupdate.Models;
 using Cimian.Core;
 using Cimian.Core.Services;
+using Cimian.Core.Version;
 
 namespace Cimian.CLI.managedsoftwareupdate.Services;
 
 /// <summary>
 /// Richer result from script execution. Used by callers that need to surface
 /// a Warning outcome via the CIMIAN-WARNING marker convention.
+/// StandardOutput and StandardError are the streams as captured (as-user runs
+/// only have the combined output) for script_run events.
 /// </summary>
-public record ScriptResult(bool Success, int ExitCode, string Output, string? WarningMessage);
+public record ScriptResult(
+    bool Success,
+    int ExitCode,
+    string Output,
+    string? WarningMessage,
+    bool TimedOut = false,
+    string? StandardOutput = null,
+    string? StandardError = null,
+    TimeSpan Duration = default);
+
+/// <summary>
+/// The kinds of admin-authored script, as keyed in ScriptPolicies and recorded
+/// on script_run events.
+/// </summary>
+public static class ScriptKind
+{
+    public const string Preflight = "preflight";
+    public const string Postflight = "postflight";
+    public const string Preinstall = "preinstall";
+    public const string Postinstall = "postinstall";
+    public const string Preuninstall = "preuninstall";
+    public const string Postuninstall = "postuninstall";
+    public const string Install = "install";
+    public const string Uninstall = "uninstall";
+
+    /// <summary>installcheck_script, version_script and check.script.</summary>
+    public const string Installcheck = "installcheck";
+
+    public static readonly string[] All =
+        [Preflight, Postflight, Preinstall, Postinstall, Preuninstall, Postuninstall, Install, Uninstall, Installcheck];
+}
+
+/// <summary>
+/// What a failing script does: fail the install/uninstall (or abort the run),
+/// warn and carry on, or carry on quietly.
+/// </summary>
+public static class ScriptFailureAction
+{
+    public const string Fail = "fail";
+    public const string Warn = "warn";
+    public const string Ignore = "ignore";
+
+    public static readonly string[] All = [Fail, Warn, Ignore];
+}
+
+/// <summary>A ScriptPolicies entry with the defaults filled in.</summary>
+public sealed record ResolvedScriptPolicy(TimeSpan Timeout, bool ConstrainedLanguage, string OnFailure);
+
+/// <summary>
+/// The Config.yaml settings every admin-authored script runs under: ScriptTimeout,
+/// the installer timeout (install and uninstall scripts), ScriptConstrainedLanguage and
+/// the per-kind ScriptPolicies overrides.
+/// </summary>
+public sealed class ScriptSettings
+{
+    // pre* scripts gate the step after them; post* scripts only report on it
+    private static readonly Dictionary<string, string> DefaultFailureActions = new(StringComparer.OrdinalIgnoreCase)
+    {
+        [ScriptKind.Preflight] = ScriptFailureAction.Warn,
+        [ScriptKind.Postflight] = ScriptFailureAction.Warn,
+        [ScriptKind.Preinstall] = ScriptFailureAction.Fail,
+        [ScriptKind.Postinstall] = ScriptFailureAction.Warn,
+        [ScriptKind.Preuninstall] = ScriptFailureAction.Fail,
+        [ScriptKind.Postuninstall] = ScriptFailureAction.Warn,
+        [ScriptKind.Install] = ScriptFailureAction.Fail,
+        [ScriptKind.Uninstall] = ScriptFailureAction.Fail,
+        [ScriptKind.Installcheck] = ScriptFailureAction.Ignore
+    };
+
+    private readonly int _scriptTimeout;
+    private readonly int _installerTimeout;
+    private readonly bool _constrainedLanguage;
+    private readonly Dictionary<string, ScriptPolicy> _policies;
+
+    /// <summary>No timeouts, full language mode, default failure actions.</summary>
+    public static ScriptSettings Default { get; } = new(0, 0, false, null);
+
+    private ScriptSettings(int scriptTimeout, int installerTimeout, bool constrainedLanguage, Dictionary<string, ScriptPolicy>? policies)
+    {
+        _scriptTimeout = scriptTimeout;
+        _installerTimeout = installerTimeout;
+        _constrainedLanguage = constrainedLanguage;
+        _policies = new Dictionary<string, ScriptPolicy>(policies ?? new Dictionary<string, ScriptPolicy>(), StringComparer.OrdinalIgnoreCase);
+    }
+
+    public static ScriptSettings From(CimianConfig config) =>
+        new(config.ScriptTimeout, (int)config.EffectiveInstallerTimeout.TotalSeconds, config.ScriptConstrainedLanguage, config.ScriptPolicies);
+
+    /// <summary>
+    /// The policy for <paramref name="kind"/>: its ScriptPolicies entry where set,
+    /// otherwise the installer timeout for install/uninstall scripts and ScriptTimeout
+    /// for the rest. A timeout of 0 means none.
+    /// </summary>
+    public ResolvedScriptPolicy Resolve(string kind)
+    {
+        _policies.TryGetValue(kind, out var policy);
+        var defaultTimeout = kind is ScriptKind.Install or ScriptKind.Uninstall ? _installerTimeout : _scriptTimeout;
+        var seconds = policy?.Timeout ?? defaultTimeout;
+        var onFailure = policy?.OnFailure?.ToLowerInvariant() is { } action && ScriptFailureAction.All.Contains(action)
+            ? action
+            : DefaultFailureActions.GetValueOrDefault(kind, ScriptFailureAction.Warn);
+
+        return new ResolvedScriptPolicy(
+            seconds > 0 ? TimeSpan.FromSeconds(seconds) : Timeout.InfiniteTimeSpan,
+            policy?.ConstrainedLanguage ?? _constrainedLanguage,
+            onFailure);
+    }
+}
+
+/// <summary>
+/// Facts about the current run that every script gets as CIMIAN_* environment variables.
+/// </summary>
+public record ScriptSessionInfo(string SessionId, string RunType, string CachePath, string RepoUrl);
 
 /// <summary>
 /// Service for executing PowerShell scripts
@@ -19,6 +135,87 @@ public record ScriptResult(bool Success, int ExitCode, string Output, string? Wa
 /// </summary>
 public class ScriptService
 {
+    /// <summary>
+    /// Set by UpdateEngine once the session starts. Scripts run outside a session
+    /// still get the machine variables, just not the session ones.
+    /// </summary>
+    public static ScriptSessionInfo? Session { get; set; }
+
+    /// <summary>
+    /// Timeouts, language mode and failure actions for admin-authored scripts.
+    /// Set by UpdateEngine from Config.yaml; outside a session nothing is limited.
+    /// </summary>
+    public static ScriptSettings Settings { get; set; } = ScriptSettings.Default;
+
+    /// <summary>
+    /// Where <see cref="RunAsync"/> and <see cref="RunFileAsync"/> record their
+    /// script_run events. Set by UpdateEngine once the session logger exists.
+    /// </summary>
+    public static SessionLogger? EventLogger { get; set; }
+
+    private CatalogItem? _item;
+
+    /// <summary>
+    /// A runner whose scripts also get CIMIAN_ITEM_NAME and CIMIAN_ITEM_VERSION,
+    /// so pre/post/check scripts know which item they belong to.
+    /// </summary>
+    public ScriptService ForItem(CatalogItem item) => new() { _item = item };
+
+    private bool _asUser;
+
+    /// <summary>
+    /// A runner whose scripts run in the console user's session rather than as
+    /// SYSTEM, for install_context: user items.
+    /// </summary>
+    public ScriptService AsActiveUser() => new() { _item = _item, _asUser = true, _environment = _environment };
+
+    private IReadOnlyDictionary<string, string>? _environment;
+
+    /// <summary>
+    /// A runner whose scripts also get <paramref name="environment"/>, for
+    /// installers whose scripts expect variables of their own.
+    /// </summary>
+    public ScriptService WithEnvironment(IReadOnlyDictionary<string, string> environment) =>
+        new() { _item = _item, _asUser = _asUser, _environment = environment };
+
+    /// <summary>
+    /// The CIMIAN_* variables a script runs with: machine facts always, session
+    /// facts once a session has started, item facts when run for an item.
+    /// </summary>
+    public static Dictionary<string, string> BuildEnvironment(ScriptSessionInfo? session, CatalogItem? item)
+    {
+        var env = new Dictionary<string, string>
+        {
+            ["CIMIAN_ARCH"] = CatalogService.GetSystemArchitecture(),
+            ["CIMIAN_OS_BUILD"] = VersionService.GetCurrentOsVersion()
+        };
+        if (session != null)
+        {
+            env["CIMIAN_SESSION_ID"] = session.SessionId;
+            env["CIMIAN_RUN_TYPE"] = session.RunType;
+            env["CIMIAN_CACHE_PATH"] = session.CachePath;
+            env["CIMIAN_REPO_URL"] = session.RepoUrl;
+        }
+        if (item != null)
+        {
+            env["CIMIAN_ITEM_NAME"] = item.Name;
+            env["CIMIAN_ITEM_VERSION"] = item.Version;
+        }
+        return env;
+    }
+
+    private void ApplyEnvironment(ProcessStartInfo startInfo)
+    {
+        foreach (var (name, value) in BuildEnvironment(Session, _item))
+        {
+            startInfo.Environment[name] = value;
+        }
+        foreach (var (name, value) in _environment ?? new Dictionary<string, string>())
+        {
+            startInfo.Environment[name] = value;
+        }
+    }
+
     // Postinstall scripts may emit a line of the form:
     //   CIMIAN-WARNING: <message>
     // on stdout or stderr. The runner extracts the message into ScriptResult.WarningMessage,
@@ -50,8 +247,9 @@ public class ScriptService
     }
 
     /// <summary>
-    /// Executes a PowerShell script from string content using in-process SDK
-    /// Note: This method has limitations with exit codes - use ExecuteScriptWithExitCodeAsync for scripts that rely on exit codes
+    /// Executes a PowerShell script from string content using external process
+    /// (see <see cref="ExecuteScriptWithExitCodeAsync"/>). For Cimian's own
+    /// generated scripts; admin-authored scripts go through <see cref="RunAsync"/>.
     /// </summary>
     public async Task<(bool Success, string Output)> ExecuteScriptAsync(
         string scriptContent,
@@ -75,78 +273,8 @@ public class ScriptService
         string scriptContent,
         CancellationToken cancellationToken = default)
     {
-        if (string.IsNullOrWhiteSpace(scriptContent))
-        {
-            return (true, "No script content to execute");
-        }
-
-        // Find PowerShell executable (prefer pwsh over powershell)
-        var psExe = FindPowerShellExecutable();
-        if (string.IsNullOrEmpty(psExe))
-        {
-            return (false, "Neither pwsh.exe nor powershell.exe was found");
-        }
-
-        try
-        {
-            var startInfo = new ProcessStartInfo
-            {
-                FileName = psExe,
-                UseShellExecute = false,
-                RedirectStandardOutput = true,
-                RedirectStandardError = true,
-                RedirectStandardInput = false,
-                CreateNoWindow = true,
-            };
-
-            // Use -Command to execute inline script, properly escaping
-            // -Command interprets the rest as a PowerShell command
-            startInfo.ArgumentList.Add("-NoLogo");
-            startInfo.ArgumentList.Add("-NoProfile");
-            startInfo.ArgumentList.Add("-ExecutionPolicy");
-            startInfo.ArgumentList.Add("Bypass");
-            startInfo.ArgumentList.Add("-Command");
-            startInfo.ArgumentList.Add(scriptContent);
-
-            using var process = new Process { StartInfo = startInfo };
-            var output = new StringBuilder();
-            var errors = new StringBuilder();
-
-            process.OutputDataReceived += (sender, e) =>
-            {
-                if (e.Data != null)
-                {
-                    output.AppendLine(e.Data);
-                }
-            };
-
-            process.ErrorDataReceived += (sender, e) =>
-            {
-                if (e.Data != null)
-                {
-                    errors.AppendLine(e.Data);
-                }
-            };
-
-            process.Start();
-            process.BeginOutputReadLine();
-            process.BeginErrorReadLine();
-
-            await process.WaitForExitAsync(cancellationToken);
-
-            var combinedOutput = output.ToString();
-            if (errors.Length > 0)
-            {
-                combinedOutput += Environment.NewLine + errors.ToString();
-            }
-
-            // Exit code 0 = success, non-zero = failure
-            return (process.ExitCode == 0, combinedOutput);
-        }
-        catch (Exception ex)
-        {
-            return (false, $"Script execution failed: {ex.Message}");
-        }
+        var result = await ExecuteScriptWithDetailsAsync(scriptContent, cancellationToken);
+        return (result.Success, result.Output);
     }
 
     /// <summary>
@@ -165,69 +293,74 @@ public class ScriptService
             return new ScriptResult(Success: true, ExitCode: 0, Output: "No script content to execute", WarningMessage: null);
         }
 
-        var psExe = FindPowerShellExecutable();
-        if (string.IsNullOrEmpty(psExe))
-        {
-            return new ScriptResult(Success: false, ExitCode: -1, Output: "Neither pwsh.exe nor powershell.exe was found", WarningMessage: null);
-        }
+        return await RunProcessAsync(ScriptSource.Inline(scriptContent), Timeout.InfiniteTimeSpan, constrainedLanguage: false, cancellationToken);
+    }
 
-        try
+    /// <summary>
+    /// Runs an admin-authored <paramref name="kind"/> script (preinstall_script,
+    /// installcheck_script, ...) under its ScriptPolicies timeout and language
+    /// mode, and records a script_run event with its full stdout and stderr.
+    /// What a failure means is up to the caller; see <see cref="FailureActionFor"/>.
+    /// </summary>
+    public async Task<ScriptResult> RunAsync(
+        string kind,
+        string scriptContent,
+        CancellationToken cancellationToken = default)
+    {
+        if (string.IsNullOrWhiteSpace(scriptContent))
         {
-            var startInfo = new ProcessStartInfo
-            {
-                FileName = psExe,
-                UseShellExecute = false,
-                RedirectStandardOutput = true,
-                RedirectStandardError = true,
-                RedirectStandardInput = false,
-                CreateNoWindow = true,
-            };
-
-            startInfo.ArgumentList.Add("-NoLogo");
-            startInfo.ArgumentList.Add("-NoProfile");
-            startInfo.ArgumentList.Add("-ExecutionPolicy");
-            startInfo.ArgumentList.Add("Bypass");
-            startInfo.ArgumentList.Add("-Command");
-            startInfo.ArgumentList.Add(scriptContent);
-
-            using var process = new Process { StartInfo = startInfo };
-            var output = new StringBuilder();
-            var errors = new StringBuilder();
-
-            process.OutputDataReceived += (sender, e) =>
-            {
-                if (e.Data != null) output.AppendLine(e.Data);
-            };
-
-            process.ErrorDataReceived += (sender, e) =>
-            {
-                if (e.Data != null) errors.AppendLine(e.Data);
-            };
-
-            process.Start();
-            process.BeginOutputReadLine();
-            process.BeginErrorReadLine();
-
-            await process.WaitForExitAsync(cancellationToken);
-
-            var combinedOutput = output.ToString();
-            if (errors.Length > 0)
-            {
-                combinedOutput += Environment.NewLine + errors.ToString();
-            }
+            return new ScriptResult(Success: true, ExitCode: 0, Output: "No script content to execute", WarningMessage: null);
+        }
 
-            var exitCode = process.ExitCode;
-            var warningMessage = ExtractWarningMarker(combinedOutput);
-            var success = exitCode == 0;
+        var policy = PolicyFor(kind);
+        var result = await RunProcessAsync(ScriptSource.Inline(scriptContent), policy.Timeout, policy.ConstrainedLanguage, cancellationToken);
+        LogScriptRun(kind, $"{kind}_script", result, policy, context: null);
+        return result;
+    }
 
-            return new ScriptResult(success, exitCode, combinedOutput, warningMessage);
-        }
-        catch (Exception ex)
+    /// <summary>
+    /// Runs an admin-authored script file like <see cref="RunAsync"/>.
+    /// <paramref name="timeout"/> overrides the ScriptPolicies timeout, and
+    /// <paramref name="context"/> is added to the script_run event.
+    /// </summary>
+    public async Task<ScriptResult> RunFileAsync(
+        string kind,
+        string scriptPath,
+        TimeSpan? timeout = null,
+        IReadOnlyDictionary<string, object>? context = null,
+        CancellationToken cancellationToken = default)
+    {
+        var policy = PolicyFor(kind);
+        if (timeout != null)
         {
-            return new ScriptResult(Success: false, ExitCode: -1, Output: $"Script execution failed: {ex.Message}", WarningMessage: null);
+            policy = policy with { Timeout = timeout.Value };
         }
+
+        var result = File.Exists(scriptPath)
+            ? await RunProcessAsync(ScriptSource.FromFile(scriptPath), policy.Timeout, policy.ConstrainedLanguage, cancellationToken)
+            : new ScriptResult(Success: false, ExitCode: -1, Output: $"Script file not found: {scriptPath}", WarningMessage: null);
+        LogScriptRun(kind, scriptPath, result, policy, context);
+        return result;
+    }
+
+    /// <summary>
+    /// <see cref="ScriptSettings.Resolve"/>, with the item's installer_timeout
+    /// for its install and uninstall scripts.
+    /// </summary>
+    private ResolvedScriptPolicy PolicyFor(string kind)
+    {
+        var policy = Settings.Resolve(kind);
+        return kind is ScriptKind.Install or ScriptKind.Uninstall && _item?.InstallerTimeout is > 0
+            ? policy with { Timeout = TimeSpan.FromMinutes(_item.InstallerTimeout.Value) }
+            : policy;
     }
 
+    /// <summary>
+    /// What a failing <paramref name="kind"/> script does to the run: fail, warn or
+    /// ignore (<see cref="ScriptFailureAction"/>).
+    /// </summary>
+    public static string FailureActionFor(string kind) => Settings.Resolve(kind).OnFailure;
+
     /// <summary>
     /// Executes a PowerShell script from string content using in-process SDK
     /// Note: This method does NOT properly handle exit codes - only use for simple scripts without exit statements
@@ -289,19 +422,88 @@ public class ScriptService
     public async Task<(bool Success, string Output)> ExecuteScriptFileAsync(
         string scriptPath,
         CancellationToken cancellationToken = default)
+    {
+        var result = await ExecuteScriptFileWithDetailsAsync(scriptPath, Timeout.InfiniteTimeSpan, cancellationToken);
+        return (result.Success, result.Output);
+    }
+
+    /// <summary>
+    /// Executes a PowerShell script file like <see cref="ExecuteScriptFileAsync"/>,
+    /// stopping it (and anything it started) once <paramref name="timeout"/> passes.
+    /// </summary>
+    public async Task<ScriptResult> ExecuteScriptFileWithDetailsAsync(
+        string scriptPath,
+        TimeSpan timeout,
+        CancellationToken cancellationToken = default)
     {
         if (!File.Exists(scriptPath))
         {
-            return (false, $"Script file not found: {scriptPath}");
+            return new ScriptResult(Success: false, ExitCode: -1, Output: $"Script file not found: {scriptPath}", WarningMessage: null);
         }
 
+        return await RunProcessAsync(ScriptSource.FromFile(scriptPath), timeout, constrainedLanguage: false, cancellationToken);
+    }
+
+    // What to run: inline content (-Command) or a file (-File). Files keep
+    // $PSCommandPath and stream their output to the console as they run.
+    private sealed record ScriptSource(string? Content, string? Path)
+    {
+        public static ScriptSource Inline(string content) => new(content, null);
+        public static ScriptSource FromFile(string path) => new(null, path);
+    }
+
+    // Setting LanguageMode is one-way: nothing the script does can lift it again
+    private const string ConstrainedLanguagePrelude = "$ExecutionContext.SessionState.LanguageMode = 'ConstrainedLanguage'";
+
+    /// <summary>
+    /// The powershell arguments for inline <paramref name="content"/> or the file at
+    /// <paramref name="path"/>. A constrained file is invoked after the prelude
+    /// via -Command, since -File can't take one.
+    /// </summary>
+    internal static List<string> BuildArguments(string? content, string? path, bool constrainedLanguage)
+    {
+        var args = new List<string> { "-NoLogo", "-NoProfile", "-ExecutionPolicy", "Bypass" };
+        if (path != null && !constrainedLanguage)
+        {
+            args.Add("-File");
+            args.Add(path);
+        }
+        else if (path != null)
+        {
+            args.Add("-Command");
+            args.Add($"{ConstrainedLanguagePrelude}; & '{path.Replace("'", "''")}'; exit $LASTEXITCODE");
+        }
+        else
+        {
+            // -Command interprets the rest as a PowerShell command
+            args.Add("-Command");
+            args.Add(constrainedLanguage ? $"{ConstrainedLanguagePrelude}{Environment.NewLine}{content}" : content!);
+        }
+        return args;
+    }
+
+    /// <summary>
+    /// The one place a script process is started. Captures stdout and stderr
+    /// separately, and on <paramref name="timeout"/> ends the script together with
+    /// anything it started.
+    /// </summary>
+    private async Task<ScriptResult> RunProcessAsync(
+        ScriptSource source,
+        TimeSpan timeout,
+        bool constrainedLanguage,
+        CancellationToken cancellationToken)
+    {
         // Find PowerShell executable (prefer pwsh over powershell)
         var psExe = FindPowerShellExecutable();
         if (string.IsNullOrEmpty(psExe))
         {
-            return (false, "Neither pwsh.exe nor powershell.exe was found");
+            return new ScriptResult(Success: false, ExitCode: -1, Output: "Neither pwsh.exe nor powershell.exe was found", WarningMessage: null);
         }
 
+        var stopwatch = Stopwatch.StartNew();
+        var echo = source.Path != null;
+        using var heartbeat = ConsoleLogger.HeartbeatWhile(
+            source.Path != null ? $"Waiting for script {Path.GetFileName(source.Path)}" : "Waiting for a script", timeout);
         try
         {
             var startInfo = new ProcessStartInfo
@@ -310,20 +512,34 @@ public class ScriptService
                 UseShellExecute = false,
                 RedirectStandardOutput = true,
                 RedirectStandardError = true,
+                RedirectStandardInput = false,
                 CreateNoWindow = true,
-                WorkingDirectory = Path.GetDirectoryName(scriptPath) ?? ""
             };
+            if (source.Path != null)
+            {
+                startInfo.WorkingDirectory = Path.GetDirectoryName(source.Path) ?? "";
+                // Set TERM so ANSI colors are preserved (matching Go behavior)
+                startInfo.Environment["TERM"] = "xterm-256color";
+            }
+            foreach (var arg in BuildArguments(source.Content, source.Path, constrainedLanguage))
+            {
+                startInfo.ArgumentList.Add(arg);
+            }
+            ApplyEnvironment(startInfo);
 
-            // Build arguments properly to handle paths with spaces
-            startInfo.ArgumentList.Add("-NoLogo");
-            startInfo.ArgumentList.Add("-NoProfile");
-            startInfo.ArgumentList.Add("-ExecutionPolicy");
-            startInfo.ArgumentList.Add("Bypass");
-            startInfo.ArgumentList.Add("-File");
-            startInfo.ArgumentList.Add(scriptPath);
-
-            // Set TERM so ANSI colors are preserved (matching Go behavior)
-            startInfo.Environment["TERM"] = "xterm-256color";
+            if (_asUser)
+            {
+                try
+                {
+                    var (userExitCode, userOutput) = await UserContextRunner.RunAsync(startInfo, timeout, cancellationToken);
+                    return new ScriptResult(userExitCode == 0, userExitCode, userOutput, ExtractWarningMarker(userOutput),
+                        StandardOutput: userOutput, Duration: stopwatch.Elapsed);
+                }
+                catch (TimeoutException ex)
+                {
+                    return TimedOutResult(timeout, "", "", stopwatch.Elapsed, ex.Message);
+                }
+            }
 
             using var process = new Process { StartInfo = startInfo };
             var output = new StringBuilder();
@@ -334,8 +550,8 @@ public class ScriptService
                 if (e.Data != null)
                 {
                     output.AppendLine(e.Data);
-                    // Stream output to console in real-time
-                    Console.WriteLine(e.Data);
+                    // Stream file output to console in real-time
+                    if (echo) Console.WriteLine(e.Data);
                 }
             };
 
@@ -344,15 +560,33 @@ public class ScriptService
                 if (e.Data != null)
                 {
                     errors.AppendLine(e.Data);
-                    Console.Error.WriteLine(e.Data);
+                    if (echo) Console.Error.WriteLine(e.Data);
                 }
             };
 
             process.Start();
+            // An install_script's children (setup.exe, msiexec) join the job, so stopping it stops them too
+            using var job = InstallerJob.TryAttach(process);
             process.BeginOutputReadLine();
             process.BeginErrorReadLine();
 
-            await process.WaitForExitAsync(cancellationToken);
+            using var timeoutCts = CancellationTokenSource.CreateLinkedTokenSource(cancellationToken);
+            timeoutCts.CancelAfter(timeout);
+            try
+            {
+                await process.WaitForExitAsync(timeoutCts.Token);
+            }
+            catch (OperationCanceledException)
+            {
+                var stop = InstallerJob.StopTree(process.Id, job, () => process.Kill(entireProcessTree: true));
+                if (cancellationToken.IsCancellationRequested)
+                {
+                    throw;
+                }
+                ConsoleLogger.Warn($"Script timed out after {timeout.TotalSeconds:F0}s, stopped {stop.Stopped.Count} process(es):\n{stop.Describe()}");
+                return TimedOutResult(timeout, output.ToString(), errors.ToString(), stopwatch.Elapsed,
+                    $"Processes stopped:{Environment.NewLine}{stop.Describe()}");
+            }
 
             var combinedOutput = output.ToString();
             if (errors.Length > 0)
@@ -360,12 +594,63 @@ public class ScriptService
                 combinedOutput += Environment.NewLine + errors.ToString();
             }
 
-            return (process.ExitCode == 0, combinedOutput);
+            // Exit code 0 = success, non-zero = failure
+            return new ScriptResult(process.ExitCode == 0, process.ExitCode, combinedOutput, ExtractWarningMarker(combinedOutput),
+                StandardOutput: output.ToString(), StandardError: errors.ToString(), Duration: stopwatch.Elapsed);
         }
         catch (Exception ex)
         {
-            return (false, $"Script execution failed: {ex.Message}");
+            return new ScriptResult(Success: false, ExitCode: -1, Output: $"Script execution failed: {ex.Message}", WarningMessage: null);
+        }
+    }
+
+    private static ScriptResult TimedOutResult(TimeSpan timeout, string stdout, string stderr, TimeSpan duration, string? processes = null) =>
+        new(Success: false, ExitCode: -1,
+            Output: $"Script timed out after {timeout.TotalSeconds:F0}s{Environment.NewLine}{(processes != null ? processes + Environment.NewLine : "")}{stdout}{stderr}",
+            WarningMessage: null, TimedOut: true,
+            StandardOutput: stdout, StandardError: stderr, Duration: duration);
+
+    private void LogScriptRun(string kind, string script, ScriptResult result, ResolvedScriptPolicy policy, IReadOnlyDictionary<string, object>? context)
+    {
+        var logger = EventLogger;
+        if (logger == null)
+        {
+            return;
+        }
+
+        var eventContext = new Dictionary<string, object>
+        {
+            ["script"] = script,
+            ["kind"] = kind,
+            ["exit_code"] = result.ExitCode,
+            ["duration_ms"] = (long)result.Duration.TotalMilliseconds,
+            ["timed_out"] = result.TimedOut,
+            ["timeout_seconds"] = policy.Timeout == Timeout.InfiniteTimeSpan ? 0 : (long)policy.Timeout.TotalSeconds,
+            ["constrained_language"] = policy.ConstrainedLanguage,
+            ["as_user"] = _asUser,
+            ["stdout"] = result.StandardOutput ?? result.Output,
+            ["stderr"] = result.StandardError ?? ""
+        };
+        foreach (var (key, value) in context ?? new Dictionary<string, object>())
+        {
+            eventContext[key] = value;
         }
+
+        logger.LogEvent(new LogEvent
+        {
+            Level = result.Success ? "INFO" : "WARN",
+            EventType = "script_run",
+            PackageName = _item?.Name,
+            PackageVersion = _item?.Version,
+            Action = kind,
+            Status = result.Success ? "success" : result.TimedOut ? "timeout" : "failed",
+            Message = result.Success
+                ? $"{kind} script completed"
+                : result.TimedOut
+                    ? $"{kind} script timed out after {policy.Timeout.TotalSeconds:F0}s"
+                    : $"{kind} script failed with exit code {result.ExitCode}",
+            Context = eventContext
+        });
     }
 
     private static string? FindPowerShellExecutable()
@@ -437,7 +722,8 @@ public class ScriptService
         }
 
         ConsoleLogger.Info($"Executing preflight script: {preflightPath}");
-        return await ExecuteScriptFileAsync(preflightPath, cancellationToken);
+        var result = await RunFileAsync(ScriptKind.Preflight, preflightPath, cancellationToken: cancellationToken);
+        return (result.Success, result.Output);
     }
 
     /// <summary>
@@ -469,6 +755,7 @@ public class ScriptService
         }
 
         ConsoleLogger.Info($"Executing postflight script: {postflightPath}");
-        return await ExecuteScriptFileAsync(postflightPath, cancellationToken);
+        var result = await RunFileAsync(ScriptKind.Postflight, postflightPath, cancellationToken: cancellationToken);
+        return (result.Success, result.Output);
     }
 }
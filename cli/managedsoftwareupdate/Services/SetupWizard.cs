@@ -0,0 +1,328 @@
+using System.Diagnostics;
+using System.Net;
+using Cimian.CLI.managedsoftwareupdate.Models;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Answers for the first-run setup. Anything left null is prompted for in an
+/// interactive run, or taken from the existing Config.yaml / defaults when
+/// running non-interactively (--setup --non-interactive with the --setup-* flags).
+/// </summary>
+public class SetupAnswers
+{
+    public string? RepoUrl { get; set; }
+    /// <summary>none, basic, token or certificate.</summary>
+    public string? AuthMethod { get; set; }
+    public string? AuthUser { get; set; }
+    public string? AuthPassword { get; set; }
+    public string? AuthToken { get; set; }
+    /// <summary>PFX/PEM path or store thumbprint for certificate auth.</summary>
+    public string? ClientCertificate { get; set; }
+    /// <summary>hostname, serial, certificate or fixed.</summary>
+    public string? ClientIdStrategy { get; set; }
+    public string? ClientIdentifier { get; set; }
+    public List<string>? Catalogs { get; set; }
+    /// <summary>Hours between automatic runs; 0 leaves the scheduled task alone.</summary>
+    public int? ScheduleHours { get; set; }
+}
+
+/// <summary>
+/// First-run setup for new deployments (managedsoftwareupdate --setup).
+///
+/// Collapses the manual steps new adopters tend to get wrong into one guided
+/// pass: repo URL, auth method (with a live test against the repo), client
+/// identifier strategy, run schedule, then saves Config.yaml (backing up the
+/// previous one). The caller follows up with a check-only validation run.
+/// Every prompt has a matching --setup-* flag so the same flow can be scripted,
+/// except the auth secret, which comes from the environment or stdin instead
+/// (see <see cref="ApplyAuthSecret"/>).
+/// </summary>
+public class SetupWizard
+{
+    /// <summary>Scheduled task created by the MSI (build/msi/install-tasks.ps1).</summary>
+    internal const string AutoRunTaskName = "Cimian Managed Software Update Hourly";
+
+    /// <summary>Environment variables a scripted --setup takes the auth secret from.</summary>
+    internal const string PasswordEnvVar = "CIMIAN_SETUP_AUTH_PASSWORD";
+    internal const string TokenEnvVar = "CIMIAN_SETUP_AUTH_TOKEN";
+
+    private static readonly string[] AuthMethods = { "none", "basic", "token", "certificate" };
+    private static readonly string[] ClientIdStrategies = { "hostname", "serial", "certificate", "fixed" };
+
+    private readonly ConfigurationService _configService;
+    private readonly bool _interactive;
+    private readonly Func<string?> _readLine;
+    private readonly Action<string> _write;
+
+    public SetupWizard(ConfigurationService configService, bool interactive,
+        Func<string?>? readLine = null, Action<string>? write = null)
+    {
+        _configService = configService;
+        _interactive = interactive;
+        _readLine = readLine ?? Console.ReadLine;
+        _write = write ?? Console.Write;
+    }
+
+    /// <summary>
+    /// Fills in the basic auth password and bearer token for a scripted setup.
+    /// Secrets are never taken as arguments (they'd show up in process listings,
+    /// run_broker.log and the watcher's log), so they come from
+    /// <see cref="PasswordEnvVar"/> / <see cref="TokenEnvVar"/>, or from the first
+    /// line of stdin when <paramref name="readSecretLine"/> is given. Stdin needs
+    /// an explicit --setup-auth basic or token to know which secret it carries.
+    /// Returns an error message, or null when the answers are usable.
+    /// </summary>
+    internal static string? ApplyAuthSecret(SetupAnswers answers, Func<string, string?> getEnv, Func<string?>? readSecretLine)
+    {
+        answers.AuthPassword = NullIfEmpty(getEnv(PasswordEnvVar));
+        answers.AuthToken = NullIfEmpty(getEnv(TokenEnvVar));
+
+        if (readSecretLine == null) return null;
+
+        var method = answers.AuthMethod?.Trim().ToLowerInvariant();
+        if (method != "basic" && method != "token")
+            return "--setup-auth-secret-stdin needs --setup-auth basic or --setup-auth token";
+
+        var secret = NullIfEmpty(readSecretLine()?.Trim());
+        if (secret == null)
+            return "--setup-auth-secret-stdin was given but nothing was read from stdin";
+
+        if (method == "basic") answers.AuthPassword = secret;
+        else answers.AuthToken = secret;
+        return null;
+    }
+
+    private static string? NullIfEmpty(string? value) => string.IsNullOrEmpty(value) ? null : value;
+
+    /// <summary>
+    /// Runs the prompts and returns the resulting configuration, or null with
+    /// errors when the answers don't form a usable config. Nothing is saved here.
+    /// </summary>
+    public CimianConfig? BuildConfig(CimianConfig current, SetupAnswers answers, out List<string> errors)
+    {
+        errors = new List<string>();
+        var config = current;
+
+        config.SoftwareRepoURL = Ask("Software repository URL", answers.RepoUrl, config.SoftwareRepoURL)!.TrimEnd('/');
+
+        var authMethod = Choose("Authentication method", answers.AuthMethod, DetectAuthMethod(config), AuthMethods);
+        ApplyAuth(config, authMethod, answers);
+
+        var strategy = Choose("Client identifier strategy", answers.ClientIdStrategy,
+            config.UseClientCertificateCNAsClientIdentifier ? "certificate" : "hostname", ClientIdStrategies);
+        if (strategy == "certificate" && authMethod != "certificate")
+        {
+            errors.Add("Client identifier strategy 'certificate' requires certificate authentication");
+        }
+        ApplyClientIdStrategy(config, strategy, answers.ClientIdentifier);
+
+        var catalogs = answers.Catalogs ?? SplitList(Ask("Catalogs (comma separated)", null, string.Join(",", config.Catalogs)));
+        if (catalogs.Count > 0)
+        {
+            config.Catalogs = catalogs;
+        }
+
+        errors.AddRange(_configService.ValidateConfig(config));
+        if (authMethod == "basic" && (string.IsNullOrEmpty(config.AuthUser) || string.IsNullOrEmpty(config.AuthPassword)))
+        {
+            errors.Add("Basic authentication requires a user name and password");
+        }
+        if (authMethod == "token" && string.IsNullOrEmpty(config.AuthToken))
+        {
+            errors.Add("Token authentication requires a token");
+        }
+
+        return errors.Count == 0 ? config : null;
+    }
+
+    /// <summary>
+    /// Fetches the first catalog with the configured auth to prove the repo URL and
+    /// credentials work before anything is saved. A 404 still proves auth worked.
+    /// </summary>
+    public async Task<(bool Success, string Message)> TestRepositoryAsync(CimianConfig config, CancellationToken cancellationToken = default)
+    {
+        var catalog = config.Catalogs.FirstOrDefault() ?? "Production";
+        var url = $"{config.SoftwareRepoURL.TrimEnd('/')}/catalogs/{catalog}.yaml";
+        try
+        {
+            using var client = CimianHttpClientFactory.CreateHttpClient(config, TimeSpan.FromSeconds(30));
+            using var response = await client.GetAsync(url, HttpCompletionOption.ResponseHeadersRead, cancellationToken);
+            return DescribeRepositoryResponse(response.StatusCode, url);
+        }
+        catch (Exception ex)
+        {
+            return (false, $"Could not reach {url}: {ex.Message}");
+        }
+    }
+
+    /// <summary>
+    /// Maps the repo test response to a pass/fail and an actionable message.
+    /// </summary>
+    internal static (bool Success, string Message) DescribeRepositoryResponse(HttpStatusCode status, string url) => status switch
+    {
+        HttpStatusCode.OK => (true, $"Repository reachable and authenticated ({url})"),
+        HttpStatusCode.NotFound => (true, $"Repository reachable, but {url} was not found - check the catalog name or run makecatalogs"),
+        HttpStatusCode.Unauthorized or HttpStatusCode.Forbidden =>
+            (false, $"Repository rejected the credentials ({(int)status}) - check the authentication settings"),
+        _ => (false, $"Repository returned {(int)status} {status} for {url}")
+    };
+
+    /// <summary>
+    /// Sets the repetition interval of the MSI-created automatic run task.
+    /// </summary>
+    public bool ApplySchedule(int hours, out string message)
+    {
+        if (hours <= 0)
+        {
+            message = "Schedule unchanged";
+            return true;
+        }
+
+        try
+        {
+            using var process = Process.Start(new ProcessStartInfo
+            {
+                FileName = "schtasks.exe",
+                Arguments = $"/Change /TN \"{AutoRunTaskName}\" /RI {hours * 60}",
+                UseShellExecute = false,
+                RedirectStandardOutput = true,
+                RedirectStandardError = true,
+                CreateNoWindow = true
+            });
+            if (process == null)
+            {
+                message = "Failed to start schtasks.exe";
+                return false;
+            }
+            process.StandardOutput.ReadToEnd();
+            var error = process.StandardError.ReadToEnd();
+            process.WaitForExit(30000);
+            if (process.ExitCode != 0)
+            {
+                message = $"Could not update '{AutoRunTaskName}': {error.Trim()} (is Cimian installed from the MSI?)";
+                return false;
+            }
+            message = $"Automatic runs scheduled every {hours} hour(s)";
+            return true;
+        }
+        catch (Exception ex)
+        {
+            message = $"Could not update schedule: {ex.Message}";
+            return false;
+        }
+    }
+
+    /// <summary>
+    /// Prompts for the schedule when not supplied on the command line.
+    /// </summary>
+    public int AskScheduleHours(int? supplied)
+    {
+        var answer = Ask("Hours between automatic runs (0 = keep current)", supplied?.ToString(), "0");
+        return int.TryParse(answer, out var hours) && hours >= 0 ? hours : 0;
+    }
+
+    /// <summary>
+    /// Prompts for a yes/no decision; non-interactive runs take the default.
+    /// </summary>
+    public bool Confirm(string prompt, bool defaultYes)
+    {
+        var answer = Ask($"{prompt} (y/n)", null, defaultYes ? "y" : "n");
+        return answer != null && answer.StartsWith("y", StringComparison.OrdinalIgnoreCase);
+    }
+
+    internal static string DetectAuthMethod(CimianConfig config)
+    {
+        if (config.UseClientCertificate) return "certificate";
+        if (!string.IsNullOrEmpty(config.AuthToken)) return "token";
+        if (!string.IsNullOrEmpty(config.AuthUser)) return "basic";
+        return "none";
+    }
+
+    private void ApplyAuth(CimianConfig config, string method, SetupAnswers answers)
+    {
+        // Clear the methods not chosen so a stale token can't override new basic
+        // credentials (CreateHttpClient prefers the token when both are set).
+        config.UseClientCertificate = method == "certificate";
+        if (method != "token") config.AuthToken = null;
+        if (method != "basic")
+        {
+            config.AuthUser = null;
+            config.AuthPassword = null;
+        }
+
+        switch (method)
+        {
+            case "basic":
+                config.AuthUser = Ask("User name", answers.AuthUser, config.AuthUser);
+                config.AuthPassword = Ask("Password", answers.AuthPassword, config.AuthPassword);
+                break;
+            case "token":
+                config.AuthToken = Ask("Bearer token", answers.AuthToken, config.AuthToken);
+                break;
+            case "certificate":
+                var cert = Ask("Client certificate (PFX/PEM path or store thumbprint)", answers.ClientCertificate,
+                    config.ClientCertificatePath ?? config.ClientCertificateThumbprint);
+                if (!string.IsNullOrEmpty(cert) && (cert.Contains('\\') || cert.Contains('/') || cert.Contains('.')))
+                {
+                    config.ClientCertificatePath = cert;
+                    config.ClientCertificateThumbprint = null;
+                }
+                else
+                {
+                    config.ClientCertificateThumbprint = cert;
+                    config.ClientCertificatePath = null;
+                }
+                break;
+        }
+    }
+
+    private void ApplyClientIdStrategy(CimianConfig config, string strategy, string? fixedValue)
+    {
+        config.UseClientCertificateCNAsClientIdentifier = strategy == "certificate";
+        config.ClientIdentifier = strategy switch
+        {
+            "serial" => ManifestService.GetSerialNumber() ?? Environment.MachineName,
+            "fixed" => Ask("Client identifier (manifest name)", fixedValue, config.ClientIdentifier) ?? string.Empty,
+            "certificate" => string.Empty,
+            _ => Environment.MachineName
+        };
+    }
+
+    private string Choose(string prompt, string? supplied, string current, string[] options)
+    {
+        while (true)
+        {
+            var answer = Ask($"{prompt} [{string.Join("/", options)}]", supplied, current)?.ToLowerInvariant();
+            if (answer != null && options.Contains(answer))
+            {
+                return answer;
+            }
+            if (!_interactive || supplied != null)
+            {
+                // Scripted runs fail fast on a bad value rather than looping
+                throw new ArgumentException($"{prompt}: '{answer}' is not one of {string.Join(", ", options)}");
+            }
+            _write($"  Please enter one of: {string.Join(", ", options)}{Environment.NewLine}");
+        }
+    }
+
+    private string? Ask(string prompt, string? supplied, string? current)
+    {
+        if (supplied != null)
+        {
+            return supplied.Trim();
+        }
+        if (!_interactive)
+        {
+            return current;
+        }
+
+        _write(string.IsNullOrEmpty(current) ? $"{prompt}: " : $"{prompt} [{current}]: ");
+        var line = _readLine();
+        return string.IsNullOrWhiteSpace(line) ? current : line.Trim();
+    }
+
+    private static List<string> SplitList(string? value) =>
+        (value ?? string.Empty).Split(',', StringSplitOptions.RemoveEmptyEntries | StringSplitOptions.TrimEntries).ToList();
+}
@@ -32,6 +32,14 @@ public class StatusReporter : IDisposable
     private Task? _commandReadTask;
     private CancellationTokenSource? _commandReadCts;
 
+    // Set while a logLine is being sent, so the reporter's own debug output
+    // about that send isn't streamed back into the connection.
+    [ThreadStatic]
+    private static bool _streamingLine;
+
+    // Log levels from least to most severe, as SessionLogger writes them
+    private static readonly string[] LogLevels = ["TRACE", "DEBUG", "INFO", "WARN", "ERROR"];
+
     /// <summary>
     /// Raised when the GUI sends a stop command over the status connection.
     /// The engine links this to its cancellation token so the user's Cancel
@@ -188,6 +196,44 @@ public class StatusReporter : IDisposable
         });
     }
 
+    /// <summary>
+    /// Report an item's progress within a stage, sent alongside itemStatus so
+    /// older listeners that only know itemStatus keep working. Downloads carry
+    /// <paramref name="bytes"/> of <paramref name="totalBytes"/> and an ETA when
+    /// known; installs carry the phase (e.g. "Running MSI installer") in
+    /// <paramref name="detail"/> with no percent.
+    /// </summary>
+    public void ItemProgress(string itemName, string stage, int percent, long? bytes = null,
+        long? totalBytes = null, int? etaSeconds = null, string? detail = null)
+    {
+        SendMessage(new StatusMessage
+        {
+            Type = "itemProgress",
+            Item = itemName,
+            Data = stage,
+            Message = detail,
+            Percent = percent,
+            Bytes = bytes,
+            TotalBytes = totalBytes,
+            EtaSeconds = etaSeconds
+        });
+    }
+
+    /// <summary>
+    /// Send an installed item's release notes (<paramref name="notes"/> in Data,
+    /// the version in Message) so the GUI can show "What's new".
+    /// </summary>
+    public void ReleaseNotes(string itemName, string version, string notes)
+    {
+        SendMessage(new StatusMessage
+        {
+            Type = "releaseNotes",
+            Item = itemName,
+            Data = notes,
+            Message = version
+        });
+    }
+
     private void StartCommandReader()
     {
         if (_commandReadTask is { IsCompleted: false }) return;
@@ -224,6 +270,48 @@ public class StatusReporter : IDisposable
         }, token);
     }
 
+    /// <summary>
+    /// Least severe log level <see cref="LogLine"/> forwards (StatusLogStreamLevel).
+    /// </summary>
+    public string LogStreamLevel { get; set; } = "INFO";
+
+    /// <summary>
+    /// Stream a run.log line to the GUI for its live log pane. Only sent to a GUI
+    /// that is already connected; a log line never opens the connection.
+    /// </summary>
+    public void LogLine(string level, string text)
+    {
+        if (_disposed || !_connected || _streamingLine || !ShouldStream(level, LogStreamLevel)) return;
+
+        _streamingLine = true;
+        try
+        {
+            SendMessage(new StatusMessage
+            {
+                Type = "logLine",
+                Level = level,
+                Data = text
+            });
+        }
+        finally
+        {
+            _streamingLine = false;
+        }
+    }
+
+    /// <summary>
+    /// True when <paramref name="level"/> is at least as severe as
+    /// <paramref name="minimumLevel"/>. Unknown levels count as INFO.
+    /// </summary>
+    internal static bool ShouldStream(string level, string minimumLevel) =>
+        LevelRank(level) >= LevelRank(minimumLevel);
+
+    private static int LevelRank(string? level)
+    {
+        var rank = Array.FindIndex(LogLevels, l => l.Equals(level?.Trim(), StringComparison.OrdinalIgnoreCase));
+        return rank >= 0 ? rank : Array.IndexOf(LogLevels, "INFO");
+    }
+
     /// <summary>
     /// Request to display the log file
     /// </summary>
@@ -336,10 +424,26 @@ internal class StatusMessage
     [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
     public string? Item { get; set; }
 
+    [JsonPropertyName("level")]
+    [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public string? Level { get; set; }
+
     [JsonPropertyName("percent")]
     [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingDefault)]
     public int Percent { get; set; }
 
+    [JsonPropertyName("bytes")]
+    [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public long? Bytes { get; set; }
+
+    [JsonPropertyName("total_bytes")]
+    [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public long? TotalBytes { get; set; }
+
+    [JsonPropertyName("eta_seconds")]
+    [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public int? EtaSeconds { get; set; }
+
     [JsonPropertyName("error")]
     [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingDefault)]
     public bool Error { get; set; }
@@ -59,6 +59,47 @@ public class StatusService
         return false;
     }
 
+    /// <summary>
+    /// True for a Cimian package built for a self-update channel
+    /// <paramref name="channel"/> doesn't take: one less stable than it (beta
+    /// and dev builds on a stable machine). An unset self_update_channel (and an
+    /// empty configured channel) means stable.
+    /// </summary>
+    public static bool IsOutsideSelfUpdateChannel(CatalogItem item, string? channel)
+    {
+        if (!IsCimianPackage(item))
+            return false;
+
+        return !SelfUpdateRollout.AcceptsChannel(item.SelfUpdateChannel, channel);
+    }
+
+    /// <summary>
+    /// True for a Cimian package this machine doesn't take yet: its
+    /// self_update_rollout_percent hasn't reached the machine's bucket
+    /// (<see cref="RolloutBucket"/> unless <paramref name="bucket"/> is given),
+    /// or this version already failed its health check here and was rolled back.
+    /// </summary>
+    public static bool IsHeldBackSelfUpdate(CatalogItem item, int? bucket = null, string? healthPath = null)
+    {
+        if (!IsCimianPackage(item))
+            return false;
+
+        if (item.SelfUpdateRolloutPercent is { } percent && !SelfUpdateRollout.InRollout(percent, bucket ?? RolloutBucket))
+            return true;
+
+        return SelfUpdateHealthStore.IsRolledBack(item.Name, item.Version, healthPath);
+    }
+
+    private static int? _rolloutBucket;
+
+    /// <summary>
+    /// This machine's staged-rollout bucket (0-99), from its Windows MachineGuid,
+    /// which survives renames and changes only when Windows is reinstalled.
+    /// </summary>
+    public static int RolloutBucket => _rolloutBucket ??= SelfUpdateRollout.Bucket(
+        Registry.GetValue(@"HKEY_LOCAL_MACHINE\SOFTWARE\Microsoft\Cryptography", "MachineGuid", null) as string
+        ?? Environment.MachineName);
+
     /// <summary>
     /// Gets the running version of the managedsoftwareupdate binary
     /// </summary>
@@ -123,6 +164,14 @@ public class StatusService
                 return result;
             }
 
+            // Priority 0.5: configuration items are compliant or not, never installed.
+            // Their test runs every check, so drift is caught on the next run.
+            if (item.IsConfigurationItem)
+            {
+                ConsoleLogger.Info($"Checking compliance of configuration item: {item.Name}");
+                return CheckConfigurationItem(item);
+            }
+
             // Priority 1: Check installcheck_script if defined (Go parity - runs before anything else)
             if (!string.IsNullOrEmpty(item.InstallcheckScript))
             {
@@ -296,6 +345,115 @@ public class StatusService
         return result;
     }
 
+    /// <summary>
+    /// Which of <see cref="CheckStatus"/>'s checks decides <paramref name="item"/>'s
+    /// install state, in the same priority order, without running anything. A
+    /// ManagedInstalls receipt is only consulted at run time, so items that fall
+    /// through to it are described as such.
+    /// </summary>
+    public static InstallCheckStrategy DescribeCheckStrategy(CatalogItem item)
+    {
+        if (item.OnDemand)
+        {
+            return new(DetectionMethod.None, "OnDemand: never considered installed, (re)installed every run", []);
+        }
+        if (item.IsConfigurationItem)
+        {
+            var method = item.Configuration?.Method == "dsc" ? DetectionMethod.Dsc : DetectionMethod.Script;
+            return new(method, $"Configuration item: compliance test ({item.Configuration?.Method ?? "script"}) runs every check", []);
+        }
+        if (!string.IsNullOrEmpty(item.InstallcheckScript))
+        {
+            return new(DetectionMethod.Script, "installcheck_script: exit code 0 means an install is needed", []);
+        }
+        if (!string.IsNullOrEmpty(item.VersionScript))
+        {
+            return new(DetectionMethod.Script, "version_script: its output is the installed version, compared to the catalog version", []);
+        }
+        if (item.Installs is { Count: > 0 })
+        {
+            var details = item.Installs.Select(i =>
+            {
+                var target = i.EffectiveType() switch
+                {
+                    "msi" => i.ProductCode ?? i.UpgradeCode ?? "",
+                    "msix" => i.IdentityName ?? i.Path ?? "",
+                    _ => i.Path ?? ""
+                };
+                var version = string.IsNullOrEmpty(i.Version) ? "" : $" version {i.Version}";
+                var hash = string.IsNullOrEmpty(i.Md5Checksum) ? "" : $" md5 {i.Md5Checksum}";
+                return $"{i.EffectiveType()} {target}{version}{hash}".TrimEnd();
+            }).ToList();
+            return new(DetectionMethod.InstallsArray, $"installs array: {details.Count} entr{(details.Count == 1 ? "y" : "ies")} must all match", details);
+        }
+        if (!string.IsNullOrEmpty(item.Check.Registry.Name))
+        {
+            var versioned = string.IsNullOrEmpty(item.Check.Registry.Version) ? "" : $", at version {item.Version} or newer";
+            return new(DetectionMethod.Registry,
+                $"check.registry: an uninstall entry whose DisplayName contains \"{item.Check.Registry.Name}\"{versioned}", []);
+        }
+        if (item.Check.File != null && !string.IsNullOrEmpty(item.Check.File.Path))
+        {
+            return new(DetectionMethod.File, $"check.file: {item.Check.File.Path}", []);
+        }
+        if (!string.IsNullOrEmpty(item.Check.Script))
+        {
+            return new(DetectionMethod.Script, "check.script: exit code 0 means installed", []);
+        }
+
+        var installer = item.Installer;
+        var fallback = "ManagedInstalls receipt version compared to the catalog version";
+        if (installer != null
+            && string.Equals(installer.Type, "msi", StringComparison.OrdinalIgnoreCase)
+            && (!string.IsNullOrEmpty(installer.ProductCode) || !string.IsNullOrEmpty(installer.UpgradeCode)))
+        {
+            return new(DetectionMethod.ManagedInstalls,
+                $"{fallback}; without a receipt, the installer block's MSI ProductCode/UpgradeCode",
+                [$"msi {installer.ProductCode ?? installer.UpgradeCode}"]);
+        }
+
+        var installerType = (installer?.Type ?? string.Empty).Trim().ToLowerInvariant();
+        return installerType is "" or "nopkg" or "script"
+            ? new(DetectionMethod.ManagedInstalls, $"{fallback}; without a receipt, script-only items are assumed installed", [])
+            : new(DetectionMethod.ManagedInstalls, $"{fallback}; without a receipt, treated as not installed", []);
+    }
+
+    /// <summary>
+    /// Runs a configuration item's test. Non-compliant (or untestable) items need
+    /// action, which for them means remediation rather than an install.
+    /// </summary>
+    private static StatusCheckResult CheckConfigurationItem(CatalogItem item)
+    {
+        var result = new StatusCheckResult
+        {
+            DetectionMethod = item.Configuration?.Method == "dsc" ? DetectionMethod.Dsc : DetectionMethod.Script,
+            TargetVersion = item.Version
+        };
+
+        var test = new ConfigurationItemService().TestAsync(item).Result;
+        if (test.Error != null)
+        {
+            result.Status = "error";
+            result.NeedsAction = true;
+            result.Reason = test.Error;
+            result.ReasonCode = StatusReasonCode.CheckFailed;
+            return result;
+        }
+        if (test.Compliant)
+        {
+            result.Status = "installed";
+            result.Reason = "Configuration test passed";
+            result.ReasonCode = StatusReasonCode.ConfigurationCompliant;
+            return result;
+        }
+
+        result.Status = "pending";
+        result.NeedsAction = true;
+        result.Reason = "Configuration test failed - remediation needed";
+        result.ReasonCode = StatusReasonCode.ConfigurationNoncompliant;
+        return result;
+    }
+
     /// <summary>
     /// Checks the installcheck_script - if exit code 0, install is needed; if exit code 1, install is not needed
     /// This is Go parity behavior
@@ -310,8 +468,7 @@ public class StatusService
 
         try
         {
-            var scriptService = new ScriptService();
-            var (success, output) = scriptService.ExecuteScriptAsync(item.InstallcheckScript!).Result;
+            var (success, output) = RunCheckScript(item, item.InstallcheckScript!);
 
             ConsoleLogger.Debug($"InstallCheckScript output stdout: {output?.Trim()} stderr:  error: <nil>");
 
@@ -363,8 +520,7 @@ public class StatusService
 
         try
         {
-            var scriptService = new ScriptService();
-            var (success, output) = scriptService.ExecuteScriptAsync(item.VersionScript!).Result;
+            var (success, output) = RunCheckScript(item, item.VersionScript!);
             var installedVersion = output?.Trim() ?? "";
 
             ConsoleLogger.Debug($"version_script output for {item.Name}: '{installedVersion}'");
@@ -430,7 +586,7 @@ public class StatusService
         // Go parity: Read the ManagedInstalls registry version first
         var registryVersion = GetManagedInstallsVersion(item.Name);
 
-        foreach (var installItem in item.Installs)
+        foreach (var installItem in UserContextRunner.ResolveInstalls(item))
         {
             switch (installItem.EffectiveType())
             {
@@ -842,10 +998,19 @@ if ($results.Count -gt 0) {{
     }
 
     /// <summary>
-    /// Check if an MSI product is installed and return its version
+    /// Check if an MSI product is installed and return its version. Windows
+    /// Installer is asked first; the Uninstall key named after the ProductCode
+    /// covers products it doesn't know about, such as EXE installers that
+    /// register under a GUID.
     /// </summary>
     private (bool installed, string? version) CheckMsiProductWithVersion(string productCode)
     {
+        if (MsiProducts.FindByProductCode(productCode) is { Version: { Length: > 0 } } product)
+        {
+            ConsoleLogger.Debug($"Found MSI product via Windows Installer productCode: {productCode} name: {product.Name} version: {product.Version}");
+            return (true, product.Version);
+        }
+
         var views = new[] { RegistryView.Registry64, RegistryView.Registry32 };
         
         foreach (var view in views)
@@ -903,14 +1068,22 @@ if ($results.Count -gt 0) {{
     }
 
     /// <summary>
-    /// Finds any installed product with the given UpgradeCode and returns its version.
+    /// Finds the newest installed product with the given UpgradeCode and returns its version.
     /// Essential for auto-updating apps (Chrome, etc.) where ProductCode changes each version.
+    /// Windows Installer is asked first (MsiEnumRelatedProducts); the packed-GUID registry
+    /// walk remains for when it can't be queried.
     /// </summary>
     private (bool installed, string? version) FindMsiByUpgradeCode(string upgradeCode)
     {
         if (string.IsNullOrEmpty(upgradeCode))
             return (false, null);
 
+        if (MsiProducts.FindByUpgradeCode(upgradeCode) is { Version: { Length: > 0 } } product)
+        {
+            ConsoleLogger.Info($"Found installed product via Windows Installer upgradeCode: {upgradeCode} productCode: {product.ProductCode} version: {product.Version}");
+            return (true, product.Version);
+        }
+
         var packedUpgradeCode = PackGuid(upgradeCode);
         if (string.IsNullOrEmpty(packedUpgradeCode))
         {
@@ -1471,8 +1644,7 @@ if ($results.Count -gt 0) {{
 
         try
         {
-            var scriptService = new ScriptService();
-            var (success, output) = scriptService.ExecuteScriptAsync(item.Check.Script!).Result;
+            var (success, output) = RunCheckScript(item, item.Check.Script!);
 
             ConsoleLogger.Debug($"Check script output stdout: {output?.Trim()} stderr:  error: <nil>");
 
@@ -1508,6 +1680,20 @@ if ($results.Count -gt 0) {{
         return result;
     }
 
+    /// <summary>
+    /// Runs an installcheck-kind script under its ScriptPolicies. A timeout throws,
+    /// so callers report a script error rather than reading it as a verdict.
+    /// </summary>
+    private static (bool Success, string Output) RunCheckScript(CatalogItem item, string script)
+    {
+        var result = new ScriptService().ForItem(item).RunAsync(ScriptKind.Installcheck, script).Result;
+        if (result.TimedOut)
+        {
+            throw new TimeoutException($"timed out after {result.Duration.TotalSeconds:F0}s");
+        }
+        return (result.Success, result.Output);
+    }
+
     private StatusCheckResult CheckManagedInstallsStatus(CatalogItem item)
     {
         var result = new StatusCheckResult
@@ -1580,19 +1766,24 @@ if ($results.Count -gt 0) {{
         return result;
     }
 
-    private static string? GetFileVersion(string path)
+    /// <summary>
+    /// The version in a PE file's resources, as Major.Minor.Build.Revision from
+    /// the fixed file info - the form makepkginfo and cimiimport write into
+    /// installs entries. The FileVersion string ("10.0.26100.1 (WinBuild...)",
+    /// "1, 2, 3, 4") is only the fallback for files whose numeric parts are zero.
+    /// </summary>
+    internal static string? GetFileVersion(string path)
     {
         try
         {
             var versionInfo = System.Diagnostics.FileVersionInfo.GetVersionInfo(path);
-            var version = versionInfo.FileVersion;
-            
-            // Validate that the returned string is actually a version number.
-            // FileVersionInfo can return metadata fields like "InternalName" on some builds.
-            if (!string.IsNullOrEmpty(version) && version.Any(char.IsDigit))
-                return version;
-            
-            return null;
+            if (versionInfo.FileMajorPart > 0 || versionInfo.FileMinorPart > 0 ||
+                versionInfo.FileBuildPart > 0 || versionInfo.FilePrivatePart > 0)
+            {
+                return $"{versionInfo.FileMajorPart}.{versionInfo.FileMinorPart}.{versionInfo.FileBuildPart}.{versionInfo.FilePrivatePart}";
+            }
+
+            return CleanVersionString(versionInfo.FileVersion);
         }
         catch
         {
@@ -1600,6 +1791,25 @@ if ($results.Count -gt 0) {{
         }
     }
 
+    /// <summary>
+    /// A FileVersion string reduced to its dotted number: trailing text such as
+    /// " (WinBuild.160101.0800)" is dropped and "1, 2, 3, 4" becomes "1.2.3.4".
+    /// Null when no digits are left; FileVersionInfo can return metadata fields
+    /// like "InternalName" on some builds.
+    /// </summary>
+    internal static string? CleanVersionString(string? version)
+    {
+        if (string.IsNullOrWhiteSpace(version))
+            return null;
+
+        var parenIndex = version.IndexOf('(');
+        if (parenIndex >= 0)
+            version = version[..parenIndex];
+        version = version.Replace(", ", ".").Replace(',', '.').Trim();
+
+        return version.Any(char.IsDigit) ? version : null;
+    }
+
     /// <summary>
     /// Gets the system architecture
     /// </summary>
@@ -1744,41 +1954,7 @@ if ($results.Count -gt 0) {{
     /// Checks if the system has a pending reboot
     /// </summary>
     /// <returns>True if a reboot is pending</returns>
-    public static bool IsPendingReboot()
-    {
-        try
-        {
-            // Check Windows Update pending reboot
-            using var wuKey = Registry.LocalMachine.OpenSubKey(@"SOFTWARE\Microsoft\Windows\CurrentVersion\WindowsUpdate\Auto Update\RebootRequired");
-            if (wuKey != null) return true;
-
-            // Check Component Based Servicing pending reboot
-            using var cbsKey = Registry.LocalMachine.OpenSubKey(@"SOFTWARE\Microsoft\Windows\CurrentVersion\Component Based Servicing\RebootPending");
-            if (cbsKey != null) return true;
-
-            // Check Session Manager pending file rename operations
-            using var smKey = Registry.LocalMachine.OpenSubKey(@"SYSTEM\CurrentControlSet\Control\Session Manager");
-            if (smKey != null)
-            {
-                var pendingOps = smKey.GetValue("PendingFileRenameOperations");
-                if (pendingOps is string[] ops && ops.Length > 0) return true;
-            }
-
-            // Check if SCCM/ConfigMgr says reboot is pending
-            using var ccmKey = Registry.LocalMachine.OpenSubKey(@"SOFTWARE\Microsoft\CCM\ClientSDK\InProgress");
-            if (ccmKey != null)
-            {
-                var rebootPending = ccmKey.GetValue("RebootRequired");
-                if (rebootPending != null) return true;
-            }
-
-            return false;
-        }
-        catch
-        {
-            return false;
-        }
-    }
+    public static bool IsPendingReboot() => PendingReboot.GetReasons().Count > 0;
 
     /// <summary>
     /// Checks if there's sufficient disk space for installation
@@ -1816,6 +1992,69 @@ if ($results.Count -gt 0) {{
         }
     }
 
+    /// <summary>
+    /// Free bytes the system drive needs before <paramref name="item"/> installs:
+    /// installer_size_required plus the download while it isn't cached, and at
+    /// least minimum_free_disk_mb. 0 when the item sets neither.
+    /// </summary>
+    internal static long RequiredFreeBytes(CatalogItem item, bool payloadCached)
+    {
+        const long bytesPerMb = 1024 * 1024;
+        var needed = Math.Max(item.InstallerSizeRequired ?? 0, 0) * bytesPerMb;
+        if (needed > 0 && !payloadCached)
+        {
+            needed += Math.Max(item.Installer.Size ?? 0, 0);
+        }
+        return Math.Max(needed, Math.Max(item.MinimumFreeDiskMb ?? 0, 0) * bytesPerMb);
+    }
+
+    /// <summary>Available bytes on the Windows drive, or null when it can't be read.</summary>
+    public static long? GetSystemDriveFreeBytes()
+    {
+        try
+        {
+            var root = Path.GetPathRoot(Environment.GetFolderPath(Environment.SpecialFolder.Windows));
+            return new DriveInfo(string.IsNullOrEmpty(root) ? @"C:\" : root).AvailableFreeSpace;
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException or ArgumentException)
+        {
+            ConsoleLogger.Debug($"Could not read free disk space: {ex.Message}");
+            return null;
+        }
+    }
+
+    /// <summary>
+    /// Whether the machine runs on battery and its charge, from
+    /// GetSystemPowerStatus. Desktops and unknown states report on AC.
+    /// </summary>
+    public static PowerState GetPowerState()
+    {
+        if (!OperatingSystem.IsWindows() || !GetSystemPowerStatus(out var status))
+        {
+            return PowerState.OnAc;
+        }
+
+        const byte acOffline = 0, noSystemBattery = 128, unknownPercent = 255;
+        if (status.ACLineStatus != acOffline || (status.BatteryFlag & noSystemBattery) != 0)
+        {
+            return PowerState.OnAc;
+        }
+        return new PowerState(true, status.BatteryLifePercent == unknownPercent ? null : status.BatteryLifePercent);
+    }
+
+    /// <summary>
+    /// Why installs should wait for power under MinimumBatteryPercent, or null
+    /// when on AC, above the threshold, or the charge is unknown.
+    /// </summary>
+    internal static string? LowBatteryReason(PowerState power, int minimumPercent)
+    {
+        if (minimumPercent <= 0 || !power.OnBattery || power.ChargePercent is not { } percent || percent >= minimumPercent)
+        {
+            return null;
+        }
+        return $"on battery at {percent}%, below MinimumBatteryPercent {minimumPercent}%";
+    }
+
     /// <summary>
     /// Checks pending states for a package and returns appropriate status result if blocked
     /// </summary>
@@ -1899,4 +2138,24 @@ if ($results.Count -gt 0) {{
 
     [DllImport("user32.dll")]
     private static extern bool GetLastInputInfo(ref LASTINPUTINFO plii);
+
+    [StructLayout(LayoutKind.Sequential)]
+    private struct SYSTEM_POWER_STATUS
+    {
+        public byte ACLineStatus;
+        public byte BatteryFlag;
+        public byte BatteryLifePercent;
+        public byte SystemStatusFlag;
+        public int BatteryLifeTime;
+        public int BatteryFullLifeTime;
+    }
+
+    [DllImport("kernel32.dll")]
+    private static extern bool GetSystemPowerStatus(out SYSTEM_POWER_STATUS lpSystemPowerStatus);
+}
+
+/// <summary>Power source and battery charge; ChargePercent is null when Windows doesn't know it.</summary>
+public sealed record PowerState(bool OnBattery, int? ChargePercent)
+{
+    public static readonly PowerState OnAc = new(false, null);
 }
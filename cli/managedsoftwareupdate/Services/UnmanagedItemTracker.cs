@@ -0,0 +1,124 @@
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+public class UnmanagedItemEntry
+{
+    [JsonPropertyName("item_name")]
+    public string ItemName { get; set; } = "";
+
+    [JsonPropertyName("version")]
+    public string Version { get; set; } = "";
+
+    /// <summary>The first run that found the item in no manifest.</summary>
+    [JsonPropertyName("first_unmanaged")]
+    public DateTime FirstUnmanaged { get; set; }
+}
+
+/// <summary>An item out of every manifest, and when RemoveUnmanagedItems removes it.</summary>
+public sealed record UnmanagedItem(CatalogItem Item, DateTime FirstUnmanaged, DateTime RemoveAfter, bool Due);
+
+/// <summary>
+/// Grace-period clock for RemoveUnmanagedItems, kept in
+/// <see cref="CimianPaths.UnmanagedItemsJson"/>. An item's clock starts on the
+/// first run that finds it in no manifest and stops as soon as a manifest lists
+/// it again or it's gone, so putting an item back in time cancels its removal.
+/// </summary>
+public class UnmanagedItemTracker
+{
+    private readonly string _path;
+    private readonly int _graceDays;
+    private Dictionary<string, UnmanagedItemEntry>? _entries;
+
+    public UnmanagedItemTracker(int graceDays, string? path = null)
+    {
+        _graceDays = Math.Max(0, graceDays);
+        _path = path ?? CimianPaths.UnmanagedItemsJson;
+    }
+
+    /// <summary>
+    /// Records that <paramref name="unmanaged"/> are the items in no manifest as of
+    /// <paramref name="now"/> and returns each with its removal date. Items no
+    /// longer in the list are forgotten.
+    /// </summary>
+    public List<UnmanagedItem> Track(IEnumerable<CatalogItem> unmanaged, DateTime now)
+    {
+        var current = new Dictionary<string, UnmanagedItemEntry>();
+        var result = new List<UnmanagedItem>();
+        foreach (var item in unmanaged)
+        {
+            var key = ItemKey.Canonical(item.Name);
+            if (current.ContainsKey(key))
+            {
+                continue;
+            }
+
+            var entry = Entries.GetValueOrDefault(key) ?? new UnmanagedItemEntry { FirstUnmanaged = now };
+            entry.ItemName = item.Name;
+            entry.Version = item.Version;
+            current[key] = entry;
+
+            var removeAfter = entry.FirstUnmanaged.AddDays(_graceDays);
+            result.Add(new UnmanagedItem(item, entry.FirstUnmanaged, removeAfter, now >= removeAfter));
+        }
+
+        _entries = current;
+        return result;
+    }
+
+    public void Save()
+    {
+        if (_entries == null)
+        {
+            return;
+        }
+        try
+        {
+            JsonStateFile.Write(_path, _entries.Values.OrderBy(e => e.ItemName));
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not save unmanaged item state: {ex.Message}");
+        }
+    }
+
+    private Dictionary<string, UnmanagedItemEntry> Entries => _entries ??= Load();
+
+    private Dictionary<string, UnmanagedItemEntry> Load()
+    {
+        var entries = new Dictionary<string, UnmanagedItemEntry>();
+        try
+        {
+            if (File.Exists(_path))
+            {
+                foreach (var entry in JsonSerializer.Deserialize<List<UnmanagedItemEntry>>(File.ReadAllText(_path)) ?? [])
+                {
+                    entries[ItemKey.Canonical(entry.ItemName)] = entry;
+                }
+            }
+        }
+        catch (Exception ex) when (ex is IOException or JsonException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not read unmanaged item state: {ex.Message}");
+        }
+        return entries;
+    }
+
+    /// <summary>
+    /// Names the manifests still account for: every manifest item, everything
+    /// they require (transitively), and the update_for items that follow them.
+    /// A receipt outside this set is unmanaged.
+    /// </summary>
+    public static HashSet<string> ManagedNames(
+        IEnumerable<ManifestItem> manifestItems, Dictionary<string, CatalogItem> catalogMap)
+    {
+        var seeds = manifestItems.Select(m => m.Name).Where(n => !string.IsNullOrEmpty(n)).ToList();
+        var managed = new HashSet<string>(seeds, ItemKey.Comparer);
+        managed.UnionWith(CatalogService.BuildDependencyClosure(seeds, catalogMap));
+        return managed;
+    }
+}
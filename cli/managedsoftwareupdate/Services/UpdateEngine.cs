@@ -31,12 +31,46 @@ public class UpdateEngine : IDisposable
     private CatalogService _catalogService;
     private DownloadService _downloadService;
     private InstallerService _installerService;
+    private readonly IconCacheService _iconCacheService;
     private readonly StatusService _statusService;
     private readonly ScriptService _scriptService;
+    private readonly RollbackService _rollbackService = new();
+    private readonly DeferralService _deferrals = new();
+    private readonly CatalogOverrideService _catalogOverrides = new();
+    private readonly InstalledItemsStore _installedItemsStore = new();
+    private CatalogOverride? _catalogOverride;
+
+    // Items whose deferral budget or deadline ran out this run; blocking apps are closed for them
+    private readonly HashSet<string> _forcedItems = new(StringComparer.OrdinalIgnoreCase);
+
+    // BlockingAppTimeout: the applications the last install attempt was held for
+    // (null when it wasn't), and whether the wait for them has run out
+    private List<string>? _heldForApps;
+    private bool _blockingAppWaitOver;
+    private static readonly TimeSpan BlockingAppPollInterval = TimeSpan.FromSeconds(5);
+    private const string UserNotificationsTaskName = "Cimian User Notifications";
+
     private StatusReporter? _statusReporter;
+    private ConsoleProgressDisplay? _progressDisplay;
+    private LogForwarder? _logForwarder;
     private SessionLogger? _sessionLogger;
+
+    /// <summary>This run's session log directory, or null before a session starts.</summary>
+    public string? SessionDir => _sessionLogger?.SessionDir is { Length: > 0 } dir ? dir : null;
+
+    /// <summary>Items this run installed or removed, and items it failed to; read by the bootstrap loop.</summary>
+    public IReadOnlyList<ItemOutcome> Outcomes => _outcomes;
+    private List<ItemOutcome> _outcomes = new();
+
+    /// <summary>Whether this run scheduled a restart of the machine.</summary>
+    public bool RestartScheduled { get; private set; }
     private LoopGuard? _loopGuard;
 
+    // Config.yaml as the session committed to it. Mid-run edits are reported
+    // against this and take effect on the next run, never halfway through this one.
+    private ConfigSnapshot? _configSnapshot;
+    private bool _configChangeWarned;
+
     // Cancelled when the GUI sends a stop command over the status connection.
     // Checked between items so a user cancel aborts gracefully, never mid-install.
     private readonly CancellationTokenSource _userStop = new();
@@ -45,13 +79,25 @@ public class UpdateEngine : IDisposable
     private bool _isBootstrap;
     private bool _checkOnly;
     private bool _installOnly;
+    private bool _precache;
+    private string _runType = "";
+    private bool _insideMaintenanceWindow;
+    private MachinePersistence _persistence = MachinePersistence.Persistent;
+    private const int NonPersistentLogRetentionDays = 2;
     private bool _auto;
     private bool _showStatus;
     private bool _restartNeeded;
+    private DateTime? _restartDeadline; // earliest force_install_after_date among items needing a restart
+    private readonly List<string> _restartRequiredBy = new();
+    // Every deferral this run with its reason, for the --dry-run plan and the session summary
+    private readonly List<(CatalogItem Item, string Reason)> _deferralReasons = new();
     private bool _logoutNeeded;
+    private bool _degraded; // repo unreachable; running from cached manifests/catalogs
 
     // Store for managed items tracking (for status table)
     private List<ManifestItem> _allManifestItems = new();
+    private List<UnmanagedItem> _unmanagedItems = new(); // this run's RemoveUnmanagedItems candidates, for items.json
+    private List<CatalogItem> _orphanedDependencies = new(); // this run's RemoveOrphanedDependencies removals, for items.json
     private Dictionary<string, CatalogItem> _catalogMap = new();
 
     public UpdateEngine(CimianConfig config)
@@ -61,7 +107,9 @@ public class UpdateEngine : IDisposable
         _manifestService = new ManifestService(config);
         _catalogService = new CatalogService(config);
         _downloadService = new DownloadService(config);
+        _downloadService.OriginRejected += LogOriginRejectedEvent;
         _installerService = new InstallerService(config);
+        _iconCacheService = new IconCacheService(config);
         _statusService = new StatusService();
         _scriptService = new ScriptService();
 
@@ -163,6 +211,19 @@ public class UpdateEngine : IDisposable
         return true;
     }
 
+    /// <summary>
+    /// Whether the AllowSelfServiceUninstall policy lets a user remove this item:
+    /// never refuses, approved needs self_service_uninstall in the pkginfo, and
+    /// always (or an unrecognized value) allows any uninstallable item.
+    /// </summary>
+    internal static bool IsSelfServiceUninstallAllowed(string? policy, CatalogItem item) =>
+        policy?.Trim().ToLowerInvariant() switch
+        {
+            "never" => false,
+            "approved" => item.SelfServiceUninstall,
+            _ => true
+        };
+
     /// <summary>
     /// Enable ANSI escape codes for colored output on Windows console
     /// </summary>
@@ -220,6 +281,11 @@ public class UpdateEngine : IDisposable
         bool showStatus = false,
         int statusPort = StatusReporter.DefaultPort,
         IEnumerable<string>? itemFilter = null,
+        bool dryRun = false,
+        string? planOutputPath = null,
+        bool precache = false,
+        bool ignoreMaintenanceWindow = false,
+        bool progress = false,
         CancellationToken cancellationToken = default)
     {
         // Create item filter service (Go parity: pkg/filter)
@@ -227,17 +293,35 @@ public class UpdateEngine : IDisposable
         
         _checkOnly = checkOnly;
         _installOnly = installOnly;
+        _precache = precache;
+        _catalogService.RecordGenerations = !checkOnly && !dryRun;
         _auto = auto;
         _isBootstrap = bootstrap || StatusService.IsBootstrapMode();
         _verbosity = verbosity;
         _showStatus = showStatus;
 
+        // MaintenanceWindows: outside every window an auto run only checks and
+        // precaches; inside one it installs even with the user active
+        var maintenanceWindowDeferred = false;
+        if (_auto && !_isBootstrap && !ignoreMaintenanceWindow && _config.MaintenanceWindows.Count > 0)
+        {
+            var utcNow = DateTime.UtcNow;
+            _insideMaintenanceWindow = _config.MaintenanceWindows.Any(w => w.IsWithinWindow(utcNow));
+            if (!_insideMaintenanceWindow && !precache)
+            {
+                precache = true;
+                _precache = true;
+                maintenanceWindowDeferred = true;
+            }
+        }
+
         // Initialize loop guard for install loop prevention. Admins can disable it
         // fleet-wide via LoopGuardEnabled: false in config.yaml. The startup notice
         // is emitted further down, once ConsoleLogger.Verbosity is set and the
         // SessionLogger is attached, so it actually reaches the console and run.log.
         var loopGuardDisabled = !_config.LoopGuardEnabled;
-        _loopGuard = new LoopGuard(_isBootstrap, disabled: loopGuardDisabled, maxSuppressionDays: _config.LoopMaxTime);
+        _loopGuard = new LoopGuard(_isBootstrap, disabled: loopGuardDisabled, maxSuppressionDays: _config.LoopMaxTime,
+            quarantineThreshold: _config.QuarantineFailureThreshold);
 
         // Track session duration for run.log summary
         var sessionStopwatch = System.Diagnostics.Stopwatch.StartNew();
@@ -254,15 +338,42 @@ public class UpdateEngine : IDisposable
             _statusReporter.TryConnect();
         }
 
+        // --progress in an interactive console: bars instead of log lines
+        if (progress && ConsoleProgressDisplay.IsSupported)
+        {
+            _progressDisplay = new ConsoleProgressDisplay();
+            ConsoleLogger.ProgressDisplay = _progressDisplay.WriteLine;
+        }
+
         // Initialize session logger for structured logging (Go parity: pkg/logging)
         // This creates timestamped directories in C:\ProgramData\ManagedInstalls\logs
         // and writes to reports directory for external monitoring tools
-        var runType = _isBootstrap ? "bootstrap" : 
+        var runType = dryRun ? "dryrun" :
+                      precache ? "precache" :
+                      _isBootstrap ? "bootstrap" : 
                       _auto ? "auto" : 
                       _checkOnly ? "checkonly" : 
                       _installOnly ? "installonly" : "manual";
+        _runType = runType;
         
-        _sessionLogger = new SessionLogger();
+        // --override-catalogs pin: replaces Config.yaml's catalogs for its remaining
+        // runs. A dry run looks at it without spending one.
+        _catalogOverride = dryRun ? _catalogOverrides.Get() : _catalogOverrides.Consume(runType, DateTime.Now);
+        if (_catalogOverride != null)
+        {
+            _config.Catalogs = new List<string>(_catalogOverride.Catalogs);
+        }
+
+        _persistence = PersistenceDetector.Detect(_config.NonPersistentMode);
+        CimianEventLog.Enabled = _config.EventLogEnabled;
+        _sessionLogger = new SessionLogger
+        {
+            RetentionDays = _persistence.IsNonPersistent ? NonPersistentLogRetentionDays : _config.LogRetentionDays,
+            KeepSessions = _config.LogRetentionSessions,
+            MaxLogSizeMB = _config.MaxLogSizeMB,
+            ReportFormats = _config.ReportFormats,
+            ManifestName = _config.ClientIdentifier
+        };
         var sessionId = _sessionLogger.StartSession(runType, new Dictionary<string, object>
         {
             ["verbosity"] = verbosity,
@@ -273,25 +384,82 @@ public class UpdateEngine : IDisposable
             ["show_status"] = showStatus,
             ["skip_preflight"] = skipPreflight,
             ["skip_postflight"] = skipPostflight,
+            ["dry_run"] = dryRun,
+            ["precache"] = precache,
             ["manifest_target"] = manifestTarget ?? "",
             ["local_manifest"] = localManifest ?? "",
-            ["client_identifier"] = _config.ClientIdentifier
+            ["client_identifier"] = _config.ClientIdentifier,
+            ["persistence"] = _persistence.ReportValue,
+            ["machine_role"] = MachineRole.Normalize(_config.MachineRole) ?? _config.MachineRole,
+            ["catalog_override"] = _catalogOverride != null ? string.Join(",", _catalogOverride.Catalogs) : ""
         });
         
         // Bridge ConsoleLogger → SessionLogger so all output goes to log files
         ConsoleLogger.SetSessionLogger(_sessionLogger);
+
+        // StatusLogStreaming: the GUI's live log pane gets run.log as it's written
+        if (_statusReporter != null && _config.StatusLogStreaming)
+        {
+            _statusReporter.LogStreamLevel = _config.StatusLogStreamLevel;
+            _sessionLogger.LineLogged += _statusReporter.LogLine;
+        }
         
         // Pass session logger to services for structured logging
         _installerService.SetSessionLogger(_sessionLogger);
+        _manifestService.SetSessionLogger(_sessionLogger);
+
+        // Scripts get CIMIAN_SESSION_ID and friends so their output can be tied to this run
+        ScriptService.Session = new ScriptSessionInfo(sessionId, runType, _config.CachePath, _config.SoftwareRepoURL);
+        ScriptService.Settings = ScriptSettings.From(_config);
+        ScriptService.EventLogger = _sessionLogger;
+
+        // Helpers we spawn inherit CIMIAN_LOG_PIPE and log into this session
+        _logForwarder = new LogForwarder(_sessionLogger);
+        _logForwarder.Start();
         
         _sessionLogger.Log("INFO", $"Session started: {sessionId}");
         _sessionLogger.Log("INFO", $"Run type: {runType}");
+        _sessionLogger.Log("INFO", $"Machine role: {_config.MachineRole} (restart policy {_config.RestartPolicy})");
+        _sessionLogger.Log("INFO", $"Proxy: {_config.ProxySettings.Describe()}");
+        _sessionLogger.SetEnvironmentValue("proxy", _config.ProxySettings.Describe());
+
+        if (_catalogOverride != null)
+        {
+            var runsLeft = dryRun ? _catalogOverride.RunsRemaining : _catalogOverride.RunsRemaining - 1;
+            LogWarn($"Catalog override active: {string.Join(", ", _catalogOverride.Catalogs)} instead of Config.yaml catalogs " +
+                    $"({runsLeft} more run(s), set by {_catalogOverride.SetBy}; clear with --clear-catalog-override)");
+            _sessionLogger.Log("WARN", $"Catalog override: {_catalogOverride.Describe()}");
+        }
+
+        _configSnapshot = ConfigSnapshot.Take(CimianConfig.ConfigPath);
+
+        // Deep diagnostics (TraceDiagnostics / --trace): spans for every phase below
+        if (_config.TraceDiagnostics)
+        {
+            DiagnosticTrace.Start(sessionId);
+        }
 
         // Now that verbosity is set and the SessionLogger is attached, surface the
         // LoopGuard kill-switch so it reaches both the console and run.log.
         if (loopGuardDisabled)
             ConsoleLogger.Info("LoopGuard disabled by config (LoopGuardEnabled: false) — install-loop suppression is off");
 
+        if (_persistence.IsNonPersistent)
+        {
+            LogInfo($"Non-persistent machine ({_persistence.Platform ?? "configured"}: {_persistence.Reason}) - no cache kept, no self-update");
+        }
+
+        if (maintenanceWindowDeferred)
+        {
+            LogInfo($"Outside maintenance windows [{string.Join("; ", _config.MaintenanceWindows)}] - checking and precaching only");
+            _sessionLogger.Log("INFO", "Outside configured MaintenanceWindows - auto run limited to check and precache");
+        }
+        else if (_insideMaintenanceWindow)
+        {
+            LogInfo("Inside a configured maintenance window - installing regardless of user activity");
+            _sessionLogger.Log("INFO", "Inside configured MaintenanceWindows - active-user restriction lifted");
+        }
+
         try
         {
             // Report initial status
@@ -312,11 +480,12 @@ public class UpdateEngine : IDisposable
             // Ensure directories exist
             _configService.EnsureDirectoriesExist(_config);
 
-            // Clean pre-run directories
-            CleanManifestsAndCatalogsPreRun();
+            // Manifests and catalogs from the last run stay in place: they are
+            // revalidated with conditional GETs and are the fallback when offline
+            EnsureManifestsAndCatalogsDirs();
 
-            // Run preflight unless skipped
-            if (!skipPreflight && !_config.NoPreflight)
+            // Run preflight unless skipped. A dry run never executes scripts.
+            if (!skipPreflight && !_config.NoPreflight && !dryRun)
             {
                 LogInfo("----------------------------------------------------------------------");
                 LogInfo("PREFLIGHT EXECUTION");
@@ -333,27 +502,14 @@ public class UpdateEngine : IDisposable
                 }
 
                 // Reload configuration after preflight - preflight script may have updated config
-                // preflight sets SoftwareRepoURL, ClientIdentifier, etc.
-                _config = _configService.LoadConfig();
-                
-                // Apply verbosity settings again after reload
-                if (verbosity >= 1)
-                {
-                    _config.Verbose = true;
-                    _config.LogLevel = "INFO";
-                }
-                if (verbosity >= 3)
+                // preflight sets SoftwareRepoURL, ClientIdentifier, etc. Only reload if the
+                // file actually changed, and only adopt it if it reads stably and validates;
+                // a half-written push keeps the config this session started with.
+                if (_configSnapshot?.HasChanged() == true)
                 {
-                    _config.Debug = true;
-                    _config.LogLevel = "DEBUG";
+                    ReloadConfigAfterPreflight(verbosity);
                 }
-                
-                // Recreate services with updated config
-                _manifestService = new ManifestService(_config);
-                _catalogService = new CatalogService(_config);
-                _downloadService = new DownloadService(_config);
-                _installerService = new InstallerService(_config);
-                _installerService.SetSessionLogger(_sessionLogger);
+                _configSnapshot = ConfigSnapshot.Take(CimianConfig.ConfigPath);
             }
 
             // Go parity: Always log system configuration to run.log
@@ -366,17 +522,26 @@ public class UpdateEngine : IDisposable
             LogInfo("Retrieving manifests...");
             List<ManifestItem> manifestItems;
 
-            if (!string.IsNullOrEmpty(localManifest))
-            {
-                manifestItems = _manifestService.LoadLocalOnlyManifest(localManifest);
-            }
-            else if (!string.IsNullOrEmpty(manifestTarget))
-            {
-                manifestItems = await _manifestService.LoadSpecificManifestAsync(manifestTarget);
-            }
-            else
+            using (DiagnosticTrace.Begin("manifest", manifestTarget ?? localManifest ?? _config.ClientIdentifier))
             {
-                manifestItems = await _manifestService.GetManifestItemsAsync();
+                if (!string.IsNullOrEmpty(localManifest))
+                {
+                    manifestItems = _manifestService.LoadLocalOnlyManifest(localManifest);
+                }
+                else if (!string.IsNullOrEmpty(manifestTarget))
+                {
+                    manifestItems = await _manifestService.LoadSpecificManifestAsync(manifestTarget);
+                }
+                else
+                {
+                    manifestItems = await _manifestService.GetManifestItemsAsync();
+
+                    // A templated ClientIdentifier only names the manifest once expanded
+                    if (_sessionLogger != null && _manifestService.ResolvedManifest != null)
+                    {
+                        _sessionLogger.ManifestName = _manifestService.ResolvedManifest;
+                    }
+                }
             }
 
             // Go parity: pkg/status.DeduplicateManifestItems - deduplicate before processing
@@ -390,15 +555,27 @@ public class UpdateEngine : IDisposable
             LogInfo($"Retrieved {manifestItems.Count} manifest items");
             _allManifestItems = manifestItems;
 
+            // Manifest preflight_scripts run once the manifests say which apply
+            if (!skipPreflight && !_config.NoPreflight && !dryRun)
+            {
+                await RunManifestScriptsAsync(_manifestService.PreflightScripts, "preflight", cancellationToken);
+            }
+
             // Download and load catalogs
             LogInfo("----------------------------------------------------------------------");
             LogInfo("CATALOG LOADING");
             LogInfo("----------------------------------------------------------------------");
             ReportDetail("Loading catalogs...");
             LogInfo("Loading catalogs...");
-            var catalogMap = await _catalogService.LoadCatalogsAsync();
+            Dictionary<string, CatalogItem> catalogMap;
+            using (DiagnosticTrace.Begin("catalogs", string.Join(",", _config.Catalogs)))
+            {
+                catalogMap = await _catalogService.LoadCatalogsAsync();
+            }
             _catalogMap = catalogMap;
             LogInfo($"Loaded {catalogMap.Count} catalog items");
+            FlagDegradedIfOffline();
+            ResolveManifestAliases(manifestItems);
 
             // Validate cache
             ReportDetail("Validating cache...");
@@ -414,32 +591,88 @@ public class UpdateEngine : IDisposable
             // name. Surfaces in items.json as Warning + last_warning + status_reason_code,
             // and in a sibling reports/loop_suppressed.json for dashboards.
             var loopSuppressedByName = loopSuppressed.ToDictionary(
-                x => x.Item.Name.ToLowerInvariant(),
+                x => ItemKey.Canonical(x.Item.Name),
                 x => (x.Reason, x.InstalledVersion, x.WasUpdate));
 
-            // AutoRemove: queue uninstall for packages installed by Cimian but no longer in any manifest
-            if (_config.AutoRemove)
+            // RemoveUnmanagedItems (formerly AutoRemove): queue uninstall for packages installed
+            // by Cimian that no manifest accounts for any more, once UnmanagedItemGraceDays
+            // have passed. Not from a cached manifest: it may predate the item being assigned.
+            _unmanagedItems = new List<UnmanagedItem>();
+            if (_config.RemovesUnmanagedItems && !_degraded)
             {
-                var autoRemoveItems = IdentifyAutoRemoveItems(manifestItems, catalogMap);
-                if (autoRemoveItems.Count > 0)
+                var tracker = new UnmanagedItemTracker(_config.UnmanagedItemGraceDays);
+                _unmanagedItems = tracker.Track(IdentifyUnmanagedItems(manifestItems, catalogMap), DateTime.Now);
+                // A plan doesn't start or stop anyone's grace period
+                if (!dryRun)
+                {
+                    tracker.Save();
+                }
+
+                if (_unmanagedItems.Count > 0)
                 {
-                    ConsoleLogger.Info($"AutoRemove: {autoRemoveItems.Count} package(s) no longer in manifests");
-                    foreach (var item in autoRemoveItems)
+                    ConsoleLogger.Info($"RemoveUnmanagedItems: {_unmanagedItems.Count} package(s) no longer in manifests");
+                }
+                foreach (var unmanaged in _unmanagedItems)
+                {
+                    var item = unmanaged.Item;
+                    if (unmanaged.Due)
+                    {
+                        ConsoleLogger.Info($"    -> Removing unmanaged: {item.Name} v{item.Version} (not in any manifest since {unmanaged.FirstUnmanaged:yyyy-MM-dd})");
+                        _sessionLogger?.Log("INFO", $"RemoveUnmanagedItems: {item.Name} v{item.Version} no longer in any manifest; queuing uninstall");
+                        _sessionLogger?.LogStatusCheck(
+                            item.Name, item.Version, "pending",
+                            $"not in any manifest since {unmanaged.FirstUnmanaged:yyyy-MM-dd}",
+                            StatusReasonCode.UnmanagedRemoval,
+                            DetectionMethod.ManagedInstalls,
+                            needsAction: true);
+                        toUninstall.Add(item);
+                    }
+                    else
                     {
-                        ConsoleLogger.Info($"    -> Auto-removing: {item.Name} v{item.Version}");
-                        _sessionLogger?.Log("INFO", $"AutoRemove: {item.Name} v{item.Version} no longer in any manifest");
+                        ConsoleLogger.Info($"    -> Unmanaged: {item.Name} v{item.Version} will be removed after {unmanaged.RemoveAfter:yyyy-MM-dd HH:mm}");
+                        _sessionLogger?.LogStatusCheck(
+                            item.Name, item.Version, "installed",
+                            $"not in any manifest; removal after {unmanaged.RemoveAfter:yyyy-MM-dd HH:mm}",
+                            StatusReasonCode.UnmanagedGracePeriod,
+                            DetectionMethod.ManagedInstalls);
                     }
-                    toUninstall.AddRange(autoRemoveItems);
                 }
             }
 
+            // RemoveOrphanedDependencies: queue uninstall for items installed only because
+            // something required them, once nothing staying installed does. After the
+            // removals above, so what they leave orphaned goes in the same run.
+            _orphanedDependencies = new List<CatalogItem>();
+            if (_config.RemoveOrphanedDependencies && !_degraded)
+            {
+                var wanted = UnmanagedItemTracker.ManagedNames(
+                    manifestItems.Where(m => m.Action?.ToLowerInvariant() is "install" or "update"), catalogMap);
+                _orphanedDependencies = OrphanedDependencyFinder.Find(
+                    _installedItemsStore.Installed(), wanted, toUninstall.Select(i => i.Name), catalogMap);
+                if (_orphanedDependencies.Count > 0)
+                {
+                    ConsoleLogger.Info($"RemoveOrphanedDependencies: {_orphanedDependencies.Count} dependency package(s) no longer required");
+                }
+                foreach (var item in _orphanedDependencies)
+                {
+                    ConsoleLogger.Info($"    -> Removing orphaned dependency: {item.Name} v{item.Version}");
+                    _sessionLogger?.LogStatusCheck(
+                        item.Name, item.Version, "pending",
+                        "installed as a dependency; nothing installed requires it any more",
+                        StatusReasonCode.OrphanedDependency,
+                        DetectionMethod.ManagedInstalls,
+                        needsAction: true);
+                }
+                toUninstall.AddRange(_orphanedDependencies);
+            }
+
             // Stale-usage removal: queue uninstall for opted-in packages whose
             // tracked executables nobody on the device has used within
-            // unused_software_removal_info. Peer of AutoRemove, not a
+            // unused_software_removal_info. Peer of RemoveUnmanagedItems, not a
             // dependency walker — and placed before the downstream filters so
             // install_window / blocking_applications / unattended gating apply
             // to these uninstalls the same as any other.
-            if (_config.UsageStaleUninstallEnabled)
+            if (_config.UsageStaleUninstallEnabled && !_degraded)
             {
                 // Resolved here rather than in the constructor: preflight can
                 // reload _config, and the source's lazy snapshot should reflect
@@ -506,7 +739,11 @@ public class UpdateEngine : IDisposable
             // Walks both `requires` and `update_for` to the transitive closure so that a stale
             // dep gets surfaced even when its parent is already at the catalog version
             // (e.g. ManageUsersPrefs current, ManageUsers stale).
-            ResolveDependencies(manifestItems, catalogMap, toUpdate, itemFilterService);
+            ResolveDependencies(manifestItems, catalogMap, toInstall, toUpdate, itemFilterService);
+
+            // Icons for everything the GUIs may show, before InstallInfo points at them.
+            // A dry run writes no InstallInfo, so it leaves the icon cache alone too.
+            if (!_degraded && !dryRun) await SyncIconsAsync(manifestItems, catalogMap, toInstall, toUpdate, toUninstall, cancellationToken);
 
             // Print hierarchy and tables in checkonly mode (matches Go behavior - always shows this)
             if (_checkOnly)
@@ -529,6 +766,8 @@ public class UpdateEngine : IDisposable
             // Exit if check-only mode
             if (_checkOnly)
             {
+                await ProcessProfilesAsync(manifestItems, itemFilterService, cancellationToken);
+
                 sessionStopwatch.Stop();
                 LogInfo("----------------------------------------------------------------------");
                 LogInfo("SESSION COMPLETE");
@@ -552,16 +791,24 @@ public class UpdateEngine : IDisposable
                 return 0;
             }
 
+            // Degraded: only what can finish without the repo
+            if (_degraded)
+            {
+                RestrictToCachedInstalls(toInstall, toUpdate, toUninstall);
+            }
+
             // Filter out items outside their install_window (applies to installs, updates, and uninstalls)
             // Exception: force_install_after_date overrides install_window — if deadline has passed, install anyway
             var deferredItems = new List<CatalogItem>();
+            _deferralReasons.Clear();
             var now = DateTime.Now;
             foreach (var list in new[] { toInstall, toUpdate, toUninstall })
             {
                 for (int i = list.Count - 1; i >= 0; i--)
                 {
                     var item = list[i];
-                    if (item.InstallWindow != null && !item.InstallWindow.IsWithinWindow(now))
+                    // A precache run fetches ahead of the window so the install inside it is fast
+                    if (!_precache && item.InstallWindow != null && !item.InstallWindow.IsWithinWindow(now))
                     {
                         // Deadline override: force_install_after_date takes priority over install_window
                         if (item.ForceInstallAfterDate != null && now >= item.ForceInstallAfterDate.Value)
@@ -583,6 +830,7 @@ public class UpdateEngine : IDisposable
                             Cimian.Core.Models.StatusReasonCode.DeferredInstallWindow,
                             Cimian.Core.Models.DetectionMethod.None, null, false);
                         deferredItems.Add(item);
+                        _deferralReasons.Add((item, $"outside install window {item.InstallWindow}"));
                         list.RemoveAt(i);
                     }
                 }
@@ -592,6 +840,41 @@ public class UpdateEngine : IDisposable
                 LogInfo($"{deferredItems.Count} item(s) deferred due to install_window restrictions");
             }
 
+            // Per-item: "Defer" from a user's toast postpones that item for this
+            // auto run only. It spends one of the item's deferrals, so only items
+            // with max_deferrals or force_install_after_date can be put off.
+            if (_auto && !_precache)
+            {
+                var requested = DeferralRequestStore.Read(now).Select(r => r.Item).ToHashSet(ItemKey.Comparer);
+                foreach (var list in new[] { toInstall, toUpdate })
+                {
+                    for (int i = list.Count - 1; i >= 0; i--)
+                    {
+                        var item = list[i];
+                        if (!requested.Contains(item.Name) || !DeferralService.TracksDeferrals(item)
+                            || ForceInsteadOfDeferring(item, now, "deferred by the user"))
+                        {
+                            continue;
+                        }
+
+                        RecordUserDeferral(item, "deferred by the user");
+                        LogInfo($"Deferred: {item.Name} v{item.Version} (deferred by the user)");
+                        _sessionLogger?.Log("INFO", $"Deferred {item.Name} v{item.Version}: requested by the user");
+                        _sessionLogger?.LogStatusCheck(
+                            item.Name, item.Version, "deferred",
+                            "Deferred by the user",
+                            Cimian.Core.Models.StatusReasonCode.UserDeferred,
+                            Cimian.Core.Models.DetectionMethod.None, null, true);
+                        _deferralReasons.Add((item, "deferred by the user"));
+                        list.RemoveAt(i);
+                    }
+                }
+                if (requested.Count > 0 && !dryRun)
+                {
+                    DeferralRequestStore.Clear();
+                }
+            }
+
             // Per-item: defer items whose blocking_applications are running.
             // Installing while the blocking app is open would fail or destroy
             // the user's open work. Always applied — independent of mode/user.
@@ -608,6 +891,17 @@ public class UpdateEngine : IDisposable
                     if (StatusService.CheckBlockingApps(item.BlockingApps, runningProcessNames, out var running))
                     {
                         var runningList = string.Join(", ", running);
+                        if (ForceInsteadOfDeferring(item, now, $"blocking applications running: {runningList}"))
+                        {
+                            continue;
+                        }
+                        // BlockingAppTimeout: keep the install; the install pass asks the user to close them
+                        if (_config.BlockingAppTimeout > 0 && !_precache && list != toUninstall)
+                        {
+                            LogInfo($"{item.Name} v{item.Version} waits up to {_config.BlockingAppTimeout}s for {runningList} to close");
+                            continue;
+                        }
+                        RecordUserDeferral(item, $"blocking applications running: {runningList}");
                         LogInfo($"Deferred: {item.Name} v{item.Version} (blocking applications running: {runningList})");
                         _sessionLogger?.Log("INFO", $"Deferred {item.Name} v{item.Version}: blocking applications running ({runningList})");
                         _sessionLogger?.LogStatusCheck(
@@ -616,6 +910,7 @@ public class UpdateEngine : IDisposable
                             Cimian.Core.Models.StatusReasonCode.BlockingApps,
                             Cimian.Core.Models.DetectionMethod.None, null, true);
                         blockedItems.Add(item);
+                        _deferralReasons.Add((item, $"blocking applications running: {runningList}"));
                         list.RemoveAt(i);
                     }
                 }
@@ -625,6 +920,35 @@ public class UpdateEngine : IDisposable
                 LogInfo($"{blockedItems.Count} item(s) deferred while blocking applications are running");
             }
 
+            // Per-item: install_context: user needs someone at the console to
+            // install for. Not a user deferral; the item goes in on a later run.
+            if (!_precache && toInstall.Concat(toUpdate).Any(i => i.InstallsAsUser)
+                && UserContextRunner.GetActiveUserName() == null)
+            {
+                foreach (var list in new[] { toInstall, toUpdate })
+                {
+                    for (int i = list.Count - 1; i >= 0; i--)
+                    {
+                        var item = list[i];
+                        if (!item.InstallsAsUser) continue;
+
+                        LogInfo($"Deferred: {item.Name} v{item.Version} (install_context: user and no user is logged on)");
+                        _sessionLogger?.LogStatusCheck(
+                            item.Name, item.Version, "deferred",
+                            "Installs as the user; no user is logged on",
+                            Cimian.Core.Models.StatusReasonCode.NoActiveUser,
+                            Cimian.Core.Models.DetectionMethod.None, null, false);
+                        _deferralReasons.Add((item, "no user is logged on to install for"));
+                        list.RemoveAt(i);
+                    }
+                }
+            }
+
+            // Machine preconditions: battery charge, metered network and free
+            // disk space, before anything is downloaded. Not a user deferral;
+            // the items go in on a later run once the machine can take them.
+            SkipItemsFailingPreconditions(toInstall, toUpdate);
+
             // Auto mode + active user: restrict to items that can run silently
             // without disrupting the session. An item is eligible only if it is
             // marked unattended AND its restart_action would not reboot or log
@@ -632,7 +956,7 @@ public class UpdateEngine : IDisposable
             // disruptive here). Everything else is deferred to a later run
             // (idle machine, interactive run, or scheduled maintenance window).
             var deferredForUser = new List<CatalogItem>();
-            if (_auto && StatusService.IsUserActive())
+            if ((_auto || _precache) && !_insideMaintenanceWindow && StatusService.IsUserActive())
             {
                 LogInfo($"User is active (idle: {StatusService.GetIdleSeconds()}s) - restricting to unattended items that won't disrupt the session");
                 _sessionLogger?.Log("INFO", "User is active - restricting auto run to unattended, non-disruptive items");
@@ -649,11 +973,17 @@ public class UpdateEngine : IDisposable
                         }
                         else if (WouldInterruptUser(item))
                         {
-                            deferReason = $"restart_action '{item.RestartAction}' would interrupt the active user";
+                            deferReason = $"restart_action '{item.EffectiveRestartAction}' would interrupt the active user";
+                        }
+
+                        if (deferReason != null && ForceInsteadOfDeferring(item, now, deferReason))
+                        {
+                            deferReason = null;
                         }
 
                         if (deferReason != null)
                         {
+                            RecordUserDeferral(item, deferReason);
                             LogInfo($"Deferred install of {item.Name} v{item.Version}: {deferReason}");
                             _sessionLogger?.Log("INFO", $"Deferred {item.Name} v{item.Version}: {deferReason} (auto mode, user active)");
                             _sessionLogger?.LogStatusCheck(
@@ -662,6 +992,7 @@ public class UpdateEngine : IDisposable
                                 Cimian.Core.Models.StatusReasonCode.DeferredUserActive,
                                 Cimian.Core.Models.DetectionMethod.None, null, true);
                             deferredForUser.Add(item);
+                            _deferralReasons.Add((item, deferReason));
                             list.RemoveAt(i);
                         }
                     }
@@ -677,11 +1008,17 @@ public class UpdateEngine : IDisposable
                     }
                     else if (WouldInterruptUser(item))
                     {
-                        deferReason = $"restart_action '{item.RestartAction}' would interrupt the active user";
+                        deferReason = $"restart_action '{item.EffectiveRestartAction}' would interrupt the active user";
+                    }
+
+                    if (deferReason != null && ForceInsteadOfDeferring(item, now, deferReason))
+                    {
+                        deferReason = null;
                     }
 
                     if (deferReason != null)
                     {
+                        RecordUserDeferral(item, deferReason);
                         LogInfo($"Deferred removal of {item.Name} v{item.Version}: {deferReason}");
                         _sessionLogger?.Log("INFO", $"Deferred removal of {item.Name} v{item.Version}: {deferReason} (auto mode, user active)");
                         _sessionLogger?.LogStatusCheck(
@@ -690,6 +1027,7 @@ public class UpdateEngine : IDisposable
                             Cimian.Core.Models.StatusReasonCode.DeferredUserActive,
                             Cimian.Core.Models.DetectionMethod.None, null, true);
                         deferredForUser.Add(item);
+                        _deferralReasons.Add((item, deferReason));
                         toUninstall.RemoveAt(i);
                     }
                 }
@@ -700,10 +1038,32 @@ public class UpdateEngine : IDisposable
                 }
             }
 
+            // A plan or a precache pass doesn't spend anyone's deferrals
+            if (!dryRun && !_precache)
+            {
+                _deferrals.Save();
+            }
+
+            // Dry run: the full decision pipeline has run (dependencies, deferrals,
+            // blocking apps); emit the plan instead of downloading or installing.
+            if (dryRun)
+            {
+                return WriteDryRunPlan(manifestItems, toInstall, toUpdate, toUninstall, catalogMap,
+                    _deferralReasons, planOutputPath, sessionStopwatch);
+            }
+
+            if (_precache)
+            {
+                return await RunPrecacheAsync(manifestItems, toInstall, toUpdate, toUninstall, catalogMap,
+                    sessionStopwatch, cancellationToken);
+            }
+
             // Precache: download optional items marked with precache=true
             // This runs before installations so precached items are ready if the user requests them
             await PrecacheOptionalItemsAsync(manifestItems, catalogMap, cancellationToken);
 
+            WarnIfConfigChanged("before installs");
+
             // Perform installations
             var installSuccess = true;
             var successCount = 0;
@@ -734,6 +1094,16 @@ public class UpdateEngine : IDisposable
                     }
                 }
                 
+                // Non-persistent machines would lose the scheduled update at reboot;
+                // Cimian is updated in the golden image instead
+                if (selfUpdateItems.Count > 0 && _persistence.IsNonPersistent)
+                {
+                    LogInfo($"Skipping {selfUpdateItems.Count} Cimian self-update package(s) on a non-persistent machine");
+                    _sessionLogger?.Log("INFO", $"Self-update skipped (non-persistent): {string.Join(", ", selfUpdateItems.Select(i => $"{i.Name} v{i.Version}"))}");
+                    selfUpdateItems.Clear();
+                    allToInstall = regularItems;
+                }
+
                 // Handle self-updates by scheduling them for next restart
                 if (selfUpdateItems.Count > 0)
                 {
@@ -755,18 +1125,73 @@ public class UpdateEngine : IDisposable
                             _sessionLogger?.Log("ERROR", $"Failed to download self-update package: {item.Name}");
                             continue;
                         }
+
+                        // The agent replaces itself from this file as SYSTEM, so the
+                        // catalog hash is checked whatever RequireHashValidation says,
+                        // and it must be signed by the expected publisher on top
+                        var payload = DownloadService.VerifyPayload(item, localFile, requireHash: true);
+                        if (payload.Status != PayloadVerificationStatus.Valid)
+                        {
+                            var hashDetails = payload.Status == PayloadVerificationStatus.MissingHash
+                                ? "catalog entry has no hash to check it against"
+                                : $"hash {payload.ActualHash ?? "unavailable"} doesn't match the catalog's {payload.ExpectedHash}";
+                            ConsoleLogger.Error($"Refusing self-update {item.Name} v{item.Version}: {hashDetails}");
+                            LogSelfUpdateRefusedEvent(item, localFile,
+                                payload.Status == PayloadVerificationStatus.MissingHash ? StatusReasonCode.HashMissing : StatusReasonCode.HashMismatch,
+                                hashDetails,
+                                new Dictionary<string, object>
+                                {
+                                    ["expected_hash"] = payload.ExpectedHash ?? "",
+                                    ["actual_hash"] = payload.ActualHash ?? ""
+                                });
+                            continue;
+                        }
+
+                        var expectedSigner = InstallerService.ExpectedSelfUpdateSigner(_config, item);
+                        if (expectedSigner == null)
+                        {
+                            ConsoleLogger.Warn($"Self-update {item.Name}: no SelfUpdateExpectedSigner or expected_signer set; any trusted signature is accepted");
+                        }
+                        var (signatureValid, signatureDetails) = _installerService.VerifySelfUpdateSignature(item, localFile);
+                        if (!signatureValid)
+                        {
+                            if (_config.SelfUpdateRequireSignature)
+                            {
+                                ConsoleLogger.Error($"Refusing self-update {item.Name} v{item.Version}: {signatureDetails} (SelfUpdateRequireSignature is on)");
+                                LogSelfUpdateRefusedEvent(item, localFile, StatusReasonCode.SignatureInvalid, signatureDetails,
+                                    new Dictionary<string, object>
+                                    {
+                                        ["expected_signer"] = expectedSigner ?? "",
+                                        ["hash"] = payload.ActualHash ?? ""
+                                    });
+                                continue;
+                            }
+                            ConsoleLogger.Warn($"Self-update {item.Name} v{item.Version} signature check failed: {signatureDetails} (SelfUpdateRequireSignature is off)");
+                            _sessionLogger?.Log("WARN", $"Self-update signature check failed: {item.Name}: {signatureDetails}");
+                        }
                         
                         // Schedule the self-update for next service restart
                         var scheduled = SelfUpdateService.ScheduleSelfUpdate(
                             item.Name, 
                             item.Version, 
                             item.Installer.Type ?? "pkg", 
-                            localFile);
+                            localFile,
+                            item.SelfUpdateChannel ?? "stable");
                         
                         if (scheduled)
                         {
                             LogSuccess($"Self-update scheduled: {item.Name} v{item.Version}");
                             _sessionLogger?.Log("INFO", $"Self-update scheduled successfully: {item.Name} v{item.Version}");
+                            _sessionLogger?.LogEvent(new LogEvent
+                            {
+                                EventType = "self_update",
+                                PackageName = item.Name,
+                                PackageVersion = item.Version,
+                                Action = "self_update",
+                                Status = "scheduled",
+                                Message = "Self-update scheduled for the next service restart",
+                                InstallerType = item.Installer.Type
+                            });
                         }
                         else
                         {
@@ -813,15 +1238,26 @@ public class UpdateEngine : IDisposable
                 await CleanUpSelfServeUninstallsAsync(uninstallOutcomes);
             }
 
+            var profilesSuccess = await ProcessProfilesAsync(manifestItems, itemFilterService, cancellationToken);
+
             // Combine install + uninstall outcomes keyed by lower-invariant name so
             // CollectSessionItems can stamp each manifest item with its real result.
             var outcomesByName = new Dictionary<string, ItemOutcome>(StringComparer.OrdinalIgnoreCase);
-            foreach (var o in installOutcomes) outcomesByName[o.Name.ToLowerInvariant()] = o;
-            foreach (var o in uninstallOutcomes) outcomesByName[o.Name.ToLowerInvariant()] = o;
+            foreach (var o in installOutcomes) outcomesByName[ItemKey.Canonical(o.Name)] = o;
+            foreach (var o in uninstallOutcomes) outcomesByName[ItemKey.Canonical(o.Name)] = o;
+            _outcomes = installOutcomes.Concat(uninstallOutcomes).ToList();
+
+            WarnIfConfigChanged("during installs");
+
+            // Keep the cache inside CacheSizeLimitMB / CacheRetentionDays now that
+            // this run's payloads have been used
+            EvictCache(catalogMap);
 
             // Run postflight unless skipped
             if (!skipPostflight && !_config.NoPostflight)
             {
+                await RunManifestScriptsAsync(_manifestService.PostflightScripts, "postflight", cancellationToken);
+
                 LogInfo("----------------------------------------------------------------------");
                 LogInfo("POSTFLIGHT EXECUTION");
                 LogInfo("----------------------------------------------------------------------");
@@ -846,7 +1282,7 @@ public class UpdateEngine : IDisposable
             LogInfo("SESSION COMPLETE");
             LogInfo($"Total duration: {sessionStopwatch.Elapsed.TotalSeconds:F1}s");
             LogInfo("----------------------------------------------------------------------");
-            if (installSuccess && uninstallSuccess)
+            if (installSuccess && uninstallSuccess && profilesSuccess)
             {
                 LogSuccess("All operations completed successfully");
                 _sessionLogger?.Log("INFO", "All operations completed successfully");
@@ -863,7 +1299,7 @@ public class UpdateEngine : IDisposable
                     toInstall.Count + toUpdate.Count + toUninstall.Count, 0, manifestItems);
                 
                 // Handle restart_action: restart takes precedence over logout (Munki parity)
-                if (_restartNeeded)
+                if (_restartNeeded || File.Exists(CimianPaths.RestartDeferredFlagFile))
                 {
                     PerformRestartAction();
                 }
@@ -890,7 +1326,7 @@ public class UpdateEngine : IDisposable
                     successCount, failCount, manifestItems);
 
                 // Even on partial failure, honor restart/logout if any successful item required it
-                if (_restartNeeded)
+                if (_restartNeeded || File.Exists(CimianPaths.RestartDeferredFlagFile))
                 {
                     PerformRestartAction();
                 }
@@ -918,19 +1354,142 @@ public class UpdateEngine : IDisposable
                 TotalActions = 0,
                 Failures = 1,
                 PackagesHandled = new List<string>()
-            });
+            }, $"Update failed: {ex.Message}");
             return 1;
         }
         finally
         {
+            // Flush the diagnostic trace while ConsoleLogger still reaches run.log
+            DiagnosticTrace.Stop();
+
             // Detach ConsoleLogger from SessionLogger before disposing
             ConsoleLogger.SetSessionLogger(null);
             // Always send quit and dispose resources
             _statusReporter?.Dispose();
+            ConsoleLogger.ProgressDisplay = null;
+            _progressDisplay?.Dispose();
+            _logForwarder?.Dispose();
             _sessionLogger?.Dispose();
         }
     }
 
+    /// <summary>
+    /// Ends a --precache run: downloads every pending install/update (plus
+    /// precache: true optional items) into the cache and stops. Items held back by
+    /// blocking applications or an active user were already filtered out like in
+    /// an install run; install_window deferrals were not, since fetching ahead of
+    /// the window is the point. Removals are left for the install run.
+    /// </summary>
+    private async Task<int> RunPrecacheAsync(
+        List<ManifestItem> manifestItems,
+        List<CatalogItem> toInstall,
+        List<CatalogItem> toUpdate,
+        List<CatalogItem> toUninstall,
+        Dictionary<string, CatalogItem> catalogMap,
+        System.Diagnostics.Stopwatch sessionStopwatch,
+        CancellationToken cancellationToken)
+    {
+        var pending = toInstall.Concat(toUpdate)
+            .Where(i => !string.IsNullOrEmpty(i.Installer?.Location))
+            .ToList();
+
+        LogInfo("----------------------------------------------------------------------");
+        LogInfo("PRECACHING PENDING UPDATES");
+        LogInfo("----------------------------------------------------------------------");
+        LogInfo($"Downloading {pending.Count} pending item(s) without installing...");
+        _sessionLogger?.Log("INFO", $"Precache run: downloading {pending.Count} pending items");
+        ReportStatus("Downloading updates...");
+
+        var downloads = pending.Count > 0
+            ? await _downloadService.DownloadItemsAsync(pending, null, cancellationToken)
+            : new Dictionary<string, string>();
+
+        var failed = pending.Where(i => !downloads.ContainsKey(i.Name)).ToList();
+        foreach (var item in pending)
+        {
+            if (downloads.TryGetValue(item.Name, out var path))
+            {
+                LogInfo($"    Precached: {item.Name} v{item.Version} -> {path}");
+            }
+            else
+            {
+                ConsoleLogger.Warn($"Failed to precache {item.Name} v{item.Version}");
+                _sessionLogger?.Log("WARN", $"Failed to precache {item.Name} v{item.Version}");
+            }
+        }
+
+        await PrecacheOptionalItemsAsync(manifestItems, catalogMap, cancellationToken);
+
+        if (toUninstall.Count > 0)
+        {
+            LogInfo($"{toUninstall.Count} pending removal(s) left for the next install run");
+        }
+
+        // InstallInfo picks up the precached flags for the GUI
+        WriteInstallInfo(manifestItems, toInstall, toUpdate, toUninstall, catalogMap);
+
+        sessionStopwatch.Stop();
+        LogInfo("----------------------------------------------------------------------");
+        LogInfo("SESSION COMPLETE");
+        LogInfo($"Total duration: {sessionStopwatch.Elapsed.TotalSeconds:F1}s");
+        LogInfo("----------------------------------------------------------------------");
+        LogInfo($"Precache complete: {pending.Count - failed.Count} downloaded, {failed.Count} failed - nothing installed");
+        ReportStatus("Precache complete");
+        ReportPercent(100);
+
+        EndSessionWithSummary(failed.Count == 0 ? "completed" : "partial_failure",
+            0, 0, 0, pending.Count - failed.Count, failed.Count, manifestItems);
+        return failed.Count == 0 ? 0 : 1;
+    }
+
+    /// <summary>
+    /// Ends a --dry-run: builds the JSON plan, writes it to planOutputPath (or
+    /// reports\dry_run_plan.json) and echoes it to stdout for CI. Nothing is
+    /// downloaded, installed, or recorded in InstallInfo/items.json.
+    /// </summary>
+    private int WriteDryRunPlan(
+        List<ManifestItem> manifestItems,
+        List<CatalogItem> toInstall,
+        List<CatalogItem> toUpdate,
+        List<CatalogItem> toUninstall,
+        Dictionary<string, CatalogItem> catalogMap,
+        List<(CatalogItem Item, string Reason)> deferred,
+        string? planOutputPath,
+        System.Diagnostics.Stopwatch sessionStopwatch)
+    {
+        var planner = new DryRunPlanner(_config, _downloadService);
+        var plan = planner.Build(manifestItems.Count, toInstall, toUpdate, toUninstall, catalogMap, deferred);
+        var json = DryRunPlanner.ToJson(plan);
+
+        var path = planOutputPath ?? Path.Combine(CimianPaths.ReportsDir, "dry_run_plan.json");
+        try
+        {
+            var dir = Path.GetDirectoryName(path);
+            if (!string.IsNullOrEmpty(dir))
+            {
+                Directory.CreateDirectory(dir);
+            }
+            File.WriteAllText(path, json);
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Error($"Failed to write dry-run plan to {path}: {ex.Message}");
+            return 1;
+        }
+
+        sessionStopwatch.Stop();
+        LogInfo("----------------------------------------------------------------------");
+        LogInfo("DRY RUN COMPLETE");
+        LogInfo($"Total duration: {sessionStopwatch.Elapsed.TotalSeconds:F1}s");
+        LogInfo("----------------------------------------------------------------------");
+        LogInfo($"Dry run - no actions performed. {plan.Actions.Count} planned, {plan.Deferred.Count} deferred");
+        LogInfo($"Plan written to {path}");
+        Console.WriteLine(json);
+
+        EndSessionWithSummary("completed", toInstall.Count, toUpdate.Count, toUninstall.Count, 0, 0, manifestItems);
+        return plan.Actions.Any(a => a.Warnings.Count > 0) ? 2 : 0;
+    }
+
     private (List<CatalogItem> ToInstall, List<CatalogItem> ToUpdate, List<CatalogItem> ToUninstall,
              List<(CatalogItem Item, string Reason, string? InstalledVersion, bool WasUpdate)> LoopSuppressed)
         IdentifyActions(List<ManifestItem> manifestItems, Dictionary<string, CatalogItem> catalogMap,
@@ -966,7 +1525,7 @@ public class UpdateEngine : IDisposable
                 continue;
             }
 
-            var key = item.Name.ToLowerInvariant();
+            var key = ItemKey.Canonical(item.Name);
             
             if (!catalogMap.TryGetValue(key, out var catalogItem))
             {
@@ -977,7 +1536,7 @@ public class UpdateEngine : IDisposable
             }
 
             // Check architecture compatibility
-            if (!CatalogService.SupportsArchitecture(catalogItem, sysArch))
+            if (!CatalogService.SupportsArchitecture(catalogItem, sysArch, !_config.ForbidEmulatedInstalls))
             {
                 ConsoleLogger.Info($"Skipping {item.Name}: architecture mismatch (system: {sysArch}, item version: {catalogItem.Version}, item arch: [{string.Join(",", catalogItem.SupportedArch)}])");
                 continue;
@@ -994,15 +1553,7 @@ public class UpdateEngine : IDisposable
                     if (!IsEligibleForOsVersion(catalogItem, out var osReason, out var osReasonCode))
                     {
                         ConsoleLogger.Info($"Skipping {item.Name}: {osReason}");
-                        _sessionLogger?.LogStatusCheck(
-                            catalogItem.Name,
-                            catalogItem.Version,
-                            "skipped",
-                            osReason,
-                            osReasonCode,
-                            DetectionMethod.None,
-                            null,
-                            false);
+                        LogOsVersionGated(catalogItem, osReason, osReasonCode);
                         break;
                     }
 
@@ -1046,8 +1597,11 @@ public class UpdateEngine : IDisposable
                         // Recurring items bypass for the same reason: idempotent maintenance
                         // scripts (cache clears, time sync, account checks) are meant to run
                         // every session, so their repeated same-version runs are not a loop.
+                        // Configuration items remediate whenever they drift, which can be
+                        // every run, and a remediation that doesn't stick already fails.
                         var bypassLoopGuard = catalogItem.OnDemand
                             || catalogItem.Recurring
+                            || catalogItem.IsConfigurationItem
                             || (itemFilterService != null
                                 && itemFilterService.HasFilter
                                 && itemFilterService.Items.Contains(catalogItem.Name));
@@ -1055,7 +1609,8 @@ public class UpdateEngine : IDisposable
                         if (bypassLoopGuard)
                         {
                             var bypassReason = catalogItem.OnDemand ? "OnDemand"
-                                : catalogItem.Recurring ? "recurring" : "--item";
+                                : catalogItem.Recurring ? "recurring"
+                                : catalogItem.IsConfigurationItem ? "configuration item" : "--item";
                             var msg = $"{bypassReason}: bypassing LoopGuard for '{catalogItem.Name}'";
                             ConsoleLogger.Info(msg);
                             _sessionLogger?.Log("INFO", msg);
@@ -1106,15 +1661,7 @@ public class UpdateEngine : IDisposable
                         if (!IsEligibleForOsVersion(catalogItem, out var optOsReason, out var optOsReasonCode))
                         {
                             ConsoleLogger.Info($"Skipping forced optional {item.Name}: {optOsReason}");
-                            _sessionLogger?.LogStatusCheck(
-                                catalogItem.Name,
-                                catalogItem.Version,
-                                "skipped",
-                                optOsReason,
-                                optOsReasonCode,
-                                DetectionMethod.None,
-                                null,
-                                false);
+                            LogOsVersionGated(catalogItem, optOsReason, optOsReasonCode);
                             break;
                         }
 
@@ -1161,6 +1708,13 @@ public class UpdateEngine : IDisposable
                     break;
 
                 case "uninstall":
+                    // A user's removal request the policy doesn't allow stays in the
+                    // SelfServeManifest and is carried out once the policy allows it.
+                    if (item.IsSelfServe && !IsSelfServiceUninstallAllowed(_config.AllowSelfServiceUninstall, catalogItem))
+                    {
+                        LogInfo($"Self-serve: holding removal request for {item.Name} (AllowSelfServiceUninstall: {_config.AllowSelfServiceUninstall})");
+                        break;
+                    }
                     if (catalogItem.IsUninstallable())
                     {
                         toUninstall.Add(catalogItem);
@@ -1168,6 +1722,9 @@ public class UpdateEngine : IDisposable
                     break;
 
                 case "profile":
+                    // Applied after installs by ProcessProfilesAsync
+                    break;
+
                 case "app":
                     // External MDM management - skip
                     ConsoleLogger.Detail($"    Skipping external item: {item.Name} (action: {item.Action})");
@@ -1179,54 +1736,160 @@ public class UpdateEngine : IDisposable
     }
 
     /// <summary>
-    /// Identifies packages installed by Cimian (in ManagedInstalls registry) that are no longer
-    /// referenced in any manifest. These are candidates for automatic removal.
+    /// managed_profiles: fetches each profile from the repo's profiles/ directory,
+    /// brings its settings into line and puts back the settings of profiles no
+    /// manifest lists any more. Check-only runs only report what would change.
+    /// Returns false when a profile couldn't be fully applied or removed.
     /// </summary>
-    private List<CatalogItem> IdentifyAutoRemoveItems(
-        List<ManifestItem> manifestItems, Dictionary<string, CatalogItem> catalogMap)
+    private async Task<bool> ProcessProfilesAsync(
+        List<ManifestItem> manifestItems,
+        ItemFilterService itemFilterService,
+        CancellationToken cancellationToken)
     {
-        var autoRemove = new List<CatalogItem>();
-
-        var manifestedNames = new HashSet<string>(
-            manifestItems.Select(m => m.Name).Where(n => !string.IsNullOrEmpty(n)),
-            StringComparer.OrdinalIgnoreCase);
-
+        var names = manifestItems
+            .Where(m => string.Equals(m.Action, "profile", StringComparison.OrdinalIgnoreCase))
+            .Where(m => !itemFilterService.HasFilter || itemFilterService.Items.Contains(m.Name))
+            .Select(m => m.Name)
+            .Distinct(StringComparer.OrdinalIgnoreCase)
+            .ToList();
+        var store = new ManagedProfilesStore();
+        if (names.Count == 0 && store.All().Count == 0)
+        {
+            return true;
+        }
+
+        LogInfo("----------------------------------------------------------------------");
+        LogInfo("MANAGED PROFILES");
+        LogInfo("----------------------------------------------------------------------");
+        var profileService = new ProfileService(_config, _scriptService, store: store);
+        var outcomes = await profileService.ApplyAsync(
+            await profileService.FetchAsync(names, cancellationToken), _checkOnly, DateTime.Now, cancellationToken);
+
+        // Removal needs every manifest: a degraded or --item run can't tell a
+        // dropped profile from one it didn't look at
+        if (!_degraded && !itemFilterService.HasFilter)
+        {
+            outcomes.AddRange(await profileService.RemoveAsync(names, _checkOnly, cancellationToken));
+        }
+
+        foreach (var outcome in outcomes)
+        {
+            var label = string.IsNullOrEmpty(outcome.Version) ? outcome.Name : $"{outcome.Name} v{outcome.Version}";
+            switch (outcome.Action)
+            {
+                case ProfileOutcome.Compliant:
+                    LogInfo($"Profile {label}: compliant");
+                    break;
+                case ProfileOutcome.WouldApply:
+                    LogInfo($"Profile {label}: {outcome.Changes.Count} setting(s) would change");
+                    break;
+                case ProfileOutcome.WouldRemove:
+                    LogInfo($"Profile {label}: no longer managed, {outcome.Changes.Count} setting(s) would be restored");
+                    break;
+                case ProfileOutcome.Applied when outcome.Success:
+                    LogSuccess($"Applied profile {label} ({outcome.Changes.Count} setting(s) changed)");
+                    break;
+                case ProfileOutcome.Removed when outcome.Success:
+                    LogSuccess($"Removed profile {label} ({outcome.Changes.Count} setting(s) restored)");
+                    break;
+            }
+            foreach (var change in outcome.Changes)
+            {
+                LogDetail($"    {change}");
+            }
+            foreach (var error in outcome.Errors)
+            {
+                LogError($"Profile {outcome.Name}: {error}");
+            }
+
+            if (!_checkOnly)
+            {
+                _sessionLogger?.LogEvent(new LogEvent
+                {
+                    Level = outcome.Success ? "INFO" : "ERROR",
+                    EventType = "profile",
+                    PackageName = outcome.Name,
+                    PackageVersion = outcome.Version,
+                    Action = outcome.Action,
+                    Status = outcome.Success ? "completed" : "failed",
+                    Message = outcome.Success
+                        ? $"{outcome.Changes.Count} setting(s) changed"
+                        : string.Join("; ", outcome.Errors)
+                });
+            }
+        }
+
+        return outcomes.All(o => o.Success);
+    }
+
+    /// <summary>
+    /// Renames manifest entries that still use an item's old name (a pkginfo
+    /// alias) to the current catalog name, so everything downstream — status,
+    /// receipts, items.json — sees one name per item.
+    /// </summary>
+    private void ResolveManifestAliases(List<ManifestItem> manifestItems)
+    {
+        foreach (var item in manifestItems)
+        {
+            var resolved = _catalogService.ResolveAlias(item.Name);
+            if (!ItemKey.Comparer.Equals(resolved, item.Name))
+            {
+                LogInfo($"Manifest item {item.Name} is an alias of {resolved} (from {item.SourceManifest}); update the manifest to the new name");
+                item.Name = resolved;
+            }
+        }
+    }
+
+    /// <summary>
+    /// Identifies packages installed by Cimian (in ManagedInstalls registry) that no manifest
+    /// accounts for any more, directly or as a dependency or update of a manifest item.
+    /// These are candidates for RemoveUnmanagedItems.
+    /// </summary>
+    private List<CatalogItem> IdentifyUnmanagedItems(
+        List<ManifestItem> manifestItems, Dictionary<string, CatalogItem> catalogMap)
+    {
+        var unmanaged = new List<CatalogItem>();
+
+        var managedNames = UnmanagedItemTracker.ManagedNames(manifestItems, catalogMap);
+
         try
         {
             using var managedKey = Microsoft.Win32.Registry.LocalMachine.OpenSubKey(
                 @"SOFTWARE\ManagedInstalls");
-            if (managedKey == null) return autoRemove;
+            if (managedKey == null) return unmanaged;
 
-            foreach (var name in managedKey.GetSubKeyNames())
+            foreach (var receiptName in managedKey.GetSubKeyNames())
             {
-                if (manifestedNames.Contains(name)) continue;
+                // Receipts written before a rename are still under the old name
+                var name = _catalogService.ResolveAlias(receiptName);
+                if (managedNames.Contains(name)) continue;
 
-                using var itemKey = managedKey.OpenSubKey(name);
+                using var itemKey = managedKey.OpenSubKey(receiptName);
                 var version = itemKey?.GetValue("Version")?.ToString() ?? "0";
 
-                if (catalogMap.TryGetValue(name.ToLowerInvariant(), out var catalogItem))
+                if (catalogMap.TryGetValue(ItemKey.Canonical(name), out var catalogItem))
                 {
                     if (catalogItem.IsUninstallable())
                     {
-                        autoRemove.Add(catalogItem);
+                        unmanaged.Add(catalogItem);
                     }
                     else
                     {
-                        ConsoleLogger.Detail($"    AutoRemove: skipping {name} (not uninstallable)");
+                        ConsoleLogger.Detail($"    RemoveUnmanagedItems: skipping {name} (not uninstallable)");
                     }
                 }
                 else
                 {
-                    ConsoleLogger.Detail($"    AutoRemove: skipping {name} (not in catalog)");
+                    ConsoleLogger.Detail($"    RemoveUnmanagedItems: skipping {name} (not in catalog)");
                 }
             }
         }
         catch (Exception ex)
         {
-            ConsoleLogger.Warn($"AutoRemove: failed to enumerate ManagedInstalls registry: {ex.Message}");
+            ConsoleLogger.Warn($"RemoveUnmanagedItems: failed to enumerate ManagedInstalls registry: {ex.Message}");
         }
 
-        return autoRemove;
+        return unmanaged;
     }
 
     /// <summary>
@@ -1263,14 +1926,14 @@ public class UpdateEngine : IDisposable
 
         // manifestItems is already deduplicated, so one entry per name carries
         // the winning action (and the IsSelfServe flag from the merge).
-        var manifestByName = new Dictionary<string, ManifestItem>(StringComparer.OrdinalIgnoreCase);
+        var manifestByName = new Dictionary<string, ManifestItem>(ItemKey.Comparer);
         foreach (var mi in manifestItems)
         {
             if (!string.IsNullOrEmpty(mi.Name)) manifestByName.TryAdd(mi.Name, mi);
         }
         var queuedNames = new HashSet<string>(
             alreadyQueued.Select(i => i.Name),
-            StringComparer.OrdinalIgnoreCase);
+            ItemKey.Comparer);
 
         // One service for the whole pass: its YAML serializers and file lock are
         // instance-scoped, so reusing a single instance both avoids rebuilding
@@ -1284,14 +1947,15 @@ public class UpdateEngine : IDisposable
                 @"SOFTWARE\ManagedInstalls");
             if (managedKey == null) return stale;
 
-            foreach (var name in managedKey.GetSubKeyNames())
+            foreach (var receiptName in managedKey.GetSubKeyNames())
             {
+                var name = _catalogService.ResolveAlias(receiptName);
                 if (queuedNames.Contains(name)) continue;
 
                 var scope = StaleUsageEvaluator.ClassifyScope(manifestByName.GetValueOrDefault(name));
                 if (scope == StaleUsageScope.Protected) continue;
 
-                if (!catalogMap.TryGetValue(name.ToLowerInvariant(), out var catalogItem)) continue;
+                if (!catalogMap.TryGetValue(ItemKey.Canonical(name), out var catalogItem)) continue;
 
                 var decision = StaleUsageEvaluator.Evaluate(
                     catalogItem, usageSource, _config.UsageStaleUninstallMinimumHistoryDays);
@@ -1396,17 +2060,23 @@ public class UpdateEngine : IDisposable
     ///      iterated the original manifest list, not the deps it added.
     /// The closure walk lives in <see cref="CatalogService.BuildDependencyClosure"/>;
     /// this method does the I/O (status check, manifest mutation).
+    ///
+    /// Deps reached only through <c>update_for</c> are updates, Munki style: they're
+    /// skipped unless something they update is installed or is being installed this
+    /// run. The closure is breadth-first, so in a chain each link is decided before
+    /// the update that targets it.
     /// </remarks>
     private void ResolveDependencies(
         List<ManifestItem> manifestItems,
         Dictionary<string, CatalogItem> catalogMap,
+        List<CatalogItem> toInstall,
         List<CatalogItem> itemsToProcess,
         ItemFilterService? itemFilterService = null)
     {
         LogInfo("Resolving dependencies (requires and update_for)...");
 
         var existingNames = new HashSet<string>(
-            manifestItems.Select(m => m.Name.ToLowerInvariant()),
+            manifestItems.Select(m => ItemKey.Canonical(m.Name)),
             StringComparer.OrdinalIgnoreCase);
 
         // Map name → set of actions already declared by the manifest, so we can
@@ -1441,11 +2111,30 @@ public class UpdateEngine : IDisposable
 
         LogDetail($"    Resolving deps for {seedNames.Count} manifest item(s)");
 
-        var deps = CatalogService.BuildDependencyClosure(seedNames, catalogMap);
+        var updateOnly = new HashSet<string>(StringComparer.OrdinalIgnoreCase);
+        var deps = CatalogService.BuildDependencyClosure(seedNames, catalogMap, updateOnly);
+
+        var installedNames = updateOnly.Count > 0 ? LoadInstalledItemNames() : new List<string>();
+        var present = new Dictionary<string, bool>(StringComparer.OrdinalIgnoreCase);
+        bool IsPresent(string name)
+        {
+            if (toInstall.Concat(itemsToProcess).Any(i => i.Name.Equals(name, StringComparison.OrdinalIgnoreCase)))
+            {
+                return true;
+            }
+            if (!present.TryGetValue(name, out var isPresent))
+            {
+                isPresent = CatalogService.IsItemInstalled(name, installedNames)
+                    || (catalogMap.TryGetValue(ItemKey.Canonical(name), out var target)
+                        && !_statusService.CheckStatus(target, "install", _config.CachePath).NeedsAction);
+                present[name] = isPresent;
+            }
+            return isPresent;
+        }
 
         foreach (var depName in deps)
         {
-            var depKey = depName.ToLowerInvariant();
+            var depKey = ItemKey.Canonical(depName);
             if (!catalogMap.TryGetValue(depKey, out var depItem))
             {
                 LogDetail($"    Skipping {depName} - not found in catalog");
@@ -1462,7 +2151,14 @@ public class UpdateEngine : IDisposable
                 continue;
             }
 
+            if (updateOnly.Contains(depItem.Name) && !CatalogService.HasUpdateTarget(depItem, IsPresent))
+            {
+                LogDetail($"    Skipping update {depItem.Name}: nothing it is an update_for ({string.Join(", ", depItem.UpdateFor)}) is installed or being installed");
+                continue;
+            }
+
             var status = _statusService.CheckStatus(depItem, "install", _config.CachePath);
+            present[depItem.Name] = !status.NeedsAction;
 
             LogInfo($"Dependency {depItem.Name} v{depItem.Version}: needsAction={status.NeedsAction} ({status.Reason})");
 
@@ -1496,7 +2192,7 @@ public class UpdateEngine : IDisposable
             LogInfo($"Dependency resolution complete: {depCount} dependency item(s) tracked");
             LogInfo($"Added dependencies: {string.Join(", ", manifestItems.Where(m => m.SourceManifest == "dependency").Select(m => m.Name))}");
         }
-        LogInfo($"Managed items: {managedCount} (excludes {manifestItems.Count - managedCount} MDM profiles/apps)");
+        LogInfo($"Managed items: {managedCount} (excludes {manifestItems.Count - managedCount} profiles/apps)");
     }
 
     private void PrintActionSummary(
@@ -1558,7 +2254,11 @@ public class UpdateEngine : IDisposable
             ReportItemStatus(item.Name, "pending");
         }
 
+        // Downloads run on MaxConcurrentDownloads workers, so this callback fires
+        // from several threads at once.
         var downloadCount = 0;
+        var lastReportedDecile = new System.Collections.Concurrent.ConcurrentDictionary<string, int>(StringComparer.OrdinalIgnoreCase);
+        var itemProgress = new ItemProgressTracker();
         var downloadProgress = new Progress<(string ItemName, double Percent)>(p =>
         {
             // Report which item is being downloaded with version info
@@ -1569,9 +2269,26 @@ public class UpdateEngine : IDisposable
             if (p.Percent <= 0)
             {
                 // Starting a new item download
-                downloadCount++;
+                var started = Interlocked.Increment(ref downloadCount);
                 ReportItemStatus(p.ItemName, "downloading");
-                ReportDetail($"Downloading {label} ({downloadCount}/{items.Count})");
+                ReportDetail($"Downloading {label} ({started}/{items.Count})");
+                return;
+            }
+
+            // Bytes and ETA at whole-percent steps for the status window's item list
+            // (installer.size is in KB, as makepkginfo writes it)
+            if (itemProgress.Update(p.ItemName, p.Percent, matchingItem?.Installer?.Size * 1024, DateTime.UtcNow) is { } update)
+            {
+                ReportItemProgress(p.ItemName, "downloading", update.Percent,
+                    update.Bytes, update.TotalBytes, update.EtaSeconds);
+            }
+
+            // Per-item progress, throttled to 10% steps so parallel downloads don't flood the pipe
+            var decile = (int)(p.Percent / 10);
+            var previous = lastReportedDecile.GetOrAdd(p.ItemName, 0);
+            if (decile > previous && lastReportedDecile.TryUpdate(p.ItemName, decile, previous))
+            {
+                ReportItemStatus(p.ItemName, "downloading", $"{decile * 10}%");
             }
         });
         var downloadedPaths = await _downloadService.DownloadItemsAsync(items, downloadProgress, cancellationToken);
@@ -1586,11 +2303,14 @@ public class UpdateEngine : IDisposable
             }
         }
 
-        // Track installed and scheduled items for dependency checking
-        // Start with items that are already confirmed installed (from status checks)
-        var installedItems = new List<string>();
+        // Track installed and scheduled items for dependency checking. Start from what
+        // earlier runs recorded as installed, minus the items this run (re)installs.
         var scheduledItems = items.Select(i => i.Name).ToList();
+        var installedItems = LoadInstalledItemNames()
+            .Where(i => !CatalogService.IsItemInstalled(i, scheduledItems))
+            .ToList();
         var itemIndex = 0;
+        var heldItems = new List<(CatalogItem Item, List<string> Apps)>();
 
         // Process each item with full dependency handling
         // This is Go parity: ProcessInstallWithDependencies from process.go
@@ -1626,6 +2346,7 @@ public class UpdateEngine : IDisposable
                 continue;
             }
 
+            _heldForApps = null;
             var success = await ProcessInstallWithDependenciesAsync(
                 item.Name,
                 installedItems,
@@ -1634,6 +2355,14 @@ public class UpdateEngine : IDisposable
                 outcomes,
                 cancellationToken);
 
+            // Held for its (or a dependency's) blocking applications; retried after the rest
+            if (!success && _heldForApps != null)
+            {
+                heldItems.Add((item, _heldForApps));
+                ReportItemProgress(item.Name, "pending", 0, detail: $"Waiting for {string.Join(", ", _heldForApps)} to close");
+                continue;
+            }
+
             var failureDetail = success ? null : SummarizeFailure(
                 outcomes.LastOrDefault(o =>
                     string.Equals(o.Name, item.Name, StringComparison.OrdinalIgnoreCase) && !o.Success)?.ErrorMessage);
@@ -1649,6 +2378,14 @@ public class UpdateEngine : IDisposable
             }
         }
 
+        if (heldItems.Count > 0 && !cancellationToken.IsCancellationRequested && !_userStop.IsCancellationRequested)
+        {
+            var (succeeded, failed) = await InstallWhenAppsCloseAsync(
+                heldItems, installedItems, scheduledItems, downloadedPaths, outcomes, cancellationToken);
+            successCount += succeeded;
+            failCount += failed;
+        }
+
         LogInfo($"Installation summary: {successCount} succeeded, {failCount} failed");
         return outcomes;
     }
@@ -1663,14 +2400,21 @@ public class UpdateEngine : IDisposable
         Dictionary<string, CatalogItem> catalogMap,
         CancellationToken cancellationToken)
     {
+        // Nothing cached survives a non-persistent reboot
+        if (_persistence.IsNonPersistent)
+        {
+            return;
+        }
+
         var precacheItems = new List<CatalogItem>();
+        var network = _config.RespectMeteredConnections ? NetworkCost.Detect() : NetworkCostKind.Unmetered;
 
         foreach (var mi in manifestItems)
         {
             if (string.IsNullOrEmpty(mi.Name)) continue;
             if (!string.Equals(mi.Action, "optional", StringComparison.OrdinalIgnoreCase)) continue;
 
-            var key = mi.Name.ToLowerInvariant();
+            var key = ItemKey.Canonical(mi.Name);
             if (!catalogMap.TryGetValue(key, out var cat)) continue;
             if (!cat.Precache) continue;
 
@@ -1681,6 +2425,14 @@ public class UpdateEngine : IDisposable
             var cachePath = _downloadService.GetCachePath(cat);
             if (File.Exists(cachePath)) continue;
 
+            if (network != NetworkCostKind.Unmetered && HoldsForMeteredNetwork(cat, _config.MeteredDownloadThresholdMB))
+            {
+                var reason = MeteredDeferralReason(cat, network);
+                LogInfo($"Precache of {cat.Name} v{cat.Version} deferred: {reason}");
+                _sessionLogger?.Log("INFO", $"Precache of {cat.Name} v{cat.Version} deferred: {reason}");
+                continue;
+            }
+
             precacheItems.Add(cat);
         }
 
@@ -1712,6 +2464,91 @@ public class UpdateEngine : IDisposable
         }
     }
 
+    /// <summary>
+    /// Refreshes the local icon cache for the manifest's items (optional installs
+    /// included) and anything this run will install, update or remove. A repo
+    /// without icons costs one request per item; failures never stop the run.
+    /// </summary>
+    private async Task SyncIconsAsync(
+        List<ManifestItem> manifestItems,
+        Dictionary<string, CatalogItem> catalogMap,
+        List<CatalogItem> toInstall,
+        List<CatalogItem> toUpdate,
+        List<CatalogItem> toUninstall,
+        CancellationToken cancellationToken)
+    {
+        var items = manifestItems
+            .Where(mi => !string.IsNullOrEmpty(mi.Name))
+            .Select(mi => catalogMap.GetValueOrDefault(ItemKey.Canonical(mi.Name)))
+            .OfType<CatalogItem>()
+            .Concat(toInstall)
+            .Concat(toUpdate)
+            .Concat(toUninstall)
+            .ToList();
+        if (items.Count == 0) return;
+
+        try
+        {
+            await _iconCacheService.SyncAsync(items, cancellationToken);
+        }
+        catch (Exception ex) when (ex is not OperationCanceledException)
+        {
+            ConsoleLogger.Warn($"Icon sync failed: {ex.Message}");
+        }
+    }
+
+    /// <summary>
+    /// Sets <see cref="_degraded"/> when any manifest or catalog came from the
+    /// local copy because the repo couldn't be reached, and records it in the
+    /// session so reports show the run was degraded.
+    /// </summary>
+    private void FlagDegradedIfOffline()
+    {
+        var cached = _manifestService.OfflineManifests
+            .Select(m => $"manifest {m}")
+            .Concat(_catalogService.OfflineCatalogs.Select(c => $"catalog {c}"))
+            .ToList();
+        _degraded = cached.Count > 0;
+        if (!_degraded) return;
+
+        var message = $"Repo unreachable; running degraded from cached {string.Join(", ", cached)}";
+        ConsoleLogger.Warn(message);
+        ReportDetail("Software repository unreachable - using cached data");
+        _sessionLogger?.Log("WARN", message);
+        _sessionLogger?.SetEnvironmentValue("degraded", true);
+        _sessionLogger?.SetEnvironmentValue("degraded_sources", cached);
+    }
+
+    /// <summary>
+    /// In a degraded run, keeps only installs and updates whose payload is
+    /// already in the cache with a matching hash (or that have no payload), so
+    /// nothing needs the repo. Removals wait for a run with current manifests.
+    /// </summary>
+    private void RestrictToCachedInstalls(List<CatalogItem> toInstall, List<CatalogItem> toUpdate, List<CatalogItem> toUninstall)
+    {
+        foreach (var list in new[] { toInstall, toUpdate })
+        {
+            list.RemoveAll(item =>
+            {
+                if (string.IsNullOrEmpty(item.Installer.Location)) return false;
+                var localPath = _downloadService.GetCachePath(item);
+                var verification = DownloadService.VerifyPayload(item, localPath, requireHash: true);
+                if (verification.Status == PayloadVerificationStatus.Valid) return false;
+
+                LogInfo($"Skipped: {item.Name} v{item.Version} (degraded run, installer not cached)");
+                _sessionLogger?.Log("INFO", $"Skipped {item.Name} v{item.Version}: repo unreachable and installer not in cache");
+                return true;
+            });
+        }
+
+        if (toUninstall.Count > 0)
+        {
+            LogInfo($"Skipped {toUninstall.Count} removal(s): degraded run uses cached manifests");
+            _sessionLogger?.Log("INFO", $"Skipped {toUninstall.Count} removal(s) during degraded run");
+            toUninstall.Clear();
+        }
+    }
+
     #region Dependency-Aware Installation (Go parity: pkg/process/process.go)
 
     /// <summary>
@@ -1736,7 +2573,7 @@ public class UpdateEngine : IDisposable
         LogDetail($"ProcessInstallWithDependencies: {itemName}");
 
         // Get the item from catalog
-        var key = itemName.ToLowerInvariant();
+        var key = ItemKey.Canonical(itemName);
         if (!_catalogMap.TryGetValue(key, out var item))
         {
             ConsoleLogger.Error($"Item not found in catalog: {itemName}");
@@ -1746,7 +2583,7 @@ public class UpdateEngine : IDisposable
         var systemArch = StatusService.GetSystemArchitecture();
 
         // Check architecture support
-        if (!CatalogService.SupportsArchitecture(item, systemArch))
+        if (!CatalogService.SupportsArchitecture(item, systemArch, !_config.ForbidEmulatedInstalls))
         {
             LogInfo($"Skipping {item.Name}: architecture mismatch (system: {systemArch})");
             return true; // Not an error, just skipped
@@ -1755,15 +2592,7 @@ public class UpdateEngine : IDisposable
         if (!IsEligibleForOsVersion(item, out var osReason, out var osReasonCode))
         {
             LogInfo($"Skipping {item.Name}: {osReason}");
-            _sessionLogger?.LogStatusCheck(
-                item.Name,
-                item.Version,
-                "skipped",
-                osReason,
-                osReasonCode,
-                DetectionMethod.None,
-                null,
-                false);
+            LogOsVersionGated(item, osReason, osReasonCode);
             return true;
         }
 
@@ -1801,7 +2630,7 @@ public class UpdateEngine : IDisposable
                 var (depName, _) = CatalogService.SplitNameAndVersion(dep);
 
                 // Check if dependency exists in catalog
-                var depKey = depName.ToLowerInvariant();
+                var depKey = ItemKey.Canonical(depName);
                 if (!_catalogMap.TryGetValue(depKey, out var depItem))
                 {
                     ConsoleLogger.Error($"Required dependency not found in catalog: {depName} (for {itemName})");
@@ -1841,7 +2670,10 @@ public class UpdateEngine : IDisposable
                 var newScheduled = new List<string>(scheduledItems) { dep };
                 if (!await ProcessInstallWithDependenciesAsync(dep, installedItems, newScheduled, downloadedPaths, outcomes, cancellationToken))
                 {
-                    ConsoleLogger.Error($"Failed to install required dependency: {dep}");
+                    if (_heldForApps == null)
+                    {
+                        ConsoleLogger.Error($"Failed to install required dependency: {dep}");
+                    }
                     return false;
                 }
 
@@ -1859,9 +2691,20 @@ public class UpdateEngine : IDisposable
         LogInfo($"Installing: {item.Name} v{item.Version}");
 
         // Check for blocking apps
-        if (_installerService.CheckBlockingApps(item, out var runningApps))
+        if (_installerService.CheckBlockingApps(item, out var runningApps)
+            && (_forcedItems.Contains(item.Name) || (_blockingAppWaitOver && item.ForceCloseBlockingApps)))
+        {
+            CloseBlockingApps(item, runningApps);
+        }
+        if (_installerService.CheckBlockingApps(item, out runningApps))
         {
             var blockingAppsStr = string.Join(", ", runningApps);
+            if (_config.BlockingAppTimeout > 0 && !_blockingAppWaitOver)
+            {
+                LogInfo($"Holding {item.Name}: waiting for {blockingAppsStr} to close");
+                _heldForApps = runningApps;
+                return false;
+            }
             ConsoleLogger.Warn($"Skipping {item.Name}: blocking apps running: {blockingAppsStr}");
             
             // Log with status reason tracking
@@ -1883,7 +2726,9 @@ public class UpdateEngine : IDisposable
 
         // Guard: file-based installer types must have a valid downloaded file
         var installerType = (item.Installer?.Type ?? "").ToLowerInvariant();
-        var requiresFile = installerType is not ("nopkg" or "script");
+        // chocolatey items with no location install straight from ChocolateySources
+        var requiresFile = installerType is not ("nopkg" or "script" or "configuration")
+            && !(installerType == "chocolatey" && string.IsNullOrEmpty(item.Installer?.Location));
         if (requiresFile && string.IsNullOrEmpty(localFile))
         {
             var msg = $"Download missing for {item.Name} — cannot install {installerType} without a local file";
@@ -1894,25 +2739,72 @@ public class UpdateEngine : IDisposable
             return false;
         }
 
+        if (requiresFile)
+        {
+            ReportInstallPhase(item.Name, "Verifying installer");
+            localFile = await VerifyPayloadBeforeInstallAsync(item, localFile!, cancellationToken);
+            if (localFile == null)
+            {
+                outcomes.Add(new ItemOutcome(item.Name, item.Version, "install", false,
+                    "Installer payload failed hash validation", DateTime.UtcNow));
+                return false;
+            }
+            downloadedPaths[item.Name] = localFile;
+        }
+
+        var receipt = RollbackService.ReadReceipt(item.Name);
+        SnapshotForRollback(item, receipt);
+
+        if (!await ApplyUpgradeStrategyAsync(item, receipt, outcomes, cancellationToken))
+        {
+            return false;
+        }
+
+        ReportInstallPhase(item.Name, requiresFile ? $"Running {installerType} installer" : "Running install script");
+        using var installSpan = DiagnosticTrace.Begin("install", item.Name);
         var (success, output, warningMessage) = await _installerService.InstallAsync(item, localFile ?? "", cancellationToken);
+        if (!success) installSpan.Fail();
         outcomes.Add(new ItemOutcome(item.Name, item.Version, "install", success, success ? null : output, DateTime.UtcNow, warningMessage));
 
         if (success)
         {
             LogSuccess($"Installed: {item.Name} v{item.Version}");
+            TryRecordInstalledForRollback(item, localFile);
+            _installedItemsStore.RecordInstall(item, DateTime.Now, asDependency: !IsRequestedDirectly(item.Name));
+
+            if (_persistence.IsNonPersistent)
+            {
+                _downloadService.PurgeItemCache(item);
+            }
+
+            if (DeferralService.TracksDeferrals(item))
+            {
+                _deferrals.Clear(item.Name);
+                _deferrals.Save();
+            }
+
+            if (!string.IsNullOrWhiteSpace(item.ReleaseNotes))
+            {
+                PublishReleaseNotes(item);
+            }
             
             // Track restart_action (Munki parity: requires_restart check)
-            if (RequiresRestart(item))
+            if (RequiresRestart(item) || InstallerService.InstallerRequestedRestart(output))
             {
+                var restartSource = RequiresRestart(item)
+                    ? $"restart_action: {item.EffectiveRestartAction}"
+                    : "installer exit code requested a restart";
                 _restartNeeded = true;
-                LogInfo($"Restart required after installing {item.Name} (restart_action: {item.RestartAction})");
-                _sessionLogger?.Log("INFO", $"Restart required: {item.Name} (restart_action: {item.RestartAction})");
+                _restartDeadline = EarlierOf(_restartDeadline, item.ForceInstallAfterDate);
+                _restartRequiredBy.Add(item.Name);
+                LogInfo($"Restart required after installing {item.Name} ({restartSource})");
+                _sessionLogger?.Log("INFO", $"Restart required: {item.Name} ({restartSource})");
             }
             else if (RequiresLogout(item))
             {
                 _logoutNeeded = true;
-                LogInfo($"Logout required after installing {item.Name} (restart_action: {item.RestartAction})");
-                _sessionLogger?.Log("INFO", $"Logout required: {item.Name} (restart_action: {item.RestartAction})");
+                LogInfo($"Logout required after installing {item.Name} (restart_action: {item.EffectiveRestartAction})");
+                _sessionLogger?.Log("INFO", $"Logout required: {item.Name} (restart_action: {item.EffectiveRestartAction})");
             }
             
             // Log structured event for external monitoring with reason tracking
@@ -1980,7 +2872,7 @@ public class UpdateEngine : IDisposable
             LogInfo($"Installing update for {item.Name}: {updateItemName}");
 
             // Check if update item needs action
-            var updateKey = updateItemName.ToLowerInvariant();
+            var updateKey = ItemKey.Canonical(updateItemName);
             if (_catalogMap.TryGetValue(updateKey, out var updateItem))
             {
                 var status = _statusService.CheckStatus(updateItem, "install", _config.CachePath);
@@ -2015,37 +2907,58 @@ public class UpdateEngine : IDisposable
 
     /// <summary>
     /// Process uninstallation of an item with dependency checking.
-    /// This handles: finding dependent items and removing them first.
+    /// This handles: finding dependent items and removing them first, or, with
+    /// DependentRemovalPolicy block, refusing the removal while an installed
+    /// dependent isn't in <paramref name="removing"/> too.
     /// Migrated from Go: ProcessUninstallWithDependencies() - process.go lines 642-690
     /// </summary>
+    /// <param name="visited">Items already on this removal's path, so a requires cycle ends.</param>
     private async Task<bool> ProcessUninstallWithDependenciesAsync(
         string itemName,
         List<string> installedItems,
+        ISet<string> removing,
+        ISet<string> visited,
         List<ItemOutcome> outcomes,
         CancellationToken cancellationToken)
     {
         LogDetail($"ProcessUninstallWithDependencies: {itemName}");
+        visited.Add(itemName);
+
+        // Find installed items that require this item
+        var dependentItems = CatalogService.FindItemsRequiring(itemName, _catalogMap)
+            .Where(d => !visited.Contains(d.Name) && CatalogService.IsItemInstalled(d.Name, installedItems))
+            .ToList();
 
-        // Find items that require this item
-        var dependentItems = CatalogService.FindItemsRequiring(itemName, _catalogMap);
+        var blockingDependents = dependentItems.Where(d => !removing.Contains(d.Name)).Select(d => d.Name).ToList();
+        if (blockingDependents.Count > 0
+            && string.Equals(_config.DependentRemovalPolicy?.Trim(), "block", StringComparison.OrdinalIgnoreCase))
+        {
+            var version = _catalogMap.GetValueOrDefault(ItemKey.Canonical(itemName))?.Version ?? "";
+            var reason = $"required by installed {string.Join(", ", blockingDependents)} (DependentRemovalPolicy is block)";
+            ConsoleLogger.Warn($"Not removing {itemName}: {reason}");
+            _sessionLogger?.LogStatusCheck(
+                itemName, version, "pending", reason,
+                StatusReasonCode.RemovalBlockedByDependents,
+                DetectionMethod.None,
+                needsAction: true);
+            outcomes.Add(new ItemOutcome(itemName, version, "remove", false, $"Removal blocked: {reason}", DateTime.UtcNow));
+            ReportItemStatus(itemName, "failed", $"Required by {string.Join(", ", blockingDependents)}");
+            return false;
+        }
 
         // Remove dependent items first
         foreach (var depItem in dependentItems)
         {
-            // Check if dependent item is actually installed
-            if (CatalogService.IsItemInstalled(depItem.Name, installedItems))
+            LogInfo($"Removing dependent item first: {depItem.Name} (requires {itemName})");
+            if (!await ProcessUninstallWithDependenciesAsync(depItem.Name, installedItems, removing, visited, outcomes, cancellationToken))
             {
-                LogInfo($"Removing dependent item first: {depItem.Name} (requires {itemName})");
-                if (!await ProcessUninstallWithDependenciesAsync(depItem.Name, installedItems, outcomes, cancellationToken))
-                {
-                    ConsoleLogger.Error($"Failed to remove dependent item: {depItem.Name}");
-                    return false;
-                }
+                ConsoleLogger.Error($"Failed to remove dependent item: {depItem.Name}");
+                return false;
             }
         }
 
         // Get the main item and uninstall it
-        var key = itemName.ToLowerInvariant();
+        var key = ItemKey.Canonical(itemName);
         if (!_catalogMap.TryGetValue(key, out var item))
         {
             ConsoleLogger.Error($"Item not found in catalog: {itemName}");
@@ -2053,7 +2966,11 @@ public class UpdateEngine : IDisposable
         }
 
         // Check for blocking apps
-        if (_installerService.CheckBlockingApps(item, out var runningApps))
+        if (_installerService.CheckBlockingApps(item, out var runningApps) && _forcedItems.Contains(item.Name))
+        {
+            CloseBlockingApps(item, runningApps);
+        }
+        if (_installerService.CheckBlockingApps(item, out runningApps))
         {
             ConsoleLogger.Warn($"Skipping {item.Name}: blocking apps running: {string.Join(", ", runningApps)}");
             return false;
@@ -2061,29 +2978,51 @@ public class UpdateEngine : IDisposable
 
         LogInfo($"Removing: {item.Name}");
         ReportItemStatus(item.Name, "removing");
+        using var uninstallSpan = DiagnosticTrace.Begin("uninstall", item.Name);
         var (success, output) = await _installerService.UninstallAsync(item, cancellationToken);
+        if (!success) uninstallSpan.Fail();
         outcomes.Add(new ItemOutcome(item.Name, item.Version, "remove", success, success ? null : output, DateTime.UtcNow));
         ReportItemStatus(item.Name, success ? "removed" : "failed", success ? null : SummarizeFailure(output));
 
         if (success)
         {
             LogSuccess($"Removed: {item.Name}");
+            _installedItemsStore.RecordRemoval(item, DateTime.Now);
+            if (DeferralService.TracksDeferrals(item))
+            {
+                _deferrals.Clear(item.Name);
+                _deferrals.Save();
+            }
             
             // Track restart_action for uninstalls (Munki parity)
-            if (RequiresRestart(item))
+            if (RequiresRestart(item) || InstallerService.InstallerRequestedRestart(output))
             {
+                var restartSource = RequiresRestart(item)
+                    ? $"restart_action: {item.EffectiveRestartAction}"
+                    : "installer exit code requested a restart";
                 _restartNeeded = true;
-                LogInfo($"Restart required after removing {item.Name} (restart_action: {item.RestartAction})");
-                _sessionLogger?.Log("INFO", $"Restart required: {item.Name} (restart_action: {item.RestartAction})");
+                _restartDeadline = EarlierOf(_restartDeadline, item.ForceInstallAfterDate);
+                _restartRequiredBy.Add(item.Name);
+                LogInfo($"Restart required after removing {item.Name} ({restartSource})");
+                _sessionLogger?.Log("INFO", $"Restart required: {item.Name} ({restartSource})");
             }
             else if (RequiresLogout(item))
             {
                 _logoutNeeded = true;
-                LogInfo($"Logout required after removing {item.Name} (restart_action: {item.RestartAction})");
-                _sessionLogger?.Log("INFO", $"Logout required: {item.Name} (restart_action: {item.RestartAction})");
+                LogInfo($"Logout required after removing {item.Name} (restart_action: {item.EffectiveRestartAction})");
+                _sessionLogger?.Log("INFO", $"Logout required: {item.Name} (restart_action: {item.EffectiveRestartAction})");
+            }
+
+            if (_config.PurgeCacheOnUninstall)
+            {
+                var (purgedFiles, purgedBytes) = _downloadService.PurgeItemCache(item);
+                if (purgedFiles > 0)
+                {
+                    LogInfo($"Purged {purgedFiles} cached file(s) for {item.Name} ({purgedBytes / (1024 * 1024)} MB)");
+                }
             }
 
-            installedItems.RemoveAll(i => string.Equals(i, item.Name, StringComparison.OrdinalIgnoreCase));
+            installedItems.RemoveAll(i => CatalogService.IsItemInstalled(item.Name, [i]));
             return true;
         }
         else
@@ -2105,9 +3044,11 @@ public class UpdateEngine : IDisposable
         var successCount = 0;
         var failCount = 0;
 
-        // Track installed items - start with what we're about to remove
-        // (In a real scenario, we'd have a better way to track currently installed items)
+        // Track installed items: what we're about to remove plus everything earlier
+        // runs installed, so installed items requiring a removal go first
         var installedItems = items.Select(i => i.Name).ToList();
+        installedItems.AddRange(LoadInstalledItemNames().Where(i => !CatalogService.IsItemInstalled(i, installedItems)).ToList());
+        var removing = new HashSet<string>(items.Select(i => i.Name), ItemKey.Comparer);
 
         // Process each uninstall with dependency checking
         // This is Go parity: ProcessUninstallWithDependencies from process.go
@@ -2133,6 +3074,8 @@ public class UpdateEngine : IDisposable
             var success = await ProcessUninstallWithDependenciesAsync(
                 item.Name,
                 installedItems,
+                removing,
+                new HashSet<string>(ItemKey.Comparer),
                 outcomes,
                 cancellationToken);
 
@@ -2150,27 +3093,16 @@ public class UpdateEngine : IDisposable
         return outcomes;
     }
 
-    private void CleanManifestsAndCatalogsPreRun()
+    private void EnsureManifestsAndCatalogsDirs()
     {
         try
         {
-            if (Directory.Exists(_config.CatalogsPath))
-            {
-                Directory.Delete(_config.CatalogsPath, true);
-            }
             Directory.CreateDirectory(_config.CatalogsPath);
-            LogDebug($"Cleaned and recreated directory dirPath: {_config.CatalogsPath}");
-
-            if (Directory.Exists(_config.ManifestsPath))
-            {
-                Directory.Delete(_config.ManifestsPath, true);
-            }
             Directory.CreateDirectory(_config.ManifestsPath);
-            LogDebug($"Cleaned and recreated directory dirPath: {_config.ManifestsPath}");
         }
         catch (Exception ex)
         {
-            LogDebug($"Failed to clean pre-run directories: {ex.Message}");
+            LogDebug($"Failed to create pre-run directories: {ex.Message}");
         }
     }
 
@@ -2316,102 +3248,42 @@ public class UpdateEngine : IDisposable
         Log("MANIFEST HIERARCHY");
         Log("----------------------------------------------------------------------");
         
-        // Build hierarchy tree
-        var tree = BuildManifestHierarchy(manifestCounts, manifestPackages);
-        PrintManifestTree(tree, "", true, manifestPackages);
-        
-        Log();
-    }
-    
-    private class ManifestNode
-    {
-        public string Name { get; set; } = "";
-        public int Count { get; set; }
-        public Dictionary<string, ManifestNode> Children { get; set; } = new();
-    }
-    
-    private ManifestNode BuildManifestHierarchy(Dictionary<string, int> counts, Dictionary<string, List<ManifestItem>> packages)
-    {
-        var root = new ManifestNode { Name = "root" };
-        
-        // Core manifests first (not part of client identifier hierarchy)
-        var coreManifests = new[] { "ManagementTools", "ManagementPrefs", "CoreApps", "CoreDrivers" };
-        
-        foreach (var manifest in coreManifests)
+        // The include tree the manifests actually described, then any item sources
+        // outside it (self-serve requests, items without a source)
+        var roots = new List<ManifestHierarchyNode>();
+        if (_manifestService.GetManifestHierarchy() is { } tree)
         {
-            if (counts.ContainsKey(manifest) || packages.ContainsKey(manifest))
-            {
-                var packageCount = packages.TryGetValue(manifest, out var pkgs) ? pkgs.Count : 0;
-                root.Children[manifest] = new ManifestNode 
-                { 
-                    Name = manifest, 
-                    Count = packageCount
-                };
-            }
+            roots.Add(tree);
         }
-        
-        // Build client identifier hierarchy (e.g., Assigned/Staff/IT/B1115/Desktop)
-        var clientId = _config.ClientIdentifier;
-        if (!string.IsNullOrEmpty(clientId))
+        var inTree = new HashSet<string>(roots.SelectMany(Flatten).Select(n => n.Name), StringComparer.OrdinalIgnoreCase);
+        roots.AddRange(manifestCounts.Keys
+            .Where(source => !inTree.Contains(source))
+            .Select(source => new ManifestHierarchyNode(source, [])));
+
+        for (int i = 0; i < roots.Count; i++)
         {
-            var parts = clientId.Split('/');
-            var current = root;
-            
-            // Add to last core manifest's children (CoreManifest)
-            if (root.Children.TryGetValue("ManagementTools", out var managementNode))
-            {
-                var coreManifestNode = new ManifestNode { Name = "CoreManifest", Count = 1 };
-                managementNode.Children["CoreManifest"] = coreManifestNode;
-                current = coreManifestNode;
-            }
-            
-            foreach (var part in parts)
-            {
-                if (string.IsNullOrEmpty(part)) continue;
-                
-                var packageCount = packages.TryGetValue(part, out var pkgs) ? pkgs.Count : 0;
-                
-                if (!current.Children.ContainsKey(part))
-                {
-                    current.Children[part] = new ManifestNode 
-                    { 
-                        Name = part, 
-                        Count = packageCount
-                    };
-                }
-                current = current.Children[part];
-            }
+            PrintManifestTree(roots[i], "", i == roots.Count - 1, manifestPackages);
         }
         
-        return root;
+        Log();
     }
+
+    private static IEnumerable<ManifestHierarchyNode> Flatten(ManifestHierarchyNode node) =>
+        node.Children.SelectMany(Flatten).Prepend(node);
     
-    private void PrintManifestTree(ManifestNode node, string prefix, bool isLast, Dictionary<string, List<ManifestItem>> packages)
+    private void PrintManifestTree(ManifestHierarchyNode node, string prefix, bool isLast, Dictionary<string, List<ManifestItem>> packages)
     {
-        if (node.Name == "root")
-        {
-            var names = node.Children.Keys.ToList();
-            for (int i = 0; i < names.Count; i++)
-            {
-                PrintManifestTree(node.Children[names[i]], "", i == names.Count - 1, packages);
-            }
-            return;
-        }
-        
         var connector = isLast ? "└─" : "├─";
         var childPrefix = prefix + (isLast ? "   " : "│  ");
         
-        // Calculate items count
-        var itemCount = node.Count + node.Children.Count;
-        if (packages.TryGetValue(node.Name, out var pkgs))
-        {
-            itemCount = pkgs.Count + node.Children.Count;
-        }
+        // Items count: this manifest's installs plus the manifests it includes
+        packages.TryGetValue(node.Name, out var manifestPkgs);
+        var itemCount = (manifestPkgs?.Count ?? 0) + node.Children.Count;
         
         Log($"{prefix}{connector} {node.Name} [{itemCount} items]");
         
         // Print packages for this manifest
-        if (packages.TryGetValue(node.Name, out var manifestPkgs) && manifestPkgs.Count > 0)
+        if (manifestPkgs != null && manifestPkgs.Count > 0)
         {
             for (int i = 0; i < manifestPkgs.Count; i++)
             {
@@ -2422,10 +3294,9 @@ public class UpdateEngine : IDisposable
         }
         
         // Print children
-        var childNames = node.Children.Keys.ToList();
-        for (int i = 0; i < childNames.Count; i++)
+        for (int i = 0; i < node.Children.Count; i++)
         {
-            PrintManifestTree(node.Children[childNames[i]], childPrefix, i == childNames.Count - 1, packages);
+            PrintManifestTree(node.Children[i], childPrefix, i == node.Children.Count - 1, packages);
         }
     }
 
@@ -2489,8 +3360,8 @@ public class UpdateEngine : IDisposable
 
         // Build status for each item
         var packageStatuses = new List<(string Name, string Version, string Status)>();
-        var toInstallNames = toInstall.Select(i => i.Name.ToLowerInvariant()).ToHashSet();
-        var toUpdateNames = toUpdate.Select(i => i.Name.ToLowerInvariant()).ToHashSet();
+        var toInstallNames = toInstall.Select(i => ItemKey.Canonical(i.Name)).ToHashSet();
+        var toUpdateNames = toUpdate.Select(i => ItemKey.Canonical(i.Name)).ToHashSet();
         
         foreach (var item in managedInstalls)
         {
@@ -2499,17 +3370,17 @@ public class UpdateEngine : IDisposable
             var status = "Installed";
             
             // Get catalog version
-            if (catalogMap.TryGetValue(name.ToLowerInvariant(), out var catalogItem))
+            if (catalogMap.TryGetValue(ItemKey.Canonical(name), out var catalogItem))
             {
                 version = catalogItem.Version;
             }
             
             // Determine status
-            if (toInstallNames.Contains(name.ToLowerInvariant()))
+            if (toInstallNames.Contains(ItemKey.Canonical(name)))
             {
                 status = "Pending Install";
             }
-            else if (toUpdateNames.Contains(name.ToLowerInvariant()))
+            else if (toUpdateNames.Contains(ItemKey.Canonical(name)))
             {
                 status = "Pending Update";
             }
@@ -2573,7 +3444,7 @@ public class UpdateEngine : IDisposable
 
         // Build status for each item
         var packageStatuses = new List<(string Name, string Version, string Status)>();
-        var toUpdateNames = toUpdate.Select(i => i.Name.ToLowerInvariant()).ToHashSet();
+        var toUpdateNames = toUpdate.Select(i => ItemKey.Canonical(i.Name)).ToHashSet();
         
         foreach (var item in managedUpdates)
         {
@@ -2582,13 +3453,13 @@ public class UpdateEngine : IDisposable
             var status = "Installed";
             
             // Get catalog version
-            if (catalogMap.TryGetValue(name.ToLowerInvariant(), out var catalogItem))
+            if (catalogMap.TryGetValue(ItemKey.Canonical(name), out var catalogItem))
             {
                 version = catalogItem.Version;
             }
             
             // Determine status
-            if (toUpdateNames.Contains(name.ToLowerInvariant()))
+            if (toUpdateNames.Contains(ItemKey.Canonical(name)))
             {
                 status = "Pending Update";
             }
@@ -2643,7 +3514,7 @@ public class UpdateEngine : IDisposable
 
         // Build status for each item
         var packageStatuses = new List<(string Name, string Version, string Status)>();
-        var toUninstallNames = toUninstall.Select(i => i.Name.ToLowerInvariant()).ToHashSet();
+        var toUninstallNames = toUninstall.Select(i => ItemKey.Canonical(i.Name)).ToHashSet();
         
         foreach (var item in managedUninstalls)
         {
@@ -2652,13 +3523,13 @@ public class UpdateEngine : IDisposable
             var status = "Removed";
             
             // Get catalog version
-            if (catalogMap.TryGetValue(name.ToLowerInvariant(), out var catalogItem))
+            if (catalogMap.TryGetValue(ItemKey.Canonical(name), out var catalogItem))
             {
                 version = catalogItem.Version;
             }
             
             // Determine status - if in toUninstall list, it's still installed and pending removal
-            if (toUninstallNames.Contains(name.ToLowerInvariant()))
+            if (toUninstallNames.Contains(ItemKey.Canonical(name)))
             {
                 status = "Pending Removal";
             }
@@ -2684,52 +3555,1189 @@ public class UpdateEngine : IDisposable
     
     #endregion
 
-    #region Status Reporter Methods (GUI integration)
+    #region Payload Verification
 
     /// <summary>
-    /// Reports a status message to the GUI (main headline)
+    /// Last check before an installer runs: the cached payload must match the
+    /// catalog hash. A mismatched or vanished file is re-downloaded once; if that
+    /// doesn't produce the right bytes, or the item has no hash while
+    /// RequireHashValidation is on, the install is refused. Every failure is also
+    /// recorded as a hash_validation event. Returns the path to install from, or
+    /// null to skip the item.
     /// </summary>
-    private void ReportStatus(string message)
+    private async Task<string?> VerifyPayloadBeforeInstallAsync(
+        CatalogItem item, string localFile, CancellationToken cancellationToken)
     {
-        _statusReporter?.Message(message);
-    }
+        var verification = DownloadService.VerifyPayload(item, localFile, _config.RequireHashValidation);
+        if (verification.Status is PayloadVerificationStatus.Mismatch or PayloadVerificationStatus.Missing)
+        {
+            LogHashValidationEvent(item, localFile, verification, "retrying");
+            ConsoleLogger.Warn($"Cached installer for {item.Name} failed hash validation ({verification.Status}); re-downloading once");
+            try { File.Delete(localFile); } catch { /* re-download overwrites it */ }
 
-    /// <summary>
-    /// Reports a detail message to the GUI (secondary text)
-    /// </summary>
-    private void ReportDetail(string message)
-    {
-        _statusReporter?.Detail(message);
-    }
+            var redownloaded = await _downloadService.DownloadItemAsync(item, cancellationToken: cancellationToken);
+            if (redownloaded != null)
+            {
+                localFile = redownloaded;
+                verification = DownloadService.VerifyPayload(item, localFile, _config.RequireHashValidation);
+            }
+        }
 
-    /// <summary>
-    /// Reports progress percentage to the GUI
-    /// </summary>
-    private void ReportPercent(int percent)
-    {
-        _statusReporter?.Percent(percent);
-    }
+        if (verification.CanInstall)
+        {
+            if (verification.Status == PayloadVerificationStatus.NotChecked)
+            {
+                var warning = $"{item.Name} has no installer hash in the catalog (installer.hash in its pkginfo); installing unverified. Add one with makepkginfo, or set RequireHashValidation to refuse such items";
+                ConsoleLogger.Warn(warning);
+                _sessionLogger?.Log("WARN", warning);
+                LogHashValidationEvent(item, localFile, verification, "warning");
+            }
+            return localFile;
+        }
 
-    /// <summary>
-    /// Reports a per-item lifecycle stage to the GUI (pending, downloading,
-    /// downloaded, installing, installed, removing, removed, failed) so the
-    /// Updates list can render live status on each row.
-    /// </summary>
-    private void ReportItemStatus(string itemName, string stage, string? detail = null)
-    {
-        _statusReporter?.ItemStatus(itemName, stage, detail);
+        var reason = verification.Status == PayloadVerificationStatus.MissingHash
+            ? $"{item.Name} has no installer hash in the catalog (installer.hash in its pkginfo) and RequireHashValidation is on"
+            : $"Installer for {item.Name} does not match the catalog hash after re-download";
+        ConsoleLogger.Error(reason);
+        _sessionLogger?.Log("ERROR", reason);
+        LogHashValidationEvent(item, localFile, verification, "failed");
+        return null;
     }
 
     /// <summary>
-    /// Condenses an installer's raw failure output into a short, user-readable
-    /// reason for the GUI and problem_items — exit code first, with a plain-English
-    /// gloss for the common MSI codes so users can report a meaningful issue
-    /// instead of "Will be installed" that never changes.
+    /// Drops installs and updates the machine can't take right now: on battery
+    /// below MinimumBatteryPercent, a download held back on a metered or
+    /// cellular connection under RespectMeteredConnections, or short of the
+    /// disk space the item's installer_size_required / minimum_free_disk_mb ask for.
     /// </summary>
-    private static string? SummarizeFailure(string? rawOutput)
+    private void SkipItemsFailingPreconditions(List<CatalogItem> toInstall, List<CatalogItem> toUpdate)
     {
-        if (string.IsNullOrWhiteSpace(rawOutput)) return null;
-
+        if (toInstall.Count == 0 && toUpdate.Count == 0)
+        {
+            return;
+        }
+
+        // A precache run only downloads, so the battery doesn't matter to it
+        var power = StatusService.GetPowerState();
+        var batteryReason = _precache ? null : StatusService.LowBatteryReason(power, _config.MinimumBatteryPercent);
+        var network = _config.RespectMeteredConnections ? NetworkCost.Detect() : NetworkCostKind.Unmetered;
+        var freeBytes = new Lazy<long?>(StatusService.GetSystemDriveFreeBytes);
+
+        foreach (var list in new[] { toInstall, toUpdate })
+        {
+            for (int i = list.Count - 1; i >= 0; i--)
+            {
+                var item = list[i];
+                var cached = new Lazy<bool>(() => IsPayloadCached(item));
+                string? reason = null;
+                string? reasonCode = null;
+                var status = "skipped";
+                var context = new Dictionary<string, object>();
+
+                if (batteryReason != null)
+                {
+                    reason = batteryReason;
+                    reasonCode = Cimian.Core.Models.StatusReasonCode.LowBattery;
+                    context["battery_percent"] = power.ChargePercent ?? -1;
+                    context["minimum_battery_percent"] = _config.MinimumBatteryPercent;
+                }
+                else if (network != NetworkCostKind.Unmetered && !cached.Value)
+                {
+                    if (HoldsForMeteredNetwork(item, _config.MeteredDownloadThresholdMB))
+                    {
+                        reason = MeteredDeferralReason(item, network);
+                        reasonCode = Cimian.Core.Models.StatusReasonCode.NetworkMetered;
+                        status = "deferred";
+                        context["network"] = network.ToString().ToLowerInvariant();
+                        context["download_bytes"] = item.Installer.Size ?? 0;
+                        context["threshold_mb"] = _config.MeteredDownloadThresholdMB;
+                    }
+                    else if (item.Critical)
+                    {
+                        LogInfo($"Downloading critical {item.Name} v{item.Version} on a {network.ToString().ToLowerInvariant()} connection");
+                    }
+                }
+
+                if (reason == null && ((item.InstallerSizeRequired ?? 0) > 0 || (item.MinimumFreeDiskMb ?? 0) > 0))
+                {
+                    var required = StatusService.RequiredFreeBytes(item, cached.Value);
+                    if (freeBytes.Value is { } free && free < required)
+                    {
+                        reason = $"needs {required / (1024 * 1024)} MB free on the system drive, {free / (1024 * 1024)} MB available";
+                        reasonCode = Cimian.Core.Models.StatusReasonCode.DiskSpace;
+                        context["required_bytes"] = required;
+                        context["available_bytes"] = free;
+                    }
+                }
+
+                if (reason == null || reasonCode == null)
+                {
+                    continue;
+                }
+
+                var verb = status == "deferred" ? "Deferred" : "Skipped";
+                LogInfo($"{verb}: {item.Name} v{item.Version} ({reason})");
+                _sessionLogger?.Log("INFO", $"{verb} {item.Name} v{item.Version}: {reason}");
+                LogPreconditionSkipped(item, status, reason, reasonCode, context);
+                _deferralReasons.Add((item, reason));
+                list.RemoveAt(i);
+            }
+        }
+    }
+
+    /// <summary>
+    /// Whether RespectMeteredConnections holds this item's download back:
+    /// critical items never wait, and with MeteredDownloadThresholdMB set only
+    /// downloads over it (or of unknown size) do.
+    /// </summary>
+    internal static bool HoldsForMeteredNetwork(CatalogItem item, int thresholdMb)
+    {
+        if (item.Critical)
+        {
+            return false;
+        }
+        var size = item.Installer.Size ?? 0;
+        return thresholdMb <= 0 || size <= 0 || size > thresholdMb * 1024L * 1024;
+    }
+
+    private static string MeteredDeferralReason(CatalogItem item, NetworkCostKind network)
+    {
+        var connection = network == NetworkCostKind.Cellular ? "cellular" : "metered";
+        return item.Installer.Size is > 0 and var size
+            ? $"{size / (1024 * 1024)} MB download deferred on a {connection} connection"
+            : $"download deferred on a {connection} connection";
+    }
+
+    /// <summary>Installer already in the cache with a matching hash, or no installer to fetch.</summary>
+    private bool IsPayloadCached(CatalogItem item)
+    {
+        if (string.IsNullOrEmpty(item.Installer.Location))
+        {
+            return true;
+        }
+
+        var localPath = _downloadService.GetCachePath(item);
+        return DownloadService.VerifyPayload(item, localPath, requireHash: false).Status switch
+        {
+            PayloadVerificationStatus.Valid => true,
+            PayloadVerificationStatus.NotChecked => File.Exists(localPath),
+            _ => false
+        };
+    }
+
+    /// <summary>
+    /// Records an item held back by a machine precondition: a skipped (or, for
+    /// a metered network, deferred) status check plus a precondition_skipped
+    /// event whose context carries the measurement that failed.
+    /// </summary>
+    private void LogPreconditionSkipped(CatalogItem item, string status, string reason, string reasonCode, Dictionary<string, object> context)
+    {
+        _sessionLogger?.LogStatusCheck(
+            item.Name,
+            item.Version,
+            status,
+            reason,
+            reasonCode,
+            DetectionMethod.None,
+            null,
+            true);
+        _sessionLogger?.LogEvent(new LogEvent
+        {
+            Level = "INFO",
+            EventType = "precondition_skipped",
+            PackageName = item.Name,
+            PackageVersion = item.Version,
+            Action = "install",
+            Status = status,
+            Message = reason,
+            StatusReason = reason,
+            StatusReasonCode = reasonCode,
+            Context = context
+        });
+    }
+
+    /// <summary>
+    /// Records an item skipped by minimum_os_version / maximum_os_version: the
+    /// usual skipped status check plus an os_version_gated event carrying the
+    /// bounds and the running build, so reports can tell "wrong OS" apart from
+    /// other skips without parsing the reason text.
+    /// </summary>
+    private void LogOsVersionGated(CatalogItem item, string reason, string reasonCode)
+    {
+        _sessionLogger?.LogStatusCheck(
+            item.Name,
+            item.Version,
+            "skipped",
+            reason,
+            reasonCode,
+            DetectionMethod.None,
+            null,
+            false);
+        _sessionLogger?.LogEvent(new LogEvent
+        {
+            Level = "INFO",
+            EventType = "os_version_gated",
+            PackageName = item.Name,
+            PackageVersion = item.Version,
+            Action = "install",
+            Status = "skipped",
+            Message = reason,
+            StatusReason = reason,
+            StatusReasonCode = reasonCode,
+            Context = new Dictionary<string, object>
+            {
+                ["current_os_version"] = Cimian.Core.Version.VersionService.GetCurrentOsVersion(),
+                ["minimum_os_version"] = item.MinimumOsVersion ?? "",
+                ["maximum_os_version"] = item.MaximumOsVersion ?? ""
+            }
+        });
+    }
+
+    private void LogHashValidationEvent(CatalogItem item, string localFile, PayloadVerification verification, string status)
+    {
+        _sessionLogger?.LogEvent(new LogEvent
+        {
+            Level = status == "failed" ? "ERROR" : "WARN",
+            EventType = "hash_validation",
+            PackageName = item.Name,
+            PackageVersion = item.Version,
+            Action = "install",
+            Status = status,
+            Message = $"Payload hash validation {verification.Status}",
+            Error = status == "failed" ? verification.Status.ToString() : null,
+            InstallerType = item.Installer.Type,
+            Context = new Dictionary<string, object>
+            {
+                ["path"] = localFile,
+                ["expected_hash"] = verification.ExpectedHash ?? "",
+                ["actual_hash"] = verification.ActualHash ?? ""
+            }
+        });
+    }
+
+    /// <summary>
+    /// Security event for an installer location outside AllowedDownloadOrigins,
+    /// i.e. a catalog pointing somewhere the fleet never downloads from.
+    /// </summary>
+    private void LogOriginRejectedEvent(CatalogItem item, string url)
+    {
+        _sessionLogger?.Log("ERROR", $"Security: {item.Name} installer URL {url} is outside AllowedDownloadOrigins; download refused");
+        _sessionLogger?.LogEvent(new LogEvent
+        {
+            Level = "ERROR",
+            EventType = "security",
+            PackageName = item.Name,
+            PackageVersion = item.Version,
+            Action = "download",
+            Status = "blocked",
+            Message = "Installer origin is not in AllowedDownloadOrigins",
+            Error = StatusReasonCode.OriginNotAllowed,
+            InstallerType = item.Installer.Type,
+            Context = new Dictionary<string, object>
+            {
+                ["url"] = url,
+                ["origin"] = DownloadService.NormalizeOrigin(url) ?? "",
+                ["allowed_origins"] = string.Join(",", _config.AllowedDownloadOrigins)
+            }
+        });
+    }
+
+    /// <summary>
+    /// Security event for a downloaded Cimian package that was not scheduled
+    /// because its hash or signature didn't check out: either the repo or the
+    /// download was tampered with, so it is raised as an error, not a warning.
+    /// </summary>
+    private void LogSelfUpdateRefusedEvent(CatalogItem item, string localFile, string reason, string details, Dictionary<string, object> context)
+    {
+        _sessionLogger?.Log("ERROR", $"Security: self-update {item.Name} v{item.Version} refused: {details}");
+        context["path"] = localFile;
+        _sessionLogger?.LogEvent(new LogEvent
+        {
+            Level = "ERROR",
+            EventType = "security",
+            PackageName = item.Name,
+            PackageVersion = item.Version,
+            Action = "self_update",
+            Status = "blocked",
+            Message = $"Self-update package refused: {details}",
+            Error = reason,
+            InstallerType = item.Installer.Type,
+            Context = context
+        });
+    }
+
+    #endregion
+
+    #region On-demand install/uninstall
+
+    /// <summary>
+    /// Installs (with its requires and update_for items) or removes a single item
+    /// straight from the catalogs, without loading a manifest: the helpdesk's
+    /// --install / --uninstall. Logged as its own "ondemand" session. Catalogs are
+    /// Config.yaml's, or an active --override-catalogs pin, which isn't spent.
+    /// </summary>
+    public async Task<int> RunOnDemandAsync(string itemName, bool uninstall, int verbosity = 0, CancellationToken cancellationToken = default)
+    {
+        _verbosity = verbosity;
+        ConsoleLogger.Verbosity = verbosity;
+        _runType = "ondemand";
+        var action = uninstall ? "uninstall" : "install";
+
+        _catalogOverride = _catalogOverrides.Get();
+        if (_catalogOverride != null)
+        {
+            _config.Catalogs = new List<string>(_catalogOverride.Catalogs);
+        }
+
+        _persistence = PersistenceDetector.Detect(_config.NonPersistentMode);
+        _loopGuard = new LoopGuard(false, disabled: !_config.LoopGuardEnabled, maxSuppressionDays: _config.LoopMaxTime,
+            quarantineThreshold: _config.QuarantineFailureThreshold);
+
+        CimianEventLog.Enabled = _config.EventLogEnabled;
+        _sessionLogger = new SessionLogger();
+        var sessionId = _sessionLogger.StartSession(_runType, new Dictionary<string, object>
+        {
+            ["verbosity"] = verbosity,
+            ["ondemand_action"] = action,
+            ["ondemand_item"] = itemName,
+            ["client_identifier"] = _config.ClientIdentifier,
+            ["catalog_override"] = _catalogOverride != null ? string.Join(",", _catalogOverride.Catalogs) : ""
+        });
+        ConsoleLogger.SetSessionLogger(_sessionLogger);
+        ScriptService.Session = new ScriptSessionInfo(sessionId, _runType, _config.CachePath, _config.SoftwareRepoURL);
+        ScriptService.Settings = ScriptSettings.From(_config);
+        ScriptService.EventLogger = _sessionLogger;
+        _installerService.SetSessionLogger(_sessionLogger);
+
+        try
+        {
+            if (!StatusService.IsAdministrator())
+            {
+                return FailOnDemand(itemName, action, "Administrative access required.");
+            }
+
+            EnsureManifestsAndCatalogsDirs();
+            _catalogMap = await _catalogService.LoadCatalogsAsync();
+            var item = _catalogService.FindItem(_catalogMap, itemName);
+            if (item == null)
+            {
+                return FailOnDemand(itemName, action,
+                    $"{itemName} is not in any catalog this machine uses ({string.Join(", ", _config.Catalogs)})");
+            }
+
+            var status = _statusService.CheckStatus(item, action, _config.CachePath);
+            // IsUpdate: installed, just not at the catalog version
+            var installed = status.Status == "installed" || status.IsUpdate;
+            if (!uninstall && !status.NeedsAction)
+            {
+                return FinishOnDemand(item, action, "skipped", $"{item.Name} {item.Version} is already installed ({status.Reason})", []);
+            }
+            if (uninstall && !installed)
+            {
+                return FinishOnDemand(item, action, "skipped", $"{item.Name} is not installed ({status.Reason})", []);
+            }
+            if (uninstall && !item.IsUninstallable())
+            {
+                return FailOnDemand(item.Name, action, $"{item.Name} can't be removed: its pkginfo has no uninstall method or sets uninstallable: false");
+            }
+
+            // The item is asked for in its own right; what it pulls in is a dependency
+            _allManifestItems = [new ManifestItem { Name = item.Name, Action = action, SourceManifest = "ondemand" }];
+
+            LogInfo($"On-demand {action}: {item.Name} {item.Version}");
+            var outcomes = uninstall
+                ? await PerformUninstallsAsync(new List<CatalogItem> { item }, cancellationToken)
+                : await PerformInstallationsAsync(new List<CatalogItem> { item }, cancellationToken);
+
+            var failed = outcomes.Where(o => !o.Success).ToList();
+            var done = outcomes.Any(o => o.Success && ItemKey.Comparer.Equals(o.Name, item.Name));
+            if (failed.Count > 0 || !done)
+            {
+                var reason = failed.Count > 0
+                    ? $"On-demand {action} of {item.Name} failed: {string.Join("; ", failed.Select(o => $"{o.Name}: {SummarizeFailure(o.ErrorMessage) ?? "failed"}"))}"
+                    : $"On-demand {action} of {item.Name} did not complete";
+                return FinishOnDemand(item, action, "failed", reason, outcomes);
+            }
+
+            if (_restartNeeded || _logoutNeeded)
+            {
+                LogWarn($"{(_restartNeeded ? "A restart" : "A logout")} is required to finish ({string.Join(", ", _restartRequiredBy.DefaultIfEmpty(item.Name))}); " +
+                        "the next scheduled run handles it");
+            }
+            return FinishOnDemand(item, action, "completed",
+                $"{(uninstall ? "Removed" : "Installed")} {item.Name} {item.Version} on demand", outcomes);
+        }
+        catch (Exception ex)
+        {
+            return FailOnDemand(itemName, action, $"On-demand {action} failed: {ex.Message}");
+        }
+        finally
+        {
+            ConsoleLogger.SetSessionLogger(null);
+            _sessionLogger.Dispose();
+        }
+    }
+
+    private int FinishOnDemand(CatalogItem item, string action, string status, string message, List<ItemOutcome> outcomes)
+    {
+        if (status == "failed")
+        {
+            ConsoleLogger.Error(message);
+        }
+        else if (status == "completed")
+        {
+            LogSuccess(message);
+        }
+        else
+        {
+            Log(message);
+        }
+
+        LogOnDemandEvent(item.Name, item.Version, action, status, message);
+        var removals = outcomes.Count(o => o.Action == "remove");
+        _sessionLogger?.EndSession(status == "failed" ? "failed" : "completed", new SessionLogSummary
+        {
+            TotalActions = outcomes.Count,
+            Installs = outcomes.Count - removals,
+            Removals = removals,
+            Successes = outcomes.Count(o => o.Success),
+            Failures = outcomes.Count(o => !o.Success),
+            PackagesHandled = outcomes.Select(o => o.Name).Distinct(StringComparer.OrdinalIgnoreCase).ToList()
+        }, message);
+        return status == "failed" ? 1 : 0;
+    }
+
+    private int FailOnDemand(string name, string action, string reason)
+    {
+        ConsoleLogger.Error(reason);
+        LogOnDemandEvent(name, "", action, "failed", reason);
+        _sessionLogger?.EndSession("failed", new SessionLogSummary { Failures = 1 }, reason);
+        return 1;
+    }
+
+    private void LogOnDemandEvent(string name, string version, string action, string status, string message)
+    {
+        _sessionLogger?.LogEvent(new LogEvent
+        {
+            Level = status == "failed" ? "ERROR" : "INFO",
+            EventType = "ondemand",
+            PackageName = name,
+            PackageVersion = version,
+            Action = action,
+            Status = status,
+            Message = message,
+            Error = status == "failed" ? message : null,
+            Context = new Dictionary<string, object>
+            {
+                ["requested_by"] = $"{Environment.UserDomainName}\\{Environment.UserName}"
+            }
+        });
+    }
+
+    #endregion
+
+    #region Rollback
+
+    /// <summary>
+    /// Before an update replaces an installed version, keeps what's needed to put
+    /// it back: its pkginfo, receipt, product code and cached installer.
+    /// </summary>
+    private void SnapshotForRollback(CatalogItem item, IReadOnlyDictionary<string, string> receipt)
+    {
+        try
+        {
+            if (!receipt.TryGetValue("Version", out var installedVersion) || string.IsNullOrEmpty(installedVersion))
+            {
+                return;
+            }
+
+            var snapshot = _rollbackService.TakeSnapshot(item, installedVersion, receipt);
+            if (snapshot != null)
+            {
+                LogDetail($"    Rollback snapshot: {item.Name} v{snapshot.Version}" +
+                          (snapshot.CatalogItem == null ? " (receipt only)" : ""));
+            }
+        }
+        catch (Exception ex)
+        {
+            // A failed snapshot never blocks the update itself
+            ConsoleLogger.Warn($"Could not snapshot {item.Name} for rollback: {ex.Message}");
+        }
+    }
+
+    /// <summary>
+    /// Whether a manifest (or --install) asks for <paramref name="name"/> itself,
+    /// rather than it being installed because something requires or updates it.
+    /// </summary>
+    private bool IsRequestedDirectly(string name) =>
+        _allManifestItems.Any(m => ItemKey.Comparer.Equals(m.Name, name)
+                                   && m.SourceManifest != "dependency"
+                                   && m.Action?.ToLowerInvariant() == "install");
+
+    /// <summary>
+    /// Installed items from the state store, seeded from ManagedInstalls registry
+    /// receipts the first time it's used on a machine.
+    /// </summary>
+    private List<string> LoadInstalledItemNames()
+    {
+        if (!_installedItemsStore.Exists)
+        {
+            var imported = _installedItemsStore.ImportReceipts(InstalledItemsStore.ReadRegistryReceipts());
+            LogDetail($"    Created installed items state from {imported} ManagedInstalls receipt(s)");
+        }
+        return _installedItemsStore.InstalledItemNames();
+    }
+
+    private void TryRecordInstalledForRollback(CatalogItem item, string? localFile)
+    {
+        try
+        {
+            _rollbackService.RecordInstalled(item, localFile);
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Debug($"Could not record {item.Name} for rollback: {ex.Message}");
+        }
+    }
+
+    /// <summary>
+    /// managedsoftwareupdate --rollback &lt;item&gt;: reinstalls the version the
+    /// last update replaced, from the cache when the installer is still there
+    /// and verifies, otherwise from the repo. MSI and uninstall-first rollbacks
+    /// remove the current version first (unless the item is side-by-side). The next
+    /// regular run will offer the newer version again unless the catalog or
+    /// manifest changes.
+    /// </summary>
+    public async Task<int> RollbackAsync(string itemName, int verbosity = 0, CancellationToken cancellationToken = default)
+    {
+        _verbosity = verbosity;
+        ConsoleLogger.Verbosity = verbosity;
+
+        CimianEventLog.Enabled = _config.EventLogEnabled;
+        _sessionLogger = new SessionLogger();
+        var sessionId = _sessionLogger.StartSession("rollback", new Dictionary<string, object>
+        {
+            ["verbosity"] = verbosity,
+            ["rollback_item"] = itemName,
+            ["client_identifier"] = _config.ClientIdentifier
+        });
+        ConsoleLogger.SetSessionLogger(_sessionLogger);
+        ScriptService.Session = new ScriptSessionInfo(sessionId, "rollback", _config.CachePath, _config.SoftwareRepoURL);
+        ScriptService.Settings = ScriptSettings.From(_config);
+        ScriptService.EventLogger = _sessionLogger;
+        _installerService.SetSessionLogger(_sessionLogger);
+
+        try
+        {
+            var name = _catalogService.ResolveAlias(itemName);
+            var currentVersion = RollbackService.ReadReceipt(name).GetValueOrDefault("Version", "");
+            var snapshot = _rollbackService.GetLatestSnapshot(name);
+            if (snapshot == null)
+            {
+                return FailRollback(name, null, currentVersion, $"No rollback snapshot recorded for {name}");
+            }
+
+            var previous = RollbackService.DeserializeItem(snapshot.CatalogItem);
+            if (previous == null)
+            {
+                return FailRollback(name, snapshot, currentVersion,
+                    $"{name} v{snapshot.Version} was installed before rollback tracking; its pkginfo wasn't recorded");
+            }
+
+            LogInfo($"Rolling back {name}: {(string.IsNullOrEmpty(currentVersion) ? "(not installed)" : currentVersion)} -> {previous.Version}");
+
+            string? localFile = null;
+            string source = "none";
+            var installerType = (previous.Installer?.Type ?? "").ToLowerInvariant();
+            if (installerType is not ("nopkg" or "script" or "configuration"))
+            {
+                if (!string.IsNullOrEmpty(snapshot.CachedInstaller) && File.Exists(snapshot.CachedInstaller)
+                    && DownloadService.VerifyPayload(previous, snapshot.CachedInstaller, _config.RequireHashValidation).CanInstall)
+                {
+                    localFile = snapshot.CachedInstaller;
+                    source = "cache";
+                }
+                else
+                {
+                    LogInfo($"    Cached installer for {name} v{previous.Version} unavailable; downloading from repo");
+                    localFile = await _downloadService.DownloadItemAsync(previous, cancellationToken: cancellationToken);
+                    source = "repo";
+                }
+
+                if (localFile != null)
+                {
+                    localFile = await VerifyPayloadBeforeInstallAsync(previous, localFile, cancellationToken);
+                }
+                if (localFile == null)
+                {
+                    return FailRollback(name, snapshot, currentVersion,
+                        $"Could not obtain a valid installer for {name} v{previous.Version}");
+                }
+            }
+
+            // Windows Installer won't downgrade over a newer MSI, and uninstall-first
+            // items need a clean removal either way; side-by-side leaves it in place
+            var strategy = UpgradeStrategy.Normalize(previous.UpgradeStrategy);
+            if ((installerType == "msi" && strategy != UpgradeStrategy.SideBySide) || strategy == UpgradeStrategy.UninstallFirst)
+            {
+                var current = RollbackService.DeserializeItem(_rollbackService.Load(name).InstalledItem);
+                if (current != null && current.IsUninstallable())
+                {
+                    LogInfo($"    Removing {name} v{current.Version} before reinstalling v{previous.Version}");
+                    var (removed, removeOutput) = await _installerService.UninstallAsync(current, cancellationToken);
+                    if (!removed)
+                    {
+                        return FailRollback(name, snapshot, currentVersion,
+                            $"Could not remove {name} v{current.Version}: {removeOutput}");
+                    }
+                }
+            }
+
+            var (success, output, _) = await _installerService.InstallAsync(previous, localFile ?? "", cancellationToken);
+            if (!success)
+            {
+                return FailRollback(name, snapshot, currentVersion, $"Reinstall of {name} v{previous.Version} failed: {output}", source);
+            }
+
+            _installedItemsStore.RecordInstall(previous, DateTime.Now,
+                asDependency: _installedItemsStore.Get(previous.Name)?.InstalledAsDependency == true);
+            var message = $"Rolled back {name} to v{previous.Version} (from {source})";
+            _rollbackService.RecordRollback(name, snapshot, currentVersion, true, source, message, sessionId);
+            LogRollbackEvent(name, snapshot.Version, currentVersion, "completed", message, source);
+            LogSuccess(message);
+            _sessionLogger.EndSession("completed", new SessionLogSummary
+            {
+                TotalActions = 1,
+                Installs = 1,
+                Successes = 1,
+                PackagesHandled = new List<string> { name }
+            }, message);
+            return 0;
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Error($"Rollback failed: {ex.Message}");
+            _sessionLogger.EndSession("failed", new SessionLogSummary { Failures = 1 }, $"Rollback failed: {ex.Message}");
+            return 1;
+        }
+        finally
+        {
+            ConsoleLogger.SetSessionLogger(null);
+            _sessionLogger.Dispose();
+        }
+    }
+
+    private int FailRollback(string name, RollbackSnapshot? snapshot, string currentVersion, string reason, string? source = null)
+    {
+        ConsoleLogger.Error(reason);
+        if (snapshot != null)
+        {
+            _rollbackService.RecordRollback(name, snapshot, currentVersion, false, source, reason, _sessionLogger?.SessionId);
+        }
+        LogRollbackEvent(name, snapshot?.Version ?? "", currentVersion, "failed", reason, source);
+        _sessionLogger?.EndSession("failed", new SessionLogSummary
+        {
+            TotalActions = 1,
+            Failures = 1,
+            PackagesHandled = new List<string> { name }
+        }, reason);
+        return 1;
+    }
+
+    private void LogRollbackEvent(string name, string toVersion, string fromVersion, string status, string message, string? source)
+    {
+        _sessionLogger?.LogEvent(new LogEvent
+        {
+            Level = status == "failed" ? "ERROR" : "INFO",
+            EventType = "rollback",
+            PackageName = name,
+            PackageVersion = toVersion,
+            Action = "rollback",
+            Status = status,
+            Message = message,
+            Error = status == "failed" ? message : null,
+            Context = new Dictionary<string, object>
+            {
+                ["from_version"] = fromVersion,
+                ["to_version"] = toVersion,
+                ["source"] = source ?? ""
+            }
+        });
+    }
+
+    #endregion
+
+    #region Deferrals
+
+    /// <summary>
+    /// When the item's force_install_after_date has passed or its max_deferrals
+    /// are used up, it stays in this run instead of being deferred for
+    /// <paramref name="deferReason"/>.
+    /// </summary>
+    private bool ForceInsteadOfDeferring(CatalogItem item, DateTime now, string deferReason)
+    {
+        if (!_deferrals.ShouldForce(item, now, out var forceReason))
+        {
+            return false;
+        }
+
+        _forcedItems.Add(item.Name);
+        LogInfo($"Not deferring {item.Name} v{item.Version} ({deferReason}): {forceReason}");
+        _sessionLogger?.Log("INFO", $"Forcing {item.Name} v{item.Version} despite {deferReason}: {forceReason}");
+        return true;
+    }
+
+    /// <summary>
+    /// Counts a user-caused deferral and lists it for cimistatus.
+    /// </summary>
+    private void RecordUserDeferral(CatalogItem item, string reason)
+    {
+        if (_precache || !DeferralService.TracksDeferrals(item))
+        {
+            return;
+        }
+
+        var entry = _deferrals.RecordDeferral(item, reason, DateTime.Now);
+        var notice = new LastRunDeferral
+        {
+            Name = item.DisplayName ?? item.Name,
+            Item = item.Name,
+            Version = item.Version,
+            Reason = reason,
+            DeferralsUsed = entry.Count,
+            MaxDeferrals = item.MaxDeferrals,
+            ForceInstallAfter = item.ForceInstallAfterDate
+        };
+        _sessionLogger?.AddDeferral(notice);
+        ReportDetail(notice.Describe());
+    }
+
+    /// <summary>
+    /// A forced item's blocking applications get a polite close, then are ended.
+    /// The user has already been told through their deferrals, or by the
+    /// close-apps toast for force_close_blocking_apps.
+    /// </summary>
+    private void CloseBlockingApps(CatalogItem item, List<string> runningApps)
+    {
+        LogInfo($"Closing {string.Join(", ", runningApps)} to install {item.Name}");
+        _sessionLogger?.Log("INFO", $"Closing blocking applications for forced install of {item.Name}: {string.Join(", ", runningApps)}");
+
+        foreach (var app in runningApps)
+        {
+            foreach (var process in System.Diagnostics.Process.GetProcessesByName(Path.GetFileNameWithoutExtension(app)))
+            {
+                using (process)
+                {
+                    try
+                    {
+                        if (process.CloseMainWindow() && process.WaitForExit(15_000))
+                        {
+                            continue;
+                        }
+                        process.Kill(true);
+                        process.WaitForExit(5_000);
+                    }
+                    catch (Exception ex) when (ex is InvalidOperationException or System.ComponentModel.Win32Exception)
+                    {
+                        ConsoleLogger.Warn($"Could not close {process.ProcessName}: {ex.Message}");
+                    }
+                }
+            }
+        }
+    }
+
+    /// <summary>
+    /// BlockingAppTimeout: asks the user to close what is holding
+    /// <paramref name="held"/> (a CimianStatus toast and the status window) and
+    /// installs each item as soon as its applications are gone. When the time
+    /// runs out, force_close_blocking_apps items have them closed; the rest are
+    /// skipped and spend a deferral, as an install deferred at planning would.
+    /// </summary>
+    private async Task<(int Succeeded, int Failed)> InstallWhenAppsCloseAsync(
+        List<(CatalogItem Item, List<string> Apps)> held,
+        List<string> installedItems,
+        List<string> scheduledItems,
+        Dictionary<string, string> downloadedPaths,
+        List<ItemOutcome> outcomes,
+        CancellationToken cancellationToken)
+    {
+        var succeeded = 0;
+        var failed = 0;
+        var state = new BlockingAppsState
+        {
+            RecordedAt = DateTime.Now,
+            WaitUntil = DateTime.Now.AddSeconds(_config.BlockingAppTimeout)
+        };
+
+        LogInfo($"Waiting up to {_config.BlockingAppTimeout}s for blocking applications to close ({held.Count} item(s))");
+        _sessionLogger?.Log("INFO", $"Waiting until {state.WaitUntil:T} for blocking applications: {string.Join("; ", held.Select(h => $"{h.Item.Name} ({string.Join(", ", h.Apps)})"))}");
+        ReportStatus("Waiting for applications to close...");
+        PublishBlockingApps(state, held, notifyUser: true);
+
+        try
+        {
+            while (held.Count > 0 && !cancellationToken.IsCancellationRequested && !_userStop.IsCancellationRequested)
+            {
+                _blockingAppWaitOver = DateTime.Now >= state.WaitUntil;
+                var runningProcessNames = StatusService.GetRunningProcessNames();
+                var changed = false;
+
+                for (var i = 0; i < held.Count;)
+                {
+                    var (item, apps) = held[i];
+                    if (!_blockingAppWaitOver && StatusService.CheckBlockingApps(apps, runningProcessNames, out var stillRunning))
+                    {
+                        if (stillRunning.Count != apps.Count)
+                        {
+                            held[i] = (item, stillRunning);
+                            changed = true;
+                        }
+                        i++;
+                        continue;
+                    }
+
+                    held.RemoveAt(i);
+                    changed = true;
+                    LogInfo(_blockingAppWaitOver
+                        ? $"Stopped waiting for {string.Join(", ", apps)}: installing {item.Name}"
+                        : $"{string.Join(", ", apps)} closed: installing {item.Name}");
+                    ReportItemStatus(item.Name, "installing");
+
+                    _heldForApps = null;
+                    var success = await ProcessInstallWithDependenciesAsync(
+                        item.Name, installedItems, scheduledItems, downloadedPaths, outcomes, cancellationToken);
+
+                    // Reopened before the installer started, or a dependency is blocked too
+                    if (!success && _heldForApps != null)
+                    {
+                        held.Insert(i, (item, _heldForApps));
+                        ReportItemProgress(item.Name, "pending", 0, detail: $"Waiting for {string.Join(", ", _heldForApps)} to close");
+                        i++;
+                        continue;
+                    }
+
+                    string? failureDetail = null;
+                    if (!success && _installerService.CheckBlockingApps(item, out var stillOpen))
+                    {
+                        failureDetail = $"{string.Join(", ", stillOpen)} still running";
+                        RecordUserDeferral(item, $"blocking applications running: {string.Join(", ", stillOpen)}");
+                    }
+                    else if (!success)
+                    {
+                        failureDetail = SummarizeFailure(
+                            outcomes.LastOrDefault(o =>
+                                string.Equals(o.Name, item.Name, StringComparison.OrdinalIgnoreCase) && !o.Success)?.ErrorMessage);
+                    }
+                    ReportItemStatus(item.Name, success ? "installed" : "failed", failureDetail);
+
+                    if (success)
+                    {
+                        succeeded++;
+                    }
+                    else
+                    {
+                        failed++;
+                    }
+                }
+
+                if (_blockingAppWaitOver || held.Count == 0)
+                {
+                    break;
+                }
+                if (changed)
+                {
+                    PublishBlockingApps(state, held, notifyUser: false);
+                }
+
+                try
+                {
+                    await Task.Delay(BlockingAppPollInterval, cancellationToken);
+                }
+                catch (OperationCanceledException)
+                {
+                    break;
+                }
+            }
+        }
+        finally
+        {
+            BlockingAppsStateStore.Clear();
+        }
+
+        return (succeeded, failed);
+    }
+
+    /// <summary>
+    /// Writes blocking_apps.json for CimianStatus and, when asked, runs the user
+    /// notifications task so the close-apps toast shows now rather than at the
+    /// task's next 30-minute pass.
+    /// </summary>
+    private void PublishBlockingApps(BlockingAppsState state, List<(CatalogItem Item, List<string> Apps)> held, bool notifyUser)
+    {
+        state.Items = held.Select(h => new BlockingAppsItem
+        {
+            Item = h.Item.Name,
+            Name = h.Item.DisplayName ?? h.Item.Name,
+            Version = h.Item.Version,
+            Applications = h.Apps,
+            ForceClose = h.Item.ForceCloseBlockingApps
+        }).ToList();
+
+        ReportDetail(state.Items.Count == 1
+            ? state.Items[0].Describe(state.WaitUntil)
+            : $"Close {string.Join(", ", state.Items.SelectMany(i => i.Applications).Distinct(StringComparer.OrdinalIgnoreCase))} to finish installing updates");
+
+        try
+        {
+            BlockingAppsStateStore.Write(state);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not record blocking applications for CimianStatus: {ex.Message}");
+            return;
+        }
+
+        if (!notifyUser)
+        {
+            return;
+        }
+
+        try
+        {
+            using var process = System.Diagnostics.Process.Start(new System.Diagnostics.ProcessStartInfo
+            {
+                FileName = "schtasks.exe",
+                Arguments = $"/Run /TN \"{UserNotificationsTaskName}\"",
+                UseShellExecute = false,
+                CreateNoWindow = true,
+            });
+        }
+        catch (Exception ex) when (ex is System.ComponentModel.Win32Exception or InvalidOperationException)
+        {
+            LogDetail($"Could not run {UserNotificationsTaskName}: {ex.Message}");
+        }
+    }
+
+    #endregion
+
+    #region Upgrade Strategy
+
+    /// <summary>
+    /// Applies the item's upgrade_strategy before an install that changes the
+    /// installed version. uninstall-first removes the installed version using the
+    /// pkginfo Cimian recorded when it installed it (falling back to the new
+    /// pkginfo's uninstaller), and fails the install if removal fails so the new
+    /// version never lands on top of a half-removed one. in-place and side-by-side
+    /// leave the installed version to the installer.
+    /// </summary>
+    private async Task<bool> ApplyUpgradeStrategyAsync(
+        CatalogItem item,
+        IReadOnlyDictionary<string, string> receipt,
+        List<ItemOutcome> outcomes,
+        CancellationToken cancellationToken)
+    {
+        var strategy = UpgradeStrategy.Normalize(item.UpgradeStrategy);
+        if (strategy == null)
+        {
+            ConsoleLogger.Warn($"{item.Name}: unknown upgrade_strategy '{item.UpgradeStrategy}', using {UpgradeStrategy.InPlace}");
+            return true;
+        }
+        if (strategy != UpgradeStrategy.UninstallFirst
+            || !receipt.TryGetValue("Version", out var installedVersion)
+            || string.IsNullOrEmpty(installedVersion)
+            || CatalogService.CompareVersions(installedVersion, item.Version) == 0)
+        {
+            return true;
+        }
+
+        var installed = RollbackService.DeserializeItem(_rollbackService.Load(item.Name).InstalledItem);
+        if (installed == null || CatalogService.CompareVersions(installed.Version, installedVersion) != 0)
+        {
+            LogDetail($"    No recorded pkginfo for {item.Name} v{installedVersion}; removing with the v{item.Version} uninstaller");
+            installed = item;
+        }
+
+        if (!installed.IsUninstallable())
+        {
+            var msg = $"{item.Name} is uninstall-first but v{installedVersion} has no usable uninstaller";
+            ConsoleLogger.Error(msg);
+            _sessionLogger?.LogInstall(item.Name, item.Version, "install", "failed", msg);
+            outcomes.Add(new ItemOutcome(item.Name, item.Version, "install", false, msg, DateTime.UtcNow));
+            return false;
+        }
+
+        LogInfo($"Removing {item.Name} v{installedVersion} before installing v{item.Version} (upgrade_strategy: {strategy})");
+        _sessionLogger?.Log("INFO", $"upgrade_strategy {strategy}: uninstalling {item.Name} v{installedVersion}");
+        var (removed, output) = await _installerService.UninstallAsync(installed, cancellationToken);
+        if (!removed)
+        {
+            var msg = $"Could not remove {item.Name} v{installedVersion} before upgrade: {output}";
+            ConsoleLogger.Error(msg);
+            _sessionLogger?.LogInstall(item.Name, item.Version, "install", "failed", msg);
+            outcomes.Add(new ItemOutcome(item.Name, item.Version, "install", false, msg, DateTime.UtcNow));
+            return false;
+        }
+
+        return true;
+    }
+
+    #endregion
+
+    #region Config Snapshot
+
+    /// <summary>
+    /// Adopts the Config.yaml written by preflight and rebuilds the services on it.
+    /// Keeps the current config if the new file is mid-write or invalid.
+    /// </summary>
+    private void ReloadConfigAfterPreflight(int verbosity)
+    {
+        if (!_configService.TryLoadStableConfig(CimianConfig.ConfigPath, out var reloaded, out var error))
+        {
+            ConsoleLogger.Warn($"Config.yaml changed during preflight but could not be reloaded ({error}); continuing with the session's configuration");
+            _sessionLogger?.Log("WARN", $"Config reload after preflight rejected: {error}");
+            return;
+        }
+
+        // Session-only settings from the command line survive the reload
+        reloaded!.TraceDiagnostics |= _config.TraceDiagnostics;
+        if (_catalogOverride != null)
+        {
+            reloaded.Catalogs = new List<string>(_catalogOverride.Catalogs);
+        }
+        _config = reloaded;
+
+        // Apply verbosity settings again after reload
+        if (verbosity >= 1)
+        {
+            _config.Verbose = true;
+            _config.LogLevel = "INFO";
+        }
+        if (verbosity >= 3)
+        {
+            _config.Debug = true;
+            _config.LogLevel = "DEBUG";
+        }
+
+        // Recreate services with updated config
+        _manifestService = new ManifestService(_config);
+        _catalogService = new CatalogService(_config) { RecordGenerations = _catalogService.RecordGenerations };
+        _downloadService = new DownloadService(_config);
+        _downloadService.OriginRejected += LogOriginRejectedEvent;
+        _installerService = new InstallerService(_config);
+        _installerService.SetSessionLogger(_sessionLogger);
+        _manifestService.SetSessionLogger(_sessionLogger);
+        if (ScriptService.Session != null)
+        {
+            ScriptService.Session = ScriptService.Session with { CachePath = _config.CachePath, RepoUrl = _config.SoftwareRepoURL };
+        }
+        ScriptService.Settings = ScriptSettings.From(_config);
+        LogDetail("Reloaded configuration written by preflight");
+    }
+
+    /// <summary>
+    /// Warns (once per session) that Config.yaml was edited after the session took
+    /// its snapshot. The run carries on with the snapshot; the edit applies next run.
+    /// </summary>
+    private void WarnIfConfigChanged(string phase)
+    {
+        if (_configChangeWarned || _configSnapshot == null || !_configSnapshot.HasChanged())
+        {
+            return;
+        }
+
+        _configChangeWarned = true;
+        ConsoleLogger.Warn($"Config.yaml changed {phase}; continuing with the configuration loaded at {_configSnapshot.TakenAt:HH:mm:ss}. The change takes effect next run.");
+        _sessionLogger?.Log("WARN", $"Config.yaml modified mid-session ({phase}); using session snapshot");
+    }
+
+    #endregion
+
+    #region Status Reporter Methods (GUI integration)
+
+    /// <summary>
+    /// Reports a status message to the GUI (main headline)
+    /// </summary>
+    private void ReportStatus(string message)
+    {
+        _statusReporter?.Message(message);
+        _progressDisplay?.Status(message);
+    }
+
+    /// <summary>
+    /// Reports a detail message to the GUI (secondary text)
+    /// </summary>
+    private void ReportDetail(string message)
+    {
+        _statusReporter?.Detail(message);
+    }
+
+    /// <summary>
+    /// Reports progress percentage to the GUI
+    /// </summary>
+    private void ReportPercent(int percent)
+    {
+        _statusReporter?.Percent(percent);
+        _progressDisplay?.Percent(percent);
+    }
+
+    /// <summary>
+    /// Reports a per-item lifecycle stage to the GUI (pending, downloading,
+    /// downloaded, installing, installed, removing, removed, failed) so the
+    /// Updates list can render live status on each row.
+    /// </summary>
+    private void ReportItemStatus(string itemName, string stage, string? detail = null)
+    {
+        _statusReporter?.ItemStatus(itemName, stage, detail);
+        _progressDisplay?.ItemStatus(itemName, stage, detail);
+    }
+
+    /// <summary>
+    /// Reports an item's progress within its stage: download bytes and ETA, or
+    /// the install phase.
+    /// </summary>
+    private void ReportItemProgress(string itemName, string stage, int percent, long? bytes = null,
+        long? totalBytes = null, int? etaSeconds = null, string? detail = null)
+    {
+        _statusReporter?.ItemProgress(itemName, stage, percent, bytes, totalBytes, etaSeconds, detail);
+        _progressDisplay?.ItemProgress(itemName, stage, percent, bytes, totalBytes, etaSeconds, detail);
+    }
+
+    /// <summary>
+    /// Reports what an installing item is doing right now ("Running msi
+    /// installer") so its row in the status window says more than "Installing".
+    /// </summary>
+    private void ReportInstallPhase(string itemName, string phase)
+    {
+        ReportItemProgress(itemName, "installing", 0, detail: phase);
+    }
+
+    /// <summary>
+    /// "What's new" for an item that just installed: live to the GUIs for their
+    /// toast and status window, and into status.json for later.
+    /// </summary>
+    private void PublishReleaseNotes(CatalogItem item)
+    {
+        var notes = item.ReleaseNotes!.Trim();
+        _statusReporter?.ReleaseNotes(item.Name, item.Version, notes);
+        _sessionLogger?.AddReleaseNote(new LastRunReleaseNote
+        {
+            Name = item.Name,
+            DisplayName = item.DisplayName,
+            Version = item.Version,
+            Notes = notes
+        });
+    }
+
+    /// <summary>
+    /// Condenses an installer's raw failure output into a short, user-readable
+    /// reason for the GUI and problem_items — exit code first, with a plain-English
+    /// gloss for the common MSI codes so users can report a meaningful issue
+    /// instead of "Will be installed" that never changes.
+    /// </summary>
+    private static string? SummarizeFailure(string? rawOutput)
+    {
+        if (string.IsNullOrWhiteSpace(rawOutput)) return null;
+
         var firstLine = rawOutput
             .Split('\n')
             .Select(l => l.Trim())
@@ -2786,9 +4794,9 @@ public class UpdateEngine : IDisposable
     {
         if (_sessionLogger == null) return;
 
-        var toInstallNames = toInstall.Select(i => i.Name.ToLowerInvariant()).ToHashSet();
-        var toUpdateNames = toUpdate.Select(i => i.Name.ToLowerInvariant()).ToHashSet();
-        var toUninstallNames = toUninstall.Select(i => i.Name.ToLowerInvariant()).ToHashSet();
+        var toInstallNames = toInstall.Select(i => ItemKey.Canonical(i.Name)).ToHashSet();
+        var toUpdateNames = toUpdate.Select(i => ItemKey.Canonical(i.Name)).ToHashSet();
+        var toUninstallNames = toUninstall.Select(i => ItemKey.Canonical(i.Name)).ToHashSet();
 
         var items = new List<SessionPackageInfo>();
         var seen = new HashSet<string>(StringComparer.OrdinalIgnoreCase);
@@ -2799,7 +4807,7 @@ public class UpdateEngine : IDisposable
                 continue;
 
             var action = mi.Action?.ToLowerInvariant() ?? "install";
-            var key = mi.Name.ToLowerInvariant();
+            var key = ItemKey.Canonical(mi.Name);
 
             // Determine item type (Go parity: determineItemType mapping)
             var itemType = action switch
@@ -2879,6 +4887,70 @@ public class UpdateEngine : IDisposable
             });
         }
 
+        // Dependencies nothing requires any more (RemoveOrphanedDependencies)
+        foreach (var item in _orphanedDependencies)
+        {
+            if (!seen.Add(item.Name))
+                continue;
+
+            var key = ItemKey.Canonical(item.Name);
+            var hadOutcome = outcomesByName.TryGetValue(key, out var outcome) && outcome is not null;
+            items.Add(new SessionPackageInfo
+            {
+                Name = item.Name,
+                Version = item.Version,
+                Status = SessionItemStatusResolver.Resolve(
+                    hadOutcome ? outcome : null,
+                    isPendingInstall: false,
+                    isPendingUpdate: false,
+                    isPendingUninstall: toUninstallNames.Contains(key),
+                    manifestAction: "install"),
+                ItemType = "orphaned_dependency",
+                DisplayName = string.IsNullOrEmpty(item.DisplayName) ? item.Name : item.DisplayName,
+                ErrorMessage = hadOutcome && !outcome!.Success ? outcome.ErrorMessage : null,
+                StatusReason = "installed as a dependency; nothing installed requires it any more",
+                StatusReasonCode = Cimian.Core.Models.StatusReasonCode.OrphanedDependency,
+                DetectionMethod = Cimian.Core.Models.DetectionMethod.ManagedInstalls,
+                ActionPerformed = hadOutcome ? outcome!.Action : null,
+                OutcomeTimestamp = hadOutcome ? outcome!.Timestamp : null
+            });
+        }
+
+        // Items no manifest accounts for any more (RemoveUnmanagedItems), whether
+        // queued for removal this run or still inside their grace period
+        foreach (var unmanaged in _unmanagedItems)
+        {
+            var item = unmanaged.Item;
+            if (!seen.Add(item.Name))
+                continue;
+
+            var key = ItemKey.Canonical(item.Name);
+            var hadOutcome = outcomesByName.TryGetValue(key, out var outcome) && outcome is not null;
+            items.Add(new SessionPackageInfo
+            {
+                Name = item.Name,
+                Version = item.Version,
+                Status = SessionItemStatusResolver.Resolve(
+                    hadOutcome ? outcome : null,
+                    isPendingInstall: false,
+                    isPendingUpdate: false,
+                    isPendingUninstall: toUninstallNames.Contains(key),
+                    manifestAction: "install"),
+                ItemType = "unmanaged",
+                DisplayName = string.IsNullOrEmpty(item.DisplayName) ? item.Name : item.DisplayName,
+                ErrorMessage = hadOutcome && !outcome!.Success ? outcome.ErrorMessage : null,
+                StatusReason = unmanaged.Due
+                    ? $"not in any manifest since {unmanaged.FirstUnmanaged:yyyy-MM-dd}"
+                    : $"not in any manifest; removal after {unmanaged.RemoveAfter:yyyy-MM-dd HH:mm}",
+                StatusReasonCode = unmanaged.Due
+                    ? Cimian.Core.Models.StatusReasonCode.UnmanagedRemoval
+                    : Cimian.Core.Models.StatusReasonCode.UnmanagedGracePeriod,
+                DetectionMethod = Cimian.Core.Models.DetectionMethod.ManagedInstalls,
+                ActionPerformed = hadOutcome ? outcome!.Action : null,
+                OutcomeTimestamp = hadOutcome ? outcome!.Timestamp : null
+            });
+        }
+
         _sessionLogger.SetCurrentSessionItems(items);
 
         // Surface LoopGuard suppressions for reports/loop_suppressed.json. Pulled from
@@ -2890,6 +4962,50 @@ public class UpdateEngine : IDisposable
         }
     }
 
+    /// <summary>
+    /// Applies CacheSizeLimitMB / CacheRetentionDays to the download cache
+    /// </summary>
+    private void EvictCache(Dictionary<string, CatalogItem> catalogMap)
+    {
+        var (fileCount, bytesFreed) = new CacheManager(_config, catalogMap.Values).Evict();
+        if (fileCount > 0)
+        {
+            _sessionLogger?.Log("INFO", $"Evicted {fileCount} cached payloads ({bytesFreed / (1024 * 1024)} MB)");
+        }
+    }
+
+    /// <summary>
+    /// Runs the manifests' preflight_scripts or postflight_scripts. A failing
+    /// script is logged and the run carries on unless ScriptPolicies sets the
+    /// kind's OnFailure to fail, which aborts the run like PreflightFailureAction=abort.
+    /// </summary>
+    private async Task RunManifestScriptsAsync(
+        IReadOnlyList<(RepoScript Script, string Manifest)> scripts,
+        string phase,
+        CancellationToken cancellationToken)
+    {
+        if (scripts.Count == 0)
+        {
+            return;
+        }
+
+        ReportDetail($"Running {phase} scripts...");
+        var repoScripts = new RepoScriptService(_config, _scriptService);
+        repoScripts.SetSessionLogger(_sessionLogger);
+        var abortOnFailure = ScriptService.FailureActionFor(phase) == ScriptFailureAction.Fail;
+        var outcomes = await repoScripts.RunAllAsync(scripts.Select(s => (s.Script, (string?)s.Manifest)), phase,
+            stopOnFailure: abortOnFailure, cancellationToken: cancellationToken);
+        var failed = outcomes.Count(o => !o.Success);
+        LogInfo($"Ran {outcomes.Count} {phase} scripts ({failed} failed)");
+
+        if (failed > 0 && abortOnFailure)
+        {
+            var first = outcomes.First(o => !o.Success);
+            ConsoleLogger.Error($"Aborting: {phase} script {first.Name} failed ({first.Error}) and ScriptPolicies {phase} OnFailure is fail");
+            throw new Exception($"{phase} script {first.Name} failed");
+        }
+    }
+
     /// <summary>
     /// Ends the session with a summary of operations performed
     /// </summary>
@@ -2918,12 +5034,65 @@ public class UpdateEngine : IDisposable
             Removals = uninstallCount,
             Successes = successCount,
             Failures = failCount,
-            PackagesHandled = packagesHandled
+            Degraded = _degraded,
+            PackagesHandled = packagesHandled,
+            Deferred = _deferralReasons.Count > 0
+                ? _deferralReasons.Select(d => new SessionDeferral { Name = d.Item.Name, Version = d.Item.Version, Reason = d.Reason }).ToList()
+                : null
         };
 
+        // Pending reboot state after this run, for session.json / sessions.json
+        var pendingReasons = PendingReboot.GetReasons();
+        if (_restartNeeded)
+        {
+            pendingReasons.Insert(0, "cimian_items");
+        }
+        _sessionLogger.SetEnvironmentValue("pending_reboot", pendingReasons.Count > 0);
+        _sessionLogger.SetEnvironmentValue("pending_reboot_reasons", pendingReasons);
+        if (_restartRequiredBy.Count > 0)
+        {
+            _sessionLogger.SetEnvironmentValue("restart_required_by", _restartRequiredBy.ToList());
+        }
+        if (ProxySelector.Routes is { Count: > 0 } proxyRoutes)
+        {
+            _sessionLogger.SetEnvironmentValue("proxy_routes", proxyRoutes);
+        }
+        if (RepoMirrorSet.For(_config) is { HasFailover: true } mirrors)
+        {
+            _sessionLogger.SetEnvironmentValue("repo_mirror_order", mirrors.Order);
+            _sessionLogger.SetEnvironmentValue("repo_mirror_sources", mirrors.ServedBy);
+        }
+
+        // Facts for reports/facts.json; collected again when this run changed the
+        // machine so free disk and the like reflect the state after installs.
+        _sessionLogger.SetCurrentFacts(_manifestService
+            .GetSystemFacts(refresh: successCount > 0)
+            .ToInventory(includeIdentity: !_config.AnonymousUsageReports));
+        _sessionLogger.SetCurrentUsage(BuildUsageReport());
         _sessionLogger.EndSession(status, summary);
     }
 
+    /// <summary>
+    /// reports/usage.json for this run: everything in the installed items state
+    /// plus every installer fetched, labelled per AnonymousUsageReports.
+    /// </summary>
+    private UsageReport BuildUsageReport()
+    {
+        var identifier = string.IsNullOrEmpty(_config.ClientIdentifier) ? Environment.MachineName : _config.ClientIdentifier;
+        return new UsageReport
+        {
+            Machine = UsageReports.MachineLabel(identifier, _config.AnonymousUsageReports),
+            Anonymous = _config.AnonymousUsageReports,
+            SessionId = _sessionLogger?.SessionId ?? "",
+            RunType = _runType,
+            GeneratedAt = DateTime.UtcNow,
+            Installed = _installedItemsStore.Installed()
+                .Select(r => new UsageInstalledItem { Name = r.Name, Version = r.Version })
+                .ToList(),
+            Downloads = _downloadService.Downloads.ToList()
+        };
+    }
+
     #endregion
 
     #region InstallInfo.yaml
@@ -2944,9 +5113,9 @@ public class UpdateEngine : IDisposable
     {
         try
         {
-            var toInstallNames = toInstall.Select(i => i.Name.ToLowerInvariant()).ToHashSet();
-            var toUpdateNames = toUpdate.Select(i => i.Name.ToLowerInvariant()).ToHashSet();
-            var toUninstallNames = toUninstall.Select(i => i.Name.ToLowerInvariant()).ToHashSet();
+            var toInstallNames = toInstall.Select(i => ItemKey.Canonical(i.Name)).ToHashSet();
+            var toUpdateNames = toUpdate.Select(i => ItemKey.Canonical(i.Name)).ToHashSet();
+            var toUninstallNames = toUninstall.Select(i => ItemKey.Canonical(i.Name)).ToHashSet();
 
             var info = new InstallInfoFile
             {
@@ -2955,12 +5124,17 @@ public class UpdateEngine : IDisposable
 
             var seen = new HashSet<string>(StringComparer.OrdinalIgnoreCase);
 
+            // Compliance covers what the admin mandated, not what a user asked for
+            var mandatoryItems = 0;
+            var missingItems = new List<string>();
+            var noncompliantConfigurations = new List<string>();
+
             foreach (var mi in manifestItems)
             {
                 if (string.IsNullOrEmpty(mi.Name) || !seen.Add(mi.Name))
                     continue;
 
-                var key = mi.Name.ToLowerInvariant();
+                var key = ItemKey.Canonical(mi.Name);
                 catalogMap.TryGetValue(key, out var cat);
 
                 var action = mi.Action?.ToLowerInvariant() ?? "install";
@@ -2972,6 +5146,28 @@ public class UpdateEngine : IDisposable
                         // Always bookkeep the name as processed.
                         info.ProcessedInstalls.Add(mi.Name);
 
+                        // Configuration items report compliance, not install state
+                        if (cat is { IsConfigurationItem: true })
+                        {
+                            // The check re-tests and records, so read the record after it
+                            var configurationCheck = _statusService.CheckStatus(cat, action, _config.CachePath);
+                            var record = new ConfigurationItemsStore().Get(cat.Name);
+                            info.ConfigurationItems.Add(new InstallInfoConfigurationItem
+                            {
+                                Name = mi.Name,
+                                DisplayName = cat.DisplayName,
+                                Version = cat.Version,
+                                Method = cat.Configuration?.Method ?? "script",
+                                Compliant = !configurationCheck.NeedsAction,
+                                EvaluatedAt = record?.EvaluatedAt,
+                                RemediatedAt = record?.RemediatedAt,
+                                Error = record?.Error
+                            });
+                            if (!mi.PromotedFromOptional && configurationCheck.NeedsAction)
+                                noncompliantConfigurations.Add(mi.Name);
+                            break;
+                        }
+
                         // managed_updates from the manifest is surfaced as a name list.
                         if (action == "update")
                             info.ManagedUpdates.Add(mi.Name);
@@ -2990,6 +5186,15 @@ public class UpdateEngine : IDisposable
                         // its record so the Updates list survives partial writes.
                         var installPending = needsAction && (installScheduled || mi.PromotedFromOptional);
 
+                        // managed_updates only bind software that is present; a managed
+                        // install missing from every catalog can't be verified, so it counts as missing
+                        if (!mi.PromotedFromOptional && (action == "install" || cat != null))
+                        {
+                            mandatoryItems++;
+                            if (cat == null || needsAction)
+                                missingItems.Add(mi.Name);
+                        }
+
                         if (installPending)
                         {
                             // Needs install or update this session — full record on managed_installs.
@@ -3084,7 +5289,7 @@ public class UpdateEngine : IDisposable
             {
                 foreach (var o in outcomes.Where(o => !o.Success))
                 {
-                    catalogMap.TryGetValue(o.Name.ToLowerInvariant(), out var pcat);
+                    catalogMap.TryGetValue(ItemKey.Canonical(o.Name), out var pcat);
                     info.ProblemItems.Add(new InstallInfoProblem
                     {
                         Name = o.Name,
@@ -3103,6 +5308,8 @@ public class UpdateEngine : IDisposable
             File.WriteAllText(path, yaml);
 
             LogInfo($"Wrote {path}");
+
+            ExportCompliance(mandatoryItems, missingItems, noncompliantConfigurations);
         }
         catch (Exception ex)
         {
@@ -3111,6 +5318,27 @@ public class UpdateEngine : IDisposable
         }
     }
 
+    /// <summary>
+    /// Publishes the compliance signal (ComplianceExport) for the state InstallInfo
+    /// was just written from, so it is current after check-only, install and
+    /// precache runs alike.
+    /// </summary>
+    private void ExportCompliance(int mandatoryItems, List<string> missingItems, List<string> noncompliantConfigurations)
+    {
+        if (!ComplianceSignal.Exports(_config.ComplianceExport, "registry") &&
+            !ComplianceSignal.Exports(_config.ComplianceExport, "file"))
+        {
+            return;
+        }
+
+        var state = ComplianceSignal.Evaluate(mandatoryItems, missingItems, DateTime.Now, noncompliantConfigurations);
+        ComplianceSignal.Publish(state, _config.ComplianceExport);
+        LogInfo(state.Compliant
+            ? $"Compliance: compliant ({state.MandatoryItems} mandatory item(s) installed)"
+            : $"Compliance: not compliant, missing {string.Join(", ", state.MissingItems.Concat(state.NoncompliantConfigurations))}");
+        _sessionLogger?.Log("INFO", $"Compliance exported: compliant={state.Compliant} missing={state.MissingItems.Count}");
+    }
+
     /// <summary>
     /// Consumes self-serve removal requests that have been satisfied: once the
     /// uninstaller for an item the user asked to remove has succeeded, its name is
@@ -3163,9 +5391,10 @@ public class UpdateEngine : IDisposable
             Description = cat?.Description,
             Category = cat?.Category,
             Developer = cat?.Developer,
+            Icon = cat?.IconName,
             InstallerItemSize = cat?.Installer?.Size ?? 0,
             Uninstallable = cat?.IsUninstallable() ?? false,
-            RestartAction = cat?.RestartAction,
+            RestartAction = cat?.EffectiveRestartAction,
             ForceInstallAfterDate = cat?.ForceInstallAfterDate,
         };
         return item;
@@ -3180,6 +5409,10 @@ public class UpdateEngine : IDisposable
     private InstallInfoItem BuildOptionalInstallRecord(string name, CatalogItem? cat, string? pendingStatus)
     {
         var optItem = BuildInstallInfoItem(name, cat);
+        // Managed Software Center and cimitrigger only offer Remove for what the
+        // self-service uninstall policy will actually carry out.
+        optItem.Uninstallable = optItem.Uninstallable && cat != null
+            && IsSelfServiceUninstallAllowed(_config.AllowSelfServiceUninstall, cat);
         if (cat != null)
         {
             var status = _statusService.CheckStatus(cat, "install", _config.CachePath);
@@ -3234,12 +5467,12 @@ public class UpdateEngine : IDisposable
     /// </summary>
     private static bool RequiresRestart(CatalogItem item)
     {
-        return item.RestartAction is "RequireRestart" or "RecommendRestart";
+        return item.EffectiveRestartAction is "RequireRestart" or "RecommendRestart";
     }
 
     private static bool RequiresLogout(CatalogItem item)
     {
-        return item.RestartAction is "RequireLogout";
+        return item.EffectiveRestartAction is "RequireLogout";
     }
 
     /// <summary>
@@ -3250,51 +5483,157 @@ public class UpdateEngine : IDisposable
     /// </summary>
     private static bool WouldInterruptUser(CatalogItem item)
     {
-        return item.RestartAction is "RequireRestart" or "RecommendRestart"
+        return item.EffectiveRestartAction is "RequireRestart" or "RecommendRestart"
             or "RequireLogout" or "RecommendLogout";
     }
 
     /// <summary>
     /// Triggers a system restart after all install/uninstall operations complete.
-    /// In auto/bootstrap mode: schedules a reboot with a 5-minute grace period.
+    /// In auto/bootstrap mode: schedules a reboot with the RestartPolicy grace
+    /// period (RestartGracePeriodMinutes, else 5 minutes for countdown and 1 for
+    /// immediate), unless the policy is prompt or never or the user is in Do Not
+    /// Disturb (see <see cref="HoldRestartForFocus"/>).
     /// In interactive mode: logs a recommendation only.
+    /// Either way the owed restart goes to restart.json so CimianStatus can show
+    /// the countdown or ask the user to restart.
     /// </summary>
     private void PerformRestartAction()
     {
         ConsoleLogger.Warn("One or more items require a system restart");
         _sessionLogger?.Log("INFO", "Restart required by installed/removed items");
 
-        if (_auto || _isBootstrap)
+        var policy = RestartPolicy.Normalize(_config.RestartPolicy) ?? RestartPolicy.Countdown;
+        if (!_auto && !_isBootstrap)
         {
-            ConsoleLogger.Warn("Scheduling system restart in 5 minutes...");
-            _sessionLogger?.Log("INFO", "Scheduling system restart (auto/bootstrap mode)");
+            ConsoleLogger.Warn("Restart recommended - please restart your computer to complete updates");
+            _sessionLogger?.Log("INFO", "Restart recommended (interactive mode - not forcing)");
+            RecordRestartState(policy, null);
+            return;
+        }
 
-            try
+        if (policy is RestartPolicy.Never or RestartPolicy.Prompt)
+        {
+            ConsoleLogger.Warn(policy == RestartPolicy.Never
+                ? "RestartPolicy is never - restart left to the administrator"
+                : "RestartPolicy is prompt - asking the user to restart");
+            _sessionLogger?.Log("INFO", $"Restart not scheduled (RestartPolicy: {policy})");
+            RecordRestartState(policy, null);
+            return;
+        }
+
+        if (HoldRestartForFocus())
+        {
+            RecordRestartState(policy, null);
+            return;
+        }
+
+        var graceSeconds = RestartPolicy.GraceSeconds(policy, _config.RestartGracePeriodMinutes);
+        ConsoleLogger.Warn($"Scheduling system restart in {graceSeconds / 60} minute(s)...");
+        _sessionLogger?.Log("INFO", "Scheduling system restart (auto/bootstrap mode)");
+
+        try
+        {
+            var psi = new System.Diagnostics.ProcessStartInfo
             {
-                var psi = new System.Diagnostics.ProcessStartInfo
-                {
-                    FileName = "shutdown.exe",
-                    Arguments = "/r /t 300 /c \"Cimian: System restarting to complete software updates\"",
-                    UseShellExecute = false,
-                    CreateNoWindow = true,
-                };
-                System.Diagnostics.Process.Start(psi);
-                ConsoleLogger.Info("System restart scheduled (300 second delay)");
-                _sessionLogger?.Log("INFO", "System restart scheduled via shutdown.exe /r /t 300");
-            }
-            catch (Exception ex)
+                FileName = "shutdown.exe",
+                Arguments = $"/r /t {graceSeconds} /c \"Cimian: System restarting to complete software updates\"",
+                UseShellExecute = false,
+                CreateNoWindow = true,
+            };
+            System.Diagnostics.Process.Start(psi);
+            ConsoleLogger.Info($"System restart scheduled ({graceSeconds} second delay)");
+            _sessionLogger?.Log("INFO", $"System restart scheduled via shutdown.exe /r /t {graceSeconds}");
+            File.Delete(CimianPaths.RestartDeferredFlagFile);
+            RestartScheduled = true;
+            RecordRestartState(policy, DateTime.Now.AddSeconds(graceSeconds));
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Error($"Failed to schedule system restart: {ex.Message}");
+            _sessionLogger?.Log("ERROR", $"Failed to schedule system restart: {ex.Message}");
+            RecordRestartState(policy, null);
+        }
+    }
+
+    private void RecordRestartState(string policy, DateTime? scheduledFor)
+    {
+        try
+        {
+            RestartStateStore.Write(new RestartState
             {
-                ConsoleLogger.Error($"Failed to schedule system restart: {ex.Message}");
-                _sessionLogger?.Log("ERROR", $"Failed to schedule system restart: {ex.Message}");
-            }
+                RecordedAt = DateTime.Now,
+                Policy = policy,
+                ScheduledFor = scheduledFor,
+                Items = _restartRequiredBy.Distinct(StringComparer.OrdinalIgnoreCase).ToList(),
+                PendingReasons = PendingReboot.GetReasons()
+            });
         }
-        else
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
         {
-            ConsoleLogger.Warn("Restart recommended - please restart your computer to complete updates");
-            _sessionLogger?.Log("INFO", "Restart recommended (interactive mode - not forcing)");
+            ConsoleLogger.Debug($"Could not write restart state: {ex.Message}");
         }
     }
 
+    /// <summary>
+    /// Auto runs don't start the restart countdown while the user is in Focus
+    /// Assist, presenting or full-screen. The pending restart is kept in
+    /// <see cref="CimianPaths.RestartDeferredFlagFile"/> (with the earliest
+    /// deadline) so the first later run that finds the user available restarts.
+    /// Bootstrap never waits, and a passed force_install_after_date overrides.
+    /// </summary>
+    private bool HoldRestartForFocus()
+    {
+        if (_isBootstrap || !_config.RespectFocusAssist)
+        {
+            return false;
+        }
+
+        var state = FocusAssist.GetState();
+        if (state == FocusState.Available)
+        {
+            return false;
+        }
+
+        var deadline = EarlierOf(_restartDeadline, ReadDeferredRestartDeadline());
+        if (deadline != null && DateTime.Now >= deadline.Value)
+        {
+            LogInfo($"Restarting although {FocusAssist.Describe(state)}: deadline {deadline.Value:yyyy-MM-dd} has passed");
+            _sessionLogger?.Log("INFO", $"Restart deadline {deadline.Value:yyyy-MM-dd} passed, overriding focus state {state}");
+            return false;
+        }
+
+        ConsoleLogger.Warn($"Restart deferred: {FocusAssist.Describe(state)}");
+        _sessionLogger?.Log("INFO", $"Restart deferred until the user leaves {state}");
+        try
+        {
+            File.WriteAllText(CimianPaths.RestartDeferredFlagFile, deadline?.ToString("o") ?? "");
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not record deferred restart: {ex.Message}");
+        }
+        return true;
+    }
+
+    private static DateTime? ReadDeferredRestartDeadline()
+    {
+        try
+        {
+            return File.Exists(CimianPaths.RestartDeferredFlagFile)
+                && DateTime.TryParse(File.ReadAllText(CimianPaths.RestartDeferredFlagFile).Trim(), null,
+                    System.Globalization.DateTimeStyles.RoundtripKind, out var deadline)
+                ? deadline
+                : null;
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            return null;
+        }
+    }
+
+    private static DateTime? EarlierOf(DateTime? a, DateTime? b) =>
+        a == null ? b : b == null ? a : (a < b ? a : b);
+
     /// <summary>
     /// Forces a user logout after all install/uninstall operations complete.
     /// Matches Munki's RequireLogout behavior.
@@ -3346,6 +5685,7 @@ public class UpdateEngine : IDisposable
         _disposed = true;
 
         _statusReporter?.Dispose();
+        _progressDisplay?.Dispose();
         _sessionLogger?.Dispose();
 
         GC.SuppressFinalize(this);
@@ -0,0 +1,410 @@
+using System.ComponentModel;
+using System.Diagnostics;
+using System.IO.Pipes;
+using System.Runtime.InteropServices;
+using System.Security.Principal;
+using System.Text;
+using System.Text.RegularExpressions;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Runs install_context: user installers as whoever is logged on at the
+/// console. managedsoftwareupdate runs as SYSTEM, which may take the console
+/// session's token (WTSQueryUserToken) and start a process with it directly, so
+/// nothing in the user's session has to cooperate. The process gets the user's
+/// own environment, plus the CIMIAN_* variables the caller set, and its output
+/// comes back through an inherited pipe as it would from Process.
+/// </summary>
+public static class UserContextRunner
+{
+    private const uint InvalidSessionId = 0xFFFFFFFF;
+    private const uint CreateUnicodeEnvironment = 0x00000400;
+    private const uint CreateNoWindow = 0x08000000;
+    private const int StartfUseStdHandles = 0x00000100;
+    private const uint WaitTimeout = 0x00000102;
+
+    private static readonly TimeSpan PollInterval = TimeSpan.FromMilliseconds(250);
+    private static readonly TimeSpan OutputDrainTimeout = TimeSpan.FromSeconds(5);
+
+    private static readonly Regex EnvironmentReference = new(@"%([^%\\/]+)%", RegexOptions.Compiled);
+
+    /// <summary>Installer types that can run as the user; the rest need SYSTEM.</summary>
+    public static bool SupportsInstallerType(string installerType) =>
+        installerType.ToLowerInvariant() is "exe" or "msi" or "msix" or "appx" or "powershell" or "ps1" or "nopkg" or "script" or "configuration";
+
+    /// <summary>DOMAIN\user at the console, or null when nobody is logged on.</summary>
+    public static string? GetActiveUserName()
+    {
+        try
+        {
+            using var token = OpenConsoleUserToken();
+            if (token == null)
+            {
+                return null;
+            }
+            using var identity = new WindowsIdentity(token.DangerousGetHandle());
+            return identity.Name;
+        }
+        catch (Exception ex) when (ex is DllNotFoundException or EntryPointNotFoundException or Win32Exception)
+        {
+            ConsoleLogger.Debug($"Console user unavailable: {ex.Message}");
+            return null;
+        }
+    }
+
+    /// <summary>SID of the user at the console (their HKEY_USERS hive), or null when nobody is logged on.</summary>
+    public static string? GetActiveUserSid()
+    {
+        try
+        {
+            using var token = OpenConsoleUserToken();
+            if (token == null)
+            {
+                return null;
+            }
+            using var identity = new WindowsIdentity(token.DangerousGetHandle());
+            return identity.User?.Value;
+        }
+        catch (Exception ex) when (ex is DllNotFoundException or EntryPointNotFoundException or Win32Exception)
+        {
+            ConsoleLogger.Debug($"Console user unavailable: {ex.Message}");
+            return null;
+        }
+    }
+
+    /// <summary>
+    /// The item's installs checks with %LOCALAPPDATA%-style references resolved
+    /// against the console user's environment rather than SYSTEM's profile.
+    /// Other items, or nobody logged on, get the checks unchanged.
+    /// </summary>
+    public static List<InstallCheckItem> ResolveInstalls(CatalogItem item)
+    {
+        if (!item.InstallsAsUser || !item.Installs.Any(i => i.Path?.Contains('%') == true))
+        {
+            return item.Installs;
+        }
+
+        Dictionary<string, string>? environment;
+        try
+        {
+            environment = ReadUserEnvironment();
+        }
+        catch (Exception ex) when (ex is DllNotFoundException or EntryPointNotFoundException or Win32Exception)
+        {
+            ConsoleLogger.Debug($"Could not read the console user's environment: {ex.Message}");
+            environment = null;
+        }
+        if (environment == null)
+        {
+            return item.Installs;
+        }
+
+        return item.Installs
+            .Select(i => i.Path?.Contains('%') == true ? i.WithPath(Expand(i.Path, environment)) : i)
+            .ToList();
+    }
+
+    /// <summary>Replaces %NAME% references found in <paramref name="environment"/>; others are left as they are.</summary>
+    internal static string Expand(string path, IReadOnlyDictionary<string, string> environment) =>
+        EnvironmentReference.Replace(path, m => environment.TryGetValue(m.Groups[1].Value, out var value) ? value : m.Value);
+
+    /// <summary>
+    /// Runs <paramref name="startInfo"/>'s command line as the console user and
+    /// returns its exit code with stdout and stderr combined. Throws
+    /// <see cref="InvalidOperationException"/> when nobody is logged on and
+    /// <see cref="TimeoutException"/> (after ending the process and everything it
+    /// started, listed in the message) when it overruns.
+    /// </summary>
+    public static async Task<(int ExitCode, string Output)> RunAsync(
+        ProcessStartInfo startInfo,
+        TimeSpan timeout,
+        CancellationToken cancellationToken)
+    {
+        using var token = OpenConsoleUserToken()
+            ?? throw new InvalidOperationException("No user is logged on at the console");
+
+        var environment = ReadEnvironment(token) ?? new Dictionary<string, string>(StringComparer.OrdinalIgnoreCase);
+        foreach (var (name, value) in AddedVariables(startInfo))
+        {
+            environment[name] = value;
+        }
+
+        var commandLine = new StringBuilder(BuildCommandLine(startInfo));
+        var workingDirectory = string.IsNullOrEmpty(startInfo.WorkingDirectory) ? null : startInfo.WorkingDirectory;
+
+        using var pipe = new AnonymousPipeServerStream(PipeDirection.In, HandleInheritability.Inheritable);
+        var startup = new StartupInfo
+        {
+            cb = Marshal.SizeOf<StartupInfo>(),
+            lpDesktop = @"winsta0\default",
+            dwFlags = StartfUseStdHandles,
+            hStdOutput = pipe.ClientSafePipeHandle.DangerousGetHandle(),
+            hStdError = pipe.ClientSafePipeHandle.DangerousGetHandle()
+        };
+
+        var environmentBlock = Marshal.StringToHGlobalUni(BuildEnvironmentBlock(environment));
+        ProcessInformation process;
+        try
+        {
+            if (!CreateProcessAsUser(token, null, commandLine, IntPtr.Zero, IntPtr.Zero, true,
+                    CreateUnicodeEnvironment | CreateNoWindow, environmentBlock, workingDirectory,
+                    ref startup, out process))
+            {
+                throw new Win32Exception(Marshal.GetLastWin32Error(), $"CreateProcessAsUser failed for {startInfo.FileName}");
+            }
+        }
+        finally
+        {
+            Marshal.FreeHGlobal(environmentBlock);
+            // Only the child holds the write end now, so the read ends when it exits
+            pipe.DisposeLocalCopyOfClientHandle();
+        }
+
+        ConsoleLogger.Detail($"Process started as the console user with PID {process.dwProcessId}");
+        using var job = InstallerJob.TryAttach(process.hProcess);
+        var output = new StringBuilder();
+        var readTask = DrainAsync(pipe, output);
+
+        try
+        {
+            var deadline = timeout == Timeout.InfiniteTimeSpan ? DateTime.MaxValue : DateTime.UtcNow + timeout;
+            while (WaitForSingleObject(process.hProcess, 0) == WaitTimeout)
+            {
+                if (cancellationToken.IsCancellationRequested || DateTime.UtcNow >= deadline)
+                {
+                    var hProcess = process.hProcess;
+                    var stop = InstallerJob.StopTree(process.dwProcessId, job, () => TerminateProcess(hProcess, 1));
+                    if (cancellationToken.IsCancellationRequested)
+                    {
+                        ConsoleLogger.Warn($"Cancelled {startInfo.FileName}; processes stopped:\n{stop.Describe()}");
+                        cancellationToken.ThrowIfCancellationRequested();
+                    }
+                    throw new TimeoutException(
+                        $"{startInfo.FileName} did not finish within {timeout.TotalMinutes} minutes. Processes stopped:\n{stop.Describe()}");
+                }
+                await Task.Delay(PollInterval, CancellationToken.None);
+            }
+
+            if (!GetExitCodeProcess(process.hProcess, out var exitCode))
+            {
+                throw new Win32Exception(Marshal.GetLastWin32Error(), "GetExitCodeProcess failed");
+            }
+
+            // An app the installer launched can inherit the pipe and keep it open; don't wait on it
+            await Task.WhenAny(readTask, Task.Delay(OutputDrainTimeout, CancellationToken.None));
+            lock (output)
+            {
+                return ((int)exitCode, output.ToString());
+            }
+        }
+        finally
+        {
+            CloseHandle(process.hThread);
+            CloseHandle(process.hProcess);
+        }
+    }
+
+    private static async Task DrainAsync(Stream pipe, StringBuilder output)
+    {
+        try
+        {
+            using var reader = new StreamReader(pipe, leaveOpen: true);
+            var buffer = new char[4096];
+            int read;
+            while ((read = await reader.ReadAsync(buffer)) > 0)
+            {
+                lock (output)
+                {
+                    output.Append(buffer, 0, read);
+                }
+            }
+        }
+        catch (Exception ex) when (ex is IOException or ObjectDisposedException)
+        {
+            // The pipe went away with the method; what was read is kept
+        }
+    }
+
+    /// <summary>
+    /// Quotes an argument list the way CommandLineToArgvW splits it back up.
+    /// Arguments, when set instead, are used as written.
+    /// </summary>
+    internal static string BuildCommandLine(ProcessStartInfo startInfo)
+    {
+        var parts = new List<string> { Quote(startInfo.FileName) };
+        if (startInfo.ArgumentList.Count > 0)
+        {
+            parts.AddRange(startInfo.ArgumentList.Select(Quote));
+        }
+        else if (!string.IsNullOrEmpty(startInfo.Arguments))
+        {
+            parts.Add(startInfo.Arguments);
+        }
+        return string.Join(" ", parts);
+    }
+
+    private static string Quote(string argument)
+    {
+        if (argument.Length > 0 && argument.IndexOfAny([' ', '\t', '\n', '"']) < 0)
+        {
+            return argument;
+        }
+
+        var quoted = new StringBuilder("\"");
+        var backslashes = 0;
+        foreach (var c in argument)
+        {
+            if (c == '\\')
+            {
+                backslashes++;
+                continue;
+            }
+            // Backslashes only escape when they precede a quote
+            quoted.Append('\\', c == '"' ? backslashes * 2 + 1 : backslashes).Append(c);
+            backslashes = 0;
+        }
+        return quoted.Append('\\', backslashes * 2).Append('"').ToString();
+    }
+
+    /// <summary>Variables the caller added or changed on top of this process's own environment.</summary>
+    private static IEnumerable<(string Name, string Value)> AddedVariables(ProcessStartInfo startInfo) =>
+        startInfo.Environment
+            .Where(e => e.Value != null && Environment.GetEnvironmentVariable(e.Key) != e.Value)
+            .Select(e => (e.Key, e.Value!));
+
+    private static string BuildEnvironmentBlock(Dictionary<string, string> environment) =>
+        string.Concat(environment.Select(e => $"{e.Key}={e.Value}\0")) + "\0";
+
+    private static Dictionary<string, string>? ReadUserEnvironment()
+    {
+        using var token = OpenConsoleUserToken();
+        return token == null ? null : ReadEnvironment(token);
+    }
+
+    /// <summary>The user's environment as Explorer would give it: their profile folders, TEMP and PATH.</summary>
+    private static Dictionary<string, string>? ReadEnvironment(SafeUserToken token)
+    {
+        if (!CreateEnvironmentBlock(out var block, token, false))
+        {
+            return null;
+        }
+        try
+        {
+            var environment = new Dictionary<string, string>(StringComparer.OrdinalIgnoreCase);
+            var offset = 0;
+            while (true)
+            {
+                var entry = Marshal.PtrToStringUni(block + offset);
+                if (string.IsNullOrEmpty(entry))
+                {
+                    break;
+                }
+                // Skip the "=C:=C:\" drive entries
+                var separator = entry.IndexOf('=', 1);
+                if (separator > 0)
+                {
+                    environment[entry[..separator]] = entry[(separator + 1)..];
+                }
+                offset += (entry.Length + 1) * sizeof(char);
+            }
+            return environment;
+        }
+        finally
+        {
+            DestroyEnvironmentBlock(block);
+        }
+    }
+
+    private static SafeUserToken? OpenConsoleUserToken()
+    {
+        var session = WTSGetActiveConsoleSessionId();
+        if (session == InvalidSessionId || !WTSQueryUserToken(session, out var token))
+        {
+            return null;
+        }
+        return new SafeUserToken(token);
+    }
+
+    private sealed class SafeUserToken : Microsoft.Win32.SafeHandles.SafeHandleZeroOrMinusOneIsInvalid
+    {
+        public SafeUserToken(IntPtr handle) : base(ownsHandle: true)
+        {
+            SetHandle(handle);
+        }
+
+        protected override bool ReleaseHandle() => CloseHandle(handle);
+    }
+
+    [StructLayout(LayoutKind.Sequential, CharSet = CharSet.Unicode)]
+    private struct StartupInfo
+    {
+        public int cb;
+        public string? lpReserved;
+        public string? lpDesktop;
+        public string? lpTitle;
+        public int dwX;
+        public int dwY;
+        public int dwXSize;
+        public int dwYSize;
+        public int dwXCountChars;
+        public int dwYCountChars;
+        public int dwFillAttribute;
+        public int dwFlags;
+        public short wShowWindow;
+        public short cbReserved2;
+        public IntPtr lpReserved2;
+        public IntPtr hStdInput;
+        public IntPtr hStdOutput;
+        public IntPtr hStdError;
+    }
+
+    [StructLayout(LayoutKind.Sequential)]
+    private struct ProcessInformation
+    {
+        public IntPtr hProcess;
+        public IntPtr hThread;
+        public int dwProcessId;
+        public int dwThreadId;
+    }
+
+    [DllImport("kernel32.dll")]
+    private static extern uint WTSGetActiveConsoleSessionId();
+
+    [DllImport("wtsapi32.dll", SetLastError = true)]
+    private static extern bool WTSQueryUserToken(uint sessionId, out IntPtr token);
+
+    [DllImport("userenv.dll", SetLastError = true)]
+    private static extern bool CreateEnvironmentBlock(out IntPtr environment, SafeUserToken token, bool inherit);
+
+    [DllImport("userenv.dll", SetLastError = true)]
+    private static extern bool DestroyEnvironmentBlock(IntPtr environment);
+
+    [DllImport("advapi32.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+    private static extern bool CreateProcessAsUser(
+        SafeUserToken token,
+        string? applicationName,
+        StringBuilder commandLine,
+        IntPtr processAttributes,
+        IntPtr threadAttributes,
+        bool inheritHandles,
+        uint creationFlags,
+        IntPtr environment,
+        string? currentDirectory,
+        ref StartupInfo startupInfo,
+        out ProcessInformation processInformation);
+
+    [DllImport("kernel32.dll", SetLastError = true)]
+    private static extern uint WaitForSingleObject(IntPtr handle, uint milliseconds);
+
+    [DllImport("kernel32.dll", SetLastError = true)]
+    private static extern bool GetExitCodeProcess(IntPtr process, out uint exitCode);
+
+    [DllImport("kernel32.dll", SetLastError = true)]
+    private static extern bool TerminateProcess(IntPtr process, uint exitCode);
+
+    [DllImport("kernel32.dll", SetLastError = true)]
+    private static extern bool CloseHandle(IntPtr handle);
+}
@@ -0,0 +1,310 @@
+using System.Text;
+using System.Text.Json;
+using System.Text.Json.Nodes;
+using System.Text.RegularExpressions;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.Core.Models;
+using Cimian.Core.Services;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Fires the Config.yaml Webhooks after a run: one POST per webhook and
+/// matching event (completed, partial_failure, failed, self_update_scheduled,
+/// self_update_refused), shaped for Slack, Teams or as a generic JSON document.
+///
+/// The run is read back from its session.json and events.jsonl, like
+/// ReportUploader does. Webhooks go through the configured proxy but never get
+/// the repo's credentials or client certificate; the secret is the URL itself.
+/// A webhook that can't be delivered is logged and dropped, not queued.
+/// </summary>
+public sealed class WebhookNotifier
+{
+    private const int MaxAttempts = 2;
+
+    private static readonly Dictionary<string, string> DefaultTemplates = new()
+    {
+        [WebhookEvent.Completed] = "{hostname}: {run_type} run completed ({installs} installs, {updates} updates, {removals} removals)",
+        [WebhookEvent.PartialFailure] = "{hostname}: {run_type} run finished with {failed_count} failed item(s): {failed_items}",
+        [WebhookEvent.Failed] = "{hostname}: {run_type} run failed. Failed items: {failed_items}",
+        [WebhookEvent.SelfUpdateScheduled] = "{hostname}: Cimian self-update {self_update} scheduled for the next service restart",
+        [WebhookEvent.SelfUpdateRefused] = "{hostname}: Cimian self-update {self_update_refused} refused, its hash or signature didn't verify"
+    };
+
+    private static readonly Regex Placeholder = new(@"\{(\w+)\}", RegexOptions.Compiled);
+
+    private readonly CimianConfig _config;
+    private readonly HttpClient _httpClient;
+    private readonly string _hostname;
+    private readonly TimeSpan _retryDelay;
+
+    public WebhookNotifier(CimianConfig config, HttpClient? httpClient = null, string? hostname = null, TimeSpan? retryDelay = null)
+    {
+        _config = config;
+        _httpClient = httpClient ?? CreateHttpClient(config);
+        _hostname = hostname ?? Environment.MachineName;
+        _retryDelay = retryDelay ?? TimeSpan.FromSeconds(2);
+    }
+
+    public bool IsEnabled => _config.Webhooks.Count > 0;
+
+    /// <summary>
+    /// What a run's webhooks report, read from its session directory.
+    /// </summary>
+    internal sealed record WebhookRun(
+        string SessionId,
+        string RunType,
+        string Status,
+        SessionLogSummary Summary,
+        IReadOnlyList<string> FailedItems,
+        IReadOnlyList<string> SelfUpdates,
+        IReadOnlyList<string> RefusedSelfUpdates)
+    {
+        /// <summary>The events this run raises, session result first.</summary>
+        public IEnumerable<string> Events
+        {
+            get
+            {
+                yield return WebhookEvent.ForSessionStatus(Status);
+                if (SelfUpdates.Count > 0)
+                {
+                    yield return WebhookEvent.SelfUpdateScheduled;
+                }
+                if (RefusedSelfUpdates.Count > 0)
+                {
+                    yield return WebhookEvent.SelfUpdateRefused;
+                }
+            }
+        }
+    }
+
+    /// <summary>
+    /// Sends every webhook whose Events match the run in
+    /// <paramref name="sessionDir"/>. Never throws.
+    /// </summary>
+    public async Task NotifyAsync(string? sessionDir, CancellationToken cancellationToken = default)
+    {
+        if (!IsEnabled || sessionDir == null)
+        {
+            return;
+        }
+
+        try
+        {
+            var run = ReadRun(sessionDir);
+            if (run == null)
+            {
+                return;
+            }
+
+            foreach (var webhook in _config.Webhooks)
+            {
+                foreach (var evt in run.Events.Where(webhook.EffectiveEvents.Contains))
+                {
+                    await SendAsync(webhook, BuildBody(webhook, evt, run), cancellationToken);
+                }
+            }
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException or JsonException)
+        {
+            ConsoleLogger.Warn($"Webhook notifications skipped: {ex.Message}");
+        }
+    }
+
+    /// <summary>
+    /// The run in <paramref name="sessionDir"/>, or null when its session.json
+    /// is missing or the session never finished.
+    /// </summary>
+    internal static WebhookRun? ReadRun(string sessionDir)
+    {
+        var sessionPath = Path.Combine(sessionDir, "session.json");
+        if (!File.Exists(sessionPath) || JsonNode.Parse(File.ReadAllText(sessionPath)) is not JsonObject session)
+        {
+            return null;
+        }
+        var status = session["status"]?.GetValue<string>() ?? "";
+        if (status is "" or "running")
+        {
+            return null;
+        }
+
+        var failed = new List<string>();
+        var selfUpdates = new List<string>();
+        var refused = new List<string>();
+        var eventsPath = Path.Combine(sessionDir, "events.jsonl");
+        if (File.Exists(eventsPath))
+        {
+            foreach (var line in File.ReadLines(eventsPath).Where(l => !string.IsNullOrWhiteSpace(l)))
+            {
+                LogEvent? evt;
+                try
+                {
+                    evt = JsonSerializer.Deserialize<LogEvent>(line);
+                }
+                catch (JsonException)
+                {
+                    // A torn last line from a crashed run; skip it
+                    continue;
+                }
+                if (evt?.PackageName is not { Length: > 0 } name)
+                {
+                    continue;
+                }
+
+                var label = string.IsNullOrEmpty(evt.PackageVersion) ? name : $"{name} {evt.PackageVersion}";
+                if (evt.EventType == "install" && evt.Status == "failed")
+                {
+                    failed.Add(label);
+                }
+                else if (evt.EventType == "self_update" && evt.Status == "scheduled")
+                {
+                    selfUpdates.Add(label);
+                }
+                else if (evt.EventType == "security" && evt.Action == "self_update" && evt.Status == "blocked")
+                {
+                    refused.Add(label);
+                }
+            }
+        }
+
+        return new WebhookRun(
+            session["session_id"]?.GetValue<string>() ?? Path.GetFileName(sessionDir),
+            session["run_type"]?.GetValue<string>() ?? "",
+            status,
+            session["summary"]?.Deserialize<SessionLogSummary>() ?? new SessionLogSummary(),
+            failed.Distinct().ToList(),
+            selfUpdates.Distinct().ToList(),
+            refused.Distinct().ToList());
+    }
+
+    /// <summary>The request body for one webhook and event.</summary>
+    internal JsonObject BuildBody(WebhookSettings webhook, string evt, WebhookRun run)
+    {
+        var values = new Dictionary<string, string>
+        {
+            ["event"] = evt,
+            ["hostname"] = _hostname,
+            ["run_type"] = run.RunType,
+            ["status"] = run.Status,
+            ["session_id"] = run.SessionId,
+            ["failed_items"] = run.FailedItems.Count > 0 ? string.Join(", ", run.FailedItems) : "none",
+            ["failed_count"] = Math.Max(run.FailedItems.Count, run.Summary.Failures).ToString(),
+            ["installs"] = run.Summary.Installs.ToString(),
+            ["updates"] = run.Summary.Updates.ToString(),
+            ["removals"] = run.Summary.Removals.ToString(),
+            ["self_update"] = string.Join(", ", run.SelfUpdates),
+            ["self_update_refused"] = string.Join(", ", run.RefusedSelfUpdates)
+        };
+        var message = Render(string.IsNullOrWhiteSpace(webhook.Template) ? DefaultTemplates[evt] : webhook.Template, values);
+
+        return WebhookFormat.Normalize(webhook.Format) switch
+        {
+            WebhookFormat.Slack => new JsonObject { ["text"] = message },
+            WebhookFormat.Teams => TeamsCard($"Cimian: {evt.Replace('_', ' ')} on {_hostname}", message, values),
+            _ => new JsonObject
+            {
+                ["event"] = evt,
+                ["hostname"] = _hostname,
+                ["run_type"] = run.RunType,
+                ["status"] = run.Status,
+                ["session_id"] = run.SessionId,
+                ["message"] = message,
+                ["summary"] = new JsonObject
+                {
+                    ["installs"] = run.Summary.Installs,
+                    ["updates"] = run.Summary.Updates,
+                    ["removals"] = run.Summary.Removals,
+                    ["failures"] = run.Summary.Failures
+                },
+                ["failed_items"] = new JsonArray(run.FailedItems.Select(i => (JsonNode?)i).ToArray()),
+                ["self_updates"] = new JsonArray(run.SelfUpdates.Select(i => (JsonNode?)i).ToArray()),
+                ["refused_self_updates"] = new JsonArray(run.RefusedSelfUpdates.Select(i => (JsonNode?)i).ToArray())
+            }
+        };
+    }
+
+    /// <summary>
+    /// Replaces {name} placeholders; unknown names are left as written so a
+    /// typo shows up in the message instead of vanishing.
+    /// </summary>
+    internal static string Render(string template, IReadOnlyDictionary<string, string> values) =>
+        Placeholder.Replace(template, m => values.TryGetValue(m.Groups[1].Value, out var value) ? value : m.Value);
+
+    /// <summary>A Teams message carrying an Adaptive Card, as Workflows webhooks expect.</summary>
+    private static JsonObject TeamsCard(string title, string message, IReadOnlyDictionary<string, string> values) => new()
+    {
+        ["type"] = "message",
+        ["attachments"] = new JsonArray(new JsonObject
+        {
+            ["contentType"] = "application/vnd.microsoft.card.adaptive",
+            ["content"] = new JsonObject
+            {
+                ["$schema"] = "http://adaptivecards.io/schemas/adaptive-card.json",
+                ["type"] = "AdaptiveCard",
+                ["version"] = "1.4",
+                ["body"] = new JsonArray(
+                    new JsonObject { ["type"] = "TextBlock", ["text"] = title, ["weight"] = "Bolder", ["wrap"] = true },
+                    new JsonObject { ["type"] = "TextBlock", ["text"] = message, ["wrap"] = true },
+                    new JsonObject
+                    {
+                        ["type"] = "FactSet",
+                        ["facts"] = new JsonArray(
+                            Fact("Host", values["hostname"]),
+                            Fact("Run type", values["run_type"]),
+                            Fact("Status", values["status"]),
+                            Fact("Session", values["session_id"]))
+                    })
+            }
+        })
+    };
+
+    private static JsonObject Fact(string title, string value) => new() { ["title"] = title, ["value"] = value };
+
+    private async Task SendAsync(WebhookSettings webhook, JsonObject body, CancellationToken cancellationToken)
+    {
+        var host = Uri.TryCreate(webhook.URL, UriKind.Absolute, out var uri) ? uri.Host : webhook.URL;
+        for (var attempt = 1; attempt <= MaxAttempts; attempt++)
+        {
+            try
+            {
+                using var content = new StringContent(body.ToJsonString(), Encoding.UTF8, "application/json");
+                using var response = await _httpClient.PostAsync(webhook.URL, content, cancellationToken);
+                if (response.IsSuccessStatusCode)
+                {
+                    ConsoleLogger.Detail($"    Webhook sent to {host}");
+                    return;
+                }
+                if (!MirrorFailoverHandler.IsMirrorFailure(response.StatusCode))
+                {
+                    ConsoleLogger.Warn($"Webhook {host} answered {(int)response.StatusCode} {response.StatusCode}; not retrying");
+                    return;
+                }
+                ConsoleLogger.Detail($"    Webhook {host} attempt {attempt}/{MaxAttempts}: {(int)response.StatusCode} {response.StatusCode}");
+            }
+            catch (HttpRequestException ex)
+            {
+                ConsoleLogger.Detail($"    Webhook {host} attempt {attempt}/{MaxAttempts}: {ex.Message}");
+            }
+            catch (TaskCanceledException) when (!cancellationToken.IsCancellationRequested)
+            {
+                ConsoleLogger.Detail($"    Webhook {host} attempt {attempt}/{MaxAttempts} timed out");
+            }
+
+            if (attempt < MaxAttempts)
+            {
+                await Task.Delay(_retryDelay, cancellationToken);
+            }
+        }
+        ConsoleLogger.Warn($"Webhook {host} could not be delivered");
+    }
+
+    /// <summary>Proxy settings only: webhook hosts must not see repo credentials.</summary>
+    private static HttpClient CreateHttpClient(CimianConfig config)
+    {
+        var handler = new HttpClientHandler();
+        ProxySelector.Apply(handler, config.ProxySettings);
+        var client = new HttpClient(handler) { Timeout = TimeSpan.FromSeconds(30) };
+        client.DefaultRequestHeaders.Add("User-Agent", "Cimian-ManagedSoftwareUpdate/1.0");
+        return client;
+    }
+}
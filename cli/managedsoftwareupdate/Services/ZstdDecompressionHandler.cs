@@ -0,0 +1,36 @@
+using System.Net.Http.Headers;
+using ZstdSharp;
+
+namespace Cimian.CLI.managedsoftwareupdate.Services;
+
+/// <summary>
+/// Adds zstd to Accept-Encoding and decodes zstd-encoded responses, which
+/// HttpClientHandler's AutomaticDecompression doesn't cover. gzip, deflate and
+/// br are still decoded by the inner handler.
+/// </summary>
+internal sealed class ZstdDecompressionHandler : DelegatingHandler
+{
+    protected override async Task<HttpResponseMessage> SendAsync(HttpRequestMessage request, CancellationToken cancellationToken)
+    {
+        request.Headers.AcceptEncoding.Add(new StringWithQualityHeaderValue("zstd"));
+        var response = await base.SendAsync(request, cancellationToken).ConfigureAwait(false);
+
+        var encoding = response.Content.Headers.ContentEncoding;
+        if (!encoding.Contains("zstd", StringComparer.OrdinalIgnoreCase))
+        {
+            return response;
+        }
+
+        var encoded = await response.Content.ReadAsStreamAsync(cancellationToken).ConfigureAwait(false);
+        var decoded = new StreamContent(new DecompressionStream(encoded));
+        foreach (var header in response.Content.Headers)
+        {
+            if (header.Key is not ("Content-Encoding" or "Content-Length"))
+            {
+                decoded.Headers.TryAddWithoutValidation(header.Key, header.Value);
+            }
+        }
+        response.Content = decoded;
+        return response;
+    }
+}
@@ -1,8 +1,10 @@
 using System.CommandLine;
+using System.Text.Json;
 using Microsoft.Extensions.DependencyInjection;
 using Microsoft.Extensions.Hosting;
 using Microsoft.Extensions.Logging;
 using Cimian.CLI.Repoclean.Services;
+using Cimian.Core.Services;
 
 namespace Cimian.CLI.Repoclean;
 
@@ -38,6 +40,14 @@ public class Program
             aliases: ["--remove", "--delete"],
             description: "Actually perform deletions (default is dry-run)");
 
+        var usageReportsOption = new Option<string?>(
+            aliases: ["--usage-reports"],
+            description: "Summarize collected usage.json reports under this directory instead of cleaning: which item versions are deployed on which machines");
+
+        var jsonOption = new Option<bool>(
+            aliases: ["--json"],
+            description: "Print the --usage-reports summary as JSON");
+
         var versionOption = new Option<bool>(
             aliases: ["-V"],
             description: "Print version and exit");
@@ -47,6 +57,8 @@ public class Program
         rootCommand.AddOption(showAllOption);
         rootCommand.AddOption(autoOption);
         rootCommand.AddOption(removeOption);
+        rootCommand.AddOption(usageReportsOption);
+        rootCommand.AddOption(jsonOption);
         rootCommand.AddOption(versionOption);
 
         rootCommand.SetHandler(async (context) =>
@@ -56,6 +68,8 @@ public class Program
             var showAll = context.ParseResult.GetValueForOption(showAllOption);
             var auto = context.ParseResult.GetValueForOption(autoOption);
             var remove = context.ParseResult.GetValueForOption(removeOption);
+            var usageReports = context.ParseResult.GetValueForOption(usageReportsOption);
+            var json = context.ParseResult.GetValueForOption(jsonOption);
             var showVersion = context.ParseResult.GetValueForOption(versionOption);
 
             if (showVersion)
@@ -65,12 +79,63 @@ public class Program
                 return;
             }
 
+            if (!string.IsNullOrEmpty(usageReports))
+            {
+                context.ExitCode = RunUsageSummary(usageReports, json);
+                return;
+            }
+
             context.ExitCode = await RunCleanAsync(repoUrl, keep, showAll, auto, remove);
         });
 
         return await rootCommand.InvokeAsync(args);
     }
 
+    /// <summary>
+    /// Rolls up the usage.json reports clients write (collected into one directory
+    /// by the reporting pipeline) to show what is actually deployed where, e.g. for
+    /// license true-ups or before removing an old version.
+    /// </summary>
+    private static int RunUsageSummary(string directory, bool json)
+    {
+        if (!Directory.Exists(directory))
+        {
+            Console.Error.WriteLine($"Error: {directory} does not exist");
+            return 1;
+        }
+
+        var summary = UsageReports.Aggregate(UsageReports.Load(directory));
+        if (json)
+        {
+            Console.WriteLine(JsonSerializer.Serialize(summary, new JsonSerializerOptions { WriteIndented = true }));
+            return 0;
+        }
+
+        Console.WriteLine("Usage Summary");
+        Console.WriteLine("=============");
+        Console.WriteLine($"Machines reporting: {summary.Machines}");
+        Console.WriteLine($"Cache hit rate: {summary.CacheHitRate:P1}");
+        Console.WriteLine();
+        Console.WriteLine($"{"Item",-40} {"Version",-20} {"Machines",8}");
+        foreach (var item in summary.Items)
+        {
+            foreach (var (version, machines) in item.Versions)
+            {
+                Console.WriteLine($"{item.Name,-40} {version,-20} {machines.Count,8}");
+            }
+        }
+        if (summary.Sources.Count > 0)
+        {
+            Console.WriteLine();
+            Console.WriteLine($"{"Download source",-40} {"Downloads",10} {"Failures",9} {"MB",10} {"Avg ms",8}");
+            foreach (var source in summary.Sources)
+            {
+                Console.WriteLine($"{source.Source,-40} {source.Downloads,10} {source.Failures,9} {source.Bytes / (1024 * 1024),10} {source.AverageDurationMs,8}");
+            }
+        }
+        return 0;
+    }
+
     private static async Task<int> RunCleanAsync(
         string? repoUrl,
         int keep,
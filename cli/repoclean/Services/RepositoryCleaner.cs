@@ -2,10 +2,10 @@ using System;
 using System.Collections.Generic;
 using System.IO;
 using System.Linq;
-using System.Text.RegularExpressions;
 using System.Threading.Tasks;
 using Microsoft.Extensions.Logging;
 using Newtonsoft.Json;
+using Cimian.Core.Version;
 
 namespace Cimian.CLI.Repoclean.Services;
 
@@ -130,7 +130,7 @@ public class RepositoryCleaner : IRepositoryCleaner
             }
 
             var index = 0;
-            foreach (var versionKvp in versions.OrderByDescending(x => new Version(NormalizeVersion(x.Key))))
+            foreach (var versionKvp in versions.OrderByDescending(x => x.Key, VersionService.Comparer))
             {
                 var version = versionKvp.Key;
                 var itemList = versionKvp.Value;
@@ -207,36 +207,6 @@ public class RepositoryCleaner : IRepositoryCleaner
         return (itemsToDelete, packagesToKeep);
     }
 
-    private string NormalizeVersion(string version)
-    {
-        // Simple version normalization - replace non-numeric characters with dots
-        var normalized = Regex.Replace(version, @"[^\d\.]", ".");
-        var parts = normalized.Split('.', StringSplitOptions.RemoveEmptyEntries);
-        
-        // Ensure we have at least 4 parts for Version constructor
-        while (parts.Length < 4)
-        {
-            var list = parts.ToList();
-            list.Add("0");
-            parts = list.ToArray();
-        }
-
-        // Take only first 4 parts for Version constructor
-        if (parts.Length > 4)
-        {
-            parts = parts.Take(4).ToArray();
-        }
-
-        try
-        {
-            return string.Join(".", parts);
-        }
-        catch
-        {
-            return "0.0.0.0";
-        }
-    }
-
     private void DisplayStatistics(
         List<PackageInfo> itemsToDelete,
         List<string> orphanedPackages,
@@ -1,4 +1,5 @@
 using System;
+using Newtonsoft.Json;
 
 namespace Cimian.Status.Models
 {
@@ -14,6 +15,26 @@ namespace Cimian.Status.Models
         public bool IsError { get; set; }
     }
 
+    /// <summary>
+    /// Per-item lifecycle stage from managedsoftwareupdate (downloading,
+    /// installing, removing, installed, failed, ...).
+    /// </summary>
+    public class ItemStatusEventArgs : EventArgs
+    {
+        public string Item { get; set; } = string.Empty;
+        public string Stage { get; set; } = string.Empty;
+    }
+
+    /// <summary>
+    /// Release notes of an item managedsoftwareupdate just installed.
+    /// </summary>
+    public class ReleaseNotesEventArgs : EventArgs
+    {
+        public string Item { get; set; } = string.Empty;
+        public string Version { get; set; } = string.Empty;
+        public string Notes { get; set; } = string.Empty;
+    }
+
     public class UpdateCompletedEventArgs : EventArgs
     {
         public bool Success { get; set; }
@@ -25,7 +46,23 @@ namespace Cimian.Status.Models
     {
         public string Type { get; set; } = string.Empty;
         public string Data { get; set; } = string.Empty;
+        public string? Message { get; set; }
+        public string? Item { get; set; }
         public int Percent { get; set; }
         public bool Error { get; set; }
+
+        // itemProgress only: download bytes so far, of total, and the estimate left
+        [JsonProperty("bytes")]
+        public long? Bytes { get; set; }
+
+        [JsonProperty("total_bytes")]
+        public long? TotalBytes { get; set; }
+
+        [JsonProperty("eta_seconds")]
+        public int? EtaSeconds { get; set; }
+
+        // logLine only: the run.log level of the streamed line
+        [JsonProperty("level")]
+        public string? Level { get; set; }
     }
 }
@@ -1,9 +1,11 @@
 using System;
+using System.Linq;
 using System.Threading;
 using System.Windows;
 using Microsoft.Extensions.DependencyInjection;
 using Microsoft.Extensions.Hosting;
 using Microsoft.Extensions.Logging;
+using Cimian.Core.Services;
 using Cimian.Status.Services;
 using Cimian.Status.ViewModels;
 using Cimian.Status.Views;
@@ -21,11 +23,31 @@ namespace Cimian.Status
             bool isBackgroundMode = Environment.UserName == "SYSTEM" || 
                                   string.IsNullOrEmpty(Environment.GetEnvironmentVariable("USERPROFILE"));
 
-            if (isBackgroundMode)
+            if (args.Contains(BootstrapScreen.Argument, StringComparer.OrdinalIgnoreCase))
+            {
+                // Setup screen during a bootstrap; on the logon screen this runs as SYSTEM
+                Environment.ExitCode = RunBootstrapScreen(onLogonScreen: isBackgroundMode);
+            }
+            else if (isBackgroundMode)
             {
                 // Run as a background service without UI
                 RunBackgroundService(args);
             }
+            else if (args.Contains("--notify", StringComparer.OrdinalIgnoreCase))
+            {
+                // Scheduled toast pass for the logged-in user; no window
+                CreateToastNotifier().ShowPending();
+            }
+            else if (args.Contains("--tray", StringComparer.OrdinalIgnoreCase))
+            {
+                // Notification-area icon for the logged-in user, started at logon
+                RunTray();
+            }
+            else if (args.FirstOrDefault(a => a.StartsWith(ToastNotifier.ProtocolScheme + ":", StringComparison.OrdinalIgnoreCase)) is { } link
+                && CreateToastNotifier().HandleActivationAsync(link).GetAwaiter().GetResult())
+            {
+                // A toast's Defer button; anything else opens the window below
+            }
             else
             {
                 // Check for single instance (only for UI mode)
@@ -110,6 +132,68 @@ namespace Cimian.Status
             host.Run();
         }
 
+        private static void RunTray()
+        {
+            if (!TrayAgent.IsEnabled())
+            {
+                return;
+            }
+
+            // One icon per session, however many times the Run key fires
+            using var mutex = new Mutex(true, "CimianStatusTray", out bool isNewInstance);
+            if (!isNewInstance)
+            {
+                return;
+            }
+
+            var loggerFactory = LoggerFactory.Create(logging =>
+            {
+                logging.AddEventLog();
+                logging.SetMinimumLevel(LogLevel.Information);
+            });
+
+            System.Windows.Forms.Application.EnableVisualStyles();
+            using var agent = new TrayAgent(loggerFactory.CreateLogger<TrayAgent>());
+            System.Windows.Forms.Application.Run();
+        }
+
+        private static int RunBootstrapScreen(bool onLogonScreen)
+        {
+            // One screen per session, however often CimianWatcher asks
+            using var mutex = new Mutex(true, "CimianStatusBootstrapScreen", out bool isNewInstance);
+            if (!isNewInstance)
+            {
+                return BootstrapScreenExitCode.AlreadyShown;
+            }
+
+            var loggerFactory = LoggerFactory.Create(logging =>
+            {
+                logging.AddEventLog();
+                logging.SetMinimumLevel(LogLevel.Information);
+            });
+
+            var app = new App();
+            app.InitializeComponent();
+
+            var window = new BootstrapWindow(
+                new StatusServer(loggerFactory.CreateLogger<StatusServer>()),
+                BootstrapScreenConfig.Load(),
+                onLogonScreen,
+                loggerFactory.CreateLogger<BootstrapWindow>());
+            app.Run(window);
+            return window.ExitCode;
+        }
+
+        private static ToastNotifier CreateToastNotifier()
+        {
+            var loggerFactory = LoggerFactory.Create(logging =>
+            {
+                logging.AddEventLog();
+                logging.SetMinimumLevel(LogLevel.Information);
+            });
+            return new ToastNotifier(loggerFactory.CreateLogger<ToastNotifier>());
+        }
+
         private static void BringExistingInstanceToFront()
         {
             // Try to find and activate the existing CimianStatus window
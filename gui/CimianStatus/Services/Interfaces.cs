@@ -1,6 +1,7 @@
 using System;
 using System.Diagnostics;
 using System.Threading.Tasks;
+using Cimian.Core.Services;
 using Cimian.Status.Models;
 
 namespace Cimian.Status.Services
@@ -9,6 +10,8 @@ namespace Cimian.Status.Services
     {
         event EventHandler<ProgressEventArgs>? ProgressChanged;
         event EventHandler<StatusEventArgs>? StatusChanged;
+        event EventHandler<ItemStatusEventArgs>? ItemStatusChanged;
+        event EventHandler<ReleaseNotesEventArgs>? ReleaseNotesReceived;
         event EventHandler<UpdateCompletedEventArgs>? Completed;
 
         Task MonitorExistingProcessesAsync();
@@ -20,6 +23,9 @@ namespace Cimian.Status.Services
     {
         string GetLastRunTime();
         void SaveLastRunTime();
+        LastRunStatus? GetLastRunStatus();
+        RestartState? GetRestartState();
+        MaintenanceHold? GetMaintenanceHold();
         void OpenLogsDirectory();
         string GetLatestLogDirectory();
         
@@ -4,6 +4,7 @@ using System.IO;
 using System.Linq;
 using System.Threading;
 using System.Threading.Tasks;
+using Cimian.Core.Services;
 using Microsoft.Extensions.Logging;
 using System.Text;
 
@@ -87,6 +88,26 @@ namespace Cimian.Status.Services
             }
         }
 
+        public LastRunStatus? GetLastRunStatus()
+        {
+            var status = LastRunStatusStore.Read();
+            if (status == null)
+            {
+                _logger.LogDebug("No last-run status available");
+            }
+            return status;
+        }
+
+        public RestartState? GetRestartState()
+        {
+            return RestartStateStore.Read();
+        }
+
+        public MaintenanceHold? GetMaintenanceHold()
+        {
+            return MaintenanceHoldStore.Read(DateTime.Now);
+        }
+
         public void OpenLogsDirectory()
         {
             try
@@ -159,9 +159,8 @@ namespace Cimian.Status.Services
             {
                 diagnostics.AppendLine();
                 diagnostics.AppendLine("✓ Service appears to be running normally.");
-                diagnostics.AppendLine("If updates aren't working, check the bootstrap trigger files:");
-                diagnostics.AppendLine("  - C:\\ProgramData\\ManagedInstalls\\.cimian.bootstrap");
-                diagnostics.AppendLine("  - C:\\ProgramData\\ManagedInstalls\\.cimian.headless");
+                diagnostics.AppendLine("If updates aren't working, check the run broker's audit log:");
+                diagnostics.AppendLine("  - C:\\ProgramData\\ManagedInstalls\\logs\\run_broker.log");
             }
 
             // Check current user context
@@ -0,0 +1,282 @@
+using System;
+using System.Collections.Generic;
+using System.IO;
+using System.Linq;
+using System.Security;
+using System.Text;
+using System.Text.Json;
+using System.Threading.Tasks;
+using Cimian.Core;
+using Cimian.Core.Models;
+using Cimian.Core.Services;
+using Microsoft.Extensions.Logging;
+using Microsoft.Win32;
+using Windows.Data.Xml.Dom;
+using Windows.UI.Notifications;
+using YamlDotNet.Serialization;
+
+namespace Cimian.Status.Services
+{
+    /// <summary>
+    /// The Config.yaml keys that decide whether and when CimianStatus raises toasts.
+    /// </summary>
+    public class ToastConfig
+    {
+        [YamlMember(Alias = "MachineRole")]
+        public string? MachineRole { get; set; }
+
+        [YamlMember(Alias = "ShowNotifications")]
+        public bool? ShowNotifications { get; set; }
+
+        [YamlMember(Alias = "RespectFocusAssist")]
+        public bool? RespectFocusAssist { get; set; }
+
+        [YamlMember(Alias = "ForcedInstallWarningHours")]
+        public int? ForcedInstallWarningHours { get; set; }
+    }
+
+    /// <summary>
+    /// Raises the logged-in user's toasts through the WinRT ToastNotificationManager
+    /// (cimistatus --notify, run by the "Cimian User Notifications" task at logon
+    /// and every 30 minutes, and by managedsoftwareupdate when an install starts
+    /// waiting on blocking applications). Which toasts to raise comes from
+    /// <see cref="UserNotices.Plan"/>; a toast already shown is remembered in
+    /// %LOCALAPPDATA%\Cimian\notices.json and not repeated.
+    ///
+    /// Buttons activate by protocol: cimian://updates opens Managed Software
+    /// Center, and cimian-status: links come back to cimistatus to open the
+    /// status window or ask the run broker to defer an item.
+    /// </summary>
+    public class ToastNotifier
+    {
+        public const string AppUserModelId = "Cimian.Status";
+        public const string ProtocolScheme = "cimian-status";
+
+        private static readonly string HistoryPath = Path.Combine(
+            Environment.GetFolderPath(Environment.SpecialFolder.LocalApplicationData), "Cimian", "notices.json");
+
+        private readonly ILogger<ToastNotifier> _logger;
+
+        public ToastNotifier(ILogger<ToastNotifier> logger)
+        {
+            _logger = logger ?? throw new ArgumentNullException(nameof(logger));
+        }
+
+        /// <summary>
+        /// Shows every notice the user hasn't seen yet. Notices raised while the
+        /// user is in Focus Assist aren't marked seen, so the next pass retries them.
+        /// </summary>
+        public void ShowPending()
+        {
+            var config = LoadConfig();
+            if (!MachineRole.ResolveBool(config.MachineRole, "ShowNotifications", config.ShowNotifications, true))
+            {
+                _logger.LogDebug("Toasts disabled by ShowNotifications / MachineRole");
+                return;
+            }
+
+            var notices = UserNotices.Plan(
+                LastRunStatusStore.Read(),
+                UserNotices.ReadPendingItems(),
+                RestartStateStore.Read(),
+                DateTime.Now,
+                config.ForcedInstallWarningHours ?? UserNotices.DefaultForcedInstallWarningHours,
+                BlockingAppsStateStore.Read(DateTime.Now));
+
+            var seen = ReadHistory();
+            var unseen = notices.Where(n => !seen.Contains(n.Key)).ToList();
+            if (unseen.Count == 0)
+            {
+                return;
+            }
+
+            var state = MachineRole.ResolveBool(config.MachineRole, "RespectFocusAssist", config.RespectFocusAssist, true)
+                ? FocusAssist.GetState()
+                : FocusState.Available;
+            if (state != FocusState.Available)
+            {
+                _logger.LogInformation("Holding {Count} toast(s): {Reason}", unseen.Count, FocusAssist.Describe(state));
+                return;
+            }
+
+            RegisterApp();
+            var notifier = ToastNotificationManager.CreateToastNotifier(AppUserModelId);
+            foreach (var notice in unseen)
+            {
+                try
+                {
+                    notifier.Show(new ToastNotification(BuildToast(notice)) { Tag = notice.Kind });
+                    _logger.LogInformation("Showed {Kind} toast: {Message}", notice.Kind, notice.Message);
+                }
+                catch (Exception ex)
+                {
+                    _logger.LogError(ex, "Failed to show {Kind} toast", notice.Kind);
+                }
+            }
+
+            // Only what's still current is kept, so a notice that comes back later shows again
+            WriteHistory(notices.Select(n => n.Key));
+        }
+
+        /// <summary>
+        /// Handles a cimian-status: link from a toast button. Returns true when
+        /// the link was a deferral (handled here), false to open the status window.
+        /// </summary>
+        public async Task<bool> HandleActivationAsync(string uri)
+        {
+            if (!Uri.TryCreate(uri, UriKind.Absolute, out var parsed)
+                || !parsed.Scheme.Equals(ProtocolScheme, StringComparison.OrdinalIgnoreCase)
+                || !parsed.AbsolutePath.Trim('/').Equals("defer", StringComparison.OrdinalIgnoreCase))
+            {
+                return false;
+            }
+
+            var item = ParseQuery(parsed.Query).GetValueOrDefault("item");
+            if (string.IsNullOrWhiteSpace(item))
+            {
+                return true;
+            }
+
+            var response = await RunBrokerClient.SendAsync(
+                new RunBrokerRequest { Mode = RunBrokerProtocol.DeferMode, Items = [item] });
+            var message = response?.Accepted == true
+                ? $"{item} won't be installed at the next automatic update."
+                : $"{item} could not be postponed: {response?.Message ?? "Cimian service is not running"}";
+            _logger.LogInformation("Deferral of {Item}: {Message}", item, message);
+
+            try
+            {
+                RegisterApp();
+                ToastNotificationManager.CreateToastNotifier(AppUserModelId)
+                    .Show(new ToastNotification(BuildToast(new UserNotice("deferral", "", "Cimian", message))));
+            }
+            catch (Exception ex)
+            {
+                _logger.LogError(ex, "Failed to confirm deferral");
+            }
+            return true;
+        }
+
+        private static XmlDocument BuildToast(UserNotice notice)
+        {
+            var launch = notice.Kind == UserNoticeKind.UpdatesPending ? "cimian://updates" : $"{ProtocolScheme}:show";
+            var actions = new StringBuilder();
+            switch (notice.Kind)
+            {
+                case UserNoticeKind.UpdatesPending:
+                    actions.Append(Action("View updates", "cimian://updates"));
+                    break;
+                case UserNoticeKind.ForcedInstall:
+                    actions.Append(Action("Open status", $"{ProtocolScheme}:show"));
+                    if (notice.CanDefer && notice.Item != null)
+                    {
+                        actions.Append(Action("Defer", $"{ProtocolScheme}:defer?item={Uri.EscapeDataString(notice.Item)}"));
+                    }
+                    break;
+                case UserNoticeKind.RestartRequired:
+                case UserNoticeKind.CloseApps:
+                    actions.Append(Action("Open status", $"{ProtocolScheme}:show"));
+                    break;
+            }
+
+            var xml = new XmlDocument();
+            xml.LoadXml(
+                $"<toast activationType=\"protocol\" launch=\"{Escape(launch)}\"" +
+                (notice.Kind is UserNoticeKind.ForcedInstall or UserNoticeKind.CloseApps ? " scenario=\"reminder\">" : ">") +
+                "<visual><binding template=\"ToastGeneric\">" +
+                $"<text>{Escape(notice.Title)}</text><text>{Escape(notice.Message)}</text>" +
+                "</binding></visual>" +
+                (actions.Length > 0 ? $"<actions>{actions}</actions>" : "") +
+                "</toast>");
+            return xml;
+        }
+
+        private static string Action(string content, string arguments) =>
+            $"<action content=\"{Escape(content)}\" activationType=\"protocol\" arguments=\"{Escape(arguments)}\"/>";
+
+        private static string Escape(string text) => SecurityElement.Escape(text) ?? "";
+
+        private static Dictionary<string, string> ParseQuery(string query) =>
+            query.TrimStart('?')
+                .Split('&', StringSplitOptions.RemoveEmptyEntries)
+                .Select(pair => pair.Split('=', 2))
+                .Where(parts => parts.Length == 2)
+                .GroupBy(parts => parts[0], StringComparer.OrdinalIgnoreCase)
+                .ToDictionary(g => g.Key, g => Uri.UnescapeDataString(g.First()[1]), StringComparer.OrdinalIgnoreCase);
+
+        /// <summary>
+        /// An unpackaged app needs its AppUserModelID registered before Windows
+        /// shows its toasts, and the cimian-status: scheme for the buttons to
+        /// come back here. Both live under HKCU, so no elevation is needed.
+        /// </summary>
+        private void RegisterApp()
+        {
+            try
+            {
+                var exe = Environment.ProcessPath ?? CimianPaths.CimiStatusExe;
+                using (var key = Registry.CurrentUser.CreateSubKey($@"Software\Classes\AppUserModelId\{AppUserModelId}"))
+                {
+                    key.SetValue("DisplayName", "Cimian");
+                    key.SetValue("IconUri", exe);
+                }
+                using (var key = Registry.CurrentUser.CreateSubKey($@"Software\Classes\{ProtocolScheme}"))
+                {
+                    key.SetValue("", "URL:Cimian Status");
+                    key.SetValue("URL Protocol", "");
+                    using var command = key.CreateSubKey(@"shell\open\command");
+                    command.SetValue("", $"\"{exe}\" \"%1\"");
+                }
+            }
+            catch (Exception ex) when (ex is UnauthorizedAccessException or SecurityException or IOException)
+            {
+                _logger.LogWarning("Could not register toast activation: {Message}", ex.Message);
+            }
+        }
+
+        private static HashSet<string> ReadHistory()
+        {
+            try
+            {
+                if (File.Exists(HistoryPath))
+                {
+                    return JsonSerializer.Deserialize<HashSet<string>>(File.ReadAllText(HistoryPath)) ?? [];
+                }
+            }
+            catch (Exception ex) when (ex is IOException or JsonException or UnauthorizedAccessException)
+            {
+                // Start over; at worst a toast repeats once
+            }
+            return [];
+        }
+
+        private void WriteHistory(IEnumerable<string> keys)
+        {
+            try
+            {
+                Directory.CreateDirectory(Path.GetDirectoryName(HistoryPath)!);
+                File.WriteAllText(HistoryPath, JsonSerializer.Serialize(keys.ToList()));
+            }
+            catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+            {
+                _logger.LogWarning("Could not save toast history: {Message}", ex.Message);
+            }
+        }
+
+        private ToastConfig LoadConfig()
+        {
+            try
+            {
+                if (File.Exists(CimianPaths.ConfigYaml))
+                {
+                    return YamlUtils.Deserializer.Deserialize<ToastConfig>(File.ReadAllText(CimianPaths.ConfigYaml))
+                        ?? new ToastConfig();
+                }
+            }
+            catch (Exception ex)
+            {
+                _logger.LogDebug("Could not read {Path}: {Message}", CimianPaths.ConfigYaml, ex.Message);
+            }
+            return new ToastConfig();
+        }
+    }
+}
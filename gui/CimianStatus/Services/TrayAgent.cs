@@ -0,0 +1,245 @@
+using System;
+using System.Diagnostics;
+using System.Drawing;
+using System.Drawing.Drawing2D;
+using System.Drawing.Text;
+using System.IO;
+using System.Linq;
+using System.Runtime.InteropServices;
+using System.Threading.Tasks;
+using System.Windows.Forms;
+using Cimian.Core;
+using Cimian.Core.Models;
+using Cimian.Core.Services;
+using Microsoft.Extensions.Logging;
+using YamlDotNet.Serialization;
+
+namespace Cimian.Status.Services
+{
+    /// <summary>
+    /// The Config.yaml keys the tray agent reads.
+    /// </summary>
+    public class TrayConfig
+    {
+        [YamlMember(Alias = "MachineRole")]
+        public string? MachineRole { get; set; }
+
+        [YamlMember(Alias = "ShowTrayIcon")]
+        public bool? ShowTrayIcon { get; set; }
+    }
+
+    /// <summary>
+    /// The notification-area icon (cimistatus --tray, started at logon from the
+    /// HKLM Run key the installer writes). Its badge and tooltip come from
+    /// <see cref="TrayStatus.Evaluate"/> over the files the last run left behind,
+    /// re-read every <see cref="RefreshInterval"/>; its menu asks the run broker
+    /// for a check, so no elevation is involved.
+    /// </summary>
+    public sealed class TrayAgent : IDisposable
+    {
+        public const string SelfServiceUri = "cimian://software";
+
+        private static readonly TimeSpan RefreshInterval = TimeSpan.FromSeconds(30);
+
+        private readonly ILogger<TrayAgent> _logger;
+        private readonly Icon _baseIcon;
+        private readonly NotifyIcon _notifyIcon;
+        private readonly System.Windows.Forms.Timer _timer;
+        private Icon? _badgeIcon;
+        private TrayStatus? _status;
+
+        public TrayAgent(ILogger<TrayAgent> logger)
+        {
+            _logger = logger ?? throw new ArgumentNullException(nameof(logger));
+            _baseIcon = Icon.ExtractAssociatedIcon(Environment.ProcessPath ?? CimianPaths.CimiStatusExe) ?? SystemIcons.Application;
+
+            var menu = new ContextMenuStrip();
+            menu.Items.Add("Check now", null, async (_, _) => await CheckNowAsync());
+            menu.Items.Add("View logs", null, (_, _) => OpenLogs());
+            menu.Items.Add("Open self-service", null, (_, _) => Launch(SelfServiceUri, useShell: true));
+            menu.Items.Add(new ToolStripSeparator());
+            menu.Items.Add("Open status", null, (_, _) => OpenStatusWindow());
+
+            _notifyIcon = new NotifyIcon
+            {
+                Icon = _baseIcon,
+                Text = "Cimian",
+                ContextMenuStrip = menu,
+                Visible = true
+            };
+            _notifyIcon.DoubleClick += (_, _) => OpenStatusWindow();
+
+            _timer = new System.Windows.Forms.Timer { Interval = (int)RefreshInterval.TotalMilliseconds };
+            _timer.Tick += (_, _) => Refresh();
+            _timer.Start();
+
+            Refresh();
+        }
+
+        /// <summary>
+        /// Whether this machine shows the tray icon: ShowTrayIcon, else the
+        /// MachineRole default (off for kiosk and server), else on.
+        /// </summary>
+        public static bool IsEnabled()
+        {
+            var config = new TrayConfig();
+            try
+            {
+                if (File.Exists(CimianPaths.ConfigYaml))
+                {
+                    config = YamlUtils.Deserializer.Deserialize<TrayConfig>(File.ReadAllText(CimianPaths.ConfigYaml))
+                        ?? config;
+                }
+            }
+            catch (Exception)
+            {
+                // An unreadable config shouldn't hide the icon
+            }
+            return MachineRole.ResolveBool(config.MachineRole, "ShowTrayIcon", config.ShowTrayIcon, true);
+        }
+
+        public void Refresh()
+        {
+            var status = TrayStatus.Evaluate(
+                LastRunStatusStore.Read(),
+                UserNotices.ReadPendingItems(),
+                RestartStateStore.Read(),
+                IsUpdateRunning(),
+                DateTime.Now,
+                MaintenanceHoldStore.Read(DateTime.Now));
+            if (status == _status)
+            {
+                return;
+            }
+
+            _status = status;
+            _notifyIcon.Text = status.ToolTip;
+
+            var previous = _badgeIcon;
+            _badgeIcon = DrawBadge(status);
+            _notifyIcon.Icon = _badgeIcon ?? _baseIcon;
+            previous?.Dispose();
+        }
+
+        private async Task CheckNowAsync()
+        {
+            var response = await RunBrokerClient.SendAsync(new RunBrokerRequest { Mode = "checkonly" });
+            if (response?.Accepted == true)
+            {
+                _logger.LogInformation("Check requested from the tray (pid {Pid})", response.ProcessId);
+                Refresh();
+                return;
+            }
+
+            var message = response?.Message ?? "Cimian service is not running";
+            _logger.LogWarning("Tray check refused: {Message}", message);
+            _notifyIcon.ShowBalloonTip(5000, "Cimian", $"Could not check for updates: {message}", ToolTipIcon.Warning);
+        }
+
+        private void OpenLogs()
+        {
+            // Most recent session folder, else the logs root
+            var latest = Directory.Exists(CimianPaths.LogsDir)
+                ? Directory.GetDirectories(CimianPaths.LogsDir).OrderByDescending(Path.GetFileName, StringComparer.Ordinal).FirstOrDefault()
+                : null;
+            Launch("explorer.exe", latest ?? CimianPaths.LogsDir);
+        }
+
+        private void OpenStatusWindow()
+        {
+            Launch(Environment.ProcessPath ?? CimianPaths.CimiStatusExe);
+        }
+
+        private void Launch(string fileName, string? arguments = null, bool useShell = false)
+        {
+            try
+            {
+                using var process = Process.Start(new ProcessStartInfo
+                {
+                    FileName = fileName,
+                    Arguments = arguments ?? "",
+                    UseShellExecute = useShell
+                });
+            }
+            catch (Exception ex)
+            {
+                _logger.LogError(ex, "Could not open {FileName}", fileName);
+            }
+        }
+
+        private static bool IsUpdateRunning()
+        {
+            try
+            {
+                var processes = Process.GetProcessesByName("managedsoftwareupdate");
+                foreach (var process in processes)
+                {
+                    process.Dispose();
+                }
+                return processes.Length > 0;
+            }
+            catch (InvalidOperationException)
+            {
+                return false;
+            }
+        }
+
+        /// <summary>
+        /// The Cimian icon with a corner badge: the pending count, or "!" for an
+        /// owed restart (orange) or a failed run (red). Null means no badge.
+        /// </summary>
+        private Icon? DrawBadge(TrayStatus status)
+        {
+            var (color, text) = status.Kind switch
+            {
+                TrayStatusKind.UpdatesPending => (Color.FromArgb(0, 120, 212), status.PendingCount > 9 ? "9+" : status.PendingCount.ToString()),
+                TrayStatusKind.RestartRequired => (Color.FromArgb(202, 80, 16), "!"),
+                TrayStatusKind.Error => (Color.FromArgb(196, 43, 28), "!"),
+                _ => (Color.Empty, "")
+            };
+            if (text.Length == 0)
+            {
+                return null;
+            }
+
+            var size = SystemInformation.SmallIconSize;
+            using var bitmap = new Bitmap(size.Width, size.Height);
+            using (var graphics = Graphics.FromImage(bitmap))
+            {
+                graphics.SmoothingMode = SmoothingMode.AntiAlias;
+                graphics.TextRenderingHint = TextRenderingHint.AntiAliasGridFit;
+                graphics.DrawIcon(_baseIcon, new Rectangle(Point.Empty, size));
+
+                var diameter = size.Width * 5 / 8;
+                var badge = new Rectangle(size.Width - diameter, size.Height - diameter, diameter, diameter);
+                using var brush = new SolidBrush(color);
+                using var font = new Font("Segoe UI", diameter * (text.Length > 1 ? 0.5f : 0.7f), FontStyle.Bold, GraphicsUnit.Pixel);
+                using var format = new StringFormat { Alignment = StringAlignment.Center, LineAlignment = StringAlignment.Center };
+                graphics.FillEllipse(brush, badge);
+                graphics.DrawString(text, font, Brushes.White, badge, format);
+            }
+
+            // Icon.FromHandle doesn't own the handle; keep a copy that does
+            var handle = bitmap.GetHicon();
+            try
+            {
+                return (Icon)Icon.FromHandle(handle).Clone();
+            }
+            finally
+            {
+                DestroyIcon(handle);
+            }
+        }
+
+        public void Dispose()
+        {
+            _timer.Dispose();
+            _notifyIcon.Visible = false;
+            _notifyIcon.Dispose();
+            _badgeIcon?.Dispose();
+        }
+
+        [DllImport("user32.dll")]
+        private static extern bool DestroyIcon(IntPtr handle);
+    }
+}
@@ -4,6 +4,7 @@ using System.IO;
 using System.Linq;
 using System.Threading.Tasks;
 using Microsoft.Extensions.Logging;
+using Cimian.Core.Services;
 using Cimian.Status.Models;
 
 namespace Cimian.Status.Services
@@ -15,6 +16,8 @@ namespace Cimian.Status.Services
 
         public event EventHandler<ProgressEventArgs>? ProgressChanged;
         public event EventHandler<StatusEventArgs>? StatusChanged;
+        public event EventHandler<ItemStatusEventArgs>? ItemStatusChanged;
+        public event EventHandler<ReleaseNotesEventArgs>? ReleaseNotesReceived;
         public event EventHandler<UpdateCompletedEventArgs>? Completed;
 
         private volatile bool _isExecutingUpdate = false;
@@ -47,10 +50,9 @@ namespace Cimian.Status.Services
                     Message = "Starting update..." 
                 });
 
-                // First try to trigger via CimianWatcher service (preferred method)
-                if (await TryTriggerViaBootstrapAsync())
+                // First try the CimianWatcher service's run broker (preferred method)
+                if (await TryTriggerViaBrokerAsync())
                 {
-                    _logger.LogInformation("Successfully triggered update via CimianWatcher service");
                     return;
                 }
 
@@ -77,117 +79,64 @@ namespace Cimian.Status.Services
             }
         }
 
-        private async Task<bool> TryTriggerViaBootstrapAsync()
+        /// <summary>
+        /// Asks CimianWatcher's run broker for a check-and-install run. The broker
+        /// decides whether this user may and launches managedsoftwareupdate as
+        /// SYSTEM. Returns false only when the service isn't reachable, so the
+        /// caller falls back to running it directly; a refusal is reported here.
+        /// </summary>
+        private async Task<bool> TryTriggerViaBrokerAsync()
         {
-            // Default to GUI mode when called from the UI
-            return await TryTriggerViaBootstrapAsync(true);
-        }
+            ProgressChanged?.Invoke(this, new ProgressEventArgs 
+            { 
+                Percentage = 20, 
+                Message = "Checking for system service..." 
+            });
 
-        private async Task<bool> TryTriggerViaBootstrapAsync(bool withGui)
-        {
-            try
+            var response = await RunBrokerClient.SendAsync(new RunBrokerRequest { Mode = RunBrokerProtocol.CheckMode });
+            if (response == null)
             {
+                _logger.LogWarning("CimianWatcher run broker is not reachable");
                 ProgressChanged?.Invoke(this, new ProgressEventArgs 
                 { 
-                    Percentage = 20, 
-                    Message = "Checking for system service..." 
-                });
-
-                // Choose the appropriate bootstrap flag file
-                var bootstrapFlagPath = withGui 
-                    ? @"C:\ProgramData\ManagedInstalls\.cimian.bootstrap"
-                    : @"C:\ProgramData\ManagedInstalls\.cimian.headless";
-                
-                var managedInstallsPath = Path.GetDirectoryName(bootstrapFlagPath);
-
-                // Ensure the directory exists
-                if (!string.IsNullOrEmpty(managedInstallsPath) && !Directory.Exists(managedInstallsPath))
-                {
-                    Directory.CreateDirectory(managedInstallsPath);
-                }
-
-                ProgressChanged?.Invoke(this, new ProgressEventArgs 
-                { 
-                    Percentage = 40, 
-                    Message = "Creating bootstrap trigger..." 
+                    Percentage = 30, 
+                    Message = "Service not available, trying direct approach..." 
                 });
+                return false;
+            }
 
-                // Create the bootstrap flag file
-                var timestamp = DateTime.Now.ToString("yyyy-MM-dd HH:mm:ss");
-                var triggerType = withGui ? "GUI" : "headless";
-                var content = $"Bootstrap triggered at: {timestamp}\nMode: {triggerType}\nTriggered by: CimianStatus UI";
-                
-                await File.WriteAllTextAsync(bootstrapFlagPath, content);
-                
-                _logger.LogInformation("Created {Mode} bootstrap flag file: {FilePath}", triggerType, bootstrapFlagPath);
-                
+            if (!response.Accepted)
+            {
+                _logger.LogWarning("CimianWatcher refused the update request: {Message}", response.Message);
                 StatusChanged?.Invoke(this, new StatusEventArgs 
                 { 
-                    Message = $"Triggered {triggerType} software update via system service..." 
-                });
-
-                ProgressChanged?.Invoke(this, new ProgressEventArgs 
-                { 
-                    Percentage = 60, 
-                    Message = "Waiting for service response..." 
-                });
-
-                // Wait a moment for the service to pick up the file
-                await Task.Delay(2000);
-
-                ProgressChanged?.Invoke(this, new ProgressEventArgs 
-                { 
-                    Percentage = 80, 
-                    Message = "Verifying trigger..." 
+                    Message = $"Update not started: {response.Message}", 
+                    IsError = true 
                 });
-
-                // Check if the file was processed (should be deleted by CimianWatcher)
-                var fileProcessed = !File.Exists(bootstrapFlagPath);
-                
-                if (fileProcessed)
-                {
-                    StatusChanged?.Invoke(this, new StatusEventArgs 
-                    { 
-                        Message = $"Update process initiated by system service ({triggerType} mode)" 
-                    });
-
-                    ProgressChanged?.Invoke(this, new ProgressEventArgs 
-                    { 
-                        Percentage = 100, 
-                        Message = "Update process started" 
-                    });
-                    
-                    Completed?.Invoke(this, new UpdateCompletedEventArgs 
-                    { 
-                        Success = true, 
-                        ErrorMessage = null 
-                    });
-                    
-                    return true;
-                }
-                else
-                {
-                    _logger.LogWarning("{Mode} bootstrap flag file was not processed by CimianWatcher service", triggerType);
-                    ProgressChanged?.Invoke(this, new ProgressEventArgs 
-                    { 
-                        Percentage = 50, 
-                        Message = "Service not available, trying direct approach..." 
-                    });
-                    // Clean up the file we created
-                    try { File.Delete(bootstrapFlagPath); } catch { }
-                    return false;
-                }
-            }
-            catch (Exception ex)
-            {
-                _logger.LogWarning(ex, "Failed to trigger via CimianWatcher service, falling back to direct execution");
-                ProgressChanged?.Invoke(this, new ProgressEventArgs 
+                Completed?.Invoke(this, new UpdateCompletedEventArgs 
                 { 
-                    Percentage = 30, 
-                    Message = "Service trigger failed, trying direct approach..." 
+                    Success = false, 
+                    ErrorMessage = response.Message 
                 });
-                return false;
+                return true;
             }
+
+            _logger.LogInformation("CimianWatcher started managedsoftwareupdate (pid {Pid})", response.ProcessId);
+            StatusChanged?.Invoke(this, new StatusEventArgs 
+            { 
+                Message = "Update process initiated by system service" 
+            });
+            ProgressChanged?.Invoke(this, new ProgressEventArgs 
+            { 
+                Percentage = 100, 
+                Message = "Update process started" 
+            });
+            Completed?.Invoke(this, new UpdateCompletedEventArgs 
+            { 
+                Success = true, 
+                ErrorMessage = null 
+            });
+            return true;
         }
 
         private async Task ExecuteDirectAsync()
@@ -498,6 +447,29 @@ namespace Cimian.Status.Services
                         }
                         break;
 
+                    case "itemstatus":
+                        if (!string.IsNullOrEmpty(message.Item))
+                        {
+                            ItemStatusChanged?.Invoke(this, new ItemStatusEventArgs
+                            {
+                                Item = message.Item,
+                                Stage = message.Data
+                            });
+                        }
+                        break;
+
+                    case "releasenotes":
+                        if (!string.IsNullOrEmpty(message.Item) && !string.IsNullOrWhiteSpace(message.Data))
+                        {
+                            ReleaseNotesReceived?.Invoke(this, new ReleaseNotesEventArgs
+                            {
+                                Item = message.Item,
+                                Version = message.Message ?? string.Empty,
+                                Notes = message.Data
+                            });
+                        }
+                        break;
+
                     case "quit":
                         _logger.LogInformation("Received quit message from managedsoftwareupdate");
                         _updateCompleted = true;
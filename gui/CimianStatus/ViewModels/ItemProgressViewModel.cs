@@ -0,0 +1,123 @@
+using System;
+using System.Windows.Media;
+using CommunityToolkit.Mvvm.ComponentModel;
+
+namespace Cimian.Status.ViewModels
+{
+    /// <summary>
+    /// One row of the status window's item list, driven by managedsoftwareupdate's
+    /// itemStatus (the stage) and itemProgress (bytes, ETA, install phase) messages.
+    /// </summary>
+    public partial class ItemProgressViewModel : ObservableObject
+    {
+        private static readonly Brush SuccessBrush = Frozen(Color.FromRgb(16, 124, 16));
+        private static readonly Brush FailureBrush = Frozen(Colors.Red);
+
+        public ItemProgressViewModel(string name)
+        {
+            Name = name;
+        }
+
+        public string Name { get; }
+
+        [ObservableProperty]
+        [NotifyPropertyChangedFor(nameof(StageText), nameof(IsIndeterminate), nameof(IsFinished), nameof(Icon), nameof(IconBrush))]
+        private string _stage = "pending";
+
+        [ObservableProperty]
+        [NotifyPropertyChangedFor(nameof(StageText))]
+        private int _percent;
+
+        [ObservableProperty]
+        [NotifyPropertyChangedFor(nameof(HasDetail))]
+        private string _detail = "";
+
+        public bool HasDetail => !string.IsNullOrEmpty(Detail);
+
+        public string StageText => Stage switch
+        {
+            "pending" => "Waiting",
+            "downloading" => Percent > 0 ? $"Downloading {Percent}%" : "Downloading",
+            "downloaded" => "Downloaded",
+            "installing" => "Installing",
+            "installed" => "Installed",
+            "removing" => "Removing",
+            "removed" => "Removed",
+            "failed" => "Failed",
+            _ => Stage
+        };
+
+        // Installers and removals report no percent, only that they're running
+        public bool IsIndeterminate => Stage is "installing" or "removing";
+
+        public bool IsFinished => Stage is "installed" or "removed" or "failed";
+
+        public string Icon => Stage switch
+        {
+            "installed" or "removed" => "✓",
+            "failed" => "✗",
+            _ => ""
+        };
+
+        public Brush IconBrush => Stage == "failed" ? FailureBrush : SuccessBrush;
+
+        /// <summary>
+        /// A new lifecycle stage. A repeated downloading/installing keeps the
+        /// progress and detail the itemProgress messages already filled in.
+        /// </summary>
+        public void ApplyStatus(string stage, string? detail)
+        {
+            if (stage == Stage && stage is "downloading" or "installing" or "removing")
+            {
+                return;
+            }
+
+            Stage = stage;
+            Percent = stage is "downloaded" or "installed" or "removed" ? 100 : 0;
+            Detail = stage == "failed" ? detail ?? "" : "";
+        }
+
+        public void ApplyProgress(string stage, int percent, long? bytes, long? totalBytes, int? etaSeconds, string? detail)
+        {
+            if (IsFinished)
+            {
+                return;
+            }
+
+            Stage = stage;
+            Percent = Math.Clamp(percent, 0, 100);
+            Detail = stage == "downloading" ? DescribeDownload(bytes, totalBytes, etaSeconds) : detail ?? "";
+        }
+
+        private static string DescribeDownload(long? bytes, long? totalBytes, int? etaSeconds)
+        {
+            var size = bytes != null && totalBytes != null
+                ? $"{FormatBytes(bytes.Value)} of {FormatBytes(totalBytes.Value)}"
+                : "";
+            var eta = etaSeconds switch
+            {
+                null => "",
+                < 60 => "less than a minute left",
+                < 3600 => $"about {(int)Math.Ceiling(etaSeconds.Value / 60.0)} min left",
+                _ => $"about {etaSeconds.Value / 3600} h {etaSeconds.Value % 3600 / 60} min left"
+            };
+            return size.Length > 0 && eta.Length > 0 ? $"{size} - {eta}" : size + eta;
+        }
+
+        private static string FormatBytes(long bytes)
+        {
+            if (bytes < 1024 * 1024)
+                return $"{bytes / 1024.0:F0} KB";
+            if (bytes < 1024L * 1024 * 1024)
+                return $"{bytes / (1024.0 * 1024):F1} MB";
+            return $"{bytes / (1024.0 * 1024 * 1024):F2} GB";
+        }
+
+        private static Brush Frozen(Color color)
+        {
+            var brush = new SolidColorBrush(color);
+            brush.Freeze();
+            return brush;
+        }
+    }
+}
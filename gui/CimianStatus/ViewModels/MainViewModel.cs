@@ -1,11 +1,17 @@
 using System;
+using System.Collections.Generic;
 using System.Collections.ObjectModel;
 using System.ComponentModel;
+using System.IO;
 using System.Linq;
 using System.Threading.Tasks;
 using System.Windows.Media;
+using System.Windows.Media.Imaging;
+using System.Windows.Threading;
 using CommunityToolkit.Mvvm.ComponentModel;
 using CommunityToolkit.Mvvm.Input;
+using Cimian.Core;
+using Cimian.Core.Services;
 using Cimian.Status.Models;
 using Cimian.Status.Services;
 
@@ -65,6 +71,52 @@ namespace Cimian.Status.ViewModels
         [ObservableProperty]
         private bool _isLogTailing = false;
 
+        // Restart owed by the last run (restart.json)
+        [ObservableProperty]
+        private bool _hasPendingRestart = false;
+
+        [ObservableProperty]
+        private string _restartText = "";
+
+        private readonly DispatcherTimer _restartTimer;
+        private RestartState? _restartState;
+
+        // Maintenance hold (maintenance_hold.json): automatic runs paused
+        [ObservableProperty]
+        [NotifyPropertyChangedFor(nameof(HasMaintenanceHold))]
+        private string _maintenanceHoldText = "";
+
+        public bool HasMaintenanceHold => !string.IsNullOrEmpty(MaintenanceHoldText);
+
+        // Header icon: the item being downloaded/installed/removed, else the Cimian logo
+        private static readonly ImageSource DefaultHeaderIcon =
+            new BitmapImage(new Uri("pack://application:,,,/Assets/cimian.png"));
+
+        [ObservableProperty]
+        private ImageSource _headerIcon = DefaultHeaderIcon;
+
+        // icon_name per item from InstallInfo.yaml, read once per run
+        private Dictionary<string, string?>? _iconNames;
+
+        // "What's new" for items installed this run (or the last one) that publish release_notes
+        [ObservableProperty]
+        [NotifyPropertyChangedFor(nameof(HasWhatsNew))]
+        private string _whatsNewText = "";
+
+        public bool HasWhatsNew => !string.IsNullOrEmpty(WhatsNewText);
+
+        // One row per item the current run is downloading, installing or removing
+        public ObservableCollection<ItemProgressViewModel> Items { get; } = new();
+
+        public bool HasItems => Items.Count > 0;
+
+        // Set by quit, so the next run's first message starts a fresh list
+        private bool _itemsComplete;
+
+        // Set once the run streams its log (StatusLogStreaming); tailed file
+        // lines are dropped from then on so the pane doesn't show both
+        private bool _logStreaming;
+
         public MainViewModel(IUpdateService updateService, ILogService logService)
         {
             _updateService = updateService ?? throw new ArgumentNullException(nameof(updateService));
@@ -73,12 +125,22 @@ namespace Cimian.Status.ViewModels
             // Subscribe to update service events
             _updateService.ProgressChanged += OnProgressChanged;
             _updateService.StatusChanged += OnStatusChanged;
+            _updateService.ItemStatusChanged += OnItemStatusChanged;
+            _updateService.ReleaseNotesReceived += OnReleaseNotesReceived;
             _updateService.Completed += OnUpdateCompleted;
 
             // Subscribe to log service events
             _logService.LogLineReceived += OnLogLineReceived;
 
+            Items.CollectionChanged += (_, _) => OnPropertyChanged(nameof(HasItems));
+
+            _restartTimer = new DispatcherTimer { Interval = TimeSpan.FromSeconds(1) };
+            _restartTimer.Tick += (_, _) => RefreshRestartText();
+
             LoadLastRunTime();
+            LoadLastRunStatus();
+            LoadRestartState();
+            LoadMaintenanceHold();
         }
 
         public bool CanRunNow => !IsRunning;
@@ -109,6 +171,8 @@ namespace Cimian.Status.ViewModels
                 IsRunning = true;
                 RunButtonText = "Running...";
                 HasError = false;
+                WhatsNewText = "";
+                Items.Clear();
                 ShowProgress = true;
                 IsIndeterminate = true; // Start with indeterminate progress
                 ProgressValue = 0;
@@ -138,6 +202,37 @@ namespace Cimian.Status.ViewModels
             }
         }
 
+        [RelayCommand]
+        public void RestartNow()
+        {
+            try
+            {
+                // A countdown already scheduled by shutdown.exe has to be cancelled first
+                if (_restartState?.ScheduledFor != null)
+                {
+                    RunShutdown("/a");
+                }
+                RunShutdown("/r /t 0 /c \"Restarting to finish installing updates\"");
+            }
+            catch (Exception ex)
+            {
+                HasError = true;
+                RestartText = $"Could not restart: {ex.Message}";
+            }
+        }
+
+        private static void RunShutdown(string arguments)
+        {
+            using var process = System.Diagnostics.Process.Start(new System.Diagnostics.ProcessStartInfo
+            {
+                FileName = "shutdown.exe",
+                Arguments = arguments,
+                UseShellExecute = false,
+                CreateNoWindow = true
+            });
+            process?.WaitForExit(5000);
+        }
+
         [RelayCommand]
         public void ShowLogs()
         {
@@ -221,6 +316,8 @@ namespace Cimian.Status.ViewModels
 
         private void OnLogLineReceived(object? sender, string logLine)
         {
+            if (_logStreaming) return;
+
             // Ensure UI updates happen on the UI thread
             App.Current.Dispatcher.BeginInvoke(() =>
             {
@@ -281,8 +378,147 @@ namespace Cimian.Status.ViewModels
             HasError = e.IsError;
         }
 
+        private void OnItemStatusChanged(object? sender, ItemStatusEventArgs e)
+        {
+            if (e.Stage is not ("downloading" or "installing" or "removing"))
+            {
+                return;
+            }
+
+            App.Current.Dispatcher.BeginInvoke(() =>
+            {
+                _iconNames ??= LoadIconNames();
+                var path = IconCache.FindIcon(e.Item, _iconNames.GetValueOrDefault(e.Item));
+                HeaderIcon = path != null ? LoadIcon(path) ?? DefaultHeaderIcon : DefaultHeaderIcon;
+            });
+        }
+
+        /// <summary>
+        /// An itemStatus message: adds the item's row on first sight and moves it
+        /// to the new stage. Called on the UI thread.
+        /// </summary>
+        public void ApplyItemStatus(string item, string stage, string? detail)
+        {
+            FindOrAddItem(item).ApplyStatus(stage, detail);
+        }
+
+        /// <summary>
+        /// An itemProgress message: download bytes and ETA, or the install phase.
+        /// Called on the UI thread.
+        /// </summary>
+        public void ApplyItemProgress(StatusMessage message)
+        {
+            FindOrAddItem(message.Item!).ApplyProgress(message.Data, message.Percent,
+                message.Bytes, message.TotalBytes, message.EtaSeconds, message.Message);
+        }
+
+        /// <summary>
+        /// The run sent quit: the list stays up for the user to read, and is
+        /// replaced when the next run reports its first item.
+        /// </summary>
+        public void CompleteItems()
+        {
+            _itemsComplete = true;
+            _logStreaming = false;
+        }
+
+        /// <summary>
+        /// A logLine message: a run.log line streamed by managedsoftwareupdate,
+        /// shown in the live log pane while it's open. Called on the UI thread.
+        /// </summary>
+        public void ApplyLogLine(string? level, string text)
+        {
+            _logStreaming = true;
+            if (!IsLogViewerExpanded) return;
+
+            AddLogLine(string.IsNullOrEmpty(level) || level == "INFO" ? text : $"{level}: {text}");
+        }
+
+        private ItemProgressViewModel FindOrAddItem(string name)
+        {
+            if (_itemsComplete)
+            {
+                Items.Clear();
+                _itemsComplete = false;
+            }
+
+            var row = Items.FirstOrDefault(i => string.Equals(i.Name, name, StringComparison.OrdinalIgnoreCase));
+            if (row == null)
+            {
+                row = new ItemProgressViewModel(name);
+                Items.Add(row);
+            }
+            return row;
+        }
+
+        private void OnReleaseNotesReceived(object? sender, ReleaseNotesEventArgs e)
+        {
+            var note = new LastRunReleaseNote { Name = e.Item, Version = e.Version, Notes = e.Notes.Trim() };
+            App.Current.Dispatcher.BeginInvoke(() =>
+            {
+                WhatsNewText = string.IsNullOrEmpty(WhatsNewText)
+                    ? FormatReleaseNotes([note])
+                    : WhatsNewText + "\n\n" + FormatReleaseNotes([note]);
+            });
+        }
+
+        private static string FormatReleaseNotes(IEnumerable<LastRunReleaseNote> notes) =>
+            string.Join("\n\n", notes.Select(n => $"{n.Heading}\n{n.Notes}"));
+
+        /// <summary>
+        /// Loads a cached icon fully into memory so the file isn't held open while
+        /// managedsoftwareupdate may be refreshing it.
+        /// </summary>
+        private static ImageSource? LoadIcon(string path)
+        {
+            try
+            {
+                var bitmap = new BitmapImage();
+                bitmap.BeginInit();
+                bitmap.CacheOption = BitmapCacheOption.OnLoad;
+                bitmap.UriSource = new Uri(path);
+                bitmap.EndInit();
+                bitmap.Freeze();
+                return bitmap;
+            }
+            catch (Exception ex) when (ex is IOException or NotSupportedException or UriFormatException)
+            {
+                return null;
+            }
+        }
+
+        private static Dictionary<string, string?> LoadIconNames()
+        {
+            var names = new Dictionary<string, string?>(StringComparer.OrdinalIgnoreCase);
+            try
+            {
+                if (!File.Exists(CimianPaths.InstallInfoYaml))
+                {
+                    return names;
+                }
+                var info = YamlUtils.DeserializeInstallInfo(File.ReadAllText(CimianPaths.InstallInfoYaml));
+                foreach (var item in (info?.ManagedInstalls ?? []).Concat(info?.Removals ?? []).Concat(info?.OptionalInstalls ?? []))
+                {
+                    names.TryAdd(item.Name, item.Icon);
+                }
+            }
+            catch (Exception ex)
+            {
+                System.Diagnostics.Debug.WriteLine($"Could not read icon names from InstallInfo: {ex.Message}");
+            }
+            return names;
+        }
+
         private void OnUpdateCompleted(object? sender, UpdateCompletedEventArgs e)
         {
+            App.Current.Dispatcher.BeginInvoke(() =>
+            {
+                LoadRestartState();
+                LoadMaintenanceHold();
+                HeaderIcon = DefaultHeaderIcon;
+                _iconNames = null;
+            });
+
             ProgressValue = 100;
             ShowProgress = true;
             
@@ -299,11 +535,77 @@ namespace Cimian.Status.ViewModels
             }
         }
 
+        private void LoadRestartState()
+        {
+            _restartState = _logService.GetRestartState();
+            HasPendingRestart = _restartState != null;
+            RefreshRestartText();
+            if (_restartState?.ScheduledFor != null)
+            {
+                _restartTimer.Start();
+            }
+            else
+            {
+                _restartTimer.Stop();
+            }
+        }
+
+        private void RefreshRestartText()
+        {
+            RestartText = _restartState?.Describe(DateTime.Now) ?? "";
+        }
+
+        private void LoadMaintenanceHold()
+        {
+            MaintenanceHoldText = _logService.GetMaintenanceHold()?.Describe() ?? "";
+        }
+
         private void LoadLastRunTime()
         {
             LastRunTime = _logService.GetLastRunTime();
         }
 
+        /// <summary>
+        /// Opened with no run in progress: show how the last run ended (from
+        /// status.json) instead of an idle "Ready" window.
+        /// </summary>
+        private void LoadLastRunStatus()
+        {
+            try
+            {
+                if (System.Diagnostics.Process.GetProcessesByName("managedsoftwareupdate").Length > 0)
+                {
+                    return;
+                }
+            }
+            catch
+            {
+                // Can't tell; showing the last result is still better than nothing
+            }
+
+            var status = _logService.GetLastRunStatus();
+            if (status == null)
+            {
+                return;
+            }
+
+            StatusText = status.Outcome switch
+            {
+                "success" => "Last run completed successfully",
+                "partial" => "Last run completed with errors",
+                _ => "Last run failed"
+            };
+            DetailText = status.Deferrals.Count > 0
+                ? string.Join("\n", status.Deferrals.Select(d => d.Describe()))
+                : status.Message;
+            ProgressText = status.Message;
+            WhatsNewText = FormatReleaseNotes(status.ReleaseNotes);
+            ProgressValue = 100;
+            IsIndeterminate = false;
+            HasError = status.Outcome != "success";
+            LastRunTime = status.EndTime.ToString("yyyy-MM-dd HH:mm:ss");
+        }
+
         private void SaveLastRunTime()
         {
             _logService.SaveLastRunTime();
@@ -0,0 +1,215 @@
+using System;
+using System.ComponentModel;
+using System.Diagnostics;
+using System.Linq;
+using System.Threading.Tasks;
+using System.Windows;
+using System.Windows.Threading;
+using Microsoft.Extensions.Logging;
+using Cimian.Core.Services;
+using Cimian.Status.Services;
+
+namespace Cimian.Status.Views
+{
+    /// <summary>
+    /// "Setting up your device": covers every screen while a bootstrap runs and
+    /// can't be closed until the bootstrap ends, BootstrapScreenTimeoutMinutes
+    /// passes, or the user skips it (BootstrapScreenAllowSkip). Progress comes
+    /// from bootstrap_progress.json and, while a run is going, from
+    /// managedsoftwareupdate's status messages. The copy on the logon screen
+    /// closes itself once someone logs on; CimianWatcher opens theirs.
+    /// </summary>
+    public partial class BootstrapWindow : Window
+    {
+        private static readonly TimeSpan PollInterval = TimeSpan.FromSeconds(5);
+        private static readonly TimeSpan FarewellDelay = TimeSpan.FromSeconds(5);
+
+        private readonly IStatusServer _statusServer;
+        private readonly BootstrapScreenConfig _config;
+        private readonly bool _onLogonScreen;
+        private readonly ILogger<BootstrapWindow> _logger;
+        private readonly DispatcherTimer _timer;
+        private bool _finishing;
+
+        /// <summary>How the screen ended; cimistatus exits with it (see <see cref="BootstrapScreenExitCode"/>).</summary>
+        public int ExitCode { get; private set; } = BootstrapScreenExitCode.Ended;
+
+        public BootstrapWindow(IStatusServer statusServer, BootstrapScreenConfig config, bool onLogonScreen, ILogger<BootstrapWindow> logger)
+        {
+            InitializeComponent();
+
+            _statusServer = statusServer ?? throw new ArgumentNullException(nameof(statusServer));
+            _config = config ?? throw new ArgumentNullException(nameof(config));
+            _onLogonScreen = onLogonScreen;
+            _logger = logger ?? throw new ArgumentNullException(nameof(logger));
+
+            // Nobody can skip on behalf of the logon screen
+            SkipButton.Visibility = _config.AllowSkip && !_onLogonScreen ? Visibility.Visible : Visibility.Collapsed;
+
+            // Cover every monitor, not just the primary one
+            Left = SystemParameters.VirtualScreenLeft;
+            Top = SystemParameters.VirtualScreenTop;
+            Width = SystemParameters.VirtualScreenWidth;
+            Height = SystemParameters.VirtualScreenHeight;
+
+            _timer = new DispatcherTimer { Interval = PollInterval };
+            _timer.Tick += (_, _) => Refresh();
+
+            _statusServer.MessageReceived += OnStatusMessageReceived;
+            Loaded += OnLoaded;
+            Closing += OnClosing;
+        }
+
+        private async void OnLoaded(object sender, RoutedEventArgs e)
+        {
+            Refresh();
+            _timer.Start();
+            Activate();
+
+            try
+            {
+                if (!_statusServer.IsRunning)
+                {
+                    await _statusServer.StartAsync();
+                }
+            }
+            catch (Exception ex)
+            {
+                // Another copy holds the status port; bootstrap_progress.json still drives the screen
+                _logger.LogWarning("Bootstrap screen has no live status: {Message}", ex.Message);
+            }
+        }
+
+        private void Refresh()
+        {
+            if (_onLogonScreen && UserLoggedOn())
+            {
+                _logger.LogInformation("User logged on; closing the logon-screen bootstrap screen");
+                Finish(BootstrapScreenExitCode.Ended, null);
+                return;
+            }
+
+            var progress = BootstrapProgressStore.Read();
+            var state = BootstrapScreen.Evaluate(progress, _config, DateTime.Now);
+            if (state == BootstrapScreenState.Show)
+            {
+                DetailText.Text = BootstrapScreen.Describe(progress!);
+                return;
+            }
+
+            _logger.LogInformation("Bootstrap screen closing: {State}", state);
+            Finish(state == BootstrapScreenState.TimedOut ? BootstrapScreenExitCode.TimedOut : BootstrapScreenExitCode.Ended,
+                BootstrapScreen.Farewell(state));
+        }
+
+        private void OnStatusMessageReceived(object? sender, Models.StatusMessage message)
+        {
+            Dispatcher.Invoke(() =>
+            {
+                if (_finishing)
+                {
+                    return;
+                }
+
+                switch (message.Type?.ToLowerInvariant())
+                {
+                    case "statusmessage":
+                        if (!string.IsNullOrWhiteSpace(message.Data))
+                        {
+                            StatusText.Text = message.Data;
+                        }
+                        break;
+
+                    case "percentprogress":
+                        if (message.Percent >= 0)
+                        {
+                            Progress.IsIndeterminate = false;
+                            Progress.Value = message.Percent;
+                        }
+                        else
+                        {
+                            Progress.IsIndeterminate = true;
+                        }
+                        break;
+                }
+            });
+        }
+
+        /// <summary>Shows <paramref name="farewell"/> for a few seconds, or closes straight away without one.</summary>
+        private void Finish(int exitCode, string? farewell)
+        {
+            if (_finishing)
+            {
+                return;
+            }
+            _finishing = true;
+            _timer.Stop();
+            ExitCode = exitCode;
+
+            if (farewell == null)
+            {
+                Close();
+                return;
+            }
+
+            HeadlineText.Text = farewell;
+            StatusText.Text = "";
+            DetailText.Text = "";
+            FooterText.Text = "";
+            SkipButton.Visibility = Visibility.Collapsed;
+            Progress.IsIndeterminate = false;
+            Progress.Value = 100;
+
+            var closeTimer = new DispatcherTimer { Interval = FarewellDelay };
+            closeTimer.Tick += (_, _) =>
+            {
+                closeTimer.Stop();
+                Close();
+            };
+            closeTimer.Start();
+        }
+
+        private void SkipButton_Click(object sender, RoutedEventArgs e)
+        {
+            _logger.LogInformation("Bootstrap screen skipped by {User}", Environment.UserName);
+            Finish(BootstrapScreenExitCode.Skipped, null);
+        }
+
+        private void OnClosing(object? sender, CancelEventArgs e)
+        {
+            // Alt+F4 and the like: the screen only closes itself
+            if (!_finishing)
+            {
+                e.Cancel = true;
+            }
+        }
+
+        private static bool UserLoggedOn()
+        {
+            using var current = Process.GetCurrentProcess();
+            return Process.GetProcessesByName("explorer").Any(p => p.SessionId == current.SessionId);
+        }
+
+        protected override void OnClosed(EventArgs e)
+        {
+            _timer.Stop();
+            _statusServer.MessageReceived -= OnStatusMessageReceived;
+            if (_statusServer.IsRunning)
+            {
+                _ = Task.Run(async () =>
+                {
+                    try
+                    {
+                        await _statusServer.StopAsync();
+                    }
+                    catch (Exception ex)
+                    {
+                        _logger.LogWarning(ex, "Error stopping status server");
+                    }
+                });
+            }
+
+            base.OnClosed(e);
+        }
+    }
+}
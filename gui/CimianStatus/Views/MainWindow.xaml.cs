@@ -77,6 +77,24 @@ namespace Cimian.Status.Views
                             }
                             break;
 
+                        case "itemstatus":
+                            if (!string.IsNullOrEmpty(message.Item))
+                            {
+                                _viewModel.ApplyItemStatus(message.Item, message.Data, message.Message);
+                            }
+                            break;
+
+                        case "itemprogress":
+                            if (!string.IsNullOrEmpty(message.Item))
+                            {
+                                _viewModel.ApplyItemProgress(message);
+                            }
+                            break;
+
+                        case "logline":
+                            _viewModel.ApplyLogLine(message.Level, message.Data);
+                            break;
+
                         case "displaylog":
                             // Log path received - could be used for direct log access
                             _logger.LogInformation("Log path received: {LogPath}", message.Data);
@@ -88,6 +106,7 @@ namespace Cimian.Status.Views
                             _viewModel.ProgressValue = 100;
                             _viewModel.ProgressText = "Update completed successfully";
                             _viewModel.StatusText = "All operations completed";
+                            _viewModel.CompleteItems();
                             // Allow user to manually close the window instead of auto-shutdown
                             break;
                     }
@@ -117,6 +117,11 @@ public enum ProgressMessageType
     /// </summary>
     ItemStatus,
 
+    /// <summary>
+    /// An installed item's release notes (ItemName, version in Message, notes in Detail)
+    /// </summary>
+    ReleaseNotes,
+
     /// <summary>
     /// Download progress
     /// </summary>
@@ -27,6 +27,11 @@ public interface INotificationService
     /// </summary>
     void ShowInstallFailed(string itemName, string? errorMessage = null);
 
+    /// <summary>
+    /// Show an installed item's release notes ("What's new")
+    /// </summary>
+    void ShowWhatsNew(string itemName, string version, string notes);
+
     /// <summary>
     /// Show notification for required restart
     /// </summary>
@@ -3,22 +3,19 @@
 
 using System.Collections.Concurrent;
 using System.IO;
+using Cimian.Core;
+using Cimian.Core.Services;
 using Microsoft.UI.Xaml.Media.Imaging;
 
 namespace Cimian.GUI.ManagedSoftwareCenter.Services;
 
 /// <summary>
-/// Loads icons from C:\ProgramData\ManagedInstalls\icons\ and caches them in memory.
+/// Loads icons from C:\ProgramData\ManagedInstalls\icons\ (kept current by
+/// managedsoftwareupdate) and caches them in memory.
 /// Generates colored initials icons as fallback.
 /// </summary>
 public sealed class IconService : IIconService
 {
-    private static readonly string IconsDirectory =
-        Path.Combine(Environment.GetFolderPath(Environment.SpecialFolder.CommonApplicationData),
-            "ManagedInstalls", "icons");
-
-    private static readonly string[] SupportedExtensions = [".png", ".jpg", ".jpeg", ".ico", ".bmp"];
-
     // Cache: key = itemName (lowercase), value = weak reference to BitmapImage
     private readonly ConcurrentDictionary<string, WeakReference<BitmapImage>> _cache = new();
 
@@ -57,32 +54,11 @@ public sealed class IconService : IIconService
 
     private static async Task<BitmapImage?> TryLoadFromDiskAsync(string itemName, string? iconFileName)
     {
-        // Build candidate filenames to try
-        var candidates = new List<string>();
-
-        // If an explicit icon filename was provided, try it first
-        if (!string.IsNullOrWhiteSpace(iconFileName))
+        foreach (var candidate in IconCache.Candidates(itemName, iconFileName))
         {
-            candidates.Add(iconFileName);
-            // Also try without extension in case it's just a name
-            var nameOnly = Path.GetFileNameWithoutExtension(iconFileName);
-            foreach (var ext in SupportedExtensions)
-                candidates.Add(nameOnly + ext);
-        }
-
-        // Try itemName with each supported extension
-        foreach (var ext in SupportedExtensions)
-            candidates.Add(itemName + ext);
-
-        foreach (var candidate in candidates.Distinct(StringComparer.OrdinalIgnoreCase))
-        {
-            var fullPath = Path.Combine(IconsDirectory, candidate);
             // Ensure the resolved path stays within the icons directory
-            var resolvedPath = Path.GetFullPath(fullPath);
-            if (!resolvedPath.StartsWith(IconsDirectory, StringComparison.OrdinalIgnoreCase))
-                continue;
-
-            if (!File.Exists(resolvedPath))
+            var resolvedPath = IconCache.ResolveInside(CimianPaths.IconsDir, candidate);
+            if (resolvedPath == null || !File.Exists(resolvedPath))
                 continue;
 
             try
@@ -1,9 +1,13 @@
 // NotificationService.cs - Windows toast notifications for Software Center (WinUI 3)
 // Uses Microsoft.Windows.AppNotifications from WindowsAppSDK
 
+using Cimian.Core;
+using Cimian.Core.Models;
+using Cimian.Core.Services;
 using Microsoft.Extensions.Logging;
 using Microsoft.Windows.AppNotifications;
 using Microsoft.Windows.AppNotifications.Builder;
+using YamlDotNet.Serialization;
 
 namespace Cimian.GUI.ManagedSoftwareCenter.Services;
 
@@ -13,9 +17,17 @@ namespace Cimian.GUI.ManagedSoftwareCenter.Services;
 /// </summary>
 public class NotificationService : INotificationService
 {
+    private static readonly TimeSpan FocusPollInterval = TimeSpan.FromSeconds(30);
+
     private readonly ILogger<NotificationService>? _logger;
     private bool _initialized;
 
+    // Toasts raised while the user is in Focus Assist, presenting or full-screen
+    // wait here (newest per kind) and go out once they're available again
+    private readonly Dictionary<string, AppNotification> _held = new();
+    private readonly object _heldLock = new();
+    private Timer? _focusTimer;
+
     public NotificationService(ILogger<NotificationService>? logger = null)
     {
         _logger = logger;
@@ -89,7 +101,7 @@ public class NotificationService : INotificationService
                 .AddButton(new AppNotificationButton("Later")
                     .AddArgument("action", "dismiss"));
 
-            AppNotificationManager.Default.Show(builder.BuildNotification());
+            Show("updates", builder.BuildNotification());
 
             _logger?.LogDebug("Showed updates available notification: {Count}", updateCount);
         }
@@ -110,7 +122,7 @@ public class NotificationService : INotificationService
                 .AddText("Installation complete")
                 .AddText($"{itemName} has been installed successfully.");
 
-            AppNotificationManager.Default.Show(builder.BuildNotification());
+            Show($"installComplete:{itemName}", builder.BuildNotification());
 
             _logger?.LogDebug("Showed install complete notification: {Item}", itemName);
         }
@@ -136,7 +148,7 @@ public class NotificationService : INotificationService
                 builder.AddText(errorMessage);
             }
 
-            AppNotificationManager.Default.Show(builder.BuildNotification());
+            Show($"installFailed:{itemName}", builder.BuildNotification());
 
             _logger?.LogDebug("Showed install failed notification: {Item}", itemName);
         }
@@ -146,6 +158,37 @@ public class NotificationService : INotificationService
         }
     }
 
+    /// <inheritdoc />
+    public void ShowWhatsNew(string itemName, string version, string notes)
+    {
+        try
+        {
+            var builder = new AppNotificationBuilder()
+                .AddArgument("action", "viewItem")
+                .AddArgument("item", itemName)
+                .AddText($"What's new in {itemName} {version}")
+                .AddText(Summarize(notes));
+
+            Show($"whatsNew:{itemName}", builder.BuildNotification());
+
+            _logger?.LogDebug("Showed release notes notification: {Item} {Version}", itemName, version);
+        }
+        catch (Exception ex)
+        {
+            _logger?.LogError(ex, "Failed to show release notes notification");
+        }
+    }
+
+    /// <summary>
+    /// First paragraph of the notes, cut to what a toast can show.
+    /// </summary>
+    private static string Summarize(string notes)
+    {
+        const int MaxLength = 200;
+        var text = notes.Replace("\r\n", "\n").Split("\n\n", 2)[0].Replace('\n', ' ').Trim();
+        return text.Length <= MaxLength ? text : text[..(MaxLength - 1)].TrimEnd() + "…";
+    }
+
     /// <inheritdoc />
     public void ShowRestartRequired()
     {
@@ -160,7 +203,7 @@ public class NotificationService : INotificationService
                 .AddButton(new AppNotificationButton("Later")
                     .AddArgument("action", "restartLater"));
 
-            AppNotificationManager.Default.Show(builder.BuildNotification());
+            Show("restart", builder.BuildNotification());
 
             _logger?.LogDebug("Showed restart required notification");
         }
@@ -184,7 +227,7 @@ public class NotificationService : INotificationService
                 .AddButton(new AppNotificationButton("Later")
                     .AddArgument("action", "dismiss"));
 
-            AppNotificationManager.Default.Show(builder.BuildNotification());
+            Show("logout", builder.BuildNotification());
 
             _logger?.LogDebug("Showed logout required notification");
         }
@@ -194,9 +237,69 @@ public class NotificationService : INotificationService
         }
     }
 
+    private void Show(string kind, AppNotification notification)
+    {
+        var config = LoadConfig();
+        if (!MachineRole.ResolveBool(config.MachineRole, "ShowNotifications", config.ShowNotifications, true))
+        {
+            _logger?.LogDebug("Dropping {Kind} notification: disabled by ShowNotifications / MachineRole", kind);
+            return;
+        }
+
+        var state = MachineRole.ResolveBool(config.MachineRole, "RespectFocusAssist", config.RespectFocusAssist, true)
+            ? FocusAssist.GetState()
+            : FocusState.Available;
+        if (state == FocusState.Available)
+        {
+            AppNotificationManager.Default.Show(notification);
+            return;
+        }
+
+        lock (_heldLock)
+        {
+            _held[kind] = notification;
+            _focusTimer ??= new Timer(_ => ShowHeld(), null, FocusPollInterval, FocusPollInterval);
+        }
+        _logger?.LogDebug("Holding {Kind} notification: {Reason}", kind, FocusAssist.Describe(state));
+    }
+
+    private void ShowHeld()
+    {
+        if (FocusAssist.GetState() != FocusState.Available)
+        {
+            return;
+        }
+
+        List<AppNotification> pending;
+        lock (_heldLock)
+        {
+            pending = _held.Values.ToList();
+            _held.Clear();
+            _focusTimer?.Dispose();
+            _focusTimer = null;
+        }
+
+        foreach (var notification in pending)
+        {
+            try
+            {
+                AppNotificationManager.Default.Show(notification);
+            }
+            catch (Exception ex)
+            {
+                _logger?.LogError(ex, "Failed to show held notification");
+            }
+        }
+    }
+
     /// <inheritdoc />
     public void ClearAllNotifications()
     {
+        lock (_heldLock)
+        {
+            _held.Clear();
+        }
+
         try
         {
             AppNotificationManager.Default.RemoveAllAsync().GetAwaiter().GetResult();
@@ -213,6 +316,12 @@ public class NotificationService : INotificationService
     /// </summary>
     public void Shutdown()
     {
+        lock (_heldLock)
+        {
+            _focusTimer?.Dispose();
+            _focusTimer = null;
+        }
+
         try
         {
             AppNotificationManager.Default.Unregister();
@@ -222,4 +331,37 @@ public class NotificationService : INotificationService
             _logger?.LogError(ex, "Failed to unregister notification manager");
         }
     }
+
+    /// <summary>
+    /// Read on every toast so a Config.yaml push applies without restarting MSC.
+    /// </summary>
+    private NotificationConfig LoadConfig()
+    {
+        try
+        {
+            if (File.Exists(CimianPaths.ConfigYaml))
+            {
+                return YamlUtils.Deserializer.Deserialize<NotificationConfig>(File.ReadAllText(CimianPaths.ConfigYaml))
+                    ?? new NotificationConfig();
+            }
+        }
+        catch (Exception ex)
+        {
+            _logger?.LogDebug("Could not read {Path}: {Message}", CimianPaths.ConfigYaml, ex.Message);
+        }
+        return new NotificationConfig();
+    }
+
+    /// <summary>The Config.yaml keys that decide whether and when toasts show.</summary>
+    private class NotificationConfig
+    {
+        [YamlMember(Alias = "MachineRole")]
+        public string? MachineRole { get; set; }
+
+        [YamlMember(Alias = "ShowNotifications")]
+        public bool? ShowNotifications { get; set; }
+
+        [YamlMember(Alias = "RespectFocusAssist")]
+        public bool? RespectFocusAssist { get; set; }
+    }
 }
@@ -255,6 +255,13 @@ public class ProgressServer : IProgressPipeClient, IDisposable
                 progress.Message = goMessage.Message ?? string.Empty;
                 break;
 
+            case "releaseNotes":
+                progress.Type = ProgressMessageType.ReleaseNotes;
+                progress.ItemName = goMessage.Item;
+                progress.Message = goMessage.Message ?? string.Empty;
+                progress.Detail = goMessage.Data;
+                break;
+
             case "quit":
                 progress.Type = ProgressMessageType.Complete;
                 progress.Message = "Complete";
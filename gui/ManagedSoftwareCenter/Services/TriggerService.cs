@@ -1,7 +1,6 @@
-// TriggerService.cs - Triggers managedsoftwareupdate via CimianWatcher service
-// Uses flag-file IPC so the SYSTEM service launches the process — no UAC needed.
+// TriggerService.cs - Triggers managedsoftwareupdate via CimianWatcher's run broker
+// The SYSTEM service launches the process on request, so no UAC prompt is needed.
 
-using System.IO;
 using Cimian.Core.Services;
 using Microsoft.Extensions.Logging;
 
@@ -9,22 +8,20 @@ namespace Cimian.GUI.ManagedSoftwareCenter.Services;
 
 /// <summary>
 /// Service for triggering managedsoftwareupdate operations via CimianWatcher.
-/// Writes a flag file with custom arguments; the CimianWatcher service (running
-/// as SYSTEM) picks it up and launches managedsoftwareupdate elevated — no UAC
-/// prompt required, even for standard users.
+/// Sends a request on the run broker's user pipe; the CimianWatcher service
+/// (running as SYSTEM) decides whether this user may and launches
+/// managedsoftwareupdate itself — no UAC prompt required, even for standard
+/// users. MSC only ever asks for a checkonly run or a gui run of named items,
+/// never a command line.
 /// </summary>
 public class TriggerService : ITriggerService, IDisposable
 {
-    private const string BootstrapFlagFile = @"C:\ProgramData\ManagedInstalls\.cimian.bootstrap";
-    // Tell the engine to report progress to MSC's own listener (ProgressServer.Port)
-    // instead of the default 19847 used by the login-window CimianStatus, so the
-    // two never collide. Appended to every --show-status run MSC triggers.
-    private static readonly string StatusPortArg = $"--status-port {ProgressServer.Port}";
-    private static readonly TimeSpan ServiceTimeout = TimeSpan.FromSeconds(30);
+    private const string ServiceUnavailableMessage =
+        "CimianWatcher service did not respond. Ensure the service is running: sc query CimianWatcher";
     private static readonly TimeSpan PollInterval = TimeSpan.FromMilliseconds(500);
 
     private readonly ILogger<TriggerService>? _logger;
-    private readonly SemaphoreSlim _flagFileLock = new(1, 1);
+    private readonly SemaphoreSlim _batchLock = new(1, 1);
     private readonly HashSet<string> _pendingItems = new(StringComparer.OrdinalIgnoreCase);
     private readonly List<string> _pendingItemOrder = new();
     // Subset of _pendingItems the caller flagged as removals, so the progress
@@ -33,14 +30,15 @@ public class TriggerService : ITriggerService, IDisposable
     // affects the human-facing banner verb.
     private readonly HashSet<string> _pendingRemovals = new(StringComparer.OrdinalIgnoreCase);
 
-    // A single in-flight Task per targeted-install batch. Concurrent
-    // TriggerInstallItemAsync calls that arrive while the flag file from a
-    // prior click is still pending watcher consumption rewrite the file with
-    // the merged --item list and await the SAME task — otherwise they would
-    // start their own polling loops and could recreate the flag file moments
-    // after CimianWatcher deleted it, kicking off a second MSU run that
-    // races the first.
+    // A single in-flight Task per targeted-install batch. The broker refuses a
+    // request while another run holds its slot, so a batch waits for that run
+    // to exit and asks again. TriggerInstallItemAsync calls that arrive in the
+    // meantime merge their names into the pending list and await the SAME task,
+    // so rapid clicks become one --item run instead of racing each other.
     private Task? _currentBatch;
+    // Set by TriggerStopAsync: a request still waiting for the current run to
+    // finish gives up instead of sending.
+    private volatile bool _stopRequested;
     private bool _isOperationRunning;
     private bool _isItemScopedOperation;
     private string? _currentOperationLabel;
@@ -56,34 +54,50 @@ public class TriggerService : ITriggerService, IDisposable
     public TriggerService(ILogger<TriggerService>? logger = null)
     {
         _logger = logger;
-        _logger?.LogInformation("TriggerService initialized — flag-file IPC via CimianWatcher");
+        _logger?.LogInformation("TriggerService initialized — run broker requests via CimianWatcher");
     }
 
     /// <inheritdoc />
     public async Task TriggerCheckAsync()
     {
-        _logger?.LogInformation("Triggering check via CimianWatcher");
+        _logger?.LogInformation("Requesting a check via CimianWatcher");
         _isItemScopedOperation = false;
         _currentOperationLabel = "Checking for updates...";
+        _stopRequested = false;
+        _isOperationRunning = true;
+        RaiseOperationStatusChanged(true);
         try
         {
-            await WriteFlagFileAndWaitAsync($"--checkonly --show-status -vv {StatusPortArg}").ConfigureAwait(false);
+            // Progress goes to MSC's own listener (ProgressServer.Port) instead of
+            // the default 19847 used by the login-window CimianStatus.
+            var request = new RunBrokerRequest { Mode = "checkonly", StatusPort = ProgressServer.Port };
+            while (true)
+            {
+                var response = await SendRequestAsync(request).ConfigureAwait(false);
+                if (response.Accepted)
+                {
+                    _logger?.LogInformation("CimianWatcher started the check (pid {Pid})", response.ProcessId);
+                    break;
+                }
+                if (!await WaitForBusySlotAsync(response).ConfigureAwait(false))
+                {
+                    _isOperationRunning = false;
+                    RaiseOperationStatusChanged(false);
+                    return;
+                }
+            }
         }
-        finally
+        catch (InvalidOperationException)
         {
-            _currentOperationLabel = null;
+            _isOperationRunning = false;
+            RaiseOperationStatusChanged(false);
+            throw;
         }
-    }
-
-    /// <inheritdoc />
-    public async Task TriggerInstallAsync()
-    {
-        _logger?.LogInformation("Triggering install via CimianWatcher");
-        _isItemScopedOperation = false;
-        _currentOperationLabel = "Installing pending updates...";
-        try
+        catch (Exception ex)
         {
-            await WriteFlagFileAndWaitAsync($"--installonly --show-status -vv {StatusPortArg}").ConfigureAwait(false);
+            _logger?.LogError(ex, "Check request failed");
+            _isOperationRunning = false;
+            RaiseOperationStatusChanged(false);
         }
         finally
         {
@@ -113,13 +127,15 @@ public class TriggerService : ITriggerService, IDisposable
             throw new ArgumentException("At least one item name is required", nameof(itemNames));
         }
 
-        // Validate names up front — BuildSelfServeInstallArgs would throw on
-        // control characters too, but catching it here gives a clearer caller
-        // stack trace than something coming from inside the merge loop.
-        foreach (var n in names) _ = BootstrapArgsBuilder.QuoteArgument(n);
+        // Validate names up front against the broker's own pattern, so a bad
+        // name fails this click instead of the whole merged batch.
+        if (!RunBrokerProtocol.ValidateItems(new RunBrokerRequest { Items = names }, out var invalid))
+        {
+            throw new ArgumentException(invalid, nameof(itemNames));
+        }
 
         Task batchTask;
-        await _flagFileLock.WaitAsync().ConfigureAwait(false);
+        await _batchLock.WaitAsync().ConfigureAwait(false);
         try
         {
             foreach (var n in names)
@@ -134,34 +150,31 @@ public class TriggerService : ITriggerService, IDisposable
                 foreach (var r in removalNames.Where(n => !string.IsNullOrWhiteSpace(n)))
                     _pendingRemovals.Add(r);
             }
-            var mergedArgs = $"{BootstrapArgsBuilder.BuildSelfServeInstallArgs(_pendingItemOrder)} {StatusPortArg}";
             _isItemScopedOperation = true;
             _currentOperationLabel = BuildOperationLabel(_pendingItemOrder, _pendingRemovals);
 
             if (_currentBatch != null && !_currentBatch.IsCompleted)
             {
-                // A batch is already polling for consume — just rewrite the
-                // flag file with the merged --item list under the same lock so
-                // CimianWatcher reads the up-to-date set on its next 10s tick.
-                // The existing batch's poll loop is what we await.
-                await WriteFlagFileAsync(mergedArgs).ConfigureAwait(false);
+                // A batch is waiting for the current run to finish; it sends the
+                // merged --item list when it asks again.
                 _logger?.LogInformation(
-                    "Merged [{Items}] into in-flight self-serve batch ({Label})",
+                    "Merged [{Items}] into waiting self-serve batch ({Label})",
                     string.Join(", ", names), _currentOperationLabel);
                 batchTask = _currentBatch;
             }
             else
             {
                 _logger?.LogInformation(
-                    "Triggering targeted install via CimianWatcher: {Label}",
+                    "Requesting targeted install via CimianWatcher: {Label}",
                     _currentOperationLabel);
-                _currentBatch = RunSelfServeBatchAsync(mergedArgs);
+                _stopRequested = false;
+                _currentBatch = RunSelfServeBatchAsync();
                 batchTask = _currentBatch;
             }
         }
         finally
         {
-            _flagFileLock.Release();
+            _batchLock.Release();
         }
 
         await batchTask.ConfigureAwait(false);
@@ -188,49 +201,65 @@ public class TriggerService : ITriggerService, IDisposable
         return $"{verb} {items.Count} items ({items[0]}, {items[1]}, +{items.Count - 2} more)...";
     }
 
-    /// <summary>
-    /// Back-compat wrapper for the original internal helper. Existing callers
-    /// in this assembly continue to use this name; the implementation lives in
-    /// <see cref="BootstrapArgsBuilder.QuoteArgument"/>.
-    /// </summary>
-    internal static string QuoteArgument(string value) => BootstrapArgsBuilder.QuoteArgument(value);
-
     /// <inheritdoc />
     public Task TriggerStopAsync()
     {
-        // With the flag-file approach we don't hold a process handle.
-        // The best we can do is delete the flag file if the service hasn't
-        // picked it up yet, or rely on managedsoftwareupdate's own
-        // graceful-shutdown logic.
-        try
-        {
-            if (File.Exists(BootstrapFlagFile))
-            {
-                File.Delete(BootstrapFlagFile);
-                _logger?.LogInformation("Deleted bootstrap flag file (stop requested)");
-            }
-        }
-        catch (Exception ex)
-        {
-            _logger?.LogWarning(ex, "Could not delete flag file during stop");
-        }
+        // Nothing is queued on the service side: a request is either running or
+        // waiting here for the current run to finish. Drop the waiting one; the
+        // running managedsoftwareupdate is stopped over the progress pipe.
+        _stopRequested = true;
+        _logger?.LogInformation("Stop requested; a request waiting to be sent is dropped");
         return Task.CompletedTask;
     }
 
     /// <summary>
-    /// Self-serve batch poll loop. Writes the merged --item args, waits for
-    /// CimianWatcher to consume the flag file, then clears the pending state
-    /// so the next click starts a fresh batch. Runs once per batch; concurrent
-    /// callers within the same batch reuse the returned Task.
+    /// Self-serve batch. Sends the merged --item list as a gui run; while another
+    /// run holds the broker's slot, waits for it to exit and asks again with
+    /// whatever has been merged since. The send happens under the batch lock and
+    /// an accepted batch clears the pending state in the same hold, so a click
+    /// either makes it into this run or starts the next batch.
     /// </summary>
-    private async Task RunSelfServeBatchAsync(string initialArgs)
+    private async Task RunSelfServeBatchAsync()
     {
         _isOperationRunning = true;
         RaiseOperationStatusChanged(true);
         try
         {
-            await WriteFlagFileAsync(initialArgs).ConfigureAwait(false);
-            await WaitForFlagFileConsumedAsync().ConfigureAwait(false);
+            while (true)
+            {
+                RunBrokerResponse response;
+                await _batchLock.WaitAsync().ConfigureAwait(false);
+                try
+                {
+                    response = await SendRequestAsync(new RunBrokerRequest
+                    {
+                        Mode = "gui",
+                        Items = _pendingItemOrder.ToList(),
+                        StatusPort = ProgressServer.Port
+                    }).ConfigureAwait(false);
+                    if (response.Accepted)
+                    {
+                        ClearBatchState();
+                    }
+                }
+                finally
+                {
+                    _batchLock.Release();
+                }
+
+                if (response.Accepted)
+                {
+                    _logger?.LogInformation("CimianWatcher started the self-serve run (pid {Pid})", response.ProcessId);
+                    break;
+                }
+                if (!await WaitForBusySlotAsync(response).ConfigureAwait(false))
+                {
+                    _isOperationRunning = false;
+                    RaiseOperationStatusChanged(false);
+                    await ClearBatchStateAsync().ConfigureAwait(false);
+                    return;
+                }
+            }
         }
         catch (InvalidOperationException)
         {
@@ -248,12 +277,10 @@ public class TriggerService : ITriggerService, IDisposable
             return;
         }
 
-        // Consumed cleanly — pending state cleared so the next click starts a
-        // fresh batch. Completion of the install is normally signaled by the
-        // InstallInfo.yaml FileSystemWatcher or the StatusReporter quit, which
-        // flips the overlay back to idle.
-        await ClearBatchStateAsync().ConfigureAwait(false);
-
+        // Completion of the install is normally signaled by the InstallInfo.yaml
+        // FileSystemWatcher or the StatusReporter quit, which flips the overlay
+        // back to idle.
+        //
         // Safety net: a run that ends WITHOUT rewriting InstallInfo or sending
         // quit (e.g. an immediate parse/lock failure) would otherwise leave the
         // GUI stuck showing "Stop" forever. Wait for the launched process to
@@ -262,16 +289,16 @@ public class TriggerService : ITriggerService, IDisposable
     }
 
     /// <summary>
-    /// Waits for the managedsoftwareupdate process the watcher just launched to
+    /// Waits for the managedsoftwareupdate process the broker just launched to
     /// exit, then clears <see cref="_isOperationRunning"/> and notifies — UNLESS a
-    /// follow-up batch has been queued in the meantime (a fresh flag or a new
-    /// in-flight batch), in which case that batch owns the running state. This is
-    /// the backstop for runs that never signal completion any other way; normal
-    /// runs pass through harmlessly after InstallInfoChanged already reset the UI.
+    /// follow-up batch has been started in the meantime, in which case that batch
+    /// owns the running state. This is the backstop for runs that never signal
+    /// completion any other way; normal runs pass through harmlessly after
+    /// InstallInfoChanged already reset the UI.
     /// </summary>
     private async Task SignalOperationDoneOnProcessExitAsync()
     {
-        // Give the freshly-consumed flag a moment to spawn the process.
+        // Give the process a moment to show up.
         var startWait = TimeSpan.Zero;
         while (!IsUpdateProcessRunning() && startWait < TimeSpan.FromSeconds(5))
         {
@@ -280,101 +307,76 @@ public class TriggerService : ITriggerService, IDisposable
         }
         // Then wait for it to finish. No fixed cap — a real install can take
         // minutes; the process either exits or the user closes the app.
-        while (IsUpdateProcessRunning())
-        {
-            await Task.Delay(PollInterval).ConfigureAwait(false);
-        }
+        await WaitForUpdateProcessExitAsync().ConfigureAwait(false);
 
-        await _flagFileLock.WaitAsync().ConfigureAwait(false);
+        await _batchLock.WaitAsync().ConfigureAwait(false);
         try
         {
-            // A queued follow-up (new batch or pending flag) now owns the state.
-            if (_currentBatch != null || File.Exists(BootstrapFlagFile)) return;
+            // A follow-up batch now owns the state.
+            if (_currentBatch != null) return;
             if (!_isOperationRunning) return;
             _isOperationRunning = false;
         }
         finally
         {
-            _flagFileLock.Release();
+            _batchLock.Release();
         }
         RaiseOperationStatusChanged(false);
     }
 
     /// <summary>
-    /// Wraps a one-shot Check/Install flag-file flow that doesn't need batching.
-    /// </summary>
-    private async Task WriteFlagFileAndWaitAsync(string arguments)
-    {
-        _isOperationRunning = true;
-        RaiseOperationStatusChanged(true);
-        try
-        {
-            await WriteFlagFileAsync(arguments).ConfigureAwait(false);
-            await WaitForFlagFileConsumedAsync().ConfigureAwait(false);
-        }
-        catch (InvalidOperationException)
-        {
-            _isOperationRunning = false;
-            RaiseOperationStatusChanged(false);
-            throw;
-        }
-        catch (Exception ex)
-        {
-            _logger?.LogError(ex, "Flag-file trigger failed");
-            _isOperationRunning = false;
-            RaiseOperationStatusChanged(false);
-        }
-    }
-
-    /// <summary>
-    /// Raises OperationStatusChanged on the UI thread. The poll loops run on
+    /// Raises OperationStatusChanged on the UI thread. The request loops run on
     /// threadpool continuations (ConfigureAwait(false)) and subscribers set
     /// XAML-bound observable properties.
     /// </summary>
     private void RaiseOperationStatusChanged(bool isRunning)
         => UiDispatcher.Post(() => OperationStatusChanged?.Invoke(this, isRunning));
 
-    private async Task WriteFlagFileAsync(string arguments)
+    /// <summary>
+    /// The broker's answer. Throws <see cref="InvalidOperationException"/> when the
+    /// service can't be reached, so callers show the same error as before.
+    /// </summary>
+    private async Task<RunBrokerResponse> SendRequestAsync(RunBrokerRequest request)
     {
-        var timestamp = DateTime.Now.ToString("yyyy-MM-dd HH:mm:ss");
-        var content = $"Bootstrap triggered at: {timestamp}\nSource: ManagedSoftwareCenter\nArgs: {arguments}\n";
-        var dir = Path.GetDirectoryName(BootstrapFlagFile);
-        if (!string.IsNullOrEmpty(dir))
-            Directory.CreateDirectory(dir);
-        await File.WriteAllTextAsync(BootstrapFlagFile, content).ConfigureAwait(false);
-        _logger?.LogInformation("Wrote bootstrap flag file with args: {Args}", arguments);
+        var response = await RunBrokerClient.SendAsync(request).ConfigureAwait(false);
+        if (response == null)
+        {
+            _logger?.LogError("CimianWatcher run broker is not reachable — is the service running?");
+            throw new InvalidOperationException(ServiceUnavailableMessage);
+        }
+        return response;
     }
 
-    private async Task WaitForFlagFileConsumedAsync()
+    /// <summary>
+    /// Handles a refusal. While another managedsoftwareupdate holds the run slot
+    /// the refusal just means "not yet": wait for it to exit and return true so
+    /// the caller asks again (false if a stop came in meanwhile). Any other
+    /// refusal throws <see cref="InvalidOperationException"/> with the broker's reason.
+    /// </summary>
+    private async Task<bool> WaitForBusySlotAsync(RunBrokerResponse response)
     {
-        var elapsed = TimeSpan.Zero;
-        while (File.Exists(BootstrapFlagFile) && elapsed < ServiceTimeout)
+        if (!IsUpdateProcessRunning())
         {
-            await Task.Delay(PollInterval).ConfigureAwait(false);
-
-            if (IsUpdateProcessRunning())
-            {
-                // CimianWatcher defers flag consumption while a run is in
-                // flight (a second launch would just lose the instance lock).
-                // The flag file is our queued request, not a dead service —
-                // don't count this wait against the timeout.
-                elapsed = TimeSpan.Zero;
-                continue;
-            }
-
-            elapsed += PollInterval;
+            _logger?.LogError("CimianWatcher refused the request: {Message}", response.Message);
+            throw new InvalidOperationException($"CimianWatcher refused the request: {response.Message}");
         }
 
-        if (File.Exists(BootstrapFlagFile))
+        _logger?.LogInformation("An update is already running; asking again once it finishes");
+        await WaitForUpdateProcessExitAsync().ConfigureAwait(false);
+        if (_stopRequested)
         {
-            _logger?.LogError("CimianWatcher did not consume flag file within {Timeout}s — is the service running?",
-                ServiceTimeout.TotalSeconds);
-            try { File.Delete(BootstrapFlagFile); } catch { }
-            throw new InvalidOperationException(
-                "CimianWatcher service did not respond. Ensure the service is running: sc query CimianWatcher");
+            _logger?.LogInformation("Stop requested while waiting; request not sent");
+            return false;
         }
+        return true;
+    }
 
-        _logger?.LogInformation("CimianWatcher consumed flag file — managedsoftwareupdate is running");
+    private static async Task WaitForUpdateProcessExitAsync()
+    {
+        while (IsUpdateProcessRunning())
+        {
+            await Task.Delay(PollInterval).ConfigureAwait(false);
+        }
     }
 
     private static bool IsUpdateProcessRunning()
@@ -392,26 +394,32 @@ public class TriggerService : ITriggerService, IDisposable
         }
     }
 
+    /// <summary>Resets the pending batch; the caller holds <see cref="_batchLock"/>.</summary>
+    private void ClearBatchState()
+    {
+        _pendingItems.Clear();
+        _pendingItemOrder.Clear();
+        _pendingRemovals.Clear();
+        _currentOperationLabel = null;
+        _currentBatch = null;
+    }
+
     private async Task ClearBatchStateAsync()
     {
-        await _flagFileLock.WaitAsync().ConfigureAwait(false);
+        await _batchLock.WaitAsync().ConfigureAwait(false);
         try
         {
-            _pendingItems.Clear();
-            _pendingItemOrder.Clear();
-            _pendingRemovals.Clear();
-            _currentOperationLabel = null;
-            _currentBatch = null;
+            ClearBatchState();
         }
         finally
         {
-            _flagFileLock.Release();
+            _batchLock.Release();
         }
     }
 
     public void Dispose()
     {
-        _flagFileLock.Dispose();
+        _batchLock.Dispose();
         GC.SuppressFinalize(this);
     }
 }
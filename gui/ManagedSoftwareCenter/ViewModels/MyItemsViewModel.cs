@@ -212,8 +212,20 @@ public partial class MyItemsViewModel : ObservableObject
     {
         if (!HasPendingActions) return;
 
-        // Trigger install/removal of pending items
-        await _triggerService.TriggerInstallAsync();
+        // One targeted run over the requested installs and removals
+        var targets = Items
+            .Where(x => x.Status is ItemStatus.InstallRequested or ItemStatus.RemovalRequested)
+            .Select(x => x.Name)
+            .Where(n => !string.IsNullOrWhiteSpace(n))
+            .Distinct(StringComparer.OrdinalIgnoreCase)
+            .ToList();
+        var removals = Items
+            .Where(x => x.Status == ItemStatus.RemovalRequested)
+            .Select(x => x.Name)
+            .ToList();
+        if (targets.Count == 0) return;
+
+        await _triggerService.TriggerInstallItemsAsync(targets, removals);
     }
 
     private async void OnInstallInfoChanged(object? sender, InstallInfo info)
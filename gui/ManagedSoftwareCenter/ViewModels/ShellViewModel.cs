@@ -218,9 +218,9 @@ public partial class ShellViewModel : ObservableObject
     [RelayCommand]
     private async Task StopInstallAsync()
     {
-        // Cancel covers both phases: delete a queued (not yet consumed) flag
-        // file, and tell a running managedsoftwareupdate to stop gracefully
-        // before its next item.
+        // Cancel covers both phases: drop a request still waiting for the
+        // current run to finish, and tell a running managedsoftwareupdate to
+        // stop gracefully before its next item.
         await _triggerService.TriggerStopAsync();
         await _progressClient.SendCommandAsync(new CommandMessage { Type = CommandType.Stop });
 
@@ -408,6 +408,14 @@ public partial class ShellViewModel : ObservableObject
             case ProgressMessageType.RestartRequired:
                 _notificationService.ShowRestartRequired();
                 break;
+
+            case ProgressMessageType.ReleaseNotes:
+                if (!string.IsNullOrEmpty(message.ItemName) && !string.IsNullOrWhiteSpace(message.Detail))
+                {
+                    _notificationService.ShowWhatsNew(message.ItemName, message.Message, message.Detail);
+                }
+                // Not progress text: keep it out of the banner below
+                return;
                 
             case ProgressMessageType.LogoutRequired:
                 _notificationService.ShowLogoutRequired();
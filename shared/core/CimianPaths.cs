@@ -24,17 +24,38 @@ public static class CimianPaths
     public static readonly string ConfigYaml             = Path.Combine(ManagedInstallsRoot, "Config.yaml");
     public static readonly string SelfServeManifestYaml  = Path.Combine(ManagedInstallsRoot, "SelfServeManifest.yaml");
     public static readonly string InstallInfoYaml        = Path.Combine(ManagedInstallsRoot, "InstallInfo.yaml");
+    public static readonly string LastRunStatusJson      = Path.Combine(ManagedInstallsRoot, "status.json");
+    public static readonly string DeferralsJson          = Path.Combine(ManagedInstallsRoot, "deferrals.json");
+    public static readonly string DeferralRequestsJson   = Path.Combine(ManagedInstallsRoot, "deferral_requests.json");
+    public static readonly string RestartStateJson       = Path.Combine(ManagedInstallsRoot, "restart.json");
+    public static readonly string BlockingAppsJson       = Path.Combine(ManagedInstallsRoot, "blocking_apps.json");
+    public static readonly string CatalogOverrideJson    = Path.Combine(ManagedInstallsRoot, "catalog_override.json");
+    public static readonly string InstalledItemsJson     = Path.Combine(ManagedInstallsRoot, "installed_items.json");
+    public static readonly string UnmanagedItemsJson     = Path.Combine(ManagedInstallsRoot, "unmanaged_items.json");
+    public static readonly string ManagedProfilesJson    = Path.Combine(ManagedInstallsRoot, "managed_profiles.json");
+    public static readonly string ConfigurationItemsJson = Path.Combine(ManagedInstallsRoot, "configuration_items.json");
+    public static readonly string ComplianceJson         = Path.Combine(ManagedInstallsRoot, "compliance.json");
+    public static readonly string AgentBaselineJson      = Path.Combine(ManagedInstallsRoot, "agent_baseline.json");
+    public static readonly string AutoRunScheduleJson    = Path.Combine(ManagedInstallsRoot, "autorun_schedule.json");
+    public static readonly string BootstrapProgressJson  = Path.Combine(ManagedInstallsRoot, "bootstrap_progress.json");
+    public static readonly string SelfUpdateHealthJson   = Path.Combine(ManagedInstallsRoot, "selfupdate_health.json");
 
     // ── Subdirectories under ManagedInstallsRoot ─────────────────────────────
     public static readonly string CacheDir       = Path.Combine(ManagedInstallsRoot, "Cache");
     public static readonly string CatalogsDir    = Path.Combine(ManagedInstallsRoot, "catalogs");
     public static readonly string ManifestsDir   = Path.Combine(ManagedInstallsRoot, "manifests");
+    public static readonly string ProfilesDir    = Path.Combine(ManagedInstallsRoot, "profiles");
+    public static readonly string ScriptsDir     = Path.Combine(ManagedInstallsRoot, "scripts");
     public static readonly string LogsDir        = Path.Combine(ManagedInstallsRoot, "logs");
     public static readonly string ReportsDir     = Path.Combine(ManagedInstallsRoot, "reports");
     public static readonly string ConditionsDir  = Path.Combine(ManagedInstallsRoot, "conditions");
     public static readonly string ReceiptsDir    = Path.Combine(ManagedInstallsRoot, "Receipts");
     public static readonly string SbinDir        = Path.Combine(ManagedInstallsRoot, "sbin");
     public static readonly string SelfUpdateBackupDir = Path.Combine(ManagedInstallsRoot, "SelfUpdateBackup");
+    public static readonly string RollbackDir    = Path.Combine(ManagedInstallsRoot, "Rollback");
+    public static readonly string IconsDir       = Path.Combine(ManagedInstallsRoot, "icons");
+    public static readonly string ReportQueueDir = Path.Combine(ManagedInstallsRoot, "report_queue");
+    public static readonly string NupkgLibDir    = Path.Combine(ManagedInstallsRoot, "lib");
 
     // ── Script hooks (sbin) ──────────────────────────────────────────────────
     public static readonly string PreflightScript  = Path.Combine(SbinDir, "preflight.ps1");
@@ -44,9 +65,18 @@ public static class CimianPaths
     public static readonly string BootstrapFlagFile  = Path.Combine(ManagedInstallsRoot, ".cimian.bootstrap");
     public static readonly string HeadlessFlagFile   = Path.Combine(ManagedInstallsRoot, ".cimian.headless");
     public static readonly string SelfUpdateFlagFile = Path.Combine(ManagedInstallsRoot, ".cimian.selfupdate");
+    public static readonly string RestartDeferredFlagFile = Path.Combine(ManagedInstallsRoot, ".cimian.restartdeferred");
+    public static readonly string NonPersistentFlagFile = Path.Combine(ManagedInstallsRoot, ".cimian.nonpersistent");
 
     // ── Specific log files ───────────────────────────────────────────────────
     public static readonly string CimiwatcherLog = Path.Combine(LogsDir, "cimiwatcher.log");
+    public static readonly string CatalogOverrideAuditLog = Path.Combine(LogsDir, "catalog_override.log");
+    public static readonly string RunBrokerAuditLog = Path.Combine(LogsDir, "run_broker.log");
+    public static readonly string CrashDumpsDir = Path.Combine(LogsDir, "crashdumps");
+
+    // ── Reports written outside a run ────────────────────────────────────────
+    public static readonly string WatchdogJson = Path.Combine(ReportsDir, "watchdog.json");
+    public static readonly string MaintenanceHoldJson = Path.Combine(ReportsDir, "maintenance_hold.json");
 
     // ── Installed Cimian binaries / scripts (under %ProgramFiles%\Cimian) ────
     public static readonly string ManagedSoftwareUpdateExe = Path.Combine(CimianInstallDir, "managedsoftwareupdate.exe");
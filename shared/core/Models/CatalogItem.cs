@@ -179,6 +179,12 @@ public class CatalogItem
     [YamlMember(Alias = "restart_action")]
     public string? RestartAction { get; set; }
 
+    /// <summary>
+    /// Shorthand for restart_action: RequireRestart
+    /// </summary>
+    [YamlMember(Alias = "requires_restart")]
+    public bool RequiresRestart { get; set; }
+
     /// <summary>
     /// Script that returns the installed version on stdout.
     /// Used as an alternative to registry/file-based version detection.
@@ -0,0 +1,35 @@
+// InstallContext.cs - Values of the pkginfo install_context key
+
+namespace Cimian.Core.Models;
+
+/// <summary>
+/// Which account an item's installer runs as.
+/// </summary>
+public static class InstallContext
+{
+    /// <summary>SYSTEM, machine-wide (default).</summary>
+    public const string System = "system";
+
+    /// <summary>The user logged on at the console, for per-user MSIX and AppData installers.</summary>
+    public const string User = "user";
+
+    public static readonly IReadOnlyList<string> All = [System, User];
+
+    /// <summary>
+    /// Canonical form of an install_context value. Unset means system;
+    /// anything unrecognized returns null so callers can warn.
+    /// </summary>
+    public static string? Normalize(string? value)
+    {
+        if (string.IsNullOrWhiteSpace(value))
+        {
+            return System;
+        }
+        return value.Trim().ToLowerInvariant() switch
+        {
+            "system" or "machine" => System,
+            "user" => User,
+            _ => null
+        };
+    }
+}
@@ -36,6 +36,10 @@ public class InstallInfoFile
     [YamlMember(Alias = "featured_items")]
     public List<string> FeaturedItems { get; set; } = [];
 
+    /// <summary>Configuration items, reported by compliance rather than install state.</summary>
+    [YamlMember(Alias = "configuration_items")]
+    public List<InstallInfoConfigurationItem> ConfigurationItems { get; set; } = [];
+
     [YamlMember(Alias = "last_check")]
     public DateTime LastCheck { get; set; }
 }
@@ -109,6 +113,38 @@ public class InstallInfoItem
     public bool Precached { get; set; }
 }
 
+/// <summary>
+/// A configuration item (installer type: configuration) and whether its last
+/// test found the machine compliant.
+/// </summary>
+public class InstallInfoConfigurationItem
+{
+    [YamlMember(Alias = "name")]
+    public string Name { get; set; } = string.Empty;
+
+    [YamlMember(Alias = "display_name")]
+    public string? DisplayName { get; set; }
+
+    [YamlMember(Alias = "version")]
+    public string? Version { get; set; }
+
+    /// <summary>script or dsc.</summary>
+    [YamlMember(Alias = "method")]
+    public string Method { get; set; } = string.Empty;
+
+    [YamlMember(Alias = "compliant")]
+    public bool Compliant { get; set; }
+
+    [YamlMember(Alias = "evaluated_at")]
+    public DateTime? EvaluatedAt { get; set; }
+
+    [YamlMember(Alias = "remediated_at")]
+    public DateTime? RemediatedAt { get; set; }
+
+    [YamlMember(Alias = "error")]
+    public string? Error { get; set; }
+}
+
 /// <summary>
 /// An item that encountered installation problems.
 /// </summary>
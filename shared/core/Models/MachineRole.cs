@@ -0,0 +1,90 @@
+// MachineRole.cs - Values of the Config.yaml MachineRole key and their defaults
+
+namespace Cimian.Core.Models;
+
+/// <summary>
+/// What kind of machine Cimian is managing. Each role carries a bundle of
+/// Config.yaml defaults (notifications, status window, restart policy, how
+/// eagerly to pick up repo changes) so sites set one key instead of the same
+/// dozen flags. Anything Config.yaml sets explicitly still wins.
+/// </summary>
+public static class MachineRole
+{
+    /// <summary>A user's own PC; the built-in defaults (default).</summary>
+    public const string Workstation = "workstation";
+
+    /// <summary>Single-app or signage device with nobody to prompt.</summary>
+    public const string Kiosk = "kiosk";
+
+    /// <summary>No interactive user; restarts are the administrator's call.</summary>
+    public const string Server = "server";
+
+    /// <summary>Shared classroom or lab PC that should converge quickly between sessions.</summary>
+    public const string Lab = "lab";
+
+    public static readonly IReadOnlyList<string> All = [Workstation, Kiosk, Server, Lab];
+
+    private static readonly Dictionary<string, IReadOnlyDictionary<string, object>> RoleDefaults = new()
+    {
+        [Workstation] = new Dictionary<string, object>(),
+        [Kiosk] = new Dictionary<string, object>
+        {
+            ["ShowNotifications"] = false,
+            ["ShowStatusWindow"] = false,
+            ["ShowTrayIcon"] = false,
+            ["RespectFocusAssist"] = false,
+            ["RestartPolicy"] = RestartPolicy.Immediate,
+            ["SkipSelfService"] = true
+        },
+        [Server] = new Dictionary<string, object>
+        {
+            ["ShowNotifications"] = false,
+            ["ShowStatusWindow"] = false,
+            ["ShowTrayIcon"] = false,
+            ["RespectFocusAssist"] = false,
+            ["RestartPolicy"] = RestartPolicy.Never,
+            ["RepoChangeWatch"] = false
+        },
+        [Lab] = new Dictionary<string, object>
+        {
+            ["RespectFocusAssist"] = false,
+            ["RestartPolicy"] = RestartPolicy.Immediate,
+            ["RepoChangeWatch"] = true,
+            ["RepoChangeWatchInterval"] = 120
+        }
+    };
+
+    /// <summary>
+    /// Canonical form of a MachineRole value. Unset means workstation;
+    /// anything unrecognized returns null.
+    /// </summary>
+    public static string? Normalize(string? value)
+    {
+        if (string.IsNullOrWhiteSpace(value))
+        {
+            return Workstation;
+        }
+        var lowered = value.Trim().ToLowerInvariant();
+        return All.Contains(lowered) ? lowered : null;
+    }
+
+    /// <summary>
+    /// Config.yaml key to value for everything the role changes from the
+    /// built-in defaults. Unknown roles change nothing.
+    /// </summary>
+    public static IReadOnlyDictionary<string, object> Defaults(string? role) =>
+        Normalize(role) is { } known ? RoleDefaults[known] : RoleDefaults[Workstation];
+
+    /// <summary>
+    /// For components that read a subset of Config.yaml: the explicit value,
+    /// else the role's default, else <paramref name="fallback"/>.
+    /// </summary>
+    public static bool ResolveBool(string? role, string key, bool? explicitValue, bool fallback)
+    {
+        if (explicitValue is bool value)
+        {
+            return value;
+        }
+        return Defaults(role).TryGetValue(key, out var roleValue) && roleValue is bool b ? b : fallback;
+    }
+}
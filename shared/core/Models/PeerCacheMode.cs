@@ -0,0 +1,36 @@
+// PeerCacheMode.cs - Values of the Config.yaml PeerCache.Mode key
+
+namespace Cimian.Core.Models;
+
+/// <summary>
+/// Where managedsoftwareupdate looks for installer payloads before the repo.
+/// Whatever a peer serves is checked against the catalog hash; the repo is
+/// used when no peer has the content.
+/// </summary>
+public static class PeerCacheMode
+{
+    /// <summary>Installers come straight from the repo (default).</summary>
+    public const string Off = "off";
+
+    /// <summary>Windows Delivery Optimization fetches the repo URL, sharing pieces with LAN peers.</summary>
+    public const string DeliveryOptimization = "deliveryoptimization";
+
+    /// <summary>The PeerCache.Peers servers are asked for the same repo path first.</summary>
+    public const string Peers = "peers";
+
+    public static readonly IReadOnlyList<string> All = [Off, DeliveryOptimization, Peers];
+
+    /// <summary>
+    /// Canonical form of a PeerCache.Mode value. Unset means off;
+    /// anything unrecognized returns null.
+    /// </summary>
+    public static string? Normalize(string? value)
+    {
+        if (string.IsNullOrWhiteSpace(value))
+        {
+            return Off;
+        }
+        var lowered = value.Trim().ToLowerInvariant();
+        return All.Contains(lowered) ? lowered : null;
+    }
+}
@@ -0,0 +1,35 @@
+// RepoBackend.cs - Values of the Config.yaml RepoBackend key
+
+namespace Cimian.Core.Models;
+
+/// <summary>
+/// How managedsoftwareupdate authenticates to the storage behind SoftwareRepoURL.
+/// The repo layout (manifests/, catalogs/, pkgs/, icons/) is the same for all of them.
+/// </summary>
+public static class RepoBackend
+{
+    /// <summary>Any web server; AuthUser/AuthPassword, AuthToken or a client certificate (default).</summary>
+    public const string Http = "http";
+
+    /// <summary>An S3 (or S3-compatible) bucket; requests are SigV4-signed with AWS SDK credentials.</summary>
+    public const string S3 = "s3";
+
+    /// <summary>An Azure Blob Storage container; managed identity / Azure SDK credentials, or a SAS token.</summary>
+    public const string AzureBlob = "azblob";
+
+    public static readonly IReadOnlyList<string> All = [Http, S3, AzureBlob];
+
+    /// <summary>
+    /// Canonical form of a RepoBackend value. Unset means http;
+    /// anything unrecognized returns null.
+    /// </summary>
+    public static string? Normalize(string? value)
+    {
+        if (string.IsNullOrWhiteSpace(value))
+        {
+            return Http;
+        }
+        var lowered = value.Trim().ToLowerInvariant();
+        return All.Contains(lowered) ? lowered : null;
+    }
+}
@@ -0,0 +1,25 @@
+// ReportFormat.cs - Values of the Config.yaml ReportFormats key
+
+namespace Cimian.Core.Models;
+
+/// <summary>
+/// Extra report formats written to the reports directory after each run, next
+/// to Cimian's own sessions.json / items.json / events.json.
+/// </summary>
+public static class ReportFormat
+{
+    /// <summary>ManagedInstallReport.plist, the Munki report MunkiReport's modules read.</summary>
+    public const string MunkiReport = "munkireport";
+
+    /// <summary>cimian_osquery.json, flat rows per table for osquery's json_each / ATC.</summary>
+    public const string Osquery = "osquery";
+
+    public static readonly IReadOnlyList<string> All = [MunkiReport, Osquery];
+
+    /// <summary>Canonical form of a ReportFormats entry, or null when unrecognized.</summary>
+    public static string? Normalize(string? value)
+    {
+        var lowered = value?.Trim().ToLowerInvariant();
+        return lowered != null && All.Contains(lowered) ? lowered : null;
+    }
+}
@@ -74,6 +74,25 @@ public class SessionRecord
     [JsonPropertyName("log_version")]
     public string LogVersion { get; set; } = string.Empty;
 
+    /// <summary>
+    /// "persistent" or "non_persistent" (VDI clone, write-filtered image)
+    /// </summary>
+    [JsonPropertyName("persistence")]
+    [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public string? Persistence { get; set; }
+
+    /// <summary>
+    /// Whether a restart was outstanding when the run ended, and why
+    /// (cimian_items, cbs_reboot_pending, windows_update, pending_file_rename, configmgr)
+    /// </summary>
+    [JsonPropertyName("pending_reboot")]
+    [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingDefault)]
+    public bool PendingReboot { get; set; }
+
+    [JsonPropertyName("pending_reboot_reasons")]
+    [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public List<string>? PendingRebootReasons { get; set; }
+
     [JsonPropertyName("packages_handled")]
     [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
     public List<string>? PackagesHandled { get; set; }
@@ -733,11 +752,147 @@ public class LoopSuppressedReportItem
     [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
     public DateTime? SuppressedUntil { get; set; }
 
+    /// <summary>
+    /// True when the item is quarantined after repeated failures rather than in a
+    /// backoff window; it stays skipped until a new version appears or it is cleared.
+    /// </summary>
+    [JsonPropertyName("quarantined")]
+    public bool Quarantined { get; set; }
+
     /// <summary>Operator-actionable command string (matches LoopGuard's WARN log line).</summary>
     [JsonPropertyName("clear_command")]
     public string ClearCommand { get; set; } = string.Empty;
 }
 
+/// <summary>
+/// Usage data for reports/usage.json: what is installed on the machine and how
+/// this run's downloads went. Collected from many machines it answers "which
+/// packages are actually deployed where" (see <c>repoclean --usage-reports</c>).
+/// </summary>
+public class UsageReport
+{
+    /// <summary>Client identifier or hostname, or an "anon-" hash of it when AnonymousUsageReports is set.</summary>
+    [JsonPropertyName("machine")]
+    public string Machine { get; set; } = string.Empty;
+
+    [JsonPropertyName("anonymous")]
+    public bool Anonymous { get; set; }
+
+    [JsonPropertyName("session_id")]
+    public string SessionId { get; set; } = string.Empty;
+
+    [JsonPropertyName("run_type")]
+    public string RunType { get; set; } = string.Empty;
+
+    [JsonPropertyName("generated_at")]
+    public DateTime GeneratedAt { get; set; }
+
+    /// <summary>Everything Cimian has installed on the machine, not just this run's installs.</summary>
+    [JsonPropertyName("installed")]
+    public List<UsageInstalledItem> Installed { get; set; } = new();
+
+    [JsonPropertyName("downloads")]
+    public List<UsageDownload> Downloads { get; set; } = new();
+
+    [JsonPropertyName("cache_hits")]
+    public int CacheHits => Downloads.Count(d => d.CacheHit);
+
+    [JsonPropertyName("cache_misses")]
+    public int CacheMisses => Downloads.Count(d => !d.CacheHit);
+
+    /// <summary>Share of installers served from the local cache, 0 when nothing was fetched.</summary>
+    [JsonPropertyName("cache_hit_rate")]
+    public double CacheHitRate => Downloads.Count == 0 ? 0 : Math.Round((double)CacheHits / Downloads.Count, 3);
+}
+
+public class UsageInstalledItem
+{
+    [JsonPropertyName("name")]
+    public string Name { get; set; } = string.Empty;
+
+    [JsonPropertyName("version")]
+    public string Version { get; set; } = string.Empty;
+}
+
+/// <summary>
+/// One installer fetch: from the cache, or from <see cref="Source"/> (the URL's
+/// origin, never the full path).
+/// </summary>
+public class UsageDownload
+{
+    [JsonPropertyName("item")]
+    public string Item { get; set; } = string.Empty;
+
+    [JsonPropertyName("version")]
+    public string Version { get; set; } = string.Empty;
+
+    /// <summary>"https://cdn.example.com", "cache" for a cache hit, or "deliveryoptimization" / "peer http://cache01:8080" when PeerCache served it.</summary>
+    [JsonPropertyName("source")]
+    public string Source { get; set; } = string.Empty;
+
+    [JsonPropertyName("cache_hit")]
+    public bool CacheHit { get; set; }
+
+    [JsonPropertyName("success")]
+    public bool Success { get; set; }
+
+    [JsonPropertyName("duration_ms")]
+    public long DurationMs { get; set; }
+
+    [JsonPropertyName("bytes")]
+    public long Bytes { get; set; }
+}
+
+/// <summary>
+/// usage.json files from many machines rolled up: which item versions are
+/// deployed on which machines, and how downloads are being served.
+/// </summary>
+public class UsageSummary
+{
+    [JsonPropertyName("machines")]
+    public int Machines { get; set; }
+
+    [JsonPropertyName("items")]
+    public List<UsageItemDeployment> Items { get; set; } = new();
+
+    [JsonPropertyName("sources")]
+    public List<UsageSourceStats> Sources { get; set; } = new();
+
+    [JsonPropertyName("cache_hit_rate")]
+    public double CacheHitRate { get; set; }
+}
+
+public class UsageItemDeployment
+{
+    [JsonPropertyName("name")]
+    public string Name { get; set; } = string.Empty;
+
+    [JsonPropertyName("machine_count")]
+    public int MachineCount { get; set; }
+
+    /// <summary>Version → machines that have it.</summary>
+    [JsonPropertyName("versions")]
+    public SortedDictionary<string, List<string>> Versions { get; set; } = new();
+}
+
+public class UsageSourceStats
+{
+    [JsonPropertyName("source")]
+    public string Source { get; set; } = string.Empty;
+
+    [JsonPropertyName("downloads")]
+    public int Downloads { get; set; }
+
+    [JsonPropertyName("failures")]
+    public int Failures { get; set; }
+
+    [JsonPropertyName("bytes")]
+    public long Bytes { get; set; }
+
+    [JsonPropertyName("average_duration_ms")]
+    public long AverageDurationMs { get; set; }
+}
+
 /// <summary>
 /// Pure helper that resolves the per-item session status reported in items.json.
 /// Prefers the actual install/uninstall outcome over the pre-install plan so a
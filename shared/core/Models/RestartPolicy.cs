@@ -0,0 +1,51 @@
+// RestartPolicy.cs - Values of the Config.yaml RestartPolicy key
+
+namespace Cimian.Core.Models;
+
+/// <summary>
+/// What an auto or bootstrap run does when an item needs a restart.
+/// Interactive runs only ever recommend one.
+/// </summary>
+public static class RestartPolicy
+{
+    /// <summary>Schedule the restart with a five-minute warning (default).</summary>
+    public const string Countdown = "countdown";
+
+    /// <summary>Restart with a short warning; for machines nobody sits at.</summary>
+    public const string Immediate = "immediate";
+
+    /// <summary>Don't schedule anything; CimianStatus asks the user to restart.</summary>
+    public const string Prompt = "prompt";
+
+    /// <summary>Never restart; report it and leave it to the administrator.</summary>
+    public const string Never = "never";
+
+    public static readonly IReadOnlyList<string> All = [Countdown, Immediate, Prompt, Never];
+
+    /// <summary>
+    /// Canonical form of a RestartPolicy value. Unset means countdown;
+    /// anything unrecognized returns null.
+    /// </summary>
+    public static string? Normalize(string? value)
+    {
+        if (string.IsNullOrWhiteSpace(value))
+        {
+            return Countdown;
+        }
+        var lowered = value.Trim().ToLowerInvariant();
+        return All.Contains(lowered) ? lowered : null;
+    }
+
+    /// <summary>
+    /// Seconds of warning shutdown.exe gives before restarting: RestartGracePeriodMinutes
+    /// when set, otherwise one minute for immediate and five for countdown.
+    /// </summary>
+    public static int GraceSeconds(string? policy, int configuredMinutes = 0)
+    {
+        if (configuredMinutes > 0)
+        {
+            return configuredMinutes * 60;
+        }
+        return Normalize(policy) == Immediate ? 60 : 300;
+    }
+}
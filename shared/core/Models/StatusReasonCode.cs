@@ -41,6 +41,9 @@ public static class StatusReasonCode
     /// <summary>Running version is same or newer than catalog</summary>
     public const string SelfUpdateCurrent = "self_update_current";
 
+    /// <summary>Configuration item's test (script or DSC) found the machine compliant</summary>
+    public const string ConfigurationCompliant = "configuration_compliant";
+
     #endregion
 
     #region Pending Reasons - Package needs installation/update
@@ -96,6 +99,9 @@ public static class StatusReasonCode
     /// <summary>Insufficient disk space for installation</summary>
     public const string DiskSpace = "disk_space";
 
+    /// <summary>Running on battery below MinimumBatteryPercent</summary>
+    public const string LowBattery = "low_battery";
+
     /// <summary>Network is metered - large download deferred</summary>
     public const string NetworkMetered = "network_metered";
 
@@ -111,6 +117,9 @@ public static class StatusReasonCode
     /// <summary>OnDemand item — never tracked as installed; always (re)installed each run</summary>
     public const string OnDemand = "on_demand";
 
+    /// <summary>Configuration item's test found drift; its set script or DSC Set will remediate</summary>
+    public const string ConfigurationNoncompliant = "configuration_noncompliant";
+
     /// <summary>Architecture not supported on this system</summary>
     public const string ArchitectureMismatch = "architecture_mismatch";
 
@@ -138,6 +147,9 @@ public static class StatusReasonCode
     /// <summary>Auto run deferred this item because a user is active — either it is not unattended-eligible, or its restart_action would interrupt the session</summary>
     public const string DeferredUserActive = "deferred_user_active";
 
+    /// <summary>install_context: user item deferred because nobody is logged on to install it for</summary>
+    public const string NoActiveUser = "no_active_user";
+
     /// <summary>Package queued for removal: no tracked executable used within unused_software_removal_info.removal_days</summary>
     public const string StaleUsageUninstall = "stale_usage_uninstall";
 
@@ -147,6 +159,18 @@ public static class StatusReasonCode
     /// <summary>Stale-usage check skipped: device has fewer days of usage history than the required minimum_history_days</summary>
     public const string StaleUsageSkippedInsufficientHistory = "stale_usage_skipped_insufficient_history";
 
+    /// <summary>Installed by Cimian but in no manifest; removal waits for UnmanagedItemGraceDays</summary>
+    public const string UnmanagedGracePeriod = "unmanaged_grace_period";
+
+    /// <summary>Package queued for removal: out of every manifest for longer than UnmanagedItemGraceDays</summary>
+    public const string UnmanagedRemoval = "unmanaged_removal";
+
+    /// <summary>Package queued for removal: installed only as a dependency, and nothing installed requires it any more</summary>
+    public const string OrphanedDependency = "orphaned_dependency";
+
+    /// <summary>Removal refused: installed items require it (DependentRemovalPolicy block)</summary>
+    public const string RemovalBlockedByDependents = "removal_blocked_by_dependents";
+
     #endregion
 
     #region Removed Reasons - Package confirmed removed
@@ -176,6 +200,15 @@ public static class StatusReasonCode
     /// <summary>Detection script encountered an error</summary>
     public const string ScriptError = "script_error";
 
+    /// <summary>Installer location is outside AllowedDownloadOrigins - download refused</summary>
+    public const string OriginNotAllowed = "origin_not_allowed";
+
+    /// <summary>Catalog entry has no hash to check a Cimian self-update package against - not scheduled</summary>
+    public const string HashMissing = "hash_missing";
+
+    /// <summary>Package signature didn't verify or isn't from the expected publisher - not scheduled</summary>
+    public const string SignatureInvalid = "signature_invalid";
+
     /// <summary>Unable to determine status</summary>
     public const string Unknown = "unknown";
 
@@ -202,6 +235,9 @@ public static class DetectionMethod
     /// <summary>PowerShell/script-based detection</summary>
     public const string Script = "script";
 
+    /// <summary>DSC resource Test method (Invoke-DscResource)</summary>
+    public const string Dsc = "dsc";
+
     /// <summary>MSI product code detection</summary>
     public const string Msi = "msi";
 
@@ -48,7 +48,7 @@ public class SystemFacts
 
     /// <summary>
     /// OS build version number
-    /// Maps to 'os_build_number' fact key
+    /// Maps to 'os_build_number' / 'os_build' fact keys
     /// </summary>
     public int OSBuildNumber { get; set; }
 
@@ -106,6 +106,24 @@ public class SystemFacts
     /// </summary>
     public string BatteryState { get; set; } = string.Empty;
 
+    /// <summary>
+    /// Whether Win32_Battery reports a battery at all
+    /// Maps to 'has_battery' fact key
+    /// </summary>
+    public bool HasBattery { get; set; }
+
+    /// <summary>
+    /// SMBIOS chassis type name (e.g., "notebook", "convertible", "tower", "rack_mount")
+    /// Maps to 'chassis_type' fact key
+    /// </summary>
+    public string ChassisType { get; set; } = string.Empty;
+
+    /// <summary>
+    /// BIOS serial number
+    /// Maps to 'serial_number' fact key
+    /// </summary>
+    public string SerialNumber { get; set; } = string.Empty;
+
     /// <summary>
     /// Current date in YYYY-MM-DD format
     /// Maps to 'date' fact key
@@ -256,6 +274,12 @@ public class SystemFacts
     /// </summary>
     public long StorageCapacityGb { get; set; }
 
+    /// <summary>
+    /// Free space on the system drive in GB
+    /// Maps to 'free_disk_gb' fact key
+    /// </summary>
+    public long FreeDiskGb { get; set; }
+
     /// <summary>
     /// Additional custom facts that can be added by plugins or extensions
     /// </summary>
@@ -276,7 +300,7 @@ public class SystemFacts
             "os_version" => OperatingSystemVersion,
             "os_vers_major" => OSVersMajor,
             "os_vers_minor" => OSVersMinor,
-            "os_build_number" => OSBuildNumber,
+            "os_build_number" or "os_build" => OSBuildNumber,
             "domain" => Domain,
             "username" => Username,
             "machine_type" => MachineType,
@@ -284,6 +308,9 @@ public class SystemFacts
             "model_version" => ModelVersion,
             "joined_type" => JoinedType,
             "battery_state" => BatteryState,
+            "has_battery" => HasBattery,
+            "chassis_type" => ChassisType,
+            "serial_number" => SerialNumber,
             "date" => Date,
             "catalogs" => Catalogs,
             
@@ -309,6 +336,7 @@ public class SystemFacts
             // Storage facts
             "storage_type" => StorageType,
             "storage_capacity_gb" => StorageCapacityGb,
+            "free_disk_gb" => FreeDiskGb,
             
             // Legacy mappings
             "operatingsystem" => OperatingSystem,
@@ -326,6 +354,43 @@ public class SystemFacts
         };
     }
 
+    /// <summary>
+    /// Inventory facts for reports/facts.json, keyed by their condition names.
+    /// Hostname and serial number are left out when <paramref name="includeIdentity"/>
+    /// is false so anonymous reporting doesn't name the machine.
+    /// </summary>
+    public Dictionary<string, object?> ToInventory(bool includeIdentity = true)
+    {
+        var inventory = new Dictionary<string, object?>
+        {
+            ["arch"] = Architecture,
+            ["os_version"] = OperatingSystemVersion,
+            ["os_build"] = OSBuildNumber,
+            ["machine_type"] = MachineType,
+            ["chassis_type"] = ChassisType,
+            ["machine_model"] = MachineModel,
+            ["model_version"] = ModelVersion,
+            ["joined_type"] = JoinedType,
+            ["domain"] = Domain,
+            ["isenrolled"] = IsEnrolled,
+            ["has_battery"] = HasBattery,
+            ["battery_state"] = BatteryState,
+            ["gpu_names"] = GpuNames,
+            ["cpu_name"] = CpuName,
+            ["ram_total_gb"] = RamTotalGb,
+            ["storage_type"] = StorageType,
+            ["storage_capacity_gb"] = StorageCapacityGb,
+            ["free_disk_gb"] = FreeDiskGb,
+            ["collected_at"] = CollectedAt
+        };
+        if (includeIdentity)
+        {
+            inventory["hostname"] = Hostname;
+            inventory["serial_number"] = SerialNumber;
+        }
+        return inventory;
+    }
+
     /// <summary>
     /// Checks if a software package is installed
     /// </summary>
@@ -0,0 +1,40 @@
+// UpgradeStrategy.cs - Values of the pkginfo upgrade_strategy key
+
+namespace Cimian.Core.Models;
+
+/// <summary>
+/// How a version change is applied when an item is already installed.
+/// </summary>
+public static class UpgradeStrategy
+{
+    /// <summary>Run the new installer over the old one and let it upgrade (default).</summary>
+    public const string InPlace = "in-place";
+
+    /// <summary>Remove the installed version first, then install the new one.</summary>
+    public const string UninstallFirst = "uninstall-first";
+
+    /// <summary>Install the new version alongside; Cimian never removes the old one.</summary>
+    public const string SideBySide = "side-by-side";
+
+    public static readonly IReadOnlyList<string> All = [InPlace, UninstallFirst, SideBySide];
+
+    /// <summary>
+    /// Canonical form of an upgrade_strategy value. Unset means in-place;
+    /// anything unrecognized returns null so callers can warn.
+    /// </summary>
+    public static string? Normalize(string? value)
+    {
+        if (string.IsNullOrWhiteSpace(value))
+        {
+            return InPlace;
+        }
+        var compact = value.Trim().ToLowerInvariant().Replace('_', '-');
+        return compact switch
+        {
+            "in-place" or "inplace" => InPlace,
+            "uninstall-first" => UninstallFirst,
+            "side-by-side" => SideBySide,
+            _ => null
+        };
+    }
+}
@@ -0,0 +1,47 @@
+// WebhookEvent.cs - Values of the Config.yaml Webhooks[].Events key
+
+namespace Cimian.Core.Models;
+
+/// <summary>
+/// Run results a webhook can be sent for.
+/// </summary>
+public static class WebhookEvent
+{
+    /// <summary>A run finished without failures.</summary>
+    public const string Completed = "completed";
+
+    /// <summary>A run finished with some items failed.</summary>
+    public const string PartialFailure = "partial_failure";
+
+    /// <summary>A run failed outright.</summary>
+    public const string Failed = "failed";
+
+    /// <summary>A Cimian self-update was scheduled for the next service restart.</summary>
+    public const string SelfUpdateScheduled = "self_update_scheduled";
+
+    /// <summary>A downloaded Cimian self-update failed its hash or signature check and wasn't scheduled.</summary>
+    public const string SelfUpdateRefused = "self_update_refused";
+
+    public static readonly IReadOnlyList<string> All = [Completed, PartialFailure, Failed, SelfUpdateScheduled, SelfUpdateRefused];
+
+    /// <summary>
+    /// Sent when a webhook lists no Events; successful runs are left out so an
+    /// hourly schedule doesn't flood the channel.
+    /// </summary>
+    public static readonly IReadOnlyList<string> Default = [PartialFailure, Failed, SelfUpdateScheduled, SelfUpdateRefused];
+
+    /// <summary>The event for a SessionLogger session status.</summary>
+    public static string ForSessionStatus(string status) => status switch
+    {
+        "completed" or "success" => Completed,
+        "partial_failure" => PartialFailure,
+        _ => Failed
+    };
+
+    /// <summary>Canonical form of an Events entry, or null when unrecognized.</summary>
+    public static string? Normalize(string? value)
+    {
+        var lowered = value?.Trim().ToLowerInvariant();
+        return lowered != null && All.Contains(lowered) ? lowered : null;
+    }
+}
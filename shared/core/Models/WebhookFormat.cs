@@ -0,0 +1,34 @@
+// WebhookFormat.cs - Values of the Config.yaml Webhooks[].Format key
+
+namespace Cimian.Core.Models;
+
+/// <summary>
+/// Body shape of a webhook notification.
+/// </summary>
+public static class WebhookFormat
+{
+    /// <summary>A JSON document with the event, host, run and failed items (default).</summary>
+    public const string Generic = "generic";
+
+    /// <summary>Slack incoming webhook: {"text": ...}.</summary>
+    public const string Slack = "slack";
+
+    /// <summary>Teams Workflows / incoming webhook: a message with an Adaptive Card.</summary>
+    public const string Teams = "teams";
+
+    public static readonly IReadOnlyList<string> All = [Generic, Slack, Teams];
+
+    /// <summary>
+    /// Canonical form of a Format value. Unset means generic; anything
+    /// unrecognized returns null.
+    /// </summary>
+    public static string? Normalize(string? value)
+    {
+        if (string.IsNullOrWhiteSpace(value))
+        {
+            return Generic;
+        }
+        var lowered = value.Trim().ToLowerInvariant();
+        return All.Contains(lowered) ? lowered : null;
+    }
+}
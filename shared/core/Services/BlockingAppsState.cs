@@ -0,0 +1,83 @@
+using System.Text.Json.Serialization;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// An install managedsoftwareupdate is holding until the user closes its
+/// blocking_applications (BlockingAppTimeout).
+/// </summary>
+public class BlockingAppsItem
+{
+    [JsonPropertyName("item")]
+    public string Item { get; set; } = "";
+
+    [JsonPropertyName("name")]
+    public string Name { get; set; } = "";
+
+    [JsonPropertyName("version")]
+    public string Version { get; set; } = "";
+
+    /// <summary>The blocking_applications entries still running.</summary>
+    [JsonPropertyName("applications")]
+    public List<string> Applications { get; set; } = new();
+
+    /// <summary>force_close_blocking_apps: the applications are ended at the deadline.</summary>
+    [JsonPropertyName("force_close")]
+    public bool ForceClose { get; set; }
+
+    /// <summary>"Close chrome.exe so Google Chrome 120.0 can be installed. ..."</summary>
+    public string Describe(DateTime waitUntil)
+    {
+        var apps = string.Join(", ", Applications.Select(a => Path.GetFileNameWithoutExtension(a)));
+        return ForceClose
+            ? $"Close {apps} so {Name} {Version} can be installed. Save your work: it will be closed at {waitUntil:t}."
+            : $"Close {apps} so {Name} {Version} can be installed. Cimian waits until {waitUntil:t}.";
+    }
+}
+
+/// <summary>
+/// The installs a run is waiting on, kept at <see cref="CimianPaths.BlockingAppsJson"/>
+/// while it waits so CimianStatus can ask the user to close the applications.
+/// </summary>
+public class BlockingAppsState
+{
+    [JsonPropertyName("recorded_at")]
+    public DateTime RecordedAt { get; set; }
+
+    /// <summary>When the run stops waiting and closes or skips what is still blocked.</summary>
+    [JsonPropertyName("wait_until")]
+    public DateTime WaitUntil { get; set; }
+
+    [JsonPropertyName("items")]
+    public List<BlockingAppsItem> Items { get; set; } = new();
+}
+
+public static class BlockingAppsStateStore
+{
+    public static void Write(BlockingAppsState state, string? path = null)
+    {
+        JsonStateFile.Write(path ?? CimianPaths.BlockingAppsJson, state);
+    }
+
+    /// <summary>
+    /// The installs being waited on, or null when there are none or the wait is
+    /// over (a run that died mid-wait leaves its file behind).
+    /// </summary>
+    public static BlockingAppsState? Read(DateTime now, string? path = null)
+    {
+        var state = JsonStateFile.TryRead<BlockingAppsState>(path ?? CimianPaths.BlockingAppsJson);
+        return state is { Items.Count: > 0 } && state.WaitUntil > now ? state : null;
+    }
+
+    public static void Clear(string? path = null)
+    {
+        try
+        {
+            File.Delete(path ?? CimianPaths.BlockingAppsJson);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Debug($"Could not remove blocking apps state: {ex.Message}");
+        }
+    }
+}
@@ -0,0 +1,152 @@
+using System.Text.Json.Serialization;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Where a bootstrap has got to: how many runs it has taken, what is still
+/// outstanding and whether it has finished. Kept at
+/// <see cref="CimianPaths.BootstrapProgressJson"/> so a restart mid-bootstrap
+/// carries on with the attempts it has left instead of starting over.
+/// </summary>
+public class BootstrapProgress
+{
+    public const string InProgress = "in_progress";
+    public const string Completed = "completed";
+    public const string Exhausted = "exhausted";
+
+    [JsonPropertyName("status")]
+    public string Status { get; set; } = InProgress;
+
+    [JsonPropertyName("started")]
+    public DateTime Started { get; set; }
+
+    [JsonPropertyName("attempts")]
+    public int Attempts { get; set; }
+
+    [JsonPropertyName("last_attempt")]
+    public DateTime? LastAttempt { get; set; }
+
+    [JsonPropertyName("last_result")]
+    public int? LastResult { get; set; }
+
+    [JsonPropertyName("next_attempt")]
+    public DateTime? NextAttempt { get; set; }
+
+    /// <summary>Items the last run failed to install or remove.</summary>
+    [JsonPropertyName("remaining")]
+    public List<string> Remaining { get; set; } = new();
+
+    /// <summary>Items installed or removed by any run of this bootstrap.</summary>
+    [JsonPropertyName("completed_items")]
+    public List<string> CompletedItems { get; set; } = new();
+
+    [JsonPropertyName("finished")]
+    public DateTime? Finished { get; set; }
+
+    [JsonIgnore]
+    public bool IsActive => Status == InProgress;
+}
+
+/// <summary>
+/// Starts, records and ends a bootstrap. managedsoftwareupdate --set-bootstrap-mode
+/// starts one; each bootstrap run records its attempt and outcome; the bootstrap
+/// ends when a run leaves nothing outstanding or BootstrapMaxAttempts runs
+/// have been made. While one is active CimianWatcher resumes it after a restart.
+/// </summary>
+public static class BootstrapProgressStore
+{
+    /// <summary>Longest wait between two attempts, however many have failed.</summary>
+    public static readonly TimeSpan MaxRetryDelay = TimeSpan.FromHours(1);
+
+    /// <summary>
+    /// The wait after the given failed attempt: <paramref name="baseDelay"/>,
+    /// doubled for each attempt before it, up to <see cref="MaxRetryDelay"/>.
+    /// </summary>
+    public static TimeSpan RetryDelay(int attempt, TimeSpan baseDelay)
+    {
+        if (baseDelay <= TimeSpan.Zero || attempt < 1)
+        {
+            return TimeSpan.Zero;
+        }
+        var factor = Math.Pow(2, Math.Min(attempt - 1, 16));
+        return TimeSpan.FromTicks((long)Math.Min(baseDelay.Ticks * factor, MaxRetryDelay.Ticks));
+    }
+
+    /// <summary>Starts a new bootstrap, replacing any earlier record.</summary>
+    public static BootstrapProgress Start(DateTime now, string? path = null)
+    {
+        var progress = new BootstrapProgress { Started = now };
+        Save(progress, path);
+        return progress;
+    }
+
+    /// <summary>The active bootstrap, started now when there isn't one.</summary>
+    public static BootstrapProgress Resume(DateTime now, string? path = null) =>
+        Read(path) is { IsActive: true } progress ? progress : Start(now, path);
+
+    /// <summary>Counts another run of the bootstrap. Saved before the run so a restart during it still counts.</summary>
+    public static void BeginAttempt(BootstrapProgress progress, DateTime now, string? path = null)
+    {
+        progress.Attempts++;
+        progress.LastAttempt = now;
+        progress.NextAttempt = null;
+        Save(progress, path);
+    }
+
+    /// <summary>
+    /// Records a run's outcome and works out what happens next: the bootstrap
+    /// completes when the run succeeded with nothing left over, is exhausted
+    /// after <paramref name="maxAttempts"/> runs, and otherwise stays active.
+    /// </summary>
+    public static void RecordAttempt(BootstrapProgress progress, int result, IEnumerable<string> failed,
+        IEnumerable<string> succeeded, int maxAttempts, DateTime now, string? path = null)
+    {
+        progress.LastResult = result;
+        progress.Remaining = failed.Distinct(StringComparer.OrdinalIgnoreCase).OrderBy(n => n, StringComparer.OrdinalIgnoreCase).ToList();
+        progress.CompletedItems = progress.CompletedItems
+            .Concat(succeeded)
+            .Where(n => !progress.Remaining.Contains(n, StringComparer.OrdinalIgnoreCase))
+            .Distinct(StringComparer.OrdinalIgnoreCase)
+            .OrderBy(n => n, StringComparer.OrdinalIgnoreCase)
+            .ToList();
+
+        if (result == 0 && progress.Remaining.Count == 0)
+        {
+            progress.Status = BootstrapProgress.Completed;
+            progress.Finished = now;
+        }
+        else if (progress.Attempts >= maxAttempts)
+        {
+            progress.Status = BootstrapProgress.Exhausted;
+            progress.Finished = now;
+        }
+        Save(progress, path);
+    }
+
+    /// <summary>The bootstrap record, or null when no bootstrap has been started.</summary>
+    public static BootstrapProgress? Read(string? path = null)
+    {
+        return JsonStateFile.TryRead<BootstrapProgress>(path ?? CimianPaths.BootstrapProgressJson);
+    }
+
+    /// <summary>Whether a bootstrap is under way and has attempts left.</summary>
+    public static bool IsActive(string? path = null) => Read(path)?.IsActive == true;
+
+    public static void Save(BootstrapProgress progress, string? path = null)
+    {
+        JsonStateFile.Write(path ?? CimianPaths.BootstrapProgressJson, progress);
+    }
+
+    /// <summary>Forgets the bootstrap (managedsoftwareupdate --clear-bootstrap-mode).</summary>
+    public static void Clear(string? path = null)
+    {
+        try
+        {
+            File.Delete(path ?? CimianPaths.BootstrapProgressJson);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Debug($"Could not remove bootstrap progress: {ex.Message}");
+        }
+    }
+}
@@ -0,0 +1,134 @@
+using YamlDotNet.Serialization;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// The Config.yaml keys for the bootstrap setup screen, read by CimianWatcher
+/// (to launch it) and CimianStatus (to run it).
+/// </summary>
+public class BootstrapScreenConfig
+{
+    [YamlMember(Alias = "BootstrapScreen")]
+    public bool Enabled { get; set; }
+
+    [YamlMember(Alias = "BootstrapScreenTimeoutMinutes")]
+    public int TimeoutMinutes { get; set; } = 240;
+
+    [YamlMember(Alias = "BootstrapScreenAllowSkip")]
+    public bool AllowSkip { get; set; }
+
+    /// <summary>The keys from Config.yaml; defaults (screen off) when it's missing or unreadable.</summary>
+    public static BootstrapScreenConfig Load(string? path = null)
+    {
+        path ??= CimianPaths.ConfigYaml;
+        try
+        {
+            if (File.Exists(path))
+            {
+                return YamlUtils.Deserializer.Deserialize<BootstrapScreenConfig>(File.ReadAllText(path)) ?? new BootstrapScreenConfig();
+            }
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Debug($"Could not read {path}: {ex.Message}");
+        }
+        return new BootstrapScreenConfig();
+    }
+}
+
+public static class BootstrapScreenState
+{
+    public const string Show = "show";
+    public const string Completed = "completed";
+    public const string GaveUp = "gave_up";
+    public const string TimedOut = "timed_out";
+    public const string NotRunning = "not_running";
+}
+
+/// <summary>How cimistatus --bootstrap exits, so CimianWatcher knows whether to show it again.</summary>
+public static class BootstrapScreenExitCode
+{
+    /// <summary>The bootstrap ended (completed, gave up or was cleared).</summary>
+    public const int Ended = 0;
+
+    /// <summary>The user chose to continue to the desktop; not shown again in that session.</summary>
+    public const int Skipped = 2;
+
+    /// <summary>BootstrapScreenTimeoutMinutes passed with the bootstrap still going.</summary>
+    public const int TimedOut = 3;
+
+    /// <summary>The session already has the screen up (e.g. after CimianWatcher restarted).</summary>
+    public const int AlreadyShown = 4;
+}
+
+/// <summary>
+/// The full-screen "Setting up your device" screen shown while a bootstrap
+/// runs (BootstrapScreen). CimianWatcher opens it on the logon screen and in
+/// the session of whoever logs on; it stays until the bootstrap ends, its
+/// timeout passes, or (with BootstrapScreenAllowSkip) the user skips it.
+/// </summary>
+public static class BootstrapScreen
+{
+    /// <summary>Command-line switch that starts cimistatus as the setup screen.</summary>
+    public const string Argument = "--bootstrap";
+
+    /// <summary>
+    /// Whether the screen should be up: only while a bootstrap is in progress
+    /// and, when there's a timeout, until that many minutes after it started,
+    /// so restarts and logons don't reset it.
+    /// </summary>
+    public static string Evaluate(BootstrapProgress? progress, BootstrapScreenConfig config, DateTime now)
+    {
+        if (progress == null)
+        {
+            return BootstrapScreenState.NotRunning;
+        }
+        if (progress.Status == BootstrapProgress.Completed)
+        {
+            return BootstrapScreenState.Completed;
+        }
+        if (progress.Status == BootstrapProgress.Exhausted)
+        {
+            return BootstrapScreenState.GaveUp;
+        }
+        return Deadline(progress, config) is { } deadline && now >= deadline
+            ? BootstrapScreenState.TimedOut
+            : BootstrapScreenState.Show;
+    }
+
+    /// <summary>When the screen gives up waiting, or null with no timeout.</summary>
+    public static DateTime? Deadline(BootstrapProgress progress, BootstrapScreenConfig config) =>
+        config.TimeoutMinutes > 0 ? progress.Started.AddMinutes(config.TimeoutMinutes) : null;
+
+    /// <summary>The line under the progress bar: the attempt, and what is being retried.</summary>
+    public static string Describe(BootstrapProgress progress)
+    {
+        if (progress.Attempts == 0)
+        {
+            return "Getting ready...";
+        }
+
+        var installed = progress.CompletedItems.Count > 0 ? $" {progress.CompletedItems.Count} installed so far." : "";
+        if (progress.Remaining.Count == 0)
+        {
+            return $"Attempt {progress.Attempts}.{installed}";
+        }
+
+        const int shown = 3;
+        var retrying = string.Join(", ", progress.Remaining.Take(shown));
+        if (progress.Remaining.Count > shown)
+        {
+            retrying += $" and {progress.Remaining.Count - shown} more";
+        }
+        return $"Attempt {progress.Attempts}.{installed} Retrying {retrying}.";
+    }
+
+    /// <summary>What the screen says just before it closes itself.</summary>
+    public static string Farewell(string state) => state switch
+    {
+        BootstrapScreenState.Completed => "Your device is ready.",
+        BootstrapScreenState.GaveUp => "Setup couldn't install everything. The rest will be installed by later updates.",
+        BootstrapScreenState.TimedOut => "Setup is still finishing in the background.",
+        _ => "Setup has ended."
+    };
+}
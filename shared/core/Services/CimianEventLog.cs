@@ -0,0 +1,196 @@
+using System.Diagnostics;
+using Cimian.Core.Models;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Stable event IDs in the "Cimian" Windows Event Log channel. SIEM rules and
+/// GPO / Task Scheduler event triggers key on these, so existing numbers never
+/// change meaning; new events get new numbers.
+/// </summary>
+public static class CimianEventId
+{
+    // 1xxx: sessions
+    public const int SessionStarted = 1000;
+    public const int SessionCompleted = 1001;
+    public const int SessionPartialFailure = 1002;
+    public const int SessionFailed = 1003;
+
+    // 2xxx: install results
+    public const int InstallSucceeded = 2000;
+    public const int InstallFailed = 2001;
+    public const int UninstallSucceeded = 2002;
+    public const int UninstallFailed = 2003;
+
+    // 3xxx: blocked installs
+    public const int InstallBlocked = 3000;
+    public const int DownloadOriginBlocked = 3001;
+    public const int HashValidationFailed = 3002;
+
+    // 4xxx: agent self-update
+    public const int SelfUpdateScheduled = 4000;
+    public const int SelfUpdateScheduleFailed = 4001;
+    public const int SelfUpdateHealthy = 4002;
+    public const int SelfUpdateRolledBack = 4003;
+    public const int SelfUpdateRefused = 4004;
+}
+
+/// <summary>
+/// Writes the agent's major events to the "Cimian" Windows Event Log channel
+/// (Applications and Services Logs\Cimian) next to the session's file logs, so
+/// monitoring can alert on failures without reading install.log or events.jsonl.
+/// The log and source are registered on first use, which needs admin rights;
+/// when that or a write fails the channel is skipped for the rest of the process.
+/// </summary>
+public static class CimianEventLog
+{
+    public const string LogName = "Cimian";
+    public const string SourceName = "Cimian";
+
+    private static readonly object SourceLock = new();
+    private static bool? _available;
+
+    /// <summary>
+    /// Off with EventLogEnabled: false in Config.yaml.
+    /// </summary>
+    public static bool Enabled { get; set; } = true;
+
+    public static void SessionStarted(string sessionId, string runType) =>
+        Write(CimianEventId.SessionStarted, EventLogEntryType.Information,
+            $"Cimian session {sessionId} started (run type {runType})");
+
+    public static void SessionEnded(string sessionId, string runType, string status, SessionLogSummary summary, string? message)
+    {
+        var (id, type) = ClassifySession(status);
+        var text = $"Cimian session {sessionId} ended: {status} (run type {runType})\n" +
+                   $"Installs: {summary.Installs}, updates: {summary.Updates}, removals: {summary.Removals}, failures: {summary.Failures}";
+        Write(id, type, string.IsNullOrEmpty(message) ? text : $"{text}\n{message}");
+    }
+
+    public static void SelfUpdateScheduled(string itemName, string version, bool scheduled, string? error = null) =>
+        Write(scheduled ? CimianEventId.SelfUpdateScheduled : CimianEventId.SelfUpdateScheduleFailed,
+            scheduled ? EventLogEntryType.Information : EventLogEntryType.Error,
+            scheduled
+                ? $"Self-update scheduled: {itemName} {version} installs on the next CimianWatcher restart"
+                : $"Failed to schedule self-update {itemName} {version}: {error}");
+
+    public static void SelfUpdateChecked(SelfUpdateHealth health) =>
+        Write(health.Status == SelfUpdateHealth.Healthy ? CimianEventId.SelfUpdateHealthy : CimianEventId.SelfUpdateRolledBack,
+            health.Status == SelfUpdateHealth.Healthy ? EventLogEntryType.Information : EventLogEntryType.Error,
+            health.Status == SelfUpdateHealth.Healthy
+                ? $"Self-update {health.Item} {health.Version} passed its health check"
+                : $"Self-update {health.Item} {health.Version} failed its health check and was rolled back to {health.PreviousVersion ?? "the previous version"}: {health.Detail}");
+
+    /// <summary>
+    /// Writes a structured session event when it is one the channel carries.
+    /// </summary>
+    public static void Event(LogEvent evt)
+    {
+        if (Classify(evt) is not { } entry)
+        {
+            return;
+        }
+
+        var version = string.IsNullOrEmpty(evt.PackageVersion) ? "" : $" {evt.PackageVersion}";
+        var lines = new List<string> { $"{evt.PackageName}{version}: {evt.Message}" };
+        if (!string.IsNullOrEmpty(evt.Error) && evt.Error != evt.Message)
+        {
+            lines.Add($"Error: {evt.Error}");
+        }
+        if (!string.IsNullOrEmpty(evt.StatusReasonCode))
+        {
+            lines.Add($"Reason: {evt.StatusReasonCode}");
+        }
+        lines.Add($"Session: {evt.SessionId}");
+        Write(entry.Id, entry.Type, string.Join("\n", lines));
+    }
+
+    /// <summary>
+    /// Event ID and entry type for a session event, or null for events that
+    /// stay in events.jsonl only (progress, status checks, ...).
+    /// </summary>
+    internal static (int Id, EventLogEntryType Type)? Classify(LogEvent evt)
+    {
+        if (evt.EventType == "security" && evt.Action == "self_update" && evt.Status == "blocked")
+        {
+            return (CimianEventId.SelfUpdateRefused, EventLogEntryType.Error);
+        }
+        if (evt.EventType == "security" && evt.Status == "blocked")
+        {
+            return (CimianEventId.DownloadOriginBlocked, EventLogEntryType.Error);
+        }
+        if (evt.EventType == "hash_validation" && evt.Status == "failed")
+        {
+            return (CimianEventId.HashValidationFailed, EventLogEntryType.Error);
+        }
+        if (evt.Status == "blocked"
+            || (evt.Status == "deferred" && evt.StatusReasonCode == StatusReasonCode.BlockingApps))
+        {
+            return (CimianEventId.InstallBlocked, EventLogEntryType.Warning);
+        }
+        if (evt.EventType != "install")
+        {
+            return null;
+        }
+
+        var uninstall = evt.Action == "uninstall";
+        return evt.Status switch
+        {
+            "completed" => (uninstall ? CimianEventId.UninstallSucceeded : CimianEventId.InstallSucceeded, EventLogEntryType.Information),
+            "failed" => (uninstall ? CimianEventId.UninstallFailed : CimianEventId.InstallFailed, EventLogEntryType.Error),
+            _ => null
+        };
+    }
+
+    internal static (int Id, EventLogEntryType Type) ClassifySession(string status) => status switch
+    {
+        "completed" or "success" => (CimianEventId.SessionCompleted, EventLogEntryType.Information),
+        "partial_failure" => (CimianEventId.SessionPartialFailure, EventLogEntryType.Warning),
+        _ => (CimianEventId.SessionFailed, EventLogEntryType.Error)
+    };
+
+    private static void Write(int eventId, EventLogEntryType type, string message)
+    {
+        if (!Enabled || !EnsureSource())
+        {
+            return;
+        }
+
+        try
+        {
+            // Event Log messages are capped at 31839 characters
+            EventLog.WriteEntry(SourceName, message.Length > 31000 ? message[..31000] : message, type, eventId);
+        }
+        catch (Exception ex) when (ex is InvalidOperationException or System.ComponentModel.Win32Exception or ArgumentException)
+        {
+            _available = false;
+            Console.Error.WriteLine($"[WARN] Windows Event Log write failed, skipping it for this run: {ex.Message}");
+        }
+    }
+
+    private static bool EnsureSource()
+    {
+        lock (SourceLock)
+        {
+            if (_available.HasValue)
+            {
+                return _available.Value;
+            }
+
+            try
+            {
+                if (!EventLog.SourceExists(SourceName))
+                {
+                    EventLog.CreateEventSource(new EventSourceCreationData(SourceName, LogName));
+                }
+                _available = true;
+            }
+            catch (Exception ex) when (ex is System.Security.SecurityException or InvalidOperationException or UnauthorizedAccessException or ArgumentException)
+            {
+                // Registering needs admin; non-elevated runs keep their file logs only
+                _available = false;
+            }
+            return _available.Value;
+        }
+    }
+}
@@ -0,0 +1,147 @@
+using System.Formats.Asn1;
+using System.Security.Cryptography;
+using System.Security.Cryptography.X509Certificates;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Finds the client certificate presented to the software repo for mutual TLS,
+/// shared by managedsoftwareupdate and cimiwatcher so both pick the same one.
+///
+/// A certificate is chosen by thumbprint (LocalMachine\My, then CurrentUser\My)
+/// or by certificate template (LocalMachine\My only), which suits auto-enrolled
+/// device certificates whose thumbprint changes at every renewal. Template
+/// matching accepts the template's name or OID and, when several certificates
+/// match, prefers the valid one that expires last.
+/// </summary>
+public static class ClientCertificateStore
+{
+    /// <summary>szOID_ENROLL_CERTTYPE_EXTENSION: V1 templates, the template name as a BMPString.</summary>
+    internal const string TemplateNameOid = "1.3.6.1.4.1.311.20.2";
+
+    /// <summary>szOID_CERTIFICATE_TEMPLATE: V2+ templates, the template OID and version.</summary>
+    internal const string TemplateInfoOid = "1.3.6.1.4.1.311.21.7";
+
+    private const string ClientAuthenticationOid = "1.3.6.1.5.5.7.3.2";
+
+    public static X509Certificate2? FindByThumbprint(string thumbprint)
+    {
+        var normalized = thumbprint.Replace(" ", "").ToUpperInvariant();
+        foreach (var location in new[] { StoreLocation.LocalMachine, StoreLocation.CurrentUser })
+        {
+            using var store = new X509Store(StoreName.My, location);
+            try
+            {
+                store.Open(OpenFlags.ReadOnly);
+                var certs = store.Certificates.Find(X509FindType.FindByThumbprint, normalized, validOnly: false);
+                if (certs.Count > 0)
+                {
+                    ConsoleLogger.Detail($"    Found client certificate in {location}\\My store");
+                    return certs[0];
+                }
+            }
+            catch (CryptographicException ex)
+            {
+                ConsoleLogger.Detail($"    Could not search {location}\\My store: {ex.Message}");
+            }
+        }
+
+        ConsoleLogger.Warn($"Client certificate with thumbprint {normalized} not found in any store");
+        return null;
+    }
+
+    public static X509Certificate2? FindByTemplate(string template)
+    {
+        using var store = new X509Store(StoreName.My, StoreLocation.LocalMachine);
+        try
+        {
+            store.Open(OpenFlags.ReadOnly);
+            var cert = SelectByTemplate(store.Certificates, template, DateTime.Now);
+            if (cert == null)
+            {
+                ConsoleLogger.Warn($"No valid client certificate from template '{template}' with a private key in LocalMachine\\My");
+                return null;
+            }
+            ConsoleLogger.Detail($"    Found client certificate from template '{template}': {cert.Thumbprint}, expires {cert.NotAfter:yyyy-MM-dd}");
+            return cert;
+        }
+        catch (CryptographicException ex)
+        {
+            ConsoleLogger.Warn($"Could not search LocalMachine\\My store: {ex.Message}");
+            return null;
+        }
+    }
+
+    /// <summary>
+    /// The certificate in <paramref name="candidates"/> issued from
+    /// <paramref name="template"/> that has a private key, is valid at
+    /// <paramref name="now"/> and allows client authentication, expiring last.
+    /// </summary>
+    public static X509Certificate2? SelectByTemplate(IEnumerable<X509Certificate2> candidates, string template, DateTime now) =>
+        candidates
+            .Where(c => c.HasPrivateKey && c.NotBefore <= now && now <= c.NotAfter)
+            .Where(AllowsClientAuthentication)
+            .Where(c => MatchesTemplate(c, template))
+            .OrderByDescending(c => c.NotAfter)
+            .FirstOrDefault();
+
+    /// <summary>
+    /// True when the certificate's template extension names
+    /// <paramref name="template"/>, by name or OID, case-insensitively.
+    /// </summary>
+    public static bool MatchesTemplate(X509Certificate2 cert, string template)
+    {
+        template = template.Trim();
+        foreach (var extension in cert.Extensions)
+        {
+            try
+            {
+                switch (extension.Oid?.Value)
+                {
+                    case TemplateNameOid:
+                        if (string.Equals(ReadTemplateName(extension.RawData), template, StringComparison.OrdinalIgnoreCase))
+                        {
+                            return true;
+                        }
+                        break;
+
+                    case TemplateInfoOid:
+                        var templateOid = ReadTemplateOid(extension.RawData);
+                        if (string.Equals(templateOid, template, StringComparison.OrdinalIgnoreCase)
+                            || string.Equals(new Oid(templateOid).FriendlyName, template, StringComparison.OrdinalIgnoreCase)
+                            || extension.Format(false).Contains($"Template={template}(", StringComparison.OrdinalIgnoreCase))
+                        {
+                            return true;
+                        }
+                        break;
+                }
+            }
+            catch (AsnContentException)
+            {
+                // Malformed extension: not a match, keep looking
+            }
+        }
+        return false;
+    }
+
+    private static bool AllowsClientAuthentication(X509Certificate2 cert)
+    {
+        var eku = cert.Extensions.OfType<X509EnhancedKeyUsageExtension>().FirstOrDefault();
+        return eku == null || eku.EnhancedKeyUsages.Cast<Oid>().Any(o => o.Value == ClientAuthenticationOid);
+    }
+
+    private static string ReadTemplateName(byte[] rawData)
+    {
+        var reader = new AsnReader(rawData, AsnEncodingRules.DER);
+        var tag = reader.PeekTag();
+        return tag.HasSameClassAndValue(new Asn1Tag(UniversalTagNumber.UTF8String))
+            ? reader.ReadCharacterString(UniversalTagNumber.UTF8String)
+            : reader.ReadCharacterString(UniversalTagNumber.BMPString);
+    }
+
+    private static string ReadTemplateOid(byte[] rawData)
+    {
+        var sequence = new AsnReader(rawData, AsnEncodingRules.DER).ReadSequence();
+        return sequence.ReadObjectIdentifier();
+    }
+}
@@ -0,0 +1,121 @@
+using System.Text;
+using System.Text.RegularExpressions;
+using Microsoft.Win32;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// ClientIdentifier values with %token% placeholders, such as
+/// "Assigned/%ou%/%serial%", expanded from machine facts when manifests are
+/// resolved. %serial%, %hostname%, %domain%, %ou% and %site% are built in; any
+/// other token is looked up as a conditional-items fact (%machine_type%,
+/// %arch%, ...). A template that can't be fully expanded yields no identifier,
+/// so resolution moves on to the next candidate instead of fetching a manifest
+/// named after half a template.
+/// </summary>
+public static partial class ClientIdentifierTemplate
+{
+    // Group Policy's record of the computer's AD object, refreshed at every machine policy refresh
+    private const string GroupPolicyStateKey = @"SOFTWARE\Microsoft\Windows\CurrentVersion\Group Policy\State\Machine";
+
+    [GeneratedRegex(@"%([A-Za-z_][A-Za-z0-9_]*)%")]
+    private static partial Regex TokenPattern();
+
+    /// <summary>True when <paramref name="identifier"/> has at least one %token%.</summary>
+    public static bool IsTemplate(string? identifier) =>
+        !string.IsNullOrEmpty(identifier) && TokenPattern().IsMatch(identifier);
+
+    /// <summary>The token names in <paramref name="template"/>, lower-cased, in order.</summary>
+    public static IReadOnlyList<string> Tokens(string template) =>
+        TokenPattern().Matches(template).Select(m => m.Groups[1].Value.ToLowerInvariant()).ToList();
+
+    /// <summary>
+    /// Replaces each %token% with <paramref name="resolve"/>(token), made safe to
+    /// use as a manifest path segment. Returns null, with the offending token in
+    /// <paramref name="missingToken"/>, when any token has no value.
+    /// </summary>
+    public static string? Expand(string template, Func<string, string?> resolve, out string? missingToken)
+    {
+        missingToken = null;
+        var result = new StringBuilder();
+        var last = 0;
+        foreach (Match match in TokenPattern().Matches(template))
+        {
+            var token = match.Groups[1].Value.ToLowerInvariant();
+            var value = SanitizeSegment(resolve(token));
+            if (value == null)
+            {
+                missingToken = token;
+                return null;
+            }
+            result.Append(template, last, match.Index - last).Append(value);
+            last = match.Index + match.Length;
+        }
+        result.Append(template, last, template.Length - last);
+        return result.ToString();
+    }
+
+    /// <summary>
+    /// A fact value usable inside a manifest path: trimmed, with path separators,
+    /// characters Windows doesn't allow in file names and "." / ".." replaced.
+    /// Null for a blank value.
+    /// </summary>
+    internal static string? SanitizeSegment(string? value)
+    {
+        value = value?.Trim();
+        if (string.IsNullOrEmpty(value))
+        {
+            return null;
+        }
+
+        var invalid = Path.GetInvalidFileNameChars().Concat(['/', '\\', ':', '*', '?', '"', '<', '>', '|']).ToHashSet();
+        var sanitized = new string(value.Select(c => invalid.Contains(c) || char.IsControl(c) ? '_' : c).ToArray());
+        return sanitized is "." or ".." ? sanitized.Replace('.', '_') : sanitized;
+    }
+
+    /// <summary>
+    /// The name of the OU holding the computer object, from its distinguished name
+    /// ("CN=PC01,OU=Labs,OU=Staff,DC=corp,DC=example" gives "Labs"). Null when the
+    /// object isn't in an OU.
+    /// </summary>
+    public static string? OuFromDistinguishedName(string? distinguishedName)
+    {
+        if (string.IsNullOrWhiteSpace(distinguishedName))
+        {
+            return null;
+        }
+
+        // Split on commas that aren't escaped (CN=Smith\, John)
+        foreach (var part in Regex.Split(distinguishedName, @"(?<!\\),"))
+        {
+            var trimmed = part.Trim();
+            if (trimmed.StartsWith("OU=", StringComparison.OrdinalIgnoreCase))
+            {
+                return trimmed[3..].Replace("\\,", ",").Trim();
+            }
+        }
+        return null;
+    }
+
+    /// <summary>
+    /// The computer's OU (see <see cref="OuFromDistinguishedName"/>) as last
+    /// recorded by Group Policy. Null off-domain or before the first policy refresh.
+    /// </summary>
+    public static string? GetComputerOu() => OuFromDistinguishedName(ReadGroupPolicyState("Distinguished-Name"));
+
+    /// <summary>The computer's AD site as last recorded by Group Policy, or null.</summary>
+    public static string? GetComputerSite() => ReadGroupPolicyState("Site-Name");
+
+    private static string? ReadGroupPolicyState(string valueName)
+    {
+        try
+        {
+            using var key = Registry.LocalMachine.OpenSubKey(GroupPolicyStateKey);
+            return key?.GetValue(valueName) as string;
+        }
+        catch (Exception ex) when (ex is System.Security.SecurityException or UnauthorizedAccessException or IOException)
+        {
+            return null;
+        }
+    }
+}
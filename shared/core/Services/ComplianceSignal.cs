@@ -0,0 +1,134 @@
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using Microsoft.Win32;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Whether every mandatory item (the manifests' managed_installs, plus
+/// managed_updates for software that is present) is installed and current, and
+/// every mandatory configuration item passed its last test, as of the last
+/// InstallInfo write.
+/// </summary>
+public class ComplianceState
+{
+    [JsonPropertyName("compliant")]
+    public bool Compliant { get; set; }
+
+    [JsonPropertyName("evaluated_at")]
+    public DateTime EvaluatedAt { get; set; }
+
+    [JsonPropertyName("mandatory_items")]
+    public int MandatoryItems { get; set; }
+
+    /// <summary>Mandatory items that are missing, outdated or not in any catalog.</summary>
+    [JsonPropertyName("missing_items")]
+    public List<string> MissingItems { get; set; } = new();
+
+    /// <summary>Configuration items whose last test failed, counted apart from installs.</summary>
+    [JsonPropertyName("noncompliant_configurations")]
+    public List<string> NoncompliantConfigurations { get; set; } = new();
+}
+
+/// <summary>
+/// Publishes <see cref="ComplianceState"/> where device compliance tooling can
+/// read it without a custom script per tenant: HKLM\SOFTWARE\Cimian\Compliance
+/// (Compliant REG_DWORD for Intune registry detection rules and custom
+/// compliance discovery scripts) and/or <see cref="CimianPaths.ComplianceJson"/>.
+/// Chosen by the ComplianceExport setting: none, registry, file or both.
+/// </summary>
+public static class ComplianceSignal
+{
+    public const string RegistryPath = @"SOFTWARE\Cimian\Compliance";
+
+    public static readonly string[] Modes = ["none", "registry", "file", "both"];
+
+    public static ComplianceState Evaluate(
+        int mandatoryItems,
+        IEnumerable<string> missingItems,
+        DateTime now,
+        IEnumerable<string>? noncompliantConfigurations = null)
+    {
+        var missing = missingItems.Distinct(StringComparer.OrdinalIgnoreCase).ToList();
+        var drifted = (noncompliantConfigurations ?? []).Distinct(StringComparer.OrdinalIgnoreCase).ToList();
+        return new ComplianceState
+        {
+            Compliant = missing.Count == 0 && drifted.Count == 0,
+            EvaluatedAt = now,
+            MandatoryItems = mandatoryItems,
+            MissingItems = missing,
+            NoncompliantConfigurations = drifted
+        };
+    }
+
+    /// <summary>True when <paramref name="mode"/> includes "registry" or "file".</summary>
+    public static bool Exports(string? mode, string target)
+    {
+        var normalized = mode?.Trim().ToLowerInvariant();
+        return normalized == target || normalized == "both";
+    }
+
+    /// <summary>Writes the state through every target <paramref name="mode"/> names.</summary>
+    public static void Publish(ComplianceState state, string? mode, string? path = null)
+    {
+        if (Exports(mode, "registry"))
+        {
+            WriteRegistry(state);
+        }
+        if (Exports(mode, "file"))
+        {
+            WriteFile(state, path);
+        }
+    }
+
+    public static void WriteFile(ComplianceState state, string? path = null)
+    {
+        path ??= CimianPaths.ComplianceJson;
+        try
+        {
+            JsonStateFile.Write(path, state);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not write compliance state: {ex.Message}");
+        }
+    }
+
+    public static ComplianceState? ReadFile(string? path = null)
+    {
+        path ??= CimianPaths.ComplianceJson;
+        try
+        {
+            return JsonStateFile.Read<ComplianceState>(path);
+        }
+        catch (Exception ex) when (ex is IOException or JsonException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not read compliance state: {ex.Message}");
+            return null;
+        }
+    }
+
+    /// <summary>
+    /// Compliant (REG_DWORD 1/0), MandatoryItems (REG_DWORD), MissingItems and
+    /// NoncompliantConfigurations (REG_MULTI_SZ) and EvaluatedAt (REG_SZ, ISO 8601) under
+    /// HKLM\SOFTWARE\Cimian\Compliance.
+    /// </summary>
+    public static void WriteRegistry(ComplianceState state)
+    {
+        try
+        {
+            using var key = Registry.LocalMachine.CreateSubKey(RegistryPath);
+            if (key == null) return;
+
+            key.SetValue("Compliant", state.Compliant ? 1 : 0, RegistryValueKind.DWord);
+            key.SetValue("MandatoryItems", state.MandatoryItems, RegistryValueKind.DWord);
+            key.SetValue("MissingItems", state.MissingItems.ToArray(), RegistryValueKind.MultiString);
+            key.SetValue("NoncompliantConfigurations", state.NoncompliantConfigurations.ToArray(), RegistryValueKind.MultiString);
+            key.SetValue("EvaluatedAt", state.EvaluatedAt.ToString("o"), RegistryValueKind.String);
+        }
+        catch (Exception ex) when (ex is System.Security.SecurityException or UnauthorizedAccessException or IOException)
+        {
+            ConsoleLogger.Warn($"Could not write compliance registry key: {ex.Message}");
+        }
+    }
+}
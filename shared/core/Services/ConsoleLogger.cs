@@ -44,6 +44,14 @@ public static class ConsoleLogger
     /// </summary>
     public static bool UseIndentation { get; set; } = false;
 
+    /// <summary>
+    /// Set while managedsoftwareupdate --progress draws its progress bars. Routine
+    /// lines (Log, Info, Detail, Success, ...) stay off the console because the
+    /// bars stand in for them; warnings and errors are handed to this callback so
+    /// they print above the bars. The session log still gets everything.
+    /// </summary>
+    public static Action<string>? ProgressDisplay { get; set; }
+
     /// <summary>
     /// Optional SessionLogger reference for writing to log files.
     /// When set, all console output is also written to the session run.log.
@@ -59,6 +67,19 @@ public static class ConsoleLogger
         _sessionLogger = logger;
     }
 
+    /// <summary>
+    /// Tells the session's structured log the run is still alive during a long
+    /// step (see <see cref="SessionLogger.Heartbeat"/>). No-op without a session.
+    /// </summary>
+    public static void Heartbeat(string activity) => _sessionLogger?.Heartbeat(activity);
+
+    /// <summary>
+    /// Heartbeats while a bounded wait is in progress (see
+    /// <see cref="SessionLogger.HeartbeatWhile"/>); null without a session.
+    /// </summary>
+    public static IDisposable? HeartbeatWhile(string activity, TimeSpan limit) =>
+        _sessionLogger?.HeartbeatWhile(activity, limit);
+
     /// <summary>
     /// Write a message to the session logger if attached.
     /// Strips ANSI color codes and Unicode box-drawing characters before writing to log files.
@@ -74,12 +95,32 @@ public static class ConsoleLogger
         _sessionLogger.Log(level, clean);
     }
 
+    private static void WriteRoutine(string line)
+    {
+        if (ProgressDisplay == null)
+        {
+            Console.WriteLine(line);
+        }
+    }
+
+    private static void WriteImportant(TextWriter writer, string line)
+    {
+        if (ProgressDisplay is { } display)
+        {
+            display(line);
+        }
+        else
+        {
+            writer.WriteLine(line);
+        }
+    }
+
     /// <summary>
     /// Log a plain message (always shown) - no color
     /// </summary>
     public static void Log(string message = "")
     {
-        Console.WriteLine(message);
+        WriteRoutine(message);
         LogToSession("INFO", message);
     }
 
@@ -90,7 +131,7 @@ public static class ConsoleLogger
     {
         if (Verbosity >= 1)
         {
-            Console.WriteLine(message);
+            WriteRoutine(message);
         }
         LogToSession("INFO", message);
     }
@@ -102,7 +143,7 @@ public static class ConsoleLogger
     {
         if (Verbosity >= 2)
         {
-            Console.WriteLine($"{ColorCyan}    {message}{ColorReset}");
+            WriteRoutine($"{ColorCyan}    {message}{ColorReset}");
         }
         LogToSession("DEBUG", message);
     }
@@ -114,7 +155,7 @@ public static class ConsoleLogger
     {
         if (Verbosity >= 3)
         {
-            Console.WriteLine($"{ColorCyan}    {message}{ColorReset}");
+            WriteRoutine($"{ColorCyan}    {message}{ColorReset}");
         }
         LogToSession("DEBUG", message);
     }
@@ -131,7 +172,7 @@ public static class ConsoleLogger
     {
         if (Verbosity >= 4)
         {
-            Console.WriteLine($"{ColorCyan}    {message}{ColorReset}");
+            WriteRoutine($"{ColorCyan}    {message}{ColorReset}");
         }
         LogToSession("TRACE", message);
     }
@@ -146,7 +187,7 @@ public static class ConsoleLogger
     /// </summary>
     public static void Success(string message)
     {
-        Console.WriteLine($"{ColorGreen}{message}{ColorReset}");
+        WriteRoutine($"{ColorGreen}{message}{ColorReset}");
         LogToSession("INFO", message);
     }
 
@@ -155,7 +196,7 @@ public static class ConsoleLogger
     /// </summary>
     public static void Warn(string message)
     {
-        Console.WriteLine($"{ColorYellow}{message}{ColorReset}");
+        WriteImportant(Console.Out, $"{ColorYellow}{message}{ColorReset}");
         LogToSession("WARN", message);
     }
 
@@ -164,7 +205,7 @@ public static class ConsoleLogger
     /// </summary>
     public static void Error(string message)
     {
-        Console.Error.WriteLine($"{ColorRed}{message}{ColorReset}");
+        WriteImportant(Console.Error, $"{ColorRed}{message}{ColorReset}");
         LogToSession("ERROR", message);
     }
 
@@ -174,7 +215,7 @@ public static class ConsoleLogger
     public static void Indented(string message, int level = 1)
     {
         var indent = new string('\t', level);
-        Console.WriteLine($"{indent}{message}");
+        WriteRoutine($"{indent}{message}");
     }
 
     /// <summary>
@@ -182,7 +223,7 @@ public static class ConsoleLogger
     /// </summary>
     public static void Item(string message)
     {
-        Console.WriteLine($"* {message}");
+        WriteRoutine($"* {message}");
     }
 
     /// <summary>
@@ -190,6 +231,6 @@ public static class ConsoleLogger
     /// </summary>
     public static void SubItem(string message)
     {
-        Console.WriteLine($"** {message}");
+        WriteRoutine($"** {message}");
     }
 }
@@ -273,6 +273,13 @@ public class DataExporter
                                 record.LogVersion = logVersion?.ToString() ?? "";
                             if (session.Environment.TryGetValue("process_id", out var pid))
                                 record.ProcessId = Convert.ToInt32(pid);
+                            if (session.Environment.TryGetValue("persistence", out var persistence))
+                                record.Persistence = persistence?.ToString();
+                            if (session.Environment.TryGetValue("pending_reboot", out var pendingReboot))
+                                record.PendingReboot = pendingReboot is JsonElement { ValueKind: JsonValueKind.True };
+                            if (session.Environment.TryGetValue("pending_reboot_reasons", out var rebootReasons)
+                                && rebootReasons is JsonElement { ValueKind: JsonValueKind.Array } reasonArray)
+                                record.PendingRebootReasons = reasonArray.EnumerateArray().Select(r => r.GetString() ?? "").ToList();
                         }
 
                         // Create enhanced summary
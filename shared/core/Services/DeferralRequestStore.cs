@@ -0,0 +1,75 @@
+using System.Text.Json;
+using System.Text.Json.Serialization;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// A user's "Defer" on a toast, recorded by the run broker for the next auto run.
+/// </summary>
+public class DeferralRequest
+{
+    [JsonPropertyName("item")]
+    public string Item { get; set; } = "";
+
+    [JsonPropertyName("requested_by")]
+    public string RequestedBy { get; set; } = "";
+
+    [JsonPropertyName("requested_at")]
+    public DateTime RequestedAt { get; set; }
+}
+
+/// <summary>
+/// Deferrals users asked for, kept in <see cref="CimianPaths.DeferralRequestsJson"/>.
+/// The broker writes them (users can't write ProgramData); the next auto run
+/// postpones each requested item once, counting it against the item's
+/// max_deferrals, and clears the file. A request nobody acted on within
+/// <see cref="MaxAge"/> is ignored.
+/// </summary>
+public static class DeferralRequestStore
+{
+    public static readonly TimeSpan MaxAge = TimeSpan.FromHours(24);
+
+    /// <summary>Adds or refreshes a request for each item.</summary>
+    public static void Add(IEnumerable<string> items, string requestedBy, DateTime now, string? path = null)
+    {
+        path ??= CimianPaths.DeferralRequestsJson;
+        var requests = Read(now, path);
+        foreach (var item in items)
+        {
+            requests.RemoveAll(r => ItemKey.Comparer.Equals(r.Item, item));
+            requests.Add(new DeferralRequest { Item = item, RequestedBy = requestedBy, RequestedAt = now });
+        }
+
+        JsonStateFile.Write(path, requests);
+    }
+
+    /// <summary>Requests younger than <see cref="MaxAge"/>.</summary>
+    public static List<DeferralRequest> Read(DateTime now, string? path = null)
+    {
+        path ??= CimianPaths.DeferralRequestsJson;
+        try
+        {
+            return (JsonStateFile.Read<List<DeferralRequest>>(path) ?? [])
+                .Where(r => !string.IsNullOrWhiteSpace(r.Item) && now - r.RequestedAt < MaxAge)
+                .ToList();
+        }
+        catch (Exception ex) when (ex is IOException or JsonException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Debug($"Could not read deferral requests: {ex.Message}");
+        }
+        return [];
+    }
+
+    public static void Clear(string? path = null)
+    {
+        path ??= CimianPaths.DeferralRequestsJson;
+        try
+        {
+            File.Delete(path);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Warn($"Could not clear deferral requests: {ex.Message}");
+        }
+    }
+}
@@ -0,0 +1,157 @@
+using System.Diagnostics;
+using System.Runtime.InteropServices;
+using System.Security.Principal;
+
+namespace Cimian.Core.Services;
+
+public enum FocusState
+{
+    /// <summary>Nothing suggests the user wants to be left alone.</summary>
+    Available,
+    /// <summary>Focus Assist / Do Not Disturb is on (priority-only or alarms-only).</summary>
+    DoNotDisturb,
+    /// <summary>Presentation mode (Windows Mobility Center, PowerPoint slideshow).</summary>
+    Presentation,
+    /// <summary>A full-screen app or Direct3D game has the foreground.</summary>
+    FullScreen
+}
+
+/// <summary>
+/// Reads whether the signed-in user is in Do Not Disturb so toasts, the status
+/// window and countdown reboots can wait. Focus Assist lives in a per-user WNF
+/// state, which a SYSTEM process reads with the console user's SID as the
+/// explicit scope. Presentation and full-screen state come from
+/// SHQueryUserNotificationState and are only visible from the user's own
+/// session; from session 0 they're covered only through Focus Assist's
+/// automatic rules ("when I'm presenting", "when playing a game").
+/// </summary>
+public static class FocusAssist
+{
+    // QUERY_USER_NOTIFICATION_STATE
+    private const int QunsBusy = 2;
+    private const int QunsRunningD3DFullScreen = 3;
+    private const int QunsPresentationMode = 4;
+    private const int QunsQuietTime = 6;
+
+    // WNF_SHEL_QUIETHOURS_ACTIVE_PROFILE_CHANGED: 0 off, 1 priority only, 2 alarms only
+    private const ulong WnfShelQuietHoursActiveProfileChanged = 0x0D83063EA3BF1C75;
+
+    [DllImport("shell32.dll")]
+    private static extern int SHQueryUserNotificationState(out int state);
+
+    [DllImport("ntdll.dll")]
+    private static extern int NtQueryWnfStateData(ref ulong stateName, IntPtr typeId, IntPtr explicitScope,
+        out uint changeStamp, out int buffer, ref uint bufferSize);
+
+    [DllImport("kernel32.dll")]
+    private static extern uint WTSGetActiveConsoleSessionId();
+
+    [DllImport("wtsapi32.dll", SetLastError = true)]
+    private static extern bool WTSQueryUserToken(uint sessionId, out IntPtr token);
+
+    [DllImport("kernel32.dll", SetLastError = true)]
+    private static extern bool CloseHandle(IntPtr handle);
+
+    /// <summary>
+    /// Current state for the interactive user. Anything that fails to read
+    /// counts as <see cref="FocusState.Available"/>, so a broken query never
+    /// holds back a reboot forever.
+    /// </summary>
+    public static FocusState GetState()
+    {
+        try
+        {
+            int? notificationState = null;
+            if (Process.GetCurrentProcess().SessionId != 0
+                && SHQueryUserNotificationState(out var quns) == 0)
+            {
+                notificationState = quns;
+            }
+            return Classify(notificationState, QueryQuietHoursProfile());
+        }
+        catch (Exception ex) when (ex is DllNotFoundException or EntryPointNotFoundException or ExternalException)
+        {
+            ConsoleLogger.Debug($"Focus Assist state unavailable: {ex.Message}");
+            return FocusState.Available;
+        }
+    }
+
+    /// <summary>
+    /// Combines a SHQueryUserNotificationState result and the Focus Assist
+    /// profile (either may be unknown) into one state.
+    /// </summary>
+    public static FocusState Classify(int? notificationState, int? quietHoursProfile)
+    {
+        switch (notificationState)
+        {
+            case QunsPresentationMode:
+                return FocusState.Presentation;
+            case QunsBusy or QunsRunningD3DFullScreen:
+                return FocusState.FullScreen;
+            case QunsQuietTime:
+                return FocusState.DoNotDisturb;
+        }
+        return quietHoursProfile > 0 ? FocusState.DoNotDisturb : FocusState.Available;
+    }
+
+    public static string Describe(FocusState state) => state switch
+    {
+        FocusState.DoNotDisturb => "Focus Assist is on",
+        FocusState.Presentation => "the user is presenting",
+        FocusState.FullScreen => "a full-screen app is in use",
+        _ => "the user is available"
+    };
+
+    private static int? QueryQuietHoursProfile()
+    {
+        var stateName = WnfShelQuietHoursActiveProfileChanged;
+        uint size = sizeof(int);
+
+        if (Process.GetCurrentProcess().SessionId != 0)
+        {
+            return NtQueryWnfStateData(ref stateName, IntPtr.Zero, IntPtr.Zero, out _, out var own, ref size) == 0 && size > 0
+                ? own
+                : null;
+        }
+
+        // Session 0: the state is per-user, so scope the query to whoever is at the console
+        var sid = GetConsoleUserSid();
+        if (sid == null)
+        {
+            return null;
+        }
+
+        var sidBytes = new byte[sid.BinaryLength];
+        sid.GetBinaryForm(sidBytes, 0);
+        var scope = Marshal.AllocHGlobal(sidBytes.Length);
+        try
+        {
+            Marshal.Copy(sidBytes, 0, scope, sidBytes.Length);
+            return NtQueryWnfStateData(ref stateName, IntPtr.Zero, scope, out _, out var profile, ref size) == 0 && size > 0
+                ? profile
+                : null;
+        }
+        finally
+        {
+            Marshal.FreeHGlobal(scope);
+        }
+    }
+
+    private static SecurityIdentifier? GetConsoleUserSid()
+    {
+        var session = WTSGetActiveConsoleSessionId();
+        if (session == 0xFFFFFFFF || !WTSQueryUserToken(session, out var token))
+        {
+            return null;
+        }
+        try
+        {
+            using var identity = new WindowsIdentity(token);
+            return identity.User;
+        }
+        finally
+        {
+            CloseHandle(token);
+        }
+    }
+}
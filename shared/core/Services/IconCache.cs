@@ -0,0 +1,86 @@
+using System.Security.Cryptography;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Naming and lookup rules for item icons, shared by managedsoftwareupdate
+/// (which fills <see cref="CimianPaths.IconsDir"/> from the repo's icons/
+/// folder), makecatalogs (which indexes that folder) and the GUIs that render
+/// them. An item's icon is its pkginfo icon_name, or the item name with .png.
+/// </summary>
+public static class IconCache
+{
+    /// <summary>
+    /// Index written into the repo's icons/ folder by makecatalogs: icon file
+    /// name to SHA-256, so clients can tell a stale cached icon without fetching it.
+    /// </summary>
+    public const string HashesFileName = "_icon_hashes.yaml";
+
+    public static readonly string[] SupportedExtensions = [".png", ".jpg", ".jpeg", ".ico", ".bmp"];
+
+    /// <summary>
+    /// The icon file the repo is expected to hold for an item: icon_name as given
+    /// (with .png added when it has no extension), else "&lt;name&gt;.png".
+    /// </summary>
+    public static string IconFileName(string itemName, string? iconName)
+    {
+        if (string.IsNullOrWhiteSpace(iconName))
+        {
+            return itemName + ".png";
+        }
+        return Path.HasExtension(iconName) ? iconName : iconName + ".png";
+    }
+
+    /// <summary>
+    /// File names to try, in order: icon_name, icon_name's stem with each
+    /// supported extension, then the item name with each extension.
+    /// </summary>
+    public static List<string> Candidates(string itemName, string? iconName)
+    {
+        var candidates = new List<string>();
+        if (!string.IsNullOrWhiteSpace(iconName))
+        {
+            candidates.Add(iconName);
+            var stem = Path.GetFileNameWithoutExtension(iconName);
+            candidates.AddRange(SupportedExtensions.Select(ext => stem + ext));
+        }
+        candidates.AddRange(SupportedExtensions.Select(ext => itemName + ext));
+        return candidates.Distinct(StringComparer.OrdinalIgnoreCase).ToList();
+    }
+
+    /// <summary>
+    /// Full path of the first candidate that exists in the icon directory, or
+    /// null. Names that would resolve outside the directory are ignored.
+    /// </summary>
+    public static string? FindIcon(string itemName, string? iconName, string? iconsDir = null)
+    {
+        var dir = iconsDir ?? CimianPaths.IconsDir;
+        foreach (var candidate in Candidates(itemName, iconName))
+        {
+            var path = ResolveInside(dir, candidate);
+            if (path != null && File.Exists(path))
+            {
+                return path;
+            }
+        }
+        return null;
+    }
+
+    /// <summary>
+    /// <paramref name="fileName"/> under <paramref name="iconsDir"/>, or null if it
+    /// would land anywhere else ("..\..\x.png", rooted paths).
+    /// </summary>
+    public static string? ResolveInside(string iconsDir, string fileName)
+    {
+        var root = Path.GetFullPath(iconsDir).TrimEnd(Path.DirectorySeparatorChar) + Path.DirectorySeparatorChar;
+        var path = Path.GetFullPath(Path.Combine(root, fileName));
+        return path.StartsWith(root, StringComparison.OrdinalIgnoreCase) ? path : null;
+    }
+
+    /// <summary>Lower-case hex SHA-256 of a file, the form the hash index uses.</summary>
+    public static string ComputeHash(string path)
+    {
+        using var stream = File.OpenRead(path);
+        return Convert.ToHexString(SHA256.HashData(stream)).ToLowerInvariant();
+    }
+}
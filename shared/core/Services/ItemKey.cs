@@ -0,0 +1,67 @@
+using System.Globalization;
+using System.Text;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Canonical form of an item name for use as a map key. Item names arrive from
+/// hand-edited manifests, pkginfo, catalogs and receipts with inconsistent case
+/// and accents ("Café Reader" vs "cafe reader"); every lookup keyed on an item
+/// name goes through here instead of an ad-hoc ToLowerInvariant so they all
+/// agree on what "the same item" means.
+/// </summary>
+public static class ItemKey
+{
+    /// <summary>
+    /// Trimmed, accent-stripped, lower-cased (invariant) form of the name.
+    /// </summary>
+    public static string Canonical(string? name)
+    {
+        if (string.IsNullOrEmpty(name))
+        {
+            return string.Empty;
+        }
+
+        var trimmed = name.Trim();
+
+        // Fast path: plain ASCII names (the vast majority) need no decomposition
+        var ascii = true;
+        foreach (var c in trimmed)
+        {
+            if (c > 0x7F)
+            {
+                ascii = false;
+                break;
+            }
+        }
+        if (ascii)
+        {
+            return trimmed.ToLowerInvariant();
+        }
+
+        var decomposed = trimmed.Normalize(NormalizationForm.FormD);
+        var sb = new StringBuilder(decomposed.Length);
+        foreach (var c in decomposed)
+        {
+            if (CharUnicodeInfo.GetUnicodeCategory(c) != UnicodeCategory.NonSpacingMark)
+            {
+                sb.Append(c);
+            }
+        }
+        return sb.ToString().Normalize(NormalizationForm.FormC).ToLowerInvariant();
+    }
+
+    /// <summary>
+    /// Equality comparer over canonical item names, for dictionaries and sets
+    /// keyed on raw names.
+    /// </summary>
+    public static IEqualityComparer<string> Comparer { get; } = new CanonicalComparer();
+
+    private sealed class CanonicalComparer : IEqualityComparer<string>
+    {
+        public bool Equals(string? x, string? y) =>
+            string.Equals(Canonical(x), Canonical(y), StringComparison.Ordinal);
+
+        public int GetHashCode(string obj) => Canonical(obj).GetHashCode(StringComparison.Ordinal);
+    }
+}
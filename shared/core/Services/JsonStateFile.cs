@@ -0,0 +1,49 @@
+using System.Text.Json;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Reads and writes the small JSON state files the stores keep (holds,
+/// deferrals, restart and bootstrap state, caches). A write goes to a .tmp
+/// beside the file and then replaces it, so a reader never sees half a file.
+/// </summary>
+public static class JsonStateFile
+{
+    /// <summary>Indented, like every state file Cimian writes.</summary>
+    public static readonly JsonSerializerOptions DefaultOptions = new() { WriteIndented = true };
+
+    /// <summary>Serializes <paramref name="value"/> to <paramref name="path"/>, creating its folder. I/O errors go to the caller.</summary>
+    public static void Write<T>(string path, T value, JsonSerializerOptions? options = null) =>
+        WriteText(path, JsonSerializer.Serialize(value, options ?? DefaultOptions));
+
+    /// <summary>Replaces <paramref name="path"/> with <paramref name="contents"/> via a .tmp beside it.</summary>
+    public static void WriteText(string path, string contents)
+    {
+        var dir = Path.GetDirectoryName(path);
+        if (!string.IsNullOrEmpty(dir))
+        {
+            Directory.CreateDirectory(dir);
+        }
+
+        var tempPath = path + ".tmp";
+        File.WriteAllText(tempPath, contents);
+        File.Move(tempPath, path, overwrite: true);
+    }
+
+    /// <summary>The file's contents, or default when it doesn't exist. Unreadable or corrupt files throw.</summary>
+    public static T? Read<T>(string path, JsonSerializerOptions? options = null) =>
+        File.Exists(path) ? JsonSerializer.Deserialize<T>(File.ReadAllText(path), options) : default;
+
+    /// <summary>Like <see cref="Read{T}"/>, but null for an unreadable or corrupt file too.</summary>
+    public static T? TryRead<T>(string path, JsonSerializerOptions? options = null) where T : class
+    {
+        try
+        {
+            return Read<T>(path, options);
+        }
+        catch (Exception ex) when (ex is IOException or JsonException or UnauthorizedAccessException)
+        {
+            return null;
+        }
+    }
+}
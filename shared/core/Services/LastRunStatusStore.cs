@@ -0,0 +1,209 @@
+using System.Text.Json.Serialization;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Final result of the most recent managedsoftwareupdate session, kept at
+/// <see cref="CimianPaths.LastRunStatusJson"/> so cimistatus can show what
+/// happened last time when it's opened with no run in progress.
+/// </summary>
+public class LastRunStatus
+{
+    /// <summary>success, partial or failure.</summary>
+    [JsonPropertyName("outcome")]
+    public string Outcome { get; set; } = "";
+
+    [JsonPropertyName("session_id")]
+    public string SessionId { get; set; } = "";
+
+    [JsonPropertyName("run_type")]
+    public string RunType { get; set; } = "";
+
+    [JsonPropertyName("start_time")]
+    public DateTime StartTime { get; set; }
+
+    [JsonPropertyName("end_time")]
+    public DateTime EndTime { get; set; }
+
+    [JsonPropertyName("installs")]
+    public int Installs { get; set; }
+
+    [JsonPropertyName("updates")]
+    public int Updates { get; set; }
+
+    [JsonPropertyName("removals")]
+    public int Removals { get; set; }
+
+    [JsonPropertyName("successes")]
+    public int Successes { get; set; }
+
+    [JsonPropertyName("failures")]
+    public int Failures { get; set; }
+
+    [JsonPropertyName("message")]
+    public string Message { get; set; } = "";
+
+    /// <summary>The repo was unreachable and the run worked from cached manifests.</summary>
+    [JsonPropertyName("degraded")]
+    public bool Degraded { get; set; }
+
+    /// <summary>Items the user put off this run, so cimistatus can say what's coming.</summary>
+    [JsonPropertyName("deferrals")]
+    public List<LastRunDeferral> Deferrals { get; set; } = new();
+
+    /// <summary>"What's new" for items installed this run that publish release_notes.</summary>
+    [JsonPropertyName("release_notes")]
+    public List<LastRunReleaseNote> ReleaseNotes { get; set; } = new();
+
+    /// <summary>
+    /// Primary manifest the run resolved to, so cimiwatcher can watch it when
+    /// ClientIdentifier is a template.
+    /// </summary>
+    [JsonPropertyName("manifest")]
+    public string Manifest { get; set; } = "";
+}
+
+public class LastRunReleaseNote
+{
+    [JsonPropertyName("name")]
+    public string Name { get; set; } = "";
+
+    [JsonPropertyName("display_name")]
+    public string? DisplayName { get; set; }
+
+    [JsonPropertyName("version")]
+    public string Version { get; set; } = "";
+
+    [JsonPropertyName("notes")]
+    public string Notes { get; set; } = "";
+
+    /// <summary>"What's new in Google Chrome 130.0".</summary>
+    public string Heading => $"What's new in {(string.IsNullOrWhiteSpace(DisplayName) ? Name : DisplayName)} {Version}";
+}
+
+public class LastRunDeferral
+{
+    [JsonPropertyName("name")]
+    public string Name { get; set; } = "";
+
+    /// <summary>Catalog name (Name may be the display name); a deferral request names this.</summary>
+    [JsonPropertyName("item")]
+    [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public string? Item { get; set; }
+
+    [JsonPropertyName("version")]
+    public string Version { get; set; } = "";
+
+    [JsonPropertyName("reason")]
+    public string Reason { get; set; } = "";
+
+    [JsonPropertyName("deferrals_used")]
+    public int DeferralsUsed { get; set; }
+
+    [JsonPropertyName("max_deferrals")]
+    public int? MaxDeferrals { get; set; }
+
+    [JsonPropertyName("force_install_after")]
+    public DateTime? ForceInstallAfter { get; set; }
+
+    /// <summary>False once max_deferrals are used up: the next run installs it.</summary>
+    public bool CanDefer => MaxDeferrals is not > 0 || DeferralsUsed < MaxDeferrals;
+
+    /// <summary>"Chrome 120 postponed (2 of 3 deferrals left, installs by 2026-11-01)".</summary>
+    public string Describe()
+    {
+        var limits = new List<string>();
+        if (MaxDeferrals is int max && max > 0)
+        {
+            limits.Add($"{Math.Max(0, max - DeferralsUsed)} of {max} deferrals left");
+        }
+        if (ForceInstallAfter is { } deadline)
+        {
+            limits.Add($"installs by {deadline:yyyy-MM-dd}");
+        }
+        var suffix = limits.Count > 0 ? $" ({string.Join(", ", limits)})" : "";
+        return $"{Name} {Version} postponed{suffix}";
+    }
+}
+
+public static class LastRunStatusStore
+{
+    /// <summary>
+    /// Builds the status for a finished session from SessionLogger's status
+    /// ("completed", "partial_failure", "failed") and summary counts.
+    /// </summary>
+    public static LastRunStatus FromSession(string sessionId, string runType, DateTime start, DateTime end,
+        string status, SessionLogSummary summary, string? message = null,
+        IEnumerable<LastRunDeferral>? deferrals = null, IEnumerable<LastRunReleaseNote>? releaseNotes = null,
+        string? manifest = null)
+    {
+        var outcome = ClassifyOutcome(status, summary.Successes, summary.Failures);
+        return new LastRunStatus
+        {
+            Outcome = outcome,
+            SessionId = sessionId,
+            RunType = runType,
+            StartTime = start,
+            EndTime = end,
+            Installs = summary.Installs,
+            Updates = summary.Updates,
+            Removals = summary.Removals,
+            Successes = summary.Successes,
+            Failures = summary.Failures,
+            Message = string.IsNullOrWhiteSpace(message) ? DescribeOutcome(outcome, summary) : message.Trim(),
+            Degraded = summary.Degraded,
+            Deferrals = deferrals?.ToList() ?? new(),
+            ReleaseNotes = releaseNotes?.ToList() ?? new(),
+            Manifest = manifest ?? ""
+        };
+    }
+
+    public static string ClassifyOutcome(string status, int successes, int failures)
+    {
+        if (string.Equals(status, "failed", StringComparison.OrdinalIgnoreCase))
+        {
+            return "failure";
+        }
+        if (failures == 0)
+        {
+            return "success";
+        }
+        return successes > 0 ? "partial" : "failure";
+    }
+
+    private static string DescribeOutcome(string outcome, SessionLogSummary summary)
+    {
+        if (outcome == "failure" && summary.TotalActions == 0)
+        {
+            return "The update run failed";
+        }
+        if (summary.TotalActions == 0)
+        {
+            return summary.Degraded
+                ? "Software is up to date as of the cached manifests (repository unreachable)"
+                : "Software is up to date";
+        }
+
+        var attempted = summary.Successes + summary.Failures;
+        var text = outcome switch
+        {
+            "success" => $"{summary.TotalActions} item(s) processed successfully",
+            "partial" => $"{summary.Successes} of {attempted} item(s) succeeded, {summary.Failures} failed",
+            _ => $"{summary.Failures} item(s) failed"
+        };
+        return summary.Degraded ? $"{text} (offline: software repository unreachable)" : text;
+    }
+
+    /// <summary>
+    /// Writes via a temp file and rename so a reader never sees half a file.
+    /// </summary>
+    public static void Write(LastRunStatus status, string? path = null)
+    {
+        JsonStateFile.Write(path ?? CimianPaths.LastRunStatusJson, status);
+    }
+
+    public static LastRunStatus? Read(string? path = null)
+    {
+        return JsonStateFile.TryRead<LastRunStatus>(path ?? CimianPaths.LastRunStatusJson);
+    }
+}
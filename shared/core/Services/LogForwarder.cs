@@ -0,0 +1,281 @@
+using System.IO.Pipes;
+using System.Text;
+using System.Text.Json;
+using System.Text.Json.Serialization;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Collects log output from helper processes the agent spawns (per-user context
+/// workers, plugins, script hosts) into the parent's session log. The parent
+/// listens on a named pipe and exports its name in <see cref="PipeEnvVar"/>, which
+/// every child inherits; helpers write JSON lines to it through
+/// <see cref="ForwardedLogWriter"/> (or a plain pipe write from PowerShell). Lines
+/// go through the same SessionLogger lock as the parent's own output, so the
+/// session's install.log / events.jsonl stay in arrival order instead of each
+/// helper writing a file of its own.
+/// </summary>
+public sealed class LogForwarder : IDisposable
+{
+    /// <summary>
+    /// Environment variable carrying the pipe name to child processes.
+    /// </summary>
+    public const string PipeEnvVar = "CIMIAN_LOG_PIPE";
+
+    private static readonly JsonSerializerOptions JsonOptions = new()
+    {
+        PropertyNameCaseInsensitive = true
+    };
+
+    private readonly SessionLogger _sessionLogger;
+    private readonly CancellationTokenSource _cts = new();
+    private Task? _acceptLoop;
+
+    public string PipeName { get; }
+
+    public LogForwarder(SessionLogger sessionLogger, string? pipeName = null)
+    {
+        _sessionLogger = sessionLogger;
+        PipeName = pipeName ?? $"cimian-log-{Environment.ProcessId}-{Guid.NewGuid():N}";
+    }
+
+    /// <summary>
+    /// Starts accepting helper connections and exports the pipe name to this
+    /// process's environment so children inherit it.
+    /// </summary>
+    public void Start()
+    {
+        if (_acceptLoop != null)
+        {
+            return;
+        }
+        Environment.SetEnvironmentVariable(PipeEnvVar, PipeName);
+        _acceptLoop = Task.Run(() => AcceptLoopAsync(_cts.Token));
+    }
+
+    private async Task AcceptLoopAsync(CancellationToken cancellationToken)
+    {
+        while (!cancellationToken.IsCancellationRequested)
+        {
+            NamedPipeServerStream? server = null;
+            try
+            {
+                server = new NamedPipeServerStream(
+                    PipeName,
+                    PipeDirection.In,
+                    NamedPipeServerStream.MaxAllowedServerInstances,
+                    PipeTransmissionMode.Byte,
+                    PipeOptions.Asynchronous);
+                await server.WaitForConnectionAsync(cancellationToken);
+
+                // Hand the connection off and go straight back to accepting, so
+                // concurrent helpers don't queue behind each other
+                var connection = server;
+                server = null;
+                _ = Task.Run(() => ReadConnectionAsync(connection, cancellationToken), CancellationToken.None);
+            }
+            catch (OperationCanceledException)
+            {
+                break;
+            }
+            catch (Exception ex)
+            {
+                _sessionLogger.Log("WARN", $"Log forwarder: {ex.Message}");
+                await Task.Delay(500, CancellationToken.None);
+            }
+            finally
+            {
+                server?.Dispose();
+            }
+        }
+    }
+
+    private async Task ReadConnectionAsync(NamedPipeServerStream connection, CancellationToken cancellationToken)
+    {
+        using (connection)
+        using (var reader = new StreamReader(connection, Encoding.UTF8))
+        {
+            try
+            {
+                string? line;
+                while ((line = await reader.ReadLineAsync(cancellationToken)) != null)
+                {
+                    Dispatch(line);
+                }
+            }
+            catch (OperationCanceledException)
+            {
+                // Session ending
+            }
+            catch (IOException)
+            {
+                // Helper exited mid-write; whatever arrived is already logged
+            }
+        }
+    }
+
+    private void Dispatch(string line)
+    {
+        var record = Parse(line);
+        if (record == null)
+        {
+            return;
+        }
+
+        if (record.Event != null)
+        {
+            _sessionLogger.LogEvent(record.Event);
+        }
+        if (!string.IsNullOrEmpty(record.Message))
+        {
+            _sessionLogger.Log(record.Level, $"[{record.Source}] {record.Message}");
+        }
+    }
+
+    /// <summary>
+    /// Parses one line from a helper. JSON lines carry level/source/message and an
+    /// optional structured event; anything else (a script echoing straight into
+    /// the pipe) is kept verbatim as an INFO line.
+    /// </summary>
+    public static ForwardedLogRecord? Parse(string line)
+    {
+        if (string.IsNullOrWhiteSpace(line))
+        {
+            return null;
+        }
+
+        var trimmed = line.Trim();
+        if (trimmed.StartsWith('{'))
+        {
+            try
+            {
+                var record = JsonSerializer.Deserialize<ForwardedLogRecord>(trimmed, JsonOptions);
+                if (record != null)
+                {
+                    record.Level = NormalizeLevel(record.Level);
+                    record.Source = string.IsNullOrWhiteSpace(record.Source) ? "helper" : record.Source.Trim();
+                    return record;
+                }
+            }
+            catch (JsonException)
+            {
+                // Not one of ours; fall through and keep it as text
+            }
+        }
+
+        return new ForwardedLogRecord { Level = "INFO", Source = "helper", Message = trimmed };
+    }
+
+    private static string NormalizeLevel(string? level) => level?.Trim().ToUpperInvariant() switch
+    {
+        "ERROR" or "ERR" => "ERROR",
+        "WARN" or "WARNING" => "WARN",
+        "DEBUG" or "TRACE" => "DEBUG",
+        _ => "INFO"
+    };
+
+    public void Dispose()
+    {
+        _cts.Cancel();
+        try { _acceptLoop?.Wait(TimeSpan.FromSeconds(2)); } catch { /* shutting down */ }
+        _cts.Dispose();
+        if (Environment.GetEnvironmentVariable(PipeEnvVar) == PipeName)
+        {
+            Environment.SetEnvironmentVariable(PipeEnvVar, null);
+        }
+    }
+}
+
+/// <summary>
+/// One line of helper output as sent over the log pipe.
+/// </summary>
+public class ForwardedLogRecord
+{
+    [JsonPropertyName("level")]
+    public string Level { get; set; } = "INFO";
+
+    [JsonPropertyName("source")]
+    public string Source { get; set; } = "helper";
+
+    [JsonPropertyName("message")]
+    public string Message { get; set; } = "";
+
+    [JsonPropertyName("event")]
+    public LogEvent? Event { get; set; }
+}
+
+/// <summary>
+/// Helper-side end of the log pipe. Connects to the parent named in
+/// <see cref="LogForwarder.PipeEnvVar"/>; when the process wasn't started by a
+/// Cimian session there is nothing to connect to and
+/// <see cref="TryConnectFromEnvironment"/> returns null, so helpers fall back
+/// to their own logging.
+/// </summary>
+public sealed class ForwardedLogWriter : IDisposable
+{
+    private static readonly JsonSerializerOptions JsonOptions = new()
+    {
+        DefaultIgnoreCondition = JsonIgnoreCondition.WhenWritingNull
+    };
+
+    private readonly NamedPipeClientStream _pipe;
+    private readonly StreamWriter _writer;
+    private readonly string _source;
+    private readonly object _lock = new();
+
+    private ForwardedLogWriter(NamedPipeClientStream pipe, string source)
+    {
+        _pipe = pipe;
+        _source = source;
+        _writer = new StreamWriter(pipe, new UTF8Encoding(false)) { AutoFlush = true };
+    }
+
+    public static ForwardedLogWriter? TryConnectFromEnvironment(string source, int timeoutMs = 2000)
+    {
+        var pipeName = Environment.GetEnvironmentVariable(LogForwarder.PipeEnvVar);
+        if (string.IsNullOrEmpty(pipeName))
+        {
+            return null;
+        }
+
+        var pipe = new NamedPipeClientStream(".", pipeName, PipeDirection.Out);
+        try
+        {
+            pipe.Connect(timeoutMs);
+            return new ForwardedLogWriter(pipe, source);
+        }
+        catch (Exception ex) when (ex is TimeoutException or IOException or UnauthorizedAccessException)
+        {
+            pipe.Dispose();
+            return null;
+        }
+    }
+
+    public void Write(string level, string message) =>
+        Send(new ForwardedLogRecord { Level = level, Source = _source, Message = message });
+
+    public void WriteEvent(LogEvent evt) =>
+        Send(new ForwardedLogRecord { Level = evt.Level, Source = _source, Event = evt });
+
+    private void Send(ForwardedLogRecord record)
+    {
+        var json = JsonSerializer.Serialize(record, JsonOptions);
+        lock (_lock)
+        {
+            try
+            {
+                _writer.WriteLine(json);
+            }
+            catch (IOException)
+            {
+                // Parent went away; nothing useful to do from a helper
+            }
+        }
+    }
+
+    public void Dispose()
+    {
+        try { _writer.Dispose(); } catch (IOException) { /* parent gone */ }
+        _pipe.Dispose();
+    }
+}
@@ -0,0 +1,248 @@
+using System.Globalization;
+using System.IO.Compression;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// How many session logs to keep under logs\. 0 turns a limit off.
+/// </summary>
+/// <param name="RetentionDays">Sessions older than this are deleted (LogRetentionDays).</param>
+/// <param name="KeepSessions">The newest sessions, always kept and never compressed.</param>
+/// <param name="MaxLogSizeMB">Oldest sessions are deleted until the session logs fit (MaxLogSizeMB).</param>
+public sealed record LogRetentionPolicy(int RetentionDays, int KeepSessions, int MaxLogSizeMB)
+{
+    public const int DefaultRetentionDays = 30;
+    public const int DefaultKeepSessions = 10;
+}
+
+/// <summary>A session log: a directory, or the zip it was compressed to.</summary>
+public sealed record LogSession(string Path, DateTime Started, long Size, bool Compressed);
+
+/// <summary>What <see cref="LogRetention.Prune"/> did.</summary>
+public sealed record LogPruneResult(int Compressed, int Deleted, long BytesFreed);
+
+/// <summary>
+/// Applies a <see cref="LogRetentionPolicy"/> to the session logs under
+/// logs\YYYY-MM-DD\HHMM (and legacy logs\YYYY-MM-DD-HHMMss). The newest
+/// sessions stay as they are, older ones within the retention window are
+/// zipped in place (HHMM.zip), and the rest are deleted. Other files in
+/// logs\ (cimiwatcher.log, traces\) are left alone.
+/// </summary>
+public static class LogRetention
+{
+    private const string ZipExtension = ".zip";
+
+    /// <summary>
+    /// Compresses and deletes session logs under <paramref name="logsDir"/> per
+    /// <paramref name="policy"/>. <paramref name="activeSessionDir"/> is never touched.
+    /// </summary>
+    public static LogPruneResult Prune(string logsDir, LogRetentionPolicy policy, DateTime now, string? activeSessionDir = null)
+    {
+        var sessions = FindSessions(logsDir)
+            .Where(s => activeSessionDir == null
+                || !string.Equals(Path.GetFullPath(s.Path), Path.GetFullPath(activeSessionDir), StringComparison.OrdinalIgnoreCase))
+            .ToList();
+        var (compress, delete) = Plan(sessions, policy, now);
+
+        var deleted = 0;
+        var bytesFreed = 0L;
+        foreach (var session in delete)
+        {
+            try
+            {
+                if (session.Compressed)
+                {
+                    File.Delete(session.Path);
+                }
+                else
+                {
+                    Directory.Delete(session.Path, recursive: true);
+                }
+                deleted++;
+                bytesFreed += session.Size;
+            }
+            catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+            {
+                ConsoleLogger.Debug($"Could not delete session log {session.Path}: {ex.Message}");
+            }
+        }
+
+        var compressed = 0;
+        foreach (var session in compress)
+        {
+            var zipPath = session.Path + ZipExtension;
+            try
+            {
+                if (File.Exists(zipPath))
+                {
+                    File.Delete(zipPath);
+                }
+                ZipFile.CreateFromDirectory(session.Path, zipPath, CompressionLevel.Optimal, includeBaseDirectory: false);
+                Directory.Delete(session.Path, recursive: true);
+                compressed++;
+                bytesFreed += Math.Max(0, session.Size - new FileInfo(zipPath).Length);
+            }
+            catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+            {
+                // Leave the directory; a half-written zip would only confuse the next prune
+                try { File.Delete(zipPath); } catch { }
+                ConsoleLogger.Debug($"Could not compress session log {session.Path}: {ex.Message}");
+            }
+        }
+
+        RemoveEmptyDayDirectories(logsDir);
+        return new LogPruneResult(compressed, deleted, bytesFreed);
+    }
+
+    /// <summary>
+    /// Every session log under <paramref name="logsDir"/>, compressed or not.
+    /// </summary>
+    public static List<LogSession> FindSessions(string logsDir)
+    {
+        var sessions = new List<LogSession>();
+        if (!Directory.Exists(logsDir))
+        {
+            return sessions;
+        }
+
+        foreach (var entry in Directory.EnumerateFileSystemEntries(logsDir))
+        {
+            var name = Path.GetFileName(entry);
+            if (Directory.Exists(entry) && SessionLogger.IsDayDirectory(name))
+            {
+                foreach (var session in Directory.EnumerateFileSystemEntries(entry))
+                {
+                    if (TryParseStart(session, name) is { } started)
+                    {
+                        sessions.Add(Describe(session, started));
+                    }
+                }
+            }
+            else if (TryParseStart(entry, null) is { } started)
+            {
+                sessions.Add(Describe(entry, started));
+            }
+        }
+
+        return sessions;
+    }
+
+    /// <summary>
+    /// Splits <paramref name="sessions"/> into those to zip and those to delete.
+    /// The newest KeepSessions are kept whatever their age; of the rest, those past
+    /// RetentionDays are deleted and the others compressed. Then the oldest
+    /// remaining sessions go until the total fits MaxLogSizeMB, counting a session
+    /// about to be zipped at its current size.
+    /// </summary>
+    internal static (List<LogSession> Compress, List<LogSession> Delete) Plan(
+        IReadOnlyList<LogSession> sessions, LogRetentionPolicy policy, DateTime now)
+    {
+        var newestFirst = sessions.OrderByDescending(s => s.Started).ToList();
+        var keep = Math.Max(0, policy.KeepSessions);
+        var cutoff = now.AddDays(-policy.RetentionDays);
+
+        var compress = new List<LogSession>();
+        var delete = new List<LogSession>();
+        foreach (var session in newestFirst.Skip(keep))
+        {
+            if (policy.RetentionDays > 0 && session.Started < cutoff)
+            {
+                delete.Add(session);
+            }
+            else if (!session.Compressed)
+            {
+                compress.Add(session);
+            }
+        }
+
+        if (policy.MaxLogSizeMB > 0)
+        {
+            var limit = policy.MaxLogSizeMB * 1024L * 1024L;
+            var total = newestFirst.Except(delete).Sum(s => s.Size);
+            foreach (var session in newestFirst.Skip(keep).Except(delete).Reverse().ToList())
+            {
+                if (total <= limit)
+                {
+                    break;
+                }
+                compress.Remove(session);
+                delete.Add(session);
+                total -= session.Size;
+            }
+        }
+
+        return (compress, delete);
+    }
+
+    // HHMM / HHMM_N (inside a day directory) or YYYY-MM-DD-HHMMss, optionally zipped
+    private static DateTime? TryParseStart(string path, string? day)
+    {
+        var name = Path.GetFileName(path);
+        var zipped = name.EndsWith(ZipExtension, StringComparison.OrdinalIgnoreCase);
+        if (zipped ? !File.Exists(path) : !Directory.Exists(path))
+        {
+            return null;
+        }
+        if (zipped)
+        {
+            name = name[..^ZipExtension.Length];
+        }
+
+        if (day != null)
+        {
+            if (!SessionLogger.IsTimeSessionDirectory(name))
+            {
+                return null;
+            }
+            var started = DateTime.ParseExact($"{day} {name[..4]}", "yyyy-MM-dd HHmm", CultureInfo.InvariantCulture);
+            // Same-minute collisions sort after the first session
+            return name.Length > 4 ? started.AddSeconds(name[5] - '0') : started;
+        }
+
+        return SessionLogger.IsLegacySessionDirectory(name)
+            ? DateTime.ParseExact(name, "yyyy-MM-dd-HHmmss", CultureInfo.InvariantCulture)
+            : null;
+    }
+
+    private static LogSession Describe(string path, DateTime started)
+    {
+        if (File.Exists(path))
+        {
+            return new LogSession(path, started, new FileInfo(path).Length, Compressed: true);
+        }
+
+        long size = 0;
+        try
+        {
+            size = Directory.EnumerateFiles(path, "*", SearchOption.AllDirectories).Sum(f => new FileInfo(f).Length);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Debug($"Could not size session log {path}: {ex.Message}");
+        }
+        return new LogSession(path, started, size, Compressed: false);
+    }
+
+    private static void RemoveEmptyDayDirectories(string logsDir)
+    {
+        if (!Directory.Exists(logsDir))
+        {
+            return;
+        }
+
+        foreach (var dir in Directory.GetDirectories(logsDir).Where(d => SessionLogger.IsDayDirectory(Path.GetFileName(d))))
+        {
+            try
+            {
+                if (!Directory.EnumerateFileSystemEntries(dir).Any())
+                {
+                    Directory.Delete(dir);
+                }
+            }
+            catch
+            {
+                // Ignore - another session may be starting in it
+            }
+        }
+    }
+}
@@ -36,6 +36,12 @@ namespace Cimian.Core.Services;
 /// permanently-suppressed entries (DateTime.MaxValue, written before this cap) are
 /// migrated to a finite window (LoopMaxTime) anchored on their last attempt when first seen.
 ///
+/// Quarantine (separate from backoff): a version that fails to install N times in a row
+/// (QuarantineFailureThreshold) is skipped with reason "quarantined_after_N_failures"
+/// until the catalog offers a different version or fingerprint, or an admin clears it.
+/// Unlike the backoff windows it never retries on its own, so one broken package stops
+/// adding a failed install to every run.
+///
 /// State persisted to: %ProgramData%\ManagedInstalls\reports\state.json
 /// Clear with: managedsoftwareupdate --clear-loop (name or all)
 /// </summary>
@@ -77,6 +83,7 @@ public class LoopGuard
     private readonly bool _isBootstrap;
     private readonly bool _disabled;
     private readonly int _maxSuppressionDays;
+    private readonly int _quarantineThreshold;
 
     /// <summary>
     /// Creates a new LoopGuard.
@@ -85,12 +92,15 @@ public class LoopGuard
     /// kill-switch, driven by the LoopGuardEnabled config setting.
     /// maxSuppressionDays caps the longest suppression window (the global LoopMaxTime config,
     /// in days); a non-positive value falls back to the DefaultMaxSuppressionDays default.
+    /// quarantineThreshold is the number of consecutive failed installs of one version that
+    /// quarantines it (the QuarantineFailureThreshold config); 0 turns quarantine off.
     /// </summary>
-    public LoopGuard(bool isBootstrap = false, bool disabled = false, int maxSuppressionDays = DefaultMaxSuppressionDays)
+    public LoopGuard(bool isBootstrap = false, bool disabled = false, int maxSuppressionDays = DefaultMaxSuppressionDays, int quarantineThreshold = 0)
     {
         _isBootstrap = isBootstrap;
         _disabled = disabled;
         _maxSuppressionDays = maxSuppressionDays > 0 ? maxSuppressionDays : DefaultMaxSuppressionDays;
+        _quarantineThreshold = Math.Max(0, quarantineThreshold);
         _state = LoadState();
         BuildHistoryFromEvents();
     }
@@ -98,11 +108,12 @@ public class LoopGuard
     /// <summary>
     /// For unit testing — constructor that takes custom paths.
     /// </summary>
-    internal LoopGuard(string statePath, string logsDir, bool isBootstrap = false, string? cacheDir = null, bool disabled = false, int maxSuppressionDays = DefaultMaxSuppressionDays)
+    internal LoopGuard(string statePath, string logsDir, bool isBootstrap = false, string? cacheDir = null, bool disabled = false, int maxSuppressionDays = DefaultMaxSuppressionDays, int quarantineThreshold = 0)
     {
         _isBootstrap = isBootstrap;
         _disabled = disabled;
         _maxSuppressionDays = maxSuppressionDays > 0 ? maxSuppressionDays : DefaultMaxSuppressionDays;
+        _quarantineThreshold = Math.Max(0, quarantineThreshold);
         StatePath_Override = statePath;
         LogsDir_Override = logsDir;
         CacheDir_Override = cacheDir;
@@ -164,6 +175,31 @@ public class LoopGuard
         // Check explicit suppression state first (from previous runs)
         if (_state.Packages.TryGetValue(key, out var pkgState))
         {
+            // Quarantine holds until the catalog offers something different — a new
+            // version, or the same version with a changed fingerprint. No time window.
+            if (pkgState.QuarantinedVersion != null)
+            {
+                var sameVersion = string.IsNullOrEmpty(version) ||
+                                  string.Equals(version, pkgState.QuarantinedVersion, StringComparison.OrdinalIgnoreCase);
+                var sameFingerprint = string.IsNullOrEmpty(catalogFingerprint) ||
+                                      string.IsNullOrEmpty(pkgState.CatalogFingerprint) ||
+                                      string.Equals(catalogFingerprint, pkgState.CatalogFingerprint, StringComparison.OrdinalIgnoreCase);
+                if (sameVersion && sameFingerprint)
+                {
+                    return (true, $"QUARANTINED: {packageName} {pkgState.QuarantinedVersion} — {pkgState.QuarantineReason}. Clear with: managedsoftwareupdate --clear-loop {packageName}");
+                }
+
+                var released = pkgState.QuarantinedVersion;
+                ClearQuarantine(pkgState);
+                SaveState();
+                if (!pkgState.SuppressedUntil.HasValue)
+                {
+                    return (false, sameVersion
+                        ? $"Released from quarantine: pkgsinfo fields updated for {version}"
+                        : $"Released from quarantine: catalog version changed from {released} to {version}");
+                }
+            }
+
             if (pkgState.SuppressedUntil.HasValue)
             {
                 // Auto-clear: if the catalog fingerprint changed, ANY install-behavior
@@ -278,6 +314,13 @@ public class LoopGuard
             _state.Packages[key] = pkgState;
         }
 
+        // Consecutive failures count per version: a success, or an attempt at a
+        // different version, starts the run over.
+        if (success || !string.Equals(version, pkgState.LastVersion, StringComparison.OrdinalIgnoreCase))
+            pkgState.ConsecutiveFailures = 0;
+        if (!success)
+            pkgState.ConsecutiveFailures++;
+
         pkgState.AttemptCount++;
         pkgState.LastAttempt = DateTime.UtcNow;
         pkgState.LastVersion = version;
@@ -308,6 +351,17 @@ public class LoopGuard
             pkgState.SuppressionReason = reason;
         }
 
+        if (success)
+        {
+            // An --item run that finally succeeds releases the quarantine
+            ClearQuarantine(pkgState);
+        }
+        else if (_quarantineThreshold > 0 && pkgState.ConsecutiveFailures >= _quarantineThreshold)
+        {
+            pkgState.QuarantinedVersion = version;
+            pkgState.QuarantinedAt = DateTime.UtcNow;
+        }
+
         SaveState();
     }
 
@@ -325,6 +379,7 @@ public class LoopGuard
             pkgState.SessionCount = 0;
             pkgState.VersionAttempts.Clear();
             pkgState.RecentTimestamps.Clear();
+            ClearQuarantine(pkgState);
             SaveState();
             return true;
         }
@@ -336,7 +391,7 @@ public class LoopGuard
     /// </summary>
     public int ClearAll()
     {
-        var count = _state.Packages.Count(p => p.Value.SuppressedUntil.HasValue);
+        var count = _state.Packages.Count(p => p.Value.SuppressedUntil.HasValue || p.Value.QuarantinedVersion != null);
         _state = new LoopGuardState();
         SaveState();
         return count;
@@ -350,6 +405,11 @@ public class LoopGuard
         var result = new List<(string, string, DateTime?)>();
         foreach (var (key, pkgState) in _state.Packages)
         {
+            if (pkgState.QuarantinedVersion != null)
+            {
+                result.Add((pkgState.PackageName, pkgState.QuarantineReason, null));
+                continue;
+            }
             if (pkgState.SuppressedUntil.HasValue &&
                 (pkgState.SuppressedUntil.Value == DateTime.MaxValue || DateTime.UtcNow < pkgState.SuppressedUntil.Value))
             {
@@ -369,6 +429,19 @@ public class LoopGuard
         var result = new List<LoopSuppressedReportItem>();
         foreach (var (_, pkgState) in _state.Packages)
         {
+            if (pkgState.QuarantinedVersion != null)
+            {
+                result.Add(new LoopSuppressedReportItem
+                {
+                    Name         = pkgState.PackageName,
+                    Version      = pkgState.QuarantinedVersion,
+                    Reason       = pkgState.QuarantineReason,
+                    Quarantined  = true,
+                    ClearCommand = $"managedsoftwareupdate --clear-loop {pkgState.PackageName}"
+                });
+                continue;
+            }
+
             if (!pkgState.SuppressedUntil.HasValue) continue;
             var until = pkgState.SuppressedUntil.Value;
             // Indefinite (DateTime.MaxValue) and not-yet-expired entries both qualify.
@@ -792,6 +865,11 @@ public class LoopGuard
             lines.Add($"  Cache: MISS — package not cached");
         }
 
+        if (pkgState.QuarantinedVersion != null)
+        {
+            lines.Add($"  Quarantined: {pkgState.QuarantinedVersion} since {pkgState.QuarantinedAt?.ToString("g") ?? "(unknown)"} ({pkgState.QuarantineReason})");
+        }
+
         if (pkgState.SuppressedUntil.HasValue)
         {
             var until = pkgState.SuppressedUntil.Value == DateTime.MaxValue
@@ -808,6 +886,13 @@ public class LoopGuard
 
     #region Helpers
 
+    private static void ClearQuarantine(PackageLoopState pkgState)
+    {
+        pkgState.QuarantinedVersion = null;
+        pkgState.QuarantinedAt = null;
+        pkgState.ConsecutiveFailures = 0;
+    }
+
     private static string FormatDuration(TimeSpan duration)
     {
         if (duration.TotalDays >= 1)
@@ -890,6 +975,29 @@ public class PackageLoopState
     [JsonPropertyName("recent_timestamps")]
     public List<DateTime> RecentTimestamps { get; set; } = new();
 
+    /// <summary>
+    /// Failed installs in a row of <see cref="LastVersion"/>; reset by a success or a
+    /// different version.
+    /// </summary>
+    [JsonPropertyName("consecutive_failures")]
+    public int ConsecutiveFailures { get; set; }
+
+    /// <summary>
+    /// Version held in quarantine after reaching the failure threshold; null when the
+    /// package is not quarantined.
+    /// </summary>
+    [JsonPropertyName("quarantined_version")]
+    [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public string? QuarantinedVersion { get; set; }
+
+    [JsonPropertyName("quarantined_at")]
+    [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public DateTime? QuarantinedAt { get; set; }
+
+    /// <summary>Report reason for a quarantined package, e.g. quarantined_after_5_failures.</summary>
+    [JsonIgnore]
+    public string QuarantineReason => $"quarantined_after_{ConsecutiveFailures}_failures";
+
     /// <summary>
     /// Tracks which session IDs have been processed to avoid double-counting
     /// when rebuilding from events.jsonl
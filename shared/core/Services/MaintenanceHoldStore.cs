@@ -0,0 +1,154 @@
+using System.Globalization;
+using System.Text.Json.Serialization;
+using System.Text.RegularExpressions;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// A maintenance hold: automatic runs are suspended until <see cref="Until"/>.
+/// Kept at <see cref="CimianPaths.MaintenanceHoldJson"/>, so it survives
+/// restarts and shows up with the rest of the reports.
+/// </summary>
+public class MaintenanceHold
+{
+    [JsonPropertyName("until")]
+    public DateTime Until { get; set; }
+
+    [JsonPropertyName("reason")]
+    public string Reason { get; set; } = "";
+
+    [JsonPropertyName("set_by")]
+    public string SetBy { get; set; } = "";
+
+    [JsonPropertyName("set_at")]
+    public DateTime SetAt { get; set; }
+
+    /// <summary>"Automatic updates paused until 2026-10-20 08:00: Quarter-end close (CONTOSO\admin)".</summary>
+    public string Describe()
+    {
+        var reason = string.IsNullOrWhiteSpace(Reason) ? "" : $": {Reason}";
+        var by = string.IsNullOrWhiteSpace(SetBy) ? "" : $" ({SetBy})";
+        return $"Automatic updates paused until {Until:yyyy-MM-dd HH:mm}{reason}{by}";
+    }
+}
+
+/// <summary>
+/// Sets, reads and ends the maintenance hold. managedsoftwareupdate --pause and
+/// CimianWatcher's pause request both write it; unattended --auto runs (the
+/// hourly task, CimianWatcher's schedule) skip while it lasts, and it ends by
+/// itself at its end time. Runs someone asked for still run.
+/// </summary>
+public static partial class MaintenanceHoldStore
+{
+    /// <summary>Longest a hold may last, so a forgotten one can't stop updates for good.</summary>
+    public static readonly TimeSpan MaxDuration = TimeSpan.FromDays(30);
+
+    [GeneratedRegex(@"^(\d+)\s*([mhd])$", RegexOptions.IgnoreCase)]
+    private static partial Regex DurationPattern();
+
+    /// <summary>
+    /// The end of a hold: a duration from <paramref name="now"/> (90m, 4h, 2d)
+    /// or a date and time (2026-10-20 08:00). Null when it's neither.
+    /// </summary>
+    public static DateTime? ParseUntil(string value, DateTime now)
+    {
+        var trimmed = value.Trim();
+        var match = DurationPattern().Match(trimmed);
+        if (match.Success && int.TryParse(match.Groups[1].Value, out var amount))
+        {
+            return char.ToLowerInvariant(match.Groups[2].Value[0]) switch
+            {
+                'm' => now.AddMinutes(amount),
+                'h' => now.AddHours(amount),
+                _ => now.AddDays(amount)
+            };
+        }
+
+        if (DateTime.TryParse(trimmed, CultureInfo.CurrentCulture, DateTimeStyles.AssumeLocal, out var at)
+            || DateTime.TryParse(trimmed, CultureInfo.InvariantCulture, DateTimeStyles.AssumeLocal, out at))
+        {
+            return at;
+        }
+        return null;
+    }
+
+    /// <summary>Why a hold can't end at <paramref name="until"/>, or null when it can.</summary>
+    public static string? Validate(DateTime until, DateTime now)
+    {
+        if (until <= now)
+        {
+            return "the end time has already passed";
+        }
+        if (until - now > MaxDuration)
+        {
+            return $"a hold lasts at most {MaxDuration.TotalDays:0} days";
+        }
+        return null;
+    }
+
+    /// <summary>Whether a hold skips this run: unattended auto runs only.</summary>
+    public static bool AppliesTo(bool auto, bool bootstrap, bool showStatus) => auto && !bootstrap && !showStatus;
+
+    /// <summary>Starts (or replaces) the hold. Throws <see cref="ArgumentException"/> for a bad end time.</summary>
+    public static MaintenanceHold Set(DateTime until, string? reason, string setBy, DateTime now, string? path = null)
+    {
+        if (Validate(until, now) is { } error)
+        {
+            throw new ArgumentException(error, nameof(until));
+        }
+
+        var hold = new MaintenanceHold
+        {
+            Until = until,
+            Reason = reason?.Trim() ?? "",
+            SetBy = setBy,
+            SetAt = now
+        };
+        path ??= CimianPaths.MaintenanceHoldJson;
+        JsonStateFile.Write(path, hold);
+        return hold;
+    }
+
+    /// <summary>The hold in force at <paramref name="now"/>, or null when there is none or it has ended.</summary>
+    public static MaintenanceHold? Read(DateTime now, string? path = null)
+    {
+        var hold = ReadFile(path ?? CimianPaths.MaintenanceHoldJson);
+        return hold != null && hold.Until > now ? hold : null;
+    }
+
+    /// <summary>Removes a hold whose end time has passed and returns it, so the caller can log that it ended.</summary>
+    public static MaintenanceHold? Expire(DateTime now, string? path = null)
+    {
+        path ??= CimianPaths.MaintenanceHoldJson;
+        var hold = ReadFile(path);
+        if (hold == null || hold.Until > now)
+        {
+            return null;
+        }
+        Delete(path);
+        return hold;
+    }
+
+    /// <summary>Ends the hold now. Returns the hold that was in force, if any.</summary>
+    public static MaintenanceHold? Clear(DateTime now, string? path = null)
+    {
+        path ??= CimianPaths.MaintenanceHoldJson;
+        var hold = Read(now, path);
+        Delete(path);
+        return hold;
+    }
+
+    private static MaintenanceHold? ReadFile(string path) => JsonStateFile.TryRead<MaintenanceHold>(path);
+
+    private static void Delete(string path)
+    {
+        try
+        {
+            File.Delete(path);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Debug($"Could not remove maintenance hold: {ex.Message}");
+        }
+    }
+}
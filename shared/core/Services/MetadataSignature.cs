@@ -0,0 +1,146 @@
+using System.Security.Cryptography;
+using System.Text;
+
+namespace Cimian.Core.Services;
+
+/// <summary>How a manifest or catalog's signature checked out.</summary>
+public enum MetadataSignatureStatus
+{
+    /// <summary>Signed by one of the pinned keys.</summary>
+    Valid,
+
+    /// <summary>No signature, embedded or detached.</summary>
+    Unsigned,
+
+    /// <summary>A signature that no pinned key verifies: tampered or signed by someone else.</summary>
+    Invalid
+}
+
+/// <summary>
+/// Signing rules for repo metadata (manifests and catalogs), shared by
+/// makecatalogs, which signs, and managedsoftwareupdate, which verifies against
+/// the keys pinned in MetadataSigningKeys. A file is signed either by a
+/// detached &lt;file&gt;.sig next to it, or by a last line of the form
+/// "# cimian-signature: &lt;base64&gt;" covering everything before it. Both hold
+/// a SHA-256 signature of the UTF-8 text: ECDSA (IEEE P1363) or RSA PKCS#1 v1.5,
+/// depending on the key.
+/// </summary>
+public static class MetadataSignature
+{
+    /// <summary>Extension of a detached signature file.</summary>
+    public const string DetachedExtension = ".sig";
+
+    /// <summary>Prefix of the embedded signature line. A YAML comment, so the file still parses.</summary>
+    public const string EmbeddedPrefix = "# cimian-signature:";
+
+    /// <summary>
+    /// Splits an embedded signature off <paramref name="content"/>. Returns the
+    /// signature, and in <paramref name="signedContent"/> the text it covers; null
+    /// (with the content unchanged) when there is no signature line.
+    /// </summary>
+    public static string? ExtractEmbedded(string content, out string signedContent)
+    {
+        signedContent = content;
+        var trimmed = content.TrimEnd('\r', '\n');
+        var lineStart = trimmed.LastIndexOf('\n') + 1;
+        var lastLine = trimmed[lineStart..];
+        if (!lastLine.StartsWith(EmbeddedPrefix, StringComparison.Ordinal))
+        {
+            return null;
+        }
+
+        signedContent = content[..lineStart];
+        return lastLine[EmbeddedPrefix.Length..].Trim();
+    }
+
+    /// <summary>
+    /// Checks <paramref name="content"/> against its embedded signature, or
+    /// <paramref name="detachedSignature"/> when it has none.
+    /// </summary>
+    public static MetadataSignatureStatus Verify(string content, string? detachedSignature, IReadOnlyList<AsymmetricAlgorithm> keys)
+    {
+        var signature = ExtractEmbedded(content, out var signedContent) ?? detachedSignature?.Trim();
+        if (string.IsNullOrEmpty(signature))
+        {
+            return MetadataSignatureStatus.Unsigned;
+        }
+
+        byte[] signatureBytes;
+        try
+        {
+            signatureBytes = Convert.FromBase64String(signature);
+        }
+        catch (FormatException)
+        {
+            return MetadataSignatureStatus.Invalid;
+        }
+
+        var data = Encoding.UTF8.GetBytes(signedContent);
+        foreach (var key in keys)
+        {
+            var valid = key switch
+            {
+                ECDsa ecdsa => ecdsa.VerifyData(data, signatureBytes, HashAlgorithmName.SHA256),
+                RSA rsa => rsa.VerifyData(data, signatureBytes, HashAlgorithmName.SHA256, RSASignaturePadding.Pkcs1),
+                _ => false
+            };
+            if (valid)
+            {
+                return MetadataSignatureStatus.Valid;
+            }
+        }
+        return MetadataSignatureStatus.Invalid;
+    }
+
+    /// <summary>
+    /// The base64 signature of <paramref name="content"/> with <paramref name="privateKey"/>,
+    /// for a detached .sig file.
+    /// </summary>
+    public static string Sign(string content, AsymmetricAlgorithm privateKey)
+    {
+        var data = Encoding.UTF8.GetBytes(content);
+        var signature = privateKey switch
+        {
+            ECDsa ecdsa => ecdsa.SignData(data, HashAlgorithmName.SHA256),
+            RSA rsa => rsa.SignData(data, HashAlgorithmName.SHA256, RSASignaturePadding.Pkcs1),
+            _ => throw new NotSupportedException($"Unsupported signing key type {privateKey.GetType().Name}")
+        };
+        return Convert.ToBase64String(signature);
+    }
+
+    /// <summary>
+    /// An ECDSA or RSA key from PEM text, or from the PEM file it names. Public
+    /// keys verify; private keys (PKCS#8, SEC1 or PKCS#1) also sign.
+    /// </summary>
+    public static AsymmetricAlgorithm LoadKey(string pemOrPath)
+    {
+        var pem = pemOrPath.Contains("-----BEGIN", StringComparison.Ordinal) ? pemOrPath : File.ReadAllText(pemOrPath);
+        var isRsa = pem.Contains("RSA ", StringComparison.Ordinal);
+        if (!isRsa)
+        {
+            var ecdsa = ECDsa.Create();
+            try
+            {
+                ecdsa.ImportFromPem(pem);
+                return ecdsa;
+            }
+            catch (CryptographicException)
+            {
+                // A SubjectPublicKeyInfo / PKCS#8 PEM doesn't say which; try RSA next
+                ecdsa.Dispose();
+            }
+        }
+
+        var rsa = RSA.Create();
+        try
+        {
+            rsa.ImportFromPem(pem);
+            return rsa;
+        }
+        catch (Exception ex) when (ex is CryptographicException or ArgumentException)
+        {
+            rsa.Dispose();
+            throw new CryptographicException($"Not an ECDSA or RSA key in PEM format: {ex.Message}", ex);
+        }
+    }
+}
@@ -0,0 +1,77 @@
+using Microsoft.Win32;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Reboots Windows itself is waiting on, independent of anything Cimian
+/// installed: servicing stack, Windows Update, queued file renames and
+/// ConfigMgr. Reported in session.json and used to hold installs until the
+/// machine has restarted.
+/// </summary>
+public static class PendingReboot
+{
+    public const string ComponentServicing = "cbs_reboot_pending";
+    public const string WindowsUpdate = "windows_update";
+    public const string FileRenameOperations = "pending_file_rename";
+    public const string ConfigMgr = "configmgr";
+
+    private const string CbsKey = @"SOFTWARE\Microsoft\Windows\CurrentVersion\Component Based Servicing\RebootPending";
+    private const string WindowsUpdateKey = @"SOFTWARE\Microsoft\Windows\CurrentVersion\WindowsUpdate\Auto Update\RebootRequired";
+    private const string SessionManagerKey = @"SYSTEM\CurrentControlSet\Control\Session Manager";
+    private const string ConfigMgrKey = @"SOFTWARE\Microsoft\CCM\ClientSDK\InProgress";
+
+    /// <summary>
+    /// Every pending-reboot source currently set; empty when none are.
+    /// </summary>
+    public static List<string> GetReasons()
+    {
+        var reasons = new List<string>();
+        try
+        {
+            if (KeyExists(CbsKey))
+            {
+                reasons.Add(ComponentServicing);
+            }
+            if (KeyExists(WindowsUpdateKey))
+            {
+                reasons.Add(WindowsUpdate);
+            }
+
+            using (var sessionManager = Registry.LocalMachine.OpenSubKey(SessionManagerKey))
+            {
+                if (sessionManager?.GetValue("PendingFileRenameOperations") is string[] { Length: > 0 })
+                {
+                    reasons.Add(FileRenameOperations);
+                }
+            }
+
+            using (var ccm = Registry.LocalMachine.OpenSubKey(ConfigMgrKey))
+            {
+                if (ccm?.GetValue("RebootRequired") != null)
+                {
+                    reasons.Add(ConfigMgr);
+                }
+            }
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Debug($"Pending reboot check failed: {ex.Message}");
+        }
+        return reasons;
+    }
+
+    public static string Describe(string reason) => reason switch
+    {
+        ComponentServicing => "Windows servicing (CBS) is waiting for a restart",
+        WindowsUpdate => "Windows Update is waiting for a restart",
+        FileRenameOperations => "files are queued to be replaced at restart",
+        ConfigMgr => "Configuration Manager is waiting for a restart",
+        _ => reason
+    };
+
+    private static bool KeyExists(string path)
+    {
+        using var key = Registry.LocalMachine.OpenSubKey(path);
+        return key != null;
+    }
+}
@@ -0,0 +1,264 @@
+using System.Collections.Concurrent;
+using System.Net;
+using System.Runtime.InteropServices;
+using System.Text.RegularExpressions;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Proxy keys from Config.yaml. ProxyURL wins over ProxyPACURL, which wins over
+/// the system proxy; with UseSystemProxy off and neither set, requests go direct.
+/// </summary>
+public sealed record ProxySettings(
+    string? Url,
+    IReadOnlyList<string> BypassList,
+    bool UseSystemProxy,
+    string? PacUrl,
+    string? User,
+    string? Password,
+    bool UseDefaultCredentials)
+{
+    /// <summary>manual, pac, system or direct.</summary>
+    public string Mode =>
+        !string.IsNullOrWhiteSpace(Url) ? "manual"
+        : !string.IsNullOrWhiteSpace(PacUrl) ? "pac"
+        : UseSystemProxy ? "system"
+        : "direct";
+
+    /// <summary>One line for logs and session.json, without credentials.</summary>
+    public string Describe()
+    {
+        var description = Mode switch
+        {
+            "manual" => $"manual {Url}",
+            "pac" => $"pac {PacUrl}",
+            _ => Mode
+        };
+        if (Mode is "manual" && BypassList.Count > 0)
+        {
+            description += $" (bypass {string.Join(", ", BypassList)})";
+        }
+        if (!string.IsNullOrEmpty(User))
+        {
+            description += $", authenticating as {User}";
+        }
+        else if (UseDefaultCredentials && Mode != "direct")
+        {
+            description += ", authenticating with the machine account";
+        }
+        return description;
+    }
+}
+
+/// <summary>
+/// Applies <see cref="ProxySettings"/> to an HttpClientHandler the same way for
+/// every HTTP client Cimian creates (manifests, catalogs, downloads, icons, the
+/// watcher), and records which proxy each host was sent through. The first
+/// choice for each host is logged; <see cref="Routes"/> goes into session.json.
+/// </summary>
+public static class ProxySelector
+{
+    internal const string Direct = "DIRECT";
+
+    private static readonly ConcurrentDictionary<string, string> RouteLog = new(StringComparer.OrdinalIgnoreCase);
+
+    /// <summary>Host to proxy (or DIRECT) for every host contacted so far in this process.</summary>
+    public static IReadOnlyDictionary<string, string> Routes => new SortedDictionary<string, string>(RouteLog, StringComparer.OrdinalIgnoreCase);
+
+    public static void Apply(HttpClientHandler handler, ProxySettings settings)
+    {
+        if (settings.Mode == "direct")
+        {
+            handler.UseProxy = false;
+            return;
+        }
+
+        IWebProxy inner = settings.Mode switch
+        {
+            "manual" => CreateManualProxy(settings),
+            "pac" => new PacWebProxy(settings.PacUrl!),
+            _ => HttpClient.DefaultProxy
+        };
+
+        handler.UseProxy = true;
+        handler.Proxy = new RecordingWebProxy(inner, settings.Mode)
+        {
+            Credentials = !string.IsNullOrEmpty(settings.User)
+                ? new NetworkCredential(settings.User, settings.Password)
+                : settings.UseDefaultCredentials ? CredentialCache.DefaultNetworkCredentials : inner.Credentials
+        };
+    }
+
+    private static WebProxy CreateManualProxy(ProxySettings settings)
+    {
+        var bypassLocal = settings.BypassList.Any(b => b.Trim().Equals("<local>", StringComparison.OrdinalIgnoreCase));
+        var patterns = settings.BypassList
+            .Where(b => !string.IsNullOrWhiteSpace(b) && !b.Trim().Equals("<local>", StringComparison.OrdinalIgnoreCase))
+            .Select(ToBypassRegex)
+            .ToArray();
+        return new WebProxy(new Uri(settings.Url!), bypassLocal, patterns);
+    }
+
+    /// <summary>
+    /// WebProxy matches its bypass list as regular expressions against the whole
+    /// URI; administrators write host wildcards ("*.corp.example.com", "10.*").
+    /// </summary>
+    internal static string ToBypassRegex(string pattern)
+    {
+        var host = pattern.Trim();
+        var schemeEnd = host.IndexOf("://", StringComparison.Ordinal);
+        if (schemeEnd >= 0)
+        {
+            host = host[(schemeEnd + 3)..];
+        }
+        return "^[a-z]+://" + Regex.Escape(host).Replace(@"\*", ".*") + "(:\\d+)?(/.*)?$";
+    }
+
+    /// <summary>
+    /// First proxy in a WinHTTP proxy list ("proxy1:8080;proxy2:8080",
+    /// "http=proxy:8080;https=proxy:8443"), preferring the entry for
+    /// <paramref name="scheme"/>. Null when the list is empty.
+    /// </summary>
+    internal static Uri? ParseWinHttpProxyList(string? list, string scheme)
+    {
+        if (string.IsNullOrWhiteSpace(list))
+        {
+            return null;
+        }
+
+        var entries = list.Split([';', ' '], StringSplitOptions.RemoveEmptyEntries);
+        var chosen = entries.FirstOrDefault(e => e.StartsWith(scheme + "=", StringComparison.OrdinalIgnoreCase))
+            ?? entries.FirstOrDefault(e => !e.Contains('='))
+            ?? entries[0];
+        var equals = chosen.IndexOf('=');
+        if (equals >= 0)
+        {
+            chosen = chosen[(equals + 1)..];
+        }
+        return Uri.TryCreate(chosen.Contains("://") ? chosen : "http://" + chosen, UriKind.Absolute, out var proxy) ? proxy : null;
+    }
+
+    internal static void Record(Uri destination, Uri? proxy, string mode)
+    {
+        var route = proxy?.ToString() ?? Direct;
+        if (RouteLog.TryGetValue(destination.Host, out var previous) && previous == route)
+        {
+            return;
+        }
+        RouteLog[destination.Host] = route;
+        ConsoleLogger.Debug($"Proxy selection: {destination.Host} -> {route} (mode {mode})");
+    }
+
+    /// <summary>Wraps the real proxy to record each host's route.</summary>
+    private sealed class RecordingWebProxy(IWebProxy inner, string mode) : IWebProxy
+    {
+        public ICredentials? Credentials { get; set; }
+
+        public Uri? GetProxy(Uri destination)
+        {
+            var proxy = inner.IsBypassed(destination) ? null : inner.GetProxy(destination);
+            if (proxy != null && proxy.Equals(destination))
+            {
+                proxy = null;
+            }
+            Record(destination, proxy, mode);
+            return proxy;
+        }
+
+        public bool IsBypassed(Uri host) => GetProxy(host) == null;
+    }
+
+    /// <summary>
+    /// Resolves proxies from a PAC script through WinHTTP, which downloads and
+    /// runs it; answers are cached per scheme and host for the process.
+    /// </summary>
+    private sealed class PacWebProxy(string pacUrl) : IWebProxy
+    {
+        private const int WinHttpAccessTypeNoProxy = 1;
+        private const int WinHttpAccessTypeNamedProxy = 3;
+        private const int WinHttpAutoProxyConfigUrl = 0x2;
+
+        private readonly ConcurrentDictionary<string, Uri?> _cache = new(StringComparer.OrdinalIgnoreCase);
+
+        public ICredentials? Credentials { get; set; }
+
+        [StructLayout(LayoutKind.Sequential, CharSet = CharSet.Unicode)]
+        private struct WinHttpAutoProxyOptions
+        {
+            public int dwFlags;
+            public int dwAutoDetectFlags;
+            public string? lpszAutoConfigUrl;
+            public IntPtr lpvReserved;
+            public int dwReserved;
+            [MarshalAs(UnmanagedType.Bool)]
+            public bool fAutoLogonIfChallenged;
+        }
+
+        [StructLayout(LayoutKind.Sequential)]
+        private struct WinHttpProxyInfo
+        {
+            public int dwAccessType;
+            public IntPtr lpszProxy;
+            public IntPtr lpszProxyBypass;
+        }
+
+        [DllImport("winhttp.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        private static extern IntPtr WinHttpOpen(string? agent, int accessType, string? proxyName, string? proxyBypass, int flags);
+
+        [DllImport("winhttp.dll", SetLastError = true, CharSet = CharSet.Unicode)]
+        [return: MarshalAs(UnmanagedType.Bool)]
+        private static extern bool WinHttpGetProxyForUrl(IntPtr session, string url, ref WinHttpAutoProxyOptions options, out WinHttpProxyInfo info);
+
+        [DllImport("winhttp.dll")]
+        private static extern bool WinHttpCloseHandle(IntPtr handle);
+
+        [DllImport("kernel32.dll")]
+        private static extern IntPtr GlobalFree(IntPtr handle);
+
+        public Uri? GetProxy(Uri destination) =>
+            _cache.GetOrAdd($"{destination.Scheme}://{destination.Host}", _ => Resolve(destination));
+
+        public bool IsBypassed(Uri host) => GetProxy(host) == null;
+
+        private Uri? Resolve(Uri destination)
+        {
+            var session = WinHttpOpen("Cimian", WinHttpAccessTypeNoProxy, null, null, 0);
+            if (session == IntPtr.Zero)
+            {
+                ConsoleLogger.Warn($"Could not open WinHTTP to evaluate {pacUrl} (error {Marshal.GetLastWin32Error()}); going direct");
+                return null;
+            }
+
+            try
+            {
+                var options = new WinHttpAutoProxyOptions
+                {
+                    dwFlags = WinHttpAutoProxyConfigUrl,
+                    lpszAutoConfigUrl = pacUrl,
+                    fAutoLogonIfChallenged = true
+                };
+                if (!WinHttpGetProxyForUrl(session, destination.ToString(), ref options, out var info))
+                {
+                    ConsoleLogger.Warn($"PAC file {pacUrl} gave no answer for {destination.Host} (error {Marshal.GetLastWin32Error()}); going direct");
+                    return null;
+                }
+
+                try
+                {
+                    return info.dwAccessType == WinHttpAccessTypeNamedProxy
+                        ? ParseWinHttpProxyList(Marshal.PtrToStringUni(info.lpszProxy), destination.Scheme)
+                        : null;
+                }
+                finally
+                {
+                    if (info.lpszProxy != IntPtr.Zero) GlobalFree(info.lpszProxy);
+                    if (info.lpszProxyBypass != IntPtr.Zero) GlobalFree(info.lpszProxyBypass);
+                }
+            }
+            finally
+            {
+                WinHttpCloseHandle(session);
+            }
+        }
+    }
+}
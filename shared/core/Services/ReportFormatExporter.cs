@@ -0,0 +1,225 @@
+using System.Collections;
+using System.Globalization;
+using System.Text.Json.Nodes;
+using System.Xml;
+using System.Xml.Linq;
+using Cimian.Core.Models;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Everything one run reported, as the format exporters read it.
+/// </summary>
+public sealed record ReportSnapshot(
+    SessionData Session,
+    IReadOnlyList<ItemRecord> Items,
+    IReadOnlyList<LogEvent> Events,
+    IReadOnlyDictionary<string, object?>? Facts,
+    string ManifestName,
+    string AgentVersion);
+
+/// <summary>
+/// Writes a run's report in formats other tools already read (ReportFormats in
+/// Config.yaml), so dashboards built for Munki fleets or osquery can take Cimian
+/// data with little adaptation:
+///
+/// - munkireport: ManagedInstallReport.plist with the keys Munki's own report
+///   has (ManagedInstalls, InstallResults, ProblemInstalls, Errors, ...).
+/// - osquery: cimian_osquery.json, one array of flat rows per table
+///   (cimian_info, cimian_items, cimian_errors) for json_each or ATC.
+/// </summary>
+public static class ReportFormatExporter
+{
+    public const string MunkiReportFileName = "ManagedInstallReport.plist";
+    public const string OsqueryFileName = "cimian_osquery.json";
+
+    public static void Write(string reportsDir, IEnumerable<string> formats, ReportSnapshot snapshot)
+    {
+        foreach (var format in formats.Select(ReportFormat.Normalize).OfType<string>().Distinct())
+        {
+            switch (format)
+            {
+                case ReportFormat.MunkiReport:
+                    var plist = BuildManagedInstallReport(snapshot);
+                    using (var writer = XmlWriter.Create(Path.Combine(reportsDir, MunkiReportFileName),
+                        new XmlWriterSettings { Indent = true, Encoding = new System.Text.UTF8Encoding(false) }))
+                    {
+                        plist.Save(writer);
+                    }
+                    break;
+
+                case ReportFormat.Osquery:
+                    File.WriteAllText(Path.Combine(reportsDir, OsqueryFileName),
+                        BuildOsquerySnapshot(snapshot).ToJsonString(new System.Text.Json.JsonSerializerOptions { WriteIndented = true }));
+                    break;
+            }
+        }
+    }
+
+    /// <summary>The run as Munki's ManagedInstallReport.plist.</summary>
+    public static XDocument BuildManagedInstallReport(ReportSnapshot snapshot)
+    {
+        var session = snapshot.Session;
+        var items = snapshot.Items.Where(i => i.CurrentStatus != "Removed").ToList();
+        var touched = snapshot.Items.Where(i => i.LastSeenInSession == session.SessionId).ToList();
+
+        var report = new Dictionary<string, object?>
+        {
+            ["ManagedInstallVersion"] = snapshot.AgentVersion,
+            ["ManifestName"] = snapshot.ManifestName,
+            ["RunType"] = session.RunType,
+            ["StartTime"] = MunkiTime(session.StartTime),
+            ["EndTime"] = MunkiTime(session.EndTime),
+            ["Errors"] = Messages(snapshot.Events, "ERROR"),
+            ["Warnings"] = Messages(snapshot.Events, "WARN"),
+            ["ManagedInstalls"] = items.Select(i => new Dictionary<string, object?>
+            {
+                ["name"] = i.ItemName,
+                ["display_name"] = DisplayName(i),
+                ["installed"] = i.CurrentStatus == "Installed",
+                ["installed_version"] = i.InstalledVersion,
+                ["version_to_install"] = i.CurrentStatus == "Installed" ? null : i.LatestVersion
+            }).ToList(),
+            ["ItemsToInstall"] = items.Where(i => i.CurrentStatus == "Pending").Select(ToInstall).ToList(),
+            ["ProblemInstalls"] = items.Where(i => i.CurrentStatus == "Error").Select(i =>
+            {
+                var problem = ToInstall(i);
+                problem["note"] = i.LastError;
+                return problem;
+            }).ToList(),
+            ["InstallResults"] = touched.Where(i => i.CurrentStatus is "Installed" or "Error").Select(Result).ToList(),
+            ["RemovalResults"] = touched.Where(i => i.CurrentStatus == "Removed").Select(Result).ToList(),
+            ["MachineInfo"] = new Dictionary<string, object?>
+            {
+                ["hostname"] = Fact(snapshot.Facts, "hostname"),
+                ["os_vers"] = Fact(snapshot.Facts, "os_version"),
+                ["arch"] = Fact(snapshot.Facts, "arch"),
+                ["serial_number"] = Fact(snapshot.Facts, "serial_number"),
+                ["machine_model"] = Fact(snapshot.Facts, "machine_model")
+            }
+        };
+        if (snapshot.Facts?.GetValueOrDefault("free_disk_gb") is { } freeGb
+            && double.TryParse(Convert.ToString(freeGb, CultureInfo.InvariantCulture), NumberStyles.Float, CultureInfo.InvariantCulture, out var gb))
+        {
+            // Munki reports free space in KB
+            report["AvailableDiskSpace"] = (long)(gb * 1024 * 1024);
+        }
+
+        return new XDocument(
+            new XDeclaration("1.0", "UTF-8", null),
+            new XDocumentType("plist", "-//Apple//DTD PLIST 1.0//EN", "http://www.apple.com/DTDs/PropertyList-1.0.dtd", null),
+            new XElement("plist", new XAttribute("version", "1.0"), PlistValue(report)));
+    }
+
+    /// <summary>The run as osquery-readable tables of flat rows.</summary>
+    public static JsonObject BuildOsquerySnapshot(ReportSnapshot snapshot)
+    {
+        var session = snapshot.Session;
+        var summary = session.Summary;
+        var info = new JsonObject
+        {
+            ["session_id"] = session.SessionId,
+            ["run_type"] = session.RunType,
+            ["status"] = session.Status,
+            ["start_time"] = session.StartTime,
+            ["end_time"] = session.EndTime ?? "",
+            ["duration_seconds"] = session.DurationSeconds ?? 0,
+            ["installs"] = summary?.Installs ?? 0,
+            ["updates"] = summary?.Updates ?? 0,
+            ["removals"] = summary?.Removals ?? 0,
+            ["failures"] = summary?.Failures ?? 0,
+            ["manifest"] = snapshot.ManifestName,
+            ["agent_version"] = snapshot.AgentVersion
+        };
+
+        var items = new JsonArray();
+        foreach (var item in snapshot.Items)
+        {
+            items.Add(new JsonObject
+            {
+                ["name"] = item.ItemName,
+                ["display_name"] = DisplayName(item),
+                ["type"] = item.ItemType,
+                ["status"] = item.CurrentStatus,
+                ["installed_version"] = item.InstalledVersion ?? "",
+                ["latest_version"] = item.LatestVersion,
+                ["last_attempt_time"] = item.LastAttemptTime,
+                ["last_attempt_status"] = item.LastAttemptStatus,
+                ["last_error"] = item.LastError,
+                ["failure_count"] = item.FailureCount,
+                ["install_loop"] = item.InstallLoopDetected ? 1 : 0
+            });
+        }
+
+        var errors = new JsonArray();
+        foreach (var evt in snapshot.Events.Where(e => e.Level == "ERROR" || e.Status == "failed"))
+        {
+            errors.Add(new JsonObject
+            {
+                ["timestamp"] = evt.Timestamp.ToUniversalTime().ToString("yyyy-MM-ddTHH:mm:ssZ", CultureInfo.InvariantCulture),
+                ["level"] = evt.Level,
+                ["event_type"] = evt.EventType,
+                ["package"] = evt.PackageName ?? "",
+                ["message"] = evt.Error ?? evt.Message
+            });
+        }
+
+        return new JsonObject
+        {
+            ["cimian_info"] = new JsonArray(info),
+            ["cimian_items"] = items,
+            ["cimian_errors"] = errors
+        };
+    }
+
+    private static string DisplayName(ItemRecord item) =>
+        string.IsNullOrEmpty(item.DisplayName) ? item.ItemName : item.DisplayName;
+
+    private static Dictionary<string, object?> ToInstall(ItemRecord item) => new()
+    {
+        ["name"] = item.ItemName,
+        ["display_name"] = DisplayName(item),
+        ["version_to_install"] = item.LatestVersion
+    };
+
+    /// <summary>An InstallResults/RemovalResults entry; status 0 is success, as in Munki.</summary>
+    private static Dictionary<string, object?> Result(ItemRecord item) => new()
+    {
+        ["name"] = item.ItemName,
+        ["display_name"] = DisplayName(item),
+        ["version"] = item.CurrentStatus == "Removed" ? item.InstalledVersion : item.LatestVersion,
+        ["status"] = item.CurrentStatus == "Error" ? 1 : 0,
+        ["time"] = DateTime.TryParse(item.LastAttemptTime, CultureInfo.InvariantCulture, DateTimeStyles.AdjustToUniversal, out var time) ? time : null
+    };
+
+    private static List<string> Messages(IEnumerable<LogEvent> events, string level) =>
+        events.Where(e => e.Level == level)
+            .Select(e => string.IsNullOrEmpty(e.PackageName) ? e.Message : $"{e.PackageName}: {e.Error ?? e.Message}")
+            .Distinct()
+            .ToList();
+
+    private static string? Fact(IReadOnlyDictionary<string, object?>? facts, string key) =>
+        facts?.GetValueOrDefault(key) is { } value ? Convert.ToString(value, CultureInfo.InvariantCulture) : null;
+
+    /// <summary>"2026-10-16 09:00:00 +0000", the timestamp format of Munki's report.</summary>
+    private static string? MunkiTime(string? isoTime) =>
+        DateTimeOffset.TryParse(isoTime, CultureInfo.InvariantCulture, DateTimeStyles.None, out var time)
+            ? time.ToUniversalTime().ToString("yyyy-MM-dd HH:mm:ss +0000", CultureInfo.InvariantCulture)
+            : null;
+
+    /// <summary>A plist element for a string, number, bool, date, list or dictionary; null for null.</summary>
+    private static XElement? PlistValue(object? value) => value switch
+    {
+        null => null,
+        string s => new XElement("string", s),
+        bool b => new XElement(b ? "true" : "false"),
+        int or long => new XElement("integer", Convert.ToString(value, CultureInfo.InvariantCulture)),
+        double d => new XElement("real", d.ToString(CultureInfo.InvariantCulture)),
+        DateTime dt => new XElement("date", dt.ToUniversalTime().ToString("yyyy-MM-ddTHH:mm:ssZ", CultureInfo.InvariantCulture)),
+        IDictionary<string, object?> dict => new XElement("dict", dict
+            .Where(kv => kv.Value != null)
+            .SelectMany(kv => new object?[] { new XElement("key", kv.Key), PlistValue(kv.Value) })),
+        IEnumerable list => new XElement("array", list.Cast<object?>().Select(PlistValue)),
+        _ => new XElement("string", Convert.ToString(value, CultureInfo.InvariantCulture))
+    };
+}
@@ -0,0 +1,80 @@
+using System.Text.Json.Serialization;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// A restart Cimian still owes the machine, kept at
+/// <see cref="CimianPaths.RestartStateJson"/> so CimianStatus can show the
+/// countdown (or ask the user to restart) after managedsoftwareupdate exits.
+/// </summary>
+public class RestartState
+{
+    [JsonPropertyName("recorded_at")]
+    public DateTime RecordedAt { get; set; }
+
+    /// <summary>RestartPolicy in effect: countdown, immediate, prompt or never.</summary>
+    [JsonPropertyName("policy")]
+    public string Policy { get; set; } = "";
+
+    /// <summary>When shutdown.exe will restart; null when nothing is scheduled.</summary>
+    [JsonPropertyName("scheduled_for")]
+    public DateTime? ScheduledFor { get; set; }
+
+    /// <summary>Items whose restart_action, requires_restart or 3010/1641 exit asked for it.</summary>
+    [JsonPropertyName("items")]
+    public List<string> Items { get; set; } = new();
+
+    /// <summary>Windows' own pending-reboot sources (see <see cref="PendingReboot"/>).</summary>
+    [JsonPropertyName("pending_reasons")]
+    public List<string> PendingReasons { get; set; } = new();
+
+    /// <summary>"Restarting in 4:32", or a request to restart when nothing is scheduled.</summary>
+    public string Describe(DateTime now)
+    {
+        if (ScheduledFor is { } at)
+        {
+            var left = at - now;
+            return left > TimeSpan.Zero
+                ? $"Restarting in {(int)left.TotalMinutes}:{left.Seconds:D2} to finish installing updates"
+                : "Restarting to finish installing updates";
+        }
+        return Items.Count > 0
+            ? $"Restart required to finish installing {string.Join(", ", Items)}"
+            : "Restart required to finish installing updates";
+    }
+}
+
+public static class RestartStateStore
+{
+    public static void Write(RestartState state, string? path = null)
+    {
+        JsonStateFile.Write(path ?? CimianPaths.RestartStateJson, state);
+    }
+
+    /// <summary>
+    /// The owed restart, or null when there is none or the machine has
+    /// restarted since it was recorded.
+    /// </summary>
+    public static RestartState? Read(string? path = null, DateTime? lastBoot = null)
+    {
+        var state = JsonStateFile.TryRead<RestartState>(path ?? CimianPaths.RestartStateJson);
+        return state != null && !IsSatisfied(state, lastBoot ?? LastBootTime()) ? state : null;
+    }
+
+    public static void Clear(string? path = null)
+    {
+        try
+        {
+            File.Delete(path ?? CimianPaths.RestartStateJson);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Debug($"Could not remove restart state: {ex.Message}");
+        }
+    }
+
+    /// <summary>A restart recorded before the last boot has already happened.</summary>
+    public static bool IsSatisfied(RestartState state, DateTime lastBoot) => state.RecordedAt < lastBoot;
+
+    public static DateTime LastBootTime() => DateTime.Now - TimeSpan.FromMilliseconds(Environment.TickCount64);
+}
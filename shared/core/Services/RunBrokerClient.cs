@@ -0,0 +1,36 @@
+using System.IO.Pipes;
+using System.Text;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Sends one request to CimianWatcher's run broker on the user pipe
+/// (<see cref="RunBrokerProtocol.PipeName"/>): CimianStatus's toast Defer and tray
+/// "Check now", and Managed Software Center's checks and self-service installs.
+/// The service decides whether this user may, so none of them needs elevation.
+/// </summary>
+public static class RunBrokerClient
+{
+    /// <summary>
+    /// Returns the broker's answer, or null when the service isn't reachable.
+    /// </summary>
+    public static async Task<RunBrokerResponse?> SendAsync(RunBrokerRequest request)
+    {
+        using var pipe = new NamedPipeClientStream(".", RunBrokerProtocol.PipeName, PipeDirection.InOut, PipeOptions.Asynchronous);
+        try
+        {
+            await pipe.ConnectAsync(3000).ConfigureAwait(false);
+            using var writer = new StreamWriter(pipe, new UTF8Encoding(false), leaveOpen: true) { AutoFlush = true };
+            using var reader = new StreamReader(pipe, Encoding.UTF8, leaveOpen: true);
+
+            await writer.WriteLineAsync(RunBrokerProtocol.Serialize(request)).ConfigureAwait(false);
+
+            using var cts = new CancellationTokenSource(TimeSpan.FromSeconds(30));
+            return RunBrokerProtocol.Deserialize<RunBrokerResponse>(await reader.ReadLineAsync(cts.Token).ConfigureAwait(false));
+        }
+        catch (Exception ex) when (ex is TimeoutException or IOException or UnauthorizedAccessException or OperationCanceledException)
+        {
+            return null;
+        }
+    }
+}
@@ -0,0 +1,234 @@
+using System.Text.Json;
+using System.Text.Json.Serialization;
+using System.Text.RegularExpressions;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Wire format for the "run now" broker hosted by CimianWatcher. A client sends
+/// one JSON line describing what to run and gets one JSON line back; the service
+/// launches managedsoftwareupdate itself under SYSTEM, so no path needs a UAC
+/// prompt or a scheduled task.
+///
+/// The broker listens on two pipes. <see cref="ControlPipeName"/> only opens for
+/// Administrators and SYSTEM and takes every request. <see cref="PipeName"/> is
+/// open to signed-in users in RunBrokerAllowedGroups and takes only what
+/// <see cref="IsUserRequest"/> allows: the audited <see cref="CheckMode"/>,
+/// self-service runs of named items, checkonly, defer and status.
+///
+/// Callers never send a command line: the request names a mode and optional
+/// items, and <see cref="BuildArguments"/> turns that into the only argument
+/// strings the broker will ever launch with. The <see cref="DeferMode"/> request
+/// launches nothing: the broker records it with <see cref="DeferralRequestStore"/>
+/// so the next auto run postpones those items. The <see cref="StatusMode"/>,
+/// <see cref="PauseMode"/> and <see cref="ResumeMode"/> requests control
+/// CimianWatcher's own auto-run schedule and launch nothing either.
+/// </summary>
+public static partial class RunBrokerProtocol
+{
+    public const string PipeName = "CimianRunBroker";
+
+    /// <summary>Pipe for administrators and SYSTEM; its ACL admits no one else.</summary>
+    public const string ControlPipeName = "CimianControl";
+
+    /// <summary>Mode for a standard user's "check for updates now": a normal auto run, audited.</summary>
+    public const string CheckMode = "check";
+
+    /// <summary>Mode for a user's "defer" from a toast; needs at least one item.</summary>
+    public const string DeferMode = "defer";
+
+    /// <summary>Mode asking for the auto-run schedule; answered with <see cref="RunBrokerResponse.Schedule"/>.</summary>
+    public const string StatusMode = "status";
+
+    /// <summary>Mode starting a maintenance hold until <see cref="RunBrokerRequest.Until"/>; administrators only.</summary>
+    public const string PauseMode = "pause";
+
+    /// <summary>Mode ending a pause early; administrators only.</summary>
+    public const string ResumeMode = "resume";
+
+    /// <summary>Lowest port a request may point the run's progress at; below it are system services.</summary>
+    public const int MinStatusPort = 1024;
+
+    /// <summary>Groups allowed to request a run when Config.yaml doesn't say.</summary>
+    public static readonly IReadOnlyList<string> DefaultAllowedGroups = [@"BUILTIN\Administrators", @"BUILTIN\Users"];
+
+    private static readonly JsonSerializerOptions JsonOptions = new()
+    {
+        PropertyNamingPolicy = JsonNamingPolicy.SnakeCaseLower,
+        DefaultIgnoreCondition = JsonIgnoreCondition.WhenWritingNull
+    };
+
+    [GeneratedRegex(@"^[\p{L}\p{N} ._+\-()]{1,128}$")]
+    private static partial Regex ItemNamePattern();
+
+    /// <summary>
+    /// Maps a request to managedsoftwareupdate arguments, or null with the reason
+    /// when the request asks for something the broker won't run.
+    /// </summary>
+    public static string? BuildArguments(RunBrokerRequest request, out string error)
+    {
+        error = string.Empty;
+
+        var args = request.Mode?.Trim().ToLowerInvariant() switch
+        {
+            "gui" => "--auto --show-status -vv",
+            "headless" => "--auto --show-status",
+            "checkonly" => "--checkonly --show-status",
+            CheckMode => "--auto --show-status",
+            _ => null
+        };
+        if (args == null)
+        {
+            error = $"Unknown mode '{request.Mode}'";
+            return null;
+        }
+
+        if (!ValidateItems(request, out error))
+        {
+            return null;
+        }
+        // One --item followed by every name: the engine's --item is a sequence
+        // option and exits 1 when the flag is repeated.
+        if (request.Items is { Count: > 0 } items)
+        {
+            args += " --item " + string.Join(" ", items.Distinct(StringComparer.OrdinalIgnoreCase).Select(item => $"\"{item}\""));
+        }
+
+        if (request.StatusPort is { } port)
+        {
+            if (port is < MinStatusPort or > 65535)
+            {
+                error = $"Invalid status port {port}";
+                return null;
+            }
+            args += $" --status-port {port}";
+        }
+
+        return args;
+    }
+
+    public static bool IsDeferRequest(RunBrokerRequest request) =>
+        string.Equals(request.Mode?.Trim(), DeferMode, StringComparison.OrdinalIgnoreCase);
+
+    /// <summary>Whether the request is about the auto-run schedule rather than a run.</summary>
+    public static bool IsScheduleRequest(RunBrokerRequest request) =>
+        request.Mode?.Trim().ToLowerInvariant() is StatusMode or PauseMode or ResumeMode;
+
+    /// <summary>
+    /// Whether a standard user may send the request on <see cref="PipeName"/>.
+    /// gui and headless runs are only allowed for named items (self-service);
+    /// a whole run is <see cref="CheckMode"/>. Pause and resume never are.
+    /// </summary>
+    public static bool IsUserRequest(RunBrokerRequest request) =>
+        request.Mode?.Trim().ToLowerInvariant() switch
+        {
+            CheckMode or "checkonly" or DeferMode or StatusMode => true,
+            "gui" or "headless" => request.Items is { Count: > 0 },
+            _ => false
+        };
+
+    /// <summary>
+    /// Checks every item name against the same pattern run requests use.
+    /// </summary>
+    public static bool ValidateItems(RunBrokerRequest request, out string error)
+    {
+        error = string.Empty;
+        foreach (var item in request.Items ?? [])
+        {
+            if (!ItemNamePattern().IsMatch(item))
+            {
+                error = $"Invalid item name '{item}'";
+                return false;
+            }
+        }
+        return true;
+    }
+
+    public static string Serialize<T>(T message) => JsonSerializer.Serialize(message, JsonOptions);
+
+    public static T? Deserialize<T>(string? line) where T : class
+    {
+        if (string.IsNullOrWhiteSpace(line))
+        {
+            return null;
+        }
+        try
+        {
+            return JsonSerializer.Deserialize<T>(line, JsonOptions);
+        }
+        catch (JsonException)
+        {
+            return null;
+        }
+    }
+}
+
+public class RunBrokerRequest
+{
+    /// <summary>gui, headless, check, checkonly, defer, status, pause or resume.</summary>
+    public string Mode { get; set; } = "headless";
+
+    /// <summary>Optional --item filter (self-service installs), or the items to defer.</summary>
+    public List<string>? Items { get; set; }
+
+    /// <summary>For pause: when scheduled runs start again.</summary>
+    public DateTime? Until { get; set; }
+
+    /// <summary>For pause: why, recorded with the maintenance hold.</summary>
+    public string? Reason { get; set; }
+
+    /// <summary>
+    /// For runs: where the run reports progress (--status-port), for a client
+    /// with its own listener such as Managed Software Center. Default: CimianStatus.
+    /// </summary>
+    public int? StatusPort { get; set; }
+}
+
+public class RunBrokerResponse
+{
+    public bool Accepted { get; set; }
+
+    public string Message { get; set; } = string.Empty;
+
+    /// <summary>PID of the launched managedsoftwareupdate, when accepted.</summary>
+    public int? ProcessId { get; set; }
+
+    /// <summary>The auto-run schedule, for status, pause and resume requests.</summary>
+    public ScheduleStatus? Schedule { get; set; }
+}
+
+/// <summary>
+/// CimianWatcher's auto-run schedule as reported over the run broker.
+/// </summary>
+public class ScheduleStatus
+{
+    /// <summary>Whether CimianWatcher runs managedsoftwareupdate itself (AutoRunIntervalMinutes set).</summary>
+    public bool Enabled { get; set; }
+
+    public int IntervalMinutes { get; set; }
+
+    public int SplayMinutes { get; set; }
+
+    /// <summary>When the next scheduled run starts, pause included; null when disabled.</summary>
+    public DateTime? NextRun { get; set; }
+
+    public DateTime? PausedUntil { get; set; }
+
+    public string? PausedBy { get; set; }
+
+    public string? PauseReason { get; set; }
+
+    /// <summary>A run came due while the machine was offline and starts when the network is back.</summary>
+    public bool WaitingForNetwork { get; set; }
+
+    /// <summary>A managedsoftwareupdate run started by CimianWatcher is in progress.</summary>
+    public bool UpdateRunning { get; set; }
+
+    /// <summary>When the scheduler last started a run.</summary>
+    public DateTime? LastScheduledRun { get; set; }
+
+    /// <summary>End and outcome of the most recent run of any kind, from status.json.</summary>
+    public DateTime? LastRunEnd { get; set; }
+
+    public string? LastRunOutcome { get; set; }
+}
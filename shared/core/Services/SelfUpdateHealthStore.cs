@@ -0,0 +1,104 @@
+using System.Text.Json.Serialization;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// The last Cimian self-update and whether the new version passed its health
+/// check. Written as the update is launched and settled the first time
+/// CimianWatcher starts on the new version; kept at
+/// <see cref="CimianPaths.SelfUpdateHealthJson"/>.
+/// </summary>
+public class SelfUpdateHealth
+{
+    public const string Pending = "pending";
+    public const string Healthy = "healthy";
+    public const string RolledBack = "rolled_back";
+
+    [JsonPropertyName("status")]
+    public string Status { get; set; } = Pending;
+
+    [JsonPropertyName("item")]
+    public string Item { get; set; } = "";
+
+    [JsonPropertyName("version")]
+    public string Version { get; set; } = "";
+
+    [JsonPropertyName("channel")]
+    public string Channel { get; set; } = "";
+
+    /// <summary>The version the update replaced, and the one a rollback restores.</summary>
+    [JsonPropertyName("previous_version")]
+    public string? PreviousVersion { get; set; }
+
+    [JsonPropertyName("installed")]
+    public DateTime Installed { get; set; }
+
+    /// <summary>CimianWatcher starts that began the health check.</summary>
+    [JsonPropertyName("checks")]
+    public int Checks { get; set; }
+
+    [JsonPropertyName("checked")]
+    public DateTime? Checked { get; set; }
+
+    /// <summary>Why the check failed, for a rolled-back update.</summary>
+    [JsonPropertyName("detail")]
+    public string? Detail { get; set; }
+}
+
+public static class SelfUpdateHealthStore
+{
+    /// <summary>
+    /// CimianWatcher starts that may begin the check without finishing it before
+    /// the update is rolled back anyway: a new CimianWatcher that crashes during
+    /// the check never gets to report the failure itself.
+    /// </summary>
+    public const int MaxChecks = 3;
+
+    /// <summary>Records an update about to be installed, replacing the last record.</summary>
+    public static SelfUpdateHealth Begin(string item, string version, string channel, string? previousVersion, DateTime now, string? path = null)
+    {
+        var health = new SelfUpdateHealth
+        {
+            Item = item,
+            Version = version,
+            Channel = SelfUpdateRollout.NormalizeChannel(channel),
+            PreviousVersion = previousVersion,
+            Installed = now
+        };
+        Save(health, path);
+        return health;
+    }
+
+    /// <summary>
+    /// Whether <paramref name="version"/> of the Cimian package <paramref name="item"/>
+    /// was rolled back, so it isn't offered again. A newer build clears this.
+    /// </summary>
+    public static bool IsRolledBack(string item, string version, string? path = null) =>
+        Read(path) is { Status: SelfUpdateHealth.RolledBack } health
+        && string.Equals(health.Item, item, StringComparison.OrdinalIgnoreCase)
+        && string.Equals(health.Version, version, StringComparison.OrdinalIgnoreCase);
+
+    /// <summary>The last self-update's record, or null when there hasn't been one.</summary>
+    public static SelfUpdateHealth? Read(string? path = null)
+    {
+        return JsonStateFile.TryRead<SelfUpdateHealth>(path ?? CimianPaths.SelfUpdateHealthJson);
+    }
+
+    public static void Save(SelfUpdateHealth health, string? path = null)
+    {
+        JsonStateFile.Write(path ?? CimianPaths.SelfUpdateHealthJson, health);
+    }
+
+    /// <summary>Forgets the last self-update, for one that never got installed.</summary>
+    public static void Clear(string? path = null)
+    {
+        try
+        {
+            File.Delete(path ?? CimianPaths.SelfUpdateHealthJson);
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            ConsoleLogger.Debug($"Could not remove the self-update health record: {ex.Message}");
+        }
+    }
+}
@@ -0,0 +1,72 @@
+using System.Security.Cryptography;
+using System.Text;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Which Cimian builds a machine may take: builds from its SelfUpdateChannel or
+/// a more stable one, and only once a staged rollout
+/// (self_update_rollout_percent) has reached the machine's bucket.
+/// </summary>
+public static class SelfUpdateRollout
+{
+    public const string Stable = "stable";
+    public const string Beta = "beta";
+    public const string Dev = "dev";
+
+    /// <summary>The channels SelfUpdateChannel accepts, most stable first.</summary>
+    public static readonly IReadOnlyList<string> Channels = [Stable, Beta, Dev];
+
+    /// <summary>A channel name lowercased and trimmed; unset means stable.</summary>
+    public static string NormalizeChannel(string? channel) =>
+        string.IsNullOrWhiteSpace(channel) ? Stable : channel.Trim().ToLowerInvariant();
+
+    /// <summary>
+    /// Whether a machine on <paramref name="configured"/> takes builds from
+    /// <paramref name="buildChannel"/>: its own channel and the more stable ones,
+    /// so a dev machine still gets a stable build newer than the last dev one.
+    /// Channels Cimian doesn't know only match themselves.
+    /// </summary>
+    public static bool AcceptsChannel(string? buildChannel, string? configured)
+    {
+        var build = NormalizeChannel(buildChannel);
+        var machine = NormalizeChannel(configured);
+        var buildRank = IndexOf(build);
+        var machineRank = IndexOf(machine);
+        if (buildRank < 0 || machineRank < 0)
+        {
+            return build == machine;
+        }
+        return buildRank <= machineRank;
+    }
+
+    /// <summary>
+    /// The machine's rollout bucket, 0-99, from a hash of its identifier. It
+    /// doesn't change between runs or releases, so a machine let into a rollout
+    /// at 10% stays in as the percentage is raised.
+    /// </summary>
+    public static int Bucket(string machineId)
+    {
+        var hash = SHA256.HashData(Encoding.UTF8.GetBytes(machineId.Trim().ToLowerInvariant()));
+        return (int)(BitConverter.ToUInt32(hash, 0) % 100);
+    }
+
+    /// <summary>
+    /// Whether a build rolled out to <paramref name="percent"/> of machines has
+    /// reached <paramref name="bucket"/>. Unset means every machine; 0 holds the
+    /// build back everywhere.
+    /// </summary>
+    public static bool InRollout(int? percent, int bucket) => percent is not { } p || bucket < Math.Clamp(p, 0, 100);
+
+    private static int IndexOf(string channel)
+    {
+        for (var i = 0; i < Channels.Count; i++)
+        {
+            if (Channels[i] == channel)
+            {
+                return i;
+            }
+        }
+        return -1;
+    }
+}
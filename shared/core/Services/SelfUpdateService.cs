@@ -20,6 +20,7 @@ public static class SelfUpdateService
         public string Version { get; set; } = string.Empty;
         public string InstallerType { get; set; } = string.Empty;
         public string LocalFile { get; set; } = string.Empty;
+        public string Channel { get; set; } = string.Empty;
         public string ScheduledAt { get; set; } = string.Empty;
     }
 
@@ -54,9 +55,11 @@ public static class SelfUpdateService
     }
 
     /// <summary>
-    /// Schedules a self-update to be performed on next service restart
+    /// Schedules a self-update to be performed on next service restart. The flag
+    /// file is written to a temp file and moved into place, so CimianWatcher never
+    /// reads a half-written one and an existing schedule is replaced in one step.
     /// </summary>
-    public static bool ScheduleSelfUpdate(string itemName, string version, string installerType, string localFile)
+    public static bool ScheduleSelfUpdate(string itemName, string version, string installerType, string localFile, string? channel = null)
     {
         try
         {
@@ -66,17 +69,22 @@ public static class SelfUpdateService
                 Version: {version}
                 InstallerType: {installerType}
                 LocalFile: {localFile}
+                Channel: {(string.IsNullOrEmpty(channel) ? "stable" : channel)}
                 ScheduledAt: {DateTime.Now:O}
                 """;
 
-            File.WriteAllText(SelfUpdateFlagFile, flagData);
+            var tempPath = SelfUpdateFlagFile + ".tmp";
+            File.WriteAllText(tempPath, flagData);
+            File.Move(tempPath, SelfUpdateFlagFile, overwrite: true);
             
             ConsoleLogger.Success("Self-update scheduled successfully. Cimian will update on next service restart.");
+            CimianEventLog.SelfUpdateScheduled(itemName, version, scheduled: true);
             return true;
         }
         catch (Exception ex)
         {
             ConsoleLogger.Error($"Failed to create self-update flag file: {ex.Message}");
+            CimianEventLog.SelfUpdateScheduled(itemName, version, scheduled: false, ex.Message);
             return false;
         }
     }
@@ -138,6 +146,8 @@ public static class SelfUpdateService
             log("Failed to create backup before self-update");
             return false;
         }
+        // The next CimianWatcher start, on the new version, checks it (CheckUpdatedVersion)
+        SelfUpdateHealthStore.Begin(metadata.Item, metadata.Version, metadata.Channel, InstalledVersion(), DateTime.Now);
 
         // Clear the flag BEFORE launching the installer to prevent an infinite loop.
         ClearSelfUpdateFlag();
@@ -166,6 +176,7 @@ public static class SelfUpdateService
                 if (!File.Exists(sbinInstaller))
                 {
                     log($"sbin-installer not found at: {sbinInstaller}");
+                    SelfUpdateHealthStore.Clear();
                     return false;
                 }
                 fileName = sbinInstaller;
@@ -174,6 +185,7 @@ public static class SelfUpdateService
             }
             default:
                 log($"Unsupported installer type for self-update: {metadata.InstallerType}");
+                SelfUpdateHealthStore.Clear();
                 return false;
         }
 
@@ -199,8 +211,9 @@ public static class SelfUpdateService
         catch (Exception ex)
         {
             log($"Failed to launch detached installer: {ex.Message}");
+            SelfUpdateHealthStore.Clear();
             // Re-schedule so we retry on next SCM restart.
-            ScheduleSelfUpdate(metadata.Item, metadata.Version, metadata.InstallerType, metadata.LocalFile);
+            ScheduleSelfUpdate(metadata.Item, metadata.Version, metadata.InstallerType, metadata.LocalFile, metadata.Channel);
             return false;
         }
     }
@@ -239,6 +252,7 @@ public static class SelfUpdateService
             ConsoleLogger.Error("Failed to create backup before self-update");
             return false;
         }
+        SelfUpdateHealthStore.Begin(metadata.Item, metadata.Version, metadata.Channel, InstalledVersion(), DateTime.Now);
 
         // Clear the flag file BEFORE running the installer.
         // The MSI's custom action will taskkill cimiwatcher.exe during install,
@@ -264,6 +278,7 @@ public static class SelfUpdateService
         else
         {
             ConsoleLogger.Warn("Self-update failed, attempting rollback...");
+            SelfUpdateHealthStore.Clear();
             if (PerformRollback())
             {
                 ConsoleLogger.Info("Rollback completed successfully");
@@ -274,7 +289,7 @@ public static class SelfUpdateService
             }
             // Re-schedule the self-update for retry on next service restart
             ScheduleSelfUpdate(metadata.Item, metadata.Version,
-                metadata.InstallerType, metadata.LocalFile);
+                metadata.InstallerType, metadata.LocalFile, metadata.Channel);
         }
 
         return success;
@@ -303,6 +318,7 @@ public static class SelfUpdateService
                 case "Version": metadata.Version = value; break;
                 case "InstallerType": metadata.InstallerType = value; break;
                 case "LocalFile": metadata.LocalFile = value; break;
+                case "Channel": metadata.Channel = value; break;
                 case "ScheduledAt": metadata.ScheduledAt = value; break;
             }
         }
@@ -494,10 +510,16 @@ public static class SelfUpdateService
 
             ConsoleLogger.Info("Rolling back to previous version...");
 
-            // Copy backup files back to install directory
+            // Copy backup files back to install directory. A running binary
+            // (CimianWatcher's own) can't be overwritten but can be renamed, so
+            // each file is moved aside first; the leftovers go at the next start.
             foreach (var file in Directory.GetFiles(SelfUpdateBackupDir))
             {
                 var destFile = Path.Combine(CimianInstallDir, Path.GetFileName(file));
+                if (File.Exists(destFile))
+                {
+                    File.Move(destFile, destFile + RollbackLeftoverSuffix, overwrite: true);
+                }
                 File.Copy(file, destFile, overwrite: true);
             }
 
@@ -513,23 +535,9 @@ public static class SelfUpdateService
 
     private static void CleanupAfterSuccess()
     {
-        try
-        {
-            // Remove the self-update flag file
-            ClearSelfUpdateFlag();
-
-            // Remove the backup directory
-            if (Directory.Exists(SelfUpdateBackupDir))
-            {
-                Directory.Delete(SelfUpdateBackupDir, recursive: true);
-            }
-
-            ConsoleLogger.Info("Self-update cleanup completed");
-        }
-        catch (Exception ex)
-        {
-            ConsoleLogger.Warn($"Cleanup after self-update had issues: {ex.Message}");
-        }
+        // The backup stays until the new version passes its health check
+        ClearSelfUpdateFlag();
+        ConsoleLogger.Info("Self-update cleanup completed");
     }
 
     /// <summary>
@@ -537,11 +545,12 @@ public static class SelfUpdateService
     /// detached self-update.  In the detached path the installer runs after
     /// CimianWatcher exits, so CleanupAfterSuccess never executes.  Call this on
     /// service startup when no self-update is pending — its presence means the
-    /// new version is running and the backup is no longer needed.
+    /// new version is running and the backup is no longer needed.  A backup
+    /// still waiting on the new version's health check is kept.
     /// </summary>
     public static void CleanupStaleBackup()
     {
-        if (IsSelfUpdatePending())
+        if (IsSelfUpdatePending() || SelfUpdateHealthStore.Read() is { Status: SelfUpdateHealth.Pending })
             return;
 
         if (!Directory.Exists(SelfUpdateBackupDir))
@@ -557,4 +566,149 @@ public static class SelfUpdateService
             ConsoleLogger.Warn($"Failed to remove stale self-update backup: {ex.Message}");
         }
     }
+
+    /// <summary>
+    /// Settles the last self-update the first time CimianWatcher starts on the
+    /// new version: runs the new managedsoftwareupdate and, if it fails, copies
+    /// the backup back over the install and records the version as rolled back
+    /// so it isn't offered again. Returns the record it settled, or null when no
+    /// check was due. After a rollback the caller should exit so the service
+    /// restarts on the restored binaries.
+    /// </summary>
+    public static SelfUpdateHealth? CheckUpdatedVersion(Action<string> log)
+    {
+        RemoveRollbackLeftovers();
+        if (IsSelfUpdatePending() || SelfUpdateHealthStore.Read() is not { Status: SelfUpdateHealth.Pending } health)
+        {
+            return null;
+        }
+
+        // Counted before the check, so a new version that crashes during it is still caught
+        health.Checks++;
+        SelfUpdateHealthStore.Save(health);
+        log($"Health check for self-update {health.Item} v{health.Version} (start {health.Checks})");
+
+        var failure = health.Checks > SelfUpdateHealthStore.MaxChecks
+            ? $"CimianWatcher restarted {health.Checks - 1} times without finishing the health check"
+            : ProbeUpdatedVersion();
+        health.Checked = DateTime.Now;
+
+        if (failure == null)
+        {
+            health.Status = SelfUpdateHealth.Healthy;
+            SelfUpdateHealthStore.Save(health);
+            CimianEventLog.SelfUpdateChecked(health);
+            log($"Self-update {health.Item} v{health.Version} passed its health check");
+            CleanupStaleBackup();
+            return health;
+        }
+
+        health.Detail = failure;
+        log($"Self-update {health.Item} v{health.Version} failed its health check: {failure}");
+        if (!PerformRollback())
+        {
+            // Still pending: the next start tries the rollback again
+            SelfUpdateHealthStore.Save(health);
+            log("Rollback failed; it is retried when CimianWatcher next starts");
+            return health;
+        }
+
+        health.Status = SelfUpdateHealth.RolledBack;
+        SelfUpdateHealthStore.Save(health);
+        CimianEventLog.SelfUpdateChecked(health);
+        log($"Rolled back to {health.PreviousVersion ?? "the previous version"}");
+        return health;
+    }
+
+    private const string RollbackLeftoverSuffix = ".rollback-old";
+    private static readonly TimeSpan HealthCheckTimeout = TimeSpan.FromSeconds(60);
+
+    // --version proves the binary and its dependencies load; --show-config
+    // that it can still read this machine's configuration
+    private static readonly string[] HealthCheckArguments = ["--version", "--show-config"];
+
+    /// <summary>Why the installed managedsoftwareupdate is unhealthy, or null when every check passes.</summary>
+    private static string? ProbeUpdatedVersion()
+    {
+        var exe = CimianPaths.ManagedSoftwareUpdateExe;
+        if (!File.Exists(exe))
+        {
+            return $"{exe} is missing";
+        }
+
+        foreach (var arguments in HealthCheckArguments)
+        {
+            try
+            {
+                using var process = Process.Start(new ProcessStartInfo
+                {
+                    FileName = exe,
+                    Arguments = arguments,
+                    UseShellExecute = false,
+                    CreateNoWindow = true,
+                    RedirectStandardOutput = true,
+                    RedirectStandardError = true
+                })!;
+                // Drained so a chatty run can't block on a full pipe
+                var output = process.StandardOutput.ReadToEndAsync();
+                var error = process.StandardError.ReadToEndAsync();
+                if (!process.WaitForExit(HealthCheckTimeout))
+                {
+                    process.Kill(entireProcessTree: true);
+                    return $"managedsoftwareupdate {arguments} didn't finish within {HealthCheckTimeout.TotalSeconds:0} seconds";
+                }
+                if (process.ExitCode != 0)
+                {
+                    var message = error.Result.Trim();
+                    if (message.Length == 0)
+                    {
+                        message = output.Result.Trim();
+                    }
+                    if (message.Length > 300)
+                    {
+                        message = message[..300] + "...";
+                    }
+                    return $"managedsoftwareupdate {arguments} exited with {process.ExitCode}{(message.Length > 0 ? $": {message}" : "")}";
+                }
+            }
+            catch (Exception ex) when (ex is System.ComponentModel.Win32Exception or InvalidOperationException)
+            {
+                return $"managedsoftwareupdate {arguments} didn't start: {ex.Message}";
+            }
+        }
+        return null;
+    }
+
+    /// <summary>The installed managedsoftwareupdate's version, recorded as the one a rollback restores.</summary>
+    private static string? InstalledVersion()
+    {
+        try
+        {
+            var exe = CimianPaths.ManagedSoftwareUpdateExe;
+            // ProductVersion can carry a +commit suffix
+            return File.Exists(exe) ? FileVersionInfo.GetVersionInfo(exe).ProductVersion?.Split('+')[0] : null;
+        }
+        catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+        {
+            return null;
+        }
+    }
+
+    private static void RemoveRollbackLeftovers()
+    {
+        if (!Directory.Exists(CimianInstallDir))
+            return;
+
+        foreach (var file in Directory.GetFiles(CimianInstallDir, "*" + RollbackLeftoverSuffix))
+        {
+            try
+            {
+                File.Delete(file);
+            }
+            catch (Exception ex) when (ex is IOException or UnauthorizedAccessException)
+            {
+                // Still running from before the rollback; removed at a later start
+            }
+        }
+    }
 }
@@ -2,6 +2,7 @@ using System.Collections.Concurrent;
 using System.Text.Json;
 using System.Text.Json.Serialization;
 using Cimian.Core.Models;
+using Cimian.Core.Version;
 
 namespace Cimian.Core.Services;
 
@@ -13,7 +14,7 @@ namespace Cimian.Core.Services;
 /// Features:
 /// - Day-nested directories: logs/YYYY-MM-DD/HHMM/ for easy navigation
 /// - Creates session.json, events.jsonl, install.log, and run.log files
-/// - 7-day rolling retention with automatic cleanup
+/// - Rolling retention (see <see cref="LogRetention"/>): recent sessions kept, older ones zipped, then deleted
 /// - Writes reports to C:\ProgramData\ManagedInstalls\reports
 /// - Structured data formats for external tool integration
 /// </summary>
@@ -22,9 +23,6 @@ public class SessionLogger : IDisposable
     private static readonly string BaseLogsDir = CimianPaths.LogsDir;
     private static readonly string ReportsDir = CimianPaths.ReportsDir;
 
-    // Retention policy: 30-day rolling window (~220MB at typical usage)
-    private const int DefaultMaxAgeDays = 30;
-
     private static readonly JsonSerializerOptions JsonOptions = new()
     {
         WriteIndented = true,
@@ -50,10 +48,20 @@ public class SessionLogger : IDisposable
     private StreamWriter? _eventsFile;     // events.jsonl
 
     private readonly ConcurrentQueue<LogEvent> _events = new();
+    private readonly List<LastRunDeferral> _deferrals = new();
+    private readonly List<LastRunReleaseNote> _releaseNotes = new();
     private SessionData _sessionData = new();
     private bool _disposed;
 
     private readonly object _logLock = new();
+    private long _lastEventTicks;
+
+    /// <summary>
+    /// Longest a running session goes without an events.jsonl line while
+    /// <see cref="Heartbeat"/> is being called. CimianWatcher's watchdog counts
+    /// a session whose events.jsonl stops growing for HungRunMinutes as hung.
+    /// </summary>
+    public static readonly TimeSpan HeartbeatInterval = TimeSpan.FromMinutes(1);
 
     /// <summary>
     /// Gets the current session ID
@@ -65,6 +73,36 @@ public class SessionLogger : IDisposable
     /// </summary>
     public string SessionDir => _sessionDir;
 
+    /// <summary>
+    /// Days of session logs kept. Non-persistent machines shorten it since the
+    /// disk is thrown away anyway.
+    /// </summary>
+    public int RetentionDays { get; init; } = LogRetentionPolicy.DefaultRetentionDays;
+
+    /// <summary>Newest sessions kept uncompressed whatever their age.</summary>
+    public int KeepSessions { get; init; } = LogRetentionPolicy.DefaultKeepSessions;
+
+    /// <summary>Most the session logs may take up, in MB. 0 = unlimited.</summary>
+    public int MaxLogSizeMB { get; init; }
+
+    /// <summary>
+    /// Extra report formats (<see cref="ReportFormat"/>) written with the
+    /// standard reports at the end of the session.
+    /// </summary>
+    public IReadOnlyList<string> ReportFormats { get; init; } = [];
+
+    /// <summary>
+    /// Manifest the run used, for the extra report formats and status.json. Starts
+    /// as the ClientIdentifier and is updated once manifest resolution settles it.
+    /// </summary>
+    public string ManifestName { get; set; } = "";
+
+    /// <summary>
+    /// Raised with the level and message of every line written to run.log, for
+    /// live viewers. Handlers run on the logging thread and must not block.
+    /// </summary>
+    public event Action<string, string>? LineLogged;
+
     /// <summary>
     /// Initializes a new session with timestamped directory structure
     /// </summary>
@@ -136,6 +174,8 @@ public class SessionLogger : IDisposable
         // Write initial session.json
         WriteSessionFile();
 
+        CimianEventLog.SessionStarted(_sessionId, runType);
+
         return _sessionId;
     }
 
@@ -205,6 +245,8 @@ public class SessionLogger : IDisposable
             }
         }
 
+        LineLogged?.Invoke(level, message);
+
         // Note: Console output is handled separately by ConsoleLogger
         // SessionLogger only writes to log files
     }
@@ -225,6 +267,7 @@ public class SessionLogger : IDisposable
             evt.EventId = $"{_sessionId}-{DateTime.Now.Ticks}";
 
         _events.Enqueue(evt);
+        Interlocked.Exchange(ref _lastEventTicks, DateTime.UtcNow.Ticks);
 
         // Write to events.jsonl
         try
@@ -239,6 +282,49 @@ public class SessionLogger : IDisposable
         {
             Console.Error.WriteLine($"[ERROR] Failed to write event: {ex.Message}");
         }
+
+        // Install results, blocked installs etc. also go to the Cimian event log
+        CimianEventLog.Event(evt);
+    }
+
+    /// <summary>
+    /// Records that the run is alive during a long step that logs no events of its
+    /// own (a download, an installer or script being waited on). Writes a
+    /// heartbeat event only when nothing else reached events.jsonl in the last
+    /// <see cref="HeartbeatInterval"/>, so it's cheap to call from a loop.
+    /// </summary>
+    public void Heartbeat(string activity)
+    {
+        var last = new DateTime(Interlocked.Read(ref _lastEventTicks), DateTimeKind.Utc);
+        if (DateTime.UtcNow - last < HeartbeatInterval)
+        {
+            return;
+        }
+
+        LogEvent(new LogEvent
+        {
+            EventType = "heartbeat",
+            Level = "DEBUG",
+            Message = activity
+        });
+    }
+
+    /// <summary>
+    /// Heartbeats every <see cref="HeartbeatInterval"/> while a wait is in progress,
+    /// but only for <paramref name="limit"/>: a wait that outlives its own timeout,
+    /// or has none, goes quiet so the watchdog sees it. Dispose when the wait ends.
+    /// </summary>
+    public IDisposable HeartbeatWhile(string activity, TimeSpan limit)
+    {
+        var started = DateTime.UtcNow;
+        return new Timer(_ =>
+        {
+            var elapsed = DateTime.UtcNow - started;
+            if (elapsed < limit)
+            {
+                Heartbeat($"{activity} ({(int)elapsed.TotalMinutes} min)");
+            }
+        }, null, HeartbeatInterval, HeartbeatInterval);
     }
 
     /// <summary>
@@ -335,10 +421,48 @@ public class SessionLogger : IDisposable
         });
     }
 
+    /// <summary>
+    /// Records an item the user deferred; listed in status.json at session end.
+    /// </summary>
+    public void AddDeferral(LastRunDeferral deferral)
+    {
+        lock (_logLock)
+        {
+            _deferrals.Add(deferral);
+        }
+    }
+
+    /// <summary>
+    /// Records release notes for an item installed this run; listed in status.json.
+    /// </summary>
+    public void AddReleaseNote(LastRunReleaseNote note)
+    {
+        lock (_logLock)
+        {
+            _releaseNotes.Add(note);
+        }
+    }
+
+    /// <summary>
+    /// Adds or replaces a value in session.json's environment block for facts
+    /// only known late in the run (e.g. pending reboot after installs).
+    /// </summary>
+    public void SetEnvironmentValue(string key, object value)
+    {
+        lock (_logLock)
+        {
+            _sessionData.Environment ??= new Dictionary<string, object>();
+            _sessionData.Environment[key] = value;
+        }
+    }
+
     /// <summary>
     /// Ends the current session and writes final summary
     /// </summary>
-    public void EndSession(string status, SessionLogSummary summary)
+    /// <param name="status">completed, partial_failure or failed</param>
+    /// <param name="summary">Action counts for the session</param>
+    /// <param name="message">Optional one-line result for status.json (e.g. the fatal error)</param>
+    public void EndSession(string status, SessionLogSummary summary, string? message = null)
     {
         var endTime = DateTime.Now;
         var duration = endTime - _sessionStart;
@@ -353,6 +477,19 @@ public class SessionLogger : IDisposable
         // Write final session.json
         WriteSessionFile();
 
+        // Last-run status for cimistatus to show after the process is gone
+        try
+        {
+            LastRunStatusStore.Write(LastRunStatusStore.FromSession(
+                _sessionId, _runType, _sessionStart, endTime, status, summary, message, _deferrals, _releaseNotes, ManifestName));
+        }
+        catch (Exception ex)
+        {
+            Console.Error.WriteLine($"[ERROR] Failed to write status.json: {ex.Message}");
+        }
+
+        CimianEventLog.SessionEnded(_sessionId, _runType, status, summary, message);
+
         // Generate reports
         GenerateReports();
 
@@ -378,45 +515,14 @@ public class SessionLogger : IDisposable
     }
 
     /// <summary>
-    /// Performs 7-day rolling retention cleanup.
-    /// Removes day directories older than retention window and cleans up any legacy flat-format sessions.
+    /// Applies the retention policy to every session but this one.
     /// </summary>
     private void PerformRetentionCleanup()
     {
         try
         {
-            if (!Directory.Exists(BaseLogsDir))
-                return;
-
-            var cutoff = DateTime.Now.AddDays(-DefaultMaxAgeDays);
-
-            foreach (var entry in Directory.GetDirectories(BaseLogsDir))
-            {
-                var dirName = Path.GetFileName(entry);
-
-                // New format: day directories (YYYY-MM-DD, 10 chars)
-                if (IsDayDirectory(dirName))
-                {
-                    if (DateTime.TryParseExact(dirName, "yyyy-MM-dd", null,
-                            System.Globalization.DateTimeStyles.None, out var dayDate)
-                        && dayDate < cutoff.Date)
-                    {
-                        TryDeleteSessionDirectory(entry);
-                    }
-                    continue;
-                }
-
-                // Legacy flat format (YYYY-MM-DD-HHMMss, 17 chars) — clean up old sessions
-                if (IsLegacySessionDirectory(dirName))
-                {
-                    if (DateTime.TryParseExact(dirName, "yyyy-MM-dd-HHmmss", null,
-                            System.Globalization.DateTimeStyles.None, out var legacyDate)
-                        && legacyDate < cutoff)
-                    {
-                        TryDeleteSessionDirectory(entry);
-                    }
-                }
-            }
+            LogRetention.Prune(BaseLogsDir, new LogRetentionPolicy(RetentionDays, KeepSessions, MaxLogSizeMB),
+                DateTime.Now, _sessionDir);
         }
         catch
         {
@@ -427,7 +533,7 @@ public class SessionLogger : IDisposable
     /// <summary>
     /// Checks if a directory name is a day directory (YYYY-MM-DD)
     /// </summary>
-    private static bool IsDayDirectory(string name)
+    internal static bool IsDayDirectory(string name)
     {
         return name.Length == 10 && name[4] == '-' && name[7] == '-'
             && DateTime.TryParseExact(name, "yyyy-MM-dd", null,
@@ -437,7 +543,7 @@ public class SessionLogger : IDisposable
     /// <summary>
     /// Checks if a directory name is a time-of-day session (HHMM or HHMM_N for collisions)
     /// </summary>
-    private static bool IsTimeSessionDirectory(string name)
+    internal static bool IsTimeSessionDirectory(string name)
     {
         // Primary: 4-digit HHMM (e.g. "1430")
         if (name.Length == 4 && int.TryParse(name, out var hhmm))
@@ -453,28 +559,13 @@ public class SessionLogger : IDisposable
     /// <summary>
     /// Checks if a directory name is a legacy flat-format session (YYYY-MM-DD-HHMMss)
     /// </summary>
-    private static bool IsLegacySessionDirectory(string name)
+    internal static bool IsLegacySessionDirectory(string name)
     {
         return name.Length == 17 && name[4] == '-' && name[7] == '-' && name[10] == '-'
             && DateTime.TryParseExact(name, "yyyy-MM-dd-HHmmss", null,
                 System.Globalization.DateTimeStyles.None, out _);
     }
 
-    /// <summary>
-    /// Safely attempts to delete a session directory
-    /// </summary>
-    private static void TryDeleteSessionDirectory(string path)
-    {
-        try
-        {
-            Directory.Delete(path, recursive: true);
-        }
-        catch
-        {
-            // Ignore - directory may be in use or protected
-        }
-    }
-
     // Current session items for items.json generation (set by UpdateEngine)
     private List<SessionPackageInfo> _currentSessionItems = new();
 
@@ -507,6 +598,15 @@ public class SessionLogger : IDisposable
             // Generate loop_suppressed.json - LoopGuard suppressions surfaced for
             // dashboards. Skipped silently if no suppressions were registered.
             GenerateLoopSuppressedReport();
+
+            // Generate usage.json - installed items and download stats for aggregation
+            GenerateUsageReport();
+
+            // Generate facts.json - machine inventory facts for this run
+            GenerateFactsReport();
+
+            // ReportFormats - MunkiReport / osquery copies of the reports above
+            GenerateFormatReports();
         }
         catch (Exception ex)
         {
@@ -532,6 +632,48 @@ public class SessionLogger : IDisposable
         File.WriteAllText(path, JsonSerializer.Serialize(_currentLoopSuppressed, JsonOptions));
     }
 
+    private UsageReport? _currentUsage;
+
+    /// <summary>
+    /// Sets this run's usage data for reports/usage.json. Runs that never set it
+    /// (rollback, failed before installs) leave the previous usage.json in place.
+    /// </summary>
+    public void SetCurrentUsage(UsageReport usage)
+    {
+        _currentUsage = usage;
+    }
+
+    private void GenerateUsageReport()
+    {
+        if (_currentUsage == null)
+        {
+            return;
+        }
+        var path = Path.Combine(ReportsDir, UsageReports.FileName);
+        File.WriteAllText(path, JsonSerializer.Serialize(_currentUsage, JsonOptions));
+    }
+
+    private Dictionary<string, object?>? _currentFacts;
+
+    /// <summary>
+    /// Sets this run's machine facts for reports/facts.json (see
+    /// <see cref="SystemFacts.ToInventory"/>).
+    /// </summary>
+    public void SetCurrentFacts(Dictionary<string, object?> facts)
+    {
+        _currentFacts = facts;
+    }
+
+    private void GenerateFactsReport()
+    {
+        if (_currentFacts == null)
+        {
+            return;
+        }
+        var path = Path.Combine(ReportsDir, "facts.json");
+        File.WriteAllText(path, JsonSerializer.Serialize(_currentFacts, JsonOptions));
+    }
+
     /// <summary>
     /// Enumerates all session directories (both new nested and legacy flat format),
     /// returning full paths ordered newest-first.
@@ -723,6 +865,23 @@ public class SessionLogger : IDisposable
         };
     }
 
+    private void GenerateFormatReports()
+    {
+        if (ReportFormats.Count == 0)
+        {
+            return;
+        }
+
+        var items = new List<ItemRecord>();
+        var itemsPath = Path.Combine(ReportsDir, "items.json");
+        if (File.Exists(itemsPath))
+        {
+            items = JsonSerializer.Deserialize<List<ItemRecord>>(File.ReadAllText(itemsPath)) ?? items;
+        }
+        ReportFormatExporter.Write(ReportsDir, ReportFormats, new ReportSnapshot(
+            _sessionData, items, _events.ToList(), _currentFacts, ManifestName, VersionService.GetRunningAgentVersion()));
+    }
+
     /// <summary>
     /// Returns the latest session directory (new nested or legacy flat format).
     /// Used by external consumers to find the most recent log session.
@@ -929,6 +1088,29 @@ public class SessionLogSummary
     [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingDefault)]
     public TimeSpan Duration { get; set; }
 
+    /// <summary>The repo was unreachable and the run used cached manifests/catalogs.</summary>
+    [JsonPropertyName("degraded")]
+    [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingDefault)]
+    public bool Degraded { get; set; }
+
     [JsonPropertyName("packages_handled")]
     public List<string> PackagesHandled { get; set; } = new();
+
+    /// <summary>Installs held back this run and why: install window, blocking apps, metered network...</summary>
+    [JsonPropertyName("deferred")]
+    [JsonIgnore(Condition = JsonIgnoreCondition.WhenWritingNull)]
+    public List<SessionDeferral>? Deferred { get; set; }
+}
+
+/// <summary>One item in <see cref="SessionLogSummary.Deferred"/>.</summary>
+public class SessionDeferral
+{
+    [JsonPropertyName("name")]
+    public string Name { get; set; } = string.Empty;
+
+    [JsonPropertyName("version")]
+    public string Version { get; set; } = string.Empty;
+
+    [JsonPropertyName("reason")]
+    public string Reason { get; set; } = string.Empty;
 }
@@ -0,0 +1,71 @@
+namespace Cimian.Core.Services;
+
+public static class TrayStatusKind
+{
+    public const string UpToDate = "up_to_date";
+    public const string UpdatesPending = "updates_pending";
+    public const string RestartRequired = "restart_required";
+    public const string Running = "running";
+    public const string Error = "error";
+}
+
+/// <summary>
+/// What the CimianStatus tray icon shows: a badge (<see cref="Kind"/>, with
+/// <see cref="PendingCount"/> on the updates badge) and a one-line tooltip.
+/// </summary>
+public sealed record TrayStatus(string Kind, int PendingCount, string ToolTip)
+{
+    // NotifyIcon.Text rejects anything longer
+    public const int MaxToolTipLength = 127;
+
+    /// <summary>
+    /// A run in progress wins, then a failed last run, then an owed restart,
+    /// then a maintenance hold, then pending updates. A partial run with nothing
+    /// left pending is up to date.
+    /// </summary>
+    public static TrayStatus Evaluate(
+        LastRunStatus? lastRun,
+        IReadOnlyCollection<string> pendingItems,
+        RestartState? restart,
+        bool running,
+        DateTime now,
+        MaintenanceHold? hold = null)
+    {
+        if (running)
+        {
+            return Create(TrayStatusKind.Running, pendingItems.Count, "Cimian: installing updates");
+        }
+
+        if (lastRun?.Outcome == "failure")
+        {
+            var message = string.IsNullOrWhiteSpace(lastRun.Message) ? "the last update run failed" : lastRun.Message;
+            return Create(TrayStatusKind.Error, pendingItems.Count, $"Cimian: {message}");
+        }
+
+        if (restart != null)
+        {
+            return Create(TrayStatusKind.RestartRequired, pendingItems.Count, $"Cimian: {restart.Describe(now)}");
+        }
+
+        if (hold != null)
+        {
+            var pending = pendingItems.Count > 0 ? $"{pendingItems.Count} pending, " : "";
+            return Create(pendingItems.Count > 0 ? TrayStatusKind.UpdatesPending : TrayStatusKind.UpToDate, pendingItems.Count,
+                $"Cimian: {pending}automatic updates paused until {hold.Until:g}");
+        }
+
+        if (pendingItems.Count > 0)
+        {
+            return Create(TrayStatusKind.UpdatesPending, pendingItems.Count, pendingItems.Count == 1
+                ? "Cimian: 1 update pending"
+                : $"Cimian: {pendingItems.Count} updates pending");
+        }
+
+        return Create(TrayStatusKind.UpToDate, 0, lastRun != null
+            ? $"Cimian: up to date (checked {lastRun.EndTime:g})"
+            : "Cimian: up to date");
+    }
+
+    private static TrayStatus Create(string kind, int pendingCount, string toolTip) =>
+        new(kind, pendingCount, toolTip.Length > MaxToolTipLength ? toolTip[..(MaxToolTipLength - 3)] + "..." : toolTip);
+}
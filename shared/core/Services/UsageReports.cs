@@ -0,0 +1,118 @@
+using System.Security.Cryptography;
+using System.Text;
+using System.Text.Json;
+using Cimian.Core.Models;
+
+namespace Cimian.Core.Services;
+
+/// <summary>
+/// Helpers for reports/usage.json: the machine label written on the client, and
+/// the roll-up of many machines' reports on the collecting side.
+/// </summary>
+public static class UsageReports
+{
+    public const string FileName = "usage.json";
+
+    /// <summary>
+    /// The machine identifier itself, or "anon-" and the first 16 hex digits of its
+    /// SHA-256 when anonymous. The hash is stable, so an anonymous machine still
+    /// counts once across runs without being named.
+    /// </summary>
+    public static string MachineLabel(string identifier, bool anonymous)
+    {
+        if (!anonymous)
+        {
+            return identifier;
+        }
+        var hash = SHA256.HashData(Encoding.UTF8.GetBytes(identifier.ToLowerInvariant()));
+        return "anon-" + Convert.ToHexString(hash)[..16].ToLowerInvariant();
+    }
+
+    /// <summary>
+    /// Every usage.json (or *-usage.json) under <paramref name="directory"/>.
+    /// Files that don't parse are skipped with a warning.
+    /// </summary>
+    public static List<UsageReport> Load(string directory)
+    {
+        var reports = new List<UsageReport>();
+        foreach (var path in Directory.EnumerateFiles(directory, "*usage.json", SearchOption.AllDirectories))
+        {
+            try
+            {
+                var report = JsonSerializer.Deserialize<UsageReport>(File.ReadAllText(path));
+                if (report != null && !string.IsNullOrEmpty(report.Machine))
+                {
+                    reports.Add(report);
+                }
+            }
+            catch (Exception ex) when (ex is IOException or JsonException or UnauthorizedAccessException)
+            {
+                ConsoleLogger.Warn($"Skipping unreadable usage report {path}: {ex.Message}");
+            }
+        }
+        return reports;
+    }
+
+    /// <summary>
+    /// Rolls reports up per item version. Only the newest report from each machine
+    /// counts, so collecting the same machine twice doesn't inflate its numbers.
+    /// </summary>
+    public static UsageSummary Aggregate(IEnumerable<UsageReport> reports)
+    {
+        var latest = reports
+            .GroupBy(r => r.Machine, StringComparer.OrdinalIgnoreCase)
+            .Select(g => g.OrderByDescending(r => r.GeneratedAt).First())
+            .ToList();
+
+        var items = new Dictionary<string, UsageItemDeployment>(StringComparer.OrdinalIgnoreCase);
+        foreach (var report in latest)
+        {
+            foreach (var installed in report.Installed)
+            {
+                if (!items.TryGetValue(installed.Name, out var deployment))
+                {
+                    deployment = new UsageItemDeployment { Name = installed.Name };
+                    items[installed.Name] = deployment;
+                }
+                if (!deployment.Versions.TryGetValue(installed.Version, out var machines))
+                {
+                    machines = new List<string>();
+                    deployment.Versions[installed.Version] = machines;
+                }
+                machines.Add(report.Machine);
+            }
+        }
+        foreach (var deployment in items.Values)
+        {
+            deployment.MachineCount = deployment.Versions.Values
+                .SelectMany(m => m)
+                .Distinct(StringComparer.OrdinalIgnoreCase)
+                .Count();
+        }
+
+        var downloads = latest.SelectMany(r => r.Downloads).ToList();
+        var sources = downloads
+            .GroupBy(d => d.Source, StringComparer.OrdinalIgnoreCase)
+            .Select(g => new UsageSourceStats
+            {
+                Source = g.Key,
+                Downloads = g.Count(),
+                Failures = g.Count(d => !d.Success),
+                Bytes = g.Sum(d => d.Bytes),
+                AverageDurationMs = (long)g.Average(d => d.DurationMs)
+            })
+            .OrderByDescending(s => s.Downloads)
+            .ToList();
+
+        return new UsageSummary
+        {
+            Machines = latest.Count,
+            Items = items.Values
+                .OrderByDescending(i => i.MachineCount)
+                .ThenBy(i => i.Name, StringComparer.OrdinalIgnoreCase)
+                .ToList(),
+            Sources = sources,
+            CacheHitRate = downloads.Count == 0 ? 0 : Math.Round((double)downloads.Count(d => d.CacheHit) / downloads.Count, 3)
+        };
+    }
+}
@@ -0,0 +1,127 @@
+namespace Cimian.Core.Services;
+
+public static class UserNoticeKind
+{
+    public const string UpdatesPending = "updates_pending";
+    public const string ForcedInstall = "forced_install";
+    public const string RestartRequired = "restart_required";
+    public const string CloseApps = "close_apps";
+}
+
+/// <summary>
+/// One toast for the logged-in user. <see cref="Key"/> changes whenever the
+/// notice says something new, so a notice already shown isn't repeated.
+/// </summary>
+public sealed record UserNotice(
+    string Kind,
+    string Key,
+    string Title,
+    string Message,
+    string? Item = null,
+    bool CanDefer = false);
+
+/// <summary>
+/// Decides which toasts CimianStatus raises for the logged-in user from what
+/// the last run left behind: pending updates (reports/items.json), deferred
+/// items about to be forced (status.json) and an owed restart (restart.json),
+/// and from a running one: installs waiting on applications (blocking_apps.json).
+/// </summary>
+public static class UserNotices
+{
+    public const int DefaultForcedInstallWarningHours = 24;
+
+    public static List<UserNotice> Plan(
+        LastRunStatus? lastRun,
+        IReadOnlyCollection<string> pendingItems,
+        RestartState? restart,
+        DateTime now,
+        int forcedInstallWarningHours = DefaultForcedInstallWarningHours,
+        BlockingAppsState? blockingApps = null)
+    {
+        var notices = new List<UserNotice>();
+
+        foreach (var blocked in blockingApps?.Items ?? [])
+        {
+            notices.Add(new UserNotice(
+                UserNoticeKind.CloseApps,
+                $"{UserNoticeKind.CloseApps}:{blocked.Item}:{blocked.Version}:{blockingApps!.RecordedAt:o}",
+                $"Close apps to install {blocked.Name}",
+                blocked.Describe(blockingApps.WaitUntil),
+                blocked.Item));
+        }
+
+        if (restart != null)
+        {
+            notices.Add(new UserNotice(
+                UserNoticeKind.RestartRequired,
+                $"{UserNoticeKind.RestartRequired}:{restart.RecordedAt:o}",
+                "Restart required",
+                restart.Describe(now)));
+        }
+
+        var warning = TimeSpan.FromHours(Math.Max(0, forcedInstallWarningHours));
+        foreach (var deferral in lastRun?.Deferrals ?? [])
+        {
+            var label = $"{deferral.Name} {deferral.Version}";
+            var deadlinePassed = deferral.ForceInstallAfter is { } passed && passed <= now;
+            var canDefer = deferral.CanDefer && !deadlinePassed;
+            string? message = null;
+            if (deferral.ForceInstallAfter is { } deadline && !deadlinePassed && deadline - now <= warning)
+            {
+                message = $"{label} will be installed after {deadline:g}. Save your work before then.";
+            }
+            else if (!canDefer)
+            {
+                message = $"{label} can't be postponed again and will be installed at the next update.";
+            }
+
+            if (message != null)
+            {
+                notices.Add(new UserNotice(
+                    UserNoticeKind.ForcedInstall,
+                    $"{UserNoticeKind.ForcedInstall}:{deferral.Item ?? deferral.Name}:{deferral.Version}:{deferral.DeferralsUsed}",
+                    $"{deferral.Name} installs soon",
+                    message,
+                    deferral.Item ?? deferral.Name,
+                    canDefer));
+            }
+        }
+
+        if (pendingItems.Count > 0)
+        {
+            var names = pendingItems.Order(StringComparer.OrdinalIgnoreCase).ToList();
+            notices.Add(new UserNotice(
+                UserNoticeKind.UpdatesPending,
+                $"{UserNoticeKind.UpdatesPending}:{string.Join(",", names)}",
+                names.Count == 1 ? "1 update available" : $"{names.Count} updates available",
+                names.Count <= 3 ? string.Join(", ", names) : $"{string.Join(", ", names.Take(3))} and {names.Count - 3} more"));
+        }
+
+        return notices;
+    }
+
+    /// <summary>
+    /// Display names of the managed installs the last check found needing an
+    /// update, from InstallInfo.yaml (readable by standard users).
+    /// </summary>
+    public static List<string> ReadPendingItems(string? path = null)
+    {
+        path ??= CimianPaths.InstallInfoYaml;
+        try
+        {
+            if (File.Exists(path))
+            {
+                var info = YamlUtils.DeserializeInstallInfo(File.ReadAllText(path));
+                return (info?.ManagedInstalls ?? [])
+                    .Where(i => i.NeedsUpdate)
+                    .Select(i => string.IsNullOrWhiteSpace(i.DisplayName) ? i.Name : i.DisplayName)
+                    .ToList();
+            }
+        }
+        catch (Exception ex)
+        {
+            ConsoleLogger.Debug($"Could not read {path}: {ex.Message}");
+        }
+        return [];
+    }
+}
@@ -17,7 +17,8 @@ namespace Cimian.Core.Version;
 public static class VersionService
 {
     private static readonly Regex VersionCleanupRegex = new(@"^v", RegexOptions.IgnoreCase | RegexOptions.Compiled);
-    private static readonly Regex PreReleaseRegex = new(@"-(.+)$", RegexOptions.Compiled);
+    private static readonly Regex CommaSeparatorRegex = new(@"\s*,\s*", RegexOptions.Compiled);
+    private static readonly Regex PreReleaseTagRegex = new(@"^([a-zA-Z]+)[.\-_]?(\d*)$", RegexOptions.Compiled);
 
     /// <summary>
     /// Returns the running Cimian agent version from assembly metadata.
@@ -70,8 +71,32 @@ public static class VersionService
     }
     
     /// <summary>
-    /// Compares two version strings.
-    /// Returns: -1 if v1 &lt; v2, 0 if v1 == v2, 1 if v1 &gt; v2
+    /// Compares two version strings. Returns: -1 if v1 &lt; v2, 0 if v1 == v2, 1 if v1 &gt; v2.
+    /// This is the one comparison every Cimian tool uses - catalog deduplication,
+    /// install status, manifest resolution, cimiimport and repoclean - so a
+    /// version sorts the same way wherever it's seen. Both sides are read with
+    /// the same rules:
+    ///
+    /// 1. Surrounding whitespace and a leading "v" are dropped.
+    /// 2. Build metadata is ignored: anything from "+" or "(" on
+    ///    ("1.2.3+sha.5114f85", "5.2.3 (git 68d178c)").
+    /// 3. Commas separate components like dots, as in FileVersion resources
+    ///    ("2025, 0, 408, 54890" is 2025.0.408.54890).
+    /// 4. Text after the first "-" is a suffix. A numeric suffix is one more
+    ///    component ("1.2.3-45" is 1.2.3.45); anything else is a pre-release tag,
+    ///    which sorts before the release: dev &lt; alpha &lt; beta/preview &lt; rc
+    ///    &lt; unknown tags &lt; no tag, then by the tag's number ("rc.2" &lt; "rc10").
+    /// 5. Components compare as numbers, so 24.09 equals 24.9 and 131.0.10 is
+    ///    newer than 131.0.2; missing components count as zero (1.2 equals 1.2.0.0).
+    ///    A component with a text tail counts by its leading digits ("3b" is 3),
+    ///    one without digits as zero ("2.45.1.windows.2" is 2.45.1.0.2).
+    /// 6. When both sides are Cimian calendar build stamps, their decoded build
+    ///    times are compared instead (see <see cref="TryParseCalendarBuildStamp"/>).
+    ///
+    /// Empty sorts before everything. A string with no digits at all
+    /// ("InternalName", which some FileVersion resources return) isn't a version
+    /// and compares equal to anything, so it can never force an install or an
+    /// upgrade on its own.
     /// </summary>
     public static int CompareVersions(string? v1, string? v2)
     {
@@ -84,39 +109,66 @@ public static class VersionService
         if (string.IsNullOrWhiteSpace(v2))
             return 1;
 
+        var clean1 = StripMetadata(v1);
+        var clean2 = StripMetadata(v2);
+
         // When both are Cimian calendar build stamps, compare by decoded build time.
         // Element-wise numeric comparison mis-orders the legacy 3-component form:
         // "2026.7.2006" -> [2026,7,2006] sorts newer than "2026.07.20.0632" ->
         // [2026,7,20,632] because 2006 > 20, which would let a stale agent consider
         // itself current and suppress its own self-update. See TryParseCalendarBuildStamp.
-        if (TryParseCalendarBuildStamp(v1, out var stamp1) &&
-            TryParseCalendarBuildStamp(v2, out var stamp2))
+        if (TryParseCalendarBuildStamp(clean1, out var stamp1) &&
+            TryParseCalendarBuildStamp(clean2, out var stamp2))
         {
             return stamp1.CompareTo(stamp2);
         }
 
-        var v1Normalized = Normalize(v1);
-        var v2Normalized = Normalize(v2);
-        
-        var v1Parsed = ParseVersion(v1Normalized);
-        var v2Parsed = ParseVersion(v2Normalized);
+        var v1Parsed = ParseVersion(clean1);
+        var v2Parsed = ParseVersion(clean2);
         
         if (v1Parsed == null || v2Parsed == null)
         {
-            return string.Compare(v1Normalized, v2Normalized, StringComparison.OrdinalIgnoreCase);
+            return 0;
         }
         
-        return v1Parsed.CompareTo(v2Parsed);
+        return Math.Sign(v1Parsed.CompareTo(v2Parsed));
     }
+
+    /// <summary>
+    /// A comparer over <see cref="CompareVersions"/>, for sorting and ordering
+    /// version strings (<c>OrderByDescending(v =&gt; v, VersionService.Comparer)</c>).
+    /// </summary>
+    public static IComparer<string?> Comparer { get; } = System.Collections.Generic.Comparer<string?>.Create(CompareVersions);
     
     /// <summary>
-    /// Returns the running Windows OS version as a string in the form "10.0.x.y".
+    /// Returns the running Windows OS version as a string in the form "10.0.x.y",
+    /// where y is the update build revision (UBR) so a minimum_os_version can pin
+    /// a cumulative update ("10.0.22631.4317"); Environment.OSVersion leaves it 0.
     /// </summary>
     public static string GetCurrentOsVersion()
     {
-        return Environment.OSVersion.Version.ToString();
+        int? ubr = null;
+        if (OperatingSystem.IsWindows())
+        {
+            try
+            {
+                using var key = Microsoft.Win32.Registry.LocalMachine.OpenSubKey(@"SOFTWARE\Microsoft\Windows NT\CurrentVersion");
+                ubr = key?.GetValue("UBR") as int?;
+            }
+            catch
+            {
+                // Unreadable key: fall back to the kernel version alone
+            }
+        }
+        return FormatOsVersion(Environment.OSVersion.Version, ubr);
     }
 
+    /// <summary>major.minor.build with the UBR as the revision when it is known.</summary>
+    internal static string FormatOsVersion(System.Version kernel, int? ubr) =>
+        ubr is >= 0
+            ? $"{kernel.Major}.{kernel.Minor}.{Math.Max(kernel.Build, 0)}.{ubr}"
+            : kernel.ToString();
+
     /// <summary>
     /// Build number at which the Windows kernel began shipping as Windows 11.
     /// Windows 11 kept the 10.0 major.minor of Windows 10 and is distinguished
@@ -134,7 +186,9 @@ public static class VersionService
     /// When the requirement is a bare marketing major ("10" or "11"), the comparison
     /// is by Windows generation only, so any Windows 11 build satisfies an "11" floor
     /// or ceiling (and any Windows 10 build satisfies a "10" one). When the requirement
-    /// pins a build (e.g. "10.0.22631"), versions are compared numerically.
+    /// pins a build (e.g. "10.0.22631"), versions are compared numerically; the
+    /// running update revision only counts when the requirement pins one too
+    /// ("10.0.22631.4317"), so "10.0.22631" covers every cumulative update of it.
     ///
     /// Returns: -1 if current is older than required, 0 if equivalent, 1 if newer.
     /// </summary>
@@ -158,7 +212,13 @@ public static class VersionService
         // --maximum_os_version, e.g. "11.0.22000") into the "10.0.<build>"
         // kernel version Windows actually reports — otherwise a build-pinned
         // Win 11 value would compare as newer than the running "10.0.<build>".
-        return CompareVersions(ToKernelWindowsVersion(current), ToKernelWindowsVersion(requirement));
+        var kernelCurrent = ToKernelWindowsVersion(current);
+        var kernelRequirement = ToKernelWindowsVersion(requirement);
+        if (kernelRequirement.Split('.').Length <= 3)
+        {
+            kernelCurrent = string.Join('.', kernelCurrent.Split('.').Take(3));
+        }
+        return CompareVersions(kernelCurrent, kernelRequirement);
     }
 
     /// <summary>
@@ -331,67 +391,55 @@ public static class VersionService
     }
 
     /// <summary>
-    /// Parses a version string into a comparable Version object.
-    /// Handles various formats: semantic, Windows build, Chrome-style, date-based.
+    /// Rules 1-3 of <see cref="CompareVersions"/>: trims, drops a leading "v"
+    /// and any "+..." or " (...)" metadata, and turns commas into dots.
+    /// </summary>
+    private static string StripMetadata(string version)
+    {
+        var clean = VersionCleanupRegex.Replace(version.Trim(), "");
+        var metadata = clean.IndexOfAny(['+', '(']);
+        if (metadata >= 0)
+        {
+            clean = clean[..metadata];
+        }
+        return CommaSeparatorRegex.Replace(clean.Trim(), ".");
+    }
+
+    /// <summary>
+    /// Rules 4 and 5 of <see cref="CompareVersions"/>: splits a cleaned version
+    /// into numeric components and an optional pre-release tag. Null when the
+    /// string has no digits, i.e. isn't a version.
     /// </summary>
     private static ParsedVersion? ParseVersion(string version)
     {
-        if (string.IsNullOrWhiteSpace(version))
+        if (!version.Any(char.IsDigit))
             return null;
-        
-        try
+
+        string? preRelease = null;
+        var dash = version.IndexOf('-');
+        if (dash >= 0)
         {
-            // Remove v prefix if present
-            var cleanVersion = VersionCleanupRegex.Replace(version.Trim(), "");
-            
-            // Extract pre-release suffix
-            string? preRelease = null;
-            var preReleaseMatch = PreReleaseRegex.Match(cleanVersion);
-            if (preReleaseMatch.Success)
+            var suffix = version[(dash + 1)..];
+            version = version[..dash];
+            if (suffix.Length > 0 && suffix.All(char.IsDigit))
             {
-                preRelease = preReleaseMatch.Groups[1].Value;
-                cleanVersion = cleanVersion[..preReleaseMatch.Index];
+                version += "." + suffix;
             }
-            
-            // Split into numeric parts
-            var parts = cleanVersion.Split('.');
-            var numericParts = new List<long>();
-            
-            foreach (var part in parts)
-            {
-                // Try to parse each part as a number
-                if (long.TryParse(part, out var num))
-                {
-                    numericParts.Add(num);
-                }
-                else
-                {
-                    // Handle non-numeric parts (like "beta1")
-                    // Try to extract leading digits
-                    var digits = new string(part.TakeWhile(char.IsDigit).ToArray());
-                    if (!string.IsNullOrEmpty(digits) && long.TryParse(digits, out var leadingNum))
-                    {
-                        numericParts.Add(leadingNum);
-                    }
-                    else
-                    {
-                        numericParts.Add(0);
-                    }
-                }
-            }
-            
-            // Ensure at least one part
-            if (numericParts.Count == 0)
+            else if (suffix.Length > 0)
             {
-                numericParts.Add(0);
+                preRelease = suffix;
             }
-            
-            return new ParsedVersion(numericParts.ToArray(), preRelease);
         }
-        catch
+
+        var numericParts = new List<long>();
+        foreach (var part in version.Split('.'))
         {
-            return null;
+            // Leading digits count ("3b" is 3); a component without any is zero
+            var digits = new string(part.TakeWhile(char.IsDigit).ToArray());
+            numericParts.Add(long.TryParse(digits, out var num) ? num : 0);
         }
+
+        return new ParsedVersion(numericParts.ToArray(), preRelease);
     }
     
     /// <summary>
@@ -449,9 +497,9 @@ public static class VersionService
                 { "dev", 0 }
             };
             
-            // Extract base name and number (e.g., "beta1" -> "beta", 1)
-            var aMatch = Regex.Match(a, @"^([a-zA-Z]+)(\d*)$");
-            var bMatch = Regex.Match(b, @"^([a-zA-Z]+)(\d*)$");
+            // Extract base name and number (e.g., "beta1" or "rc.2" -> "beta", 1 / "rc", 2)
+            var aMatch = PreReleaseTagRegex.Match(a);
+            var bMatch = PreReleaseTagRegex.Match(b);
             
             if (aMatch.Success && bMatch.Success)
             {
@@ -464,8 +512,8 @@ public static class VersionService
                 if (aOrder != bOrder) return aOrder.CompareTo(bOrder);
                 
                 // Same base, compare numbers
-                var aNum = string.IsNullOrEmpty(aMatch.Groups[2].Value) ? 0 : int.Parse(aMatch.Groups[2].Value);
-                var bNum = string.IsNullOrEmpty(bMatch.Groups[2].Value) ? 0 : int.Parse(bMatch.Groups[2].Value);
+                var aNum = long.TryParse(aMatch.Groups[2].Value, out var aParsed) ? aParsed : 0;
+                var bNum = long.TryParse(bMatch.Groups[2].Value, out var bParsed) ? bParsed : 0;
                 
                 return aNum.CompareTo(bNum);
             }
@@ -345,6 +345,14 @@ public class PredicateEngine : IPredicateEngine
         {
             return leftNum.CompareTo(rightNum);
         }
+
+        // Dotted versions ("os_version >= 10.0.22621") compare part by part, so
+        // 10.0.9200 sorts below 10.0.22621 instead of above it as text would.
+        if (Version.TryParse(left?.ToString(), out var leftVersion) &&
+            Version.TryParse(right?.ToString(), out var rightVersion))
+        {
+            return leftVersion.CompareTo(rightVersion);
+        }
         
         return string.Compare(left?.ToString(), right?.ToString(), StringComparison.OrdinalIgnoreCase);
     }
@@ -103,6 +103,23 @@ public sealed class ConsolePrompter : IImportPrompter
         return Task.FromResult(yes);
     }
 
+    public Task<bool> ReviewChangesAsync(
+        PkgsInfo previous,
+        IReadOnlyList<PkgInfoChange> changes,
+        CancellationToken cancellationToken = default)
+    {
+        Console.WriteLine();
+        foreach (var line in PkgInfoDiff.Format(previous, changes))
+        {
+            Console.WriteLine(line);
+        }
+        Console.WriteLine();
+        Console.Write("Keep these changes? (y/n) [n]: ");
+        var confirm = Console.ReadLine()?.Trim();
+        var yes = !string.IsNullOrEmpty(confirm) && confirm.Equals("y", StringComparison.OrdinalIgnoreCase);
+        return Task.FromResult(yes);
+    }
+
     public void ReportInfo(string message) => Console.WriteLine(message);
 
     public void ReportWarning(string message) => Console.WriteLine($"[WARN] {message}");
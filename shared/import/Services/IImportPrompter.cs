@@ -51,6 +51,25 @@ public interface IImportPrompter
     /// </summary>
     Task<bool> ConfirmImportAsync(PkgsInfo finalPkginfo, CancellationToken cancellationToken = default);
 
+    /// <summary>
+    /// The import is a new version of an existing item and its pkginfo differs
+    /// from the previous version's in more than version, hash and location (see
+    /// <see cref="PkgInfoDiff"/>). Show <paramref name="changes"/> and return
+    /// <c>false</c> to cancel. The default reports the diff as warnings and
+    /// continues, so hosts without their own review screen keep working.
+    /// </summary>
+    Task<bool> ReviewChangesAsync(
+        PkgsInfo previous,
+        IReadOnlyList<PkgInfoChange> changes,
+        CancellationToken cancellationToken = default)
+    {
+        foreach (var line in PkgInfoDiff.Format(previous, changes))
+        {
+            ReportWarning(line);
+        }
+        return Task.FromResult(true);
+    }
+
     /// <summary>Generic informational message (e.g. "Calculating file hash…").</summary>
     void ReportInfo(string message);
 
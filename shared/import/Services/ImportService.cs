@@ -3,6 +3,7 @@ using System.Globalization;
 using Cimian.CLI.Cimiimport.Models;
 using Cimian.Core;
 using Cimian.Core.Services;
+using Cimian.Core.Version;
 
 namespace Cimian.CLI.Cimiimport.Services;
 
@@ -87,6 +88,15 @@ public class ImportService
             }
         }
 
+        // Step 3b: Warn when this exact binary is already in the repo, usually under
+        // another name or version, before a near-identical duplicate entry is created.
+        prompter.ReportInfo("Calculating file hash...");
+        var fileHash = MetadataExtractor.CalculateSHA256(packagePath);
+        foreach (var duplicate in FindItemsWithHash(config.RepoPath, fileHash))
+        {
+            prompter.ReportWarning($"This exact binary already exists as {duplicate.Name} v{duplicate.Version}");
+        }
+
         // Step 4: Let the user review/edit the seven metadata fields.
         metadata = await prompter.EditMetadataAsync(metadata, config, cancellationToken).ConfigureAwait(false);
 
@@ -105,9 +115,7 @@ public class ImportService
             uninstaller = ProcessUninstaller(uninstallerPath, config.RepoPath, prompter);
         }
 
-        // Step 7: Calculate file hash and size
-        prompter.ReportInfo("Calculating file hash...");
-        var fileHash = MetadataExtractor.CalculateSHA256(packagePath);
+        // Step 7: File size (the hash was taken in step 3b)
         var fileInfo = new FileInfo(packagePath);
         var fileSizeKB = fileInfo.Length / 1024;
 
@@ -184,6 +192,20 @@ public class ImportService
             ];
         }
 
+        // Step 10b: For a new version of an existing item, show what differs from the
+        // previous pkginfo (scripts, arguments, uninstallers lost in template reuse)
+        // and let the user back out before anything is written.
+        if (existingPkg != null)
+        {
+            var changes = PkgInfoDiff.Compare(existingPkg, pkgsInfo);
+            if (changes.Count > 0
+                && !await prompter.ReviewChangesAsync(existingPkg, changes, cancellationToken).ConfigureAwait(false))
+            {
+                prompter.ReportInfo("Import canceled.");
+                return false;
+            }
+        }
+
         // Step 11: Final review + confirm. Prompter renders the summary; we just supply
         // the assembled pkginfo so any frontend can present it however it likes.
         var confirmed = await prompter.ConfirmImportAsync(pkgsInfo, cancellationToken).ConfigureAwait(false);
@@ -347,7 +369,7 @@ public class ImportService
 
             // Return the item with the highest version
             var best = matches
-                .OrderByDescending(i => i.Version, new VersionComparer())
+                .OrderByDescending(i => i.Version, VersionService.Comparer)
                 .First();
 
             return (best, true);
@@ -359,31 +381,30 @@ public class ImportService
     }
 
     /// <summary>
-    /// Compares dot-separated version strings numerically.
-    /// e.g. "2026.01.28" > "2025.11.27"
+    /// Items in All.yaml whose installer hash matches <paramref name="hash"/>.
+    /// Reads the catalog as left by <see cref="FindMatchingItemInAllCatalog"/>,
+    /// so it doesn't run makecatalogs again.
     /// </summary>
-    private class VersionComparer : IComparer<string>
+    public static List<PkgsInfo> FindItemsWithHash(string repoPath, string hash)
     {
-        public int Compare(string? x, string? y)
+        var allCatalogPath = Path.Combine(repoPath, "catalogs", "All.yaml");
+        if (string.IsNullOrEmpty(hash) || !File.Exists(allCatalogPath))
         {
-            if (x == y) return 0;
-            if (x is null) return -1;
-            if (y is null) return 1;
-
-            var parts1 = x.Split('.');
-            var parts2 = y.Split('.');
-            var maxLen = Math.Max(parts1.Length, parts2.Length);
-
-            for (int i = 0; i < maxLen; i++)
-            {
-                int p1 = i < parts1.Length && int.TryParse(parts1[i], out var v1) ? v1 : 0;
-                int p2 = i < parts2.Length && int.TryParse(parts2[i], out var v2) ? v2 : 0;
-
-                if (p1 < p2) return -1;
-                if (p1 > p2) return 1;
-            }
+            return [];
+        }
 
-            return 0;
+        try
+        {
+            var catalog = YamlUtils.Deserializer.Deserialize<AllCatalog>(File.ReadAllText(allCatalogPath));
+            return catalog?.Items?
+                .Where(i => string.Equals(i.Installer?.Hash, hash, StringComparison.OrdinalIgnoreCase))
+                .OrderBy(i => i.Name, StringComparer.OrdinalIgnoreCase)
+                .ThenBy(i => i.Version, VersionService.Comparer)
+                .ToList() ?? [];
+        }
+        catch
+        {
+            return [];
         }
     }
 
@@ -727,8 +748,7 @@ public class ImportService
 
                 if (Path.GetExtension(absPath).Equals(".exe", StringComparison.OrdinalIgnoreCase))
                 {
-                    var versionInfo = FileVersionInfo.GetVersionInfo(absPath);
-                    version = versionInfo.FileVersion;
+                    version = MetadataExtractor.ExtractFileVersion(absPath);
                 }
             }
             catch
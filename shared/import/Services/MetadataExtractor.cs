@@ -661,6 +661,30 @@ public partial class MetadataExtractor
         return Convert.ToHexString(hash).ToLowerInvariant();
     }
 
+    /// <summary>
+    /// File version of a PE file as Major.Minor.Build.Revision from its fixed
+    /// file info, the form managedsoftwareupdate compares installs entries
+    /// against. Falls back to the FileVersion string without trailing
+    /// " (...)" text when the numeric parts are all zero.
+    /// </summary>
+    public static string? ExtractFileVersion(string filePath)
+    {
+        var versionInfo = FileVersionInfo.GetVersionInfo(filePath);
+        if (versionInfo.FileMajorPart > 0 || versionInfo.FileMinorPart > 0 ||
+            versionInfo.FileBuildPart > 0 || versionInfo.FilePrivatePart > 0)
+        {
+            return $"{versionInfo.FileMajorPart}.{versionInfo.FileMinorPart}.{versionInfo.FileBuildPart}.{versionInfo.FilePrivatePart}";
+        }
+
+        var version = versionInfo.FileVersion;
+        if (string.IsNullOrWhiteSpace(version))
+        {
+            return null;
+        }
+        var parenIndex = version.IndexOf('(');
+        return (parenIndex > 0 ? version[..parenIndex] : version).Trim();
+    }
+
     /// <summary>
     /// Parses package name from filename.
     /// </summary>
@@ -49,6 +49,19 @@ public sealed class NoInteractivePrompter : IImportPrompter
     public Task<bool> ConfirmImportAsync(PkgsInfo finalPkginfo, CancellationToken cancellationToken = default)
         => Task.FromResult(true);
 
+    /// <summary>Log the diff against the previous version so CI output shows it, then proceed.</summary>
+    public Task<bool> ReviewChangesAsync(
+        PkgsInfo previous,
+        IReadOnlyList<PkgInfoChange> changes,
+        CancellationToken cancellationToken = default)
+    {
+        foreach (var line in PkgInfoDiff.Format(previous, changes))
+        {
+            _status.WriteLine(line);
+        }
+        return Task.FromResult(true);
+    }
+
     public void ReportInfo(string message) => _status.WriteLine(message);
 
     public void ReportWarning(string message) => _status.WriteLine($"[WARN] {message}");
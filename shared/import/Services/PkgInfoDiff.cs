@@ -0,0 +1,186 @@
+using Cimian.CLI.Cimiimport.Models;
+
+namespace Cimian.CLI.Cimiimport.Services;
+
+public enum PkgInfoChangeKind
+{
+    Added,
+    Removed,
+    Changed
+}
+
+/// <summary>
+/// One pkginfo field that differs from the previous version, named by its YAML
+/// key (installer fields as <c>installer.arguments</c> and so on).
+/// </summary>
+public sealed record PkgInfoChange(string Field, PkgInfoChangeKind Kind, string? Previous, string? Current)
+{
+    /// <summary>Changed lines of a script field as "- old" / "+ new"; empty for other fields.</summary>
+    public IReadOnlyList<string> ScriptDiff { get; init; } = [];
+}
+
+/// <summary>
+/// Compares a freshly built pkginfo with the previous version's so a review
+/// step can show what template reuse dropped or changed (scripts, installer
+/// arguments, uninstallers) before anything is written. Fields that change with
+/// every version (version, hash, size, location, product code, installs
+/// versions and checksums) are left out.
+/// </summary>
+public static class PkgInfoDiff
+{
+    public static List<PkgInfoChange> Compare(PkgsInfo previous, PkgsInfo current)
+    {
+        var changes = new List<PkgInfoChange>();
+
+        AddScalar(changes, "name", previous.Name, current.Name);
+        AddScalar(changes, "display_name", previous.DisplayName, current.DisplayName);
+        AddScalar(changes, "identifier", previous.Identifier, current.Identifier);
+        AddScalar(changes, "description", previous.Description, current.Description);
+        AddScalar(changes, "category", previous.Category, current.Category);
+        AddScalar(changes, "developer", previous.Developer, current.Developer);
+        AddScalar(changes, "icon_name", previous.IconName, current.IconName);
+        AddList(changes, "catalogs", previous.Catalogs, current.Catalogs);
+        AddList(changes, "supported_architectures", previous.SupportedArch, current.SupportedArch);
+        AddScalar(changes, "unattended_install", previous.UnattendedInstall.ToString().ToLowerInvariant(), current.UnattendedInstall.ToString().ToLowerInvariant());
+        AddScalar(changes, "unattended_uninstall", previous.UnattendedUninstall.ToString().ToLowerInvariant(), current.UnattendedUninstall.ToString().ToLowerInvariant());
+        AddList(changes, "requires", previous.Requires, current.Requires);
+        AddList(changes, "update_for", previous.UpdateFor, current.UpdateFor);
+        AddList(changes, "blocking_applications", previous.BlockingApps, current.BlockingApps);
+        AddScalar(changes, "minimum_os_version", previous.MinOSVersion, current.MinOSVersion);
+        AddScalar(changes, "maximum_os_version", previous.MaxOSVersion, current.MaxOSVersion);
+        AddScalar(changes, "minimum_cimian_version", previous.MinCimianVersion, current.MinCimianVersion);
+
+        AddScalar(changes, "installer.type", previous.Installer?.Type, current.Installer?.Type);
+        AddScalar(changes, "installer.arguments", JoinArguments(previous.Installer?.Arguments), JoinArguments(current.Installer?.Arguments));
+        AddScalar(changes, "installer.upgrade_code", previous.Installer?.UpgradeCode, current.Installer?.UpgradeCode);
+        AddScalar(changes, "installer.identity_name", previous.Installer?.IdentityName, current.Installer?.IdentityName);
+        AddList(changes, "uninstaller", previous.Uninstaller?.Select(DescribeUninstaller).ToList(), current.Uninstaller?.Select(DescribeUninstaller).ToList());
+        AddList(changes, "installs", previous.Installs?.Select(i => $"{i.Type}:{i.Path}").ToList(), current.Installs?.Select(i => $"{i.Type}:{i.Path}").ToList());
+
+        AddScript(changes, "preinstall_script", previous.PreinstallScript, current.PreinstallScript);
+        AddScript(changes, "postinstall_script", previous.PostinstallScript, current.PostinstallScript);
+        AddScript(changes, "preuninstall_script", previous.PreuninstallScript, current.PreuninstallScript);
+        AddScript(changes, "postuninstall_script", previous.PostuninstallScript, current.PostuninstallScript);
+        AddScript(changes, "installcheck_script", previous.InstallCheckScript, current.InstallCheckScript);
+        AddScript(changes, "uninstallcheck_script", previous.UninstallCheckScript, current.UninstallCheckScript);
+
+        return changes;
+    }
+
+    /// <summary>
+    /// Console rendering: a header naming the previous version, then one line per
+    /// change (+ added, - removed, ~ changed) with script diffs indented below.
+    /// </summary>
+    public static List<string> Format(PkgsInfo previous, IReadOnlyList<PkgInfoChange> changes)
+    {
+        var lines = new List<string> { $"Changes from {previous.Name} v{previous.Version}:" };
+        foreach (var change in changes)
+        {
+            lines.Add(change.Kind switch
+            {
+                PkgInfoChangeKind.Added => $"  + {change.Field}: {Summarize(change.Current)}",
+                PkgInfoChangeKind.Removed => $"  - {change.Field}: {Summarize(change.Previous)}",
+                _ => change.ScriptDiff.Count > 0
+                    ? $"  ~ {change.Field}:"
+                    : $"  ~ {change.Field}: {Summarize(change.Previous)} -> {Summarize(change.Current)}"
+            });
+            lines.AddRange(change.ScriptDiff.Select(l => "      " + l));
+        }
+        return lines;
+    }
+
+    /// <summary>
+    /// Lines removed from and added to <paramref name="previous"/>, in order, via
+    /// a longest-common-subsequence walk. Unchanged lines are omitted.
+    /// </summary>
+    public static List<string> DiffLines(string previous, string current)
+    {
+        var a = SplitLines(previous);
+        var b = SplitLines(current);
+        var lcs = new int[a.Length + 1, b.Length + 1];
+        for (var i = a.Length - 1; i >= 0; i--)
+        {
+            for (var j = b.Length - 1; j >= 0; j--)
+            {
+                lcs[i, j] = a[i] == b[j] ? lcs[i + 1, j + 1] + 1 : Math.Max(lcs[i + 1, j], lcs[i, j + 1]);
+            }
+        }
+
+        var diff = new List<string>();
+        int x = 0, y = 0;
+        while (x < a.Length && y < b.Length)
+        {
+            if (a[x] == b[y])
+            {
+                x++;
+                y++;
+            }
+            else if (lcs[x + 1, y] >= lcs[x, y + 1])
+            {
+                diff.Add("- " + a[x++]);
+            }
+            else
+            {
+                diff.Add("+ " + b[y++]);
+            }
+        }
+        diff.AddRange(a.Skip(x).Select(l => "- " + l));
+        diff.AddRange(b.Skip(y).Select(l => "+ " + l));
+        return diff;
+    }
+
+    private static void AddScalar(List<PkgInfoChange> changes, string field, string? previous, string? current)
+    {
+        var hadValue = !string.IsNullOrWhiteSpace(previous);
+        var hasValue = !string.IsNullOrWhiteSpace(current);
+        if (hadValue && !hasValue)
+        {
+            changes.Add(new PkgInfoChange(field, PkgInfoChangeKind.Removed, previous, null));
+        }
+        else if (!hadValue && hasValue)
+        {
+            changes.Add(new PkgInfoChange(field, PkgInfoChangeKind.Added, null, current));
+        }
+        else if (hadValue && previous!.Trim() != current!.Trim())
+        {
+            changes.Add(new PkgInfoChange(field, PkgInfoChangeKind.Changed, previous, current));
+        }
+    }
+
+    private static void AddList(List<PkgInfoChange> changes, string field, List<string>? previous, List<string>? current)
+    {
+        AddScalar(changes, field,
+            previous is { Count: > 0 } ? string.Join(", ", previous) : null,
+            current is { Count: > 0 } ? string.Join(", ", current) : null);
+    }
+
+    private static void AddScript(List<PkgInfoChange> changes, string field, string? previous, string? current)
+    {
+        var before = changes.Count;
+        AddScalar(changes, field, previous, current);
+        if (changes.Count > before && changes[^1].Kind == PkgInfoChangeKind.Changed)
+        {
+            changes[^1] = changes[^1] with { ScriptDiff = DiffLines(previous!, current!) };
+        }
+    }
+
+    private static string? JoinArguments(List<string>? arguments) =>
+        arguments is { Count: > 0 } ? string.Join(" ", arguments) : null;
+
+    private static string DescribeUninstaller(Installer uninstaller)
+    {
+        var target = !string.IsNullOrEmpty(uninstaller.Location) ? uninstaller.Location : uninstaller.IdentityName;
+        var arguments = JoinArguments(uninstaller.Arguments);
+        return string.Join(" ", new[] { uninstaller.Type, target, arguments }.Where(p => !string.IsNullOrEmpty(p)));
+    }
+
+    private static string[] SplitLines(string text) =>
+        text.Replace("\r\n", "\n").TrimEnd('\n').Split('\n');
+
+    private static string Summarize(string? value)
+    {
+        var lines = SplitLines(value ?? "");
+        var first = lines[0].Length > 80 ? lines[0][..80] : lines[0];
+        return first.Length < lines[0].Length || lines.Length > 1 ? first + "..." : first;
+    }
+}
@@ -52,6 +52,41 @@ public class SystemFactsCollector : ISystemFactsCollector
         _catalogs = catalogs.ToList();
     }
 
+    private static SystemFacts? _sessionFacts;
+    private static readonly SemaphoreSlim SessionLock = new(1, 1);
+
+    /// <summary>
+    /// Facts collected once per process and shared by everything in the run, so
+    /// each manifest reload doesn't repeat the WMI queries. Pass
+    /// <paramref name="refresh"/> to collect again (e.g. after installs changed
+    /// free disk space).
+    /// </summary>
+    public async Task<SystemFacts> GetSessionFactsAsync(bool refresh = false)
+    {
+        await SessionLock.WaitAsync();
+        try
+        {
+            if (refresh || _sessionFacts == null)
+            {
+                _sessionFacts = await CollectAsync();
+            }
+            _sessionFacts.Catalogs = _catalogs;
+            return _sessionFacts;
+        }
+        finally
+        {
+            SessionLock.Release();
+        }
+    }
+
+    /// <summary>
+    /// Drops the session facts so the next <see cref="GetSessionFactsAsync"/> collects again.
+    /// </summary>
+    public static void InvalidateSessionFacts()
+    {
+        _sessionFacts = null;
+    }
+
     public async Task<SystemFacts> CollectAsync()
     {
         _logger.LogDebug("Collecting system facts...");
@@ -213,7 +248,10 @@ public class SystemFactsCollector : ISystemFactsCollector
 
             // Determine machine type (laptop, desktop, virtual, server)
             facts.MachineType = DetermineMachineType();
+            facts.ChassisType = GetChassisType();
             facts.BatteryState = GetBatteryState();
+            facts.HasBattery = facts.BatteryState != "unknown";
+            facts.SerialNumber = GetSerialNumber();
         }
         catch (Exception ex)
         {
@@ -284,6 +322,83 @@ public class SystemFactsCollector : ISystemFactsCollector
         return "desktop";
     }
 
+    private string GetChassisType()
+    {
+        try
+        {
+            using var searcher = new ManagementObjectSearcher("SELECT ChassisTypes FROM Win32_SystemEnclosure");
+            foreach (ManagementObject mo in searcher.Get())
+            {
+                if (mo["ChassisTypes"] is ushort[] { Length: > 0 } chassisTypes)
+                {
+                    return ChassisTypeName(chassisTypes[0]);
+                }
+            }
+        }
+        catch (Exception ex)
+        {
+            _logger.LogWarning(ex, "Failed to read chassis type");
+        }
+
+        return "unknown";
+    }
+
+    /// <summary>
+    /// SMBIOS System Enclosure type (DSP0134 7.4.1) as a snake_case name.
+    /// </summary>
+    public static string ChassisTypeName(ushort chassisType) => chassisType switch
+    {
+        3 => "desktop",
+        4 => "low_profile_desktop",
+        5 => "pizza_box",
+        6 => "mini_tower",
+        7 => "tower",
+        8 => "portable",
+        9 => "laptop",
+        10 => "notebook",
+        11 => "hand_held",
+        12 => "docking_station",
+        13 => "all_in_one",
+        14 => "sub_notebook",
+        15 => "space_saving",
+        16 => "lunch_box",
+        17 => "main_server_chassis",
+        23 => "rack_mount",
+        24 => "sealed_case_pc",
+        25 => "multi_system",
+        28 => "blade",
+        30 => "tablet",
+        31 => "convertible",
+        32 => "detachable",
+        33 => "iot_gateway",
+        34 => "embedded_pc",
+        35 => "mini_pc",
+        36 => "stick_pc",
+        _ => "other"
+    };
+
+    private string GetSerialNumber()
+    {
+        try
+        {
+            using var searcher = new ManagementObjectSearcher("SELECT SerialNumber FROM Win32_BIOS");
+            foreach (ManagementObject mo in searcher.Get())
+            {
+                var serial = mo["SerialNumber"]?.ToString()?.Trim() ?? "";
+                if (!string.IsNullOrEmpty(serial))
+                {
+                    return serial;
+                }
+            }
+        }
+        catch (Exception ex)
+        {
+            _logger.LogWarning(ex, "Failed to read BIOS serial number");
+        }
+
+        return string.Empty;
+    }
+
     private string GetBatteryState()
     {
         try
@@ -762,8 +877,14 @@ public class SystemFactsCollector : ISystemFactsCollector
                 }
             }
 
-            _logger.LogDebug("Storage info collected: type={Type}, capacity={Capacity}GB",
-                facts.StorageType, facts.StorageCapacityGb);
+            var systemRoot = Path.GetPathRoot(Environment.SystemDirectory);
+            if (!string.IsNullOrEmpty(systemRoot))
+            {
+                facts.FreeDiskGb = (long)Math.Round(new DriveInfo(systemRoot).AvailableFreeSpace / (1024.0 * 1024.0 * 1024.0));
+            }
+
+            _logger.LogDebug("Storage info collected: type={Type}, capacity={Capacity}GB, free={Free}GB",
+                facts.StorageType, facts.StorageCapacityGb, facts.FreeDiskGb);
         }
         catch (Exception ex)
         {
@@ -227,4 +227,40 @@ public class ImportServiceTests
     {
         Assert.Throws<ArgumentException>(() => ImportService.NormalizeRepoSubPath(input));
     }
+
+    [Fact]
+    public void FindItemsWithHash_ReturnsEveryCatalogItemWithThatInstallerHash()
+    {
+        var repo = Path.Combine(Path.GetTempPath(), "CimianTests", "ImportDuplicates", Guid.NewGuid().ToString());
+        Directory.CreateDirectory(Path.Combine(repo, "catalogs"));
+        try
+        {
+            File.WriteAllText(Path.Combine(repo, "catalogs", "All.yaml"), """
+                items:
+                  - name: Chrome
+                    version: 130.0
+                    installer:
+                      hash: ABC123
+                  - name: GoogleChrome
+                    version: 130.0.1
+                    installer:
+                      hash: abc123
+                  - name: Zoom
+                    version: 6.2
+                    installer:
+                      hash: def456
+                """);
+
+            var matches = ImportService.FindItemsWithHash(repo, "abc123");
+
+            Assert.Equal(new[] { "Chrome", "GoogleChrome" }, matches.Select(m => m.Name));
+            Assert.Equal("130.0.1", matches[1].Version);
+            Assert.Empty(ImportService.FindItemsWithHash(repo, "999"));
+            Assert.Empty(ImportService.FindItemsWithHash(Path.Combine(repo, "missing"), "abc123"));
+        }
+        finally
+        {
+            try { Directory.Delete(repo, true); } catch { }
+        }
+    }
 }
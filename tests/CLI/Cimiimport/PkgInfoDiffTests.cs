@@ -0,0 +1,73 @@
+using Cimian.CLI.Cimiimport.Models;
+using Cimian.CLI.Cimiimport.Services;
+using Xunit;
+
+namespace Cimian.Tests.CLI.Cimiimport;
+
+public class PkgInfoDiffTests
+{
+    private static PkgsInfo Slack(string version) => new()
+    {
+        Name = "Slack",
+        Version = version,
+        Description = "Team chat",
+        Catalogs = ["Production"],
+        UnattendedInstall = true,
+        Installer = new Installer { Type = "exe", Hash = $"hash-{version}", Location = $"apps/Slack-{version}.exe", Arguments = ["/S", "--machine"] },
+        PostinstallScript = "Write-Host 'start'\nRemove-Item $desktopShortcut\nWrite-Host 'done'"
+    };
+
+    [Fact]
+    public void Compare_IgnoresVersionHashAndLocation()
+    {
+        Assert.Empty(PkgInfoDiff.Compare(Slack("4.49"), Slack("4.50")));
+    }
+
+    [Fact]
+    public void Compare_ReportsLostArgumentsAndChangedScriptLines()
+    {
+        var previous = Slack("4.49");
+        var current = Slack("4.50");
+        current.Installer!.Arguments = null;
+        current.Requires = ["VCRedist"];
+        current.PostinstallScript = "Write-Host 'start'\nWrite-Host 'done'\nStart-Process slack";
+
+        var changes = PkgInfoDiff.Compare(previous, current);
+
+        Assert.Equal(new[] { "requires", "installer.arguments", "postinstall_script" }, changes.Select(c => c.Field));
+        Assert.Equal(PkgInfoChangeKind.Added, changes[0].Kind);
+        Assert.Equal(PkgInfoChangeKind.Removed, changes[1].Kind);
+        Assert.Equal("/S --machine", changes[1].Previous);
+        Assert.Equal(new[] { "- Remove-Item $desktopShortcut", "+ Start-Process slack" }, changes[2].ScriptDiff);
+    }
+
+    [Fact]
+    public void Format_NamesPreviousVersionAndMarksEachChange()
+    {
+        var previous = Slack("4.49");
+        var current = Slack("4.50");
+        current.PreinstallScript = "Stop-Process -Name slack";
+        current.Description = "Slack for teams";
+
+        var lines = PkgInfoDiff.Format(previous, PkgInfoDiff.Compare(previous, current));
+
+        Assert.Equal("Changes from Slack v4.49:", lines[0]);
+        Assert.Contains("  ~ description: Team chat -> Slack for teams", lines);
+        Assert.Contains("  + preinstall_script: Stop-Process -Name slack", lines);
+    }
+
+    [Fact]
+    public async Task NoInteractivePrompter_LogsDiffAndProceeds()
+    {
+        var output = new StringWriter();
+        var prompter = new NoInteractivePrompter(output);
+        var previous = Slack("4.49");
+        var current = Slack("4.50");
+        current.PostinstallScript = null;
+
+        var proceed = await prompter.ReviewChangesAsync(previous, PkgInfoDiff.Compare(previous, current));
+
+        Assert.True(proceed);
+        Assert.Contains("- postinstall_script: Write-Host 'start'...", output.ToString());
+    }
+}
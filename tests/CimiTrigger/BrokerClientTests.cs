@@ -0,0 +1,52 @@
+using Cimian.Core.Services;
+using CimianTools.CimiTrigger.Models;
+using CimianTools.CimiTrigger.Services;
+using Xunit;
+
+namespace Cimian.Tests.CimiTrigger;
+
+/// <summary>
+/// Tests for BrokerClient.
+/// </summary>
+public class BrokerClientTests
+{
+    [Theory]
+    [InlineData(TriggerMode.Gui, "gui")]
+    [InlineData(TriggerMode.Headless, "headless")]
+    public void CreateRequest_MapsTriggerMode(TriggerMode mode, string expected)
+    {
+        var request = BrokerClient.CreateRequest(mode);
+
+        Assert.Equal(expected, request.Mode);
+        Assert.Null(request.Items);
+    }
+
+    [Fact]
+    public void CreateRequest_WithItems_LimitsRunToThem()
+    {
+        var request = BrokerClient.CreateRequest(TriggerMode.Headless, ["Zoom"]);
+
+        Assert.Equal(new[] { "Zoom" }, request.Items);
+        Assert.Equal("--auto --show-status --item \"Zoom\"", RunBrokerProtocol.BuildArguments(request, out _));
+    }
+
+    [Fact]
+    public void CreateCheckRequest_IsAllowedOnTheUserPipe()
+    {
+        var request = BrokerClient.CreateCheckRequest();
+
+        Assert.Equal(RunBrokerProtocol.CheckMode, request.Mode);
+        Assert.True(RunBrokerProtocol.IsUserRequest(request));
+        Assert.Equal("--auto --show-status", RunBrokerProtocol.BuildArguments(request, out _));
+    }
+
+    [Fact]
+    public async Task RequestRunAsync_NoBroker_ReturnsNull()
+    {
+        var client = new BrokerClient($"cimian-test-{Guid.NewGuid():N}");
+
+        var response = await client.RequestRunAsync(TriggerMode.Headless, connectTimeoutMs: 200);
+
+        Assert.Null(response);
+    }
+}
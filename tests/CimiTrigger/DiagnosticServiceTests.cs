@@ -6,8 +6,7 @@ namespace Cimian.Tests.CimiTrigger;
 /// <summary>
 /// Tests for DiagnosticService.
 /// NOTE: We do NOT call RunDiagnostics() in tests because on a machine with CimianWatcher
-/// installed, it creates trigger files that would cause the service to launch
-/// managedsoftwareupdate.exe with real package installations.
+/// installed, it talks to the real service's pipes and writes to C:\Windows\Temp.
 /// </summary>
 public class DiagnosticServiceTests
 {
@@ -0,0 +1,68 @@
+using Cimian.Core.Services;
+using CimianTools.CimiTrigger.Services;
+using Xunit;
+
+namespace Cimian.Tests.CimiTrigger;
+
+/// <summary>
+/// Tests for ScheduleClient.
+/// </summary>
+public class ScheduleClientTests : StoreTestBase
+{
+    [Fact]
+    public void FormatStatus_Paused_ShowsWhoAndUntilWhen()
+    {
+        var lines = ScheduleClient.FormatStatus(new ScheduleStatus
+        {
+            Enabled = true,
+            IntervalMinutes = 60,
+            SplayMinutes = 10,
+            NextRun = Now.AddHours(4),
+            PausedUntil = Now.AddHours(4),
+            PausedBy = @"CONTOSO\admin",
+            LastRunEnd = Now.AddHours(-1),
+            LastRunOutcome = "success"
+        });
+
+        Assert.Equal(new[]
+        {
+            "Auto runs: every 60 minutes, plus up to 10 minutes splay",
+            @"Paused until: 2026-10-16 13:00 (by CONTOSO\admin)",
+            "Next run: 2026-10-16 13:00",
+            "Last run finished: 2026-10-16 08:00 (success)"
+        }, lines);
+    }
+
+    [Fact]
+    public void FormatStatus_HoldWithReason_ShownWithoutScheduler()
+    {
+        var lines = ScheduleClient.FormatStatus(new ScheduleStatus
+        {
+            PausedUntil = Now.AddDays(2),
+            PausedBy = @"CONTOSO\admin",
+            PauseReason = "Quarter-end close"
+        });
+
+        Assert.Equal(new[]
+        {
+            "Auto runs: scheduled task (AutoRunIntervalMinutes is not set)",
+            @"Paused until: 2026-10-18 09:00 - Quarter-end close (by CONTOSO\admin)"
+        }, lines);
+    }
+
+    [Fact]
+    public void FormatStatus_Disabled_PointsAtScheduledTask()
+    {
+        var lines = ScheduleClient.FormatStatus(new ScheduleStatus { UpdateRunning = true });
+
+        Assert.Equal(new[] { "Auto runs: scheduled task (AutoRunIntervalMinutes is not set)", "An update is running now" }, lines);
+    }
+
+    [Fact]
+    public async Task ShowStatusAsync_NoBroker_Fails()
+    {
+        var client = new ScheduleClient(new BrokerClient($"cimian-test-{Guid.NewGuid():N}"));
+
+        Assert.False(await client.ShowStatusAsync());
+    }
+}
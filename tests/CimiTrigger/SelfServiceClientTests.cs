@@ -0,0 +1,101 @@
+using Cimian.Core.Models;
+using Cimian.Core.Services;
+using CimianTools.CimiTrigger.Services;
+using Moq;
+using Xunit;
+
+namespace Cimian.Tests.CimiTrigger;
+
+/// <summary>
+/// Tests for SelfServiceClient.
+/// </summary>
+public class SelfServiceClientTests : IDisposable
+{
+    private readonly string _testDir;
+    private readonly string _installInfoPath;
+    private readonly Mock<ISelfServiceManifestService> _manifest = new();
+    private readonly SelfServiceClient _client;
+
+    public SelfServiceClientTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "cimitrigger_tests", Guid.NewGuid().ToString());
+        Directory.CreateDirectory(_testDir);
+        _installInfoPath = Path.Combine(_testDir, "InstallInfo.yaml");
+        File.WriteAllText(_installInfoPath, """
+            optional_installs:
+              - name: Zoom
+                display_name: Zoom Workplace
+                version_to_install: 6.2.0
+                description: Video meetings
+                icon_name: Zoom.png
+              - name: Blender
+                version_to_install: 4.2.1
+                installed: true
+                uninstallable: true
+            """);
+        _manifest.Setup(m => m.LoadAsync()).ReturnsAsync(new SelfServiceManifest());
+        _client = new SelfServiceClient(_installInfoPath, _manifest.Object, new BrokerClient($"cimian-test-{Guid.NewGuid():N}"));
+    }
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    [Fact]
+    public void FormatAvailable_ShowsStateDescriptionAndIcon()
+    {
+        var lines = SelfServiceClient.FormatAvailable(_client.LoadOptionalInstalls(), ["zoom"]);
+
+        Assert.Equal(new[]
+        {
+            "Blender (Blender 4.2.1) - installed",
+            "Zoom Workplace (Zoom 6.2.0) - install requested",
+            "    Video meetings",
+            "    icon: Zoom.png"
+        }, lines);
+    }
+
+    [Fact]
+    public async Task RequestInstallAsync_ByDisplayName_RecordsRequestEvenWithoutBroker()
+    {
+        Assert.True(await _client.RequestInstallAsync("zoom workplace"));
+
+        _manifest.Verify(m => m.AddInstallRequestAsync("Zoom"), Times.Once);
+    }
+
+    [Fact]
+    public async Task RequestInstallAsync_ItemNotOffered_RecordsNothing()
+    {
+        Assert.False(await _client.RequestInstallAsync("Photoshop"));
+        Assert.True(await _client.RequestInstallAsync("Blender"));
+
+        _manifest.Verify(m => m.AddInstallRequestAsync(It.IsAny<string>()), Times.Never);
+    }
+
+    [Fact]
+    public async Task RequestRemovalAsync_InstalledItem_RecordsRequest()
+    {
+        Assert.True(await _client.RequestRemovalAsync("blender"));
+        Assert.True(await _client.RequestRemovalAsync("Zoom"));
+
+        _manifest.Verify(m => m.AddRemovalRequestAsync("Blender"), Times.Once);
+        _manifest.Verify(m => m.AddRemovalRequestAsync("Zoom"), Times.Never);
+    }
+
+    [Fact]
+    public async Task RequestRemovalAsync_NotUninstallable_IsRefused()
+    {
+        File.WriteAllText(_installInfoPath, """
+            optional_installs:
+              - name: Office
+                version_to_install: 16.0
+                installed: true
+                uninstallable: false
+            """);
+
+        Assert.False(await _client.RequestRemovalAsync("Office"));
+
+        _manifest.Verify(m => m.AddRemovalRequestAsync(It.IsAny<string>()), Times.Never);
+    }
+}
@@ -0,0 +1,64 @@
+using Xunit;
+using FluentAssertions;
+using Cimian.CLI.Cimiwatcher.Services;
+
+namespace Cimian.Tests.Cimiwatcher;
+
+/// <summary>
+/// Coverage for the auto-run scheduler's timing: startup runs, interval and
+/// splay, pauses, and the limits on the configured values.
+/// </summary>
+public class AutoRunSchedulerTests : StoreTestBase
+{
+    [Fact]
+    public void FirstRun_AtStartup_RunsAfterStartupDelayPlusSplay()
+    {
+        var config = new AutoRunConfig { AutoRunIntervalMinutes = 60, AutoRunSplayMinutes = 10 };
+
+        AutoRunSchedule.FirstRun(config, Now, lastRun: Now.AddMinutes(-5), jitter: 0.5)
+            .Should().Be(Now + AutoRunSchedule.StartupDelay + TimeSpan.FromMinutes(5));
+    }
+
+    [Fact]
+    public void FirstRun_NotAtStartup_WaitsAnIntervalFromTheLastRun()
+    {
+        var config = new AutoRunConfig { AutoRunIntervalMinutes = 60, AutoRunSplayMinutes = 0, AutoRunAtStartup = false };
+
+        AutoRunSchedule.FirstRun(config, Now, Now.AddMinutes(-20), 0).Should().Be(Now.AddMinutes(40));
+        // Overdue: as soon as the startup delay allows
+        AutoRunSchedule.FirstRun(config, Now, Now.AddHours(-3), 0).Should().Be(Now + AutoRunSchedule.StartupDelay);
+        // Never ran: a full interval
+        AutoRunSchedule.FirstRun(config, Now, null, 0).Should().Be(Now.AddMinutes(60));
+    }
+
+    [Fact]
+    public void NextRun_AddsIntervalAndShareOfSplay()
+    {
+        var config = new AutoRunConfig { AutoRunIntervalMinutes = 120, AutoRunSplayMinutes = 30 };
+
+        AutoRunSchedule.NextRun(config, Now, 0).Should().Be(Now.AddMinutes(120));
+        AutoRunSchedule.NextRun(config, Now, 1).Should().Be(Now.AddMinutes(150));
+    }
+
+    [Fact]
+    public void Due_HeldBackByPause()
+    {
+        AutoRunSchedule.Due(Now, Now.AddHours(4)).Should().Be(Now.AddHours(4));
+        AutoRunSchedule.Due(Now, Now.AddHours(-1)).Should().Be(Now);
+        AutoRunSchedule.Due(Now, null).Should().Be(Now);
+    }
+
+    [Theory]
+    [InlineData(0, 10, false, 15, 10)]
+    [InlineData(5, 10, true, 15, 10)]
+    [InlineData(60, 90, true, 60, 60)]
+    [InlineData(5000, -3, true, 1440, 0)]
+    public void Config_ClampsIntervalAndSplay(int interval, int splay, bool enabled, int expectedInterval, int expectedSplay)
+    {
+        var config = new AutoRunConfig { AutoRunIntervalMinutes = interval, AutoRunSplayMinutes = splay };
+
+        config.Enabled.Should().Be(enabled);
+        config.Interval.Should().Be(TimeSpan.FromMinutes(expectedInterval));
+        config.Splay.Should().Be(TimeSpan.FromMinutes(expectedSplay));
+    }
+}
@@ -1,3 +1,4 @@
+using System.Security.Principal;
 using System.ServiceProcess;
 using Microsoft.Extensions.Logging;
 using Moq;
@@ -266,4 +267,20 @@ public class CimianStatusLauncherTests
     {
         Assert.EndsWith(".exe", CimianExePath);
     }
+
+    [Theory]
+    [InlineData("S-1-5-18", true)]                                        // SYSTEM
+    [InlineData("S-1-5-32-544", true)]                                    // BUILTIN\Administrators
+    [InlineData("S-1-5-32-545", false)]                                   // BUILTIN\Users
+    [InlineData("S-1-5-21-1004336348-1177238915-682003330-1001", false)]  // a user account
+    public void IsTrustedFlagFileOwner_OnlySystemAndAdministrators(string sid, bool trusted)
+    {
+        Assert.Equal(trusted, FileWatcherService.IsTrustedFlagFileOwner(new SecurityIdentifier(sid)));
+    }
+
+    [Fact]
+    public void IsTrustedFlagFileOwner_UnknownOwnerIsNotTrusted()
+    {
+        Assert.False(FileWatcherService.IsTrustedFlagFileOwner(null));
+    }
 }
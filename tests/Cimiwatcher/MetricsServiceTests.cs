@@ -0,0 +1,103 @@
+using Xunit;
+using FluentAssertions;
+using Cimian.CLI.Cimiwatcher.Services;
+using Cimian.Core.Models;
+using Cimian.Core.Services;
+
+namespace Cimian.Tests.Cimiwatcher;
+
+/// <summary>
+/// Coverage for the metrics endpoint's exposition text: run counters, the
+/// download histogram and the Prometheus vs OpenMetrics differences.
+/// </summary>
+public class MetricsServiceTests
+{
+    private static LastRunStatus Run(string sessionId, string outcome = "partial", int failures = 1) => new()
+    {
+        SessionId = sessionId,
+        Outcome = outcome,
+        StartTime = new DateTime(2026, 10, 16, 9, 0, 0, DateTimeKind.Utc),
+        EndTime = new DateTime(2026, 10, 16, 9, 5, 0, DateTimeKind.Utc),
+        Failures = failures
+    };
+
+    private static UsageReport Usage(string sessionId) => new()
+    {
+        SessionId = sessionId,
+        Downloads =
+        [
+            new UsageDownload { Item = "Chrome", Success = true, DurationMs = 4000, Bytes = 100 },
+            new UsageDownload { Item = "Zoom", Success = true, DurationMs = 90_000, Bytes = 50 },
+            new UsageDownload { Item = "Slack", Success = true, CacheHit = true, DurationMs = 10 }
+        ]
+    };
+
+    [Fact]
+    public void RecordRun_CountsEachSessionOnce()
+    {
+        var metrics = new CimianMetrics();
+
+        metrics.RecordRun(Run("2026-10-16-0900"), null).Should().BeTrue();
+        metrics.RecordRun(Run("2026-10-16-0900"), null).Should().BeFalse();
+        metrics.RecordRun(Run("2026-10-16-1000", "success", 0), null).Should().BeTrue();
+
+        var text = metrics.Render();
+        text.Should().Contain("cimian_runs_total{outcome=\"partial\"} 1\n");
+        text.Should().Contain("cimian_runs_total{outcome=\"success\"} 1\n");
+        text.Should().Contain("cimian_failed_installs_total 1\n");
+        text.Should().Contain("cimian_last_run_success 1\n");
+        text.Should().Contain("cimian_last_run_timestamp_seconds 1792141500\n");
+        text.Should().Contain("cimian_last_run_duration_seconds 300\n");
+    }
+
+    [Fact]
+    public void RecordRun_FillsDownloadHistogramWithoutCacheHits()
+    {
+        var metrics = new CimianMetrics();
+
+        metrics.RecordRun(Run("s1"), Usage("s1"));
+
+        var text = metrics.Render();
+        text.Should().Contain("cimian_download_duration_seconds_bucket{le=\"1\"} 0\n");
+        text.Should().Contain("cimian_download_duration_seconds_bucket{le=\"5\"} 1\n");
+        text.Should().Contain("cimian_download_duration_seconds_bucket{le=\"120\"} 2\n");
+        text.Should().Contain("cimian_download_duration_seconds_bucket{le=\"+Inf\"} 2\n");
+        text.Should().Contain("cimian_download_duration_seconds_sum 94\n");
+        text.Should().Contain("cimian_download_duration_seconds_count 2\n");
+        text.Should().Contain("cimian_download_bytes_total 150\n");
+    }
+
+    [Fact]
+    public void RecordRun_IgnoresUsageFromAnotherSession()
+    {
+        var metrics = new CimianMetrics();
+
+        metrics.RecordRun(Run("s2"), Usage("s1"));
+
+        metrics.Render().Should().Contain("cimian_download_duration_seconds_count 0\n");
+    }
+
+    [Fact]
+    public void SetState_ReportsItemsPendingAndCache()
+    {
+        var metrics = new CimianMetrics();
+
+        metrics.SetState(new Dictionary<string, int> { ["Installed"] = 12, ["Pending"] = 3 }, 2048, selfUpdatePending: true);
+
+        var text = metrics.Render();
+        text.Should().Contain("cimian_items{status=\"Installed\"} 12\n");
+        text.Should().Contain("cimian_pending_updates 3\n");
+        text.Should().Contain("cimian_cache_bytes 2048\n");
+        text.Should().Contain("cimian_self_update_pending 1\n");
+        text.Should().NotContain("cimian_last_run_timestamp_seconds");
+    }
+
+    [Fact]
+    public void Render_OpenMetricsNamesCounterFamiliesAndEndsWithEof()
+    {
+        var metrics = new CimianMetrics();
+
+        metrics.Render().Should().Contain("# TYPE cimian_failed_installs_total counter\n").And.NotContain("# EOF");
+        metrics.Render(openMetrics: true).Should().Contain("# TYPE cimian_failed_installs counter\n").And.EndWith("# EOF\n");
+    }
+}
@@ -0,0 +1,93 @@
+using System.Net;
+using System.Net.Http.Headers;
+using Xunit;
+using FluentAssertions;
+using Cimian.CLI.Cimiwatcher.Services;
+
+namespace Cimian.Tests.Cimiwatcher;
+
+/// <summary>
+/// Coverage for the repo change monitor's pure decisions: which URLs get HEADed,
+/// how a response is fingerprinted, and what counts as a change.
+/// </summary>
+public class RepoChangeMonitorServiceTests
+{
+    [Fact]
+    public void BuildWatchUrls_IncludesManifestAndEveryCatalog()
+    {
+        var config = new RepoWatchConfig
+        {
+            SoftwareRepoURL = "https://repo.example.com/deployment/",
+            ClientIdentifier = "labs/lab-01",
+            Catalogs = ["Testing", "Production"]
+        };
+
+        RepoChangeMonitorService.BuildWatchUrls(config).Should().Equal(
+            "https://repo.example.com/deployment/manifests/labs/lab-01.yaml",
+            "https://repo.example.com/deployment/catalogs/Testing.yaml",
+            "https://repo.example.com/deployment/catalogs/Production.yaml");
+    }
+
+    [Fact]
+    public void BuildWatchUrls_NoClientIdentifier_UsesSiteDefault()
+    {
+        var config = new RepoWatchConfig { SoftwareRepoURL = "https://repo" };
+
+        RepoChangeMonitorService.BuildWatchUrls(config).Should().ContainSingle()
+            .Which.Should().Be("https://repo/manifests/site_default.yaml");
+    }
+
+    [Theory]
+    [InlineData("labs/%serial%", "labs/ABC123", "labs/ABC123")]
+    [InlineData("", "ABC123", "ABC123")]
+    [InlineData("%ou%/%hostname%", null, "site_default")]
+    [InlineData("labs/lab-01", "ABC123", "labs/lab-01")]
+    public void BuildWatchUrls_TemplatedClientIdentifier_WatchesLastResolvedManifest(string clientIdentifier, string? lastManifest, string expected)
+    {
+        var config = new RepoWatchConfig { SoftwareRepoURL = "https://repo", ClientIdentifier = clientIdentifier };
+
+        RepoChangeMonitorService.BuildWatchUrls(config, lastManifest).Should().ContainSingle()
+            .Which.Should().Be($"https://repo/manifests/{expected}.yaml");
+    }
+
+    [Theory]
+    [InlineData(null, true)]
+    [InlineData("HTTP", true)]
+    [InlineData("s3", false)]
+    [InlineData("azblob", false)]
+    [InlineData("ftp", false)]
+    public void IsWatchableBackend_OnlyPlainHttp(string? backend, bool watchable)
+    {
+        var config = new RepoWatchConfig { SoftwareRepoURL = "https://repo", RepoBackend = backend };
+
+        RepoChangeMonitorService.IsWatchableBackend(config).Should().Be(watchable);
+    }
+
+    [Fact]
+    public void Fingerprint_PrefersETag()
+    {
+        using var response = new HttpResponseMessage(HttpStatusCode.OK) { Content = new ByteArrayContent([]) };
+        response.Headers.ETag = new EntityTagHeaderValue("\"abc\"");
+        response.Content.Headers.LastModified = DateTimeOffset.UnixEpoch;
+
+        RepoChangeMonitorService.Fingerprint(response).Should().Be("\"abc\"");
+    }
+
+    [Fact]
+    public void Fingerprint_FallsBackToLastModifiedAndLength()
+    {
+        using var response = new HttpResponseMessage(HttpStatusCode.OK) { Content = new ByteArrayContent(new byte[12]) };
+        response.Content.Headers.LastModified = DateTimeOffset.FromUnixTimeSeconds(1000);
+
+        RepoChangeMonitorService.Fingerprint(response).Should().Be("1000:12");
+    }
+
+    [Fact]
+    public void ChangedResources_ReportsModifiedAndNewButNotMissing()
+    {
+        var previous = new Dictionary<string, string> { ["a"] = "1", ["b"] = "1", ["gone"] = "1" };
+        var current = new Dictionary<string, string> { ["a"] = "1", ["b"] = "2", ["new"] = "1" };
+
+        RepoChangeMonitorService.ChangedResources(previous, current).Should().BeEquivalentTo("b", "new");
+    }
+}
@@ -0,0 +1,87 @@
+using Xunit;
+using FluentAssertions;
+using Cimian.CLI.Cimiwatcher.Services;
+using Cimian.Core.Services;
+
+namespace Cimian.Tests.Cimiwatcher;
+
+/// <summary>
+/// Coverage for the run broker's audit log lines.
+/// </summary>
+public class RunAuditLogTests : StoreTestBase
+{
+    [Fact]
+    public void Describe_IncludesItemsAndPauseEnd()
+    {
+        RunAuditLog.Describe(new RunBrokerRequest { Mode = " Check " }).Should().Be("check");
+        RunAuditLog.Describe(new RunBrokerRequest { Mode = "headless", Items = ["Zoom", "Firefox"] })
+            .Should().Be("headless items=Zoom,Firefox");
+        RunAuditLog.Describe(new RunBrokerRequest { Mode = "pause", Until = new DateTime(2026, 10, 20, 8, 0, 0) })
+            .Should().Be("pause until=2026-10-20 08:00");
+        RunAuditLog.Describe(new RunBrokerRequest { Mode = "pause", Until = new DateTime(2026, 10, 20, 8, 0, 0), Reason = " Exams " })
+            .Should().Be("pause until=2026-10-20 08:00 reason=\"Exams\"");
+    }
+
+    [Fact]
+    public void Describe_InvalidModeOrItemsAreNotLoggedAsSent()
+    {
+        RunAuditLog.Describe(new RunBrokerRequest { Mode = "check accepted" }).Should().Be("<invalid>");
+        RunAuditLog.Describe(new RunBrokerRequest { Mode = "gui", Items = ["Zoom", "Firefox\r\nforged"] })
+            .Should().Be("gui items=<invalid>");
+        RunAuditLog.Describe(new RunBrokerRequest { Mode = "pause", Reason = "say \"hi\" \\ bye" })
+            .Should().Be("pause reason=\"say \\\"hi\\\" \\\\ bye\"");
+    }
+
+    [Fact]
+    public void Append_RefusedRequestCannotForgeALine()
+    {
+        var path = Path.Combine(TestDir, "run_broker.log");
+        var forged = "\n2026-10-16 09:00:00 CimianControl user=NT AUTHORITY\\SYSTEM request=gui accepted pid=1";
+        var request = new RunBrokerRequest { Mode = "pause", Until = new DateTime(2026, 10, 20, 8, 0, 0), Reason = "x" + forged };
+
+        RunAuditLog.Append(RunBrokerProtocol.PipeName, @"CONTOSO\jdoe", RunAuditLog.Describe(request),
+            RunAuditLog.Outcome(new RunBrokerResponse { Message = "Unknown mode '" + forged + "'" }), path);
+
+        var lines = File.ReadAllLines(path);
+        lines.Should().ContainSingle();
+        lines[0].Should().Contain(@"CimianRunBroker user=CONTOSO\jdoe request=pause until=2026-10-20 08:00 reason=""x\n2026-10-16");
+        lines[0].Should().Contain(@"refused: Unknown mode '\n2026-10-16");
+    }
+
+    [Fact]
+    public void FormatLine_EscapesControlCharactersInEveryField()
+    {
+        RunAuditLog.FormatLine(Now, ".cimian.bootstrap", "a\rb", "--item \"x\"\u2028y", "refused:\tz")
+            .Should().Be(@"2026-10-16 09:00:00 .cimian.bootstrap user=a\rb request=--item ""x""\u2028y refused:\tz");
+    }
+
+    [Fact]
+    public void Outcome_AcceptedWithPidOrRefusedWithReason()
+    {
+        RunAuditLog.Outcome(new RunBrokerResponse { Accepted = true, ProcessId = 4242 }).Should().Be("accepted pid=4242");
+        RunAuditLog.Outcome(new RunBrokerResponse { Accepted = true }).Should().Be("accepted");
+        RunAuditLog.Outcome(new RunBrokerResponse { Message = "An update is already running" })
+            .Should().Be("refused: An update is already running");
+    }
+
+    [Fact]
+    public void Append_WritesOneLinePerEntry()
+    {
+        var path = Path.Combine(TestDir, "run_broker.log");
+
+        RunAuditLog.Append(RunBrokerProtocol.PipeName, @"CONTOSO\jdoe", "check", "accepted pid=1", path);
+        RunAuditLog.Append(RunBrokerProtocol.ControlPipeName, @"CONTOSO\admin", "pause", "accepted", path);
+
+        var lines = File.ReadAllLines(path);
+        lines.Should().HaveCount(2);
+        lines[0].Should().EndWith(@"CimianRunBroker user=CONTOSO\jdoe request=check accepted pid=1");
+        lines[1].Should().EndWith(@"CimianControl user=CONTOSO\admin request=pause accepted");
+    }
+
+    [Fact]
+    public void FormatLine_StartsWithTimestamp()
+    {
+        RunAuditLog.FormatLine(Now, ".cimian.headless", @"NT AUTHORITY\SYSTEM", "default", "consumed")
+            .Should().Be(@"2026-10-16 09:00:00 .cimian.headless user=NT AUTHORITY\SYSTEM request=default consumed");
+    }
+}
@@ -0,0 +1,87 @@
+using System.Text;
+using Xunit;
+using FluentAssertions;
+using Cimian.CLI.Cimiwatcher.Services;
+
+namespace Cimian.Tests.Cimiwatcher;
+
+/// <summary>
+/// Coverage for how the run broker reads a request line off its pipes.
+/// </summary>
+public class RunBrokerServiceTests
+{
+    private static readonly TimeSpan ReadTimeout = TimeSpan.FromSeconds(5);
+
+    private static MemoryStream Sent(string text) => new(Encoding.UTF8.GetBytes(text));
+
+    [Fact]
+    public async Task ReadRequestLine_ReturnsFirstLine()
+    {
+        var (line, tooLarge) = await RunBrokerService.ReadRequestLineAsync(
+            Sent("{\"mode\":\"check\"}\r\n{\"mode\":\"gui\"}\n"), 64, ReadTimeout, CancellationToken.None);
+
+        tooLarge.Should().BeFalse();
+        line.Should().Be("{\"mode\":\"check\"}");
+    }
+
+    [Fact]
+    public async Task ReadRequestLine_LineAtTheLimitIsAccepted()
+    {
+        var (line, tooLarge) = await RunBrokerService.ReadRequestLineAsync(
+            Sent(new string('a', 16) + "\n"), 16, ReadTimeout, CancellationToken.None);
+
+        tooLarge.Should().BeFalse();
+        line.Should().HaveLength(16);
+    }
+
+    [Fact]
+    public async Task ReadRequestLine_StopsReadingPastTheLimitWithoutNewline()
+    {
+        var stream = Sent(new string('a', 1024 * 1024));
+
+        var (line, tooLarge) = await RunBrokerService.ReadRequestLineAsync(stream, 16, ReadTimeout, CancellationToken.None);
+
+        tooLarge.Should().BeTrue();
+        line.Should().BeNull();
+        stream.Position.Should().BeLessThan(1024);
+    }
+
+    [Fact]
+    public async Task ReadRequestLine_ClosedWithoutSendingIsNull()
+    {
+        var (line, tooLarge) = await RunBrokerService.ReadRequestLineAsync(Sent(""), 16, ReadTimeout, CancellationToken.None);
+
+        tooLarge.Should().BeFalse();
+        line.Should().BeNull();
+    }
+
+    [Fact]
+    public async Task ReadRequestLine_GivesUpOnSilentCaller()
+    {
+        var read = () => RunBrokerService.ReadRequestLineAsync(new SilentStream(), 16, TimeSpan.FromMilliseconds(50), CancellationToken.None);
+
+        await read.Should().ThrowAsync<OperationCanceledException>();
+    }
+
+    /// <summary>A caller that connects and never writes.</summary>
+    private sealed class SilentStream : Stream
+    {
+        public override bool CanRead => true;
+        public override bool CanSeek => false;
+        public override bool CanWrite => false;
+        public override long Length => throw new NotSupportedException();
+        public override long Position { get => throw new NotSupportedException(); set => throw new NotSupportedException(); }
+
+        public override async ValueTask<int> ReadAsync(Memory<byte> buffer, CancellationToken cancellationToken = default)
+        {
+            await Task.Delay(Timeout.Infinite, cancellationToken);
+            return 0;
+        }
+
+        public override int Read(byte[] buffer, int offset, int count) => throw new NotSupportedException();
+        public override void Flush() { }
+        public override long Seek(long offset, SeekOrigin origin) => throw new NotSupportedException();
+        public override void SetLength(long value) => throw new NotSupportedException();
+        public override void Write(byte[] buffer, int offset, int count) => throw new NotSupportedException();
+    }
+}
@@ -0,0 +1,89 @@
+using Xunit;
+using FluentAssertions;
+using Cimian.CLI.Cimiwatcher.Services;
+
+namespace Cimian.Tests.Cimiwatcher;
+
+/// <summary>
+/// Coverage for the run watchdog: when a run counts as hung, which runs are
+/// restarted, and the watchdog report.
+/// </summary>
+public class RunWatchdogTests : StoreTestBase
+{
+    [Fact]
+    public void EventLogSilence_GrowingLogIsNeverSilent()
+    {
+        var silence = new EventLogSilence();
+
+        silence.Observe(@"C:\logs\a", 100, Now).Should().Be(TimeSpan.Zero);
+        silence.Observe(@"C:\logs\a", 100, Now.AddMinutes(30)).Should().Be(TimeSpan.FromMinutes(30));
+        silence.Observe(@"C:\logs\a", 250, Now.AddMinutes(45)).Should().Be(TimeSpan.Zero);
+        silence.Observe(@"C:\logs\a", 250, Now.AddMinutes(50)).Should().Be(TimeSpan.FromMinutes(5));
+    }
+
+    [Fact]
+    public void EventLogSilence_NewSessionOrResetStartsOver()
+    {
+        var silence = new EventLogSilence();
+        silence.Observe(@"C:\logs\a", 100, Now);
+
+        silence.Observe(@"C:\logs\b", 100, Now.AddMinutes(30)).Should().Be(TimeSpan.Zero);
+        silence.Reset();
+        silence.Observe(@"C:\logs\b", 100, Now.AddMinutes(40)).Should().Be(TimeSpan.Zero);
+    }
+
+    [Theory]
+    [InlineData("auto", "--auto --show-status")]
+    [InlineData("bootstrap", "--auto --show-status")]
+    [InlineData("checkonly", "--checkonly")]
+    [InlineData("installonly", "--installonly")]
+    [InlineData("manual", null)]
+    [InlineData("ondemand", null)]
+    [InlineData(null, null)]
+    public void RestartArguments_OnlyUnattendedRunsRestart(string? runType, string? expected)
+    {
+        RunWatchdogService.RestartArguments(runType).Should().Be(expected);
+    }
+
+    [Theory]
+    [InlineData(0, false, 10)]
+    [InlineData(5, true, 10)]
+    [InlineData(90, true, 90)]
+    [InlineData(5000, true, 1440)]
+    public void Config_ClampsLimit(int minutes, bool enabled, int expectedLimit)
+    {
+        var config = new WatchdogConfig { HungRunMinutes = minutes };
+
+        config.Enabled.Should().Be(enabled);
+        config.Limit.Should().Be(TimeSpan.FromMinutes(expectedLimit));
+    }
+
+    [Fact]
+    public void WatchdogReport_UpdateCountsAcrossWrites()
+    {
+        var path = Path.Combine(TestDir, "watchdog.json");
+
+        WatchdogReport.Update(r => r.HungRuns++, path);
+        WatchdogReport.Update(r =>
+        {
+            r.HungRuns++;
+            r.LastHungRun = new HungRunRecord { SessionId = "2026-10-16-0900", RunType = "auto", Restarted = true };
+        }, path);
+
+        var report = WatchdogReport.Read(path);
+        report.HungRuns.Should().Be(2);
+        report.LastHungRun!.RunType.Should().Be("auto");
+        report.ServiceCrashes.Should().Be(0);
+        File.ReadAllText(path).Should().Contain("\"hung_runs\": 2");
+    }
+
+    [Fact]
+    public void WatchdogReport_CorruptFileStartsOver()
+    {
+        Directory.CreateDirectory(TestDir);
+        var path = Path.Combine(TestDir, "watchdog.json");
+        File.WriteAllText(path, "{ not json");
+
+        WatchdogReport.Read(path).HungRuns.Should().Be(0);
+    }
+}
@@ -32,9 +32,9 @@ public class LoopGuardTests : IDisposable
         catch { /* cleanup best-effort */ }
     }
 
-    private LoopGuard CreateGuard(bool isBootstrap = false, bool disabled = false)
+    private LoopGuard CreateGuard(bool isBootstrap = false, bool disabled = false, int quarantineThreshold = 0)
     {
-        return new LoopGuard(_statePath, _logsDir, isBootstrap, _cacheDir, disabled);
+        return new LoopGuard(_statePath, _logsDir, isBootstrap, _cacheDir, disabled, quarantineThreshold: quarantineThreshold);
     }
 
     #region Basic Behavior
@@ -586,6 +586,78 @@ public class LoopGuardTests : IDisposable
 
     #endregion
 
+    #region Failure Quarantine
+
+    [Fact]
+    public void ConsecutiveFailures_ReachingThreshold_QuarantineVersion()
+    {
+        var guard = CreateGuard(quarantineThreshold: 3);
+        for (int i = 0; i < 3; i++)
+            guard.RecordAttempt("BrokenPkg", "1.0.0", success: false, "fp1");
+
+        var (suppress, reason) = guard.ShouldSuppress("BrokenPkg", "1.0.0", "fp1");
+        suppress.Should().BeTrue();
+        reason.Should().Contain("quarantined_after_3_failures");
+
+        var report = guard.GetSuppressedReport().Single();
+        report.Quarantined.Should().BeTrue();
+        report.Reason.Should().Be("quarantined_after_3_failures");
+        report.SuppressedUntil.Should().BeNull();
+    }
+
+    [Fact]
+    public void Success_ResetsConsecutiveFailures()
+    {
+        var guard = CreateGuard(quarantineThreshold: 3);
+        guard.RecordAttempt("FlakyPkg", "1.0.0", success: false);
+        guard.RecordAttempt("FlakyPkg", "1.0.0", success: false);
+        guard.RecordAttempt("FlakyPkg", "1.0.0", success: true);
+        guard.RecordAttempt("FlakyPkg", "1.0.0", success: false);
+
+        var state = guard.GetPackageState("FlakyPkg")!;
+        state.ConsecutiveFailures.Should().Be(1);
+        state.QuarantinedVersion.Should().BeNull();
+    }
+
+    [Fact]
+    public void Quarantine_ReleasedWhenNewVersionAppears()
+    {
+        var guard = CreateGuard(quarantineThreshold: 2);
+        guard.RecordAttempt("BrokenPkg", "1.0.0", success: false, "fp1");
+        guard.RecordAttempt("BrokenPkg", "1.0.0", success: false, "fp1");
+
+        var (suppress, reason) = guard.ShouldSuppress("BrokenPkg", "1.0.1", "fp2");
+        suppress.Should().BeFalse();
+        reason.Should().Contain("Released from quarantine");
+        guard.GetPackageState("BrokenPkg")!.QuarantinedVersion.Should().BeNull();
+    }
+
+    [Fact]
+    public void Quarantine_PersistsUntilClearLoop()
+    {
+        var guard = CreateGuard(quarantineThreshold: 2);
+        guard.RecordAttempt("BrokenPkg", "1.0.0", success: false);
+        guard.RecordAttempt("BrokenPkg", "1.0.0", success: false);
+
+        var reloaded = CreateGuard(quarantineThreshold: 2);
+        reloaded.ShouldSuppress("BrokenPkg", "1.0.0").Suppress.Should().BeTrue();
+
+        reloaded.ClearLoop("BrokenPkg").Should().BeTrue();
+        reloaded.ShouldSuppress("BrokenPkg", "1.0.0").Suppress.Should().BeFalse();
+    }
+
+    [Fact]
+    public void ThresholdZero_NeverQuarantines()
+    {
+        var guard = CreateGuard();
+        for (int i = 0; i < 6; i++)
+            guard.RecordAttempt("BrokenPkg", "1.0.0", success: false);
+
+        guard.GetPackageState("BrokenPkg")!.QuarantinedVersion.Should().BeNull();
+    }
+
+    #endregion
+
     #region Cache Analysis
 
     [Fact]
@@ -184,6 +184,74 @@ catalogs: []
         Assert.Contains("missing uninstaller", warnings[0]);
     }
 
+    [Fact]
+    public void VerifyPayloads_WarnsForUnknownUpgradeStrategy()
+    {
+        var items = new List<PkgsInfo>
+        {
+            new PkgsInfo { Name = "App1", FilePath = "a.yaml", UpgradeStrategy = "uninstall-first" },
+            new PkgsInfo { Name = "App2", FilePath = "b.yaml", UpgradeStrategy = "replace" }
+        };
+
+        var warnings = _builder.VerifyPayloads(_tempDir, items);
+
+        Assert.Single(warnings);
+        Assert.Contains("upgrade_strategy 'replace'", warnings[0]);
+    }
+
+    [Fact]
+    public void VerifyPayloads_WarnsForCodeInBothExitCodeLists()
+    {
+        var items = new List<PkgsInfo>
+        {
+            new PkgsInfo
+            {
+                Name = "App1",
+                FilePath = "a.yaml",
+                Installer = new Installer { SuccessExitCodes = [1, 3010], RebootExitCodes = [3010] }
+            }
+        };
+
+        var warnings = _builder.VerifyPayloads(_tempDir, items);
+
+        Assert.Single(warnings);
+        Assert.Contains("exit code(s) 3010", warnings[0]);
+    }
+
+    [Fact]
+    public void VerifyPayloads_WarnsForConfigurationItemWithoutTestAndSet()
+    {
+        var items = new List<PkgsInfo>
+        {
+            new PkgsInfo
+            {
+                Name = "Config1",
+                FilePath = "a.yaml",
+                Installer = new Installer { Type = "configuration" },
+                Configuration = new ConfigurationPayload { TestScript = "exit 0", SetScript = "Set-Thing" }
+            },
+            new PkgsInfo
+            {
+                Name = "Config2",
+                FilePath = "b.yaml",
+                Installer = new Installer { Type = "configuration" },
+                Configuration = new ConfigurationPayload { DscResource = new DscResourceInfo { Name = "Registry", Module = "PSDscResources" } }
+            },
+            new PkgsInfo
+            {
+                Name = "Config3",
+                FilePath = "c.yaml",
+                Installer = new Installer { Type = "configuration" },
+                Configuration = new ConfigurationPayload { TestScript = "exit 0" }
+            }
+        };
+
+        var warnings = _builder.VerifyPayloads(_tempDir, items);
+
+        Assert.Single(warnings);
+        Assert.Contains("c.yaml is a configuration item", warnings[0]);
+    }
+
     [Fact]
     public void BuildCatalogs_AlwaysIncludesAllCatalog()
     {
@@ -281,6 +349,94 @@ catalogs: []
         Assert.Single(_warnings); // Should warn about removal
     }
 
+    [Fact]
+    public void WriteCatalogs_Compress_WritesPrecompressedCopiesAndRemovesThemWhenOff()
+    {
+        var catalogs = new Dictionary<string, List<PkgsInfo>>(StringComparer.OrdinalIgnoreCase)
+        {
+            ["production"] = new List<PkgsInfo> { new PkgsInfo { Name = "App1", Version = "1.0.0" } }
+        };
+        var catalogPath = Path.Combine(_tempDir, "catalogs", "production.yaml");
+
+        _builder.WriteCatalogs(_tempDir, catalogs, silent: true, compress: true);
+
+        using (var gzip = new System.IO.Compression.GZipStream(File.OpenRead(catalogPath + ".gz"), System.IO.Compression.CompressionMode.Decompress))
+        using (var reader = new StreamReader(gzip))
+        {
+            Assert.Equal(File.ReadAllText(catalogPath), reader.ReadToEnd());
+        }
+        Assert.True(File.Exists(catalogPath + ".zst"));
+
+        _builder.WriteCatalogs(_tempDir, catalogs, silent: true);
+
+        Assert.False(File.Exists(catalogPath + ".gz"));
+        Assert.False(File.Exists(catalogPath + ".zst"));
+    }
+
+    [Fact]
+    public void WriteCatalogs_SigningKey_WritesVerifiableSignatureAndRemovesItWhenOff()
+    {
+        var catalogs = new Dictionary<string, List<PkgsInfo>>(StringComparer.OrdinalIgnoreCase)
+        {
+            ["production"] = new List<PkgsInfo> { new PkgsInfo { Name = "App1", Version = "1.0.0" } }
+        };
+        var catalogPath = Path.Combine(_tempDir, "catalogs", "production.yaml");
+        using var key = System.Security.Cryptography.ECDsa.Create();
+
+        _builder.WriteCatalogs(_tempDir, catalogs, silent: true, signingKey: key);
+
+        var status = Cimian.Core.Services.MetadataSignature.Verify(
+            File.ReadAllText(catalogPath), File.ReadAllText(catalogPath + ".sig"), [key]);
+        Assert.Equal(Cimian.Core.Services.MetadataSignatureStatus.Valid, status);
+
+        _builder.WriteCatalogs(_tempDir, catalogs, silent: true);
+
+        Assert.False(File.Exists(catalogPath + ".sig"));
+    }
+
+    [Fact]
+    public void WriteCatalogs_StampsGenerationAboveExistingCatalogs()
+    {
+        // Simulate a catalog published by a build host whose clock ran ahead
+        var future = DateTimeOffset.UtcNow.AddDays(1).ToUnixTimeMilliseconds();
+        File.WriteAllText(Path.Combine(_tempDir, "catalogs", "production.yaml"),
+            $"generation: {future}\nitems: []\n");
+
+        var catalogs = new Dictionary<string, List<PkgsInfo>>(StringComparer.OrdinalIgnoreCase)
+        {
+            ["production"] = new List<PkgsInfo> { new PkgsInfo { Name = "App1", Version = "1.0.0" } }
+        };
+
+        _builder.WriteCatalogs(_tempDir, catalogs, silent: true);
+
+        var content = File.ReadAllText(Path.Combine(_tempDir, "catalogs", "production.yaml"));
+        Assert.StartsWith($"generation: {future + 1}", content);
+    }
+
+    [Fact]
+    public void ScanRepoThenWriteCatalogs_KeepsInstallerTimeout()
+    {
+        CreatePkgInfo("vs.yaml", @"
+name: VisualStudio
+version: 17.11.0
+catalogs:
+  - production
+installer_timeout: 120
+");
+
+        var catalogs = _builder.BuildCatalogs(_builder.ScanRepo(_tempDir), silent: true);
+        _builder.WriteCatalogs(_tempDir, catalogs, silent: true);
+
+        var content = File.ReadAllText(Path.Combine(_tempDir, "catalogs", "production.yaml"));
+        Assert.Contains("installer_timeout: 120", content);
+    }
+
+    [Fact]
+    public void NextGeneration_NoExistingCatalogs_UsesClock()
+    {
+        Assert.Equal(1_700_000_000_000, CatalogBuilder.NextGeneration(Array.Empty<string>(), 1_700_000_000_000));
+    }
+
     [Fact]
     public void WriteCatalogs_LogsSuccessWhenNotSilent()
     {
@@ -344,6 +500,30 @@ installer:
 
         Assert.DoesNotContain(_warnings, w => w.Contains("missing installer"));
     }
+
+    [Fact]
+    public void WriteIconHashes_IndexesImagesOnly()
+    {
+        var iconsDir = Path.Combine(_tempDir, "icons");
+        Directory.CreateDirectory(iconsDir);
+        File.WriteAllBytes(Path.Combine(iconsDir, "Chrome.png"), [1, 2, 3]);
+        File.WriteAllText(Path.Combine(iconsDir, "notes.txt"), "not an icon");
+
+        _builder.WriteIconHashes(_tempDir, silent: true);
+
+        var yaml = File.ReadAllText(Path.Combine(iconsDir, Cimian.Core.Services.IconCache.HashesFileName));
+        var hashes = Cimian.Core.Services.YamlUtils.Deserializer.Deserialize<Dictionary<string, string>>(yaml);
+        Assert.Equal(new[] { "Chrome.png" }, hashes.Keys);
+        Assert.Equal(Cimian.Core.Services.IconCache.ComputeHash(Path.Combine(iconsDir, "Chrome.png")), hashes["Chrome.png"]);
+    }
+
+    [Fact]
+    public void WriteIconHashes_NoIconsFolder_WritesNothing()
+    {
+        _builder.WriteIconHashes(_tempDir, silent: true);
+
+        Assert.False(Directory.Exists(Path.Combine(_tempDir, "icons")));
+    }
 }
 
 /// <summary>
@@ -0,0 +1,58 @@
+using System.Security.AccessControl;
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+public class AgentVerifierTests
+{
+    private static readonly AgentBaselineEntry Known = new() { Version = "25.10.1.0", Sha256 = "abc123" };
+
+    [Fact]
+    public void CompareToBaseline_RecordsFirstSightingAndUpgrades()
+    {
+        var first = AgentVerifier.CompareToBaseline("25.10.1.0", "abc123", null);
+        var upgraded = AgentVerifier.CompareToBaseline("25.11.0.0", "def456", Known);
+
+        Assert.Equal((AgentCheckStatus.Ok, true), (first.Status, first.Record));
+        Assert.Equal((AgentCheckStatus.Ok, true), (upgraded.Status, upgraded.Record));
+    }
+
+    [Fact]
+    public void CompareToBaseline_SameVersionDifferentHash_IsTampering()
+    {
+        var unchanged = AgentVerifier.CompareToBaseline("25.10.1.0", "ABC123", Known);
+        var tampered = AgentVerifier.CompareToBaseline("25.10.1.0", "def456", Known);
+
+        Assert.Equal((AgentCheckStatus.Ok, false), (unchanged.Status, unchanged.Record));
+        Assert.Equal((AgentCheckStatus.Failed, false), (tampered.Status, tampered.Record));
+    }
+
+    [Fact]
+    public void ParseScValue_ReadsQueryAndConfigOutput()
+    {
+        const string output = """
+            SERVICE_NAME: CimianWatcher
+                    TYPE               : 10  WIN32_OWN_PROCESS
+                    START_TYPE         : 2   AUTO_START
+                    BINARY_PATH_NAME   : "C:\Program Files\Cimian\cimiwatcher.exe"
+                    STATE              : 1  STOPPED
+            """;
+
+        Assert.Equal("STOPPED", AgentVerifier.ParseScValue(output, "STATE"));
+        Assert.Equal("AUTO_START", AgentVerifier.ParseScValue(output, "START_TYPE"));
+        Assert.Equal("\"C:\\Program Files\\Cimian\\cimiwatcher.exe\"", AgentVerifier.ParseScValue(output, "BINARY_PATH_NAME", lastTokenOnly: false));
+        Assert.Null(AgentVerifier.ParseScValue(output, "WIN32_EXIT_CODE"));
+    }
+
+    [Theory]
+    [InlineData(FileSystemRights.ReadAndExecute, false)]
+    [InlineData(FileSystemRights.Read | FileSystemRights.Synchronize, false)]
+    [InlineData(FileSystemRights.CreateFiles, true)]
+    [InlineData(FileSystemRights.Modify, true)]
+    [InlineData(FileSystemRights.ChangePermissions, true)]
+    public void GrantsWrite_FlagsAnyWriteRight(FileSystemRights rights, bool expected)
+    {
+        Assert.Equal(expected, AgentVerifier.GrantsWrite(rights));
+    }
+}
@@ -0,0 +1,63 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for ArpRegistry - picking an item's entry from the Uninstall hives.
+/// </summary>
+public class ArpRegistryTests
+{
+    private static readonly ArpEntry Zoom = new("ZoomUMX", "Zoom Workplace (64-bit)", "6.1.0", @"""C:\Program Files\Zoom\uninstall\Installer.exe"" /uninstall", null);
+    private static readonly ArpEntry SevenZip = new("{23170F69-40C1-2702-2409-000001000000}", "7-Zip 24.09 (x64 edition)", "24.09.00.0", "MsiExec.exe /I{23170F69-40C1-2702-2409-000001000000}", null);
+    private static readonly ArpEntry Notepad = new("{B3C7F2E1-5A40-4F8B-9E31-2D6C0B7A9F12}_is1", "Notepad Next", "0.8", @"""C:\Program Files\NotepadNext\unins000.exe""", null);
+    private static readonly ArpEntry Spotify = new("Spotify", "Spotify", "1.2.40", @"C:\Users\ada\AppData\Roaming\Spotify\Spotify.exe /uninstall", null, "S-1-5-21-1-2-3-1001");
+    private static readonly ArpEntry Component = new("{0A1B2C3D-0000-0000-0000-000000000000}", "Zoom Outlook Plugin", null, null, null);
+
+    private static readonly List<ArpEntry> Entries = [Component, Zoom, SevenZip, Notepad, Spotify];
+
+    [Fact]
+    public void Find_MatchesProductCodeIgnoringCaseAndBraces()
+    {
+        Assert.Same(SevenZip, ArpRegistry.Find(Entries, new ArpQuery(ProductCode: "23170f69-40c1-2702-2409-000001000000")));
+        Assert.Same(Notepad, ArpRegistry.Find(Entries, new ArpQuery(ProductCode: "{b3c7f2e1-5a40-4f8b-9e31-2d6c0b7a9f12}")));
+    }
+
+    [Fact]
+    public void Find_UsesRegexWhenProductCodeIsAbsent()
+    {
+        var found = ArpRegistry.Find(Entries, new ArpQuery(ProductCode: "{11111111-2222-3333-4444-555555555555}", DisplayNameRegex: @"^7-zip [\d.]+ \(x64"));
+
+        Assert.Same(SevenZip, found);
+    }
+
+    [Fact]
+    public void Find_SkipsEntriesWithoutUninstallCommand()
+    {
+        Assert.Same(Zoom, ArpRegistry.Find(Entries, new ArpQuery(DisplayNameRegex: "^Zoom")));
+    }
+
+    [Fact]
+    public void Find_DisplayNamePrefersExactThenContains()
+    {
+        Assert.Same(Spotify, ArpRegistry.Find(Entries, new ArpQuery(DisplayName: "spotify")));
+        Assert.Same(Zoom, ArpRegistry.Find(Entries, new ArpQuery(DisplayName: "Zoom Workplace")));
+        Assert.Null(ArpRegistry.Find(Entries, new ArpQuery(DisplayName: "Zoom Workplace Enterprise Edition")));
+    }
+
+    [Fact]
+    public void Find_RejectsInvalidRegex()
+    {
+        Assert.ThrowsAny<ArgumentException>(() => ArpRegistry.Find(Entries, new ArpQuery(DisplayNameRegex: "Zoom (")));
+    }
+
+    [Theory]
+    [InlineData("{23170f69-40c1-2702-2409-000001000000}", "{23170F69-40C1-2702-2409-000001000000}")]
+    [InlineData(" 23170F69-40C1-2702-2409-000001000000 ", "{23170F69-40C1-2702-2409-000001000000}")]
+    [InlineData("ZoomUMX", null)]
+    [InlineData(null, null)]
+    public void NormalizeProductCode_ReturnsBracedUppercaseGuid(string? value, string? expected)
+    {
+        Assert.Equal(expected, ArpRegistry.NormalizeProductCode(value));
+    }
+}
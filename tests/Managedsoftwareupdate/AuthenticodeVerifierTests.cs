@@ -0,0 +1,62 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for AuthenticodeVerifier - expected_signer matching and which payloads are checked.
+/// </summary>
+public class AuthenticodeVerifierTests
+{
+    private const string MozillaSubject = "CN=Mozilla Corporation, O=Mozilla Corporation, L=San Francisco, S=California, C=US";
+    private const string MozillaThumbprint = "A1B2C3D4E5F60718293A4B5C6D7E8F9012345678";
+
+    [Theory]
+    [InlineData("Mozilla Corporation")]
+    [InlineData("cn=mozilla corporation")]
+    [InlineData(MozillaSubject)]
+    public void MatchesSigner_SubjectText_MatchesCaseInsensitively(string expected)
+    {
+        Assert.True(AuthenticodeVerifier.MatchesSigner(expected, MozillaSubject, MozillaThumbprint));
+    }
+
+    [Fact]
+    public void MatchesSigner_DifferentPublisher_DoesNotMatch()
+    {
+        Assert.False(AuthenticodeVerifier.MatchesSigner("Google LLC", MozillaSubject, MozillaThumbprint));
+    }
+
+    [Theory]
+    [InlineData("a1b2c3d4e5f60718293a4b5c6d7e8f9012345678")]
+    [InlineData("A1 B2 C3 D4 E5 F6 07 18 29 3A 4B 5C 6D 7E 8F 90 12 34 56 78")]
+    [InlineData("A1:B2:C3:D4:E5:F6:07:18:29:3A:4B:5C:6D:7E:8F:90:12:34:56:78")]
+    public void MatchesSigner_Thumbprint_MatchesRegardlessOfFormatting(string expected)
+    {
+        Assert.True(AuthenticodeVerifier.MatchesSigner(expected, MozillaSubject, MozillaThumbprint));
+    }
+
+    [Fact]
+    public void MatchesSigner_WrongThumbprint_DoesNotFallBackToSubject()
+    {
+        Assert.False(AuthenticodeVerifier.MatchesSigner("0000000000000000000000000000000000000000", MozillaSubject, MozillaThumbprint));
+    }
+
+    [Fact]
+    public void MatchesSigner_NoCertificate_DoesNotMatch()
+    {
+        Assert.False(AuthenticodeVerifier.MatchesSigner("Mozilla Corporation", null, null));
+    }
+
+    [Theory]
+    [InlineData("exe", "setup.bin", true)]
+    [InlineData("msi", "product.msi", true)]
+    [InlineData("", "Firefox.exe", true)]
+    [InlineData("", "hotfix.msp", true)]
+    [InlineData("powershell", "install.ps1", false)]
+    [InlineData("msix", "app.msix", false)]
+    [InlineData("zip", "bundle.zip", false)]
+    public void AppliesTo_OnlyExeAndMsiPayloads(string installerType, string path, bool expected)
+    {
+        Assert.Equal(expected, AuthenticodeVerifier.AppliesTo(installerType, path));
+    }
+}
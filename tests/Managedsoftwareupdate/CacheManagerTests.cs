@@ -0,0 +1,135 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for CacheManager - CacheSizeLimitMB / CacheRetentionDays eviction that
+/// keeps the newest payload of every item for rollback.
+/// </summary>
+public class CacheManagerTests : IDisposable
+{
+    private const long Mb = 1024 * 1024;
+    private static readonly DateTime Now = new(2026, 3, 1, 12, 0, 0, DateTimeKind.Utc);
+
+    private readonly string _testCacheDir;
+
+    public CacheManagerTests()
+    {
+        _testCacheDir = Path.Combine(Path.GetTempPath(), "CimianTests", "Cache", Guid.NewGuid().ToString());
+        Directory.CreateDirectory(_testCacheDir);
+    }
+
+    public void Dispose()
+    {
+        try
+        {
+            if (Directory.Exists(_testCacheDir))
+            {
+                Directory.Delete(_testCacheDir, recursive: true);
+            }
+        }
+        catch { /* Ignore cleanup errors */ }
+    }
+
+    private static CachedPayload Payload(string name, string item, long size, int downloadedDaysAgo, int usedDaysAgo) =>
+        new(name, item, size, Now.AddDays(-downloadedDaysAgo), Now.AddDays(-usedDaysAgo));
+
+    [Fact]
+    public void PlanEviction_EvictsLeastRecentlyUsedUntilUnderLimit()
+    {
+        var payloads = new[]
+        {
+            Payload("chrome-119.msi", "chrome", 40 * Mb, 20, 20),
+            Payload("chrome-120.msi", "chrome", 40 * Mb, 5, 5),
+            Payload("zoom-5.msi", "zoom", 40 * Mb, 15, 1),
+            Payload("zoom-6.msi", "zoom", 40 * Mb, 2, 2),
+            Payload("teams-1.msix", "teams", 40 * Mb, 10, 10),
+            Payload("teams-2.msix", "teams", 40 * Mb, 3, 3)
+        };
+
+        var plan = CacheManager.PlanEviction(payloads, 160 * Mb, 0, Now);
+
+        Assert.Equal(["chrome-119.msi", "teams-1.msix"], plan.Candidates.Select(p => p.Path));
+        Assert.Equal(160 * Mb, plan.BytesAfterEviction);
+    }
+
+    [Fact]
+    public void PlanEviction_KeepsNewestPayloadPerItemEvenOverLimit()
+    {
+        var payloads = new[]
+        {
+            Payload("office-1.exe", "office", 500 * Mb, 90, 90),
+            Payload("office-2.exe", "office", 500 * Mb, 1, 1)
+        };
+
+        var plan = CacheManager.PlanEviction(payloads, 100 * Mb, 30, Now);
+
+        Assert.Equal(["office-1.exe"], plan.Candidates.Select(p => p.Path));
+        Assert.Equal(["office-2.exe"], plan.Retained.Select(p => p.Path));
+    }
+
+    [Fact]
+    public void PlanEviction_RetentionDaysEvictsUnusedPayloadsWithoutALimit()
+    {
+        var payloads = new[]
+        {
+            Payload("vlc-3.0.msi", "vlc", Mb, 60, 45),
+            Payload("vlc-3.0.1.msi", "vlc", Mb, 40, 10),
+            Payload("vlc-3.0.2.msi", "vlc", Mb, 1, 1)
+        };
+
+        Assert.Equal(["vlc-3.0.msi"], CacheManager.PlanEviction(payloads, 0, 30, Now).Candidates.Select(p => p.Path));
+        Assert.Empty(CacheManager.PlanEviction(payloads, 0, 0, Now).Candidates);
+    }
+
+    [Fact]
+    public void OwnerOf_GroupsFilesByNameUpToTheVersion()
+    {
+        var manager = new CacheManager(new CimianConfig { CachePath = _testCacheDir });
+
+        Assert.Equal("chrome", manager.OwnerOf(Path.Combine(_testCacheDir, "Chrome-120.0.1-x64.msi")));
+        Assert.Equal("chrome", manager.OwnerOf(Path.Combine(_testCacheDir, "Chrome_121.0.msi")));
+        Assert.Equal("browsers/firefox setup", manager.OwnerOf(Path.Combine(_testCacheDir, "browsers", "Firefox Setup 128.0.exe")));
+        Assert.Equal("7z2301-x64", manager.OwnerOf(Path.Combine(_testCacheDir, "7z2301-x64.exe")));
+    }
+
+    [Fact]
+    public void OwnerOf_PrefersTheCatalogItem()
+    {
+        var item = new CatalogItem
+        {
+            Name = "GoogleChrome",
+            Version = "120.0",
+            Category = "Browsers",
+            Installer = new InstallerInfo { Location = "apps/chrome/googlechromestandaloneenterprise64.msi" }
+        };
+        var manager = new CacheManager(new CimianConfig { CachePath = _testCacheDir }, [item]);
+
+        Assert.Equal("googlechrome", manager.OwnerOf(Path.Combine(_testCacheDir, "browsers", "googlechromestandaloneenterprise64.msi")));
+    }
+
+    [Fact]
+    public void Evict_DeletesCandidatesAndSkipsPartialDownloads()
+    {
+        var config = new CimianConfig { CachePath = _testCacheDir, CacheSizeLimitMB = 1, CacheRetentionDays = 0 };
+        var old = Path.Combine(_testCacheDir, "tool-1.0.exe");
+        var current = Path.Combine(_testCacheDir, "tool-2.0.exe");
+        var partial = Path.Combine(_testCacheDir, "tool-3.0.exe.downloading");
+        foreach (var path in new[] { old, current, partial })
+        {
+            File.WriteAllBytes(path, new byte[Mb]);
+        }
+        File.SetLastWriteTimeUtc(old, DateTime.UtcNow.AddDays(-10));
+        File.SetLastAccessTimeUtc(old, DateTime.UtcNow.AddDays(-10));
+
+        var (fileCount, bytesFreed) = new CacheManager(config).Evict();
+
+        Assert.Equal(1, fileCount);
+        Assert.Equal(Mb, bytesFreed);
+        Assert.False(File.Exists(old));
+        Assert.True(File.Exists(current));
+        Assert.True(File.Exists(partial));
+    }
+}
@@ -0,0 +1,67 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for architecture eligibility and preference: ARM64 machines take arm64
+/// builds first and fall back to x64 under emulation unless ForbidEmulatedInstalls.
+/// </summary>
+public class CatalogArchitectureTests
+{
+    private static CatalogItem Build(string version, params string[] arch) => new()
+    {
+        Name = "Zoom",
+        Version = version,
+        SupportedArch = arch.ToList()
+    };
+
+    [Theory]
+    [InlineData("arm64", "arm64", ArchitectureMatch.Native)]
+    [InlineData("arm64", "x64", ArchitectureMatch.Emulated)]
+    [InlineData("arm64", "x86", ArchitectureMatch.Emulated)]
+    [InlineData("arm64", "AMD64", ArchitectureMatch.Emulated)]
+    [InlineData("aarch64", "arm64", ArchitectureMatch.Native)]
+    [InlineData("x64", "x86_64", ArchitectureMatch.Native)]
+    [InlineData("x64", "arm64", ArchitectureMatch.Unsupported)]
+    [InlineData("x64", "x86", ArchitectureMatch.Unsupported)]
+    public void MatchArchitecture_ClassifiesBuilds(string systemArch, string itemArch, ArchitectureMatch expected)
+    {
+        Assert.Equal(expected, CatalogService.MatchArchitecture(Build("1.0", itemArch), systemArch));
+    }
+
+    [Fact]
+    public void MatchArchitecture_UnrestrictedItemsAreNativeEverywhere()
+    {
+        Assert.Equal(ArchitectureMatch.Native, CatalogService.MatchArchitecture(Build("1.0"), "arm64", allowEmulation: false));
+    }
+
+    [Fact]
+    public void SupportsArchitecture_ForbiddingEmulationDropsX64OnArm64()
+    {
+        var x64 = Build("1.0", "x64");
+
+        Assert.True(CatalogService.SupportsArchitecture(x64, "arm64"));
+        Assert.False(CatalogService.SupportsArchitecture(x64, "arm64", allowEmulation: false));
+        Assert.True(CatalogService.SupportsArchitecture(x64, "x64", allowEmulation: false));
+    }
+
+    [Fact]
+    public void IsPreferredBuild_NativeBeatsNewerEmulatedBuild()
+    {
+        var arm64 = Build("6.0", "arm64");
+        var x64 = Build("6.2", "x64");
+
+        Assert.True(CatalogService.IsPreferredBuild(arm64, x64, "arm64"));
+        Assert.False(CatalogService.IsPreferredBuild(x64, arm64, "arm64"));
+    }
+
+    [Fact]
+    public void IsPreferredBuild_HigherVersionWinsBetweenEquals()
+    {
+        Assert.True(CatalogService.IsPreferredBuild(Build("6.2", "x64"), Build("6.0", "x64"), "arm64"));
+        Assert.True(CatalogService.IsPreferredBuild(Build("6.2", "x64"), Build("6.0", "x64", "arm64"), "x64"));
+        Assert.False(CatalogService.IsPreferredBuild(Build("6.0", "arm64"), Build("6.2", "x64", "arm64"), "arm64"));
+    }
+}
@@ -47,4 +47,22 @@ public class CatalogDeserializationTests
         Assert.NotNull(item);
         Assert.False(item!.OnDemand);
     }
+
+    [Fact]
+    public void CatalogItem_RequiresRestart_IsShorthandForRequireRestart()
+    {
+        const string yaml = """
+            name: Driver
+            version: 1.0
+            requires_restart: true
+            """;
+
+        var item = YamlUtils.Deserializer.Deserialize<CatalogItem>(yaml);
+
+        Assert.True(item.RequiresRestart);
+        Assert.Equal("RequireRestart", item.EffectiveRestartAction);
+
+        item.RestartAction = "RecommendRestart";
+        Assert.Equal("RecommendRestart", item.EffectiveRestartAction);
+    }
 }
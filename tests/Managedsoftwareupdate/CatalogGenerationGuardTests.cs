@@ -0,0 +1,109 @@
+using System.Net;
+using Xunit;
+using FluentAssertions;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Catalog replay protection: once a generation has been accepted, older
+/// catalogs (or catalogs that drop the generation) must be refused unless
+/// AllowCatalogDowngrade is set.
+/// </summary>
+public class CatalogGenerationGuardTests : IDisposable
+{
+    private readonly string _statePath;
+
+    public CatalogGenerationGuardTests()
+    {
+        var dir = Path.Combine(Path.GetTempPath(), "CimianTests", "CatalogGeneration", Guid.NewGuid().ToString());
+        Directory.CreateDirectory(dir);
+        _statePath = Path.Combine(dir, "catalog_generations.json");
+    }
+
+    public void Dispose()
+    {
+        try { Directory.Delete(Path.GetDirectoryName(_statePath)!, true); } catch { }
+    }
+
+    [Fact]
+    public void FirstSeen_AnyGenerationAccepted()
+    {
+        var guard = new CatalogGenerationGuard(_statePath, allowDowngrade: false);
+
+        guard.IsAcceptable("Production", 100, out _).Should().BeTrue();
+        guard.IsAcceptable("Production", null, out _).Should().BeTrue();
+    }
+
+    [Fact]
+    public void OlderGeneration_RefusedAfterRecord_AndPersistsAcrossRuns()
+    {
+        new CatalogGenerationGuard(_statePath, allowDowngrade: false).Record("Production", 200);
+
+        var nextRun = new CatalogGenerationGuard(_statePath, allowDowngrade: false);
+
+        nextRun.IsAcceptable("production", 199, out var reason).Should().BeFalse();
+        reason.Should().Contain("older");
+        nextRun.IsAcceptable("Production", 200, out _).Should().BeTrue();
+        nextRun.IsAcceptable("Production", 201, out _).Should().BeTrue();
+    }
+
+    [Fact]
+    public void MissingGeneration_RefusedOnceGenerationSeen()
+    {
+        var guard = new CatalogGenerationGuard(_statePath, allowDowngrade: false);
+        guard.Record("Testing", 50);
+
+        guard.IsAcceptable("Testing", null, out _).Should().BeFalse();
+    }
+
+    [Fact]
+    public void AllowDowngrade_AcceptsAndRebaselines()
+    {
+        new CatalogGenerationGuard(_statePath, allowDowngrade: false).Record("Production", 500);
+
+        var overridden = new CatalogGenerationGuard(_statePath, allowDowngrade: true);
+        overridden.IsAcceptable("Production", 10, out _).Should().BeTrue();
+        overridden.Record("Production", 10);
+
+        new CatalogGenerationGuard(_statePath, allowDowngrade: false).LastSeen("Production").Should().Be(10);
+    }
+
+    [Theory]
+    [InlineData("generation: 1700000000000\nitems: []\n", 1700000000000L)]
+    [InlineData("generation: '42'\r\nitems: []\r\n", 42L)]
+    [InlineData("items:\n  - name: generation\n", null)]
+    public void ReadCatalogGeneration_ParsesTopLevelKeyOnly(string yaml, long? expected)
+    {
+        CatalogService.ReadCatalogGeneration(yaml).Should().Be(expected);
+    }
+
+    [Theory]
+    [InlineData(false, 50L)]
+    [InlineData(true, 100L)]
+    public async Task DownloadCatalog_DryRunLeavesStoredGenerationUnchanged(bool recordGenerations, long expected)
+    {
+        new CatalogGenerationGuard(_statePath, allowDowngrade: false).Record("Production", 50);
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = "https://test-repo.example.com",
+            CatalogsPath = Path.Combine(Path.GetDirectoryName(_statePath)!, "catalogs")
+        };
+        var service = new CatalogService(config, new HttpClient(new CatalogServer("generation: 100\nitems: []\n")),
+            new CatalogGenerationGuard(_statePath, allowDowngrade: false))
+        {
+            RecordGenerations = recordGenerations
+        };
+
+        await service.DownloadCatalogAsync("Production");
+
+        new CatalogGenerationGuard(_statePath, allowDowngrade: false).LastSeen("Production").Should().Be(expected);
+    }
+
+    private sealed class CatalogServer(string catalog) : HttpMessageHandler
+    {
+        protected override Task<HttpResponseMessage> SendAsync(HttpRequestMessage request, CancellationToken cancellationToken) =>
+            Task.FromResult(new HttpResponseMessage(HttpStatusCode.OK) { Content = new StringContent(catalog) });
+    }
+}
@@ -0,0 +1,70 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for CatalogOverrideService - the --override-catalogs pin and its expiry.
+/// </summary>
+public class CatalogOverrideServiceTests : IDisposable
+{
+    private readonly string _testDir;
+    private readonly string _path;
+    private readonly string _auditPath;
+
+    public CatalogOverrideServiceTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "CatalogOverride", Guid.NewGuid().ToString());
+        _path = Path.Combine(_testDir, "catalog_override.json");
+        _auditPath = Path.Combine(_testDir, "logs", "catalog_override.log");
+    }
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    private CatalogOverrideService NewService() => new(_path, _auditPath);
+
+    [Fact]
+    public void Consume_ExpiresAfterConfiguredRuns()
+    {
+        var now = new DateTime(2026, 10, 16, 9, 0, 0);
+        NewService().Set(["Testing", "Production"], 2, @"CONTOSO\helpdesk", now);
+
+        var first = NewService().Consume("manual", now.AddMinutes(5));
+        Assert.Equal(new[] { "Testing", "Production" }, first!.Catalogs);
+        Assert.Equal(1, NewService().Get()!.RunsRemaining);
+
+        Assert.NotNull(NewService().Consume("auto", now.AddHours(1)));
+        Assert.Null(NewService().Get());
+        Assert.Null(NewService().Consume("auto", now.AddHours(2)));
+        Assert.False(File.Exists(_path));
+    }
+
+    [Fact]
+    public void SetConsumeClear_AreAudited()
+    {
+        var now = new DateTime(2026, 10, 16, 9, 0, 0);
+        var service = NewService();
+        service.Set(["Testing"], 3, @"CONTOSO\helpdesk", now);
+        service.Consume("manual", now);
+        Assert.True(service.Clear(@"CONTOSO\helpdesk", now));
+        Assert.False(service.Clear(@"CONTOSO\helpdesk", now));
+
+        var lines = File.ReadAllLines(_auditPath);
+        Assert.Equal(3, lines.Length);
+        Assert.Contains(@"set catalogs=Testing runs=3 by=CONTOSO\helpdesk", lines[0]);
+        Assert.Contains("used catalogs=Testing run_type=manual runs_left=2", lines[1]);
+        Assert.Contains("cleared catalogs=Testing runs_left=2", lines[2]);
+    }
+
+    [Fact]
+    public void Set_RejectsEmptyCatalogsAndZeroRuns()
+    {
+        var now = DateTime.Now;
+        Assert.Throws<ArgumentException>(() => NewService().Set([" ", ""], 3, "user", now));
+        Assert.Throws<ArgumentOutOfRangeException>(() => NewService().Set(["Testing"], 0, "user", now));
+        Assert.Null(NewService().Get());
+    }
+}
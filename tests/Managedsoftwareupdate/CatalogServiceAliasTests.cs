@@ -0,0 +1,94 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for pkginfo <c>aliases</c>: renamed items stay resolvable by their old
+/// names without appearing twice in the catalog map.
+/// </summary>
+public class CatalogServiceAliasTests : IDisposable
+{
+    private readonly string _testDir;
+    private readonly CimianConfig _config;
+
+    public CatalogServiceAliasTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "Aliases", Guid.NewGuid().ToString());
+        _config = new CimianConfig { CatalogsPath = Path.Combine(_testDir, "catalogs") };
+        Directory.CreateDirectory(_config.CatalogsPath);
+    }
+
+    public void Dispose()
+    {
+        try
+        {
+            if (Directory.Exists(_testDir))
+            {
+                Directory.Delete(_testDir, recursive: true);
+            }
+        }
+        catch { /* Ignore cleanup errors */ }
+    }
+
+    private CatalogService LoadCatalog(string yaml, out Dictionary<string, CatalogItem> items)
+    {
+        File.WriteAllText(Path.Combine(_config.CatalogsPath, "Production.yaml"), yaml);
+        var service = new CatalogService(_config, new HttpClient());
+        items = service.LoadLocalCatalogItems();
+        return service;
+    }
+
+    [Fact]
+    public void Alias_ResolvesToCurrentItem_WithoutDuplicatingIt()
+    {
+        var service = LoadCatalog("""
+            items:
+              - name: AcrobatReader
+                version: 25.1.0
+                aliases:
+                  - AdobeReaderDC
+            """, out var items);
+
+        Assert.Single(items);
+        Assert.Equal("AcrobatReader", service.ResolveAlias("adobereaderdc"));
+        Assert.Equal("AcrobatReader", service.FindItem(items, "AdobeReaderDC")?.Name);
+        Assert.Equal("Unrelated", service.ResolveAlias("Unrelated"));
+    }
+
+    [Fact]
+    public void Alias_RewritesRequiresAndUpdateFor()
+    {
+        var service = LoadCatalog("""
+            items:
+              - name: AcrobatReader
+                version: 25.1.0
+                aliases: [AdobeReaderDC]
+              - name: ReaderPlugin
+                version: 1.0.0
+                requires: [AdobeReaderDC-24.0]
+                update_for: [AdobeReaderDC]
+            """, out var items);
+
+        var plugin = service.FindItem(items, "ReaderPlugin")!;
+        Assert.Equal(["AcrobatReader-24.0"], plugin.Requires);
+        Assert.Equal(["AcrobatReader"], plugin.UpdateFor);
+    }
+
+    [Fact]
+    public void Alias_CollidingWithRealItem_IsIgnored()
+    {
+        var service = LoadCatalog("""
+            items:
+              - name: NewTool
+                version: 2.0.0
+                aliases: [OldTool]
+              - name: OldTool
+                version: 1.0.0
+            """, out var items);
+
+        Assert.Equal("OldTool", service.ResolveAlias("OldTool"));
+        Assert.Equal("1.0.0", service.FindItem(items, "OldTool")?.Version);
+    }
+}
@@ -204,4 +204,33 @@ public class CatalogServiceDependencyTests
         Assert.True(sw.ElapsedMilliseconds < 500,
             $"Closure walk took {sw.ElapsedMilliseconds}ms on 2k-item catalog — likely regressed to per-node full scan");
     }
+
+    [Fact]
+    public void Closure_UpdateOnly_TracksDepsReachedOnlyThroughUpdateFor()
+    {
+        // AUpdate is only an update; Shared is both an update and required by A.
+        var catalog = Catalog(
+            Item("A", requires: new() { "B" }),
+            Item("B"),
+            Item("AUpdate", updateFor: new() { "A" }),
+            Item("Shared", updateFor: new() { "A" }),
+            Item("C", requires: new() { "Shared" }),
+            Item("AUpdate2", updateFor: new() { "AUpdate" }));
+        var updateOnly = new HashSet<string>(StringComparer.OrdinalIgnoreCase);
+
+        var deps = CatalogService.BuildDependencyClosure(new[] { "A", "C" }, catalog, updateOnly);
+
+        Assert.Equal(4, deps.Count);
+        Assert.Equal(new[] { "AUpdate", "AUpdate2" }, updateOnly.OrderBy(n => n));
+    }
+
+    [Fact]
+    public void HasUpdateTarget_NeedsAnInstalledTarget()
+    {
+        var update = Item("OfficeUpdate", updateFor: new() { "Office2019", "Office2021--16.0" });
+
+        Assert.True(CatalogService.HasUpdateTarget(update, name => name == "Office2021"));
+        Assert.False(CatalogService.HasUpdateTarget(update, _ => false));
+        Assert.True(CatalogService.HasUpdateTarget(Item("Plain"), _ => false));
+    }
 }
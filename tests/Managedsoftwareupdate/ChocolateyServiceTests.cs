@@ -0,0 +1,94 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for ChocolateyService - source selection and the choco command lines it builds.
+/// </summary>
+public class ChocolateyServiceTests
+{
+    private static readonly ChocolateySource Internal = new()
+    {
+        Name = "internal",
+        URL = "https://nexus.example.com/repository/choco/",
+        User = "svc-choco",
+        Password = "s3cret",
+        Priority = 1
+    };
+
+    private static readonly ChocolateySource Community = new() { Name = "community", URL = "https://community.chocolatey.org/api/v2/" };
+
+    private static ChocolateyService Service() => new(new CimianConfig { ChocolateySources = [Internal, Community] });
+
+    private static CatalogItem Item(params string[] sources) => new()
+    {
+        Name = "NotepadPlusPlus",
+        Version = "8.6.9",
+        Installer = new InstallerInfo { Type = "chocolatey", PackageId = "notepadplusplus", Sources = sources.ToList() }
+    };
+
+    [Fact]
+    public void ResolveSources_DefaultsToEveryConfiguredSource()
+    {
+        var (sources, error) = Service().ResolveSources(Item());
+
+        Assert.Null(error);
+        Assert.Equal(new[] { "internal", "community" }, sources.Select(s => s.Name));
+    }
+
+    [Fact]
+    public void ResolveSources_UsesNamedSourcesAndRejectsUnknownOnes()
+    {
+        var (named, namedError) = Service().ResolveSources(Item("Internal"));
+        var (_, unknownError) = Service().ResolveSources(Item("internal", "staging"));
+
+        Assert.Null(namedError);
+        Assert.Same(Internal, Assert.Single(named));
+        Assert.Equal("Chocolatey source 'staging' is not in ChocolateySources", unknownError);
+    }
+
+    [Fact]
+    public void BuildInstallArgs_PassesLocalFolderThenRegisteredSources()
+    {
+        var args = ChocolateyService.BuildInstallArgs(Item(), Path.Combine("cache", "npp", "npp.8.6.9.nupkg"), [Internal]);
+
+        Assert.Equal("install", args[0]);
+        Assert.Equal("notepadplusplus", args[1]);
+        Assert.Contains("--version=8.6.9", args);
+        Assert.Contains($"--source=\"{Path.Combine("cache", "npp")};cimian-internal\"", args);
+        Assert.DoesNotContain(args, a => a.Contains("s3cret"));
+    }
+
+    [Fact]
+    public void BuildInstallArgs_WithoutSourcesLeavesSourceOff()
+    {
+        var item = Item();
+        item.Installer.PackageId = null;
+
+        var args = ChocolateyService.BuildInstallArgs(item, null, []);
+
+        Assert.Equal("NotepadPlusPlus", args[1]);
+        Assert.DoesNotContain(args, a => a.StartsWith("--source"));
+    }
+
+    [Fact]
+    public void BuildSourceAddArgs_IncludesCredentialsAndPriority()
+    {
+        Assert.Equal(
+            new[] { "source", "add", "--name=cimian-internal", "--source=https://nexus.example.com/repository/choco/", "--user=svc-choco", "--password=s3cret", "--priority=1", "--limit-output" },
+            ChocolateyService.BuildSourceAddArgs(Internal));
+        Assert.Equal(
+            new[] { "source", "add", "--name=cimian-community", "--source=https://community.chocolatey.org/api/v2/", "--limit-output" },
+            ChocolateyService.BuildSourceAddArgs(Community));
+        Assert.DoesNotContain("s3cret", Internal.ToString());
+    }
+
+    [Fact]
+    public void BuildPinArgs_PinsTheInstalledVersion()
+    {
+        Assert.Equal(new[] { "pin", "add", "--name=notepadplusplus", "--version=8.6.9", "--limit-output" }, ChocolateyService.BuildPinArgs(Item(), add: true));
+        Assert.Equal(new[] { "pin", "remove", "--name=notepadplusplus", "--limit-output" }, ChocolateyService.BuildPinArgs(Item(), add: false));
+    }
+}
@@ -0,0 +1,181 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+using Cimian.Core.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for ConfigurationItemService - validation, test/set flow and the DSC script it builds.
+/// </summary>
+public class ConfigurationItemServiceTests : IDisposable
+{
+    private readonly string _testDir;
+    private readonly List<string> _ran = new();
+    private bool _compliant;
+    private bool _setFixes = true;
+
+    public ConfigurationItemServiceTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "ConfigurationItems", Guid.NewGuid().ToString());
+    }
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    private ConfigurationItemsStore Store() => new(Path.Combine(_testDir, "configuration_items.json"));
+
+    private ConfigurationItemService Service() => new(new ScriptService(), Store(), (item, script, ct) =>
+    {
+        _ran.Add(script);
+        if (script == "Set-Thing")
+        {
+            _compliant = _setFixes;
+            return Task.FromResult((true, "set"));
+        }
+        return Task.FromResult((_compliant, _compliant ? "" : "drift"));
+    });
+
+    private static CatalogItem ScriptItem(string? setScript = "Set-Thing") => new()
+    {
+        Name = "TimeSync",
+        Version = "1.0",
+        Installer = new InstallerInfo { Type = "configuration" },
+        Configuration = new ConfigurationPayload { TestScript = "Test-Thing", SetScript = setScript }
+    };
+
+    [Fact]
+    public async Task TestAsync_RecordsComplianceWithoutRemediating()
+    {
+        _compliant = true;
+
+        var result = await Service().TestAsync(ScriptItem());
+
+        Assert.True(result.Compliant);
+        Assert.Equal(new[] { "Test-Thing" }, _ran);
+        var record = Store().Get("TimeSync")!;
+        Assert.True(record.Compliant);
+        Assert.Equal("script", record.Method);
+        Assert.NotNull(record.EvaluatedAt);
+        Assert.Null(record.RemediatedAt);
+    }
+
+    [Fact]
+    public async Task RemediateAsync_SetsThenRetests()
+    {
+        var (success, _) = await Service().RemediateAsync(ScriptItem());
+
+        Assert.True(success);
+        Assert.Equal(new[] { "Set-Thing", "Test-Thing" }, _ran);
+        var record = Store().Get("TimeSync")!;
+        Assert.True(record.Compliant);
+        Assert.NotNull(record.RemediatedAt);
+    }
+
+    [Fact]
+    public async Task RemediateAsync_FailsWhenSetDoesNotStick()
+    {
+        _setFixes = false;
+
+        var (success, output) = await Service().RemediateAsync(ScriptItem());
+
+        Assert.False(success);
+        Assert.Contains("still not compliant", output);
+        var record = Store().Get("TimeSync")!;
+        Assert.False(record.Compliant);
+        Assert.Null(record.RemediatedAt);
+    }
+
+    [Fact]
+    public async Task TestAsync_InvalidItemRunsNothing()
+    {
+        var result = await Service().TestAsync(ScriptItem(setScript: null));
+
+        Assert.False(result.Compliant);
+        Assert.Equal("configuration has a test_script but no set_script", result.Error);
+        Assert.Empty(_ran);
+        Assert.Equal(result.Error, Store().Get("TimeSync")!.Error);
+    }
+
+    [Fact]
+    public void Validate_RejectsMixedAndNestedDsc()
+    {
+        var mixed = ScriptItem();
+        mixed.Configuration!.DscResource = new DscResourceInfo { Name = "Registry", Module = "PSDscResources" };
+        var nested = new CatalogItem
+        {
+            Installer = new InstallerInfo { Type = "configuration" },
+            Configuration = new ConfigurationPayload
+            {
+                DscResource = new DscResourceInfo
+                {
+                    Name = "Registry",
+                    Module = "PSDscResources",
+                    Properties = new() { ["Options"] = new Dictionary<object, object> { ["a"] = "b" } }
+                }
+            }
+        };
+
+        Assert.Equal("configuration has both dsc_resource and test_script/set_script", ConfigurationItemService.Validate(mixed));
+        Assert.Contains("nested object", ConfigurationItemService.Validate(nested)!);
+        Assert.Equal("configuration item has no configuration block", ConfigurationItemService.Validate(new CatalogItem()));
+    }
+
+    [Fact]
+    public void BuildDscScript_InvokesResourceWithLiteralProperties()
+    {
+        var dsc = new DscResourceInfo
+        {
+            Name = "Registry",
+            Module = "PSDscResources",
+            ModuleVersion = "2.12.0",
+            Properties = new()
+            {
+                ["Key"] = @"HKLM:\SOFTWARE\Contoso",
+                ["ValueName"] = "Owner's",
+                ["ValueData"] = new List<object> { "1" },
+                ["Force"] = "true"
+            }
+        };
+
+        var test = ConfigurationItemService.BuildDscScript(dsc, "Test");
+        var set = ConfigurationItemService.BuildDscScript(dsc, "Set");
+
+        Assert.Contains("Invoke-DscResource -Name 'Registry' -ModuleName @{ ModuleName = 'PSDscResources'; ModuleVersion = '2.12.0' } -Method Test", test);
+        Assert.Contains(@"Key = 'HKLM:\SOFTWARE\Contoso'", test);
+        Assert.Contains("ValueName = 'Owner''s'", test);
+        Assert.Contains("ValueData = @(1)", test);
+        Assert.Contains("Force = $true", test);
+        Assert.Contains("if ($result.InDesiredState) { exit 0 }", test);
+        Assert.Contains("-Method Set", set);
+        Assert.DoesNotContain("InDesiredState", set);
+    }
+
+    [Fact]
+    public void CatalogItem_BindsConfigurationBlock()
+    {
+        const string yaml = """
+            name: DefenderCloudProtection
+            version: 1.0
+            installer:
+              type: configuration
+            configuration:
+              dsc_resource:
+                name: Registry
+                module: PSDscResources
+                properties:
+                  Key: HKLM:\SOFTWARE\Policies\Contoso
+                  ValueData:
+                    - 1
+            """;
+
+        var item = YamlUtils.Deserializer.Deserialize<CatalogItem>(yaml);
+
+        Assert.True(item.IsConfigurationItem);
+        Assert.Equal("dsc", item.Configuration!.Method);
+        Assert.Equal("PSDscResources", item.Configuration.DscResource!.Module);
+        Assert.Null(ConfigurationItemService.Validate(item));
+    }
+}
@@ -267,6 +267,173 @@ Catalogs:
         Assert.Contains(errors, e => e.Contains("InstallerTimeout"));
     }
 
+    [Fact]
+    public void ValidateConfig_UnknownDependentRemovalPolicy_ReturnsError()
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = "https://valid.example.com",
+            CachePath = @"C:\Cache",
+            DependentRemovalPolicy = "ignore"
+        };
+
+        Assert.Contains(_service.ValidateConfig(config), e => e.Contains("DependentRemovalPolicy"));
+
+        config.DependentRemovalPolicy = "Block";
+        Assert.DoesNotContain(_service.ValidateConfig(config), e => e.Contains("DependentRemovalPolicy"));
+    }
+
+    [Theory]
+    [InlineData(-1, true)]
+    [InlineData(0, false)]
+    [InlineData(90, false)]
+    [InlineData(1441, true)]
+    public void ValidateConfig_DefaultInstallerTimeoutMinutes_ChecksRange(int minutes, bool invalid)
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = "https://valid.example.com",
+            CachePath = @"C:\Cache",
+            DefaultInstallerTimeoutMinutes = minutes
+        };
+
+        Assert.Equal(invalid, _service.ValidateConfig(config).Any(e => e.Contains("DefaultInstallerTimeoutMinutes")));
+    }
+
+    [Theory]
+    [InlineData(0, false)]
+    [InlineData(10, true)]
+    [InlineData(60, false)]
+    [InlineData(-5, true)]
+    [InlineData(2000, true)]
+    public void ValidateConfig_AutoRunIntervalMinutes_ZeroOrFifteenToADay(int minutes, bool invalid)
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = "https://valid.example.com",
+            CachePath = @"C:\Cache",
+            AutoRunIntervalMinutes = minutes
+        };
+
+        Assert.Equal(invalid, _service.ValidateConfig(config).Any(e => e.Contains("AutoRunIntervalMinutes")));
+    }
+
+    [Theory]
+    [InlineData(0, false)]
+    [InlineData(5, true)]
+    [InlineData(60, false)]
+    [InlineData(-1, true)]
+    [InlineData(1441, true)]
+    public void ValidateConfig_HungRunMinutes_ZeroOrTenToADay(int minutes, bool invalid)
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = "https://valid.example.com",
+            CachePath = @"C:\Cache",
+            HungRunMinutes = minutes
+        };
+
+        Assert.Equal(invalid, _service.ValidateConfig(config).Any(e => e.Contains("HungRunMinutes")));
+    }
+
+    [Theory]
+    [InlineData(0, 2, true)]
+    [InlineData(1, 0, false)]
+    [InlineData(5, 2, false)]
+    [InlineData(51, 2, true)]
+    [InlineData(5, 61, true)]
+    [InlineData(5, -1, true)]
+    public void ValidateConfig_BootstrapRetries_Bounded(int attempts, int delayMinutes, bool invalid)
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = "https://valid.example.com",
+            CachePath = @"C:\Cache",
+            BootstrapMaxAttempts = attempts,
+            BootstrapRetryDelayMinutes = delayMinutes
+        };
+
+        Assert.Equal(invalid, _service.ValidateConfig(config).Any(e => e.StartsWith("Bootstrap")));
+    }
+
+    [Theory]
+    [InlineData(0, false)]
+    [InlineData(240, false)]
+    [InlineData(1440, false)]
+    [InlineData(-1, true)]
+    [InlineData(1441, true)]
+    public void ValidateConfig_BootstrapScreenTimeout_UpToADay(int minutes, bool invalid)
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = "https://valid.example.com",
+            CachePath = @"C:\Cache",
+            BootstrapScreenTimeoutMinutes = minutes
+        };
+
+        Assert.Equal(invalid, _service.ValidateConfig(config).Any(e => e.Contains("BootstrapScreenTimeoutMinutes")));
+    }
+
+    [Theory]
+    [InlineData("stable", false)]
+    [InlineData("Beta", false)]
+    [InlineData("dev", false)]
+    [InlineData("", false)]
+    [InlineData("canary", true)]
+    public void ValidateConfig_SelfUpdateChannel_StableBetaOrDev(string channel, bool invalid)
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = "https://valid.example.com",
+            CachePath = @"C:\Cache",
+            SelfUpdateChannel = channel
+        };
+
+        Assert.Equal(invalid, _service.ValidateConfig(config).Any(e => e.Contains("SelfUpdateChannel")));
+    }
+
+    [Fact]
+    public void ValidateConfig_EspTrackedItems_RejectsBlankNames()
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = "https://valid.example.com",
+            CachePath = @"C:\Cache",
+            EspTrackedItems = ["Firefox", " "]
+        };
+
+        Assert.Contains(_service.ValidateConfig(config), e => e.Contains("EspTrackedItems"));
+
+        config.EspTrackedItems = ["Firefox"];
+        Assert.DoesNotContain(_service.ValidateConfig(config), e => e.Contains("EspTrackedItems"));
+    }
+
+    [Fact]
+    public void ValidateConfig_ChocolateySources_NeedUniqueNamesUrlsAndUserForPassword()
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = "https://valid.example.com",
+            CachePath = @"C:\Cache",
+            InstallerTimeout = 900,
+            ChocolateySources =
+            [
+                new ChocolateySource { Name = "internal", URL = "https://nexus.example.com/choco/", User = "svc", Password = "pw" },
+                new ChocolateySource { Name = "Internal", URL = "https://other.example.com/" },
+                new ChocolateySource { Name = "bad name", URL = "" },
+                new ChocolateySource { Name = "share", URL = @"\\files\choco", Password = "pw" }
+            ]
+        };
+
+        var errors = _service.ValidateConfig(config);
+
+        Assert.Contains(errors, e => e.Contains("'Internal' is used more than once"));
+        Assert.Contains(errors, e => e.Contains("'bad name' must be"));
+        Assert.Contains(errors, e => e.Contains("bad name needs a URL"));
+        Assert.Contains(errors, e => e.Contains("share has a Password but no User"));
+        Assert.Equal(4, errors.Count);
+    }
+
     #endregion
 
     #region EnsureDirectoriesExist Tests
@@ -289,4 +456,131 @@ Catalogs:
     }
 
     #endregion
+
+    #region Mid-Run Config Change Tests
+
+    [Fact]
+    public void TryLoadStableConfig_ValidFile_ReturnsConfig()
+    {
+        File.WriteAllText(_testConfigPath, "SoftwareRepoURL: https://repo.example.com\nCachePath: C:\\Cache\n");
+
+        var ok = _service.TryLoadStableConfig(_testConfigPath, out var config, out var error);
+
+        Assert.True(ok);
+        Assert.Null(error);
+        Assert.Equal("https://repo.example.com", config!.SoftwareRepoURL);
+    }
+
+    [Fact]
+    public void TryLoadStableConfig_TruncatedFile_DoesNotFallBackToDefaults()
+    {
+        // A push caught mid-write: the value is cut off and the YAML is invalid
+        File.WriteAllText(_testConfigPath, "SoftwareRepoURL: \"https://repo.exa");
+
+        var ok = _service.TryLoadStableConfig(_testConfigPath, out var config, out var error);
+
+        Assert.False(ok);
+        Assert.Null(config);
+        Assert.False(string.IsNullOrEmpty(error));
+    }
+
+    [Fact]
+    public void TryLoadStableConfig_InvalidValues_ReturnsValidationError()
+    {
+        File.WriteAllText(_testConfigPath, "SoftwareRepoURL: ftp://repo\nCachePath: C:\\Cache\n");
+
+        var ok = _service.TryLoadStableConfig(_testConfigPath, out _, out var error);
+
+        Assert.False(ok);
+        Assert.Contains("HTTP/HTTPS", error);
+    }
+
+    [Fact]
+    public void LoadConfig_MachineRole_FillsUnsetKeysOnly()
+    {
+        File.WriteAllText(_testConfigPath,
+            "SoftwareRepoURL: https://repo.example.com\nMachineRole: kiosk\nShowStatusWindow: true\n");
+
+        var config = _service.LoadConfig(_testConfigPath);
+
+        Assert.False(config.ShowNotifications);
+        Assert.True(config.ShowStatusWindow);
+        Assert.False(config.RespectFocusAssist);
+        Assert.Equal("immediate", config.RestartPolicy);
+        Assert.True(config.SkipSelfService);
+    }
+
+    [Fact]
+    public void ValidateConfig_UnknownMachineRole_ReturnsError()
+    {
+        var config = _service.GetDefaultConfig();
+        config.MachineRole = "desktop";
+
+        var errors = _service.ValidateConfig(config);
+
+        Assert.Contains(errors, e => e.Contains("MachineRole"));
+    }
+
+    [Fact]
+    public void ValidateConfig_AllowedDownloadOriginNotHttp_ReturnsError()
+    {
+        var config = _service.GetDefaultConfig();
+        config.AllowedDownloadOrigins = ["https://cdn.example.com", "cdn.example.com"];
+
+        var errors = _service.ValidateConfig(config);
+
+        Assert.Single(errors, e => e.Contains("AllowedDownloadOrigins entry 'cdn.example.com'"));
+        Assert.DoesNotContain(errors, e => e.Contains("'https://cdn.example.com'"));
+    }
+
+    [Fact]
+    public void TryLoadStableConfig_KioskRespectingFocusAssist_ReturnsValidationError()
+    {
+        File.WriteAllText(_testConfigPath,
+            "SoftwareRepoURL: https://repo.example.com\nMachineRole: kiosk\nRespectFocusAssist: true\n");
+
+        var ok = _service.TryLoadStableConfig(_testConfigPath, out _, out var error);
+
+        Assert.False(ok);
+        Assert.Contains("kiosk", error);
+    }
+
+    [Fact]
+    public void ValidateConfig_ServerImmediateRestartWithoutWindows_ReturnsError()
+    {
+        var config = _service.GetDefaultConfig();
+        config.MachineRole = "server";
+        config.RestartPolicy = "immediate";
+
+        Assert.Contains(_service.ValidateConfig(config), e => e.Contains("MaintenanceWindows"));
+
+        config.MaintenanceWindows.Add(new MaintenanceWindow { Start = "01:00", End = "04:00" });
+        Assert.Empty(_service.ValidateConfig(config));
+    }
+
+    [Fact]
+    public void ConfigSnapshot_DetectsEditsAfterItWasTaken()
+    {
+        File.WriteAllText(_testConfigPath, "SoftwareRepoURL: https://a\n");
+        var snapshot = ConfigSnapshot.Take(_testConfigPath);
+
+        Assert.False(snapshot.HasChanged());
+
+        File.WriteAllText(_testConfigPath, "SoftwareRepoURL: https://b\n");
+
+        Assert.True(snapshot.HasChanged());
+    }
+
+    [Fact]
+    public void ConfigSnapshot_MissingFile_ChangesWhenCreated()
+    {
+        var snapshot = ConfigSnapshot.Take(_testConfigPath);
+        Assert.Null(snapshot.Hash);
+
+        File.WriteAllText(_testConfigPath, "SoftwareRepoURL: https://a\n");
+
+        Assert.True(snapshot.HasChanged());
+    }
+
+    #endregion
 }
@@ -0,0 +1,79 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for ConsoleProgressDisplay - result lines, passthrough lines and download detail.
+/// </summary>
+public class ConsoleProgressDisplayTests
+{
+    [Fact]
+    public void ItemStatus_Installed_PrintsResultLine()
+    {
+        var output = new StringWriter();
+        using var display = new ConsoleProgressDisplay(output);
+
+        display.ItemStatus("Zoom", "installing", null);
+        display.ItemStatus("Zoom", "installed", null);
+
+        Assert.Contains("+ Zoom  installed", output.ToString());
+    }
+
+    [Fact]
+    public void ItemStatus_Failed_PrintsResultLineWithReason()
+    {
+        var output = new StringWriter();
+        using var display = new ConsoleProgressDisplay(output);
+
+        display.ItemStatus("Chrome", "failed", "exit code 1603");
+
+        Assert.Contains("x Chrome  failed: exit code 1603", output.ToString());
+    }
+
+    [Fact]
+    public void ItemProgress_AfterFinish_IsIgnored()
+    {
+        var output = new StringWriter();
+        using var display = new ConsoleProgressDisplay(output);
+        display.ItemStatus("Zoom", "installed", null);
+        var before = output.ToString();
+
+        display.ItemProgress("Zoom", "installing", 0, null, null, null, "Running msi installer");
+
+        Assert.Equal(before, output.ToString());
+    }
+
+    [Fact]
+    public void WriteLine_PrintsLine()
+    {
+        var output = new StringWriter();
+        using var display = new ConsoleProgressDisplay(output);
+
+        display.WriteLine("[WARN] Catalog Testing not found");
+
+        Assert.Contains("[WARN] Catalog Testing not found", output.ToString());
+    }
+
+    [Fact]
+    public void Dispose_PrintsFinalStatus()
+    {
+        var output = new StringWriter();
+        var display = new ConsoleProgressDisplay(output);
+        display.Status("Done");
+
+        display.Dispose();
+
+        Assert.EndsWith("Done" + Environment.NewLine, output.ToString());
+    }
+
+    [Theory]
+    [InlineData(1_048_576L, 10_485_760L, 42, "1.0/10.0 MB, 42s left")]
+    [InlineData(1_048_576L, 10_485_760L, 125, "1.0/10.0 MB, 2m 5s left")]
+    [InlineData(null, null, 30, "30s left")]
+    [InlineData(null, null, null, "")]
+    public void DescribeDownload_FormatsSizeAndEta(long? bytes, long? totalBytes, int? etaSeconds, string expected)
+    {
+        Assert.Equal(expected, ConsoleProgressDisplay.DescribeDownload(bytes, totalBytes, etaSeconds));
+    }
+}
@@ -0,0 +1,80 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for DeferralService - counting user deferrals and deciding when to force.
+/// </summary>
+public class DeferralServiceTests : IDisposable
+{
+    private readonly string _testDir;
+    private readonly string _path;
+
+    public DeferralServiceTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "Deferrals", Guid.NewGuid().ToString());
+        _path = Path.Combine(_testDir, "deferrals.json");
+    }
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    private static CatalogItem MakeItem(string version = "2.0", int? maxDeferrals = 2, DateTime? deadline = null) => new()
+    {
+        Name = "Zoom",
+        Version = version,
+        MaxDeferrals = maxDeferrals,
+        ForceInstallAfterDate = deadline
+    };
+
+    [Fact]
+    public void ShouldForce_AfterMaxDeferrals_SurvivesReload()
+    {
+        var now = new DateTime(2026, 10, 1, 9, 0, 0);
+        var service = new DeferralService(_path);
+        service.RecordDeferral(MakeItem(), "blocking applications running: zoom.exe", now);
+        Assert.False(service.ShouldForce(MakeItem(), now, out _));
+        service.RecordDeferral(MakeItem(), "user active", now.AddHours(1));
+        service.Save();
+
+        var reloaded = new DeferralService(_path);
+        Assert.True(reloaded.ShouldForce(MakeItem(), now.AddHours(2), out var reason));
+        Assert.Contains("2 deferrals", reason);
+        Assert.Equal(2, reloaded.Get(MakeItem())!.Count);
+    }
+
+    [Fact]
+    public void ShouldForce_PassedDeadline()
+    {
+        var item = MakeItem(maxDeferrals: null, deadline: new DateTime(2026, 10, 1));
+        var service = new DeferralService(_path);
+
+        Assert.False(service.ShouldForce(item, new DateTime(2026, 9, 30), out _));
+        Assert.True(service.ShouldForce(item, new DateTime(2026, 10, 2), out var reason));
+        Assert.Contains("2026-10-01", reason);
+    }
+
+    [Fact]
+    public void NewVersion_StartsCountOver()
+    {
+        var now = DateTime.Now;
+        var service = new DeferralService(_path);
+        service.RecordDeferral(MakeItem("2.0"), "user active", now);
+        service.RecordDeferral(MakeItem("2.0"), "user active", now);
+
+        Assert.False(service.ShouldForce(MakeItem("2.1"), now, out _));
+        Assert.Equal(1, service.RecordDeferral(MakeItem("2.1"), "user active", now).Count);
+    }
+
+    [Fact]
+    public void TracksDeferrals_OnlyWithBudgetOrDeadline()
+    {
+        Assert.False(DeferralService.TracksDeferrals(MakeItem(maxDeferrals: null)));
+        Assert.True(DeferralService.TracksDeferrals(MakeItem(maxDeferrals: 3)));
+        Assert.True(DeferralService.TracksDeferrals(MakeItem(maxDeferrals: null, deadline: DateTime.Today)));
+    }
+}
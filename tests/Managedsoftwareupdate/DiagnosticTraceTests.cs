@@ -0,0 +1,59 @@
+using System.Text.Json;
+using Xunit;
+using FluentAssertions;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Coverage for the TraceDiagnostics span recorder: disabled spans must be free
+/// no-ops, and the emitted file must be valid Chrome trace-event JSON so it
+/// opens in chrome://tracing / Perfetto / PerfView without post-processing.
+/// </summary>
+public class DiagnosticTraceTests
+{
+    [Fact]
+    public void Begin_WhenDisabled_ReturnsNoOpSpan()
+    {
+        DiagnosticTrace.Enabled.Should().BeFalse();
+
+        var span = DiagnosticTrace.Begin("download", "Firefox");
+        span.Fail();
+        var act = () => span.Dispose();
+
+        act.Should().NotThrow();
+    }
+
+    [Fact]
+    public void BuildChromeTrace_EmitsCompleteEventsInMicroseconds()
+    {
+        var spans = new[]
+        {
+            ("manifest", "site_default", 0.0, 1500.0, true, 1),
+            ("install", "Chrome", 2000.0, 250.5, false, 4)
+        };
+
+        var json = DiagnosticTrace.BuildChromeTrace(spans, pid: 1234);
+
+        using var doc = JsonDocument.Parse(json);
+        var events = doc.RootElement.GetProperty("traceEvents").EnumerateArray().ToList();
+        events.Should().HaveCount(2);
+
+        events[0].GetProperty("name").GetString().Should().Be("manifest: site_default");
+        events[0].GetProperty("ph").GetString().Should().Be("X");
+        events[0].GetProperty("dur").GetDouble().Should().Be(1_500_000);
+
+        events[1].GetProperty("ts").GetDouble().Should().Be(2_000_000);
+        events[1].GetProperty("tid").GetInt32().Should().Be(4);
+        events[1].GetProperty("args").GetProperty("success").GetBoolean().Should().BeFalse();
+    }
+
+    [Fact]
+    public void BuildChromeTrace_EmptyName_UsesPhaseOnly()
+    {
+        var json = DiagnosticTrace.BuildChromeTrace(new[] { ("catalogs", "", 0.0, 1.0, true, 1) }, pid: 1);
+
+        using var doc = JsonDocument.Parse(json);
+        doc.RootElement.GetProperty("traceEvents")[0].GetProperty("name").GetString().Should().Be("catalogs");
+    }
+}
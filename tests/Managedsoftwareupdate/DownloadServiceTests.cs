@@ -319,4 +319,435 @@ public class DownloadServiceTests : IDisposable
     }
 
     #endregion
+
+    #region PurgeItemCache Tests
+
+    [Fact]
+    public void PurgeItemCache_RemovesInstallerAndPartials_KeepsOtherItems()
+    {
+        var item = new CatalogItem
+        {
+            Name = "App",
+            Category = "Utilities",
+            Installer = new InstallerInfo { Location = "/apps/app-1.0.msi" }
+        };
+        var cached = _service.GetCachePath(item);
+        Directory.CreateDirectory(Path.GetDirectoryName(cached)!);
+        File.WriteAllText(cached, "12345");
+        File.WriteAllText(cached + ".downloading", "12");
+        var other = Path.Combine(Path.GetDirectoryName(cached)!, "other-1.0.msi");
+        File.WriteAllText(other, "content");
+
+        var (count, bytes) = _service.PurgeItemCache(item);
+
+        Assert.Equal(2, count);
+        Assert.Equal(7, bytes);
+        Assert.False(File.Exists(cached));
+        Assert.True(File.Exists(other));
+    }
+
+    [Fact]
+    public void PurgeItemCache_DeltaItem_RemovesBaseAndFull()
+    {
+        var item = new CatalogItem
+        {
+            Name = "App",
+            Installer = new InstallerInfo
+            {
+                Type = "delta",
+                Location = "/apps/app-1.0-to-2.0.patch",
+                BaseLocation = "/apps/app-1.0.msi",
+                FullLocation = "/apps/app-2.0.msi"
+            }
+        };
+        File.WriteAllText(Path.Combine(_testCacheDir, "app-1.0.msi"), "base");
+        File.WriteAllText(Path.Combine(_testCacheDir, "app-2.0.msi"), "full");
+
+        var (count, _) = _service.PurgeItemCache(item);
+
+        Assert.Equal(2, count);
+        Assert.Empty(Directory.GetFiles(_testCacheDir));
+    }
+
+    [Fact]
+    public void PurgeItemCache_NothingCached_ReturnsZero()
+    {
+        var item = new CatalogItem { Name = "App", Installer = new InstallerInfo { Location = "/apps/app.msi" } };
+
+        Assert.Equal((0, 0L), _service.PurgeItemCache(item));
+    }
+
+    #endregion
+
+    #region Parallel Download Tests
+
+    [Fact]
+    public async Task DownloadItemsAsync_RunsUpToMaxConcurrentDownloadsAtOnce()
+    {
+        var handler = new ConcurrencyTrackingHandler();
+        var config = new CimianConfig
+        {
+            CachePath = _testCacheDir,
+            SoftwareRepoURL = "https://test.example.com/repo",
+            MaxConcurrentDownloads = 3
+        };
+        var service = new DownloadService(config, new HttpClient(handler));
+        var items = Enumerable.Range(1, 6).Select(i => new CatalogItem
+        {
+            Name = $"App{i}",
+            Installer = new InstallerInfo { Location = $"apps/app{i}.msi" }
+        }).ToList();
+
+        var started = new List<string>();
+        var progress = new SynchronousProgress<(string ItemName, double Percent)>(p =>
+        {
+            if (p.Percent <= 0) lock (started) started.Add(p.ItemName);
+        });
+
+        var result = await service.DownloadItemsAsync(items, progress);
+
+        Assert.Equal(6, result.Count);
+        Assert.Equal(3, handler.MaxObserved);
+        Assert.Equal(6, started.Count);
+    }
+
+    [Fact]
+    public async Task DownloadItemsAsync_MaxConcurrentDownloadsOne_IsSequential()
+    {
+        var handler = new ConcurrencyTrackingHandler();
+        var config = new CimianConfig
+        {
+            CachePath = _testCacheDir,
+            SoftwareRepoURL = "https://test.example.com/repo",
+            MaxConcurrentDownloads = 1
+        };
+        var service = new DownloadService(config, new HttpClient(handler));
+        var items = Enumerable.Range(1, 3).Select(i => new CatalogItem
+        {
+            Name = $"App{i}",
+            Installer = new InstallerInfo { Location = $"apps/app{i}.msi" }
+        }).ToList();
+
+        await service.DownloadItemsAsync(items);
+
+        Assert.Equal(1, handler.MaxObserved);
+    }
+
+    [Fact]
+    public void BandwidthThrottle_DelaysOnceBudgetIsSpent()
+    {
+        var throttle = new BandwidthThrottle(1000);
+
+        Assert.Equal(TimeSpan.Zero, throttle.Reserve(1000));
+        var delay = throttle.Reserve(500);
+
+        Assert.InRange(delay.TotalMilliseconds, 400, 500);
+    }
+
+    [Fact]
+    public void BandwidthThrottle_ZeroLimit_IsUnlimited()
+    {
+        Assert.Null(BandwidthThrottle.FromKilobytesPerSecond(0));
+        Assert.Equal(2048, BandwidthThrottle.FromKilobytesPerSecond(2)!.BytesPerSecond);
+    }
+
+    [Fact]
+    public void BandwidthThrottle_ScheduleWindowOverridesDefaultRate()
+    {
+        var config = new CimianConfig
+        {
+            MaxDownloadRateKBps = 0,
+            DownloadRateSchedule =
+            [
+                new DownloadRateWindow { Days = ["Mon", "Tue", "Wed", "Thu", "Fri"], Start = "08:00", End = "17:00", TimeZone = "UTC", MaxDownloadRateKBps = 512 }
+            ]
+        };
+
+        // Wednesday 2026-10-14
+        Assert.Equal(512, BandwidthThrottle.KilobytesPerSecondAt(config, new DateTime(2026, 10, 14, 10, 0, 0, DateTimeKind.Utc)));
+        Assert.Equal(0, BandwidthThrottle.KilobytesPerSecondAt(config, new DateTime(2026, 10, 14, 18, 0, 0, DateTimeKind.Utc)));
+        Assert.Equal(0, BandwidthThrottle.KilobytesPerSecondAt(config, new DateTime(2026, 10, 17, 10, 0, 0, DateTimeKind.Utc)));
+        Assert.Equal(512 * 1024, BandwidthThrottle.LowestBytesPerSecond(config));
+    }
+
+    [Fact]
+    public void BandwidthThrottle_OlderSettingNameStillCaps()
+    {
+        var config = new CimianConfig { DownloadBandwidthLimitKBps = 256 };
+
+        Assert.Equal(256 * 1024, BandwidthThrottle.FromConfig(config)!.BytesPerSecond);
+        Assert.Equal(1024 * 1024, BandwidthThrottle.FromConfig(new CimianConfig { MaxDownloadRateKBps = 1024, DownloadBandwidthLimitKBps = 256 })!.BytesPerSecond);
+        Assert.Null(BandwidthThrottle.FromConfig(new CimianConfig()));
+    }
+
+    [Fact]
+    public void BandwidthThrottle_UnlimitedStretchDoesNotDelay()
+    {
+        var throttle = new BandwidthThrottle(() => 0);
+
+        Assert.Equal(TimeSpan.Zero, throttle.Reserve(10_000_000));
+        Assert.Equal(0, throttle.BytesPerSecond);
+    }
+
+    #region Origin Allowlist Tests
+
+    [Fact]
+    public async Task DownloadItemAsync_OriginNotAllowed_RefusesWithoutRequest()
+    {
+        var handler = new ConcurrencyTrackingHandler();
+        var config = new CimianConfig
+        {
+            CachePath = _testCacheDir,
+            SoftwareRepoURL = "https://test.example.com/repo",
+            AllowedDownloadOrigins = ["https://cdn.example.com"]
+        };
+        var service = new DownloadService(config, new HttpClient(handler));
+        var rejected = new List<string>();
+        service.OriginRejected += (item, url) => rejected.Add($"{item.Name} {url}");
+
+        var path = await service.DownloadItemAsync(new CatalogItem
+        {
+            Name = "App",
+            Installer = new InstallerInfo { Location = "https://evil.example.net/app.msi" }
+        });
+
+        Assert.Null(path);
+        Assert.Equal(0, handler.MaxObserved);
+        Assert.Equal(new[] { "App https://evil.example.net/app.msi" }, rejected);
+    }
+
+    [Theory]
+    [InlineData("https://cdn.example.com/apps/app.msi", true)]
+    [InlineData("https://CDN.example.com:443/apps/app.msi", true)]
+    [InlineData("https://test.example.com/repo/pkgs/app.msi", true)]
+    [InlineData("http://cdn.example.com/apps/app.msi", false)]
+    [InlineData("https://cdn.example.com.evil.net/app.msi", false)]
+    [InlineData("ftp://cdn.example.com/app.msi", false)]
+    public void IsOriginAllowed_MatchesSchemeHostAndPort(string url, bool expected)
+    {
+        var config = new CimianConfig
+        {
+            CachePath = _testCacheDir,
+            SoftwareRepoURL = "https://test.example.com/repo",
+            AllowedDownloadOrigins = ["https://cdn.example.com/"]
+        };
+
+        Assert.Equal(expected, new DownloadService(config).IsOriginAllowed(url));
+    }
+
+    [Fact]
+    public void IsOriginAllowed_NoAllowlist_AllowsAnything()
+    {
+        Assert.True(_service.IsOriginAllowed("https://anywhere.example.org/app.msi"));
+    }
+
+    #endregion
+
+    private sealed class SynchronousProgress<T> : IProgress<T>
+    {
+        private readonly Action<T> _handler;
+        public SynchronousProgress(Action<T> handler) => _handler = handler;
+        public void Report(T value) => _handler(value);
+    }
+
+    /// <summary>
+    /// Serves a small body for every GET after a short delay, recording the
+    /// highest number of requests in flight at once.
+    /// </summary>
+    private sealed class ConcurrencyTrackingHandler : HttpMessageHandler
+    {
+        private int _inFlight;
+        public int MaxObserved { get; private set; }
+
+        protected override async Task<HttpResponseMessage> SendAsync(HttpRequestMessage request, CancellationToken cancellationToken)
+        {
+            if (request.Method == HttpMethod.Head)
+            {
+                return new HttpResponseMessage(System.Net.HttpStatusCode.OK) { Content = new ByteArrayContent(new byte[16]) };
+            }
+
+            var now = Interlocked.Increment(ref _inFlight);
+            lock (this) MaxObserved = Math.Max(MaxObserved, now);
+            await Task.Delay(100, cancellationToken);
+            Interlocked.Decrement(ref _inFlight);
+
+            return new HttpResponseMessage(System.Net.HttpStatusCode.OK) { Content = new ByteArrayContent(new byte[16]) };
+        }
+    }
+
+    #endregion
+
+    #region Delta Installer Tests
+
+    [Fact]
+    public void GetCachePath_DeltaItem_ResolvesToFullInstaller()
+    {
+        var item = new CatalogItem
+        {
+            Name = "BigApp",
+            Installer = new InstallerInfo
+            {
+                Type = "delta",
+                Location = "apps/bigapp/BigApp-2.0-from-1.0.msdelta",
+                FullLocation = "apps/bigapp/BigApp-2.0.msi"
+            }
+        };
+
+        Assert.EndsWith("BigApp-2.0.msi", _service.GetCachePath(item));
+    }
+
+    [Fact]
+    public async Task DownloadItemAsync_DeltaWithoutCachedBase_FallsBackToFullInstaller()
+    {
+        var fullBytes = new byte[] { 1, 2, 3, 4 };
+        var handler = new UrlRecordingHandler(fullBytes);
+        var service = new DownloadService(_testConfig, new HttpClient(handler));
+        var item = new CatalogItem
+        {
+            Name = "BigApp",
+            Installer = new InstallerInfo
+            {
+                Type = "delta",
+                Location = "apps/bigapp/BigApp-2.0-from-1.0.msdelta",
+                BaseLocation = "apps/bigapp/BigApp-1.0.msi",
+                FullLocation = "apps/bigapp/BigApp-2.0.msi",
+                FullHash = Sha256(fullBytes)
+            }
+        };
+
+        var path = await service.DownloadItemAsync(item);
+
+        Assert.NotNull(path);
+        Assert.EndsWith("BigApp-2.0.msi", path);
+        Assert.DoesNotContain(handler.RequestedUrls, u => u.EndsWith(".msdelta"));
+        Assert.Contains(handler.RequestedUrls, u => u.EndsWith("BigApp-2.0.msi"));
+    }
+
+    [Fact]
+    public async Task DownloadItemAsync_DeltaWithMismatchedBase_SkipsPatch()
+    {
+        File.WriteAllText(Path.Combine(_testCacheDir, "BigApp-1.0.msi"), "not the base the patch was built from");
+        var fullBytes = new byte[] { 9, 9 };
+        var handler = new UrlRecordingHandler(fullBytes);
+        var service = new DownloadService(_testConfig, new HttpClient(handler));
+        var item = new CatalogItem
+        {
+            Name = "BigApp",
+            Installer = new InstallerInfo
+            {
+                Type = "delta",
+                Location = "apps/bigapp/BigApp-2.0-from-1.0.msdelta",
+                BaseLocation = "apps/bigapp/BigApp-1.0.msi",
+                BaseHash = new string('a', 64),
+                FullLocation = "apps/bigapp/BigApp-2.0.msi"
+            }
+        };
+
+        var path = await service.DownloadItemAsync(item);
+
+        Assert.NotNull(path);
+        Assert.DoesNotContain(handler.RequestedUrls, u => u.EndsWith(".msdelta"));
+    }
+
+    private static string Sha256(byte[] data) =>
+        Convert.ToHexString(System.Security.Cryptography.SHA256.HashData(data)).ToLowerInvariant();
+
+    /// <summary>
+    /// Serves the same body for every request and records the URLs asked for.
+    /// </summary>
+    private sealed class UrlRecordingHandler : HttpMessageHandler
+    {
+        private readonly byte[] _body;
+        public List<string> RequestedUrls { get; } = new();
+        public int GetCount { get; private set; }
+
+        public UrlRecordingHandler(byte[] body) => _body = body;
+
+        protected override Task<HttpResponseMessage> SendAsync(HttpRequestMessage request, CancellationToken cancellationToken)
+        {
+            lock (RequestedUrls)
+            {
+                RequestedUrls.Add(request.RequestUri!.ToString());
+                if (request.Method == HttpMethod.Get) GetCount++;
+            }
+            return Task.FromResult(new HttpResponseMessage(System.Net.HttpStatusCode.OK) { Content = new ByteArrayContent(_body) });
+        }
+    }
+
+    #endregion
+
+    #region Hash Validation Tests
+
+    [Fact]
+    public async Task DownloadFileAsync_HashMismatch_RedownloadsOnceThenFails()
+    {
+        var handler = new UrlRecordingHandler(new byte[] { 1, 2, 3 });
+        var service = new DownloadService(_testConfig, new HttpClient(handler));
+        var localPath = Path.Combine(_testCacheDir, "app.msi");
+
+        var ok = await service.DownloadFileAsync("https://test.example.com/repo/pkgs/app.msi", localPath, new string('0', 64));
+
+        Assert.False(ok);
+        Assert.Equal(2, handler.GetCount);
+        Assert.False(File.Exists(localPath));
+    }
+
+    [Fact]
+    public void VerifyPayload_MatchingHash_IsValid()
+    {
+        var bytes = new byte[] { 4, 5, 6 };
+        var path = Path.Combine(_testCacheDir, "app.msi");
+        File.WriteAllBytes(path, bytes);
+        var item = new CatalogItem { Name = "App", Installer = new InstallerInfo { Location = "/app.msi", Hash = Sha256(bytes).ToUpperInvariant() } };
+
+        var result = DownloadService.VerifyPayload(item, path, requireHash: true);
+
+        Assert.Equal(PayloadVerificationStatus.Valid, result.Status);
+        Assert.True(result.CanInstall);
+    }
+
+    [Fact]
+    public void VerifyPayload_TamperedFile_IsMismatch()
+    {
+        var path = Path.Combine(_testCacheDir, "app.msi");
+        File.WriteAllBytes(path, new byte[] { 4, 5, 6 });
+        var item = new CatalogItem { Name = "App", Installer = new InstallerInfo { Location = "/app.msi", Hash = Sha256(new byte[] { 7 }) } };
+
+        var result = DownloadService.VerifyPayload(item, path, requireHash: false);
+
+        Assert.Equal(PayloadVerificationStatus.Mismatch, result.Status);
+        Assert.False(result.CanInstall);
+    }
+
+    [Theory]
+    [InlineData(true, PayloadVerificationStatus.MissingHash, false)]
+    [InlineData(false, PayloadVerificationStatus.NotChecked, true)]
+    public void VerifyPayload_NoCatalogHash_FollowsRequireHashValidation(bool requireHash, PayloadVerificationStatus expected, bool canInstall)
+    {
+        var path = Path.Combine(_testCacheDir, "app.msi");
+        File.WriteAllText(path, "payload");
+        var item = new CatalogItem { Name = "App", Installer = new InstallerInfo { Location = "/app.msi" } };
+
+        var result = DownloadService.VerifyPayload(item, path, requireHash);
+
+        Assert.Equal(expected, result.Status);
+        Assert.Equal(canInstall, result.CanInstall);
+    }
+
+    [Fact]
+    public void VerifyPayload_DeltaItem_ChecksFullHash()
+    {
+        var bytes = new byte[] { 8, 8 };
+        var path = Path.Combine(_testCacheDir, "BigApp-2.0.msi");
+        File.WriteAllBytes(path, bytes);
+        var item = new CatalogItem
+        {
+            Name = "BigApp",
+            Installer = new InstallerInfo { Type = "delta", Location = "/p.msdelta", Hash = Sha256(new byte[] { 1 }), FullHash = Sha256(bytes) }
+        };
+
+        Assert.Equal(PayloadVerificationStatus.Valid, DownloadService.VerifyPayload(item, path, requireHash: true).Status);
+    }
+
+    #endregion
 }
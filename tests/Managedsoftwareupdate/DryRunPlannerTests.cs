@@ -0,0 +1,122 @@
+using System.Text.Json;
+using Xunit;
+using FluentAssertions;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Coverage for the --dry-run plan: dependency ordering, download URL / installer
+/// type computation, uninstall method selection, and warnings that make CI fail.
+/// </summary>
+public class DryRunPlannerTests
+{
+    private readonly CimianConfig _config = new()
+    {
+        CachePath = Path.Combine(Path.GetTempPath(), "CimianTests", "DryRun"),
+        SoftwareRepoURL = "https://repo.example.com",
+        ClientIdentifier = "lab/site_default",
+        Catalogs = new List<string> { "Production" }
+    };
+
+    private DryRunPlanner CreatePlanner() => new(_config, new DownloadService(_config));
+
+    [Fact]
+    public void OrderByDependencies_PlacesScheduledRequiresFirst()
+    {
+        var app = new CatalogItem { Name = "App", Version = "2.0", Requires = ["Runtime-1.0"] };
+        var runtime = new CatalogItem { Name = "Runtime", Version = "1.0" };
+        var other = new CatalogItem { Name = "Other", Version = "1.0", Requires = ["NotScheduled"] };
+
+        var ordered = DryRunPlanner.OrderByDependencies([app, other, runtime]);
+
+        ordered.Select(i => i.Name).Should().Equal("Runtime", "App", "Other");
+    }
+
+    [Fact]
+    public void OrderByDependencies_CycleDoesNotLoop()
+    {
+        var a = new CatalogItem { Name = "A", Requires = ["B"] };
+        var b = new CatalogItem { Name = "B", Requires = ["A"] };
+
+        DryRunPlanner.OrderByDependencies([a, b]).Should().HaveCount(2);
+    }
+
+    [Fact]
+    public void Build_InstallAction_ComputesUrlTypeAndScripts()
+    {
+        var item = new CatalogItem
+        {
+            Name = "Firefox",
+            Version = "130.0",
+            Installer = new InstallerInfo { Location = "/apps/Firefox-130.0.msi", Hash = "abc" },
+            PreinstallScript = "Write-Output pre",
+            PostinstallScript = "Write-Output post",
+            BlockingApps = ["firefox.exe"]
+        };
+        var catalog = new Dictionary<string, CatalogItem>(StringComparer.OrdinalIgnoreCase) { ["firefox"] = item };
+
+        var plan = CreatePlanner().Build(1, [item], [], [], catalog, []);
+
+        var action = plan.Actions.Should().ContainSingle().Subject;
+        action.Order.Should().Be(1);
+        action.Action.Should().Be("install");
+        action.InstallerType.Should().Be("msi");
+        action.DownloadUrl.Should().Be("https://repo.example.com/pkgs/apps/Firefox-130.0.msi");
+        action.Scripts.Should().Equal("preinstall_script", "postinstall_script");
+        action.BlockingApplications.Should().Equal("firefox.exe");
+        action.Warnings.Should().BeEmpty();
+    }
+
+    [Fact]
+    public void Build_MissingHashAndUnknownRequirement_AreWarnings()
+    {
+        var item = new CatalogItem
+        {
+            Name = "Tool",
+            Version = "1.0",
+            Requires = ["Ghost"],
+            Installer = new InstallerInfo { Location = "/apps/tool.exe" }
+        };
+
+        var plan = CreatePlanner().Build(1, [], [item], [], new Dictionary<string, CatalogItem>(), []);
+
+        var action = plan.Actions.Single();
+        action.Action.Should().Be("update");
+        action.Warnings.Should().HaveCount(2);
+    }
+
+    [Fact]
+    public void Build_Uninstall_ReportsMethodAndDeferrals()
+    {
+        var remove = new CatalogItem
+        {
+            Name = "OldAgent",
+            Version = "1.0",
+            Installs = [new InstallCheckItem { Type = "msi", ProductCode = "{11111111-2222-3333-4444-555555555555}" }]
+        };
+        var deferred = new CatalogItem { Name = "Zoom", Version = "6.0" };
+
+        var plan = CreatePlanner().Build(2, [], [], [remove], new Dictionary<string, CatalogItem>(),
+            [(deferred, "blocking applications running: zoom.exe")]);
+
+        plan.Actions.Single().UninstallMethod.Should().Be("msi_product_code");
+        plan.Deferred.Should().ContainSingle(d => d.Name == "Zoom" && d.Reason.Contains("zoom.exe"));
+
+        using var doc = JsonDocument.Parse(DryRunPlanner.ToJson(plan));
+        doc.RootElement.GetProperty("actions")[0].GetProperty("uninstall_method").GetString().Should().Be("msi_product_code");
+        doc.RootElement.GetProperty("client_identifier").GetString().Should().Be("lab/site_default");
+    }
+
+    [Theory]
+    [InlineData("exe", "registry_uninstall_string")]
+    [InlineData("nupkg", "nupkg_package_folder")]
+    [InlineData("pkg", "none")]
+    public void DescribeUninstallMethod_FallsBackByInstallerType(string installerType, string expected)
+    {
+        var item = new CatalogItem { Name = "X", Installer = new InstallerInfo { Type = installerType } };
+
+        InstallerService.DescribeUninstallMethod(item).Should().Be(expected);
+    }
+}
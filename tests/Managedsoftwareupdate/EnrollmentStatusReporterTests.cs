@@ -0,0 +1,78 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+using Cimian.Core.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for EnrollmentStatusReporter - what the Enrollment Status Page is told about each core app.
+/// </summary>
+public class EnrollmentStatusReporterTests
+{
+    [Fact]
+    public void TrackedItems_UsesConfiguredItems()
+    {
+        var config = new CimianConfig { EspTrackedItems = ["Firefox", " Chrome ", "firefox"] };
+        var progress = new BootstrapProgress { CompletedItems = ["Zoom"] };
+
+        Assert.Equal(["Firefox", "Chrome"], EnrollmentStatusReporter.TrackedItems(config, progress));
+    }
+
+    [Fact]
+    public void TrackedItems_DefaultsToBootstrapItems()
+    {
+        var progress = new BootstrapProgress { CompletedItems = ["Zoom"], Remaining = ["Office"] };
+
+        Assert.Equal(["Zoom", "Office"], EnrollmentStatusReporter.TrackedItems(new CimianConfig(), progress));
+    }
+
+    [Fact]
+    public void StateOf_NotInstalledBeforeFirstAttempt()
+    {
+        var progress = new BootstrapProgress();
+
+        Assert.Equal(EspInstallationState.NotInstalled, EnrollmentStatusReporter.StateOf("Firefox", progress));
+    }
+
+    [Fact]
+    public void StateOf_InstalledItemsCompleteWhileOthersAreInProgress()
+    {
+        var progress = new BootstrapProgress { Attempts = 1, CompletedItems = ["Firefox"], Remaining = ["Office"] };
+
+        Assert.Equal(EspInstallationState.Completed, EnrollmentStatusReporter.StateOf("firefox", progress));
+        Assert.Equal(EspInstallationState.InProgress, EnrollmentStatusReporter.StateOf("Office", progress));
+    }
+
+    [Fact]
+    public void StateOf_CompletedBootstrapCompletesEveryItem()
+    {
+        var progress = new BootstrapProgress { Attempts = 1, Status = BootstrapProgress.Completed };
+
+        Assert.Equal(EspInstallationState.Completed, EnrollmentStatusReporter.StateOf("AlreadyInstalled", progress));
+    }
+
+    [Fact]
+    public void StateOf_ExhaustedBootstrapFailsItemsNotInstalled()
+    {
+        var progress = new BootstrapProgress
+        {
+            Attempts = 5,
+            Status = BootstrapProgress.Exhausted,
+            CompletedItems = ["Firefox"],
+            Remaining = ["Office"]
+        };
+
+        Assert.Equal(EspInstallationState.Completed, EnrollmentStatusReporter.StateOf("Firefox", progress));
+        Assert.Equal(EspInstallationState.Error, EnrollmentStatusReporter.StateOf("Office", progress));
+    }
+
+    [Theory]
+    [InlineData("Firefox", "Win32App_Cimian_Firefox_1")]
+    [InlineData("Microsoft Office 365", "Win32App_Cimian_Microsoft_Office_365_1")]
+    [InlineData(@"Tools\7-Zip.x64", "Win32App_Cimian_Tools_7-Zip.x64_1")]
+    public void TrackingKeyName_IsRegistrySafe(string item, string expected)
+    {
+        Assert.Equal(expected, EnrollmentStatusReporter.TrackingKeyName(item));
+    }
+}
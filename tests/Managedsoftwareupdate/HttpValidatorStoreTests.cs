@@ -0,0 +1,116 @@
+using System.Net;
+using System.Net.Http.Headers;
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for HttpValidatorStore and the conditional catalog downloads built on it.
+/// </summary>
+public class HttpValidatorStoreTests : IDisposable
+{
+    private const string Repo = "https://repo.example.com";
+    private const string Catalog = """
+        generation: 100
+        items:
+          - name: Chrome
+            version: "130.0"
+        """;
+
+    private readonly string _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "HttpValidators", Guid.NewGuid().ToString());
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    private CatalogService CreateCatalogService(CimianConfig config, StubHandler handler) =>
+        new(config, new HttpClient(handler), new CatalogGenerationGuard(Path.Combine(_testDir, "generations.json"), allowDowngrade: false));
+
+    [Fact]
+    public void ApplyTo_SendsValidatorsOnlyWhileLocalCopyExists()
+    {
+        var localPath = Path.Combine(_testDir, "Production.yaml");
+        var url = $"{Repo}/catalogs/Production.yaml";
+        var response = new HttpResponseMessage(HttpStatusCode.OK) { Content = new StringContent("x") };
+        response.Headers.ETag = new EntityTagHeaderValue("\"v1\"");
+        response.Content.Headers.LastModified = new DateTimeOffset(2026, 10, 1, 0, 0, 0, TimeSpan.Zero);
+        new HttpValidatorStore(_testDir).Record(url, response);
+
+        var withoutFile = new HttpRequestMessage(HttpMethod.Get, url);
+        new HttpValidatorStore(_testDir).ApplyTo(withoutFile, localPath);
+        File.WriteAllText(localPath, "x");
+        var withFile = new HttpRequestMessage(HttpMethod.Get, url);
+        new HttpValidatorStore(_testDir).ApplyTo(withFile, localPath);
+
+        Assert.Empty(withoutFile.Headers.IfNoneMatch);
+        Assert.Equal("\"v1\"", withFile.Headers.IfNoneMatch.Single().ToString());
+        Assert.Equal(response.Content.Headers.LastModified, withFile.Headers.IfModifiedSince);
+    }
+
+    [Fact]
+    public async Task DownloadCatalogAsync_NotModified_UsesLocalCopy()
+    {
+        var config = new CimianConfig { SoftwareRepoURL = Repo, CatalogsPath = Path.Combine(_testDir, "catalogs") };
+        var handler = new StubHandler(Catalog, "\"gen100\"");
+
+        await CreateCatalogService(config, handler).DownloadCatalogAsync("Production");
+        var items = await CreateCatalogService(config, handler).DownloadCatalogAsync("Production");
+
+        Assert.Equal(new[] { HttpStatusCode.OK, HttpStatusCode.NotModified }, handler.Statuses);
+        Assert.Equal("Chrome", Assert.Single(items).Name);
+    }
+
+    [Fact]
+    public async Task LoadCatalogsAsync_RemovesCatalogsNoLongerAssigned()
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = Repo,
+            CatalogsPath = Path.Combine(_testDir, "catalogs"),
+            Catalogs = ["Production"]
+        };
+        Directory.CreateDirectory(config.CatalogsPath);
+        File.WriteAllText(Path.Combine(config.CatalogsPath, "Testing.yaml"), Catalog);
+
+        await CreateCatalogService(config, new StubHandler(Catalog, null)).LoadCatalogsAsync();
+
+        Assert.True(File.Exists(Path.Combine(config.CatalogsPath, "Production.yaml")));
+        Assert.False(File.Exists(Path.Combine(config.CatalogsPath, "Testing.yaml")));
+    }
+
+    /// <summary>
+    /// Serves <c>body</c> tagged with <c>etag</c>, or 304 when If-None-Match carries it.
+    /// </summary>
+    private sealed class StubHandler : HttpMessageHandler
+    {
+        private readonly string _body;
+        private readonly string? _etag;
+        public List<HttpStatusCode> Statuses { get; } = new();
+
+        public StubHandler(string body, string? etag)
+        {
+            _body = body;
+            _etag = etag;
+        }
+
+        protected override Task<HttpResponseMessage> SendAsync(HttpRequestMessage request, CancellationToken cancellationToken)
+        {
+            if (_etag != null && request.Headers.IfNoneMatch.Any(e => e.ToString() == _etag))
+            {
+                Statuses.Add(HttpStatusCode.NotModified);
+                return Task.FromResult(new HttpResponseMessage(HttpStatusCode.NotModified));
+            }
+
+            Statuses.Add(HttpStatusCode.OK);
+            var response = new HttpResponseMessage(HttpStatusCode.OK) { Content = new StringContent(_body) };
+            if (_etag != null)
+            {
+                response.Headers.ETag = new EntityTagHeaderValue(_etag);
+            }
+            return Task.FromResult(response);
+        }
+    }
+}
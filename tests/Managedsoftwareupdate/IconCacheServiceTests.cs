@@ -0,0 +1,142 @@
+using System.Net;
+using System.Security.Cryptography;
+using System.Text;
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+using Cimian.Core.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for IconCacheService - keeping the local icon cache in step with the repo's icons/ folder.
+/// </summary>
+public class IconCacheServiceTests : IDisposable
+{
+    private const string Repo = "https://repo.example.com";
+
+    private readonly string _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "IconCache", Guid.NewGuid().ToString());
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    private IconCacheService CreateService(StubHandler handler) =>
+        new(new CimianConfig { SoftwareRepoURL = Repo }, new HttpClient(handler), _testDir);
+
+    private static string Sha256(string content) =>
+        Convert.ToHexString(SHA256.HashData(Encoding.UTF8.GetBytes(content))).ToLowerInvariant();
+
+    [Fact]
+    public void IconFileName_UsesIconNameOrItemName()
+    {
+        Assert.Equal("Chrome.png", IconCache.IconFileName("Chrome", null));
+        Assert.Equal("browser.png", IconCache.IconFileName("Chrome", "browser"));
+        Assert.Equal("browser.ico", IconCache.IconFileName("Chrome", "browser.ico"));
+    }
+
+    [Fact]
+    public void FindIcon_IgnoresNamesOutsideTheIconsDirectory()
+    {
+        Directory.CreateDirectory(_testDir);
+        File.WriteAllText(Path.Combine(_testDir, "Chrome.jpg"), "jpg");
+
+        Assert.Equal(Path.Combine(_testDir, "Chrome.jpg"), IconCache.FindIcon("Chrome", null, _testDir));
+        Assert.Null(IconCache.ResolveInside(_testDir, Path.Combine("..", "evil.png")));
+    }
+
+    [Fact]
+    public async Task SyncAsync_WithHashIndex_DownloadsOnlyChangedIcons()
+    {
+        Directory.CreateDirectory(_testDir);
+        File.WriteAllText(Path.Combine(_testDir, "Chrome.png"), "chrome-v1");
+        File.WriteAllText(Path.Combine(_testDir, "browser.png"), "zoom-old");
+        var handler = new StubHandler(url => url switch
+        {
+            _ when url.EndsWith(IconCache.HashesFileName) =>
+                (HttpStatusCode.OK, $"Chrome.png: {Sha256("chrome-v1")}\nbrowser.png: {Sha256("zoom-new")}\n"),
+            _ when url.EndsWith("/icons/browser.png") => (HttpStatusCode.OK, "zoom-new"),
+            _ => (HttpStatusCode.NotFound, "")
+        });
+
+        var written = await CreateService(handler).SyncAsync(
+        [
+            new CatalogItem { Name = "Chrome" },
+            new CatalogItem { Name = "Zoom", IconName = "browser.png" },
+            new CatalogItem { Name = "NoIcon" }
+        ]);
+
+        Assert.Equal(1, written);
+        Assert.Equal("zoom-new", File.ReadAllText(Path.Combine(_testDir, "browser.png")));
+        Assert.DoesNotContain(handler.RequestedUrls, u => u.EndsWith("/icons/Chrome.png") || u.Contains("NoIcon"));
+    }
+
+    [Fact]
+    public async Task SyncAsync_HashMismatch_KeepsCachedCopy()
+    {
+        Directory.CreateDirectory(_testDir);
+        File.WriteAllText(Path.Combine(_testDir, "Chrome.png"), "chrome-v1");
+        var handler = new StubHandler(url => url.EndsWith(IconCache.HashesFileName)
+            ? (HttpStatusCode.OK, $"Chrome.png: {Sha256("chrome-v2")}\n")
+            : (HttpStatusCode.OK, "tampered"));
+
+        var written = await CreateService(handler).SyncAsync([new CatalogItem { Name = "Chrome" }]);
+
+        Assert.Equal(0, written);
+        Assert.Equal("chrome-v1", File.ReadAllText(Path.Combine(_testDir, "Chrome.png")));
+    }
+
+    [Fact]
+    public async Task SyncAsync_WithoutHashIndex_SendsRecordedEtag()
+    {
+        var handler = new StubHandler(url => url.EndsWith("/icons/Chrome.png")
+            ? (HttpStatusCode.OK, "chrome")
+            : (HttpStatusCode.NotFound, ""), etag: "\"abc\"");
+        await CreateService(handler).SyncAsync([new CatalogItem { Name = "Chrome" }]);
+
+        var written = await CreateService(handler).SyncAsync([new CatalogItem { Name = "Chrome" }]);
+
+        Assert.Equal(0, written);
+        Assert.Equal("\"abc\"", handler.IfNoneMatch.Last());
+        Assert.Equal("chrome", File.ReadAllText(Path.Combine(_testDir, "Chrome.png")));
+    }
+
+    /// <summary>
+    /// Answers with the responder's status and body, tagging 200s with
+    /// <c>etag</c> and answering 304 when the request's If-None-Match matches it.
+    /// </summary>
+    private sealed class StubHandler : HttpMessageHandler
+    {
+        private readonly Func<string, (HttpStatusCode Status, string Body)> _responder;
+        private readonly string? _etag;
+        public List<string> RequestedUrls { get; } = new();
+        public List<string?> IfNoneMatch { get; } = new();
+
+        public StubHandler(Func<string, (HttpStatusCode, string)> responder, string? etag = null)
+        {
+            _responder = responder;
+            _etag = etag;
+        }
+
+        protected override Task<HttpResponseMessage> SendAsync(HttpRequestMessage request, CancellationToken cancellationToken)
+        {
+            var url = request.RequestUri!.ToString();
+            RequestedUrls.Add(url);
+            var ifNoneMatch = request.Headers.IfNoneMatch.FirstOrDefault()?.ToString();
+            IfNoneMatch.Add(ifNoneMatch);
+
+            var (status, body) = _responder(url);
+            if (status == HttpStatusCode.OK && _etag != null && ifNoneMatch == _etag)
+            {
+                return Task.FromResult(new HttpResponseMessage(HttpStatusCode.NotModified));
+            }
+            var response = new HttpResponseMessage(status) { Content = new StringContent(body) };
+            if (status == HttpStatusCode.OK && _etag != null)
+            {
+                response.Headers.ETag = new System.Net.Http.Headers.EntityTagHeaderValue(_etag);
+            }
+            return Task.FromResult(response);
+        }
+    }
+}
@@ -0,0 +1,111 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for InstalledItemsStore - the local record of what Cimian installed and removed.
+/// </summary>
+public class InstalledItemsStoreTests : IDisposable
+{
+    private readonly string _testDir;
+    private readonly string _path;
+
+    public InstalledItemsStoreTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "InstalledItems", Guid.NewGuid().ToString());
+        _path = Path.Combine(_testDir, "installed_items.json");
+    }
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    private static CatalogItem MakeItem(string name, string version, bool onDemand = false) => new()
+    {
+        Name = name,
+        Version = version,
+        OnDemand = onDemand,
+        Installer = new InstallerInfo { Type = "MSI", Hash = $"{name}-hash" }
+    };
+
+    [Fact]
+    public void RecordInstallAndRemoval_SurviveReload()
+    {
+        var now = new DateTime(2026, 10, 16, 9, 0, 0);
+        var store = new InstalledItemsStore(_path);
+        store.RecordInstall(MakeItem("Chrome", "130.0"), now);
+        store.RecordInstall(MakeItem("Zoom", "6.2"), now);
+        store.RecordRemoval(MakeItem("Zoom", "6.2"), now.AddHours(1));
+
+        var reloaded = new InstalledItemsStore(_path);
+        var chrome = reloaded.Get("chrome")!;
+        Assert.Equal("130.0", chrome.Version);
+        Assert.Equal("Chrome-hash", chrome.InstallerHash);
+        Assert.Equal("msi", chrome.InstallerType);
+        Assert.Equal(now, chrome.InstalledAt);
+
+        var zoom = reloaded.Get("Zoom")!;
+        Assert.Equal(InstalledItemsStore.StatusRemoved, zoom.Status);
+        Assert.Equal(now.AddHours(1), zoom.RemovedAt);
+        Assert.Equal(new[] { "Chrome--130.0" }, reloaded.InstalledItemNames());
+    }
+
+    [Fact]
+    public void InstalledItemNames_SatisfyCheckDependencies()
+    {
+        var store = new InstalledItemsStore(_path);
+        store.RecordInstall(MakeItem("VCRedist", "14.40"), DateTime.Now);
+        var app = new CatalogItem { Name = "App", Requires = ["VCRedist", "DotNetRuntime"] };
+
+        var missing = CatalogService.CheckDependencies(app, store.InstalledItemNames(), []);
+
+        Assert.Equal(new[] { "DotNetRuntime" }, missing);
+    }
+
+    [Fact]
+    public void RecordInstall_OnDemandItem_IsNotRecorded()
+    {
+        var store = new InstalledItemsStore(_path);
+        store.RecordInstall(MakeItem("RepairTool", "1.0", onDemand: true), DateTime.Now);
+
+        Assert.Null(store.Get("RepairTool"));
+        Assert.Empty(store.Installed());
+    }
+
+    [Fact]
+    public void RecordInstall_AsDependency_ClearedByDirectInstallOnly()
+    {
+        var store = new InstalledItemsStore(_path);
+        store.RecordInstall(MakeItem("VCRedist", "14.40"), DateTime.Now, asDependency: true);
+        Assert.True(store.Get("VCRedist")!.InstalledAsDependency);
+
+        store.RecordInstall(MakeItem("VCRedist", "14.42"), DateTime.Now);
+        Assert.False(store.Get("VCRedist")!.InstalledAsDependency);
+
+        // Once asked for directly, an update pulled in by a dependent doesn't demote it
+        store.RecordInstall(MakeItem("VCRedist", "14.44"), DateTime.Now, asDependency: true);
+        Assert.False(new InstalledItemsStore(_path).Get("VCRedist")!.InstalledAsDependency);
+    }
+
+    [Fact]
+    public void ImportReceipts_OnlySeedsANewStore()
+    {
+        var receipts = new List<InstalledItemRecord>
+        {
+            new() { Name = "Chrome", Version = "129.0", Source = "receipt" },
+            new() { Name = "chrome", Version = "128.0", Source = "receipt" }
+        };
+
+        var store = new InstalledItemsStore(_path);
+        Assert.Equal(1, store.ImportReceipts(receipts));
+        Assert.True(store.Exists);
+        Assert.Equal(0, new InstalledItemsStore(_path).ImportReceipts([new() { Name = "Zoom", Version = "6.2" }]));
+
+        var reloaded = new InstalledItemsStore(_path);
+        Assert.Equal("129.0", reloaded.Get("Chrome")!.Version);
+        Assert.Null(reloaded.Get("Zoom"));
+    }
+}
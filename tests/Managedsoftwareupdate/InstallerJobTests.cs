@@ -0,0 +1,111 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for InstallerJob - which processes a timed-out installer left running, and the tree dump.
+/// </summary>
+public class InstallerJobTests
+{
+    private static readonly DateTime Boot = new(2026, 10, 1, 8, 0, 0);
+
+    private static ProcessTreeNode Node(int id, int parentId, string name, int startedMinute = 0, string? commandLine = null) =>
+        new(id, parentId, name, commandLine, Boot.AddMinutes(startedMinute));
+
+    [Fact]
+    public void SelectTree_FollowsChildrenOfTheInstallerAndOrphansInTheJob()
+    {
+        var all = new[]
+        {
+            Node(4, 0, "System"),
+            Node(100, 4, "setup.exe", 10),
+            Node(110, 100, "msiexec.exe", 11),
+            Node(120, 110, "vc_redist.exe", 12),
+            // Its parent exited; only the job knows it belongs to the installer
+            Node(200, 999, "updater.exe", 13),
+            Node(210, 200, "dialog.exe", 14),
+            Node(300, 4, "explorer.exe", 1)
+        };
+
+        var tree = InstallerJob.SelectTree(all, new HashSet<int> { 100, 200 });
+
+        Assert.Equal([100, 200, 110, 210, 120], tree.Select(p => p.Id));
+    }
+
+    [Fact]
+    public void SelectTree_SkipsReusedParentIds()
+    {
+        var all = new[]
+        {
+            Node(100, 4, "setup.exe", 10),
+            // Started before setup.exe: its real parent had PID 100 earlier
+            Node(150, 100, "svchost.exe", 2)
+        };
+
+        Assert.Equal([100], InstallerJob.SelectTree(all, new HashSet<int> { 100 }).Select(p => p.Id));
+    }
+
+    [Fact]
+    public void FormatTree_IndentsChildrenAndTruncatesLongCommandLines()
+    {
+        var nodes = new[]
+        {
+            Node(100, 4, "setup.exe", commandLine: "setup.exe /quiet"),
+            Node(110, 100, "msiexec.exe", commandLine: "msiexec.exe /i " + new string('x', 400)),
+            Node(200, 999, "dialog.exe")
+        };
+
+        var lines = InstallerJob.FormatTree(nodes).Split(Environment.NewLine);
+
+        Assert.Equal(3, lines.Length);
+        Assert.Equal("setup.exe (PID 100): setup.exe /quiet", lines[0]);
+        Assert.StartsWith("  msiexec.exe (PID 110): msiexec.exe /i xxx", lines[1]);
+        Assert.EndsWith("...", lines[1]);
+        Assert.Equal("dialog.exe (PID 200)", lines[2]);
+    }
+
+    [Fact]
+    public void FormatTree_Empty_SaysNothingWasLeft()
+    {
+        Assert.Equal("(no processes left running)", InstallerJob.FormatTree([]));
+    }
+
+    [Fact]
+    public void StillRunning_MatchesIdAndStartTime()
+    {
+        var stopped = new[] { Node(100, 4, "setup.exe", 10), Node(110, 100, "msiexec.exe", 11), Node(120, 100, "helper.exe", 12) };
+        var running = new[]
+        {
+            Node(110, 100, "msiexec.exe", 11),
+            // PID 120 already belongs to something newer
+            Node(120, 4, "notepad.exe", 30)
+        };
+
+        Assert.Equal([110], InstallerJob.StillRunning(stopped, running).Select(p => p.Id));
+    }
+
+    [Fact]
+    public void IsSameProcess_NeedsTheCapturedStartTime()
+    {
+        var node = Node(120, 100, "helper.exe", 12);
+
+        Assert.True(InstallerJob.IsSameProcess(node, Boot.AddMinutes(12).AddTicks(5)));
+        // PID 120 reused by a process started later
+        Assert.False(InstallerJob.IsSameProcess(node, Boot.AddMinutes(30)));
+        Assert.False(InstallerJob.IsSameProcess(node with { Started = null }, Boot.AddMinutes(12)));
+    }
+
+    [Fact]
+    public void ProcessTreeStop_Describe_ListsSurvivorsAfterTheTree()
+    {
+        var setup = Node(100, 4, "setup.exe");
+        var msiexec = Node(110, 100, "msiexec.exe");
+
+        var clean = new ProcessTreeStop([setup, msiexec], []).Describe();
+        var stuck = new ProcessTreeStop([setup, msiexec], [msiexec]).Describe();
+
+        Assert.DoesNotContain("Still running", clean);
+        Assert.EndsWith($"Still running afterwards:{Environment.NewLine}msiexec.exe (PID 110)", stuck);
+    }
+}
@@ -240,6 +240,26 @@ public class InstallerServiceTests
         Assert.Contains("unable to resolve PackageFullName", output);
     }
 
+    [Theory]
+    [InlineData(null, false, true)]
+    [InlineData(true, false, true)]
+    [InlineData(false, false, false)]
+    [InlineData(false, true, true)] // per-account install under SYSTEM would reach no user
+    public void ShouldProvisionMsix_HonoursProvisionKeyword(bool? provision, bool runningAsSystem, bool expected)
+    {
+        var item = new CatalogItem { Name = "MsixApp", Provision = provision };
+
+        Assert.Equal(expected, InstallerService.ShouldProvisionMsix(item, runningAsSystem));
+    }
+
+    [Fact]
+    public void ShouldProvisionMsix_UserContextNeverProvisions()
+    {
+        var item = new CatalogItem { Name = "MsixApp", InstallContext = "user" };
+
+        Assert.False(InstallerService.ShouldProvisionMsix(item, runningAsSystem: true));
+    }
+
     #endregion
 
     #region IsUninstallable Tests
@@ -324,5 +344,268 @@ public class InstallerServiceTests
         Assert.Equal(expected, InstallerService.InferRegistrySilentSwitch(exePath));
     }
 
+    [Fact]
+    public void BuildArpQuery_RegistryUninstallerOverridesNameMatching()
+    {
+        var item = new CatalogItem
+        {
+            Name = "7zip",
+            DisplayName = "7-Zip",
+            Installer = new InstallerInfo { Type = "msi", ProductCode = "{23170F69-40C1-2702-2409-000001000000}" }
+        };
+
+        var fallback = InstallerService.BuildArpQuery(item, null);
+        var declared = InstallerService.BuildArpQuery(item, new UninstallerInfo { Type = "registry", DisplayNameRegex = "^7-Zip .* x64" });
+
+        Assert.Equal(new ArpQuery("{23170F69-40C1-2702-2409-000001000000}", null, "7-Zip"), fallback);
+        Assert.Equal(new ArpQuery("{23170F69-40C1-2702-2409-000001000000}", "^7-Zip .* x64", null), declared);
+    }
+
+    [Fact]
+    public void BuildRegistryUninstallCommand_QuietRunsAsRegisteredAndOthersGetSwitches()
+    {
+        var quiet = new ArpEntry("App", "App", null, @"""C:\App\uninstall.exe""", @"""C:\App\uninstall.exe"" /quiet");
+        var inno = new ArpEntry("App_is1", "App", null, @"""C:\App\unins000.exe""", null);
+        var declared = new UninstallerInfo { Type = "registry", Switches = ["silent"] };
+
+        Assert.Equal((@"C:\App\uninstall.exe", "/quiet"), InstallerService.BuildRegistryUninstallCommand(quiet, declared));
+        Assert.Equal((@"C:\App\unins000.exe", "/VERYSILENT /SUPPRESSMSGBOXES /NORESTART"), InstallerService.BuildRegistryUninstallCommand(inno, null));
+        Assert.Equal((@"C:\App\unins000.exe", "/silent"), InstallerService.BuildRegistryUninstallCommand(inno, declared));
+    }
+
+    [Fact]
+    public void BuildRegistryUninstallCommand_MsiexecBecomesSilentRemoval()
+    {
+        var entry = new ArpEntry("{23170F69-40C1-2702-2409-000001000000}", "7-Zip", null, "MsiExec.exe /I{23170F69-40C1-2702-2409-000001000000}", null);
+
+        Assert.Equal(("MsiExec.exe", "/X{23170F69-40C1-2702-2409-000001000000} /qn /norestart"), InstallerService.BuildRegistryUninstallCommand(entry, null));
+    }
+
     #endregion
+
+    #region Archive Installer Tests
+
+    [Theory]
+    [InlineData("setup.exe")]
+    [InlineData(@"Setup\Installer.msi")]
+    [InlineData("x64/setup.exe")]
+    public void ResolveArchiveInnerPath_RelativePath_StaysUnderRoot(string innerPath)
+    {
+        var resolved = InstallerService.ResolveArchiveInnerPath(_testDir, innerPath);
+
+        Assert.NotNull(resolved);
+        Assert.StartsWith(Path.GetFullPath(_testDir), resolved);
+    }
+
+    [Theory]
+    [InlineData(@"..\..\Windows\System32\cmd.exe")]
+    [InlineData("../outside.exe")]
+    public void ResolveArchiveInnerPath_TraversalOutsideRoot_ReturnsNull(string innerPath)
+    {
+        Assert.Null(InstallerService.ResolveArchiveInnerPath(_testDir, innerPath));
+    }
+
+    [Theory]
+    [InlineData(null, "setup.msi", "msi")]
+    [InlineData(null, "App.msixbundle", "msix")]
+    [InlineData(null, "install.ps1", "powershell")]
+    [InlineData(null, "setup.bin", "exe")]
+    [InlineData("EXE", "setup.msi", "exe")]
+    public void InferArchiveInnerType_PrefersExplicitTypeThenExtension(string? innerType, string file, string expected)
+    {
+        Assert.Equal(expected, InstallerService.InferArchiveInnerType(innerType, file));
+    }
+
+    [Fact]
+    public async Task InstallAsync_ZipWithoutInnerPath_ReturnsFailure()
+    {
+        var zipPath = Path.Combine(_testDir, "payload.zip");
+        using (var archive = System.IO.Compression.ZipFile.Open(zipPath, System.IO.Compression.ZipArchiveMode.Create))
+        {
+            archive.CreateEntry("setup.exe");
+        }
+
+        var item = new CatalogItem
+        {
+            Name = "ZipApp",
+            Version = "1.0.0",
+            Installer = new InstallerInfo { Type = "zip" }
+        };
+
+        var (success, output, _) = await _service.InstallAsync(item, zipPath);
+
+        Assert.False(success);
+        Assert.Contains("inner_path", output);
+    }
+
+    [Fact]
+    public async Task InstallAsync_ZipInnerPathMissing_ReturnsFailureAndCleansStaging()
+    {
+        var zipPath = Path.Combine(_testDir, "payload.zip");
+        using (var archive = System.IO.Compression.ZipFile.Open(zipPath, System.IO.Compression.ZipArchiveMode.Create))
+        {
+            archive.CreateEntry("readme.txt");
+        }
+
+        var item = new CatalogItem
+        {
+            Name = "ZipApp",
+            Version = "1.0.0",
+            Installer = new InstallerInfo { Type = "zip", InnerPath = "setup.exe" }
+        };
+
+        var (success, output, _) = await _service.InstallAsync(item, zipPath);
+
+        Assert.False(success);
+        Assert.Contains("not found", output);
+        var extractDir = Path.Combine(_testDir, "extract");
+        Assert.True(!Directory.Exists(extractDir) || Directory.GetDirectories(extractDir).Length == 0);
+    }
+
+    #endregion
+
+    #region Delta Installer Tests
+
+    [Fact]
+    public void GetInstallerType_Delta_UsesTargetType()
+    {
+        var item = new CatalogItem
+        {
+            Name = "BigApp",
+            Installer = new InstallerInfo { Type = "delta", TargetType = "exe" }
+        };
+
+        Assert.Equal("exe", InstallerService.GetInstallerType(item, @"C:\cache\BigApp-2.0.msi"));
+    }
+
+    [Fact]
+    public void GetInstallerType_DeltaWithoutTargetType_InfersFromRebuiltInstaller()
+    {
+        var item = new CatalogItem
+        {
+            Name = "BigApp",
+            Installer = new InstallerInfo { Type = "delta" }
+        };
+
+        Assert.Equal("msi", InstallerService.GetInstallerType(item, @"C:\cache\BigApp-2.0.msi"));
+    }
+
+    #endregion
+
+    [Theory]
+    [InlineData("Exit code: 3010\nNote: A reboot is required to complete the installation\nRESTART_REQUIRED=3010", true)]
+    [InlineData("Exit code: 0", false)]
+    [InlineData(null, false)]
+    public void InstallerRequestedRestart_ReadsTag(string? output, bool expected)
+    {
+        Assert.Equal(expected, InstallerService.InstallerRequestedRestart(output));
+    }
+
+    [Theory]
+    [InlineData(0, true, false)]
+    [InlineData(1, false, false)]
+    [InlineData(3010, true, true)]
+    [InlineData(1641, true, true)]
+    public void ClassifyExitCode_NoItemCodes_UsesDefaults(int exitCode, bool success, bool restart)
+    {
+        Assert.Equal((success, restart), InstallerService.ClassifyExitCode(exitCode));
+    }
+
+    [Fact]
+    public void ClassifyExitCode_ItemCodes_ExtendSuccessAndReplaceRebootDefaults()
+    {
+        int[] success = [1, 3010];
+        int[] reboot = [194];
+
+        Assert.Equal((true, false), InstallerService.ClassifyExitCode(1, success, reboot));
+        Assert.Equal((true, false), InstallerService.ClassifyExitCode(3010, success, reboot));
+        Assert.Equal((true, true), InstallerService.ClassifyExitCode(194, success, reboot));
+        Assert.Equal((false, false), InstallerService.ClassifyExitCode(1641, success, reboot));
+        Assert.Equal((true, false), InstallerService.ClassifyExitCode(0, success, reboot));
+    }
+
+    [Fact]
+    public void InstallerTimeoutFor_ItemMinutesBeatConfig()
+    {
+        var config = new CimianConfig { InstallerTimeout = 900 };
+        var item = new CatalogItem { Name = "Visual Studio" };
+
+        Assert.Equal(TimeSpan.FromMinutes(15), InstallerService.InstallerTimeoutFor(item, config));
+
+        config.DefaultInstallerTimeoutMinutes = 45;
+        Assert.Equal(TimeSpan.FromMinutes(45), InstallerService.InstallerTimeoutFor(item, config));
+
+        item.InstallerTimeout = 120;
+        Assert.Equal(TimeSpan.FromMinutes(120), InstallerService.InstallerTimeoutFor(item, config));
+    }
+
+    [Fact]
+    public void ExpectedSelfUpdateSigner_ConfigPinBeatsPkginfo()
+    {
+        var config = new CimianConfig();
+        var item = new CatalogItem { Name = "Cimian", ExpectedSigner = "CN=Repo Named Signer" };
+
+        Assert.Equal("CN=Repo Named Signer", InstallerService.ExpectedSelfUpdateSigner(config, item));
+
+        config.SelfUpdateExpectedSigner = " CN=Contoso IT ";
+        Assert.Equal("CN=Contoso IT", InstallerService.ExpectedSelfUpdateSigner(config, item));
+
+        config.SelfUpdateExpectedSigner = null;
+        item.ExpectedSigner = "";
+        Assert.Null(InstallerService.ExpectedSelfUpdateSigner(config, item));
+    }
+
+    [Fact]
+    public void VerifySelfUpdateSignature_NupkgCannotBeSigned()
+    {
+        var path = Path.Combine(_testDir, "Cimian-2026.10.17.nupkg");
+        File.WriteAllText(path, "not really a package");
+        var item = new CatalogItem { Name = "Cimian", Installer = new InstallerInfo { Type = "nupkg" } };
+
+        var (valid, details) = _service.VerifySelfUpdateSignature(item, path);
+
+        Assert.False(valid);
+        Assert.Contains("can't carry a signature", details);
+    }
+
+    [Fact]
+    public void VerifySelfUpdateSignature_PkgClaimingThePinnedSignerIsRefused()
+    {
+        const string pinned = "0123456789ABCDEF0123456789ABCDEF01234567";
+        var config = new CimianConfig { CachePath = _testDir, SelfUpdateExpectedSigner = pinned };
+        var path = Path.Combine(_testDir, "Cimian-2026.10.17.pkg");
+        var payload = "tampered agent"u8.ToArray();
+        // A forged build-info that gets every self-declared field right: the
+        // payload hash, a current certificate and the pinned thumbprint
+        var payloadHash = Convert.ToHexString(System.Security.Cryptography.SHA256.HashData(payload)).ToLowerInvariant();
+        var packageHash = Convert.ToHexString(System.Security.Cryptography.SHA256.HashData(
+            System.Text.Encoding.UTF8.GetBytes($"payload/managedsoftwareupdate.exe:{payloadHash}"))).ToLowerInvariant();
+        using (var archive = System.IO.Compression.ZipFile.Open(path, System.IO.Compression.ZipArchiveMode.Create))
+        {
+            using (var entry = archive.CreateEntry("payload/managedsoftwareupdate.exe").Open())
+            {
+                entry.Write(payload);
+            }
+            using var writer = new StreamWriter(archive.CreateEntry("build-info.yaml").Open());
+            writer.Write($"""
+                product:
+                  identifier: com.cimian.agent
+                  version: 2026.10.17
+                signature:
+                  package_hash: sha256:{packageHash}
+                  signed_hash: forged
+                  certificate:
+                    subject: CN=Contoso IT
+                    thumbprint: {pinned}
+                    not_before: 2020-01-01T00:00:00Z
+                    not_after: 2099-01-01T00:00:00Z
+                """);
+        }
+        var item = new CatalogItem { Name = "Cimian", Installer = new InstallerInfo { Type = "pkg" } };
+
+        var (valid, details) = new InstallerService(config).VerifySelfUpdateSignature(item, path);
+
+        Assert.False(valid);
+        Assert.Contains("can't be verified", details);
+    }
 }
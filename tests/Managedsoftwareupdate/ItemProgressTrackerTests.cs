@@ -0,0 +1,72 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for ItemProgressTracker - per-item throttling, bytes and ETA for itemProgress.
+/// </summary>
+public class ItemProgressTrackerTests
+{
+    private static readonly DateTime Start = new(2026, 10, 1, 9, 0, 0, DateTimeKind.Utc);
+
+    [Fact]
+    public void Update_ReportsOncePerWholePercent()
+    {
+        var tracker = new ItemProgressTracker();
+        Assert.Null(tracker.Update("Zoom", 0.5, null, Start));
+
+        var first = tracker.Update("Zoom", 1.2, null, Start.AddSeconds(1));
+        Assert.NotNull(first);
+        Assert.Equal(1, first.Percent);
+        Assert.Null(tracker.Update("Zoom", 1.9, null, Start.AddSeconds(2)));
+        Assert.Equal(2, tracker.Update("Zoom", 2.0, null, Start.AddSeconds(3))?.Percent);
+    }
+
+    [Fact]
+    public void Update_DerivesBytesFromTotalSize()
+    {
+        var tracker = new ItemProgressTracker();
+        tracker.Update("Zoom", 0.1, 200_000, Start);
+
+        var update = tracker.Update("Zoom", 25, 200_000, Start.AddSeconds(5));
+
+        Assert.Equal(50_000, update?.Bytes);
+        Assert.Equal(200_000, update?.TotalBytes);
+    }
+
+    [Fact]
+    public void Update_UnknownSize_OmitsBytes()
+    {
+        var tracker = new ItemProgressTracker();
+        tracker.Update("Zoom", 0.1, null, Start);
+
+        var update = tracker.Update("Zoom", 50, 0, Start.AddSeconds(5));
+
+        Assert.Null(update?.Bytes);
+        Assert.Null(update?.TotalBytes);
+    }
+
+    [Fact]
+    public void Update_ResumedDownload_EtaFromRateSinceFirstSample()
+    {
+        var tracker = new ItemProgressTracker();
+        // Resumed at 40%: 10 points in 10 seconds leaves 50 points, about 50 seconds
+        tracker.Update("Zoom", 40, null, Start);
+
+        var update = tracker.Update("Zoom", 50, null, Start.AddSeconds(10));
+
+        Assert.Equal(50, update?.EtaSeconds);
+    }
+
+    [Fact]
+    public void Update_TracksItemsIndependently()
+    {
+        var tracker = new ItemProgressTracker();
+        tracker.Update("Zoom", 0.1, null, Start);
+        tracker.Update("Chrome", 0.1, null, Start);
+
+        Assert.Equal(30, tracker.Update("Zoom", 30, null, Start.AddSeconds(1))?.Percent);
+        Assert.Equal(5, tracker.Update("chrome", 5, null, Start.AddSeconds(1))?.Percent);
+    }
+}
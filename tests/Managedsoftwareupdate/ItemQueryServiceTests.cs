@@ -0,0 +1,107 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+using Cimian.Core.Models;
+using Cimian.Core.Services;
+using CatalogItem = Cimian.CLI.managedsoftwareupdate.Models.CatalogItem;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for ItemQueryService - the read-only --list-items / --item-info queries.
+/// </summary>
+public class ItemQueryServiceTests
+{
+    private static Dictionary<string, CatalogItem> Catalog(params CatalogItem[] items) =>
+        items.ToDictionary(i => ItemKey.Canonical(i.Name), ItemKey.Comparer);
+
+    private static CatalogItem Item(string name, string version = "1.0", string catalog = "Production", params string[] requires) => new()
+    {
+        Name = name,
+        Version = version,
+        SourceCatalog = catalog,
+        Requires = requires.ToList()
+    };
+
+    private static ItemQueryService CreateService() => new(new CimianConfig());
+
+    [Fact]
+    public void ListItems_SortsByNameWithCatalogAndType()
+    {
+        var firefox = Item("Firefox", "128.0", "Testing");
+        firefox.Installer.Type = "MSI";
+        var items = Catalog(firefox, Item("7zip", "24.08"));
+
+        var rows = ItemQueryService.ListItems(items);
+
+        Assert.Equal(["7zip", "Firefox"], rows.Select(r => r.Name));
+        Assert.Equal("nopkg", rows[0].Type);
+        Assert.Equal("msi", rows[1].Type);
+        Assert.Equal("Testing", rows[1].Catalog);
+    }
+
+    [Fact]
+    public void Describe_BuildsDependencyTreeAndMarksMissingAndCycles()
+    {
+        var items = Catalog(
+            Item("App", "2.0", "Production", "Runtime-8.0", "Plugin"),
+            Item("Runtime", "8.0.4", "Production", "VCRedist"),
+            Item("VCRedist", "14.40"),
+            Item("Plugin", "1.0", "Production", "App", "Gone"));
+
+        var info = CreateService().Describe(items, "app")!;
+
+        Assert.Equal("App", info.Name);
+        Assert.Equal(["Runtime", "Plugin"], info.Requires.Select(d => d.Name));
+
+        var runtime = info.Requires[0];
+        Assert.Equal("8.0", runtime.RequiredVersion);
+        Assert.Equal("8.0.4", runtime.Version);
+        Assert.Equal("VCRedist", Assert.Single(runtime.Requires).Name);
+
+        var plugin = info.Requires[1];
+        Assert.True(plugin.Requires.Single(d => d.Name == "App").Cycle);
+        Assert.True(plugin.Requires.Single(d => d.Name == "Gone").Missing);
+    }
+
+    [Fact]
+    public void Describe_ListsItemsThatUpdateIt()
+    {
+        var patch = Item("Office-Patch");
+        patch.UpdateFor = ["Office"];
+        var items = Catalog(Item("Office"), patch);
+
+        var info = CreateService().Describe(items, "Office")!;
+
+        Assert.Equal(["Office-Patch"], info.Updates);
+        Assert.Null(CreateService().Describe(items, "Visio"));
+    }
+
+    [Fact]
+    public void DescribeCheckStrategy_FollowsCheckStatusPriority()
+    {
+        var item = Item("Tool");
+        item.Check.Registry.Name = "Tool";
+        item.Installs = [new InstallCheckItem { Type = "file", Path = @"C:\Program Files\Tool\tool.exe", Version = "1.0" }];
+
+        var strategy = StatusService.DescribeCheckStrategy(item);
+        Assert.Equal(DetectionMethod.InstallsArray, strategy.Method);
+        Assert.Equal([@"file C:\Program Files\Tool\tool.exe version 1.0"], strategy.Details);
+
+        item.InstallcheckScript = "exit 1";
+        Assert.Equal(DetectionMethod.Script, StatusService.DescribeCheckStrategy(item).Method);
+
+        item.OnDemand = true;
+        Assert.Equal(DetectionMethod.None, StatusService.DescribeCheckStrategy(item).Method);
+    }
+
+    [Fact]
+    public void ToJson_UsesSnakeCase()
+    {
+        var json = ItemQueryService.ToJson(ItemQueryService.ListItems(Catalog(Item("7zip"))));
+
+        Assert.Contains("\"source_catalog\"", ItemQueryService.ToJson(Item("7zip")), StringComparison.Ordinal);
+        Assert.DoesNotContain("display_name", json, StringComparison.Ordinal);
+        Assert.Contains("\"catalog\": \"Production\"", json, StringComparison.Ordinal);
+    }
+}
@@ -6,6 +6,7 @@ using Xunit;
 using Cimian.CLI.managedsoftwareupdate.Models;
 using Cimian.CLI.managedsoftwareupdate.Services;
 using Cimian.Core;
+using SystemFacts = Cimian.Core.Models.SystemFacts;
 
 namespace Cimian.Tests.Managedsoftwareupdate;
 
@@ -233,7 +234,7 @@ public class ManifestServiceTests
     }
 
     // --- Primary-manifest 404 fallback chain -------------------------------
-    // configured identifier -> hostname -> serial -> Orphaned -> site_default.
+    // configured identifier -> serial -> hostname -> Orphaned -> site_default.
     // Only a 404 advances the chain; a non-404 aborts without degrading to a
     // catch-all so a transient server error stays visible.
 
@@ -261,6 +262,32 @@ public class ManifestServiceTests
         Assert.Equal("install", fallback.Action);
         Assert.Equal("Orphaned", fallback.SourceManifest);
         Assert.Contains("Production", config.Catalogs);
+        Assert.Equal("Orphaned", service.ResolvedManifest);
+    }
+
+    [Fact]
+    public async Task GetManifestItems_TemplatedClientIdentifier_FetchesExpandedManifest()
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = "https://repo.example.test",
+            ClientIdentifier = "labs/%hostname%",
+            ManifestsPath = Directory.CreateTempSubdirectory().FullName,
+        };
+        var expected = $"labs/{Environment.MachineName}";
+
+        var handler = new StubHandler(url =>
+            url.EndsWith($"/manifests/{expected}.yaml", StringComparison.OrdinalIgnoreCase)
+                ? (HttpStatusCode.OK, "managed_installs:\n  - LabApp\n")
+                : (HttpStatusCode.NotFound, string.Empty));
+
+        var service = new ManifestService(config, new HttpClient(handler));
+
+        var items = await service.GetManifestItemsAsync();
+
+        Assert.Single(items, i => i.Name == "LabApp");
+        Assert.Equal(expected, service.ResolvedManifest);
+        Assert.DoesNotContain(handler.RequestedUrls, u => u.Contains('%'));
     }
 
     [Fact]
@@ -290,6 +317,118 @@ public class ManifestServiceTests
             u => u.Contains("/manifests/site_default.yaml", StringComparison.OrdinalIgnoreCase));
     }
 
+    [Fact]
+    public async Task GetManifestHierarchy_FollowsIncludesThatLoaded()
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = "https://repo.example.test",
+            ClientIdentifier = "Assigned/Staff/PC01",
+            ManifestsPath = Directory.CreateTempSubdirectory().FullName,
+        };
+
+        var manifests = new Dictionary<string, string>(StringComparer.OrdinalIgnoreCase)
+        {
+            ["Assigned/Staff/PC01"] = "included_manifests:\n  - Assigned/Staff\n  - CoreApps\n",
+            ["Assigned/Staff"] = "included_manifests:\n  - CoreApps\n  - Missing\n",
+            ["CoreApps"] = "included_manifests:\n  - Assigned/Staff/PC01\nmanaged_installs:\n  - Firefox\n",
+        };
+        var handler = new StubHandler(url =>
+        {
+            var name = url["https://repo.example.test/manifests/".Length..^".yaml".Length];
+            return manifests.TryGetValue(name, out var yaml) ? (HttpStatusCode.OK, yaml) : (HttpStatusCode.NotFound, string.Empty);
+        });
+
+        var service = new ManifestService(config, new HttpClient(handler));
+        await service.GetManifestItemsAsync();
+
+        // CoreApps shows once, under Staff where it was first loaded; the 404'd
+        // include and the cycle back to the primary are left out
+        var root = service.GetManifestHierarchy();
+        Assert.NotNull(root);
+        Assert.Equal("Assigned/Staff/PC01", root.Name);
+        var staff = Assert.Single(root.Children);
+        Assert.Equal("Assigned/Staff", staff.Name);
+        var core = Assert.Single(staff.Children);
+        Assert.Equal("CoreApps", core.Name);
+        Assert.Empty(core.Children);
+    }
+
+    [Fact]
+    public async Task GetManifestItems_NestedConditionalItems_OnlyApplyWhenParentMatches()
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = "https://repo.example.test",
+            ClientIdentifier = "configured-pc",
+            ManifestsPath = Directory.CreateTempSubdirectory().FullName,
+        };
+
+        const string yaml = """
+            catalogs:
+              - Production
+            conditional_items:
+              - condition: machine_type == "laptop"
+                managed_installs:
+                  - VPNClient
+                conditional_items:
+                  - condition: os_build >= 22621
+                    managed_installs:
+                      - NewBuildPolicy
+                  - condition: os_build < 22621
+                    managed_installs:
+                      - OldBuildPolicy
+              - condition: machine_type == "desktop"
+                conditional_items:
+                  - condition: os_build >= 22621
+                    managed_installs:
+                      - DesktopOnly
+            """;
+        var handler = new StubHandler(url =>
+            url.EndsWith("/manifests/configured-pc.yaml", StringComparison.OrdinalIgnoreCase)
+                ? (HttpStatusCode.OK, yaml)
+                : (HttpStatusCode.NotFound, string.Empty));
+
+        var service = new ManifestService(config, new HttpClient(handler));
+        service.UseSystemFacts(new SystemFacts { MachineType = "laptop", OSBuildNumber = 22631 });
+
+        var items = await service.GetManifestItemsAsync();
+
+        Assert.Equal(new[] { "VPNClient", "NewBuildPolicy" },
+            items.Where(i => i.SourceManifest == "configured-pc").Select(i => i.Name));
+    }
+
+    // --- Offline mode (OfflineCacheMaxAgeHours) ------------------------------
+
+    [Theory]
+    [InlineData(2, 1, true)]   // fresh copy, offline mode on
+    [InlineData(30, 1, false)] // copy past the threshold
+    [InlineData(2, 0, false)]  // offline mode off
+    public async Task GetManifestItems_RepoUnreachable_UsesRecentLocalCopy(int ageHours, int maxAgeDays, bool expectCached)
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = "https://repo.example.test",
+            ClientIdentifier = "configured-pc",
+            ManifestsPath = Directory.CreateTempSubdirectory().FullName,
+            OfflineCacheMaxAgeHours = maxAgeDays * 24,
+        };
+        var localPath = Path.Combine(config.ManifestsPath, "configured-pc.yaml");
+        File.WriteAllText(localPath, "catalogs:\n  - Production\nmanaged_installs:\n  - CachedApp\n");
+        File.SetLastWriteTimeUtc(localPath, DateTime.UtcNow.AddHours(-ageHours));
+
+        var handler = new StubHandler(_ => (HttpStatusCode.ServiceUnavailable, string.Empty));
+        var service = new ManifestService(config, new HttpClient(handler));
+
+        var items = await service.GetManifestItemsAsync();
+
+        Assert.Equal(expectCached, items.Any(i => i.Name == "CachedApp"));
+        Assert.Equal(expectCached, service.OfflineManifests.Contains("configured-pc"));
+        // Offline mode never turns an outage into a catch-all manifest
+        Assert.DoesNotContain(handler.RequestedUrls,
+            u => u.Contains("/manifests/Orphaned.yaml", StringComparison.OrdinalIgnoreCase));
+    }
+
     /// <summary>
     /// Minimal HttpMessageHandler that answers each request from a URL-driven
     /// responder and records every requested URL for assertions.
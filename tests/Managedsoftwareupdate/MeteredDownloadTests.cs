@@ -0,0 +1,50 @@
+using System.Net.NetworkInformation;
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for RespectMeteredConnections: which downloads wait on a metered or
+/// cellular connection, and when traffic counts as cellular.
+/// </summary>
+public class MeteredDownloadTests
+{
+    private const long Mb = 1024 * 1024;
+
+    private static CatalogItem Item(long? size, bool critical = false) => new()
+    {
+        Name = "Teams",
+        Version = "24.1",
+        Critical = critical,
+        Installer = new InstallerInfo { Location = "apps/teams.msix", Size = size }
+    };
+
+    [Theory]
+    [InlineData(0, 5L * Mb, true)]        // no threshold: every download waits
+    [InlineData(50, 5L * Mb, false)]      // small download goes ahead
+    [InlineData(50, 50L * Mb, false)]
+    [InlineData(50, 51L * Mb, true)]
+    [InlineData(50, null, true)]          // unknown size counts as large
+    public void HoldsForMeteredNetwork_DefersLargeDownloads(int thresholdMb, long? size, bool held)
+    {
+        Assert.Equal(held, UpdateEngine.HoldsForMeteredNetwork(Item(size), thresholdMb));
+    }
+
+    [Fact]
+    public void HoldsForMeteredNetwork_CriticalItemsNeverWait()
+    {
+        Assert.False(UpdateEngine.HoldsForMeteredNetwork(Item(900L * Mb, critical: true), 0));
+    }
+
+    [Fact]
+    public void IsCellularRoute_OnlyWhenMobileBroadbandCarriesTheTraffic()
+    {
+        Assert.True(NetworkCost.IsCellularRoute([NetworkInterfaceType.Wwanpp]));
+        Assert.True(NetworkCost.IsCellularRoute([NetworkInterfaceType.Wwanpp, NetworkInterfaceType.Wwanpp2]));
+        Assert.False(NetworkCost.IsCellularRoute([NetworkInterfaceType.Wwanpp, NetworkInterfaceType.Wireless80211]));
+        Assert.False(NetworkCost.IsCellularRoute([NetworkInterfaceType.Ethernet]));
+        Assert.False(NetworkCost.IsCellularRoute([]));
+    }
+}
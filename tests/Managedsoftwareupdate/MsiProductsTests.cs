@@ -0,0 +1,53 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for MsiProducts - Windows Installer product lookups by ProductCode and UpgradeCode.
+/// </summary>
+public class MsiProductsTests
+{
+    [Theory]
+    [InlineData(null)]
+    [InlineData("")]
+    [InlineData("not-a-guid")]
+    public void Find_IgnoresCodesThatAreNotGuids(string? code)
+    {
+        Assert.Null(MsiProducts.FindByProductCode(code));
+        Assert.Null(MsiProducts.FindByUpgradeCode(code));
+    }
+
+    [Fact]
+    public void Find_UnknownCodesAreNotInstalled()
+    {
+        var code = Guid.NewGuid().ToString("B");
+
+        Assert.Null(MsiProducts.FindByProductCode(code));
+        Assert.Null(MsiProducts.FindByUpgradeCode(code));
+    }
+
+    [Fact]
+    public void Newest_PicksHighestVersion()
+    {
+        var products = new[]
+        {
+            new MsiProduct("{11111111-1111-1111-1111-111111111111}", "9.2.0", "Contoso 9"),
+            new MsiProduct("{22222222-2222-2222-2222-222222222222}", "10.0.1", "Contoso 10"),
+            new MsiProduct("{33333333-3333-3333-3333-333333333333}", "10.0.0", "Contoso 10")
+        };
+
+        Assert.Equal("10.0.1", MsiProducts.Newest(products)?.Version);
+    }
+
+    [Fact]
+    public void Newest_PrefersProductsThatReportAVersion()
+    {
+        var unversioned = new MsiProduct("{11111111-1111-1111-1111-111111111111}", null, "Contoso");
+        var versioned = new MsiProduct("{22222222-2222-2222-2222-222222222222}", "1.0", "Contoso");
+
+        Assert.Same(versioned, MsiProducts.Newest([unversioned, versioned]));
+        Assert.Same(unversioned, MsiProducts.Newest([unversioned]));
+        Assert.Null(MsiProducts.Newest([]));
+    }
+}
@@ -0,0 +1,176 @@
+using System.IO.Compression;
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for NupkgService - native .nupkg extraction and the Chocolatey scripts it runs.
+/// </summary>
+public class NupkgServiceTests : IDisposable
+{
+    private readonly string _testDir;
+    private readonly string _libRoot;
+    private readonly List<(string Script, IReadOnlyDictionary<string, string> Environment)> _ran = new();
+    private bool _scriptsSucceed = true;
+
+    public NupkgServiceTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "Nupkg", Guid.NewGuid().ToString());
+        _libRoot = Path.Combine(_testDir, "lib");
+        Directory.CreateDirectory(_testDir);
+    }
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    private NupkgService Service() => new(libRoot: _libRoot, runScript: (script, env, ct) =>
+    {
+        _ran.Add((Path.GetRelativePath(_libRoot, script), env));
+        return Task.FromResult((_scriptsSucceed, "ran " + Path.GetFileName(script)));
+    });
+
+    private string BuildPackage(string version, Dictionary<string, string>? tools = null, string id = "ContosoAgent")
+    {
+        var path = Path.Combine(_testDir, $"{id}.{version}.nupkg");
+        File.Delete(path);
+        using var archive = ZipFile.Open(path, ZipArchiveMode.Create);
+        void Add(string name, string content)
+        {
+            using var writer = new StreamWriter(archive.CreateEntry(name).Open());
+            writer.Write(content);
+        }
+
+        Add("[Content_Types].xml", "<Types />");
+        Add("_rels/.rels", "<Relationships />");
+        Add("package/services/metadata/core-properties/abc.psmdcp", "<coreProperties />");
+        Add($"{id}.nuspec", $"""
+            <?xml version="1.0"?>
+            <package xmlns="http://schemas.microsoft.com/packaging/2015/06/nuspec.xsd">
+              <metadata><id>{id}</id><version>{version}</version></metadata>
+            </package>
+            """);
+        Add("tools/payload/Contoso%20Agent.exe", version);
+        foreach (var (name, content) in tools ?? new Dictionary<string, string> { [NupkgService.InstallScript] = "Copy-Item payload" })
+        {
+            Add($"tools/{name}", content);
+        }
+        return path;
+    }
+
+    [Fact]
+    public void ReadNuspec_ReturnsIdAndVersion()
+    {
+        var nuspec = NupkgService.ReadNuspec(BuildPackage("2.1.0"));
+
+        Assert.Equal(new NuspecInfo("ContosoAgent", "2.1.0"), nuspec);
+    }
+
+    [Fact]
+    public async Task InstallAsync_ExtractsContentAndRunsInstallScripts()
+    {
+        var package = BuildPackage("2.1.0", new()
+        {
+            [NupkgService.BeforeInstallScript] = "Stop-Service ContosoAgent",
+            [NupkgService.InstallScript] = "Copy-Item payload"
+        });
+
+        var (success, _, folder) = await Service().InstallAsync(package);
+
+        Assert.True(success);
+        Assert.Equal(Path.Combine(_libRoot, "ContosoAgent"), folder);
+        Assert.Equal("2.1.0", File.ReadAllText(Path.Combine(folder!, "tools", "payload", "Contoso Agent.exe")));
+        Assert.True(File.Exists(Path.Combine(folder!, "ContosoAgent.nuspec")));
+        Assert.False(File.Exists(Path.Combine(folder!, "[Content_Types].xml")));
+        Assert.False(Directory.Exists(Path.Combine(folder!, "_rels")));
+        Assert.False(Directory.Exists(Path.Combine(folder!, "package")));
+        Assert.Equal(
+            new[] { Path.Combine("ContosoAgent", "tools", NupkgService.BeforeInstallScript), Path.Combine("ContosoAgent", "tools", NupkgService.InstallScript) },
+            _ran.Select(r => r.Script));
+        Assert.Equal("2.1.0", _ran[0].Environment["ChocolateyPackageVersion"]);
+        Assert.Equal(folder, _ran[0].Environment["ChocolateyPackageFolder"]);
+    }
+
+    [Fact]
+    public async Task InstallAsync_UpgradeRunsOldBeforeModifyAndReplacesFolder()
+    {
+        await Service().InstallAsync(BuildPackage("1.0.0", new() { [NupkgService.BeforeModifyScript] = "Stop-Process contoso" }));
+        File.WriteAllText(Path.Combine(_libRoot, "ContosoAgent", "tools", "leftover.txt"), "old");
+        _ran.Clear();
+
+        var (success, _, folder) = await Service().InstallAsync(BuildPackage("2.0.0"));
+
+        Assert.True(success);
+        Assert.Equal(Path.Combine("ContosoAgent", "tools", NupkgService.BeforeModifyScript), _ran[0].Script);
+        Assert.Equal(Path.Combine("ContosoAgent", "tools", NupkgService.InstallScript), _ran[1].Script);
+        Assert.False(File.Exists(Path.Combine(folder!, "tools", "leftover.txt")));
+        Assert.Equal("2.0.0", File.ReadAllText(Path.Combine(folder!, "tools", "payload", "Contoso Agent.exe")));
+    }
+
+    [Fact]
+    public async Task InstallAsync_RefusesChocolateyHelpersAndKeepsInstalledVersion()
+    {
+        await Service().InstallAsync(BuildPackage("1.0.0"));
+        _ran.Clear();
+        var package = BuildPackage("2.0.0", new()
+        {
+            [NupkgService.InstallScript] = "# Install-ChocolateyPackage is not used here\nInstall-ChocolateyZipPackage -PackageName $env:ChocolateyPackageName"
+        });
+
+        var (success, output, _) = await Service().InstallAsync(package);
+
+        Assert.False(success);
+        Assert.Equal("ContosoAgent calls the Chocolatey helper Install-ChocolateyZipPackage; install it with Chocolatey instead", output);
+        Assert.Empty(_ran);
+        Assert.Equal("1.0.0", File.ReadAllText(Path.Combine(_libRoot, "ContosoAgent", "tools", "payload", "Contoso Agent.exe")));
+        Assert.False(Directory.Exists(Path.Combine(_libRoot, "ContosoAgent.new")));
+    }
+
+    [Fact]
+    public async Task InstallAsync_RejectsPackagesWithoutNuspecOrWithUnsafeIds()
+    {
+        var bare = Path.Combine(_testDir, "bare.nupkg");
+        using (var archive = ZipFile.Open(bare, ZipArchiveMode.Create))
+        {
+            archive.CreateEntry("tools/chocolateyInstall.ps1");
+        }
+
+        var (bareSuccess, bareOutput, _) = await Service().InstallAsync(bare);
+        var (unsafeSuccess, unsafeOutput, _) = await Service().InstallAsync(BuildPackage("1.0.0", id: ".."));
+
+        Assert.False(bareSuccess);
+        Assert.Equal("bare.nupkg has no .nuspec; it is not a NuGet package", bareOutput);
+        Assert.False(unsafeSuccess);
+        Assert.Equal("'..' is not a valid package id", unsafeOutput);
+    }
+
+    [Fact]
+    public async Task UninstallAsync_RunsUninstallScriptThenRemovesFolder()
+    {
+        var (_, _, folder) = await Service().InstallAsync(BuildPackage("1.0.0", new() { [NupkgService.UninstallScript] = "Remove-Item payload" }));
+        _ran.Clear();
+
+        var (success, _) = await Service().UninstallAsync(folder!);
+        var (again, _) = await Service().UninstallAsync(folder!);
+
+        Assert.True(success);
+        Assert.Equal(Path.Combine("ContosoAgent", "tools", NupkgService.UninstallScript), Assert.Single(_ran).Script);
+        Assert.False(Directory.Exists(folder));
+        Assert.True(again);
+    }
+
+    [Fact]
+    public async Task UninstallAsync_KeepsFolderWhenUninstallScriptFails()
+    {
+        var (_, _, folder) = await Service().InstallAsync(BuildPackage("1.0.0", new() { [NupkgService.UninstallScript] = "throw" }));
+        _scriptsSucceed = false;
+
+        var (success, output) = await Service().UninstallAsync(folder!);
+
+        Assert.False(success);
+        Assert.StartsWith("chocolateyUninstall.ps1 failed", output);
+        Assert.True(Directory.Exists(folder));
+    }
+}
@@ -0,0 +1,76 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+using Cimian.Core.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for OrphanedDependencyFinder - which dependency-only installs RemoveOrphanedDependencies removes.
+/// </summary>
+public class OrphanedDependencyFinderTests
+{
+    private static CatalogItem Item(string name, params string[] requires) => new()
+    {
+        Name = name,
+        Version = "1.0",
+        Requires = requires.ToList(),
+        Installer = new InstallerInfo { Type = "msi", ProductCode = $"{{{name}}}" }
+    };
+
+    private static Dictionary<string, CatalogItem> Catalog(params CatalogItem[] items) =>
+        items.ToDictionary(i => ItemKey.Canonical(i.Name), ItemKey.Comparer);
+
+    private static InstalledItemRecord Installed(string name, bool asDependency = false) => new()
+    {
+        Name = name,
+        Version = "1.0",
+        InstalledAsDependency = asDependency
+    };
+
+    private static HashSet<string> Names(params string[] names) => new(names, ItemKey.Comparer);
+
+    [Fact]
+    public void Find_DependencyOfRemovedItem_IsOrphanedWithWhatOnlyItRequired()
+    {
+        var catalog = Catalog(Item("Tool", "Runtime"), Item("Runtime", "VCRedist"), Item("VCRedist"), Item("Editor", "VCRedist"));
+        var installed = new[]
+        {
+            Installed("Tool"), Installed("Runtime", asDependency: true), Installed("VCRedist", asDependency: true)
+        };
+
+        var orphans = OrphanedDependencyFinder.Find(installed, Names(), ["Tool"], catalog);
+
+        // Runtime first: it required VCRedist
+        Assert.Equal(["Runtime", "VCRedist"], orphans.Select(o => o.Name));
+    }
+
+    [Fact]
+    public void Find_KeepsDependenciesStillRequiredOrWanted()
+    {
+        var catalog = Catalog(Item("Tool", "Runtime"), Item("Editor", "VCRedist"), Item("Runtime"), Item("VCRedist"), Item("Fonts"));
+        var installed = new[]
+        {
+            Installed("Editor"),
+            Installed("Runtime", asDependency: true),
+            Installed("VCRedist", asDependency: true),
+            Installed("Fonts", asDependency: true)
+        };
+
+        // Editor stays installed and requires VCRedist; a manifest asks for Fonts
+        var orphans = OrphanedDependencyFinder.Find(installed, Names("Fonts"), [], catalog);
+
+        Assert.Equal(["Runtime"], orphans.Select(o => o.Name));
+    }
+
+    [Fact]
+    public void Find_SkipsDirectInstallsAndItemsThatCantBeRemoved()
+    {
+        var pinned = Item("Driver");
+        pinned.Uninstallable = false;
+        var catalog = Catalog(Item("Runtime"), pinned);
+        var installed = new[] { Installed("Runtime"), Installed("Driver", asDependency: true), Installed("Gone", asDependency: true) };
+
+        Assert.Empty(OrphanedDependencyFinder.Find(installed, Names(), [], catalog));
+    }
+}
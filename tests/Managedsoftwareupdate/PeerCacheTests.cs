@@ -0,0 +1,123 @@
+using System.Net;
+using System.Security.Cryptography;
+using System.Text;
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for PeerCache Mode peers: payloads from peer cache servers, verified
+/// against the catalog hash, with the repo as fallback.
+/// </summary>
+public class PeerCacheTests : IDisposable
+{
+    private const string Repo = "https://repo.example.com/cimian";
+    private const string PeerA = "http://cache01.branch.example.com:8080";
+    private const string PeerB = "http://cache02.branch.example.com:8080";
+
+    private static readonly byte[] Payload = Encoding.UTF8.GetBytes("installer payload");
+    private static readonly string PayloadHash = Convert.ToHexString(SHA256.HashData(Payload)).ToLowerInvariant();
+
+    private readonly string _testDir;
+
+    public PeerCacheTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "PeerCache", Guid.NewGuid().ToString());
+        Directory.CreateDirectory(_testDir);
+    }
+
+    public void Dispose()
+    {
+        try
+        {
+            if (Directory.Exists(_testDir))
+            {
+                Directory.Delete(_testDir, recursive: true);
+            }
+        }
+        catch { /* Ignore cleanup errors */ }
+    }
+
+    private static CimianConfig Config(int minimumSizeMB = 0) => new()
+    {
+        SoftwareRepoURL = Repo,
+        PeerCache = new PeerCacheSettings { Mode = "peers", Peers = [PeerA, PeerB], MinimumSizeMB = minimumSizeMB }
+    };
+
+    [Fact]
+    public async Task TryFetch_UsesFirstPeerWithMatchingContent()
+    {
+        var peers = new StubPeers(url => url.StartsWith(PeerA) ? Encoding.UTF8.GetBytes("stale build") : Payload);
+        var service = new PeerCacheService(Config(), new HttpClient(peers));
+        var localPath = Path.Combine(_testDir, "app.msi");
+
+        var source = await service.TryFetchAsync($"{Repo}/pkgs/apps/app.msi", localPath, PayloadHash, CancellationToken.None);
+
+        Assert.Equal($"peer {PeerB}", source);
+        Assert.Equal(Payload, File.ReadAllBytes(localPath));
+        Assert.Equal([$"{PeerA}/pkgs/apps/app.msi", $"{PeerB}/pkgs/apps/app.msi"], peers.Requests);
+        Assert.False(File.Exists(localPath + ".peer"));
+    }
+
+    [Fact]
+    public async Task TryFetch_ReturnsNullWhenNoPeerHasIt()
+    {
+        var service = new PeerCacheService(Config(), new HttpClient(new StubPeers(_ => null)));
+        var localPath = Path.Combine(_testDir, "app.msi");
+
+        var source = await service.TryFetchAsync($"{Repo}/pkgs/app.msi", localPath, PayloadHash, CancellationToken.None);
+
+        Assert.Null(source);
+        Assert.False(File.Exists(localPath));
+    }
+
+    [Fact]
+    public void AppliesTo_NeedsHashSizeAndRepoUrl()
+    {
+        var service = new PeerCacheService(Config(minimumSizeMB: 10), new HttpClient(new StubPeers(_ => Payload)));
+        var large = 50L * 1024 * 1024;
+
+        Assert.True(service.AppliesTo($"{Repo}/pkgs/app.msi", large, PayloadHash));
+        Assert.False(service.AppliesTo($"{Repo}/pkgs/app.msi", large, null));
+        Assert.False(service.AppliesTo($"{Repo}/pkgs/app.msi", 1024, PayloadHash));
+        Assert.False(service.AppliesTo($"{Repo}/pkgs/app.msi", -1, PayloadHash));
+        Assert.False(service.AppliesTo("https://downloads.example.org/app.msi", large, PayloadHash));
+    }
+
+    [Fact]
+    public void AppliesTo_FalseWhenOff()
+    {
+        var service = new PeerCacheService(new CimianConfig { SoftwareRepoURL = Repo });
+
+        Assert.False(service.IsEnabled);
+        Assert.False(service.AppliesTo($"{Repo}/pkgs/app.msi", 50L * 1024 * 1024, PayloadHash));
+    }
+
+    [Fact]
+    public void ValidateConfig_RejectsPeersModeWithoutPeers()
+    {
+        var config = new CimianConfig { SoftwareRepoURL = Repo, PeerCache = new PeerCacheSettings { Mode = "peers" } };
+
+        var errors = new ConfigurationService().ValidateConfig(config);
+
+        Assert.Contains(errors, e => e.Contains("PeerCache Mode peers"));
+    }
+
+    /// <summary>Serves the body chosen per URL, or 404 for null.</summary>
+    private sealed class StubPeers(Func<string, byte[]?> body) : HttpMessageHandler
+    {
+        public List<string> Requests { get; } = new();
+
+        protected override Task<HttpResponseMessage> SendAsync(HttpRequestMessage request, CancellationToken cancellationToken)
+        {
+            var url = request.RequestUri!.ToString();
+            Requests.Add(url);
+            var content = body(url);
+            return Task.FromResult(content == null
+                ? new HttpResponseMessage(HttpStatusCode.NotFound)
+                : new HttpResponseMessage(HttpStatusCode.OK) { Content = new ByteArrayContent(content) });
+        }
+    }
+}
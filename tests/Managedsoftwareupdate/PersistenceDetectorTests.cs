@@ -0,0 +1,39 @@
+using Cimian.CLI.managedsoftwareupdate.Services;
+using Xunit;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+public class PersistenceDetectorTests
+{
+    [Fact]
+    public void Evaluate_NoSignals_IsPersistent()
+    {
+        var result = PersistenceDetector.Evaluate([]);
+
+        Assert.False(result.IsNonPersistent);
+        Assert.Equal("persistent", result.ReportValue);
+    }
+
+    [Fact]
+    public void Evaluate_FirstSignalNamesPlatform()
+    {
+        var result = PersistenceDetector.Evaluate(
+        [
+            ("citrix-pvs", @"HKLM\SYSTEM\CurrentControlSet\Services\bnistack\PvsAgent"),
+            ("uwf", "Unified Write Filter enabled")
+        ]);
+
+        Assert.True(result.IsNonPersistent);
+        Assert.Equal("citrix-pvs", result.Platform);
+        Assert.Contains("Unified Write Filter", result.Reason);
+        Assert.Equal("non_persistent", result.ReportValue);
+    }
+
+    [Theory]
+    [InlineData("always", true)]
+    [InlineData("Never", false)]
+    public void Detect_ExplicitModeSkipsDetection(string mode, bool expected)
+    {
+        Assert.Equal(expected, PersistenceDetector.Detect(mode).IsNonPersistent);
+    }
+}
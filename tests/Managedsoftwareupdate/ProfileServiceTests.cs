@@ -0,0 +1,163 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for ProfileService - compliance, apply, check-only and removal against an in-memory handler.
+/// </summary>
+public class ProfileServiceTests : StoreTestBase
+{
+    private readonly FakeHandler _handler = new();
+
+    private sealed class FakeHandler : IProfilePayloadHandler
+    {
+        public Dictionary<string, string> Values { get; } = new(StringComparer.OrdinalIgnoreCase);
+        public int Writes { get; private set; }
+
+        public string? Validate(ProfilePayload payload) => payload.Name == null ? "registry payload needs key and name" : null;
+
+        public Task<string?> ReadAsync(ProfilePayload payload, CancellationToken cancellationToken) =>
+            Task.FromResult(Values.TryGetValue(payload.Id(), out var value) ? payload.NormalizeValue(value) : null);
+
+        public Task WriteAsync(ProfilePayload payload, string? value, CancellationToken cancellationToken)
+        {
+            Writes++;
+            if (value == null)
+                Values.Remove(payload.Id());
+            else
+                Values[payload.Id()] = value;
+            return Task.CompletedTask;
+        }
+    }
+
+    private ManagedProfilesStore Store() => new(Path.Combine(TestDir, "managed_profiles.json"));
+
+    private ProfileService Service(ManagedProfilesStore? store = null) => new(
+        new CimianConfig { SoftwareRepoURL = "https://repo.example.com" },
+        new ScriptService(),
+        new HttpClient(),
+        store ?? Store(),
+        new Dictionary<string, IProfilePayloadHandler> { ["registry"] = _handler },
+        Path.Combine(TestDir, "profiles"));
+
+    private static ProfilePayload Dword(string name, string value) => new()
+    {
+        Type = "registry",
+        Key = @"HKLM\SOFTWARE\Policies\Contoso",
+        Name = name,
+        ValueType = "dword",
+        Value = value
+    };
+
+    private static ManagedProfile Profile(string name, params ProfilePayload[] payloads) =>
+        new() { Name = name, Version = "1", Payloads = payloads.ToList() };
+
+    [Fact]
+    public async Task ApplyAsync_WritesOnlyWhatDiffers()
+    {
+        _handler.Values[Dword("A", "1").Id()] = "1";
+
+        var outcome = Assert.Single(await Service().ApplyAsync([Profile("Baseline", Dword("A", "1"), Dword("B", "0x2"))], false, Now, default));
+
+        Assert.Equal(ProfileOutcome.Applied, outcome.Action);
+        Assert.True(outcome.Success);
+        Assert.Equal(1, _handler.Writes);
+        Assert.Equal("2", _handler.Values[Dword("B", "2").Id()]);
+
+        var record = Store().Get("baseline")!;
+        Assert.True(record.Compliant);
+        Assert.Equal(Now, record.AppliedAt);
+        Assert.Equal(2, record.Payloads.Count);
+    }
+
+    [Fact]
+    public async Task ApplyAsync_CompliantMachineIsLeftAlone()
+    {
+        _handler.Values[Dword("A", "1").Id()] = "0x1";
+
+        var outcome = Assert.Single(await Service().ApplyAsync([Profile("Baseline", Dword("A", "1"))], false, Now, default));
+
+        Assert.Equal(ProfileOutcome.Compliant, outcome.Action);
+        Assert.Equal(0, _handler.Writes);
+    }
+
+    [Fact]
+    public async Task ApplyAsync_CheckOnlyWritesNothing()
+    {
+        var outcome = Assert.Single(await Service().ApplyAsync([Profile("Baseline", Dword("A", "1"))], true, Now, default));
+
+        Assert.Equal(ProfileOutcome.WouldApply, outcome.Action);
+        Assert.Single(outcome.Changes);
+        Assert.Equal(0, _handler.Writes);
+        Assert.Empty(Store().All());
+    }
+
+    [Fact]
+    public async Task ApplyAsync_SecondProfileClaimingASettingSkipsIt()
+    {
+        var outcomes = await Service().ApplyAsync(
+            [Profile("First", Dword("A", "1")), Profile("Second", Dword("A", "0"))], false, Now, default);
+
+        Assert.Equal(ProfileOutcome.Applied, outcomes[0].Action);
+        Assert.Equal(ProfileOutcome.Compliant, outcomes[1].Action);
+        Assert.Equal("1", _handler.Values[Dword("A", "1").Id()]);
+    }
+
+    [Fact]
+    public async Task ApplyAsync_InvalidPayloadFails()
+    {
+        var payload = Dword("A", "1");
+        payload.Name = null;
+
+        var outcome = Assert.Single(await Service().ApplyAsync([Profile("Baseline", payload)], false, Now, default));
+
+        Assert.False(outcome.Success);
+        Assert.False(Store().Get("Baseline")!.Compliant);
+    }
+
+    [Fact]
+    public async Task ApplyAsync_SettingDroppedFromProfileIsRestored()
+    {
+        _handler.Values[Dword("B", "5").Id()] = "5";
+        await Service().ApplyAsync([Profile("Baseline", Dword("A", "1"), Dword("B", "9"))], false, Now, default);
+
+        await Service().ApplyAsync([Profile("Baseline", Dword("A", "1"))], false, Now, default);
+
+        Assert.Equal("5", _handler.Values[Dword("B", "5").Id()]);
+        Assert.Single(Store().Get("Baseline")!.Payloads);
+    }
+
+    [Fact]
+    public async Task RemoveAsync_RestoresPreviousValues()
+    {
+        _handler.Values[Dword("B", "5").Id()] = "5";
+        await Service().ApplyAsync([Profile("Baseline", Dword("A", "1"), Dword("B", "9"))], false, Now, default);
+
+        var outcome = Assert.Single(await Service().RemoveAsync([], false, default));
+
+        Assert.Equal(ProfileOutcome.Removed, outcome.Action);
+        Assert.False(_handler.Values.ContainsKey(Dword("A", "1").Id()));
+        Assert.Equal("5", _handler.Values[Dword("B", "5").Id()]);
+        Assert.Empty(Store().All());
+    }
+
+    [Fact]
+    public async Task RemoveAsync_KeepsProfilesStillListed()
+    {
+        await Service().ApplyAsync([Profile("Baseline", Dword("A", "1"))], false, Now, default);
+
+        Assert.Empty(await Service().RemoveAsync(["baseline"], false, default));
+        Assert.Equal("1", _handler.Values[Dword("A", "1").Id()]);
+    }
+
+    [Theory]
+    [InlineData("dword", "0x10", "16")]
+    [InlineData("dword", "True", "true")]
+    [InlineData("string", "0x10", "0x10")]
+    public void NormalizeValue_ComparesNumbersAndBooleans(string valueType, string value, string expected)
+    {
+        Assert.Equal(expected, new ProfilePayload { Type = "registry", ValueType = valueType }.NormalizeValue(value));
+    }
+}
@@ -0,0 +1,86 @@
+using Microsoft.Win32;
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for RegistryPolFile - the PReg format and gpt.ini versioning.
+/// </summary>
+public class RegistryPolFileTests
+{
+    private const string PersonalizationKey = @"Software\Policies\Microsoft\Windows\Personalization";
+
+    [Fact]
+    public void ToBytes_RoundTripsThroughParse()
+    {
+        var file = new RegistryPolFile();
+        file.Set(PersonalizationKey, "NoLockScreen", RegistryValueKind.DWord, RegistryPolFile.Encode(RegistryValueKind.DWord, "1"));
+        file.Set(@"Software\Policies\Google\Chrome", "HomepageLocation", RegistryValueKind.String,
+            RegistryPolFile.Encode(RegistryValueKind.String, "https://intranet"));
+
+        var parsed = RegistryPolFile.Parse(file.ToBytes());
+
+        Assert.Equal(2, parsed.Entries.Count);
+        var entry = parsed.Find(PersonalizationKey.ToUpperInvariant(), "nolockscreen")!;
+        Assert.Equal(RegistryValueKind.DWord, entry.Kind);
+        Assert.Equal("1", RegistryPolFile.Decode(entry.Kind, entry.Data));
+    }
+
+    [Fact]
+    public void ToBytes_StartsWithPRegHeader()
+    {
+        var bytes = new RegistryPolFile().ToBytes();
+
+        Assert.Equal(new byte[] { 0x50, 0x52, 0x65, 0x67, 1, 0, 0, 0 }, bytes);
+    }
+
+    [Fact]
+    public void Parse_RejectsAnotherFormat()
+    {
+        Assert.Throws<InvalidDataException>(() => RegistryPolFile.Parse("[General]"u8.ToArray()));
+    }
+
+    [Fact]
+    public void Set_ReplacesExistingValue()
+    {
+        var file = new RegistryPolFile();
+        file.Set(PersonalizationKey, "NoLockScreen", RegistryValueKind.DWord, RegistryPolFile.Encode(RegistryValueKind.DWord, "1"));
+        file.Set(PersonalizationKey, "NoLockScreen", RegistryValueKind.DWord, RegistryPolFile.Encode(RegistryValueKind.DWord, "0"));
+
+        var entry = Assert.Single(file.Entries);
+        Assert.Equal("0", RegistryPolFile.Decode(entry.Kind, entry.Data));
+        Assert.True(file.Remove(PersonalizationKey, "NoLockScreen"));
+        Assert.Empty(file.Entries);
+    }
+
+    [Theory]
+    [InlineData(RegistryValueKind.DWord, "0x10", "16")]
+    [InlineData(RegistryValueKind.QWord, "5000000000", "5000000000")]
+    [InlineData(RegistryValueKind.MultiString, "a\nb", "a\nb")]
+    [InlineData(RegistryValueKind.ExpandString, @"%ProgramFiles%\App", @"%ProgramFiles%\App")]
+    public void EncodeDecode_RoundTrips(RegistryValueKind kind, string value, string expected)
+    {
+        Assert.Equal(expected, RegistryPolFile.Decode(kind, RegistryPolFile.Encode(kind, value)));
+    }
+
+    [Fact]
+    public void UpdateGptIni_CreatesMachineEntry()
+    {
+        var ini = RegistryPolFile.UpdateGptIni(null, "machine");
+
+        Assert.Contains("[General]", ini);
+        Assert.Contains("gPCMachineExtensionNames=[{35378EAC-683F-11D2-A89A-00C04FBBCFA2}{D02B1F72-3407-48AE-BA88-E8213C6761F1}]", ini);
+        Assert.Contains("Version=1\r\n", ini);
+    }
+
+    [Fact]
+    public void UpdateGptIni_BumpsUserHalfOfVersion()
+    {
+        var ini = RegistryPolFile.UpdateGptIni("[General]\r\nVersion=65539\r\n", "user");
+
+        // user 1 -> 2 in the high word, machine 3 unchanged
+        Assert.Contains($"Version={(2 << 16) | 3}\r\n", ini);
+        Assert.Contains("gPCUserExtensionNames=", ini);
+    }
+}
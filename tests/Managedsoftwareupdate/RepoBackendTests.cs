@@ -0,0 +1,103 @@
+using System.Net;
+using Azure.Core;
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for the S3 and Azure Blob repo backends (RepoBackend: s3 / azblob).
+/// </summary>
+public class RepoBackendTests
+{
+    private const string S3Host = "cimian-repo.s3.eu-west-1.amazonaws.com";
+    private static readonly S3Credentials Credentials = new("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", null);
+
+    [Theory]
+    [InlineData("https://cimian-repo.s3.eu-west-1.amazonaws.com/", null, "eu-west-1")]
+    [InlineData("https://s3.ap-southeast-2.amazonaws.com/cimian-repo/", null, "ap-southeast-2")]
+    [InlineData("https://cimian-repo.s3-us-west-2.amazonaws.com/", null, "us-west-2")]
+    [InlineData("https://cimian-repo.s3.amazonaws.com/", null, "us-east-1")]
+    [InlineData("https://minio.corp.example.com/cimian/", "eu-central-1", "eu-central-1")]
+    public void ResolveRegion_PrefersConfigThenHostName(string url, string? configured, string expected)
+    {
+        Assert.Equal(expected, S3SigningHandler.ResolveRegion(new Uri(url), configured));
+    }
+
+    [Fact]
+    public void Sign_ProducesSigV4Authorization()
+    {
+        var request = new HttpRequestMessage(HttpMethod.Get, $"https://{S3Host}/pkgs/Google%20Chrome/chrome+1.msi");
+
+        S3SigningHandler.Sign(request, Credentials, "eu-west-1", new DateTime(2026, 10, 16, 9, 0, 0, DateTimeKind.Utc));
+
+        Assert.Equal(
+            "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20261016/eu-west-1/s3/aws4_request, " +
+            "SignedHeaders=host;x-amz-content-sha256;x-amz-date, " +
+            "Signature=db80afe562f0e2e075aac7d0454fb609030c9319bbd336bba4d4523c1eeb259c",
+            request.Headers.GetValues("Authorization").Single());
+        Assert.Equal("20261016T090000Z", request.Headers.GetValues("x-amz-date").Single());
+    }
+
+    [Fact]
+    public void Sign_IncludesSessionTokenForTemporaryCredentials()
+    {
+        var request = new HttpRequestMessage(HttpMethod.Get, $"https://{S3Host}/catalogs/Production.yaml");
+
+        S3SigningHandler.Sign(request, Credentials with { SessionToken = "token" }, "eu-west-1", DateTime.UtcNow);
+
+        Assert.Equal("token", request.Headers.GetValues("x-amz-security-token").Single());
+        Assert.Contains("SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token", request.Headers.GetValues("Authorization").Single());
+    }
+
+    [Fact]
+    public async Task S3SigningHandler_OnlySignsRepoRequests()
+    {
+        var inner = new RecordingHandler();
+        using var client = new HttpClient(new S3SigningHandler([S3Host], null, _ => Task.FromResult(Credentials)) { InnerHandler = inner });
+
+        await client.GetAsync($"https://{S3Host}/manifests/site_default.yaml");
+        await client.GetAsync("https://cdn.example.com/chrome.msi");
+
+        Assert.True(inner.Requests[0].Headers.Contains("Authorization"));
+        Assert.False(inner.Requests[1].Headers.Contains("Authorization"));
+    }
+
+    [Fact]
+    public async Task AzureBlobAuthHandler_SendsBearerTokenOrSas()
+    {
+        const string host = "cimianrepo.blob.core.windows.net";
+        var tokenInner = new RecordingHandler();
+        var sasInner = new RecordingHandler();
+        using var tokenClient = new HttpClient(new AzureBlobAuthHandler([host], null, null, new FakeCredential()) { InnerHandler = tokenInner });
+        using var sasClient = new HttpClient(new AzureBlobAuthHandler([host], "?sv=2023-11-03&sig=abc", null) { InnerHandler = sasInner });
+
+        await tokenClient.GetAsync($"https://{host}/cimian/catalogs/Production.yaml");
+        await sasClient.GetAsync($"https://{host}/cimian/catalogs/Production.yaml");
+
+        Assert.Equal("Bearer fake-token", tokenInner.Requests[0].Headers.Authorization?.ToString());
+        Assert.Equal(AzureBlobAuthHandler.StorageApiVersion, tokenInner.Requests[0].Headers.GetValues("x-ms-version").Single());
+        Assert.Null(sasInner.Requests[0].Headers.Authorization);
+        Assert.Equal("?sv=2023-11-03&sig=abc", sasInner.Requests[0].RequestUri!.Query);
+    }
+
+    private sealed class RecordingHandler : HttpMessageHandler
+    {
+        public List<HttpRequestMessage> Requests { get; } = new();
+
+        protected override Task<HttpResponseMessage> SendAsync(HttpRequestMessage request, CancellationToken cancellationToken)
+        {
+            Requests.Add(request);
+            return Task.FromResult(new HttpResponseMessage(HttpStatusCode.OK) { Content = new StringContent("") });
+        }
+    }
+
+    private sealed class FakeCredential : TokenCredential
+    {
+        public override AccessToken GetToken(TokenRequestContext requestContext, CancellationToken cancellationToken) =>
+            new("fake-token", DateTimeOffset.UtcNow.AddHours(1));
+
+        public override ValueTask<AccessToken> GetTokenAsync(TokenRequestContext requestContext, CancellationToken cancellationToken) =>
+            new(GetToken(requestContext, cancellationToken));
+    }
+}
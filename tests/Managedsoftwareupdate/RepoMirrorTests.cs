@@ -0,0 +1,120 @@
+using System.Net;
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for SoftwareRepoURLs mirror ordering and failover.
+/// </summary>
+public class RepoMirrorTests
+{
+    private const string Primary = "https://repo.example.com/cimian";
+    private const string Secondary = "https://cdn.example.net/cimian";
+
+    [Fact]
+    public void Configured_PutsSoftwareRepoURLFirstWithoutDuplicates()
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = Primary + "/",
+            SoftwareRepoURLs = [Primary, Secondary, " "]
+        };
+
+        Assert.Equal([Primary, Secondary], RepoMirrorSet.Configured(config));
+    }
+
+    [Fact]
+    public void OrderByHealth_MovesUnhealthyMirrorsToTheBack()
+    {
+        var mirrors = new[] { "a", "b", "c" };
+
+        var ordered = RepoMirrorSet.OrderByHealth(mirrors, new HashSet<string> { "a" });
+
+        Assert.Equal(["b", "c", "a"], ordered);
+    }
+
+    [Fact]
+    public void RelativePath_IsNullOutsideTheRepo()
+    {
+        var set = new RepoMirrorSet([Primary, Secondary]);
+
+        Assert.Equal("catalogs/Production.yaml", set.RelativePath(new Uri($"{Secondary}/catalogs/Production.yaml")));
+        Assert.Null(set.RelativePath(new Uri("https://downloads.example.org/chrome.msi")));
+    }
+
+    [Fact]
+    public async Task Handler_FailsOverOnServerErrorAndRecordsServingMirror()
+    {
+        var set = new RepoMirrorSet([Primary, Secondary]);
+        var inner = new StubHandler(uri => uri.StartsWith(Primary) && uri.EndsWith(".yaml") ? HttpStatusCode.ServiceUnavailable : HttpStatusCode.OK);
+        using var client = new HttpClient(new MirrorFailoverHandler(set) { InnerHandler = inner });
+
+        var response = await client.GetAsync($"{Primary}/catalogs/Production.yaml");
+
+        Assert.Equal(HttpStatusCode.OK, response.StatusCode);
+        Assert.Equal(Secondary, set.ServedBy["catalogs/Production.yaml"]);
+        Assert.Equal([Secondary, Primary], set.Order);
+    }
+
+    [Fact]
+    public async Task Handler_DoesNotFailOverOnNotFound()
+    {
+        var set = new RepoMirrorSet([Primary, Secondary]);
+        var inner = new StubHandler(uri => uri.EndsWith(".yaml") ? HttpStatusCode.NotFound : HttpStatusCode.OK);
+        using var client = new HttpClient(new MirrorFailoverHandler(set) { InnerHandler = inner });
+
+        var response = await client.GetAsync($"{Primary}/manifests/missing.yaml");
+
+        Assert.Equal(HttpStatusCode.NotFound, response.StatusCode);
+        Assert.Single(inner.Requests, r => r.EndsWith(".yaml"));
+        Assert.Equal([Primary, Secondary], set.Order);
+    }
+
+    [Fact]
+    public async Task Handler_PassesNonRepoRequestsThrough()
+    {
+        var set = new RepoMirrorSet([Primary, Secondary]);
+        var inner = new StubHandler(_ => HttpStatusCode.ServiceUnavailable);
+        using var client = new HttpClient(new MirrorFailoverHandler(set) { InnerHandler = inner });
+
+        var response = await client.GetAsync("https://downloads.example.org/chrome.msi");
+
+        Assert.Equal(HttpStatusCode.ServiceUnavailable, response.StatusCode);
+        Assert.Equal(["https://downloads.example.org/chrome.msi"], inner.Requests);
+    }
+
+    [Fact]
+    public void ReportBadContent_DemotesTheMirrorThatServedIt()
+    {
+        var set = new RepoMirrorSet([Primary, Secondary]);
+        set.RecordServed("pkgs/chrome.msi", Primary);
+
+        Assert.True(set.ReportBadContent($"{Primary}/pkgs/chrome.msi", "hash mismatch"));
+        Assert.Equal([Secondary, Primary], set.Order);
+    }
+
+    [Fact]
+    public void ReportBadContent_IsFalseWithASingleMirror()
+    {
+        var set = new RepoMirrorSet([Primary]);
+
+        Assert.False(set.ReportBadContent($"{Primary}/pkgs/chrome.msi", "hash mismatch"));
+    }
+
+    private sealed class StubHandler(Func<string, HttpStatusCode> status) : HttpMessageHandler
+    {
+        public List<string> Requests { get; } = new();
+
+        protected override Task<HttpResponseMessage> SendAsync(HttpRequestMessage request, CancellationToken cancellationToken)
+        {
+            var uri = request.RequestUri!.ToString();
+            lock (Requests)
+            {
+                Requests.Add(uri);
+            }
+            return Task.FromResult(new HttpResponseMessage(status(uri)) { Content = new StringContent("") });
+        }
+    }
+}
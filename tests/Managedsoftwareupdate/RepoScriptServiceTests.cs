@@ -0,0 +1,120 @@
+using System.Net;
+using System.Security.Cryptography;
+using System.Text;
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for RepoScriptService - fetching scripts/ from the repo with hash pinning.
+/// </summary>
+public class RepoScriptServiceTests : IDisposable
+{
+    private const string Repo = "https://repo.example.com";
+    private const string Script = "Write-Output 'checked'";
+
+    private readonly string _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "RepoScripts", Guid.NewGuid().ToString());
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    private RepoScriptService CreateService(StubHandler handler, bool requireHash = true) =>
+        new(new CimianConfig { SoftwareRepoURL = Repo, RequireHashValidation = requireHash },
+            new ScriptService(), new HttpClient(handler), _testDir);
+
+    private static string Sha256(string content) =>
+        Convert.ToHexString(SHA256.HashData(Encoding.UTF8.GetBytes(content))).ToLowerInvariant();
+
+    [Theory]
+    [InlineData("check-vpn.ps1", true)]
+    [InlineData("network/check-vpn.ps1", true)]
+    [InlineData("network\\check-vpn.PS1", true)]
+    [InlineData("../check-vpn.ps1", false)]
+    [InlineData("network/../../check-vpn.ps1", false)]
+    [InlineData("/check-vpn.ps1", false)]
+    [InlineData("C:\\scripts\\check-vpn.ps1", false)]
+    [InlineData("check-vpn.bat", false)]
+    [InlineData("", false)]
+    public void IsSafeName_OnlyAllowsPs1InsideScripts(string name, bool expected)
+    {
+        Assert.Equal(expected, RepoScriptService.IsSafeName(name));
+    }
+
+    [Fact]
+    public async Task FetchAsync_DownloadsAndVerifiesPinnedScript()
+    {
+        var handler = new StubHandler(_ => (HttpStatusCode.OK, Script));
+
+        var (path, error) = await CreateService(handler).FetchAsync(
+            new RepoScript { Name = "network/check-vpn.ps1", Hash = Sha256(Script).ToUpperInvariant() }, default);
+
+        Assert.Null(error);
+        Assert.Equal(Script, File.ReadAllText(path!));
+        Assert.Equal([$"{Repo}/scripts/network/check-vpn.ps1"], handler.RequestedUrls);
+    }
+
+    [Fact]
+    public async Task FetchAsync_RejectsHashMismatch()
+    {
+        var handler = new StubHandler(_ => (HttpStatusCode.OK, "Remove-Item C:\\ -Recurse"));
+
+        var (path, error) = await CreateService(handler).FetchAsync(
+            new RepoScript { Name = "check-vpn.ps1", Hash = Sha256(Script) }, default);
+
+        Assert.Null(path);
+        Assert.Contains("hash mismatch", error);
+        Assert.False(File.Exists(Path.Combine(_testDir, "check-vpn.ps1")));
+    }
+
+    [Fact]
+    public async Task FetchAsync_ReusesMatchingCachedCopyWithoutDownloading()
+    {
+        Directory.CreateDirectory(_testDir);
+        File.WriteAllText(Path.Combine(_testDir, "check-vpn.ps1"), Script);
+        var handler = new StubHandler(_ => (HttpStatusCode.InternalServerError, ""));
+
+        var (path, error) = await CreateService(handler).FetchAsync(
+            new RepoScript { Name = "check-vpn.ps1", Hash = Sha256(Script) }, default);
+
+        Assert.Null(error);
+        Assert.NotNull(path);
+        Assert.Empty(handler.RequestedUrls);
+    }
+
+    [Fact]
+    public async Task FetchAsync_UnpinnedScriptNeedsHashValidationOff()
+    {
+        var handler = new StubHandler(_ => (HttpStatusCode.OK, Script));
+        var script = new RepoScript { Name = "check-vpn.ps1" };
+
+        var (refused, error) = await CreateService(handler).FetchAsync(script, default);
+        var (allowed, _) = await CreateService(handler, requireHash: false).FetchAsync(script, default);
+
+        Assert.Null(refused);
+        Assert.Contains("RequireHashValidation", error);
+        Assert.NotNull(allowed);
+    }
+
+    private sealed class StubHandler : HttpMessageHandler
+    {
+        private readonly Func<string, (HttpStatusCode Status, string Body)> _responder;
+        public List<string> RequestedUrls { get; } = new();
+
+        public StubHandler(Func<string, (HttpStatusCode, string)> responder)
+        {
+            _responder = responder;
+        }
+
+        protected override Task<HttpResponseMessage> SendAsync(HttpRequestMessage request, CancellationToken cancellationToken)
+        {
+            var url = request.RequestUri!.ToString();
+            RequestedUrls.Add(url);
+            var (status, body) = _responder(url);
+            return Task.FromResult(new HttpResponseMessage(status) { Content = new StringContent(body) });
+        }
+    }
+}
@@ -0,0 +1,142 @@
+using System.Net;
+using System.Text.Json.Nodes;
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for ReportUploader: the ReportURL payload and the offline queue.
+/// </summary>
+public class ReportUploaderTests : IDisposable
+{
+    private readonly string _testDir;
+    private readonly string _sessionDir;
+    private readonly string _reportsDir;
+    private readonly string _queueDir;
+    private readonly CimianConfig _config = new()
+    {
+        SoftwareRepoURL = "https://repo.example.com/cimian",
+        ReportURL = "https://reports.example.com/api/cimian",
+        ClientIdentifier = "LAB-PC-01"
+    };
+
+    public ReportUploaderTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "ReportUploader", Guid.NewGuid().ToString());
+        _sessionDir = Path.Combine(_testDir, "logs", "2026-10-16", "0900");
+        _reportsDir = Path.Combine(_testDir, "reports");
+        _queueDir = Path.Combine(_testDir, "report_queue");
+        Directory.CreateDirectory(_sessionDir);
+        Directory.CreateDirectory(_reportsDir);
+
+        File.WriteAllText(Path.Combine(_sessionDir, "session.json"), """{"session_id":"2026-10-16-0900","status":"partial_failure"}""");
+        File.WriteAllLines(Path.Combine(_sessionDir, "events.jsonl"),
+        [
+            """{"level":"INFO","event_type":"install","status":"completed","package_name":"Chrome"}""",
+            """{"level":"ERROR","event_type":"install","status":"failed","package_name":"Zoom"}""",
+            """{"level":"INFO","event_type":"ins"""
+        ]);
+        File.WriteAllText(Path.Combine(_reportsDir, "facts.json"), """{"hostname":"LAB-PC-01"}""");
+        File.WriteAllText(Path.Combine(_reportsDir, "items.json"), """[{"name":"Chrome"}]""");
+    }
+
+    public void Dispose()
+    {
+        try
+        {
+            if (Directory.Exists(_testDir))
+            {
+                Directory.Delete(_testDir, recursive: true);
+            }
+        }
+        catch { /* Ignore cleanup errors */ }
+    }
+
+    private ReportUploader Uploader(StubServer server) =>
+        new(_config, new HttpClient(server), _queueDir, _reportsDir, TimeSpan.Zero);
+
+    [Fact]
+    public void BuildPayload_CombinesSessionInventoryItemsAndErrors()
+    {
+        var payload = Uploader(new StubServer(HttpStatusCode.OK)).BuildPayload(_sessionDir)!;
+
+        Assert.Equal("LAB-PC-01", payload["machine"]!.GetValue<string>());
+        Assert.Equal("2026-10-16-0900", payload["session_id"]!.GetValue<string>());
+        Assert.Equal("partial_failure", payload["session"]!["status"]!.GetValue<string>());
+        Assert.Equal("LAB-PC-01", payload["inventory"]!["hostname"]!.GetValue<string>());
+        Assert.Single(payload["items"]!.AsArray());
+        Assert.Null(payload["usage"]);
+        var error = Assert.Single(payload["errors"]!.AsArray());
+        Assert.Equal("Zoom", error!["package_name"]!.GetValue<string>());
+    }
+
+    [Fact]
+    public async Task Upload_PostsAndEmptiesQueue()
+    {
+        var server = new StubServer(HttpStatusCode.OK);
+
+        await Uploader(server).UploadAsync(_sessionDir);
+
+        var body = Assert.Single(server.Bodies);
+        Assert.Equal("2026-10-16-0900", JsonNode.Parse(body)!["session_id"]!.GetValue<string>());
+        Assert.Empty(Directory.GetFiles(_queueDir));
+    }
+
+    [Fact]
+    public async Task Upload_KeepsReportQueuedWhileServerIsDown()
+    {
+        await Uploader(new StubServer(HttpStatusCode.ServiceUnavailable)).UploadAsync(_sessionDir);
+        Assert.Single(Directory.GetFiles(_queueDir));
+
+        var server = new StubServer(HttpStatusCode.OK);
+        await Uploader(server).UploadAsync(sessionDir: null);
+
+        Assert.Single(server.Bodies);
+        Assert.Empty(Directory.GetFiles(_queueDir));
+    }
+
+    [Fact]
+    public async Task Upload_KeepsReportQueuedOnAuthFailure()
+    {
+        await Uploader(new StubServer(HttpStatusCode.Unauthorized)).UploadAsync(_sessionDir);
+
+        Assert.Single(Directory.GetFiles(_queueDir));
+    }
+
+    [Fact]
+    public async Task Upload_DropsReportTheServerRejects()
+    {
+        await Uploader(new StubServer(HttpStatusCode.BadRequest)).UploadAsync(_sessionDir);
+
+        Assert.Empty(Directory.GetFiles(_queueDir));
+    }
+
+    [Fact]
+    public async Task Upload_SendsReportAuthTokenAsBearer()
+    {
+        _config.ReportAuthToken = "report-token";
+        var server = new StubServer(HttpStatusCode.OK);
+
+        await Uploader(server).UploadAsync(_sessionDir);
+
+        Assert.Equal("Bearer report-token", Assert.Single(server.Authorizations));
+    }
+
+    private sealed class StubServer(HttpStatusCode status) : HttpMessageHandler
+    {
+        public List<string> Bodies { get; } = new();
+        public List<string?> Authorizations { get; } = new();
+
+        protected override async Task<HttpResponseMessage> SendAsync(HttpRequestMessage request, CancellationToken cancellationToken)
+        {
+            Authorizations.Add(request.Headers.Authorization?.ToString());
+            if (status == HttpStatusCode.OK)
+            {
+                Bodies.Add(await request.Content!.ReadAsStringAsync(cancellationToken));
+            }
+            return new HttpResponseMessage(status);
+        }
+    }
+}
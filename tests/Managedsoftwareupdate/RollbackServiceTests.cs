@@ -0,0 +1,122 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for RollbackService - snapshotting the replaced version and consuming it on rollback.
+/// </summary>
+public class RollbackServiceTests : IDisposable
+{
+    private readonly string _testDir;
+    private readonly RollbackService _service;
+
+    public RollbackServiceTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "Rollback", Guid.NewGuid().ToString());
+        _service = new RollbackService(_testDir);
+    }
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    private static CatalogItem MakeItem(string version, string? productCode = null) => new()
+    {
+        Name = "Firefox",
+        Version = version,
+        Installer = new InstallerInfo { Type = "msi", Location = $"apps/Firefox-{version}.msi", ProductCode = productCode }
+    };
+
+    [Fact]
+    public void TakeSnapshot_AfterRecordInstalled_KeepsPreviousPkginfoAndInstaller()
+    {
+        _service.RecordInstalled(MakeItem("120.0", "{AAAA}"), @"C:\cache\Firefox-120.0.msi");
+
+        var snapshot = _service.TakeSnapshot(MakeItem("121.0"), "120.0",
+            new Dictionary<string, string> { ["Version"] = "120.0" });
+
+        Assert.NotNull(snapshot);
+        Assert.Equal("120.0", snapshot!.Version);
+        Assert.Equal("121.0", snapshot.ReplacedBy);
+        Assert.Equal("{AAAA}", snapshot.ProductCode);
+        Assert.Equal(@"C:\cache\Firefox-120.0.msi", snapshot.CachedInstaller);
+        Assert.Equal("120.0", snapshot.Receipt["Version"]);
+
+        var previous = RollbackService.DeserializeItem(snapshot.CatalogItem);
+        Assert.NotNull(previous);
+        Assert.Equal("120.0", previous!.Version);
+        Assert.Equal("apps/Firefox-120.0.msi", previous.Installer.Location);
+    }
+
+    [Fact]
+    public void TakeSnapshot_RecordedVersionDiffers_KeepsReceiptOnly()
+    {
+        _service.RecordInstalled(MakeItem("119.0"), @"C:\cache\Firefox-119.0.msi");
+
+        var snapshot = _service.TakeSnapshot(MakeItem("121.0"), "120.0");
+
+        Assert.NotNull(snapshot);
+        Assert.Null(snapshot!.CatalogItem);
+        Assert.Null(snapshot.CachedInstaller);
+    }
+
+    [Fact]
+    public void TakeSnapshot_SameVersion_IsSkipped()
+    {
+        Assert.Null(_service.TakeSnapshot(MakeItem("120.0"), "120.0"));
+        Assert.Null(_service.TakeSnapshot(MakeItem("120.0"), ""));
+    }
+
+    [Fact]
+    public void TakeSnapshot_KeepsOnlyNewestSnapshots()
+    {
+        for (var v = 1; v <= RollbackService.MaxSnapshotsPerItem + 2; v++)
+        {
+            _service.TakeSnapshot(MakeItem($"{v + 1}.0"), $"{v}.0");
+        }
+
+        var state = _service.Load("Firefox");
+        Assert.Equal(RollbackService.MaxSnapshotsPerItem, state.Snapshots.Count);
+        Assert.Equal($"{RollbackService.MaxSnapshotsPerItem + 2}.0", state.Snapshots[^1].Version);
+    }
+
+    [Fact]
+    public void RecordRollback_Success_ConsumesSnapshotAndRecordsHistory()
+    {
+        _service.RecordInstalled(MakeItem("120.0"), @"C:\cache\Firefox-120.0.msi");
+        var snapshot = _service.TakeSnapshot(MakeItem("121.0"), "120.0")!;
+        _service.RecordInstalled(MakeItem("121.0"), @"C:\cache\Firefox-121.0.msi");
+
+        _service.RecordRollback("Firefox", snapshot, "121.0", true, "cache", "ok", "session-1");
+
+        var state = _service.Load("firefox");
+        Assert.Empty(state.Snapshots);
+        Assert.Equal("120.0", RollbackService.DeserializeItem(state.InstalledItem)!.Version);
+        var entry = Assert.Single(state.History);
+        Assert.Equal("121.0", entry.FromVersion);
+        Assert.Equal("120.0", entry.ToVersion);
+        Assert.Equal("completed", entry.Status);
+        Assert.Equal("cache", entry.Source);
+    }
+
+    [Fact]
+    public void RecordRollback_Failure_KeepsSnapshotForRetry()
+    {
+        var snapshot = _service.TakeSnapshot(MakeItem("121.0"), "120.0")!;
+
+        _service.RecordRollback("Firefox", snapshot, "121.0", false, "repo", "download failed", null);
+
+        var state = _service.Load("Firefox");
+        Assert.Single(state.Snapshots);
+        Assert.Equal("failed", Assert.Single(state.History).Status);
+    }
+
+    [Fact]
+    public void GetLatestSnapshot_NoState_ReturnsNull()
+    {
+        Assert.Null(_service.GetLatestSnapshot("NeverInstalled"));
+    }
+}
@@ -1,4 +1,5 @@
 using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
 using Cimian.CLI.managedsoftwareupdate.Services;
 
 namespace Cimian.Tests.Managedsoftwareupdate;
@@ -335,4 +336,119 @@ Write-Output 'CIMIAN-WARNING: second-reason'
     }
 
     #endregion
+
+    #region Script Environment Tests
+
+    [Fact]
+    public void BuildEnvironment_NoSessionOrItem_HasMachineFactsOnly()
+    {
+        var env = ScriptService.BuildEnvironment(null, null);
+
+        Assert.Equal(CatalogService.GetSystemArchitecture(), env["CIMIAN_ARCH"]);
+        Assert.Equal(Environment.OSVersion.Version.ToString(), env["CIMIAN_OS_BUILD"]);
+        Assert.False(env.ContainsKey("CIMIAN_SESSION_ID"));
+        Assert.False(env.ContainsKey("CIMIAN_ITEM_NAME"));
+    }
+
+    [Fact]
+    public void BuildEnvironment_WithSessionAndItem_HasAllVariables()
+    {
+        var session = new ScriptSessionInfo("2026-10-16-090000", "auto", @"C:\ProgramData\ManagedInstalls\Cache", "https://cimian.example.com");
+        var item = new CatalogItem { Name = "Chrome", Version = "130.0" };
+
+        var env = ScriptService.BuildEnvironment(session, item);
+
+        Assert.Equal("Chrome", env["CIMIAN_ITEM_NAME"]);
+        Assert.Equal("130.0", env["CIMIAN_ITEM_VERSION"]);
+        Assert.Equal("2026-10-16-090000", env["CIMIAN_SESSION_ID"]);
+        Assert.Equal("auto", env["CIMIAN_RUN_TYPE"]);
+        Assert.Equal(@"C:\ProgramData\ManagedInstalls\Cache", env["CIMIAN_CACHE_PATH"]);
+        Assert.Equal("https://cimian.example.com", env["CIMIAN_REPO_URL"]);
+    }
+
+    [Fact]
+    public async Task ExecuteScriptAsync_ForItem_SeesItemVariables()
+    {
+        var item = new CatalogItem { Name = "Chrome", Version = "130.0" };
+
+        var (success, output) = await _service.ForItem(item).ExecuteScriptAsync(
+            "Write-Output \"$env:CIMIAN_ITEM_NAME/$env:CIMIAN_ITEM_VERSION\"");
+
+        Assert.True(success);
+        Assert.Contains("Chrome/130.0", output);
+    }
+
+    #endregion
+
+    #region Script Policy Tests
+
+    [Fact]
+    public void ScriptSettings_Defaults_FailBeforeAndWarnAfter()
+    {
+        var settings = ScriptSettings.From(new CimianConfig { ScriptTimeout = 120, InstallerTimeout = 900 });
+
+        Assert.Equal(new ResolvedScriptPolicy(TimeSpan.FromSeconds(120), false, ScriptFailureAction.Fail), settings.Resolve(ScriptKind.Preinstall));
+        Assert.Equal(new ResolvedScriptPolicy(TimeSpan.FromSeconds(120), false, ScriptFailureAction.Warn), settings.Resolve(ScriptKind.Postinstall));
+        Assert.Equal(TimeSpan.FromSeconds(900), settings.Resolve(ScriptKind.Install).Timeout);
+        Assert.Equal(ScriptFailureAction.Ignore, settings.Resolve(ScriptKind.Installcheck).OnFailure);
+    }
+
+    [Fact]
+    public void ScriptSettings_PoliciesOverrideDefaults()
+    {
+        var settings = ScriptSettings.From(new CimianConfig
+        {
+            ScriptTimeout = 300,
+            ScriptConstrainedLanguage = true,
+            ScriptPolicies = new()
+            {
+                ["PreInstall"] = new ScriptPolicy { Timeout = 0, OnFailure = "Warn" },
+                ["installcheck"] = new ScriptPolicy { Timeout = 30, ConstrainedLanguage = false },
+                ["postinstall"] = new ScriptPolicy { OnFailure = "bogus" }
+            }
+        });
+
+        Assert.Equal(new ResolvedScriptPolicy(Timeout.InfiniteTimeSpan, true, ScriptFailureAction.Warn), settings.Resolve(ScriptKind.Preinstall));
+        Assert.Equal(new ResolvedScriptPolicy(TimeSpan.FromSeconds(30), false, ScriptFailureAction.Ignore), settings.Resolve(ScriptKind.Installcheck));
+        Assert.Equal(ScriptFailureAction.Warn, settings.Resolve(ScriptKind.Postinstall).OnFailure);
+    }
+
+    [Fact]
+    public void BuildArguments_ConstrainedLanguageSetsModeBeforeTheScript()
+    {
+        var inline = ScriptService.BuildArguments("Write-Output 'hi'", null, constrainedLanguage: true);
+        var file = ScriptService.BuildArguments(null, @"C:\Scripts\it's.ps1", constrainedLanguage: true);
+        var plainFile = ScriptService.BuildArguments(null, @"C:\Scripts\check.ps1", constrainedLanguage: false);
+
+        Assert.StartsWith("$ExecutionContext.SessionState.LanguageMode = 'ConstrainedLanguage'", inline[^1]);
+        Assert.EndsWith("Write-Output 'hi'", inline[^1]);
+        Assert.Equal("-Command", file[^2]);
+        Assert.Contains(@"& 'C:\Scripts\it''s.ps1'; exit $LASTEXITCODE", file[^1]);
+        Assert.Equal(["-File", @"C:\Scripts\check.ps1"], plainFile[^2..]);
+    }
+
+    [Fact]
+    public async Task ExecuteScriptFileWithDetailsAsync_StopsScriptAtTimeout()
+    {
+        var scriptPath = Path.Combine(_testScriptDir, "slow.ps1");
+        await File.WriteAllTextAsync(scriptPath, "Write-Output 'started'; Start-Sleep -Seconds 60");
+
+        var result = await _service.ExecuteScriptFileWithDetailsAsync(scriptPath, TimeSpan.FromSeconds(3));
+
+        Assert.False(result.Success);
+        Assert.True(result.TimedOut);
+        Assert.True(result.Duration < TimeSpan.FromSeconds(30));
+    }
+
+    [Fact]
+    public async Task ExecuteScriptWithDetailsAsync_CapturesStreamsSeparately()
+    {
+        var result = await _service.ExecuteScriptWithDetailsAsync("Write-Output 'to stdout'; [Console]::Error.WriteLine('to stderr')");
+
+        Assert.Contains("to stdout", result.StandardOutput);
+        Assert.DoesNotContain("to stderr", result.StandardOutput);
+        Assert.Contains("to stderr", result.StandardError);
+    }
+
+    #endregion
 }
@@ -0,0 +1,25 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for the AllowSelfServiceUninstall policy check in UpdateEngine.
+/// </summary>
+public class SelfServiceUninstallPolicyTests
+{
+    [Theory]
+    [InlineData("always", false, true)]
+    [InlineData("Always", true, true)]
+    [InlineData("approved", false, false)]
+    [InlineData("approved", true, true)]
+    [InlineData("never", true, false)]
+    [InlineData(null, false, true)]
+    public void IsSelfServiceUninstallAllowed_FollowsPolicy(string? policy, bool approved, bool expected)
+    {
+        var item = new CatalogItem { Name = "Zoom", Version = "6.2", SelfServiceUninstall = approved };
+
+        Assert.Equal(expected, UpdateEngine.IsSelfServiceUninstallAllowed(policy, item));
+    }
+}
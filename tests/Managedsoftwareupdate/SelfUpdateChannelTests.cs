@@ -0,0 +1,98 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+using Cimian.Core.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for self-update channels and staged rollout: a machine only sees Cimian
+/// builds from its SelfUpdateChannel or a more stable one, once their rollout
+/// has reached it.
+/// </summary>
+public class SelfUpdateChannelTests : IDisposable
+{
+    private readonly string _testDir;
+    private readonly CimianConfig _config;
+
+    private const string Catalog = """
+        items:
+          - name: Cimian
+            version: 2026.10.1
+          - name: Cimian
+            version: 2026.10.20
+            self_update_channel: beta
+          - name: Cimian
+            version: 2026.10.30
+            self_update_channel: dev
+          - name: Firefox
+            version: 131.0
+            self_update_channel: beta
+        """;
+
+    public SelfUpdateChannelTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "SelfUpdateChannel", Guid.NewGuid().ToString());
+        _config = new CimianConfig { CatalogsPath = Path.Combine(_testDir, "catalogs") };
+        Directory.CreateDirectory(_config.CatalogsPath);
+        File.WriteAllText(Path.Combine(_config.CatalogsPath, "Production.yaml"), Catalog);
+    }
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    [Theory]
+    [InlineData("stable", "2026.10.1")]
+    [InlineData("", "2026.10.1")]
+    [InlineData("Beta", "2026.10.20")]
+    [InlineData("dev", "2026.10.30")]
+    public void LoadLocalCatalogItems_PicksNewestCimianInChannel(string channel, string expected)
+    {
+        _config.SelfUpdateChannel = channel;
+
+        var items = new CatalogService(_config, new HttpClient()).LoadLocalCatalogItems();
+
+        Assert.Equal(expected, items["Cimian"].Version);
+        Assert.Equal("131.0", items["Firefox"].Version);
+    }
+
+    [Fact]
+    public void IsOutsideSelfUpdateChannel_IgnoresNonCimianItems()
+    {
+        var beta = new CatalogItem { Name = "Cimian", SelfUpdateChannel = "beta" };
+        var other = new CatalogItem { Name = "Firefox", SelfUpdateChannel = "beta" };
+
+        Assert.True(StatusService.IsOutsideSelfUpdateChannel(beta, "stable"));
+        Assert.False(StatusService.IsOutsideSelfUpdateChannel(beta, "beta"));
+        Assert.False(StatusService.IsOutsideSelfUpdateChannel(other, "stable"));
+    }
+
+    [Theory]
+    [InlineData(null, 99, false)]
+    [InlineData(25, 24, false)]
+    [InlineData(25, 25, true)]
+    [InlineData(0, 0, true)]
+    public void IsHeldBackSelfUpdate_UntilRolloutReachesBucket(int? percent, int bucket, bool heldBack)
+    {
+        var item = new CatalogItem { Name = "Cimian", Version = "2026.10.20", SelfUpdateRolloutPercent = percent };
+        var other = new CatalogItem { Name = "Firefox", Version = "131.0", SelfUpdateRolloutPercent = percent };
+        var healthPath = Path.Combine(_testDir, "selfupdate_health.json");
+
+        Assert.Equal(heldBack, StatusService.IsHeldBackSelfUpdate(item, bucket, healthPath));
+        Assert.False(StatusService.IsHeldBackSelfUpdate(other, bucket, healthPath));
+    }
+
+    [Fact]
+    public void IsHeldBackSelfUpdate_RolledBackVersion()
+    {
+        var healthPath = Path.Combine(_testDir, "selfupdate_health.json");
+        var health = SelfUpdateHealthStore.Begin("Cimian", "2026.10.20", "stable", "2026.10.1", DateTime.Now, healthPath);
+        health.Status = SelfUpdateHealth.RolledBack;
+        SelfUpdateHealthStore.Save(health, healthPath);
+
+        Assert.True(StatusService.IsHeldBackSelfUpdate(new CatalogItem { Name = "Cimian", Version = "2026.10.20" }, 0, healthPath));
+        Assert.False(StatusService.IsHeldBackSelfUpdate(new CatalogItem { Name = "Cimian", Version = "2026.10.21" }, 0, healthPath));
+    }
+}
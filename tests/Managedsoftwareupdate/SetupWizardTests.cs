@@ -0,0 +1,166 @@
+using System.Net;
+using Xunit;
+using FluentAssertions;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Coverage for the --setup wizard's answer handling. The network test, schedule
+/// and validation run are exercised on real machines; these pin down the
+/// config produced from scripted and prompted answers.
+/// </summary>
+public class SetupWizardTests
+{
+    private static CimianConfig DefaultConfig() => new ConfigurationService().GetDefaultConfig();
+
+    [Fact]
+    public void BuildConfig_NonInteractive_AppliesScriptedAnswers()
+    {
+        var wizard = new SetupWizard(new ConfigurationService(), interactive: false);
+        var answers = new SetupAnswers
+        {
+            RepoUrl = "https://cimian.example.com/repo/",
+            AuthMethod = "basic",
+            AuthUser = "svc",
+            AuthPassword = "secret",
+            ClientIdStrategy = "fixed",
+            ClientIdentifier = "labs/lab-01",
+            Catalogs = ["Testing", "Production"]
+        };
+
+        var config = wizard.BuildConfig(DefaultConfig(), answers, out var errors);
+
+        errors.Should().BeEmpty();
+        config.Should().NotBeNull();
+        config!.SoftwareRepoURL.Should().Be("https://cimian.example.com/repo");
+        config.AuthUser.Should().Be("svc");
+        config.AuthPassword.Should().Be("secret");
+        config.ClientIdentifier.Should().Be("labs/lab-01");
+        config.Catalogs.Should().Equal("Testing", "Production");
+    }
+
+    [Fact]
+    public void BuildConfig_SwitchingToToken_ClearsBasicCredentials()
+    {
+        var current = DefaultConfig();
+        current.AuthUser = "old";
+        current.AuthPassword = "old";
+        var wizard = new SetupWizard(new ConfigurationService(), interactive: false);
+
+        var config = wizard.BuildConfig(current,
+            new SetupAnswers { RepoUrl = "https://repo.example.com", AuthMethod = "token", AuthToken = "abc" },
+            out _);
+
+        config!.AuthToken.Should().Be("abc");
+        config.AuthUser.Should().BeNull();
+        config.AuthPassword.Should().BeNull();
+    }
+
+    [Fact]
+    public void BuildConfig_InvalidAnswers_ReturnsErrors()
+    {
+        var wizard = new SetupWizard(new ConfigurationService(), interactive: false);
+
+        var config = wizard.BuildConfig(DefaultConfig(),
+            new SetupAnswers { RepoUrl = "ftp://repo", AuthMethod = "basic", ClientIdStrategy = "certificate" },
+            out var errors);
+
+        config.Should().BeNull();
+        errors.Should().Contain("SoftwareRepoURL must be a valid HTTP/HTTPS URL");
+        errors.Should().Contain(e => e.Contains("user name and password"));
+        errors.Should().Contain(e => e.Contains("requires certificate authentication"));
+    }
+
+    [Fact]
+    public void BuildConfig_UnknownScriptedChoice_Throws()
+    {
+        var wizard = new SetupWizard(new ConfigurationService(), interactive: false);
+
+        var act = () => wizard.BuildConfig(DefaultConfig(), new SetupAnswers { AuthMethod = "kerberos" }, out _);
+
+        act.Should().Throw<ArgumentException>().WithMessage("*kerberos*");
+    }
+
+    [Fact]
+    public void BuildConfig_Interactive_BlankAnswerKeepsCurrentAndBadChoiceReprompts()
+    {
+        var input = new Queue<string?>(new[]
+        {
+            "https://repo.example.com", // repo URL
+            "oauth",                    // invalid auth method, re-prompted
+            "none",                     // auth method
+            "",                         // client id strategy -> keep current (hostname)
+            ""                          // catalogs -> keep current
+        });
+        var output = new List<string>();
+        var wizard = new SetupWizard(new ConfigurationService(), interactive: true, input.Dequeue, output.Add);
+
+        var config = wizard.BuildConfig(DefaultConfig(), new SetupAnswers(), out var errors);
+
+        errors.Should().BeEmpty();
+        config!.ClientIdentifier.Should().Be(Environment.MachineName);
+        config.Catalogs.Should().Equal("Production");
+        output.Should().Contain(o => o.Contains("Please enter one of"));
+        input.Should().BeEmpty();
+    }
+
+    [Fact]
+    public void ApplyAuthSecret_TakesSecretsFromEnvironment()
+    {
+        var env = new Dictionary<string, string?>
+        {
+            [SetupWizard.PasswordEnvVar] = "pw",
+            [SetupWizard.TokenEnvVar] = ""
+        };
+        var answers = new SetupAnswers { AuthMethod = "basic" };
+
+        var error = SetupWizard.ApplyAuthSecret(answers, name => env.GetValueOrDefault(name), readSecretLine: null);
+
+        error.Should().BeNull();
+        answers.AuthPassword.Should().Be("pw");
+        answers.AuthToken.Should().BeNull();
+    }
+
+    [Theory]
+    [InlineData("basic", "pw-from-stdin", null)]
+    [InlineData("token", null, "pw-from-stdin")]
+    public void ApplyAuthSecret_StdinFillsTheSecretForTheChosenMethod(string method, string? password, string? token)
+    {
+        var answers = new SetupAnswers { AuthMethod = method };
+
+        var error = SetupWizard.ApplyAuthSecret(answers, _ => null, () => "pw-from-stdin\r");
+
+        error.Should().BeNull();
+        answers.AuthPassword.Should().Be(password);
+        answers.AuthToken.Should().Be(token);
+    }
+
+    [Theory]
+    [InlineData(null, "secret")]
+    [InlineData("certificate", "secret")]
+    [InlineData("token", null)]
+    public void ApplyAuthSecret_StdinWithoutMethodOrInputIsAnError(string? method, string? line)
+    {
+        var answers = new SetupAnswers { AuthMethod = method };
+
+        var error = SetupWizard.ApplyAuthSecret(answers, _ => null, () => line);
+
+        error.Should().NotBeNull();
+        answers.AuthToken.Should().BeNull();
+    }
+
+    [Theory]
+    [InlineData(HttpStatusCode.OK, true)]
+    [InlineData(HttpStatusCode.NotFound, true)]
+    [InlineData(HttpStatusCode.Unauthorized, false)]
+    [InlineData(HttpStatusCode.Forbidden, false)]
+    [InlineData(HttpStatusCode.InternalServerError, false)]
+    public void DescribeRepositoryResponse_MapsStatus(HttpStatusCode status, bool expected)
+    {
+        var (success, _) = SetupWizard.DescribeRepositoryResponse(status, "https://repo/catalogs/Production.yaml");
+
+        success.Should().Be(expected);
+    }
+}
@@ -0,0 +1,35 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for StatusReporter log streaming (StatusLogStreaming / StatusLogStreamLevel).
+/// </summary>
+public class StatusReporterTests
+{
+    [Theory]
+    [InlineData("ERROR", "INFO", true)]
+    [InlineData("WARN", "INFO", true)]
+    [InlineData("INFO", "INFO", true)]
+    [InlineData("DEBUG", "INFO", false)]
+    [InlineData("TRACE", "DEBUG", false)]
+    [InlineData("debug", "Debug", true)]
+    [InlineData("INFO", "WARN", false)]
+    [InlineData("NOTICE", "INFO", true)]   // unknown levels count as INFO
+    [InlineData("DEBUG", "bogus", false)]
+    public void ShouldStream_FiltersByLevel(string level, string minimum, bool expected)
+    {
+        Assert.Equal(expected, StatusReporter.ShouldStream(level, minimum));
+    }
+
+    [Fact]
+    public void LogLine_NeverOpensTheConnection()
+    {
+        using var reporter = new StatusReporter(port: 1);
+
+        reporter.LogLine("ERROR", "Install failed");
+
+        Assert.False(reporter.IsConnected);
+    }
+}
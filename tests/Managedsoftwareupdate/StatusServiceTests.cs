@@ -247,6 +247,105 @@ public class StatusServiceTests
 
     #endregion
 
+    #region Installs Array Tests
+
+    [Fact]
+    public void CheckStatus_InstallsFile_Md5Matches_AcceptsWithoutVersionMetadata()
+    {
+        var testFile = Path.Combine(_testDir, "agent.exe");
+        File.WriteAllText(testFile, "test content");
+
+        var item = new CatalogItem
+        {
+            Name = "InstallsMd5Package",
+            Version = "2.0.0",
+            Installs = [new InstallCheckItem { Type = "file", Path = testFile, Md5Checksum = "9473FDD0D880A43C21B7778D34872157" }]
+        };
+
+        var result = _service.CheckStatus(item, "install", _testDir);
+
+        Assert.Equal("installed", result.Status);
+        Assert.False(result.NeedsAction);
+    }
+
+    [Fact]
+    public void CheckStatus_InstallsFile_Md5Mismatch_NeedsUpdate()
+    {
+        var testFile = Path.Combine(_testDir, "agent.exe");
+        File.WriteAllText(testFile, "older content");
+
+        var item = new CatalogItem
+        {
+            Name = "InstallsMd5MismatchPackage",
+            Version = "2.0.0",
+            Installs = [new InstallCheckItem { Type = "file", Path = testFile, Md5Checksum = "9473fdd0d880a43c21b7778d34872157" }]
+        };
+
+        var result = _service.CheckStatus(item, "install", _testDir);
+
+        Assert.Equal("pending", result.Status);
+        Assert.True(result.IsUpdate);
+        Assert.Equal(StatusReasonCode.HashMismatch, result.ReasonCode);
+    }
+
+    [Fact]
+    public void CheckStatus_InstallsFile_WithoutHashOrVersionMetadata_NeedsAction()
+    {
+        var testFile = Path.Combine(_testDir, "unversioned.exe");
+        File.WriteAllText(testFile, "not a PE file");
+
+        var item = new CatalogItem
+        {
+            Name = "InstallsUnversionedPackage",
+            Version = "2.0.0",
+            Installs = [new InstallCheckItem { Type = "file", Path = testFile }]
+        };
+
+        var result = _service.CheckStatus(item, "install", _testDir);
+
+        Assert.True(result.NeedsAction);
+        Assert.Equal(StatusReasonCode.VersionOutdated, result.ReasonCode);
+    }
+
+    [Fact]
+    public void CheckStatus_InstallsFile_Missing_ReportsFileMissing()
+    {
+        var item = new CatalogItem
+        {
+            Name = "InstallsMissingPackage",
+            Version = "2.0.0",
+            Installs = [new InstallCheckItem { Type = "file", Path = Path.Combine(_testDir, "missing.exe"), Md5Checksum = "9473fdd0d880a43c21b7778d34872157" }]
+        };
+
+        var result = _service.CheckStatus(item, "install", _testDir);
+
+        Assert.True(result.NeedsAction);
+        Assert.Equal(StatusReasonCode.FileMissing, result.ReasonCode);
+    }
+
+    [Fact]
+    public void GetFileVersion_ReadsNumericPartsOfPeFiles()
+    {
+        var version = StatusService.GetFileVersion(typeof(StatusService).Assembly.Location);
+
+        Assert.NotNull(version);
+        Assert.Matches(@"^\d+\.\d+\.\d+\.\d+$", version);
+        Assert.Null(StatusService.GetFileVersion(Path.Combine(_testDir, "missing.exe")));
+    }
+
+    [Theory]
+    [InlineData("10.0.26100.1 (WinBuild.160101.0800)", "10.0.26100.1")]
+    [InlineData("1, 2, 3, 4", "1.2.3.4")]
+    [InlineData("5,6,7,8", "5.6.7.8")]
+    [InlineData("InternalName", null)]
+    [InlineData("", null)]
+    public void CleanVersionString_ReducesFileVersionToDottedNumber(string raw, string? expected)
+    {
+        Assert.Equal(expected, StatusService.CleanVersionString(raw));
+    }
+
+    #endregion
+
     #region MSI installer-block ProductCode/UpgradeCode Tests (Priority 6.5)
 
     [Fact]
@@ -502,4 +601,55 @@ public class StatusServiceTests
     }
 
     #endregion
+
+    #region Install Precondition Tests
+
+    [Fact]
+    public void RequiredFreeBytes_AddsDownloadUntilCached()
+    {
+        var item = new CatalogItem
+        {
+            Name = "DiskHungry",
+            InstallerSizeRequired = 500,
+            Installer = new InstallerInfo { Location = "apps/diskhungry.msi", Size = 200L * 1024 * 1024 }
+        };
+
+        Assert.Equal(700L * 1024 * 1024, StatusService.RequiredFreeBytes(item, payloadCached: false));
+        Assert.Equal(500L * 1024 * 1024, StatusService.RequiredFreeBytes(item, payloadCached: true));
+    }
+
+    [Fact]
+    public void RequiredFreeBytes_MinimumFreeDiskIsAFloor()
+    {
+        var item = new CatalogItem { Name = "DiskHungry", InstallerSizeRequired = 500, MinimumFreeDiskMb = 2048 };
+
+        Assert.Equal(2048L * 1024 * 1024, StatusService.RequiredFreeBytes(item, payloadCached: false));
+        Assert.Equal(0, StatusService.RequiredFreeBytes(new CatalogItem { Name = "Unsized" }, payloadCached: false));
+    }
+
+    [Theory]
+    [InlineData(true, 15, 20, true)]
+    [InlineData(true, 20, 20, false)]
+    [InlineData(true, null, 20, false)]
+    [InlineData(false, 5, 20, false)]
+    [InlineData(true, 5, 0, false)]
+    public void LowBatteryReason_OnlyBelowThresholdOnBattery(bool onBattery, int? percent, int minimum, bool blocked)
+    {
+        var reason = StatusService.LowBatteryReason(new PowerState(onBattery, percent), minimum);
+
+        Assert.Equal(blocked, reason != null);
+    }
+
+    [Theory]
+    [InlineData(NetworkCost.Unrestricted, false)]
+    [InlineData(NetworkCost.Fixed, true)]
+    [InlineData(NetworkCost.Variable, true)]
+    [InlineData(NetworkCost.Unrestricted | NetworkCost.Roaming, true)]
+    [InlineData(NetworkCost.Fixed | NetworkCost.OverDataLimit, true)]
+    public void IsMeteredCost_FlagsDataPlansAndRoaming(uint cost, bool metered)
+    {
+        Assert.Equal(metered, NetworkCost.IsMeteredCost(cost));
+    }
+
+    #endregion
 }
@@ -0,0 +1,99 @@
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+using Cimian.Core.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for UnmanagedItemTracker - the RemoveUnmanagedItems grace period and managed set.
+/// </summary>
+public class UnmanagedItemTrackerTests : IDisposable
+{
+    private readonly string _testDir;
+    private readonly string _path;
+
+    public UnmanagedItemTrackerTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "UnmanagedItems", Guid.NewGuid().ToString());
+        _path = Path.Combine(_testDir, "unmanaged_items.json");
+    }
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    private static CatalogItem Item(string name, params string[] requires) => new()
+    {
+        Name = name,
+        Version = "1.0",
+        Requires = requires.ToList()
+    };
+
+    private static Dictionary<string, CatalogItem> Catalog(params CatalogItem[] items) =>
+        items.ToDictionary(i => ItemKey.Canonical(i.Name), ItemKey.Comparer);
+
+    [Fact]
+    public void Track_DueOnlyAfterGracePeriod_SurvivesReload()
+    {
+        var now = new DateTime(2026, 10, 1, 9, 0, 0);
+        var tracker = new UnmanagedItemTracker(7, _path);
+        var first = Assert.Single(tracker.Track([Item("Zoom")], now));
+        Assert.False(first.Due);
+        Assert.Equal(now.AddDays(7), first.RemoveAfter);
+        tracker.Save();
+
+        var reloaded = new UnmanagedItemTracker(7, _path);
+        Assert.False(Assert.Single(reloaded.Track([Item("Zoom")], now.AddDays(6))).Due);
+        var later = Assert.Single(reloaded.Track([Item("Zoom")], now.AddDays(7)));
+        Assert.True(later.Due);
+        Assert.Equal(now, later.FirstUnmanaged);
+    }
+
+    [Fact]
+    public void Track_ZeroGraceDays_DueImmediately()
+    {
+        var tracker = new UnmanagedItemTracker(0, _path);
+
+        Assert.True(Assert.Single(tracker.Track([Item("Zoom")], DateTime.Now)).Due);
+    }
+
+    [Fact]
+    public void Track_ItemBackInManifest_RestartsClock()
+    {
+        var now = new DateTime(2026, 10, 1);
+        var tracker = new UnmanagedItemTracker(7, _path);
+        tracker.Track([Item("Zoom")], now);
+        tracker.Save();
+
+        // A manifest lists it again, then drops it again
+        var reloaded = new UnmanagedItemTracker(7, _path);
+        Assert.Empty(reloaded.Track([], now.AddDays(3)));
+        reloaded.Save();
+
+        var again = Assert.Single(new UnmanagedItemTracker(7, _path).Track([Item("Zoom")], now.AddDays(8)));
+        Assert.False(again.Due);
+        Assert.Equal(now.AddDays(8), again.FirstUnmanaged);
+    }
+
+    [Fact]
+    public void ManagedNames_IncludesRequiresAndUpdatesOfManifestItems()
+    {
+        var patch = Item("Office-Patch");
+        patch.UpdateFor = ["Office"];
+        var catalog = Catalog(
+            Item("Office", "VCRedist-14.40"),
+            Item("VCRedist", "UCRT"),
+            Item("UCRT"),
+            patch,
+            Item("Zoom"));
+
+        var managed = UnmanagedItemTracker.ManagedNames([new ManifestItem { Name = "office" }], catalog);
+
+        Assert.Contains("VCRedist", managed);
+        Assert.Contains("UCRT", managed);
+        Assert.Contains("Office-Patch", managed);
+        Assert.DoesNotContain("Zoom", managed);
+    }
+}
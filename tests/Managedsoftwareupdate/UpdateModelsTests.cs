@@ -717,6 +717,56 @@ public class UpdateModelsTests
 
     #endregion
 
+    #region MaintenanceWindow Tests
+
+    [Fact]
+    public void MaintenanceWindow_ConvertsUtcToItsTimeZone()
+    {
+        var window = new MaintenanceWindow { Start = "22:00", End = "02:00", TimeZone = "UTC" };
+        Assert.True(window.IsWithinWindow(new DateTime(2026, 2, 23, 23, 30, 0, DateTimeKind.Utc)));
+        Assert.False(window.IsWithinWindow(new DateTime(2026, 2, 23, 12, 0, 0, DateTimeKind.Utc)));
+    }
+
+    [Fact]
+    public void MaintenanceWindow_DaysUseWindowLocalDate()
+    {
+        // 01:00 UTC Tuesday is 20:00 Monday in New York
+        var window = new MaintenanceWindow
+        {
+            Days = ["Mon"],
+            Start = "19:00",
+            End = "21:00",
+            TimeZone = "America/New_York"
+        };
+        Assert.True(window.IsWithinWindow(new DateTime(2026, 2, 24, 1, 0, 0, DateTimeKind.Utc)));
+    }
+
+    [Fact]
+    public void MaintenanceWindow_UnknownTimeZoneFallsBackToLocal()
+    {
+        var window = new MaintenanceWindow { Start = "00:00", End = "01:00", TimeZone = "Not/AZone" };
+        Assert.Equal(TimeZoneInfo.Local, window.ResolveTimeZone());
+    }
+
+    [Fact]
+    public void CimianConfig_DeserializesMaintenanceWindows()
+    {
+        var yaml = """
+            MaintenanceWindows:
+              - Days: [Sat, Sun]
+                Start: "01:00"
+                End: "05:00"
+                TimeZone: Europe/London
+            """;
+        var config = Cimian.Core.Services.YamlUtils.Deserializer.Deserialize<CimianConfig>(yaml);
+
+        var window = Assert.Single(config.MaintenanceWindows);
+        Assert.Equal(["Sat", "Sun"], window.Days!);
+        Assert.Equal("Europe/London", window.TimeZone);
+    }
+
+    #endregion
+
     #region InstallCheckItem.EffectiveType Tests
 
     [Fact]
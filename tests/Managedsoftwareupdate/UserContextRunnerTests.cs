@@ -0,0 +1,60 @@
+using System.Diagnostics;
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for UserContextRunner - installs path expansion and command-line quoting.
+/// </summary>
+public class UserContextRunnerTests
+{
+    [Fact]
+    public void Expand_ReplacesKnownVariablesOnly()
+    {
+        var environment = new Dictionary<string, string>(StringComparer.OrdinalIgnoreCase)
+        {
+            ["LOCALAPPDATA"] = @"C:\Users\pat\AppData\Local"
+        };
+
+        Assert.Equal(@"C:\Users\pat\AppData\Local\App\app.exe",
+            UserContextRunner.Expand(@"%LocalAppData%\App\app.exe", environment));
+        Assert.Equal(@"%UNKNOWN%\app.exe", UserContextRunner.Expand(@"%UNKNOWN%\app.exe", environment));
+    }
+
+    [Fact]
+    public void ResolveInstalls_SystemItemKeepsPathsAsWritten()
+    {
+        var item = new CatalogItem
+        {
+            Name = "App",
+            Installs = [new InstallCheckItem { Type = "file", Path = @"%LOCALAPPDATA%\App\app.exe" }]
+        };
+
+        Assert.Same(item.Installs, UserContextRunner.ResolveInstalls(item));
+    }
+
+    [Fact]
+    public void BuildCommandLine_QuotesArgumentsForCommandLineToArgvW()
+    {
+        var startInfo = new ProcessStartInfo { FileName = @"C:\Program Files\PowerShell\7\pwsh.exe" };
+        startInfo.ArgumentList.Add("-File");
+        startInfo.ArgumentList.Add(@"C:\cache\my script.ps1");
+        startInfo.ArgumentList.Add("say \"hi\"");
+        startInfo.ArgumentList.Add(@"C:\trailing dir\");
+        startInfo.ArgumentList.Add("");
+
+        Assert.Equal(
+            "\"C:\\Program Files\\PowerShell\\7\\pwsh.exe\" -File \"C:\\cache\\my script.ps1\" \"say \\\"hi\\\"\" \"C:\\trailing dir\\\\\" \"\"",
+            UserContextRunner.BuildCommandLine(startInfo));
+    }
+
+    [Fact]
+    public void BuildCommandLine_UsesArgumentsAsWritten()
+    {
+        var startInfo = new ProcessStartInfo { FileName = "setup.exe", Arguments = "/S /D=C:\\App" };
+
+        Assert.Equal("setup.exe /S /D=C:\\App", UserContextRunner.BuildCommandLine(startInfo));
+    }
+}
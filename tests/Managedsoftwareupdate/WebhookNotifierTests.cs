@@ -0,0 +1,156 @@
+using System.Net;
+using System.Text.Json.Nodes;
+using Xunit;
+using Cimian.CLI.managedsoftwareupdate.Models;
+using Cimian.CLI.managedsoftwareupdate.Services;
+
+namespace Cimian.Tests.Managedsoftwareupdate;
+
+/// <summary>
+/// Tests for WebhookNotifier: which events a run raises, the Slack / Teams /
+/// generic bodies and template placeholders.
+/// </summary>
+public class WebhookNotifierTests : IDisposable
+{
+    private readonly string _sessionDir;
+
+    public WebhookNotifierTests()
+    {
+        _sessionDir = Path.Combine(Path.GetTempPath(), "CimianTests", "Webhooks", Guid.NewGuid().ToString());
+        Directory.CreateDirectory(_sessionDir);
+
+        File.WriteAllText(Path.Combine(_sessionDir, "session.json"),
+            """{"session_id":"2026-10-16-0900","run_type":"auto","status":"partial_failure","summary":{"installs":3,"updates":1,"failures":1}}""");
+        File.WriteAllLines(Path.Combine(_sessionDir, "events.jsonl"),
+        [
+            """{"level":"INFO","event_type":"install","status":"completed","package_name":"Chrome","package_version":"130.0"}""",
+            """{"level":"ERROR","event_type":"install","status":"failed","package_name":"Zoom","package_version":"6.2"}""",
+            """{"level":"INFO","event_type":"self_update","status":"scheduled","package_name":"Cimian","package_version":"2026.10.16"}""",
+            """{"level":"INFO","event_type":"ins"""
+        ]);
+    }
+
+    public void Dispose()
+    {
+        try
+        {
+            if (Directory.Exists(_sessionDir))
+            {
+                Directory.Delete(_sessionDir, recursive: true);
+            }
+        }
+        catch { /* Ignore cleanup errors */ }
+    }
+
+    private static WebhookNotifier Notifier(CimianConfig config, StubServer server) =>
+        new(config, new HttpClient(server), "LAB-PC-01", TimeSpan.Zero);
+
+    [Fact]
+    public void ReadRun_CollectsFailedItemsAndSelfUpdates()
+    {
+        var run = WebhookNotifier.ReadRun(_sessionDir)!;
+
+        Assert.Equal("auto", run.RunType);
+        Assert.Equal(["Zoom 6.2"], run.FailedItems);
+        Assert.Equal(["Cimian 2026.10.16"], run.SelfUpdates);
+        Assert.Equal(["partial_failure", "self_update_scheduled"], run.Events);
+    }
+
+    [Fact]
+    public async Task Notify_SendsRefusedSelfUpdateByDefault()
+    {
+        File.AppendAllLines(Path.Combine(_sessionDir, "events.jsonl"),
+        [
+            """{"level":"ERROR","event_type":"security","action":"self_update","status":"blocked","package_name":"Cimian","package_version":"2026.10.17","error":"signature_invalid"}"""
+        ]);
+        var server = new StubServer(HttpStatusCode.OK);
+        var config = new CimianConfig { Webhooks = [new WebhookSettings { URL = "https://hooks.slack.com/services/T/B/X", Format = "slack" }] };
+
+        var run = WebhookNotifier.ReadRun(_sessionDir)!;
+        await Notifier(config, server).NotifyAsync(_sessionDir);
+
+        Assert.Equal(["Cimian 2026.10.17"], run.RefusedSelfUpdates);
+        Assert.Equal(3, server.Bodies.Count);
+        Assert.Equal("LAB-PC-01: Cimian self-update Cimian 2026.10.17 refused, its hash or signature didn't verify",
+            JsonNode.Parse(server.Bodies[2])!["text"]!.GetValue<string>());
+    }
+
+    [Fact]
+    public async Task Notify_SendsSlackTextForDefaultEvents()
+    {
+        var server = new StubServer(HttpStatusCode.OK);
+        var config = new CimianConfig { Webhooks = [new WebhookSettings { URL = "https://hooks.slack.com/services/T/B/X", Format = "slack" }] };
+
+        await Notifier(config, server).NotifyAsync(_sessionDir);
+
+        Assert.Equal(2, server.Bodies.Count);
+        Assert.Equal("LAB-PC-01: auto run finished with 1 failed item(s): Zoom 6.2",
+            JsonNode.Parse(server.Bodies[0])!["text"]!.GetValue<string>());
+        Assert.Contains("Cimian 2026.10.16", JsonNode.Parse(server.Bodies[1])!["text"]!.GetValue<string>());
+    }
+
+    [Fact]
+    public async Task Notify_SkipsEventsTheWebhookDoesNotList()
+    {
+        var server = new StubServer(HttpStatusCode.OK);
+        var config = new CimianConfig { Webhooks = [new WebhookSettings { URL = "https://alerts.example.com/cimian", Events = ["completed", "failed"] }] };
+
+        await Notifier(config, server).NotifyAsync(_sessionDir);
+
+        Assert.Empty(server.Bodies);
+    }
+
+    [Fact]
+    public void BuildBody_GenericUsesTemplate()
+    {
+        var webhook = new WebhookSettings { URL = "https://alerts.example.com/cimian", Template = "{hostname}/{run_type}: {failed_items} {unknown}" };
+        var run = WebhookNotifier.ReadRun(_sessionDir)!;
+
+        var body = Notifier(new CimianConfig(), new StubServer(HttpStatusCode.OK)).BuildBody(webhook, "partial_failure", run);
+
+        Assert.Equal("partial_failure", body["event"]!.GetValue<string>());
+        Assert.Equal("LAB-PC-01/auto: Zoom 6.2 {unknown}", body["message"]!.GetValue<string>());
+        Assert.Equal(3, body["summary"]!["installs"]!.GetValue<int>());
+        Assert.Equal("Zoom 6.2", Assert.Single(body["failed_items"]!.AsArray())!.GetValue<string>());
+    }
+
+    [Fact]
+    public void BuildBody_TeamsIsAdaptiveCardMessage()
+    {
+        var webhook = new WebhookSettings { URL = "https://example.logic.azure.com/workflows/x", Format = "Teams" };
+        var run = WebhookNotifier.ReadRun(_sessionDir)!;
+
+        var body = Notifier(new CimianConfig(), new StubServer(HttpStatusCode.OK)).BuildBody(webhook, "partial_failure", run);
+
+        Assert.Equal("message", body["type"]!.GetValue<string>());
+        var attachment = Assert.Single(body["attachments"]!.AsArray())!;
+        Assert.Equal("application/vnd.microsoft.card.adaptive", attachment["contentType"]!.GetValue<string>());
+        Assert.Equal("AdaptiveCard", attachment["content"]!["type"]!.GetValue<string>());
+    }
+
+    [Fact]
+    public void ValidateConfig_RejectsUnknownFormatAndEvent()
+    {
+        var config = new CimianConfig
+        {
+            SoftwareRepoURL = "https://repo.example.com/cimian",
+            Webhooks = [new WebhookSettings { URL = "https://alerts.example.com", Format = "discord", Events = ["reboot"] }]
+        };
+
+        var errors = new ConfigurationService().ValidateConfig(config);
+
+        Assert.Contains(errors, e => e.Contains("Webhooks Format"));
+        Assert.Contains(errors, e => e.Contains("'reboot'"));
+    }
+
+    private sealed class StubServer(HttpStatusCode status) : HttpMessageHandler
+    {
+        public List<string> Bodies { get; } = new();
+
+        protected override async Task<HttpResponseMessage> SendAsync(HttpRequestMessage request, CancellationToken cancellationToken)
+        {
+            Bodies.Add(await request.Content!.ReadAsStringAsync(cancellationToken));
+            return new HttpResponseMessage(status);
+        }
+    }
+}
@@ -3,6 +3,7 @@ using Microsoft.Extensions.Logging;
 using Moq;
 using Cimian.Core.Models;
 using Cimian.Engine.Predicates;
+using Cimian.Infrastructure.System;
 using Xunit;
 
 namespace Cimian.Tests;
@@ -174,6 +175,30 @@ public class PredicateEngineTests
         result.Should().Be(expected);
     }
 
+    [Theory]
+    [InlineData("os_version >= '10.0.22621'", "10.0.22631", true)]
+    [InlineData("os_version >= '10.0.22621'", "10.0.9200", false)]
+    [InlineData("os_version < '10.0.22000'", "10.0.19045", true)]
+    public async Task EvaluateCondition_DottedVersion_ComparesPartByPart(string condition, string osVersion, bool expected)
+    {
+        var facts = CreateFacts();
+        facts.OperatingSystemVersion = osVersion;
+        var result = await _engine.EvaluateConditionAsync(condition, facts);
+        result.Should().Be(expected);
+    }
+
+    [Theory]
+    [InlineData("machine_type == \"laptop\" AND os_build >= 22621", "laptop", 22631, true)]
+    [InlineData("machine_type == \"laptop\" AND os_build >= 22621", "laptop", 19045, false)]
+    [InlineData("machine_type == \"laptop\" AND os_build >= 22621", "desktop", 22631, false)]
+    public async Task EvaluateCondition_OsBuildAlias_ShouldMatch(string condition, string machineType, int build, bool expected)
+    {
+        var facts = CreateFacts(machineType: machineType);
+        facts.OSBuildNumber = build;
+        var result = await _engine.EvaluateConditionAsync(condition, facts);
+        result.Should().Be(expected);
+    }
+
     #endregion
 
     #region OR Expression Tests
@@ -767,6 +792,49 @@ public class PredicateEngineTests
         (await _engine.EvaluateConditionAsync("storage_capacity_gb >= 2000", facts)).Should().BeFalse();
     }
 
+    [Fact]
+    public async Task EvaluateCondition_FreeDiskGb_GatesLargeInstalls()
+    {
+        var facts = CreateFacts(storageCapacityGb: 512);
+        facts.FreeDiskGb = 40;
+
+        (await _engine.EvaluateConditionAsync("free_disk_gb >= 30", facts)).Should().BeTrue();
+        (await _engine.EvaluateConditionAsync("free_disk_gb >= 50", facts)).Should().BeFalse();
+    }
+
+    #endregion
+
+    #region Inventory Fact Tests
+
+    [Fact]
+    public async Task EvaluateCondition_ChassisAndBattery_Match()
+    {
+        var facts = CreateFacts(machineType: "laptop");
+        facts.ChassisType = SystemFactsCollector.ChassisTypeName(31);
+        facts.HasBattery = true;
+        facts.SerialNumber = "PF3ABC12";
+
+        (await _engine.EvaluateConditionAsync("chassis_type == 'convertible' AND has_battery == true", facts)).Should().BeTrue();
+        (await _engine.EvaluateConditionAsync("serial_number BEGINSWITH 'PF3'", facts)).Should().BeTrue();
+    }
+
+    [Fact]
+    public void ToInventory_WithoutIdentity_OmitsHostnameAndSerial()
+    {
+        var facts = CreateFacts(hostname: "LAB-PC-042", machineType: "laptop");
+        facts.SerialNumber = "PF3ABC12";
+        facts.FreeDiskGb = 40;
+
+        var full = facts.ToInventory();
+        var anonymous = facts.ToInventory(includeIdentity: false);
+
+        full["serial_number"].Should().Be("PF3ABC12");
+        full["hostname"].Should().Be("LAB-PC-042");
+        anonymous.Should().NotContainKey("serial_number").And.NotContainKey("hostname");
+        anonymous["free_disk_gb"].Should().Be(40L);
+        anonymous["machine_type"].Should().Be("laptop");
+    }
+
     #endregion
 
     #region Real-World CoreDrivers Scenario Tests
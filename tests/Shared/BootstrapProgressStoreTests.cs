@@ -0,0 +1,119 @@
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// Bootstrap progress (bootstrap_progress.json): attempts, what is outstanding,
+/// and when a bootstrap completes or gives up.
+/// </summary>
+public class BootstrapProgressStoreTests : StoreTestBase
+{
+    private readonly string _path;
+
+    public BootstrapProgressStoreTests()
+    {
+        _path = Path.Combine(TestDir, "bootstrap_progress.json");
+    }
+
+    [Theory]
+    [InlineData(1, 2)]
+    [InlineData(2, 4)]
+    [InlineData(4, 16)]
+    [InlineData(7, 60)]
+    [InlineData(40, 60)]
+    public void RetryDelay_DoublesUpToAnHour(int attempt, int minutes)
+    {
+        Assert.Equal(TimeSpan.FromMinutes(minutes), BootstrapProgressStore.RetryDelay(attempt, TimeSpan.FromMinutes(2)));
+    }
+
+    [Fact]
+    public void RetryDelay_ZeroBase_RetriesStraightAway()
+    {
+        Assert.Equal(TimeSpan.Zero, BootstrapProgressStore.RetryDelay(3, TimeSpan.Zero));
+    }
+
+    [Fact]
+    public void Resume_AfterRestart_KeepsAttemptsSoFar()
+    {
+        var progress = BootstrapProgressStore.Start(Now, _path);
+        BootstrapProgressStore.BeginAttempt(progress, Now, _path);
+        BootstrapProgressStore.BeginAttempt(progress, Now.AddMinutes(10), _path);
+
+        var resumed = BootstrapProgressStore.Resume(Now.AddHours(1), _path);
+
+        Assert.Equal(2, resumed.Attempts);
+        Assert.Equal(Now, resumed.Started);
+        Assert.True(BootstrapProgressStore.IsActive(_path));
+    }
+
+    [Fact]
+    public void Resume_AfterFinishedBootstrap_StartsAFreshOne()
+    {
+        var progress = BootstrapProgressStore.Start(Now, _path);
+        BootstrapProgressStore.BeginAttempt(progress, Now, _path);
+        BootstrapProgressStore.RecordAttempt(progress, 0, [], ["Firefox"], 5, Now, _path);
+
+        var next = BootstrapProgressStore.Resume(Now.AddDays(1), _path);
+
+        Assert.Equal(0, next.Attempts);
+        Assert.Equal(Now.AddDays(1), next.Started);
+    }
+
+    [Fact]
+    public void RecordAttempt_FailedItems_StayOutstandingUntilARunInstallsThem()
+    {
+        var progress = BootstrapProgressStore.Start(Now, _path);
+        BootstrapProgressStore.BeginAttempt(progress, Now, _path);
+        BootstrapProgressStore.RecordAttempt(progress, 1, ["Office"], ["Chrome", "Firefox"], 5, Now, _path);
+
+        Assert.Equal(BootstrapProgress.InProgress, progress.Status);
+        Assert.Equal(new[] { "Office" }, progress.Remaining);
+        Assert.Equal(new[] { "Chrome", "Firefox" }, progress.CompletedItems);
+
+        BootstrapProgressStore.BeginAttempt(progress, Now.AddMinutes(5), _path);
+        BootstrapProgressStore.RecordAttempt(progress, 0, [], ["Office"], 5, Now.AddMinutes(30), _path);
+
+        var saved = BootstrapProgressStore.Read(_path)!;
+        Assert.Equal(BootstrapProgress.Completed, saved.Status);
+        Assert.Empty(saved.Remaining);
+        Assert.Equal(new[] { "Chrome", "Firefox", "Office" }, saved.CompletedItems);
+        Assert.Equal(Now.AddMinutes(30), saved.Finished);
+        Assert.False(BootstrapProgressStore.IsActive(_path));
+    }
+
+    [Fact]
+    public void RecordAttempt_FailedRunWithNoItems_IsNotComplete()
+    {
+        var progress = BootstrapProgressStore.Start(Now, _path);
+        BootstrapProgressStore.BeginAttempt(progress, Now, _path);
+        BootstrapProgressStore.RecordAttempt(progress, 1, [], [], 5, Now, _path);
+
+        Assert.True(progress.IsActive);
+    }
+
+    [Fact]
+    public void RecordAttempt_LastAttemptFails_Exhausts()
+    {
+        var progress = BootstrapProgressStore.Start(Now, _path);
+        for (var i = 0; i < 3; i++)
+        {
+            BootstrapProgressStore.BeginAttempt(progress, Now.AddMinutes(i), _path);
+            BootstrapProgressStore.RecordAttempt(progress, 1, ["Office"], [], 3, Now.AddMinutes(i), _path);
+        }
+
+        Assert.Equal(BootstrapProgress.Exhausted, progress.Status);
+        Assert.Equal(3, progress.Attempts);
+        Assert.False(BootstrapProgressStore.IsActive(_path));
+    }
+
+    [Fact]
+    public void Clear_ForgetsTheBootstrap()
+    {
+        BootstrapProgressStore.Start(Now, _path);
+
+        BootstrapProgressStore.Clear(_path);
+
+        Assert.Null(BootstrapProgressStore.Read(_path));
+    }
+}
@@ -0,0 +1,89 @@
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// When the "Setting up your device" screen stays up, and what it says.
+/// </summary>
+public class BootstrapScreenTests : StoreTestBase
+{
+    public BootstrapScreenTests()
+    {
+        Directory.CreateDirectory(TestDir);
+    }
+
+    private static BootstrapProgress Progress(string status = BootstrapProgress.InProgress) =>
+        new() { Status = status, Started = Now };
+
+    [Theory]
+    [InlineData(BootstrapProgress.InProgress, BootstrapScreenState.Show)]
+    [InlineData(BootstrapProgress.Completed, BootstrapScreenState.Completed)]
+    [InlineData(BootstrapProgress.Exhausted, BootstrapScreenState.GaveUp)]
+    public void Evaluate_FollowsTheBootstrap(string status, string expected)
+    {
+        Assert.Equal(expected, BootstrapScreen.Evaluate(Progress(status), new BootstrapScreenConfig(), Now.AddMinutes(5)));
+    }
+
+    [Fact]
+    public void Evaluate_NoBootstrap_NotRunning()
+    {
+        Assert.Equal(BootstrapScreenState.NotRunning, BootstrapScreen.Evaluate(null, new BootstrapScreenConfig(), Now));
+    }
+
+    [Fact]
+    public void Evaluate_TimeoutCountsFromTheBootstrapStart()
+    {
+        var config = new BootstrapScreenConfig { TimeoutMinutes = 60 };
+
+        Assert.Equal(BootstrapScreenState.Show, BootstrapScreen.Evaluate(Progress(), config, Now.AddMinutes(59)));
+        Assert.Equal(BootstrapScreenState.TimedOut, BootstrapScreen.Evaluate(Progress(), config, Now.AddMinutes(60)));
+    }
+
+    [Fact]
+    public void Evaluate_ZeroTimeout_WaitsForTheBootstrap()
+    {
+        var config = new BootstrapScreenConfig { TimeoutMinutes = 0 };
+
+        Assert.Equal(BootstrapScreenState.Show, BootstrapScreen.Evaluate(Progress(), config, Now.AddDays(3)));
+    }
+
+    [Fact]
+    public void Describe_NamesTheAttemptAndWhatIsRetried()
+    {
+        var progress = Progress();
+        progress.Attempts = 2;
+        progress.CompletedItems = ["Chrome", "Firefox"];
+        progress.Remaining = ["Office", "Teams", "VPN", "Zoom"];
+
+        Assert.Equal("Attempt 2. 2 installed so far. Retrying Office, Teams, VPN and 1 more.", BootstrapScreen.Describe(progress));
+    }
+
+    [Fact]
+    public void Describe_BeforeTheFirstRun_GettingReady()
+    {
+        Assert.Equal("Getting ready...", BootstrapScreen.Describe(Progress()));
+    }
+
+    [Fact]
+    public void Load_ReadsTheKeysAndIgnoresTheRest()
+    {
+        var path = Path.Combine(TestDir, "Config.yaml");
+        File.WriteAllText(path, "SoftwareRepoURL: https://repo.example.com\nBootstrapScreen: true\nBootstrapScreenTimeoutMinutes: 30\nBootstrapScreenAllowSkip: true\n");
+
+        var config = BootstrapScreenConfig.Load(path);
+
+        Assert.True(config.Enabled);
+        Assert.Equal(30, config.TimeoutMinutes);
+        Assert.True(config.AllowSkip);
+    }
+
+    [Fact]
+    public void Load_MissingFile_ScreenOff()
+    {
+        var config = BootstrapScreenConfig.Load(Path.Combine(TestDir, "missing.yaml"));
+
+        Assert.False(config.Enabled);
+        Assert.Equal(240, config.TimeoutMinutes);
+    }
+}
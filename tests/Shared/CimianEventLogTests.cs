@@ -0,0 +1,64 @@
+using System.Diagnostics;
+using Cimian.Core.Models;
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+public class CimianEventLogTests
+{
+    [Theory]
+    [InlineData("install", "completed", CimianEventId.InstallSucceeded, EventLogEntryType.Information)]
+    [InlineData("install", "failed", CimianEventId.InstallFailed, EventLogEntryType.Error)]
+    [InlineData("uninstall", "completed", CimianEventId.UninstallSucceeded, EventLogEntryType.Information)]
+    [InlineData("uninstall", "failed", CimianEventId.UninstallFailed, EventLogEntryType.Error)]
+    [InlineData("install", "blocked", CimianEventId.InstallBlocked, EventLogEntryType.Warning)]
+    public void Classify_InstallResults(string action, string status, int id, EventLogEntryType type)
+    {
+        var entry = CimianEventLog.Classify(new LogEvent { EventType = "install", Action = action, Status = status });
+
+        Assert.Equal((id, type), entry);
+    }
+
+    [Fact]
+    public void Classify_BlockingAppDeferralIsBlockedInstall()
+    {
+        var entry = CimianEventLog.Classify(new LogEvent
+        {
+            EventType = "status_check",
+            Status = "deferred",
+            StatusReasonCode = StatusReasonCode.BlockingApps
+        });
+
+        Assert.Equal(CimianEventId.InstallBlocked, entry?.Id);
+    }
+
+    [Fact]
+    public void Classify_SecurityAndHashEvents()
+    {
+        Assert.Equal(CimianEventId.DownloadOriginBlocked,
+            CimianEventLog.Classify(new LogEvent { EventType = "security", Action = "download", Status = "blocked" })?.Id);
+        Assert.Equal((CimianEventId.SelfUpdateRefused, EventLogEntryType.Error),
+            CimianEventLog.Classify(new LogEvent { EventType = "security", Action = "self_update", Status = "blocked" }));
+        Assert.Equal(CimianEventId.HashValidationFailed,
+            CimianEventLog.Classify(new LogEvent { EventType = "hash_validation", Action = "install", Status = "failed" })?.Id);
+    }
+
+    [Theory]
+    [InlineData("install", "started")]
+    [InlineData("status_check", "pending")]
+    [InlineData("hash_validation", "warning")]
+    public void Classify_SkipsProgressAndStatusChecks(string eventType, string status)
+    {
+        Assert.Null(CimianEventLog.Classify(new LogEvent { EventType = eventType, Action = "install", Status = status }));
+    }
+
+    [Theory]
+    [InlineData("completed", CimianEventId.SessionCompleted)]
+    [InlineData("partial_failure", CimianEventId.SessionPartialFailure)]
+    [InlineData("failed", CimianEventId.SessionFailed)]
+    public void ClassifySession_MapsStatus(string status, int id)
+    {
+        Assert.Equal(id, CimianEventLog.ClassifySession(status).Id);
+    }
+}
@@ -0,0 +1,72 @@
+using System.Formats.Asn1;
+using System.Security.Cryptography;
+using System.Security.Cryptography.X509Certificates;
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+public class ClientCertificateStoreTests : StoreTestBase
+{
+    [Fact]
+    public void MatchesTemplate_ReadsV1NameAndV2Oid()
+    {
+        using var v1 = Create(NameExtension("CimianDevice"), Now.AddDays(-1), Now.AddDays(30));
+        using var v2 = Create(InfoExtension("1.3.6.1.4.1.311.21.8.1.2.3"), Now.AddDays(-1), Now.AddDays(30));
+
+        Assert.True(ClientCertificateStore.MatchesTemplate(v1, "cimiandevice"));
+        Assert.False(ClientCertificateStore.MatchesTemplate(v1, "Workstation"));
+        Assert.True(ClientCertificateStore.MatchesTemplate(v2, "1.3.6.1.4.1.311.21.8.1.2.3"));
+        Assert.False(ClientCertificateStore.MatchesTemplate(v2, "1.3.6.1.4.1.311.21.8.9"));
+    }
+
+    [Fact]
+    public void SelectByTemplate_PrefersValidCertificateThatExpiresLast()
+    {
+        using var expired = Create(NameExtension("CimianDevice"), Now.AddDays(-60), Now.AddDays(-1));
+        using var older = Create(NameExtension("CimianDevice"), Now.AddDays(-30), Now.AddDays(10));
+        using var renewed = Create(NameExtension("CimianDevice"), Now.AddDays(-1), Now.AddDays(365));
+        using var other = Create(NameExtension("WebServer"), Now.AddDays(-1), Now.AddDays(700));
+
+        var selected = ClientCertificateStore.SelectByTemplate([expired, older, renewed, other], "CimianDevice", Now);
+
+        Assert.Equal(renewed.Thumbprint, selected?.Thumbprint);
+    }
+
+    [Fact]
+    public void SelectByTemplate_SkipsCertificatesWithoutClientAuthentication()
+    {
+        using var serverOnly = Create(NameExtension("CimianDevice"), Now.AddDays(-1), Now.AddDays(30),
+            new X509EnhancedKeyUsageExtension([new Oid("1.3.6.1.5.5.7.3.1")], critical: false));
+
+        Assert.Null(ClientCertificateStore.SelectByTemplate([serverOnly], "CimianDevice", Now));
+    }
+
+    private static X509Certificate2 Create(X509Extension template, DateTime notBefore, DateTime notAfter, X509Extension? eku = null)
+    {
+        using var key = RSA.Create(2048);
+        var request = new CertificateRequest("CN=PC-0042", key, HashAlgorithmName.SHA256, RSASignaturePadding.Pkcs1);
+        request.CertificateExtensions.Add(template);
+        request.CertificateExtensions.Add(eku ?? new X509EnhancedKeyUsageExtension([new Oid("1.3.6.1.5.5.7.3.2")], critical: false));
+        return request.CreateSelfSigned(notBefore, notAfter);
+    }
+
+    private static X509Extension NameExtension(string name)
+    {
+        var writer = new AsnWriter(AsnEncodingRules.DER);
+        writer.WriteCharacterString(UniversalTagNumber.BMPString, name);
+        return new X509Extension(ClientCertificateStore.TemplateNameOid, writer.Encode(), critical: false);
+    }
+
+    private static X509Extension InfoExtension(string templateOid)
+    {
+        var writer = new AsnWriter(AsnEncodingRules.DER);
+        using (writer.PushSequence())
+        {
+            writer.WriteObjectIdentifier(templateOid);
+            writer.WriteInteger(100);
+            writer.WriteInteger(2);
+        }
+        return new X509Extension(ClientCertificateStore.TemplateInfoOid, writer.Encode(), critical: false);
+    }
+}
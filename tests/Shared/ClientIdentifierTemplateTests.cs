@@ -0,0 +1,69 @@
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// Expanding %token% placeholders in ClientIdentifier.
+/// </summary>
+public class ClientIdentifierTemplateTests
+{
+    private static readonly Dictionary<string, string?> Facts = new()
+    {
+        ["serial"] = "5CG1234XYZ",
+        ["hostname"] = "LAB-PC-01",
+        ["ou"] = "Labs",
+        ["machine_type"] = "laptop",
+        ["site"] = null
+    };
+
+    private static string? Resolve(string token) => Facts.GetValueOrDefault(token);
+
+    [Theory]
+    [InlineData("LAB-PC-01", false)]
+    [InlineData("Assigned/%serial%", true)]
+    [InlineData("100%", false)]
+    [InlineData("", false)]
+    public void IsTemplate_NeedsAPercentToken(string identifier, bool expected)
+    {
+        Assert.Equal(expected, ClientIdentifierTemplate.IsTemplate(identifier));
+    }
+
+    [Fact]
+    public void Expand_ReplacesEveryToken()
+    {
+        var expanded = ClientIdentifierTemplate.Expand("Assigned/%OU%/%machine_type%/%serial%", Resolve, out var missing);
+
+        Assert.Equal("Assigned/Labs/laptop/5CG1234XYZ", expanded);
+        Assert.Null(missing);
+    }
+
+    [Fact]
+    public void Expand_MissingValue_ReturnsNullAndNamesTheToken()
+    {
+        var expanded = ClientIdentifierTemplate.Expand("sites/%site%/%hostname%", Resolve, out var missing);
+
+        Assert.Null(expanded);
+        Assert.Equal("site", missing);
+    }
+
+    [Theory]
+    [InlineData("..", "__")]
+    [InlineData("a/b\\c", "a_b_c")]
+    [InlineData("  Labs  ", "Labs")]
+    [InlineData("   ", null)]
+    public void SanitizeSegment_KeepsValuesInsideOnePathSegment(string value, string? expected)
+    {
+        Assert.Equal(expected, ClientIdentifierTemplate.SanitizeSegment(value));
+    }
+
+    [Theory]
+    [InlineData("CN=PC01,OU=Labs,OU=Staff,DC=corp,DC=example", "Labs")]
+    [InlineData("CN=PC01,OU=Smith\\, J,DC=corp", "Smith, J")]
+    [InlineData("CN=PC01,CN=Computers,DC=corp,DC=example", null)]
+    [InlineData(null, null)]
+    public void OuFromDistinguishedName_TakesTheInnermostOu(string? dn, string? expected)
+    {
+        Assert.Equal(expected, ClientIdentifierTemplate.OuFromDistinguishedName(dn));
+    }
+}
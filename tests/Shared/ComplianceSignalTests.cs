@@ -0,0 +1,55 @@
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+public class ComplianceSignalTests : StoreTestBase
+{
+    [Fact]
+    public void Evaluate_CompliantOnlyWhenNothingIsMissing()
+    {
+        var compliant = ComplianceSignal.Evaluate(3, [], Now);
+        var missing = ComplianceSignal.Evaluate(3, ["Chrome", "chrome", "Zoom"], Now);
+
+        Assert.True(compliant.Compliant);
+        Assert.False(missing.Compliant);
+        Assert.Equal(new[] { "Chrome", "Zoom" }, missing.MissingItems);
+        Assert.Equal(3, missing.MandatoryItems);
+    }
+
+    [Fact]
+    public void Evaluate_NoncompliantConfigurationBreaksCompliance()
+    {
+        var state = ComplianceSignal.Evaluate(3, [], Now, ["TimeSync"]);
+
+        Assert.False(state.Compliant);
+        Assert.Empty(state.MissingItems);
+        Assert.Equal(new[] { "TimeSync" }, state.NoncompliantConfigurations);
+    }
+
+    [Theory]
+    [InlineData("registry", "registry", true)]
+    [InlineData("Both", "file", true)]
+    [InlineData("file", "registry", false)]
+    [InlineData("none", "file", false)]
+    [InlineData(null, "registry", false)]
+    public void Exports_MatchesModeToTarget(string? mode, string target, bool expected)
+    {
+        Assert.Equal(expected, ComplianceSignal.Exports(mode, target));
+    }
+
+    [Fact]
+    public void WriteFile_RoundTrips()
+    {
+        var path = Path.Combine(TestDir, "compliance.json");
+        var state = ComplianceSignal.Evaluate(2, ["Zoom"], Now);
+
+        ComplianceSignal.WriteFile(state, path);
+        var read = ComplianceSignal.ReadFile(path)!;
+
+        Assert.False(read.Compliant);
+        Assert.Equal(2, read.MandatoryItems);
+        Assert.Equal(new[] { "Zoom" }, read.MissingItems);
+        Assert.False(File.Exists(path + ".tmp"));
+    }
+}
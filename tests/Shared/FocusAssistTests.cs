@@ -0,0 +1,21 @@
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+public class FocusAssistTests
+{
+    [Theory]
+    [InlineData(null, null, FocusState.Available)]
+    [InlineData(5, 0, FocusState.Available)]        // accepts notifications, Focus Assist off
+    [InlineData(5, 1, FocusState.DoNotDisturb)]     // priority only
+    [InlineData(null, 2, FocusState.DoNotDisturb)]  // alarms only, read from session 0
+    [InlineData(4, 0, FocusState.Presentation)]
+    [InlineData(3, null, FocusState.FullScreen)]    // Direct3D full-screen
+    [InlineData(2, 0, FocusState.FullScreen)]       // busy: full-screen app
+    [InlineData(6, 0, FocusState.DoNotDisturb)]     // quiet time
+    public void Classify_CombinesNotificationStateAndProfile(int? notificationState, int? profile, FocusState expected)
+    {
+        Assert.Equal(expected, FocusAssist.Classify(notificationState, profile));
+    }
+}
@@ -0,0 +1,24 @@
+using Cimian.Core.Models;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+public class InstallContextTests
+{
+    [Theory]
+    [InlineData(null, "system")]
+    [InlineData("", "system")]
+    [InlineData("system", "system")]
+    [InlineData("Machine", "system")]
+    [InlineData(" User ", "user")]
+    public void Normalize_AcceptsKnownSpellings(string? value, string expected)
+    {
+        Assert.Equal(expected, InstallContext.Normalize(value));
+    }
+
+    [Fact]
+    public void Normalize_ReturnsNullForUnknown()
+    {
+        Assert.Null(InstallContext.Normalize("admin"));
+    }
+}
@@ -0,0 +1,38 @@
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// Pins what counts as "the same item name" for every name-keyed map.
+/// </summary>
+public class ItemKeyTests
+{
+    [Theory]
+    [InlineData("GoogleChrome", "googlechrome")]
+    [InlineData("  Firefox ", "firefox")]
+    [InlineData("Café Reader", "cafe reader")]
+    [InlineData("NAÏVE-Tool", "naive-tool")]
+    [InlineData("", "")]
+    [InlineData(null, "")]
+    public void Canonical_FoldsCaseAccentsAndWhitespace(string? name, string expected)
+    {
+        Assert.Equal(expected, ItemKey.Canonical(name));
+    }
+
+    [Fact]
+    public void Canonical_PrecomposedAndDecomposedAgree()
+    {
+        Assert.Equal(ItemKey.Canonical("Caf\u00e9"), ItemKey.Canonical("Cafe\u0301"));
+    }
+
+    [Fact]
+    public void Comparer_MatchesDictionaryLookups()
+    {
+        var map = new Dictionary<string, int>(ItemKey.Comparer) { ["Café Reader"] = 1 };
+
+        Assert.True(map.ContainsKey("CAFE READER"));
+        Assert.True(map.ContainsKey(" cafe reader"));
+        Assert.False(map.ContainsKey("Cafe Reader 2"));
+    }
+}
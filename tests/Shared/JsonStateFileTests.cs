@@ -0,0 +1,50 @@
+using System.Text.Json;
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// The write-then-move JSON persistence the state stores share.
+/// </summary>
+public class JsonStateFileTests : StoreTestBase
+{
+    [Fact]
+    public void Write_CreatesTheFolderAndLeavesNoTempFile()
+    {
+        var path = Path.Combine(TestDir, "reports", "state.json");
+
+        JsonStateFile.Write(path, new MaintenanceHold { Until = Now.AddHours(4), Reason = "Exams" });
+
+        Assert.Equal("Exams", JsonStateFile.Read<MaintenanceHold>(path)!.Reason);
+        Assert.False(File.Exists(path + ".tmp"));
+    }
+
+    [Fact]
+    public void Write_ReplacesTheExistingFile()
+    {
+        var path = Path.Combine(TestDir, "state.json");
+
+        JsonStateFile.Write(path, new List<string> { "Firefox", "Zoom" });
+        JsonStateFile.Write(path, new List<string> { "Chrome" });
+
+        Assert.Equal(["Chrome"], JsonStateFile.Read<List<string>>(path)!);
+    }
+
+    [Fact]
+    public void Read_MissingFileIsNull()
+    {
+        Assert.Null(JsonStateFile.Read<MaintenanceHold>(Path.Combine(TestDir, "missing.json")));
+    }
+
+    [Fact]
+    public void TryRead_CorruptFileIsNullButReadThrows()
+    {
+        Directory.CreateDirectory(TestDir);
+        var path = Path.Combine(TestDir, "state.json");
+        File.WriteAllText(path, "{ not json");
+
+        Assert.Null(JsonStateFile.TryRead<MaintenanceHold>(path));
+        Assert.ThrowsAny<JsonException>(() => JsonStateFile.Read<MaintenanceHold>(path));
+    }
+}
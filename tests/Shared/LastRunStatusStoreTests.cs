@@ -0,0 +1,135 @@
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// Outcome classification and round-tripping of the last-run status.json.
+/// </summary>
+public class LastRunStatusStoreTests : IDisposable
+{
+    private readonly string _testDir;
+
+    public LastRunStatusStoreTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "LastRunStatus", Guid.NewGuid().ToString());
+        Directory.CreateDirectory(_testDir);
+    }
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    [Theory]
+    [InlineData("completed", 3, 0, "success")]
+    [InlineData("completed", 0, 0, "success")]
+    [InlineData("partial_failure", 2, 1, "partial")]
+    [InlineData("partial_failure", 0, 2, "failure")]
+    [InlineData("failed", 0, 1, "failure")]
+    public void ClassifyOutcome_MapsSessionStatus(string status, int successes, int failures, string expected)
+    {
+        Assert.Equal(expected, LastRunStatusStore.ClassifyOutcome(status, successes, failures));
+    }
+
+    [Fact]
+    public void FromSession_Partial_DescribesCounts()
+    {
+        var summary = new SessionLogSummary { TotalActions = 3, Installs = 2, Updates = 1, Successes = 2, Failures = 1 };
+
+        var status = LastRunStatusStore.FromSession("2026-01-05-0930", "auto", DateTime.Now.AddMinutes(-5), DateTime.Now,
+            "partial_failure", summary);
+
+        Assert.Equal("partial", status.Outcome);
+        Assert.Equal("2 of 3 item(s) succeeded, 1 failed", status.Message);
+        Assert.Equal(2, status.Installs);
+        Assert.Equal(1, status.Updates);
+    }
+
+    [Fact]
+    public void FromSession_NoActions_ReportsUpToDate()
+    {
+        var status = LastRunStatusStore.FromSession("s", "auto", DateTime.Now, DateTime.Now, "completed", new SessionLogSummary());
+
+        Assert.Equal("success", status.Outcome);
+        Assert.Equal("Software is up to date", status.Message);
+    }
+
+    [Fact]
+    public void FromSession_Degraded_SaysRepoWasUnreachable()
+    {
+        var summary = new SessionLogSummary { TotalActions = 1, Installs = 1, Successes = 1, Degraded = true };
+
+        var status = LastRunStatusStore.FromSession("s", "auto", DateTime.Now, DateTime.Now, "completed", summary);
+
+        Assert.Equal("success", status.Outcome);
+        Assert.True(status.Degraded);
+        Assert.Equal("1 item(s) processed successfully (offline: software repository unreachable)", status.Message);
+    }
+
+    [Fact]
+    public void FromSession_ExplicitMessage_Wins()
+    {
+        var status = LastRunStatusStore.FromSession("s", "auto", DateTime.Now, DateTime.Now, "failed",
+            new SessionLogSummary { Failures = 1 }, "Update failed: manifest not found");
+
+        Assert.Equal("failure", status.Outcome);
+        Assert.Equal("Update failed: manifest not found", status.Message);
+    }
+
+    [Fact]
+    public void WriteThenRead_RoundTrips()
+    {
+        var path = Path.Combine(_testDir, "status.json");
+        var written = new LastRunStatus { Outcome = "success", SessionId = "abc", RunType = "manual", Successes = 4, Message = "ok" };
+
+        LastRunStatusStore.Write(written, path);
+        var read = LastRunStatusStore.Read(path);
+
+        Assert.NotNull(read);
+        Assert.Equal("success", read!.Outcome);
+        Assert.Equal("abc", read.SessionId);
+        Assert.Equal(4, read.Successes);
+        Assert.False(File.Exists(path + ".tmp"));
+    }
+
+    [Fact]
+    public void FromSession_CarriesReleaseNotes()
+    {
+        var notes = new[] { new LastRunReleaseNote { Name = "Chrome", DisplayName = "Google Chrome", Version = "130.0", Notes = "Tab groups sync." } };
+
+        var status = LastRunStatusStore.FromSession("s", "auto", DateTime.Now, DateTime.Now, "completed",
+            new SessionLogSummary { TotalActions = 1, Updates = 1, Successes = 1 }, releaseNotes: notes);
+        var path = Path.Combine(_testDir, "status.json");
+        LastRunStatusStore.Write(status, path);
+        var note = Assert.Single(LastRunStatusStore.Read(path)!.ReleaseNotes);
+
+        Assert.Equal("What's new in Google Chrome 130.0", note.Heading);
+        Assert.Equal("Tab groups sync.", note.Notes);
+    }
+
+    [Fact]
+    public void Read_MissingOrCorrupt_ReturnsNull()
+    {
+        var path = Path.Combine(_testDir, "status.json");
+        Assert.Null(LastRunStatusStore.Read(path));
+
+        File.WriteAllText(path, "{ not json");
+        Assert.Null(LastRunStatusStore.Read(path));
+    }
+
+    [Fact]
+    public void Deferral_DescribeListsWhatIsLeft()
+    {
+        var deferral = new LastRunDeferral
+        {
+            Name = "Zoom",
+            Version = "6.1",
+            DeferralsUsed = 1,
+            MaxDeferrals = 3,
+            ForceInstallAfter = new DateTime(2026, 11, 1)
+        };
+
+        Assert.Equal("Zoom 6.1 postponed (2 of 3 deferrals left, installs by 2026-11-01)", deferral.Describe());
+    }
+}
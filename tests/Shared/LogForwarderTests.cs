@@ -0,0 +1,66 @@
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// Parsing of helper-process log lines arriving on the session log pipe.
+/// </summary>
+public class LogForwarderTests
+{
+    [Fact]
+    public void Parse_JsonLine_ReadsLevelSourceAndMessage()
+    {
+        var record = LogForwarder.Parse("""{"level":"warning","source":"userworker","message":"profile locked"}""");
+
+        Assert.NotNull(record);
+        Assert.Equal("WARN", record!.Level);
+        Assert.Equal("userworker", record.Source);
+        Assert.Equal("profile locked", record.Message);
+        Assert.Null(record.Event);
+    }
+
+    [Fact]
+    public void Parse_JsonLineWithEvent_KeepsStructuredEvent()
+    {
+        var record = LogForwarder.Parse(
+            """{"source":"plugin","event":{"event_type":"install","package_name":"Firefox","status":"completed"}}""");
+
+        Assert.NotNull(record?.Event);
+        Assert.Equal("install", record!.Event!.EventType);
+        Assert.Equal("Firefox", record.Event.PackageName);
+        Assert.Equal("INFO", record.Level);
+    }
+
+    [Fact]
+    public void Parse_PlainText_BecomesInfoFromHelper()
+    {
+        var record = LogForwarder.Parse("  copying files...  ");
+
+        Assert.NotNull(record);
+        Assert.Equal("INFO", record!.Level);
+        Assert.Equal("helper", record.Source);
+        Assert.Equal("copying files...", record.Message);
+    }
+
+    [Fact]
+    public void Parse_BlankLine_IsDropped()
+    {
+        Assert.Null(LogForwarder.Parse("   "));
+    }
+
+    [Fact]
+    public void TryConnectFromEnvironment_NoPipeExported_ReturnsNull()
+    {
+        var previous = Environment.GetEnvironmentVariable(LogForwarder.PipeEnvVar);
+        Environment.SetEnvironmentVariable(LogForwarder.PipeEnvVar, null);
+        try
+        {
+            Assert.Null(ForwardedLogWriter.TryConnectFromEnvironment("test"));
+        }
+        finally
+        {
+            Environment.SetEnvironmentVariable(LogForwarder.PipeEnvVar, previous);
+        }
+    }
+}
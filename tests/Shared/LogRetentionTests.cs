@@ -0,0 +1,112 @@
+using System.IO.Compression;
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// Which session logs LogRetention keeps, compresses and deletes.
+/// </summary>
+public class LogRetentionTests : IDisposable
+{
+    private const long Mb = 1024 * 1024;
+    private static readonly DateTime Now = new(2026, 3, 31, 12, 0, 0);
+
+    private readonly string _testDir;
+
+    public LogRetentionTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "LogRetention", Guid.NewGuid().ToString());
+        Directory.CreateDirectory(_testDir);
+    }
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    private static LogSession Session(int daysAgo, long size = Mb, bool compressed = false) =>
+        new($"session-{daysAgo}", Now.AddDays(-daysAgo), size, compressed);
+
+    [Fact]
+    public void Plan_KeepsNewestSessionsCompressesRecentAndDeletesExpired()
+    {
+        var sessions = new[] { Session(0), Session(1), Session(5), Session(20, compressed: true), Session(40) };
+
+        var (compress, delete) = LogRetention.Plan(sessions, new LogRetentionPolicy(30, 2, 0), Now);
+
+        Assert.Equal(["session-5"], compress.Select(s => s.Path));
+        Assert.Equal(["session-40"], delete.Select(s => s.Path));
+    }
+
+    [Fact]
+    public void Plan_NewestSessionsSurviveRetentionDays()
+    {
+        var sessions = new[] { Session(60), Session(90) };
+
+        var (compress, delete) = LogRetention.Plan(sessions, new LogRetentionPolicy(30, 1, 0), Now);
+
+        Assert.Empty(compress);
+        Assert.Equal(["session-90"], delete.Select(s => s.Path));
+    }
+
+    [Fact]
+    public void Plan_MaxLogSizeDeletesOldestFirst()
+    {
+        var sessions = new[] { Session(0, 4 * Mb), Session(1, 4 * Mb), Session(2, 4 * Mb), Session(3, 4 * Mb) };
+
+        var (compress, delete) = LogRetention.Plan(sessions, new LogRetentionPolicy(0, 1, 9), Now);
+
+        Assert.Equal(["session-1"], compress.Select(s => s.Path));
+        Assert.Equal(["session-3", "session-2"], delete.Select(s => s.Path));
+    }
+
+    [Fact]
+    public void Prune_ZipsOlderSessionsAndSkipsTheActiveOne()
+    {
+        var active = WriteSession("2026-03-31", "1200");
+        WriteSession("2026-03-31", "0900");
+        var older = WriteSession("2026-03-20", "1430");
+        var expired = WriteSession("2026-01-02", "0800");
+        File.WriteAllText(Path.Combine(_testDir, "cimiwatcher.log"), "watcher");
+
+        var result = LogRetention.Prune(_testDir, new LogRetentionPolicy(30, 1, 0), Now, active);
+
+        Assert.Equal(1, result.Compressed);
+        Assert.Equal(1, result.Deleted);
+        Assert.True(Directory.Exists(active));
+        Assert.True(Directory.Exists(Path.Combine(_testDir, "2026-03-31", "0900")));
+        Assert.False(Directory.Exists(older));
+        using (var zip = ZipFile.OpenRead(older + ".zip"))
+        {
+            Assert.Contains(zip.Entries, e => e.FullName == "session.json");
+        }
+        Assert.False(Directory.Exists(Path.GetDirectoryName(expired)));
+        Assert.True(File.Exists(Path.Combine(_testDir, "cimiwatcher.log")));
+    }
+
+    [Fact]
+    public void FindSessions_ReadsZippedAndCollisionSessions()
+    {
+        WriteSession("2026-03-30", "1015_2");
+        var zipped = WriteSession("2026-03-30", "1015");
+        ZipFile.CreateFromDirectory(zipped, zipped + ".zip");
+        Directory.Delete(zipped, true);
+
+        var sessions = LogRetention.FindSessions(_testDir).OrderBy(s => s.Started).ToList();
+
+        Assert.Equal(2, sessions.Count);
+        Assert.True(sessions[0].Compressed);
+        Assert.Equal(new DateTime(2026, 3, 30, 10, 15, 0), sessions[0].Started);
+        Assert.False(sessions[1].Compressed);
+    }
+
+    private string WriteSession(string day, string time)
+    {
+        var dir = Path.Combine(_testDir, day, time);
+        Directory.CreateDirectory(dir);
+        File.WriteAllText(Path.Combine(dir, "session.json"), "{}");
+        File.WriteAllText(Path.Combine(dir, "install.log"), "installing");
+        return dir;
+    }
+}
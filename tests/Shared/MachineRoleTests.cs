@@ -0,0 +1,61 @@
+using Cimian.Core.Models;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+public class MachineRoleTests
+{
+    [Theory]
+    [InlineData(null, "workstation")]
+    [InlineData("", "workstation")]
+    [InlineData(" Kiosk ", "kiosk")]
+    [InlineData("SERVER", "server")]
+    [InlineData("lab", "lab")]
+    public void Normalize_AcceptsKnownRoles(string? value, string expected)
+    {
+        Assert.Equal(expected, MachineRole.Normalize(value));
+    }
+
+    [Fact]
+    public void Normalize_ReturnsNullForUnknown()
+    {
+        Assert.Null(MachineRole.Normalize("desktop"));
+        Assert.Null(RestartPolicy.Normalize("later"));
+    }
+
+    [Fact]
+    public void Defaults_WorkstationAndUnknownChangeNothing()
+    {
+        Assert.Empty(MachineRole.Defaults(MachineRole.Workstation));
+        Assert.Empty(MachineRole.Defaults("desktop"));
+    }
+
+    [Fact]
+    public void Defaults_ServerNeverRestartsOrShowsUi()
+    {
+        var defaults = MachineRole.Defaults("server");
+
+        Assert.Equal(RestartPolicy.Never, defaults["RestartPolicy"]);
+        Assert.Equal(false, defaults["ShowStatusWindow"]);
+        Assert.Equal(false, defaults["ShowNotifications"]);
+        Assert.Equal(false, defaults["ShowTrayIcon"]);
+    }
+
+    [Fact]
+    public void ResolveBool_ExplicitValueBeatsRole()
+    {
+        Assert.False(MachineRole.ResolveBool("kiosk", "ShowStatusWindow", null, true));
+        Assert.True(MachineRole.ResolveBool("kiosk", "ShowStatusWindow", true, true));
+        Assert.True(MachineRole.ResolveBool("workstation", "ShowStatusWindow", null, true));
+    }
+
+    [Theory]
+    [InlineData(null, 0, 300)]
+    [InlineData("countdown", 0, 300)]
+    [InlineData("immediate", 0, 60)]
+    [InlineData("immediate", 15, 900)]
+    public void RestartPolicy_GraceSeconds(string? policy, int configuredMinutes, int expected)
+    {
+        Assert.Equal(expected, RestartPolicy.GraceSeconds(policy, configuredMinutes));
+    }
+}
@@ -0,0 +1,104 @@
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// The maintenance hold (maintenance_hold.json) set by managedsoftwareupdate --pause
+/// and cimitrigger pause.
+/// </summary>
+public class MaintenanceHoldStoreTests : StoreTestBase
+{
+    private readonly string _path;
+
+    public MaintenanceHoldStoreTests()
+    {
+        _path = Path.Combine(TestDir, "reports", "maintenance_hold.json");
+    }
+
+    [Theory]
+    [InlineData("90m", 90)]
+    [InlineData("4h", 240)]
+    [InlineData(" 2D ", 2880)]
+    public void ParseUntil_Duration_CountsFromNow(string value, int minutes)
+    {
+        Assert.Equal(Now.AddMinutes(minutes), MaintenanceHoldStore.ParseUntil(value, Now));
+    }
+
+    [Fact]
+    public void ParseUntil_DateTime_IsTakenAsLocalTime()
+    {
+        Assert.Equal(new DateTime(2026, 10, 20, 8, 0, 0), MaintenanceHoldStore.ParseUntil("2026-10-20 08:00", Now));
+    }
+
+    [Theory]
+    [InlineData("soon")]
+    [InlineData("4w")]
+    [InlineData("")]
+    public void ParseUntil_Garbage_ReturnsNull(string value)
+    {
+        Assert.Null(MaintenanceHoldStore.ParseUntil(value, Now));
+    }
+
+    [Fact]
+    public void Set_HoldsUntilItsEndTime()
+    {
+        MaintenanceHoldStore.Set(Now.AddHours(4), " Quarter-end close ", @"CONTOSO\admin", Now, _path);
+
+        var hold = MaintenanceHoldStore.Read(Now.AddHours(1), _path);
+        Assert.NotNull(hold);
+        Assert.Equal("Quarter-end close", hold!.Reason);
+        Assert.Equal(@"Automatic updates paused until 2026-10-16 13:00: Quarter-end close (CONTOSO\admin)", hold.Describe());
+
+        Assert.Null(MaintenanceHoldStore.Read(Now.AddHours(5), _path));
+    }
+
+    [Fact]
+    public void Expire_RemovesOnlyAnEndedHold()
+    {
+        MaintenanceHoldStore.Set(Now.AddHours(4), null, @"CONTOSO\admin", Now, _path);
+
+        Assert.Null(MaintenanceHoldStore.Expire(Now.AddHours(1), _path));
+        Assert.True(File.Exists(_path));
+
+        var ended = MaintenanceHoldStore.Expire(Now.AddHours(4), _path);
+        Assert.Equal(@"CONTOSO\admin", ended!.SetBy);
+        Assert.False(File.Exists(_path));
+    }
+
+    [Fact]
+    public void Clear_ReturnsTheActiveHold()
+    {
+        MaintenanceHoldStore.Set(Now.AddDays(1), "Exams", @"CONTOSO\admin", Now, _path);
+
+        Assert.Equal("Exams", MaintenanceHoldStore.Clear(Now, _path)!.Reason);
+        Assert.Null(MaintenanceHoldStore.Clear(Now, _path));
+    }
+
+    [Theory]
+    [InlineData(-1, true)]
+    [InlineData(0, true)]
+    [InlineData(60, false)]
+    [InlineData(30 * 24 * 60, false)]
+    [InlineData(30 * 24 * 60 + 1, true)]
+    public void Set_EndMustBeAheadAndWithinMaxDuration(int minutes, bool rejected)
+    {
+        var until = Now.AddMinutes(minutes);
+
+        Assert.Equal(rejected, MaintenanceHoldStore.Validate(until, Now) != null);
+        if (rejected)
+        {
+            Assert.Throws<ArgumentException>(() => MaintenanceHoldStore.Set(until, null, "admin", Now, _path));
+        }
+    }
+
+    [Theory]
+    [InlineData(true, false, false, true)]
+    [InlineData(true, false, true, false)]
+    [InlineData(true, true, false, false)]
+    [InlineData(false, false, false, false)]
+    public void AppliesTo_OnlyUnattendedAutoRuns(bool auto, bool bootstrap, bool showStatus, bool held)
+    {
+        Assert.Equal(held, MaintenanceHoldStore.AppliesTo(auto, bootstrap, showStatus));
+    }
+}
@@ -0,0 +1,94 @@
+using System.Security.Cryptography;
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// Signing and verifying manifests and catalogs (MetadataSigningKeys / RequireSignedMetadata).
+/// </summary>
+public class MetadataSignatureTests
+{
+    private const string Catalog = "generation: 1700000000000\nitems:\n- name: Firefox\n  version: 128.0\n";
+
+    private static AsymmetricAlgorithm PublicKey(AsymmetricAlgorithm key) => MetadataSignature.LoadKey(key switch
+    {
+        ECDsa ecdsa => ecdsa.ExportSubjectPublicKeyInfoPem(),
+        RSA rsa => rsa.ExportRSAPublicKeyPem(),
+        _ => throw new NotSupportedException()
+    });
+
+    public static TheoryData<string> KeyTypes => new() { "ecdsa", "rsa" };
+
+    private static AsymmetricAlgorithm CreateKey(string type) =>
+        type == "ecdsa" ? ECDsa.Create(ECCurve.NamedCurves.nistP256) : RSA.Create(2048);
+
+    [Theory]
+    [MemberData(nameof(KeyTypes))]
+    public void Verify_DetachedSignatureFromPinnedKey_IsValid(string type)
+    {
+        using var key = CreateKey(type);
+        var signature = MetadataSignature.Sign(Catalog, key);
+
+        Assert.Equal(MetadataSignatureStatus.Valid, MetadataSignature.Verify(Catalog, signature, [PublicKey(key)]));
+    }
+
+    [Fact]
+    public void Verify_TamperedContent_IsInvalid()
+    {
+        using var key = CreateKey("ecdsa");
+        var signature = MetadataSignature.Sign(Catalog, key);
+
+        var tampered = Catalog.Replace("128.0", "1.0");
+
+        Assert.Equal(MetadataSignatureStatus.Invalid, MetadataSignature.Verify(tampered, signature, [PublicKey(key)]));
+    }
+
+    [Fact]
+    public void Verify_SignedByAnotherKey_IsInvalid()
+    {
+        using var key = CreateKey("ecdsa");
+        using var other = CreateKey("rsa");
+        var signature = MetadataSignature.Sign(Catalog, other);
+
+        Assert.Equal(MetadataSignatureStatus.Invalid, MetadataSignature.Verify(Catalog, signature, [PublicKey(key)]));
+        Assert.Equal(MetadataSignatureStatus.Invalid, MetadataSignature.Verify(Catalog, "not base64!", [PublicKey(key)]));
+    }
+
+    [Fact]
+    public void Verify_NoSignature_IsUnsigned()
+    {
+        using var key = CreateKey("ecdsa");
+
+        Assert.Equal(MetadataSignatureStatus.Unsigned, MetadataSignature.Verify(Catalog, null, [PublicKey(key)]));
+        Assert.Equal(MetadataSignatureStatus.Unsigned, MetadataSignature.Verify(Catalog, "  \n", [PublicKey(key)]));
+    }
+
+    [Fact]
+    public void Verify_EmbeddedSignatureCoversTheLinesAboveIt()
+    {
+        using var key = CreateKey("ecdsa");
+        var signed = $"{Catalog}{MetadataSignature.EmbeddedPrefix} {MetadataSignature.Sign(Catalog, key)}\n";
+
+        var signature = MetadataSignature.ExtractEmbedded(signed, out var signedContent);
+
+        Assert.NotNull(signature);
+        Assert.Equal(Catalog, signedContent);
+        Assert.Equal(MetadataSignatureStatus.Valid, MetadataSignature.Verify(signed, null, [PublicKey(key)]));
+        Assert.Equal(MetadataSignatureStatus.Invalid, MetadataSignature.Verify(signed.Replace("Firefox", "Malware"), null, [PublicKey(key)]));
+    }
+
+    [Fact]
+    public void ExtractEmbedded_WithoutSignatureLine_ReturnsNull()
+    {
+        Assert.Null(MetadataSignature.ExtractEmbedded(Catalog, out var signedContent));
+        Assert.Equal(Catalog, signedContent);
+    }
+
+    [Fact]
+    public void LoadKey_RejectsSomethingThatIsNotAKey()
+    {
+        Assert.Throws<CryptographicException>(() =>
+            MetadataSignature.LoadKey("-----BEGIN PUBLIC KEY-----\nbm90IGEga2V5\n-----END PUBLIC KEY-----"));
+    }
+}
@@ -0,0 +1,81 @@
+using System.Net;
+using System.Text.RegularExpressions;
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+public class ProxySelectorTests
+{
+    private static ProxySettings Settings(string? url = null, string? pac = null, bool useSystem = true, params string[] bypass) =>
+        new(url, bypass, useSystem, pac, null, null, false);
+
+    [Theory]
+    [InlineData("http://proxy:8080", null, true, "manual")]
+    [InlineData(null, "http://wpad/proxy.pac", true, "pac")]
+    [InlineData("http://proxy:8080", "http://wpad/proxy.pac", true, "manual")]
+    [InlineData(null, null, true, "system")]
+    [InlineData(null, null, false, "direct")]
+    public void Mode_FollowsPrecedence(string? url, string? pac, bool useSystem, string expected)
+    {
+        Assert.Equal(expected, Settings(url, pac, useSystem).Mode);
+    }
+
+    [Fact]
+    public void Describe_NamesUserButNotPassword()
+    {
+        var settings = new ProxySettings("http://proxy:8080", ["*.corp"], true, null, "svc-cimian", "s3cret", false);
+
+        var description = settings.Describe();
+
+        Assert.Equal("manual http://proxy:8080 (bypass *.corp), authenticating as svc-cimian", description);
+        Assert.DoesNotContain("s3cret", description);
+    }
+
+    [Theory]
+    [InlineData("*.corp.example.com", "https://repo.corp.example.com/catalogs/Production.yaml", true)]
+    [InlineData("*.corp.example.com", "https://corp.example.com.evil.net/", false)]
+    [InlineData("10.*", "http://10.1.2.3:8080/pkgs/x.msi", true)]
+    [InlineData("https://cdn.example.com", "https://cdn.example.com/chrome.msi", true)]
+    [InlineData("cdn.example.com", "https://www.example.com/", false)]
+    public void ToBypassRegex_MatchesHostWildcards(string pattern, string url, bool expected)
+    {
+        Assert.Equal(expected, Regex.IsMatch(url, ProxySelector.ToBypassRegex(pattern), RegexOptions.IgnoreCase));
+    }
+
+    [Theory]
+    [InlineData("proxy1:8080;proxy2:8080", "https", "http://proxy1:8080/")]
+    [InlineData("http=web:80;https=secure:8443", "https", "http://secure:8443/")]
+    [InlineData("http=web:80", "https", "http://web/")]
+    [InlineData("", "https", null)]
+    public void ParseWinHttpProxyList_PicksEntryForScheme(string list, string scheme, string? expected)
+    {
+        Assert.Equal(expected, ProxySelector.ParseWinHttpProxyList(list, scheme)?.ToString());
+    }
+
+    [Fact]
+    public void Apply_ManualProxyHonorsBypassAndRecordsRoutes()
+    {
+        using var handler = new HttpClientHandler();
+
+        ProxySelector.Apply(handler, Settings("http://proxy.example.com:8080", bypass: ["*.corp.example.com"]));
+
+        Assert.Equal(new Uri("http://proxy.example.com:8080/"), handler.Proxy!.GetProxy(new Uri("https://repo.example.com/")));
+        Assert.True(handler.Proxy.IsBypassed(new Uri("https://repo.corp.example.com/")));
+        Assert.Equal("DIRECT", ProxySelector.Routes["repo.corp.example.com"]);
+    }
+
+    [Fact]
+    public void Apply_DirectDisablesProxyAndUsesConfiguredCredentials()
+    {
+        using var direct = new HttpClientHandler();
+        using var authenticated = new HttpClientHandler();
+
+        ProxySelector.Apply(direct, Settings(useSystem: false));
+        ProxySelector.Apply(authenticated, new ProxySettings("http://proxy:8080", [], true, null, "svc-cimian", "s3cret", false));
+
+        Assert.False(direct.UseProxy);
+        var credential = Assert.IsType<NetworkCredential>(authenticated.Proxy!.Credentials);
+        Assert.Equal("svc-cimian", credential.UserName);
+    }
+}
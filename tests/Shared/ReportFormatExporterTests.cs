@@ -0,0 +1,102 @@
+using System.Xml.Linq;
+using Xunit;
+using Cimian.Core.Models;
+using Cimian.Core.Services;
+using SessionData = Cimian.Core.Services.SessionData;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// Tests for the MunkiReport (ManagedInstallReport.plist) and osquery report formats.
+/// </summary>
+public class ReportFormatExporterTests : IDisposable
+{
+    private const string SessionId = "2026-10-16-0900";
+    private readonly string _testDir;
+
+    public ReportFormatExporterTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "ReportFormats", Guid.NewGuid().ToString());
+        Directory.CreateDirectory(_testDir);
+    }
+
+    public void Dispose()
+    {
+        try
+        {
+            if (Directory.Exists(_testDir))
+            {
+                Directory.Delete(_testDir, recursive: true);
+            }
+        }
+        catch { /* Ignore cleanup errors */ }
+    }
+
+    private static ReportSnapshot Snapshot() => new(
+        new SessionData
+        {
+            SessionId = SessionId,
+            RunType = "auto",
+            Status = "partial_failure",
+            StartTime = "2026-10-16T09:00:00.0000000+00:00",
+            EndTime = "2026-10-16T09:05:00.0000000+00:00",
+            Summary = new SessionLogSummary { Installs = 2, Failures = 1 }
+        },
+        [
+            new ItemRecord { ItemName = "Chrome", DisplayName = "Google Chrome", CurrentStatus = "Installed", InstalledVersion = "130.0", LatestVersion = "130.0", LastSeenInSession = SessionId, LastAttemptTime = "2026-10-16T09:02:00Z" },
+            new ItemRecord { ItemName = "Zoom", CurrentStatus = "Error", LatestVersion = "6.2", LastSeenInSession = SessionId, LastError = "exit code 1603" },
+            new ItemRecord { ItemName = "Slack", CurrentStatus = "Pending", LatestVersion = "4.41" }
+        ],
+        [
+            new LogEvent { Level = "ERROR", EventType = "install", Status = "failed", PackageName = "Zoom", Message = "Install failed", Error = "exit code 1603" }
+        ],
+        new Dictionary<string, object?> { ["hostname"] = "LAB-PC-01", ["os_version"] = "10.0.26100", ["free_disk_gb"] = 100.0 },
+        "LAB-PC-01",
+        "2026.10.16.0900");
+
+    private static XElement Value(XElement dict, string key) =>
+        (XElement)dict.Elements("key").Single(k => k.Value == key).NextNode!;
+
+    [Fact]
+    public void ManagedInstallReport_HasMunkiKeys()
+    {
+        var dict = ReportFormatExporter.BuildManagedInstallReport(Snapshot()).Root!.Element("dict")!;
+
+        Assert.Equal("LAB-PC-01", Value(dict, "ManifestName").Value);
+        Assert.Equal("2026-10-16 09:00:00 +0000", Value(dict, "StartTime").Value);
+        Assert.Equal("Zoom: exit code 1603", Value(dict, "Errors").Element("string")!.Value);
+        Assert.Equal(3, Value(dict, "ManagedInstalls").Elements("dict").Count());
+        Assert.Equal("Slack", Value(Value(dict, "ItemsToInstall").Element("dict")!, "name").Value);
+        Assert.Equal("exit code 1603", Value(Value(dict, "ProblemInstalls").Element("dict")!, "note").Value);
+        Assert.Equal((100L * 1024 * 1024).ToString(), Value(dict, "AvailableDiskSpace").Value);
+
+        var results = Value(dict, "InstallResults").Elements("dict").ToList();
+        Assert.Equal(2, results.Count);
+        Assert.Equal("0", Value(results[0], "status").Value);
+        Assert.Equal("2026-10-16T09:02:00Z", Value(results[0], "time").Value);
+        Assert.Equal("1", Value(results[1], "status").Value);
+    }
+
+    [Fact]
+    public void OsquerySnapshot_HasFlatTables()
+    {
+        var snapshot = ReportFormatExporter.BuildOsquerySnapshot(Snapshot());
+
+        var info = Assert.Single(snapshot["cimian_info"]!.AsArray())!;
+        Assert.Equal("partial_failure", info["status"]!.GetValue<string>());
+        Assert.Equal(3, snapshot["cimian_items"]!.AsArray().Count);
+        var error = Assert.Single(snapshot["cimian_errors"]!.AsArray())!;
+        Assert.Equal("Zoom", error["package"]!.GetValue<string>());
+        Assert.Equal("exit code 1603", error["message"]!.GetValue<string>());
+    }
+
+    [Fact]
+    public void Write_OnlyWritesRequestedFormats()
+    {
+        ReportFormatExporter.Write(_testDir, ["MunkiReport", "unknown"], Snapshot());
+
+        Assert.True(File.Exists(Path.Combine(_testDir, ReportFormatExporter.MunkiReportFileName)));
+        Assert.False(File.Exists(Path.Combine(_testDir, ReportFormatExporter.OsqueryFileName)));
+        Assert.Contains("<!DOCTYPE plist", File.ReadAllText(Path.Combine(_testDir, ReportFormatExporter.MunkiReportFileName)));
+    }
+}
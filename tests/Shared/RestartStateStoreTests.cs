@@ -0,0 +1,62 @@
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// The restart owed after a run (restart.json) that CimianStatus counts down.
+/// </summary>
+public class RestartStateStoreTests : IDisposable
+{
+    private readonly string _testDir;
+    private readonly string _path;
+
+    public RestartStateStoreTests()
+    {
+        _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "RestartState", Guid.NewGuid().ToString());
+        _path = Path.Combine(_testDir, "restart.json");
+    }
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    [Fact]
+    public void Read_RoundTripsUntilTheMachineRestarts()
+    {
+        var recorded = new DateTime(2026, 10, 1, 12, 0, 0);
+        RestartStateStore.Write(new RestartState
+        {
+            RecordedAt = recorded,
+            Policy = "countdown",
+            ScheduledFor = recorded.AddMinutes(5),
+            Items = ["Zoom"],
+            PendingReasons = [PendingReboot.FileRenameOperations]
+        }, _path);
+
+        var beforeBoot = RestartStateStore.Read(_path, lastBoot: recorded.AddDays(-1));
+        Assert.NotNull(beforeBoot);
+        Assert.Equal(new[] { "Zoom" }, beforeBoot!.Items);
+        Assert.Equal(recorded.AddMinutes(5), beforeBoot.ScheduledFor);
+
+        Assert.Null(RestartStateStore.Read(_path, lastBoot: recorded.AddMinutes(10)));
+    }
+
+    [Fact]
+    public void Read_MissingFile_ReturnsNull()
+    {
+        Assert.Null(RestartStateStore.Read(_path));
+    }
+
+    [Fact]
+    public void Describe_CountsDownOrAsks()
+    {
+        var now = new DateTime(2026, 10, 1, 12, 0, 0);
+        var scheduled = new RestartState { ScheduledFor = now.AddSeconds(272) };
+        var prompt = new RestartState { Items = ["Zoom", "Office"] };
+
+        Assert.Equal("Restarting in 4:32 to finish installing updates", scheduled.Describe(now));
+        Assert.Equal("Restart required to finish installing Zoom, Office", prompt.Describe(now));
+    }
+}
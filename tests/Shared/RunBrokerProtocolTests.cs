@@ -0,0 +1,160 @@
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// Request validation and wire format for the CimianWatcher run broker.
+/// </summary>
+public class RunBrokerProtocolTests
+{
+    [Theory]
+    [InlineData("gui", "--auto --show-status -vv")]
+    [InlineData("HEADLESS", "--auto --show-status")]
+    [InlineData(" checkonly ", "--checkonly --show-status")]
+    [InlineData("Check", "--auto --show-status")]
+    public void BuildArguments_KnownMode_MapsToFixedArguments(string mode, string expected)
+    {
+        var args = RunBrokerProtocol.BuildArguments(new RunBrokerRequest { Mode = mode }, out var error);
+
+        Assert.Equal(expected, args);
+        Assert.Empty(error);
+    }
+
+    [Fact]
+    public void BuildArguments_UnknownMode_IsRejected()
+    {
+        var args = RunBrokerProtocol.BuildArguments(new RunBrokerRequest { Mode = "--installonly" }, out var error);
+
+        Assert.Null(args);
+        Assert.Contains("Unknown mode", error);
+    }
+
+    [Fact]
+    public void BuildArguments_Items_AppendedAsItemFilters()
+    {
+        var request = new RunBrokerRequest { Mode = "headless", Items = ["Firefox", "Visual Studio Code"] };
+
+        var args = RunBrokerProtocol.BuildArguments(request, out _);
+
+        Assert.Equal("--auto --show-status --item \"Firefox\" \"Visual Studio Code\"", args);
+    }
+
+    [Fact]
+    public void BuildArguments_StatusPort_Appended()
+    {
+        var request = new RunBrokerRequest { Mode = "gui", Items = ["Firefox"], StatusPort = 19848 };
+
+        var args = RunBrokerProtocol.BuildArguments(request, out _);
+
+        Assert.Equal("--auto --show-status -vv --item \"Firefox\" --status-port 19848", args);
+    }
+
+    [Theory]
+    [InlineData(0)]
+    [InlineData(445)]
+    [InlineData(70000)]
+    public void BuildArguments_OutOfRangeStatusPort_IsRejected(int port)
+    {
+        var args = RunBrokerProtocol.BuildArguments(new RunBrokerRequest { Mode = "checkonly", StatusPort = port }, out var error);
+
+        Assert.Null(args);
+        Assert.Contains("Invalid status port", error);
+    }
+
+    [Theory]
+    [InlineData("Firefox\" --bootstrap \"")]
+    [InlineData("a;calc.exe")]
+    [InlineData("")]
+    public void BuildArguments_UnsafeItemName_IsRejected(string item)
+    {
+        var request = new RunBrokerRequest { Mode = "gui", Items = [item] };
+
+        var args = RunBrokerProtocol.BuildArguments(request, out var error);
+
+        Assert.Null(args);
+        Assert.Contains("Invalid item name", error);
+    }
+
+    [Fact]
+    public void DeferRequest_IsRecognizedAndNeverBuildsArguments()
+    {
+        var request = new RunBrokerRequest { Mode = " Defer ", Items = ["Zoom"] };
+
+        Assert.True(RunBrokerProtocol.IsDeferRequest(request));
+        Assert.True(RunBrokerProtocol.ValidateItems(request, out _));
+        Assert.Null(RunBrokerProtocol.BuildArguments(request, out var error));
+        Assert.Contains("Unknown mode", error);
+    }
+
+    [Fact]
+    public void Serialize_RoundTripsResponse()
+    {
+        var json = RunBrokerProtocol.Serialize(new RunBrokerResponse { Accepted = true, Message = "Run started", ProcessId = 4242 });
+
+        Assert.Contains("\"process_id\":4242", json);
+        var parsed = RunBrokerProtocol.Deserialize<RunBrokerResponse>(json);
+        Assert.NotNull(parsed);
+        Assert.True(parsed!.Accepted);
+        Assert.Equal(4242, parsed.ProcessId);
+    }
+
+    [Theory]
+    [InlineData(null)]
+    [InlineData("")]
+    [InlineData("not json")]
+    public void Deserialize_Garbage_ReturnsNull(string? line)
+    {
+        Assert.Null(RunBrokerProtocol.Deserialize<RunBrokerRequest>(line));
+    }
+
+    [Theory]
+    [InlineData("status", true)]
+    [InlineData(" Pause ", true)]
+    [InlineData("resume", true)]
+    [InlineData("headless", false)]
+    [InlineData("defer", false)]
+    public void ScheduleRequests_AreRecognized(string mode, bool schedule)
+    {
+        Assert.Equal(schedule, RunBrokerProtocol.IsScheduleRequest(new RunBrokerRequest { Mode = mode }));
+    }
+
+    [Theory]
+    [InlineData("check", null, true)]
+    [InlineData("checkonly", null, true)]
+    [InlineData("defer", "Zoom", true)]
+    [InlineData(" Status ", null, true)]
+    [InlineData("headless", "Zoom", true)]
+    [InlineData("gui", "Zoom", true)]
+    [InlineData("headless", null, false)]
+    [InlineData("gui", null, false)]
+    [InlineData("pause", null, false)]
+    [InlineData("resume", null, false)]
+    [InlineData("bogus", null, false)]
+    public void IsUserRequest_AllowsOnlyChecksAndSelfService(string mode, string? item, bool allowed)
+    {
+        var request = new RunBrokerRequest { Mode = mode, Items = item == null ? null : [item] };
+
+        Assert.Equal(allowed, RunBrokerProtocol.IsUserRequest(request));
+    }
+
+    [Fact]
+    public void Serialize_RoundTripsPauseAndSchedule()
+    {
+        var until = new DateTime(2026, 10, 20, 8, 0, 0);
+        var request = RunBrokerProtocol.Deserialize<RunBrokerRequest>(
+            RunBrokerProtocol.Serialize(new RunBrokerRequest { Mode = RunBrokerProtocol.PauseMode, Until = until }));
+        var json = RunBrokerProtocol.Serialize(new RunBrokerResponse
+        {
+            Accepted = true,
+            Schedule = new ScheduleStatus { Enabled = true, IntervalMinutes = 60, PausedUntil = until, WaitingForNetwork = true }
+        });
+
+        Assert.Equal(until, request!.Until);
+        Assert.Contains("\"paused_until\"", json);
+        var schedule = RunBrokerProtocol.Deserialize<RunBrokerResponse>(json)!.Schedule!;
+        Assert.Equal(60, schedule.IntervalMinutes);
+        Assert.True(schedule.WaitingForNetwork);
+        Assert.Null(schedule.NextRun);
+    }
+}
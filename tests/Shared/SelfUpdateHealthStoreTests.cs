@@ -0,0 +1,64 @@
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// The self-update health record (selfupdate_health.json) and which versions it holds back.
+/// </summary>
+public class SelfUpdateHealthStoreTests : StoreTestBase
+{
+    private readonly string _path;
+
+    public SelfUpdateHealthStoreTests()
+    {
+        _path = Path.Combine(TestDir, "selfupdate_health.json");
+    }
+
+    [Fact]
+    public void Begin_RecordsPendingCheck()
+    {
+        SelfUpdateHealthStore.Begin("Cimian", "2026.10.20", "Beta", "2026.10.1", Now, _path);
+
+        var health = SelfUpdateHealthStore.Read(_path);
+        Assert.NotNull(health);
+        Assert.Equal(SelfUpdateHealth.Pending, health.Status);
+        Assert.Equal("beta", health.Channel);
+        Assert.Equal("2026.10.1", health.PreviousVersion);
+        Assert.Equal(0, health.Checks);
+    }
+
+    [Fact]
+    public void IsRolledBack_OnlyForTheRolledBackVersion()
+    {
+        var health = SelfUpdateHealthStore.Begin("Cimian", "2026.10.20", "stable", "2026.10.1", Now, _path);
+        Assert.False(SelfUpdateHealthStore.IsRolledBack("Cimian", "2026.10.20", _path));
+
+        health.Status = SelfUpdateHealth.RolledBack;
+        SelfUpdateHealthStore.Save(health, _path);
+
+        Assert.True(SelfUpdateHealthStore.IsRolledBack("cimian", "2026.10.20", _path));
+        Assert.False(SelfUpdateHealthStore.IsRolledBack("Cimian", "2026.10.21", _path));
+    }
+
+    [Fact]
+    public void Read_MissingOrCorrupt_IsNull()
+    {
+        Assert.Null(SelfUpdateHealthStore.Read(_path));
+
+        Directory.CreateDirectory(TestDir);
+        File.WriteAllText(_path, "{ not json");
+        Assert.Null(SelfUpdateHealthStore.Read(_path));
+        Assert.False(SelfUpdateHealthStore.IsRolledBack("Cimian", "2026.10.20", _path));
+    }
+
+    [Fact]
+    public void Clear_RemovesTheRecord()
+    {
+        SelfUpdateHealthStore.Begin("Cimian", "2026.10.20", "stable", null, Now, _path);
+
+        SelfUpdateHealthStore.Clear(_path);
+
+        Assert.Null(SelfUpdateHealthStore.Read(_path));
+    }
+}
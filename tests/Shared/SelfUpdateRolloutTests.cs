@@ -0,0 +1,56 @@
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// Self-update channels and staged rollout: which Cimian builds a machine takes.
+/// </summary>
+public class SelfUpdateRolloutTests
+{
+    [Theory]
+    [InlineData(null, "stable", true)]
+    [InlineData("stable", "dev", true)]
+    [InlineData("beta", "dev", true)]
+    [InlineData("dev", "dev", true)]
+    [InlineData("beta", "stable", false)]
+    [InlineData("dev", "beta", false)]
+    [InlineData("Beta", " BETA ", true)]
+    [InlineData("canary", "dev", false)]
+    [InlineData("canary", "canary", true)]
+    public void AcceptsChannel_OwnAndMoreStableChannels(string? build, string configured, bool accepted)
+    {
+        Assert.Equal(accepted, SelfUpdateRollout.AcceptsChannel(build, configured));
+    }
+
+    [Fact]
+    public void Bucket_IsStableAndInRange()
+    {
+        var bucket = SelfUpdateRollout.Bucket("6f1c2a90-4b1e-4a55-9d0c-0b7e3f2d8a11");
+
+        Assert.InRange(bucket, 0, 99);
+        Assert.Equal(bucket, SelfUpdateRollout.Bucket(" 6F1C2A90-4B1E-4A55-9D0C-0B7E3F2D8A11 "));
+    }
+
+    [Fact]
+    public void Bucket_SpreadsMachinesAcrossBuckets()
+    {
+        var buckets = Enumerable.Range(0, 1000).Select(i => SelfUpdateRollout.Bucket($"machine-{i}")).ToList();
+
+        // Roughly a tenth of machines land under 10
+        Assert.InRange(buckets.Count(b => b < 10), 50, 150);
+        Assert.True(buckets.Distinct().Count() > 90);
+    }
+
+    [Theory]
+    [InlineData(null, 99, true)]
+    [InlineData(100, 99, true)]
+    [InlineData(10, 9, true)]
+    [InlineData(10, 10, false)]
+    [InlineData(0, 0, false)]
+    [InlineData(-5, 0, false)]
+    public void InRollout_BucketsBelowThePercentage(int? percent, int bucket, bool included)
+    {
+        Assert.Equal(included, SelfUpdateRollout.InRollout(percent, bucket));
+    }
+}
@@ -0,0 +1,73 @@
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// The tray icon's badge and tooltip from status.json, InstallInfo and restart.json.
+/// </summary>
+public class TrayStatusTests : StoreTestBase
+{
+    private static LastRunStatus Run(string outcome, string message = "") =>
+        new() { Outcome = outcome, Message = message, EndTime = Now.AddHours(-1) };
+
+    [Fact]
+    public void Evaluate_NothingPendingIsUpToDate()
+    {
+        var status = TrayStatus.Evaluate(Run("success"), [], null, running: false, Now);
+
+        Assert.Equal(TrayStatusKind.UpToDate, status.Kind);
+        Assert.Equal(0, status.PendingCount);
+        Assert.StartsWith("Cimian: up to date", status.ToolTip);
+    }
+
+    [Fact]
+    public void Evaluate_CountsPendingUpdates()
+    {
+        var status = TrayStatus.Evaluate(Run("partial"), ["Zoom", "Google Chrome"], null, running: false, Now);
+
+        Assert.Equal(TrayStatusKind.UpdatesPending, status.Kind);
+        Assert.Equal(2, status.PendingCount);
+        Assert.Equal("Cimian: 2 updates pending", status.ToolTip);
+    }
+
+    [Fact]
+    public void Evaluate_MaintenanceHoldNamedInToolTip()
+    {
+        var hold = new MaintenanceHold { Until = Now.AddDays(2), Reason = "Exams" };
+
+        var status = TrayStatus.Evaluate(Run("success"), ["Zoom"], null, running: false, Now, hold);
+
+        Assert.Equal(TrayStatusKind.UpdatesPending, status.Kind);
+        Assert.Equal($"Cimian: 1 pending, automatic updates paused until {Now.AddDays(2):g}", status.ToolTip);
+        Assert.Equal(TrayStatusKind.RestartRequired,
+            TrayStatus.Evaluate(Run("success"), [], new RestartState(), running: false, Now, hold).Kind);
+    }
+
+    [Fact]
+    public void Evaluate_FailedRunBeatsPendingAndRestart()
+    {
+        var status = TrayStatus.Evaluate(Run("failure", "Repo unreachable"), ["Zoom"], new RestartState(), running: false, Now);
+
+        Assert.Equal(TrayStatusKind.Error, status.Kind);
+        Assert.Equal("Cimian: Repo unreachable", status.ToolTip);
+    }
+
+    [Fact]
+    public void Evaluate_RestartBeatsPending()
+    {
+        var status = TrayStatus.Evaluate(Run("success"), ["Zoom"], new RestartState { Items = ["Office"] }, running: false, Now);
+
+        Assert.Equal(TrayStatusKind.RestartRequired, status.Kind);
+    }
+
+    [Fact]
+    public void Evaluate_RunningWinsAndToolTipFitsNotifyIcon()
+    {
+        Assert.Equal(TrayStatusKind.Running,
+            TrayStatus.Evaluate(Run("failure"), ["Zoom"], null, running: true, Now).Kind);
+
+        var status = TrayStatus.Evaluate(Run("failure", new string('x', 300)), [], null, running: false, Now);
+        Assert.Equal(TrayStatus.MaxToolTipLength, status.ToolTip.Length);
+    }
+}
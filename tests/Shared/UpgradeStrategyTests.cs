@@ -0,0 +1,26 @@
+using Cimian.Core.Models;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+public class UpgradeStrategyTests
+{
+    [Theory]
+    [InlineData(null, "in-place")]
+    [InlineData("", "in-place")]
+    [InlineData("in-place", "in-place")]
+    [InlineData("InPlace", "in-place")]
+    [InlineData("uninstall_first", "uninstall-first")]
+    [InlineData(" Uninstall-First ", "uninstall-first")]
+    [InlineData("side-by-side", "side-by-side")]
+    public void Normalize_AcceptsKnownSpellings(string? value, string expected)
+    {
+        Assert.Equal(expected, UpgradeStrategy.Normalize(value));
+    }
+
+    [Fact]
+    public void Normalize_ReturnsNullForUnknown()
+    {
+        Assert.Null(UpgradeStrategy.Normalize("replace"));
+    }
+}
@@ -0,0 +1,91 @@
+using System.Text.Json;
+using Cimian.Core.Models;
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+public class UsageReportsTests : IDisposable
+{
+    private readonly string _testDir = Path.Combine(Path.GetTempPath(), "CimianTests", "UsageReports", Guid.NewGuid().ToString());
+
+    public void Dispose()
+    {
+        try { Directory.Delete(_testDir, true); } catch { }
+    }
+
+    private static UsageReport Report(string machine, DateTime generatedAt, params (string Name, string Version)[] installed) => new()
+    {
+        Machine = machine,
+        GeneratedAt = generatedAt,
+        Installed = installed.Select(i => new UsageInstalledItem { Name = i.Name, Version = i.Version }).ToList()
+    };
+
+    [Fact]
+    public void MachineLabel_Anonymous_IsStableHashNotTheName()
+    {
+        var label = UsageReports.MachineLabel("LAB-PC-042", anonymous: true);
+
+        Assert.StartsWith("anon-", label);
+        Assert.DoesNotContain("LAB", label, StringComparison.OrdinalIgnoreCase);
+        Assert.Equal(label, UsageReports.MachineLabel("lab-pc-042", anonymous: true));
+        Assert.Equal("LAB-PC-042", UsageReports.MachineLabel("LAB-PC-042", anonymous: false));
+    }
+
+    [Fact]
+    public void Aggregate_CountsMachinesPerVersion_UsingNewestReportPerMachine()
+    {
+        var day = new DateTime(2026, 10, 16);
+        var summary = UsageReports.Aggregate(
+        [
+            Report("PC1", day, ("Chrome", "129.0")),
+            Report("PC1", day.AddDays(1), ("Chrome", "130.0"), ("Zoom", "6.2")),
+            Report("PC2", day, ("chrome", "130.0")),
+            Report("PC3", day, ("Chrome", "129.0"))
+        ]);
+
+        Assert.Equal(3, summary.Machines);
+        var chrome = summary.Items[0];
+        Assert.Equal("Chrome", chrome.Name);
+        Assert.Equal(3, chrome.MachineCount);
+        Assert.Equal(new[] { "PC1", "PC2" }, chrome.Versions["130.0"]);
+        Assert.Equal(new[] { "PC3" }, chrome.Versions["129.0"]);
+        Assert.Equal(1, summary.Items.Single(i => i.Name == "Zoom").MachineCount);
+    }
+
+    [Fact]
+    public void Aggregate_GroupsDownloadsBySourceAndComputesCacheHitRate()
+    {
+        var report = Report("PC1", DateTime.UtcNow);
+        report.Downloads =
+        [
+            new UsageDownload { Item = "Chrome", Source = "https://cdn.example.com", Success = true, Bytes = 100, DurationMs = 200 },
+            new UsageDownload { Item = "Zoom", Source = "https://cdn.example.com", Success = false, DurationMs = 400 },
+            new UsageDownload { Item = "Git", Source = "cache", CacheHit = true, Success = true, Bytes = 50 }
+        ];
+
+        var summary = UsageReports.Aggregate([report]);
+
+        var cdn = summary.Sources[0];
+        Assert.Equal("https://cdn.example.com", cdn.Source);
+        Assert.Equal(2, cdn.Downloads);
+        Assert.Equal(1, cdn.Failures);
+        Assert.Equal(300, cdn.AverageDurationMs);
+        Assert.Equal(0.333, summary.CacheHitRate);
+    }
+
+    [Fact]
+    public void Load_ReadsUsageFilesAndSkipsBrokenOnes()
+    {
+        Directory.CreateDirectory(Path.Combine(_testDir, "PC1"));
+        File.WriteAllText(Path.Combine(_testDir, "PC1", "usage.json"),
+            JsonSerializer.Serialize(Report("PC1", DateTime.UtcNow, ("Chrome", "130.0"))));
+        File.WriteAllText(Path.Combine(_testDir, "PC2-usage.json"), "{ not json");
+        File.WriteAllText(Path.Combine(_testDir, "items.json"), "[]");
+
+        var reports = UsageReports.Load(_testDir);
+
+        Assert.Single(reports);
+        Assert.Equal("Chrome", reports[0].Installed[0].Name);
+    }
+}
@@ -0,0 +1,132 @@
+using Cimian.Core.Services;
+using Xunit;
+
+namespace Cimian.Tests.Shared;
+
+/// <summary>
+/// Which toasts CimianStatus raises from status.json, InstallInfo, restart.json
+/// and blocking_apps.json, and the deferral requests a toast's Defer leaves for the next run.
+/// </summary>
+public class UserNoticesTests : StoreTestBase
+{
+    private readonly string _requestsPath;
+
+    public UserNoticesTests()
+    {
+        _requestsPath = Path.Combine(TestDir, "deferral_requests.json");
+    }
+
+    private static LastRunStatus Run(params LastRunDeferral[] deferrals) => new() { Deferrals = deferrals.ToList() };
+
+    [Fact]
+    public void Plan_WarnsBeforeDeadlineAndOffersDefer()
+    {
+        var notices = UserNotices.Plan(
+            Run(new LastRunDeferral { Name = "Google Chrome", Item = "Chrome", Version = "130.0", DeferralsUsed = 1, MaxDeferrals = 3, ForceInstallAfter = Now.AddHours(6) }),
+            [], null, Now);
+
+        var notice = Assert.Single(notices);
+        Assert.Equal(UserNoticeKind.ForcedInstall, notice.Kind);
+        Assert.Equal("Chrome", notice.Item);
+        Assert.True(notice.CanDefer);
+        Assert.Contains("will be installed after", notice.Message);
+    }
+
+    [Fact]
+    public void Plan_SkipsDeadlinesOutsideTheWarningWindow()
+    {
+        var notices = UserNotices.Plan(
+            Run(new LastRunDeferral { Name = "Zoom", Version = "6.2", ForceInstallAfter = Now.AddDays(3) }),
+            [], null, Now, forcedInstallWarningHours: 24);
+
+        Assert.Empty(notices);
+    }
+
+    [Fact]
+    public void Plan_NoDeferOnceDeferralsAreUsedUp()
+    {
+        var notices = UserNotices.Plan(
+            Run(new LastRunDeferral { Name = "Zoom", Version = "6.2", DeferralsUsed = 3, MaxDeferrals = 3 }),
+            [], null, Now);
+
+        var notice = Assert.Single(notices);
+        Assert.False(notice.CanDefer);
+        Assert.Contains("can't be postponed again", notice.Message);
+    }
+
+    [Fact]
+    public void Plan_PendingUpdatesAndRestart()
+    {
+        var restart = new RestartState { RecordedAt = Now.AddHours(-1), Items = ["Zoom"] };
+
+        var notices = UserNotices.Plan(null, ["Slack", "Chrome", "Zoom", "Teams"], restart, Now);
+
+        Assert.Equal([UserNoticeKind.RestartRequired, UserNoticeKind.UpdatesPending], notices.Select(n => n.Kind));
+        Assert.Equal("4 updates available", notices[1].Title);
+        Assert.Equal("Chrome, Slack, Teams and 1 more", notices[1].Message);
+    }
+
+    [Fact]
+    public void Plan_KeyChangesWhenADeferralIsUsed()
+    {
+        var deferral = new LastRunDeferral { Name = "Zoom", Version = "6.2", DeferralsUsed = 1, MaxDeferrals = 2, ForceInstallAfter = Now.AddHours(2) };
+        var first = Assert.Single(UserNotices.Plan(Run(deferral), [], null, Now)).Key;
+
+        deferral.DeferralsUsed = 2;
+        var second = Assert.Single(UserNotices.Plan(Run(deferral), [], null, Now)).Key;
+
+        Assert.NotEqual(first, second);
+    }
+
+    [Fact]
+    public void Plan_CloseAppsComesFirst()
+    {
+        var blocking = new BlockingAppsState
+        {
+            RecordedAt = Now,
+            WaitUntil = Now.AddMinutes(10),
+            Items = [new BlockingAppsItem { Item = "Chrome", Name = "Google Chrome", Version = "130.0", Applications = ["chrome.exe"], ForceClose = true }]
+        };
+
+        var notices = UserNotices.Plan(null, ["Zoom"], null, Now, blockingApps: blocking);
+
+        Assert.Equal([UserNoticeKind.CloseApps, UserNoticeKind.UpdatesPending], notices.Select(n => n.Kind));
+        Assert.Equal("Chrome", notices[0].Item);
+        Assert.StartsWith("Close chrome so Google Chrome 130.0 can be installed", notices[0].Message);
+        Assert.Contains("it will be closed at", notices[0].Message);
+    }
+
+    [Fact]
+    public void BlockingApps_ReadIgnoresAnExpiredWait()
+    {
+        var path = Path.Combine(TestDir, "blocking_apps.json");
+        BlockingAppsStateStore.Write(new BlockingAppsState
+        {
+            RecordedAt = Now,
+            WaitUntil = Now.AddMinutes(10),
+            Items = [new BlockingAppsItem { Item = "Chrome", Name = "Google Chrome", Version = "130.0", Applications = ["chrome.exe"] }]
+        }, path);
+
+        Assert.Equal(["chrome.exe"], BlockingAppsStateStore.Read(Now.AddMinutes(5), path)?.Items.Single().Applications);
+        Assert.Null(BlockingAppsStateStore.Read(Now.AddMinutes(11), path));
+
+        BlockingAppsStateStore.Clear(path);
+        Assert.False(File.Exists(path));
+    }
+
+    [Fact]
+    public void DeferralRequests_RefreshPerItemAndExpire()
+    {
+        DeferralRequestStore.Add(["Zoom"], @"LAB\alice", Now.AddHours(-2), _requestsPath);
+        DeferralRequestStore.Add(["Chrome", "zoom"], @"LAB\bob", Now, _requestsPath);
+
+        var requests = DeferralRequestStore.Read(Now.AddHours(1), _requestsPath);
+        Assert.Equal(["Chrome", "zoom"], requests.Select(r => r.Item));
+        Assert.All(requests, r => Assert.Equal(@"LAB\bob", r.RequestedBy));
+
+        Assert.Empty(DeferralRequestStore.Read(Now.AddDays(2), _requestsPath));
+
+        DeferralRequestStore.Clear(_requestsPath);
+        Assert.False(File.Exists(_requestsPath));
+    }
+}
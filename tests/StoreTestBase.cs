@@ -0,0 +1,23 @@
+namespace Cimian.Tests;
+
+/// <summary>
+/// Scaffold shared by the tests of the state stores and the logic that reads
+/// them: one fixed "now", and a temp directory per test that's removed
+/// afterwards. Nothing is created until a test writes into <see cref="TestDir"/>.
+/// </summary>
+public abstract class StoreTestBase : IDisposable
+{
+    protected static readonly DateTime Now = new(2026, 10, 16, 9, 0, 0);
+
+    protected StoreTestBase()
+    {
+        TestDir = Path.Combine(Path.GetTempPath(), "CimianTests", GetType().Name, Guid.NewGuid().ToString());
+    }
+
+    protected string TestDir { get; }
+
+    public void Dispose()
+    {
+        try { Directory.Delete(TestDir, true); } catch { }
+    }
+}
@@ -161,6 +161,61 @@ public class VersionServiceTests
 
     #endregion
 
+    #region Parsing Rules Tests
+
+    /// <summary>
+    /// The parsing rules documented on CompareVersions, one row per rule, across
+    /// the formats catalogs actually carry.
+    /// </summary>
+    [Theory]
+    // Date-based versions
+    [InlineData("2025.09.01", "2025.10.01", -1)]
+    [InlineData("2025.9.1", "2025.09.01", 0)]
+    [InlineData("2026.01.28", "2025.11.27", 1)]
+    // Semver vs four-part Windows versions
+    [InlineData("1.2.3", "1.2.3.0", 0)]
+    [InlineData("1.2.3", "1.2.3.1", -1)]
+    [InlineData("10.0.22631.4317", "10.0.22631", 1)]
+    // Build metadata is ignored
+    [InlineData("1.2.3+sha.5114f85", "1.2.3", 0)]
+    [InlineData("5.2.3 (git 68d178c)", "5.2.3", 0)]
+    [InlineData("10.0.26100.1 (WinBuild.160101.0800)", "10.0.26100.2", -1)]
+    // Commas separate components, as in FileVersion resources
+    [InlineData("2025, 0, 408, 54890", "2025.0.408.54890", 0)]
+    [InlineData("1,2,3,4", "1.2.3.5", -1)]
+    // Numeric dash suffixes are components, others are pre-releases
+    [InlineData("1.2.3-45", "1.2.3", 1)]
+    [InlineData("1.2.3-45", "1.2.3.46", -1)]
+    [InlineData("1.0.0-rc.2", "1.0.0-rc10", -1)]
+    [InlineData("1.0.0-dev", "1.0.0-alpha", -1)]
+    [InlineData("1.0.0-preview2", "1.0.0-rc1", -1)]
+    [InlineData("1.0.0-nightly", "1.0.0-rc1", 1)]
+    [InlineData("1.0.0-nightly", "1.0.0", -1)]
+    // Components are numeric; text tails count by their leading digits
+    [InlineData("2.45.1.windows.1", "2.45.1.windows.2", -1)]
+    [InlineData("2.45.2.windows.1", "2.45.1.windows.2", 1)]
+    [InlineData("1.3b", "1.3", 0)]
+    [InlineData("V2.0", "v1.9", 1)]
+    // Strings without digits aren't versions and never win or lose
+    [InlineData("InternalName", "1.0.0", 0)]
+    [InlineData("1.0.0", "InternalName", 0)]
+    public void CompareVersions_ParsingRules_ReturnsCorrectSign(string v1, string v2, int expectedSign)
+    {
+        VersionService.CompareVersions(v1, v2).Should().Be(expectedSign);
+        VersionService.CompareVersions(v2, v1).Should().Be(-expectedSign);
+    }
+
+    [Fact]
+    public void Comparer_SortsMixedFormatsNewestFirst()
+    {
+        var versions = new[] { "1.9", "1.10.0-beta", "1.10.0", "1.10.0.1", "v1.2", "1.10.0-alpha" };
+
+        versions.OrderByDescending(v => v, VersionService.Comparer).Should().Equal(
+            "1.10.0.1", "1.10.0", "1.10.0-beta", "1.10.0-alpha", "1.9", "v1.2");
+    }
+
+    #endregion
+
     #region CompareOsVersion Tests
 
     [Theory]
@@ -181,6 +236,10 @@ public class VersionServiceTests
     [InlineData("10.0.26200.0", "11.0.22000", 1)]    // Win 11 25H2 newer than 11.0.22000 floor
     [InlineData("10.0.22000.0", "11.0.22000", 0)]    // exact build match
     [InlineData("10.0.19045.0", "11.0.22000", -1)]   // Win 10 older than 11.0.22000
+    // The update revision (UBR) only counts when the requirement pins one.
+    [InlineData("10.0.22631.4317", "10.0.22631", 0)]       // any CU of 23H2 is within a 10.0.22631 max
+    [InlineData("10.0.22631.4169", "10.0.22631.4317", -1)] // older CU fails a CU-pinned min
+    [InlineData("10.0.22631.4460", "11.0.22631.4317", 1)]
     public void CompareOsVersion_ReturnsCorrectSign(string current, string requirement, int expectedSign)
     {
         var result = VersionService.CompareOsVersion(current, requirement);
@@ -200,6 +259,15 @@ public class VersionServiceTests
         VersionService.CompareOsVersion(win11, "11.0").Should().Be(0, "Win 11 is not newer than max 11.0");
     }
 
+    [Fact]
+    public void FormatOsVersion_UsesUpdateBuildRevisionWhenKnown()
+    {
+        var kernel = new System.Version(10, 0, 22631, 0);
+
+        VersionService.FormatOsVersion(kernel, 4317).Should().Be("10.0.22631.4317");
+        VersionService.FormatOsVersion(kernel, null).Should().Be("10.0.22631.0");
+    }
+
     #endregion
 
     #region Real-World Catalog Version Tests
@@ -11,14 +11,14 @@
 
   This module reproduces that contract so any GUI interaction can be exercised
   and asserted from the terminal -- no WinUI window, no clicking, no waiting on
-  the 10s CimianWatcher poll. Plan mode (--checkonly) verifies the *decision*
+  CimianWatcher. Plan mode (--checkonly) verifies the *decision*
   (will-be-installed / will-be-removed) in seconds without touching the system;
   -Apply performs the real install/removal only when you ask for it.
 
   Canonical contract (do not duplicate logic -- mirror it):
     GUI mutation:  shared/core/Services/SelfServiceManifestService.cs
     GUI trigger:   gui/ManagedSoftwareCenter/Services/TriggerService.cs
-    Flag args:     shared/core/Services/BootstrapArgsBuilder.cs
+    Broker wire:   shared/core/Services/RunBrokerProtocol.cs
     Engine write:  cli/managedsoftwareupdate/Services/UpdateEngine.cs (WriteInstallInfo)
     Paths:         shared/core/Services/CimianPaths.cs
 
@@ -45,7 +45,6 @@
 $script:DataDir     = 'C:\ProgramData\ManagedInstalls'
 $script:SelfServe   = Join-Path $script:DataDir 'SelfServeManifest.yaml'
 $script:InstallInfo = Join-Path $script:DataDir 'InstallInfo.yaml'
-$script:FlagFile    = Join-Path $script:DataDir '.cimian.bootstrap'
 $script:StateJson   = Join-Path $script:DataDir 'reports\state.json'
 $script:Msu         = 'C:\Program Files\Cimian\managedsoftwareupdate.exe'
 
@@ -203,30 +202,38 @@ function Show-MscState {
 # ----------------------------------------------------------------------------
 # GUI button equivalents
 # ----------------------------------------------------------------------------
-function Write-MscFlag {
-    <#  Mirrors TriggerService.WriteFlagFileAsync -- drops the bootstrap flag for
-        CimianWatcher to consume. Used by -ViaWatcher to exercise the real IPC. #>
-    param([Parameter(Mandatory)] [string] $ArgsLine)
-    $ts = Get-Date -Format 'yyyy-MM-dd HH:mm:ss'
-    "Bootstrap triggered at: $ts`nSource: MscHarness`nArgs: $ArgsLine`n" |
-        Set-Content -Path $script:FlagFile -Encoding utf8
-    Write-Host "  flag written: Args: $ArgsLine" -ForegroundColor DarkGray
-}
-
-function Wait-MscFlagConsumed {
-    param([int] $TimeoutSec = 120)
-    $deadline = (Get-Date).AddSeconds($TimeoutSec)
-    while ((Get-Date) -lt $deadline) {
-        if (-not (Test-Path $script:FlagFile)) { return $true }   # watcher deleted it = consumed
-        Start-Sleep -Milliseconds 500
+function Send-MscBrokerRequest {
+    <#  Mirrors TriggerService.RunSelfServeBatchAsync -- asks CimianWatcher's run
+        broker for a gui run of the items. Used by -ViaWatcher to exercise the real IPC. #>
+    param([Parameter(Mandatory)] [string[]] $Items)
+    $request = @{ mode = 'gui'; items = @($Items) } | ConvertTo-Json -Compress
+    $pipe = [System.IO.Pipes.NamedPipeClientStream]::new('.', 'CimianRunBroker', [System.IO.Pipes.PipeDirection]::InOut)
+    try {
+        $pipe.Connect(3000)
+        $writer = [System.IO.StreamWriter]::new($pipe, [System.Text.UTF8Encoding]::new($false), 1024, $true)
+        $writer.AutoFlush = $true
+        $reader = [System.IO.StreamReader]::new($pipe, [System.Text.Encoding]::UTF8, $false, 1024, $true)
+        $writer.WriteLine($request)
+        $response = $reader.ReadLine() | ConvertFrom-Json
+    } catch {
+        Write-Host "  CimianWatcher run broker not reachable: $($_.Exception.Message)" -ForegroundColor Red
+        return $false
+    } finally {
+        $pipe.Dispose()
+    }
+    Write-Host "  broker request: $request" -ForegroundColor DarkGray
+    if (-not $response.accepted) {
+        Write-Host "  broker refused: $($response.message)" -ForegroundColor Red
+        return $false
     }
-    return $false
+    Write-Host "  broker started managedsoftwareupdate (pid $($response.process_id))" -ForegroundColor DarkGray
+    return $true
 }
 
 function Invoke-MscInstall {
     <#  Mimics clicking "Install" on an optional item.
         Default = plan mode (--checkonly): proves the engine WILL install it, no install.
-        -Apply  = actually install.  -ViaWatcher = go through the flag-file + service. #>
+        -Apply  = actually install.  -ViaWatcher = go through the run broker + service. #>
     [CmdletBinding()]
     param([Parameter(Mandatory)] [string] $Name, [switch] $Apply, [switch] $ViaWatcher)
 
@@ -238,9 +245,7 @@ function Invoke-MscInstall {
     if (-not $Apply) { $a += '--checkonly' }
 
     if ($ViaWatcher) {
-        Write-MscFlag (($a | Where-Object { $_ -ne '--checkonly' }) -join ' ')
-        if (Wait-MscFlagConsumed) { Write-Host "  watcher consumed the flag" -ForegroundColor DarkGray }
-        else { Write-Host "  TIMEOUT waiting for CimianWatcher" -ForegroundColor Red }
+        [void](Send-MscBrokerRequest $Name)
     } else {
         $r = Invoke-Msu @a
         Write-Host "  engine exit=$($r.ExitCode) in $($r.Seconds)s" -ForegroundColor DarkGray
@@ -261,9 +266,7 @@ function Invoke-MscRemove {
     if (-not $Apply) { $a += '--checkonly' }
 
     if ($ViaWatcher) {
-        Write-MscFlag (($a | Where-Object { $_ -ne '--checkonly' }) -join ' ')
-        if (Wait-MscFlagConsumed) { Write-Host "  watcher consumed the flag" -ForegroundColor DarkGray }
-        else { Write-Host "  TIMEOUT waiting for CimianWatcher" -ForegroundColor Red }
+        [void](Send-MscBrokerRequest $Name)
     } else {
         $r = Invoke-Msu @a
         Write-Host "  engine exit=$($r.ExitCode) in $($r.Seconds)s" -ForegroundColor DarkGray
@@ -295,8 +298,7 @@ function Invoke-MscInstallNow {
     if (-not $Apply) { $a += '--checkonly' }
 
     if ($ViaWatcher) {
-        Write-MscFlag (($a | Where-Object { $_ -ne '--checkonly' }) -join ' ')
-        if (-not (Wait-MscFlagConsumed)) { Write-Host "  TIMEOUT" -ForegroundColor Red }
+        [void](Send-MscBrokerRequest $targets)
     } else {
         $r = Invoke-Msu @a
         Write-Host "  engine exit=$($r.ExitCode) in $($r.Seconds)s" -ForegroundColor DarkGray